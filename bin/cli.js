@@ -7,11 +7,100 @@ import { LLMDetector } from '../lib/utils/llm-detector.js';
 import APIServer from '../lib/api/rest/server.js';
 import FileWatcher from '../lib/watch/FileWatcher.js';
 import IncrementalAnalyzer from '../lib/watch/IncrementalAnalyzer.js';
+import ContextRegenerator, { DEFAULT_OUTPUTS } from '../lib/watch/ContextRegenerator.js';
+import StructuredFormatter, { STRUCTURED_FORMATS } from '../lib/formatters/structured-formatter.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+import { XML_TAGS, parseXmlTags } from '../lib/formatters/xml-formatter.js';
+import { FENCE_CHARS, XML_CONTENT_MODES, parseFenceChar, parseXmlContentMode } from '../lib/formatters/content-escaper.js';
 import DiffAnalyzer from '../lib/integrations/git/DiffAnalyzer.js';
-import { execSync } from 'child_process';
+import GitRevision from '../lib/integrations/git/GitRevision.js';
+import SparseCheckout from '../lib/integrations/git/SparseCheckout.js';
+import TokenBudget, { parseTokenCount, parseTiers } from '../lib/core/TokenBudget.js';
+import BudgetSimulator from '../lib/core/BudgetSimulator.js';
+import TodoHarvester, { TODO_SCOPES } from '../lib/core/TodoHarvester.js';
+import DependencySummary from '../lib/core/DependencySummary.js';
+import LicenseDetector from '../lib/core/LicenseDetector.js';
+import ContextProfiles from '../lib/core/ContextProfiles.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+import ParseCache, { DEFAULT_PARSE_CACHE_BYTES } from '../lib/cache/ParseCache.js';
+import { parseJobs } from '../lib/core/WorkerPool.js';
+import { PAIR_MODES } from '../lib/core/TestPairing.js';
+import PriorityScorer, { DEFAULT_WEIGHTS } from '../lib/core/PriorityScorer.js';
+import LineSelection from '../lib/core/LineSelection.js';
+import RiskOverlay from '../lib/core/RiskOverlay.js';
+import OutputTarget, { OUTPUT_TARGETS } from '../lib/core/OutputTarget.js';
+import ContextEncryptor from '../lib/core/ContextEncryptor.js';
+import ContextAnonymizer, { ANONYMIZE_FILE } from '../lib/core/ContextAnonymizer.js';
+import { ERROR_REPORT_FILE } from '../lib/core/ErrorReport.js';
+import ContextLayout, { LAYOUT_SECTIONS } from '../lib/core/ContextLayout.js';
+import ContextFreshness from '../lib/core/ContextFreshness.js';
+import ContextComparer from '../lib/core/ContextComparer.js';
+import ContextMerger from '../lib/core/ContextMerger.js';
+import ContextWriter, { writeFileAtomic } from '../lib/core/ContextWriter.js';
+import ContextPack, { PACK_EXTENSION } from '../lib/core/ContextPack.js';
+import ContextManifest, { MANIFEST_SUFFIX } from '../lib/core/ContextManifest.js';
+import ContextSession, { SESSION_DIR } from '../lib/core/ContextSession.js';
+import CoverageHistory, { COVERAGE_FILE } from '../lib/core/CoverageHistory.js';
+import DecisionLog, { DECISION_FILE } from '../lib/core/DecisionLog.js';
+import ContextLinter from '../lib/core/ContextLinter.js';
+import GitClient from '../lib/integrations/git/GitClient.js';
+import PullRequest, { parsePullRequestRef, PR_PROVIDERS } from '../lib/integrations/git/PullRequest.js';
+import RemoteRepository, { FETCH_METHODS } from '../lib/integrations/git/RemoteRepository.js';
+import ArchiveSource from '../lib/integrations/artifact/ArchiveSource.js';
+import ContainerImage from '../lib/integrations/artifact/ContainerImage.js';
+import SecretRedactor, { REDACTION_FILE } from '../lib/core/SecretRedactor.js';
+import ContentPolicy, { POLICY_FILE } from '../lib/core/ContentPolicy.js';
+import ContentStripper, { STRIP_MODES } from '../lib/core/ContentStripper.js';
+import FileReader, { READ_MODES } from '../lib/core/FileReader.js';
+import Workspace, { WORKSPACE_FILE } from '../lib/core/Workspace.js';
+import ContextTemplate from '../lib/core/ContextTemplate.js';
+import Benchmark, { contextPipeline } from '../lib/core/Benchmark.js';
+import DuplicateDetector from '../lib/core/DuplicateDetector.js';
+import TokenTree from '../lib/core/TokenTree.js';
+import TreeCollapser, { DEFAULT_TREE_BUDGET } from '../lib/core/TreeCollapser.js';
+import ShellCompletion, { COMPLETION_SHELLS } from '../lib/core/ShellCompletion.js';
+import CrossReferenceIndex from '../lib/graph/CrossReferenceIndex.js';
+import UsageExamples, { DEFAULT_EXAMPLES } from '../lib/graph/UsageExamples.js';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import ModelPresets, { MODEL_PRESETS } from '../lib/core/ModelPresets.js';
+import { DOCS_MODES } from '../lib/core/ProjectDocs.js';
+import CodeOwners, { CODEOWNERS_LOCATIONS } from '../lib/core/CodeOwners.js';
+import BodyElider, { parseElisionRules } from '../lib/core/BodyElider.js';
+import TransformPipeline from '../lib/core/TransformPipeline.js';
+import PinRebalancer, { parseRebalanceSteps, REBALANCE_STEPS } from '../lib/core/PinRebalancer.js';
+import ProjectDetector from '../lib/core/ProjectDetector.js';
+import SnippetResolver from '../lib/graph/SnippetResolver.js';
+import { decodeText } from '../lib/core/FileSystem.js';
+import RemoteSummarizer from '../lib/core/RemoteSummarizer.js';
+import CommentTranslator from '../lib/core/CommentTranslator.js';
+import FileList from '../lib/core/FileList.js';
+import RelatedFiles from '../lib/graph/RelatedFiles.js';
+import TokenEstimator, { CALIBRATION_FILE } from '../lib/core/TokenEstimator.js';
+import TokenCalibrator from '../lib/core/TokenCalibrator.js';
+import TokenUtils from '../lib/utils/token-utils.js';
+import { SymbolExtractor } from '../lib/symbols/SymbolExtractor.js';
+import { SymbolKind } from '../lib/symbols/SymbolModel.js';
+import { symbolId } from '../lib/symbols/SymbolId.js';
+import SymbolIndex, { SYMBOL_INDEX_FILE } from '../lib/symbols/SymbolIndex.js';
+import SymbolOutline from '../lib/symbols/SymbolOutline.js';
+import SelectionQuery from '../lib/symbols/SelectionQuery.js';
+import ApiDiff from '../lib/symbols/ApiDiff.js';
+import SelectionTree from '../lib/ui/selection-tree.js';
+import { loadExtractors, EXTRACTORS_FILE } from '../lib/plugins/ExtractorRegistry.js';
+import LspGateway from '../lib/integrations/lsp/LspGateway.js';
+import WarmIndex from '../lib/daemon/WarmIndex.js';
+import ContextMetrics, { METRICS_PORT } from '../lib/core/ContextMetrics.js';
+import ContextDaemon from '../lib/daemon/ContextDaemon.js';
+import DaemonClient from '../lib/daemon/DaemonClient.js';
+import SemanticIndex from '../lib/rag/SemanticIndex.js';
+import GraphExporter, { GRAPH_FORMATS, GraphKind } from '../lib/graph/GraphExporter.js';
+import { EmbeddingProviderFactory, EMBEDDING_PROVIDERS } from '../lib/rag/EmbeddingProviderFactory.js';
+import { getLogger } from '../lib/utils/logger.js';
+import { execSync, spawn } from 'child_process';
 import { fileURLToPath } from 'url';
-import { dirname, resolve } from 'path';
-import { readFileSync } from 'fs';
+import { basename, dirname, isAbsolute, join, relative, resolve, sep } from 'path';
+import { existsSync, readFileSync, mkdirSync, openSync, rmSync } from 'fs';
+import { compareBytes } from '../lib/utils/ordering.js';
 
 // ESM equivalents for __dirname and __filename
 const __filename = fileURLToPath(import.meta.url);
@@ -46,6 +135,18 @@ async function main() {
         return;
     }
 
+    // Check for shell completion; before subcommands, its arguments name some (v3.4.0)
+    if (args.includes('completion')) {
+        await runCompletion(args);
+        return;
+    }
+
+    // Check for remote repository context (v3.4.0); before git, a --via value
+    if (args.includes('gen')) {
+        await runRemoteContext(args);
+        return;
+    }
+
     // Check for format conversion mode (v2.3.2)
     if (args.includes('convert')) {
         runFormatConversion(args);
@@ -69,6 +170,135 @@ async function main() {
         return;
     }
 
+    // Check for context comparison (v3.4.0)
+    if (args.includes('compare')) {
+        runContextComparison(args);
+        return;
+    }
+
+    // Check for context merging (v3.4.0)
+    if (args.includes('merge')) {
+        await runContextMerge(args);
+        return;
+    }
+
+    // Custom symbol extractors, before anything extracts symbols (v3.4.0)
+    try {
+        await loadExtractors(process.cwd());
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    if (args.includes('--list-extractors')) {
+        listExtractors();
+        return;
+    }
+
+    // Check for context packs (v3.4.0)
+    if (args.includes('pack')) {
+        await runPack(args);
+        return;
+    }
+    if (args.includes('unpack')) {
+        await runUnpack(args);
+        return;
+    }
+    if (args.includes('decrypt')) {
+        runDecrypt(args);
+        return;
+    }
+    if (args.includes('deanonymize')) {
+        runDeanonymize(args);
+        return;
+    }
+    if (args.includes('reproduce')) {
+        await runReproduce(args);
+        return;
+    }
+
+    // Check for conversation sessions (v3.4.0); --session NAME is an analysis option
+    if (args.includes('session') && args[args.indexOf('session') - 1] !== '--session') {
+        runSession(args);
+        return;
+    }
+
+    // Check for coverage report (v3.4.0)
+    if (args.includes('report')) {
+        runReport(args);
+        return;
+    }
+
+    // Check for exclusion explainer (v3.4.0); --explain prints priority scores
+    if (args.includes('explain')) {
+        runExplain(args);
+        return;
+    }
+
+    // Check for context lint (v3.4.0)
+    if (args.includes('lint')) {
+        await runContextLint(args);
+        return;
+    }
+    if (args.includes('check')) {
+        await runContextCheck(args);
+        return;
+    }
+
+    // Check for pipeline benchmark (v3.4.0)
+    if (args.includes('bench')) {
+        await runBenchmark(args);
+        return;
+    }
+
+    // Check for budget simulation (v3.4.0)
+    if (args.includes('simulate')) {
+        await runBudgetSimulation(args);
+        return;
+    }
+
+    // Check for token estimate calibration (v3.4.0)
+    if (args.includes('calibrate')) {
+        await runCalibration(args);
+        return;
+    }
+
+    // Check for daemon mode (v3.4.0)
+    if (args.includes('daemon')) {
+        await runDaemon(args);
+        return;
+    }
+
+    // Check for snippet enrichment mode (v3.4.0)
+    if (args.includes('enrich')) {
+        await runSnippetEnrichment(args);
+        return;
+    }
+
+    // Check for semantic query mode (v3.4.0)
+    if (args.includes('query')) {
+        await runSemanticQuery(args);
+        return;
+    }
+
+    // Check for editor RPC server mode (v3.4.0)
+    if (args.includes('editor')) {
+        await runEditorServer(args);
+        return;
+    }
+
+    // Check for gRPC server mode (v3.4.0)
+    if (args.includes('serve') && args.includes('--grpc')) {
+        await runGrpcServer(args);
+        return;
+    }
+
+    // Check for MCP server mode (v3.4.0)
+    if (args.includes('serve') && args.includes('--mcp')) {
+        await runMCPServer(args);
+        return;
+    }
+
     // Check for API server mode (v3.0.0)
     if (args.includes('serve')) {
         await runAPIServer(args);
@@ -81,6 +311,49 @@ async function main() {
         return;
     }
 
+    // Check for symbol queries (v3.4.0); --tiers symbols is a budget tier
+    if (args.includes('symbols') && args[args.indexOf('symbols') - 1] !== '--tiers') {
+        await runSymbols(args);
+        return;
+    }
+
+    // Check for single-file outlines (v3.4.0)
+    if (args.includes('outline')) {
+        await runOutline(args);
+        return;
+    }
+
+    // Check for call/type graph export (v3.4.0)
+    if (args.includes('graph')) {
+        await runGraphExport(args);
+        return;
+    }
+
+    // Check for context selection (v3.4.0): interactive, or by expression
+    if (args.includes('select')) {
+        const expression = args[args.indexOf('select') + 1];
+        await (expression && !expression.startsWith('-') ? runSelectionQuery(args, expression) : runContextSelector(args));
+        return;
+    }
+
+    // Check for pull request review context (v3.4.0)
+    if (args.includes('pr')) {
+        await runPullRequestContext(args);
+        return;
+    }
+
+    // Check for API change context (v3.4.0)
+    if (args.includes('api-diff')) {
+        await runApiDiff(args);
+        return;
+    }
+
+    // Check for diff-scoped context (v3.4.0)
+    if (args.includes('diff')) {
+        await runDiffContext(args);
+        return;
+    }
+
     // Check for explicit dashboard mode
     if (args.includes('--dashboard')) {
         try {
@@ -122,475 +395,4559 @@ async function main() {
 
     // Git integration: Filter to changed files only (v3.0.0)
     if (options.changedOnly || options.changedSince) {
+        if (options.rev || options.files) {
+            console.error(`❌ ${options.rev ? '--rev' : '--files'} cannot be combined with ${options.changedOnly ? '--changed-only' : '--changed-since'}`);
+            process.exit(1);
+        }
         await runChangedFilesAnalysis(options);
         return;
     }
 
+    useStdoutForContext(args, options);
+
+    await prepareAnalysisOptions(options);
+
     printStartupInfo(options);
 
-    const analyzer = new TokenAnalyzer(options.projectRoot, options);
-    analyzer.run();
+    await runAnalysis(options);
 }
 
-function parseArguments(args) {
-    return {
-        // Output options
-        saveReport: args.includes('--save-report') || args.includes('-s'),
-        verbose: args.includes('--verbose') || args.includes('-v'),
-        contextExport: args.includes('--context-export'),
-        contextToClipboard: args.includes('--context-clipboard'),
-        json: args.includes('--json'), // NEW: JSON output mode
+/**
+ * Run the analysis, spreading per-file work over --jobs worker threads (v3.4.0)
+ */
+async function runAnalysis(options) {
+    const analyzer = new TokenAnalyzer(options.projectRoot, options);
+    let result;
+    try {
+        // A warm index already skips unchanged files; workers would re-read them
+        result = await (options.jobs > 1 && !options.index ? analyzer.runParallel() : analyzer.run());
+    } finally {
+        options.lsp?.close();
+    }
+    if (options.manifest) {
+        writeContextManifests(analyzer, options);
+    }
+    return result;
+}
 
-        // Analysis options
-        methodLevel: args.includes('--method-level') || args.includes('-m'),
-        gitingest: args.includes('--gitingest') || args.includes('-g'),
+/**
+ * Sidecar manifest next to each written context (v3.4.0, --manifest)
+ */
+function writeContextManifests(analyzer, options) {
+    const written = analyzer.getOutputTarget().written;
+    if (!analyzer.exportResults || written.length === 0) {
+        console.log('⚠️  --manifest: no context file was written');
+        return;
+    }
+    const contexts = written.map(file => ({ name: basename(file), content: readFileSync(file) }));
+    const sidecars = written.map(file => resolve(ContextManifest.sidecarOf(file)));
+    const manifest = ContextManifest.create(describeRun(options.manifest, options, analyzer, contexts, [...written.map(file => resolve(file)), ...sidecars]));
+    for (const file of written) {
+        ContextManifest.write(manifest, ContextManifest.sidecarOf(file), { fsync: options.fsync, backup: options.backup });
+    }
+    console.log(`🧾 Manifest saved to: ${written.map(file => relative(process.cwd(), ContextManifest.sidecarOf(file))).join(', ')}`);
+}
 
-        // Format options (v2.3.0)
-        outputFormat: getOutputFormat(args),
+/**
+ * Context (or its path) on stdout keeps the report on stderr (v3.4.0)
+ */
+function useStdoutForContext(args, options) {
+    if ((options.structuredFormat || options.templateFile) && args.includes('--stdout')) {
+        options.outputStream = process.stdout;
+    }
+    if (options.outputStream || options.out === 'stdout' || options.printPath) {
+        console.log = (...messages) => console.error(...messages);
 
-        // LLM options (v2.3.7)
-        targetModel: getTargetModel(args),
-        autoDetectLLM: args.includes('--auto-detect-llm'),
+        // A reader that stops early (| head) ends the run, not an error
+        process.stdout.on('error', error => {
+            if (error.code !== 'EPIPE') throw error;
+            process.exit(0);
+        });
+    }
+}
 
-        // Git options (v3.0.0)
-        changedOnly: args.includes('--changed-only'),
-        changedSince: getChangedSince(args),
-        withAuthors: args.includes('--with-authors'),
-        withHistory: args.includes('--with-history'),
+/**
+ * Resolve v3.4.0 options that need async setup (cache, token budget, symbol extractor)
+ * Exits on invalid combinations.
+ */
+async function prepareAnalysisOptions(options) {
+    // Content cache (v3.4.0)
+    options.cache = createContentCache(options);
 
-        // UI options (v2.3.0)
-        simple: args.includes('--simple'),
-        dashboard: args.includes('--dashboard'),
+    // Calibrated token estimates (v3.4.0, ctxman calibrate)
+    TokenUtils.useEstimator(TokenEstimator.load(options.projectRoot, options.tokenizer));
 
-        // Chunking options (v2.3.0)
-        chunking: {
-            enabled: args.includes('--chunk'),
-            strategy: getChunkStrategy(args),
-            maxTokensPerChunk: getChunkSize(args)
-        },
+    // Token budget (v3.4.0)
+    if (options.tiers && options.maxTokens === null) {
+        console.error('❌ --tiers requires --max-tokens N');
+        process.exit(1);
+    }
+    if ((options.reserve !== null || options.reservePrompt !== null) && options.maxTokens === null) {
+        const flag = options.reservePrompt !== null ? '--reserve-prompt' : '--reserve-output';
+        console.error(`❌ ${flag} requires --max-tokens N or --model`);
+        process.exit(1);
+    }
+    const reserved = (options.reserve || 0) + (options.reservePrompt || 0);
+    if (options.maxTokens !== null && reserved >= options.maxTokens) {
+        console.error(`❌ Reserving ${reserved.toLocaleString()} tokens for the prompt and answer leaves no room in a ${options.maxTokens.toLocaleString()}-token budget`);
+        process.exit(1);
+    }
+    if (options.maxTokens !== null) {
+        try {
+            options.tokenBudget = await TokenBudget.create({
+                maxTokens: options.maxTokens,
+                reserve: options.reserve || 0,
+                reservePrompt: options.reservePrompt || 0,
+                tokenizer: options.tokenizer,
+                model: options.targetModel,
+                tiers: options.tiers,
+                exportedMethods: options.exportedMethods,
+                cache: options.cache
+            });
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
 
-        projectRoot: process.cwd()
-    };
-}
+    // Pinned files too large for the budget (v3.4.0)
+    if (options.rebalancePins && !options.tokenBudget) {
+        console.error('❌ --rebalance-pins requires --max-tokens N or --model');
+        process.exit(1);
+    }
+    if (options.rebalancePins) {
+        options.pinRebalancer = new PinRebalancer({
+            steps: options.rebalancePins,
+            countTokens: (text, filePath) => options.tokenBudget.count(text, filePath)
+        });
+    }
 
-function getOutputFormat(args) {
-    const formatIndex = args.findIndex(arg => arg === '--output' || arg === '-o');
-    if (formatIndex !== -1 && args[formatIndex + 1]) {
-        return args[formatIndex + 1];
+    // Pinned profile symbols select like --symbol unless another selection is given
+    if (options.profile?.symbols.length > 0 && options.symbols.length === 0 &&
+        !options.focus && !options.diff && !options.apiDiff && !options.query) {
+        options.symbols = options.profile.symbols;
     }
-    return 'toon'; // Default to TOON format in v2.3.0
-}
 
-function getChunkStrategy(args) {
-    const strategyIndex = args.findIndex(arg => arg === '--chunk-strategy');
-    if (strategyIndex !== -1 && args[strategyIndex + 1]) {
-        return args[strategyIndex + 1];
+    // Dependency expansion (v3.4.0)
+    if (options.expandDeps !== null && !options.focus && !options.diff) {
+        console.error('❌ --expand-deps requires --focus SYMBOL');
+        process.exit(1);
+    }
+    if (options.includeImplementations && !options.focus && options.symbols.length === 0 && !options.diff && !options.apiDiff) {
+        console.error('❌ --include-implementations requires --focus, --symbol, diff or api-diff');
+        process.exit(1);
+    }
+    if (options.usageExampleLimit !== null) {
+        if (!options.focus && options.symbols.length === 0) {
+            console.error('❌ --usage-examples requires --focus or --symbol');
+            process.exit(1);
+        }
+        options.usageExamples = new UsageExamples({ limit: options.usageExampleLimit });
+    }
+    if (options.exportedMethods && options.maxTokens === null && options.symbols.length === 0) {
+        console.error('❌ --exported-methods requires --max-tokens N or --symbol');
+        process.exit(1);
+    }
+    if (options.lspLanguages && !options.lspServer) {
+        console.error('❌ --lsp-languages requires --lsp COMMAND or --lsp tcp://host:port');
+        process.exit(1);
+    }
+    if (options.lspServer) {
+        try {
+            options.lsp = new LspGateway({
+                root: options.projectRoot,
+                spec: options.lspServer,
+                languages: options.lspLanguages
+            }).start();
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
+    if (options.symbols.length > 0 && options.focus) {
+        console.error('❌ --symbol and --focus cannot be combined');
+        process.exit(1);
+    }
+    if (options.diff && (options.focus || options.symbols.length > 0)) {
+        console.error('❌ diff cannot be combined with --focus or --symbol');
+        process.exit(1);
+    }
+    if (options.query && (options.focus || options.symbols.length > 0)) {
+        console.error('❌ query cannot be combined with --focus or --symbol');
+        process.exit(1);
+    }
+    if (options.query && options.pairTests) {
+        // Query chunks are packed into the budget before files could be paired
+        console.error('❌ query cannot be combined with --pair-tests');
+        process.exit(1);
+    }
+    if (options.query && options.docs) {
+        console.error('❌ query cannot be combined with --docs');
+        process.exit(1);
+    }
+    if (options.query && options.elideBodies.length > 0) {
+        console.error('❌ query cannot be combined with --elide-bodies');
+        process.exit(1);
+    }
+    if (options.query && options.remoteSummaries) {
+        console.error('❌ query cannot be combined with --summarize-remote');
+        process.exit(1);
+    }
+    if (options.query && options.commentTranslation) {
+        console.error('❌ query cannot be combined with --translate-comments');
+        process.exit(1);
     }
-    return 'smart'; // Default strategy
-}
 
-function getChunkSize(args) {
-    const sizeIndex = args.findIndex(arg => arg === '--chunk-size');
-    if (sizeIndex !== -1 && args[sizeIndex + 1]) {
-        return parseInt(args[sizeIndex + 1], 10);
+    // Digest layout (v3.4.0)
+    if (options.layout && options.chunking.enabled) {
+        console.error('❌ --layout arranges a single digest and cannot be combined with --chunk');
+        process.exit(1);
     }
-    return 100000; // Default 100k tokens
-}
 
-function getTargetModel(args) {
-    // Check for explicit model flag
-    const modelIndex = args.findIndex(arg => arg === '--target-model');
-    if (modelIndex !== -1 && args[modelIndex + 1]) {
-        return args[modelIndex + 1];
+    // Per-project bundles (v3.4.0): one digest or structured context per project
+    if (options.perProject) {
+        if (!options.gitingest && !options.structuredFormat) {
+            console.error('❌ --per-project requires --gitingest or --format');
+            process.exit(1);
+        }
+        if (options.chunking.enabled || options.templateFile) {
+            console.error(`❌ --per-project cannot be combined with ${options.chunking.enabled ? '--chunk' : '--template'}`);
+            process.exit(1);
+        }
+        if (options.outputStream || ['stdout', 'clipboard'].includes(options.out)) {
+            console.error(`❌ --per-project writes one file per project and needs a file target, not ${options.out || 'stdout'}`);
+            process.exit(1);
+        }
+        options.projectDetector = new ProjectDetector();
     }
 
-    // Check for auto-detect flag
-    if (args.includes('--auto-detect-llm')) {
-        const detected = LLMDetector.detect();
-        if (detected !== 'unknown') {
-            console.log(`✅ Auto-detected LLM: ${LLMDetector.getProfile(detected).name}`);
-            return detected;
+    // Encrypted contexts (v3.4.0)
+    if (options.encrypt.length > 0) {
+        if (options.outputStream) {
+            console.error('❌ --encrypt cannot be combined with --stdout (use --out stdout for armored output)');
+            process.exit(1);
+        }
+        if (options.manifest) {
+            console.error('❌ --manifest hashes the written context and cannot be combined with --encrypt');
+            process.exit(1);
+        }
+        try {
+            options.encryptor = ContextEncryptor.parse(options.encrypt);
+            options.encryptor.check();
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
         }
     }
 
-    return null; // No model specified
-}
+    // Anonymized contexts (v3.4.0)
+    if (options.anonymize) {
+        for (const [enabled, flag, reason] of [
+            [options.outputStream, '--stdout', 'use --out stdout'],
+            [options.contextToClipboard, '--context-clipboard', 'use --out clipboard'],
+            [options.manifest, '--manifest', 'a manifest lists the real source files']
+        ]) {
+            if (enabled) {
+                console.error(`❌ --anonymize cannot be combined with ${flag} (${reason})`);
+                process.exit(1);
+            }
+        }
+        const mapFile = resolve(options.projectRoot, options.anonymize);
+        try {
+            options.anonymizer = ContextAnonymizer.load(mapFile);
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+        // The mapping holds the real names and stays out of the context
+        options.excludePaths = new Set([...(options.excludePaths || []), relative(options.projectRoot, mapFile)]);
+    }
 
-function listLLMs() {
-    console.log('\n📋 Supported LLM Models (v2.3.7):\n');
-    console.log('═'.repeat(70));
+    // Output targets (v3.4.0)
+    if (options.out || options.printPath || options.encryptor || options.anonymizer) {
+        const target = options.out || 'file';
+        if (options.printPath && ['stdout', 'clipboard'].includes(target)) {
+            console.error(`❌ --print-path needs a file target (--out file, tmpfile or vscode), not ${target}`);
+            process.exit(1);
+        }
+        if (options.chunking.enabled && ['stdout', 'clipboard'].includes(target)) {
+            console.error(`❌ --chunk writes chunk files and cannot be combined with --out ${target}`);
+            process.exit(1);
+        }
+        // Without a format, the target receives a digest
+        if ((options.out || options.printPath) && !options.gitingest && !options.contextExport && !options.contextToClipboard && !options.structuredFormat && !options.templateFile) {
+            options.gitingest = true;
+        }
+        options.outputTarget = new OutputTarget({
+            target,
+            root: options.outputRoot || options.projectRoot,
+            printPath: options.printPath,
+            encryptor: options.encryptor,
+            anonymizer: options.anonymizer,
+            fsync: options.fsync,
+            backup: options.backup
+        });
+    }
 
-    const models = LLMDetector.getModelList();
+    // Provenance manifests (v3.4.0): a sidecar file next to each context
+    if (options.manifest) {
+        if (options.outputStream || ['stdout', 'clipboard'].includes(options.out)) {
+            console.error(`❌ --manifest needs a file target (--out file, tmpfile or vscode), not ${options.out || 'stdout'}`);
+            process.exit(1);
+        }
+        // Recorded, and used for the date of --template contexts
+        options.now = new Date();
+    }
 
-    // Group by vendor
-    const byVendor = {};
-    models.forEach(model => {
-        if (!byVendor[model.vendor]) {
-            byVendor[model.vendor] = [];
+    // Conversation sessions (v3.4.0): what earlier turns supplied is left out
+    if (options.sessionName) {
+        if (options.query || options.manifest) {
+            // A turn depends on the session state, which the next turn changes
+            console.error(`❌ --session cannot be combined with ${options.query ? 'query' : '--manifest'}`);
+            process.exit(1);
         }
-        byVendor[model.vendor].push(model);
-    });
+        try {
+            options.session = ContextSession.load(options.projectRoot, options.sessionName);
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
 
-    // Display by vendor
-    Object.entries(byVendor).forEach(([vendor, models]) => {
-        console.log(`\n${vendor}:`);
-        models.forEach(model => {
-            const contextDisplay = model.contextWindow >= 1000000
-                ? `${(model.contextWindow / 1000000).toFixed(1)}M`
-                : `${Math.floor(model.contextWindow / 1000)}k`;
-            console.log(`  ${model.id.padEnd(25)} ${model.name.padEnd(25)} (${contextDisplay} context)`);
+    // Content policies (v3.4.0): .ctxman/policy.json applies whenever it exists
+    try {
+        options.policy = ContentPolicy.load(options.projectRoot, options.policyFile);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    if (options.enforcePolicy) {
+        if (!options.policy) {
+            console.error(`❌ --enforce needs a policy (${POLICY_FILE} or --policy FILE)`);
+            process.exit(1);
+        }
+        options.policy.options.enforce = true;
+    }
+
+    // Sparse checkout (v3.4.0): the definition applies whenever sparse checkout is enabled
+    if (options.sparseMode !== false) {
+        try {
+            options.sparse = SparseCheckout.load(options.projectRoot, {
+                file: options.sparseMode?.file || null,
+                force: Boolean(options.sparseMode)
+            });
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
+
+    // Secret redaction (v3.4.0)
+    if (options.redact) {
+        try {
+            options.redactor = SecretRedactor.load(options.projectRoot);
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
+
+    // Comment and blank line stripping (v3.4.0)
+    if (options.keepDocs && (!options.strip || options.strip === 'blank-lines')) {
+        console.error('❌ --keep-docs requires --strip comments or --strip both');
+        process.exit(1);
+    }
+    if (options.strip) {
+        options.stripper = new ContentStripper({ mode: options.strip, keepDocs: options.keepDocs });
+    }
+
+    // Body elision (v3.4.0)
+    if (options.elideBodies.length > 0) {
+        try {
+            options.bodyElider = new BodyElider(parseElisionRules(options.elideBodies));
+        } catch (error) {
+            console.error(`❌ Invalid --elide-bodies value: ${error.message}`);
+            process.exit(1);
+        }
+    }
+
+    // Remote summaries of low-priority files (v3.4.0)
+    if (options.remoteSummaries) {
+        options.remoteSummarizer = new RemoteSummarizer({
+            ...options.remoteSummaries,
+            root: options.projectRoot,
+            cache: options.cache || undefined
         });
-    });
+        if (options.remoteSummarizer.missingKey()) {
+            console.error('❌ --summarize-remote needs an API key (set OPENAI_API_KEY) or --summary-endpoint URL');
+            process.exit(1);
+        }
+    }
 
-    console.log('\n' + '═'.repeat(70));
-    console.log('\nUsage:');
-    console.log('  ctxman --target-model <MODEL_ID>');
-    console.log('  ctxman --auto-detect-llm');
-    console.log('\nExample:');
-    console.log('  ctxman --target-model claude-sonnet-4.5');
-    console.log('  ctxman --auto-detect-llm --cli\n');
-}
+    // English translations of non-English comments (v3.4.0)
+    if (options.commentTranslation) {
+        try {
+            options.commentTranslator = new CommentTranslator({
+                ...options.commentTranslation,
+                root: options.projectRoot,
+                cache: options.cache || undefined
+            });
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+        if (options.commentTranslator.missingKey()) {
+            console.error('❌ --translate-comments needs an API key (set OPENAI_API_KEY) or --translate-endpoint URL');
+            process.exit(1);
+        }
+    }
 
-function printStartupInfo(options) {
-    console.log('🚀 Ctxman v3.0.0');
-    console.log('='.repeat(50));
+    // Token tree (v3.4.0)
+    if ((options.treeSort || options.treeDepth !== null) && !options.tree) {
+        console.error('❌ --tree-sort and --tree-depth require --tree');
+        process.exit(1);
+    }
+    if (options.tree) {
+        try {
+            options.tokenTree = new TokenTree({
+                sort: options.treeSort || 'name',
+                depth: options.treeDepth,
+                budget: options.tokenBudget ? options.tokenBudget.limit : null
+            });
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
 
-    // Only show active options if any are set
-    const hasOptions = options.outputFormat || options.methodLevel || options.chunking?.enabled ||
-        options.saveReport || options.verbose || options.contextExport ||
-        options.contextToClipboard || options.gitingest;
+    // Budget-aware directory structure of digests (v3.4.0)
+    if (options.treeBudget) {
+        options.treeCollapser = new TreeCollapser({ maxTokens: options.treeBudget });
+    }
 
-    if (hasOptions) {
-        console.log('📋 Active options:');
-        if (options.outputFormat) {
-            console.log(`  Output format: ${options.outputFormat}`);
+    // Cross-reference index (v3.4.0)
+    if (options.xref) {
+        options.crossReferences = new CrossReferenceIndex();
+    }
+
+    // TODO/FIXME markers (v3.4.0)
+    if (options.todos) {
+        options.todoHarvester = new TodoHarvester({ scope: options.todos });
+    }
+
+    // Dependency manifests (v3.4.0)
+    if (options.deps) {
+        options.dependencySummary = new DependencySummary();
+    }
+
+    // License headers and LICENSE files (v3.4.0)
+    if (options.licenses) {
+        options.licenseDetector = new LicenseDetector();
+    }
+
+    // Content transforms pipeline (v3.4.0): --transforms, or the profile's list
+    const transformSteps = options.transformSteps ?? options.profile?.transforms;
+    if (transformSteps) {
+        try {
+            new TransformPipeline(transformSteps).configure(options, options.projectRoot);
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
         }
-        if (options.methodLevel) {
-            console.log('  Method-level analysis: enabled');
+    }
+
+    // CODEOWNERS (v3.4.0)
+    if (options.owners.length > 0 || options.ownerAnnotations) {
+        options.codeOwners = CodeOwners.load(options.projectRoot);
+        if (!options.codeOwners) {
+            console.error(`❌ ${options.owners.length > 0 ? '--owner' : '--owners'} needs a CODEOWNERS file (looked for ${CODEOWNERS_LOCATIONS.join(', ')})`);
+            process.exit(1);
         }
-        if (options.saveReport) {
-            console.log('  Save report: enabled');
+        const unknown = options.owners.filter(owner => !options.codeOwners.names(owner));
+        if (unknown.length > 0) {
+            console.error(`❌ ${options.codeOwners.source} names no owner ${unknown.join(', ')} (owners: ${options.codeOwners.listOwners().join(', ') || 'none'})`);
+            process.exit(1);
         }
-        if (options.gitingest) {
-            console.log('  GitIngest format: enabled');
+    }
+
+    // Deduplication (v3.4.0)
+    if (options.dedupe !== null) {
+        options.duplicateDetector = new DuplicateDetector({ threshold: options.dedupe });
+    }
+
+    // Context templates (v3.4.0)
+    if (options.templateVars.length > 0 && !options.templateFile) {
+        console.error('❌ --var requires --template FILE');
+        process.exit(1);
+    }
+    if (options.templateFile) {
+        try {
+            options.template = ContextTemplate.load(resolve(options.projectRoot, options.templateFile));
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
         }
-        if (options.contextExport) {
-            console.log('  Context export: enabled');
+    }
+
+    // Ad-hoc file lists (v3.4.0)
+    if (options.files) {
+        const conflict = options.rev ? '--rev' : options.workspaceFile ? '--workspace' : null;
+        if (conflict) {
+            console.error(`❌ --files cannot be combined with ${conflict}`);
+            process.exit(1);
         }
-        if (options.contextToClipboard) {
-            console.log('  Copy to clipboard: enabled');
+        try {
+            options.fileList = FileList.read(options.files === '-' ? '-' : resolve(options.projectRoot, options.files));
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
         }
-        if (options.chunking?.enabled) {
-            console.log(`  Chunking: ${options.chunking.strategy} (${options.chunking.maxTokensPerChunk.toLocaleString()} tokens/chunk)`);
+        // stdin held the list; there is nothing left to answer the export prompt
+        if (options.files === '-') {
+            options.prompt = false;
         }
-        console.log();
     }
-}
+    if (options.suggestions) {
+        if (!options.fileList) {
+            console.error('❌ --suggest and --accept-suggestions rank files related to the seeds given with --files');
+            process.exit(1);
+        }
+        options.relatedFiles = new RelatedFiles(options.suggestions);
+    }
 
-function printHelp() {
-    console.log('Ctxman v3.0.0 - AI Development Platform with Plugin Architecture and Git Integration');
-    console.log();
-    console.log('Usage: ctxman [options]');
-    console.log();
-    console.log('Default Mode:');
-    console.log('  ctxman          Launch interactive wizard (DEFAULT)');
-    console.log('  --cli                    Use CLI mode instead of wizard');
-    console.log();
-    console.log('Analysis Options:');
-    console.log('  -s, --save-report        Save detailed JSON report');
-    console.log('  -v, --verbose            Show all included files');
-    console.log('  -m, --method-level       Enable method-level analysis');
-    console.log('  -g, --gitingest          Generate GitIngest-style digest');
-    console.log();
-    console.log('Output Options (v2.3.0):');
-    console.log('  -o, --output FORMAT      Output format (default: toon)');
-    console.log('                           Formats: toon, json, yaml, csv, xml, markdown, gitingest');
-    console.log('  --context-export         Generate LLM context file');
-    console.log('  --context-clipboard      Copy context to clipboard');
-    console.log('  --list-formats           List all available output formats');
-    console.log();
-    console.log('UI Options (v2.3.0):');
-    console.log('  --simple                 Simple text-based output (no fancy UI)');
-    console.log('  --dashboard              Live dashboard mode');
-    console.log('  --wizard                 Force interactive wizard mode');
-    console.log();
-    console.log('Chunking Options (v2.3.0):');
-    console.log('  --chunk                  Enable smart chunking for large repos');
-    console.log('  --chunk-strategy TYPE    Chunking strategy (smart, size, file, directory)');
-    console.log('  --chunk-size TOKENS      Max tokens per chunk (default: 100000)');
-    console.log();
-    console.log('LLM Optimization (v2.3.7):');
-    console.log('  --target-model MODEL     Optimize for specific LLM (e.g., claude-sonnet-4.5)');
-    console.log('  --auto-detect-llm        Auto-detect LLM from environment variables');
-    console.log('  --list-llms              List all supported LLM models');
-    console.log();
-    console.log('Git Integration (v3.0.0):');
-    console.log('  --changed-only           Analyze only files with uncommitted changes');
-    console.log('  --changed-since REF      Analyze files changed since commit/branch');
-    console.log('  --with-authors           Include author information');
-    console.log('  --with-history           Include commit history');
-    console.log();
-    console.log('Platform Features (v3.0.0):');
-    console.log('  serve [options]          Start REST API server');
-    console.log('    --port PORT            Server port (default: 3000)');
-    console.log('    --auth-token TOKEN     API authentication token');
-    console.log('  watch [options]          Watch mode with auto-analysis');
-    console.log('    --debounce MS          Debounce delay (default: 1000ms)');
-    console.log();
-    console.log('General Options:');
-    console.log('  -h, --help               Show this help');
-    console.log('  --version                Show version number');
-    console.log();
-    console.log('Configuration Files:');
-    console.log('  .contextinclude          Include only specified files');
-    console.log('  .contextignore           Exclude specified files');
-    console.log('  .methodinclude           Include only specified methods');
-    console.log('  .methodignore            Exclude specified methods');
-    console.log();
-    console.log('Format Conversion (v2.3.2):');
-    console.log('  convert INPUT --from FORMAT --to FORMAT');
-    console.log('                           Convert between formats');
-    console.log('  Examples:');
-    console.log('    ctxman convert report.json --from json --to toon');
-    console.log('    ctxman convert data.toon --from toon --to yaml');
-    console.log('    ctxman convert context.yaml --from yaml --to json');
-    console.log();
-    console.log('GitHub Integration (v2.3.6+):');
-    console.log('  github URL [options]     Generate GitIngest from GitHub repository');
-    console.log('  git URL [options]        Alias for github command');
-    console.log('  Examples:');
-    console.log('    ctxman github facebook/react');
-    console.log('    ctxman github https://github.com/vercel/next.js --branch canary');
-    console.log('    ctxman git angular/angular -o docs/angular.txt');
-    console.log();
-    console.log('Examples:');
-    console.log('  ctxman                                  # Launch interactive wizard (DEFAULT)');
-    console.log('  ctxman --cli                            # Use CLI mode');
-    console.log('  ctxman --cli -o json --save-report      # CLI: JSON format + save report');
-    console.log('  ctxman --cli -o toon --context-clipboard   # CLI: TOON to clipboard');
-    console.log('  ctxman --cli --gitingest --chunk        # CLI: GitIngest with chunking');
-    console.log('  ctxman --cli -m -o yaml                 # CLI: Method-level + YAML format');
-    console.log('  ctxman --cli --chunk --chunk-strategy smart   # CLI: Smart chunking');
-    console.log('  ctxman convert data.json --from json --to toon  # Convert formats');
-    console.log();
-    console.log('Format Comparison (token efficiency):');
-    console.log('  TOON:     40-50% reduction (most efficient)');
-    console.log('  JSON:     Standard format (baseline)');
-    console.log('  YAML:     Human-readable (5-10% larger than JSON)');
-    console.log('  Markdown: Documentation-friendly (20-30% larger)');
-    console.log();
-    console.log('For more information: https://github.com/hakkisagdic/ctxman');
+    // Historical context (v3.4.0)
+    if (options.rev) {
+        const conflict = options.diff ? 'diff' : options.workspaceFile ? '--workspace' : options.lspServer ? '--lsp' : null;
+        if (conflict) {
+            console.error(`❌ --rev cannot be combined with ${conflict}`);
+            process.exit(1);
+        }
+        try {
+            options.revision = GitRevision.open(options.projectRoot, options.rev);
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+        // Workers would read the working tree
+        options.jobs = 1;
+    }
+
+    // Multi-repo workspace (v3.4.0)
+    if (options.workspaceFile) {
+        if (options.diff) {
+            console.error('❌ diff cannot be combined with --workspace');
+            process.exit(1);
+        }
+        try {
+            options.workspace = options.workspaceFile === true
+                ? Workspace.load(options.projectRoot)
+                : Workspace.fromFile(resolve(options.projectRoot, options.workspaceFile));
+            if (!options.workspace) {
+                throw new Error(`No ${WORKSPACE_FILE} or go.work in ${options.projectRoot} (needed for --workspace)`);
+            }
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
+
+    // Pinned slices (v3.4.0): FILE:START-END and FILE#REGION pins narrow their files to those lines
+    const slices = options.pins.filter(pin => LineSelection.isSelector(pin));
+    if (slices.length > 0) {
+        if (options.query || options.stripper || options.licenseDetector || options.transforms) {
+            console.error(`❌ Pinned lines (${slices[0]}) cannot be combined with ${options.query ? 'query' : options.stripper ? '--strip' : options.licenseDetector ? '--licenses' : '--transforms'}`);
+            process.exit(1);
+        }
+        try {
+            options.lineSelection = new LineSelection(slices);
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+        options.pins = options.pins.filter(pin => !slices.includes(pin));
+    }
+
+    // Priority scoring (v3.4.0)
+    const riskReports = options.coverage.length > 0 || options.sarif.length > 0;
+    if (options.weights || options.pins.length > 0 || options.explain || options.entryPoints || riskReports) {
+        if (options.query) {
+            // Query chunks are ranked by relevance
+            console.error('❌ query cannot be combined with --weights, --pin, --explain, --entry-points, --coverage or --sarif');
+            process.exit(1);
+        }
+        options.priorityScorer = new PriorityScorer({ weights: options.weights || {}, pins: options.pins });
+        if (options.entryPoints && !options.priorityScorer.uses('entry')) {
+            console.error('❌ --entry-points cannot be combined with --weights entry=0');
+            process.exit(1);
+        }
+        if (riskReports && !options.priorityScorer.uses('risk')) {
+            console.error(`❌ ${options.coverage.length > 0 ? '--coverage' : '--sarif'} cannot be combined with --weights risk=0`);
+            process.exit(1);
+        }
+    }
+
+    // Risk overlay (v3.4.0): report paths are relative to the working directory
+    if (riskReports) {
+        try {
+            options.riskOverlay = RiskOverlay.load(options.projectRoot, {
+                coverage: options.coverage.map(file => resolve(file)),
+                sarif: options.sarif.map(file => resolve(file))
+            });
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
+
+    if (options.focus || options.symbols.length > 0 || options.diff || options.apiDiff || options.query || options.structuredFormat ||
+        options.priorityScorer?.uses('fanIn') || options.priorityScorer?.uses('entry')) {
+        try {
+            const initialize = () => new SymbolExtractor({ backend: options.symbolBackend }).initialize();
+            // The daemon initializes each backend once (v3.4.0)
+            options.symbolExtractor = await (options.index
+                ? options.index.memo(`extractor:${options.symbolBackend}`, initialize)
+                : initialize());
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
+
+    return options;
 }
 
-function listFormats() {
-    const registry = new FormatRegistry();
-    const formats = registry.getAllInfo();
+function parseArguments(args) {
+    const options = {
+        // Output options
+        saveReport: args.includes('--save-report') || args.includes('-s'),
+        verbose: args.includes('--verbose') || args.includes('-v'),
+        contextExport: args.includes('--context-export'),
+        contextToClipboard: args.includes('--context-clipboard'),
+        json: args.includes('--json'), // NEW: JSON output mode
 
-    console.log('📋 Available Output Formats:\n');
-    console.log('Format'.padEnd(15) + 'Description'.padEnd(50) + 'Extension');
-    console.log('='.repeat(80));
+        // Analysis options
+        methodLevel: args.includes('--method-level') || args.includes('-m'),
+        gitingest: args.includes('--gitingest') || args.includes('-g'),
+        structuredFormat: getStructuredFormat(args),
+        collapsible: args.includes('--collapsible'),
+        xmlTags: getXmlTags(args),
+        fence: getFenceChar(args),
+        xmlContent: getXmlContent(args),
+        sectionIds: args.includes('--section-ids'),
+        perProject: args.includes('--per-project'),
+        outputFile: getOutputFile(args),
 
-    for (const [name, info] of Object.entries(formats)) {
-        console.log(
-            name.padEnd(15) +
-            info.description.substring(0, 48).padEnd(50) +
-            info.extension
-        );
+        // Output targets (v3.4.0)
+        out: getOut(args),
+        printPath: args.includes('--print-path'),
+        fsync: args.includes('--fsync'),
+        backup: args.includes('--backup'),
+
+        // Format options (v2.3.0)
+        outputFormat: getOutputFormat(args),
+
+        // LLM options (v2.3.7)
+        targetModel: getTargetModel(args),
+        autoDetectLLM: args.includes('--auto-detect-llm'),
+        modelPreset: getModelPreset(args),
+
+        // Token budget options (v3.4.0)
+        maxTokens: getMaxTokens(args),
+        reserve: getAnswerReserve(args),
+        reservePrompt: getReserve(args, '--reserve-prompt'),
+        tokenizer: getTokenizer(args),
+        tiers: getTiers(args),
+        rebalancePins: getRebalancePins(args),
+
+        // Dependency expansion options (v3.4.0)
+        focus: getFocus(args),
+        expandDeps: getExpandDeps(args),
+        symbols: getSymbols(args),
+        includeImplementations: args.includes('--include-implementations'),
+        exportedMethods: args.includes('--exported-methods'),
+        symbolBackend: getSymbolBackend(args),
+        lspServer: getFlagValue(args, '--lsp'),
+        lspLanguages: getLspLanguages(args),
+
+        // Test pairing (v3.4.0)
+        pairTests: getPairTests(args),
+
+        // Usage examples of selected symbols (v3.4.0)
+        usageExampleLimit: getUsageExamples(args),
+
+        // Secret redaction (v3.4.0)
+        redact: args.includes('--redact'),
+
+        // Sparse checkout (v3.4.0)
+        sparseMode: getSparseMode(args),
+
+        // Content policies (v3.4.0)
+        policyFile: getFlagValue(args, '--policy'),
+        enforcePolicy: args.includes('--enforce'),
+
+        // Comment and blank line stripping (v3.4.0)
+        strip: getStrip(args),
+        keepDocs: args.includes('--keep-docs'),
+        transformSteps: getFlagValue(args, '--transforms'),
+        notebookOutputs: args.includes('--notebook-outputs'),
+        includeGenerated: args.includes('--include-generated'),
+
+        // Token tree (v3.4.0)
+        tree: args.includes('--tree'),
+        treeSort: getFlagValue(args, '--tree-sort'),
+        treeDepth: getBenchCount(args, '--tree-depth', null, 1),
+        treeBudget: getTreeBudget(args),
+
+        // Cross-reference index (v3.4.0)
+        xref: args.includes('--xref'),
+
+        // TODO/FIXME markers (v3.4.0)
+        todos: getTodos(args),
+
+        // Dependency manifests (v3.4.0)
+        deps: args.includes('--deps'),
+
+        // License headers removed, one licensing note appended (v3.4.0)
+        licenses: args.includes('--licenses'),
+
+        // Encrypted contexts (v3.4.0): age:<recipient> or gpg:<recipient>, repeatable
+        encrypt: getEncrypt(args),
+        anonymize: getAnonymizeFile(args),
+
+        // Digest layout (v3.4.0): section order, caps and separator
+        layout: getLayout(args),
+
+        // Provenance manifests (v3.4.0): the command they record, without --manifest
+        manifest: args.includes('--manifest') ? args.filter(arg => arg !== '--manifest') : null,
+
+        // Partial failures (v3.4.0): files that fail are reported, or end the run with --strict
+        strict: args.includes('--strict'),
+        errorReport: getFlagValue(args, '--error-report'),
+        decisionLog: args.includes('--decision-log'),
+
+        // Compression statistics (v3.4.0)
+        compressionStats: getCompressionStats(args),
+
+        // Conversation sessions (v3.4.0)
+        sessionName: getFlagValue(args, '--session'),
+        elideBodies: getElideBodies(args),
+        remoteSummaries: getRemoteSummaries(args),
+        commentTranslation: getCommentTranslation(args),
+
+        // Context templates (v3.4.0)
+        templateFile: getFlagValue(args, '--template'),
+        templateVars: getTemplateVars(args),
+
+        // Deduplication (v3.4.0)
+        dedupe: getDedupe(args),
+
+        // Documentation first (v3.4.0)
+        docs: getDocs(args),
+
+        // CODEOWNERS (v3.4.0)
+        owners: getFlagValues(args, '--owner'),
+        ownerAnnotations: args.includes('--owners'),
+
+        // Priority scoring (v3.4.0)
+        weights: getWeights(args),
+        pins: getPins(args),
+        explain: args.includes('--explain'),
+        entryPoints: args.includes('--entry-points'),
+        coverage: getFlagValues(args, '--coverage'),
+        sarif: getFlagValues(args, '--sarif'),
+
+        // Parallel analysis (v3.4.0)
+        jobs: getJobs(args),
+        readMode: getReadMode(args),
+
+        // Multi-repo workspace (v3.4.0)
+        workspaceFile: getWorkspaceFile(args),
+
+        // Ad-hoc file lists (v3.4.0)
+        files: getFiles(args),
+        suggestions: getSuggestions(args),
+
+        // Cache options (v3.4.0)
+        cache: args.includes('--cache'),
+        clearCache: args.includes('--clear-cache'),
+
+        // Git options (v3.0.0)
+        rev: getRev(args),
+        changedOnly: args.includes('--changed-only'),
+        changedSince: getChangedSince(args),
+        withAuthors: args.includes('--with-authors'),
+        withHistory: args.includes('--with-history'),
+
+        // UI options (v2.3.0)
+        simple: args.includes('--simple'),
+        dashboard: args.includes('--dashboard'),
+
+        // Chunking options (v2.3.0)
+        chunking: {
+            enabled: args.includes('--chunk'),
+            strategy: getChunkStrategy(args),
+            maxTokensPerChunk: getChunkSize(args)
+        },
+
+        projectRoot: process.cwd()
+    };
+
+    return applyProfile(applyModelPreset(options, args), args);
+}
+
+/**
+ * Apply the settings of a --model preset (v3.4.0)
+ * Flags given on the command line take precedence over the preset, and the
+ * preset over a --profile.
+ */
+function applyModelPreset(options, args) {
+    const preset = options.modelPreset;
+    if (!preset) {
+        return options;
+    }
+
+    if (!options.targetModel) {
+        options.targetModel = preset.model;
+    }
+    if (!args.includes('--tokenizer')) {
+        options.tokenizer = preset.tokenizer;
+    }
+    // The window holds the context and the answer
+    if (options.maxTokens === null) {
+        options.maxTokens = preset.contextWindow;
+        if (options.reserve === null) options.reserve = preset.reserve;
+    }
+
+    const formatGiven = options.gitingest || options.contextExport || options.contextToClipboard || options.structuredFormat;
+    if (!formatGiven) {
+        options.structuredFormat = preset.format;
+    }
+    return options;
+}
+
+/**
+ * Apply a context.yaml profile selected with --profile (v3.4.0)
+ * Flags given on the command line take precedence over the profile.
+ */
+function applyProfile(options, args) {
+    if (!args.includes('--profile')) {
+        return options;
+    }
+
+    const name = getFlagValue(args, '--profile');
+    if (!name) {
+        console.error('❌ --profile requires a profile name from context.yaml');
+        process.exit(1);
+    }
+
+    let profile;
+    try {
+        const profiles = ContextProfiles.load(options.projectRoot);
+        if (!profiles) {
+            throw new Error(`No context.yaml in ${options.projectRoot} (needed for --profile ${name})`);
+        }
+        profile = profiles.resolve(name);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    options.profile = profile;
+    if (options.maxTokens === null && profile.budget) {
+        options.maxTokens = profile.budget;
+    }
+    if (!args.includes('--tokenizer') && !options.modelPreset && profile.tokenizer) {
+        options.tokenizer = profile.tokenizer;
+    }
+    if (!options.weights && profile.weights) {
+        options.weights = profile.weights;
+    }
+    if (options.pins.length === 0) {
+        options.pins = profile.pins;
+    }
+    if (!options.layout && profile.layout) {
+        options.layout = profile.layout;
+    }
+
+    const formatGiven = options.gitingest || options.contextExport || options.contextToClipboard || options.structuredFormat;
+    if (!formatGiven && profile.format) {
+        if (profile.format === 'gitingest') options.gitingest = true;
+        else if (profile.format === 'context') options.contextExport = true;
+        else options.structuredFormat = profile.format;
+    }
+    return options;
+}
+
+function getOutputFormat(args) {
+    const formatIndex = args.findIndex(arg => arg === '--output' || arg === '-o');
+    if (formatIndex !== -1 && args[formatIndex + 1]) {
+        return args[formatIndex + 1];
+    }
+    return 'toon'; // Default to TOON format in v2.3.0
+}
+
+function getChunkStrategy(args) {
+    const strategyIndex = args.findIndex(arg => arg === '--chunk-strategy');
+    if (strategyIndex !== -1 && args[strategyIndex + 1]) {
+        return args[strategyIndex + 1];
+    }
+    return 'smart'; // Default strategy
+}
+
+function getChunkSize(args) {
+    const sizeIndex = args.findIndex(arg => arg === '--chunk-size');
+    if (sizeIndex !== -1 && args[sizeIndex + 1]) {
+        return parseInt(args[sizeIndex + 1], 10);
+    }
+    return 100000; // Default 100k tokens
+}
+
+function createContentCache(options) {
+    if (!options.cache && !options.clearCache) {
+        return null;
+    }
+
+    const contentCache = new ContentCache({ root: options.projectRoot });
+    if (options.clearCache) {
+        contentCache.clear();
+        console.log('🗑️  Content cache cleared');
+    }
+    return options.cache ? contentCache : null;
+}
+
+function getMaxTokens(args) {
+    const maxIndex = args.findIndex(arg => arg === '--max-tokens');
+    if (maxIndex === -1) {
+        return null;
+    }
+
+    const tokens = parseTokenCount(args[maxIndex + 1]);
+    if (!tokens) {
+        console.error(`❌ Invalid --max-tokens value: ${args[maxIndex + 1]} (e.g. 8000, 32k, 1m)`);
+        process.exit(1);
+    }
+    return tokens;
+}
+
+/**
+ * Tokens the digest's directory structure may take (--tree-budget [TOKENS], v3.4.0)
+ */
+function getTreeBudget(args) {
+    const budgetIndex = args.findIndex(arg => arg === '--tree-budget');
+    if (budgetIndex === -1) {
+        return null;
+    }
+
+    // The budget is optional: --tree-budget [TOKENS]
+    const value = args[budgetIndex + 1];
+    if (!value || value.startsWith('-')) {
+        return DEFAULT_TREE_BUDGET;
+    }
+    const tokens = parseTokenCount(value);
+    if (!tokens) {
+        console.error(`❌ Invalid --tree-budget value: ${value} (e.g. 500, 2k)`);
+        process.exit(1);
+    }
+    return tokens;
+}
+
+function getReserve(args, flag = '--reserve') {
+    if (!args.includes(flag)) {
+        return null;
+    }
+
+    const value = getFlagValue(args, flag);
+    const tokens = parseTokenCount(value);
+    if (!tokens) {
+        console.error(`❌ Invalid ${flag} value: ${value} (e.g. 4000, 8k)`);
+        process.exit(1);
+    }
+    return tokens;
+}
+
+/**
+ * Tokens kept free for the answer: --reserve-output, or its older name --reserve (v3.4.0)
+ */
+function getAnswerReserve(args) {
+    if (args.includes('--reserve') && args.includes('--reserve-output')) {
+        console.error('❌ --reserve and --reserve-output both reserve room for the answer; give one');
+        process.exit(1);
+    }
+    return getReserve(args, args.includes('--reserve-output') ? '--reserve-output' : '--reserve');
+}
+
+function getModelPreset(args) {
+    if (!args.includes('--model')) {
+        return null;
+    }
+
+    const name = getFlagValue(args, '--model');
+    try {
+        return ModelPresets.resolve(name);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+}
+
+function getRev(args) {
+    if (!args.includes('--rev')) {
+        return null;
+    }
+
+    const rev = getFlagValue(args, '--rev');
+    if (!rev || rev.startsWith('-')) {
+        console.error('❌ --rev requires a commit, branch or tag (e.g. --rev v1.2.0, --rev HEAD~3)');
+        process.exit(1);
+    }
+    return rev;
+}
+
+function getWorkspaceFile(args) {
+    const workspaceIndex = args.indexOf('--workspace');
+    if (workspaceIndex === -1) {
+        return null;
+    }
+
+    // The manifest is optional: --workspace [FILE]
+    const file = args[workspaceIndex + 1];
+    return file && !file.startsWith('-') ? file : true;
+}
+
+function getJobs(args) {
+    const jobsIndex = args.findIndex(arg => arg === '--jobs' || arg === '-j');
+    if (jobsIndex === -1) {
+        return 1;
+    }
+
+    const jobs = parseJobs(args[jobsIndex + 1]);
+    if (!jobs) {
+        console.error(`❌ Invalid --jobs value: ${args[jobsIndex + 1]} (expected a positive number or auto)`);
+        process.exit(1);
+    }
+    return jobs;
+}
+
+function getReadMode(args) {
+    if (!args.includes('--read-mode')) {
+        return 'auto';
+    }
+
+    const mode = getFlagValue(args, '--read-mode');
+    if (!READ_MODES.includes(mode)) {
+        console.error(`❌ Invalid --read-mode: ${mode} (expected ${READ_MODES.join(', ')})`);
+        process.exit(1);
+    }
+    if (mode === 'mmap' && !FileReader.canMap()) {
+        console.error('❌ --read-mode mmap needs the mmap-io package (npm install mmap-io)');
+        process.exit(1);
+    }
+    return mode;
+}
+
+function getTokenizer(args) {
+    const tokenizerIndex = args.findIndex(arg => arg === '--tokenizer');
+    if (tokenizerIndex !== -1 && args[tokenizerIndex + 1]) {
+        return args[tokenizerIndex + 1];
+    }
+    return 'auto'; // Pick from --target-model, else cl100k_base
+}
+
+function getTiers(args) {
+    const tiersIndex = args.findIndex(arg => arg === '--tiers');
+    if (tiersIndex === -1) {
+        return null;
+    }
+
+    try {
+        return parseTiers(args[tiersIndex + 1]);
+    } catch (error) {
+        console.error(`❌ Invalid --tiers value: ${error.message}`);
+        process.exit(1);
+    }
+}
+
+function getRebalancePins(args) {
+    // The steps are optional: --rebalance-pins [STEPS]
+    const flagIndex = args.findIndex(arg => arg === '--rebalance-pins');
+    if (flagIndex === -1) {
+        return null;
+    }
+
+    const value = args[flagIndex + 1];
+    if (!value || value.startsWith('--')) {
+        return REBALANCE_STEPS;
+    }
+    try {
+        return parseRebalanceSteps(value);
+    } catch (error) {
+        console.error(`❌ Invalid --rebalance-pins value: ${error.message}`);
+        process.exit(1);
+    }
+}
+
+function getStructuredFormat(args) {
+    const formatIndex = args.findIndex(arg => arg === '--format');
+    if (formatIndex === -1) {
+        return null;
+    }
+
+    const format = args[formatIndex + 1];
+    if (!STRUCTURED_FORMATS.includes(format)) {
+        console.error(`❌ Invalid --format value: ${format} (expected ${STRUCTURED_FORMATS.join(', ')})`);
+        process.exit(1);
+    }
+    return format;
+}
+
+function getOutputFile(args) {
+    const outputIndex = args.findIndex(arg => arg === '--output-file');
+    if (outputIndex !== -1 && args[outputIndex + 1]) {
+        return args[outputIndex + 1];
+    }
+    return null;
+}
+
+function getOut(args) {
+    const outIndex = args.findIndex(arg => arg === '--out');
+    if (outIndex === -1) {
+        return null;
+    }
+
+    const target = args[outIndex + 1];
+    if (!OUTPUT_TARGETS.includes(target)) {
+        console.error(`❌ Invalid --out target: ${target || ''} (expected ${OUTPUT_TARGETS.join(', ')})`);
+        process.exit(1);
+    }
+    return target;
+}
+
+function getFocus(args) {
+    const focusIndex = args.findIndex(arg => arg === '--focus');
+    if (focusIndex !== -1 && args[focusIndex + 1]) {
+        return args[focusIndex + 1];
+    }
+    return null;
+}
+
+function getExpandDeps(args) {
+    const depthIndex = args.findIndex(arg => arg === '--expand-deps');
+    if (depthIndex === -1) {
+        return null;
+    }
+
+    const depth = Number(args[depthIndex + 1]);
+    if (!Number.isInteger(depth) || depth < 0) {
+        console.error(`❌ Invalid --expand-deps value: ${args[depthIndex + 1]} (expected a depth such as 1 or 2)`);
+        process.exit(1);
+    }
+    return depth;
+}
+
+function getStrip(args) {
+    if (!args.includes('--strip')) {
+        return null;
+    }
+
+    const mode = getFlagValue(args, '--strip');
+    if (!STRIP_MODES.includes(mode)) {
+        console.error(`❌ Invalid --strip mode: ${mode} (expected ${STRIP_MODES.join(', ')})`);
+        process.exit(1);
+    }
+    return mode;
+}
+
+function getTemplateVars(args) {
+    // --var NAME=VALUE may be repeated
+    return args
+        .map((arg, i) => (arg === '--var' ? args[i + 1] : null))
+        .filter(value => value !== null)
+        .map(value => {
+            const match = /^([A-Za-z_][A-Za-z0-9_]*)=([\s\S]*)$/.exec(value || '');
+            if (!match) {
+                console.error(`❌ Invalid --var value: ${value} (expected NAME=VALUE)`);
+                process.exit(1);
+            }
+            return [match[1], match[2]];
+        });
+}
+
+function getFiles(args) {
+    if (!args.includes('--files')) {
+        return null;
+    }
+
+    const source = getFlagValue(args, '--files');
+    if (!source) {
+        console.error('❌ --files requires - (stdin) or a FILE with one path per line');
+        process.exit(1);
+    }
+    return source;
+}
+
+function getSuggestions(args) {
+    const suggestIndex = args.findIndex(arg => arg === '--suggest');
+    const accept = getBenchCount(args, '--accept-suggestions', 0, 1);
+    if (suggestIndex === -1 && accept === 0) {
+        return null;
+    }
+
+    // The count is optional: --suggest [N]
+    const count = suggestIndex === -1 ? null : args[suggestIndex + 1];
+    if (count && !count.startsWith('-')) {
+        return { limit: getBenchCount(args, '--suggest', 10, 1), accept };
+    }
+    return { limit: Math.max(10, accept), accept };
+}
+
+function getDedupe(args) {
+    const dedupeIndex = args.findIndex(arg => arg === '--dedupe');
+    if (dedupeIndex === -1) {
+        return null;
+    }
+
+    // The similarity is optional: --dedupe [0.8]
+    const value = args[dedupeIndex + 1];
+    if (!value || !/^[\d.]+$/.test(value)) {
+        return 0.8;
+    }
+    const threshold = Number(value);
+    if (!(threshold > 0 && threshold <= 1)) {
+        console.error(`❌ Invalid --dedupe similarity: ${value} (expected a number above 0, up to 1, such as 0.8)`);
+        process.exit(1);
+    }
+    return threshold;
+}
+
+function getDocs(args) {
+    const docsIndex = args.findIndex(arg => arg === '--docs');
+    if (docsIndex === -1) {
+        return null;
+    }
+
+    // The mode is optional: --docs [full|summary]
+    const mode = args[docsIndex + 1];
+    if (!mode || mode.startsWith('-')) {
+        return 'full';
+    }
+    if (!DOCS_MODES.includes(mode)) {
+        console.error(`❌ Invalid --docs mode: ${mode} (expected ${DOCS_MODES.join(' or ')})`);
+        process.exit(1);
+    }
+    return mode;
+}
+
+function getPairTests(args) {
+    const pairIndex = args.findIndex(arg => arg === '--pair-tests');
+    if (pairIndex === -1) {
+        return null;
+    }
+
+    // The mode is optional: --pair-tests [full|names]
+    const mode = args[pairIndex + 1];
+    if (!mode || mode.startsWith('-')) {
+        return 'full';
+    }
+    if (!PAIR_MODES.includes(mode)) {
+        console.error(`❌ Invalid --pair-tests mode: ${mode} (expected ${PAIR_MODES.join(' or ')})`);
+        process.exit(1);
+    }
+    return mode;
+}
+
+function getUsageExamples(args) {
+    const examplesIndex = args.findIndex(arg => arg === '--usage-examples');
+    if (examplesIndex === -1) {
+        return null;
+    }
+
+    // The count is optional: --usage-examples [N]
+    const value = args[examplesIndex + 1];
+    if (!value || value.startsWith('-')) {
+        return DEFAULT_EXAMPLES;
+    }
+    const limit = Number(value);
+    if (!Number.isInteger(limit) || limit < 1) {
+        console.error(`❌ Invalid --usage-examples count: ${value} (expected a positive integer)`);
+        process.exit(1);
+    }
+    return limit;
+}
+
+function getCompressionStats(args) {
+    const statsIndex = args.findIndex(arg => arg === '--compression-stats');
+    if (statsIndex === -1) {
+        return null;
+    }
+
+    // The JSON file is optional: --compression-stats [FILE]
+    const file = args[statsIndex + 1];
+    return { file: file && !file.startsWith('-') ? file : null };
+}
+
+function getSparseMode(args) {
+    if (args.includes('--no-sparse')) {
+        return false;
+    }
+    const sparseIndex = args.findIndex(arg => arg === '--sparse');
+    if (sparseIndex === -1) {
+        return null;
+    }
+
+    // The definition file is optional: --sparse [FILE]
+    const file = args[sparseIndex + 1];
+    return { file: file && !file.startsWith('-') ? file : null };
+}
+
+function getTodos(args) {
+    const todosIndex = args.findIndex(arg => arg === '--todos');
+    if (todosIndex === -1) {
+        return null;
+    }
+
+    // The scope is optional: --todos [included|repo]
+    const scope = args[todosIndex + 1];
+    if (!scope || scope.startsWith('-')) {
+        return 'included';
+    }
+    if (!TODO_SCOPES.includes(scope)) {
+        console.error(`❌ Invalid --todos scope: ${scope} (expected ${TODO_SCOPES.join(' or ')})`);
+        process.exit(1);
+    }
+    return scope;
+}
+
+function getLayout(args) {
+    const layoutIndex = args.findIndex(arg => arg === '--layout');
+    if (layoutIndex === -1) {
+        return null;
+    }
+
+    try {
+        return ContextLayout.parse(args[layoutIndex + 1] || '');
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+}
+
+function getAnonymizeFile(args) {
+    const anonymizeIndex = args.indexOf('--anonymize');
+    if (anonymizeIndex === -1) {
+        return null;
+    }
+
+    // The mapping file is optional: --anonymize [MAP]
+    const file = args[anonymizeIndex + 1];
+    return file && !file.startsWith('-') ? file : ANONYMIZE_FILE;
+}
+
+function getEncrypt(args) {
+    // --encrypt may be repeated to add recipients
+    return args
+        .map((arg, i) => (arg === '--encrypt' ? args[i + 1] || '' : null))
+        .filter(value => value !== null);
+}
+
+function getWeights(args) {
+    const weightsIndex = args.findIndex(arg => arg === '--weights');
+    if (weightsIndex === -1) {
+        return null;
+    }
+
+    try {
+        return PriorityScorer.parseWeights(args[weightsIndex + 1] || '');
+    } catch (error) {
+        console.error(`❌ Invalid --weights value: ${error.message}`);
+        process.exit(1);
+    }
+}
+
+function getPins(args) {
+    // --pin may be repeated or list globs separated by commas
+    return args
+        .map((arg, i) => (arg === '--pin' ? args[i + 1] : null))
+        .filter(pin => pin && !pin.startsWith('--'))
+        .flatMap(pin => pin.split(',').map(glob => glob.trim()).filter(Boolean));
+}
+
+function getElideBodies(args) {
+    // --elide-bodies may be repeated: N for every directory, DIR=N for one
+    return args
+        .map((arg, i) => (arg === '--elide-bodies' ? args[i + 1] : null))
+        .filter(value => value && !value.startsWith('--'));
+}
+
+function getRemoteSummaries(args) {
+    const flagIndex = args.findIndex(arg => arg === '--summarize-remote');
+    if (flagIndex === -1) {
+        return null;
+    }
+
+    const config = {
+        model: getFlagValue(args, '--summary-model') || undefined,
+        baseUrl: getFlagValue(args, '--summary-endpoint') || undefined,
+        concurrency: getBenchCount(args, '--summary-concurrency', undefined, 1),
+        requestsPerMinute: getBenchCount(args, '--summary-rpm', undefined, 1)
+    };
+
+    // The share is optional: --summarize-remote [N%]
+    const share = args[flagIndex + 1];
+    if (share && !share.startsWith('-')) {
+        const value = share.endsWith('%') ? Number(share.slice(0, -1)) / 100 : Number(share);
+        if (!(value > 0 && value <= 1)) {
+            console.error(`❌ Invalid --summarize-remote share: ${share} (expected a percentage like 30%)`);
+            process.exit(1);
+        }
+        config.share = value;
+    }
+    return Object.fromEntries(Object.entries(config).filter(([, value]) => value !== undefined));
+}
+
+function getCommentTranslation(args) {
+    if (!args.includes('--translate-comments')) {
+        return null;
+    }
+
+    const config = {
+        api: getFlagValue(args, '--translate-api') || undefined,
+        baseUrl: getFlagValue(args, '--translate-endpoint') || undefined,
+        model: getFlagValue(args, '--translate-model') || undefined,
+        concurrency: getBenchCount(args, '--translate-concurrency', undefined, 1)
+    };
+    return Object.fromEntries(Object.entries(config).filter(([, value]) => value !== undefined));
+}
+
+function getSymbols(args) {
+    // --symbol may be repeated
+    return args
+        .map((arg, i) => (arg === '--symbol' ? args[i + 1] : null))
+        .filter(symbol => symbol && !symbol.startsWith('--'));
+}
+
+function getLspLanguages(args) {
+    const value = getFlagValue(args, '--lsp-languages');
+    return value ? value.split(',').map(language => language.trim()).filter(Boolean) : null;
+}
+
+function getSymbolBackend(args) {
+    const backendIndex = args.findIndex(arg => arg === '--symbol-backend');
+    if (backendIndex !== -1 && args[backendIndex + 1]) {
+        return args[backendIndex + 1];
+    }
+    return 'auto'; // tree-sitter when installed, else heuristic parsers
+}
+
+function getTargetModel(args) {
+    // Check for explicit model flag
+    const modelIndex = args.findIndex(arg => arg === '--target-model');
+    if (modelIndex !== -1 && args[modelIndex + 1]) {
+        return args[modelIndex + 1];
+    }
+
+    // Check for auto-detect flag
+    if (args.includes('--auto-detect-llm')) {
+        const detected = LLMDetector.detect();
+        if (detected !== 'unknown') {
+            console.log(`✅ Auto-detected LLM: ${LLMDetector.getProfile(detected).name}`);
+            return detected;
+        }
+    }
+
+    return null; // No model specified
+}
+
+function listLLMs() {
+    console.log('\n📋 Supported LLM Models (v2.3.7):\n');
+    console.log('═'.repeat(70));
+
+    const models = LLMDetector.getModelList();
+
+    // Group by vendor
+    const byVendor = {};
+    models.forEach(model => {
+        if (!byVendor[model.vendor]) {
+            byVendor[model.vendor] = [];
+        }
+        byVendor[model.vendor].push(model);
+    });
+
+    // Display by vendor
+    Object.entries(byVendor).forEach(([vendor, models]) => {
+        console.log(`\n${vendor}:`);
+        models.forEach(model => {
+            const contextDisplay = model.contextWindow >= 1000000
+                ? `${(model.contextWindow / 1000000).toFixed(1)}M`
+                : `${Math.floor(model.contextWindow / 1000)}k`;
+            console.log(`  ${model.id.padEnd(25)} ${model.name.padEnd(25)} (${contextDisplay} context)`);
+        });
+    });
+
+    // Presets set the tokenizer, budget and format too (v3.4.0)
+    console.log('\nPresets (--model):');
+    ModelPresets.list().forEach(preset => {
+        console.log(`  ${preset.name.padEnd(25)} ${preset.model.padEnd(25)} (${preset.tokenizer}, ${preset.format})`);
+    });
+
+    console.log('\n' + '═'.repeat(70));
+    console.log('\nUsage:');
+    console.log('  ctxman --target-model <MODEL_ID>');
+    console.log('  ctxman --model <PRESET>');
+    console.log('  ctxman --auto-detect-llm');
+    console.log('\nExample:');
+    console.log('  ctxman --target-model claude-sonnet-4.5');
+    console.log('  ctxman --auto-detect-llm --cli\n');
+}
+
+function printStartupInfo(options) {
+    console.log('🚀 Ctxman v3.0.0');
+    console.log('='.repeat(50));
+
+    // Only show active options if any are set
+    const hasOptions = options.outputFormat || options.methodLevel || options.chunking?.enabled ||
+        options.saveReport || options.verbose || options.contextExport ||
+        options.contextToClipboard || options.gitingest || options.structuredFormat || options.tokenBudget || options.cache || options.jobs > 1 || options.focus ||
+        options.symbols?.length > 0 || options.pick || options.diff || options.apiDiff || options.query || options.snippet || options.profile || options.pairTests || options.usageExamples ||
+        options.priorityScorer || options.codeOwners || options.outputTarget || options.policy || options.redactor || options.stripper || options.transforms || options.template || options.duplicateDetector || options.docs || options.bodyElider || options.remoteSummarizer || options.commentTranslator || options.lsp || options.workspace || options.revision || options.sparse || options.fileList || options.notebookOutputs || options.includeGenerated || options.tokenTree || options.treeCollapser || options.crossReferences || options.todoHarvester || options.dependencySummary || options.licenseDetector || options.layout || options.manifest || options.session || options.anonymizer || options.strict || options.errorReport || options.decisionLog || options.compressionStats || options.fsync || options.backup || options.sectionIds || options.projectDetector || options.lineSelection;
+
+    if (hasOptions) {
+        console.log('📋 Active options:');
+        if (options.profile) {
+            console.log(`  Profile: ${options.profile.name}${options.profile.description ? ` - ${options.profile.description}` : ''}`);
+        }
+        if (options.revision) {
+            console.log(`  Revision: ${options.revision.describe()}`);
+        }
+        if (options.sparse) {
+            console.log(`  Sparse checkout: ${options.sparse.describe()} from ${options.sparse.options.source}`);
+        }
+        if (options.fileList) {
+            console.log(`  File list: ${options.fileList.length} paths from ${options.files === '-' ? 'stdin' : options.files}`);
+        }
+        if (options.relatedFiles) {
+            const { limit, accept } = options.relatedFiles.options;
+            console.log(`  Related files: top ${limit} suggested${accept ? `, top ${accept} added` : ''}`);
+        }
+        if (options.workspace) {
+            console.log(`  Workspace: ${options.workspace.repos.map(repo => repo.prefix || '.').join(', ')}`);
+        }
+        if (options.outputFormat) {
+            console.log(`  Output format: ${options.outputFormat}`);
+        }
+        if (options.methodLevel) {
+            console.log('  Method-level analysis: enabled');
+        }
+        if (options.saveReport) {
+            console.log('  Save report: enabled');
+        }
+        if (options.gitingest) {
+            console.log('  GitIngest format: enabled');
+        }
+        if (options.structuredFormat) {
+            console.log(`  Structured output: ${options.structuredFormat}${options.collapsible ? ' (collapsible)' : ''} (${options.outputStream ? 'stdout' : options.outputFile || StructuredFormatter.defaultFile(options.structuredFormat)})`);
+        }
+        if (options.xmlTags) {
+            console.log(`  XML tags: ${Object.entries(options.xmlTags).map(([tag, name]) => `${tag}=${name}`).join(', ')}`);
+        }
+        if (options.fence !== 'backtick') {
+            console.log(`  Markdown fence: ${options.fence}`);
+        }
+        if (options.xmlContent !== 'raw') {
+            console.log(`  XML content: ${options.xmlContent}`);
+        }
+        if (options.contextExport) {
+            console.log('  Context export: enabled');
+        }
+        if (options.contextToClipboard) {
+            console.log('  Copy to clipboard: enabled');
+        }
+        if (options.outputTarget) {
+            console.log(`  Output target: ${options.outputTarget.target}${options.printPath ? ' (paths on stdout)' : ''}`);
+        }
+        if (options.fsync || options.backup) {
+            console.log(`  Output files: ${[options.fsync && 'synced to disk', options.backup && 'previous kept as .bak'].filter(Boolean).join(', ')}`);
+        }
+        if (options.sectionIds) {
+            console.log('  Section IDs: enabled');
+        }
+        if (options.projectDetector) {
+            console.log('  Per-project bundles: enabled');
+        }
+        if (options.anonymizer) {
+            console.log(`  Anonymized: names and strings as placeholders (mapping: ${options.anonymize})`);
+        }
+        if (options.encryptor) {
+            console.log(`  Encryption: ${options.encryptor.describe()}`);
+        }
+        if (options.strict) {
+            console.log('  Strict: the first file that fails ends the run');
+        }
+        if (options.errorReport) {
+            console.log(`  Error report: ${options.errorReport}`);
+        }
+        if (options.decisionLog) {
+            console.log(`  Decision log: ${DECISION_FILE}`);
+        }
+        if (options.compressionStats) {
+            console.log(`  Compression statistics${options.compressionStats.file ? `: ${options.compressionStats.file}` : ''}`);
+        }
+        if (options.layout) {
+            console.log(`  Digest layout: ${options.layout.describe()}`);
+        }
+        if (options.policy) {
+            console.log(`  Content policy: ${options.policy.rules.length} rules from ${options.policy.options.source}${options.policy.options.enforce ? ' (enforced)' : ''}`);
+        }
+        if (options.redactor) {
+            console.log(`  Secret redaction: ${options.redactor.rules.length} rules${options.redactor.entropy ? ' + entropy' : ''}`);
+        }
+        if (options.stripper) {
+            console.log(`  Strip: ${options.strip}${options.keepDocs ? ' (keeping docs of exported symbols)' : ''}`);
+        }
+        if (options.transforms) {
+            console.log(`  Transforms: ${options.transforms.describe()}`);
+        }
+        if (options.notebookOutputs) {
+            console.log('  Notebook outputs: included as comments');
+        }
+        if (options.includeGenerated) {
+            console.log('  Binary, minified and lockfiles: included');
+        }
+        if (options.bodyElider) {
+            console.log(`  Body elision: bodies ${options.bodyElider.describe()}`);
+        }
+        if (options.remoteSummarizer) {
+            const { model, share, concurrency } = options.remoteSummarizer.options;
+            const files = share ? `lowest-priority ${Math.round(share * 100)}%` : options.tokenBudget ? 'files over the budget' : 'lowest-priority 25%';
+            console.log(`  Remote summaries: ${files} by ${model} (${concurrency} at a time)`);
+        }
+        if (options.commentTranslator) {
+            console.log(`  Comment translation: non-English comments to English by ${options.commentTranslator.describe()}`);
+        }
+        if (options.template) {
+            const output = options.outputStream ? 'stdout' : options.outputFile || ContextTemplate.defaultFile(options.template.file);
+            const vars = options.templateVars.map(([name]) => name).join(', ');
+            console.log(`  Template: ${options.template.name} (${output})${vars ? ` with ${vars}` : ''}`);
+        }
+        if (options.cache) {
+            console.log('  Content cache: enabled (.ctxman/cache)');
+        }
+        if (options.jobs > 1) {
+            console.log(`  Parallel analysis: ${options.jobs} worker threads`);
+        }
+        if (options.focus) {
+            console.log(`  Focus: ${options.focus} (dependency depth ${options.expandDeps || 0})`);
+        }
+        if (options.symbols?.length > 0) {
+            console.log(`  Symbols: ${options.symbols.join(', ')}`);
+        }
+        if (options.pick) {
+            console.log(`  Selected: ${options.pick.files.length} files, ${options.pick.symbols.length} symbols`);
+        }
+        if (options.lsp) {
+            console.log(`  Language server: ${options.lspServer} (${[...options.lsp.languages].join(', ')})`);
+        }
+        if (options.includeImplementations) {
+            console.log('  Interface implementations: included');
+        }
+        if (options.exportedMethods) {
+            console.log('  Type methods: exported only');
+        }
+        if (options.tokenTree) {
+            const { sort, depth } = options.tokenTree.options;
+            console.log(`  Token tree: by ${sort}${depth !== null ? `, ${depth} ${depth === 1 ? 'level' : 'levels'} deep` : ''}`);
+        }
+        if (options.treeCollapser) {
+            console.log(`  Digest tree: within ${options.treeBudget.toLocaleString()} tokens, directories of included files expanded`);
+        }
+        if (options.crossReferences) {
+            console.log('  Cross-reference index: appended to the context');
+        }
+        if (options.todoHarvester) {
+            console.log(`  TODO markers: ${options.todos === 'repo' ? 'of every analyzed file' : 'of the included files'}, appended to the context`);
+        }
+        if (options.dependencySummary) {
+            console.log('  Dependencies: go.mod, package.json, Cargo.toml and requirements.txt, appended to the context');
+        }
+        if (options.licenseDetector) {
+            console.log('  Licenses: headers removed, LICENSE files left out, one licensing note appended');
+        }
+        if (options.manifest) {
+            console.log(`  Manifest: ${MANIFEST_SUFFIX} next to each context`);
+        }
+        if (options.session) {
+            console.log(`  Session: ${options.session.name}, turn ${options.session.turn + 1} (unchanged files from earlier turns left out)`);
+        }
+        if (options.duplicateDetector) {
+            console.log(`  Deduplication: near-duplicates ≥ ${Math.round(options.dedupe * 100)}% similar collapsed`);
+        }
+        if (options.codeOwners) {
+            const scope = options.owners.length > 0 ? `files of ${options.owners.join(', ')}` : 'all files';
+            console.log(`  Owners: ${scope}, annotated from ${options.codeOwners.source}`);
+        }
+        if (options.docs) {
+            console.log(`  Documentation: first in the context${options.docs === 'summary' ? ' (summaries)' : ''}`);
+        }
+        if (options.pairTests) {
+            console.log(`  Test pairing: ${options.pairTests === 'names' ? 'test names' : 'full files'}`);
+        }
+        if (options.usageExamples) {
+            console.log(`  Usage examples: up to ${options.usageExamples.options.limit} per symbol`);
+        }
+        if (options.priorityScorer) {
+            const weights = options.priorityScorer.weights;
+            console.log(`  Priority weights: ${Object.entries(weights).map(([signal, weight]) => `${signal} ${weight}`).join(', ')}`);
+            if (options.pins.length > 0) {
+                console.log(`  Pinned: ${options.pins.join(', ')}`);
+            }
+            if (options.entryPoints) {
+                console.log('  Entry points: listed in the report, ranked up with their imports');
+            }
+            if (options.riskOverlay) {
+                const reports = [...options.coverage, ...options.sarif];
+                console.log(`  Risk overlay: ${reports.join(', ')} (low coverage and open findings ranked up)`);
+            }
+        }
+        if (options.lineSelection) {
+            console.log(`  Pinned lines: ${options.lineSelection.slices.map(slice => slice.selector).join(', ')}`);
+        }
+        if (options.query) {
+            console.log(`  Query: "${options.query.text}" (${options.query.provider}, ${options.query.stats.chunks} chunks)`);
+        }
+        if (options.snippet) {
+            console.log(`  Snippet: ${options.snippet.text.split('\n').length} lines from ${options.snippet.source}`);
+        }
+        if (options.diff) {
+            console.log(`  Diff: ${options.diff.changes.length} changed files since ${options.diff.base || 'HEAD'} (dependency depth ${options.expandDeps ?? 1})`);
+        }
+        if (options.apiDiff) {
+            console.log(`  API diff: ${options.apiDiff.symbols.length} exported symbols at ${options.apiDiff.base}`);
+        }
+        if (options.modelPreset) {
+            console.log(`  Model preset: ${ModelPresets.describe(options.modelPreset)}`);
+        }
+        if (options.tokenBudget) {
+            const { reserve, reservePrompt } = options.tokenBudget.options;
+            const answer = reserve ? `${reserve.toLocaleString()} ${reservePrompt ? '' : 'reserved '}for the answer` : '';
+            const reserved = [reservePrompt && `${reservePrompt.toLocaleString()} reserved for the prompt`, answer].filter(Boolean).join(', ');
+            console.log(`  Token budget: ${options.tokenBudget.limit.toLocaleString()} tokens (${options.tokenBudget.getTokenizerName()})${reserved ? `, ${reserved}` : ''}`);
+            if (options.tiers) {
+                console.log(`  Budget tiers: ${options.tokenBudget.tiers.join(' → ')}`);
+            }
+            if (options.pinRebalancer) {
+                console.log(`  Pin rebalancing: ${options.pinRebalancer.options.steps.join(' → ')}`);
+            }
+        }
+        if (options.chunking?.enabled) {
+            console.log(`  Chunking: ${options.chunking.strategy} (${options.chunking.maxTokensPerChunk.toLocaleString()} tokens/chunk)`);
+        }
+        console.log();
+    }
+}
+
+function printHelp() {
+    console.log('Ctxman v3.0.0 - AI Development Platform with Plugin Architecture and Git Integration');
+    console.log();
+    console.log('Usage: ctxman [options]');
+    console.log();
+    console.log('Default Mode:');
+    console.log('  ctxman          Launch interactive wizard (DEFAULT)');
+    console.log('  --cli                    Use CLI mode instead of wizard');
+    console.log();
+    console.log('Analysis Options:');
+    console.log('  -s, --save-report        Save detailed JSON report');
+    console.log('  -v, --verbose            Show all included files');
+    console.log('  -m, --method-level       Enable method-level analysis');
+    console.log('  -g, --gitingest          Generate GitIngest-style digest');
+    console.log('  --format json|yaml|markdown|xml');
+    console.log('                           Write structured context (files, symbols, signatures,');
+    console.log('                           tokens, priorities) to context.json / .yaml / .md / .xml');
+    console.log('  --collapsible            Markdown: file contents in collapsible <details> blocks');
+    console.log('  --xml-tags TAG=NAME,...  XML: rename document tags (e.g. document_content=document_contents)');
+    console.log(`                           TAG is ${Object.keys(XML_TAGS).join(', ')}`);
+    console.log(`  --fence ${Object.keys(FENCE_CHARS).join('|')}   Markdown: fence character (default backtick); fences`);
+    console.log('                           always outgrow runs of it in the content');
+    console.log(`  --xml-content ${XML_CONTENT_MODES.join('|')}`);
+    console.log('                           XML: file contents as written, escaping only closing tags');
+    console.log('                           (default), in CDATA sections, or entity-escaped');
+    console.log('  --section-ids            Number each file with an ID derived from its path, the same');
+    console.log('                           in every run (digest ID: line, structured id) (v3.4.0)');
+    console.log('  --per-project            Monorepos: one digest or structured context per Go module,');
+    console.log('                           npm workspace or Bazel package, e.g. digest-services-api.txt,');
+    console.log('                           each with an overview of all projects (v3.4.0)');
+    console.log('  --xref                   Append where each included symbol is defined and referenced');
+    console.log('  --todos [SCOPE]          Append the TODO, FIXME, HACK and XXX comments with their');
+    console.log('                           lines; SCOPE included (default) or repo (every analyzed file)');
+    console.log('  --deps                   Append the direct dependencies, locked versions and pins');
+    console.log('                           of go.mod, package.json, Cargo.toml and requirements.txt');
+    console.log('  --licenses               Remove license headers and leave out LICENSE files; append');
+    console.log('                           one note of the licenses and copyright holders (v3.4.0)');
+    console.log(`  --manifest               Write provenance (version, commit, command, hashes) to`);
+    console.log(`                           <context>${MANIFEST_SUFFIX} next to each context`);
+    console.log('  --session NAME           Conversation session: leave out files supplied unchanged');
+    console.log(`                           in earlier turns, send changed regions only (${SESSION_DIR})`);
+    console.log('  --output-file PATH       Write structured context to PATH');
+    console.log('  --stdout                 Write structured context to stdout (report on stderr)');
+    console.log('  --cache                  Reuse token counts/symbols of unchanged files (.ctxman/cache)');
+    console.log('  --clear-cache            Delete the content cache before analyzing');
+    console.log('  -j, --jobs N|auto        Read and tokenize files on N worker threads (default: 1)');
+    console.log('  --read-mode MODE         auto, mmap, pooled or buffer (default: auto: memory-map');
+    console.log('                           large files with mmap-io, reuse one read buffer otherwise)');
+    console.log();
+    console.log('Output Options (v2.3.0):');
+    console.log('  -o, --output FORMAT      Output format (default: toon)');
+    console.log('                           Formats: toon, json, yaml, csv, xml, markdown, gitingest');
+    console.log('  --context-export         Generate LLM context file');
+    console.log('  --context-clipboard      Copy context to clipboard');
+    console.log('  --out TARGET             Send context to file (default), stdout, clipboard, tmpfile');
+    console.log('                           or vscode (workspace file opened in VS Code) (v3.4.0)');
+    console.log('  --print-path             Print written file paths on stdout (report on stderr)');
+    console.log('  --fsync                  Sync output files to disk before they replace the old ones');
+    console.log('  --backup                 Keep each output file a run replaces as FILE.bak (v3.4.0)');
+    console.log('  --encrypt SCHEME:KEY     Encrypt the written context for an age or gpg recipient');
+    console.log('                           (age:age1..., gpg:alice@example.com, or a file of keys);');
+    console.log('                           repeat for more recipients; stdout and clipboard get armor');
+    console.log('  --anonymize [MAP]        Rename packages, files, declared names and string literals');
+    console.log(`                           to placeholders (pkg1, Type2, str3); MAP (default: ${ANONYMIZE_FILE})`);
+    console.log('                           keeps them stable and reverses them (ctxman deanonymize)');
+    console.log('  --redact                 Replace API keys, tokens, private keys and .env secrets');
+    console.log(`                           with [REDACTED:rule] placeholders (rules: ${REDACTION_FILE}) (v3.4.0)`);
+    console.log(`  --policy FILE            Content policy to check before rendering (default: ${POLICY_FILE}`);
+    console.log('                           when it exists): forbidden paths and patterns, required');
+    console.log('                           headers; violations are listed (v3.4.0)');
+    console.log('  --enforce                Fail the run on policy violations; nothing is written');
+    console.log('  --strip MODE             Remove comments, blank-lines or both before counting tokens (v3.4.0)');
+    console.log('  --keep-docs              With --strip, keep doc comments of exported symbols');
+    console.log('  --transforms LIST        Order of the content transforms, e.g. licenses,strip,normalize-whitespace,');
+    console.log('                           redact (v3.4.0); listed steps are enabled, flags add theirs');
+    console.log('                           (default: context.yaml profile transforms)');
+    console.log('  --notebook-outputs       Keep text outputs of Jupyter notebook cells (v3.4.0)');
+    console.log('  --include-generated      Keep binary files, minified bundles and lockfiles (v3.4.0)');
+    console.log('  --elide-bodies [DIR=]N   Replace function bodies over N lines with an elision comment,');
+    console.log('                           keeping signatures and docs (repeatable; DIR=0 keeps bodies)');
+    console.log('  --summarize-remote [N%]  Replace low-priority files with three-sentence LLM summaries:');
+    console.log('                           files over --max-tokens, else the lowest 25% (v3.4.0)');
+    console.log('  --summary-endpoint URL   OpenAI-compatible API (default: OPENAI_BASE_URL or OpenAI;');
+    console.log('                           key: OPENAI_API_KEY); summaries are cached by file hash,');
+    console.log('                           and by content-defined chunk for large files');
+    console.log('  --summary-model MODEL    Summary model (default: gpt-4o-mini)');
+    console.log('  --summary-concurrency N  Requests in flight (default: 4)');
+    console.log('  --summary-rpm N          Requests per minute (default: no limit)');
+    console.log('  --translate-comments     Translate non-English comments and docstrings to English');
+    console.log('                           before they are included (v3.4.0); cached by line hash');
+    console.log('  --translate-api API      openai (chat completions, default) or libretranslate');
+    console.log('  --translate-endpoint URL Translation API (default: OPENAI_BASE_URL or OpenAI, or');
+    console.log('                           http://localhost:5000 for LibreTranslate)');
+    console.log('  --translate-model MODEL  Translation model (default: gpt-4o-mini)');
+    console.log('  --translate-concurrency N Files in flight (default: 4)');
+    console.log('  --layout LIST            Order of the digest sections, with optional token caps');
+    console.log(`                           (${LAYOUT_SECTIONS.join(',')}; e.g. docs:8k,code,tests:20k);`);
+    console.log('                           sections not listed are left out (v3.4.0)');
+    console.log('  --template FILE          Render context through a Go text/template file (v3.4.0)');
+    console.log('  --var NAME=VALUE         Template variable, as {{.Vars.NAME}} (repeatable)');
+    console.log('  --strict                 Stop at the first file that cannot be read or parsed,');
+    console.log('                           instead of leaving it out (v3.4.0)');
+    console.log(`  --error-report FILE      JSON list of the files left out (default: ${ERROR_REPORT_FILE},`);
+    console.log('                           written when files fail)');
+    console.log('  --decision-log           Log why each file was or was not included, for');
+    console.log(`                           ctxman explain (${DECISION_FILE}, v3.4.0)`);
+    console.log('  --compression-stats [FILE]');
+    console.log('                           Tokens --strip, --elide-bodies, --dedupe, --session,');
+    console.log('                           --summarize-remote and the budget removed, by section');
+    console.log('                           and directory; FILE gets them as JSON (v3.4.0)');
+    console.log('  --list-formats           List all available output formats');
+    console.log();
+    console.log('UI Options (v2.3.0):');
+    console.log('  --simple                 Simple text-based output (no fancy UI)');
+    console.log('  --dashboard              Live dashboard mode');
+    console.log('  --wizard                 Force interactive wizard mode');
+    console.log();
+    console.log('Chunking Options (v2.3.0):');
+    console.log('  --chunk                  Enable smart chunking for large repos');
+    console.log('  --chunk-strategy TYPE    Chunking strategy (smart, size, file, directory)');
+    console.log('  --chunk-size TOKENS      Max tokens per chunk (default: 100000)');
+    console.log('                           Larger files are split at symbol boundaries (v3.4.0)');
+    console.log();
+    console.log('LLM Optimization (v2.3.7):');
+    console.log('  --target-model MODEL     Optimize for specific LLM (e.g., claude-sonnet-4.5)');
+    console.log('  --model PRESET           Tokenizer, window, output reserve and format of a model');
+    console.log(`                           (${Object.keys(MODEL_PRESETS).join(', ')})`);
+    console.log('  --auto-detect-llm        Auto-detect LLM from environment variables');
+    console.log('  --list-llms              List all supported LLM models');
+    console.log();
+    console.log('Token Budget (v3.4.0):');
+    console.log('  --max-tokens N           Pack highest-priority files into N tokens (e.g. 32k)');
+    console.log('                           Files that do not fit are trimmed to symbols or dropped');
+    console.log('  --tiers LIST             Fallbacks for files that do not fit, in order');
+    console.log('                           (full, symbols, signatures, names; default: full,symbols)');
+    console.log('  --tokenizer NAME         auto, cl100k_base, o200k_base, claude, estimate');
+    console.log('                           (auto picks from --target-model)');
+    console.log('  --reserve-prompt N       Tokens of the budget kept free for the task prompt');
+    console.log('  --reserve-output N       Tokens of the budget kept free for the answer');
+    console.log('                           (--reserve N is the same; default with --model: its');
+    console.log('                           output window)');
+    console.log('  --rebalance-pins [STEPS] Degrade pinned files larger than the budget until they fit:');
+    console.log('                           strip comments, elide bodies, keep the first chunk');
+    console.log('                           (default: strip,elide,chunk) (v3.4.0)');
+    console.log('  simulate --budgets LIST  Pack the context at each budget and compare what fits,');
+    console.log('                           exporting nothing; token counts or --model presets');
+    console.log('                           (e.g. 32k,128k,200k or gpt-4o,claude-sonnet)');
+    console.log('  --weights LIST           Rank files by weighted signals, e.g. churn=2,fanin=1,size=0');
+    console.log(`                           (${Object.entries(DEFAULT_WEIGHTS).map(([signal, weight]) => `${signal}=${weight}`).join(',')})`);
+    console.log('  --pin GLOB               Pack matching files first (repeatable)');
+    console.log('  --pin FILE:START-END     Pack only these lines of a file, first (also FILE:LINE,');
+    console.log('                           FILE:START- and FILE#REGION for //ctx:region blocks)');
+    console.log('  --explain                Print the score breakdown of every file');
+    console.log('  --entry-points           Rank main files, routes, CLI commands and cron jobs (and');
+    console.log('                           what they import) up, and list them in the report');
+    console.log('  --coverage FILE          Rank files with low test coverage up (LCOV, Go cover');
+    console.log('                           profile or Cobertura XML; repeatable)');
+    console.log('  --sarif FILE             Rank files with open findings of a SARIF report up');
+    console.log('  --tree                   Print the directory tree with tokens and budget share');
+    console.log('  --tree-sort KEY          Order tree entries by name or tokens (default: name)');
+    console.log('  --tree-depth N           Collapse directories below N levels');
+    console.log('  --tree-budget [TOKENS]   Digest tree of every analyzed file within TOKENS (default:');
+    console.log(`                           ${DEFAULT_TREE_BUDGET}): only directories of included files expand, others`);
+    console.log('                           show as dir/ (42 files, 12.3K tokens) (v3.4.0)');
+    console.log('  --dedupe [SIMILARITY]    Keep one file of each near-duplicate group (default: 0.8)');
+    console.log('  --docs [MODE]            READMEs, ARCHITECTURE.md, ADRs and doc.go first, linked');
+    console.log('                           from the code they describe (full, summary; default: full)');
+    console.log('  --owner OWNER            Only files CODEOWNERS assigns to OWNER, annotated with their');
+    console.log('                           owners (@team or @org/team; repeatable)');
+    console.log('  --owners                 Annotate each file with its CODEOWNERS owners');
+    console.log();
+    console.log('Context Profiles (v3.4.0):');
+    console.log('  --profile NAME           Use a profile from context.yaml (include/exclude globs,');
+    console.log('                           budget, tokenizer, format, output, priority rules,');
+    console.log('                           weights, pins, symbols)');
+    console.log('                           Flags on the command line override the profile');
+    console.log();
+    console.log('Workspaces (v3.4.0):');
+    console.log('  --workspace [FILE]       Build one context over several repositories, listed in');
+    console.log(`                           ${WORKSPACE_FILE} (path, prefix) or go.work (use)`);
+    console.log('                           Paths carry each repo\'s prefix; imports resolve across repos');
+    console.log();
+    console.log('Dependency Expansion (v3.4.0):');
+    console.log('  --focus TARGET           Export only a symbol, file:symbol or file');
+    console.log('  --expand-deps N          Also include definitions it uses, N references deep');
+    console.log('  --symbol NAME            Export only this symbol with its imports and receiver type');
+    console.log('                           e.g. pkg/calc.Calculator.Add, src/a.ts:parse (repeatable)');
+    console.log('                           or a symbol ID from --format json (survives moves and renames)');
+    console.log('  --include-implementations  Add the types implementing a selected interface');
+    console.log('                           and their methods (Go: by method set)');
+    console.log('  --exported-methods       Types come with only their exported methods, in budgets');
+    console.log('                           (a Go struct and its methods pack as one unit) and --symbol');
+    console.log('  --symbol-backend TYPE    auto, tree-sitter or heuristic (default: auto)');
+    console.log('  --lsp SERVER             Resolve references with a language server: a command');
+    console.log('                           (gopls, "typescript-language-server --stdio") or the');
+    console.log('                           tcp://host:port of a running one (gopls -listen)');
+    console.log('  --lsp-languages LIST     Languages the server answers for (e.g. go; known');
+    console.log('                           servers need none)');
+    console.log('  --list-extractors        List symbol extractors, including custom ones from');
+    console.log('                           .ctxman/extractors.json');
+    console.log('  --pair-tests [MODE]      Add the tests of selected sources and the sources of');
+    console.log('                           selected tests; MODE full (default) or names');
+    console.log(`  --usage-examples [N]     Add N (default: ${DEFAULT_EXAMPLES}) call sites of each --symbol or --focus,`);
+    console.log('                           varied in arguments and from code and tests');
+    console.log();
+    console.log('Git Integration (v3.0.0):');
+    console.log('  --rev REF                Context of the code at a commit, branch or tag (v3.4.0)');
+    console.log('                           Read from the git object database, without checkout');
+    console.log('  --sparse [FILE]          Scan only what the sparse-checkout definition keeps, even');
+    console.log('                           when sparse checkout is off; FILE is another definition');
+    console.log('                           (applied automatically when it is on) (v3.4.0)');
+    console.log('  --no-sparse              Scan the whole tree despite a sparse checkout');
+    console.log('  --files -|FILE           Analyze only the paths listed on stdin or in FILE, one per');
+    console.log('                           line or NUL separated (git diff --name-only | ctxman --files -)');
+    console.log('    --suggest [N]          Rank the N files (default: 10) most related to the listed ones');
+    console.log('                           by co-change history, shared names and import distance');
+    console.log('    --accept-suggestions N Add the top N related files to the list');
+    console.log('  --changed-only           Analyze only files with uncommitted changes');
+    console.log('  --changed-since REF      Analyze files changed since commit/branch');
+    console.log('  --with-authors           Include author information');
+    console.log('  --with-history           Include commit history');
+    console.log('  diff [options]           Context of the symbols a diff touches (v3.4.0)');
+    console.log('    --base REF             Compare with REF or a range such as main..feature');
+    console.log('                           (default: working tree against HEAD)');
+    console.log('    --expand-deps N        Dependency depth of touched symbols (default: 1)');
+    console.log('  api-diff --base REV      Context of the exported symbols whose signatures changed');
+    console.log('                           since REV, with all their call sites (v3.4.0)');
+    console.log('    --head REV             Compare with REV instead of the working tree');
+    console.log('  pr URL|N [options]       Review prompt for a GitHub PR or GitLab MR: description,');
+    console.log('                           diffs and review comments with the code they touch (v3.4.0)');
+    console.log('    --provider NAME        github or gitlab, for self-hosted instances given N');
+    console.log('                           (tokens: GITHUB_TOKEN or GH_TOKEN, GITLAB_TOKEN)');
+    console.log('  gen URL[@REF] [options]  Context of a remote repository: shallow clone into a');
+    console.log('                           temporary directory, written here (v3.4.0)');
+    console.log('                           (e.g. https://github.com/org/repo@main, org/repo@v2.1.0)');
+    console.log('    --via METHOD           auto, git or tarball (GitHub only; default: git if installed)');
+    console.log('  gen ARCHIVE [options]    Context of a .zip or .tar(.gz|.bz2|.xz) file or URL, extracted');
+    console.log('                           into a temporary directory (v3.4.0)');
+    console.log('  gen docker://IMAGE       Context of a container image\'s file system, its layers pulled');
+    console.log('                           from the registry (v3.4.0; e.g. docker://nginx:1.25, oci://ghcr.io/org/app)');
+    console.log('    --platform OS/ARCH     Image of a multi-platform index (default: linux/amd64)');
+    console.log('                           (private registries: REGISTRY_AUTH=user:password)');
+    console.log('  query "TEXT" [options]   Context of the chunks most relevant to TEXT (v3.4.0)');
+    console.log('    --embeddings TYPE      transformers, openai, ollama or local (default: transformers)');
+    console.log('    --embedding-model M    Embedding model (e.g. text-embedding-3-small, nomic-embed-text)');
+    console.log('    --embedding-url URL    Endpoint for ollama or a local OpenAI-compatible server');
+    console.log('    --top N                Keep at most N chunks (default: 10, or all that fit --max-tokens)');
+    console.log('  enrich [FILE] [options]  Context of a code snippet or stack trace piped on stdin (or');
+    console.log('                           in FILE): the snippet, then the functions around its frames');
+    console.log('                           and the definitions of the names it uses (v3.4.0)');
+    console.log();
+    console.log('Context Selection (v3.4.0):');
+    console.log('  select [options]         Browse the file tree with token counts, toggle files');
+    console.log('                           and symbols, and press enter to generate their context');
+    console.log('                           (digest.txt unless --context-export or --format is set)');
+    console.log('                           --max-tokens N or --target-model shows the budget');
+    console.log('  select EXPR [options]    Generate the context of the symbols matching EXPR, e.g.');
+    console.log(`                           'lang==go && (exported || path~"internal/auth") && tokens<2000'`);
+    console.log('                           (fields: lang, kind, name, qname, package, path, signature,');
+    console.log('                           doc, parent, exported, line, lines, tokens)');
+    console.log('    --list                 Only list the matching symbols');
+    console.log(`    --db [FILE]            Keep the symbol index in SQLite (default: ${SYMBOL_INDEX_FILE})`);
+    console.log();
+    console.log('Graph Export (v3.4.0):');
+    console.log('  graph [options]          Call graph and type dependency graph (stdout)');
+    console.log('    --format mermaid|dot   Mermaid flowchart or Graphviz DOT (default: mermaid)');
+    console.log('    --kind calls|types     Only the call graph or only the type graph');
+    console.log('    --focus TARGET, --symbol NAME');
+    console.log('                           Show what the selection includes and why');
+    console.log('    --output-file PATH     Write the graph to PATH');
+    console.log();
+    console.log('Symbol Index (v3.4.0):');
+    console.log('  symbols [options]        List project symbols with their symbol IDs');
+    console.log('    --kind KIND            class, interface, function, method, ... (repeatable)');
+    console.log('    --package PKG          Package ID; PKG/... includes everything below (repeatable)');
+    console.log('    --name PATTERN         Name or qualified name; * and ? are wildcards');
+    console.log('    --exported             Only exported symbols');
+    console.log(`    --db [FILE]            Keep the index in SQLite (default: ${SYMBOL_INDEX_FILE});`);
+    console.log('                           unchanged files are not parsed again');
+    console.log('    --json                 Print the symbols as JSON');
+    console.log('  outline FILE             Nested symbols of one file with their lines, signatures');
+    console.log('                           and doc comments; no project scan');
+    console.log('    --format json          LSP-style JSON: kinds, ranges, selection ranges, hovers');
+    console.log('    --symbol-backend NAME  auto (default), tree-sitter or heuristic');
+    console.log();
+    console.log('Context Packs (v3.4.0):');
+    console.log('  pack [FILE] [options]    Generate context (options as with --cli) into a .ctxpack');
+    console.log('                           archive with its manifest, source hashes and token map');
+    console.log('                           (default: <project>.ctxpack)');
+    console.log('    --encrypt SCHEME:KEY   Encrypt the archive for a recipient (.ctxpack.age or .gpg)');
+    console.log('  unpack FILE              Extract the contexts, manifest and token map of a pack');
+    console.log('    --dir DIR              Where to extract (default: the pack name)');
+    console.log('    --verify               Check the packed sources against the working tree');
+    console.log('    --regenerate           Rerun the packed command and compare the contexts');
+    console.log('  decrypt FILE [OUTPUT]    Decrypt a context or pack written with --encrypt');
+    console.log('                           (default OUTPUT: FILE without .age, .gpg or .asc)');
+    console.log('    --identity KEY         age identity file (gpg uses its keyring)');
+    console.log('    --stdout               Print the decrypted content instead');
+    console.log('  deanonymize FILE [OUTPUT]  Put the real names back into an answer to an anonymized');
+    console.log('                           context (default: print it)');
+    console.log(`    --map FILE             Mapping written by --anonymize (default: ${ANONYMIZE_FILE})`);
+    console.log(`  reproduce MANIFEST       Regenerate the contexts of a ${MANIFEST_SUFFIX} (--manifest)`);
+    console.log('                           and check them against their recorded hashes');
+    console.log('    --dir DIR              Where to write them (default: next to the manifest)');
+    console.log('    --verify               Only check; write nothing');
+    console.log();
+    console.log('Conversation Sessions (v3.4.0):');
+    console.log('  session [NAME]           List sessions, or the files a session has supplied');
+    console.log('    --reset                Forget the session; its next turn sends everything');
+    console.log();
+    console.log('Coverage Report (v3.4.0):');
+    console.log('  report coverage          Heat map of how often each directory made it into the');
+    console.log(`                           contexts of runs with --cache (${COVERAGE_FILE}), and`);
+    console.log('                           the files and ignored paths that never did');
+    console.log('    --depth N              Directory levels shown (default: 2)');
+    console.log('    --min-runs N           Runs a file must be seen in to be listed as never');
+    console.log('                           included (default: 3)');
+    console.log('    --reset                Forget the recorded runs');
+    console.log('    --json                 Print the report as JSON');
+    console.log();
+    console.log('Exclusion Explainer (v3.4.0):');
+    console.log('  explain [FILE]           Why FILE was or was not included in the last generation:');
+    console.log('                           the ignore rule that matched, a skip, the selection, a');
+    console.log('                           transform, or its priority against the token budget');
+    console.log(`                           (${DECISION_FILE}, see --decision-log); without FILE, a`);
+    console.log('                           summary of the run');
+    console.log('    --json                 Print the decision as JSON');
+    console.log();
+    console.log('Context Comparison (v3.4.0):');
+    console.log('  compare OLD NEW          Files, symbols and tokens that differ between two');
+    console.log('                           generated contexts (--format json, llm-context.json');
+    console.log('                           or .ctxpack)');
+    console.log('    --json                 Print the comparison as JSON');
+    console.log();
+    console.log('Context Merging (v3.4.0):');
+    console.log('  merge A.json B.json ...  Combine structured contexts (--format json or .ctxpack)');
+    console.log('                           of several runs or projects into one; later contexts');
+    console.log('                           win where they changed a file');
+    console.log('    --dedupe               Keep one file of identical contents under other paths');
+    console.log('    --max-tokens N         Re-pack the combined files into N tokens (--tokenizer,');
+    console.log('                           --tiers and the reserves apply)');
+    console.log('    --format FORMAT        json (default), yaml, markdown or xml; or --gitingest');
+    console.log('    --output-file FILE     Where to write it (default: merged-context.json)');
+    console.log('    --stdout               Write it to stdout, the report to stderr');
+    console.log();
+    console.log('Context Lint (v3.4.0):');
+    console.log('  lint [CONTEXT] [options] Check a generated context (digest, --format json or');
+    console.log('                           .ctxpack) for truncated symbols, missing type');
+    console.log('                           definitions, duplicates and budget overruns; without');
+    console.log('                           CONTEXT, lints the digest the options generate');
+    console.log('    --model LIST           Models whose context window it must fit');
+    console.log('                           (default: --target-model)');
+    console.log('    --max-tokens N         Token budget it must fit');
+    console.log('    --strict               Exit with 1 on warnings too, not only errors');
+    console.log('    --json                 Print the findings as JSON');
+    console.log('  check --against FILE     Regenerate a checked-in context (options as with --cli;');
+    console.log('                           format from the extension) and exit with 1 when FILE');
+    console.log('                           is stale, for CI and pre-commit hooks');
+    console.log('    --write                Refresh FILE instead');
+    console.log();
+    console.log('Benchmark (v3.4.0):');
+    console.log('  bench [options]          Time and measure memory of the scan, analyze, parse,');
+    console.log('                           pack and format phases (analysis options apply)');
+    console.log('    --iterations N         Measured runs; timings show median, min and max (default: 1)');
+    console.log('    --warmup N             Unmeasured runs before measuring (default: 0)');
+    console.log('    --cpu-profile FILE     CPU profile of the measured runs (pprof; .cpuprofile: V8)');
+    console.log('    --heap-profile FILE    Sampled allocations (pprof; .heapprofile: V8)');
+    console.log('    --allocations          Bytes each phase allocates (sampled, freed ones included)');
+    console.log('                           and its file read buffers; compare --read-mode values');
+    console.log('    --json                 Print the measurements as JSON');
+    console.log();
+    console.log('Token Calibration (v3.4.0):');
+    console.log('  calibrate [options]      Compare token estimates with a real tokenizer on a sample');
+    console.log(`                           of project files; saves per-language factors (${CALIBRATION_FILE})`);
+    console.log('    --tokenizer NAME       Tokenizer to match: cl100k_base, o200k_base or claude');
+    console.log('    --sample N             Files compared, spread over languages (default: 200)');
+    console.log('    --json                 Print the calibration as JSON');
+    console.log();
+    console.log('Shell Completion (v3.4.0):');
+    console.log('  completion bash|zsh|fish Print a completion script; --symbol, --profile and');
+    console.log('                           --package values come from context.yaml and the');
+    console.log(`                           symbols --db index (${SYMBOL_INDEX_FILE})`);
+    console.log();
+    console.log('Platform Features (v3.0.0):');
+    console.log('  serve [options]          Start REST API server');
+    console.log('    --port PORT            Server port (default: 3000)');
+    console.log('    --auth-token TOKEN     API authentication token');
+    console.log('  serve --http [HOST]:PORT Context daemon for this project (v3.4.0)');
+    console.log('                           GET /context, GET /symbols, POST /query');
+    console.log('                           :PORT listens on localhost; --cache, --embeddings apply');
+    console.log('    --metrics [[HOST]:PORT] Prometheus metrics on GET /metrics (default: localhost:9464,');
+    console.log('                           v3.4.0): scan time, cache hit rate, tokens, skipped files, errors');
+    console.log('  serve --grpc [HOST]:PORT gRPC service (lib/api/grpc/ctxman.proto, v3.4.0)');
+    console.log('                           GenerateContext, QuerySymbols, WatchChanges (streaming)');
+    console.log('                           --auth-token and --cache apply');
+    console.log('  serve --mcp              Serve this project over MCP (stdio) for Claude Desktop etc.');
+    console.log('    --symbol-backend TYPE  auto, tree-sitter or heuristic (default: auto)');
+    console.log('    --cache                Persist symbol outlines in .ctxman/cache');
+    console.log('  editor                   JSON-RPC server for editor extensions (stdio, v3.4.0)');
+    console.log('                           GetOutline, BuildContextForSelection, PinSymbol');
+    console.log('    --socket PATH          Listen on a unix socket (named pipe on Windows) instead');
+    console.log('  watch [options]          Watch mode with auto-analysis');
+    console.log('    --debounce MS          Debounce delay (default: 1000ms)');
+    console.log('    --gitingest            Keep digest.txt up to date (v3.4.0)');
+    console.log('    --context-export       Keep llm-context.json up to date (v3.4.0)');
+    console.log('    --output-file PATH     Write the regenerated context to PATH');
+    console.log('    --stdout               Stream each regenerated context to stdout');
+    console.log();
+    console.log('Daemon Mode (v3.4.0):');
+    console.log('  daemon start             Keep a warm index of this project in a background process');
+    console.log('  daemon run               Same, in the foreground');
+    console.log('  daemon status|stop       Show or stop the daemon of this project');
+    console.log('    --metrics [[HOST]:PORT] Prometheus metrics of start and run (default: localhost:9464)');
+    console.log('  ctxman-client [options]  Same options as ctxman --cli, answered by the daemon');
+    console.log('  --ast-cache MB           Memory for parsed symbol outlines in daemon, watch, serve');
+    console.log('                           and editor modes (default: 64, 0 = none); LRU beyond it');
+    console.log('                           (runs ctxman directly when no daemon is running)');
+    console.log();
+    console.log('General Options:');
+    console.log('  -h, --help               Show this help');
+    console.log('  --version                Show version number');
+    console.log();
+    console.log('Configuration Files:');
+    console.log('  .contextinclude          Include only specified files');
+    console.log('  .contextignore           Exclude specified files');
+    console.log('  .methodinclude           Include only specified methods');
+    console.log('  .methodignore            Exclude specified methods');
+    console.log('  context.yaml             Named context profiles (--profile)');
+    console.log(`  ${WORKSPACE_FILE.padEnd(25)}Repositories of a multi-repo workspace (--workspace)`);
+    console.log();
+    console.log('Format Conversion (v2.3.2):');
+    console.log('  convert INPUT --from FORMAT --to FORMAT');
+    console.log('                           Convert between formats');
+    console.log('  Examples:');
+    console.log('    ctxman convert report.json --from json --to toon');
+    console.log('    ctxman convert data.toon --from toon --to yaml');
+    console.log('    ctxman convert context.yaml --from yaml --to json');
+    console.log();
+    console.log('GitHub Integration (v2.3.6+):');
+    console.log('  github URL [options]     Generate GitIngest from GitHub repository');
+    console.log('  git URL [options]        Alias for github command');
+    console.log('  Examples:');
+    console.log('    ctxman github facebook/react');
+    console.log('    ctxman github https://github.com/vercel/next.js --branch canary');
+    console.log('    ctxman git angular/angular -o docs/angular.txt');
+    console.log();
+    console.log('Examples:');
+    console.log('  ctxman                                  # Launch interactive wizard (DEFAULT)');
+    console.log('  ctxman --cli                            # Use CLI mode');
+    console.log('  ctxman --cli -o json --save-report      # CLI: JSON format + save report');
+    console.log('  ctxman --cli -o toon --context-clipboard   # CLI: TOON to clipboard');
+    console.log('  ctxman --cli --gitingest --chunk        # CLI: GitIngest with chunking');
+    console.log('  ctxman --cli -m -o yaml                 # CLI: Method-level + YAML format');
+    console.log('  ctxman --cli --chunk --chunk-strategy smart   # CLI: Smart chunking');
+    console.log('  ctxman --cli -g --max-tokens 32k --tokenizer o200k_base  # CLI: Digest within budget');
+    console.log('  ctxman --cli --profile api-review       # CLI: Context of the api-review profile');
+    console.log('  ctxman convert data.json --from json --to toon  # Convert formats');
+    console.log();
+    console.log('Format Comparison (token efficiency):');
+    console.log('  TOON:     40-50% reduction (most efficient)');
+    console.log('  JSON:     Standard format (baseline)');
+    console.log('  YAML:     Human-readable (5-10% larger than JSON)');
+    console.log('  Markdown: Documentation-friendly (20-30% larger)');
+    console.log();
+    console.log('For more information: https://github.com/hakkisagdic/ctxman');
+}
+
+function listFormats() {
+    const registry = new FormatRegistry();
+    const formats = registry.getAllInfo();
+
+    console.log('📋 Available Output Formats:\n');
+    console.log('Format'.padEnd(15) + 'Description'.padEnd(50) + 'Extension');
+    console.log('='.repeat(80));
+
+    for (const [name, info] of Object.entries(formats)) {
+        console.log(
+            name.padEnd(15) +
+            info.description.substring(0, 48).padEnd(50) +
+            info.extension
+        );
+    }
+
+    console.log();
+    console.log('Usage: ctxman --output <format>');
+    console.log('Example: ctxman --output toon --context-clipboard');
+}
+
+function listExtractors() {
+    const extractor = new SymbolExtractor({ backend: 'heuristic' });
+
+    console.log('🧩 Symbol Extractors:\n');
+    console.log('Extractor'.padEnd(20) + 'Source'.padEnd(20) + 'Extensions');
+    console.log('='.repeat(80));
+
+    for (const plugin of extractor.plugins) {
+        const source = plugin.backend ? (plugin.command ? 'command' : 'module') : 'built-in';
+        console.log(plugin.name.padEnd(20) + source.padEnd(20) + plugin.extensions.join(' '));
+    }
+
+    console.log();
+    console.log(`Add extractors in ${EXTRACTORS_FILE} (see README: Custom Extractors)`);
+}
+
+function getChangedSince(args) {
+    const sinceIndex = args.findIndex(arg => arg === '--changed-since');
+    if (sinceIndex !== -1 && args[sinceIndex + 1]) {
+        return args[sinceIndex + 1];
+    }
+    return null;
+}
+
+async function runAPIServer(args) {
+    const portIndex = args.findIndex(arg => arg === '--port');
+    let port = portIndex !== -1 && args[portIndex + 1]
+        ? parseInt(args[portIndex + 1], 10)
+        : 3000;
+    let host = 'localhost';
+
+    // --http [HOST]:PORT (v3.4.0); a bare :PORT stays on localhost
+    if (args.includes('--http')) {
+        const address = getHttpAddress(args);
+        host = address.host || host;
+        port = address.port;
+    }
+
+    const authTokenIndex = args.findIndex(arg => arg === '--auth-token');
+    const authToken = authTokenIndex !== -1 && args[authTokenIndex + 1]
+        ? args[authTokenIndex + 1]
+        : null;
+
+    // Prometheus metrics on their own listener (v3.4.0, --metrics)
+    const metricsAddress = getMetricsAddress(args);
+    const metrics = metricsAddress ? new ContextMetrics({ mode: 'http', version: pkg.version }) : null;
+
+    const server = new APIServer({
+        port,
+        host,
+        authToken,
+        projectRoot: process.cwd(),
+        metrics,
+        service: {
+            symbolBackend: getSymbolBackend(args),
+            cache: args.includes('--cache') ? new ContentCache({ root: process.cwd() }) : null,
+            parseCache: getParseCache(args),
+            embeddings: getEmbeddingProvider(args),
+            embeddingModel: getFlagValue(args, '--embedding-model'),
+            embeddingUrl: getFlagValue(args, '--embedding-url')
+        }
+    });
+
+    // Handle shutdown
+    process.on('SIGINT', () => {
+        console.log('\n\n🛑 Shutting down server...');
+        server.stop();
+        process.exit(0);
+    });
+
+    server.start();
+    if (metrics) {
+        await startMetrics(metrics, metricsAddress);
+    }
+}
+
+/**
+ * --metrics [[HOST]:PORT] (v3.4.0): null without the flag, localhost:9464 without a value
+ */
+function getMetricsAddress(args) {
+    if (!args.includes('--metrics')) return null;
+    const value = getFlagValue(args, '--metrics');
+    if (!value || value.startsWith('-')) {
+        return { host: 'localhost', port: METRICS_PORT };
+    }
+    const { host, port } = getHttpAddress(args, '--metrics');
+    return { host: host || 'localhost', port };
+}
+
+/**
+ * Serve GET /metrics, or exit when the address is taken
+ * @returns {Promise<string>} URL of the endpoint
+ */
+async function startMetrics(metrics, address) {
+    let server;
+    try {
+        server = await metrics.listen(address);
+    } catch (error) {
+        console.error(`❌ Metrics endpoint on ${address.host}:${address.port}: ${error.message}`);
+        process.exit(1);
+    }
+    const url = `http://${address.host}:${server.address().port}/metrics`;
+    console.log(`📈 Metrics: ${url}`);
+    return url;
+}
+
+function getHttpAddress(args, flag = '--http') {
+    const value = getFlagValue(args, flag) || '';
+    const match = /^(?:(\[[^\]]+\]|[^:]*):)?(\d+)$/.exec(value);
+    const port = match ? Number(match[2]) : NaN;
+    if (!match || port < 1 || port > 65535) {
+        console.error(`❌ Invalid ${flag} address: ${value || '(missing)'} (e.g. :8080 or 0.0.0.0:8080)`);
+        process.exit(1);
+    }
+    return { host: match[1] ? match[1].replace(/^\[|\]$/g, '') : null, port };
+}
+
+/**
+ * gRPC service (lib/api/grpc/ctxman.proto) on --grpc [HOST]:PORT (v3.4.0)
+ */
+async function runGrpcServer(args) {
+    const { default: GrpcServer } = await import('../lib/api/grpc/GrpcServer.js');
+    const { host, port } = getHttpAddress(args, '--grpc');
+    const cache = args.includes('--cache') ? new ContentCache({ root: process.cwd() }) : null;
+    const server = new GrpcServer(process.cwd(), {
+        symbolBackend: getSymbolBackend(args),
+        cache,
+        parseCache: getParseCache(args),
+        authToken: getFlagValue(args, '--auth-token')
+    });
+
+    let address;
+    try {
+        address = await server.listen(port, host || 'localhost');
+    } catch (error) {
+        console.error(`❌ gRPC server failed: ${error.message}`);
+        process.exit(1);
+    }
+    console.log(`📡 gRPC server listening on ${address.host}:${address.port} (ctxman.v1.ContextService)`);
+
+    process.on('SIGINT', async () => {
+        console.log('\n\n🛑 Shutting down server...');
+        await server.close();
+        cache?.save();
+        process.exit(0);
+    });
+}
+
+async function runMCPServer(args) {
+    const symbolBackend = getSymbolBackend(args);
+
+    try {
+        // Loaded lazily so the MCP SDK is only required for this mode
+        const { default: MCPServer } = await import('../lib/api/mcp/server.js');
+
+        // stdout is the MCP channel: status messages go to stderr
+        const cache = args.includes('--cache') ? new ContentCache({ root: process.cwd() }) : null;
+        const server = new MCPServer({ projectRoot: process.cwd(), symbolBackend, cache });
+        await server.start();
+    } catch (error) {
+        console.error('❌ MCP server failed:', error.message);
+        process.exit(1);
+    }
+}
+
+/**
+ * JSON-RPC server for editor extensions, on stdio or --socket PATH (v3.4.0)
+ */
+async function runEditorServer(args) {
+    const { default: EditorServer } = await import('../lib/api/editor/EditorServer.js');
+    const socketPath = getFlagValue(args, '--socket');
+    const cache = args.includes('--cache') ? new ContentCache({ root: process.cwd() }) : null;
+    const server = new EditorServer(process.cwd(), { symbolBackend: getSymbolBackend(args), cache, parseCache: getParseCache(args) });
+
+    if (socketPath) {
+        try {
+            await server.listen(socketPath);
+        } catch (error) {
+            console.error(`❌ Editor server failed: ${error.message}`);
+            process.exit(1);
+        }
+        console.error(`🖊️  Editor server listening on ${socketPath}`);
+        return;
+    }
+
+    // stdout is the RPC channel: status messages go to stderr
+    getLogger().silent = true;
+    console.error('🖊️  Editor server running on stdio');
+    await server.connect(process.stdin, process.stdout);
+    cache?.save();
+}
+
+async function runChangedFilesAnalysis(options) {
+    console.log('🔀 Git Integration - Analyzing Changed Files');
+    console.log('═'.repeat(60));
+    console.log();
+
+    const diffAnalyzer = new DiffAnalyzer(options.projectRoot);
+    const changes = diffAnalyzer.analyzeChanges(options.changedSince);
+
+    console.log(`📝 Found ${changes.totalChangedFiles} changed files`);
+    if (options.changedSince) {
+        console.log(`   Since: ${options.changedSince}`);
+    }
+    console.log(`   Impact: ${changes.impact.level.toUpperCase()} (score: ${changes.impact.score})`);
+    console.log();
+
+    if (changes.totalChangedFiles === 0) {
+        console.log('✅ No changed files to analyze');
+        return;
+    }
+
+    // Analyze only changed files
+    const analyzer = new TokenAnalyzer(options.projectRoot, {
+        ...options,
+        fileFilter: (filePath) => changes.changedFiles.includes(filePath)
+    });
+
+    analyzer.run();
+}
+
+/**
+ * Context limited to what a diff touches plus its dependencies (v3.4.0)
+ */
+async function runDiffContext(args) {
+    const options = parseArguments(args);
+    useStdoutForContext(args, options);
+    const baseIndex = args.indexOf('--base');
+    const base = baseIndex !== -1 && args[baseIndex + 1] ? args[baseIndex + 1] : null;
+
+    console.log('🔀 Git Integration - Diff Context');
+    console.log('═'.repeat(60));
+    console.log();
+
+    const diffAnalyzer = new DiffAnalyzer(options.projectRoot);
+    if (!diffAnalyzer.git.isGitRepo) {
+        console.error('❌ Not a git repository');
+        process.exit(1);
+    }
+
+    let changes;
+    try {
+        // Earlier exports are outputs, not changes under review
+        const outputs = Object.values(DEFAULT_OUTPUTS);
+        changes = diffAnalyzer.getChangedLines(base).filter(change => !outputs.includes(change.path));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    if (changes.length === 0) {
+        console.log(`✅ No changes since ${base || 'HEAD'}`);
+        return;
+    }
+
+    // Review context goes to a digest unless another export is requested
+    if (!options.contextExport && !options.contextToClipboard) {
+        options.gitingest = true;
+    }
+    options.diff = { base, changes };
+
+    await prepareAnalysisOptions(options);
+    printStartupInfo(options);
+
+    await runAnalysis(options);
+}
+
+/**
+ * Context of the exported symbols changed since --base plus their call sites (v3.4.0)
+ * The head is the working tree, or --head REV read like --rev.
+ */
+async function runApiDiff(args) {
+    const base = getFlagValue(args, '--base');
+    const head = getFlagValue(args, '--head');
+    if (!base) {
+        console.error('❌ Usage: ctxman api-diff --base REV [--head REV] [options]');
+        process.exit(1);
+    }
+    for (const flag of ['--rev', '--focus', '--symbol', '--files', '--workspace', '--changed-only', '--changed-since']) {
+        if (args.includes(flag)) {
+            console.error(`❌ api-diff selects its own symbols and cannot be combined with ${flag}${flag === '--rev' ? ' (use --head)' : ''}`);
+            process.exit(1);
+        }
+    }
+
+    const options = parseArguments(args);
+    useStdoutForContext(args, options);
+
+    console.log('🔀 Git Integration - API Diff');
+    console.log('═'.repeat(60));
+    console.log();
+
+    let baseRevision;
+    try {
+        baseRevision = GitRevision.open(options.projectRoot, base);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    // API context goes to a digest unless another export is requested
+    if (!options.contextExport && !options.contextToClipboard && !options.structuredFormat) {
+        options.gitingest = true;
+    }
+    options.rev = head;
+    options.apiDiff = { base: baseRevision.describe(), head: null, symbols: [] };
+
+    await prepareAnalysisOptions(options);
+    options.apiDiff.head = options.revision?.describe() || null;
+
+    // Exported symbols at the base, after the current ignore rules
+    const files = new TokenAnalyzer(options.projectRoot, { revision: baseRevision, profile: options.profile, dashboard: true }).scanProject();
+    const baseGraph = new DependencyGraph({ root: options.projectRoot, extractor: options.symbolExtractor, revision: baseRevision })
+        .build(files.map(filePath => ({ path: filePath, relativePath: relative(options.projectRoot, filePath) })));
+    options.apiDiff.symbols = new ApiDiff().exportedSymbols(baseGraph);
+
+    printStartupInfo(options);
+
+    await runAnalysis(options);
+}
+
+/**
+ * Review prompt for a GitHub pull request or GitLab merge request (v3.4.0)
+ * The code context is the diff scope of the PR's changes in the local checkout.
+ */
+async function runPullRequestContext(args) {
+    const prIndex = args.indexOf('pr');
+    const reference = args[prIndex + 1];
+    if (!reference || reference.startsWith('-')) {
+        console.error('❌ Usage: ctxman pr URL|NUMBER [--provider github|gitlab] [--out TARGET] [--max-tokens N]');
+        process.exit(1);
+    }
+    for (const flag of ['--chunk', '--template', '--context-export', '--context-clipboard', '--format', '--files', '--rev', '--focus', '--symbol']) {
+        if (args.includes(flag)) {
+            console.error(`❌ pr builds its own review prompt and cannot be combined with ${flag}`);
+            process.exit(1);
+        }
+    }
+    const provider = getFlagValue(args, '--provider');
+    if (args.includes('--provider') && !PR_PROVIDERS.includes(provider)) {
+        console.error(`❌ Invalid --provider: ${provider} (expected ${PR_PROVIDERS.join(' or ')})`);
+        process.exit(1);
+    }
+
+    // The prompt goes to --out; the code context is collected in a temporary digest
+    const analysisArgs = args.filter((arg, i) => i !== prIndex && i !== prIndex + 1 &&
+        !['--provider', '--out', '--print-path'].includes(arg) && !['--provider', '--out'].includes(args[i - 1]));
+    const options = parseArguments(analysisArgs);
+    const out = getOut(args);
+    useStdoutForContext(args, { out, printPath: args.includes('--print-path') });
+
+    console.log('🔀 Git Integration - Pull Request Context');
+    console.log('═'.repeat(60));
+    console.log();
+
+    const git = new GitClient(options.projectRoot);
+    let pullRequest;
+    try {
+        const remoteUrl = git.isGitRepo ? getOriginUrl(git) : null;
+        const ref = parsePullRequestRef(reference, { remoteUrl, provider });
+        console.log(`🔗 Fetching ${ref.project} ${ref.provider === 'gitlab' ? '!' : '#'}${ref.number} from ${ref.host}...`);
+        pullRequest = await PullRequest.fetch(ref);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    console.log(`📝 ${pullRequest.summary()}`);
+
+    const head = git.isGitRepo ? getHeadCommit(git) : null;
+    if (pullRequest.headSha && head && head !== pullRequest.headSha) {
+        console.log(`⚠️  Local HEAD ${head.slice(0, 7)} is not the PR head ${pullRequest.headSha.slice(0, 7)}; code context shows the local files`);
+    }
+    console.log();
+
+    options.gitingest = true;
+    options.diff = { base: `${pullRequest.base} (PR ${reference})`, changes: pullRequest.lineChanges() };
+    await prepareAnalysisOptions(options);
+    printStartupInfo(options);
+    const target = new OutputTarget({ target: 'tmpfile', root: options.projectRoot });
+    options.outputTarget = target;
+
+    await runAnalysis(options);
+    const context = target.written.length > 0 ? readFileSync(target.written[0], 'utf8') : null;
+    if (target.written.length > 0) {
+        rmSync(dirname(target.written[0]), { recursive: true, force: true });
+    }
+
+    const prompt = pullRequest.formatPrompt(context);
+    const promptTarget = new OutputTarget({
+        target: out || 'file',
+        root: options.projectRoot,
+        printPath: args.includes('--print-path'),
+        ...getFileOptions(args)
+    });
+    const outputPath = promptTarget.write(prompt, `pr-${pullRequest.number}-review.md`);
+    console.log(`\n💾 Review prompt ${outputPath ? `saved to: ${relative(process.cwd(), outputPath)}` : `sent to ${promptTarget.target}`}`);
+    console.log(`📊 Prompt tokens: ${TokenUtils.calculate(prompt, `pr-${pullRequest.number}-review.md`).toLocaleString()}`);
+}
+
+/**
+ * URL of the origin remote; null without one
+ */
+function getOriginUrl(git) {
+    try {
+        return git.exec('remote get-url origin') || null;
+    } catch {
+        return null;
+    }
+}
+
+/**
+ * Commit checked out in the working tree; null before the first commit
+ */
+function getHeadCommit(git) {
+    try {
+        return git.exec('rev-parse HEAD');
+    } catch {
+        return null;
+    }
+}
+
+/**
+ * Context of a repository that is not checked out locally (v3.4.0)
+ * The repository is cloned or downloaded, an archive extracted or an image's
+ * layers applied into a temporary directory, which is removed afterwards;
+ * contexts are written to the working directory.
+ */
+async function runRemoteContext(args) {
+    const genIndex = args.indexOf('gen');
+    const spec = args[genIndex + 1];
+    if (!spec || spec.startsWith('-')) {
+        console.error('❌ Usage: ctxman gen URL[@REF]|ARCHIVE|docker://IMAGE[:TAG] [--via auto|git|tarball] [--platform OS/ARCH] [analysis options]');
+        process.exit(1);
+    }
+    for (const flag of ['--changed-only', '--changed-since', '--rev', '--session', '--workspace', '--dashboard']) {
+        if (args.includes(flag)) {
+            console.error(`❌ gen reads a fresh checkout and cannot be combined with ${flag}`);
+            process.exit(1);
+        }
+    }
+    const kind = ContainerImage.isImage(spec) ? 'image' : ArchiveSource.isArchive(spec) ? 'archive' : 'repository';
+    const via = getFlagValue(args, '--via') || 'auto';
+    if (!FETCH_METHODS.includes(via)) {
+        console.error(`❌ Invalid --via: ${via} (expected ${FETCH_METHODS.join(', ')})`);
+        process.exit(1);
+    }
+    const platform = getFlagValue(args, '--platform');
+    if (platform && !/^[a-z0-9]+\/[a-z0-9_]+(\/[a-z0-9]+)?$/i.test(platform)) {
+        console.error(`❌ Invalid --platform: ${platform} (expected OS/ARCH[/VARIANT], e.g. linux/arm64)`);
+        process.exit(1);
+    }
+    if (args.includes('--via') && kind !== 'repository') {
+        console.error(`❌ --via applies to repositories, not ${kind === 'image' ? 'images' : 'archives'}`);
+        process.exit(1);
+    }
+    if (platform && kind !== 'image') {
+        console.error('❌ --platform applies to container images (docker://IMAGE)');
+        process.exit(1);
+    }
+    let source;
+    try {
+        source = kind === 'image' ? ContainerImage.parse(spec)
+            : kind === 'archive' ? ArchiveSource.parse(spec)
+                : RemoteRepository.parse(spec);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    const analysisArgs = args.filter((arg, i) => i !== genIndex && i !== genIndex + 1 &&
+        !['--via', '--platform'].includes(arg) && !['--via', '--platform'].includes(args[i - 1]));
+    const options = parseArguments(analysisArgs);
+    useStdoutForContext(analysisArgs, options);
+
+    // Files named on the command line are the caller's, not the repository's
+    for (const key of ['templateFile', 'anonymize']) {
+        if (options[key]) options[key] = resolve(options.projectRoot, options[key]);
+    }
+    if (options.files && options.files !== '-') {
+        options.files = resolve(options.projectRoot, options.files);
+    }
+    if (!options.gitingest && !options.contextExport && !options.contextToClipboard && !options.structuredFormat && !options.templateFile) {
+        options.gitingest = true;
+    }
+
+    console.log(`${kind === 'repository' ? '🌐 Fetching' : kind === 'image' ? '🐳 Pulling' : '📂 Reading'} ${source.describe()}...`);
+    let checkout;
+    try {
+        checkout = await source.checkout(kind === 'image' ? { platform } : kind === 'repository' ? { method: via } : {});
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    if (kind === 'repository') {
+        console.log(`📦 ${checkout.method === 'git' ? 'Cloned' : 'Downloaded'} ${source.describe()}${checkout.commit ? ` at ${checkout.commit.slice(0, 7)}` : ''}`);
+    } else {
+        const { files, skipped, layers } = checkout.stats;
+        const from = kind === 'image' ? ` from ${layers} ${layers === 1 ? 'layer' : 'layers'} (${checkout.commit.slice(0, 19)})` : '';
+        const links = skipped > 0 ? `, ${skipped} links and special files skipped` : '';
+        console.log(`📦 Extracted ${source.describe()}: ${files.toLocaleString()} files${from}${links}`);
+    }
+    console.log();
+
+    options.outputRoot = options.projectRoot;
+    options.projectRoot = checkout.root;
+    try {
+        await prepareAnalysisOptions(options);
+        printStartupInfo(options);
+        await runAnalysis(options);
+    } finally {
+        rmSync(checkout.dir, { recursive: true, force: true });
+    }
+}
+
+/**
+ * Context of the chunks most relevant to a natural-language query (v3.4.0)
+ */
+async function runSemanticQuery(args) {
+    const queryIndex = args.indexOf('query');
+    const text = args[queryIndex + 1];
+    if (!text || text.startsWith('-')) {
+        console.error('❌ Usage: ctxman query "where is retry logic?" [--embeddings PROVIDER] [--max-tokens N]');
+        process.exit(1);
+    }
+
+    const options = parseArguments(args.filter((arg, i) => i !== queryIndex + 1));
+    useStdoutForContext(args, options);
+
+    console.log('🔎 Semantic Query');
+    console.log('═'.repeat(60));
+    console.log();
+
+    let provider;
+    try {
+        provider = EmbeddingProviderFactory.create(getEmbeddingProvider(args), {
+            model: getFlagValue(args, '--embedding-model'),
+            baseUrl: getFlagValue(args, '--embedding-url')
+        });
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    // Query context goes to a digest unless another export is requested
+    if (!options.contextExport && !options.contextToClipboard && !options.structuredFormat) {
+        options.gitingest = true;
+    }
+    options.query = { text, provider: provider.id };
+
+    await prepareAnalysisOptions(options);
+
+    // Earlier exports are outputs, not sources to search
+    const outputs = [...Object.values(DEFAULT_OUTPUTS), options.outputFile].filter(Boolean);
+    const scanner = new TokenAnalyzer(options.projectRoot, options);
+    const files = scanner.scanProject()
+        .map(filePath => ({ filePath, relativePath: scanner.relativePathOf(filePath) }))
+        .filter(({ relativePath }) => !outputs.includes(relativePath) && !/^context\.(json|yaml|md)$/.test(relativePath))
+        .map(({ filePath, relativePath }) => ({ relativePath, content: readFileSync(filePath, 'utf-8') }));
+
+    const index = new SemanticIndex(provider, {
+        root: options.projectRoot,
+        extractor: options.symbolExtractor,
+        cache: options.cache
+    });
+
+    console.log(`🧠 Indexing ${files.length} files with ${provider.id}...`);
+    try {
+        options.query.stats = await index.build(files);
+        options.query.chunks = await index.search(text);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    const top = getFlagValue(args, '--top');
+    options.query.limit = top ? parseInt(top, 10) || null : null;
+
+    printStartupInfo(options);
+
+    await runAnalysis(options);
+}
+
+/**
+ * Context of the definitions a snippet or stack trace mentions (v3.4.0)
+ * The snippet is read from stdin, or from FILE.
+ */
+async function runSnippetEnrichment(args) {
+    const enrichIndex = args.indexOf('enrich');
+    const source = args[enrichIndex + 1] && !args[enrichIndex + 1].startsWith('-') ? args[enrichIndex + 1] : null;
+    if (!source && process.stdin.isTTY) {
+        console.error('❌ Usage: ctxman enrich [FILE] [options] < snippet (a code snippet or stack trace on stdin)');
+        process.exit(1);
+    }
+
+    const options = parseArguments(args.filter((arg, i) => !(source && i === enrichIndex + 1)));
+    useStdoutForContext(args, options);
+    if (options.focus || options.symbols.length > 0) {
+        console.error('❌ enrich cannot be combined with --focus or --symbol');
+        process.exit(1);
+    }
+
+    let text;
+    try {
+        text = decodeText(readFileSync(source ? resolve(source) : 0));
+    } catch (error) {
+        console.error(`❌ Cannot read snippet: ${error.message}`);
+        process.exit(1);
+    }
+    if (!text.trim()) {
+        console.error('❌ The snippet is empty');
+        process.exit(1);
+    }
+
+    console.log('🧷 Snippet Enrichment');
+    console.log('═'.repeat(60));
+    console.log();
+
+    // Enriched context goes to a digest unless another export is requested
+    if (!options.contextExport && !options.contextToClipboard && !options.structuredFormat) {
+        options.gitingest = true;
+    }
+    options.snippet = { text, source: source || 'stdin' };
+    options.snippetResolver = new SnippetResolver();
+    // stdin held the snippet; there is nothing left to answer the export prompt
+    options.prompt = false;
+
+    await prepareAnalysisOptions(options);
+    printStartupInfo(options);
+    await runAnalysis(options);
+}
+
+/**
+ * Call graph and type dependency graph as Mermaid or DOT (v3.4.0)
+ * With --focus or --symbol, the graph shows what the selection included and why.
+ */
+async function runGraphExport(args) {
+    const format = getFlagValue(args, '--format') || 'mermaid';
+    if (!GRAPH_FORMATS.includes(format)) {
+        console.error(`❌ Invalid graph --format value: ${format} (expected ${GRAPH_FORMATS.join(' or ')})`);
+        process.exit(1);
+    }
+
+    const kind = getFlagValue(args, '--kind');
+    const kinds = kind ? [kind] : Object.values(GraphKind);
+    if (!kinds.every(k => Object.values(GraphKind).includes(k))) {
+        console.error(`❌ Invalid --kind value: ${kind} (expected ${Object.values(GraphKind).join(' or ')})`);
+        process.exit(1);
+    }
+
+    // --format names the graph format here, not structured output
+    const formatIndex = args.indexOf('--format');
+    const options = parseArguments(args.filter((arg, i) => formatIndex === -1 || (i !== formatIndex && i !== formatIndex + 1)));
+
+    // The graph goes to stdout unless --output-file is given; reports go to stderr
+    if (!options.outputFile) {
+        console.log = (...messages) => console.error(...messages);
+    }
+
+    await prepareAnalysisOptions(options);
+    if (!options.symbolExtractor) {
+        options.symbolExtractor = await new SymbolExtractor({ backend: options.symbolBackend }).initialize();
+    }
+
+    const calculator = new TokenAnalyzer(options.projectRoot, options);
+    const files = calculator.scanProject();
+    const results = options.jobs > 1 ? await calculator.analyzeFilesParallel(files) : calculator.analyzeFiles(files);
+    const { graph } = calculator.buildDependencyGraph(results);
+
+    let selection = null;
+    if (options.focus || options.symbols.length > 0) {
+        if (!calculator.selectExportResults(results)) {
+            process.exit(1);
+        }
+        selection = calculator.expansion
+            ? GraphExporter.selectionFromExpansion(calculator.expansion)
+            : GraphExporter.selectionFromSlice(calculator.selection, graph);
+    }
+
+    const model = new GraphExporter(graph, { kinds, selection, lsp: options.lsp }).build();
+    options.lsp?.close();
+    const text = format === 'dot' ? GraphExporter.toDot(model) : GraphExporter.toMermaid(model);
+
+    if (!options.outputFile) {
+        process.stdout.write(text);
+    } else {
+        writeFileAtomic(resolve(options.projectRoot, options.outputFile), text, getFileOptions(args));
+    }
+    console.log(`🕸️  Graph: ${model.nodes.length} nodes, ${model.edges.length} edges (${kinds.join(', ')}, ${format})` +
+        (options.outputFile ? ` saved to ${options.outputFile}` : ''));
+}
+
+/**
+ * List project symbols by kind, package and name, from memory or a SQLite index (v3.4.0)
+ */
+async function runSymbols(args) {
+    const kinds = getFlagValues(args, '--kind');
+    const invalid = kinds.find(kind => !Object.values(SymbolKind).includes(kind));
+    if (invalid) {
+        console.error(`❌ Invalid --kind value: ${invalid} (expected ${Object.values(SymbolKind).filter(kind => kind !== SymbolKind.MODULE).join(', ')})`);
+        process.exit(1);
+    }
+
+    const json = args.includes('--json');
+    const options = parseArguments(args);
+    if (options.rev || options.workspaceFile) {
+        console.error(`❌ symbols indexes the working tree; ${options.rev ? '--rev' : '--workspace'} is not supported`);
+        process.exit(1);
+    }
+
+    // JSON owns stdout; status output goes to stderr
+    if (json) {
+        console.log = (...messages) => console.error(...messages);
+    }
+
+    await prepareAnalysisOptions(options);
+    const extractor = options.symbolExtractor || await new SymbolExtractor({ backend: options.symbolBackend }).initialize();
+
+    const database = getSymbolDatabase(args);
+    let index;
+    try {
+        index = await SymbolIndex.open({ database: database && resolve(options.projectRoot, database) });
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    try {
+        const scanner = new TokenAnalyzer(options.projectRoot, options);
+        const files = scanner.scanProject().map(filePath => ({ path: filePath, relativePath: scanner.relativePathOf(filePath) }));
+        const refresh = index.refresh(files, extractor);
+        const { files: indexedFiles, symbols: indexedSymbols } = index.count();
+        console.log(`📇 Symbol index: ${indexedSymbols.toLocaleString()} symbols in ${indexedFiles.toLocaleString()} files` +
+            (database ? ` (${database}; ${refresh.indexed} re-indexed, ${refresh.unchanged} unchanged, ${refresh.removed} removed)` : ''));
+
+        const symbols = index.query({
+            kinds,
+            packages: getFlagValues(args, '--package'),
+            name: getFlagValue(args, '--name'),
+            exported: args.includes('--exported')
+        });
+
+        if (json) {
+            process.stdout.write(JSON.stringify(symbols, null, 2) + '\n');
+        } else {
+            console.log(SymbolIndex.formatSymbols(symbols));
+        }
+    } finally {
+        index.close();
+    }
+}
+
+/**
+ * Nested symbol outline of one file, without scanning its project (v3.4.0)
+ */
+async function runOutline(args) {
+    const file = args[args.indexOf('outline') + 1];
+    if (!file || file.startsWith('-')) {
+        console.error('❌ Usage: ctxman outline FILE [--format json]');
+        process.exit(1);
+    }
+    const format = getFlagValue(args, '--format') || 'text';
+    if (!['text', 'json'].includes(format)) {
+        console.error(`❌ Invalid outline --format value: ${format} (expected text or json)`);
+        process.exit(1);
+    }
+
+    let content;
+    try {
+        content = readFileSync(file, 'utf8');
+    } catch (error) {
+        console.error(`❌ Cannot read ${file}: ${error.message}`);
+        process.exit(1);
+    }
+
+    const extractor = await new SymbolExtractor({ backend: getSymbolBackend(args) }).initialize();
+    const relativeFile = relative(process.cwd(), resolve(file)).split(sep).join('/');
+    if (!extractor.supports(relativeFile, content)) {
+        console.error(`❌ No symbol extractor for ${file} (supported: ${extractor.getSupportedExtensions().join(', ')})`);
+        process.exit(1);
+    }
+
+    const outline = new SymbolOutline({ extractor }).outline(content, relativeFile);
+    if (format === 'json') {
+        process.stdout.write(JSON.stringify(outline, null, 2) + '\n');
+    } else {
+        console.log(SymbolOutline.formatText(outline));
+    }
+}
+
+/**
+ * Database of `symbols --db [FILE]`, or null for an in-memory index
+ */
+function getSymbolDatabase(args) {
+    const dbIndex = args.indexOf('--db');
+    if (dbIndex === -1) {
+        return null;
+    }
+
+    // The file is optional: --db [FILE]
+    const value = args[dbIndex + 1];
+    return value && !value.startsWith('-') && value !== 'symbols' ? value : SYMBOL_INDEX_FILE;
+}
+
+/**
+ * Completion script for a shell, or the dynamic values scripts ask for (v3.4.0)
+ * `completion --complete KIND PREFIX` prints one value per line and nothing on errors.
+ */
+async function runCompletion(args) {
+    const completeIndex = args.indexOf('--complete');
+    if (completeIndex !== -1) {
+        try {
+            const values = await ShellCompletion.values(args[completeIndex + 1], {
+                root: process.cwd(),
+                prefix: args[completeIndex + 2] || ''
+            });
+            if (values.length > 0) console.log(values.join('\n'));
+        } catch { }
+        return;
+    }
+
+    const shell = args[args.indexOf('completion') + 1];
+    if (!COMPLETION_SHELLS.includes(shell)) {
+        console.error(`❌ completion requires a shell: ${COMPLETION_SHELLS.join(', ')}`);
+        process.exit(1);
+    }
+
+    // Flags are read from the help text, so the script lists every documented flag
+    const help = [];
+    const log = console.log;
+    console.log = (...messages) => help.push(messages.join(' '));
+    try {
+        printHelp();
+    } finally {
+        console.log = log;
+    }
+
+    const completion = new ShellCompletion({
+        commands: DAEMON_LOCAL_COMMANDS,
+        flags: ShellCompletion.flagsOf(help.join('\n'))
+    });
+    process.stdout.write(completion.script(shell));
+}
+
+/**
+ * Values of a repeatable, comma-separated flag: --kind class,struct --kind interface
+ */
+function getFlagValues(args, flag) {
+    return args
+        .flatMap((arg, i) => (arg === flag && args[i + 1] && !args[i + 1].startsWith('--') ? args[i + 1].split(',') : []))
+        .map(value => value.trim())
+        .filter(Boolean);
+}
+
+/**
+ * Time the scan/analyze/parse/pack/format phases of this project (v3.4.0)
+ */
+async function runBenchmark(args) {
+    const iterations = getBenchCount(args, '--iterations', 1, 1);
+    const warmup = getBenchCount(args, '--warmup', 0, 0);
+    const options = parseArguments(args);
+
+    await prepareAnalysisOptions(options);
+    if (!options.symbolExtractor) {
+        options.symbolExtractor = await new SymbolExtractor({ backend: options.symbolBackend }).initialize();
+    }
+
+    let report;
+    try {
+        report = await new Benchmark({
+            iterations,
+            warmup,
+            cpuProfile: getFlagValue(args, '--cpu-profile'),
+            heapProfile: getFlagValue(args, '--heap-profile'),
+            allocations: args.includes('--allocations')
+        }).run(() => contextPipeline(new TokenAnalyzer(options.projectRoot, options)));
+    } catch (error) {
+        console.error(`❌ Benchmark failed: ${error.message}`);
+        process.exit(1);
+    } finally {
+        options.lsp?.close();
+    }
+
+    console.log(args.includes('--json') ? JSON.stringify(report, null, 2) : Benchmark.formatReport(report));
+}
+
+/**
+ * What the context holds at each of several budgets, exporting nothing (v3.4.0)
+ * Files are analyzed once and packed at every budget of --budgets.
+ */
+async function runBudgetSimulation(args) {
+    if (!args.includes('--budgets')) {
+        console.error('❌ Usage: ctxman simulate --budgets LIST [options] (e.g. --budgets 32k,128k,200k)');
+        process.exit(1);
+    }
+    if (args.includes('--max-tokens')) {
+        console.error('❌ simulate packs at every --budgets size and cannot be combined with --max-tokens');
+        process.exit(1);
+    }
+
+    let budgets;
+    try {
+        budgets = BudgetSimulator.parseBudgets(getFlagValue(args, '--budgets'));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    const options = parseArguments(args);
+    options.maxTokens = budgets[budgets.length - 1].maxTokens;
+    await prepareAnalysisOptions(options);
+    options.budgetSimulator = new BudgetSimulator({ budgets });
+
+    console.log(`📐 Simulating budgets: ${budgets.map(budget => budget.label).join(', ')}`);
+    console.log();
+
+    await runAnalysis(options);
+}
+
+/**
+ * Measure estimate correction factors against a real tokenizer (v3.4.0)
+ */
+async function runCalibration(args) {
+    const options = parseArguments(args);
+    const sampleSize = getBenchCount(args, '--sample', 200, 1);
+    const calibrator = new TokenCalibrator({ sampleSize });
+
+    let reference;
+    try {
+        reference = await TokenCalibrator.reference(options.tokenizer, options.targetModel);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    const scanner = new TokenAnalyzer(options.projectRoot, { profile: options.profile });
+    const files = scanner.scanProject().map(filePath => scanner.relativePathOf(filePath));
+    const samples = calibrator.sample(files).map(relativePath => ({
+        path: relativePath,
+        content: readFileSync(resolve(options.projectRoot, relativePath), 'utf8')
+    }));
+    if (samples.length === 0) {
+        console.error('❌ No text files to calibrate on');
+        process.exit(1);
+    }
+
+    const calibration = calibrator.calibrate(samples, reference);
+    TokenEstimator.saveCalibration(options.projectRoot, calibration);
+
+    if (args.includes('--json')) {
+        console.log(JSON.stringify(calibration, null, 2));
+        return;
+    }
+    console.log(TokenCalibrator.formatReport(calibration));
+    console.log(`💾 Correction factors saved to ${CALIBRATION_FILE}; estimates use them from now on`);
+}
+
+function getBenchCount(args, flag, fallback, min) {
+    if (!args.includes(flag)) {
+        return fallback;
+    }
+
+    const value = getFlagValue(args, flag);
+    const count = Number(value);
+    if (!Number.isInteger(count) || count < min) {
+        console.error(`❌ Invalid ${flag} value: ${value} (expected an integer of at least ${min})`);
+        process.exit(1);
+    }
+    return count;
+}
+
+/**
+ * Bounded cache of the symbol outlines long-running modes parse (--ast-cache MB, v3.4.0)
+ */
+function getParseCache(args) {
+    const megabytes = getBenchCount(args, '--ast-cache', DEFAULT_PARSE_CACHE_BYTES / (1024 * 1024), 0);
+    return new ParseCache({ maxBytes: megabytes * 1024 * 1024 });
+}
+
+/**
+ * Renamed tags of the XML document layout (--xml-tags, v3.4.0)
+ */
+function getXmlTags(args) {
+    if (!args.includes('--xml-tags')) return null;
+    try {
+        return parseXmlTags(getFlagValue(args, '--xml-tags') || '');
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+}
+
+/**
+ * Fence character of Markdown code blocks (--fence, v3.4.0)
+ */
+function getFenceChar(args) {
+    if (!args.includes('--fence')) return 'backtick';
+    try {
+        return parseFenceChar(getFlagValue(args, '--fence'));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+}
+
+/**
+ * Content mode of XML file contents (--xml-content, v3.4.0)
+ */
+function getXmlContent(args) {
+    if (!args.includes('--xml-content')) return 'raw';
+    try {
+        return parseXmlContentMode(getFlagValue(args, '--xml-content'));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+}
+
+function getEmbeddingProvider(args) {
+    const provider = getFlagValue(args, '--embeddings') || process.env.CTXMAN_EMBEDDINGS || 'transformers';
+    if (!EMBEDDING_PROVIDERS.includes(provider)) {
+        console.error(`❌ Invalid --embeddings value: ${provider} (expected ${EMBEDDING_PROVIDERS.join(', ')})`);
+        process.exit(1);
+    }
+    return provider;
+}
+
+/**
+ * fsync and backup of output files (--fsync, --backup, v3.4.0), as in writeFileAtomic()
+ */
+function getFileOptions(args) {
+    return { fsync: args.includes('--fsync'), backup: args.includes('--backup') };
+}
+
+function getFlagValue(args, flag) {
+    const flagIndex = args.findIndex(arg => arg === flag);
+    if (flagIndex !== -1 && args[flagIndex + 1]) {
+        return args[flagIndex + 1];
+    }
+    return null;
+}
+
+async function runWatchMode(args) {
+    const toStdout = args.includes('--stdout');
+    if (toStdout) {
+        // The context owns stdout; status output goes to stderr
+        console.log = (...messages) => console.error(...messages);
+    }
+
+    console.log('👁️  Starting watch mode...\n');
+
+    const projectRoot = process.cwd();
+    const debounce = args.includes('--debounce')
+        ? parseInt(args[args.indexOf('--debounce') + 1], 10) || 1000
+        : 1000;
+
+    // Live context regeneration (v3.4.0)
+    const options = parseArguments(args);
+    if (options.gitingest || options.contextExport || toStdout) {
+        await runContextWatch(args, options, { debounce, toStdout });
+        return;
+    }
+
+    const watcher = new FileWatcher(projectRoot, { debounce });
+    const analyzer = new IncrementalAnalyzer({ methodLevel: args.includes('-m') });
+
+    // Handle file changes
+    watcher.on('file:changed', async (event) => {
+        console.log(`\n📝 File ${event.type}: ${event.relativePath}`);
+        await analyzer.analyzeChange(event);
+    });
+
+    // Handle analysis complete
+    analyzer.on('analysis:complete', (event) => {
+        console.log(`   ✅ Analysis complete: ${event.analysis.tokens} tokens (${event.elapsed}ms)`);
+
+        const stats = analyzer.getStats();
+        console.log(`   📊 Total: ${stats.totalFiles} files, ${stats.totalTokens.toLocaleString()} tokens`);
+    });
+
+    // Handle file deletion
+    analyzer.on('file:deleted', (event) => {
+        console.log(`   🗑️  File deleted: ${event.file} (-${event.oldTokens} tokens)`);
+    });
+
+    // Start watching
+    watcher.start();
+
+    console.log('✅ Watch mode active');
+    console.log('   Press Ctrl+C to stop\n');
+
+    // Keep process alive
+    process.on('SIGINT', () => {
+        console.log('\n\n🛑 Stopping watch mode...');
+        watcher.stop();
+        process.exit(0);
+    });
+}
+
+// Modes the daemon leaves to the client: subcommands, prompts and info flags
+const DAEMON_LOCAL_COMMANDS = ['gen', 'convert', 'github', 'git', 'ask', 'compare', 'merge', 'pack', 'unpack', 'decrypt', 'deanonymize', 'reproduce', 'session', 'report', 'explain', 'lint', 'check', 'query', 'serve', 'editor', 'watch', 'graph', 'select', 'pr', 'diff', 'api-diff', 'daemon', 'bench', 'simulate', 'calibrate', 'symbols', 'outline', 'completion'];
+const DAEMON_LOCAL_FLAGS = ['--help', '-h', '--version', '--list-formats', '--list-llms', '--list-extractors',
+    '--dashboard', '--wizard', '--changed-only', '--changed-since', '--rev', '--files'];
+
+/**
+ * Daemon holding a warm index of this project, and its management commands (v3.4.0)
+ */
+async function runDaemon(args) {
+    const action = args[args.indexOf('daemon') + 1] || 'status';
+    const projectRoot = process.cwd();
+    const client = new DaemonClient({ root: projectRoot });
+
+    if (action === 'status') {
+        const status = await client.status();
+        if (!status) {
+            console.log(`⚪ No ctxman daemon for ${projectRoot} (start one with: ctxman daemon start)`);
+            return;
+        }
+        console.log(`⚡ ctxman daemon for ${status.root}`);
+        console.log(`   PID: ${status.pid}, up ${Math.round(status.uptimeMs / 1000)}s, ${status.requests} requests`);
+        console.log(`   Socket: ${status.socket}`);
+        console.log(`   Index: ${status.files.toLocaleString()} files, ${status.analysisHits.toLocaleString()} reused analyses, ${status.scanHits} reused scans`);
+        if (status.parseCache) {
+            console.log(`   ${ParseCache.describe(status.parseCache)}`);
+        }
+        if (status.metrics) {
+            console.log(`   Metrics: ${status.metrics}`);
+        }
+        return;
+    }
+
+    if (action === 'stop') {
+        console.log(await client.stop()
+            ? `🛑 Stopped ctxman daemon for ${projectRoot}`
+            : `⚪ No ctxman daemon for ${projectRoot}`);
+        return;
+    }
+
+    if (action === 'start') {
+        if (await client.status()) {
+            console.log(`⚡ A ctxman daemon already serves ${projectRoot} (ctxman daemon status)`);
+            return;
+        }
+
+        const logDir = join(projectRoot, '.ctxman', 'logs');
+        mkdirSync(logDir, { recursive: true });
+        const logFile = join(logDir, 'daemon.log');
+        const log = openSync(logFile, 'a');
+        const runArgs = args.includes('--ast-cache') ? ['--ast-cache', getFlagValue(args, '--ast-cache')] : [];
+        const metricsValue = getFlagValue(args, '--metrics');
+        if (args.includes('--metrics')) {
+            runArgs.push('--metrics', ...(metricsValue && !metricsValue.startsWith('-') ? [metricsValue] : []));
+        }
+        const child = spawn(process.execPath, [__filename, 'daemon', 'run', ...runArgs], {
+            cwd: projectRoot,
+            detached: true,
+            stdio: ['ignore', log, log]
+        });
+        child.unref();
+
+        let exited = false;
+        child.once('exit', () => { exited = true; });
+        // The daemon listens once its index is warm
+        const deadline = Date.now() + 300000;
+        while (!exited && Date.now() < deadline) {
+            const status = await client.status();
+            if (status) {
+                console.log(`⚡ ctxman daemon started for ${projectRoot} (PID ${status.pid})`);
+                console.log('   Generate context with: ctxman-client --cli [options]');
+                return;
+            }
+            await new Promise(resolveDelay => setTimeout(resolveDelay, 100));
+        }
+        console.error(`❌ ctxman daemon did not start; see ${relative(projectRoot, logFile)}`);
+        process.exit(1);
+    }
+
+    if (action !== 'run') {
+        console.error(`❌ Unknown daemon command: ${action} (expected start, run, status or stop)`);
+        process.exit(1);
+    }
+
+    const index = new WarmIndex({ root: projectRoot, parseCache: getParseCache(args) });
+    const metricsAddress = getMetricsAddress(args);
+    const metrics = metricsAddress ? new ContextMetrics({ mode: 'daemon', version: pkg.version }) : null;
+    let metricsUrl = null;
+    const daemon = new ContextDaemon({
+        root: projectRoot,
+        handler: requestArgs => runDaemonRequest(requestArgs, index, metrics),
+        status: () => ({ ...index.getStats(), metrics: metricsUrl }),
+        // Requests the client runs itself count as local
+        onRequest: metrics && (({ exit }) => metrics.recordRequest('context', exit === null ? 'local' : exit === 0 ? 'ok' : 'error'))
+    });
+
+    // Scan and analyze once before clients can connect
+    const started = Date.now();
+    await daemon.run(['--cli'], () => {});
+    try {
+        await daemon.start();
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    if (metrics) {
+        metrics.watchCache(index.cache);
+        metricsUrl = await startMetrics(metrics, metricsAddress);
+    }
+    console.log(`⚡ ctxman daemon serving ${projectRoot}`);
+    console.log(`   Socket: ${daemon.socketPath}`);
+    console.log(`   Index warm: ${index.getStats().files.toLocaleString()} files in ${Date.now() - started}ms`);
+    console.log('   Stop with: ctxman daemon stop\n');
+
+    const shutdown = () => daemon.stop();
+    process.on('SIGINT', shutdown);
+    process.on('SIGTERM', shutdown);
+    await daemon.closed();
+    console.log('🛑 ctxman daemon stopped');
+    process.exit(0);
+}
+
+/**
+ * One context generation inside the daemon; same steps as CLI mode in main()
+ * @returns {Promise<boolean>} false when the client has to run args itself
+ */
+async function runDaemonRequest(args, index, metrics = null) {
+    const cliMode = args.includes('--cli') || args.some(arg => arg.startsWith('-'));
+    if (!cliMode || args.some(arg => DAEMON_LOCAL_COMMANDS.includes(arg) || DAEMON_LOCAL_FLAGS.includes(arg))) {
+        return false;
+    }
+
+    const options = parseArguments(args);
+    options.index = index;
+    options.prompt = false;
+    options.metrics = metrics;
+
+    useStdoutForContext(args, options);
+    await prepareAnalysisOptions(options);
+    printStartupInfo(options);
+
+    // Token counts and symbol outlines outlive the request
+    options.cache = options.cache || index.cache;
+    await runAnalysis(options);
+    return true;
+}
+
+/**
+ * Watch mode that keeps a digest or LLM context up to date (v3.4.0)
+ */
+async function runContextWatch(args, options, { debounce, toStdout }) {
+    await prepareAnalysisOptions(options);
+
+    const format = options.contextExport ? 'context' : 'gitingest';
+    const output = toStdout ? null : options.outputFile || DEFAULT_OUTPUTS[format];
+
+    const regenerator = new ContextRegenerator(options.projectRoot, {
+        format,
+        output,
+        debounce: Math.min(debounce, 200),
+        analysis: options,
+        parseCache: getParseCache(args)
+    });
+    const watcher = new FileWatcher(options.projectRoot, {
+        debounce,
+        ignorePatterns: ['.ctxman', ...(output ? [output, `${output}.tmp`] : [])]
+    });
+
+    regenerator.on('context:regenerated', summary => {
+        console.log(ContextRegenerator.formatSummary(summary));
+    });
+    regenerator.on('context:error', ({ error }) => {
+        console.error(`❌ Context regeneration failed: ${error.message}`);
+    });
+    watcher.on('file:changed', event => regenerator.queue(event));
+
+    regenerator.regenerate();
+    watcher.start();
+
+    console.log(`✅ Watching for changes (${format} → ${output || 'stdout'})`);
+    console.log('   Press Ctrl+C to stop\n');
+
+    process.on('SIGINT', () => {
+        console.log('\n\n🛑 Stopping watch mode...');
+        regenerator.stop();
+        watcher.stop();
+        process.exit(0);
+    });
+}
+
+async function runWizard() {
+    try {
+        // Dynamic imports for ESM modules
+        const ReactModule = await import('react');
+        const React = ReactModule.default || ReactModule;
+        const { render } = await import('ink');
+        const Wizard = (await import('../lib/ui/wizard.js')).default;
+
+        // Clear screen for clean wizard display
+        console.clear();
+        console.log('🧙 Starting interactive wizard...\n');
+
+        const instance = render(
+            React.createElement(Wizard, {
+                onComplete: (answers) => {
+                    instance.unmount();
+
+                    console.log('\n✨ Wizard complete! Running analysis with your configuration...\n');
+
+                    // Run analyzer with wizard configuration
+                    const options = {
+                        outputFormat: answers.outputFormat,
+                        useCase: answers.useCase,
+                        targetModel: answers.targetModel,
+                        projectRoot: process.cwd(),
+                        simple: true,      // No export menu
+                        contextExport: true // Auto-export to file
+                    };
+
+                    const analyzer = new TokenAnalyzer(options.projectRoot, options);
+                    analyzer.run();
+
+                    console.log('\n✅ Analysis complete! Context exported to llm-context.json\n');
+                }
+            })
+        );
+    } catch (error) {
+        throw error; // Re-throw to be caught by main()
+    }
+}
+
+/**
+ * Pick files and symbols in a terminal UI, then generate their context (v3.4.0)
+ */
+async function runContextSelector(args) {
+    const options = parseArguments(args);
+    if (options.focus || options.symbols.length > 0) {
+        console.error('❌ select cannot be combined with --focus or --symbol');
+        process.exit(1);
+    }
+    if (!process.stdin.isTTY) {
+        console.error('❌ select needs an interactive terminal');
+        process.exit(1);
+    }
+
+    // The selection goes to a digest unless another export is requested
+    if (!options.contextExport && !options.contextToClipboard && !options.structuredFormat) {
+        options.gitingest = true;
+    }
+    await prepareAnalysisOptions(options);
+
+    let extractor;
+    try {
+        extractor = options.symbolExtractor || await new SymbolExtractor({ backend: options.symbolBackend }).initialize();
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    const ReactModule = await import('react');
+    const React = ReactModule.default || ReactModule;
+    const { render } = await import('ink');
+    const ContextSelector = (await import('../lib/ui/context-selector.js')).default;
+
+    console.log('🗂️  Analyzing project for selection...\n');
+    const calculator = new TokenAnalyzer(options.projectRoot, { ...options, dashboard: true });
+    const originalLog = console.log;
+    console.log = () => {};
+    let files;
+    try {
+        files = calculator.analyzeFiles(calculator.scanProject());
+    } finally {
+        console.log = originalLog;
+    }
+
+    const loadSymbols = relativePath => {
+        if (!extractor.supports(relativePath)) return [];
+        const content = readFileSync(calculator.absolutePathOf(relativePath), 'utf8');
+        const lines = content.split('\n');
+        const packageId = extractor.getPlugin(relativePath).getPackageId(relativePath);
+
+        return extractor.extract(content, relativePath)
+            .filter(symbol => symbol.kind !== SymbolKind.MODULE)
+            .map(symbol => ({
+                id: symbolId(symbol, packageId),
+                name: symbol.qualifiedName,
+                kind: symbol.kind,
+                tokens: calculator.calculateTokens(lines.slice(symbol.startLine - 1, symbol.endLine).join('\n'), relativePath)
+            }));
+    };
+
+    const budget = options.tokenBudget?.options.maxTokens ??
+        (options.targetModel ? LLMDetector.getProfile(options.targetModel).contextWindow : null);
+    const tree = new SelectionTree(files, { budget, loadSymbols });
+
+    console.clear();
+    const selection = await new Promise(done => {
+        const instance = render(React.createElement(ContextSelector, {
+            tree,
+            height: Math.max(5, (process.stdout.rows || 30) - 9),
+            onSubmit: picked => {
+                instance.unmount();
+                done(picked);
+            },
+            onCancel: () => {
+                instance.unmount();
+                done(null);
+            }
+        }));
+    });
+
+    if (!selection) {
+        console.log('👋 Selection cancelled');
+        return;
+    }
+    if (selection.files.length === 0 && selection.symbols.length === 0) {
+        console.log('⚠️  Nothing selected');
+        return;
+    }
+
+    // The picked selection replaces profile symbols
+    options.pick = selection;
+    options.symbols = [];
+    if (selection.symbols.length > 0) {
+        options.symbolExtractor = extractor;
+    }
+
+    printStartupInfo(options);
+    await runAnalysis(options);
+}
+
+/**
+ * Context of the symbols a selection expression matches (v3.4.0)
+ * `select EXPR --list` only prints the matches.
+ */
+async function runSelectionQuery(args, expression) {
+    let query;
+    try {
+        query = SelectionQuery.parse(expression);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        if (error.column) {
+            console.error(`   ${expression}\n   ${' '.repeat(error.column - 1)}^`);
+        }
+        process.exit(1);
+    }
+
+    const options = parseArguments(args.filter(arg => arg !== expression));
+    if (options.focus || options.symbols.length > 0) {
+        console.error('❌ select cannot be combined with --focus or --symbol');
+        process.exit(1);
+    }
+    if (options.rev || options.workspaceFile) {
+        console.error(`❌ select indexes the working tree; ${options.rev ? '--rev' : '--workspace'} is not supported`);
+        process.exit(1);
+    }
+    const list = args.includes('--list');
+    if (!list && !options.contextExport && !options.contextToClipboard && !options.structuredFormat) {
+        // The selection goes to a digest unless another export is requested
+        options.gitingest = true;
+    }
+    await prepareAnalysisOptions(options);
+
+    let extractor;
+    let index;
+    try {
+        extractor = options.symbolExtractor || await new SymbolExtractor({ backend: options.symbolBackend }).initialize();
+        const database = getSymbolDatabase(args);
+        index = await SymbolIndex.open({ database: database && resolve(options.projectRoot, database) });
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    let matched;
+    try {
+        const calculator = new TokenAnalyzer(options.projectRoot, { ...options, dashboard: true });
+        const files = calculator.scanProject().map(filePath => ({ path: filePath, relativePath: calculator.relativePathOf(filePath) }));
+        index.refresh(files, extractor);
+
+        // Tokens of the symbol's own lines, read once per file
+        const contents = new Map();
+        const tokensOf = symbol => {
+            if (!contents.has(symbol.file)) {
+                contents.set(symbol.file, calculator.readFile(calculator.absolutePathOf(symbol.file)).split('\n'));
+            }
+            return calculator.calculateTokens(contents.get(symbol.file).slice(symbol.startLine - 1, symbol.endLine).join('\n'), symbol.file);
+        };
+        matched = query.select(index.query({}), { tokensOf });
+    } finally {
+        index.close();
+    }
+
+    if (list) {
+        console.log(SymbolIndex.formatSymbols(matched));
+        return;
+    }
+    if (matched.length === 0) {
+        console.log(`⚠️  No symbols match ${expression}`);
+        return;
+    }
+
+    const fileCount = new Set(matched.map(symbol => symbol.file)).size;
+    console.log(`🔎 Selected ${matched.length} ${matched.length === 1 ? 'symbol' : 'symbols'} in ${fileCount} ${fileCount === 1 ? 'file' : 'files'}`);
+    options.pick = { files: [], symbols: matched.map(symbol => symbol.id) };
+    options.symbolExtractor = extractor;
+
+    printStartupInfo(options);
+    await runAnalysis(options);
+}
+
+async function runDashboard() {
+    try {
+        // Dynamic imports for ESM modules
+        const ReactModule = await import('react');
+        const React = ReactModule.default || ReactModule;
+        const { render } = await import('ink');
+        const Dashboard = (await import('../lib/ui/dashboard.js')).default;
+
+        // Clear console and show loading message
+        console.clear();
+        console.log('📊 Analyzing project for dashboard...\n');
+
+        // Run analyzer silently (no console output)
+        const originalLog = console.log;
+        const logs = [];
+        console.log = (...args) => logs.push(args); // Capture logs
+
+        const analyzer = new TokenAnalyzer(process.cwd(), {
+            simple: true,
+            verbose: false,
+            dashboard: true // Skip export handling
+        });
+        const stats = analyzer.run();
+
+        console.log = originalLog; // Restore console.log
+
+        // Clear and render dashboard
+        console.clear();
+
+        const instance = render(
+            React.createElement(Dashboard, {
+                stats,
+                topFiles: stats.largestFiles || [],
+                status: 'complete',
+                onExit: () => {
+                    instance.unmount();
+                    process.exit(0);
+                }
+            })
+        );
+    } catch (error) {
+        throw error; // Re-throw to be caught by main()
+    }
+}
+
+function runContextComparison(args) {
+    const compareIndex = args.indexOf('compare');
+    const [oldFile, newFile] = args.slice(compareIndex + 1).filter(arg => !arg.startsWith('-'));
+
+    if (!oldFile || !newFile) {
+        console.error('❌ Usage: ctxman compare old.json new.json [--json] (or two .ctxpack files)');
+        process.exit(1);
+    }
+
+    let comparison;
+    try {
+        comparison = ContextComparer.compare(ContextComparer.load(oldFile), ContextComparer.load(newFile));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    if (args.includes('--json')) {
+        console.log(JSON.stringify(comparison, null, 2));
+        return;
+    }
+    console.log(ContextComparer.formatComparison(comparison));
+}
+
+/**
+ * Combine structured contexts of several runs or projects into one (v3.4.0)
+ * Files are re-packed into --max-tokens when given, and rendered in
+ * --format (default json) or as a GitIngest digest.
+ */
+async function runContextMerge(args) {
+    const mergeIndex = args.indexOf('merge');
+    const valueFlags = ['--max-tokens', '--tokenizer', '--tiers', '--format', '--output-file', '--xml-tags', '--fence', '--xml-content', '--model', '--reserve', '--reserve-prompt', '--reserve-output'];
+    const inputs = args.slice(mergeIndex + 1).filter((arg, i, rest) => !arg.startsWith('-') && !valueFlags.includes(rest[i - 1]));
+    if (inputs.length < 2) {
+        console.error('❌ Usage: ctxman merge a.json b.json [more...] [--dedupe] [--max-tokens N] [--format FORMAT | --gitingest]');
+        process.exit(1);
+    }
+
+    const options = parseArguments(args);
+    const format = options.gitingest ? 'gitingest' : options.structuredFormat || 'json';
+    // Any format of the merge goes to stdout with --stdout, the digest included
+    if (args.includes('--stdout')) {
+        options.outputStream = process.stdout;
+    }
+    useStdoutForContext(args, options);
+
+    let contexts;
+    let result;
+    try {
+        contexts = inputs.map(file => ContextMerger.load(file));
+        result = new ContextMerger({ dedupe: args.includes('--dedupe') }).merge(contexts);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    console.log(ContextMerger.formatReport(result, contexts));
+
+    let budget = null;
+    if (options.maxTokens !== null) {
+        try {
+            budget = await TokenBudget.create({
+                maxTokens: options.maxTokens,
+                reserve: options.reserve || 0,
+                reservePrompt: options.reservePrompt || 0,
+                tokenizer: options.tokenizer,
+                model: options.targetModel,
+                tiers: options.tiers
+            });
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    }
+    const { fileInfos, read, plan } = ContextMerger.toFileInfos(result.files, { budget });
+    if (plan) {
+        console.log(TokenBudget.formatPlan(plan));
+    }
+
+    // The merged project stands in for a project root: only its name is shown
+    const projectRoot = resolve(result.project);
+    const stats = { totalFiles: fileInfos.length, totalTokens: fileInfos.reduce((sum, fileInfo) => sum + fileInfo.tokens, 0) };
+    const pieces = format === 'gitingest'
+        ? new GitIngestFormatter(projectRoot, stats, fileInfos, { readFile: read }).generatePieces()
+        : new StructuredFormatter(projectRoot, stats, fileInfos, {
+            readFile: read,
+            countTokens: (text, filePath) => (budget ? budget.count(text, filePath) : TokenUtils.calculate(text, filePath)),
+            budget: plan,
+            collapsible: options.collapsible,
+            xmlTags: options.xmlTags,
+            fence: options.fence,
+            xmlContent: options.xmlContent
+        }).encodePieces(format);
+
+    if (options.outputStream) {
+        ContextWriter.toStream(options.outputStream).writeAll(pieces).end();
+        return;
+    }
+    const outputFile = options.outputFile || `merged-${format === 'gitingest' ? 'digest.txt' : StructuredFormatter.defaultFile(format)}`;
+    const length = ContextWriter.toFile(outputFile, getFileOptions(args)).writeAll(pieces).end();
+    console.log(`💾 Merged context saved to: ${outputFile}`);
+    console.log(`📊 Size: ${(length / 1024).toFixed(1)} KB, ${stats.totalTokens.toLocaleString()} tokens`);
+}
+
+/**
+ * Check a generated context for truncated symbols, missing definitions,
+ * duplicates and budget overruns; exits 1 on errors for CI (v3.4.0)
+ */
+async function runContextLint(args) {
+    const lintIndex = args.indexOf('lint');
+    const next = args[lintIndex + 1];
+    const contextFile = next && !next.startsWith('-') ? next : null;
+    const analysisArgs = args.filter((arg, index) => index !== lintIndex && !(contextFile && index === lintIndex + 1));
+
+    // Presets name their model here; budgets are checked per model, not set by it
+    const models = [];
+    try {
+        models.push(...getFlagValues(args, '--model').map(model => ModelPresets.resolve(model).model));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    const targetModel = getTargetModel(analysisArgs);
+    if (models.length === 0 && targetModel) {
+        models.push(targetModel);
+    }
+    const profiles = LLMDetector.getAllProfiles();
+    const unknown = models.find(model => !profiles[model]);
+    if (unknown) {
+        console.error(`❌ Unknown --model: ${unknown} (see ctxman --list-llms)`);
+        process.exit(1);
+    }
+
+    let context;
+    try {
+        context = contextFile
+            ? ContextLinter.load(contextFile)
+            : ContextLinter.parse(await generateLintContext(analysisArgs.filter((arg, index) => arg !== '--strict' && arg !== '--json' &&
+                arg !== '--model' && analysisArgs[index - 1] !== '--model')), 'generated context');
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    const root = process.cwd();
+    const linter = new ContextLinter({
+        models,
+        maxTokens: getMaxTokens(args),
+        tokenizer: getTokenizer(args),
+        root,
+        listFiles: projectRoot => new TokenAnalyzer(projectRoot, { verbose: false }).scanProject()
+    });
+    const report = await linter.lint(context);
+
+    console.log(args.includes('--json') ? JSON.stringify(report, null, 2) : ContextLinter.formatReport(report));
+    if (report.errors > 0 || (args.includes('--strict') && report.warnings > 0)) {
+        process.exit(1);
+    }
+}
+
+/**
+ * Run an analysis into a temporary directory and return its digest
+ * @param {Array<string>} args - Analysis options
+ * @returns {Promise<string>}
+ */
+async function generateLintContext(args) {
+    const options = parseArguments(args);
+    for (const flag of ['--out', '--print-path', '--stdout', '--chunk', '--template', '--context-clipboard', '--dashboard', '--session', '--encrypt']) {
+        if (args.includes(flag)) {
+            throw new Error(`lint checks the context it generates and cannot be combined with ${flag}; lint a saved context instead`);
+        }
+    }
+    options.gitingest = true;
+    options.contextExport = false;
+    options.structuredFormat = null;
+
+    await prepareAnalysisOptions(options);
+    printStartupInfo(options);
+    const target = new OutputTarget({ target: 'tmpfile', root: options.projectRoot });
+    options.outputTarget = target;
+
+    const calculator = new TokenAnalyzer(options.projectRoot, options);
+    try {
+        await (options.jobs > 1 && !options.index ? calculator.runParallel() : calculator.run());
+    } finally {
+        options.lsp?.close();
+    }
+    if (!calculator.exportResults || target.written.length === 0) {
+        throw new Error('Nothing was exported to lint');
+    }
+
+    const digest = readFileSync(target.written[0], 'utf8');
+    rmSync(dirname(target.written[0]), { recursive: true, force: true });
+    return digest;
+}
+
+/**
+ * Regenerate a checked-in context and fail when the committed file differs,
+ * or refresh it with --write; for CI and pre-commit hooks (v3.4.0)
+ */
+async function runContextCheck(args) {
+    const checkIndex = args.indexOf('check');
+    const contextFile = getFlagValue(args, '--against');
+    if (!contextFile || contextFile.startsWith('-')) {
+        console.error('❌ Usage: ctxman check --against FILE [--write] [analysis options]');
+        process.exit(1);
+    }
+    for (const flag of ['--out', '--print-path', '--stdout', '--chunk', '--context-clipboard', '--dashboard', '--session', '--encrypt', '--manifest']) {
+        if (args.includes(flag)) {
+            console.error(`❌ check compares one context file and cannot be combined with ${flag}`);
+            process.exit(1);
+        }
+    }
+
+    const command = args.filter((arg, index) => index !== checkIndex && arg !== '--write' &&
+        arg !== '--against' && args[index - 1] !== '--against');
+    // Without a format, the file's extension names it
+    if (!['--gitingest', '--format', '--context-export', '--template'].some(flag => command.includes(flag))) {
+        const format = ContextFreshness.formatOf(contextFile);
+        command.push(...(format === 'gitingest' ? ['--gitingest'] : ['--format', format]));
+    }
+
+    const outputPath = resolve(process.cwd(), contextFile);
+    let run;
+    try {
+        // The checked-in context is not a source of itself
+        run = await generateContexts(command, new Date(), new Set([relative(process.cwd(), outputPath)]));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    if (run.contexts.length !== 1) {
+        console.error(`❌ check compares one context file, but the run wrote ${run.contexts.map(context => context.name).join(', ')}`);
+        process.exit(1);
+    }
+
+    const regenerated = run.contexts[0].content.toString('utf8');
+    let committed = null;
+    try {
+        committed = readFileSync(outputPath, 'utf8');
+    } catch (error) {
+        if (error.code !== 'ENOENT') throw error;
+    }
+    const result = ContextFreshness.compare(committed, regenerated);
+
+    if (args.includes('--write')) {
+        if (result.fresh) {
+            console.log(`\n✅ ${contextFile} is up to date`);
+            return;
+        }
+        mkdirSync(dirname(outputPath), { recursive: true });
+        writeFileAtomic(outputPath, regenerated, getFileOptions(args));
+        console.log(`\n💾 ${result.missing ? 'Created' : 'Refreshed'} ${contextFile} (${run.countTokens(regenerated).toLocaleString()} tokens)`);
+        return;
+    }
+
+    console.log(`\n${ContextFreshness.format(result, contextFile)}`);
+    if (!result.fresh) {
+        console.log(`💡 Refresh it with: ctxman ${[...args.filter(arg => arg !== '--write'), '--write'].join(' ')}`);
+        process.exit(1);
+    }
+}
+
+/**
+ * Generate context and bundle it with its manifest into a .ctxpack archive (v3.4.0)
+ */
+async function runPack(args) {
+    const packIndex = args.indexOf('pack');
+    const next = args[packIndex + 1];
+    const outputFile = next && !next.startsWith('-') ? next : null;
+    if (outputFile && !outputFile.endsWith(PACK_EXTENSION)) {
+        console.error(`❌ Context packs end in ${PACK_EXTENSION}: ${outputFile}`);
+        process.exit(1);
+    }
+    // Recorded for regeneration, without the archive name and recipients
+    const command = args.filter((arg, index) => index !== packIndex && !(outputFile && index === packIndex + 1) &&
+        arg !== '--encrypt' && args[index - 1] !== '--encrypt');
+    const recipients = getEncrypt(args);
+    if (args.includes('--anonymize')) {
+        console.error('❌ ctxman pack records the real source files and cannot be combined with --anonymize');
+        process.exit(1);
+    }
+
+    let pack;
+    let encryptor = null;
+    try {
+        encryptor = recipients.length > 0 ? ContextEncryptor.parse(recipients) : null;
+        encryptor?.check();
+        pack = await createContextPack(command);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+
+    let outputPath = resolve(process.cwd(), outputFile || `${pack.manifest.project.name}${PACK_EXTENSION}`);
+    let size;
+    if (encryptor) {
+        // The whole archive is encrypted; ctxman decrypt restores the .ctxpack
+        outputPath += encryptor.extension;
+        try {
+            const buffer = encryptor.encrypt(pack.toBuffer());
+            writeFileAtomic(outputPath, buffer, getFileOptions(args));
+            size = buffer.length;
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            process.exit(1);
+        }
+    } else {
+        size = pack.write(outputPath, getFileOptions(args));
     }
-
-    console.log();
-    console.log('Usage: ctxman --output <format>');
-    console.log('Example: ctxman --output toon --context-clipboard');
+    console.log(`\n📦 Context pack saved to: ${relative(process.cwd(), outputPath)}${encryptor ? ` (encrypted: ${encryptor.describe()})` : ''}`);
+    console.log(`📊 Pack size: ${(size / 1024).toFixed(1)} KB (${pack.manifest.contexts.map(context => context.file).join(', ')}; ${pack.manifest.files.length} files)`);
 }
 
-function getChangedSince(args) {
-    const sinceIndex = args.findIndex(arg => arg === '--changed-since');
-    if (sinceIndex !== -1 && args[sinceIndex + 1]) {
-        return args[sinceIndex + 1];
+/**
+ * Run an analysis into a temporary directory and pack what it exported
+ * @param {Array<string>} args - Analysis options, as recorded in the pack
+ * @param {Object} [run] - { now }: creation time of the pack being regenerated
+ * @returns {Promise<ContextPack>}
+ */
+async function createContextPack(args, { now } = {}) {
+    for (const flag of ['--out', '--print-path', '--stdout', '--chunk', '--template', '--context-clipboard', '--dashboard']) {
+        if (args.includes(flag)) {
+            throw new Error(`pack writes the context into the archive and cannot be combined with ${flag}`);
+        }
     }
-    return null;
+    if (args.includes('--session')) {
+        // Regenerating would need the session state of the packed turn
+        throw new Error('pack records a complete context and cannot be combined with --session');
+    }
+    return ContextPack.create(await generateContexts(args, now));
 }
 
-async function runAPIServer(args) {
-    const portIndex = args.findIndex(arg => arg === '--port');
-    const port = portIndex !== -1 && args[portIndex + 1]
-        ? parseInt(args[portIndex + 1], 10)
-        : 3000;
+/**
+ * Run an analysis into a temporary directory and read back what it exported
+ * @param {Array<string>} args - Analysis options, as recorded in a pack or manifest
+ * @param {Date} [now] - Time of the recorded run, for contexts that include the date
+ * @param {Set<string>} [excludePaths] - Project paths the recorded run did not analyze
+ * @returns {Promise<Object>} The run, as ContextManifest.create() describes it
+ */
+async function generateContexts(args, now = new Date(), excludePaths = null) {
+    const options = parseArguments(args);
+    options.excludePaths = excludePaths;
+    if (!options.gitingest && !options.contextExport && !options.structuredFormat && !options.templateFile) {
+        options.gitingest = true;
+    }
+    options.manifest = null;
+    options.now = now;
 
-    const authTokenIndex = args.findIndex(arg => arg === '--auth-token');
-    const authToken = authTokenIndex !== -1 && args[authTokenIndex + 1]
-        ? args[authTokenIndex + 1]
-        : null;
+    await prepareAnalysisOptions(options);
+    printStartupInfo(options);
+    // Replaces any recorded --out target
+    const target = new OutputTarget({ target: 'tmpfile', root: options.projectRoot, anonymizer: options.anonymizer });
+    options.outputTarget = target;
 
-    const server = new APIServer({ port, authToken });
+    const calculator = new TokenAnalyzer(options.projectRoot, options);
+    try {
+        await (options.jobs > 1 && !options.index ? calculator.runParallel() : calculator.run());
+    } finally {
+        options.lsp?.close();
+    }
+    if (!calculator.exportResults || target.written.length === 0) {
+        throw new Error('Nothing was exported');
+    }
 
-    // Handle shutdown
-    process.on('SIGINT', () => {
-        console.log('\n\n🛑 Shutting down server...');
-        server.stop();
-        process.exit(0);
-    });
+    const contexts = target.written.map(file => ({ name: basename(file), content: readFileSync(file) }));
+    rmSync(dirname(target.written[0]), { recursive: true, force: true });
+    return describeRun(args, options, calculator, contexts);
+}
 
-    server.start();
+/**
+ * A finished run, as ContextPack and ContextManifest record it
+ */
+function describeRun(command, options, calculator, contexts, outputs = []) {
+    return {
+        project: basename(options.projectRoot),
+        command,
+        contexts,
+        files: calculator.exportResults,
+        readOriginal: filePath => calculator.readOriginal(filePath),
+        countTokens: text => calculator.calculateTokens(text),
+        tokenizer: calculator.getTokenizerName(),
+        version: pkg.version,
+        git: getPackGitState(options, outputs),
+        now: options.now
+    };
 }
 
-async function runChangedFilesAnalysis(options) {
-    console.log('🔀 Git Integration - Analyzing Changed Files');
-    console.log('═'.repeat(60));
-    console.log();
+/**
+ * Commit, branch and uncommitted changes of the packed tree; null outside git
+ * @param {Object} options
+ * @param {Array<string>} [outputs] - Absolute paths of written contexts, not counted as changes
+ */
+function getPackGitState(options, outputs = []) {
+    if (options.revision) {
+        return { commit: options.revision.commit, branch: null, dirty: false };
+    }
+    const git = new GitClient(options.projectRoot);
+    if (!git.isGitRepo) {
+        return null;
+    }
+    try {
+        return {
+            commit: git.exec('rev-parse HEAD'),
+            branch: git.getCurrentBranch(),
+            dirty: git.exec('status --porcelain --untracked-files=all').split('\n')
+                .map(line => line.replace(/^\S{1,2}\s+/, ''))
+                .some(file => file && !outputs.includes(resolve(git.exec('rev-parse --show-toplevel'), file)))
+        };
+    } catch {
+        return null;
+    }
+}
 
-    const diffAnalyzer = new DiffAnalyzer(options.projectRoot);
-    const changes = diffAnalyzer.analyzeChanges(options.changedSince);
+/**
+ * Extract a context pack, check its sources, regenerate it (v3.4.0)
+ */
+async function runUnpack(args) {
+    const packFile = args[args.indexOf('unpack') + 1];
+    if (!packFile || packFile.startsWith('-')) {
+        console.error('❌ Usage: ctxman unpack FILE.ctxpack [--dir DIR] [--verify] [--regenerate]');
+        process.exit(1);
+    }
 
-    console.log(`📝 Found ${changes.totalChangedFiles} changed files`);
-    if (options.changedSince) {
-        console.log(`   Since: ${options.changedSince}`);
+    let pack;
+    try {
+        pack = ContextPack.read(packFile);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
     }
-    console.log(`   Impact: ${changes.impact.level.toUpperCase()} (score: ${changes.impact.score})`);
+    console.log(ContextPack.formatSummary(pack));
     console.log();
 
-    if (changes.totalChangedFiles === 0) {
-        console.log('✅ No changed files to analyze');
-        return;
+    if (args.includes('--verify') || args.includes('--regenerate')) {
+        printPackDrift(pack);
     }
+    // Extracted files would be analyzed by the regeneration, so they are written after it
+    const regenerated = args.includes('--regenerate') ? await regeneratePack(pack) : null;
 
-    // Analyze only changed files
-    const analyzer = new TokenAnalyzer(options.projectRoot, {
-        ...options,
-        fileFilter: (filePath) => changes.changedFiles.includes(filePath)
-    });
+    const dir = getFlagValue(args, '--dir') || basename(packFile, PACK_EXTENSION);
+    const written = pack.extract(resolve(process.cwd(), dir), getFileOptions(args));
+    console.log(`\n📂 Extracted ${written.length} files to ${dir}/`);
 
-    analyzer.run();
+    if (regenerated) {
+        const regeneratedFile = join(dir, `regenerated${PACK_EXTENSION}`);
+        regenerated.write(resolve(process.cwd(), regeneratedFile), getFileOptions(args));
+        console.log(`💡 See what changed with: ctxman compare ${packFile} ${regeneratedFile}`);
+        process.exit(1);
+    }
 }
 
-async function runWatchMode(args) {
-    console.log('👁️  Starting watch mode...\n');
-
-    const projectRoot = process.cwd();
-    const debounce = args.includes('--debounce')
-        ? parseInt(args[args.indexOf('--debounce') + 1], 10) || 1000
-        : 1000;
+/**
+ * Decrypt a context or pack written with --encrypt (v3.4.0)
+ */
+function runDecrypt(args) {
+    const decryptIndex = args.indexOf('decrypt');
+    const inputFile = args[decryptIndex + 1];
+    if (!inputFile || inputFile.startsWith('-')) {
+        console.error('❌ Usage: ctxman decrypt FILE [OUTPUT] [--identity KEY] [--stdout]');
+        process.exit(1);
+    }
+    const next = args[decryptIndex + 2];
+    const toStdout = args.includes('--stdout');
+    const outputFile = next && !next.startsWith('-') ? next : ContextEncryptor.plainPath(inputFile);
+    if (!outputFile && !toStdout) {
+        console.error(`❌ ${inputFile} has no .age, .gpg or .asc suffix; name the decrypted file: ctxman decrypt ${inputFile} OUTPUT`);
+        process.exit(1);
+    }
 
-    const watcher = new FileWatcher(projectRoot, { debounce });
-    const analyzer = new IncrementalAnalyzer({ methodLevel: args.includes('-m') });
+    let content;
+    try {
+        content = ContextEncryptor.decrypt(readFileSync(inputFile), { identity: getFlagValue(args, '--identity') });
+    } catch (error) {
+        console.error(`❌ Cannot decrypt ${inputFile}: ${error.message}`);
+        process.exit(1);
+    }
 
-    // Handle file changes
-    watcher.on('file:changed', async (event) => {
-        console.log(`\n📝 File ${event.type}: ${event.relativePath}`);
-        await analyzer.analyzeChange(event);
-    });
+    if (toStdout) {
+        process.stdout.write(content);
+        return;
+    }
+    writeFileAtomic(resolve(process.cwd(), outputFile), content, getFileOptions(args));
+    console.log(`🔓 Decrypted to: ${outputFile} (${(content.length / 1024).toFixed(1)} KB)`);
+}
 
-    // Handle analysis complete
-    analyzer.on('analysis:complete', (event) => {
-        console.log(`   ✅ Analysis complete: ${event.analysis.tokens} tokens (${event.elapsed}ms)`);
+/**
+ * Translate an answer written in placeholders back to the project's names (v3.4.0)
+ */
+function runDeanonymize(args) {
+    const commandIndex = args.indexOf('deanonymize');
+    const inputFile = args[commandIndex + 1];
+    if (!inputFile || inputFile.startsWith('-')) {
+        console.error('❌ Usage: ctxman deanonymize FILE [OUTPUT] [--map FILE]');
+        process.exit(1);
+    }
+    const next = args[commandIndex + 2];
+    const outputFile = next && !next.startsWith('-') ? next : null;
+    const mapFile = resolve(process.cwd(), getFlagValue(args, '--map') || ANONYMIZE_FILE);
+    if (!existsSync(mapFile)) {
+        console.error(`❌ No anonymization map at ${relative(process.cwd(), mapFile)} (written by --anonymize; pass it with --map)`);
+        process.exit(1);
+    }
 
-        const stats = analyzer.getStats();
-        console.log(`   📊 Total: ${stats.totalFiles} files, ${stats.totalTokens.toLocaleString()} tokens`);
-    });
+    let restored;
+    try {
+        restored = ContextAnonymizer.load(mapFile).restore(readFileSync(inputFile, 'utf8'));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
 
-    // Handle file deletion
-    analyzer.on('file:deleted', (event) => {
-        console.log(`   🗑️  File deleted: ${event.file} (-${event.oldTokens} tokens)`);
-    });
+    if (!outputFile) {
+        process.stdout.write(restored);
+        return;
+    }
+    writeFileAtomic(resolve(process.cwd(), outputFile), restored, getFileOptions(args));
+    console.log(`🔑 Restored names to: ${outputFile}`);
+}
 
-    // Start watching
-    watcher.start();
+/**
+ * Packed sources that changed in the working tree
+ */
+function printPackDrift(pack) {
+    const { changed, missing } = pack.drift(process.cwd());
+    if (changed.length === 0 && missing.length === 0) {
+        console.log(`✅ All ${pack.manifest.files.length} packed sources match the working tree`);
+        return;
+    }
+    console.log(`⚠️  ${changed.length + missing.length} packed sources differ from the working tree:`);
+    changed.slice(0, 20).forEach(file => console.log(`   ~ ${file}`));
+    missing.slice(0, 20).forEach(file => console.log(`   - ${file} (missing)`));
+    if (pack.manifest.project.commit) {
+        console.log(`💡 The pack was made at ${pack.manifest.project.commit.slice(0, 12)}${pack.manifest.project.dirty ? ' with uncommitted changes' : ''}`);
+    }
+}
 
-    console.log('✅ Watch mode active');
-    console.log('   Press Ctrl+C to stop\n');
+/**
+ * Rerun the packed command and compare its contexts with the pack
+ * @returns {Promise<ContextPack|null>} The regenerated pack when a context differs
+ */
+async function regeneratePack(pack) {
+    console.log(`\n🔁 Regenerating: ${pack.regenerateCommand()}`);
+    let fresh;
+    try {
+        fresh = await createContextPack(pack.manifest.command, { now: new Date(pack.manifest.createdAt) });
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
 
-    // Keep process alive
-    process.on('SIGINT', () => {
-        console.log('\n\n🛑 Stopping watch mode...');
-        watcher.stop();
-        process.exit(0);
-    });
+    let reproduced = true;
+    for (const context of pack.manifest.contexts) {
+        const regenerated = fresh.manifest.contexts.find(entry => entry.file === context.file);
+        if (regenerated?.sha256 === context.sha256) {
+            console.log(`✅ ${context.file} reproduced (sha256:${context.sha256.slice(0, 12)})`);
+            continue;
+        }
+        reproduced = false;
+        console.log(regenerated
+            ? `❌ ${context.file} differs: ${context.tokens.toLocaleString()} → ${regenerated.tokens.toLocaleString()} tokens`
+            : `❌ ${context.file} was not regenerated`);
+    }
+    return reproduced ? null : fresh;
 }
 
-async function runWizard() {
+/**
+ * Regenerate the contexts a manifest describes and check them against it (v3.4.0)
+ */
+async function runReproduce(args) {
+    const manifestFile = args[args.indexOf('reproduce') + 1];
+    if (!manifestFile || manifestFile.startsWith('-')) {
+        console.error(`❌ Usage: ctxman reproduce FILE${MANIFEST_SUFFIX} [--dir DIR] [--verify]`);
+        process.exit(1);
+    }
+
+    let manifest;
     try {
-        // Dynamic imports for ESM modules
-        const ReactModule = await import('react');
-        const React = ReactModule.default || ReactModule;
-        const { render } = await import('ink');
-        const Wizard = (await import('../lib/ui/wizard.js')).default;
+        manifest = ContextManifest.read(manifestFile);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    console.log(ContextManifest.formatSummary(manifest));
+    console.log();
 
-        // Clear screen for clean wizard display
-        console.clear();
-        console.log('🧙 Starting interactive wizard...\n');
+    // Changed sources are read from the recorded commit when it was clean
+    const command = [...manifest.command];
+    if (!command.includes('--rev')) {
+        const { changed, missing } = ContextManifest.drift(manifest, process.cwd());
+        const { commit, dirty } = manifest.project;
+        if (changed.length === 0 && missing.length === 0) {
+            console.log(`✅ All ${manifest.files.length} recorded sources match the working tree`);
+        } else if (commit && !dirty) {
+            console.log(`⚠️  ${changed.length + missing.length} recorded sources changed; reading them from ${commit.slice(0, 12)}`);
+            command.push('--rev', commit);
+        } else {
+            console.log(`⚠️  ${changed.length + missing.length} recorded sources differ from the working tree:`);
+            changed.slice(0, 20).forEach(file => console.log(`   ~ ${file}`));
+            missing.slice(0, 20).forEach(file => console.log(`   - ${file} (missing)`));
+            if (dirty) {
+                console.log('💡 The manifest was made with uncommitted changes, so no commit has these sources');
+            }
+        }
+    }
 
-        const instance = render(
-            React.createElement(Wizard, {
-                onComplete: (answers) => {
-                    instance.unmount();
+    // Contexts and sidecars written since, unless the recorded run analyzed them
+    const recorded = new Set(manifest.files.map(file => file.path));
+    const outputs = manifest.contexts
+        .flatMap(({ file }) => [file, `${file}${MANIFEST_SUFFIX}`])
+        .map(file => relative(process.cwd(), resolve(dirname(manifestFile), basename(file))))
+        .filter(file => !recorded.has(file.split(sep).join('/')));
 
-                    console.log('\n✨ Wizard complete! Running analysis with your configuration...\n');
+    console.log(`\n🔁 Reproducing: ${ContextManifest.command({ command })}`);
+    let run;
+    try {
+        run = await generateContexts(command, new Date(manifest.createdAt), new Set(outputs));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
 
-                    // Run analyzer with wizard configuration
-                    const options = {
-                        outputFormat: answers.outputFormat,
-                        useCase: answers.useCase,
-                        targetModel: answers.targetModel,
-                        projectRoot: process.cwd(),
-                        simple: true,      // No export menu
-                        contextExport: true // Auto-export to file
-                    };
+    const contents = new Map(run.contexts.map(context => [context.name, context.content]));
+    const results = ContextManifest.compare(manifest, contents);
+    for (const { file, status, tokens } of results) {
+        if (status === 'identical') {
+            console.log(`✅ ${file} reproduced`);
+        } else if (status === 'differs') {
+            console.log(`❌ ${file} differs: ${tokens.toLocaleString()} → ${run.countTokens(contents.get(file).toString('utf8')).toLocaleString()} tokens`);
+        } else {
+            console.log(`❌ ${file} was not regenerated`);
+        }
+    }
 
-                    const analyzer = new TokenAnalyzer(options.projectRoot, options);
-                    analyzer.run();
+    if (!args.includes('--verify')) {
+        const dir = resolve(process.cwd(), getFlagValue(args, '--dir') || dirname(manifestFile));
+        mkdirSync(dir, { recursive: true });
+        const written = results.filter(result => contents.has(result.file)).map(({ file }) => {
+            // Names come from the manifest; keep them inside dir
+            const outputPath = join(dir, basename(file));
+            writeFileAtomic(outputPath, contents.get(file), getFileOptions(args));
+            return relative(process.cwd(), outputPath);
+        });
+        console.log(`\n💾 Reproduced contexts saved to: ${written.join(', ')}`);
+    }
+    if (results.some(result => result.status !== 'identical')) {
+        process.exit(1);
+    }
+}
 
-                    console.log('\n✅ Analysis complete! Context exported to llm-context.json\n');
-                }
-            })
-        );
+/**
+ * List conversation sessions, show or reset one (v3.4.0)
+ */
+function runSession(args) {
+    const { projectRoot } = parseArguments(args);
+    const name = args[args.indexOf('session') + 1];
+
+    if (!name || name.startsWith('-')) {
+        const sessions = ContextSession.list(projectRoot);
+        if (sessions.length === 0) {
+            console.log(`No sessions in ${SESSION_DIR} (start one with --session NAME)`);
+            return;
+        }
+        for (const session of sessions) {
+            console.log(`${session.name.padEnd(24)} turn ${String(session.turn).padEnd(4)} ${String(session.files).padStart(5)} files   ${session.updatedAt}`);
+        }
+        return;
+    }
+
+    let session;
+    try {
+        session = ContextSession.load(projectRoot, name);
     } catch (error) {
-        throw error; // Re-throw to be caught by main()
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    if (args.includes('--reset')) {
+        console.log(ContextSession.remove(projectRoot, name)
+            ? `✅ Session ${name} reset`
+            : `Session ${name} has not been saved yet`);
+        return;
+    }
+    if (session.turn === 0) {
+        console.error(`❌ No session ${name} in ${SESSION_DIR}`);
+        process.exit(1);
+    }
+
+    console.log(`🔁 Session ${name}: ${session.turn} ${session.turn === 1 ? 'turn' : 'turns'}, ${session.files.size} files supplied (last ${session.updatedAt})`);
+    for (const [file, entry] of [...session.files].sort(([a], [b]) => compareBytes(a, b))) {
+        const supplied = entry.lines ? 'whole' : `${Object.keys(entry.symbols).length} symbols`;
+        console.log(`   turn ${String(entry.turn).padEnd(4)} ${supplied.padEnd(12)} ${file}`);
     }
 }
 
-async function runDashboard() {
+function runReport(args) {
+    const { projectRoot } = parseArguments(args);
+    const kind = args[args.indexOf('report') + 1];
+    if (kind !== 'coverage') {
+        console.error(`❌ Unknown report: ${kind || '(none)'} (expected: ctxman report coverage)`);
+        process.exit(1);
+    }
+
+    if (args.includes('--reset')) {
+        console.log(CoverageHistory.remove(projectRoot)
+            ? '✅ Coverage history reset'
+            : `No coverage history in ${COVERAGE_FILE}`);
+        return;
+    }
+
+    let history;
     try {
-        // Dynamic imports for ESM modules
-        const ReactModule = await import('react');
-        const React = ReactModule.default || ReactModule;
-        const { render } = await import('ink');
-        const Dashboard = (await import('../lib/ui/dashboard.js')).default;
+        history = CoverageHistory.load(projectRoot);
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    const depth = getBenchCount(args, '--depth', 2, 1);
+    const minRuns = getBenchCount(args, '--min-runs', 3, 1);
+    const report = history.report({ depth, minRuns });
 
-        // Clear console and show loading message
-        console.clear();
-        console.log('📊 Analyzing project for dashboard...\n');
+    if (args.includes('--json')) {
+        console.log(JSON.stringify(report, null, 2));
+        return;
+    }
+    console.log(CoverageHistory.format(report, { minRuns }));
+}
 
-        // Run analyzer silently (no console output)
-        const originalLog = console.log;
-        const logs = [];
-        console.log = (...args) => logs.push(args); // Capture logs
+function runExplain(args) {
+    const { projectRoot } = parseArguments(args);
+    const target = args[args.indexOf('explain') + 1];
 
-        const analyzer = new TokenAnalyzer(process.cwd(), {
-            simple: true,
-            verbose: false,
-            dashboard: true // Skip export handling
-        });
-        const stats = analyzer.run();
+    let log;
+    try {
+        log = DecisionLog.load(join(projectRoot, DECISION_FILE));
+    } catch (error) {
+        console.error(`❌ ${error.message}`);
+        process.exit(1);
+    }
+    if (!log) {
+        console.error(`❌ No decision log in ${DECISION_FILE}; run ctxman --cli --decision-log first`);
+        process.exit(1);
+    }
 
-        console.log = originalLog; // Restore console.log
+    if (!target || target.startsWith('-')) {
+        console.log(args.includes('--json') ? JSON.stringify({ run: log.run, ...log.summary() }, null, 2) : DecisionLog.formatSummary(log));
+        return;
+    }
 
-        // Clear and render dashboard
-        console.clear();
+    // Paths are relative to the working directory, or to the project when they are outside it
+    const root = log.run?.root || projectRoot;
+    const fromRoot = relative(root, resolve(target));
+    const file = (fromRoot.startsWith('..') || isAbsolute(fromRoot) ? target : fromRoot).split(sep).join('/');
+    const explanation = log.explain(file);
+    if (!explanation) {
+        const seen = existsSync(join(root, file)) ? 'it was added since' : 'there is no such file in the project';
+        console.error(`❌ ${file} is not in the decision log of the last run (${log.run?.at || 'unknown'}); ${seen}`);
+        process.exit(1);
+    }
 
-        const instance = render(
-            React.createElement(Dashboard, {
-                stats,
-                topFiles: stats.largestFiles || [],
-                status: 'complete',
-                onExit: () => {
-                    instance.unmount();
-                    process.exit(0);
-                }
-            })
-        );
-    } catch (error) {
-        throw error; // Re-throw to be caught by main()
+    if (args.includes('--json')) {
+        console.log(JSON.stringify({ run: log.run, ...explanation }, null, 2));
+        return;
     }
+    console.log(DecisionLog.format(explanation, log.run));
 }
 
 function runFormatConversion(args) {
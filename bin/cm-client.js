@@ -0,0 +1,25 @@
+#!/usr/bin/env node
+
+/**
+ * ctxman-client - Thin client of the ctxman daemon (v3.4.0)
+ * Sends its arguments to the daemon of the current directory (ctxman daemon start)
+ * and prints what it answers. Commands the daemon does not run, and every
+ * command when no daemon is running, fall back to ctxman itself.
+ */
+
+import DaemonClient from '../lib/daemon/DaemonClient.js';
+
+const args = process.argv.slice(2);
+
+try {
+    const code = await new DaemonClient({ root: process.cwd() }).run(args);
+    if (code !== null) {
+        process.exitCode = code;
+    } else {
+        // cli.js runs main() with the same process.argv
+        await import('./cli.js');
+    }
+} catch (error) {
+    console.error(`❌ ${error.message}`);
+    process.exit(1);
+}
@@ -13,6 +13,7 @@ import MethodFilterParser from './lib/parsers/method-filter-parser.js';
 
 // Formatters
 import GitIngestFormatter from './lib/formatters/gitingest-formatter.js';
+import StructuredFormatter from './lib/formatters/structured-formatter.js';
 import ToonFormatter from './lib/formatters/toon-formatter.js';
 import FormatRegistry from './lib/formatters/format-registry.js';
 
@@ -27,6 +28,35 @@ import { Logger, getLogger, createLogger } from './lib/utils/logger.js'; // v2.3
 import Updater from './lib/utils/updater.js'; // v2.3.6+
 import GitUtils from './lib/utils/git-utils.js'; // v2.3.6+
 
+// Symbols (v3.4.0+)
+import SymbolExtractor, { registerExtractorPlugin } from './lib/symbols/SymbolExtractor.js';
+import { SymbolKind } from './lib/symbols/SymbolModel.js';
+import ExtractorPlugin, { ExecExtractorPlugin } from './lib/plugins/ExtractorPlugin.js';
+import { loadExtractors } from './lib/plugins/ExtractorRegistry.js';
+
+// Cache (v3.4.0+)
+import ContentCache from './lib/cache/ContentCache.js';
+
+// Dependency graph (v3.4.0+)
+import DependencyGraph from './lib/graph/DependencyGraph.js';
+import DependencyExpander from './lib/graph/DependencyExpander.js';
+import SymbolSlicer from './lib/graph/SymbolSlicer.js';
+import ImplementationFinder from './lib/graph/ImplementationFinder.js';
+import GraphExporter from './lib/graph/GraphExporter.js';
+
+// Semantic query (v3.4.0+)
+import SemanticIndex from './lib/rag/SemanticIndex.js';
+import { EmbeddingProviderFactory } from './lib/rag/EmbeddingProviderFactory.js';
+
+// Context profiles (v3.4.0+)
+import ContextProfiles from './lib/core/ContextProfiles.js';
+
+// Content transforms (v3.4.0+)
+import TransformPipeline, { registerTransform } from './lib/core/TransformPipeline.js';
+
+// Embedding API (v3.4.0+)
+import ContextEngine from './lib/api/ContextEngine.js';
+
 // Orchestrator functions
 import { generateDigestFromReport, generateDigestFromContext } from './ctxman.js';
 
@@ -41,6 +71,7 @@ export {
 
     // Formatters
     GitIngestFormatter,
+    StructuredFormatter,
     ToonFormatter,
     FormatRegistry,
 
@@ -59,6 +90,38 @@ export {
     Updater,
     GitUtils,
 
+    // v3.4.0+ Symbols
+    SymbolExtractor,
+    SymbolKind,
+    ExtractorPlugin,
+    ExecExtractorPlugin,
+    registerExtractorPlugin,
+    loadExtractors,
+
+    // v3.4.0+ Cache
+    ContentCache,
+
+    // v3.4.0+ Dependency graph
+    DependencyGraph,
+    DependencyExpander,
+    SymbolSlicer,
+    ImplementationFinder,
+    GraphExporter,
+
+    // v3.4.0+ Semantic query
+    SemanticIndex,
+    EmbeddingProviderFactory,
+
+    // v3.4.0+ Context profiles
+    ContextProfiles,
+
+    // v3.4.0+ Content transforms
+    TransformPipeline,
+    registerTransform,
+
+    // v3.4.0+ Embedding API
+    ContextEngine,
+
     // Functions
     generateDigestFromReport,
     generateDigestFromContext
@@ -0,0 +1,71 @@
+/**
+ * Analyze Worker
+ * Runs TokenCalculator.analyzeFile() for batches of files on a worker thread
+ * (v3.4.0, --jobs). Messages: { type: 'analyze', files } returns
+ * [{ fileInfo, methodStats }] in batch order; { type: 'collect' } returns the
+ * token counts this worker cached, for merging into the main ContentCache.
+ */
+
+import { parentPort, workerData } from 'worker_threads';
+import TokenCalculator from './token-calculator.js';
+import TokenBudget from '../core/TokenBudget.js';
+import ContentCache from '../cache/ContentCache.js';
+import Workspace from '../core/Workspace.js';
+import ContentStripper from '../core/ContentStripper.js';
+import LicenseDetector from '../core/LicenseDetector.js';
+import TransformPipeline from '../core/TransformPipeline.js';
+import TokenEstimator from '../core/TokenEstimator.js';
+import TokenUtils from '../utils/token-utils.js';
+
+// Reports are printed by the main thread
+console.log = () => {};
+
+const startedAt = Date.now();
+const { projectRoot, options, budget, cacheTokens, workspace, strip, licenses, transforms, calibration } = workerData;
+
+const ready = (async () => {
+    TokenUtils.useEstimator(new TokenEstimator(calibration));
+
+    let cache = null;
+    if (cacheTokens) {
+        cache = new ContentCache({ root: projectRoot, path: null });
+        cache.mergeTokens(cacheTokens);
+    }
+
+    const tokenBudget = budget ? await TokenBudget.create(budget) : null;
+    return new TokenCalculator(projectRoot, {
+        ...options,
+        tokenBudget,
+        cache,
+        workspace: workspace ? new Workspace(workspace) : null,
+        stripper: strip ? new ContentStripper(strip) : null,
+        licenseDetector: licenses ? new LicenseDetector() : null,
+        transforms: transforms ? new TransformPipeline(transforms.map(step => ({ [step.name]: step.options }))) : null
+    });
+})();
+
+parentPort.on('message', async ({ id, message }) => {
+    try {
+        const calculator = await ready;
+        parentPort.postMessage({ id, result: handle(calculator, message) });
+    } catch (error) {
+        parentPort.postMessage({ id, error: error.message });
+    }
+});
+
+function handle(calculator, message) {
+    if (message.type === 'analyze') {
+        return message.files.map(filePath => {
+            calculator.methodStats = { totalMethods: 0, includedMethods: 0, methodTokens: {} };
+            const fileInfo = calculator.analyzeFile(filePath);
+            return { fileInfo, methodStats: calculator.methodStats };
+        });
+    }
+
+    if (message.type === 'collect') {
+        const cache = calculator.contentCache;
+        return cache ? { tokens: cache.snapshotTokens(startedAt), stats: cache.getStats() } : null;
+    }
+
+    throw new Error(`Unknown worker task: ${message.type}`);
+}
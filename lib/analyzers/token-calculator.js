@@ -10,7 +10,68 @@ import GitIgnoreParser from '../parsers/gitignore-parser.js';
 import MethodAnalyzer from './method-analyzer.js';
 import MethodFilterParser from '../parsers/method-filter-parser.js';
 import GitIngestFormatter from '../formatters/gitingest-formatter.js';
+import StructuredFormatter from '../formatters/structured-formatter.js';
 import { LLMDetector } from '../utils/llm-detector.js';
+import TokenBudget from '../core/TokenBudget.js';
+import TestPairing, { TEST_DIRS } from '../core/TestPairing.js';
+import PriorityScorer from '../core/PriorityScorer.js';
+import RiskOverlay from '../core/RiskOverlay.js';
+import OutputTarget from '../core/OutputTarget.js';
+import SecretRedactor from '../core/SecretRedactor.js';
+import ContextTemplate from '../core/ContextTemplate.js';
+import DuplicateDetector from '../core/DuplicateDetector.js';
+import ContentClassifier from '../core/ContentClassifier.js';
+import ContextProfiles from '../core/ContextProfiles.js';
+import DirectoryConfig, { DirectoryConfigError } from '../core/DirectoryConfig.js';
+import SourceDirectives from '../core/SourceDirectives.js';
+import ProjectDocs from '../core/ProjectDocs.js';
+import ContextWriter, { writeFileAtomic } from '../core/ContextWriter.js';
+import RemoteSummarizer from '../core/RemoteSummarizer.js';
+import CommentTranslator from '../core/CommentTranslator.js';
+import ContextSession from '../core/ContextSession.js';
+import ErrorReport, { FileError, ERROR_REPORT_FILE } from '../core/ErrorReport.js';
+import DependencySummary, { MANIFEST_FILES } from '../core/DependencySummary.js';
+import LicenseDetector, { LICENSE_FILES } from '../core/LicenseDetector.js';
+import CoverageHistory from '../core/CoverageHistory.js';
+import DecisionLog, { DECISION_FILE } from '../core/DecisionLog.js';
+import { nativeFileSystem } from '../core/FileSystem.js';
+import FileReader, { countLines } from '../core/FileReader.js';
+import WorkerPool from '../core/WorkerPool.js';
+import ContentCache from '../cache/ContentCache.js';
+import DependencyGraph from '../graph/DependencyGraph.js';
+import DependencyExpander from '../graph/DependencyExpander.js';
+import SymbolSlicer, { SliceRole } from '../graph/SymbolSlicer.js';
+import SnippetResolver from '../graph/SnippetResolver.js';
+import ImportPruner from '../graph/ImportPruner.js';
+import RelatedFiles from '../graph/RelatedFiles.js';
+import EntryPointDetector from '../graph/EntryPointDetector.js';
+import CrossReferenceIndex from '../graph/CrossReferenceIndex.js';
+import UsageExamples from '../graph/UsageExamples.js';
+import DiffAnalyzer from '../integrations/git/DiffAnalyzer.js';
+import SemanticIndex from '../rag/SemanticIndex.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import ApiDiff from '../symbols/ApiDiff.js';
+import CompressionStats from '../core/CompressionStats.js';
+import PinRebalancer from '../core/PinRebalancer.js';
+import ProjectDetector from '../core/ProjectDetector.js';
+import { notebookToText, NOTEBOOK_EXTENSION } from '../languages/NotebookPlugin.js';
+import LanguageDetector from '../languages/LanguageDetector.js';
+import { compareBytes, comparePaths } from '../utils/ordering.js';
+
+// Upper bound of files per worker task; smaller batches balance uneven files
+const MAX_BATCH_SIZE = 64;
+
+// Budget priority of //ctx:pin files when no scorer ranks them: ahead of the path heuristic
+const PINNED_PRIORITY = 100;
+
+// Budget priority of docs with --docs: ahead of any selected code
+const DOCS_PRIORITY = 200;
+
+// Lowest-priority share of files summarized remotely without a token budget
+const DEFAULT_SUMMARY_SHARE = 0.25;
+
+const SKIPPED_DIRS = ['node_modules', '.git', '.svn', '.hg', 'coverage', 'dist', 'build', '.ipynb_checkpoints'];
 
 class TokenCalculator {
     constructor(projectRoot, options = {}) {
@@ -21,6 +82,21 @@ class TokenCalculator {
         this.methodAnalyzer = new MethodAnalyzer();
         this.methodFilter = this.options.methodLevel ? this.initMethodFilter() : null;
         this.methodStats = { totalMethods: 0, includedMethods: 0, methodTokens: {} };
+        this.directiveWarnings = [];
+        // Files that failed; the run goes on without them unless --strict (v3.4.0)
+        this.errors = new ErrorReport({ strict: this.options.strict });
+        // Paths .contextignore or the profile excluded, for coverage history (v3.4.0)
+        this.ignoredPaths = [];
+        // Why each path was or was not included, for ctxman explain (v3.4.0)
+        this.decisions = new DecisionLog();
+        // Files and directories outside options.sparse, the sparse-checkout definition (v3.4.0)
+        this.sparseSkipped = 0;
+        // Tokens each transform removed, per file (v3.4.0, --compression-stats)
+        this.compression = this.options.compressionStats ? new CompressionStats() : null;
+        this.contentCache = this.options.cache === true
+            ? new ContentCache({ root: projectRoot })
+            : this.options.cache || null;
+        this.fileReader = new FileReader({ mode: this.options.readMode || 'auto' });
     }
 
     initMethodFilter() {
@@ -30,37 +106,210 @@ class TokenCalculator {
     initStats() {
         return {
             totalFiles: 0, totalTokens: 0, totalBytes: 0, totalLines: 0,
-            ignoredFiles: 0, calculatorIgnoredFiles: 0,
+            ignoredFiles: 0, calculatorIgnoredFiles: 0, directiveIgnoredFiles: 0, skippedContentFiles: {},
             byExtension: {}, byDirectory: {}, largestFiles: []
         };
     }
 
     initGitIgnore() {
-        return ConfigUtils.initGitIgnore(this.projectRoot);
+        // Nested .context.yaml files are read again with the ignore files
+        this.directoryConfig = new DirectoryConfig({ root: this.projectRoot });
+        const gitIgnore = ConfigUtils.initGitIgnore(this.projectRoot, this.directoryConfig);
+        const { profile } = this.options;
+        if (profile) {
+            gitIgnore.addProfileRules(profile.include, profile.exclude);
+        }
+        return gitIgnore;
     }
 
     calculateTokens(content, filePath) {
+        if (this.options.tokenBudget) {
+            return this.options.tokenBudget.count(content, filePath);
+        }
         const tokens = TokenUtils.calculate(content, filePath);
         return tokens;
     }
 
+    /**
+     * Content of a file as it may be emitted, redacted when --redact is on (v3.4.0)
+     * @param {Object} fileInfo
+     * @returns {string}
+     */
+    readSource(fileInfo) {
+        const content = this.readFile(fileInfo.path);
+        const { redactor } = this.options;
+        return redactor ? redactor.redact(content, fileInfo.relativePath) : content;
+    }
+
+    /**
+     * Content of a project file: from the working tree, or from the git
+     * object database when options.revision is set (v3.4.0, --rev), with
+     * license headers removed when options.licenseDetector is set (--licenses),
+     * comments or blank lines removed when options.stripper is set (--strip),
+     * string literals replaced when options.anonymizer is set (--anonymize)
+     * and comments in English once options.commentTranslator has translated
+     * them (--translate-comments); options.transforms runs these in its own
+     * order, with the other steps it lists (--transforms)
+     * @param {string} filePath - Absolute path
+     * @returns {string}
+     */
+    readFile(filePath) {
+        const { licenseDetector, stripper, anonymizer, commentTranslator, transforms } = this.options;
+        const relativePath = this.relativePathOf(filePath);
+        const original = this.readOriginal(filePath);
+        if (transforms) {
+            return transforms.apply(original, { relativePath, options: this.options });
+        }
+        const content = licenseDetector ? licenseDetector.strip(original, relativePath) : original;
+        const stripped = stripper ? stripper.strip(content, relativePath) : content;
+        const anonymized = anonymizer ? anonymizer.replaceStrings(stripped, relativePath.split(path.sep).join('/')) : stripped;
+        return commentTranslator ? commentTranslator.apply(anonymized, relativePath) : anonymized;
+    }
+
+    /**
+     * readFile() before --strip; ctx: directives are read from here (v3.4.0)
+     * Notebooks read as percent-format text, with outputs only when
+     * options.notebookOutputs is set (--notebook-outputs)
+     * @param {string} filePath - Absolute path
+     * @returns {string}
+     */
+    readOriginal(filePath) {
+        const { revision } = this.options;
+        const content = revision ? revision.read(filePath) : this.fileReader.readText(filePath);
+        return this.isNotebook(filePath)
+            ? notebookToText(content, { outputs: this.options.notebookOutputs })
+            : content;
+    }
+
+    isNotebook(filePath) {
+        return path.extname(filePath).toLowerCase() === NOTEBOOK_EXTENSION;
+    }
+
+    getTokenizerName() {
+        return this.options.tokenBudget
+            ? this.options.tokenBudget.getTokenizerName()
+            : (TokenUtils.hasExactCounting() ? 'tiktoken' : TokenUtils.getEstimator().getName());
+    }
+
+    /**
+     * Tokenizer part of cache keys; estimates change with each calibration (v3.4.0)
+     * @returns {string}
+     */
+    getTokenizerKey() {
+        const budget = this.options.tokenBudget;
+        const estimating = budget ? budget.isEstimating() : !TokenUtils.hasExactCounting();
+        return estimating ? `${this.getTokenizerName()}|${TokenUtils.getEstimator().getKey()}` : this.getTokenizerName();
+    }
+
+    calculateFileTokens(content, filePath) {
+        if (!this.contentCache) {
+            return this.calculateTokens(content, filePath);
+        }
+
+        return this.contentCache.tokens(
+            ContentCache.hash(content),
+            ContentCache.key(this.getTokenizerKey(), filePath),
+            () => this.calculateTokens(content, filePath)
+        );
+    }
+
     isTextFile(filePath) {
-        return FileUtils.isText(filePath);
+        if (FileUtils.isText(filePath)) return true;
+
+        // Extension-less scripts count when a shebang or modeline names their language (v3.4.0)
+        const { revision } = this.options;
+        if (!revision) return FileUtils.isScript(filePath);
+        try {
+            return FileUtils.isScript(filePath, revision.read(filePath));
+        } catch (error) {
+            return false;
+        }
+    }
+
+    /**
+     * Path of a file in the context: relative to the project root, or under
+     * its repository prefix in a multi-repo workspace (v3.4.0)
+     * @param {string} filePath - Absolute path
+     * @returns {string}
+     */
+    relativePathOf(filePath) {
+        const { workspace } = this.options;
+        return (workspace && workspace.relativePath(filePath)) ?? nativeFileSystem.relative(this.projectRoot, filePath);
+    }
+
+    /**
+     * File on disk of a context path (see relativePathOf)
+     * @param {string} relativePath
+     * @returns {string}
+     */
+    absolutePathOf(relativePath) {
+        const { workspace } = this.options;
+        return (workspace && workspace.absolutePath(relativePath)) ?? path.join(this.projectRoot, relativePath);
     }
 
     analyzeFile(filePath) {
         try {
-            const content = fs.readFileSync(filePath, 'utf8');
-            const stats = fs.statSync(filePath);
+            const { revision, stripper, licenseDetector, transforms } = this.options;
+            const relativePath = this.relativePathOf(filePath);
+            const original = this.readOriginal(filePath);
+
+            // Binaries, minified bundles and lockfiles are left out unless --include-generated
+            const skipped = this.options.includeGenerated ? null : ContentClassifier.classify(original, relativePath);
+            if (skipped) {
+                return {
+                    path: filePath, relativePath, sizeBytes: 0, tokens: 0, lines: 0,
+                    extension: path.extname(filePath).toLowerCase() || 'no-extension', skipped
+                };
+            }
 
+            const unlicensed = licenseDetector ? licenseDetector.strip(original, relativePath) : original;
+            const content = transforms
+                ? transforms.apply(original, { relativePath, options: this.options, analyze: true })
+                : stripper ? stripper.strip(unlicensed, relativePath) : unlicensed;
             const fileInfo = {
                 path: filePath,
-                relativePath: path.relative(this.projectRoot, filePath),
-                sizeBytes: stats.size,
-                tokens: this.calculateTokens(content, filePath),
-                lines: content.split('\n').length,
+                relativePath,
+                sizeBytes: revision ? revision.size(filePath) : fs.statSync(filePath).size,
+                tokens: this.calculateFileTokens(content, filePath),
+                lines: countLines(content),
                 extension: path.extname(filePath).toLowerCase() || 'no-extension'
             };
+            // What --strip (and the other steps of --transforms) removed, for --compression-stats
+            if ((stripper || transforms) && this.options.compressionStats) {
+                const strippedTokens = this.calculateFileTokens(unlicensed, filePath) - fileInfo.tokens;
+                if (strippedTokens > 0) fileInfo.strippedTokens = strippedTokens;
+            }
+            // The license header --licenses removed, for the licensing note
+            const header = licenseDetector?.headerOf(relativePath);
+            if (header) {
+                fileInfo.licenseHeader = {
+                    license: header.license,
+                    copyright: header.copyright,
+                    tokens: Math.max(0, this.calculateFileTokens(original, filePath) - this.calculateFileTokens(unlicensed, filePath))
+                };
+            }
+
+            // Build files and extension-less scripts are routed by their detected language
+            const language = LanguageDetector.detect(relativePath, original);
+            if (language) fileInfo.language = language;
+
+            // Priority rules of .context.yaml files override the default budget ranking
+            const priority = this.directoryPriority(filePath);
+            if (priority !== null) fileInfo.priority = priority;
+
+            // Profile priority rules override those of .context.yaml files
+            if (this.options.profile) {
+                const priority = ContextProfiles.priorityOf(this.options.profile, fileInfo.relativePath.split(path.sep).join('/'));
+                if (priority !== null) fileInfo.priority = priority;
+            }
+
+            // ctx: directives in the file's comments override profile rules
+            const directives = SourceDirectives.parse(original, filePath);
+            if (directives) {
+                fileInfo.directives = directives;
+                if (directives.pin) fileInfo.pinned = true;
+                if (directives.priority !== null) fileInfo.priority = directives.priority;
+            }
 
             // Method-level analysis if enabled
             if (this.options.methodLevel && this.isCodeFile(filePath)) {
@@ -71,13 +320,33 @@ class TokenCalculator {
         } catch (error) {
             return {
                 path: filePath,
-                relativePath: path.relative(this.projectRoot, filePath),
+                relativePath: this.relativePathOf(filePath),
                 sizeBytes: 0, tokens: 0, lines: 0,
-                extension: 'error', error: error.message
+                extension: 'error', error: error.message, errorCode: error.code
             };
         }
     }
 
+    /**
+     * Priority of a file under the .context.yaml files above it (v3.4.0)
+     * @param {string} filePath - Absolute path
+     * @returns {number|null} null outside the project root or without a matching rule
+     */
+    directoryPriority(filePath) {
+        const configPath = this.directoryConfigPath(filePath);
+        return configPath === null ? null : this.directoryConfig.priorityOf(configPath);
+    }
+
+    /**
+     * '/'-separated path of a file under the project root, as DirectoryConfig
+     * takes it; null for files of workspace repositories outside the root
+     * @private
+     */
+    directoryConfigPath(filePath) {
+        if (!nativeFileSystem.contains(this.projectRoot, filePath)) return null;
+        return nativeFileSystem.toPosix(nativeFileSystem.relative(this.projectRoot, filePath));
+    }
+
     isCodeFile(filePath) {
         return FileUtils.isCode(filePath);
     }
@@ -109,11 +378,20 @@ class TokenCalculator {
         return filteredMethods;
     }
 
-    scanDirectory(dir) {
+    /**
+     * @param {string} dir
+     * @param {Array<string>} [directories] - Receives every directory visited (v3.4.0, WarmIndex)
+     * @returns {Array<string>} Absolute paths of text files
+     */
+    scanDirectory(dir, directories = null) {
         const files = [];
+        directories?.push(dir);
+
+        this.directoryConfig.load(nativeFileSystem.toPosix(path.relative(this.projectRoot, dir)));
 
         try {
-            for (const item of fs.readdirSync(dir)) {
+            // Byte-wise, so the scan order does not depend on the file system
+            for (const item of fs.readdirSync(dir).sort(compareBytes)) {
                 const fullPath = path.join(dir, item);
                 const relativePath = path.relative(this.projectRoot, fullPath);
 
@@ -122,22 +400,91 @@ class TokenCalculator {
                     continue;
                 }
 
-                const stat = fs.statSync(fullPath);
+                // Outputs an earlier run did not see (ctxman reproduce) and the --anonymize mapping
+                if (this.options.excludePaths?.has(relativePath)) {
+                    this.recordSkip(relativePath, 'excluded-path');
+                    continue;
+                }
+                // The error report and decision log of an earlier run (v3.4.0)
+                if (fullPath === this.errorReportPath() || fullPath === this.decisionLogPath()) continue;
+
+                // Broken links and unreadable entries are reported, not fatal to the directory
+                let stat;
+                try {
+                    stat = fs.statSync(fullPath);
+                } catch (error) {
+                    this.errors.add('scan', nativeFileSystem.toPosix(relativePath), error);
+                    continue;
+                }
                 if (stat.isDirectory()) {
-                    if (!['node_modules', '.git', '.svn', '.hg', 'coverage', 'dist', 'build'].includes(item)) {
-                        files.push(...this.scanDirectory(fullPath));
+                    // .ctxman/cache holds ContentCache data, not project sources
+                    if (relativePath === path.join('.ctxman', 'cache')) continue;
+                    if (SKIPPED_DIRS.includes(item)) {
+                        this.recordSkip(relativePath, 'skipped-directory', { directory: true });
+                    } else if (!this.isSparseSkipped(relativePath, true)) {
+                        files.push(...this.scanDirectory(fullPath, directories));
+                    }
+                } else if (!this.isSparseSkipped(relativePath, false)) {
+                    if (this.isTextFile(fullPath)) {
+                        files.push(fullPath);
+                    } else {
+                        this.recordSkip(relativePath, 'not-text');
                     }
-                } else if (this.isTextFile(fullPath)) {
-                    files.push(fullPath);
                 }
             }
         } catch (error) {
+            if (error instanceof DirectoryConfigError || error instanceof FileError) throw error;
             console.error(`Error scanning directory ${dir}:`, error.message);
+            this.errors.add('scan', nativeFileSystem.toPosix(path.relative(this.projectRoot, dir)) || '.', error);
         }
 
         return files;
     }
 
+    /**
+     * Whether a path is outside options.sparse, the sparse-checkout definition (v3.4.0)
+     * Skipped directories are not read, and count once.
+     * @param {string} relativePath
+     * @param {boolean} isDirectory
+     * @returns {boolean}
+     */
+    isSparseSkipped(relativePath, isDirectory) {
+        const { sparse } = this.options;
+        if (!sparse || (isDirectory ? sparse.includesDirectory(relativePath) : sparse.includes(relativePath))) {
+            return false;
+        }
+        this.sparseSkipped++;
+        this.recordSkip(relativePath, 'sparse', isDirectory ? { directory: true } : {});
+        return true;
+    }
+
+    /**
+     * Log a path the scan left out (v3.4.0, ctxman explain)
+     * @param {string} relativePath
+     * @param {string} reason - excluded-path, skipped-directory, sparse or not-text
+     * @param {Object} [details]
+     */
+    recordSkip(relativePath, reason, details = {}) {
+        this.decisions.record(nativeFileSystem.toPosix(relativePath), { outcome: 'excluded', stage: 'scan', reason, ...details });
+    }
+
+    /**
+     * Log a path the ignore rules excluded, with the rule that matched (v3.4.0, ctxman explain)
+     * @param {string} relativePath
+     * @param {Object} [details] - { directory, files }
+     */
+    recordIgnored(relativePath, details = {}) {
+        const reason = this.gitIgnore._lastIgnoreReason;
+        this.decisions.record(nativeFileSystem.toPosix(relativePath), {
+            outcome: 'excluded',
+            stage: 'ignore',
+            reason,
+            rule: this.gitIgnore._lastIgnoreRule,
+            ...(reason === 'profile' && this.options.profile ? { profile: this.options.profile.name } : {}),
+            ...details
+        });
+    }
+
     countIgnoredFiles(filePath) {
         const isCalculatorIgnored = ['calculator', 'calculator-include'].includes(
             this.gitIgnore._lastIgnoreReason
@@ -145,19 +492,16 @@ class TokenCalculator {
 
         try {
             const stat = fs.statSync(filePath);
-            if (stat.isDirectory()) {
-                const count = this.countFilesInDirectory(filePath);
-                if (isCalculatorIgnored) {
-                    this.stats.calculatorIgnoredFiles += count;
-                } else {
-                    this.stats.ignoredFiles += count;
-                }
+            const count = stat.isDirectory() ? this.countFilesInDirectory(filePath) : 1;
+            if (isCalculatorIgnored) {
+                this.stats.calculatorIgnoredFiles += count;
             } else {
-                if (isCalculatorIgnored) {
-                    this.stats.calculatorIgnoredFiles++;
-                } else {
-                    this.stats.ignoredFiles++;
-                }
+                this.stats.ignoredFiles += count;
+            }
+            this.recordIgnored(path.relative(this.projectRoot, filePath), stat.isDirectory() ? { directory: true, files: count } : {});
+            // .gitignore'd paths are not meant for contexts; the others are choices of the rules
+            if (this.gitIgnore._lastIgnoreReason !== 'gitignore') {
+                this.ignoredPaths.push({ path: nativeFileSystem.toPosix(path.relative(this.projectRoot, filePath)), files: count });
             }
         } catch { }
     }
@@ -226,6 +570,120 @@ class TokenCalculator {
             context.paths = this.generateCompactPaths(analysisResults.files || analysisResults);
         }
 
+        if (this.expansion) {
+            context.focus = {
+                target: this.options.focus,
+                depth: this.expansion.depth,
+                symbols: [...this.expansion.focus, ...this.expansion.dependencies].map(({ symbol, depth }) => ({
+                    name: symbol.qualifiedName,
+                    kind: symbol.kind,
+                    file: symbol.file,
+                    line: symbol.startLine,
+                    depth
+                }))
+            };
+        }
+
+        if (this.diffScope) {
+            const describe = symbol => ({ name: symbol.qualifiedName, kind: symbol.kind, file: symbol.file, line: symbol.startLine });
+            context.diff = {
+                base: this.diffScope.base || 'HEAD',
+                files: this.diffScope.changes.map(change => ({
+                    path: change.path,
+                    status: change.status,
+                    added: change.added,
+                    deleted: change.deleted
+                })),
+                symbols: this.diffScope.touched.symbols.map(describe),
+                dependencies: this.diffScope.expansion.dependencies.map(({ symbol }) => describe(symbol))
+            };
+        }
+
+        if (this.apiDiffScope) {
+            context.api = {
+                base: this.apiDiffScope.base,
+                head: this.apiDiffScope.head || 'working tree',
+                changes: this.apiDiffScope.changes,
+                callSites: this.apiDiffScope.callSites
+            };
+        }
+
+        if (this.queryScope) {
+            context.query = {
+                text: this.queryScope.query,
+                provider: this.queryScope.provider,
+                chunks: this.queryScope.chunks.map(chunk => ({
+                    file: chunk.file,
+                    name: chunk.name,
+                    kind: chunk.kind,
+                    lines: [chunk.startLine, chunk.endLine],
+                    score: chunk.score,
+                    tokens: chunk.tokens
+                }))
+            };
+        }
+
+        if (this.selection) {
+            context.selection = {
+                symbols: this.selection.symbols.map(symbol => ({
+                    name: symbol.qualifiedName,
+                    kind: symbol.kind,
+                    file: symbol.file,
+                    line: symbol.startLine
+                })),
+                files: this.selection.files.map(({ file, ranges }) => ({
+                    file,
+                    ranges: ranges.map(range => ({ name: range.name, role: range.role, lines: [range.startLine, range.endLine] }))
+                }))
+            };
+            if (this.selection.implementations.length > 0) {
+                context.selection.implementations = this.selection.implementations.map(({ interface: iface, types, included }) => ({
+                    interface: iface.qualifiedName,
+                    included,
+                    types: types.map(({ type, via, methods }) => ({
+                        name: type.qualifiedName,
+                        file: type.file,
+                        line: type.startLine,
+                        via,
+                        methods: methods.map(method => method.name)
+                    }))
+                }));
+            }
+        }
+
+        if (this.testPairing) {
+            context.tests = {
+                mode: this.testPairing.mode,
+                pairs: this.testPairing.pairs.map(({ file, pair }) => [file, pair]),
+                untested: this.testPairing.unpaired
+            };
+        }
+
+        if (this.usageExamples) {
+            context.usageExamples = this.usageExamples.map(({ symbol, uses, examples }) => ({
+                name: symbol.qualifiedName,
+                file: symbol.file,
+                uses,
+                examples: examples.map(example => ({
+                    file: example.file,
+                    lines: [example.startLine, example.endLine],
+                    test: example.test,
+                    shape: example.shape
+                }))
+            }));
+        }
+
+        if (this.budgetPlan) {
+            context.budget = {
+                limit: this.budgetPlan.limit,
+                tokenizer: this.budgetPlan.tokenizer,
+                usedTokens: this.budgetPlan.usedTokens,
+                trimmed: this.budgetPlan.partial.map(item => item.id),
+                summarized: this.budgetPlan.summarized.map(item => item.id),
+                dropped: this.budgetPlan.dropped.map(item => item.id)
+            };
+        }
+
         return context;
     }
 
@@ -246,7 +704,7 @@ class TokenCalculator {
     }
 
     generateCompactPaths(analysisResults) {
-        const sortedFiles = analysisResults.sort((a, b) => b.tokens - a.tokens);
+        const sortedFiles = analysisResults.sort((a, b) => b.tokens - a.tokens || comparePaths(a.relativePath, b.relativePath));
         const pathGroups = {};
 
         sortedFiles.forEach(file => {
@@ -279,9 +737,11 @@ class TokenCalculator {
     }
 
     saveContextToFile(context) {
-        const contextPath = path.join(this.projectRoot, 'llm-context.json');
-        fs.writeFileSync(contextPath, JSON.stringify(context, null, 2));
-        console.log(`💾 Context saved to: ${contextPath}`);
+        const contextPath = this.getOutputTarget().write(JSON.stringify(context, null, 2), this.profileOutput('context') || 'llm-context.json');
+        if (contextPath) {
+            console.log(`💾 Context saved to: ${contextPath}`);
+        }
+        return contextPath;
     }
 
     promptForExport(analysisResults) {
@@ -346,124 +806,2532 @@ class TokenCalculator {
         });
     }
 
-    saveGitIngestDigest(analysisResults) {
-        const formatter = new GitIngestFormatter(this.projectRoot, this.stats, analysisResults);
-        const digestPath = path.join(this.projectRoot, 'digest.txt');
-        const digestSize = formatter.saveToFile(digestPath);
+    /**
+     * Structured JSON/YAML context (v3.4.0)
+     * @param {Array} analysisResults - Files selected for export
+     * @param {Object|null} bundle - { projects, project } of a per-project bundle (--per-project)
+     * @returns {StructuredFormatter}
+     */
+    createStructuredFormatter(analysisResults, bundle = null) {
+        return new StructuredFormatter(this.projectRoot, this.stats, analysisResults, {
+            extractor: this.options.symbolExtractor,
+            cache: this.contentCache,
+            countTokens: (text, filePath) => this.calculateTokens(text, filePath),
+            selection: this.selectionMode(),
+            budget: this.budgetPlan || this.queryScope?.budget,
+            redactor: this.options.redactor || null,
+            readFile: filePath => this.readFile(filePath),
+            collapsible: Boolean(this.options.collapsible),
+            xmlTags: this.options.xmlTags || null,
+            fence: this.options.fence || 'backtick',
+            xmlContent: this.options.xmlContent || 'raw',
+            crossReferences: this.crossReferenceEntries(analysisResults),
+            todos: this.todoEntries(analysisResults),
+            dependencies: this.dependencyEntries(analysisResults),
+            licenses: this.licenseEntries(analysisResults),
+            sectionIds: Boolean(this.options.sectionIds),
+            projects: bundle ? bundle.projects.filter(project => project.files.length > 0).map(project => ({
+                name: project.name,
+                kind: project.kind,
+                dir: project.dir || '.',
+                files: project.files.length,
+                tokens: project.tokens,
+                dependsOn: project.dependsOn,
+                current: project === bundle.project
+            })) : null,
+            snippet: this.snippetResolution ? this.snippetEntry() : null,
+            onError: this.exportErrorHandler()
+        });
+    }
 
-        console.log(`💾 GitIngest digest saved to: digest.txt`);
-        console.log(`📊 Digest size: ${(digestSize / 1024).toFixed(1)} KB`);
+    /**
+     * Snippet of ctxman enrich with what it resolved to, for structured output (v3.4.0)
+     * @returns {Object} { text, frames[] { location, file, line, symbol }, symbols[] { name, kind, file, line } }
+     */
+    snippetEntry() {
+        const { frames, symbols } = this.snippetResolution;
+        return {
+            text: this.options.snippet.text,
+            frames: frames.map(frame => ({
+                location: frame.location,
+                file: frame.file,
+                line: frame.line,
+                symbol: frame.symbol ? frame.symbol.qualifiedName : null
+            })),
+            symbols: symbols.map(symbol => ({ name: symbol.qualifiedName, kind: symbol.kind, file: symbol.file, line: symbol.startLine }))
+        };
     }
 
-    run() {
-        this.printHeader();
+    /**
+     * How files were selected: query, snippet, diff, symbols, focus or all
+     * @returns {{mode: string, target: string|null}}
+     */
+    selectionMode() {
+        const { query, diff, symbols, focus, snippet } = this.options;
+        return query ? { mode: 'query', target: query.text }
+            : snippet ? { mode: 'snippet', target: snippet.source || 'stdin' }
+            : diff ? { mode: 'diff', target: diff.base || 'HEAD' }
+            : symbols?.length > 0 ? { mode: 'symbols', target: symbols.join(', ') }
+                : focus ? { mode: 'focus', target: focus }
+                    : { mode: 'all', target: null };
+    }
 
-        const allFiles = this.scanDirectory(this.projectRoot);
-        this.printScanResults(allFiles);
+    saveStructuredOutput(analysisResults) {
+        const format = this.options.structuredFormat;
+        const formatter = this.createStructuredFormatter(analysisResults);
 
-        // Analyze all files
-        const analysisResults = [];
-        for (const file of allFiles) {
-            const fileInfo = this.analyzeFile(file);
-            this.updateStats(fileInfo);
-            analysisResults.push(fileInfo);
+        if (this.options.outputStream) {
+            ContextWriter.toStream(this.options.outputStream).writeAll(formatter.encodePieces(format)).end();
+            return;
         }
 
-        this.stats.largestFiles.sort((a, b) => b.tokens - a.tokens);
-        this.printReport();
-
-        // Context Fit Analysis (v2.3.7)
-        if (this.options.targetModel && !this.options.dashboard) {
-            this.printContextFitAnalysis();
+        const outputFile = this.options.outputFile || this.profileOutput(format) || StructuredFormatter.defaultFile(format);
+        const { path: outputPath, length } = this.getOutputTarget().stream(formatter.encodePieces(format), outputFile);
+        if (outputPath) {
+            console.log(`💾 Structured context saved to: ${this.displayPath(outputPath)}`);
         }
+        console.log(`📊 Size: ${(length / 1024).toFixed(1)} KB`);
+        this.printRedactionReport();
+    }
 
-        // Handle exports (skip for dashboard mode)
+    /**
+     * Where secrets were redacted, once per run (v3.4.0)
+     */
+    printRedactionReport() {
+        const { redactor } = this.options;
+        if (!redactor || this.redactionReported || this.options.dashboard) return;
+        this.redactionReported = true;
+        console.log(SecretRedactor.formatReport(redactor.findings));
+    }
+
+    /**
+     * @param {Array} analysisResults - Files selected for export
+     * @param {Object|null} bundle - { projects, project } of a per-project bundle (--per-project)
+     * @returns {GitIngestFormatter}
+     */
+    createGitIngestFormatter(analysisResults, bundle = null) {
+        const stats = bundle
+            ? { ...this.stats, totalFiles: bundle.project.files.length, totalTokens: bundle.project.tokens }
+            : this.stats;
+        return new GitIngestFormatter(this.projectRoot, stats, analysisResults, {
+            chunking: this.options.chunking,
+            countTokens: (text, filePath) => this.calculateTokens(text, filePath),
+            redactor: this.options.redactor || null,
+            readFile: filePath => this.readFile(filePath),
+            session: this.sessionDelta || null,
+            apiDiff: this.apiDiffScope || null,
+            layout: this.options.layout || null,
+            tree: this.options.treeCollapser ? this.collapsedTree(analysisResults, bundle) : null,
+            crossReferences: this.options.crossReferences && !this.options.chunking?.enabled
+                ? this.options.crossReferences.format(this.crossReferenceEntries(analysisResults))
+                : null,
+            todos: this.options.todoHarvester && !this.options.chunking?.enabled
+                ? this.options.todoHarvester.format(this.todoEntries(analysisResults))
+                : null,
+            dependencies: this.options.dependencySummary && !this.options.chunking?.enabled
+                ? this.options.dependencySummary.format(this.dependencyEntries(analysisResults))
+                : null,
+            licenses: this.options.licenseDetector && !this.options.chunking?.enabled
+                ? this.options.licenseDetector.format(this.licenseEntries(analysisResults))
+                : null,
+            sectionIds: Boolean(this.options.sectionIds),
+            projects: bundle ? ProjectDetector.formatOverview(bundle.projects, bundle.project) : null,
+            snippet: this.snippetResolution ? SnippetResolver.formatSnippet(this.options.snippet.text, this.snippetResolution) : null,
+            onError: this.exportErrorHandler()
+        });
+    }
+
+    /**
+     * Directory structure of a digest within the tree budget (v3.4.0, --tree-budget)
+     * Lists every analyzed file, expanding the directories of the exported
+     * ones; a per-project bundle lists its own project's directory.
+     * @param {Array} analysisResults - Files selected for export
+     * @param {Object|null} bundle - See createGitIngestFormatter()
+     * @returns {string} Tree rows
+     */
+    collapsedTree(analysisResults, bundle = null) {
+        const collapser = this.options.treeCollapser;
+        const dir = bundle?.project.dir || '';
+        const files = (this.analyzedResults || analysisResults).filter(fileInfo => !fileInfo.error && !fileInfo.skipped &&
+            (!dir || fileInfo.relativePath.split(path.sep).join('/').startsWith(`${dir}/`)));
+        const included = new Set(analysisResults.map(fileInfo => fileInfo.relativePath.split(path.sep).join('/')));
+        const result = collapser.layout(files, {
+            included,
+            rootName: path.basename(this.projectRoot),
+            countTokens: text => this.calculateTokens(text),
+            priorityOf: fileInfo => this.exportPriority(fileInfo)
+        });
         if (!this.options.dashboard) {
-            this.handleExports(analysisResults);
+            console.log(collapser.describe(result));
         }
+        return result.text;
+    }
 
-        // Return stats for programmatic usage (e.g., Dashboard)
-        return this.stats;
+    /**
+     * Formatter callback recording files that could not be written (v3.4.0)
+     * @returns {Function} (fileInfo, error)
+     */
+    exportErrorHandler() {
+        return (fileInfo, error) => this.errors.add('export', nativeFileSystem.toPosix(fileInfo.relativePath), error);
     }
 
-    printHeader() {
-        console.log('🔍 Analyzing project: ' + this.projectRoot);
-        console.log('📝 Respecting .gitignore rules...');
+    /**
+     * Where the exported symbols are defined and referenced (v3.4.0, --xref)
+     * Built once per export; summarized files contribute their exported
+     * symbols but no references, partial files only their selected lines.
+     * @param {Array} analysisResults - Files selected for export
+     * @returns {Array|null} CrossReferenceIndex entries, null without --xref
+     */
+    crossReferenceEntries(analysisResults) {
+        const index = this.options.crossReferences;
+        if (!index) return null;
+        if (this.crossReferences?.results === analysisResults) return this.crossReferences.entries;
+
+        const { graph } = this.buildDependencyGraph(analysisResults);
+        const files = analysisResults
+            .filter(fileInfo => !fileInfo.error)
+            .map(fileInfo => ({
+                path: fileInfo.relativePath.split(path.sep).join('/'),
+                ranges: fileInfo.selectedSymbols || null,
+                summary: Boolean(fileInfo.summary)
+            }));
+        const entries = index.build(graph, files);
+        this.crossReferences = { results: analysisResults, entries };
+        return entries;
+    }
 
-        const configPath = this.gitIgnore.hasIncludeFile ?
-            ConfigUtils.findConfigFile(this.projectRoot, '.contextinclude') :
-            ConfigUtils.findConfigFile(this.projectRoot, '.contextignore');
+    /**
+     * TODO, FIXME and HACK comments of the exported files (v3.4.0, --todos)
+     * With --todos repo, the markers of the other analyzed files follow.
+     * Markers are read from the sources before --strip, which would remove
+     * them; trimmed files then contribute all of theirs.
+     * @param {Array} analysisResults - Files selected for export
+     * @returns {Array|null} TodoHarvester entries, null without --todos
+     */
+    todoEntries(analysisResults) {
+        const harvester = this.options.todoHarvester;
+        if (!harvester) return null;
+        if (this.todos?.results === analysisResults) return this.todos.entries;
+
+        const { redactor, stripper, licenseDetector, transforms } = this.options;
+        const exported = new Set(analysisResults.map(fileInfo => fileInfo.relativePath));
+        const others = harvester.options.scope === 'repo'
+            ? (this.analyzedResults || []).filter(fileInfo => !exported.has(fileInfo.relativePath))
+            : [];
+        const files = [...analysisResults, ...others]
+            .filter(fileInfo => !fileInfo.error)
+            .map(fileInfo => {
+                const content = this.readOriginal(fileInfo.path);
+                return {
+                    path: fileInfo.relativePath.split(path.sep).join('/'),
+                    content: redactor ? redactor.redact(content, fileInfo.relativePath) : content,
+                    ranges: stripper || licenseDetector || transforms ? null : fileInfo.selectedSymbols || null,
+                    included: exported.has(fileInfo.relativePath)
+                };
+            });
 
-        if (configPath) {
-            const mode = this.gitIgnore.hasIncludeFile ? 'INCLUDE' : 'EXCLUDE';
-            console.log(`📅 Found calculator config - using ${mode} mode`);
-            console.log('   Location: ' + path.relative(this.projectRoot, configPath));
+        const entries = harvester.harvest(files);
+        this.todos = { results: analysisResults, entries };
+        if (!this.options.dashboard) {
+            console.log(harvester.describe(entries));
         }
+        return entries;
+    }
 
-        console.log('🎯 Token calculation: ' + (TokenUtils.hasExactCounting() ? '✅ Exact (using tiktoken)' : '⚠️  Estimated'));
+    /**
+     * Dependencies declared by the project's manifests (v3.4.0, --deps)
+     * Manifests are looked up in the project root and in the directories of
+     * the analyzed files, whether or not they are exported themselves, and
+     * read before --strip, which would drop go.mod's // indirect comments.
+     * @param {Array} analysisResults - Files selected for export
+     * @returns {Array|null} DependencySummary entries, null without --deps
+     */
+    dependencyEntries(analysisResults) {
+        const summary = this.options.dependencySummary;
+        if (!summary) return null;
+        if (this.dependencies?.results === analysisResults) return this.dependencies.entries;
+
+        const { redactor } = this.options;
+        const contents = new Map();
+        const read = relativePath => {
+            if (!contents.has(relativePath)) {
+                const content = this.readOriginal(path.join(this.projectRoot, ...relativePath.split('/')));
+                contents.set(relativePath, redactor ? redactor.redact(content, relativePath) : content);
+            }
+            return contents.get(relativePath);
+        };
 
-        if (this.options.methodLevel) {
-            console.log('🔧 Method-level analysis: ✅ Enabled');
-            if (this.methodFilter) {
-                const hasInclude = this.methodFilter.hasIncludeFile;
-                const mode = hasInclude ? 'INCLUDE' : 'EXCLUDE';
-                console.log(`   Method filtering: ${mode} mode active`);
+        const analyzed = (this.analyzedResults || analysisResults).map(fileInfo => fileInfo.relativePath.split(path.sep).join('/'));
+        const dirs = new Set(['.', ...analyzed.map(file => path.posix.dirname(file))]);
+        const candidates = new Set([
+            ...[...dirs].flatMap(dir => MANIFEST_FILES.map(name => (dir === '.' ? name : `${dir}/${name}`))),
+            ...analyzed.filter(file => DependencySummary.isManifest(file))
+        ]);
+        const manifests = [...candidates]
+            .filter(file => {
+                try {
+                    read(file);
+                    return true;
+                } catch {
+                    return false;
+                }
+            })
+            .sort((a, b) => a.split('/').length - b.split('/').length || compareBytes(a, b));
+
+        const entries = summary.summarize(manifests, read);
+        this.dependencies = { results: analysisResults, entries };
+        if (!this.options.dashboard) {
+            console.log(summary.describe(entries));
+        }
+        return entries;
+    }
+
+    /**
+     * Licensing note of the export (v3.4.0, --licenses)
+     * LICENSE files are looked up like manifests, in the project root and in
+     * the directories of the analyzed files, as they are left out of the
+     * export; headers come from the exported files the analysis removed them from.
+     * @param {Array} analysisResults - Files selected for export
+     * @returns {Object|null} LicenseDetector note, null without --licenses
+     */
+    licenseEntries(analysisResults) {
+        const detector = this.options.licenseDetector;
+        if (!detector) return null;
+        if (this.licenses?.results === analysisResults) return this.licenses.note;
+
+        const { redactor } = this.options;
+        const analyzed = (this.analyzedResults || analysisResults).map(fileInfo => fileInfo.relativePath.split(path.sep).join('/'));
+        const dirs = new Set(['.', ...analyzed.map(file => path.posix.dirname(file))]);
+        const candidates = new Set([
+            ...[...dirs].flatMap(dir => LICENSE_FILES.map(name => (dir === '.' ? name : `${dir}/${name}`))),
+            ...analyzed.filter(file => LicenseDetector.isLicenseFile(file))
+        ]);
+        const licenseFiles = [];
+        for (const file of candidates) {
+            let content;
+            try {
+                content = this.readOriginal(path.join(this.projectRoot, ...file.split('/')));
+            } catch {
+                continue;
             }
+            licenseFiles.push({
+                path: file,
+                content: redactor ? redactor.redact(content, file) : content,
+                tokens: this.calculateTokens(content, file)
+            });
         }
+        const headers = analysisResults
+            .filter(fileInfo => !fileInfo.error && fileInfo.licenseHeader)
+            .map(fileInfo => ({ path: fileInfo.relativePath.split(path.sep).join('/'), ...fileInfo.licenseHeader }));
 
-        console.log();
+        const note = detector.aggregate(licenseFiles, headers);
+        this.licenses = { results: analysisResults, note };
+        if (!this.options.dashboard) {
+            console.log(detector.describe(note));
+        }
+        return note;
     }
 
-    printScanResults(allFiles) {
-        console.log(`📁 Found ${allFiles.length} text files to analyze`);
-        console.log(`🚫 Ignored ${this.stats.ignoredFiles} files due to .gitignore rules`);
-        if (this.stats.calculatorIgnoredFiles > 0) {
-            const mode = this.gitIgnore.hasIncludeFile ? 'include rules' : 'ignore rules';
-            console.log(`📋 Filtered ${this.stats.calculatorIgnoredFiles} additional files due to calculator ${mode}`);
-        }
+    /**
+     * Leave LICENSE, COPYING and NOTICE files out of the export (v3.4.0, --licenses)
+     * The licensing note names them and their license instead.
+     * @param {Array} exportResults
+     * @returns {Array}
+     */
+    applyLicenseFiles(exportResults) {
+        return exportResults.filter(fileInfo => {
+            if (!LicenseDetector.isLicenseFile(fileInfo.relativePath)) return true;
+            this.compression?.record('license', fileInfo, 0);
+            return false;
+        });
+    }
 
-        if (this.options.methodLevel) {
-            console.log(`🔧 Total methods found: ${this.methodStats.totalMethods}`);
-            console.log(`✅ Methods included: ${this.methodStats.includedMethods}`);
+    saveGitIngestDigest(analysisResults) {
+        const formatter = this.createGitIngestFormatter(analysisResults);
+        const digestFile = this.profileOutput('gitingest') || 'digest.txt';
+        const target = this.getOutputTarget();
+
+        // Encrypted digests go to the encryption command rather than into a plaintext file
+        if (target.isStream() || (target.isEncrypted() && !formatter.chunking.enabled)) {
+            // Streamed file by file, so large digests can be piped without being held
+            const { path: digestPath, length } = target.stream(formatter.generatePieces(), digestFile);
+            if (digestPath) {
+                console.log(`💾 GitIngest digest saved to: ${this.displayPath(digestPath)}`);
+            }
+            console.log(`📊 Digest size: ${(length / 1024).toFixed(1)} KB`);
+            this.printRedactionReport();
+            return;
         }
 
-        console.log();
+        const digestPath = target.resolve(digestFile);
+        const digestSize = formatter.saveToFile(digestPath, target.fileOptions());
+
+        if (formatter.chunkFiles) {
+            const chunkFiles = formatter.chunkFiles.map(chunkFile => target.done(chunkFile));
+            const first = this.displayPath(chunkFiles[0]);
+            console.log(`💾 GitIngest digest saved to ${chunkFiles.length} chunks: ${first} …`);
+        } else {
+            console.log(`💾 GitIngest digest saved to: ${this.displayPath(target.done(digestPath))}`);
+        }
+        console.log(`📊 Digest size: ${(digestSize / 1024).toFixed(1)} KB`);
+        this.printRedactionReport();
     }
 
-    printReport() {
-        console.log('='.repeat(80));
-        console.log('🎯 PROJECT TOKEN ANALYSIS REPORT');
-        console.log('='.repeat(80));
-        console.log(`📊 Total files analyzed: ${this.stats.totalFiles.toLocaleString()}`);
-        console.log(`🔢 Total tokens: ${this.stats.totalTokens.toLocaleString()}`);
-        console.log(`💾 Total size: ${(this.stats.totalBytes / 1024 / 1024).toFixed(2)} MB`);
-        console.log(`📄 Total lines: ${this.stats.totalLines.toLocaleString()}`);
+    /**
+     * Projects of the exported files, with their tokens (v3.4.0, --per-project)
+     * Manifests are read before --strip, like those of --deps.
+     * @param {Array} analysisResults - Files selected for export
+     * @returns {Array<Object>} ProjectDetector projects
+     */
+    detectProjects(analysisResults) {
+        const files = analysisResults.filter(fileInfo => !fileInfo.error);
+        const byPath = new Map(files.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+        const read = relativePath => this.readOriginal(path.join(this.projectRoot, ...relativePath.split('/')));
+
+        const projects = this.options.projectDetector.detect([...byPath.keys()].sort(compareBytes), read);
+        for (const project of projects) {
+            project.tokens = project.files.reduce((sum, file) => sum + byPath.get(file).tokens, 0);
+        }
+        if (!this.options.dashboard) {
+            console.log(ProjectDetector.formatProjects(projects));
+        }
+        return projects;
+    }
 
-        const avgTokens = this.stats.totalFiles > 0
-            ? Math.round(this.stats.totalTokens / this.stats.totalFiles).toLocaleString()
-            : 'N/A';
-        console.log(`📈 Average tokens per file: ${avgTokens}`);
-        console.log(`🚫 Files ignored by .gitignore: ${this.stats.ignoredFiles.toLocaleString()}`);
-        if (this.stats.calculatorIgnoredFiles > 0) {
-            console.log(`📋 Files ignored by calculator rules: ${this.stats.calculatorIgnoredFiles.toLocaleString()}`);
+    /**
+     * One digest or structured context per project, each headed by the
+     * overview of every project; digest.txt becomes digest-<project>.txt
+     * (v3.4.0, --per-project)
+     * @param {Array} analysisResults - Files selected for export
+     */
+    saveProjectBundles(analysisResults) {
+        const projects = this.detectProjects(analysisResults);
+        const byPath = new Map(analysisResults.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+        const format = this.options.structuredFormat;
+        const baseFile = format
+            ? this.options.outputFile || this.profileOutput(format) || StructuredFormatter.defaultFile(format)
+            : this.profileOutput('gitingest') || 'digest.txt';
+        const { dir, name, ext } = path.parse(baseFile);
+        const target = this.getOutputTarget();
+
+        const written = [];
+        let length = 0;
+        for (const project of projects.filter(project => project.files.length > 0)) {
+            const files = project.files.map(file => byPath.get(file));
+            const bundle = { projects, project };
+            const pieces = format
+                ? this.createStructuredFormatter(files, bundle).encodePieces(format)
+                : this.createGitIngestFormatter(files, bundle).generatePieces();
+            const saved = target.stream(pieces, path.join(dir, `${name}-${ProjectDetector.slug(project)}${ext}`));
+            if (saved.path) written.push(saved.path);
+            length += saved.length;
         }
-        console.log();
 
-        this.printExtensionStats();
-        this.printTopFiles();
-        this.printTopDirectories();
+        if (written.length > 0) {
+            const more = written.length > 1 ? ` … (${written.length} bundles)` : '';
+            console.log(`💾 Project bundles saved to: ${this.displayPath(written[0])}${more}`);
+        }
+        console.log(`📊 Size: ${(length / 1024).toFixed(1)} KB`);
+        this.printRedactionReport();
+    }
 
-        console.log('\n💡 Tip: Use --save-report flag to save detailed analysis to JSON file');
+    /**
+     * Data a --template renders against (v3.4.0); file contents, the tree
+     * and the full context are only generated when the template uses them
+     * @param {Array} analysisResults - Files selected for export
+     * @returns {Object}
+     */
+    createTemplateData(analysisResults) {
+        const digester = this.createGitIngestFormatter(analysisResults);
+        const structured = this.createStructuredFormatter(analysisResults);
+        const format = this.options.structuredFormat;
+
+        const files = analysisResults
+            .filter(fileInfo => !fileInfo.error)
+            .sort((a, b) => b.tokens - a.tokens || comparePaths(a.relativePath, b.relativePath))
+            .map(fileInfo => ContextTemplate.lazy({
+                Path: fileInfo.relativePath,
+                Language: structured.extractor.getPlugin(fileInfo.relativePath)?.getLanguageId(fileInfo.relativePath) || fileInfo.language || null,
+                Tokens: fileInfo.tokens,
+                Lines: fileInfo.lines,
+                Duplicates: (fileInfo.duplicates || []).map(duplicate => duplicate.path)
+            }, {
+                Content: () => digester.generateFileBody(fileInfo)
+            }));
+
+        return ContextTemplate.lazy({
+            Project: path.basename(this.projectRoot),
+            Root: this.projectRoot,
+            Date: (this.options.now || new Date()).toISOString().slice(0, 10),
+            Tokenizer: this.getTokenizerName(),
+            TotalFiles: files.length,
+            TotalTokens: files.reduce((sum, file) => sum + file.Tokens, 0),
+            Files: files,
+            Vars: new Map(this.options.templateVars || [])
+        }, {
+            Tree: () => digester.formatTreeNode(digester.buildFileTree(), '', true),
+            // --format picks what {{.Context}} holds; the digest otherwise
+            Context: () => {
+                if (format) return structured.encode(format);
+                const digest = digester.generateDigest();
+                return Array.isArray(digest) ? digest.map(chunk => chunk.content).join('\n') : digest;
+            }
+        });
     }
 
-    printExtensionStats() {
-        console.log('📋 BY FILE TYPE:');
+    saveTemplatedOutput(analysisResults) {
+        const { template } = this.options;
+        let output;
+        try {
+            output = template.render(this.createTemplateData(analysisResults));
+        } catch (error) {
+            console.error(`❌ ${error.message}`);
+            return null;
+        }
+
+        if (this.options.outputStream) {
+            this.options.outputStream.write(output);
+            return null;
+        }
+
+        const outputFile = this.options.outputFile || ContextTemplate.defaultFile(template.file || template.name);
+        const outputPath = this.getOutputTarget().write(output, outputFile);
+        if (outputPath) {
+            console.log(`💾 Templated context saved to: ${this.displayPath(outputPath)}`);
+        }
+        console.log(`📊 Size: ${(output.length / 1024).toFixed(1)} KB`);
+        this.printRedactionReport();
+        return outputPath;
+    }
+
+    /**
+     * Destination of generated context (v3.4.0)
+     * @returns {OutputTarget} options.outputTarget, or files in options.outputRoot (default: the project)
+     */
+    getOutputTarget() {
+        if (!this.outputTarget) {
+            this.outputTarget = this.options.outputTarget || new OutputTarget({
+                root: this.outputRoot(),
+                fsync: this.options.fsync,
+                backup: this.options.backup
+            });
+        }
+        return this.outputTarget;
+    }
+
+    /**
+     * Directory for output files: options.outputRoot, e.g. the working directory
+     * of a remote repository's context, or the project (v3.4.0)
+     * @private
+     */
+    outputRoot() {
+        return this.options.outputRoot || this.projectRoot;
+    }
+
+    /**
+     * Output path relative to the output root, or absolute outside it
+     * @private
+     */
+    displayPath(outputPath) {
+        const relativePath = path.relative(this.outputRoot(), outputPath);
+        return relativePath.startsWith('..') || path.isAbsolute(relativePath) ? outputPath : relativePath;
+    }
+
+    /**
+     * Output file a context profile sets for its own format (v3.4.0)
+     * @param {string} format - gitingest, context, json, yaml or markdown
+     * @returns {string|null}
+     */
+    profileOutput(format) {
+        const { profile } = this.options;
+        return profile?.output && profile.format === format ? profile.output : null;
+    }
+
+    run() {
+        this.printHeader();
+        this.runStartedAt = Date.now();
+
+        const allFiles = this.filterOwnedFiles(this.scanProject());
+        this.printScanResults(allFiles);
+
+        // Analyze all files
+        return this.completeRun(this.analyzeFiles(allFiles));
+    }
+
+    /**
+     * run() with per-file analysis spread over options.jobs worker threads (v3.4.0)
+     * @returns {Promise<Object>} Stats, as returned by run()
+     */
+    async runParallel() {
+        this.printHeader();
+        this.runStartedAt = Date.now();
+
+        const allFiles = this.filterOwnedFiles(this.scanProject());
+        this.printScanResults(allFiles);
+
+        return this.completeRun(await this.analyzeFilesParallel(allFiles));
+    }
+
+    /**
+     * Files to analyze; a daemon's WarmIndex (options.index) reuses its last
+     * scan, and the ignore counts it found, while the tree is unchanged (v3.4.0)
+     * @returns {Array<string>} Absolute paths
+     */
+    scanProject() {
+        const { index, profile, workspace, revision, fileList } = this.options;
+        if (fileList) {
+            const listed = this.scanFileList(fileList);
+            return this.options.relatedFiles ? this.addRelatedFiles(listed) : listed;
+        }
+        if (revision) {
+            return this.scanRevision(revision);
+        }
+        if (workspace) {
+            // Ignore files of the other repositories are not watched by the index
+            return this.scanWorkspace(workspace);
+        }
+        if (!index) {
+            return this.scanDirectory(this.projectRoot);
+        }
+
+        const key = JSON.stringify([profile?.include, profile?.exclude, this.options.sparse?.patterns]);
+        const { files, stats } = index.scan(key, directories => {
+            const scanned = this.scanDirectory(this.projectRoot, directories);
+            const { ignoredFiles, calculatorIgnoredFiles } = this.stats;
+            return { files: scanned, stats: { ignoredFiles, calculatorIgnoredFiles } };
+        });
+        Object.assign(this.stats, stats);
+        return files;
+    }
+
+    /**
+     * Keep the files that CODEOWNERS assigns to options.owners (v3.4.0, --owner)
+     * @param {Array<string>} files - Absolute paths from scanProject()
+     * @returns {Array<string>}
+     */
+    filterOwnedFiles(files) {
+        const { codeOwners, owners } = this.options;
+        if (!codeOwners || !owners || owners.length === 0) return files;
+
+        const toPosix = file => path.relative(this.projectRoot, file).split(path.sep).join('/');
+        const owned = files.filter(file => codeOwners.isOwnedBy(toPosix(file), owners));
+        this.ownedFiles = { owners, kept: owned.length, scanned: files.length, source: codeOwners.source };
+        return owned;
+    }
+
+    /**
+     * Files named by an ad-hoc list (--files, v3.4.0)
+     * Listed files are taken as given; listed directories are scanned with the
+     * usual ignore rules. Deleted, binary and out-of-project entries are skipped.
+     * @param {Array<string>} entries - Paths relative to the project root, or absolute
+     * @returns {Array<string>} Absolute paths
+     */
+    scanFileList(entries) {
+        const files = new Set();
+        this.skippedListEntries = [];
+
+        for (const entry of entries) {
+            const filePath = path.resolve(this.projectRoot, nativeFileSystem.normalize(entry));
+            const stat = nativeFileSystem.contains(this.projectRoot, filePath)
+                ? fs.statSync(filePath, { throwIfNoEntry: false })
+                : null;
+
+            if (stat?.isDirectory()) {
+                this.scanDirectory(filePath).forEach(file => files.add(file));
+            } else if (stat?.isFile() && this.isTextFile(filePath)) {
+                files.add(filePath);
+            } else {
+                this.skippedListEntries.push(entry);
+            }
+        }
+
+        return [...files];
+    }
+
+    /**
+     * Suggest files related to the listed ones and add the accepted (v3.4.0)
+     * The rest of the project is scanned with the usual ignore rules; its
+     * ignore counts are not reported, as its files are not analyzed.
+     * @param {Array<string>} listed - Absolute paths of the seed files
+     * @returns {Array<string>} Seeds, then accepted suggestions
+     */
+    addRelatedFiles(listed) {
+        const related = this.options.relatedFiles;
+        const { ignoredFiles, calculatorIgnoredFiles } = this.stats;
+        const project = this.scanDirectory(this.projectRoot);
+        Object.assign(this.stats, { ignoredFiles, calculatorIgnoredFiles });
+
+        const toPosix = filePath => nativeFileSystem.toPosix(nativeFileSystem.relative(this.projectRoot, filePath));
+        const seeds = listed.map(toPosix);
+        const graph = new DependencyGraph({
+            root: this.projectRoot,
+            extractor: this.options.symbolExtractor,
+            cache: this.contentCache,
+            onError: (file, error) => this.errors.add('symbols', file, error)
+        }).build(project.map(filePath => ({ path: filePath, relativePath: path.relative(this.projectRoot, filePath) })));
+
+        this.relatedSuggestions = related.suggest(seeds, {
+            files: project.map(toPosix),
+            graph,
+            coChanges: RelatedFiles.coChangesOf(this.projectRoot, seeds, related.options)
+        });
+        const { limit, accept } = related.options;
+        if (!this.options.dashboard) {
+            console.log(RelatedFiles.formatSuggestions(this.relatedSuggestions, { seeds: seeds.length, limit, accepted: accept }));
+        }
+
+        const accepted = this.relatedSuggestions.slice(0, accept).map(suggestion => path.join(this.projectRoot, suggestion.path));
+        return [...listed, ...accepted];
+    }
+
+    /**
+     * Files of the project at a git revision, filtered by the current ignore files (v3.4.0)
+     * Their contents are loaded from the object database in one pass.
+     * @param {GitRevision} revision
+     * @returns {Array<string>} Absolute paths, which need not exist in the working tree
+     */
+    scanRevision(revision) {
+        const files = [];
+        for (const { relativePath } of revision.listFiles()) {
+            const dirs = relativePath.split('/').slice(0, -1);
+            if (dirs.some(dir => SKIPPED_DIRS.includes(dir)) || relativePath.startsWith('.ctxman/cache/')) continue;
+            if (this.isSparseSkipped(relativePath, false)) continue;
+
+            const filePath = path.join(this.projectRoot, relativePath);
+            if (this.gitIgnore.isIgnored(filePath, relativePath)) {
+                const isCalculatorIgnored = ['calculator', 'calculator-include'].includes(this.gitIgnore._lastIgnoreReason);
+                this.stats[isCalculatorIgnored ? 'calculatorIgnoredFiles' : 'ignoredFiles']++;
+                this.recordIgnored(relativePath);
+            } else if (this.isTextFile(filePath)) {
+                files.push(filePath);
+            }
+        }
+
+        revision.preload(files);
+        return files;
+    }
+
+    /**
+     * Files of every workspace repository, each filtered by its own ignore files (v3.4.0)
+     * @param {Workspace} workspace
+     * @returns {Array<string>} Absolute paths
+     */
+    scanWorkspace(workspace) {
+        const files = [];
+        for (const repo of workspace.repos) {
+            const scanner = new TokenCalculator(repo.root, { profile: this.options.profile });
+            files.push(...scanner.scanDirectory(repo.root));
+            this.stats.ignoredFiles += scanner.stats.ignoredFiles;
+            this.stats.calculatorIgnoredFiles += scanner.stats.calculatorIgnoredFiles;
+        }
+        return files;
+    }
+
+    /**
+     * Key of what analyzeFile() computes, or null when it cannot be reused
+     * (method-level analysis depends on filter files)
+     * @param {Array<string>} [files] - Absolute paths, whose .context.yaml files count
+     * @returns {string|null}
+     */
+    getAnalysisKey(files = []) {
+        if (this.options.methodLevel) return null;
+        const directoryKey = this.directoryConfig.getKey(files
+            .map(file => this.directoryConfigPath(file))
+            .filter(file => file !== null));
+        return JSON.stringify([this.getTokenizerKey(), this.options.profile?.priority, directoryKey, this.options.workspace?.getKey(), this.options.revision?.commit, this.options.stripper?.getKey(), this.options.licenseDetector?.getKey(), this.options.transforms?.getKey(), Boolean((this.options.stripper || this.options.transforms) && this.options.compressionStats), this.options.notebookOutputs, this.options.includeGenerated]);
+    }
+
+    /**
+     * Report, select and export analyzed files
+     */
+    completeRun(analysisResults) {
+        // Every analyzed file, before selection (v3.4.0, --todos repo)
+        this.analyzedResults = analysisResults;
+        if (this.runStartedAt !== undefined) {
+            this.scanDurationMs = Date.now() - this.runStartedAt;
+        }
+        this.printReport();
+
+        // Token tree (v3.4.0, --tree)
+        if (this.options.tokenTree && !this.options.dashboard) {
+            const files = analysisResults.filter(fileInfo => !fileInfo.error);
+            console.log(this.options.tokenTree.format(files, path.basename(this.projectRoot)));
+        }
+
+        // Context Fit Analysis (v2.3.7)
+        if (this.options.targetModel && !this.options.dashboard) {
+            this.printContextFitAnalysis();
+        }
+
+        const exportResults = this.selectExportResults(analysisResults);
+        if (exportResults instanceof Promise) {
+            // Remote summaries arrive asynchronously (v3.4.0)
+            return exportResults.then(results => this.finishRun(results));
+        }
+        return this.finishRun(exportResults);
+    }
+
+    /**
+     * Export the selected files and save the cache
+     * @param {Array|null} exportResults - Result of selectExportResults()
+     * @returns {Object} Stats
+     */
+    finishRun(exportResults) {
+        // What was exported, for ctxman pack (v3.4.0)
+        this.exportResults = exportResults;
+
+        // Handle exports (skip for dashboard mode)
+        if (exportResults && !this.options.dashboard) {
+            // Content policies are checked before anything is written (v3.4.0)
+            if (this.options.policy) {
+                this.checkPolicy(exportResults);
+            }
+            this.handleExports(exportResults);
+            if (this.compression) {
+                this.reportCompression(exportResults);
+            }
+            if (this.options.session) {
+                this.recordSession(exportResults);
+            }
+            if (this.contentCache?.getFilePath()) {
+                this.recordCoverage(exportResults);
+            }
+            if (this.decisionLogRequested()) {
+                this.recordDecisions(exportResults);
+            }
+        }
+
+        this.reportErrors();
+
+        // Servers count every run for their metrics endpoint (v3.4.0, --metrics)
+        if (this.options.metrics) {
+            this.options.metrics.recordRun(this, exportResults);
+        }
+
+        if (this.contentCache) {
+            this.contentCache.save();
+        }
+
+        // Return stats for programmatic usage (e.g., Dashboard)
+        return this.stats;
+    }
+
+    /**
+     * Summarize the files that failed and write the error report (v3.4.0)
+     * The default report is only written when files failed, and an old one
+     * is removed; --error-report FILE is always written.
+     */
+    reportErrors() {
+        const reportPath = this.errorReportPath();
+        if (this.errors.size > 0 || this.options.errorReport) {
+            this.errors.save(reportPath);
+        } else if (fs.existsSync(reportPath)) {
+            fs.rmSync(reportPath, { force: true });
+        }
+        if (this.errors.size > 0 && !this.options.dashboard) {
+            console.log(`\n${this.errors.format()}`);
+            console.log(`📋 Error report: ${this.displayPath(reportPath)}`);
+        }
+    }
+
+    /**
+     * Print what each transform removed, and write it as JSON to
+     * options.compressionStats.file when set (v3.4.0, --compression-stats)
+     * @param {Array} exportResults - Files exported
+     */
+    reportCompression(exportResults) {
+        this.compressionStats = this.compression.build(exportResults);
+        console.log(CompressionStats.formatReport(this.compressionStats));
+
+        const { file } = this.options.compressionStats;
+        if (file) {
+            const statsPath = path.resolve(this.outputRoot(), file);
+            fs.mkdirSync(path.dirname(statsPath), { recursive: true });
+            writeFileAtomic(statsPath, JSON.stringify(this.compressionStats, null, 2) + '\n', this.getOutputTarget().fileOptions());
+            console.log(`📉 Compression statistics: ${this.displayPath(statsPath)}`);
+        }
+    }
+
+    /**
+     * List the policy violations of the files to export (v3.4.0, .ctxman/policy.json)
+     * @param {Array} exportResults
+     * @throws {Error} When the policy is enforced and violated; nothing is written then
+     */
+    checkPolicy(exportResults) {
+        const { policy } = this.options;
+        this.policyViolations = policy.evaluate(exportResults.filter(fileInfo => !fileInfo.error).map(fileInfo => ({
+            path: fileInfo.relativePath.split(path.sep).join('/'),
+            read: () => this.readSource(fileInfo),
+            readOriginal: () => this.readOriginal(fileInfo.path)
+        })));
+        console.log(policy.formatReport(this.policyViolations));
+
+        if (policy.options.enforce && this.policyViolations.length > 0) {
+            throw new Error(`${this.policyViolations.length} content policy violations; no context was written`);
+        }
+    }
+
+    /**
+     * @returns {string} Absolute path of the error report
+     */
+    errorReportPath() {
+        return path.resolve(this.outputRoot(), this.options.errorReport || ERROR_REPORT_FILE);
+    }
+
+    /**
+     * @returns {string} Absolute path of the decision log (v3.4.0, ctxman explain)
+     */
+    decisionLogPath() {
+        return path.resolve(this.outputRoot(), DECISION_FILE);
+    }
+
+    /**
+     * The decision log is written only for --decision-log or a profile with decisions: true
+     * @returns {boolean}
+     */
+    decisionLogRequested() {
+        return Boolean(this.options.decisionLog || this.options.profile?.decisions);
+    }
+
+    /**
+     * Analyze files and update stats
+     * @param {Array<string>} files - Absolute paths
+     * @returns {Array} File analyses
+     */
+    analyzeFiles(files) {
+        const { index } = this.options;
+        const key = index ? this.getAnalysisKey(files) : null;
+        const analysisResults = [];
+        for (const file of files) {
+            const fileInfo = index
+                ? index.analysis(file, key, () => this.analyzeFile(file))
+                : this.analyzeFile(file);
+            this.recordFailure(fileInfo);
+            if (this.isDirectiveIgnored(fileInfo) || this.isContentSkipped(fileInfo)) continue;
+            this.updateStats(fileInfo);
+            analysisResults.push(fileInfo);
+        }
+
+        this.stats.largestFiles.sort((a, b) => b.tokens - a.tokens || comparePaths(a.relativePath, b.relativePath));
+        return analysisResults;
+    }
+
+    /**
+     * Report a file that could not be read or counted (v3.4.0)
+     * It stays in the results with its error, which later stages skip.
+     * @param {Object} fileInfo - From analyzeFile()
+     * @throws {FileError} With --strict
+     */
+    recordFailure(fileInfo) {
+        if (!fileInfo.error) return;
+        const file = nativeFileSystem.toPosix(fileInfo.relativePath);
+        this.errors.add('analyze', file, { message: fileInfo.error, code: fileInfo.errorCode });
+        this.decisions.record(file, { outcome: 'excluded', stage: 'analyze', reason: 'error', message: fileInfo.error });
+    }
+
+    /**
+     * Whether a file was classified as binary, minified or a lockfile (v3.4.0)
+     * Counts it by kind for the report.
+     * @param {Object} fileInfo - From analyzeFile()
+     * @returns {boolean}
+     */
+    isContentSkipped(fileInfo) {
+        if (!fileInfo.skipped) return false;
+        const counts = this.stats.skippedContentFiles;
+        counts[fileInfo.skipped] = (counts[fileInfo.skipped] || 0) + 1;
+        this.decisions.record(nativeFileSystem.toPosix(fileInfo.relativePath), { outcome: 'excluded', stage: 'analyze', reason: 'content', kind: fileInfo.skipped });
+        return true;
+    }
+
+    /**
+     * Whether a file opts out of the context with //ctx:ignore (v3.4.0)
+     * Counts it, and keeps the warnings of its directives for the report.
+     * @param {Object} fileInfo - From analyzeFile()
+     * @returns {boolean}
+     */
+    isDirectiveIgnored(fileInfo) {
+        const { directives } = fileInfo;
+        if (!directives) return false;
+
+        for (const warning of directives.warnings) {
+            this.directiveWarnings.push(`${fileInfo.relativePath}:${warning.line}: ${warning.message}`);
+        }
+        if (directives.ignore) {
+            this.stats.directiveIgnoredFiles++;
+            this.decisions.record(nativeFileSystem.toPosix(fileInfo.relativePath), { outcome: 'excluded', stage: 'analyze', reason: 'directive' });
+            return true;
+        }
+        return false;
+    }
+
+    /**
+     * Analyze files on a pool of worker threads (v3.4.0)
+     * Results and stats are recorded in input order, so output matches
+     * analyzeFiles() regardless of which worker finishes first.
+     * @param {Array<string>} files - Absolute paths
+     * @param {number} jobs - Worker threads
+     * @returns {Promise<Array>} File analyses; analyzed here when options.transforms
+     *   has custom steps
+     */
+    async analyzeFilesParallel(files, jobs = this.options.jobs || 1) {
+        // Transforms registered in this thread do not exist on workers
+        if (this.options.transforms?.hasCustom()) {
+            return this.analyzeFiles(files);
+        }
+        const batchSize = Math.max(1, Math.min(MAX_BATCH_SIZE, Math.ceil(files.length / (jobs * 4))));
+        const batches = [];
+        for (let i = 0; i < files.length; i += batchSize) {
+            batches.push({ type: 'analyze', files: files.slice(i, i + batchSize) });
+        }
+
+        const budget = this.options.tokenBudget;
+        const pool = new WorkerPool(new URL('./analyze-worker.js', import.meta.url), {
+            size: Math.min(jobs, batches.length),
+            workerData: {
+                projectRoot: this.projectRoot,
+                options: { methodLevel: this.options.methodLevel, profile: this.options.profile || null, notebookOutputs: this.options.notebookOutputs, includeGenerated: this.options.includeGenerated, readMode: this.options.readMode },
+                workspace: this.options.workspace ? this.options.workspace.repos : null,
+                strip: this.options.stripper
+                    ? { mode: this.options.stripper.options.mode, keepDocs: this.options.stripper.options.keepDocs }
+                    : null,
+                licenses: Boolean(this.options.licenseDetector),
+                transforms: this.options.transforms ? this.options.transforms.steps : null,
+                budget: budget
+                    ? { maxTokens: budget.options.maxTokens, tokenizer: budget.options.tokenizer, model: budget.options.model }
+                    : null,
+                cacheTokens: this.contentCache ? this.contentCache.snapshotTokens() : null,
+                calibration: TokenUtils.getEstimator().calibration
+            }
+        });
+
+        let results;
+        try {
+            results = (await pool.map(batches)).flat();
+            if (this.contentCache) {
+                for (const collected of await pool.broadcast({ type: 'collect' })) {
+                    this.contentCache.mergeTokens(collected.tokens, collected.stats);
+                }
+            }
+        } finally {
+            await pool.close();
+        }
+
+        const analysisResults = [];
+        for (const { fileInfo, methodStats } of results) {
+            this.methodStats.totalMethods += methodStats.totalMethods;
+            this.methodStats.includedMethods += methodStats.includedMethods;
+            Object.assign(this.methodStats.methodTokens, methodStats.methodTokens);
+
+            this.recordFailure(fileInfo);
+            if (this.isDirectiveIgnored(fileInfo) || this.isContentSkipped(fileInfo)) continue;
+            this.updateStats(fileInfo);
+            analysisResults.push(fileInfo);
+        }
+
+        this.stats.largestFiles.sort((a, b) => b.tokens - a.tokens || comparePaths(a.relativePath, b.relativePath));
+        return analysisResults;
+    }
+
+    /**
+     * Narrow analyzed files to what gets exported (v3.4.0)
+     * A semantic query, diff scope, symbol selection or dependency expansion
+     * keeps the requested code; the token budget then keeps what fits.
+     * @param {Array} analysisResults
+     * @returns {Array|null|Promise<Array|null>} Files to export, or null when the focus or a symbol
+     *   is not found; a promise of them when comments are translated or files summarized remotely
+     */
+    selectExportResults(analysisResults) {
+        let exportResults = analysisResults;
+        if (this.options.query) {
+            // Chunks are packed into the budget by relevance
+            return this.applyQueryScope(analysisResults);
+        }
+        if (this.options.diff) {
+            exportResults = this.applyDiffScope(analysisResults);
+        } else if (this.options.apiDiff) {
+            exportResults = this.applyApiDiffScope(analysisResults);
+        } else if (this.options.snippet) {
+            exportResults = this.applySnippetEnrichment(analysisResults);
+        } else if (this.options.symbols && this.options.symbols.length > 0) {
+            exportResults = this.applySymbolSelection(analysisResults);
+        } else if (this.options.focus) {
+            exportResults = this.applyDependencyExpansion(analysisResults);
+        } else if (this.options.pick) {
+            exportResults = this.applyPickedSelection(analysisResults);
+        }
+
+        if (exportResults && this.options.lineSelection) {
+            exportResults = this.applyLineSelection(exportResults, analysisResults);
+        }
+        if (exportResults && this.options.pairTests) {
+            exportResults = this.applyTestPairing(exportResults, analysisResults);
+        }
+        if (exportResults && this.options.usageExamples) {
+            exportResults = this.applyUsageExamples(exportResults, analysisResults);
+        }
+        if (exportResults && this.options.docs) {
+            exportResults = this.applyDocumentation(exportResults, analysisResults);
+        }
+        if (exportResults && this.options.codeOwners) {
+            exportResults = this.applyOwnership(exportResults);
+        }
+        if (exportResults && this.options.licenseDetector) {
+            exportResults = this.applyLicenseFiles(exportResults);
+        }
+        if (exportResults && this.options.commentTranslator) {
+            // Everything after reads the translated comments
+            return this.applyCommentTranslation(exportResults).then(translated => this.compressExportResults(translated, analysisResults));
+        }
+        return exportResults ? this.compressExportResults(exportResults, analysisResults) : exportResults;
+    }
+
+    /**
+     * Elide, score, deduplicate, summarize and pack selected files
+     * @param {Array} exportResults
+     * @param {Array} analysisResults - All analyzed files
+     * @returns {Array|null|Promise<Array|null>} See selectExportResults()
+     */
+    compressExportResults(exportResults, analysisResults) {
+        if (exportResults && this.options.bodyElider) {
+            exportResults = this.applyBodyElision(exportResults);
+        }
+        if (exportResults && this.options.priorityScorer) {
+            exportResults = this.applyPriorityScores(exportResults, analysisResults);
+        }
+        if (exportResults && this.options.duplicateDetector) {
+            exportResults = this.applyDeduplication(exportResults);
+        }
+        if (exportResults && this.options.session) {
+            exportResults = this.applySessionDelta(exportResults);
+        }
+        if (exportResults && this.options.remoteSummarizer) {
+            // The budget packs files once their summaries are in
+            return this.applyRemoteSummaries(exportResults).then(summarized => this.applyBudget(summarized));
+        }
+        return exportResults ? this.applyBudget(exportResults) : exportResults;
+    }
+
+    /**
+     * Pack selected files into the token budget, or simulate several budgets
+     * @param {Array} exportResults
+     * @returns {Array|null} Files to export; null for a simulation, which exports nothing
+     */
+    applyBudget(exportResults) {
+        if (this.options.budgetSimulator) return this.applyBudgetSimulation(exportResults);
+        return this.options.tokenBudget ? this.applyTokenBudget(exportResults) : exportResults;
+    }
+
+    /**
+     * Keep only the focus symbols and the definitions they depend on (v3.4.0)
+     * Selected files carry selectedSymbols; the focus file ranks highest.
+     * @param {Array} analysisResults
+     * @returns {Array|null} Files selected for export, or null when the focus is not found
+     */
+    applyDependencyExpansion(analysisResults) {
+        const { graph, byPath } = this.buildDependencyGraph(analysisResults);
+        const expander = new DependencyExpander(graph, { implementations: this.options.includeImplementations, lsp: this.options.lsp });
+
+        const focus = expander.resolveFocus(this.options.focus);
+        if (focus.length === 0) {
+            if (!this.options.dashboard) {
+                console.error(`❌ Focus not found: ${this.options.focus} (expected a symbol, file:symbol or file path)`);
+            }
+            return null;
+        }
+
+        this.expansion = expander.expand(focus, this.options.expandDeps || 0);
+        if (!this.options.dashboard) {
+            console.log(DependencyExpander.formatExpansion(this.expansion));
+        }
+
+        return this.expansion.files.map(({ file, depth, symbols }) => {
+            const fileInfo = { ...byPath.get(file), priority: 100 - depth };
+
+            // A whole-file focus exports the file unchanged
+            if (symbols.some(symbol => symbol.kind === SymbolKind.MODULE)) {
+                return fileInfo;
+            }
+
+            const lines = graph.getFile(file).content.split('\n');
+            const selectedText = symbols.map(symbol => lines.slice(symbol.startLine - 1, symbol.endLine).join('\n')).join('\n\n');
+
+            return {
+                ...fileInfo,
+                tokens: this.calculateTokens(selectedText, file),
+                selectedSymbols: symbols.map(symbol => ({
+                    name: symbol.qualifiedName,
+                    kind: symbol.kind,
+                    startLine: symbol.startLine,
+                    endLine: symbol.endLine
+                })),
+                selectionNote: depth === 0 ? `Focus ${this.options.focus}` : `Dependencies of ${this.options.focus}`
+            };
+        });
+    }
+
+    /**
+     * Keep only the requested symbols, with the imports and receiver or
+     * enclosing types they need to read on their own (v3.4.0)
+     * @param {Array} analysisResults
+     * @param {Array<string>} specs - Symbols to select (default: options.symbols)
+     * @returns {Array|null} Files selected for export, or null when a symbol is not found
+     */
+    applySymbolSelection(analysisResults, specs = this.options.symbols) {
+        const { graph, byPath } = this.buildDependencyGraph(analysisResults);
+
+        this.selection = new SymbolSlicer(graph, {
+            implementations: this.options.includeImplementations,
+            exportedMembers: this.options.exportedMethods
+        }).slice(specs);
+        if (this.selection.missing.length > 0) {
+            if (!this.options.dashboard) {
+                console.error(`❌ Symbol not found: ${this.selection.missing.join(', ')} (expected pkg/path.Type.member, file:symbol, a symbol name or a symbol ID)`);
+            }
+            return null;
+        }
+        if (!this.options.dashboard) {
+            console.log(SymbolSlicer.formatSlice(this.selection));
+        }
+
+        return this.selection.files.map(({ file, ranges }) => {
+            const fileInfo = byPath.get(file);
+
+            // A whole-file selection exports the file unchanged
+            if (ranges.some(range => range.kind === SymbolKind.MODULE)) {
+                return fileInfo;
+            }
+
+            return {
+                ...fileInfo,
+                tokens: this.calculateTokens(SymbolSlicer.excerpt(graph.getFile(file).content, ranges), file),
+                selectedSymbols: ranges,
+                selectionNote: 'Selected symbols'
+            };
+        });
+    }
+
+    /**
+     * Narrow the files of pinned slices to their lines, adding them when the
+     * selection left them out (v3.4.0, --pin FILE:START-END, --pin FILE#REGION)
+     * Symbols a file was already narrowed to are kept next to its slices.
+     * @param {Array} exportResults
+     * @param {Array} analysisResults
+     * @returns {Array|null} Files selected for export, or null when a slice is not found
+     */
+    applyLineSelection(exportResults, analysisResults) {
+        const { lineSelection } = this.options;
+        const toPosix = file => file.split(path.sep).join('/');
+        const analyzed = new Map(analysisResults.filter(fileInfo => !fileInfo.error).map(fileInfo => [toPosix(fileInfo.relativePath), fileInfo]));
+        const selected = new Map(exportResults.map(fileInfo => [toPosix(fileInfo.relativePath), fileInfo]));
+        const fail = message => {
+            if (!this.options.dashboard) {
+                console.error(`❌ ${message}`);
+            }
+            return null;
+        };
+
+        for (const file of lineSelection.files()) {
+            const fileInfo = selected.get(file) || analyzed.get(file);
+            if (!fileInfo || fileInfo.error) {
+                return fail(`Pinned file not found: ${file} (expected a path relative to the project root)`);
+            }
+
+            const content = this.readFile(fileInfo.path);
+            let ranges;
+            try {
+                ranges = lineSelection.rangesOf(file, content.split('\n').length, fileInfo.directives?.regions);
+            } catch (error) {
+                return fail(error.message);
+            }
+            const symbols = fileInfo.selectedSymbols || [];
+            const slices = ranges.filter(range => !symbols.some(symbol =>
+                symbol.startLine <= range.startLine && symbol.endLine >= range.endLine));
+            const selectedSymbols = [...symbols, ...slices].sort((a, b) => a.startLine - b.startLine);
+
+            selected.set(file, {
+                ...fileInfo,
+                tokens: this.calculateTokens(SymbolSlicer.excerpt(content, selectedSymbols), file),
+                selectedSymbols,
+                selectionNote: fileInfo.selectedSymbols ? `${fileInfo.selectionNote || 'Selected symbols'} and pinned lines` : 'Pinned lines',
+                selectionUnit: fileInfo.selectedSymbols ? 'ranges' : 'slices',
+                pinned: true,
+                pinnedBy: lineSelection.selectorsOf(file).join(', ')
+            });
+            if (!this.options.dashboard) {
+                console.log(`📌 Pinned ${file}: ${ranges.map(range => `${range.kind === 'region' ? `${range.name} ` : ''}(lines ${range.startLine}-${range.endLine})`).join(', ')}`);
+            }
+        }
+        return [...selected.values()];
+    }
+
+    /**
+     * Keep the definitions a snippet or stack trace mentions (v3.4.0, ctxman enrich)
+     * Frames select the function around their line, or a few lines outside
+     * any; names select the symbols they define. Frame files rank highest.
+     * @param {Array} analysisResults
+     * @returns {Array|null} Files selected for export, or null when nothing resolves
+     */
+    applySnippetEnrichment(analysisResults) {
+        const { graph, byPath } = this.buildDependencyGraph(analysisResults);
+        const resolver = this.options.snippetResolver || new SnippetResolver();
+
+        this.snippetResolution = resolver.resolve(graph, this.options.snippet.text);
+        const { frames, symbols } = this.snippetResolution;
+        if (!this.options.dashboard) {
+            console.log(SnippetResolver.formatResolution(this.snippetResolution));
+        }
+        if (symbols.length === 0 && !frames.some(frame => frame.file)) {
+            if (!this.options.dashboard) {
+                console.error('❌ Nothing in the snippet names a project file or symbol');
+            }
+            return null;
+        }
+
+        const slice = new SymbolSlicer(graph, { members: false }).slice(symbols);
+        const ranges = new Map(slice.files.map(({ file, ranges: fileRanges }) => [file, fileRanges]));
+        for (const frame of frames.filter(entry => entry.file && !entry.symbol)) {
+            const fileRanges = ranges.get(frame.file) || [];
+            if (!fileRanges.some(range => range.startLine <= frame.startLine && range.endLine >= frame.endLine)) {
+                fileRanges.push({ name: `line ${frame.line}`, kind: 'lines', startLine: frame.startLine, endLine: frame.endLine });
+            }
+            ranges.set(frame.file, fileRanges);
+        }
+
+        const frameFiles = new Set(frames.map(frame => frame.file).filter(Boolean));
+        return [...ranges].map(([file, fileRanges]) => {
+            const selectedSymbols = [...fileRanges].sort((a, b) => a.startLine - b.startLine);
+            return {
+                ...byPath.get(file),
+                priority: frameFiles.has(file) ? 100 : 90,
+                tokens: this.calculateTokens(SymbolSlicer.excerpt(graph.getFile(file).content, selectedSymbols), file),
+                selectedSymbols,
+                selectionNote: frameFiles.has(file) ? 'In the stack trace' : 'Mentioned in the snippet'
+            };
+        });
+    }
+
+    /**
+     * Keep the files and symbols picked in the context selector (v3.4.0)
+     * options.pick is { files, symbols }: whole files by path, plus symbol IDs
+     * of files that were only partly selected.
+     * @param {Array} analysisResults
+     * @returns {Array|null} Files selected for export, or null when a symbol is gone
+     */
+    applyPickedSelection(analysisResults) {
+        const files = new Set(this.options.pick.files);
+        const selected = analysisResults.filter(fileInfo => files.has(fileInfo.relativePath.split(path.sep).join('/')));
+        if (this.options.pick.symbols.length === 0) {
+            return selected;
+        }
+
+        const sliced = this.applySymbolSelection(analysisResults, this.options.pick.symbols);
+        return sliced && [...selected, ...sliced.filter(fileInfo => !files.has(fileInfo.relativePath.split(path.sep).join('/')))];
+    }
+
+    /**
+     * Keep only the symbols touched by a diff plus the definitions they use (v3.4.0)
+     * options.diff is { base, changes } with changes from DiffAnalyzer.getChangedLines().
+     * @param {Array} analysisResults
+     * @returns {Array} Files selected for export (changed files rank highest)
+     */
+    applyDiffScope(analysisResults) {
+        const { base, changes } = this.options.diff;
+        const { graph, byPath } = this.buildDependencyGraph(analysisResults);
+
+        const touched = DiffAnalyzer.mapChangesToSymbols(changes, graph);
+        const expansion = new DependencyExpander(graph, { implementations: this.options.includeImplementations, lsp: this.options.lsp })
+            .expand(touched.symbols, this.options.expandDeps ?? 1);
+        const slice = new SymbolSlicer(graph, { members: false })
+            .slice([...expansion.focus, ...expansion.dependencies].map(entry => entry.symbol));
+
+        this.diffScope = { base, changes, touched, expansion };
+        if (!this.options.dashboard) {
+            console.log(DiffAnalyzer.formatDiffScope(this.diffScope));
+        }
+
+        const changedAt = new Set(touched.symbols.map(symbol => `${symbol.file}#${symbol.startLine}`));
+        const wholeFiles = new Set(touched.wholeFiles);
+        const selected = touched.wholeFiles
+            .filter(file => byPath.has(file))
+            .map(file => ({ ...byPath.get(file), priority: 100 }));
+
+        for (const { file, ranges } of slice.files) {
+            if (wholeFiles.has(file)) continue;
+
+            const labeled = ranges.map(range => (range.role === SliceRole.SELECTED
+                ? { ...range, role: changedAt.has(`${file}#${range.startLine}`) ? 'changed' : 'dependency' }
+                : range));
+            const changed = labeled.some(range => range.role === 'changed');
+
+            selected.push({
+                ...byPath.get(file),
+                priority: changed ? 100 : 99,
+                tokens: this.calculateTokens(SymbolSlicer.excerpt(graph.getFile(file).content, ranges), file),
+                selectedSymbols: labeled,
+                selectionNote: changed ? `Changed since ${base || 'HEAD'}` : 'Dependencies of changes'
+            });
+        }
+
+        return selected.sort((a, b) => b.priority - a.priority);
+    }
+
+    /**
+     * Keep the exported symbols that changed since a revision plus their call sites (v3.4.0)
+     * options.apiDiff is { base, head, symbols } with the exported symbols at
+     * the base revision (ApiDiff.exportedSymbols()); the analyzed files are the head.
+     * A call site exports its innermost enclosing definition, or its line at top level;
+     * import statements are not call sites.
+     * @param {Array} analysisResults
+     * @returns {Array|null} Files selected for export (changed APIs rank highest), or null without changes
+     */
+    applyApiDiffScope(analysisResults) {
+        const { base, head, symbols: baseSymbols } = this.options.apiDiff;
+        const { graph, byPath } = this.buildDependencyGraph(analysisResults);
+        const apiDiff = new ApiDiff();
+        const headSymbols = apiDiff.exportedSymbols(graph);
+        const changes = apiDiff.compare(baseSymbols, headSymbols);
+
+        // Definitions at the head of everything but removed symbols
+        const changedAt = new Set(changes.filter(entry => entry.change !== 'removed').map(entry => `${entry.file}\0${entry.name}`));
+        const changed = headSymbols.filter(symbol => changedAt.has(`${symbol.file}\0${symbol.qualifiedName}`));
+        const references = new CrossReferenceIndex().build(graph, [...graph.files.keys()].map(file => ({ path: file, ranges: null })), changed);
+        // Imports name the symbols without calling them; the slices keep the ones used
+        const preambles = new Map();
+        const inPreamble = ({ file, line }) => {
+            if (!preambles.has(file)) {
+                const node = graph.getFile(file);
+                preambles.set(file, node.plugin.extractPreamble(node.content, file));
+            }
+            return preambles.get(file).some(range => range.startLine <= line && range.endLine >= line);
+        };
+        const callSites = references.flatMap(entry => entry.references).filter(site => !inPreamble(site));
+
+        this.apiDiffScope = { base, head, changes, callSites };
+        if (!this.options.dashboard) {
+            console.log(ApiDiff.format(this.apiDiffScope));
+        }
+        if (changes.length === 0) {
+            if (!this.options.dashboard) {
+                console.log(`\n✅ No exported API changes since ${base}`);
+            }
+            return null;
+        }
+
+        const callers = callSites.map(({ file, line }) => graph.getFile(file).symbols
+            .filter(symbol => symbol.kind !== SymbolKind.MODULE && symbol.startLine <= line && symbol.endLine >= line)
+            .sort((a, b) => (a.endLine - a.startLine) - (b.endLine - b.startLine))[0] ||
+            { name: 'call site', qualifiedName: 'call site', kind: 'region', file, startLine: line, endLine: line, parent: null });
+        const slice = new SymbolSlicer(graph, { members: false, implementations: this.options.includeImplementations })
+            .slice([...changed, ...callers]);
+
+        const definedAt = new Set(changed.map(symbol => `${symbol.file}#${symbol.startLine}`));
+        const selected = [];
+        for (const { file, ranges } of slice.files) {
+            const labeled = ranges.map(range => (range.role === SliceRole.SELECTED
+                ? { ...range, role: definedAt.has(`${file}#${range.startLine}`) ? 'changed' : 'call site' }
+                : range));
+            const defines = labeled.some(range => range.role === 'changed');
+
+            selected.push({
+                ...byPath.get(file),
+                priority: defines ? 100 : 99,
+                tokens: this.calculateTokens(SymbolSlicer.excerpt(graph.getFile(file).content, labeled), file),
+                selectedSymbols: labeled,
+                selectionNote: defines ? `API changed since ${base}` : 'Call sites of changed APIs'
+            });
+        }
+
+        return selected.sort((a, b) => b.priority - a.priority);
+    }
+
+    /**
+     * Keep the chunks most relevant to a semantic query (v3.4.0)
+     * options.query is { text, provider, stats, chunks, limit } with chunks
+     * ranked by SemanticIndex.search(). With a token budget, chunks are added
+     * best first while they fit; otherwise the top `limit` chunks are kept.
+     * @param {Array} analysisResults
+     * @returns {Array} Files selected for export (best match first)
+     */
+    applyQueryScope(analysisResults) {
+        const { text, provider, stats, chunks, limit } = this.options.query;
+        const budget = this.options.tokenBudget;
+        const files = analysisResults.filter(fileInfo => !fileInfo.error);
+        const byPath = new Map(files.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+        const contents = new Map();
+
+        const lineRange = chunk => {
+            if (!contents.has(chunk.file)) {
+                contents.set(chunk.file, this.readFile(byPath.get(chunk.file).path).split('\n'));
+            }
+            return contents.get(chunk.file).slice(chunk.startLine - 1, chunk.endLine).join('\n');
+        };
+
+        const candidates = chunks.filter(chunk => byPath.has(chunk.file));
+        const maxChunks = limit || (budget ? candidates.length : 10);
+        const picked = [];
+        let usedTokens = 0;
+        let skipped = 0;
+
+        for (const chunk of candidates) {
+            if (picked.length >= maxChunks) break;
+
+            const tokens = this.calculateTokens(lineRange(chunk), chunk.file);
+            if (budget && tokens > budget.limit - usedTokens) {
+                skipped++;
+                continue;
+            }
+            picked.push({ ...chunk, tokens });
+            usedTokens += tokens;
+        }
+
+        this.queryScope = {
+            query: text,
+            provider,
+            stats,
+            chunks: picked,
+            skipped,
+            budget: budget ? { limit: budget.limit, usedTokens, tokenizer: budget.getTokenizerName() } : null
+        };
+        if (!this.options.dashboard) {
+            console.log(SemanticIndex.formatQuery(this.queryScope));
+        }
+
+        const byFile = new Map();
+        for (const chunk of picked) {
+            if (!byFile.has(chunk.file)) byFile.set(chunk.file, []);
+            byFile.get(chunk.file).push(chunk);
+        }
+
+        // Best match first; priority keeps that order through later packing
+        return [...byFile.entries()].map(([file, fileChunks], rank) => {
+            const selectedSymbols = fileChunks
+                .sort((a, b) => a.startLine - b.startLine)
+                .map(chunk => ({
+                    name: chunk.name,
+                    kind: chunk.kind,
+                    startLine: chunk.startLine,
+                    endLine: chunk.endLine,
+                    score: chunk.score
+                }));
+            let tokens = fileChunks.reduce((sum, chunk) => sum + chunk.tokens, 0);
+
+            // Chunks keep the imports they use while the budget allows
+            const imports = this.importsFor(byPath.get(file), selectedSymbols);
+            if (imports && (!budget || imports.tokens <= budget.limit - usedTokens)) {
+                selectedSymbols.unshift(imports);
+                tokens += imports.tokens;
+                usedTokens += imports.tokens;
+            }
+
+            return {
+                ...byPath.get(file),
+                priority: 100 - rank,
+                tokens,
+                selectedSymbols,
+                selectionNote: `Relevant to "${text}"`
+            };
+        });
+    }
+
+    /**
+     * Package clause and imports an excerpt of a file uses (v3.4.0)
+     * Statements binding several names keep only those the excerpt references.
+     * @param {Object} fileInfo
+     * @param {Array} ranges - Excerpt ranges, in line order
+     * @returns {Object|null} Context entry with its tokens, or null when none is needed
+     */
+    importsFor(fileInfo, ranges) {
+        const relativePath = fileInfo.relativePath.split(path.sep).join('/');
+        if (!this.importExtractor) {
+            this.importExtractor = this.options.symbolExtractor || new SymbolExtractor({ backend: 'heuristic' });
+        }
+        const plugin = this.importExtractor.getPlugin(relativePath);
+        if (!plugin) return null;
+
+        const preamble = ImportPruner.preamble({
+            content: this.readSource(fileInfo),
+            path: relativePath,
+            plugin,
+            language: plugin.getLanguageId(relativePath)
+        }, ranges);
+        if (!preamble) return null;
+
+        return {
+            name: 'imports',
+            kind: SliceRole.PREAMBLE,
+            startLine: preamble.startLine,
+            endLine: preamble.endLine,
+            lines: preamble.lines,
+            role: SliceRole.PREAMBLE,
+            context: true,
+            tokens: this.calculateTokens(preamble.lines.join('\n'), fileInfo.path)
+        };
+    }
+
+    /**
+     * Add the tests of selected source files and the sources of selected tests (v3.4.0)
+     * Tests are often excluded by ignore rules, so pairs are also looked up on disk
+     * next to the selected files and in the project's test directories. With
+     * options.pairTests 'names', added tests are reduced to their test names.
+     * @param {Array} exportResults - Files selected so far
+     * @param {Array} analysisResults - All analyzed files
+     * @returns {Array} Selected files with their pairs after them
+     */
+    applyTestPairing(exportResults, analysisResults) {
+        const mode = this.options.pairTests;
+        const toPosix = file => file.split(path.sep).join('/');
+        const byPath = new Map(analysisResults.filter(fileInfo => !fileInfo.error)
+            .map(fileInfo => [toPosix(fileInfo.relativePath), fileInfo]));
+        const selected = exportResults.filter(fileInfo => !fileInfo.error);
+        const selectedPaths = new Set(selected.map(fileInfo => toPosix(fileInfo.relativePath)));
+        const pairing = new TestPairing([...byPath.keys(), ...this.findTestCandidates(selectedPaths)]);
+
+        const pairs = [];
+        const unpaired = [];
+        const added = new Map();
+        for (const fileInfo of selected) {
+            const file = toPosix(fileInfo.relativePath);
+            const found = pairing.pairsOf(file);
+            if (found.length === 0 && !TestPairing.isTestFile(file) && this.isCodeFile(fileInfo.path)) {
+                unpaired.push(file);
+            }
+
+            for (const pair of found) {
+                const isNew = !selectedPaths.has(pair) && !added.has(pair);
+                pairs.push({ file, pair, added: isNew });
+                if (!isNew) continue;
+
+                const pairInfo = byPath.get(pair) || this.analyzeFile(this.absolutePathOf(pair));
+                if (pairInfo.error || pairInfo.skipped || pairInfo.directives?.ignore) continue;
+
+                const entry = {
+                    ...pairInfo,
+                    ...(fileInfo.priority !== undefined ? { priority: fileInfo.priority - 1 } : {}),
+                    pairedWith: file,
+                    selectionNote: TestPairing.isTestFile(pair) ? `Tests of ${file}` : `Source of ${file}`
+                };
+                if (mode === 'names' && TestPairing.isTestFile(pair)) {
+                    entry.summary = TestPairing.summary(this.readSource(pairInfo), pair);
+                    entry.tokens = this.calculateTokens(entry.summary, pair);
+                    entry.selectionNote = `Test names of ${file}`;
+                }
+                added.set(pair, { after: fileInfo, entry });
+            }
+        }
+
+        this.testPairing = { mode, pairs, unpaired };
+        if (!this.options.dashboard) {
+            console.log(TestPairing.formatPairing(this.testPairing));
+        }
+
+        const result = [];
+        for (const fileInfo of exportResults) {
+            result.push(fileInfo);
+            for (const { after, entry } of added.values()) {
+                if (after === fileInfo) result.push(entry);
+            }
+        }
+        return result;
+    }
+
+    /**
+     * Add call sites of the selected symbols as usage examples (v3.4.0, --usage-examples)
+     * The symbols are those of --symbol or the focus of --focus. Uses are
+     * searched in every analyzed file and in the tests next to the symbols;
+     * files selected whole already show theirs, other files gain the snippets.
+     * @param {Array} exportResults - Files selected so far
+     * @param {Array} analysisResults - All analyzed files
+     * @returns {Array} Selected files, then files added for their examples
+     */
+    applyUsageExamples(exportResults, analysisResults) {
+        const toPosix = file => file.split(path.sep).join('/');
+        const targets = this.selection?.symbols || this.expansion?.focus.map(entry => entry.symbol) || [];
+        const analyzed = analysisResults.filter(fileInfo => !fileInfo.error);
+        const analyzedPaths = new Set(analyzed.map(fileInfo => toPosix(fileInfo.relativePath)));
+
+        // Tests are often excluded by ignore rules, as for --pair-tests
+        const tests = [...new Set(this.findTestCandidates(new Set(targets.map(symbol => symbol.file))))]
+            .filter(file => TestPairing.isTestFile(file) && !analyzedPaths.has(file) && this.isCodeFile(file))
+            .map(file => this.analyzeFile(this.absolutePathOf(file)))
+            .filter(fileInfo => !fileInfo.error && !fileInfo.skipped && !fileInfo.directives?.ignore);
+        const searched = [...analyzed, ...tests];
+        const { graph, byPath } = this.buildDependencyGraph(searched);
+
+        this.usageExamples = this.options.usageExamples.find(graph, [...byPath.keys()], targets);
+        if (!this.options.dashboard) {
+            console.log(UsageExamples.formatExamples(this.usageExamples));
+        }
+
+        const selected = new Map(exportResults.map(fileInfo => [toPosix(fileInfo.relativePath), fileInfo]));
+        const added = new Map();
+        for (const { symbol, examples } of this.usageExamples) {
+            for (const example of examples) {
+                const fileInfo = selected.get(example.file) || added.get(example.file) || byPath.get(example.file);
+                // Whole files and summaries show the use already, or stand in for it
+                if (selected.has(example.file) && (!fileInfo.selectedSymbols || fileInfo.summary)) continue;
+
+                const symbols = fileInfo.selectedSymbols || [];
+                if (symbols.some(range => range.startLine <= example.startLine && range.endLine >= example.endLine)) continue;
+                const usage = { name: symbol.qualifiedName, kind: 'usage', startLine: example.startLine, endLine: example.endLine };
+                const selectedSymbols = [...symbols, usage].sort((a, b) => a.startLine - b.startLine);
+                const entry = {
+                    ...fileInfo,
+                    tokens: this.calculateTokens(SymbolSlicer.excerpt(graph.getFile(example.file).content, selectedSymbols), example.file),
+                    selectedSymbols
+                };
+                if (selected.has(example.file)) {
+                    const note = fileInfo.selectionNote || 'Selected symbols';
+                    selected.set(example.file, {
+                        ...entry,
+                        selectionNote: note.endsWith(' and usage examples') ? note : `${note} and usage examples`,
+                        selectionUnit: 'ranges'
+                    });
+                } else {
+                    const names = [...new Set(selectedSymbols.map(range => range.name))];
+                    added.set(example.file, { ...entry, selectionNote: `Usage examples of ${names.join(', ')}`, selectionUnit: 'usages' });
+                }
+            }
+        }
+        return [...selected.values(), ...added.values()];
+    }
+
+    /**
+     * Test and source files on disk (or at options.revision) that the scan may have skipped (v3.4.0)
+     * @private
+     */
+    findTestCandidates(selectedPaths) {
+        const dirs = new Set();
+        for (const file of selectedPaths) {
+            const dir = path.posix.dirname(file);
+            dirs.add(dir);
+            for (const testDir of TEST_DIRS) dirs.add(path.posix.join(dir, testDir));
+        }
+
+        const { revision } = this.options;
+        if (revision) {
+            return revision.listFiles()
+                .map(({ relativePath }) => relativePath)
+                .filter(file => dirs.has(path.posix.dirname(file)) ||
+                    (TEST_DIRS.includes(file.split('/')[0]) && !file.split('/').includes('node_modules')));
+        }
+
+        const candidates = [];
+        for (const dir of dirs) {
+            try {
+                for (const entry of fs.readdirSync(this.absolutePathOf(dir), { withFileTypes: true })) {
+                    if (entry.isFile()) candidates.push(path.posix.join(dir, entry.name));
+                }
+            } catch {
+                // No such directory
+            }
+        }
+
+        // Top-level test directories mirror the source tree
+        for (const testDir of TEST_DIRS) {
+            const root = path.join(this.projectRoot, testDir);
+            if (!fs.existsSync(root)) continue;
+            for (const entry of fs.readdirSync(root, { recursive: true, withFileTypes: true })) {
+                if (!entry.isFile()) continue;
+                const file = path.relative(this.projectRoot, path.join(entry.parentPath ?? entry.path, entry.name)).split(path.sep).join('/');
+                if (!file.split('/').includes('node_modules')) candidates.push(file);
+            }
+        }
+        return candidates;
+    }
+
+    /**
+     * Collapse near-duplicate files to one representative (v3.4.0)
+     * Only whole files are compared; files trimmed to symbols or summaries by
+     * an earlier selection keep their place. Representatives list the copies
+     * they stand for in duplicates.
+     * @param {Array} exportResults
+     * @returns {Array} Files without the collapsed copies
+     */
+    applyDeduplication(exportResults) {
+        const detector = this.options.duplicateDetector;
+        const toPosix = file => file.split(path.sep).join('/');
+        const candidates = exportResults.filter(fileInfo => !fileInfo.error && !fileInfo.summary && !fileInfo.selectedSymbols);
+        const byPath = new Map(candidates.map(fileInfo => [toPosix(fileInfo.relativePath), fileInfo]));
+
+        const groups = detector.findDuplicates([...byPath].map(([file, fileInfo]) => ({
+            path: file,
+            content: this.readFile(fileInfo.path),
+            priority: fileInfo.priority
+        })));
+
+        const omitted = new Set();
+        const representatives = new Map();
+        let savedTokens = 0;
+        for (const group of groups) {
+            representatives.set(group.representative, group.duplicates);
+            for (const duplicate of group.duplicates) {
+                omitted.add(duplicate.path);
+                savedTokens += byPath.get(duplicate.path).tokens;
+                this.compression?.record('dedupe', byPath.get(duplicate.path), 0);
+            }
+        }
+
+        this.deduplication = { groups, savedTokens, threshold: detector.options.threshold };
+        if (!this.options.dashboard && groups.length > 0) {
+            console.log(DuplicateDetector.formatReport(this.deduplication));
+        }
+
+        return exportResults
+            .filter(fileInfo => !omitted.has(toPosix(fileInfo.relativePath)))
+            .map(fileInfo => {
+                const duplicates = representatives.get(toPosix(fileInfo.relativePath));
+                return duplicates ? { ...fileInfo, duplicates } : fileInfo;
+            });
+    }
+
+    /**
+     * Put project docs at the top of the context and link code to them (v3.4.0, --docs)
+     * READMEs, ARCHITECTURE.md, ADRs and doc.go files rank above code; a
+     * selection also gets the project overview and the docs of the code it
+     * selected. In summary mode docs shrink to their title, opening paragraph
+     * and outline.
+     * @param {Array} exportResults
+     * @param {Array} analysisResults - All analyzed files (docs outside the selection)
+     * @returns {Array} Files with documentation and docLinks
+     */
+    applyDocumentation(exportResults, analysisResults) {
+        const toPosix = file => file.split(path.sep).join('/');
+        const byPath = new Map(analysisResults.filter(fileInfo => !fileInfo.error).map(fileInfo => [toPosix(fileInfo.relativePath), fileInfo]));
+        const docs = new ProjectDocs([...byPath.keys()], { read: file => this.readSource(byPath.get(file)) });
+
+        const results = exportResults.map(fileInfo => {
+            const links = fileInfo.error ? [] : docs.linksOf(toPosix(fileInfo.relativePath));
+            return links.length > 0 ? { ...fileInfo, docLinks: links } : fileInfo;
+        });
+
+        // The overview and the docs of selected code join a narrower selection
+        const selected = new Set(results.map(fileInfo => toPosix(fileInfo.relativePath)));
+        for (const file of new Set([...docs.overview(), ...results.flatMap(fileInfo => fileInfo.docLinks || [])])) {
+            if (selected.has(file)) continue;
+            selected.add(file);
+            results.push({ ...byPath.get(file), selectionNote: 'Project documentation' });
+        }
+
+        return results.map(fileInfo => {
+            const file = toPosix(fileInfo.relativePath);
+            const rank = fileInfo.error ? -1 : docs.rankOf(file);
+            if (rank === -1) return fileInfo;
+
+            const doc = {
+                ...fileInfo,
+                documentation: { kind: ProjectDocs.kindOf(file), rank },
+                // Fractions keep the docs in their order within the budget
+                priority: fileInfo.priority ?? DOCS_PRIORITY - rank / docs.docs.length
+            };
+            if (this.options.docs === 'summary' && !doc.summary && !doc.selectedSymbols) {
+                doc.summary = ProjectDocs.summarize(this.readSource(doc), file);
+                doc.tokens = this.calculateTokens(doc.summary, doc.path);
+                doc.selectionNote = 'Documentation summary';
+            }
+            return doc;
+        });
+    }
+
+    /**
+     * Name the CODEOWNERS owners of each file (v3.4.0, --owner, --owners)
+     * @param {Array} exportResults
+     * @returns {Array} Files with owners; an empty list for unowned files
+     */
+    applyOwnership(exportResults) {
+        return exportResults.map(fileInfo => (fileInfo.error ? fileInfo : {
+            ...fileInfo,
+            owners: this.options.codeOwners.ownersOf(fileInfo.relativePath.split(path.sep).join('/'))
+        }));
+    }
+
+    /**
+     * Keep signatures and doc comments but elide long bodies (v3.4.0, --elide-bodies)
+     * Files already narrowed to symbols or summaries, pinned files and docs keep
+     * their content, so bodies only appear where the selection put them.
+     * Elided files carry their content as a summary with the new token count.
+     * @param {Array} exportResults
+     * @returns {Array} Files with elided bodies
+     */
+    applyBodyElision(exportResults) {
+        const elider = this.options.bodyElider;
+        let savedTokens = 0;
+        let bodies = 0;
+
+        const results = exportResults.map(fileInfo => {
+            if (fileInfo.error || fileInfo.summary || fileInfo.selectedSymbols || fileInfo.pinned || fileInfo.documentation) {
+                return fileInfo;
+            }
+            const elided = elider.elide(this.readSource(fileInfo), fileInfo.relativePath);
+            if (!elided) return fileInfo;
+
+            const tokens = this.calculateTokens(elided.content, fileInfo.path);
+            savedTokens += fileInfo.tokens - tokens;
+            bodies += elided.bodies;
+            this.compression?.record('elide', fileInfo, tokens);
+            return {
+                ...fileInfo,
+                tokens,
+                summary: elided.content,
+                selectionNote: `${elided.bodies} ${elided.bodies === 1 ? 'body' : 'bodies'} elided (${elided.lines} lines)`
+            };
+        });
+
+        this.elision = { bodies, savedTokens };
+        if (!this.options.dashboard && bodies > 0) {
+            console.log(`✂️  Elided ${bodies} function ${bodies === 1 ? 'body' : 'bodies'}, saving ${savedTokens.toLocaleString()} tokens`);
+        }
+        return results;
+    }
+
+    /**
+     * Leave out what earlier turns of a conversation supplied (v3.4.0, --session)
+     * Files supplied unchanged are omitted, changed files keep their changed
+     * regions, partial files the symbols not supplied yet. Runs before the
+     * token budget, so the budget packs the delta.
+     * @param {Array} exportResults
+     * @returns {Array} New and changed files
+     */
+    applySessionDelta(exportResults) {
+        const { session } = this.options;
+        const turn = session.beginTurn();
+        const delta = { name: session.name, turn, added: 0, changed: 0, omitted: [], removed: session.removed(this.projectRoot), savedTokens: 0 };
+
+        const results = [];
+        for (const fileInfo of exportResults) {
+            if (fileInfo.error || fileInfo.summary) {
+                results.push(fileInfo);
+                continue;
+            }
+            const content = this.readSource(fileInfo);
+            const { status, turn: suppliedIn, ranges } = session.compare(fileInfo, content);
+            if (status === 'unchanged') {
+                delta.omitted.push({ file: fileInfo.relativePath, turn: suppliedIn });
+                delta.savedTokens += fileInfo.tokens;
+                this.compression?.record('session', fileInfo, 0);
+                continue;
+            }
+            delta[status === 'changed' ? 'changed' : 'added']++;
+            if (!ranges) {
+                results.push({ ...fileInfo, session: status });
+                continue;
+            }
+
+            const tokens = this.calculateTokens(SymbolSlicer.excerpt(content, ranges), fileInfo.path);
+            delta.savedTokens += fileInfo.tokens - tokens;
+            this.compression?.record('session', fileInfo, tokens);
+            results.push({
+                ...fileInfo,
+                tokens,
+                session: status,
+                selectedSymbols: ranges,
+                ...(status === 'changed' ? { selectionNote: `Changed since turn ${suppliedIn}`, selectionUnit: 'regions' } : {})
+            });
+        }
+
+        this.sessionDelta = delta;
+        if (!this.options.dashboard) {
+            console.log(ContextSession.formatDelta(delta));
+        }
+        return results;
+    }
+
+    /**
+     * Remember what this turn exported in its session, and save it (v3.4.0, --session)
+     * @param {Array} exportResults - Files exported
+     */
+    recordSession(exportResults) {
+        const { session } = this.options;
+        for (const fileInfo of exportResults) {
+            session.record(fileInfo, this.readSource(fileInfo));
+        }
+        session.forget(this.sessionDelta?.removed || []);
+        const sessionPath = session.save(this.projectRoot, this.options.now || new Date());
+        if (!this.options.dashboard) {
+            console.log(`🔁 Session ${session.name} saved (turn ${session.turn}): ${this.displayPath(sessionPath)}`);
+        }
+    }
+
+    /**
+     * Add this run's packing decisions to the coverage history (v3.4.0, ctxman report coverage)
+     * Analyzed files that were not exported count as excluded.
+     * @param {Array} exportResults
+     */
+    recordCoverage(exportResults) {
+        const exported = new Map(exportResults.map(fileInfo => [fileInfo.relativePath, fileInfo]));
+        const analyzed = (this.analyzedResults || []).filter(fileInfo => !fileInfo.error);
+        const files = [...new Map([...analyzed, ...exportResults].map(fileInfo => [fileInfo.relativePath, fileInfo])).values()]
+            .map(fileInfo => {
+                const packed = exported.get(fileInfo.relativePath);
+                return {
+                    path: nativeFileSystem.toPosix(fileInfo.relativePath),
+                    tokens: fileInfo.tokens,
+                    status: !packed ? 'excluded' : packed.summary ? 'summary' : packed.selectedSymbols ? 'partial' : 'full'
+                };
+            });
+
+        try {
+            const history = CoverageHistory.load(this.projectRoot);
+            history.record({
+                files,
+                ignored: this.ignoredPaths,
+                profile: this.options.profile?.name || null,
+                mode: this.selectionMode().mode
+            }, this.options.now || new Date());
+            history.save();
+        } catch (error) {
+            // The history is a report aid; a broken one never fails the run
+            if (!this.options.dashboard) {
+                console.log(`⚠️  Coverage history not recorded: ${error.message}`);
+            }
+        }
+    }
+
+    /**
+     * Write why each file was or was not included, for ctxman explain (v3.4.0)
+     * Analyzed files that were not exported are settled by the stage that left
+     * them out, checked from the last stage back; exported ones by how they were packed.
+     * @param {Array} exportResults
+     */
+    recordDecisions(exportResults) {
+        const toPosix = file => nativeFileSystem.toPosix(file);
+        const plan = this.budgetPlan || null;
+        const budgetItems = new Map(plan ? [...plan.partial, ...plan.summarized, ...plan.dropped].map(item => [item.id, item]) : []);
+        const dropped = new Set(plan ? plan.dropped.map(item => item.id) : []);
+        const duplicates = new Map((this.deduplication?.groups || [])
+            .flatMap(group => group.duplicates.map(duplicate => [duplicate.path, group.representative])));
+        const supplied = new Map((this.sessionDelta?.omitted || []).map(entry => [toPosix(entry.file), entry.turn]));
+        const selection = this.selectionMode();
+        const mode = selection.mode === 'all' && this.options.apiDiff ? 'api-diff'
+            : selection.mode === 'all' && this.options.pick ? 'pick' : selection.mode;
+
+        const exported = new Map(exportResults.map(fileInfo => [fileInfo.relativePath, fileInfo]));
+        const analyzed = (this.analyzedResults || []).filter(fileInfo => !fileInfo.error);
+        for (const fileInfo of new Map([...analyzed, ...exportResults].map(fileInfo => [fileInfo.relativePath, fileInfo])).values()) {
+            if (fileInfo.error) continue;
+            const file = toPosix(fileInfo.relativePath);
+            const packed = exported.get(fileInfo.relativePath);
+            const item = budgetItems.get(fileInfo.relativePath);
+            const score = this.priorityScores?.get(fileInfo.relativePath);
+            const facts = {
+                tokens: item?.fullTokens ?? item?.tokens ?? (packed || fileInfo).tokens,
+                priority: item?.priority ?? this.exportPriority(packed || fileInfo),
+                ...(score ? { signals: Object.fromEntries(Object.entries(score.signals).filter(([, signal]) => signal.weight !== 0).map(([name, signal]) => [name, signal.points])) } : {})
+            };
+
+            if (packed) {
+                const outcome = packed.summary ? 'summarized' : packed.selectedSymbols ? 'partial' : 'included';
+                this.decisions.record(file, {
+                    outcome,
+                    stage: plan ? 'budget' : 'select',
+                    reason: 'packed',
+                    ...facts,
+                    tokens: packed.tokens,
+                    analyzedTokens: fileInfo.tokens,
+                    ...(packed.selectionNote ? { note: packed.selectionNote } : {}),
+                    ...(packed.summaryTier ? { tier: packed.summaryTier } : {})
+                });
+            } else if (dropped.has(fileInfo.relativePath)) {
+                this.decisions.record(file, { outcome: 'excluded', stage: 'budget', reason: 'budget', ...facts, available: item.available });
+            } else if (duplicates.has(file)) {
+                this.decisions.record(file, { outcome: 'excluded', stage: 'transform', reason: 'duplicate', of: duplicates.get(file), ...facts });
+            } else if (supplied.has(file)) {
+                this.decisions.record(file, { outcome: 'excluded', stage: 'transform', reason: 'session', session: this.sessionDelta.name, turn: supplied.get(file), ...facts });
+            } else if (this.options.licenseDetector && LicenseDetector.isLicenseFile(fileInfo.relativePath)) {
+                this.decisions.record(file, { outcome: 'excluded', stage: 'transform', reason: 'license-file', ...facts });
+            } else {
+                this.decisions.record(file, { outcome: 'excluded', stage: 'select', reason: 'selection', mode, target: selection.target, ...facts });
+            }
+        }
+
+        const packedWhole = plan ? plan.included.map(entry => entry.priority) : [];
+        this.decisions.run = {
+            at: (this.options.now || new Date()).toISOString(),
+            root: this.projectRoot,
+            profile: this.options.profile?.name || null,
+            mode,
+            target: selection.target,
+            transforms: this.options.transforms?.describe() || null,
+            budget: plan ? {
+                limit: plan.limit,
+                usedTokens: plan.usedTokens,
+                tokenizer: plan.tokenizer,
+                exhaustedAt: plan.exhaustedAt ? { ...plan.exhaustedAt, id: toPosix(plan.exhaustedAt.id) } : null,
+                cutoff: packedWhole.length > 0 ? Math.min(...packedWhole) : null
+            } : null
+        };
+
+        try {
+            this.decisions.save(this.decisionLogPath());
+        } catch (error) {
+            this.errors.add('export', nativeFileSystem.toPosix(DECISION_FILE), error);
+        }
+    }
+
+    /**
+     * Translate non-English comments of the selected files (v3.4.0, --translate-comments)
+     * Translations keep line numbers, so symbol ranges stay valid. Whole files
+     * are counted again; files narrowed to symbols or lines keep their counts.
+     * Text is sent redacted when --redact is on.
+     * @param {Array} exportResults
+     * @returns {Promise<Array>} Files with translated comments recounted
+     */
+    async applyCommentTranslation(exportResults) {
+        const translator = this.options.commentTranslator;
+        const { redactor } = this.options;
+        if (redactor) {
+            translator.options.redact = (text, file) => redactor.redact(text, file);
+        }
+        const files = exportResults.filter(fileInfo => !fileInfo.error && !fileInfo.summary);
+        const translated = await translator.translateAll(files.map(fileInfo => ({
+            id: fileInfo.relativePath,
+            content: this.readFile(fileInfo.path)
+        })));
+
+        const results = exportResults.map(fileInfo => {
+            const content = translated.get(fileInfo.relativePath);
+            if (content === undefined || fileInfo.selectedSymbols) return fileInfo;
+            return { ...fileInfo, tokens: this.calculateFileTokens(content, fileInfo.path) };
+        });
+
+        this.commentTranslations = { ...translator.stats };
+        if (!this.options.dashboard) {
+            console.log(CommentTranslator.formatReport(translator));
+        }
+        return results;
+    }
+
+    /**
+     * Replace low-priority files with summaries from an LLM endpoint (v3.4.0, --summarize-remote)
+     * Low priority means what the token budget cannot fit whole, or else the
+     * lowest-priority share of files. Small, pinned, already narrowed or
+     * summarized files and docs keep their content, as do files whose
+     * requests fail.
+     * @param {Array} exportResults
+     * @returns {Promise<Array>} Files with remote summaries
+     */
+    async applyRemoteSummaries(exportResults) {
+        const summarizer = this.options.remoteSummarizer;
+        const candidates = this.remoteSummaryCandidates(exportResults);
+        const summaries = await summarizer.summarizeAll(candidates.map(fileInfo => ({
+            id: fileInfo.relativePath,
+            content: this.readSource(fileInfo)
+        })));
+
+        let savedTokens = 0;
+        let files = 0;
+        const results = exportResults.map(fileInfo => {
+            const summary = candidates.includes(fileInfo) && summaries.get(fileInfo.relativePath);
+            if (!summary) return fileInfo;
+
+            const tokens = this.calculateTokens(summary, fileInfo.path);
+            if (tokens >= fileInfo.tokens) return fileInfo;
+            savedTokens += fileInfo.tokens - tokens;
+            files++;
+            this.compression?.record('summarize', fileInfo, tokens);
+            return {
+                ...fileInfo,
+                tokens,
+                summary,
+                selectionNote: `Summarized by ${summarizer.options.model}`
+            };
+        });
+
+        this.remoteSummaries = { files, savedTokens, cost: summarizer.cost(), ...summarizer.stats };
+        if (!this.options.dashboard && candidates.length > 0) {
+            console.log(RemoteSummarizer.formatReport(summarizer, savedTokens));
+        }
+        return results;
+    }
+
+    /**
+     * Files applyRemoteSummaries() sends, lowest priority first
+     * @param {Array} exportResults
+     * @returns {Array}
+     */
+    remoteSummaryCandidates(exportResults) {
+        const { share, minTokens } = this.options.remoteSummarizer.options;
+        const budget = this.options.tokenBudget;
+        const files = exportResults.filter(fileInfo => !fileInfo.error);
+        const eligible = files.filter(fileInfo => !fileInfo.summary && !fileInfo.selectedSymbols &&
+            !fileInfo.pinned && !fileInfo.documentation && fileInfo.tokens >= minTokens);
+        const byPriority = [...eligible].sort((a, b) => this.exportPriority(a) - this.exportPriority(b));
+
+        if (budget && share === null) {
+            // Without symbol or summary fallbacks the plan drops what does not fit whole
+            const { dropped } = budget.plan(files.map(fileInfo => ({
+                id: fileInfo.relativePath,
+                tokens: fileInfo.tokens,
+                priority: this.exportPriority(fileInfo)
+            })));
+            const overflow = new Set(dropped.map(item => item.id));
+            return byPriority.filter(fileInfo => overflow.has(fileInfo.relativePath));
+        }
+        return byPriority.slice(0, Math.ceil(eligible.length * (share ?? DEFAULT_SUMMARY_SHARE)));
+    }
+
+    /**
+     * Priority a file is packed by: its own, pinned, or by path
+     * @param {Object} fileInfo
+     * @returns {number}
+     */
+    exportPriority(fileInfo) {
+        return fileInfo.priority ?? (fileInfo.pinned ? PINNED_PRIORITY : TokenBudget.filePriority(fileInfo.relativePath));
+    }
+
+    /**
+     * Rank files with the priority scoring engine (v3.4.0)
+     * Scores stand in for the path heuristic; priorities already set by a
+     * profile rule or a selection mode are kept.
+     * @param {Array} exportResults
+     * @param {Array} analysisResults - All analyzed files (for import fan-in)
+     * @returns {Array} Files with a priority
+     */
+    applyPriorityScores(exportResults, analysisResults) {
+        const scorer = this.options.priorityScorer;
+        const context = {};
+        if (scorer.uses('churn')) {
+            context.churn = PriorityScorer.churnOf(this.projectRoot, scorer.options.churnDays);
+        }
+        const graph = scorer.uses('fanIn') || scorer.uses('entry') ? this.buildDependencyGraph(analysisResults).graph : null;
+        if (scorer.uses('fanIn')) {
+            context.fanIn = PriorityScorer.fanInOf(graph);
+        }
+        if (scorer.uses('entry')) {
+            this.entryPoints = new EntryPointDetector().detect(analysisResults
+                .filter(fileInfo => !fileInfo.error)
+                .map(fileInfo => ({ path: fileInfo.relativePath.split(path.sep).join('/'), content: this.readOriginal(fileInfo.path) })));
+            context.entry = EntryPointDetector.withDependencies(graph, this.entryPoints);
+        }
+        if (scorer.uses('risk') && this.options.riskOverlay) {
+            this.risk = this.options.riskOverlay.forFiles(exportResults
+                .filter(fileInfo => !fileInfo.error)
+                .map(fileInfo => fileInfo.relativePath.split(path.sep).join('/')));
+            context.risk = this.risk;
+        }
+
+        this.priorityScores = scorer.score(exportResults.filter(fileInfo => !fileInfo.error), context);
+
+        const scored = exportResults.map(fileInfo => (!fileInfo.error && fileInfo.priority === undefined
+            ? { ...fileInfo, priority: this.priorityScores.get(fileInfo.relativePath).score }
+            : fileInfo));
+
+        if (this.options.entryPoints && this.entryPoints && !this.options.dashboard) {
+            console.log(EntryPointDetector.format(this.entryPoints));
+        }
+        if (context.risk && !this.options.dashboard) {
+            console.log(RiskOverlay.format(this.options.riskOverlay, context.risk));
+        }
+        if (this.options.explain && !this.options.dashboard) {
+            console.log(PriorityScorer.formatExplanation(scored, this.priorityScores));
+        }
+        return scored;
+    }
+
+    /**
+     * Dependency graph over analyzed files (v3.4.0)
+     * @param {Array} analysisResults
+     * @returns {{graph: DependencyGraph, byPath: Map<string, Object>}}
+     */
+    buildDependencyGraph(analysisResults) {
+        let files = analysisResults.filter(fileInfo => !fileInfo.error);
+        const byPath = new Map(files.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+
+        // Symbol lines must match the stripped contents and notebook text that get exported
+        const { stripper, licenseDetector, transforms, notebookOutputs } = this.options;
+        if (stripper || licenseDetector || transforms) {
+            files = files.map(fileInfo => ({ ...fileInfo, content: this.readFile(fileInfo.path) }));
+        } else {
+            files = files.map(fileInfo => (this.isNotebook(fileInfo.path)
+                ? { ...fileInfo, content: this.readFile(fileInfo.path) }
+                : fileInfo));
+        }
+
+        const build = () => new DependencyGraph({
+            root: this.projectRoot,
+            extractor: this.options.symbolExtractor,
+            cache: this.contentCache,
+            workspace: this.options.workspace,
+            revision: this.options.revision,
+            reader: this.fileReader,
+            onError: (file, error) => this.errors.add('symbols', file, error)
+        }).build(files);
+        const { index, symbolBackend } = this.options;
+        let key = stripper ? `${symbolBackend || 'auto'}:${stripper.getKey()}` : symbolBackend || 'auto';
+        if (licenseDetector) key += `+${licenseDetector.getKey()}`;
+        if (transforms) key += `+${transforms.getKey()}`;
+        if (notebookOutputs) key += '+outputs';
+        const graph = index ? index.graph(key, files, build) : build();
+
+        return { graph, byPath };
+    }
+
+    /**
+     * Pack analyzed files into the token budget (v3.4.0)
+     * Files that do not fit whole keep only the symbols that do (selectedSymbols)
+     * or a signatures/names summary (summary), depending on the budget tiers.
+     * @param {Array} analysisResults
+     * @returns {Array} Files selected for export
+     */
+    applyTokenBudget(analysisResults) {
+        if (this.options.pinRebalancer) {
+            analysisResults = this.rebalancePins(analysisResults);
+        }
+        const { items, byPath, callbacks } = this.budgetItems(analysisResults);
+        this.budgetPlan = this.options.tokenBudget.plan(items, callbacks);
+
+        if (!this.options.dashboard) {
+            console.log(TokenBudget.formatPlan(this.budgetPlan));
+        }
+
+        const selected = this.budgetPlan.included.map(item => byPath.get(item.id));
+        if (this.compression) {
+            const { partial, summarized, dropped } = this.budgetPlan;
+            [...partial, ...summarized].forEach(item => this.compression.record('budget', byPath.get(item.id), item.tokens));
+            dropped.forEach(item => this.compression.record('budget', byPath.get(item.id), 0));
+        }
+        for (const item of this.budgetPlan.partial) {
+            selected.push({
+                ...byPath.get(item.id),
+                tokens: item.tokens,
+                selectedSymbols: item.symbols,
+                selectionNote: 'Trimmed to fit token budget'
+            });
+        }
+        for (const item of this.budgetPlan.summarized) {
+            selected.push({
+                ...byPath.get(item.id),
+                tokens: item.tokens,
+                selectedSymbols: null,
+                summary: item.summary,
+                summaryTier: item.tier,
+                selectionNote: `Summarized to fit token budget (${item.tier})`
+            });
+        }
+        return selected;
+    }
+
+    /**
+     * Degrade pinned files that exceed the budget on their own until they fit
+     * (v3.4.0, --rebalance-pins)
+     * Rebalanced files carry their content as a summary with the new token
+     * count; files narrowed to symbols or lines are left to the budget tiers.
+     * @param {Array} exportResults
+     * @returns {Array} Files with rebalanced pins
+     */
+    rebalancePins(exportResults) {
+        const rebalancer = this.options.pinRebalancer;
+        const { limit } = this.options.tokenBudget;
+        const scorer = this.options.priorityScorer;
+        const isPinned = fileInfo => fileInfo.pinned || Boolean(scorer?.pinOf(fileInfo.relativePath.split(path.sep).join('/')));
+
+        this.pinRebalancing = [];
+        const results = exportResults.map(fileInfo => {
+            if (fileInfo.error || fileInfo.selectedSymbols || fileInfo.tokens <= limit || !isPinned(fileInfo)) {
+                return fileInfo;
+            }
+            const file = fileInfo.relativePath.split(path.sep).join('/');
+            const result = rebalancer.rebalance(fileInfo.summary ?? this.readSource(fileInfo), file, limit);
+            this.pinRebalancing.push({ file, limit, ...result });
+            if (!result.fits) return fileInfo;
+
+            this.compression?.record('rebalance', fileInfo, result.tokens);
+            return {
+                ...fileInfo,
+                tokens: result.tokens,
+                summary: result.content,
+                selectionNote: `Pinned; ${PinRebalancer.describeSteps(result.steps)} to fit token budget`
+            };
+        });
+
+        if (!this.options.dashboard && this.pinRebalancing.length > 0) {
+            console.log(PinRebalancer.formatReport(this.pinRebalancing));
+        }
+        return results;
+    }
+
+    /**
+     * Pack analyzed files at every budget of `ctxman simulate` (v3.4.0)
+     * @param {Array} analysisResults
+     * @returns {null} Nothing is exported
+     */
+    applyBudgetSimulation(analysisResults) {
+        const simulator = this.options.budgetSimulator;
+        const { items, callbacks } = this.budgetItems(analysisResults);
+        this.budgetSimulation = simulator.simulate(this.options.tokenBudget, items, callbacks);
+        if (!this.options.dashboard) {
+            console.log(simulator.format(this.budgetSimulation));
+        }
+        return null;
+    }
+
+    /**
+     * Budget items of analyzed files and the plan callbacks that trim them
+     * @private
+     */
+    budgetItems(analysisResults) {
+        const budget = this.options.tokenBudget;
+        const files = analysisResults.filter(fileInfo => !fileInfo.error);
+        const byPath = new Map(files.map(fileInfo => [fileInfo.relativePath, fileInfo]));
+
+        const items = files.map(fileInfo => ({
+            id: fileInfo.relativePath,
+            tokens: fileInfo.tokens,
+            priority: this.exportPriority(fileInfo),
+            ...this.directoryTiers(fileInfo)
+        }));
+
+        // Already narrowed files can only shrink to symbols within the selection
+        const withinSelection = fileInfo => fileInfo.selectedSymbols
+            ? symbol => fileInfo.selectedSymbols.some(selected =>
+                symbol.startLine >= selected.startLine && symbol.endLine <= selected.endLine)
+            : null;
+
+        const callbacks = {
+            expand: item => {
+                const fileInfo = byPath.get(item.id);
+                return budget.symbolsFor(this.readSource(fileInfo), item.id, withinSelection(fileInfo));
+            },
+            summarize: (item, tier) => {
+                const fileInfo = byPath.get(item.id);
+                // Paired tests shrink to their test names at any summary tier
+                if (fileInfo.pairedWith && TestPairing.isTestFile(item.id.split(path.sep).join('/'))) {
+                    return TestPairing.summary(this.readSource(fileInfo), item.id);
+                }
+                return budget.summaryFor(this.readSource(fileInfo), item.id, tier, withinSelection(fileInfo));
+            },
+            // Trimmed files keep the imports their symbols use
+            context: (item, symbols) => this.importsFor(byPath.get(item.id), symbols)
+        };
+        return { items, byPath, callbacks };
+    }
+
+    /**
+     * Budget tiers a .context.yaml sets for a file, as budget item fields (v3.4.0)
+     * @private
+     */
+    directoryTiers(fileInfo) {
+        const configPath = this.directoryConfigPath(fileInfo.path);
+        const tiers = configPath === null ? null : this.directoryConfig.tiersOf(configPath);
+        return tiers ? { tiers } : {};
+    }
+
+    printHeader() {
+        console.log('🔍 Analyzing project: ' + this.projectRoot);
+        console.log('📝 Respecting .gitignore rules...');
+
+        const configPath = this.gitIgnore.hasIncludeFile ?
+            ConfigUtils.findConfigFile(this.projectRoot, '.contextinclude') :
+            ConfigUtils.findConfigFile(this.projectRoot, '.contextignore');
+
+        if (configPath) {
+            const mode = this.gitIgnore.hasIncludeFile ? 'INCLUDE' : 'EXCLUDE';
+            console.log(`📅 Found calculator config - using ${mode} mode`);
+            console.log('   Location: ' + path.relative(this.projectRoot, configPath));
+        }
+
+        const calibration = TokenUtils.getEstimator().calibration;
+        console.log('🎯 Token calculation: ' + (TokenUtils.hasExactCounting()
+            ? '✅ Exact (using tiktoken)'
+            : `⚠️  Estimated${calibration ? ` (calibrated to ${calibration.target})` : ''}`));
+
+        if (this.options.methodLevel) {
+            console.log('🔧 Method-level analysis: ✅ Enabled');
+            if (this.methodFilter) {
+                const hasInclude = this.methodFilter.hasIncludeFile;
+                const mode = hasInclude ? 'INCLUDE' : 'EXCLUDE';
+                console.log(`   Method filtering: ${mode} mode active`);
+            }
+        }
+
+        console.log();
+    }
+
+    printScanResults(allFiles) {
+        console.log(`📁 Found ${allFiles.length} text files to analyze`);
+        console.log(`🚫 Ignored ${this.stats.ignoredFiles} files due to .gitignore rules`);
+        if (this.skippedListEntries?.length > 0) {
+            const shown = this.skippedListEntries.slice(0, 5).join(', ');
+            const more = this.skippedListEntries.length > 5 ? `, … ${this.skippedListEntries.length - 5} more` : '';
+            console.log(`📝 Skipped ${this.skippedListEntries.length} listed paths (deleted, binary or outside the project): ${shown}${more}`);
+        }
+        if (this.stats.calculatorIgnoredFiles > 0) {
+            const mode = this.gitIgnore.hasIncludeFile ? 'include rules' : 'ignore rules';
+            console.log(`📋 Filtered ${this.stats.calculatorIgnoredFiles} additional files due to calculator ${mode}`);
+        }
+        if (this.options.sparse) {
+            const skipped = this.sparseSkipped > 0 ? `, skipped ${this.sparseSkipped} paths outside it` : '';
+            console.log(`🌿 Sparse checkout (${this.options.sparse.describe()})${skipped}`);
+        }
+        if (this.ownedFiles) {
+            const { owners, kept, scanned, source } = this.ownedFiles;
+            console.log(`👥 Owned by ${owners.join(', ')}: ${kept} of ${scanned} files (${source})`);
+        }
+        const configDirs = this.directoryConfig.directories();
+        if (configDirs.length > 0) {
+            const shown = configDirs.slice(0, 5).map(dir => dir || '.').join(', ');
+            const more = configDirs.length > 5 ? `, … ${configDirs.length - 5} more` : '';
+            console.log(`🗂️  Directory settings (.context.yaml): ${shown}${more}`);
+        }
+
+        if (this.options.methodLevel) {
+            console.log(`🔧 Total methods found: ${this.methodStats.totalMethods}`);
+            console.log(`✅ Methods included: ${this.methodStats.includedMethods}`);
+        }
+
+        console.log();
+    }
+
+    printReport() {
+        console.log('='.repeat(80));
+        console.log('🎯 PROJECT TOKEN ANALYSIS REPORT');
+        console.log('='.repeat(80));
+        console.log(`📊 Total files analyzed: ${this.stats.totalFiles.toLocaleString()}`);
+        console.log(`🔢 Total tokens: ${this.stats.totalTokens.toLocaleString()}`);
+        console.log(`💾 Total size: ${(this.stats.totalBytes / 1024 / 1024).toFixed(2)} MB`);
+        console.log(`📄 Total lines: ${this.stats.totalLines.toLocaleString()}`);
+
+        const avgTokens = this.stats.totalFiles > 0
+            ? Math.round(this.stats.totalTokens / this.stats.totalFiles).toLocaleString()
+            : 'N/A';
+        console.log(`📈 Average tokens per file: ${avgTokens}`);
+        console.log(`🚫 Files ignored by .gitignore: ${this.stats.ignoredFiles.toLocaleString()}`);
+        if (this.stats.calculatorIgnoredFiles > 0) {
+            console.log(`📋 Files ignored by calculator rules: ${this.stats.calculatorIgnoredFiles.toLocaleString()}`);
+        }
+        if (this.stats.directiveIgnoredFiles > 0) {
+            console.log(`🏷️  Files ignored by ctx:ignore directives: ${this.stats.directiveIgnoredFiles.toLocaleString()}`);
+        }
+        const skippedContent = ContentClassifier.describe(this.stats.skippedContentFiles);
+        if (skippedContent) {
+            console.log(`🗜️  Files skipped by content: ${skippedContent} (--include-generated keeps them)`);
+        }
+        for (const warning of this.directiveWarnings.slice(0, 10)) {
+            console.log(`⚠️  ${warning}`);
+        }
+        if (this.directiveWarnings.length > 10) {
+            console.log(`⚠️  … ${this.directiveWarnings.length - 10} more directive warnings`);
+        }
+        if (this.contentCache) {
+            const cacheStats = this.contentCache.getStats();
+            console.log(`🗄️  Content cache: ${cacheStats.hits.toLocaleString()} hits, ${cacheStats.misses.toLocaleString()} misses (${cacheStats.hitRate}% hit rate)`);
+        }
+        console.log();
+
+        this.printExtensionStats();
+        this.printTopFiles();
+        this.printTopDirectories();
+
+        console.log('\n💡 Tip: Use --save-report flag to save detailed analysis to JSON file');
+    }
+
+    printExtensionStats() {
+        console.log('📋 BY FILE TYPE:');
         console.log('-'.repeat(80));
         console.log('Extension'.padEnd(15) + 'Files'.padStart(8) + 'Tokens'.padStart(12) + 'Size (KB)'.padStart(12) + 'Lines'.padStart(10));
         console.log('-'.repeat(80));
 
         Object.entries(this.stats.byExtension)
-            .sort((a, b) => b[1].tokens - a[1].tokens)
+            .sort((a, b) => b[1].tokens - a[1].tokens || compareBytes(a[0], b[0]))
             .forEach(([ext, data]) => {
                 console.log(
                     ext.padEnd(15) +
@@ -489,7 +3357,7 @@ class TokenCalculator {
     printTopDirectories() {
         const largestDirectories = Object.entries(this.stats.byDirectory)
             .map(([dir, stats]) => ({ directory: dir, ...stats }))
-            .sort((a, b) => b.tokens - a.tokens);
+            .sort((a, b) => b.tokens - a.tokens || compareBytes(a.directory, b.directory));
 
         console.log('\n📁 TOP 5 LARGEST DIRECTORIES BY TOKEN COUNT:');
         console.log('-'.repeat(80));
@@ -502,9 +3370,12 @@ class TokenCalculator {
     }
 
     handleExports(analysisResults) {
-        const { saveReport, contextExport, contextToClipboard, gitingest } = this.options;
+        const { saveReport, contextExport, contextToClipboard, gitingest, structuredFormat, template } = this.options;
 
-        if (contextExport || contextToClipboard || saveReport || gitingest) {
+        if (contextExport || contextToClipboard || saveReport || gitingest || structuredFormat || template) {
+            if (this.options.anonymizer) {
+                this.learnNames(analysisResults);
+            }
             if (contextExport || contextToClipboard) {
                 console.log('\n🤖 Generating LLM Context...');
                 const context = this.generateLLMContext(analysisResults);
@@ -512,8 +3383,10 @@ class TokenCalculator {
                 if (contextToClipboard) {
                     this.exportContextToClipboard(context);
                 } else {
-                    this.saveContextToFile(context);
-                    console.log('💾 LLM context saved to: llm-context.json');
+                    const contextPath = this.saveContextToFile(context);
+                    if (contextPath) {
+                        console.log(`💾 LLM context saved to: ${this.displayPath(contextPath)}`);
+                    }
                 }
             }
 
@@ -521,15 +3394,52 @@ class TokenCalculator {
                 this.saveDetailedReport(analysisResults);
             }
 
-            if (gitingest) {
+            // A template wraps the digest or structured context instead of writing it (v3.4.0)
+            if (template) {
+                console.log(`\n🧩 Rendering template ${template.name}...`);
+                this.saveTemplatedOutput(analysisResults);
+            }
+
+            // One bundle per project of a monorepo (v3.4.0)
+            if (this.options.projectDetector && !template) {
+                console.log('\n🏗️  Generating per-project bundles...');
+                this.saveProjectBundles(analysisResults);
+            }
+
+            if (gitingest && !template && !this.options.projectDetector) {
                 console.log('\n📄 Generating GitIngest digest...');
                 this.saveGitIngestDigest(analysisResults);
             }
+
+            if (structuredFormat && !template && !this.options.projectDetector) {
+                console.log(`\n🧾 Generating structured ${structuredFormat.toUpperCase()} context...`);
+                this.saveStructuredOutput(analysisResults);
+            }
+
+            if (this.options.anonymizer) {
+                const mapPath = this.options.anonymizer.save();
+                console.log(`🕶️  Anonymized ${this.options.anonymizer.describe()}; mapping in ${this.displayPath(mapPath)} (keep it private)`);
+            }
+        } else if (this.options.prompt === false) {
+            // Daemon requests have no terminal to ask on (v3.4.0)
+            console.log('\n✅ Analysis complete. No export selected.');
         } else {
             this.promptForExport(analysisResults);
         }
     }
 
+    /**
+     * Teach the anonymizer the names of the exported files and the project (v3.4.0)
+     * @param {Array} analysisResults - Files selected for export
+     */
+    learnNames(analysisResults) {
+        const { anonymizer } = this.options;
+        anonymizer.learnPath(path.basename(this.projectRoot));
+        for (const fileInfo of analysisResults.filter(fileInfo => !fileInfo.error)) {
+            anonymizer.learn(this.readOriginal(fileInfo.path), fileInfo.relativePath.split(path.sep).join('/'));
+        }
+    }
+
     saveDetailedReport(analysisResults) {
         const report = {
             metadata: {
@@ -539,11 +3449,11 @@ class TokenCalculator {
                 calculatorRules: this.gitIgnore.contextPatterns.map(p => p.original)
             },
             summary: this.stats,
-            files: analysisResults.sort((a, b) => b.tokens - a.tokens)
+            files: analysisResults.sort((a, b) => b.tokens - a.tokens || comparePaths(a.relativePath, b.relativePath))
         };
 
-        const reportPath = path.join(this.projectRoot, 'token-analysis-report.json');
-        fs.writeFileSync(reportPath, JSON.stringify(report, null, 2));
+        const reportPath = path.join(this.outputRoot(), 'token-analysis-report.json');
+        writeFileAtomic(reportPath, JSON.stringify(report, null, 2), this.getOutputTarget().fileOptions());
         console.log('💾 Detailed analysis saved to: token-analysis-report.json');
     }
 
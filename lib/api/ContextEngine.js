@@ -0,0 +1,300 @@
+/**
+ * ContextEngine - Library API for embedding context generation
+ * v3.4.0 - Embedding API
+ *
+ * Responsibilities:
+ * - Run the CLI pipeline in-process, one stage at a time: scan the project,
+ *   analyze files, pack them into a selection (focus, symbols, profile,
+ *   token budget, test pairing) and render that selection in a context format
+ * - Keep no per-call state on the engine, so calls may overlap; they share the
+ *   content cache and symbol extractor only
+ * - Stop any call whose AbortSignal fires, between batches of files and
+ *   between rendered pieces; the call rejects with the signal's reason
+ * - Report through return values and errors, never the console
+ *
+ * Analyses and selections are frozen; a selection can be rendered in several
+ * formats, and an analysis packed with several options.
+ */
+
+import path from 'path';
+import TokenCalculator from '../analyzers/token-calculator.js';
+import TokenBudget, { parseTokenCount } from '../core/TokenBudget.js';
+import ContextProfiles from '../core/ContextProfiles.js';
+import TransformPipeline from '../core/TransformPipeline.js';
+import { PAIR_MODES } from '../core/TestPairing.js';
+import { parseXmlTags } from '../formatters/xml-formatter.js';
+import { parseFenceChar, parseXmlContentMode } from '../formatters/content-escaper.js';
+import ContentCache from '../cache/ContentCache.js';
+import ParseCache from '../cache/ParseCache.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+
+export const RENDER_FORMATS = ['json', 'yaml', 'markdown', 'xml', 'gitingest', 'context'];
+
+// Calculator that packed each selection, for rendering it
+const calculators = new WeakMap();
+
+export class ContextEngine {
+  /**
+   * @param {string} projectRoot
+   * @param {Object} options
+   */
+  constructor(projectRoot, options = {}) {
+    this.projectRoot = path.resolve(projectRoot);
+    this.options = {
+      symbolBackend: 'auto',
+      cache: null, // ContentCache; memory only when not given
+      parseCache: null, // ParseCache bounding the outlines of the memory cache; default size when not given
+      batchSize: 64, // Files analyzed between cancellation checks
+      transforms: null, // TransformPipeline or step list; default: the profile's transforms
+      ...options
+    };
+
+    this.cache = this.options.cache || new ContentCache({
+      root: this.projectRoot,
+      path: null,
+      parseCache: this.options.parseCache || new ParseCache()
+    });
+    this.extractor = new SymbolExtractor({ backend: this.options.symbolBackend });
+    this.ready = null;
+  }
+
+  /**
+   * Files of the project, after .gitignore, .contextignore and .contextinclude
+   * @param {Object} [options] - { signal }
+   * @returns {Promise<Array<string>>} Project-relative paths with / separators
+   */
+  async scan({ signal } = {}) {
+    signal?.throwIfAborted();
+    const calculator = this.createCalculator({});
+    const files = calculator.scanDirectory(this.projectRoot)
+      .map(filePath => path.relative(this.projectRoot, filePath).split(path.sep).join('/'));
+    signal?.throwIfAborted();
+    return files;
+  }
+
+  /**
+   * Token counts, lines and methods of files
+   * @param {Object} [options]
+   * @param {Array<string>} [options.files] - Project-relative paths (default: scan())
+   * @param {string} [options.profile] - context.yaml profile whose priorities apply
+   * @param {AbortSignal} [options.signal]
+   * @returns {Promise<Object>} Analysis { files, stats, profile }
+   */
+  async analyze({ files = null, profile = null, signal } = {}) {
+    await this.initialize(signal);
+    const resolved = profile ? this.resolveProfile(profile) : null;
+    const calculator = this.createCalculator({ profile: resolved });
+    const paths = (files || await this.scan({ signal })).map(file => path.resolve(this.projectRoot, file));
+
+    const results = [];
+    for (let start = 0; start < paths.length; start += this.options.batchSize) {
+      signal?.throwIfAborted();
+      results.push(...calculator.analyzeFiles(paths.slice(start, start + this.options.batchSize)));
+      await new Promise(resolve => setImmediate(resolve));
+    }
+    signal?.throwIfAborted();
+    this.cache.save();
+
+    return Object.freeze({ files: Object.freeze(results), stats: calculator.stats, profile: resolved });
+  }
+
+  /**
+   * Select the files of an analysis for a context, as the CLI export does
+   * @param {Object} analysis - From analyze()
+   * @param {Object} [options]
+   * @param {number|string} [options.maxTokens] - Token budget (8000, '32k'); default: the profile's
+   * @param {string} [options.tokenizer] - Tokenizer of the budget
+   * @param {Array<string>|string} [options.tiers] - Summary tiers (see BUDGET_TIERS), with maxTokens
+   * @param {number|string} [options.reservePrompt] - Tokens of maxTokens kept free for the task prompt
+   * @param {number|string} [options.reserveOutput] - Tokens of maxTokens kept free for the answer
+   * @param {string} [options.focus] - File or file:symbol to expand dependencies from
+   * @param {number} [options.depth] - Dependency depth of focus
+   * @param {Array<string>} [options.symbols] - Symbols to slice
+   * @param {string} [options.pairTests] - Test pairing mode (see PAIR_MODES)
+   * @param {boolean} [options.collapsible] - Collapsible sections when rendered
+   * @param {Object|string} [options.xmlTags] - Renamed XML tags, or a --xml-tags list (see parseXmlTags)
+   * @param {string} [options.fence] - Markdown fence character, backtick or tilde
+   * @param {string} [options.xmlContent] - XML content mode, raw, cdata or entities
+   * @param {AbortSignal} [options.signal]
+   * @returns {Promise<Object>} Selection { files, stats }
+   * @throws {Error} For invalid options and symbols that are not found
+   */
+  async pack(analysis, { signal, ...options } = {}) {
+    await this.initialize(signal);
+    const calculator = this.createCalculator(await this.packOptions(analysis, options));
+    calculator.stats = structuredClone(analysis.stats);
+    signal?.throwIfAborted();
+
+    const files = calculator.selectExportResults([...analysis.files]);
+    if (!files) {
+      throw new Error(options.focus
+        ? `Focus not found: ${options.focus}`
+        : `Symbol not found: ${calculator.selection?.missing?.join(', ')}`);
+    }
+    signal?.throwIfAborted();
+
+    const selection = Object.freeze({ files: Object.freeze(files), stats: calculator.stats });
+    calculators.set(selection, calculator);
+    return selection;
+  }
+
+  /**
+   * Context text of a selection
+   * @param {Object} selection - From pack()
+   * @param {Object} [options] - { format (see RENDER_FORMATS, default json), signal }
+   * @returns {Promise<string>}
+   */
+  async render(selection, { format = 'json', signal } = {}) {
+    const rendered = [];
+    for await (const piece of this.renderPieces(selection, { format, signal })) {
+      rendered.push(piece);
+    }
+    return rendered.join('');
+  }
+
+  /**
+   * Context text of a selection, piece by piece as its formatter produces
+   * it, for streaming; a piece is only rendered once the previous one is taken
+   * @param {Object} selection - From pack()
+   * @param {Object} [options] - { format (see RENDER_FORMATS, default json), signal }
+   * @returns {AsyncGenerator<string>}
+   */
+  async *renderPieces(selection, { format = 'json', signal } = {}) {
+    if (!RENDER_FORMATS.includes(format)) {
+      throw new Error(`Invalid format: ${format} (expected ${RENDER_FORMATS.join(', ')})`);
+    }
+    const calculator = calculators.get(selection);
+    if (!calculator) {
+      throw new Error('Not a selection of this engine (use pack())');
+    }
+    await this.initialize(signal);
+
+    if (format === 'context') {
+      yield JSON.stringify(calculator.generateLLMContext([...selection.files]), null, 2);
+      return;
+    }
+    const pieces = format === 'gitingest'
+      ? calculator.createGitIngestFormatter([...selection.files]).generatePieces()
+      : calculator.createStructuredFormatter([...selection.files]).encodePieces(format);
+
+    let count = 0;
+    for (const piece of pieces) {
+      signal?.throwIfAborted();
+      yield piece;
+      if (++count % this.options.batchSize === 0) {
+        await new Promise(resolve => setImmediate(resolve));
+      }
+    }
+    signal?.throwIfAborted();
+  }
+
+  /**
+   * Scan, analyze, pack and render in one call
+   * @param {Object} [options] - Options of pack(), plus format, profile and signal
+   * @returns {Promise<string>}
+   */
+  async generate({ format = 'json', profile = null, signal, ...options } = {}) {
+    const analysis = await this.analyze({ profile, signal });
+    return this.render(await this.pack(analysis, { ...options, signal }), { format, signal });
+  }
+
+  /**
+   * Initialize the symbol extractor once
+   * @private
+   */
+  async initialize(signal) {
+    if (!this.ready) {
+      this.ready = this.extractor.initialize();
+    }
+    await this.ready;
+    signal?.throwIfAborted();
+  }
+
+  /**
+   * TokenCalculator options of pack() options
+   * @private
+   */
+  async packOptions(analysis, options) {
+    const packed = {
+      profile: analysis.profile,
+      focus: options.focus || null,
+      expandDeps: options.depth ?? null,
+      symbols: [].concat(options.symbols || []),
+      tokenBudget: null,
+      collapsible: Boolean(options.collapsible),
+      xmlTags: typeof options.xmlTags === 'string' ? parseXmlTags(options.xmlTags) : options.xmlTags || null,
+      fence: parseFenceChar(options.fence || 'backtick'),
+      xmlContent: parseXmlContentMode(options.xmlContent || 'raw')
+    };
+    if (packed.focus && packed.symbols.length > 0) {
+      throw new Error('symbols and focus cannot be combined');
+    }
+    if (options.pairTests) {
+      if (!PAIR_MODES.includes(options.pairTests)) {
+        throw new Error(`Invalid pairTests: ${options.pairTests} (expected ${PAIR_MODES.join(', ')})`);
+      }
+      packed.pairTests = options.pairTests;
+    }
+
+    let maxTokens = analysis.profile?.budget || null;
+    if (options.maxTokens !== undefined && options.maxTokens !== null) {
+      maxTokens = parseTokenCount(options.maxTokens);
+      if (!maxTokens) {
+        throw new Error(`Invalid maxTokens: ${options.maxTokens} (e.g. 8000, '32k', '1m')`);
+      }
+    }
+    if (options.tiers && !maxTokens) {
+      throw new Error('tiers requires maxTokens');
+    }
+    const reserve = {};
+    for (const name of ['reservePrompt', 'reserveOutput']) {
+      if (options[name] === undefined || options[name] === null) continue;
+      if (!maxTokens) throw new Error(`${name} requires maxTokens`);
+      reserve[name] = parseTokenCount(options[name]);
+      if (!reserve[name]) {
+        throw new Error(`Invalid ${name}: ${options[name]} (e.g. 2000, '8k')`);
+      }
+    }
+    if (maxTokens && (reserve.reservePrompt || 0) + (reserve.reserveOutput || 0) >= maxTokens) {
+      throw new Error(`reservePrompt and reserveOutput leave no room in maxTokens ${maxTokens}`);
+    }
+    if (maxTokens) {
+      packed.tokenBudget = await TokenBudget.create({
+        maxTokens,
+        reserve: reserve.reserveOutput || 0,
+        reservePrompt: reserve.reservePrompt || 0,
+        tokenizer: options.tokenizer || analysis.profile?.tokenizer || 'auto',
+        tiers: options.tiers || null,
+        cache: this.cache
+      });
+    }
+    return packed;
+  }
+
+  /**
+   * @private
+   */
+  resolveProfile(name) {
+    const profiles = ContextProfiles.load(this.projectRoot);
+    if (!profiles) {
+      throw new Error(`No context.yaml in ${this.projectRoot}`);
+    }
+    return profiles.resolve(name);
+  }
+
+  /**
+   * @private
+   */
+  createCalculator(options) {
+    const steps = this.options.transforms ?? options.profile?.transforms;
+    const pipeline = steps instanceof TransformPipeline ? steps : steps ? new TransformPipeline(steps) : null;
+    return new TokenCalculator(this.projectRoot, {
+      ...(pipeline ? pipeline.configure({ ...options }, this.projectRoot) : options),
+      symbolExtractor: this.extractor,
+      cache: this.cache,
+      dashboard: true // No console reports from a library
+    });
+  }
+}
+
+export default ContextEngine;
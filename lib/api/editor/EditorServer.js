@@ -0,0 +1,334 @@
+/**
+ * EditorServer - JSON-RPC server for editor extensions
+ * v3.4.0 - Editor RPC protocol (ctxman editor)
+ *
+ * Responsibilities:
+ * - Speak JSON-RPC 2.0 framed like the Language Server Protocol
+ *   (Content-Length headers), over stdio or a local socket, so Neovim
+ *   (vim.lsp.rpc) and VS Code (vscode-jsonrpc) clients can connect as they
+ *   would to a language server
+ * - Answer outline, selection context and pin requests through the
+ *   ContextEngine, sharing its cache between requests
+ * - Keep the pinned symbols of the server; every selection context
+ *   includes them
+ * - Run requests concurrently and cancel them on $/cancelRequest
+ *
+ * Methods (params -> result):
+ *   Initialize {} -> { name, version, methods }
+ *   GetOutline { file } -> { file, supported, symbols }
+ *   BuildContextForSelection { file, startLine?, endLine?, format?, maxTokens?, profile? }
+ *     -> { symbols, pins, files, tokens, context }
+ *   PinSymbol { symbol } | { file, name } -> { pins }
+ *   UnpinSymbol { symbol } -> { pins }
+ *   ListPins {} -> { pins }
+ * Files are project-relative or absolute paths inside the project; lines
+ * are 1-based. Contexts are built from the files on disk.
+ */
+
+import fs from 'fs';
+import net from 'net';
+import path from 'path';
+import ContextEngine from '../ContextEngine.js';
+import { SymbolProvider } from '../mcp/symbols.js';
+import { getLogger } from '../../utils/logger.js';
+
+const logger = getLogger('EditorServer');
+
+// JSON-RPC 2.0 error codes, and the LSP code for cancelled requests
+export const RPC_ERRORS = {
+  parseError: -32700,
+  invalidRequest: -32600,
+  methodNotFound: -32601,
+  invalidParams: -32602,
+  internalError: -32603,
+  requestCancelled: -32800
+};
+
+const PROTOCOL_VERSION = 1;
+
+/**
+ * Error answered with its JSON-RPC code
+ */
+class RpcError extends Error {
+  constructor(code, message) {
+    super(message);
+    this.code = code;
+  }
+}
+
+export class EditorServer {
+  /**
+   * @param {string} projectRoot
+   * @param {Object} options
+   */
+  constructor(projectRoot, options = {}) {
+    this.projectRoot = path.resolve(projectRoot);
+    this.options = {
+      symbolBackend: 'auto',
+      cache: null, // ContentCache shared by all requests; memory only when not given
+      parseCache: null, // ParseCache bounding the outlines of the memory cache; default size when not given
+      format: 'markdown', // Default format of selection contexts
+      ...options
+    };
+
+    this.engine = new ContextEngine(this.projectRoot, {
+      symbolBackend: this.options.symbolBackend,
+      cache: this.options.cache,
+      parseCache: this.options.parseCache
+    });
+    this.symbols = new SymbolProvider({ extractor: this.engine.extractor, cache: this.engine.cache });
+    this.pins = [];
+    this.pending = new Map();
+    this.methods = {
+      Initialize: () => this.initialize(),
+      GetOutline: params => this.getOutline(params),
+      BuildContextForSelection: (params, signal) => this.buildContextForSelection(params, signal),
+      PinSymbol: (params, signal) => this.pinSymbol(params, signal),
+      UnpinSymbol: params => this.unpinSymbol(params),
+      ListPins: () => ({ pins: [...this.pins] })
+    };
+  }
+
+  /**
+   * Answer one message
+   * @param {Object} message - JSON-RPC request or notification
+   * @returns {Promise<Object|null>} Response, or null for notifications
+   */
+  async handle(message) {
+    if (!message || typeof message !== 'object' || message.jsonrpc !== '2.0' || typeof message.method !== 'string') {
+      return errorResponse(message?.id ?? null, new RpcError(RPC_ERRORS.invalidRequest, 'Invalid request'));
+    }
+
+    const { id, method, params = {} } = message;
+    if (method === '$/cancelRequest') {
+      this.pending.get(params.id)?.abort();
+      return null;
+    }
+    const notification = id === undefined;
+    const handler = Object.hasOwn(this.methods, method) ? this.methods[method] : null;
+    if (!handler) {
+      return notification ? null : errorResponse(id, new RpcError(RPC_ERRORS.methodNotFound, `Unknown method: ${method}`));
+    }
+
+    const controller = new AbortController();
+    if (!notification) this.pending.set(id, controller);
+    try {
+      const result = await handler(params, controller.signal);
+      return notification ? null : { jsonrpc: '2.0', id, result: result ?? null };
+    } catch (error) {
+      if (controller.signal.aborted) {
+        error = new RpcError(RPC_ERRORS.requestCancelled, 'Request cancelled');
+      }
+      logger.debug(`${method} failed: ${error.message}`);
+      return notification ? null : errorResponse(id, error);
+    } finally {
+      this.pending.delete(id);
+    }
+  }
+
+  /**
+   * Serve one connection until its input ends
+   * @param {stream.Readable} input
+   * @param {stream.Writable} output
+   * @returns {Promise<void>} Resolves when the input ends
+   */
+  connect(input, output) {
+    const send = response => {
+      if (response && !output.destroyed) output.write(encodeMessage(response));
+    };
+
+    readMessages(input, body => {
+      let message;
+      try {
+        message = JSON.parse(body);
+      } catch (error) {
+        send(errorResponse(null, new RpcError(RPC_ERRORS.parseError, `Parse error: ${error.message}`)));
+        return;
+      }
+      this.handle(message).then(send);
+    }, error => send(errorResponse(null, new RpcError(RPC_ERRORS.parseError, error.message))));
+
+    return new Promise(resolve => {
+      input.once('end', resolve);
+      input.once('close', resolve);
+    });
+  }
+
+  /**
+   * Serve connections on a unix socket (a named pipe on Windows)
+   * @param {string} socketPath
+   * @returns {Promise<net.Server>}
+   * @throws {Error} When the socket is in use
+   */
+  async listen(socketPath) {
+    const server = net.createServer(socket => {
+      socket.on('error', error => logger.debug(`Editor connection failed: ${error.message}`));
+      this.connect(socket, socket);
+    });
+    await new Promise((resolve, reject) => {
+      server.once('error', error => reject(error.code === 'EADDRINUSE' ? new Error(`Socket in use: ${socketPath}`) : error));
+      server.listen(socketPath, resolve);
+    });
+    logger.debug(`Editor server for ${this.projectRoot} listening on ${socketPath}`);
+    return server;
+  }
+
+  /**
+   * @private
+   */
+  initialize() {
+    return { name: 'ctxman', version: PROTOCOL_VERSION, root: this.projectRoot, methods: Object.keys(this.methods) };
+  }
+
+  /**
+   * @private
+   */
+  async getOutline(params) {
+    const file = this.relativeFile(params.file);
+    if (!fs.existsSync(path.join(this.projectRoot, file))) {
+      throw new RpcError(RPC_ERRORS.invalidParams, `File not found: ${file}`);
+    }
+    return this.symbols.getOutline(this.projectRoot, file);
+  }
+
+  /**
+   * Context of the innermost symbols a selection overlaps (the whole file
+   * when it overlaps none), plus the pinned symbols
+   * @private
+   */
+  async buildContextForSelection(params, signal) {
+    const file = this.relativeFile(params.file);
+    const format = params.format || this.options.format;
+    const outline = await this.getOutline({ file });
+    const startLine = params.startLine ?? null;
+    const endLine = params.endLine ?? startLine;
+    if (startLine !== null && (!Number.isInteger(startLine) || !Number.isInteger(endLine) || endLine < startLine)) {
+      throw new RpcError(RPC_ERRORS.invalidParams, `Invalid selection: lines ${startLine}-${endLine}`);
+    }
+
+    const overlapping = startLine === null ? [] : outline.symbols.filter(symbol =>
+      symbol.startLine <= endLine && symbol.endLine >= startLine);
+    const innermost = overlapping.filter(symbol => !overlapping.some(other => other !== symbol &&
+      other.startLine >= symbol.startLine && other.endLine <= symbol.endLine &&
+      (other.startLine !== symbol.startLine || other.endLine !== symbol.endLine)));
+    const selected = innermost.length > 0 ? [...new Set(innermost.map(symbol => `${file}:${symbol.name}`))] : [file];
+
+    const analysis = await this.engine.analyze({ profile: params.profile || null, signal });
+    const specs = [...new Set([...selected, ...this.pins])];
+    let selection;
+    try {
+      selection = await this.engine.pack(analysis, { symbols: specs, maxTokens: params.maxTokens ?? null, signal });
+    } catch (error) {
+      if (signal.aborted) throw error;
+      throw new RpcError(RPC_ERRORS.invalidParams, error.message);
+    }
+
+    return {
+      symbols: selected,
+      pins: [...this.pins],
+      files: selection.files.map(fileInfo => ({
+        path: fileInfo.relativePath.split(path.sep).join('/'),
+        tokens: fileInfo.tokens,
+        symbols: fileInfo.selectedSymbols?.map(symbol => symbol.name) ?? null
+      })),
+      tokens: selection.files.reduce((sum, fileInfo) => sum + fileInfo.tokens, 0),
+      context: await this.engine.render(selection, { format, signal })
+    };
+  }
+
+  /**
+   * Pin a symbol spec (any form --symbol accepts) once it resolves
+   * @private
+   */
+  async pinSymbol(params, signal) {
+    const spec = params.file && params.name ? `${this.relativeFile(params.file)}:${params.name}` : params.symbol;
+    if (typeof spec !== 'string' || spec === '') {
+      throw new RpcError(RPC_ERRORS.invalidParams, 'PinSymbol needs symbol, or file and name');
+    }
+    if (!this.pins.includes(spec)) {
+      const analysis = await this.engine.analyze({ signal });
+      try {
+        await this.engine.pack(analysis, { symbols: [spec], signal });
+      } catch (error) {
+        if (signal.aborted) throw error;
+        throw new RpcError(RPC_ERRORS.invalidParams, error.message);
+      }
+      this.pins.push(spec);
+    }
+    return { pins: [...this.pins] };
+  }
+
+  /**
+   * @private
+   */
+  unpinSymbol(params) {
+    if (!this.pins.includes(params.symbol)) {
+      throw new RpcError(RPC_ERRORS.invalidParams, `Not pinned: ${params.symbol}`);
+    }
+    this.pins = this.pins.filter(pin => pin !== params.symbol);
+    return { pins: [...this.pins] };
+  }
+
+  /**
+   * Project-relative path of a file parameter
+   * @private
+   */
+  relativeFile(file) {
+    if (typeof file !== 'string' || file === '') {
+      throw new RpcError(RPC_ERRORS.invalidParams, 'Missing file');
+    }
+    const relative = path.relative(this.projectRoot, path.resolve(this.projectRoot, file));
+    if (relative === '' || relative.startsWith('..') || path.isAbsolute(relative)) {
+      throw new RpcError(RPC_ERRORS.invalidParams, `File outside the project: ${file}`);
+    }
+    return relative.split(path.sep).join('/');
+  }
+}
+
+/**
+ * Content-Length framed message
+ * @param {Object} message
+ * @returns {string}
+ */
+export function encodeMessage(message) {
+  const body = JSON.stringify(message);
+  return `Content-Length: ${Buffer.byteLength(body, 'utf8')}\r\n\r\n${body}`;
+}
+
+/**
+ * Call onMessage with the body of each Content-Length framed message
+ * @private
+ */
+function readMessages(input, onMessage, onError) {
+  let buffer = Buffer.alloc(0);
+  input.on('data', chunk => {
+    buffer = Buffer.concat([buffer, typeof chunk === 'string' ? Buffer.from(chunk, 'utf8') : chunk]);
+    for (;;) {
+      const headerEnd = buffer.indexOf('\r\n\r\n');
+      if (headerEnd === -1) return;
+
+      const length = /^content-length:\s*(\d+)\s*$/im.exec(buffer.subarray(0, headerEnd).toString('ascii'));
+      const start = headerEnd + 4;
+      if (!length) {
+        buffer = buffer.subarray(start);
+        onError(new Error('Missing Content-Length header'));
+        continue;
+      }
+      const end = start + Number(length[1]);
+      if (buffer.length < end) return;
+
+      const body = buffer.subarray(start, end).toString('utf8');
+      buffer = buffer.subarray(end);
+      onMessage(body);
+    }
+  });
+}
+
+/**
+ * @private
+ */
+function errorResponse(id, error) {
+  return { jsonrpc: '2.0', id, error: { code: error.code ?? RPC_ERRORS.internalError, message: error.message } };
+}
+
+export default EditorServer;
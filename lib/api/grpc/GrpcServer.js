@@ -0,0 +1,403 @@
+/**
+ * GrpcServer - ctxman as a gRPC service
+ * v3.4.0 - gRPC service mode (serve --grpc)
+ *
+ * Responsibilities:
+ * - Serve ctxman.v1.ContextService (lib/api/grpc/ctxman.proto) over
+ *   HTTP/2 cleartext: length-prefixed protobuf messages, grpc-status
+ *   trailers, deadlines (grpc-timeout) and cancellation
+ * - GenerateContext: scan, analyze and pack through the ContextEngine, then
+ *   stream the rendered context; calls share the engine's cache
+ * - QuerySymbols: definitions of a symbol by name, or the outline of a file
+ * - WatchChanges: stream the files added, changed and deleted until the
+ *   client cancels
+ * - Apply backpressure: the next piece of a context is only rendered once
+ *   HTTP/2 flow control has taken the last one, and watch events of a file
+ *   coalesce while the client is slow
+ * - Check an optional bearer token (authorization metadata)
+ *
+ * Errors use the gRPC status codes: INVALID_ARGUMENT for bad requests,
+ * NOT_FOUND for symbols, focus files and files that do not exist.
+ * Compressed messages are refused.
+ */
+
+import fs from 'fs';
+import http2 from 'http2';
+import path from 'path';
+import { once } from 'events';
+import ContextEngine, { RENDER_FORMATS } from '../ContextEngine.js';
+import { SymbolProvider } from '../mcp/symbols.js';
+import { SymbolKind } from '../../symbols/SymbolModel.js';
+import FileWatcher from '../../watch/FileWatcher.js';
+import { encode, decode, PROTO_PACKAGE } from './protobuf.js';
+import { getLogger } from '../../utils/logger.js';
+
+const logger = getLogger('GrpcServer');
+
+export const GRPC_SERVICE = `${PROTO_PACKAGE}.ContextService`;
+
+export const PROTO_FILE = new URL('./ctxman.proto', import.meta.url);
+
+export const GRPC_STATUS = {
+  OK: 0,
+  CANCELLED: 1,
+  UNKNOWN: 2,
+  INVALID_ARGUMENT: 3,
+  DEADLINE_EXCEEDED: 4,
+  NOT_FOUND: 5,
+  RESOURCE_EXHAUSTED: 8,
+  UNIMPLEMENTED: 12,
+  INTERNAL: 13,
+  UNAUTHENTICATED: 16
+};
+
+// grpc-timeout units, in milliseconds
+const TIMEOUT_UNITS = { H: 3600000, M: 60000, S: 1000, m: 1, u: 0.001, n: 0.000001 };
+
+/**
+ * Error answered with its gRPC status
+ */
+export class GrpcError extends Error {
+  constructor(code, message) {
+    super(message);
+    this.code = code;
+  }
+}
+
+export class GrpcServer {
+  /**
+   * @param {string} projectRoot
+   * @param {Object} options
+   */
+  constructor(projectRoot, options = {}) {
+    this.projectRoot = path.resolve(projectRoot);
+    this.options = {
+      symbolBackend: 'auto',
+      cache: null, // ContentCache shared by all calls; memory only when not given
+      parseCache: null, // ParseCache bounding the outlines of the memory cache; default size when not given
+      authToken: null, // Bearer token calls must send; null = none
+      chunkSize: 64 * 1024, // Characters of context gathered into one ContextChunk
+      maxMessageBytes: 4 * 1024 * 1024, // Largest request accepted
+      debounce: 300, // WatchChanges quiet time (ms) when the request sets none
+      ...options
+    };
+
+    this.engine = new ContextEngine(this.projectRoot, {
+      symbolBackend: this.options.symbolBackend,
+      cache: this.options.cache,
+      parseCache: this.options.parseCache
+    });
+    this.symbols = new SymbolProvider({ extractor: this.engine.extractor, cache: this.engine.cache });
+    this.server = null;
+    this.sessions = new Set();
+    this.methods = {
+      GenerateContext: { request: 'GenerateContextRequest', handler: (request, call) => this.generateContext(request, call) },
+      QuerySymbols: { request: 'QuerySymbolsRequest', handler: (request, call) => this.querySymbols(request, call) },
+      WatchChanges: { request: 'WatchChangesRequest', handler: (request, call) => this.watchChanges(request, call) }
+    };
+  }
+
+  /**
+   * Listen for calls
+   * @param {number} port - 0 picks a free port
+   * @param {string} [host]
+   * @returns {Promise<{host: string, port: number}>}
+   * @throws {Error} When the port is in use
+   */
+  async listen(port, host = 'localhost') {
+    this.server = http2.createServer();
+    this.server.on('session', session => {
+      this.sessions.add(session);
+      session.once('close', () => this.sessions.delete(session));
+    });
+    this.server.on('stream', (stream, headers) => this.handleStream(stream, headers));
+    this.server.on('sessionError', error => logger.debug(`gRPC session failed: ${error.message}`));
+    await new Promise((resolve, reject) => {
+      this.server.once('error', error => reject(error.code === 'EADDRINUSE' ? new Error(`Port in use: ${host}:${port}`) : error));
+      this.server.listen(port, host, resolve);
+    });
+    const address = this.server.address();
+    logger.debug(`gRPC server for ${this.projectRoot} listening on ${address.address}:${address.port}`);
+    return { host, port: address.port };
+  }
+
+  /**
+   * Stop listening; open calls are cancelled
+   * @returns {Promise<void>}
+   */
+  async close() {
+    if (!this.server) return;
+    const closed = new Promise(resolve => this.server.close(resolve));
+    for (const session of this.sessions) session.destroy();
+    await closed;
+    this.server = null;
+  }
+
+  /**
+   * Answer one call
+   * @param {http2.ServerHttp2Stream} stream
+   * @param {Object} headers
+   */
+  handleStream(stream, headers) {
+    stream.on('error', error => logger.debug(`gRPC stream failed: ${error.message}`));
+    if (headers[':method'] !== 'POST' || !String(headers['content-type'] || '').startsWith('application/grpc')) {
+      stream.respond({ ':status': 415 });
+      stream.end();
+      return;
+    }
+
+    const controller = new AbortController();
+    const call = {
+      signal: controller.signal,
+      // Resolves once flow control has taken the message
+      write: async (type, message) => {
+        controller.signal.throwIfAborted();
+        if (!stream.write(frame(encode(type, message)))) {
+          await once(stream, 'drain', { signal: controller.signal });
+        }
+      }
+    };
+    stream.respond({ ':status': 200, 'content-type': 'application/grpc+proto' }, { waitForTrailers: true });
+
+    let status = null;
+    const finish = error => {
+      if (status || stream.destroyed) return;
+      status = error || new GrpcError(GRPC_STATUS.OK, '');
+      if (error) logger.debug(`${headers[':path']} failed (${error.code}): ${error.message}`);
+      stream.once('wantTrailers', () => stream.sendTrailers({
+        'grpc-status': String(status.code),
+        ...(status.message ? { 'grpc-message': encodeURIComponent(status.message) } : {})
+      }));
+      stream.end();
+    };
+    stream.once('close', () => controller.abort(new GrpcError(GRPC_STATUS.CANCELLED, 'Call cancelled')));
+
+    const timeout = /^(\d{1,8})([HMSmun])$/.exec(headers['grpc-timeout'] || '');
+    if (timeout) {
+      const timer = setTimeout(() => controller.abort(new GrpcError(GRPC_STATUS.DEADLINE_EXCEEDED, 'Deadline exceeded')),
+        Number(timeout[1]) * TIMEOUT_UNITS[timeout[2]]);
+      stream.once('close', () => clearTimeout(timer));
+    }
+
+    this.dispatch(stream, headers, call)
+      .then(() => finish(null), error => {
+        const reason = controller.signal.aborted ? controller.signal.reason : error;
+        finish(reason instanceof GrpcError ? reason : new GrpcError(GRPC_STATUS.INTERNAL, reason.message));
+        if (!(reason instanceof GrpcError)) logger.error(`${headers[':path']} failed: ${reason.stack || reason.message}`);
+      });
+    controller.signal.addEventListener('abort', () => finish(controller.signal.reason), { once: true });
+  }
+
+  /**
+   * @private
+   */
+  async dispatch(stream, headers, call) {
+    const { authToken } = this.options;
+    if (authToken && headers.authorization !== `Bearer ${authToken}`) {
+      throw new GrpcError(GRPC_STATUS.UNAUTHENTICATED, 'Missing or invalid bearer token');
+    }
+    const [, service, name] = /^\/([^/]+)\/([^/]+)$/.exec(headers[':path']) || [];
+    const method = service === GRPC_SERVICE && Object.hasOwn(this.methods, name) ? this.methods[name] : null;
+    if (!method) {
+      throw new GrpcError(GRPC_STATUS.UNIMPLEMENTED, `Unknown method: ${headers[':path']}`);
+    }
+
+    const body = await this.readRequest(stream, headers, call.signal);
+    let request;
+    try {
+      request = decode(method.request, body);
+    } catch (error) {
+      throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, error.message);
+    }
+    await method.handler(request, call);
+  }
+
+  /**
+   * The one request message of a call
+   * @private
+   */
+  async readRequest(stream, headers, signal) {
+    const chunks = [];
+    let length = 0;
+    for await (const chunk of stream) {
+      length += chunk.length;
+      if (length > this.options.maxMessageBytes + 5) {
+        throw new GrpcError(GRPC_STATUS.RESOURCE_EXHAUSTED, `Request larger than ${this.options.maxMessageBytes} bytes`);
+      }
+      chunks.push(chunk);
+    }
+    signal.throwIfAborted();
+
+    const data = Buffer.concat(chunks);
+    if (data.length < 5 || data.length !== 5 + data.readUInt32BE(1)) {
+      throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, 'Expected one length-prefixed request message');
+    }
+    if (data[0] !== 0) {
+      throw new GrpcError(GRPC_STATUS.UNIMPLEMENTED, `Compressed messages are not supported (grpc-encoding: ${headers['grpc-encoding'] || 'unknown'})`);
+    }
+    return data.subarray(5);
+  }
+
+  /**
+   * Stats of the selection, then the context in chunks
+   * @private
+   */
+  async generateContext(request, { write, signal }) {
+    const format = request.format || 'json';
+    if (!RENDER_FORMATS.includes(format)) {
+      throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, `Invalid format: ${format} (expected ${RENDER_FORMATS.join(', ')})`);
+    }
+
+    let selection;
+    try {
+      const analysis = await this.engine.analyze({ profile: request.profile || null, signal });
+      selection = await this.engine.pack(analysis, {
+        maxTokens: request.maxTokens || null,
+        tokenizer: request.tokenizer || undefined,
+        tiers: request.tiers.length > 0 ? request.tiers : null,
+        focus: request.focus || null,
+        depth: request.depth,
+        symbols: request.symbols,
+        pairTests: request.pairTests || null,
+        signal
+      });
+    } catch (error) {
+      if (signal.aborted) throw error;
+      throw new GrpcError(/ not found/i.test(error.message) ? GRPC_STATUS.NOT_FOUND : GRPC_STATUS.INVALID_ARGUMENT, error.message);
+    }
+
+    await write('ContextChunk', {
+      text: '',
+      stats: { files: selection.files.length, tokens: selection.files.reduce((sum, fileInfo) => sum + fileInfo.tokens, 0), format }
+    });
+    let text = '';
+    for await (const piece of this.engine.renderPieces(selection, { format, signal })) {
+      text += piece;
+      if (text.length >= this.options.chunkSize) {
+        await write('ContextChunk', { text });
+        text = '';
+      }
+    }
+    if (text) await write('ContextChunk', { text });
+  }
+
+  /**
+   * @private
+   */
+  async querySymbols(request, { write }) {
+    const kind = request.kind || null;
+    if (kind && !Object.values(SymbolKind).includes(kind)) {
+      throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, `Invalid kind: ${kind} (expected ${Object.values(SymbolKind).join(', ')})`);
+    }
+    const file = request.file ? this.relativeFile(request.file) : null;
+    if (file && !fs.existsSync(path.join(this.projectRoot, file))) {
+      throw new GrpcError(GRPC_STATUS.NOT_FOUND, `File not found: ${file}`);
+    }
+
+    let symbols;
+    if (request.name) {
+      symbols = await this.symbols.findSymbol(this.projectRoot, request.name, { kind, file, limit: request.limit ?? 10 });
+    } else if (file) {
+      const outline = await this.symbols.getOutline(this.projectRoot, file);
+      symbols = outline.symbols
+        .filter(symbol => !kind || symbol.kind === kind)
+        .slice(0, request.limit ?? undefined)
+        .map(symbol => ({ ...symbol, file }));
+    } else {
+      throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, 'QuerySymbols needs name or file');
+    }
+    await write('QuerySymbolsResponse', { symbols });
+  }
+
+  /**
+   * Changes of project files until the call is cancelled
+   * @private
+   */
+  async watchChanges(request, { write, signal }) {
+    const known = new Set(await this.engine.scan({ signal }));
+    const watcher = new FileWatcher(this.projectRoot, {
+      debounce: request.debounceMs ?? this.options.debounce,
+      ignorePatterns: ['.ctxman']
+    });
+
+    // Events of a file that arrive while the client catches up replace the unsent one
+    const pending = new Map();
+    let wake = null;
+    watcher.on('file:changed', event => {
+      if (event.exists && isDirectory(event.path)) return;
+      const file = event.relativePath.split(path.sep).join('/');
+      const type = !event.exists ? 'deleted' : known.has(file) ? 'changed' : 'added';
+      if (event.exists) {
+        known.add(file);
+      } else {
+        known.delete(file);
+      }
+      const unsent = pending.get(file);
+      pending.delete(file);
+      pending.set(file, {
+        // A file added and changed before it was sent is still new to the client
+        type: unsent?.type === 'added' && type === 'changed' ? 'added' : type,
+        file,
+        size: event.size || 0,
+        timestamp: event.timestamp,
+        coalesced: unsent ? unsent.coalesced + 1 : 0
+      });
+      wake?.();
+    });
+
+    watcher.start();
+    const woken = () => new Promise(resolve => {
+      wake = resolve;
+      signal.addEventListener('abort', resolve, { once: true });
+    });
+    try {
+      while (!signal.aborted) {
+        if (pending.size === 0) await woken();
+        wake = null;
+        for (const [file, event] of pending) {
+          pending.delete(file);
+          await write('ChangeEvent', event);
+        }
+      }
+    } finally {
+      watcher.stop();
+    }
+    signal.throwIfAborted();
+  }
+
+  /**
+   * Project-relative path of a file parameter
+   * @private
+   */
+  relativeFile(file) {
+    const relative = path.relative(this.projectRoot, path.resolve(this.projectRoot, file));
+    if (relative === '' || relative.startsWith('..') || path.isAbsolute(relative)) {
+      throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, `File outside the project: ${file}`);
+    }
+    return relative.split(path.sep).join('/');
+  }
+}
+
+/**
+ * Length-prefixed, uncompressed gRPC message
+ * @param {Buffer} body
+ * @returns {Buffer}
+ */
+export function frame(body) {
+  const header = Buffer.alloc(5);
+  header.writeUInt32BE(body.length, 1);
+  return Buffer.concat([header, body]);
+}
+
+/**
+ * @private
+ */
+function isDirectory(filePath) {
+  try {
+    return fs.statSync(filePath).isDirectory();
+  } catch {
+    return false;
+  }
+}
+
+export default GrpcServer;
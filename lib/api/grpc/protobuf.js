@@ -0,0 +1,247 @@
+/**
+ * Protocol Buffers codec for the ctxman gRPC service
+ * v3.4.0 - gRPC service mode (serve --grpc)
+ *
+ * Responsibilities:
+ * - Describe the messages of ctxman.proto as field tables
+ * - Encode and decode them in the proto3 wire format: varints, length-
+ *   delimited strings and messages, packed or unpacked repeated scalars,
+ *   and unknown fields skipped
+ *
+ * Field names are the camelCase forms of the .proto names, as protobuf.js
+ * and grpc-js's proto-loader produce them. Unset scalars decode to their
+ * proto3 defaults; optional fields that are absent decode to null.
+ */
+
+export const PROTO_PACKAGE = 'ctxman.v1';
+
+const WIRE_VARINT = 0;
+const WIRE_FIXED64 = 1;
+const WIRE_LENGTH = 2;
+const WIRE_FIXED32 = 5;
+
+const SCALAR_DEFAULTS = { string: '', bool: false, uint32: 0, uint64: 0 };
+
+/**
+ * Fields of each message: [number, name, type, label]; label is
+ * 'repeated', 'optional' or absent, type a scalar or a message name
+ */
+export const MESSAGES = {
+  GenerateContextRequest: [
+    [1, 'format', 'string'],
+    [2, 'maxTokens', 'string'],
+    [3, 'tokenizer', 'string'],
+    [4, 'tiers', 'string', 'repeated'],
+    [5, 'focus', 'string'],
+    [6, 'depth', 'uint32', 'optional'],
+    [7, 'symbols', 'string', 'repeated'],
+    [8, 'profile', 'string'],
+    [9, 'pairTests', 'string']
+  ],
+  ContextChunk: [
+    [1, 'text', 'string'],
+    [2, 'stats', 'ContextStats', 'optional']
+  ],
+  ContextStats: [
+    [1, 'files', 'uint32'],
+    [2, 'tokens', 'uint64'],
+    [3, 'format', 'string']
+  ],
+  QuerySymbolsRequest: [
+    [1, 'name', 'string'],
+    [2, 'file', 'string'],
+    [3, 'kind', 'string'],
+    [4, 'limit', 'uint32', 'optional']
+  ],
+  QuerySymbolsResponse: [
+    [1, 'symbols', 'Symbol', 'repeated']
+  ],
+  Symbol: [
+    [1, 'name', 'string'],
+    [2, 'kind', 'string'],
+    [3, 'file', 'string'],
+    [4, 'startLine', 'uint32'],
+    [5, 'endLine', 'uint32'],
+    [6, 'signature', 'string'],
+    [7, 'doc', 'string'],
+    [8, 'exported', 'bool'],
+    [9, 'source', 'string'],
+    [10, 'truncated', 'bool']
+  ],
+  WatchChangesRequest: [
+    [1, 'debounceMs', 'uint32', 'optional']
+  ],
+  ChangeEvent: [
+    [1, 'type', 'string'],
+    [2, 'file', 'string'],
+    [3, 'size', 'uint64'],
+    [4, 'timestamp', 'uint64'],
+    [5, 'coalesced', 'uint32']
+  ]
+};
+
+/**
+ * @param {string} type - Message name of MESSAGES
+ * @param {Object} message - Fields by camelCase name; null and undefined are left out
+ * @returns {Buffer}
+ */
+export function encode(type, message) {
+  const chunks = [];
+  for (const [number, name, fieldType, label] of fields(type)) {
+    const value = message[name];
+    if (value === null || value === undefined) continue;
+    for (const item of label === 'repeated' ? value : [value]) {
+      // proto3 leaves default scalars off the wire, unless the field tracks presence
+      if (label !== 'optional' && label !== 'repeated' && item === SCALAR_DEFAULTS[fieldType]) continue;
+      chunks.push(encodeField(number, fieldType, item));
+    }
+  }
+  return Buffer.concat(chunks);
+}
+
+/**
+ * @param {string} type - Message name of MESSAGES
+ * @param {Buffer} buffer
+ * @returns {Object}
+ * @throws {Error} For truncated or malformed messages
+ */
+export function decode(type, buffer) {
+  const table = fields(type);
+  const message = {};
+  for (const [, name, fieldType, label] of table) {
+    message[name] = label === 'repeated' ? [] : label === 'optional' || !(fieldType in SCALAR_DEFAULTS) ? null : SCALAR_DEFAULTS[fieldType];
+  }
+
+  const reader = { buffer, offset: 0 };
+  while (reader.offset < buffer.length) {
+    const key = Number(readVarint(reader));
+    const number = key >>> 3;
+    const wireType = key & 7;
+    const field = table.find(candidate => candidate[0] === number);
+    if (!field) {
+      skip(reader, wireType);
+      continue;
+    }
+
+    const [, name, fieldType, label] = field;
+    if (label === 'repeated' && fieldType in SCALAR_DEFAULTS && fieldType !== 'string' && wireType === WIRE_LENGTH) {
+      // Packed repeated scalars
+      const end = reader.offset + Number(readVarint(reader));
+      while (reader.offset < end) message[name].push(scalar(fieldType, readVarint(reader)));
+      continue;
+    }
+    const value = decodeValue(reader, fieldType, wireType);
+    if (label === 'repeated') {
+      message[name].push(value);
+    } else {
+      message[name] = value;
+    }
+  }
+  return message;
+}
+
+/**
+ * @private
+ */
+function fields(type) {
+  const table = MESSAGES[type];
+  if (!table) throw new Error(`Unknown message: ${PROTO_PACKAGE}.${type}`);
+  return table;
+}
+
+/**
+ * @private
+ */
+function encodeField(number, type, value) {
+  if (type === 'string') {
+    const bytes = Buffer.from(String(value), 'utf8');
+    return Buffer.concat([varint((number << 3) | WIRE_LENGTH), varint(bytes.length), bytes]);
+  }
+  if (type in SCALAR_DEFAULTS) {
+    return Buffer.concat([varint((number << 3) | WIRE_VARINT), varint(type === 'bool' ? Number(Boolean(value)) : value)]);
+  }
+  const bytes = encode(type, value);
+  return Buffer.concat([varint((number << 3) | WIRE_LENGTH), varint(bytes.length), bytes]);
+}
+
+/**
+ * @private
+ */
+function decodeValue(reader, type, wireType) {
+  const expected = type in SCALAR_DEFAULTS && type !== 'string' ? WIRE_VARINT : WIRE_LENGTH;
+  if (wireType !== expected) {
+    throw new Error(`Malformed message: wire type ${wireType} for a ${type} field`);
+  }
+  if (wireType === WIRE_VARINT) return scalar(type, readVarint(reader));
+
+  const bytes = readBytes(reader);
+  return type === 'string' ? bytes.toString('utf8') : decode(type, bytes);
+}
+
+/**
+ * @private
+ */
+function scalar(type, value) {
+  if (type === 'bool') return value !== 0n;
+  if (type === 'uint32') return Number(BigInt.asUintN(32, value));
+  return Number(value);
+}
+
+/**
+ * Base-128 varint of a non-negative integer
+ * @private
+ */
+function varint(value) {
+  let remaining = BigInt(value);
+  if (remaining < 0n) throw new Error(`Negative value for an unsigned field: ${value}`);
+  const bytes = [];
+  do {
+    let byte = Number(remaining & 0x7fn);
+    remaining >>= 7n;
+    if (remaining > 0n) byte |= 0x80;
+    bytes.push(byte);
+  } while (remaining > 0n);
+  return Buffer.from(bytes);
+}
+
+/**
+ * @private
+ */
+function readVarint(reader) {
+  let value = 0n;
+  for (let shift = 0n; shift < 70n; shift += 7n) {
+    if (reader.offset >= reader.buffer.length) throw new Error('Malformed message: truncated varint');
+    const byte = reader.buffer[reader.offset++];
+    value |= BigInt(byte & 0x7f) << shift;
+    if ((byte & 0x80) === 0) return value;
+  }
+  throw new Error('Malformed message: varint too long');
+}
+
+/**
+ * @private
+ */
+function readBytes(reader) {
+  const length = Number(readVarint(reader));
+  const end = reader.offset + length;
+  if (end > reader.buffer.length) throw new Error('Malformed message: truncated field');
+  const bytes = reader.buffer.subarray(reader.offset, end);
+  reader.offset = end;
+  return bytes;
+}
+
+/**
+ * @private
+ */
+function skip(reader, wireType) {
+  if (wireType === WIRE_VARINT) {
+    readVarint(reader);
+  } else if (wireType === WIRE_LENGTH) {
+    readBytes(reader);
+  } else if (wireType === WIRE_FIXED64 || wireType === WIRE_FIXED32) {
+    reader.offset += wireType === WIRE_FIXED64 ? 8 : 4;
+    if (reader.offset > reader.buffer.length) throw new Error('Malformed message: truncated field');
+  } else {
+    throw new Error(`Malformed message: wire type ${wireType}`);
+  }
+}
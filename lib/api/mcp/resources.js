@@ -1,5 +1,6 @@
 import { getLogger } from "../../utils/logger.js";
 import { Scanner } from "../../core/Scanner.js";
+import { SymbolProvider } from "./symbols.js";
 import path from "path";
 import fs from "fs";
 
@@ -10,14 +11,17 @@ const logger = getLogger("MCP:Resources");
  * Provides list, read, and template operations for resources
  */
 export class ResourceProvider {
-    constructor(projectPath) {
+    constructor(projectPath, symbolProvider = null) {
         this.projectPath = projectPath;
         this.scanner = null;
+        this.symbolProvider = symbolProvider || new SymbolProvider();
         this.cache = new Map();
         this.supportedTypes = {
             FILE: "file",
             ANALYSIS: "analysis",
-            CONTEXT: "context"
+            CONTEXT: "context",
+            OUTLINE: "outline",
+            SYMBOL: "symbol"
         };
     }
 
@@ -90,6 +94,12 @@ export class ResourceProvider {
                 case this.supportedTypes.CONTEXT:
                     return await this.readContextResource(parsed.name);
 
+                case this.supportedTypes.OUTLINE:
+                    return await this.readOutlineResource(parsed.path);
+
+                case this.supportedTypes.SYMBOL:
+                    return await this.readSymbolResource(parsed.name);
+
                 default:
                     throw new Error(`Unsupported resource type: ${parsed.type}`);
             }
@@ -122,6 +132,18 @@ export class ResourceProvider {
                 name: "Context Templates",
                 description: "Pre-generated context for common workflows",
                 mimeType: "text/plain"
+            },
+            {
+                uriTemplate: "outline://codebase/{path}",
+                name: "File Outlines",
+                description: "Classes, functions and methods of a source file with line ranges",
+                mimeType: "text/markdown"
+            },
+            {
+                uriTemplate: "symbol://codebase/{name}",
+                name: "Symbol Definitions",
+                description: "Source of a symbol by name or qualified name (e.g. Service.fetch)",
+                mimeType: "application/json"
             }
         ];
     }
@@ -192,6 +214,42 @@ export class ResourceProvider {
         };
     }
 
+    /**
+     * Read a file outline resource
+     * @private
+     */
+    async readOutlineResource(relativePath) {
+        const outline = await this.symbolProvider.getOutline(this.projectPath, relativePath);
+
+        return {
+            contents: [{
+                uri: `outline://codebase/${relativePath}`,
+                mimeType: "text/markdown",
+                text: this.symbolProvider.formatOutline(outline)
+            }]
+        };
+    }
+
+    /**
+     * Read a symbol definition resource
+     * @private
+     */
+    async readSymbolResource(name) {
+        const definitions = await this.symbolProvider.findSymbol(this.projectPath, name);
+
+        if (definitions.length === 0) {
+            throw new Error(`Symbol not found: ${name}`);
+        }
+
+        return {
+            contents: [{
+                uri: `symbol://codebase/${name}`,
+                mimeType: "application/json",
+                text: JSON.stringify(definitions, null, 2)
+            }]
+        };
+    }
+
     /**
      * Cache an analysis result
      * @param {string} id - Resource ID
@@ -232,6 +290,16 @@ export class ResourceProvider {
             return { type: this.supportedTypes.CONTEXT, name: contextMatch[1] };
         }
 
+        const outlineMatch = uri.match(/^outline:\/\/codebase\/(.+)$/);
+        if (outlineMatch) {
+            return { type: this.supportedTypes.OUTLINE, path: outlineMatch[1] };
+        }
+
+        const symbolMatch = uri.match(/^symbol:\/\/codebase\/(.+)$/);
+        if (symbolMatch) {
+            return { type: this.supportedTypes.SYMBOL, name: decodeURIComponent(symbolMatch[1]) };
+        }
+
         throw new Error(`Invalid resource URI: ${uri}`);
     }
 
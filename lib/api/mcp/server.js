@@ -16,11 +16,20 @@ import { Scanner } from "../../core/Scanner.js";
 import { GitClient } from "../../integrations/git/GitClient.js";
 import { ResourceProvider } from "./resources.js";
 import { PromptProvider } from "./prompts.js";
+import { SymbolProvider } from "./symbols.js";
+import { getLogger } from "../../utils/logger.js";
 import path from "path";
 import fs from "fs";
 
 class MCPServer {
-    constructor() {
+    constructor(options = {}) {
+        this.options = {
+            projectRoot: null, // Default project for tools and resources (serve --mcp)
+            symbolBackend: "auto",
+            cache: null, // ContentCache shared by symbol lookups
+            ...options
+        };
+
         this.server = new Server(
             {
                 name: "ctxman",
@@ -42,13 +51,31 @@ class MCPServer {
 
         this.analyzer = new Analyzer();
         this.contextBuilder = new ContextBuilder();
-        this.resourceProvider = new ResourceProvider();
+        this.symbolProvider = new SymbolProvider({
+            symbolBackend: this.options.symbolBackend,
+            cache: this.options.cache
+        });
+        this.resourceProvider = new ResourceProvider(this.options.projectRoot, this.symbolProvider);
         this.promptProvider = new PromptProvider(this.analyzer, this.contextBuilder);
         this.setupHandlers();
         this.setupErrorHandling();
     }
 
+    /**
+     * Project root for a tool call: explicit path argument, else the served project
+     */
+    getProjectPath(args = {}) {
+        const projectPath = args.path || this.options.projectRoot;
+        if (!projectPath) {
+            throw new Error("Missing required argument: path");
+        }
+        return projectPath;
+    }
+
     setupHandlers() {
+        // path may be omitted when serving a fixed project
+        const pathRequired = this.options.projectRoot ? [] : ["path"];
+
         this.server.setRequestHandler(ListToolsRequestSchema, async () => {
             return {
                 tools: [
@@ -63,7 +90,7 @@ class MCPServer {
                                     description: "Absolute path to the project root",
                                 },
                             },
-                            required: ["path"],
+                            required: pathRequired,
                         },
                     },
                     {
@@ -86,7 +113,7 @@ class MCPServer {
                                     description: "Maximum tokens for the context",
                                 },
                             },
-                            required: ["path"],
+                            required: pathRequired,
                         },
                     },
                     {
@@ -98,7 +125,7 @@ class MCPServer {
                                 path: { type: "string", description: "Project root" },
                                 branch: { type: "string", description: "Branch to compare against (default: main)" }
                             },
-                            required: ["path"]
+                            required: pathRequired
                         }
                     },
                     {
@@ -111,7 +138,7 @@ class MCPServer {
                                 query: { type: "string", description: "Search query" },
                                 type: { type: "string", enum: ["regex", "semantic"], default: "regex" }
                             },
-                            required: ["path", "query"]
+                            required: [...pathRequired, "query"]
                         }
                     },
                     {
@@ -129,7 +156,38 @@ class MCPServer {
                                     description: "Optional: Relative path to a specific file within the project"
                                 }
                             },
-                            required: ["path"]
+                            required: pathRequired
+                        }
+                    },
+                    {
+                        name: "get_file_outline",
+                        description: "List the classes, functions and methods of a file with signatures and line ranges",
+                        inputSchema: {
+                            type: "object",
+                            properties: {
+                                path: { type: "string", description: "Project root" },
+                                file: { type: "string", description: "Relative path to the file within the project" }
+                            },
+                            required: [...pathRequired, "file"]
+                        }
+                    },
+                    {
+                        name: "find_symbol",
+                        description: "Find symbol definitions by name (e.g. fetch or Service.fetch) and return their source",
+                        inputSchema: {
+                            type: "object",
+                            properties: {
+                                path: { type: "string", description: "Project root" },
+                                name: { type: "string", description: "Symbol name or qualified name" },
+                                kind: {
+                                    type: "string",
+                                    description: "Optional: restrict to a symbol kind",
+                                    enum: ["module", "class", "struct", "interface", "trait", "enum", "type", "function", "method", "constant", "variable"]
+                                },
+                                file: { type: "string", description: "Optional: restrict to one relative file path" },
+                                limit: { type: "number", description: "Maximum definitions to return (default: 10)" }
+                            },
+                            required: [...pathRequired, "name"]
                         }
                     },
                 ],
@@ -170,7 +228,7 @@ class MCPServer {
             try {
                 switch (name) {
                     case "analyze_codebase": {
-                        const projectPath = args.path;
+                        const projectPath = this.getProjectPath(args);
 
                         // Update resource provider project path
                         this.resourceProvider.projectPath = projectPath;
@@ -193,7 +251,7 @@ class MCPServer {
                         };
                     }
                     case "generate_context": {
-                        const projectPath = args.path;
+                        const projectPath = this.getProjectPath(args);
 
                         // Update resource provider project path
                         this.resourceProvider.projectPath = projectPath;
@@ -225,7 +283,7 @@ class MCPServer {
                         }
                     }
                     case "git_diff": {
-                        const projectPath = args.path;
+                        const projectPath = this.getProjectPath(args);
                         const gitClient = new GitClient(projectPath);
                         const branch = args.branch || "main";
 
@@ -241,7 +299,8 @@ class MCPServer {
                         };
                     }
                     case "search_code": {
-                        const { path: projectPath, query, type } = args;
+                        const { query, type } = args;
+                        const projectPath = this.getProjectPath(args);
                         const scanner = new Scanner(projectPath);
                         const files = scanner.scan();
                         const results = [];
@@ -279,7 +338,7 @@ class MCPServer {
                         };
                     }
                     case "list_methods": {
-                        const projectPath = args.path;
+                        const projectPath = this.getProjectPath(args);
                         const scanner = new Scanner(projectPath);
                         const files = scanner.scan();
 
@@ -303,6 +362,31 @@ class MCPServer {
                             content: [{ type: "text", text: JSON.stringify(methods, null, 2) }]
                         };
                     }
+                    case "get_file_outline": {
+                        const projectPath = this.getProjectPath(args);
+                        const outline = await this.symbolProvider.getOutline(projectPath, args.file);
+
+                        return {
+                            content: [{ type: "text", text: JSON.stringify(outline, null, 2) }]
+                        };
+                    }
+                    case "find_symbol": {
+                        const projectPath = this.getProjectPath(args);
+                        const definitions = await this.symbolProvider.findSymbol(projectPath, args.name, {
+                            kind: args.kind,
+                            file: args.file,
+                            limit: args.limit
+                        });
+
+                        return {
+                            content: [{
+                                type: "text",
+                                text: definitions.length > 0
+                                    ? JSON.stringify(definitions, null, 2)
+                                    : `No definitions found for ${args.name}`
+                            }]
+                        };
+                    }
                     default:
                         throw new Error(`Unknown tool: ${name}`);
                 }
@@ -328,6 +412,8 @@ class MCPServer {
 
     async start(transport) {
         if (!transport) {
+            // stdout carries JSON-RPC; keep console logging off it
+            getLogger().silent = true;
             const { StdioServerTransport } = await import("@modelcontextprotocol/sdk/server/stdio.js");
             transport = new StdioServerTransport();
         }
@@ -0,0 +1,200 @@
+import { getLogger } from "../../utils/logger.js";
+import { Scanner } from "../../core/Scanner.js";
+import { SymbolExtractor } from "../../symbols/SymbolExtractor.js";
+import { ContentCache } from "../../cache/ContentCache.js";
+import path from "path";
+import fs from "fs";
+import { compareBytes } from '../../utils/ordering.js';
+
+const logger = getLogger("MCP:Symbols");
+
+/**
+ * SymbolProvider answers outline and definition queries for MCP clients
+ * Backed by SymbolExtractor, so every supported language returns the same shape
+ */
+export class SymbolProvider {
+    constructor(options = {}) {
+        this.options = {
+            symbolBackend: "auto",
+            maxDefinitionLines: 200,
+            ...options
+        };
+        this.extractor = this.options.extractor || new SymbolExtractor({ backend: this.options.symbolBackend });
+        // Memory-only unless a persistent cache is passed in
+        this.cache = this.options.cache || new ContentCache({ path: null });
+        this.ready = null;
+    }
+
+    /**
+     * Initialize the extractor once (loads tree-sitter grammars when installed)
+     * @returns {Promise<SymbolExtractor>}
+     */
+    async initialize() {
+        if (!this.ready) {
+            this.ready = this.extractor.initialize();
+        }
+        return this.ready;
+    }
+
+    /**
+     * Resolve a project-relative path, refusing paths outside the project
+     * @param {string} projectPath - Project root
+     * @param {string} relativePath - File path relative to the root
+     * @returns {string} Absolute path
+     */
+    resolveFile(projectPath, relativePath) {
+        const resolvedProject = path.resolve(projectPath || "");
+        const resolvedPath = path.resolve(resolvedProject, relativePath);
+
+        if (resolvedPath !== resolvedProject && !resolvedPath.startsWith(resolvedProject + path.sep)) {
+            throw new Error("Access denied: path outside project directory");
+        }
+
+        return resolvedPath;
+    }
+
+    /**
+     * Get the symbol outline of a file
+     * @param {string} projectPath - Project root
+     * @param {string} relativePath - File path relative to the root
+     * @returns {Promise<{file: string, supported: boolean, symbols: Array}>}
+     */
+    async getOutline(projectPath, relativePath) {
+        await this.initialize();
+
+        const fullPath = this.resolveFile(projectPath, relativePath);
+        const content = fs.readFileSync(fullPath, "utf-8");
+        const symbols = this.extractSymbols(content, relativePath);
+
+        return {
+            file: relativePath,
+            supported: this.extractor.supports(relativePath),
+            symbols: symbols.map(symbol => ({
+                name: symbol.qualifiedName,
+                kind: symbol.kind,
+                startLine: symbol.startLine,
+                endLine: symbol.endLine,
+                signature: symbol.signature,
+                doc: symbol.doc ? symbol.doc.split("\n")[0] : null,
+                exported: symbol.exported
+            }))
+        };
+    }
+
+    /**
+     * Find symbol definitions across the project
+     * Matches the qualified name exactly, then the bare name, then a qualified suffix
+     * (e.g. "fetch" finds "Service.fetch").
+     * @param {string} projectPath - Project root
+     * @param {string} name - Symbol name or qualified name
+     * @param {Object} options
+     * @param {string} options.kind - Restrict to a SymbolKind
+     * @param {string} options.file - Restrict to one relative path
+     * @param {number} options.limit - Maximum definitions (default 10)
+     * @returns {Promise<Array>} Definitions with source text
+     */
+    async findSymbol(projectPath, name, options = {}) {
+        await this.initialize();
+
+        const { kind = null, file = null, limit = 10 } = options;
+        const files = file
+            ? [{ path: this.resolveFile(projectPath, file), relativePath: file }]
+            : new Scanner(projectPath).scan().filter(f => this.extractor.supports(f.relativePath));
+
+        const matches = [];
+
+        for (const fileInfo of files) {
+            let content;
+            try {
+                content = fs.readFileSync(fileInfo.path, "utf-8");
+            } catch (error) {
+                continue;
+            }
+
+            for (const symbol of this.extractSymbols(content, fileInfo.relativePath)) {
+                if (kind && symbol.kind !== kind) continue;
+
+                const rank = symbol.qualifiedName === name ? 0
+                    : symbol.name === name ? 1
+                        : symbol.qualifiedName.endsWith(`.${name}`) ? 2
+                            : -1;
+                if (rank === -1) continue;
+
+                matches.push({ rank, symbol, content });
+            }
+        }
+
+        matches.sort((a, b) => a.rank - b.rank ||
+            compareBytes(a.symbol.file, b.symbol.file) ||
+            a.symbol.startLine - b.symbol.startLine);
+
+        this.cache.save();
+        logger.info(`Found ${matches.length} definitions for ${name}`);
+
+        return matches.slice(0, limit).map(({ symbol, content }) => this.toDefinition(symbol, content));
+    }
+
+    /**
+     * Extract symbols, reusing outlines of unchanged content
+     * @private
+     */
+    extractSymbols(content, relativePath) {
+        if (!this.extractor.supports(relativePath)) {
+            return [];
+        }
+
+        return this.cache.symbols(
+            ContentCache.hash(content),
+            ContentCache.key(this.extractor.getBackend(relativePath), relativePath),
+            relativePath,
+            () => this.extractor.extract(content, relativePath)
+        );
+    }
+
+    /**
+     * Build a definition with source text
+     * @private
+     */
+    toDefinition(symbol, content) {
+        const lines = content.split("\n").slice(symbol.startLine - 1, symbol.endLine);
+        const truncated = lines.length > this.options.maxDefinitionLines;
+
+        return {
+            name: symbol.qualifiedName,
+            kind: symbol.kind,
+            language: symbol.language,
+            file: symbol.file,
+            startLine: symbol.startLine,
+            endLine: symbol.endLine,
+            signature: symbol.signature,
+            doc: symbol.doc,
+            exported: symbol.exported,
+            source: lines.slice(0, this.options.maxDefinitionLines).join("\n"),
+            truncated
+        };
+    }
+
+    /**
+     * Format an outline as indented text
+     * @param {Object} outline - Result of getOutline()
+     * @returns {string}
+     */
+    formatOutline(outline) {
+        if (!outline.supported) {
+            return `# ${outline.file}\n\nNo symbol extractor for this file type.\n`;
+        }
+
+        const lines = [`# ${outline.file}`, ""];
+
+        for (const symbol of outline.symbols) {
+            const depth = symbol.name.split(".").length - 1;
+            const range = symbol.startLine === symbol.endLine
+                ? `L${symbol.startLine}`
+                : `L${symbol.startLine}-${symbol.endLine}`;
+            const doc = symbol.doc ? ` — ${symbol.doc}` : "";
+            lines.push(`${"  ".repeat(depth)}- ${symbol.kind} ${symbol.signature || symbol.name} (${range})${doc}`);
+        }
+
+        return lines.join("\n") + "\n";
+    }
+}
@@ -0,0 +1,354 @@
+/**
+ * Context Service
+ * v3.4.0 - HTTP daemon (serve --http)
+ *
+ * Responsibilities:
+ * - Build fresh context for the served project on every request (GET /context)
+ * - List symbol outlines and definitions (GET /symbols)
+ * - Answer semantic queries with the most relevant chunks (POST /query)
+ * - Keep token counts, outlines and embeddings in memory between requests
+ */
+
+import path from 'path';
+import TokenCalculator from '../../analyzers/token-calculator.js';
+import GitIngestFormatter from '../../formatters/gitingest-formatter.js';
+import TokenBudget, { parseTokenCount } from '../../core/TokenBudget.js';
+import ContextProfiles from '../../core/ContextProfiles.js';
+import { nativeFileSystem } from '../../core/FileSystem.js';
+import ContentCache from '../../cache/ContentCache.js';
+import { SymbolExtractor } from '../../symbols/SymbolExtractor.js';
+import ParseCache from '../../cache/ParseCache.js';
+import { SymbolKind } from '../../symbols/SymbolModel.js';
+import { PAIR_MODES } from '../../core/TestPairing.js';
+import { parseXmlTags } from '../../formatters/xml-formatter.js';
+import { parseFenceChar, parseXmlContentMode } from '../../formatters/content-escaper.js';
+import SemanticIndex from '../../rag/SemanticIndex.js';
+import { EmbeddingProviderFactory } from '../../rag/EmbeddingProviderFactory.js';
+import { DEFAULT_OUTPUTS } from '../../watch/ContextRegenerator.js';
+import { SymbolProvider } from '../mcp/symbols.js';
+import { getLogger } from '../../utils/logger.js';
+
+const logger = getLogger('ContextService');
+
+export const CONTEXT_FORMATS = ['json', 'yaml', 'markdown', 'xml', 'gitingest', 'context'];
+
+const CONTENT_TYPES = {
+  json: 'application/json',
+  yaml: 'application/yaml',
+  markdown: 'text/markdown; charset=utf-8',
+  xml: 'application/xml; charset=utf-8',
+  gitingest: 'text/plain; charset=utf-8',
+  context: 'application/json'
+};
+
+/**
+ * Error carrying the HTTP status to answer with
+ * @param {number} statusCode
+ * @param {string} message
+ * @returns {Error}
+ */
+export function httpError(statusCode, message) {
+  const error = new Error(message);
+  error.statusCode = statusCode;
+  return error;
+}
+
+export class ContextService {
+  /**
+   * @param {string} projectRoot - Project served by the daemon
+   * @param {Object} options
+   */
+  constructor(projectRoot, options = {}) {
+    this.projectRoot = path.resolve(projectRoot);
+    this.options = {
+      symbolBackend: 'auto',
+      cache: null, // ContentCache; memory only when not given
+      parseCache: null, // ParseCache bounding the outlines of the memory cache; default size when not given
+      embeddings: 'transformers', // Default provider for POST /query
+      embeddingModel: null,
+      embeddingUrl: null,
+      provider: null, // EmbeddingProvider instance used when a query names no provider
+      metrics: null, // ContextMetrics counting every generated context (--metrics)
+      ...options
+    };
+
+    this.cache = this.options.cache || new ContentCache({
+      root: this.projectRoot,
+      path: null,
+      parseCache: this.options.parseCache || new ParseCache()
+    });
+    this.extractor = new SymbolExtractor({ backend: this.options.symbolBackend });
+    this.symbolProvider = new SymbolProvider({ extractor: this.extractor, cache: this.cache });
+    this.indexes = new Map(); // Embedding provider id -> SemanticIndex
+    this.ready = null;
+  }
+
+  /**
+   * Initialize the symbol extractor once
+   * @returns {Promise<SymbolExtractor>}
+   */
+  async initialize() {
+    if (!this.ready) {
+      this.ready = this.extractor.initialize();
+    }
+    return this.ready;
+  }
+
+  /**
+   * Context for the project, narrowed and packed like the CLI export
+   * @param {Object} params - format, maxTokens, tokenizer, tiers, focus, depth, symbol, profile, pairTests, collapsible, xmlTags, fence, xmlContent
+   * @returns {Promise<{contentType: string, body: string}>}
+   */
+  async context(params = {}) {
+    const options = await this.analysisOptions(params);
+    const calculator = this.createCalculator(options);
+    const exportResults = calculator.selectExportResults(this.analyze(calculator));
+    this.options.metrics?.recordRun(calculator, exportResults);
+
+    if (!exportResults) {
+      const missing = calculator.selection?.missing?.join(', ') || options.focus;
+      throw httpError(404, `Symbol not found: ${missing}`);
+    }
+    return this.render(calculator, exportResults, params.format);
+  }
+
+  /**
+   * Symbols of one file (file), definitions by name (name), or the whole project
+   * @param {Object} params - file, name, kind, limit
+   * @returns {Promise<{contentType: string, body: string}>}
+   */
+  async symbols(params = {}) {
+    await this.initialize();
+
+    const kind = params.kind || null;
+    if (kind && !Object.values(SymbolKind).includes(kind)) {
+      throw httpError(400, `Invalid kind: ${kind} (expected ${Object.values(SymbolKind).join(', ')})`);
+    }
+    const limit = params.limit !== undefined ? parseCount(params.limit, 'limit') : undefined;
+
+    let result;
+    if (params.name) {
+      result = await this.symbolProvider.findSymbol(this.projectRoot, params.name, { kind, file: params.file, limit });
+    } else if (params.file) {
+      result = await this.outline(params.file);
+      if (kind) result.symbols = result.symbols.filter(symbol => symbol.kind === kind);
+    } else {
+      const calculator = this.createCalculator({});
+      const files = [];
+      for (const filePath of calculator.scanDirectory(this.projectRoot)) {
+        const relativePath = path.relative(this.projectRoot, filePath).split(path.sep).join('/');
+        if (!this.extractor.supports(relativePath)) continue;
+
+        const outline = await this.outline(relativePath);
+        const symbols = kind ? outline.symbols.filter(symbol => symbol.kind === kind) : outline.symbols;
+        if (symbols.length > 0) files.push({ file: relativePath, symbols });
+      }
+      this.cache.save();
+      result = { files: files.length, symbols: files.reduce((sum, file) => sum + file.symbols.length, 0), outlines: files };
+    }
+
+    return { contentType: CONTENT_TYPES.json, body: JSON.stringify(result, null, 2) };
+  }
+
+  /**
+   * Context of the chunks most relevant to a natural-language query
+   * @param {Object} body - query, top, embeddings, plus the context() params
+   * @returns {Promise<{contentType: string, body: string}>}
+   */
+  async query(body = {}) {
+    const text = typeof body.query === 'string' ? body.query.trim() : '';
+    if (!text) {
+      throw httpError(400, 'Missing "query"');
+    }
+    if (body.focus || body.symbol || body.pairTests) {
+      throw httpError(400, 'query cannot be combined with focus, symbol or pairTests');
+    }
+
+    const options = await this.analysisOptions(body);
+    const index = this.semanticIndex(body.embeddings || null);
+    const calculator = this.createCalculator(options);
+
+    // Earlier exports are outputs, not sources to search
+    const outputs = Object.values(DEFAULT_OUTPUTS);
+    const files = calculator.scanDirectory(this.projectRoot)
+      .map(filePath => ({ filePath, relativePath: path.relative(this.projectRoot, filePath).split(path.sep).join('/') }))
+      .filter(({ relativePath }) => !outputs.includes(relativePath) && !/^context\.(json|yaml|md)$/.test(relativePath))
+      .map(({ filePath, relativePath }) => ({ relativePath, content: nativeFileSystem.readText(filePath) }));
+
+    try {
+      calculator.options.query = {
+        text,
+        provider: index.provider.id,
+        stats: await index.build(files),
+        chunks: await index.search(text),
+        limit: body.top !== undefined ? parseCount(body.top, 'top') : null
+      };
+    } catch (error) {
+      throw error.statusCode ? error : httpError(502, `Embedding failed: ${error.message}`);
+    }
+
+    return this.render(calculator, calculator.selectExportResults(this.analyze(calculator)), body.format || 'gitingest');
+  }
+
+  /**
+   * TokenCalculator options for request parameters
+   * @private
+   */
+  async analysisOptions(params) {
+    await this.initialize();
+
+    const format = params.format || 'json';
+    if (!CONTEXT_FORMATS.includes(format)) {
+      throw httpError(400, `Invalid format: ${format} (expected ${CONTEXT_FORMATS.join(', ')})`);
+    }
+
+    const options = {
+      focus: params.focus || null,
+      expandDeps: params.depth !== undefined ? parseCount(params.depth, 'depth', 0) : null,
+      symbols: [].concat(params.symbol || []).flatMap(symbol => String(symbol).split(',')).filter(Boolean),
+      profile: null,
+      tokenBudget: null,
+      collapsible: params.collapsible === 'true' || params.collapsible === true,
+      xmlTags: null
+    };
+    try {
+      if (params.xmlTags) options.xmlTags = parseXmlTags(params.xmlTags);
+      options.fence = parseFenceChar(params.fence || 'backtick');
+      options.xmlContent = parseXmlContentMode(params.xmlContent || 'raw');
+    } catch (error) {
+      throw httpError(400, error.message);
+    }
+    if (options.focus && options.symbols.length > 0) {
+      throw httpError(400, 'symbol and focus cannot be combined');
+    }
+    if (params.pairTests) {
+      options.pairTests = params.pairTests === 'true' || params.pairTests === true ? 'full' : params.pairTests;
+      if (!PAIR_MODES.includes(options.pairTests)) {
+        throw httpError(400, `Invalid pairTests: ${params.pairTests} (expected ${PAIR_MODES.join(', ')})`);
+      }
+    }
+
+    let maxTokens = null;
+    let tokenizer = params.tokenizer || 'auto';
+    if (params.profile) {
+      try {
+        const profiles = ContextProfiles.load(this.projectRoot);
+        if (!profiles) throw new Error(`No context.yaml in ${this.projectRoot}`);
+        options.profile = profiles.resolve(params.profile);
+      } catch (error) {
+        throw httpError(400, error.message);
+      }
+      maxTokens = options.profile.budget;
+      tokenizer = params.tokenizer || options.profile.tokenizer || tokenizer;
+    }
+
+    if (params.maxTokens !== undefined) {
+      maxTokens = parseTokenCount(params.maxTokens);
+      if (!maxTokens) {
+        throw httpError(400, `Invalid maxTokens: ${params.maxTokens} (e.g. 8000, 32k, 1m)`);
+      }
+    }
+    if (params.tiers && !maxTokens) {
+      throw httpError(400, 'tiers requires maxTokens');
+    }
+    if (maxTokens) {
+      try {
+        options.tokenBudget = await TokenBudget.create({ maxTokens, tokenizer, tiers: params.tiers || null, cache: this.cache });
+      } catch (error) {
+        throw httpError(400, error.message);
+      }
+    }
+
+    return options;
+  }
+
+  /**
+   * @private
+   */
+  createCalculator(options) {
+    return new TokenCalculator(this.projectRoot, {
+      ...options,
+      symbolExtractor: this.extractor,
+      cache: this.cache,
+      dashboard: true // No console reports from a daemon
+    });
+  }
+
+  /**
+   * Analyze the current tree; unchanged files reuse cached token counts
+   * @private
+   */
+  analyze(calculator) {
+    const started = Date.now();
+    const results = calculator.analyzeFiles(calculator.scanDirectory(this.projectRoot));
+    this.cache.save();
+    calculator.scanDurationMs = Date.now() - started;
+    logger.debug(`Analyzed ${results.length} files in ${calculator.scanDurationMs}ms`);
+    return results;
+  }
+
+  /**
+   * @private
+   */
+  render(calculator, exportResults, format = 'json') {
+    let body;
+    if (format === 'gitingest') {
+      body = new GitIngestFormatter(this.projectRoot, calculator.stats, exportResults).generateDigest();
+    } else if (format === 'context') {
+      body = JSON.stringify(calculator.generateLLMContext(exportResults), null, 2);
+    } else {
+      body = calculator.createStructuredFormatter(exportResults).encode(format);
+    }
+    return { contentType: CONTENT_TYPES[format], body };
+  }
+
+  /**
+   * @private
+   */
+  async outline(relativePath) {
+    try {
+      return await this.symbolProvider.getOutline(this.projectRoot, relativePath);
+    } catch (error) {
+      if (error.code === 'ENOENT') throw httpError(404, `File not found: ${relativePath}`);
+      throw httpError(error.message.startsWith('Access denied') ? 403 : 400, error.message);
+    }
+  }
+
+  /**
+   * One index per embedding provider, so vectors are reused across queries
+   * @private
+   */
+  semanticIndex(providerName) {
+    let provider = providerName ? null : this.options.provider;
+    try {
+      provider = provider || EmbeddingProviderFactory.create(providerName || this.options.embeddings, {
+        model: this.options.embeddingModel,
+        baseUrl: this.options.embeddingUrl
+      });
+    } catch (error) {
+      throw httpError(400, error.message);
+    }
+
+    if (!this.indexes.has(provider.id)) {
+      this.indexes.set(provider.id, new SemanticIndex(provider, {
+        root: this.projectRoot,
+        extractor: this.extractor,
+        cache: this.cache
+      }));
+    }
+    return this.indexes.get(provider.id);
+  }
+}
+
+/**
+ * Parse an integer parameter of at least min
+ */
+function parseCount(value, name, min = 1) {
+  const count = Number(value);
+  if (!Number.isInteger(count) || count < min) {
+    throw httpError(400, `Invalid ${name}: ${value}`);
+  }
+  return count;
+}
+
+export default ContextService;
@@ -5,11 +5,14 @@
  * Responsibilities:
  * - HTTP server for Ctxman API
  * - RESTful endpoints
+ * - Context daemon endpoints: /context, /symbols, /query (v3.4.0)
+ * - Request and context metrics for a local Prometheus endpoint (v3.4.0, --metrics)
  * - WebSocket support for watch mode
  * - Authentication (optional)
  */
 
 import http from 'http';
+import path from 'path';
 import { URL } from 'url';
 import { getLogger } from '../../utils/logger.js';
 import Scanner from '../../core/Scanner.js';
@@ -18,6 +21,7 @@ import ContextBuilder from '../../core/ContextBuilder.js';
 import Reporter from '../../core/Reporter.js';
 import GitClient from '../../integrations/git/GitClient.js';
 import DiffAnalyzer from '../../integrations/git/DiffAnalyzer.js';
+import ContextService, { httpError } from './context.js';
 
 const logger = getLogger('APIServer');
 
@@ -28,11 +32,26 @@ export class APIServer {
       host: 'localhost',
       authToken: null,
       cors: true,
+      projectRoot: process.cwd(), // Project served by /context, /symbols and /query
+      service: {}, // ContextService options (symbolBackend, cache, embeddings, ...)
+      metrics: null, // ContextMetrics counting requests and generated contexts (--metrics)
       ...options
     };
 
     this.server = null;
     this.isRunning = false;
+    this.contextService = null;
+  }
+
+  /**
+   * Context service for the served project, created on first use
+   * @returns {ContextService}
+   */
+  getContextService() {
+    if (!this.contextService) {
+      this.contextService = new ContextService(this.options.projectRoot, { metrics: this.options.metrics, ...this.options.service });
+    }
+    return this.contextService;
   }
 
   /**
@@ -54,6 +73,8 @@ export class APIServer {
       console.log(`\n🌐 Ctxman API Server`);
       console.log(`   Listening on: http://${this.options.host}:${this.options.port}`);
       console.log(`   API Version: v1`);
+      console.log(`   Project: ${this.options.projectRoot}`);
+      console.log(`   Context: GET /context, GET /symbols, POST /query`);
       console.log(`   Documentation: http://${this.options.host}:${this.options.port}/api/v1/docs`);
       console.log();
     });
@@ -88,6 +109,14 @@ export class APIServer {
    * @param {http.ServerResponse} res
    */
   async handleRequest(req, res) {
+    // Every answer counts, unauthorized ones too; known routes only, so
+    // unknown paths cannot grow the label set (v3.4.0, --metrics)
+    if (this.options.metrics) {
+      const { pathname } = new URL(req.url, 'http://localhost');
+      const route = ['/context', '/symbols', '/query'].includes(pathname) || /^\/api\/v1\/\w+$/.test(pathname) ? pathname : 'other';
+      res.once('finish', () => this.options.metrics.recordRequest(route, res.statusCode));
+    }
+
     // CORS headers
     if (this.options.cors) {
       res.setHeader('Access-Control-Allow-Origin', '*');
@@ -117,15 +146,49 @@ export class APIServer {
       // Route to handlers
       if (pathname.startsWith('/api/v1')) {
         await this.handleAPIv1(req, res, pathname, url);
+      } else if (['/context', '/symbols', '/query'].includes(pathname)) {
+        await this.handleContextAPI(req, res, pathname, url);
       } else {
         this.sendError(res, 404, 'Not Found');
       }
     } catch (error) {
-      logger.error(`Request error: ${error.message}`);
-      this.sendError(res, 500, error.message);
+      const statusCode = error.statusCode || 500;
+      if (statusCode >= 500) {
+        logger.error(`Request error: ${error.message}`);
+      }
+      this.sendError(res, statusCode, error.message);
     }
   }
 
+  /**
+   * Handle context daemon routes (v3.4.0)
+   * Every request rescans the project, so responses follow the working tree.
+   * @param {http.IncomingMessage} req
+   * @param {http.ServerResponse} res
+   * @param {string} pathname
+   * @param {URL} url
+   */
+  async handleContextAPI(req, res, pathname, url) {
+    const service = this.getContextService();
+    const params = queryParams(url);
+    const routes = {
+      '/context': ['GET', () => service.context(params)],
+      '/symbols': ['GET', () => service.symbols(params)],
+      '/query': ['POST', async () => service.query({ ...params, ...await this.parseBody(req) })]
+    };
+
+    const [method, handler] = routes[pathname];
+    if (req.method !== method) {
+      res.setHeader('Allow', method);
+      this.sendError(res, 405, `Method not allowed: use ${method} ${pathname}`);
+      return;
+    }
+
+    const { contentType, body } = await handler();
+    res.writeHead(200, { 'Content-Type': contentType });
+    res.end(body);
+  }
+
   /**
    * Handle API v1 routes
    * @param {http.IncomingMessage} req
@@ -283,6 +346,44 @@ export class APIServer {
     const docs = {
       version: 'v1',
       endpoints: [
+        {
+          path: '/context',
+          method: 'GET',
+          description: 'Fresh context for the served project',
+          parameters: {
+            format: 'json, yaml, gitingest or context (default: json)',
+            maxTokens: 'Token budget (e.g. 32k)',
+            tokenizer: 'auto, cl100k_base, o200k_base, claude, estimate',
+            tiers: 'Budget fallbacks (e.g. full,signatures,names)',
+            focus: 'Focus symbol; depth sets the dependency depth',
+            symbol: 'Symbols to slice (repeat or comma-separate)',
+            profile: 'Profile from context.yaml',
+            pairTests: 'Add paired tests and sources: full (or true) or names'
+          }
+        },
+        {
+          path: '/symbols',
+          method: 'GET',
+          description: 'Symbol outlines of the served project',
+          parameters: {
+            file: 'Outline of one file (relative path)',
+            name: 'Definitions of a symbol, with source',
+            kind: 'Restrict to a symbol kind',
+            limit: 'Maximum definitions (with name, default: 10)'
+          }
+        },
+        {
+          path: '/query',
+          method: 'POST',
+          description: 'Context of the chunks most relevant to a natural-language query',
+          body: {
+            query: 'Question or description (required)',
+            embeddings: 'transformers, openai, ollama or local',
+            top: 'Maximum chunks (default: 10, or all that fit maxTokens)',
+            maxTokens: 'Token budget',
+            format: 'gitingest, json, yaml or context (default: gitingest)'
+          }
+        },
         {
           path: '/api/v1/analyze',
           method: 'GET',
@@ -369,7 +470,7 @@ export class APIServer {
           const parsed = JSON.parse(body);
           resolve(parsed);
         } catch (error) {
-          reject(new Error('Invalid JSON'));
+          reject(httpError(400, 'Invalid JSON'));
         }
       });
 
@@ -402,4 +503,18 @@ export class APIServer {
   }
 }
 
+/**
+ * Query string as an object; repeated keys become arrays
+ * @param {URL} url
+ * @returns {Object}
+ */
+function queryParams(url) {
+  const params = {};
+  for (const key of new Set(url.searchParams.keys())) {
+    const values = url.searchParams.getAll(key);
+    params[key] = values.length > 1 ? values : values[0];
+  }
+  return params;
+}
+
 export default APIServer;
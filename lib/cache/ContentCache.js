@@ -0,0 +1,413 @@
+/**
+ * ContentCache - Content-addressed analysis cache
+ * v3.4.0 - Incremental context cache
+ *
+ * Responsibilities:
+ * - Key entries by SHA-256 of file content (renames and checkouts keep hits)
+ * - Store token counts per tokenizer, symbol outlines per backend and
+ *   remote summaries per endpoint and model
+ * - Remember where referenced symbol IDs moved (renames, file moves)
+ * - Leave outlines to a bounded ParseCache when given one (v3.4.0)
+ * - Persist to a single JSON file under .ctxman/cache
+ * - Prune entries not used recently
+ */
+
+import fs from 'fs';
+import path from 'path';
+import crypto from 'crypto';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ContentCache');
+
+// Bump when token counting or symbol extraction output changes shape
+export const CONTENT_CACHE_VERSION = 3;
+
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+export class ContentCache {
+  constructor(options = {}) {
+    this.options = {
+      root: process.cwd(),
+      path: path.join('.ctxman', 'cache', 'content-cache.json'), // Relative to root; null = memory only
+      maxAgeDays: 30,
+      maxEntries: 50000,
+      parseCache: null, // ParseCache holding outlines instead of the entries; for memory-only caches
+      ...options
+    };
+
+    this.entries = new Map();
+    this.symbolIds = new Map();
+    this.loaded = false;
+    this.dirty = false;
+    this.stats = {
+      hits: 0,
+      misses: 0,
+      writes: 0,
+      pruned: 0
+    };
+  }
+
+  /**
+   * SHA-256 hex digest of content
+   * @param {string} content
+   * @returns {string}
+   */
+  static hash(content) {
+    return crypto.createHash('sha256').update(content).digest('hex');
+  }
+
+  /**
+   * Cache key for a per-file computation (results can differ by extension)
+   * @param {string} name - Tokenizer or backend name
+   * @param {string} filePath
+   * @returns {string}
+   */
+  static key(name, filePath) {
+    return `${name}:${path.extname(filePath || '').toLowerCase()}`;
+  }
+
+  /**
+   * Absolute cache file path (null when memory only)
+   * @returns {string|null}
+   */
+  getFilePath() {
+    if (!this.options.path) return null;
+    return path.resolve(this.options.root, this.options.path);
+  }
+
+  /**
+   * Load entries from disk (once); a corrupt or outdated file starts empty
+   * @returns {ContentCache}
+   */
+  load() {
+    if (this.loaded) return this;
+    this.loaded = true;
+
+    const filePath = this.getFilePath();
+    if (!filePath || !fs.existsSync(filePath)) return this;
+
+    try {
+      const data = JSON.parse(fs.readFileSync(filePath, 'utf-8'));
+      if (data.version !== CONTENT_CACHE_VERSION) {
+        logger.info(`Content cache version changed (${data.version} -> ${CONTENT_CACHE_VERSION}), rebuilding`);
+        this.dirty = true;
+        return this;
+      }
+
+      for (const [hash, entry] of Object.entries(data.entries || {})) {
+        this.entries.set(hash, entry);
+      }
+      for (const [id, currentId] of Object.entries(data.symbolIds || {})) {
+        this.symbolIds.set(id, currentId);
+      }
+      logger.debug(`Loaded ${this.entries.size} cache entries from ${filePath}`);
+    } catch (error) {
+      logger.warn(`Ignoring unreadable content cache: ${error.message}`);
+      this.dirty = true;
+    }
+
+    return this;
+  }
+
+  /**
+   * Get the entry for a hash, marking it as used
+   * @private
+   */
+  getEntry(hash, create = false) {
+    this.load();
+
+    let entry = this.entries.get(hash);
+    if (!entry && create) {
+      entry = { tokens: {}, symbols: {} };
+      this.entries.set(hash, entry);
+    }
+    if (entry) {
+      const now = Date.now();
+      // Persist usage at most daily so hit-only runs keep entries from being pruned
+      if (!entry.usedAt || now - entry.usedAt > DAY_MS) {
+        this.dirty = true;
+      }
+      entry.usedAt = now;
+    }
+    return entry;
+  }
+
+  /**
+   * Cached token count, computing it on a miss
+   * @param {string} hash - Content hash
+   * @param {string} tokenizer - Tokenizer key (counts differ per tokenizer)
+   * @param {Function} compute - () => number
+   * @returns {number}
+   */
+  tokens(hash, tokenizer, compute) {
+    const entry = this.getEntry(hash, true);
+
+    if (entry.tokens[tokenizer] !== undefined) {
+      this.stats.hits++;
+      return entry.tokens[tokenizer];
+    }
+
+    this.stats.misses++;
+    entry.tokens[tokenizer] = compute();
+    this.markWritten();
+    return entry.tokens[tokenizer];
+  }
+
+  /**
+   * Cached symbol outline, computing it on a miss
+   * Symbols are stored without their file path, so identical content at a
+   * different path (renames, copies) is still a hit.
+   * @param {string} hash - Content hash
+   * @param {string} key - Backend/extension key (e.g. "heuristic:.ts")
+   * @param {string} filePath - Path recorded on returned symbols
+   * @param {Function} compute - () => Array<Symbol>
+   * @returns {Array<Symbol>}
+   */
+  symbols(hash, key, filePath, compute) {
+    const { parseCache } = this.options;
+    if (parseCache) {
+      const cached = parseCache.get(`${hash}:${key}`, filePath);
+      if (cached) {
+        this.stats.hits++;
+        return cached;
+      }
+      this.stats.misses++;
+      const symbols = compute();
+      parseCache.set(`${hash}:${key}`, symbols);
+      return symbols;
+    }
+
+    const entry = this.getEntry(hash, true);
+
+    if (entry.symbols[key]) {
+      this.stats.hits++;
+      return entry.symbols[key].map(symbol => ({ ...symbol, file: filePath }));
+    }
+
+    this.stats.misses++;
+    const symbols = compute();
+    entry.symbols[key] = symbols.map(({ file, ...symbol }) => symbol);
+    this.markWritten();
+    return symbols;
+  }
+
+  /**
+   * Cached remote summary (see RemoteSummarizer)
+   * @param {string} hash - Content hash
+   * @param {string} key - Endpoint and model the summary came from
+   * @returns {string|null}
+   */
+  summary(hash, key) {
+    const entry = this.getEntry(hash);
+    const summary = entry?.summaries?.[key];
+    if (summary === undefined) {
+      this.stats.misses++;
+      return null;
+    }
+    this.stats.hits++;
+    return summary;
+  }
+
+  /**
+   * Record a remote summary
+   * @param {string} hash - Content hash
+   * @param {string} key - Endpoint and model the summary came from
+   * @param {string} summary
+   */
+  setSummary(hash, key, summary) {
+    const entry = this.getEntry(hash, true);
+    entry.summaries = { ...entry.summaries, [key]: summary };
+    this.markWritten();
+  }
+
+  /**
+   * Current ID recorded for a symbol ID (see SymbolIdIndex)
+   * @param {string} id
+   * @returns {string|null}
+   */
+  symbolId(id) {
+    this.load();
+    return this.symbolIds.get(id) || null;
+  }
+
+  /**
+   * Record the current ID of a symbol ID; earlier IDs remapped to it follow
+   * @param {string} id
+   * @param {string} currentId
+   */
+  setSymbolId(id, currentId) {
+    this.load();
+    for (const [original, mapped] of this.symbolIds) {
+      if (mapped === id) this.symbolIds.set(original, currentId);
+    }
+    if (this.symbolIds.get(id) !== currentId) {
+      this.symbolIds.set(id, currentId);
+      this.markWritten();
+    }
+  }
+
+  /**
+   * Qualified names of the symbols in every cached outline, for completion
+   * Outlines are kept by content, so names of older versions can appear.
+   * @returns {Set<string>}
+   */
+  symbolNames() {
+    this.load();
+    const names = new Set();
+    for (const entry of this.entries.values()) {
+      for (const symbols of Object.values(entry.symbols || {})) {
+        for (const symbol of symbols) {
+          if (symbol.kind !== 'module') names.add(symbol.qualifiedName);
+        }
+      }
+    }
+    return names;
+  }
+
+  /**
+   * Token counts of entries used since a time, e.g. to seed or collect the
+   * memory-only cache of a worker thread
+   * @param {number} usedSince - Epoch ms (0 = all entries)
+   * @returns {Object} { hash: { tokens: { tokenizerKey: count }, usedAt } }
+   */
+  snapshotTokens(usedSince = 0) {
+    this.load();
+    const snapshot = {};
+    for (const [hash, entry] of this.entries) {
+      if (Object.keys(entry.tokens).length > 0 && (entry.usedAt || 0) >= usedSince) {
+        snapshot[hash] = { tokens: entry.tokens, usedAt: entry.usedAt || 0 };
+      }
+    }
+    return snapshot;
+  }
+
+  /**
+   * Add token counts from another cache's snapshot, keeping the later use time
+   * @param {Object} snapshot - Result of snapshotTokens()
+   * @param {Object} stats - The other cache's stats, added to these
+   */
+  mergeTokens(snapshot, stats = null) {
+    this.load();
+
+    for (const [hash, { tokens, usedAt }] of Object.entries(snapshot)) {
+      if (!this.entries.has(hash)) {
+        this.entries.set(hash, { tokens: {}, symbols: {} });
+      }
+      const entry = this.entries.get(hash);
+
+      for (const [tokenizer, count] of Object.entries(tokens)) {
+        if (entry.tokens[tokenizer] === undefined) {
+          entry.tokens[tokenizer] = count;
+          this.markWritten();
+        }
+      }
+      if (usedAt > (entry.usedAt || 0)) {
+        if (usedAt - (entry.usedAt || 0) > DAY_MS) this.dirty = true;
+        entry.usedAt = usedAt;
+      }
+    }
+
+    if (stats) {
+      this.stats.hits += stats.hits;
+      this.stats.misses += stats.misses;
+    }
+  }
+
+  /**
+   * @private
+   */
+  markWritten() {
+    this.stats.writes++;
+    this.dirty = true;
+  }
+
+  /**
+   * Drop entries unused for maxAgeDays, then the least recently used over maxEntries
+   * @returns {number} Entries removed
+   */
+  prune() {
+    const cutoff = Date.now() - this.options.maxAgeDays * DAY_MS;
+    let removed = 0;
+
+    for (const [hash, entry] of this.entries) {
+      if ((entry.usedAt || 0) < cutoff) {
+        this.entries.delete(hash);
+        removed++;
+      }
+    }
+
+    if (this.entries.size > this.options.maxEntries) {
+      const byAge = [...this.entries].sort((a, b) => (a[1].usedAt || 0) - (b[1].usedAt || 0));
+      for (const [hash] of byAge.slice(0, this.entries.size - this.options.maxEntries)) {
+        this.entries.delete(hash);
+        removed++;
+      }
+    }
+
+    if (removed > 0) {
+      this.stats.pruned += removed;
+      this.dirty = true;
+    }
+    return removed;
+  }
+
+  /**
+   * Write entries to disk if anything changed (atomic rename)
+   * @returns {boolean} True if the file was written
+   */
+  save() {
+    const filePath = this.getFilePath();
+    if (!filePath || !this.loaded) return false;
+
+    this.prune();
+    if (!this.dirty) return false;
+
+    try {
+      fs.mkdirSync(path.dirname(filePath), { recursive: true });
+      const tmpPath = `${filePath}.${process.pid}.tmp`;
+      fs.writeFileSync(tmpPath, JSON.stringify({
+        version: CONTENT_CACHE_VERSION,
+        savedAt: new Date().toISOString(),
+        entries: Object.fromEntries(this.entries),
+        symbolIds: Object.fromEntries(this.symbolIds)
+      }));
+      fs.renameSync(tmpPath, filePath);
+      this.dirty = false;
+      logger.debug(`Saved ${this.entries.size} cache entries to ${filePath}`);
+      return true;
+    } catch (error) {
+      logger.error(`Failed to save content cache: ${error.message}`);
+      return false;
+    }
+  }
+
+  /**
+   * Remove all entries and the cache file
+   */
+  clear() {
+    this.entries.clear();
+    this.symbolIds.clear();
+    this.loaded = true;
+    this.dirty = false;
+
+    const filePath = this.getFilePath();
+    if (filePath && fs.existsSync(filePath)) {
+      fs.unlinkSync(filePath);
+    }
+    logger.info('Content cache cleared');
+  }
+
+  /**
+   * @returns {Object} Hits, misses, writes, pruned, entries and hit rate (%)
+   */
+  getStats() {
+    const total = this.stats.hits + this.stats.misses;
+    return {
+      ...this.stats,
+      entries: this.entries.size,
+      hitRate: total > 0 ? Number(((this.stats.hits / total) * 100).toFixed(1)) : 0
+    };
+  }
+}
+
+export default ContentCache;
@@ -0,0 +1,95 @@
+/**
+ * ContentChunker - Content-defined chunks of large files
+ * v3.4.0 - Chunk-level summary caching
+ *
+ * Responsibilities:
+ * - Split text where a gear rolling hash over the last characters meets a
+ *   mask, so chunk boundaries depend on nearby content and not on offsets:
+ *   an edit moves at most the boundaries next to it, and every other chunk
+ *   keeps its content and hash
+ * - End chunks at line ends, between a minimum and a maximum size
+ *
+ * Each character shifts the hash one bit, so a boundary depends on the 32
+ * characters before it. The gear table is fixed: chunks, and the cache
+ * entries keyed by their hashes, stay the same across runs and versions.
+ */
+
+import ContentCache from './ContentCache.js';
+
+// 256 fixed pseudo-random 32-bit values (mulberry32, seed 0x6d2b79f5)
+const GEAR = (() => {
+  let seed = 0x6d2b79f5;
+  return Uint32Array.from({ length: 256 }, () => {
+    seed = (seed + 0x6d2b79f5) >>> 0;
+    let t = seed;
+    t = Math.imul(t ^ (t >>> 15), t | 1);
+    t ^= t + Math.imul(t ^ (t >>> 7), t | 61);
+    return (t ^ (t >>> 14)) >>> 0;
+  });
+})();
+
+export class ContentChunker {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      minChars: 2000, // No boundary before this many characters
+      averageChars: 6000, // Expected chunk size
+      maxChars: 16000, // Chunks end here at the latest (at the last line end when there is one)
+      ...options
+    };
+    // A boundary is due with probability 1 / (mask + 1) per character after minChars
+    const bits = Math.max(1, Math.round(Math.log2(Math.max(2, this.options.averageChars - this.options.minChars))));
+    this.mask = (2 ** bits - 1) >>> 0;
+  }
+
+  /**
+   * Split text into content-defined chunks
+   * @param {string} text
+   * @returns {Array<{text: string, start: number, hash: string}>} Chunks in order; start is a character offset
+   */
+  split(text) {
+    const { minChars, maxChars } = this.options;
+    const chunks = [];
+    let start = 0;
+    let hash = 0;
+    let due = false;
+    let lastLineEnd = -1;
+
+    const cut = end => {
+      const chunk = text.slice(start, end);
+      chunks.push({ text: chunk, start, hash: ContentCache.hash(chunk) });
+      start = end;
+      due = false;
+      lastLineEnd = -1;
+    };
+
+    for (let i = 0; i < text.length; i++) {
+      const code = text.charCodeAt(i);
+      hash = ((hash << 1) + GEAR[code & 0xff]) >>> 0;
+      const size = i + 1 - start;
+
+      if (code === 10) {
+        if (due) {
+          cut(i + 1);
+          continue;
+        }
+        lastLineEnd = i + 1;
+      }
+      if (size >= minChars && (hash & this.mask) === 0) {
+        // End the chunk with its line
+        due = true;
+      }
+      if (size >= maxChars) {
+        cut(lastLineEnd > start ? lastLineEnd : i + 1);
+      }
+    }
+    if (start < text.length) {
+      cut(text.length);
+    }
+    return chunks;
+  }
+}
+
+export default ContentChunker;
@@ -0,0 +1,139 @@
+/**
+ * ParseCache - Bounded in-memory cache of parsed symbol outlines
+ * v3.4.0 - Parse cache for long-running modes (--ast-cache)
+ *
+ * Responsibilities:
+ * - Hold the symbol outlines of a memory-only ContentCache, keyed by
+ *   content hash and backend, so the daemon, watch mode and the servers
+ *   parse a hot file once instead of on every request
+ * - Evict the least recently used outlines beyond a byte or entry limit,
+ *   where the entries of a ContentCache would grow with every file version
+ * - Count hits, misses and evictions
+ *
+ * Outlines are stored as frozen copies without their file, and every hit
+ * returns new symbol objects: requests running side by side can change
+ * the symbols they got without changing what another request sees. A get
+ * or set never yields to other work, so an entry is never seen half written.
+ * Sizes are estimates (the UTF-16 size of the outline's JSON); an outline
+ * larger than maxBytes is not kept.
+ */
+
+export const DEFAULT_PARSE_CACHE_BYTES = 64 * 1024 * 1024;
+
+export class ParseCache {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      maxBytes: DEFAULT_PARSE_CACHE_BYTES, // Estimated size of the outlines kept; 0 = none
+      maxEntries: 20000, // Outlines kept
+      ...options
+    };
+
+    // Insertion order is recency order: get() moves an entry to the end
+    this.entries = new Map();
+    this.bytes = 0;
+    this.stats = {
+      hits: 0,
+      misses: 0,
+      evictions: 0
+    };
+  }
+
+  /**
+   * @returns {boolean} Whether outlines are kept at all
+   */
+  isEnabled() {
+    return this.options.maxBytes > 0 && this.options.maxEntries > 0;
+  }
+
+  /**
+   * Outline cached for a key, marking it as most recently used
+   * @param {string} key - Content hash and ContentCache.key() of the backend
+   * @param {string} filePath - Path recorded on the returned symbols
+   * @returns {Array<Symbol>|null}
+   */
+  get(key, filePath) {
+    const entry = this.entries.get(key);
+    if (!entry) {
+      this.stats.misses++;
+      return null;
+    }
+
+    this.entries.delete(key);
+    this.entries.set(key, entry);
+    this.stats.hits++;
+    return entry.symbols.map(symbol => ({ ...symbol, file: filePath }));
+  }
+
+  /**
+   * Keep an outline, evicting the least recently used ones over the limits
+   * @param {string} key
+   * @param {Array<Symbol>} symbols
+   * @returns {boolean} Whether the outline was kept
+   */
+  set(key, symbols) {
+    if (!this.isEnabled()) return false;
+
+    const stored = Object.freeze(symbols.map(({ file, ...symbol }) => Object.freeze(symbol)));
+    const bytes = JSON.stringify(stored).length * 2;
+    this.delete(key);
+    if (bytes > this.options.maxBytes) return false;
+
+    this.entries.set(key, { symbols: stored, bytes });
+    this.bytes += bytes;
+    for (const [oldest, entry] of this.entries) {
+      if (this.bytes <= this.options.maxBytes && this.entries.size <= this.options.maxEntries) break;
+      this.entries.delete(oldest);
+      this.bytes -= entry.bytes;
+      this.stats.evictions++;
+    }
+    return true;
+  }
+
+  /**
+   * @param {string} key
+   * @returns {boolean} Whether an outline was removed
+   */
+  delete(key) {
+    const entry = this.entries.get(key);
+    if (!entry) return false;
+    this.entries.delete(key);
+    this.bytes -= entry.bytes;
+    return true;
+  }
+
+  clear() {
+    this.entries.clear();
+    this.bytes = 0;
+  }
+
+  /**
+   * One-line summary of getStats()
+   * @param {Object} stats
+   * @returns {string}
+   */
+  static describe(stats) {
+    const megabytes = bytes => `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+    return `Parse cache: ${stats.entries.toLocaleString()} outlines, ${megabytes(stats.bytes)} of ${megabytes(stats.maxBytes)}, ` +
+      `${stats.hitRate}% hits, ${stats.evictions.toLocaleString()} evicted`;
+  }
+
+  /**
+   * @returns {Object} Entries, bytes, limits, hits, misses, evictions and hit rate (%)
+   */
+  getStats() {
+    const total = this.stats.hits + this.stats.misses;
+    return {
+      ...this.stats,
+      entries: this.entries.size,
+      bytes: this.bytes,
+      maxBytes: this.options.maxBytes,
+      maxEntries: this.options.maxEntries,
+      hitRate: total > 0 ? Number(((this.stats.hits / total) * 100).toFixed(1)) : 0
+    };
+  }
+}
+
+export default ParseCache;
@@ -3,10 +3,13 @@
  * v3.0.0 - Modular architecture
  */
 
-import fs from 'fs';
 import TokenUtils from '../utils/token-utils.js';
 import FileUtils from '../utils/file-utils.js';
 import MethodAnalyzer from '../analyzers/method-analyzer.js';
+import SymbolExtractor from '../symbols/SymbolExtractor.js';
+import ContentCache from '../cache/ContentCache.js';
+import { nativeFileSystem } from './FileSystem.js';
+import { countLines } from './FileReader.js';
 import { getLogger } from '../utils/logger.js';
 
 const logger = getLogger('Analyzer');
@@ -15,12 +18,17 @@ export class Analyzer {
   constructor(options = {}) {
     this.options = {
       methodLevel: false,
+      symbols: false,
+      symbolBackend: 'auto',
+      cache: null, // ContentCache instance, or true for .ctxman/cache in cwd
       parallel: false,
       maxWorkers: 4,
       ...options
     };
 
     this.methodAnalyzer = new MethodAnalyzer();
+    this.symbolExtractor = new SymbolExtractor({ backend: this.options.symbolBackend });
+    this.contentCache = this.options.cache === true ? new ContentCache() : this.options.cache || null;
     this.stats = this.initStats();
   }
 
@@ -30,6 +38,7 @@ export class Analyzer {
       totalTokens: 0,
       totalSize: 0,
       totalMethods: 0,
+      totalSymbols: 0,
       byLanguage: {},
       largestFiles: [],
       analysisTime: 0
@@ -42,8 +51,17 @@ export class Analyzer {
 
     this.reset();
 
+    if (this.options.symbols) {
+      await this.symbolExtractor.initialize();
+    }
+
     const results = await this.analyzeSequential(files);
 
+    if (this.contentCache) {
+      this.contentCache.save();
+      this.stats.cache = this.contentCache.getStats();
+    }
+
     this.stats.analysisTime = Date.now() - startTime;
     logger.info(`Analysis complete in ${this.stats.analysisTime}ms`);
 
@@ -70,12 +88,16 @@ export class Analyzer {
   async analyzeFile(fileInfo) {
     try {
       // Skip if not a text file
-      if (!FileUtils.isText(fileInfo.path)) {
+      if (!FileUtils.isText(fileInfo.path) && !FileUtils.isScript(fileInfo.path)) {
         return null;
       }
 
-      const content = fs.readFileSync(fileInfo.path, 'utf-8');
-      const tokens = TokenUtils.calculate(content, fileInfo.path);
+      const content = nativeFileSystem.readText(fileInfo.path);
+      const hash = this.contentCache ? ContentCache.hash(content) : null;
+      const tokens = hash
+        ? this.contentCache.tokens(hash, ContentCache.key(this.getTokenizerKey(), fileInfo.path),
+          () => TokenUtils.calculate(content, fileInfo.path))
+        : TokenUtils.calculate(content, fileInfo.path);
 
       const analysis = {
         path: fileInfo.path,
@@ -84,16 +106,28 @@ export class Analyzer {
         extension: fileInfo.extension,
         size: fileInfo.size,
         tokens: tokens,
-        lines: content.split('\n').length,
+        lines: countLines(content),
         language: this.detectLanguage(fileInfo.extension)
       };
 
+      if (hash) {
+        analysis.hash = hash;
+      }
+
       if (this.options.methodLevel) {
         const methods = this.methodAnalyzer.extractMethods(content, fileInfo.path);
         analysis.methods = methods;
         analysis.methodCount = methods.length;
       }
 
+      if (this.options.symbols && this.symbolExtractor.supports(fileInfo.path, content)) {
+        const symbolPath = fileInfo.relativePath || fileInfo.path;
+        analysis.symbols = hash
+          ? this.contentCache.symbols(hash, ContentCache.key(this.symbolExtractor.getBackend(symbolPath, content), symbolPath),
+            symbolPath, () => this.symbolExtractor.extract(content, symbolPath))
+          : this.symbolExtractor.extract(content, symbolPath);
+      }
+
       return analysis;
 
     } catch (error) {
@@ -102,6 +136,14 @@ export class Analyzer {
     }
   }
 
+  /**
+   * Token counting method, used to keep cached counts per tokenizer
+   * @returns {string}
+   */
+  getTokenizerKey() {
+    return TokenUtils.hasExactCounting() ? 'tiktoken' : 'estimate';
+  }
+
   updateStats(analysis) {
     this.stats.totalFiles++;
     this.stats.totalTokens += analysis.tokens;
@@ -131,6 +173,10 @@ export class Analyzer {
     if (analysis.methods) {
       this.stats.totalMethods += analysis.methodCount || 0;
     }
+
+    if (analysis.symbols) {
+      this.stats.totalSymbols += analysis.symbols.length;
+    }
   }
 
   detectLanguage(extension) {
@@ -0,0 +1,313 @@
+/**
+ * Benchmark - Timings and memory of the context pipeline
+ * v3.4.0 - Benchmark subcommand (ctxman bench)
+ *
+ * Responsibilities:
+ * - Run the scan, analyze, parse, pack and format phases against a project
+ * - Record wall time, heap and RSS per phase over repeated iterations
+ * - Sample the bytes each phase allocates, freed ones included, and the
+ *   file read buffers it allocates outside the heap (--allocations)
+ * - Capture CPU and sampling heap profiles of the measured iterations, in
+ *   pprof format or as V8 .cpuprofile/.heapprofile files
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { performance } from 'perf_hooks';
+import { Session } from 'inspector/promises';
+import { cpuProfileToPprof, heapProfileToPprof } from './Pprof.js';
+
+export const BENCH_PHASES = ['scan', 'analyze', 'parse', 'pack', 'format'];
+
+const MB = 1024 * 1024;
+
+// Count allocations the GC has already freed, not only what is live at the end
+const SAMPLE_FREED = { includeObjectsCollectedByMajorGC: true, includeObjectsCollectedByMinorGC: true };
+
+export class Benchmark {
+  constructor(options = {}) {
+    this.options = {
+      iterations: 1, // Measured runs; timings report their median
+      warmup: 0, // Unmeasured runs first (JIT, file system cache)
+      cpuProfile: null, // Path of a CPU profile of the measured runs
+      heapProfile: null, // Path of a sampling heap profile of the measured runs
+      allocations: false, // Sample the allocations of each phase (not with heapProfile)
+      quiet: true, // Silence console.log inside phases, which would be timed too
+      ...options
+    };
+  }
+
+  /**
+   * @param {Function} createPipeline - () => [{ name, run(input), describe(output), buffers() }];
+   *   called once per iteration so no run reuses another's state; buffers
+   *   returns the bytes of read buffers allocated so far (optional)
+   * @returns {Promise<Object>} Report: { iterations, warmup, phases[], total, peakRss, profiles[] }
+   * @throws {Error} When allocations and heapProfile are both set
+   */
+  async run(createPipeline) {
+    const { iterations, warmup } = this.options;
+    if (this.options.allocations && this.options.heapProfile) {
+      throw new Error('Allocations and a heap profile cannot be sampled together');
+    }
+    for (let i = 0; i < warmup; i++) {
+      await this.runOnce(createPipeline());
+    }
+
+    const session = await this.startProfiling();
+    const runs = [];
+    try {
+      for (let i = 0; i < iterations; i++) {
+        runs.push(await this.runOnce(createPipeline(), session));
+      }
+    } finally {
+      this.profiles = await this.stopProfiling(session);
+    }
+
+    return this.summarize(runs);
+  }
+
+  /**
+   * @private
+   * @param {Array} pipeline
+   * @param {Session|null} [session] - Inspector session; allocations are sampled on it
+   * @returns {Promise<Array<{name, ms, heapDelta, rss, allocated, buffers, detail}>>}
+   */
+  async runOnce(pipeline, session = null) {
+    const measurements = [];
+    const log = console.log;
+    const sampling = Boolean(session && this.options.allocations);
+    let input;
+    try {
+      for (const phase of pipeline) {
+        global.gc?.();
+        const heapBefore = process.memoryUsage().heapUsed;
+        const buffersBefore = phase.buffers ? phase.buffers() : 0;
+        if (sampling) await session.post('HeapProfiler.startSampling', SAMPLE_FREED);
+        if (this.options.quiet) console.log = () => {};
+        const start = performance.now();
+        const output = await phase.run(input);
+        const ms = performance.now() - start;
+        console.log = log;
+        const allocated = sampling ? sampledBytes((await session.post('HeapProfiler.stopSampling')).profile.head) : null;
+
+        const memory = process.memoryUsage();
+        measurements.push({
+          name: phase.name,
+          ms,
+          heapDelta: memory.heapUsed - heapBefore,
+          rss: memory.rss,
+          allocated,
+          buffers: sampling && phase.buffers ? phase.buffers() - buffersBefore : null,
+          detail: phase.describe ? phase.describe(output) : null
+        });
+        input = output;
+      }
+    } finally {
+      console.log = log;
+    }
+    return measurements;
+  }
+
+  /**
+   * @private
+   */
+  summarize(runs) {
+    const names = runs[0]?.map(measurement => measurement.name) || [];
+    const phases = names.map((name, index) => {
+      const samples = runs.map(run => run[index]);
+      const times = samples.map(sample => sample.ms);
+      return {
+        name,
+        median: median(times),
+        min: Math.min(...times),
+        max: Math.max(...times),
+        heapDelta: median(samples.map(sample => sample.heapDelta)),
+        allocated: samples[0].allocated === null ? null : median(samples.map(sample => sample.allocated)),
+        buffers: samples[0].buffers === null ? null : median(samples.map(sample => sample.buffers)),
+        rss: samples[samples.length - 1].rss,
+        detail: samples[samples.length - 1].detail
+      };
+    });
+    const totals = runs.map(run => run.reduce((sum, measurement) => sum + measurement.ms, 0));
+
+    return {
+      iterations: runs.length,
+      warmup: this.options.warmup,
+      phases,
+      total: { median: median(totals), min: Math.min(...totals), max: Math.max(...totals) },
+      // maxRSS is reported in kilobytes
+      peakRss: process.resourceUsage().maxRSS * 1024,
+      profiles: this.profiles || []
+    };
+  }
+
+  /**
+   * @private
+   */
+  async startProfiling() {
+    const { cpuProfile, heapProfile, allocations } = this.options;
+    if (!cpuProfile && !heapProfile && !allocations) return null;
+
+    const session = new Session();
+    session.connect();
+    if (cpuProfile) {
+      await session.post('Profiler.enable');
+      await session.post('Profiler.start');
+    }
+    if (heapProfile || allocations) {
+      await session.post('HeapProfiler.enable');
+    }
+    if (heapProfile) {
+      await session.post('HeapProfiler.startSampling', SAMPLE_FREED);
+    }
+    return session;
+  }
+
+  /**
+   * @private
+   * @returns {Promise<Array<{kind, file, format}>>} Written profiles
+   */
+  async stopProfiling(session) {
+    if (!session) return [];
+    const { cpuProfile, heapProfile } = this.options;
+    const written = [];
+    try {
+      if (cpuProfile) {
+        const { profile } = await session.post('Profiler.stop');
+        written.push(writeProfile('cpu', cpuProfile, profile, '.cpuprofile', cpuProfileToPprof));
+      }
+      if (heapProfile) {
+        const { profile } = await session.post('HeapProfiler.stopSampling');
+        written.push(writeProfile('heap', heapProfile, profile, '.heapprofile', heapProfileToPprof));
+      }
+    } finally {
+      session.disconnect();
+    }
+    return written;
+  }
+
+  /**
+   * Text report: one row per phase, then totals and peak RSS
+   * @param {Object} report - From run()
+   * @returns {string}
+   */
+  static formatReport(report) {
+    const runs = `${report.iterations} iteration${report.iterations === 1 ? '' : 's'}` +
+      (report.warmup > 0 ? ` after ${report.warmup} warmup` : '');
+    const lines = [`⏱️  Benchmark (${runs})`, ''];
+    const allocations = report.phases.some(phase => phase.allocated !== null && phase.allocated !== undefined);
+    lines.push(`${'Phase'.padEnd(10)}${'median'.padStart(11)}${'min'.padStart(11)}${'max'.padStart(11)}${'heap Δ'.padStart(12)}${'rss'.padStart(11)}` +
+      `${allocations ? `${'alloc'.padStart(11)}${'buffers'.padStart(11)}` : ''}  Detail`);
+    for (const phase of report.phases) {
+      const allocated = allocations ? `${formatBytes(phase.allocated).padStart(11)}${formatBytes(phase.buffers || 0).padStart(11)}` : '';
+      lines.push(`${phase.name.padEnd(10)}${formatMs(phase.median).padStart(11)}${formatMs(phase.min).padStart(11)}` +
+        `${formatMs(phase.max).padStart(11)}${formatBytes(phase.heapDelta, true).padStart(12)}${formatBytes(phase.rss).padStart(11)}${allocated}  ${phase.detail || ''}`.trimEnd());
+    }
+    lines.push(`${'total'.padEnd(10)}${formatMs(report.total.median).padStart(11)}${formatMs(report.total.min).padStart(11)}${formatMs(report.total.max).padStart(11)}`);
+    lines.push('', `Peak RSS: ${formatBytes(report.peakRss)}`);
+    for (const profile of report.profiles) {
+      lines.push(`${profile.kind === 'cpu' ? 'CPU' : 'Heap'} profile: ${profile.file} (${profile.format})`);
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * The context pipeline of one TokenCalculator, phase by phase
+ * @param {TokenCalculator} calculator - Fresh calculator configured like an analysis run
+ * @returns {Array<{name, run, describe}>}
+ */
+export function contextPipeline(calculator) {
+  const { jobs, structuredFormat, template } = calculator.options;
+  const buffers = () => calculator.fileReader.stats.allocated;
+  let analysisResults;
+
+  return [
+    {
+      name: 'scan',
+      buffers,
+      run: () => calculator.scanProject(),
+      describe: files => `${files.length} files`
+    },
+    {
+      name: 'analyze',
+      buffers,
+      run: async files => {
+        analysisResults = await (jobs > 1 ? calculator.analyzeFilesParallel(files) : calculator.analyzeFiles(files));
+        return analysisResults;
+      },
+      describe: results => `${results.reduce((sum, fileInfo) => sum + (fileInfo.tokens || 0), 0).toLocaleString()} tokens`
+    },
+    {
+      name: 'parse',
+      buffers,
+      run: () => calculator.buildDependencyGraph(analysisResults).graph,
+      describe: graph => {
+        const symbols = [...graph.files.values()].reduce((sum, file) => sum + file.symbols.length, 0);
+        return `${symbols.toLocaleString()} symbols, ${graph.getStats().resolved.toLocaleString()} imports resolved`;
+      }
+    },
+    {
+      name: 'pack',
+      buffers,
+      run: () => calculator.selectExportResults(analysisResults) || [],
+      describe: results => `${results.length} files selected`
+    },
+    {
+      name: 'format',
+      buffers,
+      run: results => {
+        if (template) return template.render(calculator.createTemplateData(results));
+        if (structuredFormat) return calculator.createStructuredFormatter(results).encode(structuredFormat);
+        const digest = calculator.createGitIngestFormatter(results).generateDigest();
+        return Array.isArray(digest) ? digest.map(chunk => chunk.content).join('\n') : digest;
+      },
+      describe: output => `${(Buffer.byteLength(output) / 1024).toFixed(1)} KB ${template ? 'templated' : structuredFormat || 'digest'}`
+    }
+  ];
+}
+
+/**
+ * pprof unless the file names the V8 format (.cpuprofile / .heapprofile)
+ * @private
+ */
+function writeProfile(kind, filePath, profile, v8Extension, toPprof) {
+  const v8 = path.extname(filePath).toLowerCase() === v8Extension;
+  fs.mkdirSync(path.dirname(path.resolve(filePath)), { recursive: true });
+  fs.writeFileSync(filePath, v8 ? JSON.stringify(profile) : toPprof(profile));
+  return { kind, file: filePath, format: v8 ? 'v8' : 'pprof' };
+}
+
+/**
+ * Bytes of a sampling heap profile: the self sizes of all its nodes
+ * @private
+ */
+function sampledBytes(node) {
+  return node.selfSize + (node.children || []).reduce((sum, child) => sum + sampledBytes(child), 0);
+}
+
+/**
+ * @private
+ */
+function median(values) {
+  const sorted = [...values].sort((a, b) => a - b);
+  const middle = Math.floor(sorted.length / 2);
+  return sorted.length % 2 === 1 ? sorted[middle] : (sorted[middle - 1] + sorted[middle]) / 2;
+}
+
+/**
+ * @private
+ */
+function formatMs(ms) {
+  return ms >= 1000 ? `${(ms / 1000).toFixed(2)} s` : `${ms.toFixed(1)} ms`;
+}
+
+/**
+ * @private
+ */
+function formatBytes(bytes, signed = false) {
+  const sign = signed && bytes > 0 ? '+' : '';
+  return `${sign}${(bytes / MB).toFixed(1)} MB`;
+}
+
+export default Benchmark;
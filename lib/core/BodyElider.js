@@ -0,0 +1,178 @@
+/**
+ * BodyElider - Function body elision
+ * v3.4.0 - Signatures with elided bodies (--elide-bodies)
+ *
+ * Responsibilities:
+ * - Replace function and method bodies longer than N lines with a
+ *   `... N lines elided ...` comment, keeping signatures and doc comments
+ * - Pick the line limit per directory (the longest matching directory wins);
+ *   a limit of 0 keeps bodies
+ *
+ * Types keep their declarations and member signatures; only the bodies of
+ * functions and methods are elided, outermost first, so nested functions go
+ * with the body around them. Files without symbol support are left as they are.
+ */
+
+import path from 'path';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+
+// Marker comments by file extension; others use /* ... */
+const HASH_EXTENSIONS = new Set(['.py', '.pyi', '.rb', '.sh', '.bash', '.zsh', '.pl', '.r', '.ex', '.exs', '.ps1']);
+const DASH_EXTENSIONS = new Set(['.lua', '.sql', '.hs']);
+
+// Bodies that end with their block's indentation rather than a closing line
+const INDENTED_EXTENSIONS = new Set(['.py', '.pyi']);
+
+const BODY_KINDS = new Set([SymbolKind.FUNCTION, SymbolKind.METHOD]);
+
+/**
+ * Parse --elide-bodies values: N for every directory, DIR=N for one
+ * @param {Array<string>} values - e.g. ['20', 'src/api=0']
+ * @returns {Object} { lines, directories: [{ directory, lines }] }
+ * @throws {Error} When a value is not a line count
+ */
+export function parseElisionRules(values) {
+  const rules = { lines: 0, directories: [] };
+  for (const value of values) {
+    const separator = value.lastIndexOf('=');
+    const directory = separator === -1 ? null : value.slice(0, separator).replace(/\\/g, '/').replace(/^\.\/|\/+$/g, '');
+    const count = separator === -1 ? value : value.slice(separator + 1);
+    const lines = count === 'off' ? 0 : Number(count);
+    if (!Number.isInteger(lines) || lines < 0 || count === '') {
+      throw new Error(`${value} (expected N or DIR=N, with N a line count, or 0/off to keep bodies)`);
+    }
+    if (directory) {
+      rules.directories.push({ directory, lines });
+    } else {
+      rules.lines = lines;
+    }
+  }
+  return rules;
+}
+
+export class BodyElider {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      lines: 20, // Longest body kept, in lines (0 keeps every body)
+      directories: [], // { directory, lines } overrides for paths under a directory
+      ...options
+    };
+    this.extractor = this.options.extractor || new SymbolExtractor({ backend: 'heuristic' });
+    this.directories = [...this.options.directories].sort((a, b) => b.directory.length - a.directory.length);
+  }
+
+  /**
+   * Longest body kept for a file
+   * @param {string} relativePath
+   * @returns {number} Line limit, 0 when bodies are kept
+   */
+  limitFor(relativePath) {
+    const file = relativePath.split(path.sep).join('/');
+    const rule = this.directories.find(({ directory }) => directory === '.' || file === directory || file.startsWith(`${directory}/`));
+    return rule ? rule.lines : this.options.lines;
+  }
+
+  /**
+   * Elide the long bodies of a file
+   * @param {string} content
+   * @param {string} relativePath
+   * @returns {Object|null} { content, bodies, lines }, or null when nothing was elided
+   */
+  elide(content, relativePath) {
+    const limit = this.limitFor(relativePath);
+    if (limit === 0) return null;
+
+    const extension = path.extname(relativePath).toLowerCase();
+    const lines = content.split('\n');
+    const functions = this.extractor.extract(content, relativePath)
+      .filter(symbol => BODY_KINDS.has(symbol.kind))
+      .sort((a, b) => a.startLine - b.startLine || b.endLine - a.endLine);
+
+    // 1-based inclusive ranges of elided lines, outermost first
+    const bodies = [];
+    for (const symbol of functions) {
+      if (bodies.some(body => symbol.startLine <= body.end)) continue;
+      const body = bodyOf(lines, symbol, extension);
+      if (body && body.end - body.start + 1 > limit) bodies.push(body);
+    }
+    if (bodies.length === 0) return null;
+
+    const out = [];
+    let next = 1;
+    let elided = 0;
+    for (const body of bodies) {
+      out.push(...lines.slice(next - 1, body.start - 1));
+      const count = body.end - body.start + 1;
+      const indent = /^\s*/.exec(lines[body.start - 1])[0];
+      out.push(indent + marker(`... ${count} lines elided ...`, extension));
+      elided += count;
+      next = body.end + 1;
+    }
+    out.push(...lines.slice(next - 1));
+
+    return { content: out.join('\n'), bodies: bodies.length, lines: elided };
+  }
+
+  /**
+   * Short description for notes and reports
+   * @returns {string}
+   */
+  describe() {
+    const overrides = this.directories.map(({ directory, lines }) => `${directory} ${lines === 0 ? 'kept' : lines}`);
+    return `${this.options.lines === 0 ? 'kept' : `over ${this.options.lines} lines`}${overrides.length > 0 ? ` (${overrides.join(', ')})` : ''}`;
+  }
+}
+
+/**
+ * Lines of a function's body: after the signature (and a Python docstring),
+ * up to the closing line
+ * @private
+ */
+function bodyOf(lines, symbol, extension) {
+  const indented = INDENTED_EXTENSIONS.has(extension);
+  const opens = indented ? line => /:\s*(#.*)?$/.test(line) : line => line.includes('{');
+
+  let header = symbol.startLine;
+  while (header < symbol.endLine && !opens(lines[header - 1])) header++;
+  if (header >= symbol.endLine) return null;
+
+  let start = header + 1;
+  let end = symbol.endLine;
+  if (indented) {
+    start = afterDocstring(lines, start, end);
+  } else if (/^\s*[})\]]/.test(lines[end - 1])) {
+    end--;
+  }
+  return start <= end ? { start, end } : null;
+}
+
+/**
+ * First line after a docstring opening the body, if there is one
+ * @private
+ */
+function afterDocstring(lines, start, end) {
+  const first = /^\s*[rRuUbB]?("""|''')/.exec(lines[start - 1] || '');
+  if (!first) return start;
+  const quote = first[1];
+  const rest = lines[start - 1].slice(first[0].length);
+  if (rest.includes(quote)) return start + 1;
+  for (let line = start + 1; line <= end; line++) {
+    if (lines[line - 1].includes(quote)) return line + 1;
+  }
+  return end + 1;
+}
+
+/**
+ * @private
+ */
+function marker(text, extension) {
+  if (HASH_EXTENSIONS.has(extension)) return `# ${text}`;
+  if (DASH_EXTENSIONS.has(extension)) return `-- ${text}`;
+  return `/* ${text} */`;
+}
+
+export default BodyElider;
@@ -0,0 +1,220 @@
+/**
+ * BudgetSimulator - What a context holds at several token budgets
+ * v3.4.0 - Budget simulation (ctxman simulate)
+ *
+ * Responsibilities:
+ * - Parse a --budgets list of token counts and model names
+ * - Pack the same files into each budget, extracting symbols and
+ *   summaries once for all of them
+ * - Report per budget what is used, included, trimmed, summarized and
+ *   dropped, and per file how it is packed where the budgets differ
+ *
+ * The smallest budget that holds every file whole is named, so a model
+ * tier can be picked for a task before anything is exported.
+ */
+
+import { parseTokenCount } from './TokenBudget.js';
+import { ModelPresets } from './ModelPresets.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export class BudgetSimulator {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      budgets: [], // Result of parseBudgets()
+      limit: 40, // Files listed in the report
+      ...options
+    };
+    if (this.options.budgets.length === 0) {
+      throw new Error('A simulation needs at least one budget');
+    }
+  }
+
+  /**
+   * Parse a --budgets value such as "32k,128k,claude-sonnet"
+   * @param {string} value - Token counts, or model names standing for their context window
+   * @returns {Array<{label: string, maxTokens: number}>} Smallest first
+   * @throws {Error} For entries that are neither, and for repeated sizes
+   */
+  static parseBudgets(value) {
+    const entries = String(value ?? '').split(',').map(entry => entry.trim()).filter(Boolean);
+    if (entries.length === 0) {
+      throw new Error('Invalid --budgets: expected a list such as 32k,128k,200k');
+    }
+
+    const budgets = entries.map(entry => {
+      const tokens = parseTokenCount(entry);
+      if (tokens) return { label: formatCount(tokens), maxTokens: tokens };
+      try {
+        const preset = ModelPresets.resolve(entry);
+        return { label: `${entry} (${formatCount(preset.contextWindow)})`, maxTokens: preset.contextWindow };
+      } catch (error) {
+        throw new Error(`Invalid --budgets entry: ${entry} (a token count such as 32k, or a --model name)`);
+      }
+    });
+
+    budgets.sort((a, b) => a.maxTokens - b.maxTokens);
+    for (let i = 1; i < budgets.length; i++) {
+      if (budgets[i].maxTokens === budgets[i - 1].maxTokens) {
+        throw new Error(`Duplicate budget in --budgets: ${budgets[i - 1].label}, ${budgets[i].label}`);
+      }
+    }
+    return budgets;
+  }
+
+  /**
+   * Plan the items at every budget
+   * @param {TokenBudget} budget - Tokenizer, reserve and tiers of every plan
+   * @param {Array<Object>} items - As for TokenBudget.plan()
+   * @param {Object} options - expand, summarize and context, as for TokenBudget.plan()
+   * @returns {Object} { tokenizer, files, totalTokens, budgets: [{ label, maxTokens, plan }],
+   *   rows: [{ id, tokens, cells: [{ tier, tokens }] }] }; a dropped file's tier is null
+   */
+  simulate(budget, items, options = {}) {
+    const callbacks = memoize(options);
+    const budgets = this.options.budgets.map(({ label, maxTokens }) => ({
+      label,
+      maxTokens,
+      plan: budget.withMaxTokens(maxTokens).plan(items, callbacks)
+    }));
+
+    const cells = new Map(items.map(item => [item.id, []]));
+    budgets.forEach(({ plan }, index) => {
+      const place = (entries, tierOf) => {
+        for (const entry of entries) cells.get(entry.id)[index] = { tier: tierOf(entry), tokens: entry.tokens };
+      };
+      place(plan.included, () => 'full');
+      place(plan.partial, () => 'symbols');
+      place(plan.summarized, entry => entry.tier);
+      place(plan.dropped, () => null);
+    });
+
+    return {
+      tokenizer: budget.getTokenizerName(),
+      files: items.length,
+      totalTokens: items.reduce((sum, item) => sum + item.tokens, 0),
+      budgets,
+      rows: items.map(item => ({ id: item.id, tokens: item.tokens, cells: cells.get(item.id) }))
+    };
+  }
+
+  /**
+   * Format a simulation as a console report
+   * @param {Object} simulation - Result of simulate()
+   * @returns {string}
+   */
+  format(simulation) {
+    const lines = [];
+    const { budgets } = simulation;
+    const width = Math.max(8, ...budgets.map(({ label }) => label.length)) + 2;
+
+    lines.push('');
+    lines.push('📐 BUDGET SIMULATION');
+    lines.push('='.repeat(80));
+    lines.push(`   Tokenizer: ${simulation.tokenizer}`);
+    lines.push(`   Files:     ${simulation.files.toLocaleString()} (${simulation.totalTokens.toLocaleString()} tokens)`);
+    const reserve = budgets[0].plan.reserve;
+    if (reserve) lines.push(`   Reserve:   ${reserve.toLocaleString()} tokens of each budget`);
+    lines.push('');
+
+    lines.push(`   ${'Budget'.padEnd(width)}${'Used'.padStart(20)}${'Full'.padStart(7)}${'Trimmed'.padStart(9)}` +
+      `${'Summarized'.padStart(12)}${'Dropped'.padStart(9)}`);
+    for (const { label, plan } of budgets) {
+      const percent = plan.limit > 0 ? ((plan.usedTokens / plan.limit) * 100).toFixed(1) : '0.0';
+      lines.push(`   ${label.padEnd(width)}${`${plan.usedTokens.toLocaleString()} (${percent}%)`.padStart(20)}` +
+        `${String(plan.included.length).padStart(7)}${String(plan.partial.length).padStart(9)}` +
+        `${String(plan.summarized.length).padStart(12)}${String(plan.dropped.length).padStart(9)}`);
+    }
+
+    const fits = budgets.find(({ plan }) => plan.included.length === simulation.files);
+    lines.push('');
+    lines.push(fits
+      ? `   Every file fits whole from ${fits.label} on`
+      : `   No budget holds every file whole (${simulation.totalTokens.toLocaleString()} tokens` +
+        `${reserve ? `, plus ${reserve.toLocaleString()} reserved` : ''})`);
+
+    // Files packed the same way at every budget say nothing about the choice
+    const varying = [];
+    const same = [];
+    for (const row of simulation.rows) {
+      (new Set(row.cells.map(describeCell)).size > 1 ? varying : same).push(row);
+    }
+    if (varying.length > 0) {
+      varying.sort((a, b) => b.tokens - a.tokens || compareBytes(a.id, b.id));
+      const shown = varying.slice(0, this.options.limit);
+      const cellWidth = Math.max(width, 18);
+      const names = shown.map(row => `${row.id} (${row.tokens.toLocaleString()})`);
+      const nameWidth = Math.min(50, Math.max(...names.map(name => name.length)));
+
+      lines.push('');
+      lines.push('🔀 Packed differently across budgets:');
+      lines.push(`   ${'File (tokens)'.padEnd(nameWidth)}  ${budgets.map(({ label }) => label.padEnd(cellWidth)).join('')}`.trimEnd());
+      shown.forEach((row, index) => {
+        const name = truncate(names[index], nameWidth);
+        lines.push(`   ${name.padEnd(nameWidth)}  ${row.cells.map(cell => describeCell(cell).padEnd(cellWidth)).join('')}`.trimEnd());
+      });
+      if (varying.length > shown.length) {
+        lines.push(`   ... and ${(varying.length - shown.length).toLocaleString()} more`);
+      }
+    }
+
+    if (same.length > 0) {
+      const whole = same.filter(row => row.cells[0].tier === 'full').length;
+      lines.push('');
+      lines.push(`   Same at every budget: ${whole.toLocaleString()} whole, ${(same.length - whole).toLocaleString()} trimmed, summarized or dropped`);
+    }
+
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Plan callbacks that compute each file's symbols, summaries and imports once
+ * @private
+ */
+function memoize(options) {
+  const cached = (fn, keyOf) => {
+    if (!fn) return undefined;
+    const results = new Map();
+    return (...args) => {
+      const key = keyOf(...args);
+      if (!results.has(key)) results.set(key, fn(...args));
+      return results.get(key);
+    };
+  };
+  return {
+    expand: cached(options.expand, item => item.id),
+    summarize: cached(options.summarize, (item, tier) => `${item.id}\0${tier}`),
+    context: cached(options.context, (item, symbols) =>
+      `${item.id}\0${symbols.map(symbol => `${symbol.startLine}-${symbol.endLine}`).join(',')}`)
+  };
+}
+
+/**
+ * @private
+ */
+function describeCell(cell) {
+  if (!cell.tier) return '—';
+  return cell.tier === 'full' ? 'full' : `${cell.tier} ${formatCount(cell.tokens)}`;
+}
+
+/**
+ * Token count as 900, 1.5k or 2m
+ * @private
+ */
+function formatCount(tokens) {
+  if (tokens >= 1000000) return `${+(tokens / 1000000).toFixed(1)}m`;
+  if (tokens >= 1000) return `${+(tokens / 1000).toFixed(1)}k`;
+  return String(tokens);
+}
+
+/**
+ * @private
+ */
+function truncate(text, width) {
+  return text.length > width ? `…${text.slice(text.length - width + 1)}` : text;
+}
+
+export default BudgetSimulator;
@@ -0,0 +1,180 @@
+/**
+ * CodeOwners - CODEOWNERS awareness
+ * v3.4.0 - Ownership-aware context (--owner, --owners)
+ *
+ * Responsibilities:
+ * - Find and parse the CODEOWNERS file of a project, in the places GitHub
+ *   and GitLab look for it
+ * - Name the owners of a file: the last matching rule wins, once per
+ *   GitLab [Section], and a rule without owners leaves its files unowned
+ * - Match owner selectors: @team-payments also names @org/team-payments,
+ *   and the @ may be left out
+ *
+ * Patterns follow .gitignore syntax, except that a wildcard in the last
+ * segment matches files of that directory only (docs/* leaves docs/api/
+ * alone), as GitHub documents.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import GitIgnoreParser from '../parsers/gitignore-parser.js';
+
+// Searched in this order; the first one found is used
+export const CODEOWNERS_LOCATIONS = ['.github/CODEOWNERS', 'CODEOWNERS', 'docs/CODEOWNERS', '.gitlab/CODEOWNERS'];
+
+// [Section], ^[Optional section], [Section][2] approvals, then default owners
+const SECTION = /^\^?\[([^\]]+)\](?:\[\d+\])?(.*)$/;
+
+export class CodeOwners {
+  /**
+   * @param {string} text - Content of a CODEOWNERS file
+   * @param {string} source - Its path, for messages
+   */
+  constructor(text, source = 'CODEOWNERS') {
+    this.source = source;
+    this.sections = [];
+
+    const parser = new GitIgnoreParser(null, null, null);
+    let section = { name: null, defaults: [], rules: [] };
+    this.sections.push(section);
+
+    for (const raw of text.split(/\r?\n/)) {
+      const line = stripComment(raw).trim();
+      if (!line) continue;
+
+      const header = line.match(SECTION);
+      if (header) {
+        section = { name: header[1].trim(), defaults: ownerTokens(header[2]), rules: [] };
+        this.sections.push(section);
+        continue;
+      }
+
+      const [pattern, ...owners] = splitLine(line);
+      const { regex, exact } = parser.convertToRegex(pattern);
+      const last = pattern.replace(/\/+$/, '').split('/').pop();
+      section.rules.push({
+        pattern,
+        // docs/* names the files of docs/, not of its subdirectories
+        regex: /[*?[]/.test(last) && last !== '**' ? exact : regex,
+        owners: owners.length > 0 ? owners : section.defaults
+      });
+    }
+  }
+
+  /**
+   * Find the CODEOWNERS file of a project
+   * @param {string} projectRoot
+   * @returns {string|null} Path relative to the root
+   */
+  static find(projectRoot) {
+    return CODEOWNERS_LOCATIONS.find(file => fs.existsSync(path.join(projectRoot, file))) || null;
+  }
+
+  /**
+   * @param {string} projectRoot
+   * @returns {CodeOwners|null} Null when the project has no CODEOWNERS file
+   */
+  static load(projectRoot) {
+    const file = CodeOwners.find(projectRoot);
+    return file ? new CodeOwners(fs.readFileSync(path.join(projectRoot, file), 'utf8'), file) : null;
+  }
+
+  /**
+   * Canonical form of an owner selector: a leading @, lowercase
+   * @param {string} owner - e.g. team-payments, @org/Team-Payments or an email
+   * @returns {string}
+   */
+  static normalize(owner) {
+    const value = owner.trim().toLowerCase();
+    return value.includes('@') ? value : `@${value}`;
+  }
+
+  /**
+   * Header note naming the owners of a file
+   * @param {Array<string>} owners - From ownersOf()
+   * @returns {string}
+   */
+  static formatNote(owners) {
+    return `Owners: ${owners.length > 0 ? owners.join(', ') : 'none'}`;
+  }
+
+  /**
+   * @param {string} file - '/'-separated path relative to the root
+   * @returns {Array<string>} Owners as written in CODEOWNERS; empty when unowned
+   */
+  ownersOf(file) {
+    const owners = [];
+    for (const section of this.sections) {
+      const rule = section.rules.findLast(candidate => candidate.regex.test(file));
+      for (const owner of rule ? rule.owners : []) {
+        if (!owners.includes(owner)) owners.push(owner);
+      }
+    }
+    return owners;
+  }
+
+  /**
+   * Whether any of the selectors owns a file
+   * @param {string} file - '/'-separated path relative to the root
+   * @param {Array<string>} selectors - Owner selectors, as given to --owner
+   * @returns {boolean}
+   */
+  isOwnedBy(file, selectors) {
+    return this.ownersOf(file).some(owner => selectors.some(selector => matches(owner, selector)));
+  }
+
+  /**
+   * @returns {Array<string>} Every owner the rules name, in order of appearance
+   */
+  listOwners() {
+    const owners = this.sections.flatMap(section => section.rules.flatMap(rule => rule.owners));
+    return [...new Set(owners)];
+  }
+
+  /**
+   * Whether a selector names any owner of the rules
+   * @param {string} selector
+   * @returns {boolean}
+   */
+  names(selector) {
+    return this.listOwners().some(owner => matches(owner, selector));
+  }
+}
+
+/**
+ * @private
+ */
+function matches(owner, selector) {
+  const wanted = CodeOwners.normalize(selector);
+  const candidate = owner.toLowerCase();
+  // @team matches @org/team; @org/team itself only matches exactly
+  return candidate === wanted || (!wanted.includes('/') && candidate.startsWith('@') && candidate.endsWith(`/${wanted.slice(1)}`));
+}
+
+/**
+ * A # starts a comment unless escaped
+ * @private
+ */
+function stripComment(line) {
+  const index = line.search(/(^|[^\\])#/);
+  if (index === -1) return line;
+  return line.slice(0, line[index] === '#' ? index : index + 1);
+}
+
+/**
+ * Pattern and owners; a backslash escapes a space in the pattern
+ * @private
+ */
+function splitLine(line) {
+  const match = line.match(/^((?:\\.|\S)+)(.*)$/);
+  return [match[1].replace(/\\ /g, ' '), ...ownerTokens(match[2])];
+}
+
+/**
+ * @private
+ */
+function ownerTokens(text) {
+  return text.split(/\s+/).filter(Boolean);
+}
+
+export default CodeOwners;
@@ -0,0 +1,386 @@
+/**
+ * CommentTranslator - English translations of non-English comments
+ * v3.4.0 - Comment translation (--translate-comments)
+ *
+ * Responsibilities:
+ * - Find the comments and docstrings of a file (with ContentStripper's scanner)
+ *   and detect those not written in English, by script and common words
+ * - Translate them line by line through an OpenAI-compatible chat completions
+ *   endpoint or a LibreTranslate server, one request per file
+ * - Cache translations by content hash, API, endpoint and model, so unchanged
+ *   comment lines are never sent twice
+ * - Put the translations in place, keeping comment markers, indentation and
+ *   line numbers
+ *
+ * Languages are guessed, not identified: non-Latin scripts by the letters
+ * they use (Cyrillic is reported as ru), Latin ones by their most frequent
+ * words. Comments with fewer than minLetters letters are left alone.
+ */
+
+import path from 'path';
+import ContentCache from '../cache/ContentCache.js';
+import ContentStripper, { scan } from './ContentStripper.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('CommentTranslator');
+
+export const TRANSLATION_APIS = ['openai', 'libretranslate'];
+
+export const DEFAULT_TRANSLATION_MODEL = 'gpt-4o-mini';
+
+const OPENAI_BASE_URL = 'https://api.openai.com/v1';
+
+const SYSTEM_PROMPT = 'You translate source code comments into English for a code context given to another model. ' +
+  'You get a JSON array of comment lines from one file, in order. Reply with a JSON array of the same length ' +
+  'holding each line in English. Keep identifiers, code, paths, URLs and placeholders as they are; lines ' +
+  'already in English stay as they are. No preamble or Markdown.';
+
+// Scripts that name their language; kana before Han, so Japanese is not read as Chinese
+const SCRIPTS = [
+  { language: 'ja', letters: /[\p{Script=Hiragana}\p{Script=Katakana}]/u },
+  { language: 'zh', letters: /\p{Script=Han}/u },
+  { language: 'ko', letters: /\p{Script=Hangul}/u },
+  { language: 'ru', letters: /\p{Script=Cyrillic}/u },
+  { language: 'el', letters: /\p{Script=Greek}/u },
+  { language: 'ar', letters: /\p{Script=Arabic}/u },
+  { language: 'he', letters: /\p{Script=Hebrew}/u },
+  { language: 'hi', letters: /\p{Script=Devanagari}/u },
+  { language: 'th', letters: /\p{Script=Thai}/u }
+];
+
+// Frequent words of Latin-script languages
+const COMMON_WORDS = {
+  en: ['the', 'and', 'is', 'are', 'of', 'to', 'in', 'for', 'this', 'that', 'with', 'if', 'not', 'be', 'it', 'we', 'when', 'from', 'returns', 'should'],
+  de: ['der', 'die', 'das', 'und', 'ist', 'nicht', 'mit', 'für', 'wird', 'werden', 'eine', 'einen', 'den', 'dem', 'auf', 'auch', 'wenn', 'hier', 'noch', 'gibt'],
+  fr: ['le', 'la', 'les', 'des', 'est', 'et', 'une', 'pour', 'dans', 'pas', 'que', 'qui', 'sur', 'avec', 'ce', 'cette', 'sont', 'si', 'au', 'aux'],
+  es: ['el', 'los', 'las', 'es', 'y', 'una', 'para', 'con', 'por', 'que', 'del', 'se', 'no', 'esta', 'este', 'si', 'como', 'lo', 'al', 'más'],
+  it: ['il', 'gli', 'della', 'che', 'è', 'per', 'non', 'una', 'con', 'sono', 'questo', 'questa', 'del', 'nel', 'alla', 'se', 'anche', 'come', 'di', 'da'],
+  pt: ['os', 'as', 'é', 'não', 'uma', 'para', 'com', 'que', 'do', 'da', 'se', 'em', 'no', 'na', 'por', 'isso', 'este', 'esta', 'são', 'mais'],
+  nl: ['de', 'het', 'een', 'en', 'is', 'niet', 'van', 'met', 'voor', 'dat', 'die', 'op', 'wordt', 'zijn', 'als', 'ook', 'bij', 'naar', 'om', 'deze'],
+  tr: ['ve', 'bir', 'bu', 'için', 'ile', 'değil', 'olarak', 'olan', 'eğer', 'da', 'de', 'çok', 'daha', 'gibi', 'ama', 'kadar', 'sonra', 'önce', 'burada', 'şu']
+};
+
+const WORDS_BY_LANGUAGE = Object.entries(COMMON_WORDS).map(([language, words]) => ({ language, words: new Set(words) }));
+
+// Comment markers before and after the text of a comment line
+const LINE_PREFIX = /^\s*(?:\/\/+!?|\/\*+!?|\*+(?!\/)|#+!?|--+(?:\[\[)?|"""|'''|<!--)?\s*/;
+const LINE_SUFFIX = /\s*(?:\*+\/|-->|"""|'''|\]\])?\s*$/;
+
+export class CommentTranslator {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      api: 'openai', // openai (chat completions) or libretranslate
+      baseUrl: null, // Default: OPENAI_BASE_URL or OpenAI; http://localhost:5000 for LibreTranslate
+      apiKey: null, // Default: OPENAI_API_KEY, or LIBRETRANSLATE_API_KEY
+      model: DEFAULT_TRANSLATION_MODEL, // Chat model; unused by LibreTranslate
+      minLetters: 12, // Shorter comments are not worth a guess
+      concurrency: 4, // Files in flight
+      retries: 3, // Per file, after the first attempt
+      retryDelay: 1000, // ms, doubled per retry unless the server sends Retry-After
+      redact: null, // (text, file) => text sent instead, e.g. with secrets redacted
+      cache: null, // ContentCache; null = .ctxman/cache/content-cache.json under root
+      root: process.cwd(),
+      fetch: globalThis.fetch,
+      sleep: ms => new Promise(resolve => setTimeout(resolve, ms)),
+      ...options
+    };
+    if (!TRANSLATION_APIS.includes(this.options.api)) {
+      throw new Error(`Invalid translation API: ${this.options.api} (expected ${TRANSLATION_APIS.join(' or ')})`);
+    }
+    const libre = this.options.api === 'libretranslate';
+    this.options.baseUrl = (this.options.baseUrl || (libre ? 'http://localhost:5000' : process.env.OPENAI_BASE_URL || OPENAI_BASE_URL)).replace(/\/+$/, '');
+    this.options.apiKey = this.options.apiKey ?? ((libre ? process.env.LIBRETRANSLATE_API_KEY : process.env.OPENAI_API_KEY) || '');
+    this.cache = this.options.cache || new ContentCache({ root: this.options.root });
+    this.files = new Map(); // relativePath -> { source, content }
+    this.stats = { files: 0, translated: 0, comments: 0, lines: 0, cached: 0, requested: 0, failed: 0, retries: 0, inputTokens: 0, outputTokens: 0, languages: {} };
+  }
+
+  /**
+   * Whether the endpoint needs an API key that is missing (the OpenAI API
+   * always does; local endpoints usually do not)
+   * @returns {boolean}
+   */
+  missingKey() {
+    return !this.options.apiKey && this.options.baseUrl === OPENAI_BASE_URL;
+  }
+
+  /**
+   * Cache key of translations from this API, endpoint and model
+   * @returns {string}
+   */
+  getKey() {
+    const { api, baseUrl, model } = this.options;
+    return api === 'libretranslate' ? `translate:${api}:${baseUrl}` : `translate:${baseUrl}:${model}`;
+  }
+
+  /**
+   * Endpoint and model, for reports
+   * @returns {string}
+   */
+  describe() {
+    const { api, baseUrl, model } = this.options;
+    return api === 'libretranslate' ? `LibreTranslate (${baseUrl})` : `${model} (${baseUrl})`;
+  }
+
+  /**
+   * Language a text is probably written in, when it is not English
+   * @param {string} text
+   * @returns {string|null} e.g. de, ja; null for English or too little text
+   */
+  detect(text) {
+    const letters = text.match(/\p{L}/gu) || [];
+    if (letters.length < this.options.minLetters) return null;
+
+    const foreign = letters.filter(letter => !/[A-Za-z]/.test(letter));
+    if (foreign.length >= letters.length * 0.3) {
+      const counts = SCRIPTS.map(({ language, letters: pattern }) => ({ language, count: foreign.filter(letter => pattern.test(letter)).length }));
+      const kana = counts[0].count > 0;
+      const best = kana ? counts[0] : counts.reduce((a, b) => (b.count > a.count ? b : a));
+      if (best.count > 0) return best.language;
+    }
+
+    const words = text.toLowerCase().match(/\p{L}+/gu) || [];
+    const hits = WORDS_BY_LANGUAGE.map(({ language, words: common }) => ({ language, count: words.filter(word => common.has(word)).length }));
+    const english = hits.find(hit => hit.language === 'en').count;
+    const best = hits.filter(hit => hit.language !== 'en').reduce((a, b) => (b.count > a.count ? b : a));
+    return best.count >= 2 && best.count > english ? best.language : null;
+  }
+
+  /**
+   * Non-English comments of a file
+   * @param {string} content
+   * @param {string} file - Path; its extension picks the comment syntax
+   * @returns {Array<Object>} { language, lines[] { start, end, text } }, in order; lines
+   *   are the text of each comment line without markers, as offsets into content
+   */
+  comments(content, file) {
+    const syntax = ContentStripper.syntaxOf(file, content);
+    if (!syntax) return [];
+
+    const found = [];
+    for (const comment of scan(content, syntax).comments) {
+      const language = this.detect(content.slice(comment.start, comment.end));
+      if (!language) continue;
+
+      const lines = [];
+      let start = comment.start;
+      for (const line of content.slice(comment.start, comment.end).split('\n')) {
+        const prefix = LINE_PREFIX.exec(line)[0].length;
+        const text = line.slice(prefix).replace(LINE_SUFFIX, '');
+        if (/\p{L}/u.test(text)) {
+          lines.push({ start: start + prefix, end: start + prefix + text.length, text });
+        }
+        start += line.length + 1;
+      }
+      if (lines.length > 0) found.push({ language, lines });
+    }
+    return found;
+  }
+
+  /**
+   * Translate the non-English comments of files; files whose requests fail
+   * keep their comments
+   * @param {Array<Object>} files - { id, content }
+   * @returns {Promise<Map<string, string>>} Translated content by id, for files that changed
+   */
+  async translateAll(files) {
+    const translated = new Map();
+    const pending = [];
+    for (const file of files) {
+      const comments = this.comments(file.content, file.id);
+      if (comments.length > 0) pending.push({ ...file, comments });
+    }
+    this.stats.files += files.length;
+
+    let next = 0;
+    const worker = async () => {
+      while (next < pending.length) {
+        const file = pending[next++];
+        try {
+          const content = await this.translateFile(file);
+          const relativePath = file.id.split(path.sep).join('/');
+          this.files.set(relativePath, { source: file.content, content });
+          translated.set(file.id, content);
+          this.stats.translated++;
+          this.stats.comments += file.comments.length;
+          for (const { language } of file.comments) {
+            this.stats.languages[language] = (this.stats.languages[language] || 0) + 1;
+          }
+        } catch (error) {
+          this.stats.failed++;
+          logger.warn(`Comment translation of ${file.id} failed: ${error.message}`);
+        }
+      }
+    };
+    await Promise.all(Array.from({ length: Math.min(this.options.concurrency, pending.length) }, worker));
+
+    this.cache.save();
+    return translated;
+  }
+
+  /**
+   * A file's content with translated comments, once translateAll() has
+   * translated it; other content is returned as it is
+   * @param {string} content
+   * @param {string} relativePath
+   * @returns {string}
+   */
+  apply(content, relativePath) {
+    const known = this.files.get(relativePath.split(path.sep).join('/'));
+    return known && known.source === content ? known.content : content;
+  }
+
+  /**
+   * Format a translation report for the console
+   * @param {CommentTranslator} translator
+   * @returns {string}
+   */
+  static formatReport(translator) {
+    const { stats } = translator;
+    const languages = Object.entries(stats.languages)
+      .sort(([a, countA], [b, countB]) => countB - countA || compareBytes(a, b))
+      .map(([language, count]) => `${language} ${count}`)
+      .join(', ');
+    const lines = [];
+
+    lines.push('');
+    lines.push('🌐 COMMENT TRANSLATION');
+    lines.push('='.repeat(80));
+    lines.push(`   Endpoint:  ${translator.describe()}`);
+    if (stats.translated === 0 && stats.failed === 0) {
+      lines.push('   No comments outside English');
+      return lines.join('\n');
+    }
+    lines.push(`   Comments:  ${stats.comments} in ${stats.translated} ${stats.translated === 1 ? 'file' : 'files'} translated` +
+      (languages ? ` (${languages})` : '') + (stats.failed ? `, ${stats.failed} failed and kept as they are` : ''));
+    lines.push(`   Lines:     ${stats.lines} (${stats.cached} cached, ${stats.lines - stats.cached} sent in ${stats.requested} requests` +
+      (stats.retries ? `, ${stats.retries} retries` : '') + ')');
+    if (stats.inputTokens > 0) {
+      lines.push(`   Usage:     ${stats.inputTokens.toLocaleString()} prompt + ${stats.outputTokens.toLocaleString()} completion tokens`);
+    }
+
+    return lines.join('\n');
+  }
+
+  /**
+   * Content of one file with its comment lines translated; cached lines are
+   * not sent
+   * @private
+   */
+  async translateFile(file) {
+    const lines = file.comments.flatMap(comment => comment.lines).map(line => {
+      const sent = this.options.redact ? this.options.redact(line.text, file.id) : line.text;
+      return { ...line, sent, hash: ContentCache.hash(sent) };
+    });
+    this.stats.lines += lines.length;
+
+    const translations = new Map();
+    const missing = [];
+    for (const line of lines) {
+      const cached = translations.has(line.hash) ? translations.get(line.hash) : this.cache.summary(line.hash, this.getKey());
+      if (cached !== null) {
+        translations.set(line.hash, cached);
+        this.stats.cached++;
+      } else if (!missing.includes(line.sent)) {
+        missing.push(line.sent);
+      }
+    }
+
+    if (missing.length > 0) {
+      const results = await this.request(missing);
+      missing.forEach((text, i) => {
+        const translation = results[i].replace(/\s*\n\s*/g, ' ').trim() || text;
+        const hash = ContentCache.hash(text);
+        this.cache.setSummary(hash, this.getKey(), translation);
+        translations.set(hash, translation);
+      });
+    }
+
+    // Back to front, so earlier offsets stay valid
+    let content = file.content;
+    for (const line of [...lines].reverse()) {
+      content = content.slice(0, line.start) + (translations.get(line.hash) ?? line.text) + content.slice(line.end);
+    }
+    return content;
+  }
+
+  /**
+   * Translations of texts, in order, in one request
+   * @private
+   */
+  async request(texts) {
+    this.stats.requested++;
+    const libre = this.options.api === 'libretranslate';
+    const url = libre ? `${this.options.baseUrl}/translate` : `${this.options.baseUrl}/chat/completions`;
+    const headers = { 'Content-Type': 'application/json' };
+    if (this.options.apiKey && !libre) headers.Authorization = `Bearer ${this.options.apiKey}`;
+    const body = JSON.stringify(libre
+      ? { q: texts, source: 'auto', target: 'en', format: 'text', ...(this.options.apiKey ? { api_key: this.options.apiKey } : {}) }
+      : {
+        model: this.options.model,
+        temperature: 0,
+        messages: [
+          { role: 'system', content: SYSTEM_PROMPT },
+          { role: 'user', content: JSON.stringify(texts) }
+        ]
+      });
+
+    for (let attempt = 0; ; attempt++) {
+      let response;
+      let failure;
+      try {
+        response = await this.options.fetch(url, { method: 'POST', headers, body });
+      } catch (error) {
+        failure = { retry: true, message: `request failed: ${error.message}` };
+      }
+
+      if (response?.ok) {
+        return this.parseResponse(await response.json(), texts.length);
+      }
+      if (response) {
+        const detail = (await response.text().catch(() => '')).slice(0, 200);
+        failure = {
+          retry: response.status === 429 || response.status >= 500,
+          message: `returned ${response.status}${detail ? `: ${detail}` : ''}`,
+          after: Number(response.headers?.get?.('retry-after')) * 1000 || 0
+        };
+      }
+
+      if (!failure.retry || attempt >= this.options.retries) {
+        throw new Error(failure.message);
+      }
+      this.stats.retries++;
+      await this.options.sleep(failure.after || this.options.retryDelay * 2 ** attempt);
+    }
+  }
+
+  /**
+   * Translated texts of a response; throws unless there is one per text
+   * @private
+   */
+  parseResponse(data, count) {
+    let translations;
+    if (this.options.api === 'libretranslate') {
+      translations = data.translatedText;
+    } else {
+      this.stats.inputTokens += data.usage?.prompt_tokens || 0;
+      this.stats.outputTokens += data.usage?.completion_tokens || 0;
+      const reply = data.choices?.[0]?.message?.content || '';
+      try {
+        translations = JSON.parse(reply.replace(/^\s*```(?:json)?\s*|\s*```\s*$/g, ''));
+      } catch {
+        throw new Error('reply is not a JSON array');
+      }
+    }
+    if (!Array.isArray(translations) || translations.length !== count || translations.some(text => typeof text !== 'string')) {
+      throw new Error(`expected ${count} translations`);
+    }
+    return translations;
+  }
+}
+
+export default CommentTranslator;
@@ -0,0 +1,211 @@
+/**
+ * CompressionStats - What each transform took out of a context
+ * v3.4.0 - Compression statistics (--compression-stats)
+ *
+ * Responsibilities:
+ * - Collect the tokens each transform removed from each file: --licenses, --strip,
+ *   --elide-bodies, --dedupe, --session, --summarize-remote, --rebalance-pins
+ *   and the token budget (trimmed, summarized and dropped files)
+ * - Total them per transform, per file section (docs, schemas, code, tests)
+ *   and per directory, next to raw bytes, raw tokens and final tokens
+ *
+ * Raw tokens are a file's tokens before any transform, final tokens what the
+ * context holds of it; the transforms' savings add up to the difference.
+ * Files a transform dropped have no final tokens. Selections (--focus,
+ * --symbol, diff) decide which files take part; they are not transforms.
+ */
+
+import path from 'path';
+import ContextLayout from './ContextLayout.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export const COMPRESSION_SCHEMA = 'ctxman.compression/v1';
+
+// Transforms in the order a run applies them
+export const TRANSFORMS = ['license', 'strip', 'elide', 'dedupe', 'session', 'summarize', 'rebalance', 'budget'];
+
+export class CompressionStats {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      depth: 1, // Directory levels the report groups by
+      ...options
+    };
+    this.files = new Map();
+  }
+
+  /**
+   * Note the tokens a transform took from a file
+   * @param {string} transform - One of TRANSFORMS
+   * @param {Object} fileInfo - The file as the transform got it
+   * @param {number} after - Its tokens afterwards; 0 when the transform dropped it
+   */
+  record(transform, fileInfo, after) {
+    const entry = this.entry(fileInfo);
+    if (fileInfo.tokens > after) {
+      entry.saved[transform] = (entry.saved[transform] || 0) + fileInfo.tokens - after;
+    }
+  }
+
+  /**
+   * Statistics of a finished selection
+   * @param {Array<Object>} exportResults - Files the context holds
+   * @returns {Object} { schema, transforms, totals, bySection, byDirectory }
+   */
+  build(exportResults) {
+    const final = new Map();
+    for (const fileInfo of exportResults.filter(fileInfo => !fileInfo.error)) {
+      this.entry(fileInfo);
+      final.set(toPosix(fileInfo.relativePath), fileInfo.tokens);
+    }
+
+    const entries = [...this.files.values()];
+    const transforms = TRANSFORMS.filter(transform => entries.some(entry => entry.saved[transform] > 0));
+    const group = keyOf => {
+      const groups = new Map();
+      for (const entry of entries) {
+        const key = keyOf(entry);
+        if (!groups.has(key)) groups.set(key, total(key, transforms));
+        add(groups.get(key), entry, final.get(entry.path) || 0);
+      }
+      return [...groups.values()].sort((a, b) => totalSaved(b) - totalSaved(a) || compareBytes(a.name, b.name));
+    };
+
+    const totals = total('total', transforms);
+    entries.forEach(entry => add(totals, entry, final.get(entry.path) || 0));
+    return {
+      schema: COMPRESSION_SCHEMA,
+      transforms,
+      totals,
+      bySection: group(entry => ContextLayout.sectionOf(entry.path)),
+      byDirectory: group(entry => directoryOf(entry.path, this.options.depth))
+    };
+  }
+
+  /**
+   * Console report of build()
+   * @param {Object} stats
+   * @returns {string}
+   */
+  static formatReport(stats) {
+    const { totals, transforms } = stats;
+    const lines = ['', '📉 COMPRESSION', '='.repeat(80)];
+    lines.push(`   Tokens: ${totals.rawTokens.toLocaleString()} raw → ${totals.finalTokens.toLocaleString()} final ` +
+      `(${formatShare(totals.rawTokens - totals.finalTokens, totals.rawTokens)} saved) in ${totals.files} files, ` +
+      `${formatBytes(totals.rawBytes)} raw`);
+    if (transforms.length === 0) {
+      lines.push('   No transform removed anything');
+      return lines.join('\n');
+    }
+
+    lines.push('', '   By transform:');
+    for (const transform of transforms) {
+      const saved = totals.saved[transform];
+      lines.push(`   ${transform.padEnd(12)}${saved.toLocaleString().padStart(12)} tokens  ${formatShare(saved, totals.rawTokens).padStart(6)} ` +
+        `of raw, in ${totals.changed[transform]} files`);
+    }
+
+    const table = (title, rows) => {
+      lines.push('', `   By ${title}:`);
+      lines.push(`   ${''.padEnd(24)}${'bytes'.padStart(10)}${'raw'.padStart(10)}` +
+        transforms.map(transform => `-${transform}`.padStart(11)).join('') + `${'final'.padStart(10)}`);
+      for (const row of rows) {
+        const name = row.name.length > 23 ? `…${row.name.slice(-22)}` : row.name;
+        lines.push(`   ${name.padEnd(24)}${formatBytes(row.rawBytes).padStart(10)}${row.rawTokens.toLocaleString().padStart(10)}` +
+          transforms.map(transform => (row.saved[transform] ? row.saved[transform].toLocaleString() : '-').padStart(11)).join('') +
+          `${row.finalTokens.toLocaleString().padStart(10)}`);
+      }
+    };
+    table('section', stats.bySection);
+    table('directory', stats.byDirectory);
+    return lines.join('\n');
+  }
+
+  /**
+   * Entry of a file; its raw tokens are the tokens it comes with plus what
+   * --licenses and --strip removed during analysis
+   * @private
+   */
+  entry(fileInfo) {
+    const file = toPosix(fileInfo.relativePath);
+    if (!this.files.has(file)) {
+      const header = fileInfo.licenseHeader?.tokens || 0;
+      const stripped = fileInfo.strippedTokens || 0;
+      const saved = {};
+      if (header > 0) saved.license = header;
+      if (stripped > 0) saved.strip = stripped;
+      this.files.set(file, {
+        path: file,
+        rawBytes: fileInfo.sizeBytes || 0,
+        rawTokens: fileInfo.tokens + header + stripped,
+        saved
+      });
+    }
+    return this.files.get(file);
+  }
+}
+
+/**
+ * @private
+ */
+function total(name, transforms) {
+  const zero = () => Object.fromEntries(transforms.map(transform => [transform, 0]));
+  return { name, files: 0, rawBytes: 0, rawTokens: 0, finalTokens: 0, saved: zero(), changed: zero() };
+}
+
+/**
+ * @private
+ */
+function add(row, entry, finalTokens) {
+  row.files++;
+  row.rawBytes += entry.rawBytes;
+  row.rawTokens += entry.rawTokens;
+  row.finalTokens += finalTokens;
+  for (const [transform, saved] of Object.entries(entry.saved)) {
+    row.saved[transform] += saved;
+    row.changed[transform]++;
+  }
+}
+
+/**
+ * @private
+ */
+function totalSaved(row) {
+  return row.rawTokens - row.finalTokens;
+}
+
+/**
+ * First levels of a file's directory; '.' for the root
+ * @private
+ */
+function directoryOf(file, depth) {
+  const dirs = path.posix.dirname(file).split('/').filter(dir => dir !== '.');
+  return dirs.length === 0 ? '.' : `${dirs.slice(0, depth).join('/')}/`;
+}
+
+/**
+ * @private
+ */
+function formatShare(part, whole) {
+  return `${whole > 0 ? ((part / whole) * 100).toFixed(1) : '0.0'}%`;
+}
+
+/**
+ * @private
+ */
+function formatBytes(bytes) {
+  if (bytes < 1024) return `${bytes} B`;
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+  return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+}
+
+/**
+ * @private
+ */
+function toPosix(relativePath) {
+  return relativePath.split(path.sep).join('/');
+}
+
+export default CompressionStats;
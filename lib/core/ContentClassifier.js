@@ -0,0 +1,127 @@
+/**
+ * ContentClassifier - Files that are not worth tokens
+ * v3.4.0 - Binary, minified and lockfile detection
+ *
+ * Responsibilities:
+ * - Recognize binary content (NUL bytes, undecodable bytes) in files whose
+ *   extension claims text
+ * - Recognize minified bundles by their name (.min.js) or line lengths;
+ *   only formats that get minified, so unwrapped prose keeps its long lines
+ * - Recognize dependency lockfiles by name
+ *
+ * Files are classified from their content, so a hand-written app.js stays in
+ * while a bundled app.js is left out. Binary checks read the first SAMPLE_SIZE
+ * characters only.
+ */
+
+import path from 'path';
+
+export const ContentKind = Object.freeze({
+  BINARY: 'binary',
+  MINIFIED: 'minified',
+  LOCKFILE: 'lockfile'
+});
+
+export const LOCKFILE_NAMES = new Set([
+  'package-lock.json', 'npm-shrinkwrap.json', 'yarn.lock', 'pnpm-lock.yaml', 'bun.lock',
+  'Cargo.lock', 'Gemfile.lock', 'poetry.lock', 'Pipfile.lock', 'uv.lock', 'composer.lock',
+  'go.sum', 'mix.lock', 'pubspec.lock', 'Podfile.lock', 'Package.resolved',
+  'packages.lock.json', 'flake.lock', 'gradle.lockfile'
+]);
+
+// Characters sampled for binary and line-length checks (git samples 8000 bytes)
+const SAMPLE_SIZE = 8000;
+// Share of U+FFFD (bytes invalid as UTF-8) above which content is binary
+const MAX_INVALID_RATIO = 0.1;
+// Minified: a line this long, with lines this long on average
+const MIN_LONGEST_LINE = 1000;
+const MIN_MEAN_LINE = 200;
+// Shorter files are never called minified
+const MIN_MINIFIED_LENGTH = 2000;
+// Characters String.prototype.trim() removes
+const WHITESPACE = /\s/;
+
+const MINIFIED_NAME_PATTERN = /[.-]min\.(js|mjs|cjs|css)$/i;
+const MINIFIABLE_EXTENSIONS = new Set(['.js', '.mjs', '.cjs', '.css', '.json', '.svg', '.html', '.xml']);
+
+export class ContentClassifier {
+  /**
+   * @param {string} content - File content as read (UTF-8)
+   * @param {string} relativePath
+   * @returns {string|null} A ContentKind, or null for regular text
+   */
+  static classify(content, relativePath) {
+    const name = path.basename(relativePath);
+    if (LOCKFILE_NAMES.has(name)) return ContentKind.LOCKFILE;
+    if (isBinary(content)) return ContentKind.BINARY;
+    if (MINIFIED_NAME_PATTERN.test(name)) return ContentKind.MINIFIED;
+    if (MINIFIABLE_EXTENSIONS.has(path.extname(name).toLowerCase()) && isMinified(content)) return ContentKind.MINIFIED;
+    return null;
+  }
+
+  /**
+   * Report line of skipped files by kind, e.g. "2 minified, 1 lockfile"
+   * @param {Object<string, number>} counts - Files per ContentKind
+   * @returns {string}
+   */
+  static describe(counts) {
+    return Object.values(ContentKind)
+      .filter(kind => counts[kind] > 0)
+      .map(kind => `${counts[kind].toLocaleString()} ${kind}`)
+      .join(', ');
+  }
+}
+
+/**
+ * @private
+ */
+function isBinary(content) {
+  const sample = content.slice(0, SAMPLE_SIZE);
+  if (sample.includes('\0')) return true;
+
+  let invalid = 0;
+  for (const char of sample) {
+    if (char === '\uFFFD') invalid++;
+  }
+  return sample.length > 0 && invalid / sample.length > MAX_INVALID_RATIO;
+}
+
+/**
+ * Long lines on average and at least one very long line; whole-file mean,
+ * so a long license header or data literal alone does not count
+ * @private
+ */
+function isMinified(content) {
+  if (content.length < MIN_MINIFIED_LENGTH) return false;
+
+  // Line lengths are measured in place; large files are not split into lines
+  let nonEmpty = 0;
+  let longest = 0;
+  let total = 0;
+  for (let start = 0; start <= content.length;) {
+    const newline = content.indexOf('\n', start);
+    const end = newline === -1 ? content.length : newline;
+    if (hasText(content, start, end)) {
+      nonEmpty++;
+      longest = Math.max(longest, end - start);
+      total += end - start;
+    }
+    start = end + 1;
+  }
+  if (nonEmpty === 0) return false;
+
+  return longest >= MIN_LONGEST_LINE && total / nonEmpty >= MIN_MEAN_LINE;
+}
+
+/**
+ * Whether content.slice(start, end).trim() is not empty
+ * @private
+ */
+function hasText(content, start, end) {
+  for (let i = start; i < end; i++) {
+    if (!WHITESPACE.test(content[i])) return true;
+  }
+  return false;
+}
+
+export default ContentClassifier;
@@ -0,0 +1,213 @@
+/**
+ * ContentPolicy - Rules for what may leave in a context
+ * v3.4.0 - Content policies (.ctxman/policy.json, --enforce)
+ *
+ * Responsibilities:
+ * - Load policy rules from .ctxman/policy.json (or --policy FILE)
+ * - Check the files selected for export before anything is rendered:
+ *   forbidden paths (e.g. production configs), forbidden content patterns,
+ *   and required content such as license headers
+ * - List violations by rule, file and line; with enforce (--enforce, or
+ *   "enforce": true in the policy) the run fails and nothing is written
+ *
+ * Paths use .gitignore syntax and are relative to the project root.
+ * Patterns are checked against the content as it would be emitted (after
+ * --redact, --strip and --anonymize), required content against the file
+ * as it is, so stripping comments does not hide a license header.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import GitIgnoreParser from '../parsers/gitignore-parser.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('ContentPolicy');
+
+export const POLICY_FILE = path.join('.ctxman', 'policy.json');
+
+// Lines of a file a "require" rule looks at, unless the rule sets "within"
+export const DEFAULT_REQUIRE_LINES = 20;
+
+const RULE_KEYS = ['name', 'message', 'paths', 'exclude', 'pattern', 'require', 'flags', 'within'];
+
+export class ContentPolicy {
+  /**
+   * @param {Array<Object>} rules - { name, message, paths, exclude, pattern, require, within }
+   * @param {Object} options
+   */
+  constructor(rules, options = {}) {
+    this.options = {
+      source: POLICY_FILE, // File the rules came from, for messages
+      enforce: false, // Violations fail the run
+      ...options
+    };
+
+    const parser = new GitIgnoreParser(null, null, null);
+    const compileGlobs = globs => (globs || []).map(glob => parser.convertToRegex(glob).regex);
+    this.rules = rules.map(rule => ({
+      ...rule,
+      pathRegexes: compileGlobs(rule.paths),
+      excludeRegexes: compileGlobs(rule.exclude),
+      within: rule.within || DEFAULT_REQUIRE_LINES
+    }));
+  }
+
+  /**
+   * Load the policy of a project
+   * @param {string} root - Project root
+   * @param {string|null} file - Policy file; null for .ctxman/policy.json if it exists
+   * @returns {ContentPolicy|null} Null when there is no policy
+   * @throws {Error} When the file is missing (if given) or invalid
+   */
+  static load(root, file = null) {
+    const filePath = path.resolve(root, file || POLICY_FILE);
+    const source = file || POLICY_FILE;
+    if (!fs.existsSync(filePath)) {
+      if (file) throw new Error(`Policy file not found: ${file}`);
+      return null;
+    }
+
+    let data;
+    try {
+      data = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+    } catch (error) {
+      throw new Error(`${source}: ${error.message}`);
+    }
+    const policy = ContentPolicy.fromData(data, source);
+    logger.debug(`Loaded ${policy.rules.length} policy rules from ${source}`);
+    return policy;
+  }
+
+  /**
+   * @param {Object} data - Parsed policy file: { enforce, rules }
+   * @param {string} source - Its path, for messages
+   * @returns {ContentPolicy}
+   * @throws {Error} When the policy is invalid
+   */
+  static fromData(data, source = POLICY_FILE) {
+    const fail = message => {
+      throw new Error(`${source}: ${message}`);
+    };
+    if (!data || typeof data !== 'object' || Array.isArray(data) || !Array.isArray(data.rules)) {
+      fail('expected an object with a "rules" list');
+    }
+    const unknown = Object.keys(data).filter(key => !['enforce', 'rules'].includes(key));
+    if (unknown.length > 0) fail(`unknown keys: ${unknown.join(', ')} (allowed: enforce, rules)`);
+    if (data.enforce !== undefined && typeof data.enforce !== 'boolean') fail('"enforce" must be true or false');
+
+    const names = new Set();
+    const rules = data.rules.map((rule, index) => {
+      const label = `rule ${typeof rule?.name === 'string' ? `"${rule.name}"` : index + 1}`;
+      if (typeof rule?.name !== 'string' || !rule.name.trim()) fail(`${label} needs a "name"`);
+      if (names.has(rule.name)) fail(`${label} is defined twice`);
+      names.add(rule.name);
+
+      const unknownKeys = Object.keys(rule).filter(key => !RULE_KEYS.includes(key));
+      if (unknownKeys.length > 0) fail(`${label} has unknown keys: ${unknownKeys.join(', ')}`);
+      for (const key of ['paths', 'exclude']) {
+        if (rule[key] !== undefined && !(Array.isArray(rule[key]) && rule[key].every(glob => typeof glob === 'string' && glob))) {
+          fail(`${label}: "${key}" must be a list of path patterns`);
+        }
+      }
+      if (rule.pattern !== undefined && rule.require !== undefined) fail(`${label} sets both "pattern" and "require"`);
+      if (rule.pattern === undefined && rule.require === undefined && !rule.paths?.length) {
+        fail(`${label} needs "paths", a forbidden "pattern" or a "require" pattern`);
+      }
+      if (rule.within !== undefined && !(Number.isInteger(rule.within) && rule.within > 0)) {
+        fail(`${label}: "within" must be a number of lines`);
+      }
+
+      const compile = key => {
+        if (rule[key] === undefined) return null;
+        if (typeof rule[key] !== 'string' || !rule[key]) fail(`${label}: "${key}" must be a regular expression`);
+        try {
+          return new RegExp(rule[key], `${(rule.flags || '').replace(/[gy]/g, '')}m`);
+        } catch (error) {
+          return fail(`${label} has an invalid "${key}": ${error.message}`);
+        }
+      };
+      return {
+        name: rule.name,
+        message: rule.message || null,
+        paths: rule.paths || [],
+        exclude: rule.exclude || [],
+        pattern: compile('pattern'),
+        require: compile('require'),
+        within: rule.within
+      };
+    });
+
+    return new ContentPolicy(rules, { source, enforce: data.enforce === true });
+  }
+
+  /**
+   * Check files selected for export
+   * @param {Array<Object>} files - { path ('/'-separated, relative to the root), read(), readOriginal() }
+   * @returns {Array<{rule: string, file: string, line: number|null, message: string}>} By file, then line
+   */
+  evaluate(files) {
+    const violations = [];
+    for (const file of files) {
+      for (const rule of this.rules) {
+        if (!this.applies(rule, file.path)) continue;
+        const violation = (message, line = null) => violations.push({ rule: rule.name, file: file.path, line, message: rule.message || message });
+
+        if (rule.pattern) {
+          const content = file.read();
+          const match = rule.pattern.exec(content);
+          if (match) {
+            violation(`Forbidden content: ${truncate(match[0])}`, content.slice(0, match.index).split('\n').length);
+          }
+        } else if (rule.require) {
+          const head = file.readOriginal().split('\n').slice(0, rule.within).join('\n');
+          if (!rule.require.test(head)) {
+            violation(`Missing required content in the first ${rule.within} lines: /${rule.require.source}/`);
+          }
+        } else {
+          violation('Forbidden path');
+        }
+      }
+    }
+    return violations.sort((a, b) => compareBytes(a.file, b.file) || (a.line || 0) - (b.line || 0));
+  }
+
+  /**
+   * Console report of violations
+   * @param {Array<Object>} violations - From evaluate()
+   * @returns {string}
+   */
+  formatReport(violations) {
+    const lines = ['', `🛂 CONTENT POLICY (${this.options.source}, ${this.rules.length} rules)`, '='.repeat(80)];
+    if (violations.length === 0) {
+      lines.push('   No violations');
+      return lines.join('\n');
+    }
+    const files = new Set(violations.map(violation => violation.file));
+    lines.push(`   ${violations.length} violations in ${files.size} files${this.options.enforce ? '' : ' (not enforced; --enforce fails the run)'}`);
+    for (const violation of violations) {
+      const location = violation.line ? `${violation.file}:${violation.line}` : violation.file;
+      lines.push(`   ❌ ${location} [${violation.rule}] ${violation.message}`);
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * Whether a rule looks at a file
+   * @private
+   */
+  applies(rule, file) {
+    if (rule.pathRegexes.length > 0 && !rule.pathRegexes.some(regex => regex.test(file))) return false;
+    return !rule.excludeRegexes.some(regex => regex.test(file));
+  }
+}
+
+/**
+ * @private
+ */
+function truncate(text) {
+  const line = text.split('\n')[0];
+  return line.length > 60 ? `${line.slice(0, 57)}...` : line;
+}
+
+export default ContentPolicy;
@@ -0,0 +1,377 @@
+/**
+ * ContentStripper - Comment and blank line removal
+ * v3.4.0 - Space-saving content transforms (--strip)
+ *
+ * Responsibilities:
+ * - Remove comments (and Python docstrings) without touching string literals
+ * - Remove blank lines outside multi-line strings
+ * - Keep the doc comments of exported symbols when asked to (--keep-docs)
+ *
+ * Comment and string syntax is chosen by file extension; files of other
+ * types only lose blank lines, including those inside multi-line strings.
+ * Stripped contents replace the sources everywhere, so token counts,
+ * symbol lines and exports agree.
+ */
+
+import path from 'path';
+import LanguageDetector from '../languages/LanguageDetector.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ContentStripper');
+
+export const STRIP_MODES = ['comments', 'blank-lines', 'both'];
+
+const C_STYLE = { line: ['//'], block: [['/*', '*/']], quotes: ['"', "'"] };
+const HASH = { line: ['#'], block: [], quotes: ['"', "'"], hashAfterSpace: true };
+
+const SYNTAX = {
+  c: C_STYLE,
+  script: { ...C_STYLE, quotes: ['"', "'", '`'] },
+  rust: { ...C_STYLE, quotes: ['"', "'"], rustChars: true },
+  css: { ...C_STYLE, line: [] },
+  php: { ...C_STYLE, line: ['//', '#'] },
+  hcl: { ...C_STYLE, line: ['//', '#'] },
+  python: { ...HASH, hashAfterSpace: false, docstrings: true },
+  hash: HASH,
+  sql: { line: ['--'], block: [['/*', '*/']], quotes: ["'", '"'] },
+  lua: { line: ['--'], block: [['--[[', ']]']], quotes: ["'", '"'] },
+  markup: { line: [], block: [['<!--', '-->']], quotes: [] }
+};
+
+const EXTENSIONS = {
+  script: ['.js', '.jsx', '.mjs', '.cjs', '.ts', '.tsx', '.mts', '.cts', '.go'],
+  c: ['.java', '.kt', '.kts', '.scala', '.groovy', '.gradle', '.c', '.h', '.cc', '.cpp', '.cxx', '.hpp', '.cs', '.swift', '.dart', '.m', '.proto'],
+  rust: ['.rs'],
+  css: ['.css'],
+  php: ['.php'],
+  hcl: ['.tf', '.hcl'],
+  python: ['.py', '.pyi'],
+  hash: ['.rb', '.sh', '.bash', '.zsh', '.fish', '.yaml', '.yml', '.toml', '.pl', '.r', '.ps1', '.ex', '.exs', '.cfg', '.conf',
+    '.dockerfile', '.mk', '.cmake', '.just', '.awk', '.tcl'],
+  sql: ['.sql'],
+  lua: ['.lua'],
+  markup: ['.html', '.htm', '.xml', '.svg']
+};
+
+const SYNTAX_BY_EXTENSION = new Map(Object.entries(EXTENSIONS)
+  .flatMap(([syntax, extensions]) => extensions.map(extension => [extension, SYNTAX[syntax]])));
+SYNTAX_BY_EXTENSION.set('.scss', C_STYLE).set('.less', C_STYLE);
+
+// Lines allowed between a doc comment and its declaration
+const ATTRIBUTE_LINE = /^\s*(@|#\[|\[)/;
+
+// Header lines a Python docstring follows
+const PYTHON_HEADER = /^\s*(async\s+def|def|class)\b|^\s*\).*:\s*(#.*)?$/;
+
+export class ContentStripper {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      mode: 'both', // comments, blank-lines or both
+      keepDocs: false, // Keep doc comments of exported symbols
+      extractor: null, // SymbolExtractor finding exported symbols (default: heuristic)
+      ...options
+    };
+
+    if (!STRIP_MODES.includes(this.options.mode)) {
+      throw new Error(`Invalid strip mode: ${this.options.mode} (expected ${STRIP_MODES.join(', ')})`);
+    }
+    this.comments = this.options.mode !== 'blank-lines';
+    this.blankLines = this.options.mode !== 'comments';
+    this.extractor = this.options.extractor || new SymbolExtractor({ backend: 'heuristic' });
+    this.files = new Map(); // relativePath -> { source, content }
+  }
+
+  /**
+   * Settings that change stripped contents, for cache keys
+   * @returns {string} e.g. "comments+docs"
+   */
+  getKey() {
+    return this.options.keepDocs ? `${this.options.mode}+docs` : this.options.mode;
+  }
+
+  /**
+   * Comment syntax of a file, or null when its comments are unknown
+   * @param {string} filePath
+   * @param {string|null} [content] - Identifies extension-less scripts by their shebang
+   * @returns {Object|null}
+   */
+  static syntaxOf(filePath, content = null) {
+    return SYNTAX_BY_EXTENSION.get(LanguageDetector.extensionOf(filePath, content)) || null;
+  }
+
+  /**
+   * Strip a file's content; stripping the same content again returns the recorded result
+   * @param {string} content
+   * @param {string} relativePath
+   * @returns {string}
+   */
+  strip(content, relativePath) {
+    const file = relativePath.split(path.sep).join('/');
+    const known = this.files.get(file);
+    if (known && known.source === content) return known.content;
+
+    const stripped = this.transform(content, file);
+    this.files.set(file, { source: content, content: stripped });
+    return stripped;
+  }
+
+  /**
+   * @private
+   */
+  transform(content, file) {
+    const syntax = ContentStripper.syntaxOf(file, content);
+    const scanned = syntax ? scan(content, syntax) : { comments: [], strings: [] };
+    const strings = scanned.strings;
+    const comments = this.comments ? scanned.comments : [];
+    const lineStarts = [0];
+    for (let i = content.indexOf('\n'); i !== -1; i = content.indexOf('\n', i + 1)) {
+      lineStarts.push(i + 1);
+    }
+    const lineOf = offset => {
+      let low = 0;
+      let high = lineStarts.length - 1;
+      while (low < high) {
+        const mid = (low + high + 1) >> 1;
+        if (lineStarts[mid] <= offset) low = mid;
+        else high = mid - 1;
+      }
+      return low;
+    };
+
+    for (const comment of comments) {
+      comment.startLine = lineOf(comment.start);
+      comment.endLine = lineOf(Math.max(comment.start, comment.end - 1));
+    }
+    const kept = this.options.keepDocs && comments.length > 0 ? this.docComments(content, file, comments) : new Set();
+    const removed = comments.filter(comment => !kept.has(comment));
+
+    // Lines that start inside a string keep their blank lines and trailing text
+    const protectedLines = new Set();
+    for (const string of strings) {
+      for (let line = lineOf(string.start) + 1; line <= lineOf(string.end - 1); line++) {
+        protectedLines.add(line);
+      }
+    }
+
+    const lines = [];
+    let next = 0;
+    for (let line = 0; line < lineStarts.length; line++) {
+      const start = lineStarts[line];
+      const end = line + 1 < lineStarts.length ? lineStarts[line + 1] - 1 : content.length;
+      let text = '';
+      let cut = false;
+      let offset = start;
+      while (next < removed.length && removed[next].end <= start) next++;
+      for (let r = next; r < removed.length && removed[r].start < end; r++) {
+        const from = Math.max(start, removed[r].start);
+        text += content.slice(offset, Math.max(offset, from));
+        offset = Math.max(offset, Math.min(end, removed[r].end));
+        cut = true;
+      }
+      text += content.slice(offset, end);
+
+      const blank = text.trim() === '' && !protectedLines.has(line);
+      if (cut && blank) continue;
+      if (this.blankLines && blank && line + 1 < lineStarts.length) continue;
+      lines.push(cut ? text.trimEnd() : text);
+    }
+
+    const stripped = lines.join('\n');
+    logger.debug(`${file}: ${removed.length} comments removed, ${content.length - stripped.length} characters saved`);
+    return stripped;
+  }
+
+  /**
+   * Comments documenting exported symbols: the block right above the
+   * declaration (past attributes or decorators), or a Python docstring
+   * @private
+   */
+  docComments(content, file, comments) {
+    const symbols = this.extractor.supports(file) ? this.extractor.extract(content, file) : [];
+    const kept = new Set();
+    const endingOn = new Map();
+    for (const comment of comments) {
+      if (!comment.docstring) endingOn.set(comment.endLine, comment);
+    }
+    const lines = content.split('\n');
+
+    for (const symbol of symbols.filter(candidate => candidate.exported)) {
+      let line = symbol.startLine - 2;
+      while (line >= 0 && ATTRIBUTE_LINE.test(lines[line]) && !endingOn.has(line)) line--;
+
+      // A doc block may be several line comments, each on its own line
+      let comment = endingOn.get(line);
+      while (comment && lines[comment.startLine].slice(0, comment.start - lineStartOf(lines, comment.startLine)).trim() === '') {
+        kept.add(comment);
+        comment = endingOn.get(comment.startLine - 1);
+      }
+    }
+
+    for (const docstring of comments.filter(comment => comment.docstring)) {
+      const owner = symbols.filter(symbol => symbol.startLine - 1 <= docstring.startLine)
+        .sort((a, b) => b.startLine - a.startLine)[0];
+      if (!owner || owner.exported) kept.add(docstring);
+    }
+    return kept;
+  }
+}
+
+/**
+ * @private
+ */
+function lineStartOf(lines, line) {
+  let offset = 0;
+  for (let i = 0; i < line; i++) offset += lines[i].length + 1;
+  return offset;
+}
+
+/**
+ * Comment and string ranges of a source text, in order
+ * Python docstrings are returned as comments with `docstring: true`.
+ * @param {string} content
+ * @param {Object} syntax
+ * @returns {{comments: Array<{start: number, end: number}>, strings: Array<{start: number, end: number}>}}
+ */
+export function scan(content, syntax) {
+  const comments = [];
+  const strings = [];
+  let lastCode = null; // Last character outside comments and whitespace
+  let lineStart = true; // Only whitespace since the last newline
+  let i = 0;
+
+  // Keep a shebang line
+  if (content.startsWith('#!')) {
+    i = content.indexOf('\n') === -1 ? content.length : content.indexOf('\n');
+    lastCode = '!';
+  }
+
+  while (i < content.length) {
+    const ch = content[i];
+
+    if (ch === '\n') {
+      lineStart = true;
+      i++;
+      continue;
+    }
+    if (ch === ' ' || ch === '\t' || ch === '\r') {
+      i++;
+      continue;
+    }
+
+    // Markers are matched in loops: a closure per character adds up on large files
+    const block = blockAt(content, i, syntax);
+    if (block) {
+      const close = content.indexOf(block[1], i + block[0].length);
+      const end = close === -1 ? content.length : close + block[1].length;
+      comments.push({ start: i, end });
+      i = end;
+      continue;
+    }
+
+    const line = lineCommentAt(content, i, syntax);
+    if (line) {
+      const newline = content.indexOf('\n', i);
+      const end = newline === -1 ? content.length : newline;
+      comments.push({ start: i, end: content[end - 1] === '\r' ? end - 1 : end });
+      i = end;
+      continue;
+    }
+
+    if (syntax.quotes.includes(ch) && !(ch === "'" && syntax.rustChars && !/^'(?:\\.|[^\\'\n])'/.test(content.slice(i, i + 12)))) {
+      const start = i;
+      const triple = syntax.docstrings && content.startsWith(ch.repeat(3), i);
+      const end = stringEnd(content, i, ch, triple);
+      const statement = lineStart && /^[ \t]*(#.*)?(\n|$)/.test(content.slice(end, content.indexOf('\n', end) === -1 ? content.length : content.indexOf('\n', end) + 1));
+      const afterHeader = lastCode === null || (lastCode === ':' && PYTHON_HEADER.test(lineBefore(content, start)));
+      if (syntax.docstrings && statement && afterHeader) {
+        comments.push({ start, end, docstring: true });
+      } else {
+        strings.push({ start, end });
+      }
+      lastCode = ch;
+      lineStart = false;
+      i = end;
+      continue;
+    }
+
+    if (ch === '\\') {
+      i += 2;
+    } else {
+      i++;
+    }
+    lastCode = ch;
+    lineStart = false;
+  }
+
+  return { comments, strings };
+}
+
+/**
+ * Block comment delimiters opening at an offset, or undefined
+ * @private
+ */
+function blockAt(content, i, syntax) {
+  for (const block of syntax.block) {
+    if (content.startsWith(block[0], i)) return block;
+  }
+  return undefined;
+}
+
+/**
+ * Line comment marker at an offset, or undefined
+ * @private
+ */
+function lineCommentAt(content, i, syntax) {
+  for (const marker of syntax.line) {
+    if (!content.startsWith(marker, i)) continue;
+    if (marker === '#' && syntax.hashAfterSpace && i > 0 && !/\s/.test(content[i - 1])) continue;
+    return marker;
+  }
+  return undefined;
+}
+
+/**
+ * @private
+ */
+function stringEnd(content, start, quote, triple) {
+  if (triple) {
+    const close = content.indexOf(quote.repeat(3), start + 3);
+    return close === -1 ? content.length : close + 3;
+  }
+
+  const multiline = quote === '`';
+  let i = start + 1;
+  while (i < content.length) {
+    const ch = content[i];
+    if (ch === '\\') {
+      i += 2;
+      continue;
+    }
+    if (ch === quote) return i + 1;
+    if (ch === '\n' && !multiline) return i;
+    i++;
+  }
+  return content.length;
+}
+
+/**
+ * Last non-blank line before an offset (the line holding the preceding code)
+ * @private
+ */
+function lineBefore(content, offset) {
+  // Walk back line by line instead of splitting everything before the offset
+  let end = offset;
+  while (end > 0) {
+    const start = content.lastIndexOf('\n', end - 1) + 1;
+    const line = content.slice(start, end);
+    if (line.trim()) return line;
+    end = start - 1;
+  }
+  return '';
+}
+
+export default ContentStripper;
@@ -0,0 +1,297 @@
+/**
+ * ContextAnonymizer - Context without the project's business terms
+ * v3.4.0 - Anonymized contexts (--anonymize, ctxman deanonymize)
+ *
+ * Responsibilities:
+ * - Learn the project's names: packages and directories, file names and
+ *   the types, functions and variables its files declare
+ * - Rename them to neutral placeholders (pkg1, file2, Type3, func4, var5)
+ *   everywhere in a written context, and string literals to str6
+ * - Keep the mapping in a local file so placeholders stay the same across
+ *   runs and an answer written in placeholders can be translated back
+ *
+ * Names are renamed as whole words in any case: Invoice becomes Type1,
+ * invoice type1 and INVOICE TYPE1, so exported Go names stay exported.
+ * Language keywords and the words of the context formats are kept, and so
+ * are names the project does not declare (standard library, dependencies).
+ * Import paths are renamed word by word rather than replaced, so package
+ * structure survives.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+
+export const ANONYMIZE_FILE = path.join('.ctxman', 'anonymize.json');
+
+const MAP_SCHEMA = 'ctxman-anonymize/1';
+
+// Placeholder prefix of each kind of name
+const PREFIXES = {
+  [SymbolKind.MODULE]: 'pkg',
+  [SymbolKind.CLASS]: 'type',
+  [SymbolKind.STRUCT]: 'type',
+  [SymbolKind.INTERFACE]: 'type',
+  [SymbolKind.TRAIT]: 'type',
+  [SymbolKind.ENUM]: 'type',
+  [SymbolKind.TYPE]: 'type',
+  [SymbolKind.FUNCTION]: 'func',
+  [SymbolKind.METHOD]: 'func',
+  [SymbolKind.CONSTANT]: 'var',
+  [SymbolKind.VARIABLE]: 'var'
+};
+
+const PLACEHOLDER = /^(pkg|file|type|func|var|str)\d+$/i;
+
+// Keywords of the supported languages, conventional names and the words of
+// the digest, JSON, YAML, Markdown and XML formats
+const KEEP = new Set(`
+  abstract as async await break case catch chan class const constructor continue
+  crate def default defer del delete do dyn elif else enum export extends extern false
+  fallthrough final finally fn for from func function global go goto if impl implements
+  import in init instanceof interface is lambda let loop map match mod module move mut
+  namespace new nil none nonlocal not null of or package pass private protected pub
+  public raise range readonly return select self static struct super switch this throw
+  throws trait true try type typeof undefined union unsafe use var void where while with
+  yield string number boolean error errors object any int float bool byte rune char
+  main index app src lib libs pkg cmd internal bin test tests spec specs util utils
+  docs doc examples vendor dist build scripts config
+  directory files file analyzed estimated tokens structure part path name kind content
+  lines symbols language summary signature imports project selection mode target budget
+  included omitted id start end version format stats chunk parts size methods method
+  github gitlab bitbucket com org net io dev www http https
+`.trim().split(/\s+/));
+
+// Words of a line that is an import, require, include or use statement
+const IMPORT_LINE = /^\s*(?:import\b|export\s.*\bfrom\b|#\s*include\b|use\b|.*\brequire\s*\(|.*\bimport\s*\()/;
+
+// Lines of a Go import ( ... ) block
+const IMPORT_BLOCK_START = /^\s*import\s*\(\s*$/;
+const IMPORT_BLOCK_END = /^\s*\)/;
+
+const STRING_LITERAL = /(["'`])((?:\\.|(?!\1)[^\\\n])*)\1/g;
+
+const WORD = /[A-Za-z_][A-Za-z0-9_]*/g;
+
+export class ContextAnonymizer {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      file: null, // Mapping file, read by load() and written by save()
+      extractor: null, // SymbolExtractor for declared names
+      ...options
+    };
+    this.names = new Map(); // Original spelling -> placeholder
+    this.strings = new Map(); // Literal content -> placeholder
+    this.bases = new Map(); // Lower-case name -> lower-case placeholder
+    this.counters = {};
+  }
+
+  /**
+   * Anonymizer with the mapping of an earlier run, new when there is none
+   * @param {string} filePath - Mapping file
+   * @returns {ContextAnonymizer}
+   * @throws {Error} For unreadable mapping files
+   */
+  static load(filePath) {
+    const anonymizer = new ContextAnonymizer({ file: filePath });
+    if (!fs.existsSync(filePath)) {
+      return anonymizer;
+    }
+
+    let state;
+    try {
+      state = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+    } catch (error) {
+      throw new Error(`Cannot read anonymization map ${filePath}: ${error.message}`);
+    }
+    if (state.schema !== MAP_SCHEMA) {
+      throw new Error(`${filePath} is not a ctxman anonymization map (expected schema ${MAP_SCHEMA})`);
+    }
+    for (const [original, placeholder] of Object.entries(state.names || {})) {
+      anonymizer.names.set(original, placeholder);
+      anonymizer.bases.set(original.toLowerCase(), placeholder.toLowerCase());
+      anonymizer.count(placeholder);
+    }
+    for (const [literal, placeholder] of Object.entries(state.strings || {})) {
+      anonymizer.strings.set(literal, placeholder);
+      anonymizer.count(placeholder);
+    }
+    return anonymizer;
+  }
+
+  /**
+   * Learn the names of a project file: its path and the symbols it declares
+   * @param {string} content
+   * @param {string} relativePath - '/'-separated
+   */
+  learn(content, relativePath) {
+    // Declared names first, so a file named after its type is renamed after it too
+    const extractor = this.extractor();
+    if (extractor.supports(relativePath, content)) {
+      for (const symbol of extractor.extract(content, relativePath)) {
+        const prefix = PREFIXES[symbol.kind] || 'var';
+        const names = symbol.kind === SymbolKind.MODULE ? symbol.name.split(/[./\\]/) : [symbol.name];
+        names.forEach(name => this.add(name, prefix));
+      }
+    }
+
+    const parts = relativePath.split('/');
+    const fileName = parts.pop();
+    parts.forEach(part => this.learnPath(part));
+    this.add(fileName.replace(/\..*$/, ''), 'file');
+
+    if (fileName === 'go.mod') {
+      const module = /^module\s+(\S+)/m.exec(content);
+      if (module) this.learnPath(module[1]);
+    } else if (fileName === 'package.json') {
+      try {
+        const { name } = JSON.parse(content);
+        if (typeof name === 'string') this.learnPath(name);
+      } catch {
+        // Invalid manifests contribute no name
+      }
+    }
+  }
+
+  /**
+   * Learn the words of a directory, module path or package name as packages
+   * @param {string} value - e.g. billing, example.com/acme/billing or @acme/billing-core
+   */
+  learnPath(value) {
+    for (const [word] of value.matchAll(WORD)) this.add(word, 'pkg');
+  }
+
+  /**
+   * Replace string literals of a source file, keeping their quotes and line count
+   * Imports keep their paths, renamed word by word by anonymize(). Files
+   * without a language plugin (docs, data) are returned as they are.
+   * @param {string} content
+   * @param {string} relativePath - '/'-separated
+   * @returns {string}
+   */
+  replaceStrings(content, relativePath) {
+    if (!this.extractor().supports(relativePath, content)) return content;
+
+    let importBlock = false;
+    return content.split('\n').map(line => {
+      if (importBlock || IMPORT_LINE.test(line)) {
+        importBlock = importBlock ? !IMPORT_BLOCK_END.test(line) : IMPORT_BLOCK_START.test(line);
+        return line;
+      }
+      return line.replace(STRING_LITERAL, (literal, quote, text) => {
+        if (text.length < 2 || PLACEHOLDER.test(text)) return literal;
+        if (!this.strings.has(text)) this.strings.set(text, this.next('str'));
+        return `${quote}${this.strings.get(text)}${quote}`;
+      });
+    }).join('\n');
+  }
+
+  /**
+   * Rename the learned names of a written context
+   * @param {string} text
+   * @returns {string}
+   */
+  anonymize(text) {
+    return text.replace(WORD, word => {
+      const base = this.bases.get(word.toLowerCase());
+      if (!base) return word;
+      if (!this.names.has(word)) this.names.set(word, withCase(base, word));
+      return this.names.get(word);
+    });
+  }
+
+  /**
+   * Translate placeholders back to the project's names and literals
+   * @param {string} text - e.g. an answer to an anonymized context
+   * @returns {string}
+   */
+  restore(text) {
+    const originals = new Map();
+    for (const [original, placeholder] of this.names) {
+      if (!originals.has(placeholder)) originals.set(placeholder, original);
+    }
+    for (const [literal, placeholder] of this.strings) {
+      originals.set(placeholder, literal);
+    }
+    return text.replace(WORD, word => originals.get(word) ?? word);
+  }
+
+  /**
+   * Write the mapping file (atomic rename)
+   * @param {string} [filePath] - Defaults to options.file
+   * @returns {string} Path written
+   */
+  save(filePath = this.options.file) {
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    const tmpPath = `${filePath}.${process.pid}.tmp`;
+    fs.writeFileSync(tmpPath, `${JSON.stringify({
+      schema: MAP_SCHEMA,
+      names: Object.fromEntries(this.names),
+      strings: Object.fromEntries(this.strings)
+    }, null, 2)}\n`);
+    fs.renameSync(tmpPath, filePath);
+    return filePath;
+  }
+
+  /**
+   * Report line, e.g. "12 names, 4 strings"
+   * @returns {string}
+   */
+  describe() {
+    return `${this.bases.size} names, ${this.strings.size} strings`;
+  }
+
+  /**
+   * @private
+   */
+  extractor() {
+    if (!this.options.extractor) {
+      this.options.extractor = new SymbolExtractor({ backend: 'heuristic' });
+    }
+    return this.options.extractor;
+  }
+
+  /**
+   * @private
+   */
+  add(name, prefix) {
+    const key = name.toLowerCase();
+    if (name.length < 3 || KEEP.has(key) || PLACEHOLDER.test(name) || this.bases.has(key)) return;
+    const base = this.next(prefix);
+    this.bases.set(key, base);
+    this.names.set(name, withCase(base, name));
+  }
+
+  /**
+   * @private
+   */
+  next(prefix) {
+    this.counters[prefix] = (this.counters[prefix] || 0) + 1;
+    return `${prefix}${this.counters[prefix]}`;
+  }
+
+  /**
+   * Keep numbering after the placeholders of a loaded mapping
+   * @private
+   */
+  count(placeholder) {
+    const [, prefix, number] = /^([a-z]+)(\d+)$/.exec(placeholder.toLowerCase()) || [];
+    if (prefix) this.counters[prefix] = Math.max(this.counters[prefix] || 0, Number(number));
+  }
+}
+
+/**
+ * Placeholder in the case of the name it replaces: Invoice -> Type1, INVOICE -> TYPE1
+ * @private
+ */
+function withCase(base, name) {
+  if (name.length > 1 && name === name.toUpperCase() && /[A-Z]/.test(name)) return base.toUpperCase();
+  if (/^[A-Z]/.test(name)) return base[0].toUpperCase() + base.slice(1);
+  return base;
+}
+
+export default ContextAnonymizer;
@@ -0,0 +1,284 @@
+/**
+ * ContextComparer - Difference between two generated contexts
+ * v3.4.0 - Context comparison
+ *
+ * Responsibilities:
+ * - Read structured contexts (--format json, schema ctxman.context/v1),
+ *   LLM contexts (llm-context.json, file or method level) and context packs
+ * - Report added, removed and changed files and symbols with token deltas
+ * - Sum token deltas per top-level directory to show where a context grew
+ */
+
+import fs from 'fs';
+import { STRUCTURED_SCHEMA } from '../formatters/structured-formatter.js';
+import ContextPack, { PACK_EXTENSION } from './ContextPack.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export class ContextComparer {
+  /**
+   * Read a generated context file
+   * @param {string} filePath
+   * @returns {Object} Normalized context
+   */
+  static load(filePath) {
+    if (filePath.endsWith(PACK_EXTENSION)) {
+      return ContextComparer.fromPack(ContextPack.read(filePath), filePath);
+    }
+
+    let document;
+    try {
+      document = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+    } catch (error) {
+      throw new Error(`Cannot read context ${filePath}: ${error.message}`);
+    }
+    return ContextComparer.normalize(document, filePath);
+  }
+
+  /**
+   * Context of a pack (ctxman pack): its structured context when it has one,
+   * otherwise the files and tokens of its manifest
+   * @param {ContextPack} pack
+   * @param {string} label - Name used in errors
+   * @returns {{source: string, format: string, tokens: number|null, files: Map}}
+   */
+  static fromPack(pack, label = 'pack') {
+    const structured = pack.entries.get('context.json');
+    if (structured) {
+      return ContextComparer.normalize(JSON.parse(structured.toString('utf8')), label);
+    }
+    const files = new Map(pack.manifest.files.map(file => [file.path, { tokens: file.tokens, symbols: new Map() }]));
+    return { source: label, format: 'pack', tokens: sumTokens(files), files };
+  }
+
+  /**
+   * Normalize a parsed context to files with tokens and symbols
+   * Tokens are null where the context does not record them (compact paths).
+   * @param {Object} document - Structured context or llm-context.json
+   * @param {string} label - Name used in errors
+   * @returns {{source: string, format: string, tokens: number|null, files: Map}}
+   */
+  static normalize(document, label = 'context') {
+    const files = new Map();
+
+    if (document?.schema === STRUCTURED_SCHEMA) {
+      for (const file of document.files) {
+        const symbols = new Map();
+        for (const symbol of file.symbols || []) {
+          symbols.set(`${symbol.kind}:${symbol.name}`, {
+            name: symbol.name,
+            kind: symbol.kind,
+            signature: symbol.signature ?? null,
+            tokens: symbol.tokens ?? null
+          });
+        }
+        files.set(file.path, { tokens: file.tokens ?? null, symbols });
+      }
+      return { source: label, format: 'structured', tokens: document.project?.tokens ?? sumTokens(files), files };
+    }
+
+    if (document?.methods && typeof document.methods === 'object') {
+      for (const [filePath, methods] of Object.entries(document.methods)) {
+        const symbols = new Map();
+        for (const method of methods) {
+          symbols.set(`method:${method.name}`, { name: method.name, kind: 'method', signature: null, tokens: method.tokens ?? null });
+        }
+        files.set(filePath, { tokens: sumTokens(symbols), symbols });
+      }
+      return { source: label, format: 'methods', tokens: document.project?.totalTokens ?? null, files };
+    }
+
+    if (document?.paths && typeof document.paths === 'object') {
+      for (const [directory, names] of Object.entries(document.paths)) {
+        const prefix = directory === '/' ? '' : directory;
+        for (const name of names) {
+          files.set(`${prefix}${name}`, { tokens: null, symbols: new Map() });
+        }
+      }
+      return { source: label, format: 'paths', tokens: document.project?.totalTokens ?? null, files };
+    }
+
+    throw new Error(`${label} is not a generated context (expected ${STRUCTURED_SCHEMA} or llm-context.json)`);
+  }
+
+  /**
+   * Compare two normalized contexts
+   * @param {Object} before - Older context
+   * @param {Object} after - Newer context
+   * @returns {Object} Comparison with tokens, files, symbols and sections
+   */
+  static compare(before, after) {
+    const files = { added: [], removed: [], changed: [], unchanged: 0 };
+    const symbols = { added: 0, removed: 0, changed: 0 };
+    const sections = new Map();
+
+    const section = filePath => {
+      const name = filePath.includes('/') ? filePath.split('/')[0] : '.';
+      if (!sections.has(name)) {
+        sections.set(name, { name, before: 0, after: 0, delta: 0, added: 0, removed: 0, changed: 0 });
+      }
+      return sections.get(name);
+    };
+
+    const paths = [...new Set([...before.files.keys(), ...after.files.keys()])].sort(compareBytes);
+    for (const filePath of paths) {
+      const old = before.files.get(filePath) || null;
+      const current = after.files.get(filePath) || null;
+      const entry = section(filePath);
+      entry.before += old?.tokens || 0;
+      entry.after += current?.tokens || 0;
+
+      const symbolChanges = ContextComparer.compareSymbols(old?.symbols || new Map(), current?.symbols || new Map());
+      symbols.added += symbolChanges.added.length;
+      symbols.removed += symbolChanges.removed.length;
+      symbols.changed += symbolChanges.changed.length;
+
+      const change = {
+        path: filePath,
+        before: old?.tokens ?? null,
+        after: current?.tokens ?? null,
+        delta: delta(old?.tokens ?? (old ? null : 0), current?.tokens ?? (current ? null : 0)),
+        symbols: symbolChanges
+      };
+
+      if (!old) {
+        files.added.push(change);
+        entry.added++;
+      } else if (!current) {
+        files.removed.push(change);
+        entry.removed++;
+      } else if (old.tokens !== current.tokens || symbolChanges.added.length || symbolChanges.removed.length || symbolChanges.changed.length) {
+        files.changed.push(change);
+        entry.changed++;
+      } else {
+        files.unchanged++;
+      }
+    }
+
+    for (const entry of sections.values()) {
+      entry.delta = entry.after - entry.before;
+    }
+
+    const byDelta = (a, b) => Math.abs(b.delta ?? 0) - Math.abs(a.delta ?? 0) || compareBytes(a.path, b.path);
+    files.changed.sort(byDelta);
+
+    return {
+      before: { source: before.source, format: before.format },
+      after: { source: after.source, format: after.format },
+      tokens: { before: before.tokens, after: after.tokens, delta: delta(before.tokens, after.tokens) },
+      // Compact path contexts carry no per-file tokens
+      fileTokens: before.format !== 'paths' && after.format !== 'paths',
+      files,
+      symbols,
+      sections: [...sections.values()]
+        .filter(entry => entry.delta !== 0 || entry.added || entry.removed || entry.changed)
+        .sort((a, b) => Math.abs(b.delta) - Math.abs(a.delta) || compareBytes(a.name, b.name))
+    };
+  }
+
+  /**
+   * Symbols added, removed, or changed in signature or tokens within one file
+   * @param {Map} before
+   * @param {Map} after
+   * @returns {{added: Object[], removed: Object[], changed: Object[]}}
+   */
+  static compareSymbols(before, after) {
+    const added = [...after].filter(([key]) => !before.has(key)).map(([, symbol]) => ({ ...symbol, delta: symbol.tokens }));
+    const removed = [...before].filter(([key]) => !after.has(key)).map(([, symbol]) => ({ ...symbol, delta: delta(symbol.tokens, 0) }));
+    const changed = [];
+
+    for (const [key, old] of before) {
+      const current = after.get(key);
+      if (!current || (old.signature === current.signature && old.tokens === current.tokens)) continue;
+      changed.push({
+        name: current.name,
+        kind: current.kind,
+        signature: old.signature !== current.signature ? { before: old.signature, after: current.signature } : null,
+        before: old.tokens,
+        after: current.tokens,
+        delta: delta(old.tokens, current.tokens)
+      });
+    }
+
+    return { added, removed, changed };
+  }
+
+  /**
+   * Format a comparison as a console report
+   * @param {Object} comparison - Result of compare()
+   * @returns {string}
+   */
+  static formatComparison(comparison) {
+    const { tokens, files, symbols } = comparison;
+    const lines = ['', '🆚 CONTEXT COMPARISON', '='.repeat(80)];
+
+    lines.push(`   Old: ${comparison.before.source} (${comparison.before.format})`);
+    lines.push(`   New: ${comparison.after.source} (${comparison.after.format})`);
+    lines.push(`   Tokens: ${formatTokens(tokens.before)} → ${formatTokens(tokens.after)} (${formatDelta(tokens.delta)})`);
+    lines.push(`   Files: +${files.added.length} added, -${files.removed.length} removed, ~${files.changed.length} changed, ${files.unchanged} unchanged`);
+    lines.push(`   Symbols: +${symbols.added} added, -${symbols.removed} removed, ~${symbols.changed} changed`);
+
+    if (comparison.fileTokens && comparison.sections.length > 0) {
+      lines.push('', '   Token delta by section:');
+      for (const entry of comparison.sections) {
+        lines.push(`   ${formatDelta(entry.delta).padStart(16)}  ${entry.name === '.' ? '(root)' : `${entry.name}/`}  ` +
+          `${entry.before.toLocaleString()} → ${entry.after.toLocaleString()}`);
+      }
+    }
+
+    const describeFile = (marker, change) => {
+      lines.push(`   ${marker} ${change.path}${comparison.fileTokens ? `  ${formatDelta(change.delta)}` : ''}`);
+      for (const symbol of change.symbols.added) {
+        lines.push(`       + ${symbol.kind} ${symbol.name}${symbol.tokens !== null ? `  ${formatDelta(symbol.delta)}` : ''}`);
+      }
+      for (const symbol of change.symbols.removed) {
+        lines.push(`       - ${symbol.kind} ${symbol.name}${symbol.tokens !== null ? `  ${formatDelta(symbol.delta)}` : ''}`);
+      }
+      for (const symbol of change.symbols.changed) {
+        const what = symbol.signature ? ' (signature changed)' : '';
+        lines.push(`       ~ ${symbol.kind} ${symbol.name}  ${formatDelta(symbol.delta)}${what}`);
+      }
+    };
+
+    if (files.added.length > 0) {
+      lines.push('', '   Added files:');
+      files.added.forEach(change => describeFile('+', change));
+    }
+    if (files.removed.length > 0) {
+      lines.push('', '   Removed files:');
+      files.removed.forEach(change => describeFile('-', change));
+    }
+    if (files.changed.length > 0) {
+      lines.push('', '   Changed files:');
+      files.changed.forEach(change => describeFile('~', change));
+    }
+    if (files.added.length + files.removed.length + files.changed.length === 0) {
+      lines.push('', '   ✅ Contexts contain the same files and symbols');
+    }
+
+    return lines.join('\n');
+  }
+}
+
+function sumTokens(entries) {
+  let total = 0;
+  for (const { tokens } of entries.values()) {
+    if (tokens === null) return null;
+    total += tokens;
+  }
+  return total;
+}
+
+function delta(before, after) {
+  return before === null || after === null || before === undefined || after === undefined ? null : after - before;
+}
+
+function formatTokens(tokens) {
+  return tokens === null ? '?' : tokens.toLocaleString();
+}
+
+function formatDelta(value) {
+  if (value === null) return '? tokens';
+  return `${value > 0 ? '+' : ''}${value.toLocaleString()} tokens`;
+}
+
+export default ContextComparer;
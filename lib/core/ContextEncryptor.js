@@ -0,0 +1,227 @@
+/**
+ * ContextEncryptor - Context bundles only their recipients can read
+ * v3.4.0 - Encrypted contexts (--encrypt, ctxman decrypt)
+ *
+ * Responsibilities:
+ * - Parse --encrypt age:<recipient> and gpg:<recipient> values
+ * - Encrypt contexts with the age or gpg command before they are written,
+ *   so proprietary code can pass through shared channels
+ * - Decrypt them again (ctxman decrypt), telling age from gpg by content
+ *
+ * Content goes to the encryption command on stdin, so only ciphertext
+ * reaches the output file; chunked digests are encrypted as each chunk is
+ * written. Recipients are age public keys (or SSH keys), gpg key IDs
+ * or emails, or files listing them. Keys are never handled here: decryption
+ * uses an age identity file or the gpg keyring.
+ */
+
+import fs from 'fs';
+import { spawnSync } from 'child_process';
+import { writeFileAtomic } from './ContextWriter.js';
+
+export const ENCRYPTION_SCHEMES = ['age', 'gpg'];
+
+// File suffix of each scheme; armored gpg output is conventionally .asc
+const EXTENSIONS = { age: '.age', gpg: '.gpg' };
+const ARMORED_EXTENSIONS = { age: '.age', gpg: '.asc' };
+
+const AGE_HEADERS = ['age-encryption.org/', '-----BEGIN AGE ENCRYPTED FILE-----'];
+
+export class ContextEncryptor {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      scheme: 'age', // One of ENCRYPTION_SCHEMES
+      recipients: [], // Public keys, key IDs, emails or recipient files
+      armor: false, // ASCII output, for stdout and the clipboard
+      command: null, // Encryption command; defaults to the scheme name
+      ...options
+    };
+    if (!ENCRYPTION_SCHEMES.includes(this.options.scheme)) {
+      throw new Error(`Unknown --encrypt scheme: ${this.options.scheme} (expected ${ENCRYPTION_SCHEMES.join(' or ')})`);
+    }
+    if (this.options.recipients.length === 0) {
+      throw new Error(`--encrypt ${this.options.scheme} needs at least one recipient`);
+    }
+    this.scheme = this.options.scheme;
+  }
+
+  /**
+   * Encryptor for --encrypt values such as "age:age1ql3z..." or "gpg:alice@example.com"
+   * Repeated values add recipients; all of them must use one scheme.
+   * @param {Array<string>} values
+   * @returns {ContextEncryptor}
+   * @throws {Error} For values without a scheme or recipient, and for mixed schemes
+   */
+  static parse(values) {
+    const recipients = [];
+    let scheme = null;
+    for (const value of values) {
+      const separator = String(value ?? '').indexOf(':');
+      const name = separator === -1 ? value : value.slice(0, separator);
+      const recipient = separator === -1 ? '' : value.slice(separator + 1).trim();
+      if (!ENCRYPTION_SCHEMES.includes(name) || !recipient) {
+        throw new Error(`Invalid --encrypt value: ${value || ''} (expected age:<recipient> or gpg:<recipient>)`);
+      }
+      if (scheme && name !== scheme) {
+        throw new Error(`--encrypt recipients must all use one scheme (got ${scheme} and ${name})`);
+      }
+      scheme = name;
+      recipients.push(recipient);
+    }
+    return new ContextEncryptor({ scheme, recipients });
+  }
+
+  /**
+   * Scheme of an encrypted file, from its first bytes
+   * @param {Buffer} content
+   * @returns {string} age or gpg
+   */
+  static detect(content) {
+    const head = content.subarray(0, 64).toString('latin1');
+    return AGE_HEADERS.some(header => head.startsWith(header)) ? 'age' : 'gpg';
+  }
+
+  /**
+   * Decrypt an encrypted context
+   * @param {Buffer} content
+   * @param {Object} [options] - identity: age identity file; command: decryption command
+   * @returns {Buffer}
+   * @throws {Error} When the command is missing or cannot decrypt
+   */
+  static decrypt(content, { identity = null, command = null } = {}) {
+    const scheme = ContextEncryptor.detect(content);
+    let args;
+    if (scheme === 'age') {
+      if (!identity) {
+        throw new Error('Decrypting an age context needs --identity <key file>');
+      }
+      args = ['--decrypt', '--identity', identity];
+    } else {
+      args = ['--batch', '--quiet', '--decrypt'];
+    }
+    return run(command || scheme, args, content, scheme);
+  }
+
+  /**
+   * Path of an encrypted file without its encryption suffix
+   * @param {string} filePath - e.g. digest.txt.age
+   * @returns {string|null} e.g. digest.txt, or null without a known suffix
+   */
+  static plainPath(filePath) {
+    const extension = ['.age', '.gpg', '.asc'].find(suffix => filePath.endsWith(suffix) && filePath.length > suffix.length);
+    return extension ? filePath.slice(0, -extension.length) : null;
+  }
+
+  /**
+   * Same recipients, ASCII-armored output
+   * @returns {ContextEncryptor}
+   */
+  armored() {
+    return new ContextEncryptor({ ...this.options, armor: true });
+  }
+
+  /**
+   * Suffix of encrypted files
+   * @returns {string}
+   */
+  get extension() {
+    return (this.options.armor ? ARMORED_EXTENSIONS : EXTENSIONS)[this.scheme];
+  }
+
+  /**
+   * @param {string|Buffer} content
+   * @returns {Buffer} Ciphertext
+   * @throws {Error} When the command is missing or rejects a recipient
+   */
+  encrypt(content) {
+    return run(this.options.command || this.scheme, this.encryptArgs(), content, this.scheme);
+  }
+
+  /**
+   * Fail before a run when the encryption command is missing
+   * @throws {Error}
+   */
+  check() {
+    run(this.options.command || this.scheme, ['--version'], '', this.scheme);
+  }
+
+  /**
+   * Replace a file written in the clear by its encrypted form
+   * For outputs a formatter writes itself (chunked digests).
+   * @param {string} filePath
+   * @param {Object} fileOptions - fsync and backup, as in writeFileAtomic()
+   * @returns {string} Path of the encrypted file
+   */
+  encryptFile(filePath, fileOptions = {}) {
+    const outputPath = `${filePath}${this.extension}`;
+    try {
+      writeFileAtomic(outputPath, this.encrypt(fs.readFileSync(filePath)), fileOptions);
+    } finally {
+      fs.rmSync(filePath, { force: true });
+    }
+    return outputPath;
+  }
+
+  /**
+   * Report line, e.g. "age, 2 recipients"
+   * @returns {string}
+   */
+  describe() {
+    const count = this.options.recipients.length;
+    return `${this.scheme}, ${count} recipient${count === 1 ? '' : 's'}`;
+  }
+
+  /**
+   * @private
+   */
+  encryptArgs() {
+    const { recipients, armor } = this.options;
+    if (this.scheme === 'age') {
+      return [
+        ...(armor ? ['--armor'] : []),
+        ...recipients.flatMap(recipient => [isFile(recipient) ? '--recipients-file' : '--recipient', recipient])
+      ];
+    }
+    // Recipients are chosen explicitly, so keys need not be marked as trusted
+    return [
+      '--batch', '--yes', '--quiet', '--trust-model', 'always', '--encrypt',
+      ...(armor ? ['--armor'] : []),
+      ...recipients.flatMap(recipient => [isFile(recipient) ? '--recipient-file' : '--recipient', recipient])
+    ];
+  }
+}
+
+/**
+ * Run an age or gpg command on content
+ * @private
+ */
+function run(command, args, input, scheme) {
+  const result = spawnSync(command, args, { input, maxBuffer: Infinity });
+  if (result.error) {
+    if (result.error.code === 'ENOENT') {
+      throw new Error(`${scheme} encryption needs the ${command} command on PATH`);
+    }
+    throw result.error;
+  }
+  if (result.status !== 0) {
+    const message = result.stderr.toString('utf8').trim().split('\n').pop();
+    throw new Error(`${command} failed: ${message || `exit code ${result.status}`}`);
+  }
+  return result.stdout;
+}
+
+/**
+ * @private
+ */
+function isFile(recipient) {
+  try {
+    return fs.statSync(recipient).isFile();
+  } catch {
+    return false;
+  }
+}
+
+export default ContextEncryptor;
@@ -0,0 +1,132 @@
+/**
+ * ContextFreshness - Whether a checked-in context matches its sources
+ * v3.4.0 - Context checks for CI and pre-commit hooks (ctxman check)
+ *
+ * Responsibilities:
+ * - Pick the export format of a context file from its extension
+ * - Compare a committed context with one generated from the working tree
+ * - Name the files whose sections changed, were added or were removed,
+ *   and the first line that differs
+ *
+ * Sections are found by their file headers: "FILE: path" in digests,
+ * "### `path`" in Markdown, <file path="..."> in XML and "path" keys in
+ * JSON and YAML. Contexts compare byte for byte; sections only explain the
+ * difference.
+ */
+
+import path from 'path';
+
+// Extension -> --format value; other files are digests
+const FORMATS = { '.json': 'json', '.yaml': 'yaml', '.yml': 'yaml', '.md': 'markdown', '.markdown': 'markdown', '.xml': 'xml' };
+
+const FILE_HEADERS = [
+  /^FILE: (.+?)(?: \(part \d+ of \d+\))?$/,
+  /^### `(.+)`$/,
+  /^\s*<file path="([^"]+)"/,
+  /^\s*(?:- )?"?path"?: "?([^",]+)"?,?$/
+];
+
+export class ContextFreshness {
+  /**
+   * Export format of a context file
+   * @param {string} filePath
+   * @returns {string} gitingest, or a structured format (json, yaml, markdown, xml)
+   */
+  static formatOf(filePath) {
+    return FORMATS[path.extname(filePath).toLowerCase()] || 'gitingest';
+  }
+
+  /**
+   * Compare a committed context with a regenerated one
+   * @param {string|null} committed - null when the file does not exist
+   * @param {string} regenerated
+   * @returns {{fresh: boolean, missing: boolean, changed: Array<string>, added: Array<string>,
+   *   removed: Array<string>, firstDifference: {line: number, committed: string|null, regenerated: string|null}|null}}
+   */
+  static compare(committed, regenerated) {
+    const result = { fresh: committed === regenerated, missing: committed === null, changed: [], added: [], removed: [], firstDifference: null };
+    if (result.fresh || result.missing) return result;
+
+    const before = sections(committed);
+    const after = sections(regenerated);
+    for (const [file, content] of after) {
+      if (!before.has(file)) result.added.push(file);
+      else if (before.get(file) !== content) result.changed.push(file);
+    }
+    result.removed = [...before.keys()].filter(file => !after.has(file));
+
+    const committedLines = committed.split('\n');
+    const regeneratedLines = regenerated.split('\n');
+    const line = committedLines.findIndex((text, index) => text !== regeneratedLines[index]);
+    const index = line === -1 ? committedLines.length : line;
+    result.firstDifference = {
+      line: index + 1,
+      committed: committedLines[index] ?? null,
+      regenerated: regeneratedLines[index] ?? null
+    };
+    return result;
+  }
+
+  /**
+   * Console report of a comparison
+   * @param {Object} result - Result of compare()
+   * @param {string} file - Checked context file
+   * @param {number} [limit] - Files listed per kind of change
+   * @returns {string}
+   */
+  static format(result, file, limit = 20) {
+    if (result.fresh) return `✅ ${file} is up to date`;
+    if (result.missing) return `❌ ${file} does not exist`;
+
+    const lines = [`❌ ${file} is stale`];
+    const list = (files, mark, note = '') => {
+      files.slice(0, limit).forEach(name => lines.push(`   ${mark} ${name}${note}`));
+      if (files.length > limit) lines.push(`   ... and ${files.length - limit} more`);
+    };
+    list(result.changed, '~');
+    list(result.added, '+', ' (new)');
+    list(result.removed, '-', ' (gone)');
+
+    const { line, committed, regenerated } = result.firstDifference;
+    lines.push(`   First difference at line ${line}:`);
+    lines.push(`     committed:   ${committed === null ? '(end of file)' : truncate(committed)}`);
+    lines.push(`     regenerated: ${regenerated === null ? '(end of file)' : truncate(regenerated)}`);
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Content of each file section, keyed by path; text before the first header is left out
+ * @private
+ */
+function sections(content) {
+  const result = new Map();
+  let current = '';
+  let lines = [];
+  const close = () => {
+    // The rule above the next header belongs to neither section
+    while (lines.length > 0 && /^(=+|\s*)$/.test(lines[lines.length - 1])) lines.pop();
+    result.set(current, (result.get(current) ?? '') + lines.join('\n'));
+  };
+  for (const line of content.split('\n')) {
+    const header = FILE_HEADERS.map(pattern => pattern.exec(line)).find(Boolean);
+    if (header) {
+      close();
+      current = header[1];
+      lines = [];
+    }
+    lines.push(line);
+  }
+  close();
+  result.delete('');
+  return result;
+}
+
+/**
+ * @private
+ */
+function truncate(text, width = 100) {
+  return text.length > width ? `${text.slice(0, width - 1)}…` : text;
+}
+
+export default ContextFreshness;
@@ -0,0 +1,268 @@
+/**
+ * ContextLayout - Order, size and separation of the sections of a digest
+ * v3.4.0 - Section layouts (--layout, layout in context.yaml)
+ *
+ * Responsibilities:
+ * - Parse a layout: the order of overview, directory tree, docs, schemas,
+ *   code, tests and appendix, a token cap per section, and a separator
+ * - Sort files into the docs, schemas, tests and code sections
+ * - Emit the sections in order, leaving out what exceeds a cap and noting it
+ *
+ * Sections missing from the order are left out. A capped file section keeps
+ * the files that fit, in order, and names the ones it leaves out; capped text
+ * sections keep their first lines. Without a layout, digests keep their
+ * usual order: overview, tree, files (docs first), appendix.
+ */
+
+import path from 'path';
+import ProjectDocs from './ProjectDocs.js';
+import TestPairing from './TestPairing.js';
+import { parseTokenCount } from './TokenBudget.js';
+
+export const LAYOUT_SECTIONS = ['overview', 'tree', 'docs', 'schemas', 'code', 'tests', 'appendix'];
+
+// Sections of files; the others are rendered as text
+export const FILE_SECTIONS = ['docs', 'schemas', 'code', 'tests'];
+
+const PROSE = /\.(md|markdown|mdx|rst|adoc|txt)$/i;
+const SCHEMA = /\.(proto|graphqls?|gql|sql|prisma|avsc|avdl|thrift|xsd|capnp|fbs)$|\.schema\.(json|ya?ml)$|(^|\/)(openapi|swagger|asyncapi)\.(json|ya?ml)$/i;
+const MIGRATION_DIR = /(^|\/)(migrations|schemas?)\//i;
+const SCHEMA_DATA = /\.(json|ya?ml)$/i;
+
+export class ContextLayout {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      order: LAYOUT_SECTIONS, // Sections in the order they are emitted
+      caps: {}, // Section -> token cap
+      separator: '', // Text between sections, e.g. "\n---\n"
+      ...options
+    };
+  }
+
+  /**
+   * Layout of a --layout value or a context.yaml mapping
+   * @param {string|Object} value - "overview,docs:8k,code,tests:20k,tree", or
+   *   { order, caps, separator } with order a list or the same string
+   * @returns {ContextLayout}
+   * @throws {Error} For unknown or repeated sections, bad caps and unknown keys
+   */
+  static parse(value) {
+    if (typeof value === 'string') {
+      return new ContextLayout(parseOrder(value));
+    }
+    if (value === null || typeof value !== 'object' || Array.isArray(value)) {
+      throw new Error('layout must be a section list or a mapping of order, caps and separator');
+    }
+
+    const unknown = Object.keys(value).filter(key => !['order', 'caps', 'separator'].includes(key));
+    if (unknown.length > 0) {
+      throw new Error(`layout has unknown keys: ${unknown.join(', ')} (allowed: order, caps, separator)`);
+    }
+    const order = value.order === undefined ? null : Array.isArray(value.order) ? value.order.join(',') : value.order;
+    if (order !== null && typeof order !== 'string') {
+      throw new Error('layout order must be a list of sections');
+    }
+    const parsed = parseOrder(order ?? LAYOUT_SECTIONS.join(','));
+
+    if (value.caps !== undefined && value.caps !== null) {
+      if (typeof value.caps !== 'object' || Array.isArray(value.caps)) {
+        throw new Error('layout caps must map sections to token counts (e.g. tests: 20k)');
+      }
+      for (const [section, cap] of Object.entries(value.caps)) {
+        if (!parsed.order.includes(section)) {
+          throw new Error(`layout caps ${section}, which is not in the order (${parsed.order.join(', ')})`);
+        }
+        parsed.caps[section] = parseCap(section, cap);
+      }
+    }
+
+    if (value.separator !== undefined && value.separator !== null && typeof value.separator !== 'string') {
+      throw new Error('layout separator must be a string');
+    }
+    return new ContextLayout({ ...parsed, separator: value.separator ?? '' });
+  }
+
+  /**
+   * File section of a path
+   * @param {string} relativePath - '/'-separated
+   * @returns {string} docs, schemas, tests or code
+   */
+  static sectionOf(relativePath) {
+    if (ProjectDocs.kindOf(relativePath) || PROSE.test(relativePath)) return 'docs';
+    if (TestPairing.isTestFile(relativePath)) return 'tests';
+    if (SCHEMA.test(relativePath) || (MIGRATION_DIR.test(relativePath) && SCHEMA_DATA.test(path.posix.basename(relativePath)))) {
+      return 'schemas';
+    }
+    return 'code';
+  }
+
+  /**
+   * Files of each file section, in their given order
+   * @param {Array<Object>} files - Analysis results with a relativePath
+   * @returns {Map<string, Array<Object>>}
+   */
+  group(files) {
+    const sections = new Map(FILE_SECTIONS.map(section => [section, []]));
+    for (const file of files) {
+      sections.get(ContextLayout.sectionOf(file.relativePath)).push(file);
+    }
+    return sections;
+  }
+
+  /**
+   * Pieces of the sections in layout order
+   * @param {Object} sections - Section -> function returning its pieces: strings for text
+   *   sections, { name, render } per file for file sections, rendered once when emitted
+   * @param {Function} countTokens - (text) => number
+   * @returns {Generator<string>}
+   */
+  *arrange(sections, countTokens) {
+    let first = true;
+    for (const section of this.options.order) {
+      const pieces = sections[section] ? sections[section]() : [];
+      const cap = this.options.caps[section] ?? null;
+      const emitted = FILE_SECTIONS.includes(section)
+        ? capFiles(section, pieces, cap, countTokens)
+        : capText(section, pieces, cap, countTokens);
+
+      // Files start with a blank line of their own; text sections after others get one
+      const gap = this.options.separator || (FILE_SECTIONS.includes(section) ? '' : '\n');
+      let started = false;
+      for (const piece of emitted) {
+        if (!piece) continue;
+        if (!started && !first && gap) yield gap;
+        started = true;
+        yield piece;
+      }
+      if (started) first = false;
+    }
+  }
+
+  /**
+   * Report line, e.g. "overview → docs (8k) → code → tests (20k)"
+   * @returns {string}
+   */
+  describe() {
+    return this.options.order
+      .map(section => (this.options.caps[section] ? `${section} (${formatCount(this.options.caps[section])})` : section))
+      .join(' → ');
+  }
+}
+
+/**
+ * Order and caps of "overview,docs:8k,code"
+ * @private
+ */
+function parseOrder(value) {
+  const entries = value.split(',').map(entry => entry.trim()).filter(Boolean);
+  if (entries.length === 0) {
+    throw new Error(`Invalid layout: expected sections such as ${LAYOUT_SECTIONS.join(',')}`);
+  }
+
+  const order = [];
+  const caps = {};
+  for (const entry of entries) {
+    const [section, cap] = entry.split(':').map(part => part.trim());
+    if (!LAYOUT_SECTIONS.includes(section)) {
+      throw new Error(`Unknown layout section: ${section} (expected ${LAYOUT_SECTIONS.join(', ')})`);
+    }
+    if (order.includes(section)) {
+      throw new Error(`Layout section ${section} is listed twice`);
+    }
+    order.push(section);
+    if (cap !== undefined) caps[section] = parseCap(section, cap);
+  }
+  return { order, caps };
+}
+
+/**
+ * @private
+ */
+function parseCap(section, value) {
+  const tokens = parseTokenCount(value);
+  if (!tokens) {
+    throw new Error(`Invalid cap for layout section ${section}: ${value} (e.g. 8000, 20k)`);
+  }
+  return tokens;
+}
+
+/**
+ * Files that fit the cap, in order, then a note naming the others
+ * @private
+ */
+function* capFiles(section, files, cap, countTokens) {
+  let used = 0;
+  const left = [];
+  let leftTokens = 0;
+  for (const file of files) {
+    const text = file.render();
+    const tokens = cap === null ? 0 : countTokens(text);
+    if (cap !== null && used + tokens > cap) {
+      left.push(file.name);
+      leftTokens += tokens;
+      continue;
+    }
+    used += tokens;
+    yield text;
+  }
+  if (left.length > 0) {
+    yield `\n[${section}: ${left.length} more file${left.length === 1 ? '' : 's'} (${leftTokens.toLocaleString()} tokens) ` +
+      `over the ${formatCount(cap)} cap: ${left.join(', ')}]\n`;
+  }
+}
+
+/**
+ * First lines of text pieces that fit the cap
+ * @private
+ */
+function* capText(section, pieces, cap, countTokens) {
+  if (cap === null) {
+    yield* pieces;
+    return;
+  }
+
+  let used = 0;
+  let cut = 0;
+  for (const piece of pieces) {
+    const lines = piece.split(/(?<=\n)/);
+    if (cut > 0) {
+      cut += lines.length;
+      continue;
+    }
+    const tokens = countTokens(piece);
+    if (used + tokens <= cap) {
+      used += tokens;
+      yield piece;
+      continue;
+    }
+
+    // Most lines that fit, found with a few counts rather than one per line
+    let low = 0;
+    let high = lines.length;
+    while (low < high) {
+      const middle = Math.ceil((low + high) / 2);
+      if (used + countTokens(lines.slice(0, middle).join('')) <= cap) low = middle;
+      else high = middle - 1;
+    }
+    cut = lines.length - low;
+    if (low > 0) yield lines.slice(0, low).join('');
+  }
+  if (cut > 0) {
+    yield `[${section}: ${cut.toLocaleString()} more line${cut === 1 ? '' : 's'} over the ${formatCount(cap)} cap]\n`;
+  }
+}
+
+/**
+ * Token count as 900, 1.5k or 2m
+ * @private
+ */
+function formatCount(tokens) {
+  if (tokens >= 1000000) return `${+(tokens / 1000000).toFixed(1)}m`;
+  if (tokens >= 1000) return `${+(tokens / 1000).toFixed(1)}k`;
+  return String(tokens);
+}
+
+export default ContextLayout;
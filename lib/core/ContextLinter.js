@@ -0,0 +1,491 @@
+/**
+ * ContextLinter - Problems of a generated context, for CI
+ * v3.4.0 - Context quality linter (ctxman lint)
+ *
+ * Responsibilities:
+ * - Read gitingest digests, structured contexts (--format json) and context packs
+ * - Find symbol excerpts cut off mid-body (unbalanced brackets, Python
+ *   headers without a body)
+ * - Find types the included code uses whose definitions are left out
+ * - Find near-duplicate files that spend tokens twice
+ * - Check the context against --max-tokens and each model's context window
+ *
+ * Findings carry a rule, a severity (error or warning), a location and a hint
+ * naming the flag that fixes them.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { STRUCTURED_SCHEMA } from '../formatters/structured-formatter.js';
+import ContextPack, { PACK_EXTENSION } from './ContextPack.js';
+import DuplicateDetector from './DuplicateDetector.js';
+import TokenBudget from './TokenBudget.js';
+import { decodeText } from './FileSystem.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { referencedIdentifiers, stripNoise } from '../graph/DependencyExpander.js';
+import { XREF_TITLE } from '../graph/CrossReferenceIndex.js';
+import { TODO_TITLE } from './TodoHarvester.js';
+import { DEPENDENCIES_TITLE } from './DependencySummary.js';
+import { LLMDetector } from '../utils/llm-detector.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export const LINT_RULES = ['truncated-symbol', 'dangling-reference', 'duplicate-content', 'budget-overrun'];
+
+export const LintSeverity = Object.freeze({
+  ERROR: 'error',
+  WARNING: 'warning'
+});
+
+const FILE_HEADER_PATTERN = /^={48}\nFILE: (.+)\n(?:(?!={48}\n).*\n)*={48}\n/gm;
+const EXCERPT_HEADER_PATTERN = /^\/\/ ([^:\n]+): (.+) \(lines (\d+)-(\d+)(?:, ([^)\n]+))?\)$/gm;
+// First lines of summarized files (remote, budget tier and documentation summaries)
+const SUMMARY_NOTE_PATTERN = /^\/\/ (Summar|Documentation summary$)/;
+const TYPE_KINDS = new Set([SymbolKind.CLASS, SymbolKind.STRUCT, SymbolKind.INTERFACE, SymbolKind.TRAIT, SymbolKind.ENUM, SymbolKind.TYPE]);
+
+// Excerpt roles that are only a header line or an import block by design
+const CONTEXT_ROLES = new Set(['declaration', 'preamble']);
+
+// Languages whose symbols are delimited by brackets
+const BRACKET_EXTENSIONS = new Set([
+  '.js', '.jsx', '.mjs', '.cjs', '.ts', '.tsx', '.java', '.go', '.rs', '.c', '.h', '.cc', '.cpp', '.hpp',
+  '.cs', '.kt', '.kts', '.swift', '.scala', '.php', '.dart'
+]);
+const BRACKETS = { '{': '}', '(': ')', '[': ']' };
+
+export class ContextLinter {
+  constructor(options = {}) {
+    this.options = {
+      rules: LINT_RULES,
+      models: [],              // Model profiles whose context window the context must fit
+      maxTokens: null,         // Budget the context must fit
+      tokenizer: 'auto',
+      root: null,              // Project the context came from; null = definitions in the context only
+      duplicateThreshold: 0.9, // Similarity of files reported as duplicates
+      extractor: null,         // SymbolExtractor; null = heuristic
+      listFiles: null,         // root => absolute paths of project files (TokenCalculator scan)
+      ...options
+    };
+    this.extractor = this.options.extractor || new SymbolExtractor({ backend: 'heuristic' });
+  }
+
+  /**
+   * Read a generated context
+   * @param {string} filePath - Digest, structured JSON context or .ctxpack
+   * @returns {Object} { source, format, text, files: [{ path, content, included, excerpts }] }
+   */
+  static load(filePath) {
+    if (filePath.endsWith(PACK_EXTENSION)) {
+      const pack = ContextPack.read(filePath);
+      const name = pack.entries.has('context.json')
+        ? 'context.json'
+        : [...pack.entries.keys()].find(entry => entry.endsWith('.txt'));
+      if (!name) {
+        throw new Error(`${filePath} holds no digest or structured context to lint`);
+      }
+      return ContextLinter.parse(decodeText(pack.entries.get(name)), `${filePath}:${name}`);
+    }
+
+    let text;
+    try {
+      text = decodeText(fs.readFileSync(filePath));
+    } catch (error) {
+      throw new Error(`Cannot read context ${filePath}: ${error.message}`);
+    }
+    return ContextLinter.parse(text, filePath);
+  }
+
+  /**
+   * Parse the text of a generated context
+   * @param {string} text
+   * @param {string} label - Name used in findings and errors
+   * @returns {Object} See load()
+   */
+  static parse(text, label = 'context') {
+    if (text.trimStart().startsWith('{')) {
+      let document;
+      try {
+        document = JSON.parse(text);
+      } catch (error) {
+        throw new Error(`Cannot parse context ${label}: ${error.message}`);
+      }
+      if (document?.schema !== STRUCTURED_SCHEMA) {
+        throw new Error(`${label} is not a generated context (expected ${STRUCTURED_SCHEMA} or a digest)`);
+      }
+      return ContextLinter.fromStructured(document, text, label);
+    }
+
+    const headers = [...text.matchAll(FILE_HEADER_PATTERN)];
+    if (headers.length === 0) {
+      throw new Error(`${label} is not a generated context (no FILE: sections of a digest)`);
+    }
+
+    // The cross-reference index (--xref), markers (--todos) and dependencies (--deps) follow the last file
+    const appendix = Math.min(...[XREF_TITLE, TODO_TITLE, DEPENDENCIES_TITLE]
+      .map(title => text.indexOf(`\n${'='.repeat(48)}\n${title}\n`))
+      .map(index => (index === -1 ? Infinity : index)));
+    const files = headers.map((match, i) => {
+      const end = i + 1 < headers.length ? headers[i + 1].index : appendix > match.index ? appendix : text.length;
+      const content = text.slice(match.index + match[0].length, end).replace(/\n+$/, '\n');
+      return { path: match[1].replace(/ \(part \d+ of \d+\)$/, ''), content, ...digestExcerpts(content) };
+    });
+    return { source: label, format: 'digest', text, files };
+  }
+
+  /**
+   * @param {Object} document - Structured context (ctxman.context/v1)
+   * @private
+   */
+  static fromStructured(document, text, label) {
+    const files = document.files.map(file => {
+      const content = file.content ?? '';
+      const partial = file.included === 'partial';
+      // Ranges are joined in the content; check them as one excerpt unless one is a bare header
+      const ranges = file.ranges || [];
+      const checkable = partial && ranges.length > 0 && !ranges.some(range => CONTEXT_ROLES.has(range.role));
+      return {
+        path: file.path,
+        content,
+        included: file.included,
+        excerpts: checkable ? [{
+          name: ranges.map(range => range.name).join(', '),
+          startLine: Math.min(...ranges.map(range => range.startLine)),
+          endLine: Math.max(...ranges.map(range => range.endLine)),
+          text: content
+        }] : [],
+        omitted: (file.symbols || []).filter(symbol => symbol.included === false)
+      };
+    });
+    return { source: label, format: 'structured', text, files };
+  }
+
+  /**
+   * Lint a context
+   * @param {Object} context - Result of load() or parse()
+   * @returns {Promise<Object>} { source, files, tokens, findings, errors, warnings }
+   */
+  async lint(context) {
+    const { rules } = this.options;
+    const findings = [];
+    const tokens = {};
+
+    if (rules.includes('truncated-symbol')) findings.push(...this.truncatedSymbols(context));
+    if (rules.includes('dangling-reference')) findings.push(...this.danglingReferences(context));
+    if (rules.includes('duplicate-content')) findings.push(...this.duplicateContent(context));
+    if (rules.includes('budget-overrun')) findings.push(...await this.budgetOverruns(context, tokens));
+
+    const order = { [LintSeverity.ERROR]: 0, [LintSeverity.WARNING]: 1 };
+    findings.sort((a, b) => order[a.severity] - order[b.severity] ||
+      LINT_RULES.indexOf(a.rule) - LINT_RULES.indexOf(b.rule) || compareBytes(a.path || '', b.path || ''));
+
+    return {
+      source: context.source,
+      format: context.format,
+      files: context.files.length,
+      tokens,
+      findings,
+      errors: findings.filter(finding => finding.severity === LintSeverity.ERROR).length,
+      warnings: findings.filter(finding => finding.severity === LintSeverity.WARNING).length
+    };
+  }
+
+  /**
+   * Symbol excerpts that end inside a body or start after its opening
+   * @param {Object} context
+   * @returns {Array<Object>} Findings
+   */
+  truncatedSymbols(context) {
+    const findings = [];
+    for (const file of context.files) {
+      for (const excerpt of file.excerpts) {
+        const problem = truncation(excerpt.text, file.path);
+        if (!problem) continue;
+        findings.push({
+          rule: 'truncated-symbol',
+          severity: LintSeverity.ERROR,
+          path: file.path,
+          line: excerpt.startLine,
+          message: `${excerpt.name} (lines ${excerpt.startLine}-${excerpt.endLine}) is cut off: ${problem}`,
+          hint: 'Include the whole symbol with --symbol, or raise --max-tokens'
+        });
+      }
+    }
+    return findings;
+  }
+
+  /**
+   * Types used by included code whose definitions are not in the context
+   * Definitions come from the project (options.root) and from symbols a
+   * structured context lists as not included.
+   * @param {Object} context
+   * @returns {Array<Object>} Findings
+   */
+  danglingReferences(context) {
+    const included = new Set();
+    for (const file of context.files) {
+      for (const symbol of this.extract(file.content, file.path)) {
+        if (TYPE_KINDS.has(symbol.kind)) included.add(`${this.family(file.path)}:${symbol.name}`);
+      }
+    }
+
+    const missing = new Map();
+    const addDefinition = (name, family, location) => {
+      const key = `${family}:${name}`;
+      if (!included.has(key) && !missing.has(key)) missing.set(key, { name, location, users: [] });
+    };
+    for (const file of context.files) {
+      for (const symbol of file.omitted || []) {
+        if (TYPE_KINDS.has(symbol.kind)) addDefinition(symbol.name, this.family(file.path), `${file.path}:${symbol.startLine}`);
+      }
+    }
+    for (const definition of this.projectDefinitions(context)) {
+      addDefinition(definition.name, this.family(definition.path), `${definition.path}:${definition.startLine}`);
+    }
+    if (missing.size === 0) return [];
+
+    for (const file of context.files) {
+      if (file.included === 'summary') continue;
+      const family = this.family(file.path);
+      const language = this.extractor.getPlugin(file.path)?.getLanguageId(file.path);
+      for (const identifier of referencedIdentifiers(file.content, language)) {
+        missing.get(`${family}:${identifier}`)?.users.push(file.path);
+      }
+    }
+
+    return [...missing.values()]
+      .filter(definition => definition.users.length > 0)
+      .map(definition => {
+        const [definitionPath] = definition.location.split(/:(?=\d+$)/);
+        return {
+          rule: 'dangling-reference',
+          severity: LintSeverity.WARNING,
+          path: definition.users[0],
+          line: null,
+          message: `${definition.name} is used by ${formatList(definition.users)} but its definition ` +
+            `(${definition.location}) is not in the context`,
+          hint: `Add it with --symbol ${definitionPath}:${definition.name}, or use --focus with --expand-deps to include dependencies`
+        };
+      });
+  }
+
+  /**
+   * Near-duplicate files
+   * @param {Object} context
+   * @returns {Array<Object>} Findings
+   */
+  duplicateContent(context) {
+    const detector = new DuplicateDetector({ threshold: this.options.duplicateThreshold });
+    const groups = detector.findDuplicates(context.files
+      .filter(file => file.included !== 'summary')
+      .map(file => ({ path: file.path, content: file.content })));
+
+    return groups.map(group => ({
+      rule: 'duplicate-content',
+      severity: LintSeverity.WARNING,
+      path: group.representative,
+      line: null,
+      message: `${formatList(group.duplicates.map(duplicate => `${duplicate.path} (${Math.round(duplicate.similarity * 100)}%)`))} ` +
+        `${group.duplicates.length === 1 ? 'repeats' : 'repeat'} ${group.representative}`,
+      hint: 'Collapse near-duplicates with --dedupe, or exclude the copies'
+    }));
+  }
+
+  /**
+   * Context tokens over --max-tokens or a model's window; over a model's
+   * recommended input is a warning
+   * @param {Object} context
+   * @param {Object} tokens - Receives the count per tokenizer
+   * @returns {Promise<Array<Object>>} Findings
+   */
+  async budgetOverruns(context, tokens) {
+    const findings = [];
+    const count = async model => {
+      const budget = await TokenBudget.create({ maxTokens: 1, tokenizer: this.options.tokenizer, model });
+      const name = budget.getTokenizerName();
+      if (tokens[name] === undefined) tokens[name] = budget.count(context.text);
+      return { name, total: tokens[name] };
+    };
+
+    const { total } = await count(null);
+    if (this.options.maxTokens) {
+      if (total > this.options.maxTokens) {
+        findings.push(overrun(`${total.toLocaleString()} tokens exceed the ${this.options.maxTokens.toLocaleString()}-token budget`,
+          `Regenerate with --max-tokens ${this.options.maxTokens}`));
+      }
+    }
+
+    for (const model of this.options.models) {
+      const profile = LLMDetector.getAllProfiles()[model];
+      const { total: used } = await count(model);
+      if (used > profile.contextWindow) {
+        findings.push(overrun(`${used.toLocaleString()} tokens exceed the ${profile.contextWindow.toLocaleString()}-token window of ${profile.name}`,
+          `Regenerate with --max-tokens ${profile.maxRecommendedInput} --target-model ${model}, or split it with --chunk`));
+      } else if (profile.maxRecommendedInput && used > profile.maxRecommendedInput) {
+        findings.push({
+          ...overrun(`${used.toLocaleString()} tokens exceed the ${profile.maxRecommendedInput.toLocaleString()} tokens recommended for ${profile.name}, ` +
+            'leaving little room for the prompt and answer',
+          `Regenerate with --max-tokens ${profile.maxRecommendedInput} --target-model ${model}`),
+          severity: LintSeverity.WARNING
+        });
+      }
+    }
+    return findings;
+  }
+
+  /**
+   * Format a lint report for the console
+   * @param {Object} report - Result of lint()
+   * @returns {string}
+   */
+  static formatReport(report) {
+    const lines = [];
+    const tokens = Object.entries(report.tokens).map(([name, count]) => `${count.toLocaleString()} (${name})`).join(', ');
+
+    lines.push('');
+    lines.push(`🔍 CONTEXT LINT: ${report.source}`);
+    lines.push('='.repeat(80));
+    lines.push(`   Files:     ${report.files}${tokens ? ` · Tokens: ${tokens}` : ''}`);
+    lines.push('');
+
+    for (const finding of report.findings) {
+      const icon = finding.severity === LintSeverity.ERROR ? '❌' : '⚠️ ';
+      const location = finding.path ? `${finding.path}${finding.line ? `:${finding.line}` : ''}: ` : '';
+      lines.push(`${icon} [${finding.rule}] ${location}${finding.message}`);
+      lines.push(`   → ${finding.hint}`);
+    }
+    if (report.findings.length === 0) {
+      lines.push('✅ No problems found');
+    } else {
+      lines.push('');
+      lines.push(`${report.errors} ${report.errors === 1 ? 'error' : 'errors'}, ${report.warnings} ${report.warnings === 1 ? 'warning' : 'warnings'}`);
+    }
+
+    return lines.join('\n');
+  }
+
+  /**
+   * Type definitions of the project the context came from
+   * @private
+   */
+  projectDefinitions(context) {
+    const { root, listFiles } = this.options;
+    if (!root || !listFiles) return [];
+
+    // A context of another project shares no paths with this one
+    const inProject = context.files.filter(file => fs.existsSync(path.join(root, file.path)));
+    if (inProject.length === 0) return [];
+
+    const definitions = [];
+    for (const filePath of listFiles(root)) {
+      const relativePath = path.relative(root, filePath).split(path.sep).join('/');
+      if (!this.extractor.supports(relativePath)) continue;
+      let content;
+      try {
+        content = decodeText(fs.readFileSync(filePath));
+      } catch {
+        continue;
+      }
+      for (const symbol of this.extract(content, relativePath)) {
+        if (TYPE_KINDS.has(symbol.kind) && !symbol.parent) definitions.push({ ...symbol, path: relativePath });
+      }
+    }
+    return definitions;
+  }
+
+  /**
+   * @private
+   */
+  extract(content, filePath) {
+    if (!this.extractor.supports(filePath)) return [];
+    try {
+      return this.extractor.extract(content, filePath);
+    } catch {
+      return [];
+    }
+  }
+
+  /**
+   * Files that can reference each other's types: JavaScript and TypeScript
+   * share one namespace, other languages their own
+   * @private
+   */
+  family(filePath) {
+    const language = this.extractor.getPlugin(filePath)?.getLanguageId(filePath) || path.extname(filePath);
+    return language === 'javascript' ? 'typescript' : language;
+  }
+}
+
+/**
+ * Symbol excerpts of a digest file section
+ * @private
+ */
+function digestExcerpts(content) {
+  const firstLine = content.slice(0, content.indexOf('\n'));
+  if (!/^\/\/ .*: showing \d+ symbols$/.test(firstLine)) {
+    return { included: SUMMARY_NOTE_PATTERN.test(firstLine) ? 'summary' : 'full', excerpts: [] };
+  }
+
+  const headers = [...content.matchAll(EXCERPT_HEADER_PATTERN)];
+  const excerpts = headers
+    .map((match, i) => ({
+      name: match[2],
+      role: match[5] || null,
+      startLine: Number(match[3]),
+      endLine: Number(match[4]),
+      text: content.slice(match.index + match[0].length + 1, i + 1 < headers.length ? headers[i + 1].index : content.length)
+    }))
+    .filter(excerpt => !CONTEXT_ROLES.has(excerpt.role));
+  return { included: 'partial', excerpts };
+}
+
+/**
+ * Why an excerpt looks cut off, or null
+ * @private
+ */
+function truncation(text, filePath) {
+  const ext = path.extname(filePath).toLowerCase();
+
+  if (ext === '.py') {
+    const lines = text.split('\n').filter(line => line.trim() && !line.trim().startsWith('#'));
+    const last = lines[lines.length - 1] || '';
+    return /^\s*(async\s+def|def|class)\b.*:\s*$/.test(last) ? 'the last definition has no body' : null;
+  }
+  if (!BRACKET_EXTENSIONS.has(ext)) return null;
+
+  const code = stripNoise(text, ext === '.rs' ? 'rust' : null);
+  const open = [];
+  for (const char of code) {
+    if (BRACKETS[char]) {
+      open.push(char);
+    } else if (Object.values(BRACKETS).includes(char)) {
+      if (open.length === 0 || BRACKETS[open.pop()] !== char) {
+        return `unmatched '${char}' (starts inside a body)`;
+      }
+    }
+  }
+  if (open.length > 0) {
+    const unclosed = open.filter(char => char === '{').length || open.length;
+    return `${unclosed} unclosed '${open.includes('{') ? '{' : open[open.length - 1]}'`;
+  }
+  return null;
+}
+
+/**
+ * @private
+ */
+function overrun(message, hint) {
+  return { rule: 'budget-overrun', severity: LintSeverity.ERROR, path: null, line: null, message, hint };
+}
+
+/**
+ * "a", "a and b", "a, b and 2 more"
+ * @private
+ */
+function formatList(items) {
+  const unique = [...new Set(items)];
+  if (unique.length <= 2) return unique.join(' and ');
+  if (unique.length === 3) return `${unique[0]}, ${unique[1]} and ${unique[2]}`;
+  return `${unique[0]}, ${unique[1]} and ${unique.length - 2} more`;
+}
+
+export default ContextLinter;
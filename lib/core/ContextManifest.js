@@ -0,0 +1,222 @@
+/**
+ * ContextManifest - Provenance of a generated context
+ * v3.4.0 - Reproducibility manifests (--manifest, ctxman reproduce)
+ *
+ * Responsibilities:
+ * - Describe a run: ctxman version, time, project commit, command,
+ *   tokenizer, the contexts it wrote and the sources it exported, each
+ *   with its SHA-256 (shared with ContextPack manifests)
+ * - Write the description as a sidecar next to each context
+ *   (digest.txt -> digest.txt.manifest.json), leaving the context unchanged
+ * - Read sidecars back and compare contexts and sources with them
+ *
+ * Manifest (ctxman.manifest/v1):
+ * - schema, ctxman, createdAt, project { name, commit, branch, dirty }, command, tokenizer
+ * - contexts[] { file, format, sha256, size, tokens }: file names, next to the sidecar
+ * - files[] { path, sha256, tokens, lines, size, included }
+ */
+
+import crypto from 'crypto';
+import fs from 'fs';
+import path from 'path';
+import { writeFileAtomic } from './ContextWriter.js';
+
+export const MANIFEST_SCHEMA = 'ctxman.manifest/v1';
+export const MANIFEST_SUFFIX = '.manifest.json';
+
+// Context format of each export file name
+const CONTEXT_FORMATS = {
+  'digest.txt': 'gitingest',
+  'context.json': 'json',
+  'context.yaml': 'yaml',
+  'context.md': 'markdown',
+  'context.xml': 'xml',
+  'llm-context.json': 'llm-context'
+};
+
+export class ContextManifest {
+  /**
+   * Describe a finished run
+   * @param {Object} run
+   * @param {string} run.project - Project name
+   * @param {Array<string>} run.command - ctxman arguments that generated the contexts
+   * @param {Array<{name: string, content: Buffer|string}>} run.contexts - Written export files
+   * @param {Array} run.files - Exported file analyses
+   * @param {Function} run.readOriginal - (absolutePath) => source as analyzed
+   * @param {Function} run.countTokens - (text) => tokens
+   * @param {string} run.tokenizer
+   * @param {string} run.version - ctxman version
+   * @param {Object|null} run.git - { commit, branch, dirty }
+   * @param {Date} [run.now] - Time the run started (also the Date of --template contexts)
+   * @returns {Object} Manifest
+   */
+  static create(run) {
+    const contexts = [...run.contexts]
+      .sort((a, b) => compare(a.name, b.name))
+      .map(({ name, content }) => {
+        const buffer = Buffer.isBuffer(content) ? content : Buffer.from(content, 'utf8');
+        return {
+          file: name,
+          format: CONTEXT_FORMATS[path.basename(name)] || path.extname(name).slice(1) || null,
+          sha256: sha256(buffer),
+          size: buffer.length,
+          tokens: run.countTokens(buffer.toString('utf8'))
+        };
+      });
+
+    const files = run.files
+      .filter(fileInfo => !fileInfo.error)
+      .map(fileInfo => ({
+        path: fileInfo.relativePath.split(path.sep).join('/'),
+        sha256: sha256(run.readOriginal(fileInfo.path)),
+        tokens: fileInfo.tokens,
+        lines: fileInfo.lines,
+        size: fileInfo.sizeBytes,
+        included: fileInfo.summary ? 'summary' : fileInfo.selectedSymbols ? 'partial' : 'full'
+      }))
+      .sort((a, b) => compare(a.path, b.path));
+
+    return {
+      schema: MANIFEST_SCHEMA,
+      ctxman: run.version,
+      createdAt: (run.now || new Date()).toISOString(),
+      project: {
+        name: run.project,
+        commit: run.git?.commit || null,
+        branch: run.git?.branch || null,
+        dirty: run.git ? Boolean(run.git.dirty) : null
+      },
+      command: run.command,
+      tokenizer: run.tokenizer,
+      contexts,
+      files
+    };
+  }
+
+  /**
+   * Sidecar of a context file
+   * @param {string} contextPath
+   * @returns {string}
+   */
+  static sidecarOf(contextPath) {
+    return `${contextPath}${MANIFEST_SUFFIX}`;
+  }
+
+  /**
+   * @param {Object} manifest
+   * @param {string} filePath
+   * @param {Object} fileOptions - fsync and backup, as in writeFileAtomic()
+   */
+  static write(manifest, filePath, fileOptions = {}) {
+    writeFileAtomic(filePath, JSON.stringify(manifest, null, 2) + '\n', fileOptions);
+  }
+
+  /**
+   * @param {string} filePath - A sidecar, or the context it describes
+   * @returns {Object} Manifest
+   * @throws {Error} When unreadable or not a manifest
+   */
+  static read(filePath) {
+    const manifestPath = filePath.endsWith(MANIFEST_SUFFIX) || !fs.existsSync(ContextManifest.sidecarOf(filePath))
+      ? filePath
+      : ContextManifest.sidecarOf(filePath);
+
+    let manifest;
+    try {
+      manifest = JSON.parse(fs.readFileSync(manifestPath, 'utf8'));
+    } catch (error) {
+      throw new Error(`Cannot read manifest ${manifestPath}: ${error.message}`);
+    }
+    if (manifest?.schema !== MANIFEST_SCHEMA) {
+      throw new Error(`${manifestPath} is not a context manifest (expected ${MANIFEST_SCHEMA})`);
+    }
+    return manifest;
+  }
+
+  /**
+   * Recorded sources that differ in a working tree
+   * @param {Object} manifest - Of a context or a pack
+   * @param {string} projectRoot
+   * @returns {{changed: Array<string>, missing: Array<string>}}
+   */
+  static drift(manifest, projectRoot) {
+    const changed = [];
+    const missing = [];
+    for (const file of manifest.files) {
+      let content;
+      try {
+        content = fs.readFileSync(path.join(projectRoot, file.path));
+      } catch {
+        missing.push(file.path);
+        continue;
+      }
+      if (sha256(content) !== file.sha256) changed.push(file.path);
+    }
+    return { changed, missing };
+  }
+
+  /**
+   * Recorded contexts against regenerated ones
+   * @param {Object} manifest
+   * @param {Map<string, Buffer>} contents - Regenerated contexts by file
+   * @returns {Array<{file: string, status: string, tokens: number}>} status is
+   *   identical, differs or missing
+   */
+  static compare(manifest, contents) {
+    return manifest.contexts.map(context => {
+      const content = contents.get(context.file);
+      const status = !content ? 'missing' : sha256(content) === context.sha256 ? 'identical' : 'differs';
+      return { file: context.file, status, tokens: context.tokens };
+    });
+  }
+
+  /**
+   * Shell command of a manifest's run
+   * @param {Object} manifest
+   * @param {Array<string>} [prefix] - Arguments before the command (e.g. pack)
+   * @returns {string}
+   */
+  static command(manifest, prefix = []) {
+    const quote = arg => (/^[\w@%+=:,./-]+$/.test(arg) ? arg : `'${arg.replace(/'/g, `'\\''`)}'`);
+    return ['ctxman', ...prefix, ...manifest.command].map(quote).join(' ');
+  }
+
+  /**
+   * Provenance summary
+   * @param {Object} manifest
+   * @returns {string}
+   */
+  static formatSummary(manifest) {
+    const { project } = manifest;
+    const tokens = manifest.files.reduce((sum, file) => sum + file.tokens, 0);
+    const lines = ['🧾 CONTEXT MANIFEST', '='.repeat(80)];
+    lines.push(`Project: ${project.name}${project.commit
+      ? ` @ ${project.commit.slice(0, 12)}${project.branch ? ` (${project.branch})` : ''}${project.dirty ? ', uncommitted changes' : ''}`
+      : ''}`);
+    lines.push(`Created: ${manifest.createdAt} by ctxman ${manifest.ctxman}`);
+    lines.push(`Command: ${ContextManifest.command(manifest)}`);
+    lines.push(`Files:   ${manifest.files.length.toLocaleString()} (${tokens.toLocaleString()} tokens, ${manifest.tokenizer})`);
+    lines.push('', 'Contexts:');
+    for (const context of manifest.contexts) {
+      lines.push(`  ${context.file.padEnd(20)} ${(context.format || '').padEnd(12)} ${context.tokens.toLocaleString().padStart(10)} tokens  sha256:${context.sha256.slice(0, 12)}`);
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Bytewise order, so manifests do not depend on the locale
+ * @private
+ */
+function compare(a, b) {
+  return a < b ? -1 : a > b ? 1 : 0;
+}
+
+/**
+ * @private
+ */
+function sha256(content) {
+  return crypto.createHash('sha256').update(content).digest('hex');
+}
+
+export default ContextManifest;
@@ -0,0 +1,223 @@
+/**
+ * ContextMerger - One context from several generated ones
+ * v3.4.0 - Context merging (ctxman merge)
+ *
+ * Responsibilities:
+ * - Read structured contexts (--format json, schema ctxman.context/v1) and
+ *   context packs that hold one, with the content of every file
+ * - Combine their files: a file of the same path and content is kept once,
+ *   and a later context replaces a file whose content it changed
+ * - Keep one file of each group with identical content under different
+ *   paths (dedupe), naming the others on it
+ * - Prefix paths with their project when the contexts come from different
+ *   projects, so files of the same name do not collide
+ * - Hand the merged files to the formatters, packed into a token budget
+ *   when one is given
+ *
+ * Files are compared by the SHA-256 of their content. Files a context only
+ * held in part (trimmed to symbols or summarized) are carried as summaries
+ * with their original note; they are not expanded again.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import ContentCache from '../cache/ContentCache.js';
+import { STRUCTURED_SCHEMA } from '../formatters/structured-formatter.js';
+import ContextPack, { PACK_EXTENSION } from './ContextPack.js';
+import TokenBudget from './TokenBudget.js';
+
+export class ContextMerger {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      dedupe: false, // Keep one file of identical contents under different paths
+      ...options
+    };
+  }
+
+  /**
+   * Read a context to merge
+   * @param {string} filePath - Structured context (.json) or .ctxpack
+   * @returns {{source: string, project: string, files: Array<Object>}}
+   * @throws {Error} For unreadable files and contexts without file contents
+   */
+  static load(filePath) {
+    let document;
+    try {
+      if (filePath.endsWith(PACK_EXTENSION)) {
+        const structured = ContextPack.read(filePath).entries.get('context.json');
+        if (!structured) throw new Error('the pack holds no context.json (pack with --format json)');
+        document = JSON.parse(structured.toString('utf8'));
+      } else {
+        document = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+      }
+    } catch (error) {
+      throw new Error(`Cannot read context ${filePath}: ${error.message}`);
+    }
+    return ContextMerger.fromDocument(document, filePath);
+  }
+
+  /**
+   * @param {Object} document - Parsed structured context
+   * @param {string} source - Its file, for messages
+   * @returns {{source: string, project: string, files: Array<Object>}}
+   */
+  static fromDocument(document, source = 'context') {
+    if (document?.schema !== STRUCTURED_SCHEMA) {
+      throw new Error(`${source} is not a structured context (expected ${STRUCTURED_SCHEMA}; generate it with --format json)`);
+    }
+    const missing = document.files.filter(file => typeof file.content !== 'string');
+    if (missing.length > 0) {
+      throw new Error(`${source} has no content for ${missing.map(file => file.path).join(', ')}`);
+    }
+
+    return {
+      source,
+      project: document.project?.name || path.basename(source, path.extname(source)),
+      files: document.files.map(file => ({
+        path: file.path,
+        content: file.content,
+        hash: ContentCache.hash(file.content),
+        tokens: file.tokens ?? 0,
+        lines: file.lines ?? file.content.split('\n').length,
+        size: file.size ?? Buffer.byteLength(file.content),
+        priority: file.priority ?? null,
+        included: file.included || 'full',
+        note: file.note || null
+      }))
+    };
+  }
+
+  /**
+   * Combine contexts; later contexts win where they changed a file
+   * @param {Array<Object>} contexts - From load(), in order
+   * @returns {Object} { project, prefixed, files, replaced, duplicates, overlapping }
+   */
+  merge(contexts) {
+    const projects = [...new Set(contexts.map(context => context.project))];
+    const prefixed = projects.length > 1;
+    const byPath = new Map();
+    const replaced = [];
+    let overlapping = 0;
+
+    for (const context of contexts) {
+      for (const file of context.files) {
+        const merged = { ...file, path: prefixed ? `${context.project}/${file.path}` : file.path, source: context.source };
+        const existing = byPath.get(merged.path);
+        if (existing?.hash === merged.hash) {
+          overlapping++;
+          continue;
+        }
+        if (existing) {
+          replaced.push({ path: merged.path, source: existing.source, by: merged.source });
+        }
+        byPath.set(merged.path, merged);
+      }
+    }
+
+    let files = [...byPath.values()];
+    const duplicates = [];
+    if (this.options.dedupe) {
+      const kept = new Map();
+      files = files.filter(file => {
+        const original = kept.get(file.hash);
+        if (!original) {
+          kept.set(file.hash, file);
+          return true;
+        }
+        original.duplicates = [...(original.duplicates || []), { path: file.path, similarity: 1 }];
+        duplicates.push({ path: file.path, of: original.path, tokens: file.tokens });
+        return false;
+      });
+    }
+
+    return { project: projects.join('+'), prefixed, files, replaced, duplicates, overlapping };
+  }
+
+  /**
+   * Merged files as the file infos formatters render, read through read()
+   * @param {Array<Object>} files - files of merge()
+   * @param {Object} [options]
+   * @param {TokenBudget} [options.budget] - Recounts tokens and packs the files into its limit
+   * @returns {{fileInfos: Array<Object>, read: Function, plan: Object|null}}
+   */
+  static toFileInfos(files, { budget = null } = {}) {
+    const contents = new Map(files.map(file => [file.path, file.content]));
+    const read = filePath => {
+      if (!contents.has(filePath)) throw new Error(`Not a merged file: ${filePath}`);
+      return contents.get(filePath);
+    };
+
+    const fileInfos = files.map(file => ({
+      path: file.path,
+      relativePath: file.path,
+      tokens: budget ? budget.count(file.content, file.path) : file.tokens,
+      lines: file.lines,
+      sizeBytes: file.size,
+      ...(file.priority === null ? {} : { priority: file.priority }),
+      ...(file.included === 'full' ? {} : { summary: file.content }),
+      ...(file.note ? { selectionNote: file.note } : {}),
+      ...(file.duplicates ? { duplicates: file.duplicates } : {})
+    }));
+    if (!budget) {
+      return { fileInfos, read, plan: null };
+    }
+
+    const byPath = new Map(fileInfos.map(fileInfo => [fileInfo.path, fileInfo]));
+    const plan = budget.plan(fileInfos.map(fileInfo => ({
+      id: fileInfo.path,
+      tokens: fileInfo.tokens,
+      priority: fileInfo.priority ?? TokenBudget.filePriority(fileInfo.path)
+    })), {
+      // Parts and summaries of the merged contexts are not trimmed further
+      expand: item => (byPath.get(item.id).summary ? [] : budget.symbolsFor(read(item.id), item.id)),
+      summarize: (item, tier) => (byPath.get(item.id).summary ? null : budget.summaryFor(read(item.id), item.id, tier))
+    });
+
+    const packed = plan.included.map(item => byPath.get(item.id));
+    for (const item of plan.partial) {
+      packed.push({ ...byPath.get(item.id), tokens: item.tokens, selectedSymbols: item.symbols, selectionNote: 'Trimmed to fit token budget' });
+    }
+    for (const item of plan.summarized) {
+      packed.push({
+        ...byPath.get(item.id),
+        tokens: item.tokens,
+        summary: item.summary,
+        summaryTier: item.tier,
+        selectionNote: `Summarized to fit token budget (${item.tier})`
+      });
+    }
+    return { fileInfos: packed, read, plan };
+  }
+
+  /**
+   * Console summary of a merge
+   * @param {Object} result - From merge()
+   * @param {Array<Object>} contexts - Merged contexts
+   * @returns {string}
+   */
+  static formatReport(result, contexts) {
+    const lines = [`🔀 Merged ${contexts.length} contexts (${contexts.map(context => context.source).join(', ')}): ${result.files.length} files`];
+    if (result.prefixed) {
+      lines.push(`   Paths prefixed with their project: ${[...new Set(contexts.map(context => context.project))].join(', ')}`);
+    }
+    if (result.overlapping > 0) {
+      lines.push(`   ${result.overlapping} files in several contexts kept once`);
+    }
+    for (const { path: filePath, source, by } of result.replaced) {
+      lines.push(`   ${filePath}: ${by} replaces the one of ${source}`);
+    }
+    if (result.duplicates.length > 0) {
+      const tokens = result.duplicates.reduce((sum, duplicate) => sum + duplicate.tokens, 0);
+      lines.push(`   ${result.duplicates.length} duplicate files left out (${tokens.toLocaleString()} tokens)`);
+      for (const duplicate of result.duplicates) {
+        lines.push(`   ${duplicate.path} = ${duplicate.of}`);
+      }
+    }
+    return lines.join('\n');
+  }
+}
+
+export default ContextMerger;
@@ -0,0 +1,288 @@
+/**
+ * ContextMetrics - Prometheus metrics of a long-running ctxman
+ * v3.4.0 - Local metrics endpoint (daemon and serve --metrics)
+ *
+ * Responsibilities:
+ * - Count requests, scanned, skipped and failed files and emitted tokens,
+ *   and time scans, for the daemon and the HTTP server
+ * - Read cache hits and misses from the caches a server keeps warm
+ * - Render everything in the Prometheus text exposition format
+ * - Serve it on GET /metrics of a local listener
+ *
+ * Nothing is sent anywhere: metrics stay in memory until they are scraped,
+ * and the listener binds to localhost unless another host is given.
+ */
+
+import http from 'http';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('ContextMetrics');
+
+export const METRICS_PORT = 9464;
+export const METRICS_CONTENT_TYPE = 'text/plain; version=0.0.4; charset=utf-8';
+
+// Scan durations, in seconds
+const DURATION_BUCKETS = [0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60];
+
+/**
+ * Counters, gauges and histograms with labels, rendered for Prometheus
+ */
+export class MetricsRegistry {
+  constructor() {
+    this.metrics = new Map();
+    this.collectors = [];
+  }
+
+  /**
+   * Declare a metric
+   * @param {string} name
+   * @param {string} type - counter, gauge or histogram
+   * @param {string} help
+   * @param {Array<number>} [buckets] - Histogram upper bounds
+   * @returns {MetricsRegistry}
+   */
+  define(name, type, help, buckets = DURATION_BUCKETS) {
+    this.metrics.set(name, { name, type, help, buckets: type === 'histogram' ? buckets : null, series: new Map() });
+    return this;
+  }
+
+  /**
+   * Add to a counter
+   * @param {string} name
+   * @param {Object} [labels]
+   * @param {number} [value]
+   */
+  inc(name, labels = {}, value = 1) {
+    const series = this.series(name, labels);
+    series.value += value;
+  }
+
+  /**
+   * Set a gauge, or a counter read from elsewhere
+   * @param {string} name
+   * @param {Object} labels
+   * @param {number} value
+   */
+  set(name, labels, value) {
+    this.series(name, labels).value = value;
+  }
+
+  /**
+   * Record a histogram observation
+   * @param {string} name
+   * @param {Object} labels
+   * @param {number} value
+   */
+  observe(name, labels, value) {
+    const series = this.series(name, labels);
+    series.counts = series.counts || this.metrics.get(name).buckets.map(() => 0);
+    this.metrics.get(name).buckets.forEach((bound, i) => {
+      if (value <= bound) series.counts[i]++;
+    });
+    series.count = (series.count || 0) + 1;
+    series.value += value;
+  }
+
+  /**
+   * Run fn before every render, to set values read from elsewhere
+   * @param {function(MetricsRegistry): void} fn
+   */
+  collect(fn) {
+    this.collectors.push(fn);
+  }
+
+  /**
+   * Prometheus text exposition format (version 0.0.4)
+   * @returns {string}
+   */
+  render() {
+    for (const collector of this.collectors) collector(this);
+
+    const lines = [];
+    for (const metric of this.metrics.values()) {
+      lines.push(`# HELP ${metric.name} ${metric.help}`, `# TYPE ${metric.name} ${metric.type}`);
+      for (const series of metric.series.values()) {
+        if (metric.type !== 'histogram') {
+          lines.push(`${metric.name}${formatLabels(series.labels)} ${formatValue(series.value)}`);
+          continue;
+        }
+        metric.buckets.forEach((bound, i) => {
+          lines.push(`${metric.name}_bucket${formatLabels({ ...series.labels, le: String(bound) })} ${series.counts[i]}`);
+        });
+        lines.push(`${metric.name}_bucket${formatLabels({ ...series.labels, le: '+Inf' })} ${series.count}`);
+        lines.push(`${metric.name}_sum${formatLabels(series.labels)} ${formatValue(series.value)}`);
+        lines.push(`${metric.name}_count${formatLabels(series.labels)} ${series.count}`);
+      }
+    }
+    return lines.join('\n') + '\n';
+  }
+
+  /**
+   * @private
+   */
+  series(name, labels) {
+    const metric = this.metrics.get(name);
+    if (!metric) throw new Error(`Unknown metric: ${name}`);
+    const key = JSON.stringify(Object.entries(labels).sort(([a], [b]) => compareBytes(a, b)));
+    if (!metric.series.has(key)) {
+      metric.series.set(key, { labels, value: 0 });
+    }
+    return metric.series.get(key);
+  }
+}
+
+export class ContextMetrics extends MetricsRegistry {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    super();
+    this.options = {
+      mode: 'daemon', // mode label of every run: daemon or http
+      version: null, // ctxman version, for ctxman_info
+      ...options
+    };
+    this.startedAt = Date.now();
+    this.caches = [];
+
+    this.define('ctxman_info', 'gauge', 'ctxman build information')
+      .define('ctxman_uptime_seconds', 'gauge', 'Seconds since the server started')
+      .define('ctxman_requests_total', 'counter', 'Requests answered, by route and outcome')
+      .define('ctxman_runs_total', 'counter', 'Context generations')
+      .define('ctxman_scan_duration_seconds', 'histogram', 'Time to scan and analyze the project')
+      .define('ctxman_files_scanned_total', 'counter', 'Files analyzed')
+      .define('ctxman_files_skipped_total', 'counter', 'Files left out of the analysis, by reason')
+      .define('ctxman_files_exported_total', 'counter', 'Files in generated contexts')
+      .define('ctxman_tokens_emitted_total', 'counter', 'Tokens in generated contexts')
+      .define('ctxman_errors_total', 'counter', 'Files that failed, by stage')
+      .define('ctxman_cache_hits_total', 'counter', 'Content cache lookups answered from the cache')
+      .define('ctxman_cache_misses_total', 'counter', 'Content cache lookups that had to compute')
+      .define('ctxman_cache_hit_ratio', 'gauge', 'Share of content cache lookups answered from the cache');
+
+    this.set('ctxman_info', { version: this.options.version || 'unknown', mode: this.options.mode }, 1);
+    this.collect(() => this.collectServer());
+  }
+
+  /**
+   * Read the hits and misses of a cache kept across requests at every scrape
+   * @param {ContentCache} cache
+   */
+  watchCache(cache) {
+    if (cache && !this.caches.includes(cache)) this.caches.push(cache);
+  }
+
+  /**
+   * Record a finished request
+   * @param {string} route - e.g. context, /symbols
+   * @param {string} outcome - ok, error or an HTTP status
+   */
+  recordRequest(route, outcome) {
+    this.inc('ctxman_requests_total', { mode: this.options.mode, route, outcome: String(outcome) });
+  }
+
+  /**
+   * Record a context generation
+   * @param {TokenCalculator} calculator - After analysis; scanDurationMs set when timed
+   * @param {Array|null} exportResults - Selected files, null when nothing was selected
+   */
+  recordRun(calculator, exportResults) {
+    const { mode } = this.options;
+    const { stats } = calculator;
+    this.inc('ctxman_runs_total', { mode });
+    if (calculator.scanDurationMs !== undefined) {
+      this.observe('ctxman_scan_duration_seconds', { mode }, calculator.scanDurationMs / 1000);
+    }
+    this.inc('ctxman_files_scanned_total', { mode }, stats.totalFiles);
+
+    const skipped = {
+      ignored: stats.ignoredFiles,
+      calculator: stats.calculatorIgnoredFiles,
+      directive: stats.directiveIgnoredFiles,
+      ...stats.skippedContentFiles
+    };
+    for (const [reason, count] of Object.entries(skipped)) {
+      if (count > 0) this.inc('ctxman_files_skipped_total', { mode, reason }, count);
+    }
+
+    if (exportResults) {
+      const files = exportResults.filter(fileInfo => !fileInfo.error);
+      this.inc('ctxman_files_exported_total', { mode }, files.length);
+      this.inc('ctxman_tokens_emitted_total', { mode }, files.reduce((sum, fileInfo) => sum + (fileInfo.tokens || 0), 0));
+    }
+    for (const [stage, count] of Object.entries(calculator.errors.toJSON().stages)) {
+      this.inc('ctxman_errors_total', { mode, stage }, count);
+    }
+    if (calculator.contentCache) this.watchCache(calculator.contentCache);
+  }
+
+  /**
+   * Uptime and cache counters, read at scrape time
+   * @private
+   */
+  collectServer() {
+    this.set('ctxman_uptime_seconds', {}, (Date.now() - this.startedAt) / 1000);
+    if (this.caches.length === 0) return;
+
+    const hits = this.caches.reduce((sum, cache) => sum + cache.getStats().hits, 0);
+    const misses = this.caches.reduce((sum, cache) => sum + cache.getStats().misses, 0);
+    this.set('ctxman_cache_hits_total', {}, hits);
+    this.set('ctxman_cache_misses_total', {}, misses);
+    this.set('ctxman_cache_hit_ratio', {}, hits + misses > 0 ? hits / (hits + misses) : 0);
+  }
+
+  /**
+   * Serve GET /metrics
+   * @param {Object} [address] - { host, port }; default localhost:METRICS_PORT
+   * @returns {Promise<http.Server>} Listening server
+   */
+  listen({ host = 'localhost', port = METRICS_PORT } = {}) {
+    const server = http.createServer((req, res) => {
+      const pathname = new URL(req.url, 'http://localhost').pathname;
+      if (pathname !== '/metrics') {
+        res.writeHead(404, { 'Content-Type': 'text/plain; charset=utf-8' });
+        res.end('Not Found: metrics are served on /metrics\n');
+        return;
+      }
+      if (req.method !== 'GET' && req.method !== 'HEAD') {
+        res.writeHead(405, { Allow: 'GET, HEAD', 'Content-Type': 'text/plain; charset=utf-8' });
+        res.end('Method not allowed: use GET /metrics\n');
+        return;
+      }
+      const body = this.render();
+      res.writeHead(200, { 'Content-Type': METRICS_CONTENT_TYPE });
+      res.end(req.method === 'HEAD' ? undefined : body);
+    });
+
+    return new Promise((resolve, reject) => {
+      server.once('error', reject);
+      server.listen(port, host, () => {
+        server.off('error', reject);
+        logger.debug(`Metrics on http://${host}:${server.address().port}/metrics`);
+        resolve(server);
+      });
+    });
+  }
+}
+
+/**
+ * Label set as {a="1",b="2"}; empty without labels
+ * @private
+ */
+function formatLabels(labels) {
+  const entries = Object.entries(labels);
+  if (entries.length === 0) return '';
+  const escape = value => String(value).replace(/\\/g, '\\\\').replace(/\n/g, '\\n').replace(/"/g, '\\"');
+  return `{${entries.map(([key, value]) => `${key}="${escape(value)}"`).join(',')}}`;
+}
+
+/**
+ * @private
+ */
+function formatValue(value) {
+  if (Number.isNaN(value)) return 'NaN';
+  if (!Number.isFinite(value)) return value > 0 ? '+Inf' : '-Inf';
+  return String(Number.isInteger(value) ? value : Number(value.toFixed(6)));
+}
+
+export default ContextMetrics;
@@ -0,0 +1,304 @@
+/**
+ * ContextPack - Reusable context bundles
+ * v3.4.0 - ctxman pack / unpack
+ *
+ * Responsibilities:
+ * - Bundle generated contexts with a manifest (project, commit, command,
+ *   tokenizer, per-file hashes) and a token map into one versioned archive
+ *   (.ctxpack, a gzipped tar that standard tools can list and extract)
+ * - Read packs back, rejecting unknown versions and entries that fail their hash
+ * - Report which packed sources changed in a working tree since packing
+ *
+ * Archive entries:
+ * - manifest.json: schema, ctxman version, project, command, tokenizer,
+ *   contexts[] { file, format, sha256, size, tokens },
+ *   files[] { path, sha256, tokens, lines, size, included }
+ * - tokens.json: tokens per file and per top-level directory
+ * - the contexts themselves (digest.txt, context.json, llm-context.json, ...)
+ */
+
+import crypto from 'crypto';
+import fs from 'fs';
+import path from 'path';
+import zlib from 'zlib';
+import ContextManifest from './ContextManifest.js';
+import { writeFileAtomic } from './ContextWriter.js';
+
+export const PACK_SCHEMA = 'ctxman.pack/v1';
+export const PACK_EXTENSION = '.ctxpack';
+
+const MANIFEST = 'manifest.json';
+const TOKEN_MAP = 'tokens.json';
+
+const BLOCK = 512;
+
+export class ContextPack {
+  /**
+   * @param {Object} manifest
+   * @param {Map<string, Buffer>} entries - Context files by name, without manifest and token map
+   * @param {Object} tokenMap
+   */
+  constructor(manifest, entries, tokenMap) {
+    this.manifest = manifest;
+    this.entries = entries;
+    this.tokenMap = tokenMap;
+  }
+
+  /**
+   * Pack the contexts of a finished run
+   * @param {Object} run
+   * @param {string} run.project - Project name
+   * @param {Array<string>} run.command - ctxman arguments that generated the contexts
+   * @param {Array<{name: string, content: Buffer|string}>} run.contexts - Written export files
+   * @param {Array} run.files - Exported file analyses
+   * @param {Function} run.readOriginal - (absolutePath) => source as analyzed
+   * @param {Function} run.countTokens - (text) => tokens
+   * @param {string} run.tokenizer
+   * @param {string} run.version - ctxman version
+   * @param {Object|null} run.git - { commit, branch, dirty }
+   * @param {Date} [run.now]
+   * @returns {ContextPack}
+   */
+  static create(run) {
+    const manifest = { ...ContextManifest.create(run), schema: PACK_SCHEMA };
+    const entries = new Map();
+    for (const { name, content } of [...run.contexts].sort((a, b) => compare(a.name, b.name))) {
+      entries.set(name, Buffer.isBuffer(content) ? content : Buffer.from(content, 'utf8'));
+    }
+    return new ContextPack(manifest, entries, ContextPack.buildTokenMap(manifest));
+  }
+
+  /**
+   * Tokens per file and per top-level directory
+   * @param {Object} manifest
+   * @returns {Object}
+   */
+  static buildTokenMap(manifest) {
+    const directories = {};
+    for (const file of manifest.files) {
+      const directory = file.path.includes('/') ? file.path.split('/')[0] : '.';
+      directories[directory] = (directories[directory] || 0) + file.tokens;
+    }
+    return {
+      tokenizer: manifest.tokenizer,
+      total: manifest.files.reduce((sum, file) => sum + file.tokens, 0),
+      directories: Object.fromEntries(Object.entries(directories).sort(([a], [b]) => compare(a, b))),
+      files: Object.fromEntries(manifest.files.map(file => [file.path, file.tokens]))
+    };
+  }
+
+  /**
+   * The archive: a gzipped tar of manifest, token map and contexts
+   * Entries carry the pack's creation time, so the same pack encodes the same bytes.
+   * @returns {Buffer}
+   */
+  toBuffer() {
+    const mtime = Math.floor(Date.parse(this.manifest.createdAt) / 1000);
+    const blocks = [
+      [MANIFEST, Buffer.from(JSON.stringify(this.manifest, null, 2) + '\n')],
+      [TOKEN_MAP, Buffer.from(JSON.stringify(this.tokenMap, null, 2) + '\n')],
+      ...this.entries
+    ].flatMap(([name, content]) => tarEntry(name, content, mtime));
+
+    // Two zero blocks end the archive
+    return zlib.gzipSync(Buffer.concat([...blocks, Buffer.alloc(BLOCK * 2)]));
+  }
+
+  /**
+   * @param {string} outputPath
+   * @param {Object} fileOptions - fsync and backup, as in writeFileAtomic()
+   * @returns {number} Bytes written
+   */
+  write(outputPath, fileOptions = {}) {
+    const buffer = this.toBuffer();
+    writeFileAtomic(outputPath, buffer, fileOptions);
+    return buffer.length;
+  }
+
+  /**
+   * @param {string} filePath
+   * @returns {ContextPack}
+   */
+  static read(filePath) {
+    let buffer;
+    try {
+      buffer = fs.readFileSync(filePath);
+    } catch (error) {
+      throw new Error(`Cannot read pack ${filePath}: ${error.message}`);
+    }
+    return ContextPack.fromBuffer(buffer, filePath);
+  }
+
+  /**
+   * @param {Buffer} buffer - From toBuffer()
+   * @param {string} label - Name used in errors
+   * @returns {ContextPack}
+   */
+  static fromBuffer(buffer, label = 'pack') {
+    let files;
+    try {
+      files = readTar(zlib.gunzipSync(buffer));
+    } catch (error) {
+      throw new Error(`${label} is not a context pack: ${error.message}`);
+    }
+
+    let manifest;
+    try {
+      manifest = JSON.parse(files.get(MANIFEST).toString('utf8'));
+    } catch {
+      throw new Error(`${label} has no readable ${MANIFEST}`);
+    }
+    if (manifest.schema !== PACK_SCHEMA) {
+      throw new Error(`${label} has unsupported schema ${manifest.schema} (expected ${PACK_SCHEMA})`);
+    }
+
+    const entries = new Map();
+    for (const context of manifest.contexts) {
+      const content = files.get(context.file);
+      if (!content) {
+        throw new Error(`${label} is missing ${context.file}`);
+      }
+      if (sha256(content) !== context.sha256) {
+        throw new Error(`${label}: ${context.file} does not match its sha256 in ${MANIFEST}`);
+      }
+      entries.set(context.file, content);
+    }
+    const tokenMap = files.has(TOKEN_MAP) ? JSON.parse(files.get(TOKEN_MAP).toString('utf8')) : ContextPack.buildTokenMap(manifest);
+    return new ContextPack(manifest, entries, tokenMap);
+  }
+
+  /**
+   * Write manifest, token map and contexts into a directory
+   * @param {string} dir
+   * @param {Object} fileOptions - fsync and backup, as in writeFileAtomic()
+   * @returns {Array<string>} Written paths
+   */
+  extract(dir, fileOptions = {}) {
+    fs.mkdirSync(dir, { recursive: true });
+    const written = [];
+    const files = [
+      [MANIFEST, JSON.stringify(this.manifest, null, 2) + '\n'],
+      [TOKEN_MAP, JSON.stringify(this.tokenMap, null, 2) + '\n'],
+      ...this.entries
+    ];
+    for (const [name, content] of files) {
+      // Names come from the archive; keep them inside dir
+      const outputPath = path.join(dir, path.basename(name));
+      writeFileAtomic(outputPath, content, fileOptions);
+      written.push(outputPath);
+    }
+    return written;
+  }
+
+  /**
+   * Packed sources that differ in a working tree
+   * @param {string} projectRoot
+   * @returns {{changed: Array<string>, missing: Array<string>}}
+   */
+  drift(projectRoot) {
+    return ContextManifest.drift(this.manifest, projectRoot);
+  }
+
+  /**
+   * Command that regenerates the pack
+   * @returns {string}
+   */
+  regenerateCommand() {
+    return ContextManifest.command(this.manifest, ['pack']);
+  }
+
+  /**
+   * @param {ContextPack} pack
+   * @returns {string}
+   */
+  static formatSummary(pack) {
+    const { manifest, tokenMap } = pack;
+    const lines = ['📦 CONTEXT PACK', '='.repeat(80)];
+    lines.push(`Project: ${manifest.project.name}${manifest.project.commit
+      ? ` @ ${manifest.project.commit.slice(0, 12)}${manifest.project.branch ? ` (${manifest.project.branch})` : ''}${manifest.project.dirty ? ', uncommitted changes' : ''}`
+      : ''}`);
+    lines.push(`Created: ${manifest.createdAt} by ctxman ${manifest.ctxman}`);
+    lines.push(`Command: ${pack.regenerateCommand()}`);
+    lines.push(`Files:   ${manifest.files.length.toLocaleString()} (${tokenMap.total.toLocaleString()} tokens, ${manifest.tokenizer})`);
+    lines.push('', 'Contexts:');
+    for (const context of manifest.contexts) {
+      lines.push(`  ${context.file.padEnd(20)} ${context.format.padEnd(12)} ${context.tokens.toLocaleString().padStart(10)} tokens  sha256:${context.sha256.slice(0, 12)}`);
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Bytewise order, so packs do not depend on the locale
+ * @private
+ */
+function compare(a, b) {
+  return a < b ? -1 : a > b ? 1 : 0;
+}
+
+/**
+ * @private
+ */
+function sha256(content) {
+  return crypto.createHash('sha256').update(content).digest('hex');
+}
+
+/**
+ * ustar header and padded content of one regular file
+ * @private
+ */
+function tarEntry(name, content, mtime) {
+  if (Buffer.byteLength(name) > 100) {
+    throw new Error(`Pack entry name too long: ${name}`);
+  }
+  const header = Buffer.alloc(BLOCK);
+  const field = (value, offset, length) => header.write(value, offset, length, 'utf8');
+  const octal = (value, offset, length) => field(value.toString(8).padStart(length - 1, '0') + '\0', offset, length);
+
+  field(name, 0, 100);
+  octal(0o644, 100, 8);
+  octal(0, 108, 8);
+  octal(0, 116, 8);
+  octal(content.length, 124, 12);
+  octal(mtime, 136, 12);
+  field(' '.repeat(8), 148, 8); // Counted as spaces while summing
+  field('0', 156, 1);
+  field('ustar\0', 257, 6);
+  field('00', 263, 2);
+
+  const checksum = header.reduce((sum, byte) => sum + byte, 0);
+  field(checksum.toString(8).padStart(6, '0') + '\0 ', 148, 8);
+
+  const padding = (BLOCK - (content.length % BLOCK)) % BLOCK;
+  return [header, content, Buffer.alloc(padding)];
+}
+
+/**
+ * Regular files of a tar archive by name
+ * @private
+ */
+function readTar(buffer) {
+  const files = new Map();
+  for (let offset = 0; offset + BLOCK <= buffer.length;) {
+    const header = buffer.subarray(offset, offset + BLOCK);
+    if (header.every(byte => byte === 0)) break;
+
+    const text = (start, length) => header.subarray(start, start + length).toString('utf8').replace(/\0.*$/s, '');
+    const size = parseInt(text(124, 12).trim(), 8);
+    if (Number.isNaN(size)) {
+      throw new Error(`invalid entry header at byte ${offset}`);
+    }
+    const prefix = text(345, 155);
+    const name = prefix ? `${prefix}/${text(0, 100)}` : text(0, 100);
+    const type = text(156, 1);
+
+    offset += BLOCK;
+    if (type === '0' || type === '') {
+      files.set(name, buffer.subarray(offset, offset + size));
+    }
+    offset += Math.ceil(size / BLOCK) * BLOCK;
+  }
+  return files;
+}
+
+export default ContextPack;
@@ -0,0 +1,293 @@
+/**
+ * ContextProfiles - Named context profiles from context.yaml
+ * v3.4.0 - Context profiles
+ *
+ * Responsibilities:
+ * - Load context.yaml (or context.yml) from the project root
+ * - Validate profiles: include/exclude globs, token budget, tokenizer,
+ *   output format and file, priority rules, priority weights and pins,
+ *   pinned symbols, digest layout, content transforms, decision log
+ * - Resolve a profile by name, following `extends`
+ * - Rank files by the first priority rule whose glob matches
+ */
+
+import fs from 'fs';
+import path from 'path';
+import YAMLParser from '../parsers/yaml-parser.js';
+import GitIgnoreParser from '../parsers/gitignore-parser.js';
+import { parseTokenCount } from './TokenBudget.js';
+import PriorityScorer from './PriorityScorer.js';
+import ContextLayout from './ContextLayout.js';
+import TransformPipeline from './TransformPipeline.js';
+import { decodeText } from './FileSystem.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ContextProfiles');
+
+export const PROFILE_FILES = ['context.yaml', 'context.yml'];
+
+// gitingest = digest.txt, context = llm-context.json, json/yaml = structured context
+export const PROFILE_FORMATS = ['gitingest', 'context', 'json', 'yaml', 'markdown', 'xml'];
+
+const PROFILE_KEYS = ['description', 'extends', 'include', 'exclude', 'budget', 'tokenizer', 'format', 'output', 'priority', 'weights', 'pins', 'symbols', 'layout', 'transforms', 'decisions'];
+
+export class ContextProfiles {
+  /**
+   * @param {Object} profiles - Raw profiles keyed by name
+   * @param {string|null} filePath - Source file (for messages)
+   */
+  constructor(profiles = {}, filePath = null) {
+    this.filePath = filePath;
+    this.profiles = {};
+
+    for (const [name, profile] of Object.entries(profiles)) {
+      this.profiles[name] = validateProfile(name, profile ?? {}, this.label());
+    }
+  }
+
+  /**
+   * Profiles of a project
+   * @param {string} root - Project root
+   * @returns {ContextProfiles|null} Null when the project has no context.yaml
+   * @throws {Error} When the file cannot be parsed or is invalid
+   */
+  static load(root) {
+    const filePath = PROFILE_FILES.map(file => path.join(root, file)).find(file => fs.existsSync(file));
+    if (!filePath) return null;
+
+    const profiles = ContextProfiles.parse(decodeText(fs.readFileSync(filePath)), filePath);
+    logger.debug(`Loaded ${profiles.names().length} profiles from ${filePath}`);
+    return profiles;
+  }
+
+  /**
+   * @param {string} text - context.yaml content
+   * @param {string} filePath
+   * @returns {ContextProfiles}
+   */
+  static parse(text, filePath = PROFILE_FILES[0]) {
+    let data;
+    try {
+      data = YAMLParser.parse(text) ?? {};
+    } catch (error) {
+      throw new Error(`${path.basename(filePath)}: ${error.message}`);
+    }
+
+    if (!isPlainObject(data) || !isPlainObject(data.profiles ?? {})) {
+      throw new Error(`${path.basename(filePath)}: expected a "profiles" mapping`);
+    }
+    return new ContextProfiles(data.profiles ?? {}, filePath);
+  }
+
+  /**
+   * @returns {Array<string>} Profile names in file order
+   */
+  names() {
+    return Object.keys(this.profiles);
+  }
+
+  /**
+   * Profile with its `extends` chain applied (fields of the child win)
+   * @param {string} name
+   * @returns {Object} { name, description, include, exclude, budget, tokenizer, format, output, priority, weights, pins, symbols, layout, transforms }
+   * @throws {Error} For unknown profiles and extends cycles
+   */
+  resolve(name, chain = []) {
+    const profile = this.profiles[name];
+    if (!profile) {
+      const available = this.names();
+      throw new Error(`Unknown profile "${name}"` +
+        (available.length > 0 ? ` (available: ${available.join(', ')})` : ` (${this.label()} defines none)`));
+    }
+    if (chain.includes(name)) {
+      throw new Error(`${this.label()}: profile "${name}" extends itself (${[...chain, name].join(' -> ')})`);
+    }
+
+    const { extends: parent, ...fields } = profile;
+    const base = parent ? this.resolve(parent, [...chain, name]) : {};
+    const defined = Object.fromEntries(Object.entries(fields).filter(([, value]) => value !== undefined));
+
+    const resolved = { ...base, ...defined, name };
+    return {
+      ...resolved,
+      description: resolved.description ?? null,
+      include: resolved.include ?? [],
+      exclude: resolved.exclude ?? [],
+      budget: resolved.budget ?? null,
+      tokenizer: resolved.tokenizer ?? null,
+      format: resolved.format ?? null,
+      output: resolved.output ?? null,
+      priority: resolved.priority ?? [],
+      weights: resolved.weights ?? null,
+      pins: resolved.pins ?? [],
+      symbols: resolved.symbols ?? [],
+      layout: resolved.layout ?? null,
+      transforms: resolved.transforms ?? null,
+      decisions: resolved.decisions ?? false
+    };
+  }
+
+  /**
+   * Priority of a file under a resolved profile's rules
+   * @param {Object} profile - Result of resolve()
+   * @param {string} relativePath - '/'-separated path
+   * @returns {number|null} Priority of the first matching rule, or null
+   */
+  static priorityOf(profile, relativePath) {
+    if (!profile.priority.length) return null;
+
+    if (!profile.priorityMatchers) {
+      const parser = new GitIgnoreParser(null, null, null);
+      Object.defineProperty(profile, 'priorityMatchers', {
+        value: profile.priority.map(rule => ({ ...rule, regex: parser.convertToRegex(rule.pattern).regex }))
+      });
+    }
+
+    const rule = profile.priorityMatchers.find(matcher => matcher.regex.test(relativePath));
+    return rule ? rule.priority : null;
+  }
+
+  /**
+   * @private
+   */
+  label() {
+    return this.filePath ? path.basename(this.filePath) : PROFILE_FILES[0];
+  }
+}
+
+/**
+ * Normalize one profile; unknown keys and bad values are errors
+ */
+function validateProfile(name, profile, label) {
+  const fail = message => {
+    throw new Error(`${label}: profile "${name}" ${message}`);
+  };
+
+  if (!isPlainObject(profile)) fail('must be a mapping');
+
+  const unknown = Object.keys(profile).filter(key => !PROFILE_KEYS.includes(key));
+  if (unknown.length > 0) fail(`has unknown keys: ${unknown.join(', ')} (allowed: ${PROFILE_KEYS.join(', ')})`);
+
+  // null clears a field inherited through extends; absent keys inherit it
+  const globs = key => {
+    if (profile[key] === undefined || profile[key] === null) return profile[key];
+    const list = Array.isArray(profile[key]) ? profile[key] : [profile[key]];
+    if (!list.every(glob => typeof glob === 'string' && glob.trim())) fail(`${key} must be a list of globs`);
+    return list.map(glob => glob.trim());
+  };
+
+  const text = key => {
+    if (profile[key] === undefined || profile[key] === null) return profile[key];
+    if (typeof profile[key] !== 'string') fail(`${key} must be a string`);
+    return profile[key];
+  };
+
+  let budget = profile.budget;
+  if (budget !== undefined && budget !== null) {
+    budget = parseTokenCount(profile.budget);
+    if (!budget) fail(`has an invalid budget: ${profile.budget} (e.g. 8000, 32k, 1m)`);
+  }
+
+  if (profile.decisions !== undefined && profile.decisions !== null && typeof profile.decisions !== 'boolean') {
+    fail('decisions must be true or false');
+  }
+
+  const format = text('format');
+  if (format && !PROFILE_FORMATS.includes(format)) {
+    fail(`has an invalid format: ${format} (expected ${PROFILE_FORMATS.join(', ')})`);
+  }
+
+  return {
+    description: text('description'),
+    extends: text('extends'),
+    include: globs('include'),
+    exclude: globs('exclude'),
+    budget,
+    tokenizer: text('tokenizer'),
+    format,
+    output: text('output'),
+    priority: priorityRules(profile.priority, fail),
+    weights: priorityWeights(profile.weights, fail),
+    pins: globs('pins'),
+    symbols: symbolRefs(profile.symbols, fail),
+    layout: digestLayout(profile.layout, fail),
+    transforms: transformSteps(profile.transforms, fail),
+    decisions: profile.decisions
+  };
+}
+
+/**
+ * Priority rules as { pattern, priority }, in declaration order
+ * Accepts a mapping (glob: priority) or a list of { pattern, priority }.
+ * Also used by .context.yaml files (DirectoryConfig).
+ */
+export function priorityRules(value, fail) {
+  if (value === undefined || value === null) return value;
+
+  const rules = Array.isArray(value)
+    ? value.map(rule => (isPlainObject(rule) ? { pattern: rule.pattern, priority: rule.priority } : {}))
+    : isPlainObject(value) ? Object.entries(value).map(([pattern, priority]) => ({ pattern, priority })) : null;
+
+  if (!rules || !rules.every(rule => typeof rule.pattern === 'string' && Number.isFinite(rule.priority))) {
+    fail('priority must map globs to numbers (e.g. "src/api/**": 50)');
+  }
+  return rules;
+}
+
+/**
+ * Weights of the priority scoring signals, as a mapping (churn: 2)
+ */
+function priorityWeights(value, fail) {
+  if (value === undefined || value === null) return value;
+  if (!isPlainObject(value)) fail('weights must map signals to numbers (e.g. churn: 2)');
+
+  try {
+    return PriorityScorer.parseWeights(value);
+  } catch (error) {
+    return fail(`has invalid weights: ${error.message}`);
+  }
+}
+
+/**
+ * Symbols to select, as symbol IDs or names (same forms as --symbol)
+ */
+function symbolRefs(value, fail) {
+  if (value === undefined || value === null) return value;
+  const list = Array.isArray(value) ? value : [value];
+  if (!list.every(ref => typeof ref === 'string' && ref.trim())) {
+    fail('symbols must be a list of symbol IDs or names');
+  }
+  return list.map(ref => ref.trim());
+}
+
+/**
+ * Digest layout, as a section list or a mapping of order, caps and separator
+ */
+function digestLayout(value, fail) {
+  if (value === undefined || value === null) return value;
+
+  try {
+    return ContextLayout.parse(value);
+  } catch (error) {
+    return fail(`has an invalid layout: ${error.message}`);
+  }
+}
+
+/**
+ * Content transform steps, as names or one-key mappings of a name to its options
+ */
+function transformSteps(value, fail) {
+  if (value === undefined || value === null) return value;
+
+  try {
+    return TransformPipeline.parse(value).map(step => (Object.keys(step.options).length > 0 ? { [step.name]: step.options } : step.name));
+  } catch (error) {
+    return fail(`has invalid transforms: ${error.message}`);
+  }
+}
+
+function isPlainObject(value) {
+  return value !== null && typeof value === 'object' && !Array.isArray(value);
+}
+
+export default ContextProfiles;
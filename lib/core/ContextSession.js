@@ -0,0 +1,390 @@
+/**
+ * ContextSession - What an LLM conversation has already been given
+ * v3.4.0 - Conversation sessions (--session)
+ *
+ * Responsibilities:
+ * - Persist, per named session, the files and symbols supplied in earlier
+ *   turns: a content hash and line hashes per file, a hash per symbol of
+ *   files that were only partly supplied
+ * - Compare a new selection against them: files supplied unchanged are
+ *   omitted, changed files shrink to their changed regions, and symbols
+ *   already supplied are dropped from partial files
+ * - List files supplied earlier that no longer exist
+ * - Format the delta of a turn for the report and the digest header
+ *
+ * Sessions live in .ctxman/sessions/NAME.json and only store hashes, never
+ * file content.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import crypto from 'crypto';
+import { compareBytes } from '../utils/ordering.js';
+
+export const SESSION_DIR = path.join('.ctxman', 'sessions');
+export const SESSION_SCHEMA = 'ctxman.session/v1';
+
+const NAME_PATTERN = /^[A-Za-z0-9][\w.-]*$/;
+
+export class ContextSession {
+  /**
+   * @param {string} name
+   * @param {Object} options
+   */
+  constructor(name, options = {}) {
+    this.name = name;
+    this.options = {
+      contextLines: 3, // Unchanged lines shown around each changed region
+      wholeFileShare: 0.6, // Changed files are resent whole above this share of changed lines
+      maxDiffCells: 4_000_000, // Larger line diffs count the whole differing middle as changed
+      ...options
+    };
+    this.turn = 0;
+    this.createdAt = null;
+    this.updatedAt = null;
+    this.files = new Map();
+  }
+
+  static isValidName(name) {
+    return typeof name === 'string' && NAME_PATTERN.test(name);
+  }
+
+  static filePath(projectRoot, name) {
+    return path.join(projectRoot, SESSION_DIR, `${name}.json`);
+  }
+
+  /**
+   * The session of a project, new when it was never saved
+   * @param {string} projectRoot
+   * @param {string} name
+   * @param {Object} [options]
+   * @returns {ContextSession}
+   * @throws {Error} For invalid names and unreadable session files
+   */
+  static load(projectRoot, name, options = {}) {
+    if (!ContextSession.isValidName(name)) {
+      throw new Error(`Invalid session name: ${name} (letters, digits, '.', '_' and '-')`);
+    }
+    const session = new ContextSession(name, options);
+    const filePath = ContextSession.filePath(projectRoot, name);
+    if (!fs.existsSync(filePath)) {
+      return session;
+    }
+
+    let state;
+    try {
+      state = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+    } catch (error) {
+      throw new Error(`Cannot read session ${name}: ${error.message}`);
+    }
+    if (state.schema !== SESSION_SCHEMA) {
+      throw new Error(`${filePath} is not a ctxman session (expected schema ${SESSION_SCHEMA})`);
+    }
+    session.turn = state.turn;
+    session.createdAt = state.createdAt;
+    session.updatedAt = state.updatedAt;
+    session.files = new Map(Object.entries(state.files));
+    return session;
+  }
+
+  /**
+   * Sessions of a project
+   * @param {string} projectRoot
+   * @returns {Array<{name: string, turn: number, files: number, updatedAt: string}>} By name
+   */
+  static list(projectRoot) {
+    const dir = path.join(projectRoot, SESSION_DIR);
+    if (!fs.existsSync(dir)) return [];
+    return fs.readdirSync(dir)
+      .filter(file => file.endsWith('.json'))
+      .map(file => ContextSession.load(projectRoot, path.basename(file, '.json')))
+      .map(session => ({ name: session.name, turn: session.turn, files: session.files.size, updatedAt: session.updatedAt }))
+      .sort((a, b) => compareBytes(a.name, b.name));
+  }
+
+  /**
+   * Forget a session
+   * @returns {boolean} Whether it existed
+   */
+  static remove(projectRoot, name) {
+    const filePath = ContextSession.filePath(projectRoot, name);
+    if (!fs.existsSync(filePath)) return false;
+    fs.rmSync(filePath);
+    return true;
+  }
+
+  /**
+   * Start the next turn; files recorded from now on belong to it
+   * @returns {number} The turn
+   */
+  beginTurn() {
+    this.turn += 1;
+    return this.turn;
+  }
+
+  /**
+   * How a selected file relates to what earlier turns supplied
+   * @param {Object} fileInfo - Selected file; partial when it has selectedSymbols
+   * @param {string} content - Its content as emitted
+   * @returns {{status: string, turn?: number, ranges?: Array}} status is
+   *   new, unchanged or changed; ranges (selectedSymbols) narrow the file to
+   *   its changed regions or to the symbols not supplied yet
+   */
+  compare(fileInfo, content) {
+    const entry = this.files.get(fileInfo.relativePath);
+    if (!entry) {
+      return { status: 'new' };
+    }
+    // Only files supplied whole have a hash
+    const unchanged = entry.hash === sha256(content);
+
+    if (fileInfo.selectedSymbols) {
+      if (unchanged) {
+        return { status: 'unchanged', turn: entry.turn };
+      }
+      const lines = content.split('\n');
+      const supplied = entry.symbols || {};
+      const remaining = fileInfo.selectedSymbols.filter(symbol =>
+        symbol.context || supplied[symbolKey(symbol)] !== symbolHash(symbol, lines));
+      if (!remaining.some(symbol => !symbol.context)) {
+        return { status: 'unchanged', turn: entry.turn };
+      }
+      return remaining.length === fileInfo.selectedSymbols.length
+        ? { status: 'new' }
+        : { status: 'new', ranges: remaining };
+    }
+
+    if (unchanged) {
+      return { status: 'unchanged', turn: entry.turn };
+    }
+    if (!entry.lines) {
+      // Only symbols of it were supplied
+      return { status: 'new' };
+    }
+    return { status: 'changed', turn: entry.turn, ranges: this.changedRegions(entry.lines, content) };
+  }
+
+  /**
+   * Remember what a turn supplied of a file
+   * Summarized files supply nothing; changed regions bring the file up to date.
+   * @param {Object} fileInfo - Exported file
+   * @param {string} content - Its content as emitted
+   */
+  record(fileInfo, content) {
+    if (fileInfo.error || fileInfo.summary) return;
+
+    const regions = fileInfo.selectedSymbols?.every(range => range.kind === 'region');
+    if (!fileInfo.selectedSymbols || (fileInfo.session === 'changed' && regions)) {
+      this.files.set(fileInfo.relativePath, {
+        turn: this.turn,
+        hash: sha256(content),
+        lines: content.split('\n').map(lineHash)
+      });
+      return;
+    }
+
+    const lines = content.split('\n');
+    const entry = this.files.get(fileInfo.relativePath);
+    const symbols = entry?.symbols || {};
+    for (const symbol of fileInfo.selectedSymbols) {
+      if (!symbol.context) symbols[symbolKey(symbol)] = symbolHash(symbol, lines);
+    }
+    this.files.set(fileInfo.relativePath, { turn: this.turn, symbols });
+  }
+
+  /**
+   * Files supplied earlier that are gone from the project
+   * @param {string} projectRoot
+   * @returns {Array<string>}
+   */
+  removed(projectRoot) {
+    return [...this.files.keys()].filter(file => !fs.existsSync(path.join(projectRoot, file)));
+  }
+
+  /**
+   * Drop files from the session
+   * @param {Array<string>} files
+   */
+  forget(files) {
+    for (const file of files) this.files.delete(file);
+  }
+
+  /**
+   * Write the session (atomic rename)
+   * @param {string} projectRoot
+   * @param {Date} [now]
+   * @returns {string} Path written
+   */
+  save(projectRoot, now = new Date()) {
+    const filePath = ContextSession.filePath(projectRoot, this.name);
+    this.createdAt = this.createdAt || now.toISOString();
+    this.updatedAt = now.toISOString();
+
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    const tmpPath = `${filePath}.${process.pid}.tmp`;
+    fs.writeFileSync(tmpPath, JSON.stringify({
+      schema: SESSION_SCHEMA,
+      name: this.name,
+      turn: this.turn,
+      createdAt: this.createdAt,
+      updatedAt: this.updatedAt,
+      files: Object.fromEntries(this.files)
+    }));
+    fs.renameSync(tmpPath, filePath);
+    return filePath;
+  }
+
+  /**
+   * Changed regions of a file against the line hashes of an earlier turn
+   * @param {Array<string>} before - Line hashes supplied earlier
+   * @param {string} content - Current content
+   * @returns {Array|null} Region ranges (kind 'region'), null when most of
+   *   the file changed and it is resent whole
+   */
+  changedRegions(before, content) {
+    const after = content.split('\n');
+    const changed = changedLines(before, after.map(lineHash), this.options.maxDiffCells);
+    const { contextLines } = this.options;
+
+    const regions = [];
+    for (const line of changed) {
+      const startLine = Math.max(1, line - contextLines);
+      const endLine = Math.min(after.length, line + contextLines);
+      const last = regions[regions.length - 1];
+      if (last && startLine <= last.endLine + 1) {
+        last.endLine = Math.max(last.endLine, endLine);
+      } else {
+        regions.push({ startLine, endLine });
+      }
+    }
+
+    const shown = regions.reduce((sum, region) => sum + region.endLine - region.startLine + 1, 0);
+    if (regions.length === 0 || shown > after.length * this.options.wholeFileShare) {
+      return null;
+    }
+    return regions.map(({ startLine, endLine }) => ({ kind: 'region', name: 'changed', startLine, endLine }));
+  }
+
+  /**
+   * Format the delta of a turn as a console report
+   * @param {Object} delta - { name, turn, added, changed, omitted, removed, savedTokens }
+   * @returns {string}
+   */
+  static formatDelta(delta) {
+    const lines = ['', '🔁 CONVERSATION SESSION', '='.repeat(80)];
+    lines.push(`   Session: ${delta.name}, turn ${delta.turn}`);
+    lines.push(`   New files:       ${delta.added}`);
+    lines.push(`   Changed files:   ${delta.changed} (changed regions only)`);
+    lines.push(`   Omitted files:   ${delta.omitted.length} (supplied unchanged in earlier turns)`);
+    if (delta.removed.length > 0) {
+      lines.push(`   Removed files:   ${delta.removed.length}`);
+    }
+    if (delta.savedTokens > 0) {
+      lines.push(`   Tokens saved:    ${delta.savedTokens.toLocaleString()}`);
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * Digest header lines telling the model what earlier turns supplied
+   * @param {Object} delta - As for formatDelta()
+   * @returns {string}
+   */
+  static formatHeader(delta) {
+    let header = `Conversation turn: ${delta.turn} (session ${delta.name})` +
+      (delta.turn > 1 ? '; changed files show changed regions only\n' : '\n');
+    if (delta.omitted.length > 0) {
+      header += 'Supplied in earlier turns, unchanged:\n';
+      header += delta.omitted.map(({ file, turn }) => `  ${file} (turn ${turn})\n`).join('');
+    }
+    if (delta.removed.length > 0) {
+      header += 'Removed since earlier turns:\n';
+      header += delta.removed.map(file => `  ${file}\n`).join('');
+    }
+    return header;
+  }
+}
+
+/**
+ * @private
+ */
+function sha256(content) {
+  return crypto.createHash('sha256').update(content).digest('hex');
+}
+
+/**
+ * Short hash of one line; collisions only make a changed line look unchanged
+ * @private
+ */
+function lineHash(line) {
+  return crypto.createHash('sha1').update(line).digest('base64').slice(0, 8);
+}
+
+/**
+ * @private
+ */
+function symbolKey(symbol) {
+  return `${symbol.kind} ${symbol.name}`;
+}
+
+/**
+ * @private
+ */
+function symbolHash(symbol, lines) {
+  return lineHash((symbol.lines || lines.slice(symbol.startLine - 1, symbol.endLine)).join('\n'));
+}
+
+/**
+ * Lines (1-based, of after) that are new or next to removed lines, in order
+ * Common prefix and suffix are skipped; the rest is diffed by longest common
+ * subsequence, or counted as changed when larger than maxCells.
+ * @private
+ */
+function changedLines(before, after, maxCells) {
+  let start = 0;
+  while (start < before.length && start < after.length && before[start] === after[start]) start++;
+  let endBefore = before.length;
+  let endAfter = after.length;
+  while (endBefore > start && endAfter > start && before[endBefore - 1] === after[endAfter - 1]) {
+    endBefore--;
+    endAfter--;
+  }
+
+  const a = before.slice(start, endBefore);
+  const b = after.slice(start, endAfter);
+  // Removed lines show the line they were removed before (or after, at the end)
+  const anchor = index => Math.min(Math.max(1, start + index + 1), after.length);
+  if (a.length * b.length > maxCells) {
+    return b.length > 0 ? b.map((_, index) => start + index + 1) : [anchor(0)];
+  }
+
+  const width = b.length + 1;
+  const table = new Uint32Array((a.length + 1) * width);
+  for (let i = a.length - 1; i >= 0; i--) {
+    for (let j = b.length - 1; j >= 0; j--) {
+      table[i * width + j] = a[i] === b[j]
+        ? table[(i + 1) * width + j + 1] + 1
+        : Math.max(table[(i + 1) * width + j], table[i * width + j + 1]);
+    }
+  }
+
+  const changed = new Set();
+  let i = 0;
+  let j = 0;
+  while (i < a.length || j < b.length) {
+    if (i < a.length && j < b.length && a[i] === b[j]) {
+      i++;
+      j++;
+    } else if (i < a.length && (j === b.length || table[(i + 1) * width + j] >= table[i * width + j + 1])) {
+      // Removed before added, so a replaced line anchors to itself
+      changed.add(anchor(j));
+      i++;
+    } else {
+      changed.add(start + j + 1);
+      j++;
+    }
+  }
+  return [...changed].filter(line => line >= 1).sort((x, y) => x - y);
+}
+
+export default ContextSession;
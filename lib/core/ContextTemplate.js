@@ -0,0 +1,807 @@
+/**
+ * ContextTemplate - Prompt scaffolding around generated context
+ * v3.4.0 - Context templates (--template)
+ *
+ * Responsibilities:
+ * - Parse templates in Go text/template syntax
+ * - Render them against the context data (files, tree, digest, --var values)
+ * - Report template errors with file and line, as Go does
+ *
+ * Supported: {{.Field.Chain}}, $variables ($x := ..., $x = ...), pipelines
+ * (|), parenthesized pipelines, if/else if/else, range (with $i, $e := and
+ * else), with, define/template, comments and {{- -}} whitespace trimming.
+ * Functions: the Go builtins (and, or, not, len, index, print, printf,
+ * println, eq, ne, lt, le, gt, ge) plus join, indent, upper, lower, trim
+ * and default in their sprig argument order.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { decodeText } from './FileSystem.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const NO_VALUE = '<no value>';
+
+const TOKEN_PATTERNS = [
+  ['string', /^"(?:[^"\\\n]|\\.)*"/],
+  ['raw', /^`[^`]*`/],
+  ['declare', /^:=/],
+  ['assign', /^=/],
+  ['pipe', /^\|/],
+  ['open', /^\(/],
+  ['close', /^\)/],
+  ['comma', /^,/],
+  ['variable', /^\$[A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*/],
+  ['field', /^\.(?:[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*)?/],
+  ['number', /^-?\d+(?:\.\d+)?/],
+  ['identifier', /^[A-Za-z_][A-Za-z0-9_]*/]
+];
+
+const KEYWORDS = new Set(['if', 'else', 'end', 'range', 'with', 'define', 'template']);
+
+export const TEMPLATE_FUNCTIONS = {
+  and: (...args) => args.find(arg => !truth(arg)) ?? args[args.length - 1],
+  or: (...args) => args.find(arg => truth(arg)) ?? args[args.length - 1],
+  not: value => !truth(value),
+  len: value => lengthOf(value),
+  index: (value, ...keys) => keys.reduce((current, key) => indexInto(current, key), value),
+  print: (...args) => sprint(args),
+  println: (...args) => `${args.map(format).join(' ')}\n`,
+  printf: (pattern, ...args) => sprintf(pattern, args),
+  eq: (a, ...others) => others.some(other => a === other),
+  ne: (a, b) => a !== b,
+  lt: (a, b) => a < b,
+  le: (a, b) => a <= b,
+  gt: (a, b) => a > b,
+  ge: (a, b) => a >= b,
+  join: (separator, list) => toList(list).map(format).join(separator),
+  indent: (spaces, text) => format(text).split('\n').map(line => (line ? ' '.repeat(spaces) + line : line)).join('\n'),
+  upper: text => format(text).toUpperCase(),
+  lower: text => format(text).toLowerCase(),
+  trim: text => format(text).trim(),
+  default: (fallback, value) => (truth(value) ? value : fallback)
+};
+
+export class ContextTemplate {
+  /**
+   * @param {Array<Object>} nodes - Parsed body
+   * @param {Map<string, Array<Object>>} defines - Templates named with {{define}}
+   * @param {string} name - Name used in error messages (usually the file name)
+   */
+  constructor(nodes, defines, name) {
+    this.nodes = nodes;
+    this.defines = defines;
+    this.name = name;
+  }
+
+  /**
+   * @param {string} filePath
+   * @returns {ContextTemplate}
+   * @throws {Error} When the file is missing or does not parse
+   */
+  static load(filePath) {
+    let text;
+    try {
+      text = decodeText(fs.readFileSync(filePath));
+    } catch (error) {
+      throw new Error(`Cannot read template ${filePath}: ${error.code === 'ENOENT' ? 'file not found' : error.message}`);
+    }
+    const template = ContextTemplate.parse(text, path.basename(filePath));
+    template.file = filePath;
+    return template;
+  }
+
+  /**
+   * Output file for a template: its name without .tmpl/.tpl, .txt when no
+   * extension remains ("review.tmpl" -> "review.txt", "prompt.md.tmpl" -> "prompt.md")
+   * @param {string} filePath
+   * @returns {string}
+   */
+  static defaultFile(filePath) {
+    const name = path.basename(filePath).replace(/\.(?:tmpl|tpl|gotmpl)$/i, '');
+    return path.extname(name) ? name : `${name}.txt`;
+  }
+
+  /**
+   * Template data whose expensive fields are computed on first use
+   * @param {Object} fields - Plain values
+   * @param {Object<string, Function>} getters - Field name -> () => value
+   * @returns {Object}
+   */
+  static lazy(fields, getters = {}) {
+    const data = { ...fields };
+    for (const [name, compute] of Object.entries(getters)) {
+      let value;
+      let computed = false;
+      Object.defineProperty(data, name, {
+        enumerable: true,
+        get() {
+          if (!computed) {
+            value = compute();
+            computed = true;
+          }
+          return value;
+        }
+      });
+    }
+    return data;
+  }
+
+  /**
+   * @param {string} text - Template source
+   * @param {string} name
+   * @returns {ContextTemplate}
+   * @throws {Error} "template: NAME:LINE: ..." on syntax errors
+   */
+  static parse(text, name = 'template') {
+    const parser = new Parser(lex(text, name), name);
+    const { nodes, terminator } = parser.parseList();
+    if (terminator) {
+      throw templateError(name, terminator.line, `unexpected {{${terminator.keyword}}}`);
+    }
+    return new ContextTemplate(nodes, parser.defines, name);
+  }
+
+  /**
+   * @param {Object} data - Value of `.` (and `$`) at the top level
+   * @returns {string}
+   * @throws {Error} "template: NAME:LINE: ..." on execution errors
+   */
+  render(data) {
+    const state = { out: [], vars: [{ name: '$', value: data }], depth: 0 };
+    this.execute(this.nodes, data, state);
+    return state.out.join('');
+  }
+
+  /**
+   * @private
+   */
+  execute(nodes, dot, state) {
+    for (const node of nodes) {
+      switch (node.type) {
+        case 'text':
+          state.out.push(node.text);
+          break;
+        case 'action': {
+          const value = this.evalPipe(node.pipe, dot, state, node);
+          if (!node.pipe.declare) state.out.push(format(value));
+          break;
+        }
+        case 'if':
+          this.executeIf(node, dot, state);
+          break;
+        case 'with':
+          this.scoped(state, () => {
+            const value = this.evalPipe(node.pipe, dot, state, node);
+            if (truth(value)) this.execute(node.body, value, state);
+            else if (node.elseBody) this.execute(node.elseBody, dot, state);
+          });
+          break;
+        case 'range':
+          this.executeRange(node, dot, state);
+          break;
+        case 'template':
+          this.executeTemplate(node, dot, state);
+          break;
+      }
+    }
+  }
+
+  /**
+   * @private
+   */
+  executeIf(node, dot, state) {
+    for (const branch of node.branches) {
+      const taken = this.scoped(state, () => {
+        if (!truth(this.evalPipe(branch.pipe, dot, state, branch))) return false;
+        this.execute(branch.body, dot, state);
+        return true;
+      });
+      if (taken) return;
+    }
+    if (node.elseBody) this.scoped(state, () => this.execute(node.elseBody, dot, state));
+  }
+
+  /**
+   * @private
+   */
+  executeRange(node, dot, state) {
+    this.scoped(state, () => {
+      const value = this.evalPipe({ ...node.pipe, declare: null }, dot, state, node);
+      const entries = rangeEntries(value, () => this.fail(node, `range can't iterate over ${format(value)}`));
+      if (entries.length === 0) {
+        if (node.elseBody) this.execute(node.elseBody, dot, state);
+        return;
+      }
+
+      const declared = node.pipe.declare || [];
+      for (const [key, element] of entries) {
+        this.scoped(state, () => {
+          if (declared.length === 1) state.vars.push({ name: declared[0], value: element });
+          if (declared.length === 2) state.vars.push({ name: declared[0], value: key }, { name: declared[1], value: element });
+          this.execute(node.body, element, state);
+        });
+      }
+    });
+  }
+
+  /**
+   * @private
+   */
+  executeTemplate(node, dot, state) {
+    const body = this.defines.get(node.name);
+    if (!body) this.fail(node, `no such template "${node.name}"`);
+    if (state.depth > 100) this.fail(node, `exceeded maximum template depth (100) in "${node.name}"`);
+
+    const value = node.pipe ? this.evalPipe(node.pipe, dot, state, node) : null;
+    const inner = { ...state, vars: [{ name: '$', value }], depth: state.depth + 1 };
+    this.execute(body, value, inner);
+  }
+
+  /**
+   * Run fn with variables declared inside it dropped afterwards
+   * @private
+   */
+  scoped(state, fn) {
+    const mark = state.vars.length;
+    try {
+      return fn();
+    } finally {
+      state.vars.length = mark;
+    }
+  }
+
+  /**
+   * @private
+   */
+  evalPipe(pipe, dot, state, node) {
+    let value;
+    let hasValue = false;
+    for (const command of pipe.commands) {
+      value = this.evalCommand(command, dot, state, node, hasValue ? [value] : []);
+      hasValue = true;
+    }
+
+    if (pipe.declare) {
+      if (pipe.assign) {
+        const variable = findVariable(state, pipe.declare[0]);
+        if (!variable) this.fail(node, `undefined variable: ${pipe.declare[0]}`);
+        variable.value = value;
+      } else {
+        state.vars.push({ name: pipe.declare[0], value });
+      }
+    }
+    return value;
+  }
+
+  /**
+   * @private
+   */
+  evalCommand(operands, dot, state, node, piped) {
+    const [first, ...rest] = operands;
+    if (first.type === 'function') {
+      const fn = TEMPLATE_FUNCTIONS[first.name];
+      const args = [...rest.map(operand => this.evalOperand(operand, dot, state, node)), ...piped];
+      try {
+        return fn(...args);
+      } catch (error) {
+        this.fail(node, `error calling ${first.name}: ${error.message}`);
+      }
+    }
+    if (rest.length > 0 || piped.length > 0) {
+      this.fail(node, `can't give argument to non-function ${first.text}`);
+    }
+    return this.evalOperand(first, dot, state, node);
+  }
+
+  /**
+   * @private
+   */
+  evalOperand(operand, dot, state, node) {
+    switch (operand.type) {
+      case 'literal':
+        return operand.value;
+      case 'field':
+        return this.walk(dot, operand.chain, operand.text, node);
+      case 'variable': {
+        const variable = findVariable(state, operand.name);
+        if (!variable) this.fail(node, `undefined variable: ${operand.name}`);
+        return this.walk(variable.value, operand.chain, operand.text, node);
+      }
+      case 'pipe':
+        return this.walk(this.evalPipe(operand.pipe, dot, state, node), operand.chain, operand.text, node);
+      case 'function':
+        return this.evalCommand([operand], dot, state, node, []);
+    }
+    return null;
+  }
+
+  /**
+   * @private
+   */
+  walk(value, chain, text, node) {
+    let current = value;
+    for (const name of chain) {
+      if (current === null || current === undefined) {
+        this.fail(node, `at <${text}>: nil pointer evaluating ${name}`);
+      }
+      if (current instanceof Map) {
+        if (!current.has(name)) this.fail(node, `at <${text}>: map has no entry for key "${name}"`);
+        current = current.get(name);
+      } else if (typeof current === 'object' && name in current) {
+        current = current[name];
+      } else {
+        this.fail(node, `at <${text}>: can't evaluate field ${name} in type ${typeName(current)}`);
+      }
+    }
+    return current;
+  }
+
+  /**
+   * @private
+   */
+  fail(node, message) {
+    throw templateError(this.name, node.line, message);
+  }
+}
+
+/**
+ * Split a template into text and {{action}} items, applying trim markers
+ * @private
+ */
+function lex(text, name) {
+  const items = [];
+  let position = 0;
+  let trimNext = false;
+
+  while (position < text.length) {
+    const open = text.indexOf('{{', position);
+    let chunk = text.slice(position, open === -1 ? text.length : open);
+    if (trimNext) chunk = chunk.trimStart();
+    trimNext = false;
+    if (open === -1) {
+      if (chunk) items.push({ type: 'text', text: chunk });
+      break;
+    }
+
+    const line = lineAt(text, open);
+    let start = open + 2;
+    if (/^-\s/.test(text.slice(start, start + 2))) {
+      chunk = chunk.trimEnd();
+      start += 1;
+    }
+    if (chunk) items.push({ type: 'text', text: chunk });
+
+    const close = findClose(text, start);
+    if (close === -1) throw templateError(name, line, 'unclosed action');
+
+    let end = close;
+    if (/\s-$/.test(text.slice(start, close))) {
+      trimNext = true;
+      end = close - 1;
+    }
+    const source = text.slice(start, end).trim();
+    position = close + 2;
+
+    if (source.startsWith('/*')) {
+      if (!source.endsWith('*/')) throw templateError(name, line, 'unclosed comment');
+      continue;
+    }
+    items.push({ type: 'action', source, tokens: tokenize(source, name, line), line });
+  }
+
+  return items;
+}
+
+/**
+ * End of an action, skipping "}}" inside string literals and comments
+ * @private
+ */
+function findClose(text, start) {
+  if (text.startsWith('/*', text.slice(start).search(/\S/) + start)) {
+    const end = text.indexOf('*/', start);
+    return end === -1 ? -1 : text.indexOf('}}', end + 2);
+  }
+  for (let i = start; i < text.length; i++) {
+    const ch = text[i];
+    if (ch === '"') {
+      for (i++; i < text.length && text[i] !== '"' && text[i] !== '\n'; i++) {
+        if (text[i] === '\\') i++;
+      }
+    } else if (ch === '`') {
+      i = text.indexOf('`', i + 1);
+      if (i === -1) return -1;
+    } else if (ch === '}' && text[i + 1] === '}') {
+      return i;
+    }
+  }
+  return -1;
+}
+
+/**
+ * @private
+ */
+function tokenize(source, name, line) {
+  const tokens = [];
+  let rest = source;
+  while (rest.length > 0) {
+    const space = /^\s+/.exec(rest);
+    if (space) {
+      rest = rest.slice(space[0].length);
+      continue;
+    }
+
+    const adjacent = tokens.length > 0 && source.length - rest.length === tokens[tokens.length - 1].end;
+    const match = TOKEN_PATTERNS.map(([type, pattern]) => [type, pattern.exec(rest)]).find(([, found]) => found);
+    if (!match) throw templateError(name, line, `unexpected "${rest[0]}" in action`);
+
+    const [type, [text]] = match;
+    const start = source.length - rest.length;
+    tokens.push({ type, text, adjacent, end: start + text.length });
+    rest = rest.slice(text.length);
+  }
+  return tokens;
+}
+
+class Parser {
+  constructor(items, name) {
+    this.items = items;
+    this.index = 0;
+    this.name = name;
+    this.defines = new Map();
+  }
+
+  /**
+   * Nodes up to a terminating {{else}} or {{end}} (or the end of input)
+   */
+  parseList() {
+    const nodes = [];
+    while (this.index < this.items.length) {
+      const item = this.items[this.index++];
+      if (item.type === 'text') {
+        nodes.push(item);
+        continue;
+      }
+
+      const [first] = item.tokens;
+      const keyword = first && first.type === 'identifier' && KEYWORDS.has(first.text) ? first.text : null;
+      if (keyword === 'end' || keyword === 'else') {
+        return { nodes, terminator: { keyword, item, line: item.line } };
+      }
+      if (keyword === 'define') {
+        this.parseDefine(item);
+        continue;
+      }
+      nodes.push(keyword ? this.parseControl(keyword, item) : { type: 'action', pipe: this.parsePipe(item, item.tokens), line: item.line });
+    }
+    return { nodes, terminator: null };
+  }
+
+  parseControl(keyword, item) {
+    const tokens = item.tokens.slice(1);
+    if (keyword === 'template') {
+      const [nameToken, ...rest] = tokens;
+      if (!nameToken || (nameToken.type !== 'string' && nameToken.type !== 'raw')) {
+        this.fail(item, 'template needs a quoted name');
+      }
+      return { type: 'template', name: literalOf(nameToken), pipe: rest.length > 0 ? this.parsePipe(item, rest) : null, line: item.line };
+    }
+
+    if (tokens.length === 0 && keyword !== 'template') this.fail(item, `missing value for ${keyword}`);
+
+    if (keyword === 'if') {
+      const node = { type: 'if', branches: [], elseBody: null, line: item.line };
+      let pipe = this.parsePipe(item, tokens);
+      let line = item.line;
+      for (;;) {
+        const { nodes, terminator } = this.expectTerminator(item, keyword);
+        node.branches.push({ pipe, body: nodes, line });
+        if (terminator.keyword === 'end') return node;
+
+        const elseTokens = terminator.item.tokens.slice(1);
+        if (elseTokens.length > 0 && elseTokens[0].text === 'if') {
+          pipe = this.parsePipe(terminator.item, elseTokens.slice(1));
+          line = terminator.line;
+          continue;
+        }
+        node.elseBody = this.parseElse(item, keyword);
+        return node;
+      }
+    }
+
+    // range and with
+    const node = { type: keyword, pipe: this.parsePipe(item, tokens, keyword === 'range'), body: null, elseBody: null, line: item.line };
+    const { nodes, terminator } = this.expectTerminator(item, keyword);
+    node.body = nodes;
+    if (terminator.keyword === 'else') {
+      if (terminator.item.tokens.length > 1) this.fail(terminator.item, `unexpected ${terminator.item.source} in ${keyword}`);
+      node.elseBody = this.parseElse(item, keyword);
+    }
+    return node;
+  }
+
+  parseElse(item, keyword) {
+    const { nodes, terminator } = this.expectTerminator(item, keyword);
+    if (terminator.keyword !== 'end') this.fail(terminator.item, `expected {{end}} after {{else}} in ${keyword}`);
+    return nodes;
+  }
+
+  parseDefine(item) {
+    const nameToken = item.tokens[1];
+    if (!nameToken || (nameToken.type !== 'string' && nameToken.type !== 'raw') || item.tokens.length > 2) {
+      this.fail(item, 'define needs a quoted name');
+    }
+    const { nodes, terminator } = this.expectTerminator(item, 'define');
+    if (terminator.keyword !== 'end') this.fail(terminator.item, 'unexpected {{else}} in define');
+    this.defines.set(literalOf(nameToken), nodes);
+  }
+
+  expectTerminator(item, keyword) {
+    const result = this.parseList();
+    if (!result.terminator) this.fail(item, `unexpected EOF: {{${keyword}}} has no {{end}}`);
+    return result;
+  }
+
+  /**
+   * [$x :=] command | command ...; range also accepts $i, $e :=
+   */
+  parsePipe(item, tokens, allowTwo = false) {
+    let rest = tokens;
+    let declare = null;
+    let assign = false;
+
+    const declaration = allowTwo && tokens[0]?.type === 'variable' && tokens[1]?.type === 'comma'
+      ? 3 : (tokens[0]?.type === 'variable' && ['declare', 'assign'].includes(tokens[1]?.type) ? 1 : 0);
+    if (declaration === 3) {
+      if (tokens[2]?.type !== 'variable' || tokens[3]?.type !== 'declare') this.fail(item, 'expected $index, $element := in range');
+      declare = [tokens[0].text, tokens[2].text];
+      rest = tokens.slice(4);
+    } else if (declaration === 1) {
+      declare = [tokens[0].text];
+      assign = tokens[1].type === 'assign';
+      rest = tokens.slice(2);
+    }
+    if (declare && declare.some(name => name.includes('.'))) this.fail(item, `cannot declare ${declare.join(', ')}`);
+
+    const commands = [];
+    let command = [];
+    for (let i = 0; i < rest.length; i++) {
+      const token = rest[i];
+      if (token.type === 'pipe') {
+        if (command.length === 0) this.fail(item, 'missing command before |');
+        commands.push(command);
+        command = [];
+        continue;
+      }
+      if (token.type === 'open') {
+        const close = matchingClose(rest, i);
+        if (close === -1) this.fail(item, 'unclosed left paren');
+        const operand = { type: 'pipe', pipe: this.parsePipe(item, rest.slice(i + 1, close)), chain: [], text: tokensText(rest.slice(i, close + 1)) };
+        if (rest[close + 1]?.type === 'field' && rest[close + 1].adjacent) {
+          operand.chain = fieldChain(rest[close + 1].text);
+          operand.text += rest[close + 1].text;
+          i = close + 1;
+        } else {
+          i = close;
+        }
+        command.push(operand);
+        continue;
+      }
+      command.push(this.operand(item, token));
+    }
+    if (command.length === 0) this.fail(item, declare ? `missing value for ${declare.join(', ')}` : 'missing value for command');
+    commands.push(command);
+
+    return { declare, assign, commands };
+  }
+
+  operand(item, token) {
+    switch (token.type) {
+      case 'string':
+      case 'raw':
+        return { type: 'literal', value: literalOf(token), text: token.text };
+      case 'number':
+        return { type: 'literal', value: Number(token.text), text: token.text };
+      case 'field':
+        return { type: 'field', chain: fieldChain(token.text), text: token.text };
+      case 'variable': {
+        const [name, ...chain] = token.text.split('.');
+        return { type: 'variable', name, chain, text: token.text };
+      }
+      case 'identifier':
+        if (token.text === 'true' || token.text === 'false') return { type: 'literal', value: token.text === 'true', text: token.text };
+        if (token.text === 'nil') return { type: 'literal', value: null, text: token.text };
+        if (!Object.hasOwn(TEMPLATE_FUNCTIONS, token.text)) this.fail(item, `function "${token.text}" not defined`);
+        return { type: 'function', name: token.text, text: token.text };
+    }
+    return this.fail(item, `unexpected "${token.text}" in operand`);
+  }
+
+  fail(item, message) {
+    throw templateError(this.name, item.line, message);
+  }
+}
+
+/**
+ * @private
+ */
+function matchingClose(tokens, openIndex) {
+  let depth = 0;
+  for (let i = openIndex; i < tokens.length; i++) {
+    if (tokens[i].type === 'open') depth++;
+    if (tokens[i].type === 'close' && --depth === 0) return i;
+  }
+  return -1;
+}
+
+/**
+ * @private
+ */
+function tokensText(tokens) {
+  return tokens.map((token, i) => (i > 0 && !token.adjacent ? ' ' : '') + token.text).join('');
+}
+
+/**
+ * @private
+ */
+function fieldChain(text) {
+  return text === '.' ? [] : text.slice(1).split('.');
+}
+
+/**
+ * @private
+ */
+function literalOf(token) {
+  return token.type === 'raw' ? token.text.slice(1, -1) : JSON.parse(token.text);
+}
+
+/**
+ * @private
+ */
+function findVariable(state, name) {
+  for (let i = state.vars.length - 1; i >= 0; i--) {
+    if (state.vars[i].name === name) return state.vars[i];
+  }
+  return null;
+}
+
+/**
+ * @private
+ */
+function rangeEntries(value, fail) {
+  if (value === null || value === undefined) return [];
+  if (Array.isArray(value)) return value.map((element, i) => [i, element]);
+  if (value instanceof Map) return [...value.keys()].sort(compareBytes).map(key => [key, value.get(key)]);
+  if (Number.isInteger(value)) return Array.from({ length: Math.max(0, value) }, (_, i) => [i, i]);
+  if (typeof value === 'object') return Object.keys(value).sort(compareBytes).map(key => [key, value[key]]);
+  return fail();
+}
+
+/**
+ * Go truthiness: false, 0, nil and empty strings, lists and maps are false
+ * @private
+ */
+function truth(value) {
+  if (value === null || value === undefined || value === false || value === 0 || value === '') return false;
+  if (Array.isArray(value)) return value.length > 0;
+  if (value instanceof Map) return value.size > 0;
+  return true;
+}
+
+/**
+ * @private
+ */
+function lengthOf(value) {
+  if (typeof value === 'string' || Array.isArray(value)) return value.length;
+  if (value instanceof Map) return value.size;
+  if (value && typeof value === 'object') return Object.keys(value).length;
+  throw new Error(`len of ${typeName(value)}`);
+}
+
+/**
+ * @private
+ */
+function indexInto(value, key) {
+  if (Array.isArray(value) || typeof value === 'string') {
+    if (!Number.isInteger(key) || key < 0 || key >= value.length) throw new Error(`index out of range: ${key}`);
+    return value[key];
+  }
+  if (value instanceof Map) return value.has(key) ? value.get(key) : null;
+  if (value && typeof value === 'object') return Object.hasOwn(value, key) ? value[key] : null;
+  throw new Error(`can't index item of type ${typeName(value)}`);
+}
+
+/**
+ * @private
+ */
+function toList(value) {
+  if (Array.isArray(value)) return value;
+  if (value instanceof Map) return [...value.values()];
+  throw new Error(`join needs a list, got ${typeName(value)}`);
+}
+
+/**
+ * Printed form of a value, following Go's fmt for the shapes templates see
+ * @private
+ */
+function format(value) {
+  if (value === null || value === undefined) return NO_VALUE;
+  if (typeof value === 'string') return value;
+  if (Array.isArray(value)) return `[${value.map(format).join(' ')}]`;
+  if (value instanceof Map) return `map[${[...value.keys()].sort(compareBytes).map(key => `${key}:${format(value.get(key))}`).join(' ')}]`;
+  if (typeof value === 'object') return `map[${Object.keys(value).sort(compareBytes).map(key => `${key}:${format(value[key])}`).join(' ')}]`;
+  return String(value);
+}
+
+/**
+ * fmt.Sprint: spaces between operands when neither side is a string
+ * @private
+ */
+function sprint(args) {
+  return args.map((arg, i) => (i > 0 && typeof arg !== 'string' && typeof args[i - 1] !== 'string' ? ' ' : '') + format(arg)).join('');
+}
+
+/**
+ * fmt.Sprintf for %s %d %v %q %f %x and %%, with flags, width and precision
+ * @private
+ */
+function sprintf(pattern, args) {
+  let next = 0;
+  return String(pattern).replace(/%([-0+ ]*)(\d+)?(?:\.(\d+))?([sdvqfx%])/g, (spec, flags, width, precision, verb) => {
+    if (verb === '%') return '%';
+    if (next >= args.length) return `%!${verb}(MISSING)`;
+    const arg = args[next++];
+
+    let text;
+    switch (verb) {
+      case 'd':
+        text = typeof arg === 'number' ? String(Math.trunc(arg)) : `%!d(${format(arg)})`;
+        break;
+      case 'f':
+        text = typeof arg === 'number' ? arg.toFixed(precision === undefined ? 6 : Number(precision)) : `%!f(${format(arg)})`;
+        break;
+      case 'x':
+        text = typeof arg === 'number' ? Math.trunc(arg).toString(16) : Buffer.from(format(arg)).toString('hex');
+        break;
+      case 'q':
+        text = JSON.stringify(format(arg));
+        break;
+      default:
+        text = format(arg);
+        if (verb === 's' && precision !== undefined) text = text.slice(0, Number(precision));
+    }
+    if (flags.includes('+') && verb === 'd' && arg >= 0) text = `+${text}`;
+
+    const padding = Math.max(0, Number(width || 0) - text.length);
+    if (flags.includes('-')) return text + ' '.repeat(padding);
+    return (flags.includes('0') && verb !== 's' ? '0' : ' ').repeat(padding) + text;
+  }) + (next < args.length ? `%!(EXTRA ${args.slice(next).map(format).join(', ')})` : '');
+}
+
+/**
+ * @private
+ */
+function typeName(value) {
+  if (value === null || value === undefined) return 'nil';
+  if (Array.isArray(value)) return 'list';
+  if (value instanceof Map) return 'map';
+  return typeof value;
+}
+
+/**
+ * @private
+ */
+function lineAt(text, offset) {
+  let line = 1;
+  for (let i = text.indexOf('\n'); i !== -1 && i < offset; i = text.indexOf('\n', i + 1)) line++;
+  return line;
+}
+
+/**
+ * @private
+ */
+function templateError(name, line, message) {
+  return new Error(`template: ${name}:${line}: ${message}`);
+}
+
+export default ContextTemplate;
@@ -0,0 +1,266 @@
+/**
+ * ContextWriter - Streaming output of generated context
+ * v3.4.0 - Bounded-memory output
+ *
+ * Responsibilities:
+ * - Write context piece by piece to a file or a stream (stdout) instead of
+ *   building it in memory first, so memory stays bounded by the largest piece
+ * - Coalesce small pieces (headers, separators) into buffered writes
+ * - Replace output files only once they are complete, optionally synced to
+ *   disk (fsync) and keeping the file they replace as FILE.bak (backup)
+ * - Stop producing pieces once a stream's reader has gone (e.g. `| head`)
+ * - Count the characters written for size reports
+ */
+
+import fs from 'fs';
+import path from 'path';
+
+// Suffix of the previous output kept by the backup option
+export const BACKUP_SUFFIX = '.bak';
+
+// Wait before retrying a write to a full non-blocking pipe
+const RETRY_DELAY_MS = 5;
+const RETRY_SIGNAL = new Int32Array(new SharedArrayBuffer(4));
+
+export class ContextWriter {
+  /**
+   * @param {Object} sink - { write(text), close(), abort(), gone() }
+   * @param {Object} options
+   */
+  constructor(sink, options = {}) {
+    this.options = {
+      bufferSize: 64 * 1024, // Characters held before they are written
+      ...options
+    };
+    this.sink = sink;
+    this.buffer = [];
+    this.buffered = 0;
+    this.length = 0;
+    this.last = '';
+    this.closed = false;
+  }
+
+  /**
+   * Writer to a file, written next to it and renamed into place by end()
+   * @param {string} outputPath
+   * @param {Object} options - Writer options, and fsync and backup as in writeFileAtomic()
+   * @returns {ContextWriter}
+   */
+  static toFile(outputPath, options = {}) {
+    const tempPath = tempPathFor(outputPath);
+    const fd = fs.openSync(tempPath, 'w');
+    return new ContextWriter({
+      write: text => writeFully(fd, Buffer.from(text, 'utf8')),
+      close: () => {
+        try {
+          if (options.fsync) fs.fsyncSync(fd);
+        } finally {
+          fs.closeSync(fd);
+        }
+        replaceFile(tempPath, outputPath, options);
+      },
+      abort: () => {
+        fs.closeSync(fd);
+        fs.rmSync(tempPath, { force: true });
+      },
+      gone: () => false
+    }, options);
+  }
+
+  /**
+   * Writer to a stream such as process.stdout; the stream is left open
+   * stream.write() queues what a slow reader has not taken yet, so text goes
+   * straight to the stream's file descriptor and waits for the reader
+   * instead. A replaced write (daemon capture, tests) receives the text.
+   * @param {Object} stream - Anything with write(text)
+   * @param {Object} options
+   * @returns {ContextWriter}
+   */
+  static toStream(stream, options = {}) {
+    const direct = typeof stream.fd === 'number' && !Object.hasOwn(stream, 'write');
+    let closed = false;
+
+    return new ContextWriter({
+      write: text => {
+        // Text the stream still queues goes first
+        if (!direct || stream.writableLength > 0) {
+          stream.write(text);
+          return;
+        }
+        try {
+          writeFully(stream.fd, Buffer.from(text, 'utf8'));
+        } catch (error) {
+          if (error.code !== 'EPIPE') throw error;
+          closed = true;
+        }
+      },
+      close: () => {},
+      abort: () => {},
+      // A closed pipe fails the next write and marks the stream errored
+      gone: () => closed || Boolean(stream.errored || stream.destroyed)
+    }, options);
+  }
+
+  /**
+   * @param {string} text
+   * @returns {ContextWriter}
+   */
+  write(text) {
+    if (!text) return this;
+    this.buffer.push(text);
+    this.buffered += text.length;
+    this.length += text.length;
+    this.last = text;
+    if (this.buffered >= this.options.bufferSize) {
+      this.flush();
+    }
+    return this;
+  }
+
+  /**
+   * Write every piece, dropping an unfinished file when producing a piece fails
+   * Stops early when the reader of a stream has gone.
+   * @param {Iterable<string>} pieces
+   * @returns {ContextWriter}
+   */
+  writeAll(pieces) {
+    try {
+      for (const piece of pieces) {
+        this.write(piece);
+        if (this.sink.gone()) break;
+      }
+    } catch (error) {
+      this.abort();
+      throw error;
+    }
+    return this;
+  }
+
+  /**
+   * @returns {boolean} Whether the written text ends with a newline
+   */
+  endsWithNewline() {
+    return this.last.endsWith('\n');
+  }
+
+  flush() {
+    if (this.buffer.length === 0) return;
+    const text = this.buffer.join('');
+    this.buffer = [];
+    this.buffered = 0;
+    this.sink.write(text);
+  }
+
+  /**
+   * Flush and close; a file appears at its path now
+   * @returns {number} Characters written
+   */
+  end() {
+    if (this.closed) return this.length;
+    try {
+      this.flush();
+    } catch (error) {
+      this.abort();
+      throw error;
+    }
+    this.closed = true;
+    this.sink.close();
+    return this.length;
+  }
+
+  /**
+   * @private
+   */
+  abort() {
+    if (this.closed) return;
+    this.closed = true;
+    this.buffer = [];
+    this.sink.abort();
+  }
+}
+
+/**
+ * Write a whole file next to its path and rename it into place, so an
+ * interrupted run leaves the previous file rather than a truncated one
+ * @param {string} outputPath
+ * @param {string|Buffer} content
+ * @param {Object} options - { fsync: sync to disk before the rename, backup: keep the replaced file as FILE.bak }
+ */
+export function writeFileAtomic(outputPath, content, options = {}) {
+  const tempPath = tempPathFor(outputPath);
+  try {
+    const fd = fs.openSync(tempPath, 'w');
+    try {
+      writeFully(fd, Buffer.isBuffer(content) ? content : Buffer.from(content, 'utf8'));
+      if (options.fsync) fs.fsyncSync(fd);
+    } finally {
+      fs.closeSync(fd);
+    }
+  } catch (error) {
+    fs.rmSync(tempPath, { force: true });
+    throw error;
+  }
+  replaceFile(tempPath, outputPath, options);
+}
+
+/**
+ * @private
+ */
+function tempPathFor(outputPath) {
+  return path.join(path.dirname(outputPath), `.${path.basename(outputPath)}.${process.pid}.tmp`);
+}
+
+/**
+ * Rename a complete temporary file over the output, keeping the output it
+ * replaces when asked; a hard link keeps a file at the output path throughout
+ * @private
+ */
+function replaceFile(tempPath, outputPath, options) {
+  if (options.backup && fs.existsSync(outputPath)) {
+    const backupPath = `${outputPath}${BACKUP_SUFFIX}`;
+    fs.rmSync(backupPath, { force: true });
+    try {
+      fs.linkSync(outputPath, backupPath);
+    } catch {
+      // File systems without hard links
+      fs.copyFileSync(outputPath, backupPath);
+    }
+  }
+  fs.renameSync(tempPath, outputPath);
+  if (options.fsync) syncDirectory(path.dirname(outputPath));
+}
+
+/**
+ * Make a rename durable; directories cannot be opened for syncing on Windows
+ * @private
+ */
+function syncDirectory(dir) {
+  let fd;
+  try {
+    fd = fs.openSync(dir, 'r');
+    fs.fsyncSync(fd);
+  } catch {
+    // Best effort
+  } finally {
+    if (fd !== undefined) fs.closeSync(fd);
+  }
+}
+
+/**
+ * fs.writeSync may write less than asked, and a non-blocking pipe may take
+ * nothing until its reader catches up
+ * @private
+ */
+function writeFully(fd, buffer) {
+  let offset = 0;
+  while (offset < buffer.length) {
+    try {
+      offset += fs.writeSync(fd, buffer, offset, buffer.length - offset);
+    } catch (error) {
+      if (error.code !== 'EAGAIN') throw error;
+      Atomics.wait(RETRY_SIGNAL, 0, 0, RETRY_DELAY_MS);
+    }
+  }
+}
+
+export default ContextWriter;
@@ -0,0 +1,279 @@
+/**
+ * CoverageHistory - What generated contexts leave out, across runs
+ * v3.4.0 - Coverage report (ctxman report coverage)
+ *
+ * Responsibilities:
+ * - Record, per run, which analyzed files were packed whole, partly or as
+ *   a summary and which were left out, and what .contextignore or the
+ *   profile excluded before analysis
+ * - Keep the counts of recent runs in .ctxman/cache/coverage.json
+ * - Report how often each directory makes it into a context, as a heat
+ *   map, and the files and excluded paths that never do
+ *
+ * Runs with a persistent content cache (--cache) are recorded. Files and
+ * paths not seen in the last maxRuns runs are forgotten, so deleted and
+ * renamed files drop out of the report.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import TokenUtils from '../utils/token-utils.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export const COVERAGE_FILE = path.join('.ctxman', 'cache', 'coverage.json');
+
+export const COVERAGE_STATUSES = ['full', 'partial', 'summary', 'excluded'];
+
+const COVERAGE_SCHEMA = 'ctxman-coverage/1';
+
+// Width of the heat bar at 100%
+const BAR_WIDTH = 10;
+
+// Longest directory column; longer paths are cut with …
+const MAX_NAME_WIDTH = 48;
+
+export class CoverageHistory {
+  /**
+   * @param {string} projectRoot
+   * @param {Object} options
+   */
+  constructor(projectRoot, options = {}) {
+    this.projectRoot = projectRoot;
+    this.options = {
+      maxRuns: 100, // Runs a file or path is remembered after it was last seen
+      ...options
+    };
+    this.total = 0;
+    this.runs = [];
+    this.files = new Map();
+    this.ignored = new Map();
+  }
+
+  /**
+   * @param {string} projectRoot
+   * @param {Object} [options]
+   * @returns {CoverageHistory} Empty when nothing has been recorded
+   * @throws {Error} For a file that is not a coverage history
+   */
+  static load(projectRoot, options = {}) {
+    const history = new CoverageHistory(projectRoot, options);
+    const filePath = history.filePath();
+    if (!fs.existsSync(filePath)) return history;
+
+    let state;
+    try {
+      state = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+    } catch (error) {
+      throw new Error(`Cannot read coverage history: ${error.message}`);
+    }
+    if (state.schema !== COVERAGE_SCHEMA) {
+      throw new Error(`${filePath} is not a ctxman coverage history (expected schema ${COVERAGE_SCHEMA})`);
+    }
+    history.total = state.total;
+    history.runs = state.runs;
+    history.files = new Map(Object.entries(state.files));
+    history.ignored = new Map(Object.entries(state.ignored));
+    return history;
+  }
+
+  /**
+   * Forget every run
+   * @param {string} projectRoot
+   * @returns {boolean} Whether a history existed
+   */
+  static remove(projectRoot) {
+    const filePath = path.join(projectRoot, COVERAGE_FILE);
+    if (!fs.existsSync(filePath)) return false;
+    fs.rmSync(filePath);
+    return true;
+  }
+
+  /**
+   * @returns {string} Absolute path of the history
+   */
+  filePath() {
+    return path.join(this.projectRoot, COVERAGE_FILE);
+  }
+
+  /**
+   * Record the packing decisions of a run
+   * @param {Object} run
+   * @param {Array<{path: string, tokens: number, status: string}>} run.files - Analyzed files, '/'-separated;
+   *   status is one of COVERAGE_STATUSES
+   * @param {Array<{path: string, files: number}>} [run.ignored] - Paths .contextignore or the profile excluded
+   * @param {string|null} [run.profile]
+   * @param {string} [run.mode] - Selection mode: all, focus, query, diff, symbols
+   * @param {Date} [at]
+   * @returns {number} The run
+   */
+  record({ files, ignored = [], profile = null, mode = 'all' }, at = new Date()) {
+    const run = ++this.total;
+    for (const file of files) {
+      const entry = this.files.get(file.path) || { seen: 0, full: 0, partial: 0, summary: 0, tokens: 0, lastSeen: 0, lastIncluded: null };
+      entry.seen++;
+      if (file.status !== 'excluded') {
+        entry[file.status]++;
+        entry.lastIncluded = run;
+      }
+      entry.tokens = file.tokens;
+      entry.lastSeen = run;
+      this.files.set(file.path, entry);
+    }
+    for (const item of ignored) {
+      const entry = this.ignored.get(item.path) || { seen: 0, files: 0, lastSeen: 0 };
+      entry.seen++;
+      entry.files = item.files;
+      entry.lastSeen = run;
+      this.ignored.set(item.path, entry);
+    }
+
+    this.runs.push({
+      run,
+      at: at.toISOString(),
+      profile,
+      mode,
+      files: files.length,
+      included: files.filter(file => file.status !== 'excluded').length
+    });
+    this.prune();
+    return run;
+  }
+
+  /**
+   * Write the history
+   * @returns {string} Path written
+   */
+  save() {
+    const filePath = this.filePath();
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, `${JSON.stringify(this, null, 2)}\n`);
+    return filePath;
+  }
+
+  toJSON() {
+    return {
+      schema: COVERAGE_SCHEMA,
+      total: this.total,
+      runs: this.runs,
+      files: Object.fromEntries(this.files),
+      ignored: Object.fromEntries(this.ignored)
+    };
+  }
+
+  /**
+   * Coverage per directory, and what is always left out
+   * @param {Object} [options]
+   * @param {number} [options.depth] - Directory levels grouped (deeper files count for their ancestor)
+   * @param {number} [options.minRuns] - Runs a file must have been seen in to count as never included
+   * @returns {{runs: number, since: string|null, directories: Array<Object>, neverIncluded: Array<Object>, ignored: Array<Object>}}
+   *   directories { path, files, seen, included, partial, never, tokens, share } in path order;
+   *   neverIncluded { path, seen, tokens } most seen first; ignored { path, seen, files }
+   */
+  report({ depth = 2, minRuns = 3 } = {}) {
+    const directories = new Map();
+    for (const [file, entry] of this.files) {
+      const dir = file.split('/').slice(0, -1).slice(0, depth).join('/') || '.';
+      const group = directories.get(dir) || { path: dir, files: 0, seen: 0, included: 0, partial: 0, never: 0, tokens: 0 };
+      const included = entry.full + entry.partial + entry.summary;
+      group.files++;
+      group.seen += entry.seen;
+      group.included += included;
+      group.partial += entry.partial + entry.summary;
+      group.tokens += entry.tokens;
+      if (included === 0) group.never++;
+      directories.set(dir, group);
+    }
+
+    const byPath = (a, b) => (a.path === '.' ? -1 : b.path === '.' ? 1 : compareBytes(a.path, b.path));
+    return {
+      runs: this.runs.length,
+      since: this.runs[0]?.at || null,
+      directories: [...directories.values()]
+        .map(group => ({ ...group, share: group.seen > 0 ? group.included / group.seen : 0 }))
+        .sort(byPath),
+      neverIncluded: [...this.files]
+        .filter(([, entry]) => entry.lastIncluded === null && entry.seen >= minRuns)
+        .map(([file, entry]) => ({ path: file, seen: entry.seen, tokens: entry.tokens }))
+        .sort((a, b) => b.seen - a.seen || b.tokens - a.tokens || compareBytes(a.path, b.path)),
+      ignored: [...this.ignored]
+        .filter(([, entry]) => entry.seen >= minRuns)
+        .map(([item, entry]) => ({ path: item, seen: entry.seen, files: entry.files }))
+        .sort((a, b) => b.files - a.files || compareBytes(a.path, b.path))
+    };
+  }
+
+  /**
+   * Console report
+   * @param {Object} report - Result of report()
+   * @param {Object} [options]
+   * @param {number} [options.limit] - Never-included files and ignored paths listed
+   * @param {number} [options.minRuns] - As given to report()
+   * @returns {string}
+   */
+  static format(report, { limit = 20, minRuns = 3 } = {}) {
+    if (report.runs === 0) {
+      return `No runs recorded in ${COVERAGE_FILE} yet (runs with --cache record what they pack)`;
+    }
+
+    const lines = [
+      '',
+      `🗺️  CONTEXT COVERAGE (${report.runs} ${report.runs === 1 ? 'run' : 'runs'} since ${report.since.slice(0, 10)}, share of runs each file was included in)`,
+      '='.repeat(80)
+    ];
+    const width = Math.min(MAX_NAME_WIDTH, Math.max(...report.directories.map(group => group.path.length + 1)));
+    for (const group of report.directories) {
+      const label = group.path === '.' ? './' : `${group.path}/`;
+      const notes = [
+        group.partial > 0 ? `${Math.round(group.partial / Math.max(group.included, 1) * 100)}% partial` : null,
+        group.never > 0 ? `${group.never} never included` : null
+      ].filter(Boolean);
+      lines.push(`${fit(label, width)}  ${bar(group.share)}  ${`${Math.round(group.share * 100)}%`.padStart(4)}  ` +
+        `${String(group.files).padStart(5)} ${group.files === 1 ? 'file ' : 'files'}  ${TokenUtils.format(group.tokens).padStart(7)}` +
+        `${notes.length > 0 ? `  ${notes.join(', ')}` : ''}`);
+    }
+
+    const listed = (title, items, render) => {
+      if (items.length === 0) return;
+      lines.push('', title);
+      items.slice(0, limit).forEach(item => lines.push(`   ${render(item)}`));
+      if (items.length > limit) lines.push(`   … ${items.length - limit} more`);
+    };
+    listed(`🕳️  Never included (analyzed in ${minRuns}+ runs):`, report.neverIncluded,
+      file => `${file.path} (${file.seen} ${file.seen === 1 ? 'run' : 'runs'}, ${TokenUtils.format(file.tokens)} tokens)`);
+    listed('🚫 Excluded by .contextignore or the profile before analysis:', report.ignored,
+      item => `${item.path} (${item.files} ${item.files === 1 ? 'file' : 'files'}, ${item.seen} ${item.seen === 1 ? 'run' : 'runs'})`);
+    return lines.join('\n');
+  }
+
+  /**
+   * Forget files and paths not seen in the last maxRuns runs
+   * @private
+   */
+  prune() {
+    const { maxRuns } = this.options;
+    this.runs = this.runs.slice(-maxRuns);
+    for (const entries of [this.files, this.ignored]) {
+      for (const [key, entry] of entries) {
+        if (entry.lastSeen <= this.total - maxRuns) entries.delete(key);
+      }
+    }
+  }
+}
+
+/**
+ * Heat bar
+ * @private
+ */
+function bar(share) {
+  const filled = Math.round(share * BAR_WIDTH);
+  return `${'█'.repeat(filled)}${'░'.repeat(BAR_WIDTH - filled)}`;
+}
+
+/**
+ * @private
+ */
+function fit(label, width) {
+  return label.length > width ? `${label.slice(0, width - 1)}…` : label.padEnd(width);
+}
+
+export default CoverageHistory;
@@ -0,0 +1,274 @@
+/**
+ * DecisionLog - Why each file of the last run was or was not included
+ * v3.4.0 - Exclusion explainer (ctxman explain FILE)
+ *
+ * Responsibilities:
+ * - Collect, per path, the decision that settled it: the ignore rule that
+ *   matched (with its file and line), a scan or content skip, the selection
+ *   mode, a transform that took the file out, or the token budget with the
+ *   file's priority and the tokens left when it was visited
+ * - Keep the run's budget: limit, tokens used, where it ran out and the
+ *   lowest priority packed whole
+ * - Write the log to .ctxman/decisions.json and explain a path from it
+ *
+ * Each run replaces the log. Ignored directories are recorded once; the
+ * files below them are explained by the directory's entry.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { compareBytes } from '../utils/ordering.js';
+
+export const DECISION_FILE = path.join('.ctxman', 'decisions.json');
+
+export const DECISION_OUTCOMES = ['included', 'partial', 'summarized', 'excluded'];
+
+const DECISION_SCHEMA = 'ctxman-decisions/1';
+
+const IGNORE_SOURCES = {
+  gitignore: '.gitignore',
+  context: '.contextignore',
+  'context-include': '.contextinclude',
+  profile: 'profile',
+  'directory-config': '.context.yaml'
+};
+
+export class DecisionLog {
+  constructor() {
+    this.run = null;
+    this.files = new Map();
+  }
+
+  /**
+   * @param {string} filePath
+   * @returns {DecisionLog|null} null when no run has been logged
+   * @throws {Error} For a file that is not a decision log
+   */
+  static load(filePath) {
+    if (!fs.existsSync(filePath)) return null;
+
+    let state;
+    try {
+      state = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+    } catch (error) {
+      throw new Error(`Cannot read decision log: ${error.message}`);
+    }
+    if (state.schema !== DECISION_SCHEMA) {
+      throw new Error(`${filePath} is not a ctxman decision log (expected schema ${DECISION_SCHEMA})`);
+    }
+    const log = new DecisionLog();
+    log.run = state.run;
+    log.files = new Map(Object.entries(state.files));
+    return log;
+  }
+
+  /**
+   * Record the decision about a path; a later decision replaces an earlier one
+   * @param {string} file - '/'-separated path relative to the project root
+   * @param {Object} decision - { outcome, stage, reason, ... }; outcome is one of
+   *   DECISION_OUTCOMES, stage ignore, scan, analyze, select, transform or budget
+   */
+  record(file, decision) {
+    this.files.set(file, decision);
+  }
+
+  /**
+   * @param {string} file
+   * @returns {Object|undefined} The decision recorded for the path itself
+   */
+  get(file) {
+    return this.files.get(file);
+  }
+
+  /**
+   * Decision about a path, or about the ignored or skipped directory it is in
+   * @param {string} file - '/'-separated path relative to the project root
+   * @returns {Object|null} { path, ...decision, via? }; via is the directory decided
+   */
+  explain(file) {
+    const normalized = file.replace(/^\.\/+/, '').replace(/\/+$/, '');
+    if (this.files.has(normalized)) {
+      return { path: normalized, ...this.files.get(normalized) };
+    }
+    const parts = normalized.split('/');
+    for (let i = parts.length - 1; i > 0; i--) {
+      const dir = parts.slice(0, i).join('/');
+      const decision = this.files.get(dir);
+      if (decision?.directory) return { path: normalized, ...decision, via: dir };
+    }
+    return null;
+  }
+
+  /**
+   * Paths by outcome and by the reason they were excluded
+   * @returns {{outcomes: Object<string, number>, reasons: Array<{stage: string, reason: string, paths: number}>}}
+   */
+  summary() {
+    const outcomes = Object.fromEntries(DECISION_OUTCOMES.map(outcome => [outcome, 0]));
+    const reasons = new Map();
+    for (const decision of this.files.values()) {
+      outcomes[decision.outcome]++;
+      if (decision.outcome !== 'excluded') continue;
+      const key = `${decision.stage}\0${decision.reason}`;
+      const entry = reasons.get(key) || { stage: decision.stage, reason: decision.reason, paths: 0 };
+      entry.paths++;
+      reasons.set(key, entry);
+    }
+    return { outcomes, reasons: [...reasons.values()].sort((a, b) => b.paths - a.paths) };
+  }
+
+  /**
+   * Write the log
+   * @param {string} filePath
+   * @returns {string} Path written
+   */
+  save(filePath) {
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, `${JSON.stringify(this, null, 2)}\n`);
+    return filePath;
+  }
+
+  toJSON() {
+    const files = [...this.files].sort(([a], [b]) => compareBytes(a, b));
+    return { schema: DECISION_SCHEMA, run: this.run, files: Object.fromEntries(files) };
+  }
+
+  /**
+   * Console explanation of one path
+   * @param {Object} explanation - Result of explain()
+   * @param {Object} run - The log's run
+   * @returns {string}
+   */
+  static format(explanation, run) {
+    const lines = [`🔍 ${explanation.path}: ${headline(explanation)}`];
+    lines.push(...details(explanation, run).map(line => `   ${line}`));
+    lines.push(`   Run: ${formatRun(run)}`);
+    return lines.join('\n');
+  }
+
+  /**
+   * Console overview of the log
+   * @param {DecisionLog} log
+   * @returns {string}
+   */
+  static formatSummary(log) {
+    const { outcomes, reasons } = log.summary();
+    const lines = [
+      `🔍 Last run: ${formatRun(log.run)}`,
+      `   ${outcomes.included} included, ${outcomes.partial} partial, ${outcomes.summarized} summarized, ${outcomes.excluded} excluded`
+    ];
+    for (const { stage, reason, paths } of reasons) {
+      lines.push(`   ${String(paths).padStart(6)}  ${describeReason({ stage, reason }, log.run)}`);
+    }
+    lines.push('   ctxman explain FILE tells why one file was or was not included');
+    return lines.join('\n');
+  }
+}
+
+/**
+ * @private
+ */
+function headline(decision) {
+  if (decision.outcome === 'included') return 'included whole';
+  if (decision.outcome === 'partial') return `included in part (${decision.note || 'selected symbols'})`;
+  if (decision.outcome === 'summarized') return `included as a summary (${decision.note || decision.tier || 'summary'})`;
+  return `excluded: ${describeReason(decision)}`;
+}
+
+/**
+ * One-line reason of an exclusion
+ * @private
+ */
+function describeReason(decision, run = null) {
+  const { stage, reason } = decision;
+  if (stage === 'ignore') {
+    if (reason === 'context-include') return 'not matched by .contextinclude';
+    if (reason === 'profile' && decision.profile) return `excluded by profile ${decision.profile}`;
+    const source = reason === 'gitignore' && decision.rule?.source ? decision.rule.source : IGNORE_SOURCES[reason] || reason;
+    return `ignored by ${source} rules`;
+  }
+  switch (reason) {
+    case 'skipped-directory': return 'in a directory scans always skip';
+    case 'sparse': return 'outside the sparse-checkout definition (--sparse)';
+    case 'excluded-path': return 'an output of ctxman or the --anonymize mapping';
+    case 'not-text': return 'not a text file (by extension, shebang or modeline)';
+    case 'content': return decision.kind ? `classified as ${decision.kind} (--include-generated keeps it)` : 'classified as generated content';
+    case 'directive': return 'opted out with a ctx:ignore directive';
+    case 'error': return decision.message ? `could not be analyzed: ${decision.message}` : 'could not be analyzed';
+    case 'selection': return `not part of the ${decision.mode || run?.mode || 'file'} selection`;
+    case 'license-file': return 'license file, named in the licensing note instead (--licenses)';
+    case 'duplicate': return decision.of ? `near-duplicate of ${decision.of} (--dedupe)` : 'near-duplicate of another file (--dedupe)';
+    case 'session': return decision.turn ? `supplied unchanged in turn ${decision.turn} of session ${decision.session}` : 'supplied unchanged earlier in the session';
+    case 'budget': return 'did not fit the token budget';
+    default: return 'left out by the selection';
+  }
+}
+
+/**
+ * Lines under the headline: the rule, priority and budget figures
+ * @private
+ */
+function details(decision, run) {
+  const lines = [];
+  if (decision.via) {
+    lines.push(`Directory ${decision.via}/ was ${decision.stage === 'ignore' ? 'ignored' : 'skipped'}, so the scan did not enter it`);
+  }
+  if (decision.rule) {
+    const { pattern, source, line, path: matched } = decision.rule;
+    const where = source ? ` (${source}${line ? `:${line}` : ''})` : '';
+    const target = decision.via || decision.path;
+    lines.push(`Rule: ${pattern}${where}${matched && matched !== target ? `, matched ${matched}` : ''}`);
+  }
+  if (decision.mode && decision.target) {
+    lines.push(`Selection: ${decision.mode} ${decision.target}`);
+  }
+  if (decision.tokens !== undefined) {
+    const analyzed = decision.analyzedTokens !== undefined && decision.analyzedTokens !== decision.tokens
+      ? ` (${decision.analyzedTokens.toLocaleString()} analyzed)` : '';
+    lines.push(`Tokens: ${decision.tokens.toLocaleString()}${analyzed}`);
+  }
+  if (decision.priority !== undefined && decision.priority !== null) {
+    const signals = decision.signals
+      ? ` (${Object.entries(decision.signals).map(([name, points]) => `${name} ${points.toFixed(2)}`).join(', ')})`
+      : '';
+    lines.push(`Priority: ${formatPriority(decision.priority)}${signals}`);
+  }
+
+  const budget = run?.budget;
+  if (budget && decision.stage === 'budget' && decision.outcome !== 'included') {
+    lines.push(`Budget: ${budget.usedTokens.toLocaleString()} of ${budget.limit.toLocaleString()} tokens used (${budget.tokenizer})`);
+    if (decision.available !== undefined) {
+      lines.push(`Visited with ${decision.available.toLocaleString()} tokens left; it needed ${decision.tokens.toLocaleString()}`);
+    }
+    if (budget.exhaustedAt) {
+      const { id, priority, available } = budget.exhaustedAt;
+      const self = id === decision.path ? ' (this file)' : '';
+      lines.push(`Budget ran out at ${id}${self}, priority ${formatPriority(priority)}, with ${available.toLocaleString()} tokens left`);
+    }
+    if (budget.cutoff !== null && budget.cutoff !== undefined) {
+      const below = decision.priority < budget.cutoff ? 'below' : 'at or above';
+      lines.push(`Cutoff: ${formatPriority(budget.cutoff)}, the lowest priority packed whole; this file ranks ${below} it`);
+    }
+  }
+  return lines;
+}
+
+/**
+ * @private
+ */
+function formatPriority(priority) {
+  return Number.isInteger(priority) ? String(priority) : priority.toFixed(2);
+}
+
+/**
+ * @private
+ */
+function formatRun(run) {
+  if (!run) return 'unknown';
+  const parts = [run.at, `mode ${run.mode}${run.target ? ` (${run.target})` : ''}`];
+  if (run.profile) parts.push(`profile ${run.profile}`);
+  if (run.transforms) parts.push(`transforms ${run.transforms}`);
+  return parts.join(', ');
+}
+
+export default DecisionLog;
@@ -0,0 +1,561 @@
+/**
+ * DependencySummary - Dependencies declared by a project's manifests
+ * v3.4.0 - Dependency manifest summaries (--deps)
+ *
+ * Responsibilities:
+ * - Read go.mod (with go.sum), package.json (with package-lock.json or
+ *   yarn.lock), Cargo.toml (with Cargo.lock) and requirements.txt files
+ * - List the direct dependencies with their declared and locked versions
+ * - Count what else the lockfile resolves, and name the notable pins:
+ *   replace, exclude, overrides and resolutions, [patch] sections,
+ *   packages locked at several versions and pip-compile's transitive pins
+ * - Render them as a section after the files of a digest
+ *
+ * Lockfiles are left out of the context as generated files; their
+ * versions reach it through this summary. A manifest that cannot be
+ * parsed is listed with its error instead of ending the run.
+ */
+
+import path from 'path';
+import { compareBytes } from '../utils/ordering.js';
+
+export const MANIFEST_FILES = ['go.mod', 'package.json', 'Cargo.toml', 'requirements.txt'];
+export const DEPENDENCIES_TITLE = 'DEPENDENCIES';
+
+// requirements-dev.txt, requirements/base.txt ...
+const REQUIREMENTS = /(^|\/)(requirements[\w.-]*\.txt|requirements\/[\w.-]+\.txt)$/i;
+
+const NPM_SCOPES = [
+  ['dependencies', 'runtime'],
+  ['devDependencies', 'dev'],
+  ['peerDependencies', 'peer'],
+  ['optionalDependencies', 'optional']
+];
+const CARGO_SCOPES = { dependencies: 'runtime', 'dev-dependencies': 'dev', 'build-dependencies': 'build' };
+
+export class DependencySummary {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      limit: 40, // Dependencies and pins listed per manifest
+      ...options
+    };
+  }
+
+  /**
+   * Whether a project file is a manifest this summary reads
+   * @param {string} relativePath - '/'-separated
+   * @returns {boolean}
+   */
+  static isManifest(relativePath) {
+    return MANIFEST_FILES.includes(path.posix.basename(relativePath)) || REQUIREMENTS.test(relativePath);
+  }
+
+  /**
+   * Summaries of manifests
+   * @param {Array<string>} manifests - '/'-separated paths of manifests, in output order
+   * @param {Function} read - (relativePath) => content; throws when the file is missing
+   * @returns {Array<{manifest: string, ecosystem: string, name: string|null, version: string|null,
+   *   runtime: string|null, lockfile: string|null, dependencies: Array<{name: string, version: string,
+   *   locked: string|null, scope: string}>, transitive: number|null, pins: Array<{name: string,
+   *   version: string, kind: string}>, error: string|null}>}
+   */
+  summarize(manifests, read) {
+    const attempt = file => {
+      try {
+        return read(file);
+      } catch {
+        return null;
+      }
+    };
+
+    return manifests.map(manifest => {
+      const dir = path.posix.dirname(manifest);
+      const sibling = name => (dir === '.' ? name : `${dir}/${name}`);
+      const entry = {
+        manifest, ecosystem: null, name: null, version: null, runtime: null, lockfile: null,
+        dependencies: [], transitive: null, pins: [], error: null
+      };
+      try {
+        const content = read(manifest);
+        switch (path.posix.basename(manifest)) {
+          case 'go.mod':
+            parseGoMod(entry, content, sibling, attempt);
+            break;
+          case 'package.json':
+            parsePackageJson(entry, content, sibling, attempt);
+            break;
+          case 'Cargo.toml':
+            parseCargo(entry, content, sibling, attempt);
+            break;
+          default:
+            parseRequirements(entry, content);
+        }
+      } catch (error) {
+        entry.error = error.message;
+      }
+      return entry;
+    });
+  }
+
+  /**
+   * Digest section
+   * @param {Array<Object>} entries - Result of summarize()
+   * @returns {string}
+   */
+  format(entries) {
+    const lines = ['', '='.repeat(48), DEPENDENCIES_TITLE, '='.repeat(48)];
+    const { limit } = this.options;
+    const capped = (items, render) => {
+      items.slice(0, limit).forEach(item => lines.push(render(item)));
+      if (items.length > limit) lines.push(`  … ${items.length - limit} more`);
+    };
+
+    for (const entry of entries) {
+      if (entry.error) {
+        lines.push(`${entry.manifest}: not read (${entry.error})`, '');
+        continue;
+      }
+      const about = [
+        entry.name ? `${entry.name}${entry.version ? `@${entry.version}` : ''}` : null,
+        entry.runtime,
+        entry.lockfile ? `locked in ${entry.lockfile}` : null
+      ].filter(Boolean);
+      lines.push(`${entry.manifest}${about.length > 0 ? ` — ${about.join(', ')}` : ''}`);
+
+      capped(entry.dependencies, dependency => {
+        const scope = dependency.scope === 'runtime' ? '' : `[${dependency.scope}] `;
+        const locked = dependency.locked && dependency.locked !== dependency.version ? ` (locked ${dependency.locked})` : '';
+        return `  ${scope}${dependency.name} ${dependency.version}${locked}`;
+      });
+      if (entry.dependencies.length === 0) lines.push('  No direct dependencies');
+      if (entry.transitive !== null) lines.push(`  + ${entry.transitive} transitive`);
+      if (entry.pins.length > 0) {
+        lines.push('  Pins:');
+        capped(entry.pins, pin => `  ${pin.kind} ${pin.name} ${pin.version}`);
+      }
+      lines.push('');
+    }
+    if (entries.length === 0) {
+      lines.push(`No ${MANIFEST_FILES.join(', ')} files`);
+    }
+    return `${lines.join('\n').replace(/\n+$/, '')}\n`;
+  }
+
+  /**
+   * Report line, e.g. "📦 Dependencies: 3 manifests, 42 direct, 5 pins"
+   * @param {Array<Object>} entries - Result of summarize()
+   * @returns {string}
+   */
+  describe(entries) {
+    const count = key => entries.reduce((sum, entry) => sum + entry[key].length, 0);
+    const failed = entries.filter(entry => entry.error).length;
+    return `📦 Dependencies: ${entries.length} ${entries.length === 1 ? 'manifest' : 'manifests'}, ` +
+      `${count('dependencies')} direct, ${count('pins')} ${count('pins') === 1 ? 'pin' : 'pins'}` +
+      (failed > 0 ? `, ${failed} not read` : '');
+  }
+}
+
+/**
+ * go.mod requires, replace and exclude directives; go.sum counts the rest
+ * @private
+ */
+function parseGoMod(entry, content, sibling, read) {
+  entry.ecosystem = 'go';
+  let indirect = 0;
+  let block = null;
+
+  const directive = (verb, args, note) => {
+    const words = args.replace(/"/g, '').split(/\s+/).filter(Boolean);
+    if (verb === 'module') entry.name = words[0] || null;
+    if (verb === 'go') entry.runtime = `go ${words[0]}`;
+    if (verb === 'toolchain' && words[0]) entry.runtime = `${entry.runtime ? `${entry.runtime}, ` : ''}toolchain ${words[0]}`;
+    if (verb === 'require' && words.length >= 2) {
+      if (/\bindirect\b/.test(note)) {
+        indirect++;
+      } else {
+        entry.dependencies.push({ name: words[0], version: words[1], locked: null, scope: 'runtime' });
+      }
+    }
+    if (verb === 'replace') {
+      const arrow = words.indexOf('=>');
+      if (arrow > 0) entry.pins.push({ name: words.slice(0, arrow).join(' '), version: `=> ${words.slice(arrow + 1).join(' ')}`, kind: 'replace' });
+    }
+    if (verb === 'exclude' && words.length >= 2) {
+      entry.pins.push({ name: words[0], version: words[1], kind: 'exclude' });
+    }
+  };
+
+  for (const raw of content.split('\n')) {
+    const comment = raw.indexOf('//');
+    const note = comment >= 0 ? raw.slice(comment + 2) : '';
+    const line = (comment >= 0 ? raw.slice(0, comment) : raw).trim();
+    if (block) {
+      if (line === ')') block = null;
+      else if (line) directive(block, line, note);
+      continue;
+    }
+    const match = /^(module|go|toolchain|require|replace|exclude)\b\s*(\(?)\s*(.*)$/.exec(line);
+    if (!match) continue;
+    if (match[2]) block = match[1];
+    else directive(match[1], match[3], note);
+  }
+
+  const sum = read(sibling('go.sum'));
+  if (sum !== null) {
+    entry.lockfile = sibling('go.sum');
+    const modules = new Set(sum.split('\n').map(line => line.trim().split(/\s+/)[0]).filter(Boolean));
+    const direct = entry.dependencies.filter(dependency => modules.has(dependency.name)).length;
+    entry.transitive = modules.size - direct;
+  } else if (indirect > 0) {
+    entry.transitive = indirect;
+  }
+}
+
+/**
+ * package.json dependency groups, overrides and resolutions; versions and
+ * duplicates from package-lock.json or yarn.lock
+ * @private
+ */
+function parsePackageJson(entry, content, sibling, read) {
+  entry.ecosystem = 'npm';
+  const data = JSON.parse(content);
+  entry.name = typeof data.name === 'string' ? data.name : null;
+  entry.version = typeof data.version === 'string' ? data.version : null;
+  if (data.engines?.node) entry.runtime = `node ${data.engines.node}`;
+
+  for (const [field, scope] of NPM_SCOPES) {
+    for (const [name, version] of Object.entries(data[field] || {})) {
+      entry.dependencies.push({ name, version: String(version), locked: null, scope });
+    }
+  }
+  for (const [name, version] of flattenOverrides(data.overrides)) {
+    entry.pins.push({ name, version, kind: 'override' });
+  }
+  for (const [name, version] of flattenOverrides(data.pnpm?.overrides)) {
+    entry.pins.push({ name, version, kind: 'override' });
+  }
+  for (const [name, version] of Object.entries(data.resolutions || {})) {
+    entry.pins.push({ name, version: String(version), kind: 'resolution' });
+  }
+
+  // name -> locked versions; spec (name@range) -> version for yarn.lock
+  let locked = null;
+  let specs = new Map();
+  const npmLock = read(sibling('package-lock.json'));
+  const yarnLock = npmLock === null ? read(sibling('yarn.lock')) : null;
+  if (npmLock !== null) {
+    entry.lockfile = sibling('package-lock.json');
+    locked = packageLockVersions(JSON.parse(npmLock));
+  } else if (yarnLock !== null) {
+    entry.lockfile = sibling('yarn.lock');
+    ({ locked, specs } = yarnLockVersions(yarnLock));
+  }
+  if (!locked) return;
+
+  const direct = new Set();
+  for (const dependency of entry.dependencies) {
+    const versions = locked.get(dependency.name);
+    if (!versions) continue;
+    direct.add(dependency.name);
+    dependency.locked = specs.get(`${dependency.name}@${dependency.version}`) ??
+      specs.get(`${dependency.name}@npm:${dependency.version}`) ??
+      (versions.hoisted || [...versions.all][0]);
+  }
+  entry.transitive = [...locked.keys()].filter(name => !direct.has(name)).length;
+  addDuplicates(entry, locked);
+}
+
+/**
+ * Cargo.toml dependency tables, [patch] and [replace]; Cargo.lock versions
+ * @private
+ */
+function parseCargo(entry, content, sibling, read) {
+  entry.ecosystem = 'cargo';
+  let section = '';
+  let table = null; // [dependencies.NAME] being read
+  const packages = new Map(); // Renamed dependencies (package = "...") -> crate
+
+  const scopeOf = name => {
+    if (/^workspace\.dependencies$/.test(name)) return 'workspace';
+    const match = /(?:^|\.)(dependencies|dev-dependencies|build-dependencies)$/.exec(name);
+    return match ? CARGO_SCOPES[match[1]] : null;
+  };
+  const add = (name, fields, scope) => {
+    const version = cargoVersion(fields);
+    if (/^patch\./.test(section) || section === 'replace') {
+      entry.pins.push({ name, version, kind: section === 'replace' ? 'replace' : 'patch' });
+      return;
+    }
+    entry.dependencies.push({ name, version, locked: null, scope });
+    if (fields.package) packages.set(name, fields.package);
+  };
+
+  const flush = () => {
+    if (table) add(table.name, table.fields, table.scope);
+    table = null;
+  };
+
+  for (const raw of content.split('\n')) {
+    const line = stripTomlComment(raw).trim();
+    if (!line) continue;
+    const header = /^\[\[?\s*([^\]]+?)\s*\]\]?$/.exec(line);
+    if (header) {
+      flush();
+      section = header[1].replace(/\s+/g, '');
+      const tableForm = /^(.*(?:dependencies|dev-dependencies|build-dependencies))\.([\w-]+|"[^"]+")$/.exec(section);
+      if (tableForm && scopeOf(tableForm[1])) {
+        table = { name: unquote(tableForm[2]), fields: {}, scope: scopeOf(tableForm[1]) };
+      }
+      continue;
+    }
+
+    const pair = /^("[^"]+"|'[^']+'|[\w.-]+)\s*=\s*(.+)$/.exec(line);
+    if (!pair) continue;
+    const key = unquote(pair[1]);
+    const value = pair[2].trim();
+    if (table) {
+      table.fields[key] = value.startsWith('{') ? value : unquote(value);
+      continue;
+    }
+    if (section === 'package' && (key === 'name' || key === 'version')) {
+      entry[key] = unquote(value);
+    } else if (section === 'package' && key === 'rust-version') {
+      entry.runtime = `rust ${unquote(value)}`;
+    } else if (scopeOf(section) || /^patch\./.test(section) || section === 'replace') {
+      add(key, value.startsWith('{') ? inlineTable(value) : { version: unquote(value) }, scopeOf(section) || 'runtime');
+    }
+  }
+  flush();
+
+  const lock = read(sibling('Cargo.lock'));
+  if (lock === null) return;
+  entry.lockfile = sibling('Cargo.lock');
+  const locked = new Map();
+  let current = null;
+  const record = () => {
+    if (current?.name && current.version && current.source) {
+      if (!locked.has(current.name)) locked.set(current.name, { all: new Set(), hoisted: null });
+      locked.get(current.name).all.add(current.version);
+    }
+  };
+  for (const line of lock.split('\n')) {
+    if (line.trim() === '[[package]]') {
+      record();
+      current = {};
+      continue;
+    }
+    const pair = /^(name|version|source)\s*=\s*"([^"]*)"/.exec(line.trim());
+    if (pair && current) current[pair[1]] = pair[2];
+  }
+  record();
+
+  const direct = new Set();
+  for (const dependency of entry.dependencies) {
+    const lockedName = packages.get(dependency.name) || dependency.name;
+    const versions = locked.get(lockedName);
+    if (!versions) continue;
+    direct.add(lockedName);
+    if (versions.all.size === 1) dependency.locked = [...versions.all][0];
+  }
+  entry.transitive = [...locked.keys()].filter(name => !direct.has(name)).length;
+  addDuplicates(entry, locked);
+}
+
+/**
+ * requirements.txt; pip-compile's "# via" comments tell direct
+ * requirements (via -r FILE) from transitive pins
+ * @private
+ */
+function parseRequirements(entry, content) {
+  entry.ecosystem = 'pip';
+  const requirements = [];
+  let last = null;
+  let inVia = false;
+
+  for (const raw of content.replace(/\\\r?\n/g, ' ').split('\n')) {
+    const via = /^\s*#\s*via\b\s*(.*)$/.exec(raw);
+    if (via) {
+      inVia = Boolean(last);
+      if (last && via[1].trim()) last.via.push(via[1].trim());
+      continue;
+    }
+    const continued = /^\s*#\s+(\S.*)$/.exec(raw);
+    if (continued && inVia) {
+      last.via.push(continued[1].trim());
+      continue;
+    }
+    inVia = false;
+
+    const line = raw.replace(/(^|\s)#.*$/, '').replace(/\s--hash=\S+/g, '').trim();
+    if (!line) continue;
+    const editable = /^(?:-e|--editable)\s+(.+)$/.exec(line);
+    if (editable) {
+      const egg = /#egg=([\w.-]+)/.exec(raw);
+      last = { name: egg ? egg[1] : editable[1], version: 'editable', via: [] };
+      requirements.push(last);
+      continue;
+    }
+    if (line.startsWith('-')) continue; // -r, -c, --index-url ...
+
+    const match = /^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*([^;]*?)\s*(?:;.*)?$/.exec(line);
+    if (!match) continue;
+    last = { name: match[1], version: match[3].trim() || '*', via: [] };
+    requirements.push(last);
+  }
+
+  const compiled = requirements.some(requirement => requirement.via.length > 0);
+  let transitive = 0;
+  for (const requirement of requirements) {
+    const direct = !compiled || requirement.via.length === 0 || requirement.via.some(source => /^-r\s/.test(source));
+    if (direct) {
+      entry.dependencies.push({ name: requirement.name, version: requirement.version, locked: null, scope: 'runtime' });
+    } else {
+      transitive++;
+      entry.pins.push({ name: requirement.name, version: `${requirement.version} (via ${requirement.via.join(', ')})`, kind: 'transitive' });
+    }
+  }
+  if (compiled) entry.transitive = transitive;
+}
+
+/**
+ * Locked versions of package-lock.json (v1 nested dependencies, v2/v3 packages)
+ * @private
+ */
+function packageLockVersions(lock) {
+  const locked = new Map();
+  const add = (name, version, hoisted) => {
+    if (!name || !version) return;
+    if (!locked.has(name)) locked.set(name, { all: new Set(), hoisted: null });
+    const versions = locked.get(name);
+    versions.all.add(version);
+    if (hoisted) versions.hoisted = version;
+  };
+
+  if (lock.packages) {
+    for (const [key, info] of Object.entries(lock.packages)) {
+      const at = key.lastIndexOf('node_modules/');
+      if (at < 0 || info.link) continue;
+      add(key.slice(at + 'node_modules/'.length), info.version, at === 0);
+    }
+    return locked;
+  }
+  const walk = (dependencies, depth) => {
+    for (const [name, info] of Object.entries(dependencies || {})) {
+      add(name, info.version, depth === 0);
+      walk(info.dependencies, depth + 1);
+    }
+  };
+  walk(lock.dependencies, 0);
+  return locked;
+}
+
+/**
+ * Locked versions of yarn.lock (classic and berry)
+ * @private
+ */
+function yarnLockVersions(content) {
+  const locked = new Map();
+  const specs = new Map();
+  let current = [];
+
+  for (const line of content.split('\n')) {
+    if (/^\S.*:$/.test(line) && !line.startsWith('#') && !line.startsWith('__metadata')) {
+      current = line.slice(0, -1).split(/,\s*/).map(unquote);
+      continue;
+    }
+    const version = /^\s+version:?\s+"?([^"\s]+)"?/.exec(line);
+    if (!version || current.length === 0) continue;
+    for (const spec of current) {
+      const name = spec.slice(0, spec.lastIndexOf('@'));
+      if (!name) continue;
+      specs.set(spec, version[1]);
+      if (!locked.has(name)) locked.set(name, { all: new Set(), hoisted: null });
+      locked.get(name).all.add(version[1]);
+    }
+    current = [];
+  }
+  return { locked, specs };
+}
+
+/**
+ * Pin packages locked at more than one version
+ * @private
+ */
+function addDuplicates(entry, locked) {
+  for (const [name, versions] of [...locked].sort(([a], [b]) => compareBytes(a, b))) {
+    if (versions.all.size > 1) {
+      entry.pins.push({ name, version: [...versions.all].sort().join(', '), kind: 'duplicate' });
+    }
+  }
+}
+
+/**
+ * npm and pnpm overrides, nested ones as "parent > child"
+ * @private
+ */
+function flattenOverrides(overrides, prefix = '') {
+  if (!overrides || typeof overrides !== 'object') return [];
+  return Object.entries(overrides).flatMap(([name, value]) => {
+    const key = name === '.' ? prefix : prefix ? `${prefix} > ${name}` : name;
+    return typeof value === 'object' && value !== null
+      ? flattenOverrides(value, key)
+      : [[key, String(value)]];
+  });
+}
+
+/**
+ * Version of a Cargo dependency: version, git source, path or workspace
+ * @private
+ */
+function cargoVersion(fields) {
+  if (fields.workspace === 'true') return 'workspace';
+  if (fields.git) {
+    const ref = fields.rev || fields.tag || fields.branch;
+    return `git ${fields.git}${ref ? `#${ref}` : ''}`;
+  }
+  if (fields.version) return fields.version;
+  if (fields.path) return `path ${fields.path}`;
+  return '*';
+}
+
+/**
+ * Fields of a one-line TOML inline table
+ * @private
+ */
+function inlineTable(value) {
+  const fields = {};
+  for (const match of value.matchAll(/([\w-]+)\s*=\s*("[^"]*"|'[^']*'|true|false)/g)) {
+    fields[match[1]] = unquote(match[2]);
+  }
+  return fields;
+}
+
+/**
+ * @private
+ */
+function stripTomlComment(line) {
+  let quote = null;
+  for (let i = 0; i < line.length; i++) {
+    const char = line[i];
+    if (quote) {
+      if (char === quote && line[i - 1] !== '\\') quote = null;
+    } else if (char === '"' || char === "'") {
+      quote = char;
+    } else if (char === '#') {
+      return line.slice(0, i);
+    }
+  }
+  return line;
+}
+
+/**
+ * @private
+ */
+function unquote(value) {
+  return value.trim().replace(/^(["'])(.*)\1$/, '$2');
+}
+
+export default DependencySummary;
@@ -0,0 +1,188 @@
+/**
+ * DirectoryConfig - Settings of nested .context.yaml files
+ * v3.4.0 - Per-directory configuration
+ *
+ * Responsibilities:
+ * - Load the .context.yaml of each directory the scan visits
+ * - Validate its settings: ignore patterns, priority rules and budget tiers
+ * - Resolve what applies to a path from the files of the directories above
+ *   it, deeper files overriding their parents
+ *
+ * Nesting works like .gitignore: patterns and globs are relative to the
+ * directory of their file, ignore patterns of all files above a path apply
+ * in order (last match wins, so `!pattern` re-includes what a parent
+ * ignored), priority rules of the nearest file are tried before its
+ * parents', and the nearest `tiers` wins. `inherit: false` starts a subtree
+ * over: the settings of the directories above it no longer apply.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import YAMLParser from '../parsers/yaml-parser.js';
+import GitIgnoreParser from '../parsers/gitignore-parser.js';
+import { priorityRules } from './ContextProfiles.js';
+import { parseTiers } from './TokenBudget.js';
+import { decodeText } from './FileSystem.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('DirectoryConfig');
+
+export const DIRECTORY_CONFIG_FILE = '.context.yaml';
+
+const CONFIG_KEYS = ['inherit', 'ignore', 'priority', 'tiers'];
+
+/**
+ * Invalid .context.yaml; ends a scan instead of skipping the directory
+ */
+export class DirectoryConfigError extends Error {}
+
+export class DirectoryConfig {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      root: null, // Project root; without one no files are loaded
+      ...options
+    };
+    this.configs = new Map(); // '/'-separated directory ('' for the root) -> settings or null
+  }
+
+  /**
+   * Settings of one .context.yaml
+   * @param {string} text - File content
+   * @param {string} dir - Directory of the file, '/'-separated relative to the root
+   * @param {string} [label] - File name for messages
+   * @returns {{dir: string, inherit: boolean, ignore: Array<Object>, priority: Array<Object>, tiers: Array<string>|null, text: string}}
+   * @throws {DirectoryConfigError} For invalid YAML, unknown keys and bad values
+   */
+  static parse(text, dir = '', label = path.posix.join(dir, DIRECTORY_CONFIG_FILE)) {
+    const fail = message => {
+      throw new DirectoryConfigError(`${label}: ${message}`);
+    };
+
+    let data;
+    try {
+      data = YAMLParser.parse(text) ?? {};
+    } catch (error) {
+      return fail(error.message);
+    }
+    if (data === null || typeof data !== 'object' || Array.isArray(data)) fail('expected a mapping');
+
+    const unknown = Object.keys(data).filter(key => !CONFIG_KEYS.includes(key));
+    if (unknown.length > 0) fail(`unknown keys: ${unknown.join(', ')} (allowed: ${CONFIG_KEYS.join(', ')})`);
+
+    if (data.inherit !== undefined && typeof data.inherit !== 'boolean') fail('inherit must be true or false');
+
+    const ignore = data.ignore === undefined || data.ignore === null ? [] : [data.ignore].flat();
+    if (!ignore.every(pattern => typeof pattern === 'string' && pattern.trim())) {
+      fail('ignore must be a list of patterns (.gitignore syntax)');
+    }
+
+    let tiers = null;
+    if (data.tiers !== undefined && data.tiers !== null) {
+      try {
+        tiers = parseTiers(data.tiers);
+      } catch (error) {
+        fail(error.message);
+      }
+    }
+
+    const parser = new GitIgnoreParser(null, null, null);
+    const rules = priorityRules(data.priority, message => fail(message)) || [];
+    return {
+      dir,
+      inherit: data.inherit !== false,
+      ignore: ignore.map(pattern => ({ ...parser.convertToRegex(pattern.trim(), dir), source: label })),
+      priority: rules.map(rule => ({ ...rule, regex: parser.convertToRegex(rule.pattern, dir).regex })),
+      tiers,
+      text
+    };
+  }
+
+  /**
+   * Ignore patterns that apply to a path, shallowest file first
+   * @param {string} relativePath - '/'-separated
+   * @returns {Array<Object>} GitIgnoreParser patterns
+   */
+  ignorePatterns(relativePath) {
+    return this.chain(relativePath).flatMap(config => config.ignore);
+  }
+
+  /**
+   * Priority of a path under the rules of the nearest file that has a matching one
+   * @param {string} relativePath - '/'-separated
+   * @returns {number|null}
+   */
+  priorityOf(relativePath) {
+    for (const config of this.chain(relativePath).reverse()) {
+      const rule = config.priority.find(candidate => candidate.regex.test(relativePath));
+      if (rule) return rule.priority;
+    }
+    return null;
+  }
+
+  /**
+   * Budget tiers of a path, from the nearest file that sets them
+   * @param {string} relativePath - '/'-separated
+   * @returns {Array<string>|null} null keeps the run's tiers
+   */
+  tiersOf(relativePath) {
+    return this.chain(relativePath).reverse().find(config => config.tiers)?.tiers ?? null;
+  }
+
+  /**
+   * Key of the files loaded for some paths, for caches of what they affect
+   * @param {Array<string>} relativePaths - '/'-separated
+   * @returns {string}
+   */
+  getKey(relativePaths = []) {
+    relativePaths.forEach(relativePath => this.chain(relativePath));
+    return JSON.stringify([...this.configs].filter(([, config]) => config).map(([dir, config]) => [dir, config.text]).sort());
+  }
+
+  /**
+   * Directories whose .context.yaml was loaded
+   * @returns {Array<string>} '/'-separated
+   */
+  directories() {
+    return [...this.configs].filter(([, config]) => config).map(([dir]) => dir).sort();
+  }
+
+  /**
+   * Settings of a directory's own .context.yaml, read once
+   * @param {string} dir - '/'-separated, '' for the root
+   * @returns {Object|null} Result of parse(), null without a file
+   * @throws {DirectoryConfigError} For invalid files
+   */
+  load(dir) {
+    if (!this.configs.has(dir)) {
+      const filePath = this.options.root ? path.join(this.options.root, dir, DIRECTORY_CONFIG_FILE) : null;
+      let config = null;
+      if (filePath && fs.existsSync(filePath)) {
+        config = DirectoryConfig.parse(decodeText(fs.readFileSync(filePath)), dir);
+        logger.debug(`Loaded ${filePath}`);
+      }
+      this.configs.set(dir, config);
+    }
+    return this.configs.get(dir);
+  }
+
+  /**
+   * Settings of the directories above a path that apply to it, shallowest first
+   * @private
+   */
+  chain(relativePath) {
+    const parts = relativePath.split('/').slice(0, -1);
+    const chain = [];
+    for (let i = 0; i <= parts.length; i++) {
+      const config = this.load(parts.slice(0, i).join('/'));
+      if (!config) continue;
+      if (!config.inherit) chain.length = 0;
+      chain.push(config);
+    }
+    return chain;
+  }
+}
+
+export default DirectoryConfig;
@@ -0,0 +1,239 @@
+/**
+ * DuplicateDetector - Near-duplicate files by content fingerprint
+ * v3.4.0 - Deduplication (--dedupe)
+ *
+ * Responsibilities:
+ * - Fingerprint files as MinHash signatures of normalized token shingles
+ *   (comments and whitespace removed, so reformatted copies still match)
+ * - Find candidate pairs with locality-sensitive hashing instead of
+ *   comparing every pair, then keep pairs above the similarity threshold
+ * - Group duplicates and pick one representative per group, preferring
+ *   hand-written files over vendored, generated and mock copies
+ */
+
+import ContentStripper from './ContentStripper.js';
+import { compareBytes } from '../utils/ordering.js';
+
+// Paths of copies that should not represent a group
+const SECONDARY_PATH = /(^|\/)(vendor|vendored|third[_-]?party|external|node_modules|generated|__generated__|gen|mocks?|__mocks__|fixtures?|dist|build)\//i;
+const SECONDARY_NAME = /[._-](generated|gen|pb|mock|copy|min)\.[^/]+$|(^|\/)mock_[^/]+$/i;
+const TOKEN_PATTERN = /[A-Za-z_$][\w$]*|\d[\w.]*|\S/g;
+
+export class DuplicateDetector {
+  constructor(options = {}) {
+    this.options = {
+      threshold: 0.8, // Minimum estimated Jaccard similarity of shingle sets
+      shingleSize: 5, // Tokens per shingle
+      bands: 32, // LSH bands; files sharing any band are compared
+      rows: 4, // Signature rows per band (signature length = bands * rows)
+      minTokens: 40, // Smaller files are too generic to collapse
+      ...options
+    };
+    if (!(this.options.threshold > 0 && this.options.threshold <= 1)) {
+      throw new Error(`Invalid duplicate threshold: ${this.options.threshold} (expected a similarity above 0, up to 1)`);
+    }
+    this.stripper = new ContentStripper({ mode: 'comments' });
+    this.seeds = Array.from({ length: this.options.bands * this.options.rows }, (_, i) => mix(0x9e3779b9 + i * 0x85ebca6b));
+  }
+
+  /**
+   * Group near-duplicate files
+   * @param {Array<{path: string, content: string, priority?: number}>} files
+   * @returns {Array<{representative: string, duplicates: Array<{path: string, similarity: number}>}>}
+   *   Groups with at least one duplicate, largest first
+   */
+  findDuplicates(files) {
+    const fingerprints = files
+      .map(file => ({ ...file, signature: this.signature(file.content, file.path) }))
+      .filter(file => file.signature);
+
+    const parent = fingerprints.map((_, i) => i);
+    const find = i => (parent[i] === i ? i : (parent[i] = find(parent[i])));
+
+    for (const [a, b] of this.candidatePairs(fingerprints)) {
+      if (similarity(fingerprints[a].signature, fingerprints[b].signature) >= this.options.threshold) {
+        parent[find(a)] = find(b);
+      }
+    }
+
+    const groups = new Map();
+    fingerprints.forEach((file, i) => {
+      const root = find(i);
+      if (!groups.has(root)) groups.set(root, []);
+      groups.get(root).push(file);
+    });
+
+    return [...groups.values()]
+      .filter(group => group.length > 1)
+      .map(group => {
+        const [representative, ...duplicates] = [...group].sort(compareRepresentatives);
+        return {
+          representative: representative.path,
+          duplicates: duplicates.map(file => ({
+            path: file.path,
+            similarity: similarity(representative.signature, file.signature)
+          }))
+        };
+      })
+      .sort((a, b) => b.duplicates.length - a.duplicates.length || compareBytes(a.representative, b.representative));
+  }
+
+  /**
+   * MinHash signature of a file's normalized token shingles
+   * @param {string} content
+   * @param {string} filePath - Picks the comment syntax
+   * @returns {Uint32Array|null} null when the file is below minTokens
+   */
+  signature(content, filePath) {
+    const tokens = DuplicateDetector.normalize(this.stripper.strip(content, filePath));
+    if (tokens.length < this.options.minTokens) return null;
+
+    const { shingleSize } = this.options;
+    const shingles = new Set();
+    for (let i = 0; i + shingleSize <= tokens.length; i++) {
+      shingles.add(hashString(tokens.slice(i, i + shingleSize).join(' ')));
+    }
+
+    const signature = new Uint32Array(this.seeds.length).fill(0xffffffff);
+    for (const shingle of shingles) {
+      for (let i = 0; i < this.seeds.length; i++) {
+        const value = mix(shingle ^ this.seeds[i]);
+        if (value < signature[i]) signature[i] = value;
+      }
+    }
+    return signature;
+  }
+
+  /**
+   * Tokens compared between files: identifiers and literals, case folded
+   * @param {string} content - Comments already removed
+   * @returns {Array<string>}
+   */
+  static normalize(content) {
+    return (content.match(TOKEN_PATTERN) || []).map(token => token.toLowerCase());
+  }
+
+  /**
+   * Vendored, generated, mock and build copies, which never represent a group
+   * @param {string} filePath
+   * @returns {boolean}
+   */
+  static isSecondary(filePath) {
+    return SECONDARY_PATH.test(filePath) || SECONDARY_NAME.test(filePath);
+  }
+
+  /**
+   * Index pairs of files that share at least one LSH band
+   * @private
+   */
+  candidatePairs(fingerprints) {
+    const { bands, rows } = this.options;
+    const pairs = new Map();
+    for (let band = 0; band < bands; band++) {
+      const buckets = new Map();
+      fingerprints.forEach((file, index) => {
+        const key = Array.from(file.signature.subarray(band * rows, (band + 1) * rows)).join(',');
+        if (!buckets.has(key)) buckets.set(key, []);
+        buckets.get(key).push(index);
+      });
+      for (const bucket of buckets.values()) {
+        for (let i = 0; i < bucket.length; i++) {
+          for (let j = i + 1; j < bucket.length; j++) {
+            pairs.set(`${bucket[i]}:${bucket[j]}`, [bucket[i], bucket[j]]);
+          }
+        }
+      }
+    }
+    return pairs.values();
+  }
+
+  /**
+   * Report of collapsed groups, printed before export
+   * @param {{groups: Array, savedTokens: number, threshold: number}} deduplication
+   * @returns {string}
+   */
+  static formatReport(deduplication) {
+    const { groups, savedTokens, threshold } = deduplication;
+    const collapsed = groups.reduce((sum, group) => sum + group.duplicates.length, 0);
+    const lines = [
+      '',
+      '🧬 DUPLICATES',
+      '='.repeat(80),
+      `Threshold: ${Math.round(threshold * 100)}% similar`,
+      `Collapsed: ${collapsed} files in ${groups.length} groups (${savedTokens.toLocaleString()} tokens saved)`
+    ];
+
+    for (const group of groups) {
+      lines.push(`   ${group.representative}`);
+      for (const duplicate of group.duplicates) {
+        lines.push(`     ≈ ${duplicate.path} (${formatSimilarity(duplicate.similarity)})`);
+      }
+    }
+
+    return lines.join('\n');
+  }
+
+  /**
+   * Note listing the copies a representative stands for
+   * @param {Array<{path: string, similarity: number}>} duplicates
+   * @returns {string}
+   */
+  static formatNote(duplicates) {
+    return `Near-duplicates omitted: ${duplicates.map(duplicate => `${duplicate.path} (${formatSimilarity(duplicate.similarity)})`).join(', ')}`;
+  }
+}
+
+/**
+ * Hand-written first, then higher priority, then the shortest and first path
+ * @private
+ */
+function compareRepresentatives(a, b) {
+  return Number(DuplicateDetector.isSecondary(a.path)) - Number(DuplicateDetector.isSecondary(b.path)) ||
+    (b.priority ?? 0) - (a.priority ?? 0) ||
+    a.path.length - b.path.length ||
+    compareBytes(a.path, b.path);
+}
+
+/**
+ * Fraction of equal MinHash rows, an estimate of Jaccard similarity
+ * @private
+ */
+function similarity(a, b) {
+  let equal = 0;
+  for (let i = 0; i < a.length; i++) {
+    if (a[i] === b[i]) equal++;
+  }
+  return equal / a.length;
+}
+
+/**
+ * @private
+ */
+function formatSimilarity(value) {
+  return `${Math.floor(value * 100)}%`;
+}
+
+/**
+ * FNV-1a over UTF-16 code units
+ * @private
+ */
+function hashString(text) {
+  let hash = 0x811c9dc5;
+  for (let i = 0; i < text.length; i++) {
+    hash = Math.imul(hash ^ text.charCodeAt(i), 0x01000193);
+  }
+  return hash >>> 0;
+}
+
+/**
+ * murmur3 finalizer: spreads every input bit over the output
+ * @private
+ */
+function mix(value) {
+  let hash = value >>> 0;
+  hash = Math.imul(hash ^ (hash >>> 16), 0x85ebca6b);
+  hash = Math.imul(hash ^ (hash >>> 13), 0xc2b2ae35);
+  return (hash ^ (hash >>> 16)) >>> 0;
+}
+
+export default DuplicateDetector;
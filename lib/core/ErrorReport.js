@@ -0,0 +1,127 @@
+/**
+ * ErrorReport - Files a run could not process
+ * v3.4.0 - Partial-failure mode (--error-report, --strict)
+ *
+ * Responsibilities:
+ * - Collect per-file failures with the stage they happened in: scanning
+ *   the tree, analyzing (reading and counting), parsing symbols or writing
+ *   the context
+ * - Fail fast instead when strict, where the first failure ends the run
+ * - Summarize failures for the console and write them as JSON
+ *
+ * A file that fails is left out of the stage it failed in and of what
+ * depends on it, and the run continues with the others. Each file and
+ * stage is reported once.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ErrorReport');
+
+export const ERROR_REPORT_FILE = path.join('.ctxman', 'errors.json');
+
+export const ERROR_STAGES = ['scan', 'analyze', 'symbols', 'export'];
+
+const REPORT_SCHEMA = 'ctxman-errors/1';
+
+/**
+ * Failure of a strict run
+ */
+export class FileError extends Error {
+  /**
+   * @param {Object} entry - Report entry { file, stage, message, code }
+   */
+  constructor(entry) {
+    super(`${entry.file}: ${entry.stage} failed: ${entry.message} (--strict stops at the first failure)`);
+    this.entry = entry;
+  }
+}
+
+export class ErrorReport {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      strict: false, // Throw FileError at the first failure
+      ...options
+    };
+    this.entries = [];
+    this.seen = new Set();
+  }
+
+  /**
+   * Record that a file failed
+   * @param {string} stage - One of ERROR_STAGES
+   * @param {string} file - '/'-separated path relative to the project root
+   * @param {Error|string} error
+   * @throws {FileError} When strict
+   */
+  add(stage, file, error) {
+    const entry = {
+      file,
+      stage,
+      message: typeof error === 'string' ? error : error.message,
+      code: (typeof error === 'object' && error.code) || null
+    };
+    if (this.options.strict) {
+      throw new FileError(entry);
+    }
+
+    const key = `${stage}\0${file}`;
+    if (this.seen.has(key)) return;
+    this.seen.add(key);
+    this.entries.push(entry);
+    logger.debug(`${file}: ${stage} failed: ${entry.message}`);
+  }
+
+  /**
+   * @returns {number} Failures recorded
+   */
+  get size() {
+    return this.entries.length;
+  }
+
+  /**
+   * Machine-readable report
+   * @returns {{schema: string, failed: number, stages: Object<string, number>, errors: Array<Object>}}
+   */
+  toJSON() {
+    const stages = {};
+    for (const entry of this.entries) {
+      stages[entry.stage] = (stages[entry.stage] || 0) + 1;
+    }
+    return { schema: REPORT_SCHEMA, failed: this.size, stages, errors: this.entries };
+  }
+
+  /**
+   * Write the report (the empty report too, so a stale one is not read as current)
+   * @param {string} filePath
+   * @returns {string} Path written
+   */
+  save(filePath) {
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, `${JSON.stringify(this, null, 2)}\n`);
+    return filePath;
+  }
+
+  /**
+   * Console summary
+   * @param {number} [limit] - Failures listed
+   * @returns {string}
+   */
+  format(limit = 10) {
+    const lines = [`⚠️  ${this.size} ${this.size === 1 ? 'file' : 'files'} failed and ${this.size === 1 ? 'was' : 'were'} left out (--strict stops instead):`];
+    for (const entry of this.entries.slice(0, limit)) {
+      lines.push(`   ${entry.file} (${entry.stage}): ${entry.message}`);
+    }
+    if (this.size > limit) {
+      lines.push(`   … ${this.size - limit} more`);
+    }
+    return lines.join('\n');
+  }
+}
+
+export default ErrorReport;
@@ -0,0 +1,81 @@
+/**
+ * FileList - Ad-hoc file lists from stdin or a file
+ * v3.4.0 - File lists (--files -|FILE)
+ *
+ * Responsibilities:
+ * - Read newline or NUL separated paths, as printed by git diff --name-only,
+ *   git ls-files -z, find -print0 or rg --files
+ * - Undo git's quoting of unusual paths ("caf\303\251.js")
+ * - Drop blank lines and repeated entries, keeping the first occurrence
+ */
+
+import fs from 'fs';
+import { decodeText } from './FileSystem.js';
+
+const ESCAPES = { a: '\x07', b: '\b', f: '\f', n: '\n', r: '\r', t: '\t', v: '\v', '"': '"', '\\': '\\' };
+
+export class FileList {
+  /**
+   * Read a list from stdin ('-') or a file
+   * @param {string} source - '-' or a path
+   * @returns {Array<string>} Listed paths, as given
+   */
+  static read(source) {
+    if (source === '-') {
+      if (process.stdin.isTTY) {
+        throw new Error('--files - reads the file list from stdin (for example: git diff --name-only | ctxman --files -)');
+      }
+      return FileList.parse(decodeText(fs.readFileSync(0)));
+    }
+    try {
+      return FileList.parse(decodeText(fs.readFileSync(source)));
+    } catch (error) {
+      throw new Error(`Cannot read file list ${source}: ${error.message}`);
+    }
+  }
+
+  /**
+   * @param {string} text - One path per line, or NUL separated
+   * @returns {Array<string>}
+   */
+  static parse(text) {
+    const separator = text.includes('\0') ? '\0' : /\r?\n/;
+    const entries = new Set();
+    for (const line of text.split(separator)) {
+      // NUL separated paths are never quoted and may have surrounding spaces
+      const entry = separator === '\0' ? line : unquote(line.trim());
+      if (entry) entries.add(entry);
+    }
+    return [...entries];
+  }
+}
+
+/**
+ * Path of a git core.quotePath line: C escapes and octal UTF-8 bytes
+ * @private
+ */
+function unquote(line) {
+  if (line.length < 2 || !line.startsWith('"') || !line.endsWith('"')) return line;
+
+  const bytes = [];
+  const body = line.slice(1, -1);
+  for (let i = 0; i < body.length; i++) {
+    if (body[i] !== '\\') {
+      const char = String.fromCodePoint(body.codePointAt(i));
+      bytes.push(...Buffer.from(char, 'utf8'));
+      i += char.length - 1;
+      continue;
+    }
+    const octal = /^[0-7]{3}/.exec(body.slice(i + 1));
+    if (octal) {
+      bytes.push(parseInt(octal[0], 8));
+      i += 3;
+    } else {
+      const next = body[++i];
+      bytes.push(...Buffer.from(ESCAPES[next] ?? next ?? '', 'utf8'));
+    }
+  }
+  return Buffer.from(bytes).toString('utf8');
+}
+
+export default FileList;
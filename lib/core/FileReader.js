@@ -0,0 +1,170 @@
+/**
+ * FileReader - File reading without a buffer per file
+ * v3.4.0 - Memory-mapped reading (--read-mode)
+ *
+ * Responsibilities:
+ * - Map large files into memory (with the optional mmap-io package), so
+ *   their bytes are read from the page cache instead of copied into a
+ *   new buffer
+ * - Read other files into one buffer that is reused for every file and
+ *   grows up to options.poolLimit
+ * - Decode with decodeText(), so the string is the only copy of a file
+ *   that outlives the read
+ * - Count the bytes of the buffers it allocates, for `ctxman bench`
+ *
+ * Modes: auto (map files of options.mmapThreshold bytes or more when
+ * mmap-io is installed, reuse the buffer for the rest), mmap (map every
+ * file), pooled (never map) and buffer (a new buffer per file, as
+ * fs.readFileSync).
+ */
+
+import fs from 'fs';
+import { decodeText } from './FileSystem.js';
+
+export const READ_MODES = ['auto', 'mmap', 'pooled', 'buffer'];
+
+// mmap-io is optional: without it auto mode reads every file into the pool
+let mmap = null;
+try {
+  const mmapModule = await import('mmap-io');
+  mmap = mmapModule.default || mmapModule;
+} catch (error) {
+  // mmap-io is optional
+}
+
+export class FileReader {
+  /**
+   * @param {Object} options
+   * @throws {Error} For an unknown mode, or mmap without mmap-io
+   */
+  constructor(options = {}) {
+    this.options = {
+      mode: 'auto', // One of READ_MODES
+      mmapThreshold: 256 * 1024, // Smallest file auto mode maps
+      poolLimit: 8 * 1024 * 1024, // Largest reused buffer; bigger files get their own
+      ...options
+    };
+    if (!READ_MODES.includes(this.options.mode)) {
+      throw new Error(`Unknown read mode: ${this.options.mode} (${READ_MODES.join(', ')})`);
+    }
+    if (this.options.mode === 'mmap' && !FileReader.canMap()) {
+      throw new Error('--read-mode mmap needs the mmap-io package (npm install mmap-io)');
+    }
+
+    this.pool = null;
+    this.stats = { files: 0, bytes: 0, mapped: 0, pooled: 0, allocated: 0 };
+  }
+
+  /**
+   * Whether files can be memory-mapped (mmap-io is installed)
+   * @returns {boolean}
+   */
+  static canMap() {
+    return mmap !== null;
+  }
+
+  /**
+   * Text of a file, transcoded to UTF-8 (as FileSystem.readText)
+   * @param {string} filePath
+   * @returns {string}
+   */
+  readText(filePath) {
+    if (this.options.mode === 'buffer') {
+      const buffer = fs.readFileSync(filePath);
+      this.count(buffer.length, 'allocated');
+      return decodeText(buffer);
+    }
+
+    const fd = fs.openSync(filePath, 'r');
+    try {
+      const { size } = fs.fstatSync(fd);
+      if (size === 0) {
+        this.count(0, null);
+        return '';
+      }
+      if (this.shouldMap(size)) {
+        const mapped = mmap.map(size, mmap.PROT_READ, mmap.MAP_SHARED, fd, 0, mmap.MADV_SEQUENTIAL);
+        this.count(size, 'mapped');
+        return decodeText(mapped);
+      }
+
+      if (size > this.options.poolLimit) {
+        const buffer = Buffer.allocUnsafe(size);
+        this.count(size, 'allocated');
+        return decodeText(buffer.subarray(0, readInto(fd, buffer, size)));
+      }
+      if (!this.pool || this.pool.length < size) {
+        this.pool = Buffer.allocUnsafe(Math.min(this.options.poolLimit, Math.max(size, (this.pool?.length || 0) * 2)));
+        this.stats.allocated += this.pool.length;
+      }
+      this.count(size, 'pooled');
+      return decodeText(this.pool.subarray(0, readInto(fd, this.pool, size)));
+    } finally {
+      fs.closeSync(fd);
+    }
+  }
+
+  /**
+   * Report line of the reads, e.g. "120 files, 4.2 MB (3 mapped), 1.0 MB buffers"
+   * @returns {string}
+   */
+  describe() {
+    const { files, bytes, mapped, allocated } = this.stats;
+    return `${files.toLocaleString()} files, ${formatBytes(bytes)}` +
+      (mapped > 0 ? ` (${mapped.toLocaleString()} mapped)` : '') +
+      `, ${formatBytes(allocated)} buffers`;
+  }
+
+  /**
+   * @private
+   */
+  shouldMap(size) {
+    if (this.options.mode === 'mmap') return true;
+    return this.options.mode === 'auto' && mmap !== null && size >= this.options.mmapThreshold;
+  }
+
+  /**
+   * @private
+   */
+  count(size, kind) {
+    this.stats.files++;
+    this.stats.bytes += size;
+    if (kind === 'allocated') this.stats.allocated += size;
+    else if (kind) this.stats[kind]++;
+  }
+}
+
+/**
+ * Number of lines of a text, as text.split('\n').length without the array
+ * @param {string} text
+ * @returns {number}
+ */
+export function countLines(text) {
+  let lines = 1;
+  for (let i = text.indexOf('\n'); i !== -1; i = text.indexOf('\n', i + 1)) lines++;
+  return lines;
+}
+
+/**
+ * Read up to size bytes; fewer when the file shrank since fstat
+ * @private
+ */
+function readInto(fd, buffer, size) {
+  let offset = 0;
+  while (offset < size) {
+    const read = fs.readSync(fd, buffer, offset, size - offset, offset);
+    if (read === 0) break;
+    offset += read;
+  }
+  return offset;
+}
+
+/**
+ * @private
+ */
+function formatBytes(bytes) {
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+  return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+}
+
+export default FileReader;
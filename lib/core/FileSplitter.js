@@ -0,0 +1,193 @@
+/**
+ * FileSplitter - Split oversized files into parts
+ * v3.4.0 - Symbol-aware chunking
+ *
+ * Responsibilities:
+ * - Split a file that exceeds the per-chunk token limit along symbol boundaries
+ * - Descend into classes and other containers that do not fit on their own
+ * - Fall back to line windows for single symbols larger than the limit
+ * - Carry the enclosing declaration and an overlap with the previous part
+ */
+
+import TokenUtils from '../utils/token-utils.js';
+import ContentCache from '../cache/ContentCache.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('FileSplitter');
+
+export class FileSplitter {
+  constructor(options = {}) {
+    this.options = {
+      maxTokens: 100000, // Tokens per part; the overlap is reserved from it
+      overlap: 0, // Tokens of the previous part repeated at the start of the next
+      countTokens: (text, filePath) => TokenUtils.estimate(text, filePath),
+      extractor: null, // Initialized SymbolExtractor (default: heuristic)
+      cache: null, // ContentCache for symbol outlines
+      ...options
+    };
+    this.extractor = this.options.extractor || new SymbolExtractor({ backend: 'heuristic' });
+  }
+
+  /**
+   * Split content into parts of at most maxTokens
+   * Parts cover the file without gaps; leading comments and imports stay
+   * with the symbol that follows them.
+   * @param {string} content - File content
+   * @param {string} filePath - Relative path
+   * @returns {Array<Object>} Parts { index, total, startLine, endLine, tokens, symbols, context, overlap }
+   */
+  split(content, filePath) {
+    const lines = content.split('\n');
+    if (lines.length > 1 && lines[lines.length - 1] === '') lines.pop();
+
+    // Per-line counts keep sizing linear in file length; part totals are counted exactly
+    const prefix = [0];
+    for (const line of lines) {
+      prefix.push(prefix[prefix.length - 1] + this.options.countTokens(line + '\n', filePath));
+    }
+    const count = (start, end) => start > end ? 0 : prefix[end] - prefix[start - 1];
+    const limit = Math.max(1, this.options.maxTokens - this.options.overlap);
+    const symbols = this.extractSymbols(content, filePath)
+      .filter(symbol => symbol.kind !== SymbolKind.MODULE && symbol.endLine <= lines.length)
+      .sort((a, b) => a.startLine - b.startLine || b.endLine - a.endLine);
+
+    const units = this.units(1, lines.length, symbols, null, { count, limit });
+
+    // Pack consecutive units while they fit
+    const groups = [];
+    for (const unit of units) {
+      const last = groups[groups.length - 1];
+      if (last && count(last.startLine, unit.endLine) <= limit) {
+        last.endLine = unit.endLine;
+      } else {
+        groups.push({ startLine: unit.startLine, endLine: unit.endLine, context: unit.context });
+      }
+    }
+
+    const parts = groups.map((group, i) => {
+      const context = group.context && group.context.endLine < group.startLine ? group.context : null;
+      return {
+        index: i + 1,
+        total: groups.length,
+        startLine: group.startLine,
+        endLine: group.endLine,
+        tokens: this.options.countTokens(lines.slice(group.startLine - 1, group.endLine).join('\n'), filePath),
+        symbols: symbols
+          .filter(symbol => symbol.startLine >= group.startLine && symbol.startLine <= group.endLine)
+          .map(symbol => symbol.qualifiedName),
+        context,
+        overlap: i > 0 ? this.overlapBefore(group.startLine, context, count) : null
+      };
+    });
+
+    logger.debug(`Split ${filePath} into ${parts.length} parts`);
+    return parts;
+  }
+
+  /**
+   * Line ranges that each fit the limit where possible
+   * @private
+   */
+  units(start, end, symbols, context, sizing) {
+    const { count, limit } = sizing;
+    const topLevel = symbols.filter(symbol => symbol.startLine >= start && symbol.endLine <= end &&
+      !symbols.some(other => other !== symbol && other.startLine >= start &&
+        other.startLine <= symbol.startLine && other.endLine >= symbol.endLine &&
+        (other.startLine < symbol.startLine || other.endLine > symbol.endLine)));
+
+    const ranges = [];
+    let cursor = start;
+    for (const symbol of topLevel) {
+      if (symbol.endLine < cursor) continue; // Overlaps the previous range
+      ranges.push({ startLine: cursor, endLine: symbol.endLine, symbol });
+      cursor = symbol.endLine + 1;
+    }
+    if (cursor <= end) {
+      if (ranges.length > 0) ranges[ranges.length - 1].endLine = end;
+      else ranges.push({ startLine: cursor, endLine: end, symbol: null });
+    }
+
+    const units = [];
+    for (const range of ranges) {
+      if (count(range.startLine, range.endLine) <= limit) {
+        units.push({ ...range, context });
+        continue;
+      }
+
+      // Containers are split between their members, repeating the declaration
+      const members = range.symbol
+        ? symbols.filter(symbol => symbol !== range.symbol &&
+          symbol.startLine > range.symbol.startLine && symbol.endLine <= range.symbol.endLine)
+        : [];
+      if (members.length > 0) {
+        const firstMember = Math.min(...members.map(member => member.startLine));
+        const header = { name: range.symbol.qualifiedName, startLine: range.symbol.startLine, endLine: firstMember - 1 };
+        units.push(...this.units(range.startLine, range.endLine, members, header, sizing));
+        continue;
+      }
+
+      // Windows of one symbol repeat its first line
+      const symbolContext = range.symbol
+        ? { name: range.symbol.qualifiedName, startLine: range.symbol.startLine, endLine: range.symbol.startLine }
+        : context;
+      units.push(...this.windows(range.startLine, range.endLine, symbolContext, count, limit));
+    }
+    return units;
+  }
+
+  /**
+   * Line windows for a range with no symbol boundary to split at
+   * @private
+   */
+  windows(start, end, context, count, limit) {
+    const windows = [];
+    let windowStart = start;
+    for (let line = start; line <= end; line++) {
+      if (line > windowStart && count(windowStart, line) > limit) {
+        windows.push({ startLine: windowStart, endLine: line - 1, symbol: null, context });
+        windowStart = line;
+      }
+    }
+    windows.push({ startLine: windowStart, endLine: end, symbol: null, context });
+    return windows;
+  }
+
+  /**
+   * Lines just before a part, up to the overlap budget
+   * @private
+   */
+  overlapBefore(startLine, context, count) {
+    if (this.options.overlap <= 0) return null;
+
+    const floor = context ? context.endLine + 1 : 1;
+    let first = startLine;
+    while (first - 1 >= floor && count(first - 1, startLine - 1) <= this.options.overlap) {
+      first--;
+    }
+    return first < startLine ? { startLine: first, endLine: startLine - 1 } : null;
+  }
+
+  /**
+   * @private
+   */
+  extractSymbols(content, filePath) {
+    if (!this.extractor.supports(filePath)) {
+      return [];
+    }
+
+    const cache = this.options.cache;
+    if (!cache) {
+      return this.extractor.extract(content, filePath);
+    }
+    return cache.symbols(
+      ContentCache.hash(content),
+      ContentCache.key(this.extractor.getBackend(filePath), filePath),
+      filePath,
+      () => this.extractor.extract(content, filePath)
+    );
+  }
+}
+
+export default FileSplitter;
@@ -0,0 +1,183 @@
+/**
+ * FileSystem - Platform-independent paths and text decoding
+ * v3.4.0 - Windows path and encoding robustness
+ *
+ * Responsibilities:
+ * - Normalize mixed separators, drive letters, UNC (\\server\share) and
+ *   extended-length (\\?\C:\...) paths before comparing them
+ * - Compute project-relative paths that never climb out through a prefix
+ *   or drive letter case that differs from the project root
+ * - Detect UTF-8 (with or without BOM), UTF-16 LE/BE and Windows-1252 text
+ *   and transcode it to UTF-8 strings without a BOM
+ *
+ * Paths are handled with path.win32 or path.posix depending on the platform
+ * given, so Windows behaviour can be exercised on any OS.
+ */
+
+import fs from 'fs';
+import path from 'path';
+
+export const ENCODINGS = ['utf8', 'utf8-bom', 'utf16le', 'utf16be', 'windows-1252'];
+
+// Bytes sampled when guessing UTF-16 without a byte order mark
+const SAMPLE_BYTES = 512;
+
+// Windows-1252 characters of bytes 0x80-0x9F, where it differs from Latin-1
+const CP1252_HIGH = '\u20ac\x81\u201a\u0192\u201e\u2026\u2020\u2021\u02c6\u2030\u0160\u2039\u0152\x8d\u017d\x8f' +
+  '\x90\u2018\u2019\u201c\u201d\u2022\u2013\u2014\u02dc\u2122\u0161\u203a\u0153\x9d\u017e\u0178';
+
+/**
+ * Encoding of file contents
+ * @param {Buffer} buffer
+ * @returns {string} One of ENCODINGS
+ */
+export function detectEncoding(buffer) {
+  return wideEncoding(buffer) || (isValidUtf8(buffer, buffer.toString('utf8')) ? 'utf8' : 'windows-1252');
+}
+
+/**
+ * File contents as a UTF-8 string without a byte order mark
+ * @param {Buffer} buffer
+ * @param {string} [encoding] - One of ENCODINGS; detected when omitted
+ * @returns {string}
+ */
+export function decodeText(buffer, encoding = wideEncoding(buffer)) {
+  switch (encoding) {
+    case 'utf16le':
+      return stripBom(buffer.subarray(0, buffer.length & ~1).toString('utf16le'));
+    case 'utf16be':
+      return stripBom(Buffer.from(buffer.subarray(0, buffer.length & ~1)).swap16().toString('utf16le'));
+    case 'windows-1252':
+      return buffer.toString('latin1').replace(/[\x80-\x9f]/g, char => CP1252_HIGH[char.charCodeAt(0) - 0x80]);
+  }
+
+  const text = buffer.toString('utf8');
+  if (!encoding && !isValidUtf8(buffer, text)) {
+    return decodeText(buffer, 'windows-1252');
+  }
+  return stripBom(text);
+}
+
+export class FileSystem {
+  constructor(options = {}) {
+    this.options = {
+      platform: process.platform, // 'win32' uses Windows path rules
+      ...options
+    };
+    this.path = this.options.platform === 'win32' ? path.win32 : path.posix;
+  }
+
+  /**
+   * Text of a file, transcoded to UTF-8
+   * @param {string} filePath
+   * @returns {string}
+   */
+  readText(filePath) {
+    return decodeText(fs.readFileSync(this.normalize(filePath)));
+  }
+
+  /**
+   * Path with one separator style, no extended-length prefix and an
+   * upper-case drive letter (Windows); otherwise path.posix.normalize
+   * @param {string} filePath
+   * @returns {string}
+   */
+  normalize(filePath) {
+    if (this.options.platform !== 'win32') return path.posix.normalize(filePath);
+
+    let normalized = filePath.replace(/\//g, '\\');
+    if (/^\\\\\?\\UNC\\/i.test(normalized)) {
+      normalized = `\\\\${normalized.slice(8)}`;
+    } else if (/^\\\\[?.]\\[a-z]:/i.test(normalized)) {
+      normalized = normalized.slice(4);
+    }
+    normalized = path.win32.normalize(normalized);
+    return normalized.replace(/^[a-z]:/, drive => drive.toUpperCase());
+  }
+
+  /**
+   * Path of a file relative to a root, with native separators
+   * @param {string} root
+   * @param {string} filePath
+   * @returns {string}
+   */
+  relative(root, filePath) {
+    return this.path.relative(this.normalize(root), this.normalize(filePath));
+  }
+
+  /**
+   * Whether a path lies inside a root (or is the root)
+   * @param {string} root
+   * @param {string} filePath
+   * @returns {boolean}
+   */
+  contains(root, filePath) {
+    const relative = this.relative(root, filePath);
+    const outside = relative === '..' || relative.startsWith(`..${this.path.sep}`);
+    return relative === '' || (!outside && !this.path.isAbsolute(relative));
+  }
+
+  /**
+   * Relative path with forward slashes, as in contexts, globs and symbol IDs
+   * @param {string} relativePath - Native separators (see relative())
+   * @returns {string}
+   */
+  toPosix(relativePath) {
+    return this.options.platform === 'win32' ? relativePath.replace(/\\/g, '/') : relativePath;
+  }
+}
+
+/**
+ * FileSystem of the platform this process runs on
+ */
+export const nativeFileSystem = new FileSystem();
+
+/**
+ * @private
+ */
+function stripBom(text) {
+  return text.charCodeAt(0) === 0xfeff ? text.slice(1) : text;
+}
+
+/**
+ * UTF-8 with a byte order mark or UTF-16 by its byte order mark or zero
+ * bytes, or null for 8-bit text
+ * @private
+ */
+function wideEncoding(buffer) {
+  if (buffer.length >= 3 && buffer[0] === 0xef && buffer[1] === 0xbb && buffer[2] === 0xbf) return 'utf8-bom';
+  if (buffer.length >= 2 && buffer[0] === 0xff && buffer[1] === 0xfe) return 'utf16le';
+  if (buffer.length >= 2 && buffer[0] === 0xfe && buffer[1] === 0xff) return 'utf16be';
+
+  // ASCII text saved as UTF-16 has a zero in every other byte
+  const sample = buffer.subarray(0, Math.min(buffer.length, SAMPLE_BYTES) & ~1);
+  if (sample.length >= 4) {
+    let evenZeros = 0;
+    let oddZeros = 0;
+    for (let i = 0; i < sample.length; i += 2) {
+      if (sample[i] === 0) evenZeros++;
+      if (sample[i + 1] === 0) oddZeros++;
+    }
+    const pairs = sample.length / 2;
+    if (oddZeros > pairs * 0.4 && evenZeros < pairs * 0.05) return 'utf16le';
+    if (evenZeros > pairs * 0.4 && oddZeros < pairs * 0.05) return 'utf16be';
+  }
+  return null;
+}
+
+/**
+ * Whether bytes are well-formed UTF-8, given their lenient decoding
+ * @private
+ */
+function isValidUtf8(buffer, text) {
+  // Only replacement characters need a strict check
+  if (!text.includes('\ufffd')) return true;
+  try {
+    new TextDecoder('utf-8', { fatal: true }).decode(buffer);
+    return true;
+  } catch {
+    return false;
+  }
+}
+
+export default FileSystem;
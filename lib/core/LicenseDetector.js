@@ -0,0 +1,262 @@
+/**
+ * LicenseDetector - License headers and LICENSE files of a project
+ * v3.4.0 - Licensing note (--licenses)
+ *
+ * Responsibilities:
+ * - Find the license header of a source file: the comment blocks at its top
+ *   that name a license or a copyright, past shebangs and directives
+ * - Name the license of a header or of a LICENSE, COPYING or NOTICE file, by
+ *   SPDX identifier or by known wording, and its copyright holders
+ * - Remove license headers from the code; removed contents replace the
+ *   sources everywhere, like ContentStripper's, so token counts and symbol
+ *   lines agree
+ * - Aggregate everything into one licensing note section of the bundle
+ *
+ * Headers and files whose wording matches no known license are still
+ * removed and listed, as "unknown", with their copyright lines.
+ */
+
+import path from 'path';
+import ContentStripper, { scan } from './ContentStripper.js';
+import { compareBytes } from '../utils/ordering.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('LicenseDetector');
+
+export const LICENSES_TITLE = 'LICENSES';
+
+// Names looked up in every analyzed directory
+export const LICENSE_FILES = ['LICENSE', 'LICENSE.md', 'LICENSE.txt', 'LICENCE', 'COPYING', 'COPYING.LESSER', 'UNLICENSE', 'NOTICE', 'NOTICE.md', 'NOTICE.txt'];
+
+// LICENSE, LICENSE.md, LICENSE-MIT, COPYING.LESSER, UNLICENSE, NOTICE.txt ...
+const LICENSE_FILE = /^(?:licen[cs]e|copying|unlicense|notice)(?:[.-][\w.-]*)?$/i;
+
+// Extensions of license texts; LICENSE.APACHE-style upper-case suffixes count too
+const LICENSE_EXTENSIONS = new Set(['', '.md', '.markdown', '.txt', '.rst', '.html']);
+
+// Wording of a header worth removing; a bare "license" is not enough
+const LICENSE_HINT = /SPDX-License-Identifier|copyright\b|©|\(c\)\s*\d{4}|licen[cs]ed under|all rights reserved|permission is hereby granted|redistribution and use|public license|licen[cs]e,?\s+version|under the terms of/i;
+
+// Comments that configure tools, kept above a license header
+const DIRECTIVE = /^(?:\/\/|#|\/\*)\s*(?:-\*-|coding[:=]|vim?:|eslint|jshint|prettier|@ts-|@flow|go:build|\+build|frozen_string_literal|pylint|mypy|type:|noqa|<reference|@jsx)/i;
+
+const SPDX = /SPDX-License-Identifier:\s*([\w.+-]+(?:\s+(?:OR|AND|WITH)\s+[\w.+-]+)*)/i;
+
+// Known wording, most specific first
+const WORDING = [
+  { license: 'AGPL-3.0', pattern: /GNU Affero General Public License/i },
+  { license: 'LGPL-3.0', pattern: /GNU Lesser General Public License[\s\S]{0,400}?version 3/i },
+  { license: 'LGPL-2.1', pattern: /GNU Lesser General Public License|GNU Library General Public License/i },
+  { license: 'GPL-3.0', pattern: /GNU General Public License[\s\S]{0,400}?version 3/i },
+  { license: 'GPL-2.0', pattern: /GNU General Public License/i },
+  { license: 'Apache-2.0', pattern: /Apache License,?\s+Version 2\.0/i },
+  { license: 'MPL-2.0', pattern: /Mozilla Public License,?\s+v(?:ersion|\.)?\s*2\.0/i },
+  { license: 'EPL-2.0', pattern: /Eclipse Public License\s*-?\s*v(?:ersion|\.)?\s*2\.0/i },
+  { license: 'BSL-1.0', pattern: /Boost Software License/i },
+  { license: 'Unlicense', pattern: /This is free and unencumbered software released into the public domain/i },
+  { license: 'ISC', pattern: /Permission to use, copy, modify, and(?:\/or)? distribute this software for any\s+purpose/i },
+  { license: 'MIT', pattern: /Permission is hereby granted, free of charge|\bMIT License\b|Licensed under the MIT/i },
+  { license: 'BSD-3-Clause', pattern: /Redistribution and use in source and binary forms[\s\S]*Neither the name/i },
+  { license: 'BSD-2-Clause', pattern: /Redistribution and use in source and binary forms/i }
+];
+
+const COPYRIGHT = /(?:copyright\s*(?:\(c\)|©)?|\(c\)|©)\s*((?:\d{4}(?:\s*[-–,]\s*\d{4})*,?\s+)?[^\n]*)/i;
+
+export class LicenseDetector {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      limit: 10, // Files named per license in the note
+      ...options
+    };
+    this.files = new Map(); // relativePath -> { source, content, header }
+  }
+
+  /**
+   * Settings that change stripped contents, for cache keys
+   * @returns {string}
+   */
+  getKey() {
+    return 'licenses';
+  }
+
+  /**
+   * Whether a project file is a license file (LICENSE, COPYING, NOTICE ...)
+   * @param {string} relativePath
+   * @returns {boolean}
+   */
+  static isLicenseFile(relativePath) {
+    const name = path.basename(relativePath);
+    const extension = path.extname(name);
+    return LICENSE_FILE.test(name) && (LICENSE_EXTENSIONS.has(extension.toLowerCase()) || extension === extension.toUpperCase());
+  }
+
+  /**
+   * License and copyright holders a text states
+   * @param {string} text
+   * @returns {{license: string|null, copyright: Array<string>}} license is an SPDX
+   *   identifier, null when the wording is unknown
+   */
+  static identify(text) {
+    const spdx = SPDX.exec(text);
+    const known = spdx ? null : WORDING.find(({ pattern }) => pattern.test(text));
+    const copyright = new Set();
+    for (const line of text.split('\n')) {
+      const match = COPYRIGHT.exec(line.replace(/^\s*(?:\/\/+|\/\*+|\*+|#+|--+|<!--)?\s*/, ''));
+      if (!match) continue;
+      const holder = match[0].replace(/\s*(?:\*\/|-->)\s*$/, '').replace(/[.,;]?\s*All rights reserved\.?$/i, '').trim();
+      // "(c)" inside a sentence is no copyright line
+      if (/^(?:copyright|\(c\)|©)\s*(?:\(c\)|©)?\s*(?:\d{4}|[A-Z])/i.test(holder)) copyright.add(holder);
+    }
+    return {
+      license: spdx ? spdx[1] : known ? known.license : null,
+      copyright: [...copyright]
+    };
+  }
+
+  /**
+   * License header at the top of a file
+   * @param {string} content
+   * @param {string} file - Path; its extension picks the comment syntax
+   * @returns {Object|null} { start, end, license, copyright, text }; start and end are
+   *   offsets of what strip() removes, trailing blank lines included
+   */
+  header(content, file) {
+    const syntax = ContentStripper.syntaxOf(file, content);
+    if (!syntax) return null;
+
+    // Comment blocks before the first code; line comments on adjacent lines form one block
+    const blocks = [];
+    let offset = content.startsWith('#!') ? Math.max(0, content.indexOf('\n')) : 0;
+    for (const comment of scan(content, syntax).comments) {
+      if (comment.start < offset) continue;
+      const between = content.slice(offset, comment.start);
+      if (between.trim() !== '') break;
+      const last = blocks[blocks.length - 1];
+      const text = content.slice(comment.start, comment.end);
+      const joins = last && !/\n[ \t]*\r?\n/.test(between) && !comment.docstring &&
+        ![last.text, text].some(block => block.startsWith('/*') || DIRECTIVE.test(block));
+      if (joins) {
+        last.end = comment.end;
+        last.text = content.slice(last.start, last.end);
+      } else {
+        blocks.push({ start: comment.start, end: comment.end, text });
+      }
+      offset = comment.end;
+    }
+
+    let first = 0;
+    while (first < blocks.length && DIRECTIVE.test(blocks[first].text)) first++;
+    let last = first;
+    while (last < blocks.length && LICENSE_HINT.test(blocks[last].text)) last++;
+    if (last === first) return null;
+
+    const start = blocks[first].start - (content.slice(0, blocks[first].start).match(/[ \t]*$/)[0].length);
+    let end = blocks[last - 1].end;
+    const trailing = /^[ \t]*(?:\r?\n[ \t]*)*(?:\r?\n|$)/.exec(content.slice(end));
+    end += trailing ? trailing[0].length : 0;
+    // Keep the line break ending what comes before the header
+    const text = content.slice(blocks[first].start, blocks[last - 1].end);
+    return { start, end, ...LicenseDetector.identify(text), text };
+  }
+
+  /**
+   * Remove a file's license header; stripping the same content again returns the recorded result
+   * @param {string} content
+   * @param {string} relativePath
+   * @returns {string}
+   */
+  strip(content, relativePath) {
+    const file = relativePath.split(path.sep).join('/');
+    const known = this.files.get(file);
+    if (known && known.source === content) return known.content;
+
+    const header = this.header(content, file);
+    const stripped = header ? content.slice(0, header.start) + content.slice(header.end) : content;
+    if (header) logger.debug(`${file}: ${header.license || 'unknown'} header removed, ${header.end - header.start} characters`);
+    this.files.set(file, { source: content, content: stripped, header });
+    return stripped;
+  }
+
+  /**
+   * Header strip() removed from a file, or null
+   * @param {string} relativePath
+   * @returns {Object|null} See header()
+   */
+  headerOf(relativePath) {
+    return this.files.get(relativePath.split(path.sep).join('/'))?.header || null;
+  }
+
+  /**
+   * Licensing note of a bundle
+   * @param {Array<Object>} licenseFiles - { path, content, tokens } of license files
+   * @param {Array<Object>} headers - { path, license, copyright, tokens } of removed headers
+   * @returns {{files: Array<Object>, headers: Array<Object>}} files { path, license, copyright,
+   *   tokens }, root files first; headers { license, copyright, files, tokens }, one per
+   *   license, most files first
+   */
+  aggregate(licenseFiles, headers) {
+    const files = licenseFiles
+      .map(file => ({ path: file.path, ...LicenseDetector.identify(file.content), tokens: file.tokens }))
+      .sort((a, b) => a.path.split('/').length - b.path.split('/').length || compareBytes(a.path, b.path));
+
+    const groups = new Map();
+    for (const header of headers) {
+      const key = header.license || 'unknown';
+      if (!groups.has(key)) groups.set(key, { license: header.license, copyright: new Set(), files: [], tokens: 0 });
+      const group = groups.get(key);
+      header.copyright.forEach(holder => group.copyright.add(holder));
+      group.files.push(header.path);
+      group.tokens += header.tokens;
+    }
+    const grouped = [...groups.values()]
+      .map(group => ({ ...group, copyright: [...group.copyright].sort(compareBytes), files: group.files.sort(compareBytes) }))
+      .sort((a, b) => b.files.length - a.files.length || compareBytes(a.license || '~', b.license || '~'));
+
+    return { files, headers: grouped };
+  }
+
+  /**
+   * Digest section
+   * @param {Object} note - Result of aggregate()
+   * @returns {string}
+   */
+  format(note) {
+    const lines = ['', '='.repeat(48), LICENSES_TITLE, '='.repeat(48)];
+    for (const file of note.files) {
+      lines.push(`${file.path} — ${file.license || 'unknown license'}`);
+      file.copyright.forEach(holder => lines.push(`  ${holder}`));
+    }
+    if (note.files.length === 0) lines.push('No LICENSE file');
+
+    if (note.headers.length > 0) {
+      const count = note.headers.reduce((sum, group) => sum + group.files.length, 0);
+      lines.push('', `License headers removed from ${count} ${count === 1 ? 'file' : 'files'}:`);
+      for (const group of note.headers) {
+        const listed = group.files.slice(0, this.options.limit).join(', ');
+        const more = group.files.length > this.options.limit ? `, … ${group.files.length - this.options.limit} more` : '';
+        lines.push(`  ${group.license || 'unknown'} (${group.files.length} ${group.files.length === 1 ? 'file' : 'files'}): ${listed}${more}`);
+        group.copyright.forEach(holder => lines.push(`    ${holder}`));
+      }
+    }
+    return `${lines.join('\n')}\n`;
+  }
+
+  /**
+   * Report line, e.g. "⚖️  Licenses: MIT (LICENSE); 42 headers removed, 3,360 tokens"
+   * @param {Object} note - Result of aggregate()
+   * @returns {string}
+   */
+  describe(note) {
+    const project = note.files.length > 0
+      ? note.files.map(file => `${file.license || 'unknown'} (${file.path})`).join(', ')
+      : 'no LICENSE file';
+    const count = note.headers.reduce((sum, group) => sum + group.files.length, 0);
+    const tokens = note.headers.reduce((sum, group) => sum + group.tokens, 0);
+    return `⚖️  Licenses: ${project}; ${count} ${count === 1 ? 'header' : 'headers'} removed, ${tokens.toLocaleString()} tokens`;
+  }
+}
+
+export default LicenseDetector;
@@ -0,0 +1,134 @@
+/**
+ * LineSelection - Pinned slices of files
+ * v3.4.0 - Line ranges and regions (--pin FILE:START-END, --pin FILE#REGION)
+ *
+ * Responsibilities:
+ * - Tell slice selectors apart from the globs of --pin and profile pins
+ * - Resolve them to line ranges: START-END, a single LINE, START- to the
+ *   end of the file, or the lines of //ctx:region NAME blocks
+ * - Merge the slices of a file into ordered ranges, overlaps joined
+ *
+ * A pinned slice replaces its file in the context, which then holds only
+ * the pinned lines, and is packed before unpinned files. Line numbers are
+ * those of the file as it is read, before --strip.
+ */
+
+const LINES = /^(.+):(\d+)(?:-(\d*))?$/;
+const REGION = /^(.+)#([\w.-]+)$/;
+
+export class LineSelection {
+  /**
+   * @param {Array<string>} selectors - e.g. src/parse.go:120-240, src/parse.go#hot-loop
+   * @throws {Error} For selectors that are not slices or have an empty range
+   */
+  constructor(selectors) {
+    this.slices = selectors.map(selector => LineSelection.parse(selector));
+  }
+
+  /**
+   * Whether a pin selects lines rather than files
+   * @param {string} pin
+   * @returns {boolean}
+   */
+  static isSelector(pin) {
+    return LINES.test(pin) || REGION.test(pin);
+  }
+
+  /**
+   * @param {string} selector
+   * @returns {{selector: string, file: string, label: string, startLine: number|null, endLine: number|null, region: string|null}}
+   *   label is the part after the file; endLine null runs to the end of the file; region slices have no lines yet
+   * @throws {Error}
+   */
+  static parse(selector) {
+    const region = REGION.exec(selector);
+    if (region) {
+      return { selector, file: normalize(region[1]), label: region[2], startLine: null, endLine: null, region: region[2] };
+    }
+    const lines = LINES.exec(selector);
+    if (!lines) {
+      throw new Error(`Not a line selector: ${selector} (expected FILE:START-END, FILE:LINE, FILE:START- or FILE#REGION)`);
+    }
+    const startLine = Number(lines[2]);
+    const endLine = lines[3] === undefined ? startLine : lines[3] === '' ? null : Number(lines[3]);
+    if (startLine < 1 || (endLine !== null && endLine < startLine)) {
+      throw new Error(`Empty line range: ${selector} (lines start at 1 and END is not before START)`);
+    }
+    return { selector, file: normalize(lines[1]), label: selector.slice(lines[1].length + 1), startLine, endLine, region: null };
+  }
+
+  /**
+   * Files with pinned slices
+   * @returns {Array<string>} '/'-separated, in selector order
+   */
+  files() {
+    return [...new Set(this.slices.map(slice => slice.file))];
+  }
+
+  /**
+   * Selectors of a file
+   * @param {string} file - '/'-separated
+   * @returns {Array<string>}
+   */
+  selectorsOf(file) {
+    return this.slices.filter(slice => slice.file === file).map(slice => slice.selector);
+  }
+
+  /**
+   * Line ranges of a file's slices, ordered and merged
+   * @param {string} file - '/'-separated
+   * @param {number} lineCount - Lines of the file
+   * @param {Array<{name: string, startLine: number, endLine: number}>} regions - SourceDirectives regions
+   * @returns {Array<{name: string, kind: string, startLine: number, endLine: number}>} kind lines or region
+   * @throws {Error} When a region is missing or a range starts after the last line
+   */
+  rangesOf(file, lineCount, regions = []) {
+    const ranges = [];
+    for (const slice of this.slices.filter(candidate => candidate.file === file)) {
+      if (slice.region) {
+        const found = regions.filter(region => region.name === slice.region && region.endLine >= region.startLine);
+        if (found.length === 0) {
+          const names = [...new Set(regions.map(region => region.name))];
+          throw new Error(`Region not found: ${slice.selector} (${names.length > 0 ? `regions: ${names.join(', ')}` : 'no //ctx:region in the file'})`);
+        }
+        ranges.push(...found.map(region => ({ name: region.name, kind: 'region', startLine: region.startLine, endLine: region.endLine })));
+        continue;
+      }
+      if (slice.startLine > lineCount) {
+        throw new Error(`Line range past the end of ${file}: ${slice.selector} (${lineCount} lines)`);
+      }
+      const endLine = Math.min(slice.endLine ?? lineCount, lineCount);
+      ranges.push({ name: slice.label, kind: 'lines', startLine: slice.startLine, endLine });
+    }
+    return LineSelection.merge(ranges);
+  }
+
+  /**
+   * Order ranges by line and join overlapping ones
+   * @param {Array<{name: string, kind: string, startLine: number, endLine: number}>} ranges
+   * @returns {Array<Object>}
+   */
+  static merge(ranges) {
+    const merged = [];
+    for (const range of [...ranges].sort((a, b) => a.startLine - b.startLine || b.endLine - a.endLine)) {
+      const last = merged[merged.length - 1];
+      if (last && range.startLine <= last.endLine) {
+        if (range.endLine > last.endLine) {
+          Object.assign(last, { name: `${last.name}, ${range.name}`, endLine: range.endLine });
+        }
+        continue;
+      }
+      merged.push({ ...range });
+    }
+    return merged;
+  }
+}
+
+/**
+ * @private
+ */
+function normalize(file) {
+  return file.replace(/\\/g, '/').replace(/^\.\//, '');
+}
+
+export default LineSelection;
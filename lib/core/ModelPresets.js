@@ -0,0 +1,88 @@
+/**
+ * ModelPresets - Output settings per target model
+ * v3.4.0 - Model presets (--model)
+ *
+ * Responsibilities:
+ * - Name model families (claude-sonnet, gpt-4o, gemini-1.5) by the LLM
+ *   profile they stand for, so users need not know exact model ids
+ * - Derive the tokenizer, context window and output reserve of a model
+ *   from its profile
+ * - Carry the format quirks of each vendor: Claude reads long inputs best
+ *   as XML-tagged documents, GPT and Gemini as Markdown
+ */
+
+import { LLMDetector } from '../utils/llm-detector.js';
+
+// Preset name -> LLM profile id and the settings the profile does not hold
+export const MODEL_PRESETS = {
+  'claude-sonnet': { profile: 'claude-sonnet-4.5', tokenizer: 'claude', format: 'xml' },
+  'claude-opus': { profile: 'claude-opus-4', tokenizer: 'claude', format: 'xml' },
+  'gpt-4o': { profile: 'gpt-4o', tokenizer: 'o200k_base', format: 'markdown' },
+  'gpt-4o-mini': { profile: 'gpt-4o-mini', tokenizer: 'o200k_base', format: 'markdown' },
+  'gpt-4-turbo': { profile: 'gpt-4-turbo', tokenizer: 'cl100k_base', format: 'markdown' },
+  'gemini-1.5': { profile: 'gemini-1.5-pro', tokenizer: 'estimate', format: 'markdown' },
+  'gemini-2.0': { profile: 'gemini-2.0-flash', tokenizer: 'estimate', format: 'markdown' },
+  'deepseek-chat': { profile: 'deepseek-chat', tokenizer: 'estimate', format: 'markdown' },
+  'deepseek-coder': { profile: 'deepseek-coder', tokenizer: 'estimate', format: 'markdown' }
+};
+
+// Settings by vendor for profiles without a preset (custom profiles)
+const VENDOR_DEFAULTS = {
+  Anthropic: { tokenizer: 'claude', format: 'xml' },
+  OpenAI: { tokenizer: 'auto', format: 'markdown' }
+};
+
+const DEFAULT_SETTINGS = { tokenizer: 'estimate', format: 'markdown' };
+
+export class ModelPresets {
+  /**
+   * Settings of a preset, or of an LLM profile id (claude-sonnet-4.5)
+   * @param {string} name
+   * @returns {{name: string, model: string, label: string, tokenizer: string,
+   *   contextWindow: number, reserve: number, format: string}}
+   * @throws {Error} For names that are neither a preset nor a profile
+   */
+  static resolve(name) {
+    const profiles = LLMDetector.getAllProfiles();
+    const preset = MODEL_PRESETS[name];
+    const model = preset ? preset.profile : name;
+    const profile = profiles[model];
+    if (!profile) {
+      throw new Error(`Unknown --model: ${name} (expected ${Object.keys(MODEL_PRESETS).join(', ')} or a model from --list-llms)`);
+    }
+
+    const settings = preset || VENDOR_DEFAULTS[profile.vendor] || DEFAULT_SETTINGS;
+    return {
+      name,
+      model,
+      label: profile.name,
+      tokenizer: settings.tokenizer,
+      contextWindow: profile.contextWindow,
+      reserve: profile.outputWindow || 0,
+      format: settings.format
+    };
+  }
+
+  /**
+   * Presets with their resolved settings, in table order
+   * @returns {Array<Object>} Results of resolve(); presets without a profile are left out
+   */
+  static list() {
+    const profiles = LLMDetector.getAllProfiles();
+    return Object.entries(MODEL_PRESETS)
+      .filter(([, preset]) => profiles[preset.profile])
+      .map(([name]) => ModelPresets.resolve(name));
+  }
+
+  /**
+   * One-line description for startup output
+   * @param {Object} preset - Result of resolve()
+   * @returns {string}
+   */
+  static describe(preset) {
+    return `${preset.label} (${preset.contextWindow.toLocaleString()}-token window, ` +
+      `${preset.reserve.toLocaleString()} reserved for output, ${preset.tokenizer} tokenizer, ${preset.format} format)`;
+  }
+}
+
+export default ModelPresets;
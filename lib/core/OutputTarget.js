@@ -0,0 +1,228 @@
+/**
+ * OutputTarget - Where generated context goes
+ * v3.4.0 - Output targets
+ *
+ * Responsibilities:
+ * - Write context to a project file (default), stdout, the clipboard,
+ *   a temporary file, or a workspace file opened in VS Code
+ * - Print the written path for shell pipelines and editor plugins (--print-path)
+ * - Stream large context piece by piece to stdout and files (ContextWriter)
+ * - Encrypt context before it is written (--encrypt, ContextEncryptor)
+ * - Rename project names to placeholders first (--anonymize, ContextAnonymizer)
+ * - Replace output files atomically, synced to disk (--fsync) and keeping
+ *   the previous output as FILE.bak (--backup)
+ */
+
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { spawn } from 'child_process';
+import ClipboardUtils from '../utils/clipboard-utils.js';
+import ContextWriter, { writeFileAtomic } from './ContextWriter.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('OutputTarget');
+
+export const OUTPUT_TARGETS = ['file', 'stdout', 'clipboard', 'tmpfile', 'vscode'];
+
+// Targets that receive content rather than a file
+const STREAM_TARGETS = ['stdout', 'clipboard'];
+
+export class OutputTarget {
+  constructor(options = {}) {
+    this.options = {
+      target: 'file', // One of OUTPUT_TARGETS
+      root: process.cwd(), // Base of relative output files
+      printPath: false, // Print written paths on stdout
+      editor: 'code', // VS Code command for the vscode target
+      stdout: process.stdout,
+      encryptor: null, // ContextEncryptor; files get its suffix, stdout and the clipboard armored text
+      anonymizer: null, // ContextAnonymizer applied to everything written
+      fsync: false, // Sync output files to disk before renaming them into place
+      backup: false, // Keep the output file a run replaces as FILE.bak
+      ...options
+    };
+
+    if (!OUTPUT_TARGETS.includes(this.options.target)) {
+      throw new Error(`Unknown output target: ${this.options.target} (expected ${OUTPUT_TARGETS.join(', ')})`);
+    }
+    this.target = this.options.target;
+    this.tmpDir = null;
+    this.written = [];
+  }
+
+  /**
+   * Whether output is handed over as content (stdout, clipboard) instead of a file
+   * @returns {boolean}
+   */
+  isStream() {
+    return STREAM_TARGETS.includes(this.target);
+  }
+
+  /**
+   * Whether outputs are encrypted (--encrypt)
+   * @returns {boolean}
+   */
+  isEncrypted() {
+    return Boolean(this.options.encryptor);
+  }
+
+  /**
+   * How output files are replaced, for outputs a formatter writes itself
+   * @returns {{ fsync: boolean, backup: boolean }}
+   */
+  fileOptions() {
+    return { fsync: this.options.fsync, backup: this.options.backup };
+  }
+
+  /**
+   * Absolute path to write an output file to
+   * tmpfile outputs share one fresh temporary directory per run.
+   * @param {string} fileName - Output file, relative to the project root
+   * @returns {string}
+   */
+  resolve(fileName) {
+    if (this.target !== 'tmpfile') {
+      return path.resolve(this.options.root, fileName);
+    }
+    if (!this.tmpDir) {
+      this.tmpDir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-'));
+    }
+    return path.join(this.tmpDir, path.basename(fileName));
+  }
+
+  /**
+   * Write content to the target
+   * A clipboard that cannot be reached falls back to the output file.
+   * @param {string} content
+   * @param {string} fileName - Output file for file targets and the clipboard fallback
+   * @returns {string|null} Written path, or null when sent to stdout or the clipboard
+   */
+  write(content, fileName) {
+    if (this.options.anonymizer) {
+      content = this.options.anonymizer.anonymize(content);
+    }
+    if (this.options.encryptor) {
+      return this.writeEncrypted(content, fileName);
+    }
+    if (this.target === 'stdout') {
+      this.options.stdout.write(content.endsWith('\n') ? content : `${content}\n`);
+      return null;
+    }
+    if (this.target === 'clipboard') {
+      if (ClipboardUtils.copy(content)) return null;
+      console.log(`💡 Saved to ${fileName} instead`);
+    }
+
+    const outputPath = this.target === 'clipboard' ? path.resolve(this.options.root, fileName) : this.resolve(fileName);
+    writeFileAtomic(outputPath, content, this.fileOptions());
+    this.record(outputPath);
+    return outputPath;
+  }
+
+  /**
+   * Write content produced piece by piece without holding all of it
+   * The clipboard needs the whole content and gets it joined.
+   * @param {Iterable<string>} pieces
+   * @param {string} fileName - Output file for file targets and the clipboard fallback
+   * @returns {{ path: string|null, length: number }} Written path (as write()) and characters written
+   */
+  stream(pieces, fileName) {
+    // The encryption command reads all of the content at once
+    if (this.target === 'clipboard' || this.options.encryptor) {
+      const content = [...pieces].join('');
+      return { path: this.write(content, fileName), length: content.length };
+    }
+    if (this.options.anonymizer) {
+      pieces = anonymized(pieces, this.options.anonymizer);
+    }
+    if (this.target === 'stdout') {
+      const writer = ContextWriter.toStream(this.options.stdout).writeAll(pieces);
+      if (!writer.endsWithNewline()) writer.write('\n');
+      return { path: null, length: writer.end() };
+    }
+
+    const outputPath = this.resolve(fileName);
+    const length = ContextWriter.toFile(outputPath, this.fileOptions()).writeAll(pieces).end();
+    this.record(outputPath);
+    return { path: outputPath, length };
+  }
+
+  /**
+   * Finish a file written by a formatter: anonymize and encrypt it, print its path, open it in VS Code
+   * @param {string} outputPath - Absolute path
+   * @returns {string} Final path, with the encryption suffix when encrypted
+   */
+  done(outputPath) {
+    // The formatter already kept the previous output
+    if (this.options.anonymizer) {
+      const content = this.options.anonymizer.anonymize(fs.readFileSync(outputPath, 'utf8'));
+      writeFileAtomic(outputPath, content, { ...this.fileOptions(), backup: false });
+    }
+    const finalPath = this.options.encryptor ? this.options.encryptor.encryptFile(outputPath, this.fileOptions()) : outputPath;
+    this.record(finalPath);
+    return finalPath;
+  }
+
+  /**
+   * @private
+   */
+  writeEncrypted(content, fileName) {
+    const { encryptor } = this.options;
+    if (this.target === 'stdout') {
+      this.options.stdout.write(encryptor.armored().encrypt(content));
+      return null;
+    }
+    if (this.target === 'clipboard') {
+      if (ClipboardUtils.copy(encryptor.armored().encrypt(content).toString('utf8'))) return null;
+      console.log(`💡 Saved to ${fileName}${encryptor.extension} instead`);
+    }
+
+    const plainPath = this.target === 'clipboard' ? path.resolve(this.options.root, fileName) : this.resolve(fileName);
+    const outputPath = `${plainPath}${encryptor.extension}`;
+    writeFileAtomic(outputPath, encryptor.encrypt(content), this.fileOptions());
+    this.record(outputPath);
+    return outputPath;
+  }
+
+  /**
+   * @private
+   */
+  record(outputPath) {
+    this.written.push(outputPath);
+    if (this.options.printPath) {
+      this.options.stdout.write(`${outputPath}\n`);
+    }
+    if (this.target === 'vscode') {
+      this.open(outputPath);
+    }
+  }
+
+  /**
+   * @private
+   */
+  open(outputPath) {
+    const child = spawn(this.options.editor, ['--reuse-window', outputPath], {
+      cwd: this.options.root,
+      detached: true,
+      stdio: 'ignore',
+      shell: process.platform === 'win32'
+    });
+    child.on('error', error => {
+      logger.debug(`Could not open ${outputPath} in VS Code: ${error.message}`);
+      console.error(`⚠️  Could not run "${this.options.editor}" to open ${outputPath} (is the VS Code command installed?)`);
+    });
+    child.unref();
+  }
+}
+
+/**
+ * @private
+ */
+function* anonymized(pieces, anonymizer) {
+  for (const piece of pieces) {
+    yield anonymizer.anonymize(piece);
+  }
+}
+
+export default OutputTarget;
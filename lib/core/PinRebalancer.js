@@ -0,0 +1,152 @@
+/**
+ * PinRebalancer - Fit pinned files that exceed the token budget on their own
+ * v3.4.0 - Pin rebalancing (--rebalance-pins)
+ *
+ * Responsibilities:
+ * - Degrade a pinned file step by step until it fits: strip comments and
+ *   blank lines, then elide function bodies, then keep its first chunk
+ * - Stop at the first step that fits, skipping steps that change nothing
+ * - Report which steps each pinned file went through and what they saved
+ *
+ * Steps build on each other, so an elided file is also stripped and a
+ * chunk is cut from the stripped, elided content. Pinned files still too
+ * large after the last step are left to the budget, which drops them.
+ */
+
+import FileSplitter from './FileSplitter.js';
+import { ContentStripper } from './ContentStripper.js';
+import { BodyElider } from './BodyElider.js';
+import TokenUtils from '../utils/token-utils.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('PinRebalancer');
+
+// Degradations in the order they are tried
+export const REBALANCE_STEPS = ['strip', 'elide', 'chunk'];
+
+/**
+ * Parse a --rebalance-pins value such as "strip,chunk"
+ * @param {string|Array<string>} value
+ * @returns {Array<string>} Steps in the order of REBALANCE_STEPS
+ */
+export function parseRebalanceSteps(value) {
+  const steps = (Array.isArray(value) ? value : String(value ?? '').split(','))
+    .map(step => String(step).trim().toLowerCase())
+    .filter(Boolean);
+
+  if (steps.length === 0) {
+    throw new Error(`expected a list of ${REBALANCE_STEPS.join(', ')}`);
+  }
+  for (const step of steps) {
+    if (!REBALANCE_STEPS.includes(step)) {
+      throw new Error(`unknown step ${step} (expected ${REBALANCE_STEPS.join(', ')})`);
+    }
+  }
+  return REBALANCE_STEPS.filter(step => steps.includes(step));
+}
+
+export class PinRebalancer {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      steps: REBALANCE_STEPS, // Degradations to try, in order
+      elideLines: 3, // Longest body the elide step keeps, in lines
+      countTokens: (text, filePath) => TokenUtils.estimate(text, filePath),
+      extractor: null, // Initialized SymbolExtractor (default: heuristic)
+      ...options
+    };
+    this.stripper = new ContentStripper({ mode: 'both', extractor: this.options.extractor });
+    this.elider = new BodyElider({ lines: this.options.elideLines, extractor: this.options.extractor });
+  }
+
+  /**
+   * Degrade content until it fits the limit
+   * @param {string} content - The pinned file as it would be exported
+   * @param {string} relativePath
+   * @param {number} limit - Tokens it may take
+   * @returns {Object} { fits, content, tokens, fullTokens, steps[] { step, tokens, detail } }
+   *   content is the last step's result; steps lists only the steps that changed it
+   */
+  rebalance(content, relativePath, limit) {
+    const count = text => this.options.countTokens(text, relativePath);
+    const fullTokens = count(content);
+    const steps = [];
+    let tokens = fullTokens;
+
+    for (const step of this.options.steps) {
+      if (tokens <= limit) break;
+      const result = this.apply(step, content, relativePath, limit);
+      if (!result || result.content === content) continue;
+
+      content = result.content;
+      tokens = count(content);
+      steps.push({ step, tokens, detail: result.detail });
+    }
+
+    logger.debug(`${relativePath}: ${fullTokens} -> ${tokens} tokens (${steps.map(entry => entry.step).join(', ') || 'no step applied'})`);
+    return { fits: tokens <= limit, content, tokens, fullTokens, steps };
+  }
+
+  /**
+   * Short description of the steps a file went through, for notes
+   * @param {Array<Object>} steps - steps of rebalance()
+   * @returns {string}
+   */
+  static describeSteps(steps) {
+    return steps.map(({ step, detail }) => ({
+      strip: 'comments stripped',
+      elide: `${detail} elided`,
+      chunk: `cut to ${detail}`
+    })[step]).join(', ');
+  }
+
+  /**
+   * Console report of rebalanced pins
+   * @param {Array<Object>} results - { file, limit, ...rebalance() }
+   * @returns {string}
+   */
+  static formatReport(results) {
+    const lines = ['', '⚖️  PIN REBALANCING', '='.repeat(80)];
+    for (const { file, limit, fits, tokens, fullTokens, steps } of results) {
+      const trail = steps.map(({ step, tokens: after }) => `${step} ${after.toLocaleString()}`).join(' → ');
+      lines.push(`   ${file}: ${fullTokens.toLocaleString()} tokens${trail ? ` → ${trail}` : ''}`);
+      lines.push(fits
+        ? `     fits the ${limit.toLocaleString()}-token budget: ${PinRebalancer.describeSteps(steps)}`
+        : `     still ${tokens.toLocaleString()} tokens over a ${limit.toLocaleString()}-token budget; dropped`);
+    }
+    if (results.length === 0) {
+      lines.push('   Every pinned file fits the budget');
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * One degradation; null when it does not apply to the file
+   * @private
+   */
+  apply(step, content, relativePath, limit) {
+    if (step === 'strip') {
+      return { content: this.stripper.strip(content, relativePath), detail: null };
+    }
+    if (step === 'elide') {
+      const elided = this.elider.elide(content, relativePath);
+      return elided && { content: elided.content, detail: `${elided.bodies} ${elided.bodies === 1 ? 'body' : 'bodies'}` };
+    }
+
+    const parts = new FileSplitter({
+      maxTokens: limit,
+      countTokens: this.options.countTokens,
+      extractor: this.options.extractor
+    }).split(content, relativePath);
+    if (parts.length < 2) return null;
+    const [first] = parts;
+    return {
+      content: content.split('\n').slice(first.startLine - 1, first.endLine).join('\n'),
+      detail: `lines ${first.startLine}-${first.endLine} (part 1 of ${first.total})`
+    };
+  }
+}
+
+export default PinRebalancer;
@@ -0,0 +1,214 @@
+/**
+ * Pprof - V8 profiles in pprof format
+ * v3.4.0 - Benchmark profiling (ctxman bench)
+ *
+ * Responsibilities:
+ * - Convert inspector CPU profiles and sampling heap profiles to pprof's
+ *   profile.proto message, gzip-compressed as `go tool pprof` reads it
+ * - Encode the protobuf fields pprof needs without a protobuf dependency
+ */
+
+import zlib from 'zlib';
+
+/**
+ * CPU profile (Profiler.stop) as gzipped pprof: samples/count and cpu/nanoseconds per stack
+ * @param {Object} profile - { nodes, samples, timeDeltas, startTime, endTime } (microseconds)
+ * @returns {Buffer}
+ */
+export function cpuProfileToPprof(profile) {
+  const frames = new Map();
+  const parents = new Map();
+  for (const node of profile.nodes) {
+    frames.set(node.id, node.callFrame);
+    for (const childId of node.children || []) parents.set(childId, node.id);
+  }
+
+  const values = new Map();
+  (profile.samples || []).forEach((nodeId, i) => {
+    const value = values.get(nodeId) || [0, 0];
+    value[0] += 1;
+    value[1] += Math.max(0, profile.timeDeltas?.[i] || 0) * 1000;
+    values.set(nodeId, value);
+  });
+
+  const durationNanos = Math.max(0, (profile.endTime - profile.startTime) * 1000);
+  const sampleCount = profile.samples?.length || 0;
+  return encode({
+    sampleTypes: [['samples', 'count'], ['cpu', 'nanoseconds']],
+    periodType: ['cpu', 'nanoseconds'],
+    period: sampleCount > 0 ? Math.round(durationNanos / sampleCount) : 0,
+    durationNanos,
+    frames,
+    parents,
+    values
+  });
+}
+
+/**
+ * Sampling heap profile (HeapProfiler.stopSampling) as gzipped pprof:
+ * objects/count and space/bytes allocated per stack
+ * @param {Object} profile - { head, samples? }
+ * @returns {Buffer}
+ */
+export function heapProfileToPprof(profile) {
+  const frames = new Map();
+  const parents = new Map();
+  const values = new Map();
+
+  const counts = new Map();
+  for (const sample of profile.samples || []) {
+    counts.set(sample.nodeId, (counts.get(sample.nodeId) || 0) + 1);
+  }
+
+  const stack = [profile.head];
+  while (stack.length > 0) {
+    const node = stack.pop();
+    frames.set(node.id, node.callFrame);
+    if (node.selfSize > 0) values.set(node.id, [counts.get(node.id) || 1, node.selfSize]);
+    for (const child of node.children || []) {
+      parents.set(child.id, node.id);
+      stack.push(child);
+    }
+  }
+
+  return encode({
+    sampleTypes: [['objects', 'count'], ['space', 'bytes']],
+    periodType: ['space', 'bytes'],
+    period: 0,
+    durationNanos: 0,
+    frames,
+    parents,
+    values
+  });
+}
+
+/**
+ * @private
+ */
+function encode({ sampleTypes, periodType, period, durationNanos, frames, parents, values }) {
+  const strings = [''];
+  const stringIndex = new Map([['', 0]]);
+  const string = text => {
+    if (!stringIndex.has(text)) {
+      stringIndex.set(text, strings.length);
+      strings.push(text);
+    }
+    return stringIndex.get(text);
+  };
+
+  const functions = new Map(); // name|file|line -> { id, name, file, startLine }
+  const locations = new Map(); // functionId:line -> { id, functionId, line }
+  const locationOf = frame => {
+    const name = frame.functionName || '(anonymous)';
+    const file = (frame.url || '').replace(/^file:\/\//, '');
+    const line = Math.max(0, (frame.lineNumber ?? -1) + 1);
+    const functionKey = `${name}|${file}|${line}`;
+    if (!functions.has(functionKey)) {
+      functions.set(functionKey, { id: functions.size + 1, name: string(name), file: string(file), startLine: line });
+    }
+    const functionId = functions.get(functionKey).id;
+    const locationKey = `${functionId}:${line}`;
+    if (!locations.has(locationKey)) {
+      locations.set(locationKey, { id: locations.size + 1, functionId, line });
+    }
+    return locations.get(locationKey).id;
+  };
+
+  const samples = [];
+  for (const [nodeId, value] of values) {
+    const stack = [];
+    for (let id = nodeId; id !== undefined; id = parents.get(id)) {
+      const frame = frames.get(id);
+      // The synthetic (root) node has no code of its own
+      if (frame && frame.functionName !== '(root)') stack.push(locationOf(frame));
+    }
+    if (stack.length > 0) samples.push({ stack, value });
+  }
+
+  const profile = new ProtoWriter();
+  for (const [type, unit] of sampleTypes) {
+    profile.message(1, new ProtoWriter().varint(1, string(type)).varint(2, string(unit)));
+  }
+  for (const { stack, value } of samples) {
+    profile.message(2, new ProtoWriter().packed(1, stack).packed(2, value.map(Math.round)));
+  }
+  for (const location of locations.values()) {
+    profile.message(4, new ProtoWriter()
+      .varint(1, location.id)
+      .message(4, new ProtoWriter().varint(1, location.functionId).varint(2, location.line)));
+  }
+  for (const fn of functions.values()) {
+    profile.message(5, new ProtoWriter()
+      .varint(1, fn.id).varint(2, fn.name).varint(3, fn.name).varint(4, fn.file).varint(5, fn.startLine));
+  }
+  const periodTypeMessage = new ProtoWriter().varint(1, string(periodType[0])).varint(2, string(periodType[1]));
+  for (const text of strings) profile.string(6, text);
+  profile.varint(9, Date.now() * 1e6).varint(10, Math.round(durationNanos));
+  profile.message(11, periodTypeMessage).varint(12, period);
+
+  return zlib.gzipSync(profile.toBuffer());
+}
+
+/**
+ * Minimal protobuf writer: varint and length-delimited fields
+ * @private
+ */
+class ProtoWriter {
+  constructor() {
+    this.chunks = [];
+  }
+
+  varint(field, value) {
+    if (!value) return this;
+    this.tag(field, 0);
+    this.raw(value);
+    return this;
+  }
+
+  packed(field, values) {
+    const body = new ProtoWriter();
+    values.forEach(value => body.raw(value));
+    return this.bytes(field, body.toBuffer());
+  }
+
+  string(field, text) {
+    // The string table keeps empty strings: entry 0 must be ""
+    return this.bytes(field, Buffer.from(text, 'utf8'), true);
+  }
+
+  message(field, writer) {
+    return this.bytes(field, writer.toBuffer(), true);
+  }
+
+  bytes(field, buffer, keepEmpty = false) {
+    if (buffer.length === 0 && !keepEmpty) return this;
+    this.tag(field, 2);
+    this.raw(buffer.length);
+    this.chunks.push(buffer);
+    return this;
+  }
+
+  tag(field, wireType) {
+    this.raw(field * 8 + wireType);
+  }
+
+  /**
+   * Unsigned varint; values stay below 2^53, so plain arithmetic is exact
+   */
+  raw(value) {
+    const bytes = [];
+    let rest = Math.max(0, Math.floor(value));
+    while (rest >= 0x80) {
+      bytes.push((rest % 0x80) | 0x80);
+      rest = Math.floor(rest / 0x80);
+    }
+    bytes.push(rest);
+    this.chunks.push(Buffer.from(bytes));
+  }
+
+  toBuffer() {
+    return Buffer.concat(this.chunks);
+  }
+}
+
+export default { cpuProfileToPprof, heapProfileToPprof };
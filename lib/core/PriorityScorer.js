@@ -0,0 +1,247 @@
+/**
+ * PriorityScorer - Weighted file priority scoring
+ * v3.4.0 - Priority scoring engine
+ *
+ * Responsibilities:
+ * - Combine signals into one priority per file: the path heuristic, recent
+ *   git churn, import fan-in, path depth, file size, user pins, entry
+ *   points with the files they import, and risk from test coverage and
+ *   static-analysis findings
+ * - Normalize each signal to 0..1 across the scored files and weight it
+ * - Keep the per-signal breakdown for --explain
+ */
+
+import path from 'path';
+import TokenBudget from './TokenBudget.js';
+import RiskOverlay from './RiskOverlay.js';
+import GitIgnoreParser from '../parsers/gitignore-parser.js';
+import { GitClient } from '../integrations/git/GitClient.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('PriorityScorer');
+
+export const PRIORITY_SIGNALS = ['path', 'churn', 'fanIn', 'depth', 'size', 'pins', 'entry', 'risk'];
+
+// A pinned file outranks any unpinned one (the other weights sum to 70 points)
+export const DEFAULT_WEIGHTS = { path: 1, churn: 1, fanIn: 1, depth: 0.5, size: 0.5, pins: 8, entry: 1, risk: 2 };
+
+// Points per unit of weight for a signal at its maximum
+const SCALE = 10;
+
+export class PriorityScorer {
+  constructor(options = {}) {
+    this.options = {
+      weights: {}, // Overrides of DEFAULT_WEIGHTS
+      pins: [], // Globs of files to pack first
+      churnDays: 90, // Git history window of the churn signal
+      ...options
+    };
+
+    this.weights = { ...DEFAULT_WEIGHTS, ...this.options.weights };
+    const parser = new GitIgnoreParser(null, null, null);
+    this.pins = this.options.pins.map(pattern => ({ pattern, regex: parser.convertToRegex(pattern).regex }));
+  }
+
+  /**
+   * Parse weights given as a list such as "churn=2,fanin=1,size=0" or as a mapping
+   * @param {string|Object} spec
+   * @returns {Object} Weights keyed by signal
+   * @throws {Error} For unknown signals and non-numeric weights
+   */
+  static parseWeights(spec) {
+    const entries = typeof spec === 'string'
+      ? spec.split(',').map(part => part.trim()).filter(Boolean).map(entry => entry.split('=').map(part => part.trim()))
+      : Object.entries(spec);
+
+    const weights = {};
+    for (const [name, value] of entries) {
+      const signal = PRIORITY_SIGNALS.find(candidate => candidate.toLowerCase() === name.toLowerCase().replace(/[-_]/g, ''));
+      if (!signal) {
+        throw new Error(`Unknown priority signal: ${name} (expected ${PRIORITY_SIGNALS.join(', ')})`);
+      }
+      const weight = typeof value === 'number' ? value : Number(value);
+      if (value === undefined || value === null || value === '' || !Number.isFinite(weight)) {
+        throw new Error(`Invalid weight for ${signal}: ${value ?? ''} (e.g. ${signal}=2)`);
+      }
+      weights[signal] = weight;
+    }
+    return weights;
+  }
+
+  /**
+   * Whether a signal counts toward the score (and needs collecting)
+   * @param {string} signal
+   * @returns {boolean}
+   */
+  uses(signal) {
+    return this.weights[signal] !== 0;
+  }
+
+  /**
+   * First pin glob matching a file
+   * @param {string} relativePath - '/'-separated path
+   * @returns {string|null}
+   */
+  pinOf(relativePath) {
+    return this.pins.find(pin => pin.regex.test(relativePath))?.pattern ?? null;
+  }
+
+  /**
+   * Score files
+   * @param {Array<{relativePath: string, tokens: number, pinned?: boolean}>} files
+   * @param {Object} context - { churn: Map<path, commits>, fanIn: Map<path, importers>,
+   *   entry: Map<path, {kinds}|{importedBy}> (see EntryPointDetector.withDependencies),
+   *   risk: Map<path, {coverage, severity}> (see RiskOverlay.forFiles) }
+   * @returns {Map<string, {score: number, signals: Object}>} Keyed by relativePath;
+   *   signals[name] = { raw, value, weight, points }
+   */
+  score(files, context = {}) {
+    const raw = files.map(fileInfo => {
+      const relativePath = fileInfo.relativePath.split(path.sep).join('/');
+      return {
+        id: fileInfo.relativePath,
+        path: TokenBudget.filePriority(relativePath),
+        churn: context.churn?.get(relativePath) ?? 0,
+        fanIn: context.fanIn?.get(relativePath) ?? 0,
+        depth: relativePath.split('/').length - 1,
+        size: fileInfo.tokens || 0,
+        // A //ctx:pin directive counts as a pin of the file itself, as do pinned slices
+        pins: this.pinOf(relativePath) ?? (fileInfo.pinned ? fileInfo.pinnedBy || 'ctx:pin' : null),
+        entry: context.entry?.get(relativePath) ?? null,
+        risk: context.risk?.get(relativePath) ?? null
+      };
+    });
+
+    const range = signal => {
+      const values = raw.map(entry => entry[signal]);
+      return { min: Math.min(...values), max: Math.max(...values) };
+    };
+    const relative = (value, { min, max }) => (max > min ? (value - min) / (max - min) : 0);
+
+    const ranges = {
+      path: range('path'),
+      churn: range('churn'),
+      fanIn: range('fanIn'),
+      depth: range('depth'),
+      size: { min: 0, max: Math.log1p(range('size').max) },
+      severity: Math.max(0, ...raw.map(entry => entry.risk?.severity ?? 0))
+    };
+
+    // Higher is better for every value: shallow and small files rank up
+    const normalizers = {
+      path: entry => relative(entry.path, ranges.path),
+      churn: entry => (ranges.churn.max > 0 ? entry.churn / ranges.churn.max : 0),
+      fanIn: entry => (ranges.fanIn.max > 0 ? entry.fanIn / ranges.fanIn.max : 0),
+      depth: entry => 1 - relative(entry.depth, ranges.depth),
+      size: entry => (ranges.size.max > 0 ? 1 - Math.log1p(entry.size) / ranges.size.max : 1),
+      pins: entry => (entry.pins ? 1 : 0),
+      // Direct dependencies of an entry point count half
+      entry: entry => (entry.entry?.kinds ? 1 : entry.entry ? 0.5 : 0),
+      // The uncovered share or the relative weight of open findings, whichever is higher
+      risk: entry => Math.max(
+        typeof entry.risk?.coverage === 'number' ? 1 - entry.risk.coverage : 0,
+        ranges.severity > 0 ? (entry.risk?.severity ?? 0) / ranges.severity : 0)
+    };
+
+    const scores = new Map();
+    for (const entry of raw) {
+      const signals = {};
+      let score = 0;
+      for (const signal of PRIORITY_SIGNALS) {
+        const weight = this.weights[signal];
+        const value = round(normalizers[signal](entry));
+        const points = round(weight * value * SCALE);
+        signals[signal] = { raw: entry[signal], value, weight, points };
+        score += points;
+      }
+      scores.set(entry.id, { score: round(score), signals });
+    }
+    return scores;
+  }
+
+  /**
+   * Commits touching each file within the last `days` days
+   * @param {string} root - Repository root
+   * @param {number} days
+   * @returns {Map<string, number>} Empty outside a git repository
+   */
+  static churnOf(root, days = 90) {
+    const client = new GitClient(root);
+    if (!client.isGitRepo) return new Map();
+
+    try {
+      return client.getChurn(days);
+    } catch (error) {
+      logger.debug(`No churn signal: ${error.message}`);
+      return new Map();
+    }
+  }
+
+  /**
+   * Distinct files importing each file (a package import counts for all its files)
+   * @param {DependencyGraph} graph
+   * @returns {Map<string, number>}
+   */
+  static fanInOf(graph) {
+    const importers = new Map();
+    for (const file of graph.files.values()) {
+      for (const imported of file.imports) {
+        if (!imported.target) continue;
+        const targets = graph.files.has(imported.target)
+          ? [imported.target]
+          : graph.getPackage(imported.package)?.files || [];
+        for (const target of targets) {
+          if (target === file.path) continue;
+          if (!importers.has(target)) importers.set(target, new Set());
+          importers.get(target).add(file.path);
+        }
+      }
+    }
+    return new Map([...importers].map(([target, files]) => [target, files.size]));
+  }
+
+  /**
+   * Score breakdown report, highest priority first
+   * @param {Array<Object>} files - Files with their final priority
+   * @param {Map<string, Object>} scores - Result of score()
+   * @returns {string}
+   */
+  static formatExplanation(files, scores) {
+    const describe = {
+      path: raw => `heuristic ${raw}`,
+      churn: raw => `${raw} commit${raw === 1 ? '' : 's'}`,
+      fanIn: raw => `${raw} importer${raw === 1 ? '' : 's'}`,
+      depth: raw => `${raw} dir${raw === 1 ? '' : 's'} deep`,
+      size: raw => `${raw.toLocaleString()} tokens`,
+      pins: raw => (raw ? `pinned by ${raw}` : 'not pinned'),
+      entry: raw => (raw?.kinds ? `${raw.kinds.join('/')} entry point` : raw ? `imported by ${raw.importedBy}` : 'not an entry point'),
+      risk: raw => RiskOverlay.describe(raw)
+    };
+
+    const lines = ['', '🧮 PRIORITY SCORES', '='.repeat(80)];
+    const ranked = files
+      .filter(fileInfo => scores.has(fileInfo.relativePath))
+      .sort((a, b) => b.priority - a.priority);
+
+    for (const fileInfo of ranked) {
+      const { score, signals } = scores.get(fileInfo.relativePath);
+      const override = fileInfo.priority !== score ? ` (score ${score.toFixed(2)} overridden)` : '';
+      lines.push(`   ${fileInfo.priority.toFixed(2).padStart(7)}  ${fileInfo.relativePath}${override}`);
+      lines.push('            ' + PRIORITY_SIGNALS
+        .filter(signal => signals[signal].weight !== 0)
+        .map(signal => `${signal} ${signals[signal].points.toFixed(2)} (${describe[signal](signals[signal].raw)}, ×${signals[signal].weight})`)
+        .join(' · '));
+    }
+
+    if (ranked.length === 0) {
+      lines.push('   No files to score');
+    }
+    return lines.join('\n');
+  }
+}
+
+function round(value) {
+  return Math.round(value * 100) / 100;
+}
+
+export default PriorityScorer;
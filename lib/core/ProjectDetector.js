@@ -0,0 +1,239 @@
+/**
+ * ProjectDetector - Project boundaries inside a monorepo
+ * v3.4.0 - Per-project bundles (--per-project)
+ *
+ * Responsibilities:
+ * - Find the projects of a repository: Go modules (go.mod), npm packages
+ *   (package.json, limited to the root's workspaces when it declares them)
+ *   and Bazel packages (BUILD, BUILD.bazel) in a Bazel workspace
+ * - Assign each file to the innermost project around it; files outside
+ *   every project form the root project
+ * - Name the other projects each one depends on, from go.mod requires,
+ *   package.json dependencies and Bazel labels
+ * - Render the overview shared by every per-project bundle
+ *
+ * A directory holding several markers is one project, named by the first
+ * of go.mod, package.json and BUILD. Bazel packages only count when the
+ * root has a MODULE.bazel, WORKSPACE or WORKSPACE.bazel file.
+ */
+
+import path from 'path';
+import DependencySummary from './DependencySummary.js';
+import TokenUtils from '../utils/token-utils.js';
+import { compareBytes } from '../utils/ordering.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ProjectDetector');
+
+export const PROJECTS_TITLE = 'PROJECTS';
+
+// Markers in the order they name a project
+export const PROJECT_MARKERS = [
+  { file: 'go.mod', kind: 'go module' },
+  { file: 'package.json', kind: 'npm package' },
+  { file: 'BUILD.bazel', kind: 'bazel package' },
+  { file: 'BUILD', kind: 'bazel package' }
+];
+
+const BAZEL_WORKSPACES = ['MODULE.bazel', 'WORKSPACE', 'WORKSPACE.bazel'];
+
+// "//path/to/pkg" and "//path/to/pkg:target" labels of the main repository
+const BAZEL_LABEL = /"\/\/([\w./-]*)(?::[^"]*)?"/g;
+
+export class ProjectDetector {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      bazel: null, // Bazel packages: null detects a Bazel workspace at the root
+      ...options
+    };
+  }
+
+  /**
+   * Projects of a repository
+   * @param {Array<string>} files - '/'-separated paths of the files to assign
+   * @param {Function} read - (relativePath) => content; throws when the file is missing
+   * @returns {Array<Object>} Projects { name, kind, dir, manifest, files, dependsOn }, by dir;
+   *   dir is '' for the root, dependsOn the names of other projects
+   */
+  detect(files, read) {
+    const exists = file => {
+      try {
+        read(file);
+        return true;
+      } catch {
+        return false;
+      }
+    };
+    const join = (dir, name) => (dir ? `${dir}/${name}` : name);
+
+    const dirs = new Set(['']);
+    for (const file of files) {
+      for (let dir = path.posix.dirname(file); dir !== '.'; dir = path.posix.dirname(dir)) dirs.add(dir);
+    }
+
+    const bazel = this.options.bazel ?? BAZEL_WORKSPACES.some(exists);
+    const workspaces = this.npmWorkspaces(read);
+    const inWorkspace = dir => !workspaces || dir === '' ||
+      (workspaces.include.some(glob => glob.test(dir)) && !workspaces.exclude.some(glob => glob.test(dir)));
+
+    const projects = [];
+    for (const dir of [...dirs].sort(compareBytes)) {
+      const marker = PROJECT_MARKERS.find(({ file, kind }) => (kind !== 'bazel package' || bazel) &&
+        (file !== 'package.json' || inWorkspace(dir)) && exists(join(dir, file)));
+      if (marker) {
+        projects.push({ ...this.describe(dir, join(dir, marker.file), marker, read), files: [], dependsOn: [] });
+      }
+    }
+    if (!projects.some(project => project.dir === '')) {
+      projects.unshift({ name: '(root)', kind: 'files', dir: '', manifest: null, references: [], files: [], dependsOn: [] });
+    }
+
+    for (const file of files) {
+      ProjectDetector.projectOf(projects, file).files.push(file);
+    }
+    for (const project of projects) {
+      project.dependsOn = this.dependenciesOf(project, projects);
+      delete project.references;
+    }
+
+    logger.debug(`${projects.length} projects: ${projects.map(project => project.dir || '.').join(', ')}`);
+    return projects;
+  }
+
+  /**
+   * Innermost project around a file
+   * @param {Array<Object>} projects - Result of detect()
+   * @param {string} file - '/'-separated path
+   * @returns {Object}
+   */
+  static projectOf(projects, file) {
+    let best = projects.find(project => project.dir === '');
+    for (const project of projects) {
+      if (project.dir && file.startsWith(`${project.dir}/`) && project.dir.length > best.dir.length) best = project;
+    }
+    return best;
+  }
+
+  /**
+   * File name part of a project's bundle, e.g. services-api
+   * @param {Object} project
+   * @returns {string}
+   */
+  static slug(project) {
+    return project.dir ? project.dir.replace(/[^\w.-]+/g, '-') : 'root';
+  }
+
+  /**
+   * Console report of detect()
+   * @param {Array<Object>} projects - With tokens when counted
+   * @returns {string}
+   */
+  static formatProjects(projects) {
+    const lines = ['', '🏗️  PROJECTS', '='.repeat(80)];
+    for (const project of projects.filter(project => project.files.length > 0)) {
+      lines.push(`   ${describeProject(project)}`);
+    }
+    if (!projects.some(project => project.files.length > 0)) {
+      lines.push('   No files to bundle');
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * Overview section heading every bundle: all projects, their sizes and
+   * dependencies, with the bundle's own project marked
+   * @param {Array<Object>} projects - With tokens when counted
+   * @param {Object} current - The bundle's project
+   * @returns {string}
+   */
+  static formatOverview(projects, current) {
+    const lines = ['='.repeat(48), PROJECTS_TITLE, '='.repeat(48)];
+    lines.push(`This bundle: ${current.name} (${current.dir || '.'})`);
+    lines.push('');
+    for (const project of projects.filter(project => project.files.length > 0)) {
+      lines.push(`${project === current ? '*' : '-'} ${describeProject(project)}`);
+    }
+    return lines.join('\n') + '\n';
+  }
+
+  /**
+   * Name and outgoing references of a project's manifest
+   * @private
+   */
+  describe(dir, manifest, marker, read) {
+    const fallback = dir ? path.posix.basename(dir) : '(root)';
+    if (marker.kind === 'bazel package') {
+      const content = read(manifest);
+      const references = [...content.matchAll(BAZEL_LABEL)].map(match => match[1].replace(/\/+$/, ''));
+      return { name: `//${dir}`, kind: marker.kind, dir, manifest, references };
+    }
+
+    const [entry] = new DependencySummary().summarize([manifest], read);
+    if (entry.error) logger.warn(`${manifest}: ${entry.error}`);
+    const references = [
+      ...entry.dependencies.map(dependency => dependency.name),
+      ...entry.pins.filter(pin => pin.kind === 'replace').map(pin => pin.name.split(' ')[0])
+    ];
+    return { name: entry.name || fallback, kind: marker.kind, dir, manifest, references };
+  }
+
+  /**
+   * Projects a project's manifest refers to
+   * @private
+   */
+  dependenciesOf(project, projects) {
+    const names = new Set(project.kind === 'bazel package'
+      ? projects.filter(other => other.kind === 'bazel package' && project.references.includes(other.dir)).map(other => other.name)
+      : projects.filter(other => other.kind === project.kind && project.references.includes(other.name)).map(other => other.name));
+    names.delete(project.name);
+    return [...names].sort(compareBytes);
+  }
+
+  /**
+   * Directory patterns of the root package.json's workspaces; null without any
+   * @private
+   */
+  npmWorkspaces(read) {
+    let data;
+    try {
+      data = JSON.parse(read('package.json'));
+    } catch {
+      return null;
+    }
+    const globs = Array.isArray(data.workspaces) ? data.workspaces : data.workspaces?.packages;
+    if (!Array.isArray(globs) || globs.length === 0) return null;
+
+    const patterns = globs.filter(glob => typeof glob === 'string').map(glob => glob.replace(/^\.\//, '').replace(/\/+$/, ''));
+    return {
+      include: patterns.filter(glob => !glob.startsWith('!')).map(globRegex),
+      exclude: patterns.filter(glob => glob.startsWith('!')).map(glob => globRegex(glob.slice(1)))
+    };
+  }
+}
+
+/**
+ * One line describing a project
+ * @private
+ */
+function describeProject(project) {
+  const size = `${project.files.length} ${project.files.length === 1 ? 'file' : 'files'}` +
+    (project.tokens !== undefined ? `, ${TokenUtils.format(project.tokens)} tokens` : '');
+  const uses = project.dependsOn.length > 0 ? `; uses ${project.dependsOn.join(', ')}` : '';
+  return `${project.name} (${project.kind}, ${project.dir || '.'}): ${size}${uses}`;
+}
+
+/**
+ * Directory glob of a workspace: * within a path segment, ** across them
+ * @private
+ */
+function globRegex(glob) {
+  const source = glob.split('/').map(segment => (segment === '**'
+    ? '.*'
+    : segment.split('*').map(part => part.replace(/[.+?^${}()|[\]\\]/g, '\\$&')).join('[^/]*'))).join('/');
+  return new RegExp(`^${source}$`);
+}
+
+export default ProjectDetector;
@@ -0,0 +1,264 @@
+/**
+ * ProjectDocs - Documentation awareness
+ * v3.4.0 - Docs first in the context (--docs)
+ *
+ * Responsibilities:
+ * - Recognize READMEs, ARCHITECTURE.md, architecture decision records (ADRs)
+ *   and Go package docs (doc.go)
+ * - Order them for the top of the context: project overview first
+ * - Link each code file to the docs that describe it: the package doc and
+ *   closest README of its directory, and ADRs that mention it
+ * - Summarize docs to their title, opening paragraph and outline
+ */
+
+import path from 'path';
+
+export const DOCS_MODES = ['full', 'summary'];
+
+export const DocKind = Object.freeze({
+  README: 'readme',
+  ARCHITECTURE: 'architecture',
+  PACKAGE: 'package-doc',
+  ADR: 'adr'
+});
+
+// Order at the top of the context, after the root README and ARCHITECTURE.md
+const KIND_ORDER = [DocKind.README, DocKind.ARCHITECTURE, DocKind.PACKAGE, DocKind.ADR];
+
+const README = /^readme(\.(md|markdown|mdx|rst|txt|adoc))?$/i;
+const ARCHITECTURE = /^architecture\.(md|markdown|mdx|rst|txt|adoc)$/i;
+const ADR_DIR = /(^|\/)(adrs?|decisions)\/[^/]+\.(md|markdown|rst|txt)$/i;
+const ADR_FILE = /^adr[-_]?\d+.*\.(md|markdown|rst|txt)$/i;
+
+// Characters of the opening paragraph kept in a summary
+const SUMMARY_PARAGRAPH_CHARS = 600;
+
+export class ProjectDocs {
+  /**
+   * @param {Array<string>} files - '/'-separated paths of the analyzed files
+   * @param {Object} options
+   */
+  constructor(files, options = {}) {
+    this.options = {
+      read: null, // (relativePath) => content; ADRs are linked to the code they mention
+      ...options
+    };
+
+    this.docs = files
+      .map(file => ({ path: file, kind: ProjectDocs.kindOf(file), dir: path.posix.dirname(file) }))
+      .filter(doc => doc.kind)
+      .sort(compareDocs);
+    this.ranks = new Map(this.docs.map((doc, rank) => [doc.path, rank]));
+    this.byDir = new Map();
+    for (const doc of this.docs) {
+      if (doc.kind !== DocKind.README && doc.kind !== DocKind.PACKAGE) continue;
+      if (!this.byDir.has(doc.dir)) this.byDir.set(doc.dir, []);
+      this.byDir.get(doc.dir).push(doc);
+    }
+    this.mentions = null;
+  }
+
+  /**
+   * @param {string} relativePath - '/'-separated
+   * @returns {string|null} DocKind value, or null for other files
+   */
+  static kindOf(relativePath) {
+    const name = path.posix.basename(relativePath);
+    if (README.test(name)) return DocKind.README;
+    if (ARCHITECTURE.test(name)) return DocKind.ARCHITECTURE;
+    if (name === 'doc.go') return DocKind.PACKAGE;
+    if (ADR_DIR.test(relativePath) || ADR_FILE.test(name)) return DocKind.ADR;
+    return null;
+  }
+
+  /**
+   * Position of a doc at the top of the context; lower comes first
+   * @param {string} relativePath
+   * @returns {number} 0 for the project README, -1 for files that are not docs
+   */
+  rankOf(relativePath) {
+    return this.ranks.get(relativePath) ?? -1;
+  }
+
+  /**
+   * Docs that orient a reader in the whole project: the READMEs and
+   * ARCHITECTURE.md at the root
+   * @returns {Array<string>}
+   */
+  overview() {
+    return this.docs
+      .filter(doc => doc.dir === '.' && (doc.kind === DocKind.README || doc.kind === DocKind.ARCHITECTURE))
+      .map(doc => doc.path);
+  }
+
+  /**
+   * Docs describing a code file, most specific first
+   * Root docs are left out: they describe every file and lead the context anyway.
+   * @param {string} relativePath - '/'-separated
+   * @returns {Array<string>}
+   */
+  linksOf(relativePath) {
+    if (ProjectDocs.kindOf(relativePath)) return [];
+
+    const links = [];
+    let readme = null;
+    for (let dir = path.posix.dirname(relativePath); dir !== '.'; dir = path.posix.dirname(dir)) {
+      for (const doc of this.byDir.get(dir) || []) {
+        // The package doc of the file's own directory, and the closest README
+        if (doc.kind === DocKind.PACKAGE && dir === path.posix.dirname(relativePath)) links.push(doc.path);
+        if (doc.kind === DocKind.README && !readme) readme = doc.path;
+      }
+    }
+    if (readme) links.push(readme);
+
+    for (const [adr, mentioned] of this.adrMentions()) {
+      if (mentioned.some(target => relativePath === target || relativePath.startsWith(`${target}/`))) links.push(adr);
+    }
+    return links;
+  }
+
+  /**
+   * Short form of a doc: title, opening paragraph and section outline (for
+   * ADRs their status and decision)
+   * @param {string} content
+   * @param {string} relativePath
+   * @returns {string}
+   */
+  static summarize(content, relativePath) {
+    const kind = ProjectDocs.kindOf(relativePath);
+    if (kind === DocKind.PACKAGE) {
+      return summarizePackageDoc(content);
+    }
+
+    const lines = content.replace(/\r\n/g, '\n').split('\n');
+    const headings = [];
+    const sections = new Map(); // lowercase heading -> first paragraph
+    let title = null;
+    let intro = null;
+    let current = null;
+    let paragraph = [];
+    let fence = false;
+
+    const flush = () => {
+      const text = paragraph.join(' ').trim();
+      paragraph = [];
+      if (!text) return;
+      if (current === null && intro === null) intro = text;
+      if (current !== null && !sections.has(current)) sections.set(current, text);
+    };
+
+    for (const line of lines) {
+      if (/^\s*(```|~~~)/.test(line)) {
+        fence = !fence;
+        flush();
+        continue;
+      }
+      if (fence) continue;
+
+      const heading = /^(#{1,6})\s+(.+?)\s*#*\s*$/.exec(line);
+      if (heading) {
+        flush();
+        if (!title && heading[1].length === 1) {
+          title = heading[2];
+        } else {
+          headings.push(heading[2]);
+          current = heading[2].toLowerCase();
+        }
+        continue;
+      }
+      // Badges, images, HTML and tables are not prose
+      if (!line.trim() || /^\s*(!\[|\[!\[|<|\|)/.test(line)) {
+        flush();
+        continue;
+      }
+      paragraph.push(line.trim());
+    }
+    flush();
+
+    const summary = [`# ${title || path.posix.basename(relativePath)}`];
+    if (kind === DocKind.ADR) {
+      const status = sections.get('status');
+      const decision = sections.get('decision');
+      if (status) summary.push('', `Status: ${clip(status)}`);
+      if (decision) summary.push('', `Decision: ${clip(decision)}`);
+    }
+    if (intro) summary.push('', clip(intro));
+    if (headings.length > 0) summary.push('', `Sections: ${headings.join(' · ')}`);
+    return summary.join('\n') + '\n';
+  }
+
+  /**
+   * Header note naming the docs of a code file
+   * @param {Array<string>} links - From linksOf()
+   * @returns {string}
+   */
+  static formatNote(links) {
+    return `Docs: ${links.join(', ')}`;
+  }
+
+  /**
+   * Project paths each ADR mentions, read once
+   * @private
+   */
+  adrMentions() {
+    if (this.mentions) return this.mentions;
+
+    this.mentions = [];
+    if (!this.options.read) return this.mentions;
+
+    for (const doc of this.docs.filter(entry => entry.kind === DocKind.ADR)) {
+      let content;
+      try {
+        content = this.options.read(doc.path) || '';
+      } catch {
+        continue;
+      }
+      // Path-like words: src/auth, internal/store/cache.go, `lib/core/`
+      const mentioned = [...new Set([...content.matchAll(/[\w.-]+(?:\/[\w.-]+)+/g)]
+        .map(match => match[0].replace(/^\.\//, '').replace(/[/.]+$/, '')))]
+        .filter(Boolean);
+      if (mentioned.length > 0) this.mentions.push([doc.path, mentioned]);
+    }
+    return this.mentions;
+  }
+}
+
+/**
+ * The root README and ARCHITECTURE.md first, then READMEs, package docs and
+ * ADRs; shallower first
+ * @private
+ */
+function compareDocs(a, b) {
+  const depth = doc => (doc.dir === '.' ? 0 : doc.dir.split('/').length);
+  const overview = doc => (doc.dir === '.' && (doc.kind === DocKind.README || doc.kind === DocKind.ARCHITECTURE) ? 0 : 1);
+  return overview(a) - overview(b) || KIND_ORDER.indexOf(a.kind) - KIND_ORDER.indexOf(b.kind) ||
+    depth(a) - depth(b) || (a.path < b.path ? -1 : a.path > b.path ? 1 : 0);
+}
+
+/**
+ * First paragraph of the package comment of a doc.go, and the package clause
+ * @private
+ */
+function summarizePackageDoc(content) {
+  const lines = content.replace(/\r\n/g, '\n').split('\n');
+  const packageLine = lines.findIndex(line => /^package\s+\w+/.test(line));
+  const comment = [];
+  for (const line of lines.slice(0, packageLine === -1 ? lines.length : packageLine)) {
+    const text = line.replace(/^\s*(\/\/+|\/\*+|\*\/|\*)\s?/, '').replace(/\*\/\s*$/, '');
+    if (!text.trim() && comment.length > 0) break;
+    if (text.trim()) comment.push(text.trim());
+  }
+
+  const summary = comment.length > 0 ? [`// ${clip(comment.join(' '))}`] : [];
+  if (packageLine !== -1) summary.push(lines[packageLine].trim());
+  return summary.join('\n') + '\n';
+}
+
+/**
+ * @private
+ */
+function clip(text) {
+  return text.length > SUMMARY_PARAGRAPH_CHARS ? `${text.slice(0, SUMMARY_PARAGRAPH_CHARS).trimEnd()}…` : text;
+}
+
+export default ProjectDocs;
@@ -0,0 +1,279 @@
+/**
+ * RemoteSummarizer - File summaries from an LLM endpoint
+ * v3.4.0 - Rate-limited remote summarization
+ *
+ * Responsibilities:
+ * - Ask an OpenAI-compatible chat completions endpoint for a three-sentence
+ *   summary of each file
+ * - Cache summaries by content hash, endpoint and model, so unchanged files
+ *   are never sent twice
+ * - Summarize large files by content-defined chunks and combine the chunk
+ *   summaries, so a small edit only sends the chunks it changed (v3.4.0)
+ * - Bound concurrent requests and requests per minute; retry rate limits,
+ *   server errors and network failures with exponential backoff
+ * - Count prompt and completion tokens and their cost
+ */
+
+import path from 'path';
+import ContentCache from '../cache/ContentCache.js';
+import ContentChunker from '../cache/ContentChunker.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('RemoteSummarizer');
+
+export const DEFAULT_SUMMARY_MODEL = 'gpt-4o-mini';
+
+const OPENAI_BASE_URL = 'https://api.openai.com/v1';
+
+// USD per million prompt/completion tokens
+export const SUMMARY_PRICING = {
+  'gpt-4o-mini': { input: 0.15, output: 0.6 },
+  'gpt-4o': { input: 2.5, output: 10 },
+  'gpt-4.1': { input: 2, output: 8 },
+  'gpt-4.1-mini': { input: 0.4, output: 1.6 },
+  'gpt-4.1-nano': { input: 0.1, output: 0.4 }
+};
+
+const SYSTEM_PROMPT = 'You summarize source files for a code context given to another model. ' +
+  'Reply with exactly three sentences: what the file is for, its main exports or entry points, ' +
+  'and notable dependencies or side effects. No preamble, lists or Markdown.';
+
+const CHUNK_PROMPT = 'You summarize one part of a source file for a code context given to another model. ' +
+  'Reply with at most two sentences on what the part defines or does. No preamble, lists or Markdown.';
+
+const COMBINE_PROMPT = 'You combine summaries of the parts of a source file, given in order, into a summary ' +
+  'of the file for a code context given to another model. ' +
+  'Reply with exactly three sentences: what the file is for, its main exports or entry points, ' +
+  'and notable dependencies or side effects. No preamble, lists or Markdown.';
+
+export class RemoteSummarizer {
+  constructor(options = {}) {
+    this.options = {
+      baseUrl: process.env.OPENAI_BASE_URL || OPENAI_BASE_URL,
+      apiKey: process.env.OPENAI_API_KEY || '',
+      model: DEFAULT_SUMMARY_MODEL,
+      share: null,             // Lowest-priority share of files to summarize; null = what the budget cannot fit
+      minTokens: 200,          // Smaller files are kept whole
+      concurrency: 4,          // Requests in flight
+      requestsPerMinute: 0,    // 0 = no limit beyond concurrency
+      retries: 3,              // Per file, after the first attempt
+      retryDelay: 1000,        // ms, doubled per retry unless the server sends Retry-After
+      maxChars: 24000,         // Longer files are cut before they are sent
+      chunking: {},            // ContentChunker options; files over its maxChars are summarized by chunk; null = never
+      pricing: null,           // { input, output } USD per 1M tokens; null = SUMMARY_PRICING
+      cache: null,             // ContentCache; null = .ctxman/cache/content-cache.json under root
+      root: process.cwd(),
+      fetch: globalThis.fetch,
+      sleep: ms => new Promise(resolve => setTimeout(resolve, ms)),
+      ...options
+    };
+    this.options.baseUrl = this.options.baseUrl.replace(/\/+$/, '');
+    this.cache = this.options.cache || new ContentCache({ root: this.options.root });
+    this.chunker = this.options.chunking ? new ContentChunker(this.options.chunking) : null;
+    this.nextStart = 0;
+    this.stats = { files: 0, cached: 0, requested: 0, failed: 0, retries: 0, inputTokens: 0, outputTokens: 0, chunks: 0, chunksCached: 0 };
+  }
+
+  /**
+   * Whether the endpoint needs an API key that is missing (the OpenAI API
+   * always does; local endpoints usually do not)
+   * @returns {boolean}
+   */
+  missingKey() {
+    return !this.options.apiKey && this.options.baseUrl === OPENAI_BASE_URL;
+  }
+
+  /**
+   * Cache key of summaries from this endpoint and model
+   * @returns {string}
+   */
+  getKey() {
+    return `${this.options.baseUrl}:${this.options.model}`;
+  }
+
+  /**
+   * Summaries of files, cached ones first; files whose requests fail are left out
+   * @param {Array<Object>} files - { id, content }
+   * @returns {Promise<Map<string, string>>} Summary by id
+   */
+  async summarizeAll(files) {
+    const summaries = new Map();
+    const pending = [];
+    for (const file of files) {
+      const hash = ContentCache.hash(file.content);
+      const cached = this.cache.summary(hash, this.getKey());
+      if (cached !== null) {
+        summaries.set(file.id, cached);
+        this.stats.cached++;
+      } else {
+        pending.push({ ...file, hash });
+      }
+    }
+    this.stats.files += files.length;
+
+    let next = 0;
+    const worker = async () => {
+      while (next < pending.length) {
+        const file = pending[next++];
+        this.stats.requested++;
+        try {
+          const summary = this.chunker && file.content.length > this.chunker.options.maxChars
+            ? await this.summarizeChunks(file)
+            : await this.request(file);
+          this.cache.setSummary(file.hash, this.getKey(), summary);
+          summaries.set(file.id, summary);
+        } catch (error) {
+          this.stats.failed++;
+          logger.warn(`Summary of ${file.id} failed: ${error.message}`);
+        }
+      }
+    };
+    await Promise.all(Array.from({ length: Math.min(this.options.concurrency, pending.length) }, worker));
+
+    this.cache.save();
+    return summaries;
+  }
+
+  /**
+   * Cost of the requests made so far, or null for models without pricing
+   * @returns {number|null} USD
+   */
+  cost() {
+    const pricing = this.options.pricing || SUMMARY_PRICING[this.options.model];
+    if (!pricing) return null;
+    return (this.stats.inputTokens * pricing.input + this.stats.outputTokens * pricing.output) / 1e6;
+  }
+
+  /**
+   * Format a summarization report for the console
+   * @param {RemoteSummarizer} summarizer
+   * @param {number} savedTokens - Context tokens the summaries saved
+   * @returns {string}
+   */
+  static formatReport(summarizer, savedTokens = 0) {
+    const { stats, options } = summarizer;
+    const cost = summarizer.cost();
+    const lines = [];
+
+    lines.push('');
+    lines.push('🤖 REMOTE SUMMARIES');
+    lines.push('='.repeat(80));
+    lines.push(`   Model:     ${options.model} (${options.baseUrl})`);
+    lines.push(`   Files:     ${stats.files - stats.failed} summarized (${stats.cached} cached, ${stats.requested} requested` +
+      (stats.retries ? `, ${stats.retries} retries` : '') + ')' + (stats.failed ? `, ${stats.failed} failed and kept whole` : ''));
+    if (stats.chunks > 0) {
+      lines.push(`   Chunks:    ${stats.chunks} of large files (${stats.chunksCached} cached, ${stats.chunks - stats.chunksCached} requested)`);
+    }
+    lines.push(`   Usage:     ${stats.inputTokens.toLocaleString()} prompt + ${stats.outputTokens.toLocaleString()} completion tokens` +
+      (cost === null ? '' : `, $${cost.toFixed(4)}`));
+    lines.push(`   Saved:     ${savedTokens.toLocaleString()} context tokens`);
+
+    return lines.join('\n');
+  }
+
+  /**
+   * Summary of a large file from the summaries of its chunks; chunks are
+   * cached like files, so only changed ones are sent
+   * @private
+   */
+  async summarizeChunks(file) {
+    const name = file.id.split(path.sep).join('/');
+    const chunkKey = `${this.getKey()}#chunk`;
+    const chunks = this.chunker.split(file.content);
+    const summaries = [];
+
+    for (const [index, chunk] of chunks.entries()) {
+      this.stats.chunks++;
+      let summary = this.cache.summary(chunk.hash, chunkKey);
+      if (summary !== null) {
+        this.stats.chunksCached++;
+      } else {
+        summary = await this.send(CHUNK_PROMPT, `File: ${name} (part ${index + 1} of ${chunks.length})\n\n${chunk.text}`);
+        this.cache.setSummary(chunk.hash, chunkKey, summary);
+      }
+      summaries.push(summary);
+    }
+
+    const parts = summaries.map((summary, index) => `${index + 1}. ${summary}`).join('\n');
+    return this.send(COMBINE_PROMPT, `File: ${name}\n\n${parts}`);
+  }
+
+  /**
+   * Summary of one file in one request, cut at maxChars
+   * @private
+   */
+  async request(file) {
+    const content = file.content.length > this.options.maxChars
+      ? `${file.content.slice(0, this.options.maxChars)}\n[... truncated ...]`
+      : file.content;
+    return this.send(SYSTEM_PROMPT, `File: ${file.id.split(path.sep).join('/')}\n\n${content}`);
+  }
+
+  /**
+   * One chat completion, retrying what may succeed later
+   * @private
+   */
+  async send(system, user) {
+    const body = JSON.stringify({
+      model: this.options.model,
+      temperature: 0,
+      messages: [
+        { role: 'system', content: system },
+        { role: 'user', content: user }
+      ]
+    });
+    const headers = { 'Content-Type': 'application/json' };
+    if (this.options.apiKey) headers.Authorization = `Bearer ${this.options.apiKey}`;
+
+    for (let attempt = 0; ; attempt++) {
+      await this.throttle();
+
+      let response;
+      let failure;
+      try {
+        response = await this.options.fetch(`${this.options.baseUrl}/chat/completions`, { method: 'POST', headers, body });
+      } catch (error) {
+        failure = { retry: true, message: `request failed: ${error.message}` };
+      }
+
+      if (response?.ok) {
+        const data = await response.json();
+        this.stats.inputTokens += data.usage?.prompt_tokens || 0;
+        this.stats.outputTokens += data.usage?.completion_tokens || 0;
+        const summary = data.choices?.[0]?.message?.content?.trim();
+        if (!summary) throw new Error('empty response');
+        return summary;
+      }
+      if (response) {
+        const detail = (await response.text().catch(() => '')).slice(0, 200);
+        failure = {
+          retry: response.status === 429 || response.status >= 500,
+          message: `returned ${response.status}${detail ? `: ${detail}` : ''}`,
+          after: Number(response.headers?.get?.('retry-after')) * 1000 || 0
+        };
+      }
+
+      if (!failure.retry || attempt >= this.options.retries) {
+        throw new Error(failure.message);
+      }
+      this.stats.retries++;
+      await this.options.sleep(failure.after || this.options.retryDelay * 2 ** attempt);
+    }
+  }
+
+  /**
+   * Wait for the next request slot under requestsPerMinute
+   * @private
+   */
+  async throttle() {
+    const { requestsPerMinute } = this.options;
+    if (!requestsPerMinute) return;
+
+    const now = Date.now();
+    const start = Math.max(now, this.nextStart);
+    this.nextStart = start + 60000 / requestsPerMinute;
+    if (start > now) await this.options.sleep(start - now);
+  }
+}
+
+export default RemoteSummarizer;
@@ -0,0 +1,372 @@
+/**
+ * RiskOverlay - Test coverage and static-analysis findings per file
+ * v3.4.0 - Risk overlay (--coverage, --sarif, the risk priority signal)
+ *
+ * Responsibilities:
+ * - Read coverage profiles: LCOV (lcov.info), Go cover profiles
+ *   (go test -coverprofile) and Cobertura XML (coverage.py, JaCoCo converters)
+ * - Read SARIF 2.1 reports, keeping open findings only: results that are not
+ *   suppressed, not absent from the baseline and not passes
+ * - Map reported paths (absolute, file:// URIs, Go import paths, paths
+ *   relative to another checkout) onto project files
+ * - Render the overlay for the report
+ *
+ * A file covered by several reports keeps its best coverage; findings add up.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { fileURLToPath } from 'url';
+import { compareBytes } from '../utils/ordering.js';
+
+export const COVERAGE_FORMATS = ['lcov', 'go', 'cobertura'];
+
+// Weight of a finding by SARIF level
+export const FINDING_LEVELS = { error: 1, warning: 0.5, note: 0.2 };
+
+// SARIF result kinds that report a problem
+const OPEN_KINDS = ['fail', 'open', 'review'];
+
+export class RiskOverlay {
+  /**
+   * @param {string} projectRoot
+   */
+  constructor(projectRoot) {
+    this.projectRoot = path.resolve(projectRoot);
+    this.coverage = new Map(); // Reported path -> { covered, total }
+    this.findings = new Map(); // Reported path -> [{ level, ruleId, line, message }]
+    this.sources = []; // { file, kind: coverage format or 'sarif', files or findings }
+  }
+
+  /**
+   * Overlay of coverage profiles and SARIF reports
+   * @param {string} projectRoot
+   * @param {Object} reports - { coverage: Array<string>, sarif: Array<string> } file paths
+   * @returns {RiskOverlay}
+   * @throws {Error} For unreadable files and unknown formats
+   */
+  static load(projectRoot, { coverage = [], sarif = [] } = {}) {
+    const overlay = new RiskOverlay(projectRoot);
+    for (const file of coverage) {
+      overlay.addCoverage(read(file), file);
+    }
+    for (const file of sarif) {
+      overlay.addSarif(read(file), file);
+    }
+    return overlay;
+  }
+
+  /**
+   * Add a coverage profile
+   * @param {string} content
+   * @param {string} [source] - File name, for the report
+   * @throws {Error} When the format is not recognized
+   */
+  addCoverage(content, source = 'coverage') {
+    const format = detectCoverageFormat(content);
+    if (!format) {
+      throw new Error(`Unknown coverage format: ${source} (expected ${COVERAGE_FORMATS.join(', ')})`);
+    }
+    const parsed = { lcov: parseLcov, go: parseGoProfile, cobertura: parseCobertura }[format](content);
+    for (const [file, counts] of parsed) {
+      const key = this.reportedPath(file);
+      const known = this.coverage.get(key);
+      if (!known || ratio(counts) > ratio(known)) this.coverage.set(key, counts);
+    }
+    this.sources.push({ file: source, kind: format, files: parsed.size });
+  }
+
+  /**
+   * Add a SARIF report
+   * @param {string} content
+   * @param {string} [source] - File name, for the report
+   * @throws {Error} For invalid JSON and documents without runs
+   */
+  addSarif(content, source = 'sarif') {
+    let report;
+    try {
+      report = JSON.parse(content);
+    } catch (error) {
+      throw new Error(`Invalid SARIF report ${source}: ${error.message}`);
+    }
+    if (!Array.isArray(report.runs)) {
+      throw new Error(`Invalid SARIF report ${source}: no runs`);
+    }
+
+    let count = 0;
+    for (const run of report.runs) {
+      for (const finding of openFindings(run)) {
+        const key = this.reportedPath(finding.uri);
+        if (!this.findings.has(key)) this.findings.set(key, []);
+        this.findings.get(key).push(finding.finding);
+        count++;
+      }
+    }
+    this.sources.push({ file: source, kind: 'sarif', findings: count });
+  }
+
+  /**
+   * Risk of project files
+   * @param {Array<string>} files - '/'-separated project paths
+   * @returns {Map<string, {coverage: number|null, findings: Array, severity: number}>}
+   *   Files with coverage or findings; coverage is the covered share of lines
+   *   or statements, severity the findings weighted by FINDING_LEVELS
+   */
+  forFiles(files) {
+    const coverage = matchPaths(files, [...this.coverage.keys()]);
+    const findings = matchPaths(files, [...this.findings.keys()]);
+
+    const risk = new Map();
+    for (const file of files) {
+      const counts = coverage.has(file) ? this.coverage.get(coverage.get(file)) : null;
+      const found = findings.has(file) ? this.findings.get(findings.get(file)) : [];
+      if (!counts && found.length === 0) continue;
+      risk.set(file, {
+        coverage: counts && counts.total > 0 ? ratio(counts) : null,
+        findings: found,
+        severity: found.reduce((sum, finding) => sum + FINDING_LEVELS[finding.level], 0)
+      });
+    }
+    return risk;
+  }
+
+  /**
+   * Reported path in project terms where it can be: relative to the project
+   * for absolute paths and file:// URIs inside it, '/'-separated
+   * @private
+   */
+  reportedPath(reported) {
+    let file = reported.startsWith('file:') ? fileURLToPath(reported) : reported;
+    if (path.isAbsolute(file)) {
+      const relative = path.relative(this.projectRoot, file);
+      if (!relative.startsWith('..') && !path.isAbsolute(relative)) file = relative;
+    }
+    return path.posix.normalize(file.split(path.sep).join('/').replace(/\\/g, '/')).replace(/^\.\//, '');
+  }
+
+  /**
+   * Format the overlay as a console report
+   * @param {RiskOverlay} overlay
+   * @param {Map} risk - Result of forFiles() for the scored files
+   * @param {number} [limit] - Riskiest files listed
+   * @returns {string}
+   */
+  static format(overlay, risk, limit = 10) {
+    const lines = ['', '⚠️  RISK OVERLAY', '='.repeat(80)];
+    for (const source of overlay.sources) {
+      lines.push(source.kind === 'sarif'
+        ? `   Findings: ${source.findings} open in ${source.file}`
+        : `   Coverage: ${source.files} files in ${source.file} (${source.kind})`);
+    }
+
+    const ranked = [...risk]
+      .sort(([a, left], [b, right]) => right.severity - left.severity ||
+        (left.coverage ?? 1) - (right.coverage ?? 1) || compareBytes(a, b))
+      .slice(0, limit);
+    for (const [file, { coverage, findings }] of ranked) {
+      lines.push(`   ${describeRisk({ coverage, findings }).padEnd(28)} ${file}`);
+    }
+    if (risk.size === 0) {
+      lines.push('   No reported file is in the context');
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * Short description of a file's risk
+   * @param {Object|null} risk - Entry of forFiles()
+   * @returns {string}
+   */
+  static describe(risk) {
+    return risk ? describeRisk(risk) : 'no coverage or findings';
+  }
+}
+
+/**
+ * @private
+ */
+function read(file) {
+  try {
+    return fs.readFileSync(file, 'utf8');
+  } catch (error) {
+    throw new Error(`Cannot read ${file}: ${error.message}`);
+  }
+}
+
+/**
+ * @private
+ */
+function ratio({ covered, total }) {
+  return total > 0 ? covered / total : 0;
+}
+
+/**
+ * @private
+ */
+function describeRisk({ coverage, findings }) {
+  const parts = [];
+  if (coverage !== null) parts.push(`${Math.round(coverage * 100)}% covered`);
+  if (findings.length > 0) parts.push(`${findings.length} finding${findings.length === 1 ? '' : 's'}`);
+  return parts.join(', ');
+}
+
+/**
+ * @private
+ */
+function detectCoverageFormat(content) {
+  if (/^mode: (?:set|count|atomic)\s*$/m.test(content.split('\n', 1)[0])) return 'go';
+  if (/^SF:/m.test(content)) return 'lcov';
+  if (/<coverage\b/.test(content)) return 'cobertura';
+  return null;
+}
+
+/**
+ * Covered and instrumented lines per file of an LCOV trace
+ * @private
+ */
+function parseLcov(content) {
+  const files = new Map();
+  let current = null;
+  for (const line of content.split('\n').map(text => text.trim())) {
+    if (line.startsWith('SF:')) {
+      current = { file: line.slice(3), lines: new Map(), found: null, hit: null };
+    } else if (!current) {
+      continue;
+    } else if (line.startsWith('DA:')) {
+      const [number, hits] = line.slice(3).split(',');
+      current.lines.set(number, Math.max(current.lines.get(number) || 0, Number(hits) || 0));
+    } else if (line.startsWith('LF:')) {
+      current.found = Number(line.slice(3));
+    } else if (line.startsWith('LH:')) {
+      current.hit = Number(line.slice(3));
+    } else if (line === 'end_of_record') {
+      const total = current.found ?? current.lines.size;
+      const covered = current.hit ?? [...current.lines.values()].filter(hits => hits > 0).length;
+      files.set(current.file, { covered, total });
+      current = null;
+    }
+  }
+  return files;
+}
+
+/**
+ * Covered and total statements per file of a Go cover profile
+ * Blocks listed by several test binaries count once, covered if any covered them.
+ * @private
+ */
+function parseGoProfile(content) {
+  const blocks = new Map();
+  for (const line of content.split('\n').slice(1)) {
+    const match = line.trim().match(/^(.+):(\d+\.\d+,\d+\.\d+) (\d+) (\d+)$/);
+    if (!match) continue;
+    const [, file, range, statements, count] = match;
+    const key = `${file}:${range}`;
+    const covered = Number(count) > 0 || blocks.get(key)?.covered;
+    blocks.set(key, { file, statements: Number(statements), covered });
+  }
+
+  const files = new Map();
+  for (const { file, statements, covered } of blocks.values()) {
+    const counts = files.get(file) || { covered: 0, total: 0 };
+    counts.total += statements;
+    if (covered) counts.covered += statements;
+    files.set(file, counts);
+  }
+  return files;
+}
+
+/**
+ * Covered and total lines per file of a Cobertura report
+ * Filenames are relative to the first <source>, when there is one.
+ * @private
+ */
+function parseCobertura(content) {
+  const source = content.match(/<source>\s*([^<]*?)\s*<\/source>/)?.[1] || '';
+  const files = new Map();
+  for (const [, attributes, body] of content.matchAll(/<class\b([^>]*?)(?:\/>|>([\s\S]*?)<\/class>)/g)) {
+    const filename = attributes.match(/\bfilename="([^"]*)"/)?.[1];
+    if (!filename) continue;
+    const file = source && !path.isAbsolute(filename) ? path.posix.join(source.replace(/\\/g, '/'), filename) : filename;
+    const counts = files.get(file) || { covered: 0, total: 0 };
+    for (const [, hits] of (body || '').matchAll(/<line\b[^>]*?\bhits="(\d+)"/g)) {
+      counts.total++;
+      if (Number(hits) > 0) counts.covered++;
+    }
+    files.set(file, counts);
+  }
+  return files;
+}
+
+/**
+ * Open findings of a SARIF run, with the URI of their first location
+ * @private
+ */
+function* openFindings(run) {
+  const ruleLevels = new Map((run.tool?.driver?.rules || [])
+    .map(rule => [rule.id, rule.defaultConfiguration?.level]));
+  const baseUris = run.originalUriBaseIds || {};
+
+  for (const result of run.results || []) {
+    if (!OPEN_KINDS.includes(result.kind || 'fail')) continue;
+    if (result.baselineState === 'absent') continue;
+    if ((result.suppressions || []).some(suppression => suppression.status !== 'rejected')) continue;
+
+    const level = result.level || ruleLevels.get(result.ruleId) || 'warning';
+    if (!FINDING_LEVELS[level]) continue;
+
+    const physical = result.locations?.[0]?.physicalLocation;
+    const artifact = physical?.artifactLocation?.uri !== undefined
+      ? physical.artifactLocation
+      : run.artifacts?.[physical?.artifactLocation?.index]?.location;
+    if (!artifact?.uri) continue;
+
+    const base = artifact.uriBaseId ? baseUris[artifact.uriBaseId]?.uri : null;
+    const uri = base ? new URL(artifact.uri, base).href : artifact.uri;
+    yield {
+      uri: uri.startsWith('file:') ? uri : decodeURIComponent(uri),
+      finding: {
+        level,
+        ruleId: result.ruleId || result.rule?.id || null,
+        line: physical.region?.startLine ?? null,
+        message: result.message?.text || ''
+      }
+    };
+  }
+}
+
+/**
+ * Reported path of each project file: the same path, or the longest one
+ * ending in it (Go import paths, other checkouts); paths claimed by several
+ * project files go to none
+ * @private
+ */
+function matchPaths(files, reported) {
+  const byName = new Map();
+  for (const key of reported) {
+    const name = path.posix.basename(key);
+    if (!byName.has(name)) byName.set(name, []);
+    byName.get(name).push(key);
+  }
+
+  const exact = new Set(reported);
+  const projectFiles = new Set(files);
+  const matches = new Map();
+  const claims = new Map();
+  for (const file of files) {
+    if (exact.has(file)) {
+      matches.set(file, file);
+      continue;
+    }
+    const candidates = (byName.get(path.posix.basename(file)) || [])
+      .filter(key => key.endsWith(`/${file}`) || file.endsWith(`/${key}`));
+    if (candidates.length !== 1) continue;
+    matches.set(file, candidates[0]);
+    claims.set(candidates[0], (claims.get(candidates[0]) || 0) + 1);
+  }
+  for (const [file, key] of matches) {
+    if (key !== file && (claims.get(key) > 1 || projectFiles.has(key))) matches.delete(file);
+  }
+  return matches;
+}
+
+export default RiskOverlay;
@@ -8,6 +8,7 @@ import path from 'path';
 import GitIgnoreParser from '../parsers/gitignore-parser.js';
 import FileUtils from '../utils/file-utils.js';
 import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
 
 const logger = getLogger('Scanner');
 
@@ -26,7 +27,7 @@ export class Scanner {
     const contextIgnorePath = path.join(rootPath, '.contextignore');
     const contextIncludePath = path.join(rootPath, '.contextinclude');
 
-    this.gitIgnore = new GitIgnoreParser(gitignorePath, contextIgnorePath, contextIncludePath);
+    this.gitIgnore = new GitIgnoreParser(gitignorePath, contextIgnorePath, contextIncludePath, { rootDir: rootPath });
     this.stats = {
       filesScanned: 0,
       directoriesTraversed: 0,
@@ -55,7 +56,7 @@ export class Scanner {
     const files = [];
 
     try {
-      const entries = fs.readdirSync(dirPath, { withFileTypes: true });
+      const entries = fs.readdirSync(dirPath, { withFileTypes: true }).sort((a, b) => compareBytes(a.name, b.name));
       this.stats.directoriesTraversed++;
 
       for (const entry of entries) {
@@ -73,7 +74,7 @@ export class Scanner {
         } else if (entry.isFile()) {
           // Check if it's a text file (not binary)
           try {
-            if (FileUtils.isText(fullPath)) {
+            if (FileUtils.isText(fullPath) || FileUtils.isScript(fullPath)) {
               const fileInfo = this.getFileInfo(fullPath, relativePath);
               files.push(fileInfo);
               this.stats.filesScanned++;
@@ -109,7 +110,7 @@ export class Scanner {
   }
 
   shouldIgnore(relativePath) {
-    return this.gitIgnore.isIgnored(null, relativePath);
+    return this.gitIgnore.isIgnored(path.join(this.rootPath, relativePath), relativePath);
   }
 
   getStats() {
@@ -0,0 +1,227 @@
+/**
+ * ShellCompletion - Tab completion for bash, zsh and fish
+ * v3.4.0 - Shell completion (ctxman completion)
+ *
+ * Responsibilities:
+ * - Generate completion scripts naming the subcommands and flags
+ * - Complete the values of --symbol, --profile, --package and --session
+ *   from the project: names of context.yaml profiles and of saved sessions,
+ *   and symbols and packages of the symbol index cached by
+ *   `ctxman symbols --db`; without that index, symbol names of the content
+ *   cache (--cache)
+ *
+ * Scripts call back `ctxman completion --complete KIND PREFIX` for dynamic
+ * values, so completions follow the project the shell is in. Values come
+ * from caches only; nothing is scanned or parsed while completing.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import ContextProfiles from './ContextProfiles.js';
+import ContextSession from './ContextSession.js';
+import ContentCache from '../cache/ContentCache.js';
+import { SymbolIndex, SYMBOL_INDEX_FILE } from '../symbols/SymbolIndex.js';
+
+export const COMPLETION_SHELLS = ['bash', 'zsh', 'fish'];
+
+// Flag -> kind of value completed dynamically
+export const DYNAMIC_FLAGS = {
+  '--symbol': 'symbols',
+  '--profile': 'profiles',
+  '--package': 'packages',
+  '--session': 'sessions'
+};
+
+const FLAG_PATTERN = /^ {2,4}(?:(-[a-zA-Z]), )?(--[a-z][a-z0-9-]*)/;
+
+export class ShellCompletion {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      program: 'ctxman',
+      commands: [], // Subcommands (pack, lint, symbols, ...)
+      flags: [], // Flags, long and short (--max-tokens, -g)
+      ...options
+    };
+  }
+
+  /**
+   * Flags listed in help output: lines starting with `  --flag` or `  -f, --flag`,
+   * and subcommand options indented by four
+   * @param {string} helpText
+   * @returns {Array<string>} Long flags, then short flags, each sorted
+   */
+  static flagsOf(helpText) {
+    const long = new Set();
+    const short = new Set();
+    for (const line of helpText.split('\n')) {
+      const match = line.match(FLAG_PATTERN);
+      if (!match) continue;
+      if (match[1]) short.add(match[1]);
+      long.add(match[2]);
+    }
+    return [...[...long].sort(), ...[...short].sort()];
+  }
+
+  /**
+   * Completion script
+   * @param {string} shell - bash, zsh or fish
+   * @returns {string}
+   * @throws {Error} For other shells
+   */
+  script(shell) {
+    if (shell === 'bash') return this.bash();
+    if (shell === 'zsh') return this.zsh();
+    if (shell === 'fish') return this.fish();
+    throw new Error(`Unsupported shell: ${shell} (expected ${COMPLETION_SHELLS.join(', ')})`);
+  }
+
+  /**
+   * Dynamic values of a kind, from the project's caches
+   * @param {string} kind - symbols, profiles, packages or sessions
+   * @param {Object} options - { root, prefix }
+   * @returns {Promise<Array<string>>} Sorted, unique values starting with prefix;
+   *   empty when the project has no context.yaml or caches
+   */
+  static async values(kind, { root, prefix = '' }) {
+    let values = [];
+    if (kind === 'profiles') {
+      values = ContextProfiles.load(root)?.names() || [];
+    } else if (kind === 'sessions') {
+      values = ContextSession.list(root).map(session => session.name);
+    } else if (kind === 'symbols' || kind === 'packages') {
+      const database = path.join(root, SYMBOL_INDEX_FILE);
+      if (!fs.existsSync(database)) {
+        // Content cache outlines carry names only, not files, packages or IDs
+        values = kind === 'symbols' ? [...new ContentCache({ root }).symbolNames()] : [];
+        return values.filter(value => value.startsWith(prefix)).sort();
+      }
+
+      const index = await SymbolIndex.open({ database });
+      try {
+        const symbols = index.query({});
+        values = kind === 'packages'
+          ? symbols.map(symbol => symbol.package)
+          // IDs once the prefix names one: "pkg/calc#method:..." (see SymbolId)
+          : symbols.map(symbol => (prefix.includes('#') ? symbol.id : symbol.qualifiedName));
+      } finally {
+        index.close();
+      }
+    } else {
+      throw new Error(`Unknown completion: ${kind} (expected ${Object.values(DYNAMIC_FLAGS).join(', ')})`);
+    }
+    return [...new Set(values)].filter(value => value.startsWith(prefix)).sort();
+  }
+
+  /**
+   * @private
+   */
+  bash() {
+    const { program, commands, flags } = this.options;
+    const name = `_${identifier(program)}`;
+    const cases = Object.entries(DYNAMIC_FLAGS).map(([flag, kind]) =>
+      `    ${flag})\n        COMPREPLY=($(compgen -W "$(${program} completion --complete ${kind} "$cur" 2>/dev/null)" -- "$cur"))\n        ${colonFix()}\n        return ;;`);
+
+    return [
+      `# ${program} completion for bash`,
+      `# Load with: eval "$(${program} completion bash)"`,
+      `${name}() {`,
+      '    local cur prev',
+      '    if declare -F _get_comp_words_by_ref >/dev/null; then',
+      '        _get_comp_words_by_ref -n : cur prev',
+      '    else',
+      '        cur="${COMP_WORDS[COMP_CWORD]}"',
+      '        prev="${COMP_WORDS[COMP_CWORD-1]}"',
+      '    fi',
+      '',
+      '    case "$prev" in',
+      ...cases,
+      '    esac',
+      '',
+      '    if [[ "$cur" == -* ]]; then',
+      `        COMPREPLY=($(compgen -W "${flags.join(' ')}" -- "$cur"))`,
+      '    elif [[ $COMP_CWORD -eq 1 ]]; then',
+      `        COMPREPLY=($(compgen -W "${commands.join(' ')}" -- "$cur"))`,
+      '    fi',
+      '}',
+      `complete -o default -F ${name} ${program}`,
+      ''
+    ].join('\n');
+  }
+
+  /**
+   * @private
+   */
+  zsh() {
+    const { program, commands, flags } = this.options;
+    const name = `_${identifier(program)}`;
+    const cases = Object.entries(DYNAMIC_FLAGS).map(([flag, kind]) =>
+      `        ${flag})\n            compadd -- \${(f)"$(${program} completion --complete ${kind} "\${words[CURRENT]}" 2>/dev/null)"}\n            return ;;`);
+
+    return [
+      `#compdef ${program}`,
+      `# ${program} completion for zsh`,
+      `# Load with: source <(${program} completion zsh), after compinit`,
+      `${name}() {`,
+      '    case "${words[CURRENT-1]}" in',
+      ...cases,
+      '    esac',
+      '',
+      '    if [[ "${words[CURRENT]}" == -* ]]; then',
+      `        compadd -- ${flags.join(' ')}`,
+      '    elif (( CURRENT == 2 )); then',
+      `        compadd -- ${commands.join(' ')}`,
+      '        _files',
+      '    else',
+      '        _files',
+      '    fi',
+      '}',
+      `compdef ${name} ${program}`,
+      ''
+    ].join('\n');
+  }
+
+  /**
+   * @private
+   */
+  fish() {
+    const { program, commands, flags } = this.options;
+    const lines = [
+      `# ${program} completion for fish`,
+      `# Load with: ${program} completion fish | source`,
+      `complete -c ${program} -n __fish_use_subcommand -a '${commands.join(' ')}'`
+    ];
+
+    for (const flag of flags) {
+      if (DYNAMIC_FLAGS[flag]) continue;
+      lines.push(flag.startsWith('--')
+        ? `complete -c ${program} -l ${flag.slice(2)}`
+        : `complete -c ${program} -s ${flag.slice(1)}`);
+    }
+    for (const [flag, kind] of Object.entries(DYNAMIC_FLAGS)) {
+      lines.push(`complete -c ${program} -l ${flag.slice(2)} -x -a '(${program} completion --complete ${kind} (commandline -ct) 2>/dev/null)'`);
+    }
+
+    return lines.join('\n') + '\n';
+  }
+}
+
+/**
+ * Shell function name part of a program name (ctxman-client -> ctxman_client)
+ * @private
+ */
+function identifier(program) {
+  return program.replace(/[^a-zA-Z0-9_]/g, '_');
+}
+
+/**
+ * Bash splits words at colons (symbol IDs contain them); bash-completion can undo that
+ * @private
+ */
+function colonFix() {
+  return 'declare -F __ltrim_colon_completions >/dev/null && __ltrim_colon_completions "$cur"';
+}
+
+export default ShellCompletion;
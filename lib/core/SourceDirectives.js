@@ -0,0 +1,162 @@
+/**
+ * SourceDirectives - ctx: directives in source comments
+ * v3.4.0 - In-code annotations (//ctx:pin, //ctx:ignore, //ctx:priority=high)
+ *
+ * Responsibilities:
+ * - Find ctx: directives at the start of comments, never in strings or code
+ * - Read pin, ignore and priority for the whole file
+ * - Read named regions (//ctx:region NAME ... //ctx:endregion) with the
+ *   lines between their markers, for --pin FILE#NAME
+ * - Report unknown directives and invalid values with their line
+ *
+ * Comments are found with ContentStripper's scanner, so files of types it
+ * has no comment syntax for carry no directives. Markdown uses HTML
+ * comments (<!-- ctx:pin -->) outside fenced code blocks.
+ */
+
+import path from 'path';
+import ContentStripper, { scan } from './ContentStripper.js';
+
+export const DIRECTIVE_NAMES = ['pin', 'ignore', 'priority', 'region', 'endregion'];
+
+// Priorities of the named levels; numbers are used as they are, like profile rules
+export const PRIORITY_LEVELS = { high: 100, low: -100 };
+
+const MARKDOWN = { line: [], block: [['<!--', '-->']], quotes: [] };
+const MARKDOWN_EXTENSIONS = ['.md', '.mdx', '.markdown'];
+
+// Comment markers before the first directive
+const COMMENT_START = /^(?:\/\/+|\/\*+|#+|--+|<!--)[!\s*]*/;
+const DIRECTIVE = /^ctx:([a-z][\w-]*)(?:=([^\s*]+?))?(?=\s|\*\/|-->|$)/i;
+// Region names may follow a space instead of =: //ctx:region parser
+const REGION_NAME = /^[\w.-]+$/;
+const REGION_WORD = /^\s+([\w.-]+)(?=\s|\*\/|-->|$)/;
+const FENCE = /^ {0,3}(`{3,}|~{3,})[^\n]*\n[\s\S]*?(?:^ {0,3}\1[^\n]*$|(?![\s\S]))/gm;
+
+export class SourceDirectives {
+  /**
+   * Directives of a file
+   * @param {string} content - Unstripped source
+   * @param {string} filePath - Picks the comment syntax
+   * @returns {{pin: boolean, ignore: boolean, priority: number|null,
+   *   warnings: Array<{line: number, message: string}>,
+   *   regions?: Array<{name: string, startLine: number, endLine: number}>}|null} null when the
+   *   file has none; regions only when it has some, ordered by line
+   */
+  static parse(content, filePath) {
+    if (!content.includes('ctx:')) return null;
+    const syntax = SourceDirectives.syntaxOf(filePath, content);
+    if (!syntax) return null;
+
+    const fences = syntax === MARKDOWN ? fencesOf(content) : [];
+    const directives = { pin: false, ignore: false, priority: null, warnings: [] };
+    const regions = { open: [], closed: [] };
+    const lineOf = offset => content.slice(0, offset).split('\n').length;
+    let found = false;
+
+    for (const comment of scan(content, syntax).comments) {
+      if (comment.docstring || fences.some(([start, end]) => comment.start >= start && comment.start < end)) continue;
+
+      let rest = content.slice(comment.start, comment.end).replace(COMMENT_START, '');
+      let match;
+      while ((match = DIRECTIVE.exec(rest))) {
+        found = true;
+        let [text, name, value] = match;
+        name = name.toLowerCase();
+        if ((name === 'region' || name === 'endregion') && value === undefined) {
+          const word = REGION_WORD.exec(rest.slice(text.length));
+          if (word) {
+            value = word[1];
+            text += word[0];
+          }
+        }
+
+        const warning = name === 'region' || name === 'endregion'
+          ? applyRegion(regions, name, value, lineOf(comment.start), lineOf(comment.end))
+          : apply(directives, name, value);
+        if (warning) {
+          directives.warnings.push({ line: lineOf(comment.start), message: warning });
+        }
+        rest = rest.slice(text.length).trimStart();
+      }
+    }
+
+    for (const open of regions.open) {
+      directives.warnings.push({ line: open.line, message: `ctx:region ${open.name} is not closed (expected ctx:endregion)` });
+    }
+    if (regions.closed.length > 0) {
+      directives.regions = regions.closed.sort((a, b) => a.startLine - b.startLine);
+    }
+    return found ? directives : null;
+  }
+
+  /**
+   * Comment syntax directives are read from
+   * @param {string} filePath
+   * @param {string|null} [content]
+   * @returns {Object|null}
+   */
+  static syntaxOf(filePath, content = null) {
+    if (MARKDOWN_EXTENSIONS.includes(path.extname(filePath).toLowerCase())) return MARKDOWN;
+    return ContentStripper.syntaxOf(filePath, content);
+  }
+}
+
+/**
+ * Record one directive; returns a warning when it is not understood
+ * @private
+ */
+function apply(directives, name, value) {
+  if (!DIRECTIVE_NAMES.includes(name)) {
+    return `Unknown directive ctx:${name} (expected ${DIRECTIVE_NAMES.map(known => `ctx:${known}`).join(', ')})`;
+  }
+  if (name === 'priority') {
+    const level = value === undefined ? undefined : PRIORITY_LEVELS[value.toLowerCase()];
+    const priority = level ?? (value !== undefined && /^-?\d+(\.\d+)?$/.test(value) ? Number(value) : null);
+    if (priority === null) {
+      return `Invalid ctx:priority${value === undefined ? '' : `=${value}`} (expected ${Object.keys(PRIORITY_LEVELS).join(', ')} or a number)`;
+    }
+    directives.priority = priority;
+    return null;
+  }
+  if (value !== undefined) {
+    return `ctx:${name} takes no value (got ctx:${name}=${value})`;
+  }
+  directives[name] = true;
+  return null;
+}
+
+/**
+ * Open or close a region; the lines between the markers belong to it.
+ * ctx:endregion closes the innermost open region, or the named one.
+ * @private
+ */
+function applyRegion(regions, name, value, startLine, endLine) {
+  if (name === 'region') {
+    if (value === undefined || !REGION_NAME.test(value)) {
+      return `Invalid ctx:region${value === undefined ? '' : ` ${value}`} (expected ctx:region NAME of letters, digits, ., _ and -)`;
+    }
+    regions.open.push({ name: value, line: startLine, startLine: endLine + 1 });
+    return null;
+  }
+
+  const index = value === undefined
+    ? regions.open.length - 1
+    : regions.open.map(open => open.name).lastIndexOf(value);
+  if (index < 0) {
+    return `ctx:endregion${value === undefined ? '' : ` ${value}`} has no open ctx:region`;
+  }
+  const [open] = regions.open.splice(index, 1);
+  regions.closed.push({ name: open.name, startLine: open.startLine, endLine: startLine - 1 });
+  return null;
+}
+
+/**
+ * Offset ranges of fenced code blocks in Markdown
+ * @private
+ */
+function fencesOf(content) {
+  return [...content.matchAll(FENCE)].map(match => [match.index, match.index + match[0].length]);
+}
+
+export default SourceDirectives;
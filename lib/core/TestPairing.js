@@ -0,0 +1,210 @@
+/**
+ * TestPairing - Pair source files with their tests
+ * v3.4.0 - Test-to-source pairing (--pair-tests)
+ *
+ * Responsibilities:
+ * - Recognize test files by language convention (foo_test.go, foo.test.js, test_foo.py, FooTest.java)
+ * - Find the tests of a source file and the source of a test file
+ * - List the test function names of a test file
+ * - Format the pairing report
+ */
+
+import path from 'path';
+
+export const PAIR_MODES = ['full', 'names'];
+
+// Directories whose files are tests of sources elsewhere in the tree
+export const TEST_DIRS = ['test', 'tests', '__tests__', 'spec', 'specs'];
+
+const FAMILIES = {
+  '.go': 'go',
+  '.js': 'js', '.jsx': 'js', '.mjs': 'js', '.cjs': 'js', '.ts': 'js', '.tsx': 'js',
+  '.py': 'py',
+  '.java': 'jvm', '.kt': 'jvm',
+  '.cs': 'cs',
+  '.rb': 'rb'
+};
+
+// Basename patterns of test files; group 1 is the name of the source they test
+const TEST_PATTERNS = {
+  go: [/^(.+)_test\.go$/],
+  js: [/^(.+)\.(?:test|spec)\.[cm]?[jt]sx?$/],
+  py: [/^test_(.+)\.py$/, /^(.+)_test\.py$/],
+  jvm: [/^(.+?)(?:Tests?|Spec)\.(?:java|kt)$/],
+  cs: [/^(.+?)Tests?\.cs$/],
+  rb: [/^(.+)_(?:spec|test)\.rb$/]
+};
+
+// Test declarations; group 1 is the test name
+const TEST_NAME_PATTERNS = {
+  go: [/^func\s+((?:Test|Benchmark|Example|Fuzz)\w*)\s*\(/gm],
+  js: [/^\s*(?:test|it)(?:\.(?:only|skip|todo|concurrent))?\s*\(\s*(['"`])(.+?)\1/gm],
+  py: [/^\s*(?:async\s+)?def\s+(test\w*)\s*\(/gm],
+  jvm: [/@(?:Test|ParameterizedTest|RepeatedTest)\b[^\n]*\n(?:\s*@[^\n]*\n)*\s*(?:(?:public|protected|private|internal|suspend|fun|void)\s+)*(\w+)\s*\(/g],
+  cs: [/\[(?:Test|Fact|Theory|TestMethod|TestCase)\b[^\]]*\][^\n]*\n(?:\s*\[[^\n]*\n)*\s*(?:(?:public|private|internal|async|static|void|Task)\s+)*(\w+)\s*\(/g],
+  rb: [/^\s*(?:it|test|specify)\s+(['"])(.+?)\1/gm]
+};
+
+export class TestPairing {
+  /**
+   * @param {Array<string>} files - Relative paths (forward slashes) of files that can be paired
+   */
+  constructor(files) {
+    this.files = new Set(files);
+    this.byStem = new Map(); // "family:stem" -> source or test paths
+
+    for (const file of this.files) {
+      const key = TestPairing.key(file);
+      if (!key) continue;
+      if (!this.byStem.has(key)) this.byStem.set(key, []);
+      this.byStem.get(key).push(file);
+    }
+  }
+
+  /**
+   * Language family of a file, or null when pairing does not apply
+   * @param {string} file
+   * @returns {string|null}
+   */
+  static family(file) {
+    return FAMILIES[path.posix.extname(file).toLowerCase()] || null;
+  }
+
+  /**
+   * Whether a file is a test by naming or location convention
+   * @param {string} file - Relative path
+   * @returns {boolean}
+   */
+  static isTestFile(file) {
+    return TestPairing.testedName(file) !== null;
+  }
+
+  /**
+   * Name of the source a test file tests, or null for source files
+   * @private
+   */
+  static testedName(file) {
+    const family = TestPairing.family(file);
+    if (!family) return null;
+
+    const base = path.posix.basename(file);
+    for (const pattern of TEST_PATTERNS[family]) {
+      const match = base.match(pattern);
+      if (match) return match[1];
+    }
+
+    // Go tests always live next to their source
+    if (family !== 'go' && path.posix.dirname(file).split('/').includes('__tests__')) {
+      return base.slice(0, base.length - path.posix.extname(base).length);
+    }
+    return null;
+  }
+
+  /**
+   * Index key shared by a source and its tests: family plus the name
+   * without case, dashes or underscores (token-budget.test.js tests TokenBudget.js)
+   * @private
+   */
+  static key(file) {
+    const family = TestPairing.family(file);
+    if (!family) return null;
+
+    const base = path.posix.basename(file);
+    const name = TestPairing.testedName(file) ?? base.slice(0, base.length - path.posix.extname(base).length);
+    return `${family}:${name.toLowerCase().replace(/[^a-z0-9]/g, '')}`;
+  }
+
+  /**
+   * Files paired with a file: the tests of a source file, or the source of a test file
+   * @param {string} file - Relative path
+   * @returns {Array<string>}
+   */
+  pairsOf(file) {
+    const key = TestPairing.key(file);
+    if (!key) return [];
+
+    const candidates = this.byStem.get(key) || [];
+    if (TestPairing.isTestFile(file)) {
+      return this.sourcesOf(file, candidates);
+    }
+    return candidates.filter(test => TestPairing.isTestFile(test) && this.sourcesOf(test, candidates).includes(file));
+  }
+
+  /**
+   * Sources closest to a test: its own directory, otherwise the most shared directory names
+   * @private
+   */
+  sourcesOf(test, candidates) {
+    const sources = candidates.filter(file => !TestPairing.isTestFile(file));
+    const testDir = path.posix.dirname(test);
+    const local = sources.filter(file => path.posix.dirname(file) === testDir);
+    if (local.length > 0 || TestPairing.family(test) === 'go') {
+      return local;
+    }
+
+    const testDirs = new Set(testDir.split('/').filter(dir => dir !== '.' && !TEST_DIRS.includes(dir)));
+    const scored = sources.map(file => ({
+      file,
+      score: path.posix.dirname(file).split('/').filter(dir => testDirs.has(dir)).length
+    }));
+    const best = Math.max(...scored.map(entry => entry.score));
+    return scored.filter(entry => entry.score === best).map(entry => entry.file);
+  }
+
+  /**
+   * Names of the tests declared in a test file
+   * @param {string} content
+   * @param {string} filePath
+   * @returns {Array<string>}
+   */
+  static testNames(content, filePath) {
+    const family = TestPairing.family(filePath);
+    if (!family) return [];
+
+    const names = [];
+    for (const pattern of TEST_NAME_PATTERNS[family]) {
+      for (const match of content.matchAll(pattern)) {
+        names.push(match[match.length - 1]);
+      }
+    }
+    return names;
+  }
+
+  /**
+   * One-line summary of a test file, in place of its content
+   * @param {string} content
+   * @param {string} filePath
+   * @returns {string}
+   */
+  static summary(content, filePath) {
+    const names = TestPairing.testNames(content, filePath);
+    return names.length > 0 ? `// Tests: ${names.join(', ')}` : '// Tests: none found';
+  }
+
+  /**
+   * Format a pairing for console output
+   * @param {Object} pairing - { mode, pairs: [{ file, pair, added }], unpaired }
+   * @returns {string}
+   */
+  static formatPairing(pairing) {
+    const added = pairing.pairs.filter(entry => entry.added);
+    const lines = [
+      '',
+      '🧪 TEST PAIRING',
+      '='.repeat(80),
+      `Mode: ${pairing.mode === 'names' ? 'test names' : 'full files'}`,
+      `Pairs: ${pairing.pairs.length} (${added.length} added)`
+    ];
+
+    for (const entry of pairing.pairs) {
+      lines.push(`   ${entry.added ? '+' : '='} ${entry.file} ↔ ${entry.pair}`);
+    }
+    if (pairing.unpaired.length > 0) {
+      lines.push(`Without tests: ${pairing.unpaired.length}`);
+    }
+
+    return lines.join('\n');
+  }
+}
+
+export default TestPairing;
@@ -0,0 +1,148 @@
+/**
+ * TodoHarvester - TODO, FIXME and HACK comments of a context
+ * v3.4.0 - Known-issue markers (--todos)
+ *
+ * Responsibilities:
+ * - Find marker comments (TODO, FIXME, HACK, XXX) in the comments and
+ *   docstrings of included files, or of every analyzed file
+ * - Record each with its location, author (TODO(alice): ...) and the
+ *   lines around it
+ * - Render them as a section after the files of a digest
+ *
+ * Only comments count: markers in strings and identifiers (TODO_LIST) are
+ * not matched, and files whose comment syntax is unknown have none.
+ * Trimmed files contribute the markers of their included lines.
+ */
+
+import ContentStripper, { scan } from './ContentStripper.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export const TODO_MARKERS = ['TODO', 'FIXME', 'HACK', 'XXX'];
+export const TODO_SCOPES = ['included', 'repo'];
+export const TODO_TITLE = 'TODO / FIXME / HACK MARKERS';
+
+export class TodoHarvester {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      scope: 'included', // included: files of the context; repo: every analyzed file
+      markers: TODO_MARKERS,
+      context: 2, // Lines shown before and after each marker
+      limit: 200, // Markers listed; those of included files first
+      ...options
+    };
+    if (!TODO_SCOPES.includes(this.options.scope)) {
+      throw new Error(`Unknown --todos scope: ${this.options.scope} (expected ${TODO_SCOPES.join(' or ')})`);
+    }
+    this.pattern = new RegExp(`(?:^|[^\\w$])(${this.options.markers.join('|')})(?![\\w$])(?:\\(([^)\\n]*)\\))?:?[ \\t]*(.*)`);
+  }
+
+  /**
+   * Markers of files
+   * @param {Array<{path: string, content: string, ranges?: Array<{startLine: number, endLine: number}>|null, included?: boolean}>} files
+   *   '/'-separated paths; ranges limit a file to its included lines; included defaults to true
+   * @returns {Array<{marker: string, file: string, line: number, text: string, author: string|null,
+   *   included: boolean, context: {startLine: number, lines: Array<string>}}>} Included files first, each in
+   *   file and line order, at most options.limit
+   */
+  harvest(files) {
+    const entries = files.flatMap(file => this.harvestFile(file));
+    entries.sort((a, b) => Number(b.included) - Number(a.included) || compareBytes(a.file, b.file) || a.line - b.line);
+    return entries.slice(0, this.options.limit);
+  }
+
+  /**
+   * Digest section
+   * @param {Array<Object>} entries - Result of harvest()
+   * @returns {string}
+   */
+  format(entries) {
+    const lines = ['', '='.repeat(48), TODO_TITLE, '='.repeat(48)];
+    let elsewhere = false;
+
+    for (const entry of entries) {
+      if (!entry.included && !elsewhere) {
+        elsewhere = true;
+        lines.push('--- Outside this context ---', '');
+      }
+      lines.push(`${entry.marker} ${entry.file}:${entry.line}${entry.author ? ` (${entry.author})` : ''}${entry.text ? ` — ${entry.text}` : ''}`);
+      const width = String(entry.context.startLine + entry.context.lines.length - 1).length;
+      entry.context.lines.forEach((text, index) => {
+        const line = entry.context.startLine + index;
+        lines.push(`${line === entry.line ? '>' : ' '} ${String(line).padStart(width)} | ${text}`.trimEnd());
+      });
+      lines.push('');
+    }
+    if (entries.length === 0) {
+      lines.push(`No ${this.options.markers.join(', ')} comments`);
+    }
+    return `${lines.join('\n').replace(/\n+$/, '')}\n`;
+  }
+
+  /**
+   * Report line, e.g. "📌 Markers: 12 (8 TODO, 3 FIXME, 1 HACK), 4 outside the context"
+   * @param {Array<Object>} entries - Result of harvest()
+   * @returns {string}
+   */
+  describe(entries) {
+    const counts = this.options.markers
+      .map(marker => [marker, entries.filter(entry => entry.marker === marker).length])
+      .filter(([, count]) => count > 0)
+      .map(([marker, count]) => `${count} ${marker}`);
+    const outside = entries.filter(entry => !entry.included).length;
+    return `📌 Markers: ${entries.length}${counts.length > 0 ? ` (${counts.join(', ')})` : ''}` +
+      (outside > 0 ? `, ${outside} outside the context` : '');
+  }
+
+  /**
+   * @private
+   */
+  harvestFile(file) {
+    const syntax = ContentStripper.syntaxOf(file.path, file.content);
+    if (!syntax || !this.options.markers.some(marker => file.content.includes(marker))) return [];
+
+    const entries = [];
+    let lines = null;
+    let line = 1;
+    let offset = 0;
+    for (const comment of scan(file.content, syntax).comments) {
+      for (let i = file.content.indexOf('\n', offset); i !== -1 && i < comment.start; i = file.content.indexOf('\n', i + 1)) line++;
+      offset = comment.start;
+
+      file.content.slice(comment.start, comment.end).split('\n').forEach((text, index) => {
+        const match = this.pattern.exec(text);
+        if (!match || !inRanges(file.ranges, line + index)) return;
+
+        if (!lines) {
+          lines = file.content.split('\n');
+          if (lines[lines.length - 1] === '') lines.pop();
+        }
+        const startLine = Math.max(1, line + index - this.options.context);
+        entries.push({
+          marker: match[1],
+          file: file.path,
+          line: line + index,
+          text: match[3].replace(/\s*(\*\/|-->|\]\]|"""|''')\s*$/, '').trim(),
+          author: match[2]?.trim() || null,
+          included: file.included !== false,
+          context: {
+            startLine,
+            lines: lines.slice(startLine - 1, Math.min(lines.length, line + index + this.options.context))
+          }
+        });
+      });
+    }
+    return entries;
+  }
+}
+
+/**
+ * @private
+ */
+function inRanges(ranges, line) {
+  return !ranges || ranges.some(range => line >= range.startLine && line <= range.endLine);
+}
+
+export default TodoHarvester;
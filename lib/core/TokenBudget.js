@@ -0,0 +1,522 @@
+/**
+ * TokenBudget - Token budget planning
+ * v3.4.0 - Token budget planner
+ *
+ * Responsibilities:
+ * - Count tokens with a selectable tokenizer (cl100k_base, o200k_base, Claude)
+ * - Greedily pack the highest-priority files into a token budget
+ * - Fall back to individual symbols for files that do not fit whole, keeping
+ *   types declared apart from their methods (Go receivers) as one unit
+ * - Degrade files through summary tiers (signatures, names) before dropping them
+ * - Report what was included, trimmed, summarized and dropped
+ * - Keep room in the window for the task prompt and the model's answer
+ *   (--reserve-prompt, --reserve-output)
+ */
+
+import { getTokenizerManager, EstimationAdapter } from '../utils/tokenizer-adapter.js';
+import TokenUtils from '../utils/token-utils.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import ContentCache from '../cache/ContentCache.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('TokenBudget');
+
+/**
+ * Parse a token count such as "8000", "8k" or "1.5m"
+ * @param {string|number} value
+ * @returns {number|null} Token count, or null when not a positive number
+ */
+export function parseTokenCount(value) {
+  if (typeof value === 'number') {
+    return value > 0 ? Math.floor(value) : null;
+  }
+
+  const match = /^\s*(\d+(?:\.\d+)?)\s*([km]?)\s*$/i.exec(String(value ?? ''));
+  if (!match) return null;
+
+  const multiplier = { '': 1, k: 1000, m: 1000000 }[match[2].toLowerCase()];
+  const tokens = Math.floor(parseFloat(match[1]) * multiplier);
+  return tokens > 0 ? tokens : null;
+}
+
+/**
+ * Ways a file can be packed, from most to least detailed
+ * - full: whole file
+ * - symbols: the individual symbols that fit
+ * - signatures: package docs, exported signatures and type declarations, no bodies
+ * - names: one line listing the exported names
+ */
+export const BUDGET_TIERS = ['full', 'symbols', 'signatures', 'names'];
+
+/**
+ * Parse a --tiers value such as "full,signatures,names"
+ * @param {string|Array<string>} value
+ * @returns {Array<string>} Tiers in the given order
+ */
+export function parseTiers(value) {
+  const tiers = (Array.isArray(value) ? value : String(value ?? '').split(','))
+    .map(tier => String(tier).trim().toLowerCase())
+    .filter(Boolean);
+
+  if (tiers.length === 0) {
+    throw new Error(`Invalid tiers: expected a list of ${BUDGET_TIERS.join(', ')}`);
+  }
+  for (const tier of tiers) {
+    if (!BUDGET_TIERS.includes(tier)) {
+      throw new Error(`Unknown tier: ${tier} (expected ${BUDGET_TIERS.join(', ')})`);
+    }
+  }
+  if (new Set(tiers).size !== tiers.length) {
+    throw new Error(`Duplicate tier in: ${tiers.join(',')}`);
+  }
+  return tiers;
+}
+
+// Declarations whose body is the type itself (struct fields, interface methods)
+const TYPE_KINDS = new Set([
+  SymbolKind.STRUCT,
+  SymbolKind.INTERFACE,
+  SymbolKind.TRAIT,
+  SymbolKind.ENUM,
+  SymbolKind.TYPE
+]);
+
+export class TokenBudget {
+  constructor(options = {}) {
+    this.options = {
+      maxTokens: null,
+      tokenizer: 'auto', // auto | cl100k_base | o200k_base | claude | estimate
+      model: null,
+      reserve: 0, // Tokens kept free for the answer
+      reservePrompt: 0, // Tokens kept free for the task prompt
+      symbolFallback: true,
+      exportedMethods: false, // Types packed with their methods keep only the exported ones
+      tiers: null, // Defaults to full,symbols (full only without symbolFallback)
+      cache: null, // ContentCache for symbol outlines
+      ...options
+    };
+
+    if (!parseTokenCount(this.options.maxTokens)) {
+      throw new Error(`Invalid token budget: ${this.options.maxTokens}`);
+    }
+    this.options.maxTokens = parseTokenCount(this.options.maxTokens);
+    this.tiers = this.options.tiers
+      ? parseTiers(this.options.tiers)
+      : (this.options.symbolFallback ? ['full', 'symbols'] : ['full']);
+
+    this.tokenizer = null;
+    this.symbolExtractor = null;
+  }
+
+  /**
+   * Create and initialize a budget
+   * @param {Object} options - Same as constructor
+   * @returns {Promise<TokenBudget>}
+   */
+  static async create(options = {}) {
+    const budget = new TokenBudget(options);
+    await budget.initialize();
+    return budget;
+  }
+
+  /**
+   * Resolve the configured tokenizer
+   * @returns {Promise<TokenBudget>}
+   */
+  async initialize() {
+    const manager = await getTokenizerManager();
+    this.tokenizer = manager.resolveTokenizer(this.options.tokenizer, this.options.model);
+    logger.debug(`Token budget ${this.limit} using ${this.getTokenizerName()}`);
+    return this;
+  }
+
+  /**
+   * Same budget with another size, sharing the resolved tokenizer and extractor
+   * @param {number} maxTokens
+   * @returns {TokenBudget}
+   */
+  withMaxTokens(maxTokens) {
+    const budget = new TokenBudget({ ...this.options, maxTokens });
+    budget.tokenizer = this.tokenizer;
+    budget.symbolExtractor = this.symbolExtractor;
+    return budget;
+  }
+
+  /**
+   * Tokens available for content (maxTokens minus the reserves)
+   * @returns {number}
+   */
+  get limit() {
+    return Math.max(0, this.options.maxTokens - this.reserved);
+  }
+
+  /**
+   * Tokens kept free for the task prompt and the answer
+   * @returns {number}
+   */
+  get reserved() {
+    return (this.options.reserve || 0) + (this.options.reservePrompt || 0);
+  }
+
+  /**
+   * @returns {string} Display name of the active tokenizer
+   */
+  getTokenizerName() {
+    const { calibration } = TokenUtils.getEstimator();
+    if (this.isEstimating() && calibration) {
+      return `Estimation (calibrated to ${calibration.target})`;
+    }
+    return this.tokenizer ? this.tokenizer.getName() : 'Estimation (~95% accurate)';
+  }
+
+  /**
+   * @returns {boolean} True when counts come from TokenUtils.estimate()
+   */
+  isEstimating() {
+    return !this.tokenizer || this.tokenizer instanceof EstimationAdapter;
+  }
+
+  /**
+   * Count tokens in content
+   * @param {string} content
+   * @param {string} filePath - Used for per-extension estimates
+   * @returns {number}
+   */
+  count(content, filePath = '') {
+    if (this.isEstimating()) {
+      return TokenUtils.estimate(content, filePath);
+    }
+
+    try {
+      return this.tokenizer.count(content);
+    } catch (error) {
+      logger.warn(`${this.getTokenizerName()} failed, estimating: ${error.message}`);
+      return TokenUtils.estimate(content, filePath);
+    }
+  }
+
+  /**
+   * Default file priority (mirrors ContextBuilder's core-first heuristic)
+   * @param {string} relativePath
+   * @returns {number}
+   */
+  static filePriority(relativePath) {
+    const p = relativePath.toLowerCase();
+    let score = 0;
+
+    if (p.includes('src/') || p.includes('lib/')) score += 10;
+    if (p.includes('core/') || p.includes('main')) score += 8;
+    if (p.includes('index.') || p.includes('app.')) score += 7;
+    if (p.includes('utils/') || p.includes('helpers/')) score += 5;
+    if (p.includes('components/')) score += 4;
+    if (p.includes('test')) score -= 5;
+    if (p.includes('docs/') || p.includes('examples/')) score -= 3;
+
+    return score;
+  }
+
+  /**
+   * Split a file into symbol items for partial inclusion
+   * Containers with members are replaced by their members so no line is counted twice.
+   * Types whose methods are declared outside them (Go receivers) become one item
+   * with the type and its methods as members, so they are packed together.
+   * @param {string} content - File content
+   * @param {string} filePath - Relative path
+   * @param {Function} filter - Optional symbol => boolean, to split part of a file
+   * @returns {Array<Object>} Items with id, name, kind, startLine, endLine, tokens, priority,
+   *   and members (ranges) for grouped types
+   */
+  symbolsFor(content, filePath, filter = null) {
+    const symbols = this.extract(content, filePath)
+      .filter(symbol => symbol.kind !== SymbolKind.MODULE && (!filter || filter(symbol)));
+    const lines = content.split('\n');
+    const count = symbol => this.count(lines.slice(symbol.startLine - 1, symbol.endLine).join('\n'), filePath);
+
+    const types = new Map(symbols.filter(symbol => !symbol.parent).map(symbol => [symbol.qualifiedName, symbol]));
+    const receiverOf = symbol => {
+      const type = symbol.parent ? types.get(symbol.parent) : null;
+      return type && (symbol.startLine > type.endLine || symbol.endLine < type.startLine) ? type : null;
+    };
+    const methods = new Map();
+    for (const symbol of symbols) {
+      const type = receiverOf(symbol);
+      if (!type || (this.options.exportedMethods && !symbol.exported)) continue;
+      if (!methods.has(type)) methods.set(type, []);
+      methods.get(type).push(symbol);
+    }
+    const parents = new Set(symbols.filter(symbol => !receiverOf(symbol)).map(symbol => symbol.parent).filter(Boolean));
+
+    return symbols
+      .filter(symbol => !parents.has(symbol.qualifiedName) && !receiverOf(symbol))
+      .map((symbol, index) => {
+        const group = methods.get(symbol);
+        const members = group && [symbol, ...group].map(member => ({
+          name: member.qualifiedName,
+          kind: member.kind,
+          startLine: member.startLine,
+          endLine: member.endLine
+        }));
+        return {
+          id: `${filePath}#${symbol.qualifiedName}`,
+          name: symbol.qualifiedName,
+          kind: symbol.kind,
+          startLine: symbol.startLine,
+          endLine: symbol.endLine,
+          tokens: [symbol, ...(group || [])].reduce((sum, member) => sum + count(member), 0),
+          // Exported symbols first, then source order
+          priority: (symbol.exported ? 1 : 0) - index / (symbols.length + 1),
+          ...(members ? { members } : {})
+        };
+      });
+  }
+
+  /**
+   * Summary of a file for the signatures or names tier
+   * signatures: package docs, exported signatures and type declarations (no bodies).
+   * names: one line listing the exported names.
+   * Files without exports fall back to their non-underscore top-level API.
+   * @param {string} content - File content
+   * @param {string} filePath - Relative path
+   * @param {string} tier - signatures | names
+   * @param {Function} filter - Optional symbol => boolean, to summarize part of a file
+   * @returns {string|null} Summary text, or null when there is nothing to show
+   */
+  summaryFor(content, filePath, tier, filter = null) {
+    const extracted = this.extract(content, filePath);
+    const symbols = extracted.filter(symbol => symbol.kind !== SymbolKind.MODULE && (!filter || filter(symbol)));
+    const exported = symbols.filter(symbol => symbol.exported);
+    const api = exported.length > 0 ? exported : symbols.filter(symbol => !symbol.name.startsWith('_'));
+
+    if (tier === 'names') {
+      return api.length > 0 ? `// Exports: ${api.map(symbol => symbol.qualifiedName).join(', ')}` : null;
+    }
+
+    const lines = content.split('\n');
+    const out = [];
+    for (const module of extracted.filter(symbol => symbol.kind === SymbolKind.MODULE)) {
+      if (module.doc) out.push(...module.doc.split('\n').map(line => `// ${line}`.trimEnd()));
+      if (module.signature) out.push(module.signature);
+    }
+    if (out.length > 0 && api.length > 0) out.push('');
+
+    // Members declared inside a type are listed as signatures of their own
+    const hasMembers = type => symbols.some(symbol => symbol !== type &&
+      symbol.startLine >= type.startLine && symbol.endLine <= type.endLine);
+    for (const symbol of api) {
+      if (TYPE_KINDS.has(symbol.kind) && !hasMembers(symbol)) {
+        out.push(...lines.slice(symbol.startLine - 1, symbol.endLine).map(line => line.trimEnd()));
+      } else {
+        const indent = /^\s*/.exec(lines[symbol.startLine - 1] || '')[0];
+        out.push(indent + (symbol.signature || `${symbol.kind} ${symbol.name}`).trim());
+      }
+    }
+
+    return out.length > 0 ? out.join('\n') : null;
+  }
+
+  /**
+   * Greedily pack items into the budget
+   * Items are visited by priority (desc), then tokens (asc). Each item gets the
+   * first tier that fits what is left: full, symbols (via options.expand) or a
+   * summary (via options.summarize); items no tier fits are dropped.
+   * @param {Array<Object>} items - { id, tokens, priority, tiers? }; an item's tiers
+   *   replace the budget's (per-directory tiers)
+   * @param {Object} options
+   * @param {Function} options.expand - item => Array<symbol item>; items with members
+   *   (grouped types) are picked whole and emitted as their members' ranges
+   * @param {Function} options.summarize - (item, tier) => summary text or null
+   * @param {Function} options.context - (item, symbols) => context entry { tokens } the
+   *   picked symbols need (their imports), added first when it still fits
+   * @returns {Object} Plan with included, partial, summarized and dropped items; items that
+   *   did not fit whole carry the tokens available when they were visited, and exhaustedAt
+   *   is the first of them
+   */
+  plan(items, options = {}) {
+    const limit = this.limit;
+    const included = [];
+    const partial = [];
+    const summarized = [];
+    const dropped = [];
+    let usedTokens = 0;
+    let exhaustedAt = null;
+
+    const attempt = (label, item, fn) => {
+      try {
+        return fn();
+      } catch (error) {
+        logger.debug(`${label} failed for ${item.id}: ${error.message}`);
+        return null;
+      }
+    };
+
+    for (const item of [...items].sort(comparePriority)) {
+      const available = limit - usedTokens;
+      let packed = false;
+
+      for (const tier of item.tiers || this.tiers) {
+        if (tier === 'full') {
+          if (item.tokens <= available) {
+            included.push(item);
+            usedTokens += item.tokens;
+            packed = true;
+          }
+        } else if (tier === 'symbols') {
+          const symbols = (options.expand && attempt('Symbol expansion', item, () => options.expand(item))) || [];
+          const picked = [];
+          let symbolTokens = 0;
+          for (const symbol of [...symbols].sort(comparePriority)) {
+            if (symbol.tokens <= available - symbolTokens) {
+              picked.push(symbol);
+              symbolTokens += symbol.tokens;
+            }
+          }
+
+          if (picked.length > 0) {
+            const omittedSymbols = symbols.length - picked.length;
+            // Grouped items are emitted as the ranges of their members
+            const ranges = picked.flatMap(symbol => symbol.members || [symbol])
+              .sort((a, b) => a.startLine - b.startLine);
+            const context = options.context && attempt('Symbol context', item, () => options.context(item, ranges));
+            if (context && context.tokens <= available - symbolTokens) {
+              ranges.unshift(context);
+              symbolTokens += context.tokens;
+            }
+            partial.push({
+              ...item,
+              available,
+              fullTokens: item.tokens,
+              tokens: symbolTokens,
+              symbols: ranges,
+              omittedSymbols
+            });
+            usedTokens += symbolTokens;
+            packed = true;
+          }
+        } else if (options.summarize) {
+          const summary = attempt('Summary', item, () => options.summarize(item, tier));
+          const tokens = summary ? this.count(summary, item.id) : 0;
+          if (summary && tokens <= available) {
+            summarized.push({ ...item, available, fullTokens: item.tokens, tokens, tier, summary });
+            usedTokens += tokens;
+            packed = true;
+          }
+        }
+
+        if (packed) break;
+      }
+
+      if (!packed) {
+        dropped.push({ ...item, available });
+      }
+      if (!exhaustedAt && included[included.length - 1] !== item) {
+        exhaustedAt = { id: item.id, priority: item.priority, tokens: item.tokens, available };
+      }
+    }
+
+    return {
+      limit,
+      maxTokens: this.options.maxTokens,
+      reserve: this.reserved,
+      reservePrompt: this.options.reservePrompt || 0,
+      tokenizer: this.getTokenizerName(),
+      tiers: this.tiers,
+      usedTokens,
+      remainingTokens: limit - usedTokens,
+      exhaustedAt,
+      included,
+      partial,
+      summarized,
+      dropped
+    };
+  }
+
+  /**
+   * How the limit of a plan follows from the window, e.g.
+   * " (200,000 - 2,000 prompt - 8,192 answer reserved)"
+   * @param {Object} plan - maxTokens, reserve (total) and reservePrompt
+   * @returns {string} Empty without a reserve
+   */
+  static formatReserve({ maxTokens, reserve, reservePrompt = 0 }) {
+    if (!reserve) return '';
+    const answer = reserve - reservePrompt;
+    const parts = reservePrompt
+      ? [`${reservePrompt.toLocaleString()} prompt`, ...(answer ? [`${answer.toLocaleString()} answer`] : [])]
+      : [reserve.toLocaleString()];
+    return ` (${maxTokens.toLocaleString()} - ${parts.join(' - ')} reserved)`;
+  }
+
+  /**
+   * Format a plan as a console report
+   * @param {Object} plan - Result of plan()
+   * @returns {string}
+   */
+  static formatPlan(plan) {
+    const lines = [];
+    const percent = plan.limit > 0 ? ((plan.usedTokens / plan.limit) * 100).toFixed(1) : '0.0';
+
+    lines.push('');
+    lines.push('💰 TOKEN BUDGET');
+    lines.push('='.repeat(80));
+    lines.push(`   Budget:    ${plan.limit.toLocaleString()} tokens` + TokenBudget.formatReserve(plan));
+    lines.push(`   Tokenizer: ${plan.tokenizer}`);
+    lines.push(`   Used:      ${plan.usedTokens.toLocaleString()} tokens (${percent}%)`);
+    const summarized = plan.summarized || [];
+    lines.push(`   Files:     ${plan.included.length} included, ${plan.partial.length} trimmed to symbols, ` +
+      (summarized.length ? `${summarized.length} summarized, ` : '') + `${plan.dropped.length} dropped`);
+
+    if (plan.partial.length > 0) {
+      lines.push('');
+      lines.push('✂️  Trimmed to symbols:');
+      for (const item of plan.partial) {
+        lines.push(`   ${item.id} — ${item.symbols.filter(symbol => !symbol.context).length} symbols, ${item.tokens.toLocaleString()}/${item.fullTokens.toLocaleString()} tokens`);
+      }
+    }
+
+    if (summarized.length > 0) {
+      lines.push('');
+      lines.push('📝 Summarized:');
+      for (const item of summarized) {
+        lines.push(`   ${item.id} — ${item.tier}, ${item.tokens.toLocaleString()}/${item.fullTokens.toLocaleString()} tokens`);
+      }
+    }
+
+    if (plan.dropped.length > 0) {
+      lines.push('');
+      lines.push('🚫 Dropped:');
+      for (const item of plan.dropped) {
+        lines.push(`   ${item.id} (${item.tokens.toLocaleString()} tokens)`);
+      }
+    }
+
+    return lines.join('\n');
+  }
+
+  /**
+   * Symbols of a file, through the cache when one is configured
+   * @private
+   */
+  extract(content, filePath) {
+    if (!this.symbolExtractor) {
+      this.symbolExtractor = new SymbolExtractor({ backend: 'heuristic' });
+    }
+
+    const cache = this.options.cache;
+    return cache
+      ? cache.symbols(ContentCache.hash(content), ContentCache.key('heuristic', filePath), filePath,
+        () => this.symbolExtractor.extract(content, filePath))
+      : this.symbolExtractor.extract(content, filePath);
+  }
+}
+
+/**
+ * Priority desc, then tokens asc, then id for a stable order
+ */
+function comparePriority(a, b) {
+  return (b.priority || 0) - (a.priority || 0) ||
+    a.tokens - b.tokens ||
+    compareBytes(String(a.id), String(b.id));
+}
+
+export default TokenBudget;
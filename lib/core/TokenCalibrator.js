@@ -0,0 +1,182 @@
+/**
+ * TokenCalibrator - Correction factors for token estimates
+ * v3.4.0 - Token estimation models and calibration (ctxman calibrate)
+ *
+ * Responsibilities:
+ * - Sample project files across languages
+ * - Compare TokenEstimator's models with a real tokenizer on the sample
+ * - Derive one correction factor per language, with the estimation error
+ *   before and after correcting
+ */
+
+import crypto from 'crypto';
+import { getTokenizerManager } from '../utils/tokenizer-adapter.js';
+import TokenEstimator, { CALIBRATION_TARGETS } from './TokenEstimator.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export class TokenCalibrator {
+  constructor(options = {}) {
+    this.options = {
+      sampleSize: 200, // Files compared
+      maxFileChars: 256 * 1024, // Longer files are compared on their beginning
+      minFiles: 3, // Languages with fewer sampled files keep their model as is
+      ...options
+    };
+  }
+
+  /**
+   * Real tokenizer to calibrate against
+   * @param {string|null} tokenizerName - --tokenizer value (auto picks as analysis does)
+   * @param {string|null} model - --target-model value
+   * @returns {Promise<{target: string, name: string, count: Function}>}
+   */
+  static async reference(tokenizerName, model = null) {
+    const manager = await getTokenizerManager();
+    const tokenizer = manager.resolveTokenizer(tokenizerName, model);
+    const key = [...manager.adapters].find(([, adapter]) => adapter === tokenizer)?.[0];
+
+    // Estimates and approximations have nothing to teach the estimator
+    if (!CALIBRATION_TARGETS[key] || key === 'claude-approx') {
+      throw new Error(`Calibration needs a real tokenizer, but ${tokenizer.getName()} would be used ` +
+        '(install tiktoken for cl100k_base and o200k_base, or @anthropic-ai/tokenizer for claude)');
+    }
+    return { target: CALIBRATION_TARGETS[key], name: tokenizer.getName(), count: content => tokenizer.count(content) };
+  }
+
+  /**
+   * Files to compare: spread over languages, in a stable pseudo-random order
+   * @param {Array<string>} files - Paths
+   * @returns {Array<string>}
+   */
+  sample(files) {
+    const byLanguage = new Map();
+    const ordered = files
+      .map(file => ({ file, order: crypto.createHash('sha1').update(file).digest('hex') }))
+      .sort((a, b) => compareBytes(a.order, b.order));
+    for (const { file } of ordered) {
+      const language = TokenEstimator.languageOf(file);
+      if (!byLanguage.has(language)) byLanguage.set(language, []);
+      byLanguage.get(language).push(file);
+    }
+
+    const queues = [...byLanguage.values()];
+    const picked = [];
+    while (picked.length < this.options.sampleSize && queues.some(queue => queue.length > 0)) {
+      for (const queue of queues) {
+        if (queue.length > 0 && picked.length < this.options.sampleSize) picked.push(queue.shift());
+      }
+    }
+    return picked;
+  }
+
+  /**
+   * @param {Array<{path: string, content: string}>} samples
+   * @param {{target: string, name: string, count: Function}} reference - From reference()
+   * @returns {Object} Calibration to store with TokenEstimator.saveCalibration()
+   */
+  calibrate(samples, reference) {
+    const measured = samples
+      .map(({ path: filePath, content }) => {
+        const text = content.slice(0, this.options.maxFileChars);
+        const language = TokenEstimator.languageOf(filePath);
+        return { language, estimated: TokenEstimator.rawEstimate(text, language), actual: reference.count(text) };
+      })
+      .filter(sample => sample.actual > 0);
+
+    const factors = {};
+    const languages = [];
+    for (const language of [...new Set(measured.map(sample => sample.language))].sort()) {
+      const group = measured.filter(sample => sample.language === language);
+      const factor = group.length >= this.options.minFiles ? round(ratio(group)) : null;
+      if (factor !== null) factors[language] = factor;
+      languages.push({
+        language,
+        files: group.length,
+        estimated: Math.round(sum(group, 'estimated')),
+        actual: sum(group, 'actual'),
+        factor,
+        errorBefore: relativeError(group, 1),
+        errorAfter: relativeError(group, factor ?? 1)
+      });
+    }
+
+    return {
+      target: reference.target,
+      tokenizer: reference.name,
+      calibratedAt: new Date().toISOString(),
+      files: measured.length,
+      tokens: sum(measured, 'actual'),
+      factors,
+      languages
+    };
+  }
+
+  /**
+   * Calibration report: estimates, real counts and error per language
+   * @param {Object} calibration - From calibrate()
+   * @returns {string}
+   */
+  static formatReport(calibration) {
+    const lines = [
+      '',
+      '🎯 TOKEN CALIBRATION',
+      '='.repeat(80),
+      `Tokenizer: ${calibration.tokenizer} (${calibration.target})`,
+      `Sampled: ${calibration.files} files, ${calibration.tokens.toLocaleString()} tokens`,
+      '',
+      `${'Language'.padEnd(12)}${'Files'.padStart(7)}${'Estimated'.padStart(12)}${'Actual'.padStart(12)}${'Factor'.padStart(9)}  Error`
+    ];
+    for (const entry of calibration.languages) {
+      const factor = entry.factor === null ? '-' : entry.factor.toFixed(3);
+      lines.push(`${entry.language.padEnd(12)}${String(entry.files).padStart(7)}${entry.estimated.toLocaleString().padStart(12)}` +
+        `${entry.actual.toLocaleString().padStart(12)}${factor.padStart(9)}  ${formatPercent(entry.errorBefore)} → ${formatPercent(entry.errorAfter)}`);
+    }
+    if (calibration.languages.some(entry => entry.factor === null)) {
+      lines.push('', '- Too few files sampled; the estimate of the language is not corrected');
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Real tokens per estimated token
+ * @private
+ */
+function ratio(samples) {
+  const estimated = sum(samples, 'estimated');
+  return estimated > 0 ? sum(samples, 'actual') / estimated : 1;
+}
+
+/**
+ * Tokens the estimates are off by, relative to the real count; weighted by
+ * size, so a two-line file does not count as much as a module
+ * @private
+ */
+function relativeError(samples, factor) {
+  const actual = sum(samples, 'actual');
+  const off = samples.reduce((acc, sample) => acc + Math.abs(sample.estimated * factor - sample.actual), 0);
+  return actual > 0 ? Math.round((off / actual) * 10000) / 10000 : 0;
+}
+
+/**
+ * @private
+ */
+function sum(samples, field) {
+  return samples.reduce((acc, sample) => acc + sample[field], 0);
+}
+
+/**
+ * @private
+ */
+function round(value) {
+  return Math.round(value * 1000) / 1000;
+}
+
+/**
+ * @private
+ */
+function formatPercent(value) {
+  return `${(value * 100).toFixed(1)}%`;
+}
+
+export default TokenCalibrator;
@@ -0,0 +1,299 @@
+/**
+ * TokenEstimator - Per-language token estimates
+ * v3.4.0 - Token estimation models and calibration (ctxman calibrate)
+ *
+ * Responsibilities:
+ * - Estimate tokens from what BPE tokenizers split on: identifier pieces,
+ *   digit groups, punctuation runs, line breaks and non-ASCII characters,
+ *   instead of one characters-per-token ratio for the whole file
+ * - Keep one model per language (dense JSON, wordy Markdown, C-like code)
+ * - Apply per-language correction factors measured by `ctxman calibrate`
+ *   against a real tokenizer, stored in .ctxman/cache/calibration.json
+ */
+
+import fs from 'fs';
+import path from 'path';
+import crypto from 'crypto';
+import { TOKENIZER_ALIASES } from '../utils/tokenizer-adapter.js';
+import LanguageDetector from '../languages/LanguageDetector.js';
+
+export const CALIBRATION_FILE = path.join('.ctxman', 'cache', 'calibration.json');
+
+// Calibration target of each TokenizerManager adapter key
+export const CALIBRATION_TARGETS = {
+  'tiktoken': 'cl100k_base',
+  'tiktoken-o200k': 'o200k_base',
+  'claude': 'claude',
+  'anthropic': 'claude',
+  'claude-approx': 'claude'
+};
+
+// word: characters per token past the first of an identifier piece
+// punctuation: characters per token of a punctuation run
+export const LANGUAGE_MODELS = {
+  javascript: { extensions: ['.js', '.jsx', '.mjs', '.cjs'], word: 3.2, punctuation: 2.0 },
+  typescript: { extensions: ['.ts', '.tsx', '.mts', '.cts'], word: 3.2, punctuation: 2.0 },
+  python: { extensions: ['.py', '.pyi'], word: 3.0, punctuation: 1.8 },
+  java: { extensions: ['.java'], word: 3.5, punctuation: 2.0 },
+  go: { extensions: ['.go'], word: 3.3, punctuation: 2.0 },
+  rust: { extensions: ['.rs'], word: 3.4, punctuation: 2.0 },
+  php: { extensions: ['.php'], word: 3.1, punctuation: 1.8 },
+  ruby: { extensions: ['.rb'], word: 3.0, punctuation: 1.8 },
+  swift: { extensions: ['.swift'], word: 3.3, punctuation: 2.0 },
+  kotlin: { extensions: ['.kt', '.kts'], word: 3.5, punctuation: 2.0 },
+  csharp: { extensions: ['.cs'], word: 3.5, punctuation: 2.0 },
+  cpp: { extensions: ['.cpp', '.cc', '.cxx', '.hpp', '.hh'], word: 3.6, punctuation: 2.0 },
+  c: { extensions: ['.c', '.h'], word: 3.6, punctuation: 2.0 },
+  scala: { extensions: ['.scala'], word: 3.5, punctuation: 2.0 },
+  json: { extensions: ['.json'], word: 2.5, punctuation: 1.5 },
+  markdown: { extensions: ['.md', '.mdx'], word: 3.5, punctuation: 1.5 },
+  text: { extensions: ['.txt'], word: 4.0, punctuation: 1.5 },
+  other: { extensions: [], word: 3.5, punctuation: 1.8 }
+};
+
+const LANGUAGE_BY_EXTENSION = new Map(
+  Object.entries(LANGUAGE_MODELS).flatMap(([language, model]) => model.extensions.map(ext => [ext, language]))
+);
+
+// Pieces are scanned by character code instead of matched with a regular
+// expression, so estimating a file allocates no substrings
+const CHAR = {
+  TAB: 0x09, LF: 0x0a, CR: 0x0d, SPACE: 0x20,
+  ZERO: 0x30, NINE: 0x39, UPPER_A: 0x41, UPPER_Z: 0x5a, LOWER_A: 0x61, LOWER_Z: 0x7a
+};
+
+export class TokenEstimator {
+  /**
+   * @param {Object|null} calibration - Entry of calibration.json:
+   *   { target, tokenizer, calibratedAt, files, factors: { language: factor } }
+   */
+  constructor(calibration = null) {
+    this.calibration = calibration;
+    this.factors = calibration?.factors || {};
+  }
+
+  /**
+   * Estimator with the stored calibration for a tokenizer
+   * @param {string} root - Project root
+   * @param {string|null} tokenizer - --tokenizer value; auto/null uses the latest calibration
+   * @returns {TokenEstimator} Uncalibrated when nothing matching is stored
+   */
+  static load(root, tokenizer = null) {
+    const data = TokenEstimator.readCalibrations(root);
+    const name = (tokenizer || 'auto').toLowerCase();
+    const target = name === 'auto' ? data.latest : TokenEstimator.targetOf(name);
+    return new TokenEstimator((target && data.targets[target]) || null);
+  }
+
+  /**
+   * Stored calibrations; a missing or unreadable file has none
+   * @param {string} root
+   * @returns {{latest: string|null, targets: Object}}
+   */
+  static readCalibrations(root) {
+    try {
+      const data = JSON.parse(fs.readFileSync(path.join(root, CALIBRATION_FILE), 'utf8'));
+      return { latest: data.latest || null, targets: data.targets || {} };
+    } catch {
+      return { latest: null, targets: {} };
+    }
+  }
+
+  /**
+   * Store a calibration, replacing the previous one for its tokenizer
+   * @param {string} root
+   * @param {Object} calibration - With a `target` (cl100k_base, o200k_base, claude)
+   * @returns {string} File written
+   */
+  static saveCalibration(root, calibration) {
+    const data = TokenEstimator.readCalibrations(root);
+    data.targets[calibration.target] = calibration;
+    data.latest = calibration.target;
+
+    const filePath = path.join(root, CALIBRATION_FILE);
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    fs.writeFileSync(filePath, JSON.stringify(data, null, 2));
+    return filePath;
+  }
+
+  /**
+   * Calibration target of a tokenizer name or alias
+   * @param {string} name - e.g. cl100k, o200k_base, anthropic
+   * @returns {string|null}
+   */
+  static targetOf(name) {
+    return CALIBRATION_TARGETS[TOKENIZER_ALIASES[name]] || null;
+  }
+
+  /**
+   * @param {string} filePath
+   * @param {string|null} [content] - Identifies extension-less scripts by their shebang
+   * @returns {string} Key of LANGUAGE_MODELS
+   */
+  static languageOf(filePath, content = null) {
+    return LANGUAGE_BY_EXTENSION.get(LanguageDetector.extensionOf(filePath, content)) || 'other';
+  }
+
+  /**
+   * Estimated tokens, with the calibration factor of the file's language
+   * @param {string} content
+   * @param {string} filePath - Picks the language model
+   * @returns {number}
+   */
+  estimate(content, filePath) {
+    const language = TokenEstimator.languageOf(filePath, content);
+    return Math.round(TokenEstimator.rawEstimate(content, language) * (this.factors[language] ?? 1));
+  }
+
+  /**
+   * Uncalibrated estimate of a language model
+   * @param {string} content
+   * @param {string} language - Key of LANGUAGE_MODELS
+   * @returns {number} Fractional token count
+   */
+  static rawEstimate(content, language) {
+    const model = LANGUAGE_MODELS[language] || LANGUAGE_MODELS.other;
+    const length = content.length;
+    let tokens = 0;
+    let i = 0;
+
+    // Digit runs, ASCII letter runs, non-ASCII runs, line breaks with their
+    // indentation, space runs and punctuation runs; other whitespace is skipped
+    while (i < length) {
+      const code = content.charCodeAt(i);
+      const start = i;
+
+      if (isLetter(code)) {
+        // camelCase and PascalCase pieces are split; common words are one token
+        while (i < length && isLetter(content.charCodeAt(i))) i++;
+        tokens = addCasePieces(tokens, content, start, i, model.word);
+      } else if (isDigit(code)) {
+        // Tokenizers group up to three digits
+        while (i < length && isDigit(content.charCodeAt(i))) i++;
+        tokens += Math.ceil((i - start) / 3);
+      } else if (code >= 0x80) {
+        while (i < length && content.charCodeAt(i) >= 0x80) i++;
+        tokens += nonAsciiTokens(content, start, i);
+      } else if (code === CHAR.LF || (code === CHAR.CR && content.charCodeAt(i + 1) === CHAR.LF)) {
+        // Indentation of two or more columns is a token of its own
+        i += code === CHAR.CR ? 2 : 1;
+        const indent = i;
+        while (i < length && isBlank(content.charCodeAt(i))) i++;
+        tokens += i - indent > 1 ? 2 : 1;
+      } else if (isBlank(code)) {
+        // A single space belongs to the next word
+        while (i < length && isBlank(content.charCodeAt(i))) i++;
+        tokens += i - start > 1 ? 1 : 0;
+      } else if (isWhitespace(code)) {
+        i++;
+      } else {
+        while (i < length && isPunctuation(content.charCodeAt(i))) i++;
+        tokens += Math.max(1, (i - start) / model.punctuation);
+      }
+    }
+
+    return tokens;
+  }
+
+  /**
+   * Short display name: estimate, or the tokenizer it was calibrated to
+   * @returns {string}
+   */
+  getName() {
+    return this.calibration ? `estimate (calibrated to ${this.calibration.target})` : 'estimate';
+  }
+
+  /**
+   * Cache key of estimates: changes with every calibration
+   * @returns {string}
+   */
+  getKey() {
+    if (!this.calibration) return 'estimate';
+    const digest = crypto.createHash('sha256').update(JSON.stringify(this.factors)).digest('hex').slice(0, 12);
+    return `estimate:${this.calibration.target}:${digest}`;
+  }
+}
+
+/**
+ * Tokens of the identifier pieces of a letter run: an upper-case run
+ * (HTTP), or an optional capital and a lower-case run (Server, parse)
+ * @private
+ */
+function addCasePieces(tokens, content, start, end, word) {
+  let i = start;
+  while (i < end) {
+    const pieceStart = i;
+    while (i < end && isUpper(content.charCodeAt(i))) i++;
+    if (i < end && i - pieceStart > 1) {
+      // The last capital starts the next piece (HTTPServer: HTTP, Server)
+      i--;
+    } else {
+      while (i < end && !isUpper(content.charCodeAt(i))) i++;
+    }
+    tokens += 1 + Math.max(0, i - pieceStart - word) / word;
+  }
+  return tokens;
+}
+
+/**
+ * Non-ASCII characters cost more the more UTF-8 bytes they take: accented
+ * and Cyrillic letters often split words, CJK is about one token per
+ * character, emoji take several
+ * @private
+ */
+function nonAsciiTokens(content, start, end) {
+  let tokens = 0;
+  for (let i = start; i < end; i++) {
+    const code = content.codePointAt(i);
+    if (code > 0xffff) i++;
+    tokens += code < 0x800 ? 0.6 : code < 0x10000 ? 1.2 : 2.5;
+  }
+  return tokens;
+}
+
+/**
+ * @private
+ */
+function isLetter(code) {
+  return isUpper(code) || (code >= CHAR.LOWER_A && code <= CHAR.LOWER_Z);
+}
+
+/**
+ * @private
+ */
+function isUpper(code) {
+  return code >= CHAR.UPPER_A && code <= CHAR.UPPER_Z;
+}
+
+/**
+ * @private
+ */
+function isDigit(code) {
+  return code >= CHAR.ZERO && code <= CHAR.NINE;
+}
+
+/**
+ * Space or tab
+ * @private
+ */
+function isBlank(code) {
+  return code === CHAR.SPACE || code === CHAR.TAB;
+}
+
+/**
+ * ASCII whitespace (\t \n \v \f \r and space)
+ * @private
+ */
+function isWhitespace(code) {
+  return (code >= CHAR.TAB && code <= CHAR.CR) || code === CHAR.SPACE;
+}
+
+/**
+ * ASCII character that is not whitespace, a letter or a digit
+ * @private
+ */
+function isPunctuation(code) {
+  return code < 0x80 && !isWhitespace(code) && !isLetter(code) && !isDigit(code);
+}
+
+export default TokenEstimator;
@@ -0,0 +1,149 @@
+/**
+ * TokenTree - Directory tree with token counts
+ * v3.4.0 - Token tree (--tree)
+ *
+ * Responsibilities:
+ * - Sum tokens and files per directory, down from the project root
+ * - Show each file and directory with its tokens and its share of the
+ *   token budget (of all tokens without one)
+ * - Sort entries by name or by tokens, and collapse directories below a depth
+ * - Flag directories that alone exceed the budget
+ */
+
+import TokenUtils from '../utils/token-utils.js';
+
+export const TREE_SORTS = ['name', 'tokens'];
+
+// Width of the share bar at 100%
+const BAR_WIDTH = 10;
+
+// Longest name column; deeper or longer entries are cut with …
+const MAX_NAME_WIDTH = 56;
+
+export class TokenTree {
+  constructor(options = {}) {
+    this.options = {
+      sort: 'name', // name | tokens
+      depth: null, // Levels shown below the root; deeper directories collapse (null = all)
+      budget: null, // Tokens that make 100%; null = all analyzed tokens
+      ...options
+    };
+    if (!TREE_SORTS.includes(this.options.sort)) {
+      throw new Error(`Invalid tree sort: ${this.options.sort} (expected ${TREE_SORTS.join(', ')})`);
+    }
+  }
+
+  /**
+   * Tree of directories and files with cumulative token counts
+   * @param {Array<{relativePath: string, tokens: number}>} files
+   * @param {string} [rootName]
+   * @returns {Object} Node { name, path, type, tokens, files, children? }
+   */
+  build(files, rootName = '.') {
+    const root = directoryNode(rootName, '');
+    for (const file of files) {
+      const parts = file.relativePath.split(/[\\/]/);
+      let current = root;
+      root.tokens += file.tokens;
+      root.files++;
+      for (const part of parts.slice(0, -1)) {
+        const dirPath = current.path ? `${current.path}/${part}` : part;
+        if (!current.children.has(part)) current.children.set(part, directoryNode(part, dirPath));
+        current = current.children.get(part);
+        current.tokens += file.tokens;
+        current.files++;
+      }
+      const name = parts[parts.length - 1];
+      current.children.set(name, {
+        name,
+        path: current.path ? `${current.path}/${name}` : name,
+        type: 'file',
+        tokens: file.tokens,
+        files: 1
+      });
+    }
+    return root;
+  }
+
+  /**
+   * Tree report
+   * @param {Array<{relativePath: string, tokens: number}>} files
+   * @param {string} [rootName]
+   * @returns {string}
+   */
+  format(files, rootName) {
+    const root = this.build(files, rootName);
+    const { budget, sort } = this.options;
+    const total = budget || root.tokens;
+
+    const rows = [{ label: `${root.name}/`, node: root }];
+    const visit = (node, prefix, level) => {
+      const children = this.sortedChildren(node);
+      children.forEach((child, index) => {
+        const last = index === children.length - 1;
+        const collapsed = child.type === 'directory' && this.options.depth !== null && level + 1 >= this.options.depth;
+        const name = child.type === 'directory'
+          ? `${child.name}/${collapsed ? ` (${child.files} ${child.files === 1 ? 'file' : 'files'})` : ''}`
+          : child.name;
+        rows.push({ label: `${prefix}${last ? '└── ' : '├── '}${name}`, node: child });
+        if (child.type === 'directory' && !collapsed) {
+          visit(child, `${prefix}${last ? '    ' : '│   '}`, level + 1);
+        }
+      });
+    };
+    visit(root, '', 0);
+
+    const width = Math.min(MAX_NAME_WIDTH, Math.max(...rows.map(row => row.label.length)));
+    const of = budget ? `% of ${budget.toLocaleString()}-token budget` : '% of all tokens';
+    const lines = ['', `🌳 TOKEN TREE (by ${sort}, ${of})`, '='.repeat(80)];
+
+    for (const { label, node } of rows) {
+      const share = total > 0 ? node.tokens / total : 0;
+      const over = budget && node.type === 'directory' && node !== root && node.tokens > budget ? '  ⚠️ over budget' : '';
+      lines.push(`${fit(label, width)}  ${TokenUtils.format(node.tokens).padStart(7)}  ${`${(share * 100).toFixed(1)}%`.padStart(6)}  ${bar(share)}${over}`);
+    }
+
+    if (budget && root.tokens > budget) {
+      lines.push('');
+      lines.push(`⚠️  ${root.tokens.toLocaleString()} tokens analyzed, ${(root.tokens - budget).toLocaleString()} over the budget`);
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * @private
+   */
+  sortedChildren(node) {
+    // Names in the order of the digest's directory structure
+    const byName = (a, b) => (a.name < b.name ? -1 : a.name > b.name ? 1 : 0);
+    const children = [...node.children.values()];
+    return this.options.sort === 'tokens'
+      ? children.sort((a, b) => b.tokens - a.tokens || byName(a, b))
+      : children.sort(byName);
+  }
+}
+
+/**
+ * @private
+ */
+function directoryNode(name, dirPath) {
+  return { name, path: dirPath, type: 'directory', tokens: 0, files: 0, children: new Map() };
+}
+
+/**
+ * Share bar; shares over 100% fill it
+ * @private
+ */
+function bar(share) {
+  const filled = Math.min(BAR_WIDTH, Math.round(share * BAR_WIDTH));
+  return `${'█'.repeat(filled)}${'░'.repeat(BAR_WIDTH - filled)}`;
+}
+
+/**
+ * @private
+ */
+function fit(label, width) {
+  return label.length > width ? `${label.slice(0, width - 1)}…` : label.padEnd(width);
+}
+
+export default TokenTree;
@@ -0,0 +1,291 @@
+/**
+ * TransformPipeline - Ordered chain of file-content transforms
+ * v3.4.0 - Configurable transforms (--transforms, profile `transforms:`)
+ *
+ * Responsibilities:
+ * - Run the read-time transforms of a file in configured order: license
+ *   headers, comment stripping, anonymization, comment translation,
+ *   whitespace normalization and transforms registered through the API
+ * - Keep the emission transforms, secret redaction and body elision, at the
+ *   end of the list: they work on the files selected for export
+ * - Parse step lists from the command line and context.yaml
+ *
+ * Built-in steps run the transforms the options configure (stripper,
+ * licenseDetector, ...); configure() creates those a list names with their
+ * defaults, so listing a step enables it.
+ */
+
+import ContentStripper, { STRIP_MODES } from './ContentStripper.js';
+import LicenseDetector from './LicenseDetector.js';
+import SecretRedactor from './SecretRedactor.js';
+import BodyElider, { parseElisionRules } from './BodyElider.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('TransformPipeline');
+
+/**
+ * Built-in steps: the option holding their transform, and whether analysis
+ * token counts see them (anonymized names and translations only appear on export)
+ */
+export const BUILTIN_TRANSFORMS = {
+  licenses: { option: 'licenseDetector', flag: '--licenses', analyze: true },
+  strip: { option: 'stripper', flag: '--strip', analyze: true },
+  anonymize: { option: 'anonymizer', flag: '--anonymize', analyze: false },
+  translate: { option: 'commentTranslator', flag: '--translate-comments', analyze: false },
+  'normalize-whitespace': { option: null, flag: null, analyze: true },
+  redact: { option: 'redactor', flag: '--redact', emission: true },
+  'elide-bodies': { option: 'bodyElider', flag: '--elide-bodies', emission: true }
+};
+
+// Order of the fixed chain before pipelines; flags add their steps in this order
+export const DEFAULT_TRANSFORMS = ['licenses', 'strip', 'anonymize', 'translate', 'redact', 'elide-bodies'];
+
+const registeredTransforms = new Map();
+
+/**
+ * Register a transform for pipelines to list by name (library API)
+ * @param {string} name
+ * @param {Function|Object} transform - (content, context) => string, or
+ *   { apply(content, context), analyze = true }; context is { file, relativePath, options },
+ *   file '/'-separated and options the step's options from the list
+ */
+export function registerTransform(name, transform) {
+  if (BUILTIN_TRANSFORMS[name]) {
+    throw new Error(`Cannot register transform "${name}": it is built in`);
+  }
+  registeredTransforms.set(name, normalizeTransform(name, transform));
+}
+
+/**
+ * @returns {Array<string>} Names registered with registerTransform()
+ */
+export function getRegisteredTransforms() {
+  return [...registeredTransforms.keys()];
+}
+
+export class TransformPipeline {
+  /**
+   * @param {Array<string|Object>} steps - Step names, or one-key mappings of a name to its options
+   * @param {Object} [options]
+   * @param {Object} [options.transforms] - Transforms by name, besides registered ones
+   * @throws {Error} For unknown steps and emission steps before read-time ones
+   */
+  constructor(steps = DEFAULT_TRANSFORMS, options = {}) {
+    this.options = {
+      transforms: {}, // name -> transform, like registerTransform()
+      ...options
+    };
+    this.custom = new Map([...registeredTransforms,
+      ...Object.entries(this.options.transforms).map(([name, transform]) => [name, normalizeTransform(name, transform)])]);
+    this.steps = TransformPipeline.parse(steps).map(step => {
+      if (!BUILTIN_TRANSFORMS[step.name] && !this.custom.has(step.name)) {
+        const known = [...Object.keys(BUILTIN_TRANSFORMS), ...this.custom.keys()];
+        throw new Error(`Unknown transform "${step.name}" (available: ${known.join(', ')})`);
+      }
+      return step;
+    });
+
+    const firstEmission = this.steps.findIndex(step => BUILTIN_TRANSFORMS[step.name]?.emission);
+    const late = firstEmission === -1 ? null : this.steps.slice(firstEmission).find(step => !BUILTIN_TRANSFORMS[step.name]?.emission);
+    if (late) {
+      throw new Error(`Transform "${late.name}" must come before ${this.steps[firstEmission].name}: redact and elide-bodies work on the exported files and go last`);
+    }
+    logger.debug(`Transforms: ${this.describe()}`);
+  }
+
+  /**
+   * Normalize a step list
+   * @param {string|Array<string|Object>} value - "licenses,strip" or a list of names and { name: options }
+   * @returns {Array<{name: string, options: Object}>}
+   * @throws {Error} When the list is malformed or names a step twice
+   */
+  static parse(value) {
+    const list = typeof value === 'string' ? value.split(',').map(name => name.trim()).filter(Boolean) : value;
+    if (!Array.isArray(list)) {
+      throw new Error('transforms must be a list of transform names');
+    }
+
+    const steps = list.map(entry => {
+      if (typeof entry === 'string' && entry.trim()) return { name: entry.trim(), options: {} };
+      if (entry && typeof entry === 'object' && !Array.isArray(entry) && Object.keys(entry).length === 1) {
+        const [[name, options]] = Object.entries(entry);
+        if (options === null || typeof options !== 'object' || Array.isArray(options)) {
+          throw new Error(`transform "${name}" options must be a mapping`);
+        }
+        return { name, options };
+      }
+      throw new Error('transforms must be names or one-key mappings of a name to its options (e.g. strip: with mode: comments)');
+    });
+
+    const seen = new Set();
+    for (const step of steps) {
+      if (seen.has(step.name)) throw new Error(`transform "${step.name}" is listed twice`);
+      seen.add(step.name);
+    }
+    return steps;
+  }
+
+  /**
+   * @param {string} name
+   * @returns {boolean} Whether the pipeline lists the step
+   */
+  has(name) {
+    return this.steps.some(step => step.name === name);
+  }
+
+  /**
+   * Add a step at its DEFAULT_TRANSFORMS position (a flag enabled it); read-time
+   * steps go before the emission steps
+   * @param {string} name - Built-in step
+   * @returns {TransformPipeline}
+   */
+  add(name) {
+    if (this.has(name)) return this;
+    const rank = DEFAULT_TRANSFORMS.indexOf(name);
+    const at = BUILTIN_TRANSFORMS[name].emission
+      ? this.steps.findIndex(step => DEFAULT_TRANSFORMS.indexOf(step.name) > rank && BUILTIN_TRANSFORMS[step.name]?.emission)
+      : this.steps.findIndex(step => BUILTIN_TRANSFORMS[step.name]?.emission);
+    this.steps.splice(at === -1 ? this.steps.length : at, 0, { name, options: {} });
+    return this;
+  }
+
+  /**
+   * Create the built-in transforms the steps need and options lack, with their
+   * defaults, then add the steps of transforms options already had (flags)
+   * @param {Object} options - TokenCalculator options; set in place
+   * @param {string} root - Project root, for redaction rules
+   * @returns {Object} options, with this pipeline as options.transforms
+   * @throws {Error} For invalid step options, and anonymize and translate
+   *   steps without their mapping or API
+   */
+  configure(options, root) {
+    for (const step of this.steps) {
+      if (step.name === 'strip' && !options.stripper) {
+        const mode = step.options.mode || 'both';
+        if (!STRIP_MODES.includes(mode)) {
+          throw new Error(`Invalid strip mode: ${mode} (expected ${STRIP_MODES.join(', ')})`);
+        }
+        options.strip = mode;
+        options.stripper = new ContentStripper({ mode, keepDocs: Boolean(step.options.keepDocs) });
+      } else if (step.name === 'licenses' && !options.licenseDetector) {
+        options.licenseDetector = new LicenseDetector();
+      } else if (step.name === 'redact' && !options.redactor) {
+        options.redactor = SecretRedactor.load(root);
+      } else if (step.name === 'elide-bodies' && !options.bodyElider) {
+        options.bodyElider = new BodyElider(parseElisionRules([String(step.options.lines ?? 20)]));
+      } else if ((step.name === 'anonymize' || step.name === 'translate') && !options[BUILTIN_TRANSFORMS[step.name].option]) {
+        throw new Error(`The ${step.name} transform needs ${BUILTIN_TRANSFORMS[step.name].flag}`);
+      }
+    }
+    for (const name of DEFAULT_TRANSFORMS) {
+      if (options[BUILTIN_TRANSFORMS[name].option]) this.add(name);
+    }
+    options.transforms = this;
+    return options;
+  }
+
+  /**
+   * Whether a step cannot run on a worker thread (a transform from the API)
+   * @returns {boolean}
+   */
+  hasCustom() {
+    return this.steps.some(step => !BUILTIN_TRANSFORMS[step.name]);
+  }
+
+  /**
+   * Run the read-time steps
+   * @param {string} content
+   * @param {Object} context
+   * @param {string} context.relativePath - Native relative path
+   * @param {Object} context.options - TokenCalculator options holding the built-in transforms
+   * @param {boolean} [context.analyze] - Only the steps that analysis token counts see
+   * @returns {string}
+   */
+  apply(content, { relativePath, options, analyze = false }) {
+    const file = relativePath.split(/[\\/]/).join('/');
+    let result = content;
+    for (const step of this.steps) {
+      const builtin = BUILTIN_TRANSFORMS[step.name];
+      if (builtin) {
+        if (builtin.emission || (analyze && !builtin.analyze)) continue;
+        result = applyBuiltin(step, builtin.option ? options[builtin.option] : null, result, relativePath, file);
+        continue;
+      }
+      const transform = this.custom.get(step.name);
+      if (analyze && !transform.analyze) continue;
+      const output = transform.apply(result, { file, relativePath, options: step.options });
+      if (typeof output !== 'string') {
+        throw new Error(`Transform "${step.name}" returned ${output === null ? 'null' : typeof output} for ${file}, not a string`);
+      }
+      result = output;
+    }
+    return result;
+  }
+
+  /**
+   * Cache key of the steps that shape analyzed contents
+   * @returns {string}
+   */
+  getKey() {
+    return this.steps.map(step => (Object.keys(step.options).length > 0 ? `${step.name}${JSON.stringify(step.options)}` : step.name)).join('>');
+  }
+
+  /**
+   * Report line, e.g. "licenses → strip → normalize-whitespace → redact"
+   * @returns {string}
+   */
+  describe() {
+    return this.steps.map(step => step.name).join(' → ') || 'none';
+  }
+}
+
+/**
+ * Collapse blank-line runs and drop trailing whitespace and CRLF line ends
+ * @param {string} content
+ * @param {Object} [options] - { blankLines } kept of each run (default 1)
+ * @returns {string}
+ */
+export function normalizeWhitespace(content, { blankLines = 1 } = {}) {
+  const lines = content.replace(/\r\n?/g, '\n').split('\n').map(line => line.replace(/[ \t]+$/, ''));
+  const kept = [];
+  let blank = 0;
+  for (const line of lines) {
+    blank = line === '' ? blank + 1 : 0;
+    if (blank <= blankLines) kept.push(line);
+  }
+  while (kept.length > 0 && kept[0] === '') kept.shift();
+  const text = kept.join('\n').replace(/\n+$/, '');
+  return text ? `${text}\n` : '';
+}
+
+/**
+ * @private
+ */
+function applyBuiltin(step, transform, content, relativePath, file) {
+  switch (step.name) {
+    case 'normalize-whitespace':
+      return normalizeWhitespace(content, step.options);
+    case 'licenses':
+    case 'strip':
+      return transform ? transform.strip(content, relativePath) : content;
+    case 'anonymize':
+      return transform ? transform.replaceStrings(content, file) : content;
+    case 'translate':
+      return transform ? transform.apply(content, relativePath) : content;
+    default:
+      return content;
+  }
+}
+
+/**
+ * @private
+ */
+function normalizeTransform(name, transform) {
+  if (typeof transform === 'function') return { apply: transform, analyze: true };
+  if (transform && typeof transform.apply === 'function') {
+    return { apply: transform.apply.bind(transform), analyze: transform.analyze ?? true };
+  }
+  throw new Error(`Transform "${name}" must be a function or an object with apply(content, context)`);
+}
+
+export default TransformPipeline;
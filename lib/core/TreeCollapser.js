@@ -0,0 +1,202 @@
+/**
+ * TreeCollapser - Directory structure of a digest within a token budget
+ * v3.4.0 - Budget-aware tree (--tree-budget)
+ *
+ * Responsibilities:
+ * - Lay out every analyzed file, expanding only the directories that hold
+ *   included files; other directories show as `dir/ (42 files, 12.3K tokens)`
+ *   and the files left out of a directory as one line
+ * - Collapse expanded directories below a rising priority threshold until
+ *   the tree fits its budget; a directory ranks by its highest-priority
+ *   included file, so parents never collapse before their subdirectories
+ * - Merge the collapsed directories of a level into one line as a last resort
+ *
+ * Included files stay listed while their directory is expanded, so the
+ * tree still names every file whose content follows it.
+ */
+
+import TokenUtils from '../utils/token-utils.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export const DEFAULT_TREE_BUDGET = 1000;
+
+export class TreeCollapser {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      maxTokens: DEFAULT_TREE_BUDGET, // Tokens the tree may take
+      ...options
+    };
+  }
+
+  /**
+   * Lay out the tree
+   * @param {Array<Object>} files - Every analyzed file ({ relativePath, tokens })
+   * @param {Object} context
+   * @param {Set<string>} context.included - '/'-separated paths of the exported files
+   * @param {string} context.rootName
+   * @param {function(string): number} [context.countTokens]
+   * @param {function(Object): number} [context.priorityOf] - Priority of an included file
+   * @returns {Object} { text, tokens, threshold, files, shown, collapsed, merged }; threshold
+   *   is the priority a directory needed to stay expanded, null when none had to collapse
+   */
+  layout(files, { included, rootName, countTokens = text => Math.ceil(text.length / 4), priorityOf = () => 0 }) {
+    const root = this.build(files, included, rootName, priorityOf);
+    const expandable = [];
+    const walk = node => {
+      for (const child of node.children.values()) {
+        if (child.type === 'directory' && child.included > 0) {
+          expandable.push(child);
+          walk(child);
+        }
+      }
+    };
+    walk(root);
+
+    const attempt = (threshold, merge) => {
+      const collapsed = new Set(expandable.filter(node => node.priority < threshold));
+      const { text, listed } = this.render(root, collapsed, merge);
+      return { text, listed, tokens: countTokens(text), collapsed, merge };
+    };
+
+    // Thresholds in rising order: none, then each directory priority in turn
+    const thresholds = [-Infinity, ...[...new Set(expandable.map(node => node.priority))].sort((a, b) => a - b).slice(1), Infinity];
+    let result = null;
+    let threshold = null;
+    for (const candidate of thresholds) {
+      result = attempt(candidate, false);
+      threshold = candidate;
+      if (result.tokens <= this.options.maxTokens) break;
+    }
+    if (result.tokens > this.options.maxTokens) result = attempt(Infinity, true);
+
+    return {
+      text: result.text,
+      tokens: result.tokens,
+      threshold: threshold === -Infinity ? null : threshold,
+      files: root.files,
+      shown: result.listed,
+      collapsed: result.collapsed.size,
+      merged: result.merge
+    };
+  }
+
+  /**
+   * Report line, e.g. "🌳 Tree: 12 of 840 files listed, 6 directories collapsed below priority 10 (412 of 1,000 tokens)"
+   * @param {Object} result - Result of layout()
+   * @returns {string}
+   */
+  describe(result) {
+    const below = result.threshold === null
+      ? ''
+      : result.threshold === Infinity
+        ? `, ${result.collapsed} ${result.collapsed === 1 ? 'directory' : 'directories'} collapsed`
+        : `, ${result.collapsed} ${result.collapsed === 1 ? 'directory' : 'directories'} collapsed below priority ${result.threshold}`;
+    const over = result.tokens > this.options.maxTokens ? ' ⚠️ over budget' : '';
+    return `🌳 Tree: ${result.shown} of ${result.files} files listed${below}` +
+      ` (${result.tokens.toLocaleString()} of ${this.options.maxTokens.toLocaleString()} tokens)${over}`;
+  }
+
+  /**
+   * Directory nodes with file, token and included counts, and the priority of their best included file
+   * @private
+   */
+  build(files, included, rootName, priorityOf) {
+    const root = directoryNode(rootName, '');
+    for (const fileInfo of files) {
+      const file = fileInfo.relativePath.split(/[\\/]/).join('/');
+      const isIncluded = included.has(file);
+      const priority = isIncluded ? priorityOf(fileInfo) : -Infinity;
+      const parts = file.split('/');
+      let current = root;
+      const count = node => {
+        node.files++;
+        node.tokens += fileInfo.tokens || 0;
+        if (isIncluded) {
+          node.included++;
+          node.priority = Math.max(node.priority, priority);
+        }
+      };
+      count(root);
+      for (const part of parts.slice(0, -1)) {
+        if (!current.children.has(part)) {
+          current.children.set(part, directoryNode(part, current.path ? `${current.path}/${part}` : part));
+        }
+        current = current.children.get(part);
+        count(current);
+      }
+      const name = parts[parts.length - 1];
+      current.children.set(name, { name, type: 'file', tokens: fileInfo.tokens || 0, included: isIncluded });
+    }
+    return root;
+  }
+
+  /**
+   * Rows in the connector style of the digest's directory structure
+   * @private
+   * @returns {{text: string, listed: number}} listed counts the files named
+   */
+  render(root, collapsed, merge) {
+    const lines = [`└── ${root.name}/`];
+    let listed = 0;
+    const visit = (node, prefix) => {
+      const rows = [];
+      const others = { files: 0, tokens: 0 };
+      const folded = { directories: 0, files: 0, tokens: 0, included: 0 };
+      for (const child of [...node.children.values()].sort((a, b) => compareBytes(a.name, b.name))) {
+        if (child.type === 'file') {
+          if (child.included) {
+            rows.push({ label: child.name });
+            listed++;
+          } else {
+            others.files++;
+            others.tokens += child.tokens;
+          }
+        } else if (child.included > 0 && !collapsed.has(child)) {
+          rows.push({ label: `${child.name}/`, node: child });
+        } else if (merge) {
+          folded.directories++;
+          folded.files += child.files;
+          folded.tokens += child.tokens;
+          folded.included += child.included;
+        } else {
+          rows.push({ label: `${child.name}/ (${describeFiles(child.files, child.tokens, child.included)})` });
+        }
+      }
+      if (folded.directories > 0) {
+        const label = `${folded.directories} ${folded.directories === 1 ? 'directory' : 'directories'}`;
+        rows.push({ label: `… ${label} (${describeFiles(folded.files, folded.tokens, folded.included)})` });
+      }
+      if (others.files > 0) {
+        rows.push({ label: `… ${others.files} other ${others.files === 1 ? 'file' : 'files'} (${TokenUtils.format(others.tokens)} tokens)` });
+      }
+
+      rows.forEach((row, index) => {
+        const last = index === rows.length - 1;
+        lines.push(`${prefix}${last ? '└── ' : '├── '}${row.label}`);
+        if (row.node) visit(row.node, `${prefix}${last ? '    ' : '│   '}`);
+      });
+    };
+    visit(root, '    ');
+    return { text: `${lines.join('\n')}\n`, listed };
+  }
+}
+
+/**
+ * @private
+ */
+function directoryNode(name, dirPath) {
+  return { name, path: dirPath, type: 'directory', files: 0, tokens: 0, included: 0, priority: -Infinity, children: new Map() };
+}
+
+/**
+ * "42 files, 12.3K tokens[, 3 included]"
+ * @private
+ */
+function describeFiles(files, tokens, included) {
+  return `${files} ${files === 1 ? 'file' : 'files'}, ${TokenUtils.format(tokens)} tokens${included > 0 ? `, ${included} included` : ''}`;
+}
+
+export default TreeCollapser;
@@ -0,0 +1,185 @@
+/**
+ * WorkerPool - Bounded worker_threads pool
+ * v3.4.0 - Parallel scanning
+ *
+ * Responsibilities:
+ * - Start up to `size` workers running one script
+ * - Queue tasks and hand each idle worker one task at a time
+ * - Return results in input order, whatever order workers finish in
+ * - Broadcast a message to every worker (e.g. to collect per-worker state)
+ */
+
+import os from 'os';
+import { Worker } from 'worker_threads';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('WorkerPool');
+
+/**
+ * Worker count for a --jobs value
+ * @param {string|number|null} value - Positive integer or "auto"
+ * @returns {number|null} Worker count, or null when invalid
+ */
+export function parseJobs(value) {
+  if (value === 'auto') {
+    return typeof os.availableParallelism === 'function' ? os.availableParallelism() : os.cpus().length;
+  }
+  const jobs = Number(value);
+  return Number.isInteger(jobs) && jobs > 0 ? jobs : null;
+}
+
+export class WorkerPool {
+  /**
+   * @param {string|URL} script - Worker module; replies { id, result } or { id, error }
+   * @param {Object} options
+   */
+  constructor(script, options = {}) {
+    this.script = script;
+    this.options = {
+      size: 1,
+      workerData: null,
+      ...options
+    };
+
+    this.workers = [];
+    this.idle = [];
+    this.queue = [];
+    this.pending = new Map();
+    this.nextId = 1;
+    this.closed = false;
+  }
+
+  /**
+   * @returns {number} Workers started so far
+   */
+  get size() {
+    return this.workers.length;
+  }
+
+  /**
+   * Run one task on the next idle worker
+   * @param {*} task - Structured-clonable message
+   * @returns {Promise<*>} The worker's result
+   */
+  run(task) {
+    if (this.closed) {
+      return Promise.reject(new Error('Worker pool is closed'));
+    }
+
+    return new Promise((resolve, reject) => {
+      this.queue.push({ task, resolve, reject });
+      this.dispatch();
+    });
+  }
+
+  /**
+   * Run tasks concurrently
+   * @param {Array<*>} tasks
+   * @returns {Promise<Array<*>>} Results in the order of tasks
+   */
+  map(tasks) {
+    return Promise.all(tasks.map(task => this.run(task)));
+  }
+
+  /**
+   * Send a message to every started worker once all queued tasks are done
+   * @param {*} message
+   * @returns {Promise<Array<*>>} One result per worker
+   */
+  async broadcast(message) {
+    await Promise.all([...this.pending.values()].map(({ promise }) => promise));
+    return Promise.all(this.workers.map(worker => this.send(worker, message)));
+  }
+
+  /**
+   * Terminate all workers
+   * @returns {Promise<void>}
+   */
+  async close() {
+    this.closed = true;
+    await Promise.all(this.workers.map(worker => worker.terminate()));
+    this.workers = [];
+    this.idle = [];
+  }
+
+  /**
+   * Assign queued tasks to idle workers, starting workers up to size
+   * @private
+   */
+  dispatch() {
+    while (this.queue.length > 0) {
+      if (this.idle.length === 0 && this.workers.length < this.options.size) {
+        this.idle.push(this.startWorker());
+      }
+      if (this.idle.length === 0) return;
+
+      const worker = this.idle.pop();
+      const { task, resolve, reject } = this.queue.shift();
+      this.send(worker, task).then(resolve, reject).finally(() => {
+        if (this.workers.includes(worker)) this.idle.push(worker);
+        this.dispatch();
+      });
+    }
+  }
+
+  /**
+   * @private
+   */
+  send(worker, message) {
+    const id = this.nextId++;
+    let settle;
+    const promise = new Promise((resolve, reject) => {
+      settle = { resolve, reject };
+    });
+
+    this.pending.set(id, { worker, promise: promise.catch(() => {}), ...settle });
+    worker.postMessage({ id, message });
+    return promise;
+  }
+
+  /**
+   * @private
+   */
+  startWorker() {
+    const worker = new Worker(this.script, { workerData: this.options.workerData });
+
+    worker.on('message', ({ id, result, error }) => {
+      const entry = this.pending.get(id);
+      if (!entry) return;
+      this.pending.delete(id);
+      if (error) entry.reject(new Error(error));
+      else entry.resolve(result);
+    });
+
+    // A crashed worker fails its in-flight task; the rest of the pool keeps going
+    worker.on('error', error => {
+      logger.error(`Worker failed: ${error.message}`);
+      this.failWorker(worker, error);
+    });
+    worker.on('exit', code => {
+      if (!this.closed && code !== 0) {
+        this.failWorker(worker, new Error(`Worker exited with code ${code}`));
+      }
+    });
+
+    this.workers.push(worker);
+    logger.debug(`Started worker ${this.workers.length}/${this.options.size}`);
+    return worker;
+  }
+
+  /**
+   * @private
+   */
+  failWorker(worker, error) {
+    for (const [id, entry] of this.pending) {
+      if (entry.worker === worker) {
+        this.pending.delete(id);
+        entry.reject(error);
+      }
+    }
+    this.workers = this.workers.filter(candidate => candidate !== worker);
+    this.idle = this.idle.filter(candidate => candidate !== worker);
+  }
+}
+
+export default WorkerPool;
@@ -0,0 +1,201 @@
+/**
+ * Workspace - One context spanning several repositories
+ * v3.4.0 - Multi-repo workspaces
+ *
+ * Responsibilities:
+ * - Load the repositories of a workspace from ctxman.workspace.json or go.work
+ * - Give each repository a path prefix, so its files keep unique paths in one bundle
+ * - Map prefixed paths to files on disk and back
+ *
+ * ctxman.workspace.json lists repositories relative to the file:
+ *   { "repos": [{ "path": ".", "prefix": "service" }, { "path": "../shared-lib", "prefix": "shared" }] }
+ * The prefix defaults to the directory name. Without a manifest, the `use`
+ * directives of go.work make the workspace; modules keep their directory as
+ * prefix, and modules inside another used module are scanned with it.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { goWorkUses } from '../languages/GoPackages.js';
+import { decodeText } from './FileSystem.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('Workspace');
+
+export const WORKSPACE_FILE = 'ctxman.workspace.json';
+
+export class Workspace {
+  /**
+   * @param {Array<{root: string, prefix: string}>} repos - Absolute roots; '/'-separated prefixes ('' for none)
+   * @param {string|null} filePath - Source manifest (for messages)
+   */
+  constructor(repos, filePath = null) {
+    this.filePath = filePath;
+    this.repos = repos.map(repo => ({ root: path.resolve(repo.root), prefix: repo.prefix }));
+
+    if (this.repos.length === 0) {
+      throw new Error(`${this.label()}: no repositories listed`);
+    }
+    for (const repo of this.repos) {
+      if (repo.prefix === '..' || repo.prefix.startsWith('../') || repo.prefix.startsWith('/') || /\\/.test(repo.prefix)) {
+        throw new Error(`${this.label()}: invalid prefix "${repo.prefix}"`);
+      }
+      const overlap = this.repos.find(other => other !== repo && other.prefix !== '' &&
+        (repo.prefix === other.prefix || repo.prefix.startsWith(`${other.prefix}/`)));
+      // An empty prefix leaves no paths for other repositories
+      if (overlap || (repo.prefix === '' && this.repos.length > 1)) {
+        throw new Error(`${this.label()}: prefix "${repo.prefix || '.'}" overlaps another repository`);
+      }
+    }
+  }
+
+  /**
+   * Workspace of a project: ctxman.workspace.json, else go.work
+   * @param {string} root - Project root
+   * @returns {Workspace|null} Null when the project declares none
+   * @throws {Error} When the manifest cannot be parsed or is invalid
+   */
+  static load(root) {
+    for (const file of [WORKSPACE_FILE, 'go.work']) {
+      const filePath = path.join(root, file);
+      if (fs.existsSync(filePath)) return Workspace.fromFile(filePath);
+    }
+    return null;
+  }
+
+  /**
+   * @param {string} filePath - A ctxman.workspace.json or go.work
+   * @returns {Workspace}
+   * @throws {Error} When the file is missing, unreadable or invalid
+   */
+  static fromFile(filePath) {
+    let text;
+    try {
+      text = decodeText(fs.readFileSync(filePath));
+    } catch (error) {
+      throw new Error(`Cannot read workspace ${filePath}: ${error.message}`);
+    }
+
+    const workspace = path.basename(filePath) === 'go.work'
+      ? Workspace.parseGoWork(text, filePath)
+      : Workspace.parse(text, filePath);
+    logger.debug(`Loaded ${workspace.repos.length} repositories from ${filePath}`);
+    return workspace;
+  }
+
+  /**
+   * @param {string} text - ctxman.workspace.json content
+   * @param {string} filePath - Repository paths are relative to its directory
+   * @returns {Workspace}
+   */
+  static parse(text, filePath = WORKSPACE_FILE) {
+    const label = path.basename(filePath);
+    let data;
+    try {
+      data = JSON.parse(text);
+    } catch (error) {
+      throw new Error(`${label}: ${error.message}`);
+    }
+    if (!data || !Array.isArray(data.repos)) {
+      throw new Error(`${label}: expected a "repos" list`);
+    }
+
+    const base = path.dirname(path.resolve(filePath));
+    const repos = data.repos.map((entry, index) => {
+      const spec = typeof entry === 'string' ? { path: entry } : entry;
+      if (!spec || typeof spec.path !== 'string') {
+        throw new Error(`${label}: repos[${index}] needs a "path"`);
+      }
+      const root = path.resolve(base, spec.path);
+      if (!fs.existsSync(root) || !fs.statSync(root).isDirectory()) {
+        throw new Error(`${label}: repository ${spec.path} is not a directory`);
+      }
+      const prefix = spec.prefix ?? path.basename(root);
+      if (typeof prefix !== 'string') {
+        throw new Error(`${label}: repos[${index}].prefix must be a string`);
+      }
+      return { root, prefix: normalizePrefix(prefix) };
+    });
+    return new Workspace(repos, filePath);
+  }
+
+  /**
+   * @param {string} text - go.work content
+   * @param {string} filePath - Module paths are relative to its directory
+   * @returns {Workspace}
+   */
+  static parseGoWork(text, filePath = 'go.work') {
+    const base = path.dirname(path.resolve(filePath));
+    const uses = goWorkUses(text)
+      .map(dir => path.resolve(base, dir))
+      .filter(root => fs.existsSync(path.join(root, 'go.mod')));
+
+    // A module inside another used module is part of that module's scan
+    const roots = [...new Set(uses)].filter(root => !uses.some(other => other !== root && isInside(root, other)));
+    const repos = roots.map(root => {
+      const relative = path.relative(base, root).split(path.sep).join('/');
+      return { root, prefix: relative.startsWith('..') ? path.basename(root) : relative };
+    });
+    return new Workspace(repos, filePath);
+  }
+
+  /**
+   * @private
+   */
+  label() {
+    return this.filePath ? path.basename(this.filePath) : 'workspace';
+  }
+
+  /**
+   * Identity of the repository layout (cache keys)
+   * @returns {string}
+   */
+  getKey() {
+    return JSON.stringify(this.repos.map(repo => [repo.root, repo.prefix]));
+  }
+
+  /**
+   * Workspace path of a file on disk
+   * @param {string} filePath - Absolute path
+   * @returns {string|null} path.sep-separated path under the repository prefix, or null outside the repositories
+   */
+  relativePath(filePath) {
+    const repo = this.repos
+      .filter(candidate => isInside(filePath, candidate.root))
+      .sort((a, b) => b.root.length - a.root.length)[0];
+    if (!repo) return null;
+    return path.join(repo.prefix.split('/').join(path.sep), path.relative(repo.root, filePath)) || '.';
+  }
+
+  /**
+   * File on disk of a workspace path
+   * @param {string} relativePath - '/' or path.sep-separated workspace path
+   * @returns {string|null} Absolute path, or null when no repository prefix matches
+   */
+  absolutePath(relativePath) {
+    const posix = relativePath.split(path.sep).join('/');
+    const repo = this.repos
+      .filter(candidate => candidate.prefix === '' || posix === candidate.prefix || posix.startsWith(`${candidate.prefix}/`))
+      .sort((a, b) => b.prefix.length - a.prefix.length)[0];
+    if (!repo) return null;
+    return path.join(repo.root, repo.prefix ? posix.slice(repo.prefix.length + 1) : posix);
+  }
+}
+
+/**
+ * @private
+ */
+function normalizePrefix(prefix) {
+  const normalized = path.posix.normalize(prefix.replace(/\\/g, '/')).replace(/\/$/, '');
+  return normalized === '.' ? '' : normalized;
+}
+
+/**
+ * @private
+ */
+function isInside(filePath, dir) {
+  const relative = path.relative(dir, filePath);
+  return relative === '' || (!relative.startsWith('..') && !path.isAbsolute(relative));
+}
+
+export default Workspace;
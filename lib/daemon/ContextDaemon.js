@@ -0,0 +1,241 @@
+/**
+ * ContextDaemon - Long-running context server on a local socket
+ * v3.4.0 - Persistent daemon with warm index
+ *
+ * Responsibilities:
+ * - Listen on a unix socket (a named pipe on Windows) derived from the project root
+ * - Run one request at a time, in this process, so the WarmIndex stays warm
+ * - Stream a request's stdout and stderr back to the client and end it with an exit code
+ * - Turn process.exit() calls inside a request into that exit code
+ * - Answer status and stop commands; remove stale sockets left by a crashed daemon
+ *
+ * Protocol: newline-delimited JSON. The client sends one message,
+ * { args } or { command: 'status' | 'stop' }; the daemon answers with
+ * { stream: 'stdout' | 'stderr', data } messages followed by { exit },
+ * { local: true } (the client should run the command itself) or { status }.
+ */
+
+import fs from 'fs';
+import net from 'net';
+import { format } from 'util';
+import { socketPathFor } from './DaemonClient.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ContextDaemon');
+
+const CONSOLE_STREAMS = { log: 'stdout', info: 'stdout', debug: 'stdout', warn: 'stderr', error: 'stderr' };
+
+/**
+ * Thrown in place of process.exit() while a request runs
+ */
+class DaemonExit extends Error {
+  constructor(code) {
+    super(`exit ${code}`);
+    this.code = code;
+  }
+}
+
+export class ContextDaemon {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      root: process.cwd(),
+      socketPath: null, // Default: socketPathFor(root)
+      handler: null, // async (args) => false to have the client run args itself
+      status: null, // () => extra status fields (e.g. WarmIndex stats)
+      onRequest: null, // ({ args, exit, durationMs }) after each request, e.g. for metrics
+      ...options
+    };
+
+    this.socketPath = this.options.socketPath || socketPathFor(this.options.root);
+    this.server = null;
+    this.queue = Promise.resolve();
+    this.startedAt = null;
+    this.requests = 0;
+  }
+
+  /**
+   * Listen on the socket
+   * @returns {Promise<ContextDaemon>}
+   * @throws {Error} When a daemon already serves this root
+   */
+  async start() {
+    await this.removeStaleSocket();
+
+    this.server = net.createServer(socket => this.accept(socket));
+    await new Promise((resolve, reject) => {
+      this.server.once('error', reject);
+      this.server.listen(this.socketPath, () => {
+        this.server.off('error', reject);
+        resolve();
+      });
+    });
+
+    this.startedAt = Date.now();
+    logger.debug(`Daemon for ${this.options.root} listening on ${this.socketPath}`);
+    return this;
+  }
+
+  /**
+   * @private
+   */
+  async removeStaleSocket() {
+    if (process.platform === 'win32' || !fs.existsSync(this.socketPath)) return;
+
+    const alive = await new Promise(resolve => {
+      const probe = net.connect(this.socketPath, () => {
+        probe.destroy();
+        resolve(true);
+      });
+      probe.once('error', () => resolve(false));
+    });
+    if (alive) {
+      throw new Error(`A ctxman daemon already serves ${this.options.root} (${this.socketPath})`);
+    }
+    fs.unlinkSync(this.socketPath);
+  }
+
+  /**
+   * Read one message from a client and answer it
+   * @private
+   */
+  accept(socket) {
+    let buffer = '';
+    socket.setEncoding('utf8');
+    socket.on('error', error => logger.debug(`Client connection failed: ${error.message}`));
+    socket.on('data', chunk => {
+      buffer += chunk;
+      const end = buffer.indexOf('\n');
+      if (end === -1) return;
+
+      socket.removeAllListeners('data');
+      let message;
+      try {
+        message = JSON.parse(buffer.slice(0, end));
+      } catch (error) {
+        socket.end(JSON.stringify({ stream: 'stderr', data: `❌ Invalid daemon request: ${error.message}\n` }) + '\n' +
+          JSON.stringify({ exit: 2 }) + '\n');
+        return;
+      }
+      this.queue = this.queue.then(() => this.answer(message, socket));
+    });
+  }
+
+  /**
+   * @private
+   */
+  async answer(message, socket) {
+    const send = reply => {
+      if (!socket.destroyed) socket.write(JSON.stringify(reply) + '\n');
+    };
+
+    if (message.command === 'status') {
+      send({ status: this.getStatus() });
+      socket.end();
+      return;
+    }
+    if (message.command === 'stop') {
+      send({ exit: 0 });
+      socket.end();
+      await this.stop();
+      return;
+    }
+
+    this.requests++;
+    const started = Date.now();
+    const exit = await this.run(message.args || [], send);
+    logger.debug(`Request ${(message.args || []).join(' ')} finished in ${Date.now() - started}ms (exit ${exit})`);
+    if (this.options.onRequest) {
+      this.options.onRequest({ args: message.args || [], exit, durationMs: Date.now() - started });
+    }
+    send(exit === null ? { local: true } : { exit });
+    socket.end();
+  }
+
+  /**
+   * Run the handler with output and exits redirected
+   * @param {Array<string>} args
+   * @param {function(Object): void} send - Receives { stream, data } messages
+   * @returns {Promise<number|null>} Exit code, or null when the client should run args itself
+   */
+  async run(args, send) {
+    const saved = {
+      console: { ...console },
+      stdoutWrite: process.stdout.write,
+      stderrWrite: process.stderr.write,
+      exit: process.exit,
+      exitCode: process.exitCode
+    };
+    const redirect = stream => (chunk, encoding, callback) => {
+      const data = typeof chunk === 'string' ? chunk : Buffer.from(chunk).toString(typeof encoding === 'string' ? encoding : 'utf8');
+      send({ stream, data });
+      const done = typeof encoding === 'function' ? encoding : callback;
+      if (done) process.nextTick(done);
+      return true;
+    };
+
+    process.stdout.write = redirect('stdout');
+    process.stderr.write = redirect('stderr');
+    // console may not write through process.stdout (test runners replace it)
+    for (const [method, stream] of Object.entries(CONSOLE_STREAMS)) {
+      console[method] = (...messages) => send({ stream, data: `${format(...messages)}\n` });
+    }
+    process.exit = code => {
+      throw new DaemonExit(code ?? process.exitCode ?? 0);
+    };
+    process.exitCode = undefined;
+
+    try {
+      const handled = await this.options.handler(args);
+      return handled === false ? null : (process.exitCode ?? 0);
+    } catch (error) {
+      if (error instanceof DaemonExit) return error.code;
+      console.error(`❌ Error: ${error.message}`);
+      return 1;
+    } finally {
+      process.stdout.write = saved.stdoutWrite;
+      process.stderr.write = saved.stderrWrite;
+      process.exit = saved.exit;
+      process.exitCode = saved.exitCode;
+      Object.assign(console, saved.console);
+    }
+  }
+
+  /**
+   * @returns {Object} pid, socket, uptime and request count
+   */
+  getStatus() {
+    return {
+      pid: process.pid,
+      root: this.options.root,
+      socket: this.socketPath,
+      uptimeMs: Date.now() - this.startedAt,
+      requests: this.requests,
+      ...(this.options.status ? this.options.status() : {})
+    };
+  }
+
+  /**
+   * Stop listening and remove the socket
+   * @returns {Promise<void>}
+   */
+  async stop() {
+    if (!this.server) return;
+    const server = this.server;
+    this.server = null;
+    await new Promise(resolve => server.close(() => resolve()));
+  }
+
+  /**
+   * Resolves when the daemon stops
+   * @returns {Promise<void>}
+   */
+  closed() {
+    if (!this.server) return Promise.resolve();
+    return new Promise(resolve => this.server.once('close', resolve));
+  }
+}
+
+export default ContextDaemon;
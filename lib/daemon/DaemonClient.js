@@ -0,0 +1,115 @@
+/**
+ * DaemonClient - Thin client of a ContextDaemon
+ * v3.4.0 - Persistent daemon with warm index
+ *
+ * Responsibilities:
+ * - Derive the socket of the daemon serving a project root
+ * - Send one request to that daemon
+ * - Copy the streamed output to this process's stdout and stderr
+ * - Report when no daemon is listening, so callers can run the command themselves
+ *
+ * Only Node built-ins are imported here: the client's startup time is the
+ * point of the daemon.
+ */
+
+import net from 'net';
+import os from 'os';
+import path from 'path';
+import crypto from 'crypto';
+
+/**
+ * Socket of the daemon serving a project root
+ * @param {string} root
+ * @returns {string}
+ */
+export function socketPathFor(root) {
+  const id = crypto.createHash('sha1').update(path.resolve(root)).digest('hex').slice(0, 12);
+  return process.platform === 'win32'
+    ? `\\\\.\\pipe\\ctxman-${id}`
+    : path.join(os.tmpdir(), `ctxman-${id}.sock`);
+}
+
+export class DaemonClient {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      root: process.cwd(),
+      socketPath: null, // Default: socketPathFor(root)
+      stdout: process.stdout,
+      stderr: process.stderr,
+      ...options
+    };
+
+    this.socketPath = this.options.socketPath || socketPathFor(this.options.root);
+  }
+
+  /**
+   * Send a message and collect the daemon's final reply
+   * @private
+   * @returns {Promise<Object|null>} Final message, or null when no daemon is listening
+   */
+  request(message) {
+    return new Promise((resolve, reject) => {
+      const socket = net.connect(this.socketPath, () => socket.write(JSON.stringify(message) + '\n'));
+      let buffer = '';
+      let final = null;
+
+      socket.setEncoding('utf8');
+      socket.on('data', chunk => {
+        // Only the new chunk can end a message; large outputs span many chunks
+        let from = buffer.length;
+        buffer += chunk;
+        let end;
+        while ((end = buffer.indexOf('\n', from)) !== -1) {
+          const reply = JSON.parse(buffer.slice(0, end));
+          buffer = buffer.slice(end + 1);
+          from = 0;
+          if (reply.stream) {
+            this.options[reply.stream].write(reply.data);
+          } else {
+            final = reply;
+          }
+        }
+      });
+      socket.once('error', error => {
+        if (['ENOENT', 'ECONNREFUSED'].includes(error.code)) resolve(null);
+        else reject(new Error(`ctxman daemon at ${this.socketPath} failed: ${error.message}`));
+      });
+      socket.once('close', hadError => {
+        if (hadError) return;
+        if (final) resolve(final);
+        else reject(new Error(`ctxman daemon at ${this.socketPath} closed the connection`));
+      });
+    });
+  }
+
+  /**
+   * Run ctxman arguments in the daemon
+   * @param {Array<string>} args
+   * @returns {Promise<number|null>} Exit code, or null when there is no daemon
+   *   or the command has to run outside it
+   */
+  async run(args) {
+    const reply = await this.request({ args });
+    return reply && !reply.local ? reply.exit : null;
+  }
+
+  /**
+   * @returns {Promise<Object|null>} Daemon status, or null when none is running
+   */
+  async status() {
+    const reply = await this.request({ command: 'status' });
+    return reply ? reply.status : null;
+  }
+
+  /**
+   * @returns {Promise<boolean>} Whether a daemon was stopped
+   */
+  async stop() {
+    return (await this.request({ command: 'stop' })) !== null;
+  }
+}
+
+export default DaemonClient;
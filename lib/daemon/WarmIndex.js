@@ -0,0 +1,187 @@
+/**
+ * WarmIndex - In-memory project index kept by the daemon
+ * v3.4.0 - Persistent daemon with warm index
+ *
+ * Responsibilities:
+ * - Remember directory scans and reuse them while no scanned directory or
+ *   ignore file changed (directory mtimes change when entries are added,
+ *   removed or renamed)
+ * - Remember per-file analyses and reuse them while mtime and size match
+ * - Reuse the dependency graph while its files are unchanged
+ * - Hold a memory ContentCache and initialized extractors across requests;
+ *   its symbol outlines live in a bounded ParseCache (v3.4.0)
+ */
+
+import fs from 'fs';
+import ContentCache from '../cache/ContentCache.js';
+import ParseCache from '../cache/ParseCache.js';
+import ConfigUtils from '../utils/config-utils.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('WarmIndex');
+
+/**
+ * Change stamp of a path ('missing' when it does not exist)
+ * @param {string} filePath
+ * @returns {string}
+ */
+function stampOf(filePath) {
+  try {
+    const stat = fs.statSync(filePath);
+    return `${stat.mtimeMs}:${stat.size}`;
+  } catch {
+    return 'missing';
+  }
+}
+
+export class WarmIndex {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      root: process.cwd(),
+      parseCache: null, // ParseCache of the outlines kept between requests; default size when not given
+      ...options
+    };
+
+    this.scans = new Map();
+    this.files = new Map();
+    this.graphs = new Map();
+    this.memos = new Map();
+    this.parseCache = this.options.parseCache || new ParseCache();
+    this.cache = new ContentCache({ root: this.options.root, path: null, parseCache: this.parseCache });
+    // Bumped whenever a scan or file changes; graphs built at an older version are stale
+    this.version = 0;
+    this.stats = {
+      scans: 0,
+      scanHits: 0,
+      analyses: 0,
+      analysisHits: 0,
+      graphs: 0,
+      graphHits: 0
+    };
+  }
+
+  /**
+   * Ignore files that decide what a scan includes
+   * @private
+   * @returns {Array<string>}
+   */
+  ignoreFiles() {
+    const { gitignore, contextIgnore, contextInclude } = ConfigUtils.getConfigPaths(this.options.root);
+    return [gitignore, contextIgnore, contextInclude].filter(Boolean);
+  }
+
+  /**
+   * Files of a directory scan, rescanning only when the tree changed
+   * @param {string} key - Scan settings (e.g. profile include/exclude rules)
+   * @param {function(Array<string>): {files: Array<string>, stats: Object}} compute -
+   *   Scans the project, pushing every visited directory onto the array
+   * @returns {{files: Array<string>, stats: Object}}
+   */
+  scan(key, compute) {
+    const cached = this.scans.get(key);
+    if (cached && cached.stamps.every(([filePath, stamp]) => stampOf(filePath) === stamp)) {
+      this.stats.scanHits++;
+      return cached.result;
+    }
+
+    const directories = [];
+    const result = compute(directories);
+    const watched = [...new Set([...directories, ...this.ignoreFiles()])];
+    this.scans.set(key, { result, stamps: watched.map(filePath => [filePath, stampOf(filePath)]) });
+
+    this.stats.scans++;
+    this.version++;
+    this.prune();
+    logger.debug(`Scanned ${result.files.length} files in ${directories.length} directories`);
+    return result;
+  }
+
+  /**
+   * Analysis of a file, recomputed only when the file changed
+   * Callers get a copy, so selection can annotate it freely.
+   * @param {string} filePath - Absolute path
+   * @param {string|null} key - Analysis settings (e.g. tokenizer); null never reuses
+   * @param {function(): Object} compute
+   * @returns {Object}
+   */
+  analysis(filePath, key, compute) {
+    const stamp = stampOf(filePath);
+    const cached = this.files.get(filePath);
+    if (!cached || cached.stamp !== stamp) {
+      this.version++;
+    } else if (key !== null && cached.analyses.has(key)) {
+      this.stats.analysisHits++;
+      return { ...cached.analyses.get(key) };
+    }
+
+    const entry = cached && cached.stamp === stamp ? cached : { stamp, analyses: new Map() };
+    const fileInfo = compute();
+    if (key !== null) entry.analyses.set(key, fileInfo);
+    this.files.set(filePath, entry);
+
+    this.stats.analyses++;
+    return { ...fileInfo };
+  }
+
+  /**
+   * Dependency graph over a set of files, rebuilt only when one of them changed
+   * @param {string} key - Graph settings (e.g. symbol backend)
+   * @param {Array<{relativePath: string}>} files
+   * @param {function(): Object} build
+   * @returns {Object} Whatever build() returns
+   */
+  graph(key, files, build) {
+    const paths = files.map(fileInfo => fileInfo.relativePath).join('\n');
+    const cached = this.graphs.get(key);
+    if (cached && cached.version === this.version && cached.paths === paths) {
+      this.stats.graphHits++;
+      return cached.graph;
+    }
+
+    const graph = build();
+    this.graphs.set(key, { version: this.version, paths, graph });
+    this.stats.graphs++;
+    return graph;
+  }
+
+  /**
+   * Value computed once per daemon, such as an initialized SymbolExtractor
+   * @param {string} key
+   * @param {function(): *} compute
+   * @returns {*}
+   */
+  memo(key, compute) {
+    if (!this.memos.has(key)) {
+      this.memos.set(key, compute());
+    }
+    return this.memos.get(key);
+  }
+
+  /**
+   * Forget analyses of files that no longer exist
+   * @private
+   */
+  prune() {
+    for (const filePath of this.files.keys()) {
+      if (!fs.existsSync(filePath)) this.files.delete(filePath);
+    }
+  }
+
+  /**
+   * @returns {Object} Index size and hit counts, and the ParseCache's stats as parseCache
+   */
+  getStats() {
+    return {
+      root: this.options.root,
+      files: this.files.size,
+      version: this.version,
+      ...this.stats,
+      parseCache: this.parseCache.getStats()
+    };
+  }
+}
+
+export default WarmIndex;
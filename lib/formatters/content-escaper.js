@@ -0,0 +1,155 @@
+/**
+ * Content Escaper (v3.4.0)
+ * Fences and escapes file contents so that no content can end its block early:
+ * - Markdown code fences one character longer than any run of the fence
+ *   character in the content, backticks or tildes (--fence); the runs are
+ *   counted anywhere in a line, so content that a chat UI reflows still
+ *   cannot close the fence. Inline code spans grow the same way.
+ * - XML element contents in three modes (--xml-content): raw keeps code as
+ *   written and escapes only the closing tags of the enclosing elements; cdata
+ *   wraps contents in CDATA sections, splitting any ]]>; entities escapes &, <
+ *   and >. cdata and entities give well-formed XML.
+ * - Characters XML 1.0 cannot hold (control characters other than tab, line
+ *   feed and carriage return, lone surrogates, U+FFFE and U+FFFF) become U+FFFD
+ *   in the well-formed modes and in escaped text.
+ */
+
+export const FENCE_CHARS = { backtick: '`', tilde: '~' };
+
+export const XML_CONTENT_MODES = ['raw', 'cdata', 'entities'];
+
+// Shortest fence CommonMark accepts
+const MIN_FENCE = 3;
+
+const INVALID_XML_CHARS = /[\u0000-\u0008\u000B\u000C\u000E-\u001F\uFFFE\uFFFF]|[\uD800-\uDBFF](?![\uDC00-\uDFFF])|(?<![\uD800-\uDBFF])[\uDC00-\uDFFF]/g;
+
+/**
+ * Parse a --fence value
+ * @param {string} value - backtick or tilde
+ * @returns {string}
+ * @throws {Error} For other values
+ */
+export function parseFenceChar(value) {
+    if (!Object.hasOwn(FENCE_CHARS, value)) {
+        throw new Error(`Invalid fence: ${value || '(missing)'} (expected ${Object.keys(FENCE_CHARS).join(' or ')})`);
+    }
+    return value;
+}
+
+/**
+ * Parse an --xml-content value
+ * @param {string} value - raw, cdata or entities
+ * @returns {string}
+ * @throws {Error} For other values
+ */
+export function parseXmlContentMode(value) {
+    if (!XML_CONTENT_MODES.includes(value)) {
+        throw new Error(`Invalid XML content mode: ${value || '(missing)'} (expected ${XML_CONTENT_MODES.join(', ')})`);
+    }
+    return value;
+}
+
+/**
+ * Fenced code block
+ * @param {string} content - Without the final line break, which is dropped
+ * @param {string} [language] - Info string; characters a fence cannot carry are dropped
+ * @param {Object} [options]
+ * @param {string} [options.char] - backtick or tilde (FENCE_CHARS)
+ * @returns {string}
+ */
+export function fence(content, language = '', { char = 'backtick' } = {}) {
+    const marker = FENCE_CHARS[parseFenceChar(char)];
+    const delimiter = marker.repeat(Math.max(MIN_FENCE - 1, longestRun(content, marker)) + 1);
+    // Backtick fences cannot have backticks in their info string, and no fence a line break
+    const info = String(language || '').replace(/[`~\s]+/g, '');
+    return `${delimiter}${info}\n${content.replace(/\r?\n$/, '')}\n${delimiter}`;
+}
+
+/**
+ * Inline code span; line breaks become spaces, so a heading stays one line
+ * @param {string} text
+ * @returns {string}
+ */
+export function inlineCode(text) {
+    const value = String(text).replace(/\r?\n|\r/g, ' ');
+    const delimiter = '`'.repeat(longestRun(value, '`') + 1);
+    // A space on both sides keeps edge backticks apart from the delimiter; CommonMark strips it
+    const pad = /^`|`$/.test(value) || /^ .*[^ ].* $/.test(value) ? ' ' : '';
+    return `${delimiter}${pad}${value}${pad}${delimiter}`;
+}
+
+/**
+ * Content of an XML element
+ * @param {string} content
+ * @param {Object} [options]
+ * @param {string} [options.mode] - raw, cdata or entities (XML_CONTENT_MODES)
+ * @param {Array<string>} [options.tags] - Enclosing elements, whose closing tags raw mode escapes
+ * @returns {string}
+ */
+export function xmlContent(content, { mode = 'raw', tags = [] } = {}) {
+    switch (parseXmlContentMode(mode)) {
+        case 'cdata':
+            return cdata(content);
+        case 'entities':
+            return sanitizeXml(content).replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+        default: {
+            if (tags.length === 0) return content;
+            // End tags may have whitespace before the >
+            const closing = new RegExp(`</(${tags.map(escapeRegex).join('|')})(\\s*)>`, 'g');
+            return content.replace(closing, (match, tag, space) => `&lt;/${tag}${space}&gt;`);
+        }
+    }
+}
+
+/**
+ * CDATA section; ]]> is split across two sections, so it reads back unchanged
+ * @param {string} content
+ * @returns {string}
+ */
+export function cdata(content) {
+    return `<![CDATA[${sanitizeXml(content).split(']]>').join(']]]]><![CDATA[>')}]]>`;
+}
+
+/**
+ * Escaped text or attribute value
+ * @param {string} text
+ * @returns {string}
+ */
+export function escapeXml(text) {
+    return sanitizeXml(text)
+        .replace(/&/g, '&amp;')
+        .replace(/</g, '&lt;')
+        .replace(/>/g, '&gt;')
+        .replace(/"/g, '&quot;');
+}
+
+/**
+ * Replace the characters XML 1.0 cannot hold with U+FFFD
+ * @param {string} text
+ * @returns {string}
+ */
+export function sanitizeXml(text) {
+    return text.replace(INVALID_XML_CHARS, '\uFFFD');
+}
+
+/**
+ * Longest run of a character; counted in one pass, since contents can hold
+ * more runs than a spread argument list takes
+ * @private
+ */
+function longestRun(text, char) {
+    let longest = 0;
+    let current = 0;
+    for (let i = 0; i < text.length; i++) {
+        current = text[i] === char ? current + 1 : 0;
+        if (current > longest) longest = current;
+    }
+    return longest;
+}
+
+/**
+ * @private
+ */
+function escapeRegex(text) {
+    return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
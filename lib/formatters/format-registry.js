@@ -6,6 +6,8 @@
 
 import ToonFormatter from './toon-formatter.js';
 import GitIngestFormatter from './gitingest-formatter.js';
+import MarkdownFormatter from './markdown-formatter.js';
+import XmlFormatter from './xml-formatter.js';
 
 class FormatRegistry {
     constructor() {
@@ -62,7 +64,7 @@ class FormatRegistry {
             description: 'Markdown documentation format',
             extension: '.md',
             mimeType: 'text/markdown',
-            encoder: (data) => this.encodeMarkdown(data)
+            encoder: (data, options) => this.encodeMarkdown(data, options)
         });
 
         // CSV format
@@ -80,7 +82,7 @@ class FormatRegistry {
             description: 'Extensible Markup Language (enterprise compatible)',
             extension: '.xml',
             mimeType: 'application/xml',
-            encoder: (data) => this.encodeXML(data)
+            encoder: (data, options) => this.encodeXML(data, 0, options)
         });
 
         // GitIngest format
@@ -188,9 +190,16 @@ class FormatRegistry {
                 return String(data);
 
             case 'string':
-                // Quote if contains special characters
-                if (/[:\n\r{}[\],&*#?|<>=!%@`]/.test(data)) {
-                    return `"${data.replace(/"/g, '\\"')}"`;
+                // Multi-line text (file contents) as a literal block
+                if (this.isYAMLBlock(data)) {
+                    const blockLines = data.replace(/\n$/, '').split('\n');
+                    const chomp = data.endsWith('\n') ? '' : '-';
+                    return `|${chomp}\n` + blockLines.map(line => (line ? prefix + line : '')).join('\n');
+                }
+                // Quote if contains special characters or reads as another type
+                if (/[:\n\r\t{}[\],&*#?|<>=!%@`"'\\]/.test(data) || /^\s|\s$|^-/.test(data) || data === '' ||
+                    /^(true|false|yes|no|on|off|null|~)$/i.test(data) || !isNaN(Number(data))) {
+                    return JSON.stringify(data);
                 }
                 return data;
 
@@ -207,7 +216,8 @@ class FormatRegistry {
                 if (keys.length === 0) return '{}';
                 let objResult = '\n';
                 for (const key of keys) {
-                    objResult += prefix + key + ': ' + this.encodeYAML(data[key], indent + 1) + '\n';
+                    const safeKey = /^[\w./$][\w./$-]*$/.test(key) ? key : JSON.stringify(key);
+                    objResult += prefix + safeKey + ': ' + this.encodeYAML(data[key], indent + 1) + '\n';
                 }
                 return objResult.trimEnd();
 
@@ -216,10 +226,25 @@ class FormatRegistry {
         }
     }
 
+    /**
+     * Whether a string can be written as a literal block (|)
+     * Leading indentation, carriage returns and extra trailing newlines
+     * cannot round-trip, so those stay double-quoted.
+     */
+    isYAMLBlock(text) {
+        return text.includes('\n') && !/^[ \t]/.test(text) && !text.includes('\r') && !text.endsWith('\n\n');
+    }
+
     /**
      * Markdown encoder
+     * Structured contexts (ctxman.context/v1) render with a table of contents and
+     * fenced file contents (v3.4.0); options.collapsible wraps them in <details>.
      */
-    encodeMarkdown(data) {
+    encodeMarkdown(data, options = {}) {
+        if (Array.isArray(data?.files)) {
+            return new MarkdownFormatter(data, options).render();
+        }
+
         const { project, paths, methods, methodStats } = data;
 
         let md = `# ${project?.root || 'Project'} - Context Analysis\n\n`;
@@ -314,10 +339,15 @@ class FormatRegistry {
     /**
      * XML encoder
      */
-    encodeXML(data, indent = 0) {
+    encodeXML(data, indent = 0, options = {}) {
         const prefix = '  '.repeat(indent);
         const type = this.getType(data);
 
+        // Structured contexts (ctxman.context/v1) use the document layout (v3.4.0)
+        if (indent === 0 && Array.isArray(data?.files)) {
+            return new XmlFormatter(data, { tags: options.xmlTags, content: options.xmlContent }).render();
+        }
+
         if (indent === 0) {
             let xml = '<?xml version="1.0" encoding="UTF-8"?>\n';
             xml += '<context>\n';
@@ -1,10 +1,18 @@
-import fs from 'fs';
 import path from 'path';
 import MethodAnalyzer from '../analyzers/method-analyzer.js';
 import MethodFilterParser from '../parsers/method-filter-parser.js';
 import ConfigUtils from '../utils/config-utils.js';
 import TokenUtils from '../utils/token-utils.js';
 import FileUtils from '../utils/file-utils.js';
+import FileSplitter from '../core/FileSplitter.js';
+import DuplicateDetector from '../core/DuplicateDetector.js';
+import ProjectDocs from '../core/ProjectDocs.js';
+import CodeOwners from '../core/CodeOwners.js';
+import ContextSession from '../core/ContextSession.js';
+import ApiDiff from '../symbols/ApiDiff.js';
+import ContextWriter, { writeFileAtomic } from '../core/ContextWriter.js';
+import { nativeFileSystem } from '../core/FileSystem.js';
+import { compareBytes, comparePaths, sectionId } from '../utils/ordering.js';
 
 /**
  * GitIngest-style Digest Formatter
@@ -16,6 +24,22 @@ import FileUtils from '../utils/file-utils.js';
  * - Multiple chunking strategies (smart, size, file, directory, dependency)
  * - Cross-chunk reference preservation
  * - Chunk metadata and navigation
+ * - Oversized files split along symbol boundaries (v3.4.0)
+ * - Secrets replaced with placeholders (v3.4.0, options.redactor)
+ * - Collapsed near-duplicates named in file headers (v3.4.0, --dedupe)
+ * - Project docs first, and the docs of each file in its header (v3.4.0, --docs)
+ * - Digests streamed file by file instead of built in memory (v3.4.0)
+ * - Cross-reference index of the included symbols after the files (v3.4.0, --xref)
+ * - TODO, FIXME and HACK comments with their lines after the files (v3.4.0, --todos)
+ * - Dependencies of the project's manifests after the files (v3.4.0, --deps)
+ * - One licensing note after the files instead of LICENSE files and headers (v3.4.0, --licenses)
+ * - Old and new signatures of changed APIs in the summary (v3.4.0, api-diff)
+ * - Sections in a custom order, with token caps and separators (v3.4.0, --layout)
+ * - Files that cannot be read passed to options.onError (v3.4.0, error report)
+ * - Stable numeric section IDs in file headers (v3.4.0, --section-ids)
+ * - Overview of a monorepo's projects after the summary (v3.4.0, --per-project)
+ * - Pasted snippet and what it resolved to after the summary (v3.4.0, enrich)
+ * - Directory structure laid out within a token budget (v3.4.0, --tree-budget)
  */
 class GitIngestFormatter {
     constructor(projectRoot, stats, analysisResults, options = {}) {
@@ -32,7 +56,8 @@ class GitIngestFormatter {
             overlap: options.chunking?.overlap || 500,
             preserveContext: options.chunking?.preserveContext !== false,
             includeMetadata: options.chunking?.includeMetadata !== false, // v2.3.3
-            crossReferences: options.chunking?.crossReferences !== false // v2.3.3
+            crossReferences: options.chunking?.crossReferences !== false, // v2.3.3
+            splitFiles: options.chunking?.splitFiles !== false // v3.4.0
         };
 
         // Auto-detect method filtering configuration
@@ -51,26 +76,82 @@ class GitIngestFormatter {
         return ConfigUtils.initMethodFilter(this.projectRoot);
     }
 
+    /**
+     * File content as emitted, with secrets replaced when redaction is on (v3.4.0)
+     */
+    readFile(fileInfo) {
+        const content = this.options.readFile ? this.options.readFile(fileInfo.path) : nativeFileSystem.readText(fileInfo.path);
+        return this.options.redactor ? this.options.redactor.redact(content, fileInfo.relativePath) : content;
+    }
+
     generateDigest() {
         // Check if chunking is enabled
         if (this.chunking.enabled) {
             return this.generateChunkedDigest();
         }
 
-        let digest = '';
+        return [...this.generatePieces()].join('');
+    }
+
+    /**
+     * Digest piece by piece: header, tree, one piece per file, then the
+     * cross-reference index and markers when given (v3.4.0)
+     * Only the file being emitted is held in memory. Chunks are separated
+     * by a blank line.
+     */
+    *generatePieces() {
+        if (this.chunking.enabled) {
+            const chunks = this.generateChunkedDigest();
+            for (let i = 0; i < chunks.length; i++) {
+                yield i > 0 ? `\n${chunks[i].content}` : chunks[i].content;
+            }
+            return;
+        }
+
+        // Sections in the order and sizes of a layout (ContextLayout)
+        if (this.options.layout) {
+            yield* this.generateLayoutPieces(this.options.layout);
+            return;
+        }
 
         // Header with project info
-        digest += this.generateSummary();
-        digest += '\n';
+        yield this.generateSummary() + '\n';
+
+        // Snippet the context was enriched for (v3.4.0, ctxman enrich)
+        if (this.options.snippet) {
+            yield '\n' + this.options.snippet + '\n';
+        }
+
+        // Projects of a monorepo, in a per-project bundle (v3.4.0, --per-project)
+        if (this.options.projects) {
+            yield '\n' + this.options.projects + '\n';
+        }
 
         // Directory tree structure
-        digest += this.generateTree();
-        digest += '\n';
+        yield this.generateTree() + '\n';
 
         // File contents
-        digest += this.generateFileContents();
+        yield* this.generateFileEntries();
 
-        return digest;
+        // Where included symbols are defined and referenced (CrossReferenceIndex)
+        if (this.options.crossReferences) {
+            yield this.options.crossReferences;
+        }
+
+        // Known-issue markers (TodoHarvester)
+        if (this.options.todos) {
+            yield this.options.todos;
+        }
+
+        // Direct dependencies and pins (DependencySummary)
+        if (this.options.dependencies) {
+            yield this.options.dependencies;
+        }
+
+        // LICENSE files and removed license headers (LicenseDetector)
+        if (this.options.licenses) {
+            yield this.options.licenses;
+        }
     }
 
     /**
@@ -127,7 +208,8 @@ class GitIngestFormatter {
                 tokens: chunk.tokens,
                 metadata: chunk.metadata || {},
                 hasOverlap: chunk.hasOverlap || false, // v2.3.3
-                overlapFiles: chunk.overlapFiles || [] // v2.3.3
+                overlapFiles: chunk.overlapFiles || [], // v2.3.3
+                parts: chunk.files.filter(f => f.part).map(f => ({ file: f.relativePath, ...f.part })) // v3.4.0
             });
         }
 
@@ -169,7 +251,8 @@ class GitIngestFormatter {
         let tokenCount = 0;
 
         for (const file of files) {
-            if (tokenCount >= targetOverlapTokens) {
+            // File parts repeat the end of the previous part themselves (v3.4.0)
+            if (tokenCount >= targetOverlapTokens || file.part) {
                 break;
             }
             overlapFiles.unshift(file);
@@ -204,6 +287,15 @@ class GitIngestFormatter {
         const dirs = new Set(chunk.files.map(f => path.dirname(f.relativePath)));
         metadata += `\nDirectories: ${dirs.size}\n`;
 
+        // Parts of files split across chunks (v3.4.0)
+        const parts = chunk.files.filter(f => f.part);
+        if (parts.length > 0) {
+            metadata += '\nSplit files:\n';
+            for (const file of parts) {
+                metadata += `  ${file.relativePath}: part ${file.part.index} of ${file.part.total} (lines ${file.part.startLine}-${file.part.endLine})\n`;
+            }
+        }
+
         // Cross-references (v2.3.3)
         if (this.chunking.crossReferences && index > 0) {
             const prevChunk = allChunks[index - 1];
@@ -232,39 +324,88 @@ class GitIngestFormatter {
      */
     createChunks() {
         const strategy = this.chunking.strategy;
+        const files = this.chunking.splitFiles ? this.splitOversizedFiles(this.analysisResults) : this.analysisResults;
 
         switch (strategy) {
             case 'smart':
-                return this.createSmartChunks();
+                return this.createSmartChunks(files);
             case 'size':
-                return this.createSizeBasedChunks();
+                return this.createSizeBasedChunks(files);
             case 'file':
-                return this.createFileBasedChunks();
+                return this.createFileBasedChunks(files);
             case 'directory':
-                return this.createDirectoryBasedChunks();
+                return this.createDirectoryBasedChunks(files);
             case 'dependency':
-                return this.createDependencyBasedChunks();
+                return this.createDependencyBasedChunks(files);
             default:
-                return this.createSizeBasedChunks();
+                return this.createSizeBasedChunks(files);
+        }
+    }
+
+    /**
+     * Replace files larger than a chunk with parts split along symbol boundaries
+     * v3.4.0: Each part carries its line range, enclosing declaration and overlap
+     */
+    splitOversizedFiles(files) {
+        const maxTokens = this.chunking.maxTokensPerChunk;
+        const result = [];
+
+        for (const fileInfo of files) {
+            if (fileInfo.error || !fileInfo.path || fileInfo.tokens <= maxTokens || fileInfo.selectedSymbols || fileInfo.summary) {
+                result.push(fileInfo);
+                continue;
+            }
+
+            let parts = [];
+            try {
+                if (!this.splitter) {
+                    this.splitter = new FileSplitter({
+                        maxTokens,
+                        overlap: Math.min(this.chunking.overlap, Math.floor(maxTokens / 4)),
+                        ...(this.options.countTokens ? { countTokens: this.options.countTokens } : {})
+                    });
+                }
+                parts = this.splitter.split(this.readFile(fileInfo), fileInfo.relativePath);
+            } catch (error) {
+                parts = [];
+            }
+
+            if (parts.length < 2) {
+                result.push(fileInfo);
+                continue;
+            }
+            for (const part of parts) {
+                result.push({ ...fileInfo, tokens: part.tokens, fileTokens: fileInfo.tokens, part });
+            }
         }
+
+        return result;
+    }
+
+    /**
+     * Tokens to sort a file by; parts of a split file sort as the whole file,
+     * so they stay together and in order (v3.4.0)
+     */
+    sizeOf(file) {
+        return file.part ? file.fileTokens : file.tokens;
     }
 
     /**
      * Smart chunking - AI-based semantic grouping
      * Groups related files together based on directory and imports
      */
-    createSmartChunks() {
+    createSmartChunks(files = this.analysisResults) {
         const chunks = [];
         const maxTokens = this.chunking.maxTokensPerChunk;
 
         // Sort files by directory and then by token count
-        const sortedFiles = [...this.analysisResults].sort((a, b) => {
+        const sortedFiles = [...files].sort((a, b) => {
             const dirA = path.dirname(a.relativePath);
             const dirB = path.dirname(b.relativePath);
             if (dirA !== dirB) {
-                return dirA.localeCompare(dirB);
+                return compareBytes(dirA, dirB);
             }
-            return b.tokens - a.tokens;
+            return this.sizeOf(b) - this.sizeOf(a) || comparePaths(a.relativePath, b.relativePath);
         });
 
         let currentChunk = { files: [], tokens: 0, directories: new Set() };
@@ -303,12 +444,12 @@ class GitIngestFormatter {
     /**
      * Size-based chunking - Fixed token size chunks
      */
-    createSizeBasedChunks() {
+    createSizeBasedChunks(files = this.analysisResults) {
         const chunks = [];
         const maxTokens = this.chunking.maxTokensPerChunk;
 
         // Sort files by token count (largest first)
-        const sortedFiles = [...this.analysisResults].sort((a, b) => b.tokens - a.tokens);
+        const sortedFiles = [...files].sort((a, b) => this.sizeOf(b) - this.sizeOf(a) || comparePaths(a.relativePath, b.relativePath));
 
         let currentChunk = { files: [], tokens: 0 };
 
@@ -332,10 +473,10 @@ class GitIngestFormatter {
     /**
      * File-based chunking - One file per chunk (with directory context)
      */
-    createFileBasedChunks() {
+    createFileBasedChunks(files = this.analysisResults) {
         const chunks = [];
 
-        for (const file of this.analysisResults) {
+        for (const file of files) {
             chunks.push({
                 files: [file],
                 tokens: file.tokens,
@@ -351,12 +492,12 @@ class GitIngestFormatter {
     /**
      * Directory-based chunking - One directory per chunk
      */
-    createDirectoryBasedChunks() {
+    createDirectoryBasedChunks(files = this.analysisResults) {
         const chunks = [];
         const filesByDirectory = new Map();
 
         // Group files by directory
-        for (const file of this.analysisResults) {
+        for (const file of files) {
             const dir = path.dirname(file.relativePath);
             if (!filesByDirectory.has(dir)) {
                 filesByDirectory.set(dir, []);
@@ -381,10 +522,10 @@ class GitIngestFormatter {
      * Dependency-based chunking - Group by import/dependency graph
      * Placeholder for future implementation with AST analysis
      */
-    createDependencyBasedChunks() {
+    createDependencyBasedChunks(files = this.analysisResults) {
         // For now, fall back to smart chunking
         // In Phase 2, we'll implement proper dependency analysis
-        return this.createSmartChunks();
+        return this.createSmartChunks(files);
     }
 
     /**
@@ -435,7 +576,7 @@ class GitIngestFormatter {
         };
 
         const sortedFiles = [...files].sort((a, b) =>
-            a.relativePath.localeCompare(b.relativePath)
+            comparePaths(a.relativePath, b.relativePath)
         );
 
         for (const fileInfo of sortedFiles) {
@@ -475,20 +616,30 @@ class GitIngestFormatter {
         let content = '';
 
         // Sort files by token count (largest first)
-        const sortedFiles = [...chunk.files].sort((a, b) => b.tokens - a.tokens);
+        const sortedFiles = [...chunk.files].sort((a, b) => this.compareDocsFirst(a, b) || this.sizeOf(b) - this.sizeOf(a) || comparePaths(a.relativePath, b.relativePath));
 
         for (const fileInfo of sortedFiles) {
             if (fileInfo.error) continue;
 
             content += '\n';
             content += '='.repeat(48) + '\n';
-            content += `FILE: ${fileInfo.relativePath}\n`;
+            content += `FILE: ${fileInfo.relativePath}${fileInfo.part ? ` (part ${fileInfo.part.index} of ${fileInfo.part.total})` : ''}\n`;
+            content += this.generateIdLine(fileInfo);
+            content += this.generateDuplicatesLine(fileInfo);
+            content += this.generateDocsLine(fileInfo);
+            content += this.generateOwnersLine(fileInfo);
             content += '='.repeat(48) + '\n';
 
             try {
-                const fileContent = fs.readFileSync(fileInfo.path, 'utf8');
-
-                if (this.methodFilterEnabled && this.isCodeFile(fileInfo.path)) {
+                const fileContent = this.readFile(fileInfo);
+
+                if (fileInfo.part) {
+                    content += this.generatePartContent(fileContent, fileInfo);
+                } else if (fileInfo.summary) {
+                    content += this.generateSummaryContent(fileInfo);
+                } else if (fileInfo.selectedSymbols) {
+                    content += this.generateSelectedFileContent(fileContent, fileInfo);
+                } else if (this.methodFilterEnabled && this.isCodeFile(fileInfo.path)) {
                     const filteredContent = this.generateFilteredFileContent(fileContent, fileInfo.path);
                     content += filteredContent;
                 } else {
@@ -499,6 +650,7 @@ class GitIngestFormatter {
                     content += '\n';
                 }
             } catch (error) {
+                this.options.onError?.(fileInfo, error);
                 content += `Error reading file: ${error.message}\n`;
             }
         }
@@ -538,6 +690,16 @@ class GitIngestFormatter {
             summary += `Method filtering: ${mode} mode active\n`;
         }
 
+        // What earlier turns of the conversation supplied (v3.4.0, --session)
+        if (this.options.session) {
+            summary += ContextSession.formatHeader(this.options.session);
+        }
+
+        // Old and new signatures of the changed APIs (v3.4.0, api-diff)
+        if (this.options.apiDiff) {
+            summary += ApiDiff.formatHeader(this.options.apiDiff);
+        }
+
         if (this.stats.totalTokens > 0) {
             const tokenStr = this.formatTokenCount(this.stats.totalTokens);
             summary += `\nEstimated tokens: ${tokenStr}`;
@@ -553,6 +715,11 @@ class GitIngestFormatter {
     generateTree() {
         let tree = 'Directory structure:\n';
 
+        // Laid out by TreeCollapser within its budget (v3.4.0, --tree-budget)
+        if (this.options.tree) {
+            return tree + this.options.tree;
+        }
+
         // Build tree structure from analysis results
         const fileTree = this.buildFileTree();
         tree += this.formatTreeNode(fileTree, '', true);
@@ -569,7 +736,7 @@ class GitIngestFormatter {
 
         // Sort files by path for consistent tree structure
         const sortedFiles = [...this.analysisResults].sort((a, b) =>
-            a.relativePath.localeCompare(b.relativePath)
+            comparePaths(a.relativePath, b.relativePath)
         );
 
         for (const fileInfo of sortedFiles) {
@@ -615,7 +782,7 @@ class GitIngestFormatter {
 
         if (node.type === 'directory' && node.children) {
             const newPrefix = node.name ? (prefix + (isLast ? '    ' : '│   ')) : '';
-            const childKeys = Object.keys(node.children).sort();
+            const childKeys = Object.keys(node.children).sort(compareBytes);
 
             childKeys.forEach((key, index) => {
                 const child = node.children[key];
@@ -628,44 +795,137 @@ class GitIngestFormatter {
     }
 
     generateFileContents() {
-        let content = '';
-
-        // Sort files by token count (largest first)
-        const sortedFiles = [...this.analysisResults].sort((a, b) =>
-            b.tokens - a.tokens
-        );
+        return [...this.generateFileEntries()].join('');
+    }
 
-        for (const fileInfo of sortedFiles) {
+    /**
+     * Header and body of each file, one at a time (v3.4.0)
+     */
+    *generateFileEntries(files = this.analysisResults) {
+        for (const fileInfo of this.sortFiles(files)) {
             if (fileInfo.error) continue;
+            yield this.generateFileEntry(fileInfo);
+        }
+    }
 
-            content += '\n';
-            content += '='.repeat(48) + '\n';
-            content += `FILE: ${fileInfo.relativePath}\n`;
-            content += '='.repeat(48) + '\n';
-
-            try {
-                const fileContent = fs.readFileSync(fileInfo.path, 'utf8');
+    /**
+     * Sections of a layout: overview, tree, files by section, appendix (v3.4.0, --layout)
+     */
+    *generateLayoutPieces(layout) {
+        const files = layout.group(this.analysisResults.filter(fileInfo => !fileInfo.error));
+        const fileSection = section => () => this.sortFiles(files.get(section)).map(fileInfo => ({
+            name: fileInfo.relativePath,
+            render: () => this.generateFileEntry(fileInfo)
+        }));
+
+        yield* layout.arrange({
+            overview: () => [
+                this.generateSummary() + '\n',
+                ...[this.options.snippet, this.options.projects].filter(Boolean).map(section => '\n' + section + '\n')
+            ],
+            tree: () => [this.generateTree() + '\n'],
+            docs: fileSection('docs'),
+            schemas: fileSection('schemas'),
+            code: fileSection('code'),
+            tests: fileSection('tests'),
+            appendix: () => [this.options.crossReferences, this.options.todos, this.options.dependencies, this.options.licenses].filter(Boolean)
+        }, text => (this.options.countTokens || TokenUtils.calculate)(text));
+    }
 
-                // Apply method-level filtering if enabled and file is a code file
-                if (this.methodFilterEnabled && this.isCodeFile(fileInfo.path)) {
-                    const filteredContent = this.generateFilteredFileContent(fileContent, fileInfo.path);
-                    content += filteredContent;
-                } else {
-                    // Include full file content
-                    content += fileContent;
-                }
+    /**
+     * Docs first, then by token count (largest first), then by path
+     */
+    sortFiles(files) {
+        return [...files].sort((a, b) => this.compareDocsFirst(a, b) || b.tokens - a.tokens || comparePaths(a.relativePath, b.relativePath));
+    }
 
-                if (!content.endsWith('\n')) {
-                    content += '\n';
-                }
-            } catch (error) {
-                content += `Error reading file: ${error.message}\n`;
+    /**
+     * Header and body of one file
+     */
+    generateFileEntry(fileInfo) {
+        let content = '\n';
+        content += '='.repeat(48) + '\n';
+        content += `FILE: ${fileInfo.relativePath}\n`;
+        content += this.generateIdLine(fileInfo);
+        content += this.generateDuplicatesLine(fileInfo);
+        content += this.generateDocsLine(fileInfo);
+        content += this.generateOwnersLine(fileInfo);
+        content += '='.repeat(48) + '\n';
+
+        try {
+            const body = this.generateFileBody(fileInfo);
+
+            // Checking the piece, not the whole digest, keeps large digests linear
+            content += body;
+            if (body && !body.endsWith('\n')) {
+                content += '\n';
             }
+        } catch (error) {
+            this.options.onError?.(fileInfo, error);
+            content += `Error reading file: ${error.message}\n`;
         }
 
         return content;
     }
 
+    /**
+     * Emitted body of one file: budget summary, selected symbols, method-filtered or full content
+     */
+    generateFileBody(fileInfo) {
+        // Files summarized by the token budget only include their signatures or names
+        if (fileInfo.summary) {
+            return this.generateSummaryContent(fileInfo);
+        }
+
+        const fileContent = this.readFile(fileInfo);
+        if (fileInfo.selectedSymbols) {
+            // Files trimmed by the token budget or dependency expansion only include selected symbols
+            return this.generateSelectedFileContent(fileContent, fileInfo);
+        }
+        if (this.methodFilterEnabled && this.isCodeFile(fileInfo.path)) {
+            // Apply method-level filtering if enabled and file is a code file
+            return this.generateFilteredFileContent(fileContent, fileInfo.path);
+        }
+        // Include full file content
+        return fileContent;
+    }
+
+    /**
+     * Header line naming the near-duplicates a file stands for (v3.4.0, --dedupe)
+     */
+    generateDuplicatesLine(fileInfo) {
+        return fileInfo.duplicates ? `${DuplicateDetector.formatNote(fileInfo.duplicates)}\n` : '';
+    }
+
+    /**
+     * Header line with the stable section ID of a file (v3.4.0, --section-ids)
+     */
+    generateIdLine(fileInfo) {
+        return this.options.sectionIds ? `ID: ${sectionId(fileInfo.relativePath)}\n` : '';
+    }
+
+    /**
+     * Header line naming the docs of a file (v3.4.0, --docs)
+     */
+    generateDocsLine(fileInfo) {
+        return fileInfo.docLinks ? `${ProjectDocs.formatNote(fileInfo.docLinks)}\n` : '';
+    }
+
+    /**
+     * Header line naming the CODEOWNERS owners of a file (v3.4.0, --owner, --owners)
+     */
+    generateOwnersLine(fileInfo) {
+        return fileInfo.owners ? `${CodeOwners.formatNote(fileInfo.owners)}\n` : '';
+    }
+
+    /**
+     * Docs in their order before other files (v3.4.0, --docs)
+     */
+    compareDocsFirst(a, b) {
+        const rank = fileInfo => (fileInfo.documentation ? fileInfo.documentation.rank : Number.MAX_SAFE_INTEGER);
+        return rank(a) - rank(b);
+    }
+
     isCodeFile(filePath) {
         return FileUtils.isCode(filePath);
     }
@@ -702,6 +962,49 @@ class GitIngestFormatter {
         return filteredContent;
     }
 
+    generateSummaryContent(fileInfo) {
+        return `// ${fileInfo.selectionNote || 'Summary'}\n\n${fileInfo.summary}\n`;
+    }
+
+    generatePartContent(content, fileInfo) {
+        const lines = content.split('\n');
+        const { index, total, startLine, endLine, symbols, context, overlap } = fileInfo.part;
+        const slice = (start, end) => lines.slice(start - 1, end).join('\n') + '\n';
+
+        let partContent = `// Part ${index} of ${total} (lines ${startLine}-${endLine})`;
+        partContent += symbols.length > 0 ? `: ${symbols.join(', ')}\n\n` : '\n\n';
+
+        if (context) {
+            partContent += `// Enclosing declaration: ${context.name} (lines ${context.startLine}-${context.endLine})\n`;
+            partContent += slice(context.startLine, context.endLine) + '\n';
+        }
+        if (overlap) {
+            partContent += `// Overlap with part ${index - 1} (lines ${overlap.startLine}-${overlap.endLine})\n`;
+            partContent += slice(overlap.startLine, overlap.endLine) + '\n';
+        }
+        if (context || overlap) {
+            partContent += `// Lines ${startLine}-${endLine}\n`;
+        }
+
+        return partContent + slice(startLine, endLine);
+    }
+
+    generateSelectedFileContent(content, fileInfo) {
+        const lines = content.split('\n');
+        const note = fileInfo.selectionNote || 'Selected symbols';
+        // Context entries (imports, enclosing declarations) are not counted
+        const shown = fileInfo.selectedSymbols.filter(symbol => !symbol.context).length;
+        let selectedContent = `// ${note}: showing ${shown} ${fileInfo.selectionUnit || 'symbols'}\n\n`;
+
+        for (const symbol of fileInfo.selectedSymbols) {
+            const role = symbol.role && symbol.role !== 'selected' && symbol.role !== symbol.kind ? `, ${symbol.role}` : '';
+            selectedContent += `// ${symbol.kind}: ${symbol.name} (lines ${symbol.startLine}-${symbol.endLine}${role})\n`;
+            selectedContent += (symbol.lines || lines.slice(symbol.startLine - 1, symbol.endLine)).join('\n') + '\n\n';
+        }
+
+        return selectedContent;
+    }
+
     extractMethodBlock(lines, startLine) {
         // Simple method extraction: get the method and its body
         let braceCount = 0;
@@ -735,10 +1038,17 @@ class GitIngestFormatter {
         return methodLines.join('\n');
     }
 
-    saveToFile(outputPath) {
-        const digest = this.generateDigest();
-        fs.writeFileSync(outputPath, digest, 'utf8');
-        return digest.length;
+    saveToFile(outputPath, fileOptions = {}) {
+        // Chunked digests are written as chunk-N.txt next to the output, as named in their navigation
+        if (this.chunking.enabled) {
+            const digest = this.generateChunkedDigest();
+            const dir = path.dirname(outputPath);
+            this.chunkFiles = digest.map(chunk => path.join(dir, `chunk-${chunk.index}.txt`));
+            digest.forEach((chunk, i) => writeFileAtomic(this.chunkFiles[i], chunk.content, fileOptions));
+            return digest.reduce((sum, chunk) => sum + chunk.content.length, 0);
+        }
+
+        return ContextWriter.toFile(outputPath, fileOptions).writeAll(this.generatePieces()).end();
     }
 }
 
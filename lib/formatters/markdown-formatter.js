@@ -0,0 +1,326 @@
+import path from 'path';
+import { notebookLanguage } from '../languages/NotebookPlugin.js';
+import { fence, inlineCode } from './content-escaper.js';
+
+const ROOT_SECTION = '(root)';
+
+/**
+ * Markdown Context Formatter (v3.4.0)
+ * Renders a structured context (ctxman.context/v1, see StructuredFormatter)
+ * as Markdown that stays navigable when pasted into GitHub or chat UIs:
+ * - Table of contents with token counts per directory section and per file
+ * - One header per file, with size, selection and language-tagged fenced code
+ * - Optional <details> blocks so long files start collapsed
+ * - Fences and code spans longer than any backtick run of what they hold,
+ *   so file contents cannot close them (see content-escaper)
+ * Anchors follow GitHub's heading slugs, so TOC links work in rendered Markdown.
+ */
+class MarkdownFormatter {
+    constructor(context, options = {}) {
+        this.context = context;
+        this.options = {
+            collapsible: false, // Wrap file contents in <details>
+            fence: 'backtick', // Fence character: backtick or tilde (--fence)
+            toc: true,
+            ...options
+        };
+        this.slugs = new Map();
+    }
+
+    /**
+     * @returns {string} Markdown document
+     */
+    render() {
+        const { project, selection, files } = this.context;
+        const sections = this.groupSections(files);
+        const lines = [];
+
+        const title = `${project.name} context`;
+        this.slug(title);
+        lines.push(`# ${title}`, '', this.describeProject(project, selection), '');
+
+        // Snippet the context was enriched for (ctxman enrich)
+        if (this.context.snippet) {
+            this.slug('Snippet');
+            lines.push(...this.renderSnippet(this.context.snippet));
+        }
+
+        // Projects of a monorepo, in a per-project bundle (--per-project)
+        if (this.context.projects) {
+            this.slug('Projects');
+            lines.push(...this.renderProjects(this.context.projects));
+        }
+
+        // Slugs are numbered in document order, so headings are registered before the TOC is written
+        const withToc = this.options.toc && files.length > 0;
+        if (withToc) this.slug('Contents');
+        const anchors = sections.map(section => ({
+            section: this.slug(section.name),
+            files: section.files.map(file => this.slug(file.path))
+        }));
+
+        if (withToc) {
+            lines.push('## Contents', '');
+            sections.forEach((section, index) => {
+                lines.push(`- [${this.code(section.name)}](#${anchors[index].section}) — ${this.describeCount(section.files.length, 'file')}, ${this.formatTokens(section.tokens)}`);
+                section.files.forEach((file, fileIndex) => {
+                    lines.push(`  - [${this.code(file.path)}](#${anchors[index].files[fileIndex]}) — ${this.formatTokens(file.tokens)}`);
+                });
+            });
+            lines.push('');
+        }
+
+        for (const section of sections) {
+            lines.push(`## ${this.code(section.name)}`, '', `_${this.describeCount(section.files.length, 'file')} · ${this.formatTokens(section.tokens)}_`, '');
+            for (const file of section.files) {
+                lines.push(...this.renderFile(file));
+            }
+        }
+
+        if (this.context.crossReferences) {
+            lines.push(...this.renderCrossReferences(this.context.crossReferences));
+        }
+        if (this.context.todos) {
+            lines.push(...this.renderTodos(this.context.todos));
+        }
+        if (this.context.dependencies) {
+            lines.push(...this.renderDependencies(this.context.dependencies));
+        }
+        if (this.context.licenses) {
+            lines.push(...this.renderLicenses(this.context.licenses));
+        }
+
+        return lines.join('\n').replace(/\n+$/, '') + '\n';
+    }
+
+    /**
+     * Pasted snippet (enrich), fenced, then the locations and symbols it resolved to
+     * @private
+     */
+    renderSnippet(snippet) {
+        const items = [
+            ...snippet.frames.filter(frame => frame.file).map(frame =>
+                `- ${this.code(frame.location)} → ${this.code(`${frame.file}:${frame.line}`)}${frame.symbol ? ` in ${this.code(frame.symbol)}` : ''}`),
+            ...snippet.symbols.map(symbol => `- ${this.code(symbol.name)} (${symbol.kind}) — ${this.code(`${symbol.file}:${symbol.line}`)}`)
+        ];
+        return ['## Snippet', '', this.fence(snippet.text.replace(/\s+$/, ''), ''), '', ...items, ...(items.length > 0 ? [''] : [])];
+    }
+
+    /**
+     * Projects overview (--per-project): one item per project, this bundle's in bold
+     * @private
+     */
+    renderProjects(projects) {
+        const lines = ['## Projects', ''];
+        for (const entry of projects) {
+            const name = entry.current ? `**${this.code(entry.name)}**` : this.code(entry.name);
+            const uses = entry.dependsOn.length > 0 ? `; uses ${entry.dependsOn.map(other => this.code(other)).join(', ')}` : '';
+            lines.push(`- ${name} (${entry.kind}, ${this.code(entry.dir || '.')}) — ${this.describeCount(entry.files, 'file')}, ${this.formatTokens(entry.tokens)}${uses}`);
+        }
+        lines.push('');
+        return lines;
+    }
+
+    /**
+     * Cross-reference index (--xref): one item per symbol, its references nested
+     * @private
+     */
+    renderCrossReferences(entries) {
+        const lines = ['## Cross-reference index', ''];
+        for (const entry of entries) {
+            lines.push(`- ${this.code(entry.name)} (${entry.kind}) — ${this.code(`${entry.file}:${entry.line}`)}`);
+            for (const reference of entry.references) {
+                lines.push(`  - ${this.code(`${reference.file}:${reference.line}`)}`);
+            }
+        }
+        if (entries.length === 0) lines.push('_No symbols in this context_');
+        lines.push('');
+        return lines;
+    }
+
+    /**
+     * TODO/FIXME markers (--todos): one item per marker, its lines fenced
+     * @private
+     */
+    renderTodos(entries) {
+        const lines = ['## TODO / FIXME / HACK markers', ''];
+        for (const entry of entries) {
+            const details = [entry.author, entry.included ? null : 'outside this context'].filter(Boolean);
+            lines.push(`- **${entry.marker}** ${this.code(`${entry.file}:${entry.line}`)}${details.length > 0 ? ` (${details.join(', ')})` : ''}` +
+                `${entry.text ? ` — ${entry.text}` : ''}`);
+            lines.push('', this.fence(entry.context.lines.join('\n'), path.extname(entry.file).slice(1).toLowerCase()).replace(/^/gm, '  '), '');
+        }
+        if (entries.length === 0) lines.push('_No markers in this context_', '');
+        return lines;
+    }
+
+    /**
+     * Dependency manifests (--deps): direct dependencies, then pins
+     * @private
+     */
+    renderDependencies(entries) {
+        const lines = ['## Dependencies', ''];
+        for (const entry of entries) {
+            if (entry.error) {
+                lines.push(`### ${this.code(entry.manifest)}`, '', `_Not read: ${entry.error}_`, '');
+                continue;
+            }
+            const about = [entry.lockfile ? `locked in ${this.code(entry.lockfile)}` : null,
+                entry.transitive !== null ? `${entry.transitive} transitive` : null].filter(Boolean);
+            lines.push(`### ${this.code(entry.manifest)}`, '');
+            if (about.length > 0) lines.push(`_${about.join(', ')}_`, '');
+            for (const dependency of entry.dependencies) {
+                const locked = dependency.locked && dependency.locked !== dependency.version ? ` (locked ${dependency.locked})` : '';
+                lines.push(`- ${this.code(dependency.name)} ${dependency.version}${locked}${dependency.scope === 'runtime' ? '' : ` · ${dependency.scope}`}`);
+            }
+            for (const pin of entry.pins) {
+                lines.push(`- **${pin.kind}** ${this.code(pin.name)} ${pin.version}`);
+            }
+            if (entry.dependencies.length === 0 && entry.pins.length === 0) lines.push('_No dependencies_');
+            lines.push('');
+        }
+        if (entries.length === 0) lines.push('_No dependency manifests_', '');
+        return lines;
+    }
+
+    /**
+     * Licensing note (--licenses): LICENSE files, then the headers removed per license
+     * @private
+     */
+    renderLicenses(note) {
+        const lines = ['## Licenses', ''];
+        for (const file of note.files) {
+            lines.push(`- ${this.code(file.path)} — ${file.license || '_unknown license_'}`);
+            file.copyright.forEach(holder => lines.push(`  - ${holder}`));
+        }
+        if (note.files.length === 0) lines.push('_No LICENSE file_');
+        lines.push('');
+        for (const group of note.headers) {
+            lines.push(`### ${group.license || 'Unknown license'} headers`, '',
+                `_Removed from ${this.describeCount(group.files.length, 'file')}, ${this.formatTokens(group.tokens)}_`, '');
+            group.copyright.forEach(holder => lines.push(`- ${holder}`));
+            lines.push(`- Files: ${group.files.map(file => this.code(file)).join(', ')}`, '');
+        }
+        return lines;
+    }
+
+    /**
+     * Files grouped by top-level directory, in path order (root files first)
+     * @private
+     */
+    groupSections(files) {
+        const sections = new Map([[ROOT_SECTION, { name: ROOT_SECTION, files: [], tokens: 0 }]]);
+        for (const file of files) {
+            const name = file.path.includes('/') ? `${file.path.split('/')[0]}/` : ROOT_SECTION;
+            if (!sections.has(name)) sections.set(name, { name, files: [], tokens: 0 });
+            const section = sections.get(name);
+            section.files.push(file);
+            section.tokens += file.tokens;
+        }
+        return [...sections.values()].filter(section => section.files.length > 0);
+    }
+
+    /**
+     * @private
+     */
+    describeProject(project, selection) {
+        const parts = [`**${this.describeCount(project.files, 'file')} · ${this.formatTokens(project.tokens)}**`];
+        if (selection.mode !== 'all') {
+            parts.push(`selection: ${selection.mode}${selection.target ? ` ${selection.target}` : ''}`);
+        }
+        if (selection.budget) {
+            const { used, limit, tokenizer } = selection.budget;
+            parts.push(`budget: ${used.toLocaleString()} / ${limit.toLocaleString()} tokens (${tokenizer})`);
+        }
+        return parts.join(' · ');
+    }
+
+    /**
+     * @private
+     */
+    renderFile(file) {
+        const details = [this.formatTokens(file.tokens), this.describeCount(file.lines, 'line')];
+        if (file.language) details.push(file.language);
+        if (file.included === 'partial') {
+            details.push(`partial: ${file.ranges.map(range => range.name).join(', ')}`);
+        } else if (file.included === 'summary') {
+            details.push('summary');
+        }
+
+        const lines = [`### ${this.code(file.path)}`, ''];
+        if (file.note) lines.push(`> ${file.note}`, '');
+
+        if (file.content === null) {
+            lines.push(`_${details.join(' · ')}_`, '');
+            return lines;
+        }
+
+        const block = this.fence(file.content, this.fenceLanguage(file));
+        if (this.options.collapsible) {
+            lines.push('<details>', `<summary>${this.escapeHtml(details.join(' · '))}</summary>`, '', block, '', '</details>', '');
+        } else {
+            lines.push(`_${details.join(' · ')}_`, '', block, '');
+        }
+        return lines;
+    }
+
+    /**
+     * Fenced code block; the fence outgrows any run of its character in the content
+     * @private
+     */
+    fence(content, language) {
+        return fence(content, language, { char: this.options.fence });
+    }
+
+    /**
+     * Code span that outgrows any backtick run in the text
+     * @private
+     */
+    code(text) {
+        return inlineCode(text);
+    }
+
+    /**
+     * Language tag for a fence: the plugin's language, else the file extension
+     * Notebooks are fenced as their kernel language (v3.4.0)
+     * @private
+     */
+    fenceLanguage(file) {
+        if (file.language === 'notebook') return notebookLanguage(file.content) || 'python';
+        return file.language || path.extname(file.path).slice(1).toLowerCase();
+    }
+
+    /**
+     * GitHub heading slug, numbered when a heading repeats
+     * @private
+     */
+    slug(heading) {
+        const base = heading.toLowerCase().trim().replace(/[^\p{L}\p{N}\s_-]/gu, '').replace(/\s/g, '-');
+        const count = this.slugs.get(base) || 0;
+        this.slugs.set(base, count + 1);
+        return count === 0 ? base : `${base}-${count}`;
+    }
+
+    /**
+     * @private
+     */
+    formatTokens(tokens) {
+        return this.describeCount(tokens, 'token');
+    }
+
+    /**
+     * @private
+     */
+    describeCount(count, noun) {
+        return `${count.toLocaleString()} ${noun}${count === 1 ? '' : 's'}`;
+    }
+
+    /**
+     * @private
+     */
+    escapeHtml(text) {
+        return text.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+    }
+}
+
+export default MarkdownFormatter;
@@ -0,0 +1,314 @@
+import path from 'path';
+import FormatRegistry from './format-registry.js';
+import TokenBudget from '../core/TokenBudget.js';
+import ContentCache from '../cache/ContentCache.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { symbolId } from '../symbols/SymbolId.js';
+import DuplicateDetector from '../core/DuplicateDetector.js';
+import ProjectDocs from '../core/ProjectDocs.js';
+import CodeOwners from '../core/CodeOwners.js';
+import ContextWriter from '../core/ContextWriter.js';
+import { nativeFileSystem } from '../core/FileSystem.js';
+import { compareBytes, sectionId } from '../utils/ordering.js';
+
+export const STRUCTURED_SCHEMA = 'ctxman.context/v1';
+export const STRUCTURED_FORMATS = ['json', 'yaml', 'markdown', 'xml'];
+
+/**
+ * Structured Context Formatter (v3.4.0)
+ * Emits the exported context as JSON or YAML with a stable schema so
+ * tooling can read files, symbols, signatures, token counts and priorities
+ * without parsing the text digest. Markdown renders the same schema for
+ * people (MarkdownFormatter), XML as tagged documents for Claude (XmlFormatter).
+ *
+ * Schema (ctxman.context/v1):
+ * - schema, project { name, files, tokens }, selection { mode, target, budget }
+ * - snippet { text, frames[] { location, file, line, symbol }, symbols[] { name, kind, file, line } }
+ *   only with ctxman enrich: the pasted snippet, its file locations and the symbols it names
+ * - projects[] { name, kind, dir, files, tokens, dependsOn, current }
+ *   only with --per-project: every project of the monorepo, current for this bundle's
+ * - files[] { path, language, priority, tokens, lines, size, included, note, ranges[], symbols[], content }
+ *   included is full, partial or summary (signatures/names only; content holds the summary)
+ *   with --section-ids, id comes first: a number derived from the path, the same in every run
+ * - ranges[] { name, kind, role, startLine, endLine } (null when the whole file is included)
+ * - symbols[] { id, name, kind, signature, startLine, endLine, parent, exported, tokens, included }
+ *   id is the rename-safe reference accepted by --symbol and profile symbols
+ * - crossReferences[] { name, kind, file, line, references[] { file, line } }
+ *   only with --xref: included symbols and the included lines referencing them
+ * - todos[] { marker, file, line, text, author, included, context { startLine, lines[] } }
+ *   only with --todos: TODO, FIXME, HACK and XXX comments
+ * - dependencies[] { manifest, ecosystem, name, version, runtime, lockfile, dependencies[] { name,
+ *   version, locked, scope }, transitive, pins[] { name, version, kind }, error }
+ *   only with --deps: go.mod, package.json, Cargo.toml and requirements.txt files
+ * - licenses { files[] { path, license, copyright[], tokens }, headers[] { license, copyright[],
+ *   files[], tokens } }
+ *   only with --licenses: LICENSE files, left out of files, and the license headers removed from them
+ * Files are ordered by path and symbols by line; keys are always present.
+ * JSON is streamed one file at a time (encodePieces).
+ */
+class StructuredFormatter {
+    constructor(projectRoot, stats, analysisResults, options = {}) {
+        this.projectRoot = projectRoot;
+        this.stats = stats;
+        this.analysisResults = analysisResults;
+        this.options = {
+            extractor: null, // Initialized SymbolExtractor (default: heuristic)
+            cache: null, // ContentCache for symbol outlines
+            countTokens: text => Math.ceil(text.length / 4),
+            selection: { mode: 'all', target: null },
+            budget: null, // TokenBudget plan
+            redactor: null, // SecretRedactor applied to file contents
+            readFile: filePath => nativeFileSystem.readText(filePath), // e.g. from a GitRevision (--rev)
+            includeContent: true,
+            collapsible: false, // Markdown: file contents in <details> blocks
+            xmlTags: null, // XML: tag names replacing XML_TAGS (see parseXmlTags)
+            fence: 'backtick', // Markdown: fence character, backtick or tilde (see content-escaper)
+            xmlContent: 'raw', // XML: content mode, raw, cdata or entities (see content-escaper)
+            crossReferences: null, // CrossReferenceIndex entries, appended after files
+            todos: null, // TodoHarvester entries, appended after files
+            dependencies: null, // DependencySummary entries, appended after files
+            licenses: null, // LicenseDetector note, appended after files
+            projects: null, // Overview of a monorepo's projects, before files (--per-project)
+            snippet: null, // Snippet of ctxman enrich and what it resolved to, before files
+            onError: null, // (fileInfo, error) for files that cannot be described; they are left out
+            sectionIds: false, // Stable numeric id per file (--section-ids)
+            ...options
+        };
+        this.extractor = this.options.extractor || new SymbolExtractor({ backend: 'heuristic' });
+    }
+
+    /**
+     * Build the schema object
+     * @returns {Object}
+     */
+    build() {
+        const files = this.sortedFiles().map(fileInfo => this.describeOrReport(fileInfo)).filter(Boolean);
+        return { ...this.buildHead(files), files, ...this.buildAppendix() };
+    }
+
+    /**
+     * Schema fields after files: cross-references, markers, dependencies and licenses, when given
+     * @private
+     */
+    buildAppendix() {
+        const { crossReferences, todos, dependencies, licenses } = this.options;
+        return {
+            ...(crossReferences ? { crossReferences } : {}),
+            ...(todos ? { todos } : {}),
+            ...(dependencies ? { dependencies } : {}),
+            ...(licenses ? { licenses } : {})
+        };
+    }
+
+    /**
+     * Schema fields before files; files are described by their analysis only
+     * @private
+     */
+    buildHead(files) {
+        const budget = this.options.budget;
+
+        return {
+            schema: STRUCTURED_SCHEMA,
+            project: {
+                name: path.basename(this.projectRoot),
+                files: files.length,
+                tokens: files.reduce((sum, file) => sum + file.tokens, 0)
+            },
+            selection: {
+                mode: this.options.selection.mode,
+                target: this.options.selection.target ?? null,
+                budget: budget
+                    ? { limit: budget.limit, used: budget.usedTokens, tokenizer: budget.tokenizer }
+                    : null
+            },
+            ...(this.options.snippet ? { snippet: this.options.snippet } : {}),
+            ...(this.options.projects ? { projects: this.options.projects } : {})
+        };
+    }
+
+    /**
+     * Exported files in schema order
+     * @private
+     */
+    sortedFiles() {
+        const posix = fileInfo => fileInfo.relativePath.split(path.sep).join('/');
+        return this.analysisResults
+            .filter(fileInfo => !fileInfo.error)
+            .sort((a, b) => compareBytes(posix(a), posix(b)));
+    }
+
+    /**
+     * @param {string} format - json, yaml, markdown or xml
+     * @returns {string}
+     */
+    encode(format) {
+        if (!STRUCTURED_FORMATS.includes(format)) {
+            throw new Error(`Unsupported structured format: ${format} (expected ${STRUCTURED_FORMATS.join(', ')})`);
+        }
+
+        const registry = new FormatRegistry();
+        if (format === 'markdown') {
+            return registry.encode(format, this.build(), { collapsible: this.options.collapsible, fence: this.options.fence });
+        }
+        const output = registry.encode(format, this.build(), format === 'xml' ? { xmlTags: this.options.xmlTags, xmlContent: this.options.xmlContent } : {});
+        if (format === 'xml') return output;
+        return format === 'yaml' ? output.replace(/^\n/, '') + '\n' : output + '\n';
+    }
+
+    /**
+     * Encoded context piece by piece (v3.4.0)
+     * JSON is emitted one file at a time, the same text encode() returns,
+     * so only one file's content is held; YAML, Markdown and XML come whole.
+     * @param {string} format - json, yaml, markdown or xml
+     * @returns {Iterable<string>}
+     */
+    *encodePieces(format) {
+        if (format !== 'json') {
+            yield this.encode(format);
+            return;
+        }
+
+        const files = this.sortedFiles();
+        if (files.length === 0) {
+            yield this.encode(format);
+            return;
+        }
+        const head = JSON.stringify({ ...this.buildHead(files), files: [] }, null, 2);
+
+        // The head is written first, so it still counts files that fail later
+        yield head.replace(/\[\]\n\}$/, '[\n');
+        let written = 0;
+        for (const fileInfo of files) {
+            const described = this.describeOrReport(fileInfo);
+            if (!described) continue;
+            const file = JSON.stringify(described, null, 2).replace(/^/gm, '    ');
+            yield written++ > 0 ? `,\n${file}` : file;
+        }
+        const appendix = this.buildAppendix();
+        yield Object.keys(appendix).length > 0
+            ? `\n  ],\n${JSON.stringify(appendix, null, 2).slice(2)}\n`
+            : '\n  ]\n}\n';
+    }
+
+    /**
+     * Default output file of a format
+     * @param {string} format
+     * @returns {string} context.json, context.yaml, context.md or context.xml
+     */
+    static defaultFile(format) {
+        return `context.${format === 'markdown' ? 'md' : format}`;
+    }
+
+    /**
+     * describeFile(), or null after options.onError for a file that fails (v3.4.0)
+     * @private
+     */
+    describeOrReport(fileInfo) {
+        try {
+            return this.describeFile(fileInfo);
+        } catch (error) {
+            if (!this.options.onError) throw error;
+            this.options.onError(fileInfo, error);
+            return null;
+        }
+    }
+
+    saveToFile(outputPath, format) {
+        return ContextWriter.toFile(outputPath).writeAll(this.encodePieces(format)).end();
+    }
+
+    /**
+     * @private
+     */
+    describeFile(fileInfo) {
+        const relativePath = fileInfo.relativePath.split(path.sep).join('/');
+        const source = this.options.readFile(fileInfo.path);
+        const content = this.options.redactor ? this.options.redactor.redact(source, relativePath) : source;
+        const selected = fileInfo.selectedSymbols || null;
+        const plugin = this.extractor.getPlugin(relativePath, content);
+
+        const summary = fileInfo.summary || null;
+        const isIncluded = symbol => !summary && (!selected || selected.some(range => !range.context &&
+            symbol.startLine >= range.startLine && symbol.endLine <= range.endLine));
+
+        const lines = content.split('\n');
+        const symbols = this.extractSymbols(content, relativePath)
+            .filter(symbol => symbol.kind !== SymbolKind.MODULE)
+            .map(symbol => ({
+                id: symbolId(symbol, plugin.getPackageId(relativePath)),
+                name: symbol.qualifiedName,
+                kind: symbol.kind,
+                signature: symbol.signature || null,
+                startLine: symbol.startLine,
+                endLine: symbol.endLine,
+                parent: symbol.parent,
+                exported: symbol.exported,
+                tokens: this.options.countTokens(lines.slice(symbol.startLine - 1, symbol.endLine).join('\n'), relativePath),
+                included: isIncluded(symbol)
+            }));
+
+        return {
+            ...(this.options.sectionIds ? { id: sectionId(relativePath) } : {}),
+            path: relativePath,
+            language: plugin ? plugin.getLanguageId(relativePath) : fileInfo.language || null,
+            priority: fileInfo.priority ?? TokenBudget.filePriority(relativePath),
+            tokens: fileInfo.tokens,
+            lines: fileInfo.lines,
+            size: fileInfo.sizeBytes,
+            included: summary ? 'summary' : selected ? 'partial' : 'full',
+            note: [
+                fileInfo.selectionNote,
+                fileInfo.duplicates && DuplicateDetector.formatNote(fileInfo.duplicates),
+                fileInfo.docLinks && ProjectDocs.formatNote(fileInfo.docLinks),
+                fileInfo.owners && CodeOwners.formatNote(fileInfo.owners)
+            ].filter(Boolean).join('; ') || null,
+            ranges: selected
+                ? selected.map(range => ({
+                    name: range.name,
+                    kind: range.kind,
+                    role: range.role || 'selected',
+                    startLine: range.startLine,
+                    endLine: range.endLine
+                }))
+                : null,
+            symbols,
+            content: this.options.includeContent ? (summary ?? this.fileContent(lines, selected)) : null
+        };
+    }
+
+    /**
+     * Whole file, or the selected ranges separated by blank lines
+     * @private
+     */
+    fileContent(lines, selected) {
+        if (!selected) {
+            return lines.join('\n');
+        }
+        return selected
+            .map(range => (range.lines || lines.slice(range.startLine - 1, range.endLine)).join('\n'))
+            .join('\n\n');
+    }
+
+    /**
+     * @private
+     */
+    extractSymbols(content, relativePath) {
+        if (!this.extractor.supports(relativePath)) {
+            return [];
+        }
+
+        const cache = this.options.cache;
+        if (!cache) {
+            return this.extractor.extract(content, relativePath);
+        }
+        return cache.symbols(
+            ContentCache.hash(content),
+            ContentCache.key(this.extractor.getBackend(relativePath), relativePath),
+            relativePath,
+            () => this.extractor.extract(content, relativePath)
+        );
+    }
+}
+
+export default StructuredFormatter;
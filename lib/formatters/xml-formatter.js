@@ -0,0 +1,275 @@
+/**
+ * XML Context Formatter (v3.4.0)
+ * Renders a structured context (ctxman.context/v1, see StructuredFormatter)
+ * in the document layout Anthropic recommends for long inputs to Claude:
+ * - <documents> with one <document index="N"> per file, its <source> path
+ *   and its content in <document_content>; N is the section ID with --section-ids
+ * - File details (language, tokens, lines, selection) as attributes of <source>
+ * - With --xref, <cross_references> naming where each included symbol is
+ *   defined and referenced
+ * - With --todos, <todos> holding each TODO/FIXME comment and its <lines>
+ * - With --deps, <dependencies> with a <manifest> of direct dependencies and pins each
+ * - With --licenses, <licenses> naming the LICENSE files and the license headers removed
+ * - With --per-project, <projects> naming every project of the monorepo before the documents
+ * - With enrich, <snippet> holding the pasted text and the <symbol> elements it resolved to
+ * - Tag names of the document layout can be renamed (--xml-tags), e.g. to
+ *   the <document_contents> some prompts use
+ * Contents are left unescaped by default so code reads as written; only the
+ * closing tags of the elements around them (</document_content>, </document>,
+ * ...) are escaped, so a file cannot end them. --xml-content cdata or entities
+ * gives well-formed XML instead (see content-escaper).
+ */
+
+import { xmlContent, escapeXml } from './content-escaper.js';
+
+// Tags of the document layout, by the name --xml-tags renames them with
+export const XML_TAGS = {
+    documents: 'documents',
+    document: 'document',
+    source: 'source',
+    document_content: 'document_content'
+};
+
+const XML_NAME = /^[A-Za-z_][\w.-]*$/;
+
+/**
+ * Parse a --xml-tags list
+ * @param {string} value - TAG=NAME pairs, comma-separated (document_content=document_contents)
+ * @returns {Object} Tag names by XML_TAGS key, only those renamed
+ * @throws {Error} For unknown tags and names that are not XML names
+ */
+export function parseXmlTags(value) {
+    const tags = {};
+    for (const pair of String(value).split(',').map(entry => entry.trim()).filter(Boolean)) {
+        const [tag, name, ...rest] = pair.split('=').map(part => part.trim());
+        if (!Object.hasOwn(XML_TAGS, tag)) {
+            throw new Error(`Unknown XML tag: ${tag} (expected ${Object.keys(XML_TAGS).join(', ')})`);
+        }
+        if (rest.length > 0 || !XML_NAME.test(name || '') || /^xml/i.test(name)) {
+            throw new Error(`Invalid XML tag name for ${tag}: ${name || '(missing)'}`);
+        }
+        tags[tag] = name;
+    }
+    if (Object.keys(tags).length === 0) {
+        throw new Error('No XML tags given (e.g. document=file,source=path)');
+    }
+    return tags;
+}
+
+class XmlFormatter {
+    /**
+     * @param {Object} context - Structured context (ctxman.context/v1)
+     * @param {Object} [options] - { tags: names replacing XML_TAGS, content: raw, cdata or entities }
+     */
+    constructor(context, options = {}) {
+        this.context = context;
+        this.tags = { ...XML_TAGS, ...(options.tags || {}) };
+        this.contentMode = options.content || 'raw';
+    }
+
+    /**
+     * @returns {string} XML document
+     */
+    render() {
+        const { project, selection, files } = this.context;
+        const lines = [`<context${this.attributes({ project: project.name, files: project.files, tokens: project.tokens })}>`];
+
+        if (selection.mode !== 'all' || selection.budget) {
+            const budget = selection.budget;
+            lines.push(`<selection${this.attributes({
+                mode: selection.mode,
+                target: selection.target,
+                budget: budget ? `${budget.used}/${budget.limit}` : null,
+                tokenizer: budget ? budget.tokenizer : null
+            })}/>`);
+        }
+
+        if (this.context.snippet) {
+            lines.push(...this.renderSnippet(this.context.snippet));
+        }
+        if (this.context.projects) {
+            lines.push(...this.renderProjects(this.context.projects));
+        }
+
+        lines.push(`<${this.tags.documents}>`);
+        files.forEach((file, index) => {
+            lines.push(...this.renderFile(file, index + 1));
+        });
+        lines.push(`</${this.tags.documents}>`);
+        if (this.context.crossReferences) {
+            lines.push(...this.renderCrossReferences(this.context.crossReferences));
+        }
+        if (this.context.todos) {
+            lines.push(...this.renderTodos(this.context.todos));
+        }
+        if (this.context.dependencies) {
+            lines.push(...this.renderDependencies(this.context.dependencies));
+        }
+        if (this.context.licenses) {
+            lines.push(...this.renderLicenses(this.context.licenses));
+        }
+        lines.push('</context>');
+
+        return lines.join('\n') + '\n';
+    }
+
+    /**
+     * @private
+     */
+    renderFile(file, index) {
+        const included = file.included === 'partial'
+            ? `partial: ${file.ranges.map(range => range.name).join(', ')}`
+            : file.included;
+        const { document, source, document_content: content } = this.tags;
+        const lines = [
+            `<${document} index="${file.id ?? index}">`,
+            `<${source}${this.attributes({ language: file.language, tokens: file.tokens, lines: file.lines, included })}>${this.escape(file.path)}</${source}>`
+        ];
+        if (file.note) lines.push(`<note>${this.escape(file.note)}</note>`);
+        if (file.content !== null) {
+            lines.push(`<${content}>`, this.content(file.content.replace(/\n$/, ''), [content, document, this.tags.documents]), `</${content}>`);
+        }
+        lines.push(`</${document}>`);
+        return lines;
+    }
+
+    /**
+     * @private
+     */
+    renderSnippet(snippet) {
+        const lines = ['<snippet>', '<text>', this.content(snippet.text.replace(/\s+$/, ''), ['text', 'snippet']), '</text>'];
+        for (const symbol of snippet.symbols) {
+            lines.push(`<symbol${this.attributes({ name: symbol.name, kind: symbol.kind, file: symbol.file, line: symbol.line })}/>`);
+        }
+        lines.push('</snippet>');
+        return lines;
+    }
+
+    /**
+     * @private
+     */
+    renderProjects(projects) {
+        const lines = ['<projects>'];
+        for (const entry of projects) {
+            lines.push(`<project${this.attributes({
+                name: entry.name,
+                kind: entry.kind,
+                dir: entry.dir || '.',
+                files: entry.files,
+                tokens: entry.tokens,
+                depends_on: entry.dependsOn.length > 0 ? entry.dependsOn.join(', ') : null,
+                current: entry.current ? 'true' : null
+            })}/>`);
+        }
+        lines.push('</projects>');
+        return lines;
+    }
+
+    /**
+     * @private
+     */
+    renderCrossReferences(entries) {
+        const lines = ['<cross_references>'];
+        for (const entry of entries) {
+            const attributes = this.attributes({ name: entry.name, kind: entry.kind, defined: `${entry.file}:${entry.line}` });
+            const references = entry.references.map(reference => `${reference.file}:${reference.line}`).join(', ');
+            lines.push(`<symbol${attributes}>${this.escape(references)}</symbol>`);
+        }
+        lines.push('</cross_references>');
+        return lines;
+    }
+
+    /**
+     * @private
+     */
+    renderTodos(entries) {
+        const lines = ['<todos>'];
+        for (const entry of entries) {
+            const attributes = this.attributes({
+                marker: entry.marker,
+                location: `${entry.file}:${entry.line}`,
+                author: entry.author,
+                outside: entry.included ? null : 'true'
+            });
+            lines.push(`<todo${attributes}>`, this.escape(entry.text), `<lines start="${entry.context.startLine}">`,
+                this.content(entry.context.lines.join('\n'), ['lines', 'todo', 'todos']), '</lines>', '</todo>');
+        }
+        lines.push('</todos>');
+        return lines;
+    }
+
+    /**
+     * @private
+     */
+    renderDependencies(entries) {
+        const lines = ['<dependencies>'];
+        for (const entry of entries) {
+            lines.push(`<manifest${this.attributes({
+                path: entry.manifest,
+                ecosystem: entry.ecosystem,
+                lockfile: entry.lockfile,
+                transitive: entry.transitive,
+                error: entry.error
+            })}>`);
+            for (const dependency of entry.dependencies) {
+                const attributes = this.attributes({ name: dependency.name, version: dependency.version, locked: dependency.locked, scope: dependency.scope });
+                lines.push(`<dependency${attributes}/>`);
+            }
+            for (const pin of entry.pins) {
+                lines.push(`<pin${this.attributes({ kind: pin.kind, name: pin.name, version: pin.version })}/>`);
+            }
+            lines.push('</manifest>');
+        }
+        lines.push('</dependencies>');
+        return lines;
+    }
+
+    /**
+     * @private
+     */
+    renderLicenses(note) {
+        const lines = ['<licenses>'];
+        for (const file of note.files) {
+            lines.push(`<license_file${this.attributes({ path: file.path, license: file.license, tokens: file.tokens })}>`);
+            file.copyright.forEach(holder => lines.push(`<copyright>${this.escape(holder)}</copyright>`));
+            lines.push('</license_file>');
+        }
+        for (const group of note.headers) {
+            lines.push(`<header${this.attributes({ license: group.license, files: group.files.length, tokens: group.tokens })}>`);
+            group.copyright.forEach(holder => lines.push(`<copyright>${this.escape(holder)}</copyright>`));
+            group.files.forEach(file => lines.push(`<file>${this.escape(file)}</file>`));
+            lines.push('</header>');
+        }
+        lines.push('</licenses>');
+        return lines;
+    }
+
+    /**
+     * Attributes with a value, in the given order
+     * @private
+     */
+    attributes(values) {
+        return Object.entries(values)
+            .filter(([, value]) => value !== null && value !== undefined)
+            .map(([name, value]) => ` ${name}="${this.escape(String(value))}"`)
+            .join('');
+    }
+
+    /**
+     * File text in the content mode; raw contents escape the closing tags of
+     * the elements they are in, and of the context
+     * @private
+     */
+    content(text, tags) {
+        return xmlContent(text, { mode: this.contentMode, tags: [...tags, 'context'] });
+    }
+
+    /**
+     * @private
+     */
+    escape(text) {
+        return escapeXml(text);
+    }
+}
+
+export default XmlFormatter;
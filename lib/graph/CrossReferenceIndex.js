@@ -0,0 +1,168 @@
+/**
+ * CrossReferenceIndex - Where included symbols are defined and used
+ * v3.4.0 - Cross-reference appendix (--xref)
+ *
+ * Responsibilities:
+ * - List the symbols a context includes: every symbol of whole files, the
+ *   selected symbols of partial files, the exported symbols of summaries
+ * - Find the included lines that reference each of them (file:line), using
+ *   the names visible to each file through its imports
+ * - Render the index as the appendix of a digest
+ */
+
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { referencedIdentifiers, stripNoise } from './DependencyExpander.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export const XREF_TITLE = 'CROSS-REFERENCE INDEX';
+
+export class CrossReferenceIndex {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      maxReferences: 20, // Locations listed per symbol before "+N more"
+      ...options
+    };
+  }
+
+  /**
+   * Included symbols with their definition and the included lines referencing them
+   * @param {DependencyGraph} graph - Graph over the exported files
+   * @param {Array<{path: string, ranges: Array<{startLine: number, endLine: number}>|null, summary?: boolean}>} files
+   *   '/'-separated paths; ranges null for whole files
+   * @param {Array<Object>} [targets] - Symbols to index instead of those the files include
+   * @returns {Array<{name: string, kind: string, file: string, line: number, references: Array<{file: string, line: number}>}>}
+   *   In file and line order; references too
+   */
+  build(graph, files, targets = null) {
+    const included = new Map();
+    for (const symbol of targets || files.flatMap(file => includedSymbols(graph, file))) {
+      included.set(symbolKey(symbol), { symbol, references: [] });
+    }
+
+    for (const file of files) {
+      if (file.summary) continue;
+      const node = graph.getFile(file.path);
+      if (!node) continue;
+
+      // local name -> included symbols it may stand for
+      const visible = new Map();
+      for (const { local, symbol } of graph.visibleSymbols(file.path)) {
+        const entry = included.get(symbolKey(symbol));
+        if (!entry) continue;
+        if (!visible.has(local)) visible.set(local, []);
+        visible.get(local).push(entry);
+      }
+      if (visible.size === 0) continue;
+
+      const lines = stripNoise(node.content, node.language).split('\n');
+      for (const line of includedLines(file, lines.length)) {
+        const text = lines[line - 1];
+        const identifiers = referencedIdentifiers(text, node.language);
+        const referenced = new Set();
+        for (const identifier of identifiers) {
+          for (const entry of visible.get(identifier) || []) {
+            if (referenced.has(entry) || !this.refersTo(entry.symbol, identifier, identifiers, text, file.path, line)) continue;
+            referenced.add(entry);
+            entry.references.push({ file: file.path, line });
+          }
+        }
+      }
+    }
+
+    return [...included.values()]
+      .sort((a, b) => compareBytes(a.symbol.file, b.symbol.file) || a.symbol.startLine - b.symbol.startLine)
+      .map(({ symbol, references }) => ({
+        name: symbol.qualifiedName,
+        kind: symbol.kind,
+        file: symbol.file,
+        line: symbol.startLine,
+        references: references.sort((a, b) => compareBytes(a.file, b.file) || a.line - b.line)
+      }));
+  }
+
+  /**
+   * Digest appendix
+   * @param {Array<Object>} entries - Result of build()
+   * @returns {string}
+   */
+  format(entries) {
+    const { maxReferences } = this.options;
+    const lines = ['', '='.repeat(48), XREF_TITLE, '='.repeat(48)];
+
+    for (const entry of entries) {
+      lines.push(`${entry.name} (${entry.kind}) — ${entry.file}:${entry.line}`);
+      if (entry.references.length === 0) {
+        lines.push('    not referenced in this context');
+        continue;
+      }
+      const shown = entry.references.slice(0, maxReferences).map(reference => `${reference.file}:${reference.line}`);
+      const more = entry.references.length - shown.length;
+      lines.push(`    ${shown.join(', ')}${more > 0 ? ` +${more} more` : ''}`);
+    }
+    if (entries.length === 0) {
+      lines.push('No symbols in this context');
+    }
+    return `${lines.join('\n')}\n`;
+  }
+
+  /**
+   * Whether a name on a line stands for the symbol: not at its own
+   * definition, top-level symbols not only as a member (x.name), members
+   * only as a member or next to their container's name
+   * @private
+   */
+  refersTo(symbol, identifier, identifiers, text, filePath, line) {
+    if (symbol.file === filePath && symbol.startLine === line) return false;
+    if (identifier.includes('.')) return true;
+    if (!symbol.parent) {
+      return new RegExp(`(?:^|[^.\\w$])${identifier.replace(/\$/g, '\\$')}(?![\\w$])`).test(text);
+    }
+
+    const container = symbol.parent.split('.').pop();
+    return identifiers.has(container) ||
+      [...identifiers].some(selector => selector.endsWith(`.${identifier}`));
+  }
+}
+
+/**
+ * Symbols of a file that the context includes
+ * @private
+ */
+function includedSymbols(graph, file) {
+  const symbols = (graph.getFile(file.path)?.symbols || []).filter(symbol => symbol.kind !== SymbolKind.MODULE);
+  if (file.summary) {
+    return symbols.filter(symbol => symbol.exported);
+  }
+  if (!file.ranges) {
+    return symbols;
+  }
+  return symbols.filter(symbol => file.ranges.some(range =>
+    symbol.startLine >= range.startLine && symbol.endLine <= range.endLine));
+}
+
+/**
+ * 1-based lines of a file that the context includes
+ * @private
+ */
+function includedLines(file, lineCount) {
+  if (!file.ranges) {
+    return Array.from({ length: lineCount }, (_, index) => index + 1);
+  }
+  const lines = new Set();
+  for (const range of file.ranges) {
+    for (let line = range.startLine; line <= Math.min(range.endLine, lineCount); line++) lines.add(line);
+  }
+  return [...lines].sort((a, b) => a - b);
+}
+
+/**
+ * @private
+ */
+function symbolKey(symbol) {
+  return `${symbol.file}\0${symbol.qualifiedName}\0${symbol.startLine}`;
+}
+
+export default CrossReferenceIndex;
@@ -0,0 +1,461 @@
+/**
+ * DependencyExpander - Focus symbol dependency expansion
+ * v3.4.0 - Dependency-aware context expansion
+ *
+ * Responsibilities:
+ * - Resolve a focus (symbol name, file:symbol, file path or symbol ID)
+ * - Find the types, functions and constants a symbol references
+ * - Walk those references breadth-first up to a configurable depth
+ * - Optionally add the types implementing selected interfaces
+ * - Optionally resolve references through a language server (--lsp)
+ * - Group the selected definitions by file for export
+ */
+
+import { createSymbol, SymbolKind, CONTAINER_KINDS } from '../symbols/SymbolModel.js';
+import { SymbolIdIndex, isSymbolId } from '../symbols/SymbolId.js';
+import { ImplementationFinder } from './ImplementationFinder.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('DependencyExpander');
+
+const IDENTIFIER_PATTERN = /[A-Za-z_$][\w$]*/g;
+const SELECTOR_PATTERN = /([A-Za-z_$][\w$]*)(?=\s*\.\s*([A-Za-z_$][\w$]*))/g;
+const RECEIVER_PATTERN = /([A-Za-z_$][\w$]*)\s*\.\s*$/;
+
+export class DependencyExpander {
+  /**
+   * @param {DependencyGraph} graph - Built graph
+   * @param {Object} options
+   */
+  constructor(graph, options = {}) {
+    this.graph = graph;
+    this.options = {
+      depth: 1,
+      maxSymbols: 200, // Stop expanding beyond this many definitions
+      implementations: false, // Add implementing types and methods of selected interfaces
+      lsp: null, // LspGateway answering definitions for the languages it handles
+      ...options
+    };
+    this.finder = new ImplementationFinder(graph);
+  }
+
+  /**
+   * Resolve a focus specification to symbols
+   * Accepts "Service.fetch" / "fetch" (best-ranked matches across the
+   * project), "path/to/file.ts:fetch" (one file), "pkg/calc.Calculator.Add"
+   * (package or module path, then the symbol), a file path (the whole file)
+   * or a symbol ID ("pkg/calc#method:Calculator.Add@3f2a91c0", reconciled
+   * when the symbol moved or was renamed).
+   * @param {string} spec
+   * @returns {Array<Object>} Focus symbols (empty when nothing matches)
+   */
+  resolveFocus(spec) {
+    if (isSymbolId(spec)) {
+      const resolved = this.getSymbolIds().resolve(spec);
+      return resolved ? [resolved.symbol] : [];
+    }
+
+    const file = this.graph.getFile(spec);
+    if (file) {
+      return [createSymbol({
+        name: file.path,
+        kind: SymbolKind.MODULE,
+        language: file.language,
+        file: file.path,
+        startLine: 1,
+        endLine: file.content.split('\n').length,
+        exported: true
+      })];
+    }
+
+    const scoped = /^(.*[^:]):([^:].*)$/.exec(spec);
+    const scopedFile = scoped && this.graph.getFile(scoped[1]);
+    if (scopedFile) {
+      return matchSymbols([scopedFile], scoped[2]);
+    }
+
+    const matches = matchSymbols([...this.graph.files.values()], spec);
+    return matches.length > 0 ? matches : this.resolvePackageQualified(spec);
+  }
+
+  /**
+   * Symbol IDs of the graph (built on first use)
+   * @returns {SymbolIdIndex}
+   */
+  getSymbolIds() {
+    if (!this.symbolIds) {
+      this.symbolIds = new SymbolIdIndex(this.graph);
+    }
+    return this.symbolIds;
+  }
+
+  /**
+   * Resolve "pkg/calc.Calculator.Add" or "app.models.User" by trying each
+   * split into a path prefix (package, file without extension, or module
+   * path) and a qualified name, longest prefix first
+   * @param {string} spec
+   * @returns {Array<Object>}
+   */
+  resolvePackageQualified(spec) {
+    const pathEnd = spec.lastIndexOf('/') + 1;
+
+    for (let i = spec.length - 1; i > pathEnd; i--) {
+      if (spec[i] !== '.') continue;
+
+      const prefix = spec.slice(0, i);
+      const name = spec.slice(i + 1);
+      const files = this.filesUnder(prefix) || (pathEnd === 0 && this.filesUnder(prefix.replace(/\./g, '/')));
+      if (!files) continue;
+
+      const matches = matchSymbols(files, name).filter(symbol => symbol.qualifiedName === name);
+      if (matches.length > 0) return matches;
+    }
+
+    return [];
+  }
+
+  /**
+   * Files of the package or module a path prefix names
+   * @private
+   * @returns {Array<Object>|null}
+   */
+  filesUnder(prefix) {
+    const files = [...this.graph.files.values()].filter(candidate => {
+      const pkg = candidate.package;
+      return candidate.path.replace(/\.[^./]+$/, '') === prefix ||
+        candidate.path.replace(/\/__init__\.py$|\/mod\.rs$/, '') === prefix ||
+        pkg === prefix || pkg.endsWith(`/${prefix}`) || prefix.endsWith(`/${pkg}`);
+    });
+    return files.length > 0 ? files : null;
+  }
+
+  /**
+   * Expand a focus into the definitions it depends on
+   * @param {string|Array<Object>} focus - Focus spec or symbols from resolveFocus()
+   * @param {number} depth - Reference hops to follow (0 = focus only)
+   * @returns {Object} { focus, dependencies, files, depth, truncated }
+   */
+  expand(focus, depth = this.options.depth) {
+    const focusSymbols = typeof focus === 'string' ? this.resolveFocus(focus) : focus;
+    const selected = new Map();
+    let truncated = false;
+
+    for (const symbol of focusSymbols) {
+      selected.set(symbolKey(symbol), { symbol, depth: 0, via: null });
+    }
+
+    const add = (dependency, level, via) => {
+      if (selected.has(symbolKey(dependency)) || overlapsSelection(dependency, selected)) return false;
+      if (selected.size >= this.options.maxSymbols) {
+        truncated = true;
+        return false;
+      }
+      selected.set(symbolKey(dependency), { symbol: dependency, depth: level, via: via.qualifiedName });
+      return true;
+    };
+
+    let frontier = [...focusSymbols];
+    for (let level = 1; level <= depth && frontier.length > 0 && !truncated; level++) {
+      const next = [];
+      const containers = containerNames(selected);
+
+      for (const symbol of frontier) {
+        for (const dependency of this.referencesOf(symbol, containers)) {
+          if (add(dependency, level, symbol)) next.push(dependency);
+        }
+      }
+
+      frontier = next;
+    }
+
+    // Members called through variables (s.Get()) only match once their
+    // container is selected, which may happen a level later
+    let changed = depth > 0;
+    while (changed && !truncated) {
+      changed = false;
+      const containers = containerNames(selected);
+      for (const entry of [...selected.values()]) {
+        if (entry.depth >= depth) continue;
+        for (const dependency of this.referencesOf(entry.symbol, containers)) {
+          if (dependency.parent && add(dependency, entry.depth + 1, entry.symbol)) changed = true;
+        }
+      }
+    }
+
+    // Implementations sit one level below the interface they implement
+    if (this.options.implementations) {
+      for (const entry of [...selected.values()]) {
+        for (const { type, methods } of this.finder.find(entry.symbol)) {
+          for (const implementation of [type, ...methods]) {
+            add(implementation, entry.depth + 1, entry.symbol);
+          }
+        }
+      }
+    }
+
+    const entries = [...selected.values()];
+    logger.debug(`Expanded ${focusSymbols.length} focus symbols to ${entries.length} definitions`);
+
+    return {
+      focus: entries.filter(entry => entry.depth === 0),
+      dependencies: entries.filter(entry => entry.depth > 0).sort((a, b) => a.depth - b.depth),
+      files: groupByFile(entries),
+      depth,
+      truncated
+    };
+  }
+
+  /**
+   * Definitions referenced by a symbol's body
+   * Top-level symbols match by name; members also need their container
+   * named in the body, already selected, or shared with the symbol (this.x()).
+   * @param {Object} symbol
+   * @param {Set<string>} containers - Names of selected containers
+   * @returns {Array<Object>}
+   */
+  referencesOf(symbol, containers = new Set()) {
+    const file = this.graph.getFile(symbol.file);
+    if (!file) return [];
+
+    if (this.options.lsp?.handles(file.language)) {
+      const resolved = this.lspReferencesOf(symbol, file);
+      if (resolved) return resolved;
+    }
+
+    const body = file.content.split('\n').slice(symbol.startLine - 1, symbol.endLine).join('\n');
+    const identifiers = referencedIdentifiers(body, symbol.language);
+    const references = [];
+
+    for (const { local, symbol: candidate } of this.graph.visibleSymbols(file.path)) {
+      if (!identifiers.has(local) || isSameSymbol(candidate, symbol)) continue;
+
+      if (candidate.parent) {
+        const container = candidate.parent.split('.').pop();
+        if (!identifiers.has(container) && !containers.has(container) && candidate.parent !== symbol.parent) continue;
+      }
+
+      references.push(candidate);
+    }
+
+    return references.sort((a, b) => compareBytes(a.file, b.file) || a.startLine - b.startLine);
+  }
+
+  /**
+   * Definitions referenced by a symbol's body, as the language server resolves them
+   * Names that no graph symbol has are skipped; each name, or receiver.name
+   * selector, is looked up once at its first use.
+   * @private
+   * @returns {Array<Object>|null} References, or null when the server stopped answering
+   */
+  lspReferencesOf(symbol, file) {
+    const { lsp } = this.options;
+    const names = this.getSymbolNames();
+    if (!this.strippedLines) this.strippedLines = new Map();
+    if (!this.strippedLines.has(file.path)) {
+      this.strippedLines.set(file.path, stripNoise(file.content, file.language).split('\n'));
+    }
+    const lines = this.strippedLines.get(file.path);
+    const looked = new Set();
+    const references = new Map();
+
+    for (let index = symbol.startLine - 1; index < Math.min(symbol.endLine, lines.length); index++) {
+      const line = lines[index];
+      for (const match of line.matchAll(IDENTIFIER_PATTERN)) {
+        const name = match[0];
+        const receiver = RECEIVER_PATTERN.exec(line.slice(0, match.index))?.[1];
+        const key = receiver ? `${receiver}.${name}` : name;
+        if (!names.has(name) || looked.has(key)) continue;
+        looked.add(key);
+
+        const locations = lsp.definition(file.path, file.language, index + 1, match.index);
+        if (!locations) {
+          if (!lsp.handles(file.language)) return null;
+          continue;
+        }
+
+        for (const location of locations) {
+          const target = this.symbolAt(location.file, location.line, name);
+          const local = target && target.file === symbol.file &&
+            target.startLine >= symbol.startLine && target.endLine <= symbol.endLine;
+          if (target && !local) references.set(symbolKey(target), target);
+        }
+      }
+    }
+
+    return [...references.values()].sort((a, b) => compareBytes(a.file, b.file) || a.startLine - b.startLine);
+  }
+
+  /**
+   * Names of every symbol in the graph (built on first use)
+   * @private
+   * @returns {Set<string>}
+   */
+  getSymbolNames() {
+    if (!this.symbolNames) {
+      this.symbolNames = new Set([...this.graph.files.values()].flatMap(file => file.symbols.map(symbol => symbol.name)));
+    }
+    return this.symbolNames;
+  }
+
+  /**
+   * Innermost definition around a line, preferring one with the looked-up name
+   * @private
+   * @returns {Object|null}
+   */
+  symbolAt(filePath, line, name) {
+    const candidates = (this.graph.getFile(filePath)?.symbols || [])
+      .filter(symbol => symbol.kind !== SymbolKind.MODULE && symbol.startLine <= line && symbol.endLine >= line)
+      .sort((a, b) => (b.name === name) - (a.name === name) || (a.endLine - a.startLine) - (b.endLine - b.startLine));
+    return candidates[0] || null;
+  }
+
+  /**
+   * Format an expansion as a console report
+   * @param {Object} result - Result of expand()
+   * @returns {string}
+   */
+  static formatExpansion(result) {
+    const lines = [];
+    const location = symbol => `${symbol.file}:${symbol.startLine}`;
+
+    lines.push('');
+    lines.push('🧭 DEPENDENCY EXPANSION');
+    lines.push('='.repeat(80));
+    for (const { symbol } of result.focus) {
+      lines.push(`   Focus:        ${symbol.kind === SymbolKind.MODULE ? symbol.file : `${symbol.qualifiedName} (${location(symbol)})`}`);
+    }
+    lines.push(`   Depth:        ${result.depth}`);
+    lines.push(`   Dependencies: ${result.dependencies.length} definitions` + (result.truncated ? ' (limit reached)' : ''));
+    lines.push(`   Files:        ${result.files.length}`);
+
+    if (result.dependencies.length > 0) {
+      lines.push('');
+      for (const { symbol, depth, via } of result.dependencies) {
+        lines.push(`   ${'  '.repeat(depth - 1)}↳ ${symbol.kind} ${symbol.qualifiedName} (${location(symbol)}) ← ${via}`);
+      }
+    }
+
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Identifiers used in code, ignoring comments and string literals, plus
+ * qualified selectors such as `models.User`
+ * @param {string} text
+ * @param {string} language
+ * @returns {Set<string>}
+ */
+export function referencedIdentifiers(text, language) {
+  const code = stripNoise(text, language);
+  const identifiers = new Set(code.match(IDENTIFIER_PATTERN) || []);
+  for (const match of code.matchAll(SELECTOR_PATTERN)) {
+    identifiers.add(`${match[1]}.${match[2]}`);
+  }
+  return identifiers;
+}
+
+/**
+ * Best-ranked symbols named `name`: exact qualified name, then simple name,
+ * then qualified-name suffix
+ */
+function matchSymbols(files, name) {
+  const matches = [];
+  for (const candidate of files) {
+    for (const symbol of candidate.symbols) {
+      if (symbol.kind === SymbolKind.MODULE) continue;
+      const rank = symbol.qualifiedName === name ? 0
+        : symbol.name === name ? 1
+          : symbol.qualifiedName.endsWith(`.${name}`) ? 2
+            : -1;
+      if (rank !== -1) matches.push({ rank, symbol });
+    }
+  }
+
+  const best = Math.min(...matches.map(match => match.rank));
+  return matches.filter(match => match.rank === best).map(match => match.symbol);
+}
+
+function symbolKey(symbol) {
+  return `${symbol.file}#${symbol.qualifiedName}@${symbol.startLine}`;
+}
+
+function containerNames(selected) {
+  return new Set([...selected.values()]
+    .filter(({ symbol }) => CONTAINER_KINDS.has(symbol.kind))
+    .map(({ symbol }) => symbol.name));
+}
+
+function isSameSymbol(a, b) {
+  return a.file === b.file && a.startLine === b.startLine && a.qualifiedName === b.qualifiedName;
+}
+
+/**
+ * True when a candidate sits inside (or around) an already selected
+ * definition of the same file, which would duplicate lines
+ */
+function overlapsSelection(candidate, selected) {
+  for (const { symbol } of selected.values()) {
+    if (symbol.file !== candidate.file) continue;
+    const inside = candidate.startLine >= symbol.startLine && candidate.endLine <= symbol.endLine;
+    const around = candidate.startLine <= symbol.startLine && candidate.endLine >= symbol.endLine;
+    if (inside || around) return true;
+  }
+  return false;
+}
+
+/**
+ * Selected definitions per file, nearest to the focus first
+ * @returns {Array<{file: string, depth: number, symbols: Array<Object>}>}
+ */
+function groupByFile(entries) {
+  const files = new Map();
+
+  for (const { symbol, depth } of entries) {
+    if (!files.has(symbol.file)) {
+      files.set(symbol.file, { file: symbol.file, depth, symbols: [] });
+    }
+    const group = files.get(symbol.file);
+    group.depth = Math.min(group.depth, depth);
+    group.symbols.push(symbol);
+  }
+
+  return [...files.values()]
+    .map(group => ({ ...group, symbols: group.symbols.sort((a, b) => a.startLine - b.startLine) }))
+    .sort((a, b) => a.depth - b.depth || compareBytes(a.file, b.file));
+}
+
+/**
+ * Blank out comments and string literals so they do not count as references
+ * Lines and columns stay where they were, for language server positions.
+ */
+export function stripNoise(text, language) {
+  const blank = match => match.replace(/[^\n]/g, ' ');
+
+  if (language === 'python') {
+    return text
+      .replace(/("""|''')[\s\S]*?\1/g, blank)
+      .replace(/(["'])(?:\\.|(?!\1)[^\\\n])*\1/g, blank)
+      .replace(/#.*$/gm, blank);
+  }
+
+  // OpenAPI documents reference definitions only through $ref ("#/components/schemas/Pet")
+  if (language === 'openapi') {
+    const code = blank(text).split('');
+    for (const match of text.matchAll(/\$ref["']?\s*:\s*["']?([^"'\s,}]+)/g)) {
+      const name = match[1].split('/').pop();
+      code.splice(match.index + match[0].length - name.length, name.length, ...name);
+    }
+    return code.join('');
+  }
+
+  // Rust lifetimes ('a) look like char literals, so only strip double quotes there
+  const quotes = language === 'rust' ? /"(?:\\.|[^"\\\n])*"/g : /(["'`])(?:\\.|(?!\1)[^\\\n])*\1/g;
+  return text
+    .replace(/\/\*[\s\S]*?\*\//g, blank)
+    .replace(quotes, blank)
+    .replace(/\/\/.*$/gm, blank);
+}
+
+export default DependencyExpander;
@@ -0,0 +1,454 @@
+/**
+ * DependencyGraph - Package-level dependency graph
+ * v3.4.0 - Dependency-aware context expansion
+ *
+ * Responsibilities:
+ * - Parse imports with each language plugin and resolve them to project files
+ * - Group files into packages (directories for Go/Java, files elsewhere)
+ * - Record package-to-package import edges
+ * - Answer which symbols a file can reference (own package + imports)
+ */
+
+import path from 'path';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import ContentCache from '../cache/ContentCache.js';
+import { nativeFileSystem } from '../core/FileSystem.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('DependencyGraph');
+
+/**
+ * Lookup helpers handed to LanguagePlugin.resolveImport()
+ */
+export class ProjectIndex {
+  /**
+   * @param {string} root - Project root
+   * @param {Array<string>} relativePaths - '/'-separated project files
+   * @param {Object} [options] - { workspace: maps prefixed paths of a multi-repo
+   *   workspace to disk, revision: GitRevision files are read from,
+   *   reader: FileReader of working tree files }
+   */
+  constructor(root, relativePaths, options = {}) {
+    this.root = root;
+    this.workspace = options.workspace || null;
+    this.revision = options.revision || null;
+    this.reader = options.reader || nativeFileSystem;
+    this.files = new Set(relativePaths);
+    this.dirs = new Set(['.']);
+    this.contents = new Map();
+    this.memos = new Map();
+
+    for (const file of this.files) {
+      for (let dir = path.posix.dirname(file); dir !== '.'; dir = path.posix.dirname(dir)) {
+        this.dirs.add(dir);
+      }
+    }
+  }
+
+  /**
+   * @param {string} relativePath
+   * @returns {boolean}
+   */
+  hasFile(relativePath) {
+    return this.files.has(relativePath);
+  }
+
+  /**
+   * @param {string} relativePath
+   * @returns {boolean}
+   */
+  hasDir(relativePath) {
+    return this.dirs.has(relativePath);
+  }
+
+  /**
+   * Shortest project path equal to or ending in `/suffix`
+   * @param {string} suffix - e.g. 'com/acme/User.java'
+   * @param {string} kind - 'file' or 'dir'
+   * @returns {string|null}
+   */
+  findBySuffix(suffix, kind = 'file') {
+    let best = null;
+    for (const entry of kind === 'dir' ? this.dirs : this.files) {
+      if ((entry === suffix || entry.endsWith(`/${suffix}`)) && (!best || entry.length < best.length)) {
+        best = entry;
+      }
+    }
+    return best;
+  }
+
+  /**
+   * File on disk of a project path
+   * @param {string} relativePath
+   * @returns {string|null} Null for paths outside the workspace repositories
+   */
+  absolutePath(relativePath) {
+    return this.workspace ? this.workspace.absolutePath(relativePath) : path.join(this.root, relativePath);
+  }
+
+  /**
+   * Project path of a file on disk
+   * @param {string} filePath - Absolute path
+   * @returns {string|null} '/'-separated path, or null outside the project
+   */
+  relativePath(filePath) {
+    const relativePath = this.workspace ? this.workspace.relativePath(filePath) : path.relative(this.root, filePath);
+    if (relativePath === null || relativePath.startsWith('..') || path.isAbsolute(relativePath)) return null;
+    return relativePath.split(path.sep).join('/') || '.';
+  }
+
+  /**
+   * Read any project file (including ones that were not analyzed, e.g. go.mod)
+   * @param {string} relativePath
+   * @returns {string|null} Content, or null when missing
+   */
+  readFile(relativePath) {
+    if (!this.contents.has(relativePath)) {
+      let content = null;
+      try {
+        const filePath = this.absolutePath(relativePath);
+        content = filePath === null ? null : this.read(filePath);
+      } catch (error) {
+        content = null;
+      }
+      this.contents.set(relativePath, content);
+    }
+    return this.contents.get(relativePath);
+  }
+
+  /**
+   * @param {string} filePath - Absolute path
+   * @returns {string}
+   */
+  read(filePath) {
+    return this.revision ? this.revision.read(filePath) : this.reader.readText(filePath);
+  }
+
+  /**
+   * Compute a project-wide value once per build (e.g. `go list` output)
+   * @param {string} key
+   * @param {Function} compute - () => value
+   * @returns {*}
+   */
+  memo(key, compute) {
+    if (!this.memos.has(key)) {
+      this.memos.set(key, compute());
+    }
+    return this.memos.get(key);
+  }
+}
+
+export class DependencyGraph {
+  constructor(options = {}) {
+    this.options = {
+      root: process.cwd(),
+      symbolBackend: 'heuristic', // auto | tree-sitter | heuristic
+      extractor: null, // Initialized SymbolExtractor to share
+      cache: null, // ContentCache for symbol outlines
+      workspace: null, // Workspace whose prefixed paths the files use
+      revision: null, // GitRevision to read files from instead of the working tree
+      reader: null, // FileReader of working tree files; FileSystem.readText when not given
+      onError: null, // (relativePath, error) for files that fail to parse; build() throws without it
+      ...options
+    };
+
+    this.extractor = this.options.extractor || new SymbolExtractor({ backend: this.options.symbolBackend });
+    this.files = new Map();
+    this.packages = new Map();
+    this.visible = new Map();
+  }
+
+  /**
+   * Initialize the extractor (tree-sitter when installed) and build
+   * @param {Array<Object>} files - Same as build()
+   * @param {Object} options - Same as constructor
+   * @returns {Promise<DependencyGraph>}
+   */
+  static async create(files, options = {}) {
+    const graph = new DependencyGraph(options);
+    await graph.extractor.initialize();
+    return graph.build(files);
+  }
+
+  /**
+   * Build the graph; files without a language plugin are skipped, and so
+   * are files that fail to parse when options.onError is set
+   * @param {Array<{relativePath: string, path?: string, content?: string, language?: string}>} files -
+   *   language: what LanguageDetector found in an extension-less script
+   * @returns {DependencyGraph}
+   */
+  build(files) {
+    const supported = files.filter(fileInfo => this.extractor.supports(fileInfo.relativePath) ||
+      this.extractor.supportsLanguage(fileInfo.language));
+    const project = new ProjectIndex(this.options.root, supported.map(fileInfo => toPosix(fileInfo.relativePath)), {
+      workspace: this.options.workspace,
+      revision: this.options.revision,
+      reader: this.options.reader
+    });
+
+    for (const fileInfo of supported) {
+      try {
+        this.addFile(fileInfo, project);
+      } catch (error) {
+        if (!this.options.onError) throw error;
+        // The file stays out of the graph; the others are still linked
+        this.options.onError(toPosix(fileInfo.relativePath), error);
+      }
+    }
+    this.link(project);
+
+    if (this.options.cache) {
+      this.options.cache.save();
+    }
+
+    const stats = this.getStats();
+    logger.debug(`Dependency graph: ${stats.files} files, ${stats.packages} packages, ${stats.edges} edges`);
+    return this;
+  }
+
+  /**
+   * Parse one file's symbols and imports
+   * @private
+   */
+  addFile(fileInfo, project) {
+    const relativePath = toPosix(fileInfo.relativePath);
+    let content = fileInfo.content;
+    if (content === undefined) {
+      try {
+        content = project.read(fileInfo.path || project.absolutePath(relativePath));
+      } catch (error) {
+        logger.debug(`Skipping ${relativePath}: ${error.message}`);
+        return;
+      }
+    }
+    project.contents.set(relativePath, content);
+
+    const plugin = this.extractor.getPlugin(relativePath, content);
+    if (!plugin) return;
+    const packageId = plugin.getPackageId(relativePath);
+
+    this.files.set(relativePath, {
+      path: relativePath,
+      language: plugin.getLanguageId(relativePath),
+      package: packageId,
+      content,
+      plugin,
+      symbols: this.extractSymbols(content, relativePath),
+      imports: plugin.extractImports(content, relativePath).map(imported => ({
+        ...imported,
+        target: null,
+        package: null
+      }))
+    });
+
+    if (!this.packages.has(packageId)) {
+      this.packages.set(packageId, {
+        id: packageId,
+        language: plugin.name,
+        files: [],
+        dependencies: new Set(),
+        dependents: new Set()
+      });
+    }
+    this.packages.get(packageId).files.push(relativePath);
+  }
+
+  /**
+   * Resolve imports and record package edges
+   * @private
+   */
+  link(project) {
+    for (const file of this.files.values()) {
+      for (const imported of file.imports) {
+        const target = file.plugin.resolveImport(imported, file.path, project);
+        if (!target || target === file.path) continue;
+
+        const packageId = project.hasFile(target)
+          ? this.extractor.getPlugin(target, project.contents.get(target) ?? null)?.getPackageId(target)
+          : target;
+        if (!packageId || !this.packages.has(packageId)) continue;
+
+        imported.target = target;
+        imported.package = packageId;
+        imported.packageName = file.plugin.getPackageName(this.packageSymbols(packageId, true));
+
+        if (packageId !== file.package) {
+          this.packages.get(file.package).dependencies.add(packageId);
+          this.packages.get(packageId).dependents.add(file.package);
+        }
+      }
+    }
+  }
+
+  /**
+   * Extract symbols, reusing outlines of unchanged content
+   * @private
+   */
+  extractSymbols(content, relativePath) {
+    const cache = this.options.cache;
+    if (!cache) {
+      return this.extractor.extract(content, relativePath);
+    }
+
+    return cache.symbols(
+      ContentCache.hash(content),
+      ContentCache.key(this.extractor.getBackend(relativePath, content), relativePath),
+      relativePath,
+      () => this.extractor.extract(content, relativePath)
+    );
+  }
+
+  /**
+   * @param {string} relativePath
+   * @returns {Object|null} File node { path, language, package, content, symbols, imports }
+   */
+  getFile(relativePath) {
+    return this.files.get(toPosix(relativePath)) || null;
+  }
+
+  /**
+   * @param {string} packageId
+   * @returns {Object|null} Package node { id, language, files, dependencies, dependents }
+   */
+  getPackage(packageId) {
+    return this.packages.get(packageId) || null;
+  }
+
+  /**
+   * Packages imported by a package
+   * @param {string} packageId
+   * @returns {Array<string>}
+   */
+  getDependencies(packageId) {
+    return [...(this.packages.get(packageId)?.dependencies || [])].sort(compareBytes);
+  }
+
+  /**
+   * Packages importing a package
+   * @param {string} packageId
+   * @returns {Array<string>}
+   */
+  getDependents(packageId) {
+    return [...(this.packages.get(packageId)?.dependents || [])].sort(compareBytes);
+  }
+
+  /**
+   * Symbols a file can reference, keyed by the local name used in its code
+   * Own-package symbols are all visible; imported packages expose exported
+   * symbols, narrowed to the imported names when those name real symbols
+   * (otherwise the name is a sub-module and the whole package is visible).
+   * Languages that qualify imported names (Go) key them as `pkg.Name`.
+   * @param {string} relativePath
+   * @returns {Array<{local: string, symbol: Object}>}
+   */
+  visibleSymbols(relativePath) {
+    const file = this.getFile(relativePath);
+    if (!file) return [];
+    if (this.visible.has(file.path)) return this.visible.get(file.path);
+
+    const result = [];
+    const seen = new Set();
+    const add = (local, symbol) => {
+      const key = `${local}\0${symbol.file}\0${symbol.qualifiedName}\0${symbol.startLine}`;
+      if (!seen.has(key)) {
+        seen.add(key);
+        result.push({ local, symbol });
+      }
+    };
+
+    for (const symbol of this.packageSymbols(file.package)) {
+      add(symbol.name, symbol);
+    }
+
+    for (const imported of file.imports) {
+      if (!imported.package || imported.package === file.package) continue;
+
+      const exported = this.packageSymbols(imported.package).filter(symbol => symbol.exported);
+      const names = imported.names && exported.some(symbol => imported.names.includes(topLevelName(symbol)))
+        ? new Set(imported.names)
+        : null;
+      const localNames = invert(imported.aliases || {});
+      const qualifier = file.plugin.getImportQualifier(imported);
+      if (qualifier === false) continue;
+
+      for (const symbol of exported) {
+        const top = topLevelName(symbol);
+        if (names && !names.has(top)) continue;
+        if (symbol.parent) {
+          add(symbol.name, symbol);
+        } else {
+          add(qualifier ? `${qualifier}.${top}` : (localNames[top] || top), symbol);
+        }
+      }
+    }
+
+    this.visible.set(file.path, result);
+    return result;
+  }
+
+  /**
+   * @private
+   */
+  packageSymbols(packageId, modules = false) {
+    const node = this.packages.get(packageId);
+    if (!node) return [];
+    return node.files.flatMap(file => this.files.get(file).symbols.filter(symbol => modules || symbol.kind !== SymbolKind.MODULE));
+  }
+
+  /**
+   * @returns {{files: number, packages: number, edges: number, imports: number, resolved: number}}
+   */
+  getStats() {
+    let imports = 0;
+    let resolved = 0;
+    for (const file of this.files.values()) {
+      imports += file.imports.length;
+      resolved += file.imports.filter(imported => imported.target).length;
+    }
+
+    return {
+      files: this.files.size,
+      packages: this.packages.size,
+      edges: [...this.packages.values()].reduce((sum, node) => sum + node.dependencies.size, 0),
+      imports,
+      resolved
+    };
+  }
+
+  /**
+   * Serializable package graph
+   * @returns {Object}
+   */
+  toJSON() {
+    return {
+      packages: [...this.packages.values()]
+        .sort((a, b) => compareBytes(a.id, b.id))
+        .map(node => ({
+          id: node.id,
+          language: node.language,
+          files: [...node.files].sort(compareBytes),
+          dependencies: this.getDependencies(node.id)
+        })),
+      stats: this.getStats()
+    };
+  }
+}
+
+/**
+ * Outermost container name of a symbol (`User` for `User.save`)
+ */
+function topLevelName(symbol) {
+  return symbol.parent ? symbol.qualifiedName.split('.')[0] : symbol.name;
+}
+
+function invert(aliases) {
+  return Object.fromEntries(Object.entries(aliases).map(([local, imported]) => [imported, local]));
+}
+
+function toPosix(relativePath) {
+  return relativePath.split(path.sep).join('/');
+}
+
+export default DependencyGraph;
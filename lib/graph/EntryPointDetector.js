@@ -0,0 +1,167 @@
+/**
+ * EntryPointDetector - Where a project starts running
+ * v3.4.0 - Entry-point detection (--entry-points, the entry priority signal)
+ *
+ * Responsibilities:
+ * - Find likely entry points by convention: main functions and packages,
+ *   scripts run directly, package.json main and bin, HTTP route
+ *   registrations, CLI command definitions and scheduled jobs
+ * - Extend them by one import to the files they use directly
+ * - Render the detected entry points for the report
+ *
+ * Heuristics read source lines only; comments and test files are skipped.
+ */
+
+import path from 'path';
+import TestPairing, { TEST_DIRS } from '../core/TestPairing.js';
+
+export const ENTRY_KINDS = ['main', 'http', 'cli', 'cron'];
+
+// [kind, extensions, pattern] checked line by line
+const LINE_RULES = [
+  ['main', ['.py'], /^if\s+__name__\s*==\s*['"]__main__['"]\s*:/],
+  ['main', ['.rs'], /^(?:pub\s+)?(?:async\s+)?fn\s+main\s*\(/],
+  ['main', ['.java', '.cs', '.scala'], /\bstatic\s+(?:async\s+)?[\w<>[\]]+\s+[Mm]ain\s*\(/],
+  ['main', ['.kt'], /^fun\s+main\s*\(/],
+  ['main', ['.c', '.cc', '.cpp'], /^int\s+main\s*\(/],
+  ['http', ['.js', '.mjs', '.cjs', '.ts'], /\b(?:app|router|server|api|fastify)\.(?:get|post|put|patch|delete|all|route|use)\(\s*['"`]\//],
+  ['http', ['.py'], /^\s*@\w+\.(?:route|get|post|put|patch|delete)\(\s*['"]\//],
+  ['http', ['.go'], /\.(?:HandleFunc|Handle|GET|POST|PUT|PATCH|DELETE)\(\s*"\//],
+  ['http', ['.java', '.kt'], /@(?:Get|Post|Put|Patch|Delete|Request)Mapping\b/],
+  ['http', ['.cs'], /\[Http(?:Get|Post|Put|Patch|Delete)\b|\.Map(?:Get|Post|Put|Patch|Delete)\(\s*"\//],
+  ['http', ['.php'], /\bRoute::(?:get|post|put|patch|delete|any|resource)\(/],
+  ['cli', ['.js', '.mjs', '.cjs', '.ts'], /\.command\(\s*['"`]|from\s+['"](?:commander|yargs(?:\/\w+)?|cac|meow)['"]|require\(\s*['"](?:commander|yargs)['"]\s*\)/],
+  ['cli', ['.py'], /\bargparse\.ArgumentParser\(|^\s*@click\.(?:command|group)\b|\btyper\.Typer\(/],
+  ['cli', ['.go'], /&cobra\.Command\{|&cli\.App\{|\bflag\.Parse\(\)/],
+  ['cli', ['.rs'], /#\[derive\([^)]*\bParser\b/],
+  ['cli', ['.java', '.kt'], /@Command\(\s*name\s*=/],
+  ['cron', ['.js', '.mjs', '.cjs', '.ts'], /\bcron\.schedule\(|\bnew\s+CronJob\(|\bschedule\.scheduleJob\(/],
+  ['cron', ['.py'], /\bschedule\.every\(|\.add_job\(|^\s*@\w+\.(?:periodic_task|scheduled_job)\b|\bcrontab\(/],
+  ['cron', ['.go'], /\bcron\.New\(|\.AddFunc\(\s*"/],
+  ['cron', ['.java', '.kt'], /@Scheduled\(/],
+  ['cron', ['.yml', '.yaml'], /^\s*-?\s*cron:\s*['"]|^kind:\s*CronJob\b/]
+];
+
+export class EntryPointDetector {
+  /**
+   * Likely entry points among files
+   * @param {Array<{path: string, content: string}>} files - '/'-separated project paths
+   * @returns {Array<{file: string, kinds: Array<string>, line: number}>} In path order;
+   *   line of the first match (1 for package.json entries)
+   */
+  detect(files) {
+    const found = new Map();
+    const add = (file, kind, line) => {
+      if (!found.has(file)) found.set(file, { file, kinds: [], line });
+      const entry = found.get(file);
+      if (!entry.kinds.includes(kind)) entry.kinds.push(kind);
+      entry.line = Math.min(entry.line, line);
+    };
+
+    const paths = new Set(files.map(file => file.path));
+    for (const { path: file, content } of files) {
+      if (path.posix.basename(file) === 'package.json') {
+        for (const target of packageEntries(file, content)) {
+          if (paths.has(target)) add(target, 'main', 1);
+        }
+        continue;
+      }
+      if (TestPairing.isTestFile(file) || file.split('/').some(dir => [...TEST_DIRS, 'examples'].includes(dir))) {
+        continue;
+      }
+      for (const { kind, line } of matches(file, content)) {
+        add(file, kind, line);
+      }
+    }
+
+    return [...found.values()]
+      .map(entry => ({ ...entry, kinds: ENTRY_KINDS.filter(kind => entry.kinds.includes(kind)) }))
+      .sort((a, b) => (a.file < b.file ? -1 : a.file > b.file ? 1 : 0));
+  }
+
+  /**
+   * Entry points and the project files they import directly
+   * @param {DependencyGraph} graph
+   * @param {Array<{file: string, kinds: Array<string>}>} entryPoints - From detect()
+   * @returns {Map<string, {kinds: Array<string>}|{importedBy: string}>} Keyed by path
+   */
+  static withDependencies(graph, entryPoints) {
+    const reached = new Map(entryPoints.map(entry => [entry.file, { kinds: entry.kinds }]));
+    for (const entry of entryPoints) {
+      for (const imported of graph.files.get(entry.file)?.imports || []) {
+        if (!imported.target) continue;
+        const targets = graph.files.has(imported.target)
+          ? [imported.target]
+          : graph.getPackage(imported.package)?.files || [];
+        for (const target of targets) {
+          if (!reached.has(target)) reached.set(target, { importedBy: entry.file });
+        }
+      }
+    }
+    return reached;
+  }
+
+  /**
+   * @param {Array} entryPoints - From detect()
+   * @returns {string}
+   */
+  static format(entryPoints) {
+    const lines = ['', '🚪 ENTRY POINTS', '='.repeat(80)];
+    for (const { file, kinds, line } of entryPoints) {
+      lines.push(`   ${kinds.join(', ').padEnd(16)} ${file}:${line}`);
+    }
+    if (entryPoints.length === 0) {
+      lines.push('   No entry points detected');
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Files named by package.json main and bin, relative to the project
+ * @private
+ */
+function packageEntries(manifestPath, content) {
+  let manifest;
+  try {
+    manifest = JSON.parse(content);
+  } catch {
+    return [];
+  }
+  const bins = typeof manifest.bin === 'string' ? [manifest.bin] : Object.values(manifest.bin || {});
+  const dir = path.posix.dirname(manifestPath);
+  return [manifest.main, ...bins]
+    .filter(target => typeof target === 'string')
+    .map(target => path.posix.normalize(path.posix.join(dir, target)));
+}
+
+/**
+ * Entry-point rules matching a file's lines
+ * @private
+ */
+function matches(file, content) {
+  const extension = path.posix.extname(file).toLowerCase();
+  const rules = LINE_RULES.filter(([, extensions]) => extensions.includes(extension));
+  const lines = content.split('\n');
+  const found = [];
+
+  // Scripts run directly, and Go's main package
+  if (/^#!.*\b(?:node|python3?|deno|bun)\b/.test(lines[0])) {
+    found.push({ kind: 'main', line: 1 });
+  }
+  if (extension === '.go' && /^package\s+main\b/m.test(content)) {
+    const line = lines.findIndex(text => /^func\s+main\s*\(\s*\)/.test(text));
+    if (line !== -1) found.push({ kind: 'main', line: line + 1 });
+  }
+
+  lines.forEach((text, index) => {
+    const trimmed = text.trim();
+    if (/^(?:\/\/|\/\*|\*|# )/.test(trimmed)) return;
+    for (const [kind, , pattern] of rules) {
+      if (pattern.test(text)) found.push({ kind, line: index + 1 });
+    }
+  });
+  return found;
+}
+
+export default EntryPointDetector;
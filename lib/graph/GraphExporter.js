@@ -0,0 +1,336 @@
+/**
+ * GraphExporter - Call graph and type dependency graph export
+ * v3.4.0 - Graph visualization
+ *
+ * Responsibilities:
+ * - Derive function call edges and type dependency edges (uses, implements)
+ *   from the dependency graph
+ * - Mark what a context selection (--focus, --symbol) included and why
+ * - Render the graphs as Graphviz DOT or Mermaid flowcharts, clustered by file
+ */
+
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { DependencyExpander } from './DependencyExpander.js';
+import { ImplementationFinder, INTERFACE_KINDS } from './ImplementationFinder.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('GraphExporter');
+
+export const GRAPH_FORMATS = ['mermaid', 'dot'];
+
+export const GraphKind = Object.freeze({
+  CALLS: 'calls', // Function and method calls
+  TYPES: 'types' // Types referencing or implementing other types
+});
+
+export const EdgeKind = Object.freeze({
+  CALLS: 'calls',
+  USES: 'uses',
+  IMPLEMENTS: 'implements',
+  REFERENCES: 'references' // Selection edge between a function and a type
+});
+
+const CALLABLE_KINDS = new Set([SymbolKind.FUNCTION, SymbolKind.METHOD]);
+
+const TYPE_KINDS = new Set([
+  SymbolKind.CLASS, SymbolKind.STRUCT, SymbolKind.INTERFACE,
+  SymbolKind.TRAIT, SymbolKind.ENUM, SymbolKind.TYPE
+]);
+
+export class GraphExporter {
+  /**
+   * @param {DependencyGraph} graph - Built graph
+   * @param {Object} options
+   */
+  constructor(graph, options = {}) {
+    this.graph = graph;
+    this.options = {
+      kinds: [GraphKind.CALLS, GraphKind.TYPES],
+      // [{symbol, reason, via}] from a context selection; null = whole project
+      selection: null,
+      lsp: null, // LspGateway for call edges of the languages it handles
+      ...options
+    };
+    this.expander = new DependencyExpander(graph, { lsp: this.options.lsp });
+    this.finder = new ImplementationFinder(graph);
+  }
+
+  /**
+   * Nodes and edges of the requested graphs
+   * With a selection, only selected symbols and their direct neighbors are
+   * kept; neighbors are marked as not included.
+   * @returns {{nodes: Array<Object>, edges: Array<Object>}}
+   *   nodes: { id, symbol, included, reason, via }
+   *   edges: { from, to, kind, label, followed } (from/to are node ids)
+   */
+  build() {
+    const symbols = [...this.graph.files.values()]
+      .flatMap(file => file.symbols)
+      .filter(symbol => this.isGraphed(symbol))
+      .sort((a, b) => compareBytes(a.file, b.file) || a.startLine - b.startLine);
+    const edges = this.collectEdges(symbols);
+
+    const selection = this.options.selection
+      ? new Map(this.options.selection.map(entry => [symbolKey(entry.symbol), entry]))
+      : null;
+
+    const kept = new Set(selection ? [] : symbols.map(symbolKey));
+    if (selection) {
+      for (const edge of edges) {
+        if (selection.has(edge.from) || selection.has(edge.to)) {
+          kept.add(edge.from);
+          kept.add(edge.to);
+        }
+      }
+      for (const key of selection.keys()) kept.add(key);
+    }
+
+    const nodes = symbols
+      .filter(symbol => kept.has(symbolKey(symbol)))
+      .map((symbol, index) => {
+        const entry = selection?.get(symbolKey(symbol));
+        return {
+          id: `n${index + 1}`,
+          key: symbolKey(symbol),
+          symbol,
+          included: !selection || Boolean(entry),
+          reason: entry?.reason || null,
+          via: entry?.via || null
+        };
+      });
+
+    const byKey = new Map(nodes.map(node => [node.key, node]));
+    const keptEdges = edges
+      .filter(edge => byKey.has(edge.from) && byKey.has(edge.to))
+      .filter(edge => !selection || byKey.get(edge.from).included || byKey.get(edge.to).included)
+      .map(edge => {
+        const from = byKey.get(edge.from);
+        const to = byKey.get(edge.to);
+        return {
+          ...edge,
+          from: from.id,
+          to: to.id,
+          // The selection reached `to` through this edge
+          followed: Boolean(selection) && to.included && to.via === from.symbol.qualifiedName
+        };
+      });
+
+    // Definitions reached through a reference the graphs do not draw
+    // (a method using a type) get an edge from the symbol that pulled them in
+    for (const node of nodes) {
+      if (!node.included || !node.via) continue;
+      const source = nodes.find(candidate => candidate.included && candidate.symbol.qualifiedName === node.via);
+      if (source && !keptEdges.some(edge => edge.from === source.id && edge.to === node.id)) {
+        keptEdges.push({ from: source.id, to: node.id, kind: EdgeKind.REFERENCES, label: EdgeKind.REFERENCES, followed: true });
+      }
+    }
+
+    logger.debug(`Graph: ${nodes.length} nodes, ${keptEdges.length} edges`);
+    return { nodes, edges: keptEdges };
+  }
+
+  /**
+   * Render the graphs
+   * @param {string} format - mermaid or dot
+   * @returns {string}
+   */
+  export(format = 'mermaid') {
+    if (!GRAPH_FORMATS.includes(format)) {
+      throw new Error(`Unsupported graph format: ${format} (expected ${GRAPH_FORMATS.join(' or ')})`);
+    }
+    const model = this.build();
+    return format === 'dot' ? GraphExporter.toDot(model) : GraphExporter.toMermaid(model);
+  }
+
+  /**
+   * Graphviz DOT, one cluster per file
+   * @param {{nodes: Array<Object>, edges: Array<Object>}} model - Result of build()
+   * @returns {string}
+   */
+  static toDot({ nodes, edges }) {
+    const lines = [
+      'digraph ctxman {',
+      '  rankdir=LR;',
+      '  node [shape=box, fontname="Helvetica", fontsize=10];',
+      '  edge [fontname="Helvetica", fontsize=9];'
+    ];
+
+    groupByFile(nodes).forEach(([file, fileNodes], index) => {
+      lines.push(`  subgraph cluster_${index} {`);
+      lines.push(`    label="${dotEscape(file)}";`);
+      lines.push('    style=rounded; color="#999999";');
+      for (const node of fileNodes) {
+        const style = !node.included ? ', style=dashed, fontcolor="#888888", color="#888888"'
+          : node.reason ? ', style="filled,bold", fillcolor="#d4edda"'
+            : '';
+        lines.push(`    ${node.id} [label="${dotEscape(nodeLabel(node))}"${style}];`);
+      }
+      lines.push('  }');
+    });
+
+    for (const edge of edges) {
+      const style = edge.followed ? ', style=bold, color="#28a745"'
+        : edge.kind === EdgeKind.IMPLEMENTS ? ', style=dashed, arrowhead=empty'
+          : '';
+      lines.push(`  ${edge.from} -> ${edge.to} [label="${dotEscape(edge.label)}"${style}];`);
+    }
+
+    lines.push('}');
+    return lines.join('\n') + '\n';
+  }
+
+  /**
+   * Mermaid flowchart, one subgraph per file
+   * @param {{nodes: Array<Object>, edges: Array<Object>}} model - Result of build()
+   * @returns {string}
+   */
+  static toMermaid({ nodes, edges }) {
+    const lines = ['flowchart LR'];
+
+    groupByFile(nodes).forEach(([file, fileNodes], index) => {
+      lines.push(`  subgraph f${index}["${mermaidEscape(file)}"]`);
+      for (const node of fileNodes) {
+        lines.push(`    ${node.id}["${mermaidEscape(nodeLabel(node)).replace(/\n/g, '<br/>')}"]`);
+      }
+      lines.push('  end');
+    });
+
+    const followed = [];
+    edges.forEach((edge, index) => {
+      const arrow = edge.kind === EdgeKind.IMPLEMENTS ? '-.->' : edge.followed ? '==>' : '-->';
+      lines.push(`  ${edge.from} ${arrow}|"${mermaidEscape(edge.label)}"| ${edge.to}`);
+      if (edge.followed) followed.push(index);
+    });
+
+    const selected = nodes.filter(node => node.included && node.reason).map(node => node.id);
+    const excluded = nodes.filter(node => !node.included).map(node => node.id);
+    if (selected.length > 0 || excluded.length > 0) {
+      lines.push('  classDef included fill:#d4edda,stroke:#28a745,stroke-width:2px;');
+      lines.push('  classDef excluded stroke-dasharray:5 5,color:#888888;');
+      if (selected.length > 0) lines.push(`  class ${selected.join(',')} included;`);
+      if (excluded.length > 0) lines.push(`  class ${excluded.join(',')} excluded;`);
+    }
+    if (followed.length > 0) {
+      lines.push(`  linkStyle ${followed.join(',')} stroke:#28a745;`);
+    }
+
+    return lines.join('\n') + '\n';
+  }
+
+  /**
+   * Selection entries for the definitions a DependencyExpander expansion kept
+   * @param {Object} expansion - Result of DependencyExpander.expand()
+   * @param {string} focusReason - Reason shown on depth-0 symbols
+   * @returns {Array<{symbol: Object, reason: string, via: string|null}>}
+   */
+  static selectionFromExpansion(expansion, focusReason = 'focus') {
+    return [...expansion.focus, ...expansion.dependencies].map(({ symbol, depth, via }) => ({
+      symbol,
+      reason: depth === 0 ? focusReason : `depth ${depth}`,
+      via
+    }));
+  }
+
+  /**
+   * Selection entries for the ranges a SymbolSlicer slice kept
+   * @param {Object} slice - Result of SymbolSlicer.slice()
+   * @param {DependencyGraph} graph
+   * @returns {Array<{symbol: Object, reason: string, via: null}>}
+   */
+  static selectionFromSlice(slice, graph) {
+    return slice.files.flatMap(({ file, ranges }) => ranges.flatMap(range => {
+      const symbol = graph.getFile(file)?.symbols
+        .find(candidate => candidate.qualifiedName === range.name && candidate.startLine === range.startLine);
+      return symbol ? [{ symbol, reason: range.role, via: null }] : [];
+    }));
+  }
+
+  /**
+   * @private
+   */
+  isGraphed(symbol) {
+    return (this.options.kinds.includes(GraphKind.CALLS) && CALLABLE_KINDS.has(symbol.kind)) ||
+      (this.options.kinds.includes(GraphKind.TYPES) && TYPE_KINDS.has(symbol.kind));
+  }
+
+  /**
+   * Edges between graphed symbols, keyed by symbol
+   * @private
+   */
+  collectEdges(symbols) {
+    const edges = [];
+    const seen = new Set();
+    const add = (from, to, kind, label = kind) => {
+      const id = `${symbolKey(from)}>${symbolKey(to)}>${kind}`;
+      if (symbolKey(from) === symbolKey(to) || seen.has(id)) return;
+      seen.add(id);
+      edges.push({ from: symbolKey(from), to: symbolKey(to), kind, label });
+    };
+
+    if (this.options.kinds.includes(GraphKind.CALLS)) {
+      for (const symbol of symbols.filter(s => CALLABLE_KINDS.has(s.kind))) {
+        for (const callee of this.expander.referencesOf(symbol)) {
+          if (CALLABLE_KINDS.has(callee.kind)) add(symbol, callee, EdgeKind.CALLS);
+        }
+      }
+    }
+
+    if (this.options.kinds.includes(GraphKind.TYPES)) {
+      for (const type of symbols.filter(s => TYPE_KINDS.has(s.kind))) {
+        // A type depends on what its body and its methods reference
+        for (const part of [type, ...this.methodsOf(type)]) {
+          for (const used of this.expander.referencesOf(part)) {
+            if (TYPE_KINDS.has(used.kind)) add(type, used, EdgeKind.USES);
+          }
+        }
+        if (INTERFACE_KINDS.has(type.kind)) {
+          for (const { type: implementation, via } of this.finder.find(type)) {
+            add(implementation, type, EdgeKind.IMPLEMENTS, `${EdgeKind.IMPLEMENTS} (${via})`);
+          }
+        }
+      }
+    }
+
+    return edges;
+  }
+
+  /**
+   * Methods of a type across the files of its package
+   * @private
+   */
+  methodsOf(type) {
+    const packageId = this.graph.getFile(type.file)?.package;
+    const files = this.graph.getPackage(packageId)?.files || [type.file];
+    return files.flatMap(file => this.graph.getFile(file).symbols)
+      .filter(symbol => symbol.parent === type.qualifiedName && symbol.kind === SymbolKind.METHOD);
+  }
+}
+
+function nodeLabel(node) {
+  const detail = node.reason ? `${node.symbol.kind} · ${node.reason}` : node.symbol.kind;
+  return `${node.symbol.qualifiedName}\n${detail}`;
+}
+
+function groupByFile(nodes) {
+  const files = new Map();
+  for (const node of nodes) {
+    if (!files.has(node.symbol.file)) files.set(node.symbol.file, []);
+    files.get(node.symbol.file).push(node);
+  }
+  return [...files.entries()];
+}
+
+function dotEscape(text) {
+  return text.replace(/\\/g, '\\\\').replace(/"/g, '\\"').replace(/\n/g, '\\n');
+}
+
+function mermaidEscape(text) {
+  return text.replace(/"/g, '#quot;').replace(/</g, '#lt;').replace(/>/g, '#gt;');
+}
+
+function symbolKey(symbol) {
+  return `${symbol.file}#${symbol.qualifiedName}@${symbol.startLine}`;
+}
+
+export default GraphExporter;
@@ -0,0 +1,180 @@
+/**
+ * ImplementationFinder - Concrete types implementing an interface
+ * v3.4.0 - Interface implementation discovery
+ *
+ * Responsibilities:
+ * - Collect the method set of every type across the files of its package
+ * - Match declared implementations (implements clauses, Rust trait impls)
+ * - Match structurally where the language does (Go): the type's method set
+ *   covers the interface's methods, compared by name
+ * - Follow interface inheritance and embedding in both directions
+ */
+
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('ImplementationFinder');
+
+export const INTERFACE_KINDS = new Set([SymbolKind.INTERFACE, SymbolKind.TRAIT]);
+
+const TYPE_KINDS = new Set([SymbolKind.CLASS, SymbolKind.STRUCT, SymbolKind.ENUM, SymbolKind.TYPE]);
+
+export const ImplementationMatch = Object.freeze({
+  DECLARED: 'declared', // implements clause or trait impl
+  STRUCTURAL: 'structural' // method set covers the interface
+});
+
+export class ImplementationFinder {
+  /**
+   * @param {DependencyGraph} graph - Built graph
+   */
+  constructor(graph) {
+    this.graph = graph;
+    this.types = null;
+  }
+
+  /**
+   * Types implementing an interface, across the whole graph
+   * @param {Object} iface - Interface or trait symbol
+   * @returns {Array<{type: Object, via: string, methods: Array<Object>}>}
+   *   methods are the type's members that implement the interface
+   */
+  find(iface) {
+    if (!INTERFACE_KINDS.has(iface.kind)) return [];
+
+    const types = this.index();
+    const target = types.get(typeKey(this.packageOf(iface), iface.qualifiedName));
+    if (!target) return [];
+
+    const names = this.interfaceNames(target);
+    const required = this.requiredMethods(target, new Set());
+    const structural = this.graph.getFile(iface.file).plugin.hasStructuralInterfaces();
+    const results = [];
+
+    for (const entry of types.values()) {
+      const { symbol } = entry;
+      if (INTERFACE_KINDS.has(symbol.kind) || symbol.language !== iface.language) continue;
+
+      const methodNames = new Set(entry.methods.map(method => method.name));
+      const declared = [...entry.declared].some(name => names.has(name));
+      const covers = structural && required.size > 0 && [...required].every(name => methodNames.has(name));
+      if (!declared && !covers) continue;
+
+      const methods = entry.methods.filter(method => (required.size > 0
+        ? required.has(method.name)
+        : (method.implements || []).some(name => names.has(name))));
+
+      results.push({
+        type: symbol,
+        via: declared ? ImplementationMatch.DECLARED : ImplementationMatch.STRUCTURAL,
+        methods: methods.sort((a, b) => compareBytes(a.file, b.file) || a.startLine - b.startLine)
+      });
+    }
+
+    logger.debug(`${iface.qualifiedName}: ${results.length} implementations`);
+    return results.sort((a, b) => compareBytes(a.type.file, b.type.file) || a.type.startLine - b.type.startLine);
+  }
+
+  /**
+   * Types keyed by package and qualified name, with their members and the
+   * interfaces they (or their members, as in Rust impl blocks) declare
+   * @private
+   */
+  index() {
+    if (this.types) return this.types;
+
+    const entries = new Map();
+    const entry = (packageId, name) => {
+      const key = typeKey(packageId, name);
+      if (!entries.has(key)) entries.set(key, { symbol: null, package: packageId, methods: [], declared: new Set() });
+      return entries.get(key);
+    };
+
+    for (const file of this.graph.files.values()) {
+      for (const symbol of file.symbols) {
+        let target;
+        if (TYPE_KINDS.has(symbol.kind) || INTERFACE_KINDS.has(symbol.kind)) {
+          target = entry(file.package, symbol.qualifiedName);
+          target.symbol = target.symbol || symbol;
+        } else if (symbol.parent && symbol.kind === SymbolKind.METHOD) {
+          target = entry(file.package, symbol.parent);
+          target.methods.push(symbol);
+        } else {
+          continue;
+        }
+        for (const name of symbol.implements || []) target.declared.add(name);
+      }
+    }
+
+    this.types = new Map([...entries].filter(([, value]) => value.symbol));
+    return this.types;
+  }
+
+  /**
+   * Names of an interface and of every interface extending it
+   * @private
+   */
+  interfaceNames(target) {
+    const names = new Set([target.symbol.name]);
+    const interfaces = [...this.types.values()]
+      .filter(entry => INTERFACE_KINDS.has(entry.symbol.kind) && entry.symbol.language === target.symbol.language);
+
+    let grew = true;
+    while (grew) {
+      grew = false;
+      for (const entry of interfaces) {
+        if (!names.has(entry.symbol.name) && [...entry.declared].some(name => names.has(name))) {
+          names.add(entry.symbol.name);
+          grew = true;
+        }
+      }
+    }
+    return names;
+  }
+
+  /**
+   * Method names an interface requires, including inherited and embedded ones
+   * Inherited interfaces outside the graph contribute nothing.
+   * @private
+   */
+  requiredMethods(target, visited) {
+    const required = new Set(target.methods.map(method => method.name));
+    visited.add(target);
+
+    for (const name of target.declared) {
+      const parent = this.findInterface(name, target.package, target.symbol.language);
+      if (parent && !visited.has(parent)) {
+        for (const method of this.requiredMethods(parent, visited)) required.add(method);
+      }
+    }
+    return required;
+  }
+
+  /**
+   * Interface by simple name, preferring the given package
+   * @private
+   */
+  findInterface(name, packageId, language) {
+    let fallback = null;
+    for (const entry of this.types.values()) {
+      if (!INTERFACE_KINDS.has(entry.symbol.kind) || entry.symbol.name !== name || entry.symbol.language !== language) continue;
+      if (entry.package === packageId) return entry;
+      fallback = fallback || entry;
+    }
+    return fallback;
+  }
+
+  /**
+   * @private
+   */
+  packageOf(symbol) {
+    return this.graph.getFile(symbol.file)?.package;
+  }
+}
+
+function typeKey(packageId, name) {
+  return `${packageId}\0${name}`;
+}
+
+export default ImplementationFinder;
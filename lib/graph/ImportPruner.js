@@ -0,0 +1,78 @@
+/**
+ * ImportPruner - Minimal import blocks for excerpts
+ * v3.4.0 - Language-aware import pruning
+ *
+ * Responsibilities:
+ * - Keep the package clause and the imports an excerpt of a file references
+ * - Rewrite statements that bind several names (`import { a, b } from`,
+ *   `from x import a, b`, `use x::{a, b}`) to the names the excerpt uses,
+ *   through each language plugin's pruneImport()
+ * - Drop unused entries of import blocks such as Go's `import ( ... )`
+ *
+ * Statements a plugin cannot rewrite are kept whole when any name they bind
+ * is used, or when their bindings are unknown.
+ */
+
+import { referencedIdentifiers } from './DependencyExpander.js';
+
+export class ImportPruner {
+  /**
+   * Preamble an excerpt needs
+   * @param {Object} file - { content, path, plugin, language }
+   * @param {Array<Object>} ranges - Excerpt ranges { startLine, endLine, lines? }
+   * @returns {Object|null} { startLine, endLine, lines, pruned }, or null when the excerpt needs none;
+   *   pruned counts the import statements dropped or rewritten
+   */
+  static preamble(file, ranges) {
+    const lines = file.content.split('\n');
+    const excerpt = ranges.map(range => (range.lines || lines.slice(range.startLine - 1, range.endLine)).join('\n')).join('\n');
+    const identifiers = referencedIdentifiers(excerpt, file.language);
+    const isUsed = local => identifiers.has(local);
+    const used = imported => {
+      const locals = file.plugin.getImportBindings(imported);
+      return !locals || locals.some(isUsed);
+    };
+
+    const preamble = file.plugin.extractPreamble(file.content, file.path)
+      .filter(range => !ranges.some(selected => selected.startLine <= range.startLine && selected.endLine >= range.endLine));
+
+    const out = [];
+    const kept = [];
+    let pruned = 0;
+    let previous = null;
+    for (const range of preamble) {
+      // Blank line only where the file had one, not where a statement was dropped
+      const separated = previous && range.startLine > previous.endLine + 1;
+      previous = range;
+      const statement = lines.slice(range.startLine - 1, range.endLine);
+      let text = statement;
+
+      if (range.imports.length > 0) {
+        const rewritten = file.plugin.pruneImport(statement.join('\n'), isUsed);
+        if (rewritten === null || (rewritten === undefined && !range.imports.some(used))) {
+          pruned++;
+          continue;
+        }
+        if (rewritten !== undefined) {
+          text = rewritten.split('\n');
+        } else {
+          // Unused entries inside blocks such as Go's import ( ... )
+          const unused = new Set(range.imports
+            .filter(imported => imported.line > range.startLine && imported.line < range.endLine && !used(imported))
+            .map(imported => imported.line));
+          text = statement.filter((line, i) => !unused.has(range.startLine + i));
+        }
+        if (text.join('\n') !== statement.join('\n')) pruned++;
+      }
+
+      if (kept.length > 0 && separated) out.push('');
+      out.push(...text);
+      kept.push(range);
+    }
+
+    if (kept.length === 0) return null;
+    return { startLine: kept[0].startLine, endLine: kept[kept.length - 1].endLine, lines: out, pruned };
+  }
+}
+
+export default ImportPruner;
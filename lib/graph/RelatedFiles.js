@@ -0,0 +1,249 @@
+/**
+ * RelatedFiles - Ranked suggestions of files related to seed files
+ * v3.4.0 - Related files suggestion engine
+ *
+ * Responsibilities:
+ * - Count commits in which project files changed together with the seeds
+ * - Find files that use names the seeds define, or define names the seeds use
+ * - Measure how many import hops separate each file from the nearest seed
+ * - Combine the signals into one ranked list, with the reasons for each file
+ */
+
+import { GitClient } from '../integrations/git/GitClient.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { referencedIdentifiers } from './DependencyExpander.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('RelatedFiles');
+
+export const RELATED_SIGNALS = ['coChange', 'identifiers', 'imports'];
+
+export const DEFAULT_RELATED_WEIGHTS = { coChange: 1, identifiers: 1, imports: 1 };
+
+// Points per unit of weight for a signal at its maximum
+const SCALE = 10;
+
+// Shorter names (i, id, fn) are shared by chance
+const MIN_NAME_LENGTH = 3;
+
+// Names listed per reason before "+N more"
+const REASON_NAMES = 3;
+
+export class RelatedFiles {
+  constructor(options = {}) {
+    this.options = {
+      weights: {}, // Overrides of DEFAULT_RELATED_WEIGHTS
+      limit: 10, // Suggestions printed
+      accept: 0, // Top suggestions added to the seeds
+      maxCommits: 500, // Git history window of the co-change signal
+      maxCommitFiles: 50, // Larger commits say little about which files belong together
+      maxDepth: 3, // Import hops followed from the seeds
+      ...options
+    };
+    this.weights = { ...DEFAULT_RELATED_WEIGHTS, ...this.options.weights };
+  }
+
+  /**
+   * Files related to the seeds, most related first
+   * @param {Array<string>} seeds - '/'-separated project paths
+   * @param {Object} context
+   * @param {Array<string>} context.files - '/'-separated paths of all project files
+   * @param {DependencyGraph} [context.graph] - Graph over the project files
+   * @param {Map<string, Object>} [context.coChanges] - Result of coChangesOf()
+   * @returns {Array<{path: string, score: number, signals: Object, reasons: Array<string>}>}
+   *   signals[name] = { raw, value, weight, points }
+   */
+  suggest(seeds, context) {
+    const seedSet = new Set(seeds);
+    const candidates = context.files.filter(file => !seedSet.has(file));
+    const coChanges = context.coChanges || new Map();
+    const shared = context.graph ? sharedNames(seeds, candidates, context.graph) : new Map();
+    const distances = context.graph ? importDistances(seeds, context.graph, this.options.maxDepth) : new Map();
+
+    const raw = candidates.map(file => ({
+      path: file,
+      coChange: coChanges.get(file)?.commits ?? 0,
+      identifiers: shared.get(file)?.count ?? 0,
+      imports: distances.get(file)?.depth ?? 0
+    }));
+    const max = signal => Math.max(0, ...raw.map(entry => entry[signal]));
+    const maxima = { coChange: max('coChange'), identifiers: max('identifiers') };
+
+    const normalizers = {
+      coChange: entry => (maxima.coChange > 0 ? entry.coChange / maxima.coChange : 0),
+      identifiers: entry => (maxima.identifiers > 0 ? entry.identifiers / maxima.identifiers : 0),
+      // One hop is the closest a file can be
+      imports: entry => (entry.imports > 0 ? 1 / entry.imports : 0)
+    };
+
+    const suggestions = [];
+    for (const entry of raw) {
+      const signals = {};
+      let score = 0;
+      for (const signal of RELATED_SIGNALS) {
+        const weight = this.weights[signal];
+        const value = round(normalizers[signal](entry));
+        const points = round(weight * value * SCALE);
+        signals[signal] = { raw: entry[signal], value, weight, points };
+        score += points;
+      }
+      if (score <= 0) continue;
+
+      const reasons = [];
+      if (signals.coChange.points > 0) {
+        const { commits, files } = coChanges.get(entry.path);
+        reasons.push(`changed with ${formatNames([...files].sort())} in ${commits} ${commits === 1 ? 'commit' : 'commits'}`);
+      }
+      if (signals.identifiers.points > 0) {
+        const { uses, defines } = shared.get(entry.path);
+        if (uses.size > 0) reasons.push(`uses ${formatNames([...uses].sort())} of the seeds`);
+        if (defines.size > 0) reasons.push(`defines ${formatNames([...defines].sort())} used by the seeds`);
+      }
+      if (signals.imports.points > 0) {
+        const { depth, via, direction } = distances.get(entry.path);
+        reasons.push(depth === 1
+          ? `${direction === 'imports' ? 'imports' : 'imported by'} ${via}`
+          : `${depth} imports from ${via}`);
+      }
+      suggestions.push({ path: entry.path, score: round(score), signals, reasons });
+    }
+
+    return suggestions.sort((a, b) => b.score - a.score || compareBytes(a.path, b.path));
+  }
+
+  /**
+   * Commits in which files changed together with the seeds
+   * @param {string} root - Repository root
+   * @param {Array<string>} seeds
+   * @param {Object} [options] - { maxCommits, maxCommitFiles }
+   * @returns {Map<string, {commits: number, files: Set<string>}>} Empty outside a git repository
+   */
+  static coChangesOf(root, seeds, options = {}) {
+    const client = new GitClient(root);
+    if (!client.isGitRepo) return new Map();
+
+    try {
+      return client.getCoChanges(seeds, options.maxCommits ?? 500, options.maxCommitFiles ?? 50);
+    } catch (error) {
+      logger.debug(`No co-change signal: ${error.message}`);
+      return new Map();
+    }
+  }
+
+  /**
+   * Suggestion report, most related first
+   * @param {Array<Object>} suggestions - Result of suggest()
+   * @param {Object} options - { seeds: number, limit: number, accepted: number }
+   * @returns {string}
+   */
+  static formatSuggestions(suggestions, { seeds, limit = 10, accepted = 0 }) {
+    const lines = ['', `🔗 RELATED FILES (${seeds} ${seeds === 1 ? 'seed' : 'seeds'})`, '='.repeat(80)];
+    const shown = suggestions.slice(0, Math.max(limit, accepted));
+
+    shown.forEach((suggestion, index) => {
+      const mark = index < accepted ? '+' : ' ';
+      lines.push(`  ${mark}${String(index + 1).padStart(3)}. ${suggestion.score.toFixed(2).padStart(6)}  ${suggestion.path}`);
+      lines.push(`                ${suggestion.reasons.join(' · ')}`);
+    });
+
+    if (suggestions.length === 0) {
+      lines.push('   No related files found');
+    } else if (accepted > 0) {
+      lines.push('');
+      lines.push(`✅ Added the top ${Math.min(accepted, suggestions.length)} (+) to the context`);
+    } else {
+      lines.push('');
+      lines.push(`💡 Add the top ${Math.min(5, shown.length)} with: --accept-suggestions ${Math.min(5, shown.length)}`);
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Names each candidate shares with the seeds: seed definitions it uses and
+ * its definitions the seeds use
+ * @private
+ */
+function sharedNames(seeds, candidates, graph) {
+  const defined = file => new Set((graph.getFile(file)?.symbols || [])
+    .filter(symbol => !symbol.parent && symbol.kind !== SymbolKind.MODULE && symbol.name.length >= MIN_NAME_LENGTH)
+    .map(symbol => symbol.name));
+  const referenced = file => {
+    const node = graph.getFile(file);
+    return node ? referencedIdentifiers(node.content, node.language) : new Set();
+  };
+
+  const seedDefined = new Set(seeds.flatMap(seed => [...defined(seed)]));
+  const seedReferenced = new Set(seeds.flatMap(seed => [...referenced(seed)]));
+
+  const shared = new Map();
+  for (const file of candidates) {
+    if (!graph.getFile(file)) continue;
+    const own = defined(file);
+    const uses = new Set([...referenced(file)].filter(name => seedDefined.has(name) && !own.has(name)));
+    const defines = new Set([...own].filter(name => seedReferenced.has(name) && !seedDefined.has(name)));
+    const count = new Set([...uses, ...defines]).size;
+    if (count > 0) shared.set(file, { count, uses, defines });
+  }
+  return shared;
+}
+
+/**
+ * Import hops from the nearest seed, following imports both ways
+ * @private
+ */
+function importDistances(seeds, graph, maxDepth) {
+  const neighbours = new Map();
+  const link = (from, to, direction) => {
+    if (from === to) return;
+    if (!neighbours.has(from)) neighbours.set(from, []);
+    neighbours.get(from).push({ file: to, direction });
+  };
+  for (const file of graph.files.values()) {
+    for (const imported of file.imports) {
+      if (!imported.target) continue;
+      const targets = graph.files.has(imported.target)
+        ? [imported.target]
+        : graph.getPackage(imported.package)?.files || [];
+      for (const target of targets) {
+        link(target, file.path, 'imports');
+        link(file.path, target, 'imported');
+      }
+    }
+  }
+
+  // direction: how the reached file relates to the seed it was reached from
+  const distances = new Map(seeds.map(seed => [seed, { depth: 0, via: seed }]));
+  let frontier = seeds.filter(seed => graph.getFile(seed));
+  for (let depth = 1; depth <= maxDepth && frontier.length > 0; depth++) {
+    const next = [];
+    for (const file of frontier) {
+      for (const neighbour of neighbours.get(file) || []) {
+        if (distances.has(neighbour.file)) continue;
+        const via = depth === 1 ? file : distances.get(file).via;
+        distances.set(neighbour.file, { depth, via, direction: neighbour.direction });
+        next.push(neighbour.file);
+      }
+    }
+    frontier = next.sort(compareBytes);
+  }
+
+  for (const seed of seeds) distances.delete(seed);
+  return distances;
+}
+
+/**
+ * "a", "a, b", "a, b, c +2 more"
+ * @private
+ */
+function formatNames(names) {
+  const shown = names.slice(0, REASON_NAMES).join(', ');
+  return names.length > REASON_NAMES ? `${shown} +${names.length - REASON_NAMES} more` : shown;
+}
+
+function round(value) {
+  return Math.round(value * 100) / 100;
+}
+
+export default RelatedFiles;
@@ -0,0 +1,261 @@
+/**
+ * SnippetResolver - Definitions a pasted snippet or stack trace mentions
+ * v3.4.0 - Snippet enrichment (ctxman enrich)
+ *
+ * Responsibilities:
+ * - Find the file locations in a snippet: stack trace frames of Node,
+ *   Python, Go, Java and Rust, and compiler or linter output (path:line)
+ * - Map them to project files, also from absolute paths or paths of another
+ *   checkout, and to the function around each line
+ * - Resolve the identifiers the snippet uses to the symbols the project defines
+ * - Report what was resolved, and the locations and names that were not
+ *
+ * A location maps to the project file sharing the most trailing path
+ * segments with it; ties are left unresolved. Names defined more than
+ * maxMatches times are too ambiguous to add.
+ */
+
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('SnippetResolver');
+
+export const SNIPPET_TITLE = 'SNIPPET';
+
+// Python frames: File "app/main.py", line 12, in handler
+const PYTHON_FRAME = /File "([^"\n]+)", line (\d+)/g;
+
+// Everything else: src/app.js:12:5, (Main.java:42), at /srv/app/main.go:7 +0x1d
+const PATH_LINE = /((?:[A-Za-z]:)?[\w.$@~+/\\-]*\.[A-Za-z]\w*):(\d+)(?::\d+)?/g;
+
+const IDENTIFIER = /[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)*/g;
+
+// Keywords and stack trace words that name no project symbol
+const STOP_WORDS = new Set([
+  'and', 'any', 'async', 'await', 'bool', 'break', 'call', 'case', 'catch', 'char', 'class', 'const',
+  'continue', 'def', 'default', 'defer', 'delete', 'double', 'elif', 'else', 'enum', 'error', 'except',
+  'export', 'extends', 'false', 'file', 'final', 'finally', 'float', 'for', 'from', 'func', 'function',
+  'goroutine', 'impl', 'import', 'int', 'interface', 'last', 'let', 'line', 'long', 'main', 'match',
+  'most', 'new', 'nil', 'none', 'not', 'null', 'object', 'package', 'panic', 'pass', 'private', 'protected',
+  'pub', 'public', 'raise', 'recent', 'return', 'running', 'self', 'static', 'str', 'string', 'struct',
+  'super', 'switch', 'the', 'this', 'throw', 'trait', 'traceback', 'true', 'try', 'type', 'typeof',
+  'undefined', 'use', 'var', 'void', 'while', 'with', 'yield'
+]);
+
+export class SnippetResolver {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      context: 3, // Lines around a frame outside every function
+      minLength: 3, // Shortest identifier looked up
+      maxMatches: 3, // Most definitions of a name before it is ambiguous
+      ...options
+    };
+  }
+
+  /**
+   * Resolve what a snippet mentions
+   * @param {DependencyGraph} graph - Graph over the files to search
+   * @param {string} text - Snippet, stack trace or compiler output
+   * @returns {Object} { frames[] { location, file, line, symbol, startLine, endLine },
+   *   mentions[] { name, symbols }, ambiguous[], symbols }
+   *   file is null for locations outside the project; symbols are those of
+   *   frames and mentions, without duplicates
+   */
+  resolve(graph, text) {
+    const files = [...graph.files.keys()].sort(compareBytes);
+    const frames = this.frames(text).map(frame => this.resolveFrame(graph, files, frame));
+
+    const symbols = new Map();
+    const add = symbol => symbols.set(`${symbol.file}#${symbol.qualifiedName}@${symbol.startLine}`, symbol);
+    frames.filter(frame => frame.symbol).forEach(frame => add(frame.symbol));
+
+    // Names in paths are not identifiers of the snippet
+    const code = text.replace(PATH_LINE, ' ').replace(PYTHON_FRAME, ' ');
+    const mentions = [];
+    const ambiguous = [];
+    for (const names of this.identifiers(code)) {
+      // The longest selector that names a symbol: Server.start before start
+      const found = names.map(name => ({ name, matches: matchSymbols(graph, name) })).find(entry => entry.matches.length > 0);
+      if (!found) continue;
+      const { name, matches } = found;
+      if (mentions.some(mention => mention.name === name) || ambiguous.includes(name)) continue;
+      if (matches.length > this.options.maxMatches) {
+        ambiguous.push(name);
+        continue;
+      }
+      mentions.push({ name, symbols: matches });
+      matches.forEach(add);
+    }
+
+    logger.debug(`${frames.length} frames, ${mentions.length} names resolved, ${ambiguous.length} ambiguous`);
+    return { frames, mentions, ambiguous, symbols: [...symbols.values()] };
+  }
+
+  /**
+   * Console report of resolve()
+   * @param {Object} resolution
+   * @returns {string}
+   */
+  static formatResolution(resolution) {
+    const lines = ['', '🧷 SNIPPET REFERENCES', '='.repeat(80)];
+    if (resolution.frames.length > 0) {
+      lines.push('   Locations:');
+      for (const frame of resolution.frames) {
+        lines.push(`     ${frame.location} → ${describeFrame(frame)}`);
+      }
+    }
+    if (resolution.mentions.length > 0) {
+      lines.push('   Names:');
+      for (const { name, symbols } of resolution.mentions) {
+        lines.push(`     ${name} → ${symbols.map(symbol => `${symbol.kind} ${symbol.qualifiedName} (${symbol.file}:${symbol.startLine})`).join(', ')}`);
+      }
+    }
+    if (resolution.ambiguous.length > 0) {
+      lines.push(`   Ambiguous, left out: ${resolution.ambiguous.join(', ')}`);
+    }
+    if (resolution.frames.length === 0 && resolution.mentions.length === 0) {
+      lines.push('   Nothing in the snippet names a project file or symbol');
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * Digest section holding the snippet and what it resolved to
+   * @param {string} text
+   * @param {Object} resolution
+   * @returns {string}
+   */
+  static formatSnippet(text, resolution) {
+    const lines = ['='.repeat(48), SNIPPET_TITLE, '='.repeat(48), text.replace(/\s+$/, '')];
+    const resolved = [
+      ...resolution.frames.filter(frame => frame.file).map(frame => `- ${frame.location} → ${describeFrame(frame)}`),
+      ...resolution.mentions.map(({ name, symbols }) => `- ${name} → ${symbols.map(symbol => `${symbol.file}:${symbol.startLine}`).join(', ')}`)
+    ];
+    if (resolved.length > 0) lines.push('', 'Resolved:', ...resolved);
+    return lines.join('\n') + '\n';
+  }
+
+  /**
+   * File locations in order of appearance, without repeats
+   * @private
+   */
+  frames(text) {
+    const found = [];
+    for (const match of text.matchAll(PYTHON_FRAME)) {
+      found.push({ index: match.index, location: `${match[1]}:${match[2]}`, path: match[1], line: Number(match[2]) });
+    }
+    // Blanked, not removed, so indexes still order the frames
+    for (const match of text.replace(PYTHON_FRAME, blank => ' '.repeat(blank.length)).matchAll(PATH_LINE)) {
+      found.push({ index: match.index, location: `${match[1]}:${match[2]}`, path: match[1], line: Number(match[2]) });
+    }
+
+    const seen = new Set();
+    return found
+      .sort((a, b) => a.index - b.index)
+      .filter(frame => !seen.has(frame.location) && seen.add(frame.location))
+      .map(({ location, path, line }) => ({ location, path, line }));
+  }
+
+  /**
+   * Project file and enclosing symbol of a location
+   * @private
+   */
+  resolveFrame(graph, files, frame) {
+    const file = matchPath(files, frame.path);
+    const node = file && graph.getFile(file);
+    const lineCount = node ? node.content.split('\n').length : 0;
+    if (!node || frame.line < 1 || frame.line > lineCount) {
+      return { location: frame.location, file: null, line: frame.line, symbol: null, startLine: null, endLine: null };
+    }
+
+    const symbol = node.symbols
+      .filter(s => s.kind !== SymbolKind.MODULE && s.startLine <= frame.line && s.endLine >= frame.line)
+      .sort((a, b) => (a.endLine - a.startLine) - (b.endLine - b.startLine))[0] || null;
+    return {
+      location: frame.location,
+      file,
+      line: frame.line,
+      symbol,
+      startLine: symbol ? symbol.startLine : Math.max(1, frame.line - this.options.context),
+      endLine: symbol ? symbol.endLine : Math.min(lineCount, frame.line + this.options.context)
+    };
+  }
+
+  /**
+   * Identifiers and dotted selectors worth looking up, in order of appearance
+   * @returns {Array<Array<string>>} Per selector a.b.c: a.b.c, b.c and c
+   * @private
+   */
+  identifiers(text) {
+    const seen = new Set();
+    const selectors = [];
+    for (const [match] of text.matchAll(IDENTIFIER)) {
+      if (seen.has(match)) continue;
+      seen.add(match);
+      const parts = match.split('.');
+      // Only plain names can be common words
+      const names = parts.map((part, i) => parts.slice(i).join('.')).filter((name, i) => i < parts.length - 1 ||
+        (name.length >= this.options.minLength && !STOP_WORDS.has(name.toLowerCase())));
+      if (names.length > 0) selectors.push(names);
+    }
+    return selectors;
+  }
+}
+
+/**
+ * Project file sharing the most trailing segments with a path; null when
+ * none shares the file name or several tie
+ * @private
+ */
+function matchPath(files, location) {
+  const segments = location.replace(/\\/g, '/').split('/').filter(segment => segment && segment !== '.');
+  let best = [];
+  let bestScore = 0;
+  for (const file of files) {
+    const parts = file.split('/');
+    let score = 0;
+    while (score < parts.length && score < segments.length &&
+      parts[parts.length - 1 - score] === segments[segments.length - 1 - score]) score++;
+    if (score === 0) continue;
+    if (score > bestScore) {
+      best = [file];
+      bestScore = score;
+    } else if (score === bestScore) {
+      best.push(file);
+    }
+  }
+  return best.length === 1 ? best[0] : null;
+}
+
+/**
+ * Symbols defined as name: by qualified name, else by simple name
+ * @private
+ */
+function matchSymbols(graph, name) {
+  const byQualified = [];
+  const byName = [];
+  for (const node of graph.files.values()) {
+    for (const symbol of node.symbols) {
+      if (symbol.kind === SymbolKind.MODULE) continue;
+      if (symbol.qualifiedName === name) byQualified.push(symbol);
+      else if (symbol.name === name && !name.includes('.')) byName.push(symbol);
+    }
+  }
+  return byQualified.length > 0 ? byQualified : byName;
+}
+
+/**
+ * @private
+ */
+function describeFrame(frame) {
+  if (!frame.file) return 'not in the project';
+  return frame.symbol
+    ? `${frame.symbol.kind} ${frame.symbol.qualifiedName} (${frame.file}:${frame.symbol.startLine}-${frame.symbol.endLine})`
+    : `${frame.file}:${frame.startLine}-${frame.endLine}`;
+}
+
+export default SnippetResolver;
@@ -0,0 +1,243 @@
+/**
+ * SymbolSlicer - Self-contained symbol excerpts
+ * v3.4.0 - Symbol-level extraction
+ *
+ * Responsibilities:
+ * - Resolve symbols by qualified name (pkg/calc.Calculator.Add)
+ * - Pull in members of selected types (Go methods, Rust impl blocks)
+ * - Add the receiver or enclosing type of selected members
+ * - List (and optionally add) the types implementing selected interfaces
+ * - Keep the package clause and the imports the excerpt uses (ImportPruner)
+ */
+
+import { CONTAINER_KINDS } from '../symbols/SymbolModel.js';
+import { DependencyExpander } from './DependencyExpander.js';
+import { ImplementationFinder } from './ImplementationFinder.js';
+import { ImportPruner } from './ImportPruner.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('SymbolSlicer');
+
+export const SliceRole = Object.freeze({
+  SELECTED: 'selected', // Requested symbol
+  MEMBER: 'member', // Member of a requested type
+  RECEIVER: 'receiver type', // Type a requested method belongs to
+  DECLARATION: 'declaration', // Declaration line of an enclosing type
+  IMPLEMENTATION: 'implementation', // Type or method implementing a requested interface
+  PREAMBLE: 'preamble' // Package clause and imports
+});
+
+export class SymbolSlicer {
+  /**
+   * @param {DependencyGraph} graph - Built graph
+   * @param {Object} options
+   */
+  constructor(graph, options = {}) {
+    this.graph = graph;
+    this.options = {
+      members: true, // Selecting a type includes its members
+      exportedMembers: false, // Only its exported members
+      receivers: true, // Selecting a member includes its type
+      imports: true, // Include package clause and used imports
+      implementations: false, // Selecting an interface includes its implementations
+      ...options
+    };
+    this.expander = new DependencyExpander(graph);
+    this.finder = new ImplementationFinder(graph);
+  }
+
+  /**
+   * Resolve symbol specs
+   * @param {Array<string>} specs - Same forms as DependencyExpander.resolveFocus()
+   * @returns {{symbols: Array<Object>, missing: Array<string>}}
+   */
+  resolve(specs) {
+    const symbols = [];
+    const missing = [];
+
+    for (const spec of specs) {
+      const matches = this.expander.resolveFocus(spec);
+      if (matches.length === 0) missing.push(spec);
+      symbols.push(...matches);
+    }
+
+    return { symbols, missing };
+  }
+
+  /**
+   * Build excerpts for symbols
+   * @param {Array<string>|Array<Object>} selection - Specs or resolved symbols
+   * @returns {Object} { symbols, missing, files: [{file, ranges}], implementations, remapped }
+   *   implementations lists, per selected interface, the types implementing it;
+   *   remapped lists symbol IDs reconciled to a moved or renamed symbol ({from, to})
+   */
+  slice(selection) {
+    const { symbols, missing } = typeof selection[0] === 'string'
+      ? this.resolve(selection)
+      : { symbols: selection, missing: [] };
+    const ranges = new Map();
+
+    const add = (symbol, role) => {
+      if (!ranges.has(symbol.file)) ranges.set(symbol.file, []);
+      const fileRanges = ranges.get(symbol.file);
+      const covered = fileRanges.some(range => range.startLine <= symbol.startLine && range.endLine >= symbol.endLine &&
+        (range.role !== SliceRole.DECLARATION || role === SliceRole.DECLARATION));
+      if (covered) return;
+
+      fileRanges.push({
+        name: symbol.qualifiedName,
+        kind: symbol.kind,
+        startLine: symbol.startLine,
+        endLine: symbol.endLine,
+        role,
+        ...(role === SliceRole.DECLARATION ? { context: true } : {})
+      });
+    };
+
+    // Widest ranges first so nested requests are recognized as covered
+    const ordered = [...symbols].sort((a, b) => (b.endLine - b.startLine) - (a.endLine - a.startLine));
+    for (const symbol of ordered) {
+      add(symbol, SliceRole.SELECTED);
+    }
+
+    for (const symbol of ordered) {
+      if (this.options.members && CONTAINER_KINDS.has(symbol.kind)) {
+        const members = this.packageSymbols(symbol.file)
+          .filter(s => s.parent === symbol.qualifiedName && (!this.options.exportedMembers || s.exported));
+        for (const member of members) {
+          add(member, SliceRole.MEMBER);
+        }
+      }
+
+      // Walk out through enclosing types (nested classes)
+      for (let member = symbol; this.options.receivers && member.parent;) {
+        const container = this.packageSymbols(member.file)
+          .find(s => s.qualifiedName === member.parent && CONTAINER_KINDS.has(s.kind));
+        if (!container) break;
+
+        const encloses = container.file === member.file &&
+          container.startLine <= member.startLine && container.endLine >= member.endLine;
+        if (!encloses) {
+          add(container, SliceRole.RECEIVER);
+          break;
+        }
+        add({ ...container, endLine: container.startLine }, SliceRole.DECLARATION);
+        member = container;
+      }
+    }
+
+    const implementations = [];
+    for (const symbol of ordered) {
+      const found = this.finder.find(symbol);
+      if (found.length === 0) continue;
+      implementations.push({ interface: symbol, types: found, included: this.options.implementations });
+      if (!this.options.implementations) continue;
+
+      for (const { type, methods } of found) {
+        // Methods declared in the type body only need its declaration line
+        const enclosing = methods.some(method => method.file === type.file &&
+          type.startLine <= method.startLine && type.endLine >= method.endLine);
+        if (enclosing) {
+          add({ ...type, endLine: type.startLine }, SliceRole.DECLARATION);
+        } else {
+          add(type, SliceRole.IMPLEMENTATION);
+        }
+        for (const method of methods) {
+          add(method, SliceRole.IMPLEMENTATION);
+        }
+      }
+    }
+
+    const files = [...ranges.entries()].map(([file, fileRanges]) => ({
+      file,
+      ranges: this.withPreamble(file, fileRanges)
+    }));
+
+    logger.debug(`Sliced ${symbols.length} symbols into ${files.length} files`);
+    const remapped = this.expander.symbolIds?.remapped || [];
+    return { symbols, missing, files, implementations, remapped };
+  }
+
+  /**
+   * Symbols of the package a file belongs to
+   * @private
+   */
+  packageSymbols(relativePath) {
+    const node = this.graph.getPackage(this.graph.getFile(relativePath).package);
+    return node.files.flatMap(file => this.graph.getFile(file).symbols);
+  }
+
+  /**
+   * Prepend the package clause and the imports the excerpt references
+   * @private
+   */
+  withPreamble(relativePath, ranges) {
+    const sorted = ranges.sort((a, b) => a.startLine - b.startLine);
+    if (!this.options.imports) return sorted;
+
+    const file = this.graph.getFile(relativePath);
+    const preamble = ImportPruner.preamble({ ...file, path: relativePath }, sorted);
+    if (!preamble) return sorted;
+
+    return [{
+      name: 'imports',
+      kind: SliceRole.PREAMBLE,
+      startLine: preamble.startLine,
+      endLine: preamble.endLine,
+      lines: preamble.lines,
+      role: SliceRole.PREAMBLE,
+      context: true
+    }, ...sorted];
+  }
+
+  /**
+   * Text of a file's ranges as exported
+   * @param {string} content - File content
+   * @param {Array<Object>} ranges - Ranges of one slice() file
+   * @returns {string}
+   */
+  static excerpt(content, ranges) {
+    const lines = content.split('\n');
+    return ranges
+      .map(range => (range.lines || lines.slice(range.startLine - 1, range.endLine)).join('\n'))
+      .join('\n\n');
+  }
+
+  /**
+   * Format a slice as a console report
+   * @param {Object} result - Result of slice()
+   * @returns {string}
+   */
+  static formatSlice(result) {
+    const lines = [];
+
+    lines.push('');
+    lines.push('✂️  SYMBOL SELECTION');
+    lines.push('='.repeat(80));
+    lines.push(`   Symbols: ${result.symbols.length}` + (result.missing.length > 0 ? ` (not found: ${result.missing.join(', ')})` : ''));
+    lines.push(`   Files:   ${result.files.length}`);
+    for (const { from, to } of result.remapped || []) {
+      lines.push(`   Remapped: ${from} → ${to}`);
+    }
+    for (const { interface: iface, types, included } of result.implementations || []) {
+      const names = types.map(({ type, via }) => `${type.qualifiedName} (${type.file}:${type.startLine}, ${via})`);
+      lines.push(`   Implementations of ${iface.qualifiedName}: ${names.join(', ')}`);
+      if (!included) {
+        lines.push('     Not exported; pass --include-implementations to add their methods');
+      }
+    }
+    lines.push('');
+
+    for (const { file, ranges } of result.files) {
+      lines.push(`   ${file}`);
+      for (const range of ranges) {
+        const role = range.role === SliceRole.SELECTED || range.role === range.kind ? '' : ` — ${range.role}`;
+        lines.push(`     ${range.kind} ${range.name} (lines ${range.startLine}-${range.endLine})${role}`);
+      }
+    }
+
+    return lines.join('\n');
+  }
+}
+
+export default SymbolSlicer;
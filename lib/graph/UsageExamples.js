@@ -0,0 +1,226 @@
+/**
+ * UsageExamples - Call sites of selected symbols, as examples for the reader
+ * v3.4.0 - Usage examples (--usage-examples)
+ *
+ * Responsibilities:
+ * - Find the lines across the project that use each selected symbol, through
+ *   the names visible to each file (CrossReferenceIndex)
+ * - Cut a snippet around each use: the enclosing function when it is short,
+ *   otherwise a few lines around the call
+ * - Pick the most representative snippets per symbol: calls whose arguments
+ *   have a shape not seen yet first, then both production code and tests,
+ *   then files not shown yet
+ */
+
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { CrossReferenceIndex } from './CrossReferenceIndex.js';
+import { stripNoise } from './DependencyExpander.js';
+import TestPairing from '../core/TestPairing.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('UsageExamples');
+
+export const DEFAULT_EXAMPLES = 3;
+
+// Imports name a symbol without showing how it is used
+const IMPORT_LINE = /^\s*(?:import\b|from\s+\S+\s+import\b|export\s*(?:\*|\{)|use\s|using\s|#include\b|(?:const|let|var)\s+[\w${},\s]+=\s*require\s*\()/;
+
+// Lines a multi-line call may span
+const MAX_CALL_LINES = 10;
+
+export class UsageExamples {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      limit: DEFAULT_EXAMPLES, // Examples per symbol
+      context: 2, // Lines shown before and after a use in a long function
+      maxLines: 15, // Longest enclosing function shown whole
+      ...options
+    };
+  }
+
+  /**
+   * Examples of each symbol
+   * @param {DependencyGraph} graph - Graph over the files to search
+   * @param {Array<string>} files - '/'-separated paths to search
+   * @param {Array<Object>} targets - Symbols to find uses of
+   * @returns {Array<{symbol: Object, uses: number, examples: Array<Object>}>}
+   *   examples are { file, line, startLine, endLine, test, shape }
+   */
+  find(graph, files, targets) {
+    targets = targets.filter(symbol => symbol.kind !== SymbolKind.MODULE);
+    if (targets.length === 0) return [];
+
+    const entries = new CrossReferenceIndex().build(graph, files.map(file => ({ path: file, ranges: null })), targets);
+    const stripped = new Map();
+    const strippedOf = file => {
+      if (!stripped.has(file)) {
+        const node = graph.getFile(file);
+        stripped.set(file, stripNoise(node.content, node.language).split('\n'));
+      }
+      return stripped.get(file);
+    };
+
+    return entries.map(entry => {
+      const symbol = targets.find(target => target.file === entry.file && target.qualifiedName === entry.name && target.startLine === entry.line);
+      const uses = entry.references
+        .filter(reference => !targets.some(target => target.file === reference.file &&
+          target.startLine <= reference.line && target.endLine >= reference.line))
+        .filter(reference => !IMPORT_LINE.test(strippedOf(reference.file)[reference.line - 1]))
+        .map(reference => this.snippet(graph.getFile(reference.file), strippedOf(reference.file), reference, symbol));
+      const examples = this.pick(uses);
+      logger.debug(`${symbol.qualifiedName}: ${examples.length} of ${uses.length} uses`);
+      return { symbol, uses: uses.length, examples };
+    });
+  }
+
+  /**
+   * Console report of find()
+   * @param {Array<Object>} results
+   * @returns {string}
+   */
+  static formatExamples(results) {
+    const lines = ['', '🧩 USAGE EXAMPLES', '='.repeat(80)];
+    for (const { symbol, uses, examples } of results) {
+      const where = `${symbol.qualifiedName} (${symbol.kind}, ${symbol.file}:${symbol.startLine})`;
+      if (uses === 0) {
+        lines.push(`   ${where}: not used elsewhere`);
+        continue;
+      }
+      lines.push(`   ${where}: ${examples.length} of ${uses} uses`);
+      for (const example of examples) {
+        lines.push(`     ${example.file}:${example.startLine}-${example.endLine}${example.test ? ' (test)' : ''}  ${example.shape}`);
+      }
+    }
+    if (results.length === 0) {
+      lines.push('   No symbols to find uses of');
+    }
+    return lines.join('\n');
+  }
+
+  /**
+   * Snippet around a use, with the shape of the call's arguments
+   * @private
+   */
+  snippet(node, stripped, reference, symbol) {
+    const { file, line } = reference;
+    const raw = node.content.split('\n');
+    const call = callOf(raw, stripped, line, symbol.name);
+    const lastLine = call ? call.endLine : line;
+
+    // Smallest declaration around the use; the file when there is none
+    const enclosing = (node.symbols || [])
+      .filter(s => s.kind !== SymbolKind.MODULE && s.startLine <= line && s.endLine >= lastLine)
+      .sort((a, b) => (a.endLine - a.startLine) - (b.endLine - b.startLine))[0];
+    const bounds = enclosing || { startLine: 1, endLine: raw.length };
+
+    let startLine = bounds.startLine;
+    let endLine = bounds.endLine;
+    if (!enclosing || endLine - startLine + 1 > this.options.maxLines) {
+      startLine = Math.max(bounds.startLine, line - this.options.context);
+      endLine = Math.min(bounds.endLine, lastLine + this.options.context);
+    }
+    while (startLine < line && !raw[startLine - 1].trim()) startLine++;
+    while (endLine > lastLine && !raw[endLine - 1].trim()) endLine--;
+
+    return {
+      file,
+      line,
+      startLine,
+      endLine,
+      test: TestPairing.isTestFile(file),
+      shape: call ? `(${call.args.join(', ')})` : 'reference'
+    };
+  }
+
+  /**
+   * Greedy pick: every example adds what the picked ones lack
+   * @private
+   */
+  pick(uses) {
+    const picked = [];
+    const shapes = new Set();
+    const kinds = new Set();
+    const files = new Set();
+    let candidates = [...uses];
+
+    while (picked.length < this.options.limit && candidates.length > 0) {
+      const score = use => (shapes.has(use.shape) ? 0 : 4) + (kinds.has(use.test) ? 0 : 2) + (files.has(use.file) ? 0 : 1);
+      candidates.sort((a, b) => score(b) - score(a) ||
+        Number(a.test) - Number(b.test) ||
+        (a.endLine - a.startLine) - (b.endLine - b.startLine) ||
+        compareBytes(a.file, b.file) || a.line - b.line);
+
+      const [best] = candidates;
+      picked.push(best);
+      shapes.add(best.shape);
+      kinds.add(best.test);
+      files.add(best.file);
+      candidates = candidates.filter(use => use.file !== best.file ||
+        use.endLine < best.startLine || use.startLine > best.endLine);
+    }
+
+    return picked.sort((a, b) => Number(a.test) - Number(b.test) || compareBytes(a.file, b.file) || a.line - b.line);
+  }
+}
+
+/**
+ * Arguments of a call to name on a line: a kind per argument, and the line
+ * the call ends on. Parentheses are matched on the stripped lines, where
+ * strings and comments are blanks, and arguments read from the raw ones.
+ * @private
+ */
+function callOf(raw, stripped, line, name) {
+  const text = stripped.slice(line - 1, line - 1 + MAX_CALL_LINES).join('\n');
+  const source = raw.slice(line - 1, line - 1 + MAX_CALL_LINES).join('\n');
+  // The call starts on the line; type arguments and Rust macros may sit in between
+  const escaped = name.replace(/\$/g, '\\$');
+  const match = new RegExp(`(?:^|[^\\w$])${escaped}\\s*(?:<[^<>()\\n]*>\\s*)?!?\\(`).exec(stripped[line - 1] || '');
+  if (!match) return null;
+
+  const open = match.index + match[0].length;
+  const args = [];
+  let depth = 0;
+  let start = open;
+  for (let i = open; i < text.length; i++) {
+    const char = text[i];
+    if ('([{'.includes(char)) depth++;
+    else if (')]}'.includes(char) && depth > 0) depth--;
+    else if (char === ',' && depth === 0) {
+      args.push(source.slice(start, i));
+      start = i + 1;
+    } else if (char === ')') {
+      args.push(source.slice(start, i));
+      const kinds = args.map(arg => arg.trim()).filter(Boolean).map(argumentKind);
+      return { args: kinds, endLine: line + text.slice(0, i).split('\n').length - 1 };
+    }
+  }
+  return null;
+}
+
+/**
+ * @private
+ */
+function argumentKind(arg) {
+  const keyword = /^([A-Za-z_]\w*)\s*[=:]\s*(?![=>])(.+)$/s.exec(arg);
+  if (keyword && !/^(?:true|false|null|nil|None)$/.test(keyword[1]) && !arg.startsWith('{')) {
+    return `${keyword[1]}=${argumentKind(keyword[2].trim())}`;
+  }
+  if (/^(?:\.\.\.|\*{1,2})/.test(arg)) return 'spread';
+  if (/^(?:[rbfu]?["'`]|@")/i.test(arg)) return 'string';
+  if (/^-?\.?\d/.test(arg)) return 'number';
+  if (/^(?:true|false|True|False)$/.test(arg)) return 'boolean';
+  if (/^(?:null|nil|None|undefined)$/.test(arg)) return 'null';
+  if (/=>|^(?:async\s+)?function\b|^lambda\b|^func\b|^\|/.test(arg)) return 'function';
+  if (arg.startsWith('[')) return 'array';
+  if (arg.startsWith('{')) return 'object';
+  if (/^new\s/.test(arg) || /^&?[A-Z][\w.]*\s*\{/.test(arg)) return 'new';
+  if (/^&?[\w$.]+$/.test(arg)) return 'identifier';
+  if (/^[\w$.]+\s*\(/.test(arg) && arg.endsWith(')')) return 'call';
+  return 'expression';
+}
+
+export default UsageExamples;
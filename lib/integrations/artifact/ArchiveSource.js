@@ -0,0 +1,493 @@
+/**
+ * ArchiveSource - Release archives as scan targets
+ * v3.4.0 - Context of an archive (ctxman gen FILE.zip|FILE.tar.gz|URL)
+ *
+ * Responsibilities:
+ * - Recognize .zip, .tar, .tar.gz, .tgz, .tar.bz2, .tar.xz, wheel and jar
+ *   files, local or behind an http(s) URL
+ * - Read zip (stored, deflate, zip64) and tar (ustar, pax, GNU long names)
+ *   entries in-process; bzip2 and xz tarballs go through the system tar
+ * - Extract entries into a temporary directory, refusing paths that leave
+ *   it and stopping at a size limit, so archive bombs cannot fill the disk
+ *
+ * Symbolic links, devices and other special entries are skipped. An archive
+ * whose entries share one top-level directory (project-1.2.0/...) is
+ * scanned from that directory, so contexts show its name. Callers remove
+ * the directory once the context is written.
+ */
+
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import zlib from 'zlib';
+import { spawnSync } from 'child_process';
+
+// 1 GiB of extracted content
+export const DEFAULT_MAX_BYTES = 1024 * 1024 * 1024;
+
+const ARCHIVE = /\.(zip|jar|whl|tar|tgz|tar\.gz|tbz2?|tar\.bz2|txz|tar\.xz)$/i;
+
+export class ArchiveSource {
+  /**
+   * @param {Object} fields - { location, url, name }
+   */
+  constructor(fields) {
+    Object.assign(this, { url: null, ...fields });
+  }
+
+  /**
+   * Whether a gen target names an archive
+   * @param {string} spec
+   * @returns {boolean}
+   */
+  static isArchive(spec) {
+    return ARCHIVE.test(String(spec || '').split(/[?#]/)[0]);
+  }
+
+  /**
+   * Parse an archive target
+   * @param {string} spec - Path or http(s) URL of an archive
+   * @param {string} [cwd] - Directory relative paths resolve against
+   * @returns {ArchiveSource}
+   * @throws {Error} When the target is no archive
+   */
+  static parse(spec, cwd = process.cwd()) {
+    if (!ArchiveSource.isArchive(spec)) {
+      throw new Error(`Not an archive: ${spec} (expected .zip, .tar, .tar.gz, .tgz, .tar.bz2 or .tar.xz)`);
+    }
+    const remote = /^https?:\/\//i.test(spec);
+    const file = remote ? decodeURIComponent(new URL(spec).pathname.split('/').pop()) : path.basename(spec);
+    return new ArchiveSource({
+      location: remote ? spec : path.resolve(cwd, spec),
+      url: remote ? spec : null,
+      name: file.replace(ARCHIVE, '') || 'archive'
+    });
+  }
+
+  /**
+   * Report line, e.g. "release-1.2.0.tar.gz"
+   * @returns {string}
+   */
+  describe() {
+    return this.url ? this.url : path.basename(this.location);
+  }
+
+  /**
+   * Extract the archive into a new temporary directory
+   * @param {Object} [options]
+   * @param {number} [options.maxBytes] - Extracted size limit (default DEFAULT_MAX_BYTES)
+   * @param {Function} [options.fetch] - fetch implementation (tests)
+   * @returns {Promise<{root: string, dir: string, method: string, commit: null, stats: Object}>} root
+   *   is the extracted tree; dir, its temporary parent, is for the caller to remove; stats
+   *   { files, directories, skipped, bytes }
+   * @throws {Error} When the archive cannot be read or exceeds the limit
+   */
+  async checkout(options = {}) {
+    const data = this.url ? await download(this.url, options.fetch) : readLocal(this.location);
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-archive-'));
+    const target = path.join(dir, this.name);
+    try {
+      const maxBytes = options.maxBytes ?? DEFAULT_MAX_BYTES;
+      const writer = new TreeWriter(target, { maxBytes });
+      const kind = detect(data);
+      if (kind === 'zip') {
+        for (const entry of zipEntries(data, { maxBytes })) writer.write(entry);
+      } else if (kind === 'tar' || kind === 'gzip') {
+        for (const entry of tarEntries(kind === 'gzip' ? gunzip(data, maxBytes) : data)) writer.write(entry);
+      } else if (kind === 'bzip2' || kind === 'xz') {
+        systemTar(data, kind, target);
+        writer.count();
+      } else {
+        throw new Error(`${this.describe()} is not a zip or tar archive`);
+      }
+      return { root: singleDirectory(target), dir, method: kind === 'zip' ? 'zip' : 'tar', commit: null, stats: writer.stats };
+    } catch (error) {
+      fs.rmSync(dir, { recursive: true, force: true });
+      throw error;
+    }
+  }
+}
+
+/**
+ * Writes archive entries below a root, refusing paths that leave it
+ */
+export class TreeWriter {
+  /**
+   * @param {string} root
+   * @param {Object} [options] - { maxBytes }
+   */
+  constructor(root, options = {}) {
+    this.root = root;
+    this.options = { maxBytes: DEFAULT_MAX_BYTES, ...options };
+    this.stats = { files: 0, directories: 0, skipped: 0, bytes: 0 };
+    fs.mkdirSync(root, { recursive: true });
+  }
+
+  /**
+   * Absolute path of an entry, or null when it would leave the root
+   * @param {string} entryPath
+   * @returns {string|null}
+   */
+  resolve(entryPath) {
+    const parts = entryPath.replace(/\\/g, '/').split('/').filter(part => part && part !== '.');
+    if (parts.length === 0 || parts.includes('..')) return null;
+    return path.join(this.root, ...parts);
+  }
+
+  /**
+   * Write one entry
+   * @param {Object} entry - { path, type: file|directory|link|symlink|other, data?, linkname? }
+   */
+  write(entry) {
+    const target = this.resolve(entry.path);
+    if (!target) {
+      this.stats.skipped++;
+      return;
+    }
+    if (entry.type === 'directory') {
+      fs.mkdirSync(target, { recursive: true });
+      this.stats.directories++;
+      return;
+    }
+    let data = entry.data;
+    if (entry.type === 'link') {
+      // Hard links point at an entry written earlier
+      const source = this.resolve(entry.linkname || '');
+      data = source && fs.existsSync(source) && fs.statSync(source).isFile() ? fs.readFileSync(source) : null;
+    }
+    if (!data || (entry.type !== 'file' && entry.type !== 'link')) {
+      this.stats.skipped++;
+      return;
+    }
+    this.stats.bytes += data.length;
+    if (this.stats.bytes > this.options.maxBytes) {
+      throw new Error(`Archive expands beyond ${formatBytes(this.options.maxBytes)}; refusing to extract more`);
+    }
+    fs.mkdirSync(path.dirname(target), { recursive: true });
+    fs.rmSync(target, { recursive: true, force: true });
+    fs.writeFileSync(target, data);
+    this.stats.files++;
+  }
+
+  /**
+   * Remove an entry (container layer whiteouts)
+   * @param {string} entryPath
+   */
+  remove(entryPath) {
+    const target = this.resolve(entryPath);
+    if (target) fs.rmSync(target, { recursive: true, force: true });
+  }
+
+  /**
+   * Empty a directory, keeping it (opaque whiteouts)
+   * @param {string} entryPath - '' for the root
+   */
+  empty(entryPath) {
+    const target = entryPath ? this.resolve(entryPath) : this.root;
+    if (!target || !fs.existsSync(target)) return;
+    for (const name of fs.readdirSync(target)) {
+      fs.rmSync(path.join(target, name), { recursive: true, force: true });
+    }
+  }
+
+  /**
+   * Count what an external extraction wrote
+   */
+  count() {
+    const walk = dir => {
+      for (const entry of fs.readdirSync(dir, { withFileTypes: true })) {
+        const full = path.join(dir, entry.name);
+        if (entry.isDirectory()) {
+          this.stats.directories++;
+          walk(full);
+        } else if (entry.isFile()) {
+          this.stats.files++;
+          this.stats.bytes += fs.statSync(full).size;
+        } else {
+          fs.rmSync(full, { force: true });
+          this.stats.skipped++;
+        }
+      }
+    };
+    walk(this.root);
+  }
+}
+
+/**
+ * Entries of a tar archive
+ * @param {Buffer} data - Uncompressed tar
+ * @returns {Generator<Object>} { path, type, data, linkname, mode }
+ */
+export function* tarEntries(data) {
+  let offset = 0;
+  let longName = null;
+  let longLink = null;
+  let pax = {};
+  let globalPax = {};
+  while (offset + 512 <= data.length) {
+    const header = data.subarray(offset, offset + 512);
+    if (header.every(byte => byte === 0)) break;
+
+    const size = parseOctal(header.subarray(124, 136));
+    const flag = String.fromCharCode(header[156] || 48);
+    const body = data.subarray(offset + 512, offset + 512 + size);
+    offset += 512 + Math.ceil(size / 512) * 512;
+
+    if (flag === 'L') {
+      longName = cString(body);
+      continue;
+    }
+    if (flag === 'K') {
+      longLink = cString(body);
+      continue;
+    }
+    if (flag === 'x' || flag === 'g') {
+      const records = parsePax(body);
+      if (flag === 'g') globalPax = { ...globalPax, ...records };
+      else pax = records;
+      continue;
+    }
+
+    const magic = header.subarray(257, 262).toString('latin1');
+    const prefix = magic === 'ustar' ? cString(header.subarray(345, 500)) : '';
+    const name = cString(header.subarray(0, 100));
+    const attributes = { ...globalPax, ...pax };
+    const entryPath = attributes.path || longName || (prefix ? `${prefix}/${name}` : name);
+    const linkname = attributes.linkpath || longLink || cString(header.subarray(157, 257));
+    longName = null;
+    longLink = null;
+    pax = {};
+
+    const type = flag === '0' || flag === '7' || header[156] === 0 ? 'file'
+      : flag === '5' ? 'directory'
+        : flag === '1' ? 'link'
+          : flag === '2' ? 'symlink'
+            : 'other';
+    yield {
+      path: entryPath,
+      type: type === 'file' && entryPath.endsWith('/') ? 'directory' : type,
+      data: type === 'file' ? body : null,
+      linkname,
+      mode: parseOctal(header.subarray(100, 108))
+    };
+  }
+}
+
+/**
+ * Entries of a zip archive, read through its central directory
+ * @param {Buffer} data
+ * @param {Object} [options] - { maxBytes } an entry may inflate to
+ * @returns {Generator<Object>} { path, type, data }
+ * @throws {Error} For encrypted entries, unsupported compression methods and entries over maxBytes
+ */
+export function* zipEntries(data, { maxBytes = DEFAULT_MAX_BYTES } = {}) {
+  const end = findSignature(data, 0x06054b50, Math.max(0, data.length - 65557));
+  if (end === -1) throw new Error('Not a zip archive: end of central directory missing');
+  let count = data.readUInt16LE(end + 10);
+  let position = data.readUInt32LE(end + 16);
+
+  // Zip64 keeps counts and offsets that overflow in its own record
+  if (position === 0xffffffff || count === 0xffff) {
+    const locator = findSignature(data, 0x07064b50, Math.max(0, end - 20));
+    if (locator !== -1) {
+      const record = Number(data.readBigUInt64LE(locator + 8));
+      count = Number(data.readBigUInt64LE(record + 32));
+      position = Number(data.readBigUInt64LE(record + 48));
+    }
+  }
+
+  for (let i = 0; i < count; i++) {
+    if (data.readUInt32LE(position) !== 0x02014b50) throw new Error('Corrupt zip central directory');
+    const flags = data.readUInt16LE(position + 8);
+    const method = data.readUInt16LE(position + 10);
+    let compressedSize = data.readUInt32LE(position + 20);
+    let localOffset = data.readUInt32LE(position + 42);
+    const nameLength = data.readUInt16LE(position + 28);
+    const extraLength = data.readUInt16LE(position + 30);
+    const commentLength = data.readUInt16LE(position + 32);
+    const externalAttributes = data.readUInt32LE(position + 38);
+    const name = data.subarray(position + 46, position + 46 + nameLength).toString(flags & 0x800 ? 'utf8' : 'latin1');
+
+    // Zip64 extended information: sizes and offset that do not fit 32 bits, in that order
+    const extra = data.subarray(position + 46 + nameLength, position + 46 + nameLength + extraLength);
+    for (let at = 0; at + 4 <= extra.length; at += 4 + extra.readUInt16LE(at + 2)) {
+      if (extra.readUInt16LE(at) !== 0x0001) continue;
+      let field = at + 4;
+      if (data.readUInt32LE(position + 24) === 0xffffffff) field += 8;
+      if (compressedSize === 0xffffffff) {
+        compressedSize = Number(extra.readBigUInt64LE(field));
+        field += 8;
+      }
+      if (localOffset === 0xffffffff) localOffset = Number(extra.readBigUInt64LE(field));
+    }
+    position += 46 + nameLength + extraLength + commentLength;
+
+    const unixMode = externalAttributes >>> 16;
+    if (name.endsWith('/')) {
+      yield { path: name, type: 'directory', data: null };
+      continue;
+    }
+    if ((unixMode & 0o170000) === 0o120000) {
+      yield { path: name, type: 'symlink', data: null };
+      continue;
+    }
+    if (flags & 0x1) throw new Error(`Encrypted zip entries are not supported: ${name}`);
+
+    const start = localOffset + 30 + data.readUInt16LE(localOffset + 26) + data.readUInt16LE(localOffset + 28);
+    const compressed = data.subarray(start, start + compressedSize);
+    if (method === 0) {
+      yield { path: name, type: 'file', data: compressed };
+    } else if (method === 8) {
+      yield { path: name, type: 'file', data: inflate(() => zlib.inflateRawSync(compressed, { maxOutputLength: maxBytes }), maxBytes) };
+    } else {
+      throw new Error(`Unsupported zip compression method ${method}: ${name}`);
+    }
+  }
+}
+
+/**
+ * Container format by magic bytes
+ * @param {Buffer} data
+ * @returns {string|null} zip, gzip, bzip2, xz, zstd, tar or null
+ */
+export function detect(data) {
+  if (data.length >= 4 && data.readUInt32LE(0) === 0x04034b50) return 'zip';
+  if (data.length >= 4 && data.readUInt32LE(0) === 0x06054b50) return 'zip';
+  if (data[0] === 0x1f && data[1] === 0x8b) return 'gzip';
+  if (data.subarray(0, 3).toString('latin1') === 'BZh') return 'bzip2';
+  if (data.subarray(0, 6).equals(Buffer.from([0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00]))) return 'xz';
+  if (data.length >= 4 && data.readUInt32LE(0) === 0xfd2fb528) return 'zstd';
+  if (data.length >= 262 && data.subarray(257, 262).toString('latin1') === 'ustar') return 'tar';
+  return null;
+}
+
+/**
+ * Gzip stream, at most maxBytes once inflated
+ * @param {Buffer} data
+ * @param {number} [maxBytes]
+ * @returns {Buffer}
+ */
+export function gunzip(data, maxBytes = DEFAULT_MAX_BYTES) {
+  return inflate(() => zlib.gunzipSync(data, { maxOutputLength: maxBytes }), maxBytes);
+}
+
+/**
+ * @private
+ */
+function inflate(run, maxBytes) {
+  try {
+    return run();
+  } catch (error) {
+    if (error.code === 'ERR_BUFFER_TOO_LARGE') {
+      throw new Error(`Archive expands beyond ${formatBytes(maxBytes)}; refusing to extract more`);
+    }
+    throw error;
+  }
+}
+
+/**
+ * @private
+ */
+function readLocal(location) {
+  try {
+    return fs.readFileSync(location);
+  } catch (error) {
+    throw new Error(error.code === 'ENOENT' ? `Archive not found: ${location}` : `Cannot read ${location}: ${error.message}`);
+  }
+}
+
+/**
+ * @private
+ */
+async function download(url, fetchImpl) {
+  let response;
+  try {
+    response = await (fetchImpl || globalThis.fetch)(url, { headers: { 'User-Agent': 'ctxman' }, redirect: 'follow' });
+  } catch (error) {
+    throw new Error(`Download of ${url} failed: ${error.message}`);
+  }
+  if (!response.ok) throw new Error(`Download of ${url} failed: HTTP ${response.status}`);
+  return Buffer.from(await response.arrayBuffer());
+}
+
+/**
+ * bzip2 and xz tarballs; GNU and BSD tar refuse absolute and parent paths themselves
+ * @private
+ */
+function systemTar(data, kind, target) {
+  const tar = spawnSync('tar', [kind === 'xz' ? '-xJf' : '-xjf', '-', '-C', target], { input: data, maxBuffer: 1024 * 1024 * 1024 });
+  if (tar.error || tar.status !== 0) {
+    const reason = tar.error ? tar.error.message : tar.stderr.toString('utf8').trim().split('\n').pop();
+    throw new Error(`Cannot unpack the ${kind} tarball: ${reason}`);
+  }
+}
+
+/**
+ * The only directory of an extracted tree, or the tree itself
+ * @private
+ */
+function singleDirectory(root) {
+  const entries = fs.readdirSync(root, { withFileTypes: true });
+  return entries.length === 1 && entries[0].isDirectory() ? path.join(root, entries[0].name) : root;
+}
+
+/**
+ * @private
+ */
+function findSignature(data, signature, from) {
+  for (let i = data.length - 4; i >= from; i--) {
+    if (data.readUInt32LE(i) === signature) return i;
+  }
+  return -1;
+}
+
+/**
+ * Octal tar number, or base-256 when the high bit is set
+ * @private
+ */
+function parseOctal(field) {
+  if (field[0] & 0x80) {
+    let value = 0;
+    for (let i = 1; i < field.length; i++) value = value * 256 + field[i];
+    return value;
+  }
+  const text = cString(field).trim();
+  return text ? parseInt(text, 8) : 0;
+}
+
+/**
+ * "LEN key=value\n" records of a pax header
+ * @private
+ */
+function parsePax(body) {
+  const records = {};
+  let offset = 0;
+  while (offset < body.length) {
+    const space = body.indexOf(0x20, offset);
+    if (space === -1) break;
+    const length = parseInt(body.subarray(offset, space).toString('latin1'), 10);
+    if (!length) break;
+    const record = body.subarray(space + 1, offset + length - 1).toString('utf8');
+    const equals = record.indexOf('=');
+    if (equals !== -1) records[record.slice(0, equals)] = record.slice(equals + 1);
+    offset += length;
+  }
+  return records;
+}
+
+/**
+ * @private
+ */
+function cString(field) {
+  const nul = field.indexOf(0);
+  return field.subarray(0, nul === -1 ? field.length : nul).toString('utf8');
+}
+
+/**
+ * @private
+ */
+function formatBytes(bytes) {
+  return bytes >= 1024 * 1024 * 1024 ? `${bytes / (1024 * 1024 * 1024)} GiB` : `${Math.round(bytes / (1024 * 1024))} MiB`;
+}
+
+export default ArchiveSource;
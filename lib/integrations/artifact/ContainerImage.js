@@ -0,0 +1,252 @@
+/**
+ * ContainerImage - File systems of container images as scan targets
+ * v3.4.0 - Context of an OCI image (ctxman gen docker://IMAGE[:TAG])
+ *
+ * Responsibilities:
+ * - Parse image references: docker://alpine:3.19, oci://ghcr.io/org/app@sha256:...,
+ *   with Docker Hub as the default registry and library/ as its default namespace
+ * - Pull the manifest from the registry's v2 API with an anonymous (or
+ *   REGISTRY_AUTH user:password) bearer token, picking the platform's
+ *   manifest out of an image index
+ * - Apply the image's layers in order into a temporary directory, honoring
+ *   whiteouts, so the result is the file system the container starts with
+ *
+ * Layer digests are verified before they are applied. Symbolic links and
+ * special files are skipped like those of archives; zstd layers are not
+ * supported. Callers remove the directory once the context is written.
+ */
+
+import crypto from 'crypto';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { TreeWriter, tarEntries, gunzip, detect, DEFAULT_MAX_BYTES } from './ArchiveSource.js';
+
+export const DEFAULT_PLATFORM = 'linux/amd64';
+
+const DOCKER_HUB = 'registry-1.docker.io';
+
+const MANIFEST_TYPES = [
+  'application/vnd.oci.image.index.v1+json',
+  'application/vnd.oci.image.manifest.v1+json',
+  'application/vnd.docker.distribution.manifest.list.v2+json',
+  'application/vnd.docker.distribution.manifest.v2+json'
+];
+
+export class ContainerImage {
+  /**
+   * @param {Object} fields - { registry, repository, tag, digest }
+   */
+  constructor(fields) {
+    Object.assign(this, { tag: null, digest: null, ...fields });
+    this.name = this.repository.split('/').pop();
+  }
+
+  /**
+   * Whether a gen target names an image
+   * @param {string} spec
+   * @returns {boolean}
+   */
+  static isImage(spec) {
+    return /^(?:docker|oci):\/\//i.test(String(spec || ''));
+  }
+
+  /**
+   * Parse an image reference
+   * @param {string} spec - e.g. docker://nginx:1.25, oci://ghcr.io/org/app@sha256:<hex>
+   * @returns {ContainerImage}
+   * @throws {Error} When the reference is malformed
+   */
+  static parse(spec) {
+    const reference = String(spec || '').replace(/^(?:docker|oci):\/\//i, '');
+    const match = /^(?:([^/]+(?:[.:][^/]*|localhost))\/)?([a-z0-9]+(?:[._-][a-z0-9]+)*(?:\/[a-z0-9]+(?:[._-][a-z0-9]+)*)*)(?::([\w][\w.-]{0,127}))?(?:@(sha256:[0-9a-f]{64}))?$/
+      .exec(reference);
+    if (!match || !reference) {
+      throw new Error(`Not an image reference: ${spec} (expected docker://[REGISTRY/]REPOSITORY[:TAG][@sha256:DIGEST])`);
+    }
+    const registry = !match[1] || match[1] === 'docker.io' || match[1] === 'index.docker.io' ? DOCKER_HUB : match[1];
+    const repository = registry === DOCKER_HUB && !match[2].includes('/') ? `library/${match[2]}` : match[2];
+    return new ContainerImage({ registry, repository, tag: match[3] || (match[4] ? null : 'latest'), digest: match[4] || null });
+  }
+
+  /**
+   * Report line, e.g. "nginx:1.25" or "ghcr.io/org/app@sha256:3f2a91c…"
+   * @returns {string}
+   */
+  describe() {
+    const registry = this.registry === DOCKER_HUB ? '' : `${this.registry}/`;
+    const repository = this.registry === DOCKER_HUB ? this.repository.replace(/^library\//, '') : this.repository;
+    const digest = this.digest ? `@${this.digest.slice(0, 19)}…` : '';
+    return `${registry}${repository}${this.tag ? `:${this.tag}` : ''}${digest}`;
+  }
+
+  /**
+   * Pull the image and apply its layers into a new temporary directory
+   * @param {Object} [options]
+   * @param {string} [options.platform] - os/arch[/variant] picked from an index (default DEFAULT_PLATFORM)
+   * @param {string} [options.auth] - user:password for the token (default: REGISTRY_AUTH)
+   * @param {number} [options.maxBytes] - Extracted size limit (default DEFAULT_MAX_BYTES)
+   * @param {Function} [options.fetch] - fetch implementation (tests)
+   * @returns {Promise<{root: string, dir: string, method: string, commit: string, stats: Object}>} commit
+   *   is the manifest digest; see ArchiveSource.checkout()
+   * @throws {Error} When the image cannot be pulled
+   */
+  async checkout(options = {}) {
+    const session = { fetch: options.fetch || globalThis.fetch, auth: options.auth ?? process.env.REGISTRY_AUTH, token: null };
+    const platform = options.platform || DEFAULT_PLATFORM;
+
+    let { manifest, digest } = await this.manifest(session, this.digest || this.tag);
+    if (manifest.manifests) {
+      const chosen = manifest.manifests.find(entry => matchesPlatform(entry.platform, platform));
+      if (!chosen) {
+        const available = manifest.manifests.map(entry => formatPlatform(entry.platform)).filter(Boolean).join(', ');
+        throw new Error(`${this.describe()} has no ${platform} image (available: ${available || 'none'})`);
+      }
+      ({ manifest, digest } = await this.manifest(session, chosen.digest));
+    }
+    if (!Array.isArray(manifest.layers)) {
+      throw new Error(`Unsupported manifest for ${this.describe()}: ${manifest.mediaType || 'no layers'}`);
+    }
+
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-image-'));
+    const root = path.join(dir, this.name);
+    try {
+      const maxBytes = options.maxBytes ?? DEFAULT_MAX_BYTES;
+      const writer = new TreeWriter(root, { maxBytes });
+      for (const layer of manifest.layers) {
+        const blob = await this.blob(session, layer.digest);
+        const kind = detect(blob);
+        if (kind === 'zstd') throw new Error(`zstd layers are not supported: ${layer.digest}`);
+        applyLayer(writer, tarEntries(kind === 'gzip' ? gunzip(blob, maxBytes) : blob));
+      }
+      return { root, dir, method: 'image', commit: digest, stats: { ...writer.stats, layers: manifest.layers.length } };
+    } catch (error) {
+      fs.rmSync(dir, { recursive: true, force: true });
+      throw error;
+    }
+  }
+
+  /**
+   * Manifest or index of a tag or digest
+   * @private
+   */
+  async manifest(session, reference) {
+    const response = await this.request(session, `manifests/${reference}`, { Accept: MANIFEST_TYPES.join(', ') });
+    const body = Buffer.from(await response.arrayBuffer());
+    const digest = response.headers?.get?.('docker-content-digest') || `sha256:${sha256(body)}`;
+    if (reference.startsWith('sha256:') && `sha256:${sha256(body)}` !== reference) {
+      throw new Error(`Manifest of ${this.describe()} does not match ${reference}`);
+    }
+    return { manifest: JSON.parse(body.toString('utf8')), digest };
+  }
+
+  /**
+   * Layer blob, verified against its digest
+   * @private
+   */
+  async blob(session, digest) {
+    const response = await this.request(session, `blobs/${digest}`);
+    const data = Buffer.from(await response.arrayBuffer());
+    if (digest.startsWith('sha256:') && `sha256:${sha256(data)}` !== digest) {
+      throw new Error(`Layer ${digest} of ${this.describe()} is corrupt (digest mismatch)`);
+    }
+    return data;
+  }
+
+  /**
+   * GET on the v2 API, fetching a bearer token when the registry asks for one
+   * @private
+   */
+  async request(session, resource, headers = {}) {
+    const url = `https://${this.registry}/v2/${this.repository}/${resource}`;
+    const send = () => session.fetch(url, {
+      headers: { 'User-Agent': 'ctxman', ...headers, ...(session.token ? { Authorization: `Bearer ${session.token}` } : {}) },
+      redirect: 'follow'
+    });
+
+    let response;
+    try {
+      response = await send();
+      if (response.status === 401 && !session.token) {
+        session.token = await this.token(session, response.headers?.get?.('www-authenticate') || '');
+        response = await send();
+      }
+    } catch (error) {
+      if (error.message.startsWith('Registry')) throw error;
+      throw new Error(`Pull of ${this.describe()} failed: ${error.message}`);
+    }
+    if (!response.ok) {
+      const hint = [401, 403, 404].includes(response.status) && !session.auth ? ' (private images need REGISTRY_AUTH=user:password)' : '';
+      throw new Error(`Registry returned ${response.status} for ${this.describe()}${hint}`);
+    }
+    return response;
+  }
+
+  /**
+   * Bearer token named by a WWW-Authenticate challenge
+   * @private
+   */
+  async token(session, challenge) {
+    const fields = Object.fromEntries([...challenge.matchAll(/(\w+)="([^"]*)"/g)].map(match => [match[1], match[2]]));
+    if (!/^bearer/i.test(challenge) || !fields.realm) {
+      throw new Error(`Registry ${this.registry} asks for unsupported authentication: ${challenge || 'none'}`);
+    }
+    const url = new URL(fields.realm);
+    if (fields.service) url.searchParams.set('service', fields.service);
+    url.searchParams.set('scope', fields.scope || `repository:${this.repository}:pull`);
+    const headers = { 'User-Agent': 'ctxman' };
+    if (session.auth) headers.Authorization = `Basic ${Buffer.from(session.auth).toString('base64')}`;
+
+    const response = await session.fetch(url.toString(), { headers });
+    if (!response.ok) {
+      throw new Error(`Registry token for ${this.describe()} refused: HTTP ${response.status}`);
+    }
+    const body = await response.json();
+    return body.token || body.access_token;
+  }
+}
+
+/**
+ * Apply one layer: whiteouts remove what lower layers wrote, then entries are written
+ * @param {TreeWriter} writer
+ * @param {Iterable<Object>} entries - Tar entries of the layer
+ */
+export function applyLayer(writer, entries) {
+  for (const entry of entries) {
+    const dir = path.posix.dirname(entry.path.replace(/^\.?\/+/, ''));
+    const name = path.posix.basename(entry.path);
+    if (name === '.wh..wh..opq') {
+      writer.empty(dir === '.' ? '' : dir);
+    } else if (name.startsWith('.wh.')) {
+      writer.remove(path.posix.join(dir, name.slice(4)));
+    } else {
+      writer.write(entry);
+    }
+  }
+}
+
+/**
+ * @private
+ */
+function matchesPlatform(platform, wanted) {
+  if (!platform) return false;
+  const [os, architecture, variant] = wanted.split('/');
+  return platform.os === os && platform.architecture === architecture && (!variant || platform.variant === variant);
+}
+
+/**
+ * @private
+ */
+function formatPlatform(platform) {
+  if (!platform || platform.os === 'unknown') return null;
+  return [platform.os, platform.architecture, platform.variant].filter(Boolean).join('/');
+}
+
+/**
+ * @private
+ */
+function sha256(data) {
+  return crypto.createHash('sha256').update(data).digest('hex');
+}
+
+export default ContainerImage;
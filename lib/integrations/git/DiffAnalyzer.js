@@ -2,15 +2,23 @@
  * DiffAnalyzer - Git Diff Analysis Module
  * v3.0.0 - Complete Git integration
  *
+ * v3.4.0 - Changed line ranges and diff-scoped context
+ *
  * Responsibilities:
  * - Analyze git diffs
  * - Detect change impact
  * - Find related files
  * - Generate change summaries
+ * - Map changed lines to the symbols they touch
  */
 
+import path from 'path';
 import GitClient from './GitClient.js';
+import DependencyGraph from '../../graph/DependencyGraph.js';
+import { SymbolKind } from '../../symbols/SymbolModel.js';
+import { nativeFileSystem } from '../../core/FileSystem.js';
 import { getLogger } from '../../utils/logger.js';
+import { compareBytes } from '../../utils/ordering.js';
 
 const logger = getLogger('DiffAnalyzer');
 
@@ -174,14 +182,207 @@ export class DiffAnalyzer {
 
   /**
    * Find related files based on imports/dependencies
+   * Related files are the other files of changed packages, the packages
+   * they import and the packages importing them.
    * @param {Array<string>} changedFiles
+   * @param {DependencyGraph} graph - Built graph (default: built from tracked files)
    * @returns {Array<string>}
    */
-  findRelatedFiles(changedFiles) {
-    // TODO: Implement import graph analysis
-    // For now, return empty array
-    logger.debug('Related files detection not yet implemented');
-    return [];
+  findRelatedFiles(changedFiles, graph = null) {
+    if (!graph) {
+      const tracked = this.git.exec('ls-files').split('\n').filter(Boolean);
+      graph = new DependencyGraph({ root: this.repoPath }).build(tracked.map(relativePath => ({ relativePath })));
+    }
+
+    const changed = new Set(changedFiles);
+    const related = new Set();
+
+    for (const file of changedFiles) {
+      const node = graph.getFile(file);
+      if (!node) continue;
+
+      const packages = [node.package, ...graph.getDependencies(node.package), ...graph.getDependents(node.package)];
+      for (const packageId of packages) {
+        for (const relatedFile of graph.getPackage(packageId)?.files || []) {
+          if (!changed.has(relatedFile)) related.add(relatedFile);
+        }
+      }
+    }
+
+    logger.debug(`Found ${related.size} files related to ${changedFiles.length} changed files`);
+    return [...related].sort(compareBytes);
+  }
+
+  /**
+   * Changed line ranges per file (v3.4.0)
+   * Without a base, compares the working tree (staged and unstaged) with
+   * HEAD and reports untracked files as added. A base may be a commit,
+   * a branch, or a range such as main..feature.
+   * @param {string} base - Commit/branch reference or range
+   * @returns {Array<FileLineChanges>} { path, oldPath, status, ranges, added, deleted }
+   *   ranges lists changed [start, end] lines of the new file, null for whole files
+   */
+  getChangedLines(base = null) {
+    if (base && !this.git.validateGitRef(base)) {
+      throw new Error(`Invalid git reference: ${base}`);
+    }
+
+    const hasHead = (() => {
+      try {
+        this.git.exec('rev-parse --verify HEAD');
+        return true;
+      } catch (error) {
+        return false;
+      }
+    })();
+
+    const changes = base || hasHead
+      ? DiffAnalyzer.parseChangedLines(this.git.exec(`diff -U0 --no-color --find-renames ${base || 'HEAD'}`))
+      : [];
+
+    // Untracked files only count for working-tree comparisons
+    if (!base || !base.includes('..')) {
+      const known = new Set(changes.map(change => change.path));
+      const untracked = hasHead ? this.git.getUntrackedFiles() : this.git.exec('ls-files --others --cached --exclude-standard').split('\n').filter(Boolean);
+
+      for (const file of untracked) {
+        if (known.has(file)) continue;
+        let lines = 0;
+        try {
+          const content = nativeFileSystem.readText(path.join(this.repoPath, file));
+          lines = content === '' ? 0 : content.replace(/\n$/, '').split('\n').length;
+        } catch (error) {
+          continue;
+        }
+        changes.push({ path: file, oldPath: null, status: 'added', ranges: null, added: lines, deleted: 0 });
+      }
+    }
+
+    return changes.sort((a, b) => compareBytes(a.path, b.path));
+  }
+
+  /**
+   * Parse `git diff -U0` output into changed line ranges
+   * Pure deletions mark the line after the removed block.
+   * @param {string} diffOutput
+   * @returns {Array<FileLineChanges>}
+   */
+  static parseChangedLines(diffOutput) {
+    const changes = [];
+    let current = null;
+
+    for (const line of diffOutput.split('\n')) {
+      const header = line.match(/^diff --git a\/(.+) b\/(.+)$/);
+      if (header) {
+        current = { path: header[2], oldPath: null, status: 'modified', ranges: [], added: 0, deleted: 0 };
+        changes.push(current);
+        continue;
+      }
+      if (!current) continue;
+
+      if (line.startsWith('new file mode')) {
+        current.status = 'added';
+      } else if (line.startsWith('deleted file mode')) {
+        current.status = 'deleted';
+      } else if (line.startsWith('rename from ')) {
+        current.status = 'renamed';
+        current.oldPath = line.slice('rename from '.length);
+      } else if (line.startsWith('Binary files')) {
+        current.ranges = null;
+      } else {
+        const hunk = line.match(/^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@/);
+        if (hunk) {
+          const deleted = hunk[1] === undefined ? 1 : Number(hunk[1]);
+          const start = Number(hunk[2]);
+          const count = hunk[3] === undefined ? 1 : Number(hunk[3]);
+
+          current.ranges?.push(count === 0 ? [start + 1, start + 1] : [start, start + count - 1]);
+          current.added += count;
+          current.deleted += deleted;
+        }
+      }
+    }
+
+    return changes;
+  }
+
+  /**
+   * Symbols touched by changed lines (v3.4.0)
+   * A changed line counts for the innermost symbol containing it. Added
+   * files, files without a language plugin and files changed only outside
+   * their symbols (imports, top-level code) are kept whole.
+   * @param {Array<FileLineChanges>} changes - From getChangedLines()
+   * @param {DependencyGraph} graph - Graph over the current tree
+   * @returns {{symbols: Array<Object>, wholeFiles: Array<string>, deleted: Array<string>}}
+   */
+  static mapChangesToSymbols(changes, graph) {
+    const symbols = [];
+    const wholeFiles = [];
+    const deleted = [];
+
+    for (const change of changes) {
+      if (change.status === 'deleted') {
+        deleted.push(change.path);
+        continue;
+      }
+
+      const file = graph.getFile(change.path);
+      if (!file || !change.ranges || change.status === 'added') {
+        wholeFiles.push(change.path);
+        continue;
+      }
+
+      const candidates = file.symbols.filter(symbol => symbol.kind !== SymbolKind.MODULE);
+      const touched = new Set();
+      let outside = false;
+
+      for (const [start, end] of change.ranges) {
+        for (let line = start; line <= end; line++) {
+          const innermost = candidates
+            .filter(symbol => symbol.startLine <= line && symbol.endLine >= line)
+            .sort((a, b) => (a.endLine - a.startLine) - (b.endLine - b.startLine))[0];
+          if (innermost) touched.add(innermost);
+          else outside = true;
+        }
+      }
+
+      if (touched.size === 0 && outside) {
+        wholeFiles.push(change.path);
+      } else {
+        symbols.push(...[...touched].sort((a, b) => a.startLine - b.startLine));
+      }
+    }
+
+    return { symbols, wholeFiles, deleted };
+  }
+
+  /**
+   * Format a diff scope as a console report (v3.4.0)
+   * @param {Object} scope - { base, changes, touched, expansion }
+   * @returns {string}
+   */
+  static formatDiffScope(scope) {
+    const lines = [];
+    const { changes, touched, expansion } = scope;
+
+    lines.push('');
+    lines.push('🔀 DIFF CONTEXT');
+    lines.push('='.repeat(80));
+    lines.push(`   Base:         ${scope.base || 'HEAD (working tree)'}`);
+    lines.push(`   Files:        ${changes.length} changed` + (touched.deleted.length > 0 ? ` (${touched.deleted.length} deleted)` : ''));
+    lines.push(`   Symbols:      ${touched.symbols.length} touched, ${touched.wholeFiles.length} whole files`);
+    lines.push(`   Dependencies: ${expansion.dependencies.length} definitions (depth ${expansion.depth})`);
+
+    if (changes.length > 0) {
+      lines.push('');
+      for (const change of changes) {
+        const names = touched.symbols.filter(symbol => symbol.file === change.path).map(symbol => symbol.qualifiedName);
+        const detail = touched.wholeFiles.includes(change.path) ? 'whole file' : names.join(', ');
+        lines.push(`   ${change.status.padEnd(9)} ${change.path} (+${change.added}/-${change.deleted})${detail ? ` → ${detail}` : ''}`);
+      }
+    }
+
+    return lines.join('\n');
   }
 
   /**
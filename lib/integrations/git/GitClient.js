@@ -250,6 +250,54 @@ export class GitClient {
     }
   }
 
+  /**
+   * Get commit counts per file over a recent window
+   * @param {number} days - Window size in days
+   * @returns {Map<string, number>} Commits touching each file
+   */
+  getChurn(days = 90) {
+    if (!Number.isInteger(days) || days <= 0) {
+      throw new Error(`Invalid churn window: ${days}`);
+    }
+
+    const output = this.exec(`log --since=${days}.days --name-only --pretty=format:`);
+    const churn = new Map();
+    for (const file of output.split('\n').filter(Boolean)) {
+      churn.set(file, (churn.get(file) || 0) + 1);
+    }
+    return churn;
+  }
+
+  /**
+   * Get how often other files changed in the same commits as some files
+   * @param {Array<string>} files - Repository-relative paths
+   * @param {number} limit - Most recent commits to read
+   * @param {number} maxFiles - Larger commits (mass renames, reformatting) are skipped
+   * @returns {Map<string, {commits: number, files: Set<string>}>} Commits shared with
+   *   the given files, and which of them, for each other file
+   */
+  getCoChanges(files, limit = 500, maxFiles = 50) {
+    const wanted = new Set(files);
+    const output = this.exec(`log -n ${limit} --name-only --pretty=format:%x1e`);
+    const coChanges = new Map();
+
+    for (const commit of output.split('\x1e')) {
+      const changed = commit.split('\n').filter(Boolean);
+      if (changed.length > maxFiles) continue;
+      const shared = changed.filter(file => wanted.has(file));
+      if (shared.length === 0) continue;
+
+      for (const file of changed) {
+        if (wanted.has(file)) continue;
+        if (!coChanges.has(file)) coChanges.set(file, { commits: 0, files: new Set() });
+        const entry = coChanges.get(file);
+        entry.commits++;
+        shared.forEach(seed => entry.files.add(seed));
+      }
+    }
+    return coChanges;
+  }
+
   /**
    * Get file authors
    * @param {string} filePath
@@ -0,0 +1,191 @@
+/**
+ * GitRevision - Project files as they were at a commit
+ * v3.4.0 - Historical context (--rev)
+ *
+ * Responsibilities:
+ * - Resolve a revision (commit, branch, tag, HEAD~3) to a commit
+ * - List the files of the project directory in that commit's tree
+ * - Read their contents from the object database, without checking out
+ *
+ * Paths are relative to the project root, which may be a subdirectory of
+ * the repository. Contents are loaded with one `git cat-file --batch` per
+ * preload() and kept in memory.
+ */
+
+import path from 'path';
+import { spawnSync } from 'child_process';
+import { decodeText } from '../../core/FileSystem.js';
+import { getLogger } from '../../utils/logger.js';
+
+const logger = getLogger('GitRevision');
+
+// Regular and executable files; symlinks (120000) and submodules (160000) have no contents to export
+const FILE_MODES = new Set(['100644', '100755']);
+
+export class GitRevision {
+  /**
+   * @param {string} root - Project root
+   * @param {string} spec - Revision as given (e.g. v1.2.0, HEAD~3)
+   * @param {string} commit - Full commit hash
+   */
+  constructor(root, spec, commit) {
+    this.root = path.resolve(root);
+    this.spec = spec;
+    this.commit = commit;
+    this.entries = null;
+    this.contents = new Map();
+  }
+
+  /**
+   * @param {string} root - Project root inside a git repository
+   * @param {string} spec - Any revision `git rev-parse` accepts
+   * @returns {GitRevision}
+   * @throws {Error} When the root is not in a repository or the revision does not name a commit
+   */
+  static open(root, spec) {
+    if (!spec || spec.startsWith('-')) {
+      throw new Error(`Invalid revision: ${spec}`);
+    }
+
+    const run = git(root, ['rev-parse', '--verify', '--quiet', '--end-of-options', `${spec}^{commit}`]);
+    if (run.status !== 0) {
+      const inRepository = git(root, ['rev-parse', '--git-dir']).status === 0;
+      throw new Error(inRepository
+        ? `Unknown revision: ${spec} (expected a commit, branch or tag)`
+        : `--rev needs a git repository, and ${root} is not in one`);
+    }
+    return new GitRevision(root, spec, run.stdout.toString().trim());
+  }
+
+  /**
+   * @returns {string} e.g. "v1.2.0 (3f2a9c1b7d4e)"
+   */
+  describe() {
+    const short = this.commit.slice(0, 12);
+    return this.spec === this.commit || this.commit.startsWith(this.spec) ? short : `${this.spec} (${short})`;
+  }
+
+  /**
+   * Files of the project directory at the revision
+   * @returns {Array<{relativePath: string, size: number}>} '/'-separated paths, in tree order
+   */
+  listFiles() {
+    return [...this.getEntries()].map(([relativePath, entry]) => ({ relativePath, size: entry.size }));
+  }
+
+  /**
+   * @private
+   */
+  getEntries() {
+    if (this.entries) return this.entries;
+
+    const run = git(this.root, ['ls-tree', '-r', '-z', '--long', this.commit]);
+    if (run.status !== 0) {
+      throw new Error(`Cannot list ${this.describe()}: ${run.stderr.toString().trim()}`);
+    }
+
+    this.entries = new Map();
+    for (const record of run.stdout.toString('utf8').split('\0')) {
+      const match = record.match(/^(\d+) (\w+) ([0-9a-f]+) +(\d+|-)\t(.+)$/s);
+      if (!match || match[2] !== 'blob' || !FILE_MODES.has(match[1])) continue;
+      this.entries.set(match[5], { oid: match[3], size: Number(match[4]) });
+    }
+    logger.debug(`${this.describe()}: ${this.entries.size} files`);
+    return this.entries;
+  }
+
+  /**
+   * @param {string} filePath - Absolute, or relative to the project root
+   * @returns {boolean}
+   */
+  has(filePath) {
+    return this.getEntries().has(this.toRelative(filePath));
+  }
+
+  /**
+   * Size in bytes at the revision
+   * @param {string} filePath - Absolute, or relative to the project root
+   * @returns {number}
+   * @throws {Error} When the file is not in the revision
+   */
+  size(filePath) {
+    return this.entry(filePath).size;
+  }
+
+  /**
+   * Content at the revision
+   * @param {string} filePath - Absolute, or relative to the project root
+   * @returns {string}
+   * @throws {Error} When the file is not in the revision (code ENOENT, like fs)
+   */
+  read(filePath) {
+    const relativePath = this.toRelative(filePath);
+    if (!this.contents.has(relativePath)) {
+      this.preload([relativePath]);
+    }
+    if (!this.contents.has(relativePath)) {
+      this.entry(filePath);
+    }
+    return this.contents.get(relativePath);
+  }
+
+  /**
+   * Load the contents of many files with a single git process
+   * @param {Array<string>} filePaths - Absolute, or relative to the project root
+   */
+  preload(filePaths) {
+    const entries = this.getEntries();
+    const wanted = [...new Set(filePaths.map(filePath => this.toRelative(filePath)))]
+      .filter(relativePath => entries.has(relativePath) && !this.contents.has(relativePath));
+    if (wanted.length === 0) return;
+
+    const run = git(this.root, ['cat-file', '--batch'], wanted.map(relativePath => entries.get(relativePath).oid).join('\n') + '\n');
+    if (run.status !== 0) {
+      throw new Error(`Cannot read ${this.describe()}: ${run.stderr.toString().trim()}`);
+    }
+
+    // Each object: "<oid> blob <size>\n<content>\n"
+    const output = run.stdout;
+    let offset = 0;
+    for (const relativePath of wanted) {
+      const headerEnd = output.indexOf(0x0a, offset);
+      const size = Number(output.subarray(offset, headerEnd).toString().split(' ')[2]);
+      this.contents.set(relativePath, decodeText(output.subarray(headerEnd + 1, headerEnd + 1 + size)));
+      offset = headerEnd + 1 + size + 1;
+    }
+  }
+
+  /**
+   * @private
+   */
+  entry(filePath) {
+    const entry = this.getEntries().get(this.toRelative(filePath));
+    if (!entry) {
+      const error = new Error(`ENOENT: ${this.toRelative(filePath)} is not in ${this.describe()}`);
+      error.code = 'ENOENT';
+      throw error;
+    }
+    return entry;
+  }
+
+  /**
+   * @private
+   */
+  toRelative(filePath) {
+    const relativePath = path.isAbsolute(filePath) ? path.relative(this.root, filePath) : filePath;
+    return relativePath.split(path.sep).join('/');
+  }
+}
+
+/**
+ * @private
+ */
+function git(cwd, args, input = undefined) {
+  const run = spawnSync('git', args, { cwd, input, maxBuffer: 1024 * 1024 * 1024 });
+  if (run.error) {
+    throw new Error(`git failed: ${run.error.message}`);
+  }
+  return run;
+}
+
+export default GitRevision;
@@ -0,0 +1,401 @@
+/**
+ * PullRequest - GitHub pull requests and GitLab merge requests
+ * v3.4.0 - Review context for a PR/MR (ctxman pr)
+ *
+ * Responsibilities:
+ * - Resolve a PR/MR URL, or a number with the project's origin remote
+ * - Fetch the description, changed files with their diffs, review comments
+ *   and discussion through the GitHub (REST v3) or GitLab (REST v4) API
+ * - Render a review prompt that combines them with local code context
+ *
+ * Tokens come from GITHUB_TOKEN/GH_TOKEN or GITLAB_TOKEN; public projects
+ * work without one, within the providers' anonymous rate limits.
+ */
+
+export const PR_PROVIDERS = ['github', 'gitlab'];
+
+// Items per API page, and the most pages read per list
+const PAGE_SIZE = 100;
+const MAX_PAGES = 30;
+
+const GITHUB_URL = /^(?:https?:\/\/)?([^/\s]+)\/([^/\s]+\/[^/\s]+)\/pull\/(\d+)/;
+const GITLAB_URL = /^(?:https?:\/\/)?([^/\s]+)\/(.+?)\/-\/merge_requests\/(\d+)/;
+
+/**
+ * Parse a PR/MR reference
+ * @param {string} reference - URL, or a number (123, #123, !123) of the remote's project
+ * @param {Object} options
+ * @param {string} options.remoteUrl - Origin remote, needed for numbers
+ * @param {string} options.provider - github | gitlab, for hosts whose name does not tell
+ * @returns {Object} { provider, host, project, number, apiBase }
+ * @throws {Error} When the reference cannot be resolved
+ */
+export function parsePullRequestRef(reference, options = {}) {
+  const value = String(reference || '').trim();
+  const github = GITHUB_URL.exec(value);
+  const gitlab = !github && GITLAB_URL.exec(value);
+  if (github || gitlab) {
+    const [, host, project, number] = github || gitlab;
+    return describe(options.provider || (github ? 'github' : 'gitlab'), host, project, Number(number));
+  }
+
+  const number = /^[#!]?(\d+)$/.exec(value);
+  if (!number) {
+    throw new Error(`Not a pull request URL or number: ${value} (expected https://github.com/OWNER/REPO/pull/N, a GitLab merge request URL, or N)`);
+  }
+  const remote = parseRemote(options.remoteUrl);
+  if (!remote) {
+    throw new Error(`Pull request ${value} needs a URL here: the project has no origin remote to resolve it against`);
+  }
+  const provider = options.provider || (/gitlab/i.test(remote.host) ? 'gitlab' : 'github');
+  return describe(provider, remote.host, remote.project, Number(number[1]));
+}
+
+export class PullRequest {
+  /**
+   * @param {Object} fields - { provider, number, url, title, description, author, base, head, headSha, files, comments }
+   */
+  constructor(fields) {
+    Object.assign(this, {
+      description: '',
+      files: [], // { path, previousPath, status, additions, deletions, patch }
+      comments: [], // { path, line, author, body }; path is null for the discussion
+      ...fields
+    });
+  }
+
+  /**
+   * Fetch a PR/MR from its provider
+   * @param {Object} reference - From parsePullRequestRef
+   * @param {Object} options
+   * @param {string} options.token - API token (defaults to the provider's environment variable)
+   * @param {Function} options.fetch - fetch implementation (tests)
+   * @returns {Promise<PullRequest>}
+   */
+  static async fetch(reference, options = {}) {
+    const api = new ProviderApi(reference, options);
+    return reference.provider === 'gitlab' ? fetchMergeRequest(api, reference) : fetchPullRequest(api, reference);
+  }
+
+  /**
+   * Changed files that exist after the change, for local code context
+   * @returns {Array<string>}
+   */
+  changedPaths() {
+    return this.files.filter(file => file.status !== 'removed').map(file => file.path);
+  }
+
+  /**
+   * Changed line ranges of the new files, as DiffAnalyzer.getChangedLines() reports them
+   * Added files and files without a textual diff count as whole files.
+   * @returns {Array<Object>} { path, oldPath, status, ranges, added, deleted }
+   */
+  lineChanges() {
+    return this.files.map(file => ({
+      path: file.path,
+      oldPath: file.previousPath,
+      status: file.status === 'removed' ? 'deleted' : file.status === 'renamed' ? 'renamed' : file.status === 'added' ? 'added' : 'modified',
+      ranges: file.status === 'added' || !file.patch ? null : changedRanges(file.patch),
+      added: file.additions,
+      deleted: file.deletions
+    }));
+  }
+
+  /**
+   * Review prompt: description, changed files, comments, diffs and code
+   * @param {string|null} context - Local code context (a digest)
+   * @returns {string} Markdown
+   */
+  formatPrompt(context = null) {
+    const additions = this.files.reduce((sum, file) => sum + file.additions, 0);
+    const deletions = this.files.reduce((sum, file) => sum + file.deletions, 0);
+    const label = this.provider === 'gitlab' ? `!${this.number}` : `#${this.number}`;
+    const out = [
+      `# Review ${label}: ${this.title}`,
+      '',
+      this.url,
+      `Author: @${this.author} · ${this.head} → ${this.base} · ${this.files.length} files changed (+${additions} −${deletions})`,
+      '',
+      '## Description',
+      '',
+      this.description.trim() || '_No description._',
+      '',
+      '## Changed files',
+      ''
+    ];
+    for (const file of this.files) {
+      const renamed = file.previousPath ? ` (from \`${file.previousPath}\`)` : '';
+      out.push(`- \`${file.path}\`${renamed}: ${file.status}, +${file.additions} −${file.deletions}`);
+    }
+
+    const review = this.comments.filter(comment => comment.path);
+    if (review.length > 0) {
+      out.push('', '## Review comments');
+      for (const [where, thread] of groupBy(review, comment => `\`${comment.path}\`${comment.line ? ` line ${comment.line}` : ''}`)) {
+        out.push('', `### ${where}`, '');
+        for (const comment of thread) out.push(`**@${comment.author}**: ${comment.body.trim()}`, '');
+        out.pop();
+      }
+    }
+    const discussion = this.comments.filter(comment => !comment.path);
+    if (discussion.length > 0) {
+      out.push('', '## Discussion', '');
+      for (const comment of discussion) out.push(`**@${comment.author}**: ${comment.body.trim()}`, '');
+      out.pop();
+    }
+
+    out.push('', '## Diff');
+    for (const file of this.files) {
+      out.push('', `### \`${file.path}\``, '');
+      out.push(file.patch ? `\`\`\`diff\n${file.patch.replace(/\n$/, '')}\n\`\`\`` : '_No textual diff (binary or too large)._');
+    }
+
+    if (context) {
+      out.push('', '## Code context', '', 'Symbols the change touches and the definitions they use, from the local checkout:', '', context.trimEnd());
+    }
+
+    out.push('', '## Task', '',
+      'Review this change as a senior maintainer. Point out bugs, missing edge cases and tests,',
+      'and unclear code, referencing files and lines; address the open review comments.',
+      'Finish with a verdict: approve, or request changes with a prioritized list.', '');
+    return out.join('\n');
+  }
+
+  /**
+   * One-line description for the CLI
+   * @returns {string}
+   */
+  summary() {
+    const reviewComments = this.comments.filter(comment => comment.path).length;
+    return `${this.title} (${this.files.length} files, ${reviewComments} review comments, ${this.comments.length - reviewComments} discussion comments)`;
+  }
+}
+
+/**
+ * Authenticated JSON requests with pagination
+ * @private
+ */
+class ProviderApi {
+  constructor(reference, options) {
+    this.reference = reference;
+    this.fetch = options.fetch || globalThis.fetch;
+    this.label = reference.provider === 'gitlab' ? 'GitLab' : 'GitHub';
+    this.token = options.token ?? (reference.provider === 'gitlab'
+      ? process.env.GITLAB_TOKEN
+      : process.env.GITHUB_TOKEN || process.env.GH_TOKEN);
+  }
+
+  headers() {
+    const headers = { 'User-Agent': 'ctxman', Accept: 'application/json' };
+    if (this.reference.provider === 'github') {
+      headers.Accept = 'application/vnd.github+json';
+      if (this.token) headers.Authorization = `Bearer ${this.token}`;
+    } else if (this.token) {
+      headers['PRIVATE-TOKEN'] = this.token;
+    }
+    return headers;
+  }
+
+  async get(endpoint) {
+    const url = `${this.reference.apiBase}/${endpoint}`;
+    let response;
+    try {
+      response = await this.fetch(url, { headers: this.headers() });
+    } catch (error) {
+      throw new Error(`${this.label} request to ${url} failed: ${error.message}`);
+    }
+
+    if (!response.ok) {
+      const detail = (await response.text().catch(() => '')).slice(0, 200);
+      const hint = [401, 403, 404].includes(response.status) && !this.token
+        ? ` (private projects need ${this.reference.provider === 'gitlab' ? 'GITLAB_TOKEN' : 'GITHUB_TOKEN'})`
+        : '';
+      throw new Error(`${this.label} returned ${response.status} for ${endpoint}${detail ? `: ${detail}` : ''}${hint}`);
+    }
+    return response.json();
+  }
+
+  async list(endpoint) {
+    const items = [];
+    const separator = endpoint.includes('?') ? '&' : '?';
+    for (let page = 1; page <= MAX_PAGES; page++) {
+      const batch = await this.get(`${endpoint}${separator}per_page=${PAGE_SIZE}&page=${page}`);
+      items.push(...batch);
+      if (batch.length < PAGE_SIZE) break;
+    }
+    return items;
+  }
+}
+
+/**
+ * @private
+ */
+async function fetchPullRequest(api, reference) {
+  const base = `repos/${reference.project}/pulls/${reference.number}`;
+  const [pull, files, reviewComments, issueComments] = await Promise.all([
+    api.get(base),
+    api.list(`${base}/files`),
+    api.list(`${base}/comments`),
+    api.list(`repos/${reference.project}/issues/${reference.number}/comments`)
+  ]);
+
+  return new PullRequest({
+    provider: 'github',
+    number: reference.number,
+    url: pull.html_url,
+    title: pull.title,
+    description: pull.body || '',
+    author: pull.user?.login,
+    base: pull.base?.ref,
+    head: pull.head?.ref,
+    headSha: pull.head?.sha,
+    files: files.map(file => ({
+      path: file.filename,
+      previousPath: file.previous_filename || null,
+      status: file.status,
+      additions: file.additions,
+      deletions: file.deletions,
+      patch: file.patch || null
+    })),
+    comments: [
+      ...reviewComments.map(comment => ({
+        path: comment.path,
+        line: comment.line ?? comment.original_line ?? null,
+        author: comment.user?.login,
+        body: comment.body
+      })),
+      ...issueComments.map(comment => ({ path: null, line: null, author: comment.user?.login, body: comment.body }))
+    ]
+  });
+}
+
+/**
+ * @private
+ */
+async function fetchMergeRequest(api, reference) {
+  const base = `projects/${encodeURIComponent(reference.project)}/merge_requests/${reference.number}`;
+  const [merge, diffs, discussions] = await Promise.all([
+    api.get(base),
+    api.list(`${base}/diffs`),
+    api.list(`${base}/discussions`)
+  ]);
+
+  const comments = discussions
+    .flatMap(discussion => discussion.notes || [])
+    .filter(note => !note.system)
+    .map(note => ({
+      path: note.position ? note.position.new_path || note.position.old_path : null,
+      line: note.position ? note.position.new_line ?? note.position.old_line ?? null : null,
+      author: note.author?.username,
+      body: note.body
+    }));
+
+  return new PullRequest({
+    provider: 'gitlab',
+    number: reference.number,
+    url: merge.web_url,
+    title: merge.title,
+    description: merge.description || '',
+    author: merge.author?.username,
+    base: merge.target_branch,
+    head: merge.source_branch,
+    headSha: merge.diff_refs?.head_sha || merge.sha,
+    files: diffs.map(diff => ({
+      path: diff.new_path,
+      previousPath: diff.renamed_file ? diff.old_path : null,
+      status: diff.new_file ? 'added' : diff.deleted_file ? 'removed' : diff.renamed_file ? 'renamed' : 'modified',
+      ...countChanges(diff.diff || ''),
+      patch: diff.diff || null
+    })),
+    // Review comments before the discussion, as on GitHub
+    comments: [...comments.filter(comment => comment.path), ...comments.filter(comment => !comment.path)]
+  });
+}
+
+/**
+ * @private
+ */
+function describe(provider, host, project, number) {
+  if (!PR_PROVIDERS.includes(provider)) {
+    throw new Error(`Unknown pull request provider: ${provider} (expected ${PR_PROVIDERS.join(' or ')})`);
+  }
+  const cleanProject = project.replace(/\.git$/, '');
+  const apiBase = provider === 'gitlab'
+    ? `https://${host}/api/v4`
+    : host === 'github.com' ? 'https://api.github.com' : `https://${host}/api/v3`;
+  return { provider, host, project: cleanProject, number, apiBase };
+}
+
+/**
+ * Host and project path of a git remote (https, ssh or scp-like)
+ * @private
+ */
+function parseRemote(remoteUrl) {
+  if (!remoteUrl) return null;
+  const match = /^(?:[a-z+]+:\/\/)?(?:[^@/]+@)?([^/:]+)(?::\d+)?[:/](.+?)(?:\.git)?\/?$/.exec(remoteUrl.trim());
+  return match ? { host: match[1], project: match[2] } : null;
+}
+
+/**
+ * Changed [start, end] lines of the new file in a unified diff
+ * Pure deletions mark the line after the removed block.
+ * @private
+ */
+function changedRanges(patch) {
+  const lines = [];
+  let next = 0;
+  let removed = false;
+  for (const line of patch.split('\n')) {
+    const hunk = /^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@/.exec(line);
+    if (hunk) {
+      next = Number(hunk[1]);
+      removed = false;
+    } else if (line.startsWith('+')) {
+      lines.push(next++);
+      removed = false;
+    } else if (line.startsWith('-')) {
+      if (!removed) lines.push(next);
+      removed = true;
+    } else if (!line.startsWith('\\')) {
+      next++;
+      removed = false;
+    }
+  }
+
+  const ranges = [];
+  for (const line of [...new Set(lines)].sort((a, b) => a - b)) {
+    const last = ranges[ranges.length - 1];
+    if (last && line <= last[1] + 1) last[1] = line;
+    else ranges.push([line, line]);
+  }
+  return ranges;
+}
+
+/**
+ * Added and removed lines of a unified diff without file headers
+ * @private
+ */
+function countChanges(diff) {
+  let additions = 0;
+  let deletions = 0;
+  for (const line of diff.split('\n')) {
+    if (line.startsWith('+') && !line.startsWith('+++')) additions++;
+    else if (line.startsWith('-') && !line.startsWith('---')) deletions++;
+  }
+  return { additions, deletions };
+}
+
+/**
+ * @private
+ */
+function groupBy(items, keyOf) {
+  const groups = new Map();
+  for (const item of items) {
+    const key = keyOf(item);
+    if (!groups.has(key)) groups.set(key, []);
+    groups.get(key).push(item);
+  }
+  return groups;
+}
+
+export default PullRequest;
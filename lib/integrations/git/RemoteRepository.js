@@ -0,0 +1,221 @@
+/**
+ * RemoteRepository - Repositories that are not checked out locally
+ * v3.4.0 - Context of a remote repository (ctxman gen URL[@REF])
+ *
+ * Responsibilities:
+ * - Parse repository URLs with an optional @branch, @tag or @commit:
+ *   https and ssh URLs, git@host:owner/repo, host/owner/repo and GitHub's
+ *   owner/repo shorthand and /tree/REF links
+ * - Check them out into a temporary directory with a shallow git clone,
+ *   or download GitHub's tarball when git is not installed
+ *
+ * The checkout is named after the repository, so contexts show its name
+ * rather than the temporary directory's. Callers remove the directory once
+ * the context is written. Tarball downloads use GITHUB_TOKEN or GH_TOKEN
+ * when set; clones use git's own credentials.
+ */
+
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { spawnSync } from 'child_process';
+
+export const FETCH_METHODS = ['auto', 'git', 'tarball'];
+
+const COMMIT = /^[0-9a-f]{7,40}$/i;
+const FULL_COMMIT = /^[0-9a-f]{40}$/i;
+
+export class RemoteRepository {
+  /**
+   * @param {Object} fields - { url, host, project, ref }
+   */
+  constructor(fields) {
+    Object.assign(this, { ref: null, ...fields });
+    this.name = this.project.split('/').pop();
+  }
+
+  /**
+   * Parse a repository reference
+   * @param {string} spec - e.g. https://github.com/org/repo@main, org/repo@v1.2.0,
+   *   git@gitlab.com:group/repo.git@3f2a91c
+   * @returns {RemoteRepository}
+   * @throws {Error} When the reference is not a repository
+   */
+  static parse(spec) {
+    let value = String(spec || '').trim();
+    const tree = /^(?:https?:\/\/)?github\.com\/([^/\s]+\/[^/\s]+?)(?:\.git)?\/tree\/(.+)$/i.exec(value);
+    if (tree) {
+      return new RemoteRepository({ url: `https://github.com/${tree[1]}.git`, host: 'github.com', project: tree[1], ref: tree[2] });
+    }
+
+    // The ref follows the last @ of the path, not the user of ssh or https URLs
+    let ref = null;
+    const at = /^(.+)@([^@:]+)$/.exec(value);
+    if (at && !/^(?:[a-z][a-z0-9+.-]*:\/\/)?[^/]*$/i.test(at[1])) {
+      value = at[1];
+      ref = at[2];
+    }
+
+    let match = /^[a-z][a-z0-9+.-]*:\/\/(?:[^@/]+@)?([^/:]*)(?::\d+)?\/(.+?)(?:\.git)?\/?$/i.exec(value);
+    if (match) {
+      return new RemoteRepository({ url: value, host: match[1], project: match[2], ref });
+    }
+    match = /^[^@/\s]+@([^:/\s]+):(.+?)(?:\.git)?\/?$/.exec(value);
+    if (match) {
+      return new RemoteRepository({ url: value, host: match[1], project: match[2], ref });
+    }
+    match = /^([a-z0-9-]+(?:\.[a-z0-9-]+)+)\/([^/\s]+\/[^\s]+?)(?:\.git)?\/?$/i.exec(value);
+    if (match) {
+      return new RemoteRepository({ url: `https://${match[1]}/${match[2]}.git`, host: match[1], project: match[2], ref });
+    }
+    match = /^([\w.-]+\/[\w.-]+?)(?:\.git)?$/.exec(value);
+    if (match) {
+      return new RemoteRepository({ url: `https://github.com/${match[1]}.git`, host: 'github.com', project: match[1], ref });
+    }
+    throw new Error(`Not a repository: ${spec} (expected https://github.com/OWNER/REPO[@REF], git@HOST:OWNER/REPO.git or OWNER/REPO)`);
+  }
+
+  /**
+   * Whether GitHub serves the repository as a tarball
+   * @returns {boolean}
+   */
+  hasTarball() {
+    return this.host.toLowerCase() === 'github.com';
+  }
+
+  /**
+   * Report line, e.g. "org/repo@main" or "gitlab.com/group/repo"
+   * @returns {string}
+   */
+  describe() {
+    const host = this.hasTarball() || !this.host ? '' : `${this.host}/`;
+    return `${host}${this.project}${this.ref ? `@${this.ref}` : ''}`;
+  }
+
+  /**
+   * Check the repository out into a new temporary directory
+   * @param {Object} [options]
+   * @param {string} [options.method] - One of FETCH_METHODS; auto clones when git is installed
+   * @param {string} [options.token] - GitHub token for tarballs (default: GITHUB_TOKEN, GH_TOKEN)
+   * @param {Function} [options.fetch] - fetch implementation (tests)
+   * @returns {Promise<{root: string, dir: string, method: string, commit: string|null}>} root is
+   *   the checkout; dir, its temporary parent, is for the caller to remove
+   * @throws {Error} When the repository or ref cannot be fetched
+   */
+  async checkout(options = {}) {
+    const method = options.method || 'auto';
+    if (!FETCH_METHODS.includes(method)) {
+      throw new Error(`Unknown fetch method: ${method} (expected ${FETCH_METHODS.join(', ')})`);
+    }
+    const useGit = method === 'git' || (method === 'auto' && (!this.hasTarball() || hasGit()));
+    if (!useGit && !this.hasTarball()) {
+      throw new Error(`Tarball downloads need a GitHub repository; ${this.describe()} needs git`);
+    }
+
+    const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-remote-'));
+    const root = path.join(dir, this.name);
+    try {
+      const commit = useGit ? this.clone(root) : await this.download(root, options);
+      return { root, dir, method: useGit ? 'git' : 'tarball', commit };
+    } catch (error) {
+      fs.rmSync(dir, { recursive: true, force: true });
+      throw error;
+    }
+  }
+
+  /**
+   * Shallow clone of the ref. Full commit hashes are fetched on their own;
+   * abbreviated ones, which servers do not resolve, need the whole history.
+   * @private
+   */
+  clone(root) {
+    const steps = FULL_COMMIT.test(this.ref || '')
+      ? [['init', '--quiet', root], ['-C', root, 'fetch', '--quiet', '--depth', '1', this.url, this.ref],
+        ['-C', root, 'checkout', '--quiet', 'FETCH_HEAD']]
+      : [['clone', '--quiet', '--depth', '1', '--single-branch', ...(this.ref ? ['--branch', this.ref] : []), this.url, root]];
+    let failure = runAll(steps);
+    if (failure && this.ref && COMMIT.test(this.ref) && !FULL_COMMIT.test(this.ref)) {
+      fs.rmSync(root, { recursive: true, force: true });
+      failure = runAll([['clone', '--quiet', '--no-checkout', this.url, root], ['-C', root, 'checkout', '--quiet', this.ref]]);
+    }
+    if (failure) {
+      throw new Error(`git could not fetch ${this.describe()}: ${failure}`);
+    }
+    const head = git(['-C', root, 'rev-parse', 'HEAD']);
+    return head.status === 0 ? head.stdout.toString('utf8').trim() : null;
+  }
+
+  /**
+   * GitHub tarball of the ref (default branch without one)
+   * @private
+   */
+  async download(root, options) {
+    const token = options.token ?? (process.env.GITHUB_TOKEN || process.env.GH_TOKEN);
+    const url = `https://api.github.com/repos/${this.project}/tarball${this.ref ? `/${encodeURIComponent(this.ref)}` : ''}`;
+    const headers = { 'User-Agent': 'ctxman', Accept: 'application/vnd.github+json' };
+    if (token) headers.Authorization = `Bearer ${token}`;
+
+    let response;
+    try {
+      response = await (options.fetch || globalThis.fetch)(url, { headers, redirect: 'follow' });
+    } catch (error) {
+      throw new Error(`Download of ${this.describe()} failed: ${error.message}`);
+    }
+    if (!response.ok) {
+      const hint = [401, 403, 404].includes(response.status) && !token ? ' (private repositories need GITHUB_TOKEN)' : '';
+      throw new Error(`GitHub returned ${response.status} for ${this.describe()}${hint}`);
+    }
+
+    // GitHub wraps the tree in an OWNER-REPO-SHA directory
+    fs.mkdirSync(root, { recursive: true });
+    const tar = spawnSync('tar', ['-xzf', '-', '--strip-components=1', '-C', root], {
+      input: Buffer.from(await response.arrayBuffer()),
+      maxBuffer: 1024 * 1024 * 1024
+    });
+    if (tar.error || tar.status !== 0) {
+      throw new Error(`Cannot unpack the tarball of ${this.describe()}: ${tar.error ? tar.error.message : lastLine(tar.stderr)}`);
+    }
+    const sha = /-([0-9a-f]{7,40})\.tar\.gz"?$/i.exec(response.headers?.get?.('content-disposition') || '');
+    return sha ? sha[1] : null;
+  }
+}
+
+/**
+ * @private
+ */
+function git(args) {
+  const run = spawnSync('git', args, { env: { ...process.env, GIT_TERMINAL_PROMPT: '0' } });
+  if (run.error) {
+    throw new Error(run.error.code === 'ENOENT' ? 'Cloning needs git on PATH (or --via tarball for GitHub)' : run.error.message);
+  }
+  return run;
+}
+
+/**
+ * Run git commands until one fails
+ * @returns {string|null} Last error line of the failed command
+ * @private
+ */
+function runAll(steps) {
+  for (const args of steps) {
+    const run = git(args);
+    if (run.status !== 0) return lastLine(run.stderr);
+  }
+  return null;
+}
+
+/**
+ * @private
+ */
+function hasGit() {
+  return !spawnSync('git', ['--version']).error;
+}
+
+/**
+ * @private
+ */
+function lastLine(output) {
+  return output.toString('utf8').trim().split('\n').pop() || 'no output';
+}
+
+export default RemoteRepository;
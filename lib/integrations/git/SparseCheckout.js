@@ -0,0 +1,203 @@
+/**
+ * SparseCheckout - The paths a sparse checkout keeps
+ * v3.4.0 - Sparse checkout support (--sparse, --no-sparse)
+ *
+ * Responsibilities:
+ * - Read the sparse-checkout definition of the repository
+ *   (`git rev-parse --git-path info/sparse-checkout`) when core.sparseCheckout
+ *   is enabled, or a definition file given with --sparse FILE
+ * - Tell whether a file, or anything under a directory, is in it, so scans
+ *   skip excluded directories without reading them
+ *
+ * Cone definitions (`git sparse-checkout set --cone`) are directories: each
+ * listed directory is kept with everything beneath it, and the directories
+ * above it keep only their own files. Other definitions are .gitignore-style
+ * patterns where the last match wins; directories are then never skipped
+ * as a whole, since a later pattern may bring back a file under them.
+ *
+ * Definitions are relative to the repository; paths given to includes() are
+ * relative to the project root, which may be a subdirectory of it.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { spawnSync } from 'child_process';
+import GitIgnoreParser from '../../parsers/gitignore-parser.js';
+import { getLogger } from '../../utils/logger.js';
+
+const logger = getLogger('SparseCheckout');
+
+export class SparseCheckout {
+  /**
+   * @param {Array<string>} patterns - Lines of the definition, without comments and blank lines
+   * @param {Object} options
+   */
+  constructor(patterns, options = {}) {
+    this.options = {
+      prefix: '', // Project root relative to the repository, '/'-terminated
+      cone: null, // Cone mode; null to detect from the patterns
+      source: 'info/sparse-checkout', // Definition file, for messages
+      ...options
+    };
+    this.patterns = patterns;
+    this.cone = this.options.cone ?? patterns.every(isConePattern);
+
+    if (this.cone) {
+      this.parseCone();
+    } else {
+      const parser = new GitIgnoreParser(null, null, null);
+      this.rules = patterns.map(pattern => parser.convertToRegex(pattern));
+    }
+  }
+
+  /**
+   * Definition of the repository a project is in
+   * @param {string} root - Project root
+   * @param {Object} options
+   * @param {string|null} options.file - Definition file; null for the repository's
+   * @param {boolean} options.force - Use the repository's definition even when sparse checkout is disabled
+   * @returns {SparseCheckout|null} Null when there is no definition to apply
+   * @throws {Error} When a given file is missing, or forced outside a repository
+   */
+  static load(root, { file = null, force = false } = {}) {
+    const inRepository = git(root, ['rev-parse', '--git-dir']).status === 0;
+    if (!inRepository && !file) {
+      if (force) throw new Error(`--sparse needs a git repository or a definition file, and ${root} is not in one`);
+      return null;
+    }
+
+    const config = key => inRepository ? git(root, ['config', '--bool', key]).stdout.toString().trim() : '';
+    if (!file && !force && config('core.sparseCheckout') !== 'true') return null;
+
+    const prefix = inRepository ? git(root, ['rev-parse', '--show-prefix']).stdout.toString().trim() : '';
+    const filePath = file
+      ? path.resolve(root, file)
+      : path.resolve(root, git(root, ['rev-parse', '--git-path', 'info/sparse-checkout']).stdout.toString().trim());
+    if (!fs.existsSync(filePath)) {
+      if (file) throw new Error(`Sparse-checkout file not found: ${file}`);
+      if (force) throw new Error('--sparse: this repository has no sparse-checkout definition (git sparse-checkout set DIR...)');
+      return null;
+    }
+
+    const patterns = fs.readFileSync(filePath, 'utf8').split(/\r?\n/)
+      .map(line => line.trim())
+      .filter(line => line && !line.startsWith('#'));
+    const cone = config('core.sparseCheckoutCone');
+    const sparse = new SparseCheckout(patterns, {
+      prefix,
+      cone: cone === '' || file ? null : cone === 'true',
+      source: file || 'info/sparse-checkout'
+    });
+    logger.debug(`Loaded ${patterns.length} sparse-checkout patterns (${sparse.cone ? 'cone' : 'non-cone'}) from ${filePath}`);
+    return sparse;
+  }
+
+  /**
+   * @param {string} relativePath - File relative to the project root
+   * @returns {boolean} Whether the definition keeps the file
+   */
+  includes(relativePath) {
+    const file = this.options.prefix + toPosix(relativePath);
+    if (this.cone) {
+      const dir = path.posix.dirname(file);
+      return this.parents.has(dir === '.' ? '' : dir) || this.inRecursive(file);
+    }
+
+    let included = false;
+    for (const rule of this.rules) {
+      if (rule.regex.test(file)) included = !rule.isNegation;
+    }
+    return included;
+  }
+
+  /**
+   * @param {string} relativePath - Directory relative to the project root
+   * @returns {boolean} Whether anything under the directory may be kept
+   */
+  includesDirectory(relativePath) {
+    if (!this.cone) return true;
+    const dir = this.options.prefix + toPosix(relativePath);
+    if (this.parents.has(dir) || this.inRecursive(dir)) return true;
+    return [...this.parents, ...this.recursive].some(kept => kept.startsWith(`${dir}/`));
+  }
+
+  /**
+   * @returns {string} e.g. "cone: 2 directories"
+   */
+  describe() {
+    return this.cone
+      ? `cone: ${this.recursive.size} ${this.recursive.size === 1 ? 'directory' : 'directories'}`
+      : `${this.patterns.length} patterns`;
+  }
+
+  /**
+   * Directories of a cone definition: /A/ keeps A, and !/A/*\/ after it
+   * makes A a parent that keeps only its own files
+   * @private
+   */
+  parseCone() {
+    const listed = new Set();
+    const parentsOnly = new Set();
+    for (const pattern of this.patterns) {
+      if (pattern === '/*') {
+        listed.add('');
+      } else if (pattern === '!/*/') {
+        parentsOnly.add('');
+      } else if (pattern.startsWith('!')) {
+        parentsOnly.add(unescape(pattern.slice(2, -3)));
+      } else {
+        listed.add(unescape(pattern.slice(1, -1)));
+      }
+    }
+    this.parents = new Set([...listed].filter(dir => parentsOnly.has(dir)));
+    this.recursive = new Set([...listed].filter(dir => !parentsOnly.has(dir)));
+  }
+
+  /**
+   * Whether a path is a kept directory, or under one
+   * @private
+   */
+  inRecursive(file) {
+    if (this.recursive.has('')) return true;
+    for (const dir of this.recursive) {
+      if (file === dir || file.startsWith(`${dir}/`)) return true;
+    }
+    return false;
+  }
+}
+
+/**
+ * Patterns `git sparse-checkout set --cone` writes: /*, !/*\/, /A/ and !/A/*\/
+ * @private
+ */
+function isConePattern(pattern) {
+  return pattern === '/*' || pattern === '!/*/' || /^\/.+\/$/.test(pattern) && !/[*?[]/.test(pattern.replace(/\\./g, '')) ||
+    /^!\/.+\/\*\/$/.test(pattern) && !/[*?[]/.test(pattern.slice(0, -3).replace(/\\./g, ''));
+}
+
+/**
+ * @private
+ */
+function unescape(pattern) {
+  return pattern.replace(/\\(.)/g, '$1');
+}
+
+/**
+ * @private
+ */
+function toPosix(relativePath) {
+  return relativePath.split(path.sep).join('/');
+}
+
+/**
+ * @private
+ */
+function git(cwd, args) {
+  const run = spawnSync('git', args, { cwd });
+  if (run.error) {
+    throw new Error(`git failed: ${run.error.message}`);
+  }
+  return run;
+}
+
+export default SparseCheckout;
@@ -0,0 +1,303 @@
+/**
+ * LspClient - Language Server Protocol client
+ * v3.4.0 - LSP gateway for definitions and references
+ *
+ * Responsibilities:
+ * - Start a language server over stdio, or connect to a running one over TCP
+ *   (e.g. `gopls -listen=127.0.0.1:37374`)
+ * - Frame JSON-RPC messages with Content-Length headers
+ * - Initialize the workspace and open files before querying them
+ * - Answer textDocument/definition and textDocument/references requests
+ */
+
+import net from 'net';
+import { spawn } from 'child_process';
+import { pathToFileURL, fileURLToPath } from 'url';
+import { nativeFileSystem } from '../../core/FileSystem.js';
+import { getLogger } from '../../utils/logger.js';
+
+const logger = getLogger('LspClient');
+
+const HEADER_END = '\r\n\r\n';
+
+export class LspClient {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      root: process.cwd(), // Workspace folder
+      command: null, // [program, ...args] of a server speaking LSP on stdio
+      address: null, // { host, port } of a running server
+      timeout: 30000, // Per request; the first queries wait for the workspace to load
+      ...options
+    };
+
+    this.nextId = 1;
+    this.pending = new Map();
+    this.opened = new Set();
+    this.buffer = Buffer.alloc(0);
+    this.process = null;
+    this.socket = null;
+    this.closed = false;
+  }
+
+  /**
+   * Start or connect to the server and initialize the workspace
+   * @returns {Promise<Object>} Server capabilities
+   */
+  async start() {
+    const { input, output } = await this.connect();
+    this.output = output;
+    input.on('data', chunk => this.receive(chunk));
+
+    const rootUri = pathToFileURL(this.options.root).href;
+    const result = await this.request('initialize', {
+      processId: process.pid,
+      clientInfo: { name: 'ctxman' },
+      rootUri,
+      workspaceFolders: [{ uri: rootUri, name: 'root' }],
+      capabilities: {
+        textDocument: {
+          synchronization: { didSave: false },
+          definition: { linkSupport: true },
+          references: {}
+        },
+        workspace: { workspaceFolders: true, configuration: true }
+      }
+    });
+    this.notify('initialized', {});
+
+    this.capabilities = result?.capabilities || {};
+    logger.debug(`Language server ready: ${result?.serverInfo?.name || this.describe()}`);
+    return this.capabilities;
+  }
+
+  /**
+   * @private
+   * @returns {Promise<{input: Readable, output: Writable}>}
+   */
+  connect() {
+    const fail = reason => this.failAll(new Error(`Language server ${this.describe()} ${reason}`));
+
+    if (this.options.address) {
+      return new Promise((resolve, reject) => {
+        const socket = net.connect(this.options.address, () => resolve({ input: socket, output: socket }));
+        socket.once('error', error => {
+          reject(new Error(`Cannot connect to language server ${this.describe()}: ${error.message}`));
+          fail(`failed: ${error.message}`);
+        });
+        socket.on('close', () => fail('closed the connection'));
+        this.socket = socket;
+      });
+    }
+
+    const [program, ...args] = this.options.command;
+    return new Promise((resolve, reject) => {
+      const child = spawn(program, args, { cwd: this.options.root, stdio: ['pipe', 'pipe', 'pipe'] });
+      child.stderr.on('data', chunk => logger.debug(`${program}: ${chunk.toString().trim()}`));
+      child.once('error', error => {
+        reject(new Error(`Cannot start language server ${this.describe()}: ${error.message}`));
+        fail(`failed: ${error.message}`);
+      });
+      child.once('spawn', () => resolve({ input: child.stdout, output: child.stdin }));
+      child.once('exit', code => fail(`exited with code ${code}`));
+      this.process = child;
+    });
+  }
+
+  /**
+   * @returns {string} Command line or address, for messages
+   */
+  describe() {
+    const { command, address } = this.options;
+    return address ? `tcp://${address.host}:${address.port}` : command.join(' ');
+  }
+
+  /**
+   * Send a request and wait for its result
+   * @param {string} method
+   * @param {Object} params
+   * @returns {Promise<*>}
+   */
+  request(method, params) {
+    if (this.closed) {
+      return Promise.reject(new Error(`Language server ${this.describe()} is not running`));
+    }
+
+    const id = this.nextId++;
+    return new Promise((resolve, reject) => {
+      const timer = setTimeout(() => {
+        this.pending.delete(id);
+        reject(new Error(`${method} timed out after ${this.options.timeout}ms`));
+      }, this.options.timeout);
+      this.pending.set(id, { resolve, reject, timer });
+      this.send({ jsonrpc: '2.0', id, method, params });
+    });
+  }
+
+  /**
+   * @param {string} method
+   * @param {Object} params
+   */
+  notify(method, params) {
+    this.send({ jsonrpc: '2.0', method, params });
+  }
+
+  /**
+   * @private
+   */
+  send(message) {
+    const body = Buffer.from(JSON.stringify(message), 'utf8');
+    this.output.write(`Content-Length: ${body.length}${HEADER_END}`);
+    this.output.write(body);
+  }
+
+  /**
+   * Parse framed messages; lengths count bytes, not characters
+   * @private
+   */
+  receive(chunk) {
+    this.buffer = Buffer.concat([this.buffer, chunk]);
+
+    for (;;) {
+      const headerEnd = this.buffer.indexOf(HEADER_END);
+      if (headerEnd === -1) return;
+
+      const length = Number(/Content-Length:\s*(\d+)/i.exec(this.buffer.subarray(0, headerEnd).toString('ascii'))?.[1]);
+      const start = headerEnd + HEADER_END.length;
+      if (!Number.isFinite(length)) {
+        // Skip a malformed header instead of stalling on it
+        this.buffer = this.buffer.subarray(start);
+        continue;
+      }
+      if (this.buffer.length < start + length) return;
+
+      const body = this.buffer.subarray(start, start + length).toString('utf8');
+      this.buffer = this.buffer.subarray(start + length);
+      try {
+        this.dispatch(JSON.parse(body));
+      } catch (error) {
+        logger.debug(`Unreadable message from ${this.describe()}: ${error.message}`);
+      }
+    }
+  }
+
+  /**
+   * @private
+   */
+  dispatch(message) {
+    if (message.id !== undefined && message.method) {
+      // Requests from the server: answer configuration with defaults, accept the rest
+      const result = message.method === 'workspace/configuration'
+        ? (message.params?.items || []).map(() => null)
+        : null;
+      this.send({ jsonrpc: '2.0', id: message.id, result });
+      return;
+    }
+
+    const pending = this.pending.get(message.id);
+    if (!pending) return; // Notifications such as diagnostics, or late replies
+
+    this.pending.delete(message.id);
+    clearTimeout(pending.timer);
+    if (message.error) {
+      pending.reject(new Error(message.error.message || `Error ${message.error.code}`));
+    } else {
+      pending.resolve(message.result);
+    }
+  }
+
+  /**
+   * @private
+   */
+  failAll(error) {
+    if (this.closed) return;
+    this.closed = true;
+    for (const { reject, timer } of this.pending.values()) {
+      clearTimeout(timer);
+      reject(error);
+    }
+    this.pending.clear();
+  }
+
+  /**
+   * Open a file once, so the server answers from its current content
+   * @param {string} filePath - Absolute path
+   * @param {string} languageId
+   */
+  open(filePath, languageId) {
+    const uri = pathToFileURL(filePath).href;
+    if (this.opened.has(uri)) return uri;
+
+    this.notify('textDocument/didOpen', {
+      textDocument: { uri, languageId, version: 1, text: nativeFileSystem.readText(filePath) }
+    });
+    this.opened.add(uri);
+    return uri;
+  }
+
+  /**
+   * Where the symbol at a position is defined
+   * @param {string} filePath - Absolute path
+   * @param {string} languageId
+   * @param {{line: number, character: number}} position - Zero-based, as in LSP
+   * @returns {Promise<Array<{path: string, line: number, character: number}>>}
+   */
+  async definition(filePath, languageId, position) {
+    const uri = this.open(filePath, languageId);
+    return LspClient.locations(await this.request('textDocument/definition', { textDocument: { uri }, position }));
+  }
+
+  /**
+   * Where the symbol at a position is used
+   * @param {string} filePath - Absolute path
+   * @param {string} languageId
+   * @param {{line: number, character: number}} position - Zero-based, as in LSP
+   * @param {boolean} includeDeclaration
+   * @returns {Promise<Array<{path: string, line: number, character: number}>>}
+   */
+  async references(filePath, languageId, position, includeDeclaration = false) {
+    const uri = this.open(filePath, languageId);
+    return LspClient.locations(await this.request('textDocument/references', {
+      textDocument: { uri },
+      position,
+      context: { includeDeclaration }
+    }));
+  }
+
+  /**
+   * Normalize Location, Location[] and LocationLink[] results to file paths
+   * @param {*} result
+   * @returns {Array<{path: string, line: number, character: number}>}
+   */
+  static locations(result) {
+    const list = Array.isArray(result) ? result : result ? [result] : [];
+    return list
+      .map(location => ({
+        uri: location.targetUri || location.uri,
+        range: location.targetSelectionRange || location.targetRange || location.range
+      }))
+      .filter(location => location.uri?.startsWith('file:') && location.range)
+      .map(({ uri, range }) => ({ path: fileURLToPath(uri), line: range.start.line, character: range.start.character }));
+  }
+
+  /**
+   * Shut the server down (a running server we connected to is only disconnected)
+   */
+  async stop() {
+    if (!this.closed) {
+      try {
+        await this.request('shutdown', null);
+        if (this.process) this.notify('exit', null);
+      } catch (error) {
+        logger.debug(`Language server shutdown failed: ${error.message}`);
+      }
+    }
+    this.closed = true;
+    this.socket?.destroy();
+    this.process?.kill();
+  }
+}
+
+export default LspClient;
@@ -0,0 +1,225 @@
+/**
+ * LspGateway - Synchronous access to a language server
+ * v3.4.0 - LSP gateway for definitions and references
+ *
+ * Responsibilities:
+ * - Parse --lsp specs: a server command (gopls, typescript-language-server --stdio)
+ *   or the tcp:// address of a running server
+ * - Know which languages the server answers for
+ * - Run the LspClient on a worker thread and block on its replies, so
+ *   dependency expansion can stay synchronous
+ * - Translate between project-relative paths with 1-based lines and LSP positions
+ * - Stop answering after the server fails, so callers fall back to static analysis
+ */
+
+import path from 'path';
+import { Worker, MessageChannel, receiveMessageOnPort } from 'worker_threads';
+import { getLogger } from '../../utils/logger.js';
+
+const logger = getLogger('LspGateway');
+
+// Languages of well-known servers, by executable name
+export const SERVER_LANGUAGES = {
+  gopls: ['go'],
+  'typescript-language-server': ['typescript', 'javascript'],
+  vtsls: ['typescript', 'javascript'],
+  pylsp: ['python'],
+  'pyright-langserver': ['python'],
+  'basedpyright-langserver': ['python'],
+  'jedi-language-server': ['python'],
+  'rust-analyzer': ['rust'],
+  jdtls: ['java']
+};
+
+/**
+ * Parse an --lsp value
+ * @param {string} spec - "gopls", "typescript-language-server --stdio" or "tcp://127.0.0.1:37374"
+ * @returns {{command: Array<string>|null, address: {host: string, port: number}|null}}
+ * @throws {Error} When the spec is empty or the address has no port
+ */
+export function parseLspSpec(spec) {
+  const value = (spec || '').trim();
+  if (!value) throw new Error('--lsp needs a server command or tcp://host:port');
+
+  if (value.startsWith('tcp://')) {
+    const match = /^tcp:\/\/([^:/]*):(\d+)\/?$/.exec(value);
+    if (!match) throw new Error(`Invalid language server address: ${value} (expected tcp://host:port)`);
+    return { command: null, address: { host: match[1] || '127.0.0.1', port: Number(match[2]) } };
+  }
+
+  return { command: value.split(/\s+/), address: null };
+}
+
+export class LspGateway {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      root: process.cwd(),
+      spec: null, // --lsp value
+      languages: null, // Languages the server answers for (default: from the command)
+      timeout: 30000, // Per query, in milliseconds
+      ...options
+    };
+
+    const { command, address } = parseLspSpec(this.options.spec);
+    this.command = command;
+    this.address = address;
+
+    const known = command && SERVER_LANGUAGES[path.basename(command[0]).replace(/\.(?:cmd|exe)$/, '')];
+    this.languages = new Set(this.options.languages || known || []);
+    if (this.languages.size === 0) {
+      throw new Error(`Unknown languages for language server ${this.options.spec} (pass --lsp-languages, e.g. go)`);
+    }
+
+    this.worker = null;
+    this.failed = null;
+    this.nextId = 1;
+    this.cache = new Map();
+  }
+
+  /**
+   * Start the worker and initialize the server
+   * @returns {LspGateway}
+   * @throws {Error} When the server cannot be started or does not initialize
+   */
+  start() {
+    const { port1, port2 } = new MessageChannel();
+    this.port = port1;
+    this.signal = new Int32Array(new SharedArrayBuffer(4));
+    this.worker = new Worker(new URL('./lsp-worker.js', import.meta.url), {
+      workerData: {
+        port: port2,
+        signal: this.signal,
+        options: { root: this.options.root, command: this.command, address: this.address, timeout: this.options.timeout }
+      },
+      transferList: [port2]
+    });
+    // Never keep the process alive for the server
+    this.worker.unref();
+
+    const reply = this.call('start', {});
+    if (reply.error) {
+      this.close();
+      throw new Error(reply.error);
+    }
+    logger.debug(`Language server ${this.options.spec} answers for ${[...this.languages].join(', ')}`);
+    return this;
+  }
+
+  /**
+   * Send a request to the worker and wait for its reply
+   * @private
+   * @returns {{result: *}|{error: string}}
+   */
+  call(method, params) {
+    if (this.failed) return { error: this.failed };
+
+    const id = this.nextId++;
+    const seen = Atomics.load(this.signal, 0);
+    this.port.postMessage({ id, method, params });
+
+    const deadline = Date.now() + this.options.timeout + 1000;
+    for (;;) {
+      const remaining = deadline - Date.now();
+      if (remaining <= 0 || Atomics.wait(this.signal, 0, seen, remaining) === 'timed-out') {
+        return this.fail(`${method} did not answer within ${this.options.timeout}ms`);
+      }
+
+      // Replies to requests that timed out earlier are skipped
+      let received;
+      while ((received = receiveMessageOnPort(this.port))) {
+        const reply = received.message;
+        if (reply.id !== id) continue;
+        return reply.fatal ? this.fail(reply.error) : reply;
+      }
+    }
+  }
+
+  /**
+   * @private
+   */
+  fail(message) {
+    if (!this.failed) {
+      logger.warn(`Language server ${this.options.spec} stopped answering (${message}); using static analysis`);
+      this.failed = message;
+    }
+    return { error: message };
+  }
+
+  /**
+   * Whether the server answers for files of a language
+   * @param {string} language - Language ID of a graph file
+   * @returns {boolean}
+   */
+  handles(language) {
+    return !this.failed && this.languages.has(language);
+  }
+
+  /**
+   * Where the identifier at a position is defined
+   * @param {string} file - Project-relative path
+   * @param {string} language
+   * @param {number} line - 1-based
+   * @param {number} character - 0-based UTF-16 offset within the line
+   * @returns {Array<{file: string, line: number, character: number}>|null} Locations
+   *   (lines 1-based, files outside the project dropped), or null when the server cannot answer
+   */
+  definition(file, language, line, character) {
+    return this.query('definition', { file, language, line, character });
+  }
+
+  /**
+   * Where the identifier at a position is used
+   * @param {string} file - Project-relative path
+   * @param {string} language
+   * @param {number} line - 1-based
+   * @param {number} character - 0-based UTF-16 offset within the line
+   * @param {boolean} includeDeclaration
+   * @returns {Array<{file: string, line: number, character: number}>|null}
+   */
+  references(file, language, line, character, includeDeclaration = false) {
+    return this.query('references', { file, language, line, character, includeDeclaration });
+  }
+
+  /**
+   * @private
+   */
+  query(method, { file, language, line, character, includeDeclaration }) {
+    if (!this.handles(language)) return null;
+
+    const key = `${method}:${file}:${line}:${character}:${Boolean(includeDeclaration)}`;
+    if (this.cache.has(key)) return this.cache.get(key);
+
+    const reply = this.call(method, {
+      file: path.join(this.options.root, file),
+      languageId: language,
+      position: { line: line - 1, character },
+      includeDeclaration
+    });
+    if (reply.error) {
+      logger.debug(`${method} at ${file}:${line}:${character} failed: ${reply.error}`);
+      return null;
+    }
+
+    const locations = reply.result
+      .map(location => ({ ...location, file: path.relative(this.options.root, location.path).split(path.sep).join('/') }))
+      .filter(location => !location.file.startsWith('..') && !path.isAbsolute(location.file))
+      .map(({ file: relative, line: zeroBased, character: column }) => ({ file: relative, line: zeroBased + 1, character: column }));
+    this.cache.set(key, locations);
+    return locations;
+  }
+
+  /**
+   * Shut the server down and stop the worker
+   */
+  close() {
+    if (!this.worker) return;
+    if (!this.failed) this.call('stop', {});
+    this.worker.terminate();
+    this.worker = null;
+  }
+}
+
+export default LspGateway;
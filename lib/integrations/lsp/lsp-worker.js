@@ -0,0 +1,33 @@
+/**
+ * LSP Worker
+ * Runs an LspClient on a worker thread so LspGateway can query it
+ * synchronously (v3.4.0, --lsp). Messages { id, method, params } on the port
+ * return { id, result } or { id, error, fatal }; each reply is posted before
+ * the shared signal is bumped, so the waiting thread can read it at once.
+ */
+
+import { workerData } from 'worker_threads';
+import LspClient from './LspClient.js';
+
+const { port, signal, options } = workerData;
+const client = new LspClient(options);
+
+const handlers = {
+  start: () => client.start(),
+  definition: ({ file, languageId, position }) => client.definition(file, languageId, position),
+  references: ({ file, languageId, position, includeDeclaration }) =>
+    client.references(file, languageId, position, includeDeclaration),
+  stop: () => client.stop()
+};
+
+port.on('message', async ({ id, method, params }) => {
+  let reply;
+  try {
+    reply = { id, result: await handlers[method](params) };
+  } catch (error) {
+    reply = { id, error: error.message, fatal: client.closed };
+  }
+  port.postMessage(reply);
+  Atomics.add(signal, 0, 1);
+  Atomics.notify(signal, 0);
+});
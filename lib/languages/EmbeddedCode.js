@@ -0,0 +1,83 @@
+/**
+ * EmbeddedCode - Symbols of code embedded in documents
+ * v3.4.0 - Notebook and Markdown code extraction
+ *
+ * Responsibilities:
+ * - Map notebook kernel languages and Markdown fence info strings
+ *   (```ts, ```python) to the language plugin that parses them
+ * - Extract symbols and imports of code that sits at known lines of a
+ *   document, keeping the document's line numbers and path
+ */
+
+import { PythonPlugin } from './PythonPlugin.js';
+import { TypeScriptPlugin } from './TypeScriptPlugin.js';
+import { RustPlugin } from './RustPlugin.js';
+import { JavaPlugin } from './JavaPlugin.js';
+import { GoPlugin } from './GoPlugin.js';
+import { ProtobufPlugin } from './ProtobufPlugin.js';
+
+// Language names and fence aliases by the extension their plugin claims
+const LANGUAGE_EXTENSIONS = {
+  python: '.py', py: '.py', python3: '.py', ipython: '.py',
+  javascript: '.js', js: '.js', node: '.js', jsx: '.jsx', mjs: '.mjs', cjs: '.cjs',
+  typescript: '.ts', ts: '.ts', tsx: '.tsx', deno: '.ts',
+  rust: '.rs', rs: '.rs',
+  java: '.java',
+  go: '.go', golang: '.go',
+  protobuf: '.proto', proto: '.proto'
+};
+
+let plugins = null;
+
+/**
+ * Plugin and extension parsing a language, or null for languages without one
+ * @param {string} language - Kernel language or fence info string
+ * @returns {{plugin: LanguagePlugin, extension: string}|null}
+ */
+export function embeddedLanguage(language) {
+  const name = (language || '').trim().toLowerCase().split(/[\s{,]/)[0];
+  const extension = LANGUAGE_EXTENSIONS[name];
+  if (!extension) return null;
+
+  if (!plugins) {
+    plugins = [new PythonPlugin(), new TypeScriptPlugin(), new RustPlugin(), new JavaPlugin(), new GoPlugin(), new ProtobufPlugin()];
+  }
+  const plugin = plugins.find(candidate => candidate.extensions.includes(extension));
+  return plugin ? { plugin, extension } : null;
+}
+
+/**
+ * Symbols of code at lines of a document; other lines are blanked so the
+ * plugin reports the document's line numbers
+ * @param {Object} embedded - Result of embeddedLanguage()
+ * @param {Array<string>} lines - Document lines
+ * @param {Array<{startLine: number, endLine: number}>} ranges - 1-based code lines
+ * @param {string} filePath - Document path recorded on the symbols
+ * @returns {Array<Symbol>}
+ */
+export function embeddedSymbols(embedded, lines, ranges, filePath) {
+  const code = codeView(lines, ranges);
+  if (!embedded.plugin.validate(code)) return [];
+  return embedded.plugin.extractSymbols(code, `${filePath}${embedded.extension}`)
+    .map(symbol => ({ ...symbol, file: filePath }));
+}
+
+/**
+ * Imports of code at lines of a document; see embeddedSymbols()
+ * @returns {Array<Object>}
+ */
+export function embeddedImports(embedded, lines, ranges, filePath) {
+  const code = codeView(lines, ranges);
+  return embedded.plugin.validate(code) ? embedded.plugin.extractImports(code, `${filePath}${embedded.extension}`) : [];
+}
+
+/**
+ * @private
+ */
+function codeView(lines, ranges) {
+  const code = lines.map(() => '');
+  for (const { startLine, endLine } of ranges) {
+    for (let line = startLine; line <= endLine; line++) code[line - 1] = lines[line - 1];
+  }
+  return code.join('\n');
+}
@@ -0,0 +1,126 @@
+/**
+ * GoPackages - Package loading for Go modules
+ * v3.4.0 - Go cross-package resolution
+ *
+ * Responsibilities:
+ * - List the packages of the modules in a project with `go list` (what
+ *   golang.org/x/tools/go/packages runs underneath)
+ * - Map import paths to package directories and package names, following
+ *   go.work, local replace directives and nested modules like the go command
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { spawnSync } from 'child_process';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('GoPackages');
+
+const USE_BLOCK_PATTERN = /^use\s*\(([\s\S]*?)^\)/gm;
+
+export class GoPackages {
+  /**
+   * @param {Array<{importPath: string, dir: string, name: string}>} packages - dir as a project path
+   */
+  constructor(packages = []) {
+    this.byImportPath = new Map(packages.map(pkg => [pkg.importPath, pkg]));
+  }
+
+  /**
+   * Load the packages of the modules in a project
+   * `./...` stops at nested modules, so each module directory is listed.
+   * @param {ProjectIndex} project - Maps project paths to disk and back
+   * @param {Array<string>} moduleDirs - Project directories holding go.mod
+   * @param {Object} options - { command, timeout }
+   * @returns {GoPackages|null} Packages, or null without go.mod files on disk or a go command
+   */
+  static load(project, moduleDirs, options = {}) {
+    const { command = 'go', timeout = 30000 } = options;
+    const dirs = moduleDirs
+      .map(dir => project.absolutePath(dir))
+      .filter(dir => dir !== null && fs.existsSync(path.join(dir, 'go.mod')));
+    if (dirs.length === 0) return null;
+
+    const packages = [];
+    for (const dir of dirs) {
+      const listed = GoPackages.list(project, dir, command, timeout);
+      if (!listed) return null;
+      packages.push(...listed);
+    }
+
+    logger.debug(`go list: ${packages.length} packages in ${dirs.length} modules`);
+    return new GoPackages(packages);
+  }
+
+  /**
+   * @private
+   */
+  static list(project, cwd, command, timeout) {
+    const run = spawnSync(command, ['list', '-e', '-find', '-json', './...'], {
+      cwd,
+      encoding: 'utf8',
+      timeout,
+      maxBuffer: 64 * 1024 * 1024,
+      // Never download modules or toolchains while building context
+      env: { ...process.env, GOPROXY: 'off', GOTOOLCHAIN: 'local' }
+    });
+
+    if (run.error || run.status !== 0) {
+      logger.debug(`go list failed in ${cwd}: ${run.error?.message || run.stderr?.trim()}`);
+      return null;
+    }
+
+    try {
+      return GoPackages.parse(run.stdout)
+        .filter(pkg => pkg.Dir && pkg.ImportPath)
+        .map(pkg => ({
+          importPath: pkg.ImportPath,
+          dir: project.relativePath(pkg.Dir),
+          name: pkg.Name || null
+        }))
+        .filter(pkg => pkg.dir !== null);
+    } catch (error) {
+      logger.debug(`Unreadable go list output in ${cwd}: ${error.message}`);
+      return null;
+    }
+  }
+
+  /**
+   * Parse the stream of JSON objects `go list -json` prints
+   * @param {string} output
+   * @returns {Array<Object>}
+   */
+  static parse(output) {
+    const text = output.trim();
+    if (!text) return [];
+    // Top-level objects close at the start of a line; nested ones are indented
+    return JSON.parse(`[${text.replace(/^}\s*\n{/gm, '},\n{')}]`);
+  }
+
+  /**
+   * @param {string} importPath
+   * @returns {{importPath: string, dir: string, name: string}|null}
+   */
+  get(importPath) {
+    return this.byImportPath.get(importPath) || null;
+  }
+}
+
+/**
+ * `use ./dir` and `use ( ... )` directories of a go.work file
+ * @param {string} text
+ * @returns {Array<string>}
+ */
+export function goWorkUses(text) {
+  const specs = [];
+  for (const match of text.matchAll(USE_BLOCK_PATTERN)) {
+    specs.push(...match[1].split('\n'));
+  }
+  specs.push(...text.split('\n').filter(line => /^use\s+[^(\s]/.test(line)).map(line => line.replace(/^use\s+/, '')));
+
+  return specs
+    .map(spec => spec.replace(/\/\/.*$/, '').trim().replace(/^"(.*)"$/, '$1'))
+    .filter(Boolean);
+}
+
+export default GoPackages;
@@ -0,0 +1,401 @@
+/**
+ * GoPlugin - Go symbol extraction
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Uses tree-sitter-go when installed, otherwise a brace-aware heuristic
+ * parser. Methods carry their receiver type as parent; capitalised names
+ * are exported.
+ */
+
+import path from 'path';
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { extractWithRules } from '../symbols/HeuristicExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { lineNumberAt } from '../symbols/SourceScanner.js';
+import { GoPackages, goWorkUses } from './GoPackages.js';
+
+const TOP_LEVEL_RULES = [
+  {
+    pattern: /^package\s+(\w+)/,
+    build: m => ({ name: m[1], kind: SymbolKind.MODULE, exported: true, singleLine: true })
+  },
+  {
+    pattern: /^func\s+\(\s*(?:\w+\s+)?\*?\s*(\w+)(?:\[[^\]]*\])?\s*\)\s*(\w+)/,
+    build: m => ({ name: m[2], kind: SymbolKind.METHOD, parent: m[1], exported: isExported(m[2]) })
+  },
+  {
+    pattern: /^func\s+(\w+)\s*(\[[^\]]*\])?\s*\(/,
+    build: m => ({ name: m[1], kind: SymbolKind.FUNCTION, exported: isExported(m[1]) })
+  },
+  {
+    pattern: /^type\s+(\w+)(?:\[[^\]]*\])?\s+(struct|interface)\b/,
+    build: (m, ctx) => ({
+      name: m[1],
+      kind: m[2] === 'struct' ? SymbolKind.STRUCT : SymbolKind.INTERFACE,
+      exported: isExported(m[1]),
+      container: m[2] === 'interface',
+      implements: m[2] === 'interface' ? embeddedInterfaces(ctx.lines.slice(ctx.lineIndex + 1)) : null
+    })
+  },
+  {
+    pattern: /^type\s+(\w+)(?:\[[^\]]*\])?\s*=?\s*[^\s(]/,
+    build: m => ({ name: m[1], kind: SymbolKind.TYPE, exported: isExported(m[1]) })
+  },
+  {
+    pattern: /^(const|var)\s+(\w+)/,
+    build: m => ({
+      name: m[2],
+      kind: m[1] === 'const' ? SymbolKind.CONSTANT : SymbolKind.VARIABLE,
+      exported: isExported(m[2])
+    })
+  }
+];
+
+const INTERFACE_RULES = [
+  {
+    pattern: /^(\w+)\s*(\[[^\]]*\])?\s*\(/,
+    build: m => ({ name: m[1], kind: SymbolKind.METHOD, exported: isExported(m[1]), singleLine: true })
+  }
+];
+
+const IMPORT_BLOCK_PATTERN = /^import\s*\(([\s\S]*?)^\)/gm;
+const IMPORT_LINE_PATTERN = /^import\s+(?:([\w.]+)\s+)?"([^"]+)"/gm;
+const IMPORT_SPEC_PATTERN = /^\s*(?:([\w.]+)\s+)?"([^"]+)"/;
+const PACKAGE_PATTERN = /^package\s+\w+/m;
+const REPLACE_BLOCK_PATTERN = /^replace\s*\(([\s\S]*?)^\)/gm;
+const REPLACE_SPEC_PATTERN = /^\s*(?:replace\s+)?(\S+)(?:\s+\S+)?\s*=>\s*(\.{1,2}\/\S*|\.{1,2})\s*$/;
+
+function isExported(name) {
+  return /^[A-Z]/.test(name);
+}
+
+/**
+ * Interfaces embedded in an interface body (`io.Reader` -> Reader)
+ * @param {Array<string>} bodyLines - Lines after the opening brace
+ * @returns {Array<string>|null}
+ */
+function embeddedInterfaces(bodyLines) {
+  const names = [];
+
+  for (const line of bodyLines) {
+    const text = line.replace(/\/\/.*$/, '').trim();
+    if (text.startsWith('}')) break;
+    const embedded = text.match(/^(?:\w+\.)?(\w+)$/);
+    if (embedded) names.push(embedded[1]);
+  }
+
+  return names.length > 0 ? names : null;
+}
+
+/**
+ * Nearest go.mod above a file, with its local replace directives
+ * @param {string} fromFile
+ * @param {ProjectIndex} project
+ * @returns {{root: string, module: string, replaces: Array<{module: string, dir: string}>}|null}
+ */
+function findGoModule(fromFile, project) {
+  let dir = path.posix.dirname(fromFile);
+
+  while (true) {
+    const goMod = project.readFile(dir === '.' ? 'go.mod' : `${dir}/go.mod`) || '';
+    const match = goMod.match(/^module\s+(\S+)/m);
+    if (match) return { root: dir, module: match[1], replaces: localReplaces(goMod, dir) };
+    if (dir === '.') return null;
+    dir = path.posix.dirname(dir);
+  }
+}
+
+/**
+ * `replace example.com/lib => ./lib` directives, as project directories
+ * @param {string} goMod - go.mod content
+ * @param {string} root - Directory of the go.mod
+ * @returns {Array<{module: string, dir: string}>}
+ */
+function localReplaces(goMod, root) {
+  const specs = [];
+  for (const match of goMod.matchAll(REPLACE_BLOCK_PATTERN)) {
+    specs.push(...match[1].split('\n'));
+  }
+  specs.push(...goMod.split('\n').filter(line => /^replace\s+[^(\s]/.test(line)));
+
+  return specs
+    .map(line => line.replace(/\/\/.*$/, '').match(REPLACE_SPEC_PATTERN))
+    .filter(Boolean)
+    .map(match => ({ module: match[1], dir: path.posix.join(root, match[2]) }))
+    .filter(replace => !replace.dir.startsWith('..'));
+}
+
+/**
+ * Directories of the project holding a go.mod
+ * @param {ProjectIndex} project
+ * @returns {Array<string>}
+ */
+function goModuleDirs(project) {
+  return [...project.dirs]
+    .filter(dir => project.readFile(dir === '.' ? 'go.mod' : `${dir}/go.mod`) !== null)
+    .sort();
+}
+
+/**
+ * Modules every package of the project may import: the `use` directories of
+ * a go.work at the root, or the repositories of a multi-repo workspace
+ * @param {ProjectIndex} project
+ * @returns {Array<{module: string, dir: string}>}
+ */
+function workspaceModules(project) {
+  return project.memo('go-workspace-modules', () => {
+    const dirs = project.workspace
+      ? project.workspace.repos.map(repo => repo.prefix || '.')
+      : goWorkUses(project.readFile('go.work') || '').map(dir => path.posix.normalize(dir));
+
+    return dirs
+      .filter(dir => !dir.startsWith('..'))
+      .map(dir => ({ dir, match: (project.readFile(dir === '.' ? 'go.mod' : `${dir}/go.mod`) || '').match(/^module\s+(\S+)/m) }))
+      .filter(({ match }) => match)
+      .map(({ dir, match }) => ({ module: match[1], dir }));
+  });
+}
+
+/**
+ * Directory of an import path under a module path, or null
+ */
+function packageDir(source, module, dir) {
+  if (source === module) return dir;
+  return source.startsWith(`${module}/`) ? path.posix.join(dir, source.slice(module.length + 1)) : null;
+}
+
+/**
+ * 0-based line indices that sit inside a `type ( ... )` group
+ * @param {Array<string>} lines
+ * @returns {Set<number>}
+ */
+function typeGroupLines(lines) {
+  const result = new Set();
+  let inGroup = false;
+
+  for (let i = 0; i < lines.length; i++) {
+    if (!inGroup && /^type\s*\(\s*$/.test(lines[i])) {
+      inGroup = true;
+    } else if (inGroup && /^\)/.test(lines[i])) {
+      inGroup = false;
+    } else if (inGroup) {
+      result.add(i);
+    }
+  }
+
+  return result;
+}
+
+export class GoPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'go';
+    this.extensions = ['.go'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  getTreeSitterGrammar() {
+    return { package: 'tree-sitter-go' };
+  }
+
+  extractSymbols(content, filePath) {
+    const grouped = typeGroupLines(content.split('\n'));
+
+    return extractWithRules(content, filePath, {
+      language: 'go',
+      scanOptions: { quotes: ['"', "'", '`'] },
+      topLevel: TOP_LEVEL_RULES,
+      members: INTERFACE_RULES,
+      newlineTerminated: true,
+      doc: { linePrefixes: ['//'], blockDocs: false },
+      // Specs inside `type ( ... )` read like standalone type declarations
+      normalizeLine: (text, i) => (grouped.has(i) ? `type ${text}` : text)
+    });
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  extractImports(content) {
+    const imports = [];
+
+    for (const match of content.matchAll(IMPORT_BLOCK_PATTERN)) {
+      const firstLine = lineNumberAt(content, match.index);
+      match[1].split('\n').forEach((text, i) => {
+        const spec = text.match(IMPORT_SPEC_PATTERN);
+        if (spec) {
+          imports.push({ source: spec[2], names: null, alias: spec[1] || null, line: firstLine + i });
+        }
+      });
+    }
+    for (const match of content.matchAll(IMPORT_LINE_PATTERN)) {
+      imports.push({ source: match[2], names: null, alias: match[1] || null, line: lineNumberAt(content, match.index) });
+    }
+
+    return imports.sort((a, b) => a.line - b.line);
+  }
+
+  /**
+   * Package clause plus whole import blocks, so excerpts keep `import ( ... )`
+   */
+  extractPreamble(content) {
+    const imports = this.extractImports(content);
+    const ranges = [];
+
+    const packageMatch = PACKAGE_PATTERN.exec(content);
+    if (packageMatch) {
+      const line = lineNumberAt(content, packageMatch.index);
+      ranges.push({ startLine: line, endLine: line, imports: [] });
+    }
+
+    for (const match of content.matchAll(IMPORT_BLOCK_PATTERN)) {
+      const startLine = lineNumberAt(content, match.index);
+      const endLine = lineNumberAt(content, match.index + match[0].length - 1);
+      ranges.push({ startLine, endLine, imports: imports.filter(i => i.line >= startLine && i.line <= endLine) });
+    }
+    for (const match of content.matchAll(IMPORT_LINE_PATTERN)) {
+      const line = lineNumberAt(content, match.index);
+      ranges.push({ startLine: line, endLine: line, imports: imports.filter(i => i.line === line) });
+    }
+
+    return ranges.sort((a, b) => a.startLine - b.startLine);
+  }
+
+  /**
+   * Packages bind their alias, their declared name when the graph resolved
+   * them, or their last path element (major version suffixes skipped)
+   */
+  getImportBindings(imported) {
+    if (imported.alias === '_' || imported.alias === '.') return null;
+    if (imported.alias) return [imported.alias];
+    if (imported.packageName) return [imported.packageName];
+
+    const segments = imported.source.split('/');
+    const last = segments.pop();
+    return [/^v\d+$/.test(last) && segments.length > 0 ? segments.pop() : last];
+  }
+
+  /**
+   * Imported symbols are referenced as `pkg.Name`; dot imports make them
+   * unqualified and blank imports bind nothing
+   */
+  getImportQualifier(imported) {
+    if (imported.alias === '_') return false;
+    if (imported.alias === '.') return null;
+    return this.getImportBindings(imported)[0];
+  }
+
+  /**
+   * The package clause; external test packages (`package calc_test`) only
+   * when nothing else declares the package
+   */
+  getPackageName(symbols) {
+    const names = symbols.filter(symbol => symbol.kind === SymbolKind.MODULE).map(symbol => symbol.name);
+    return names.find(name => !name.endsWith('_test')) || names[0] || null;
+  }
+
+  /**
+   * Import paths map to package directories as `go list` reports them, which
+   * follows go.work, replace directives and nested modules. Without the go
+   * command, paths under the enclosing go.mod module (or a local replace)
+   * map to directories, as do paths under the modules of a go.work or a
+   * multi-repo workspace; without go.mod, GOPATH-style paths (host/...) are
+   * matched by their trailing directories. Standard library paths stay external.
+   */
+  resolveImport(imported, fromFile, project) {
+    // `go list` sees the working tree, not the revision files are read from
+    const packages = project.memo('go-packages', () => project.revision ? null : GoPackages.load(project, goModuleDirs(project)));
+    if (packages) {
+      const listed = packages.get(imported.source);
+      if (listed && project.hasDir(listed.dir)) return listed.dir;
+    }
+
+    const goModule = findGoModule(fromFile, project);
+
+    if (goModule) {
+      const { root, module, replaces } = goModule;
+      // The longest matching module path wins, as with nested modules
+      const modules = [{ module, dir: root }, ...replaces, ...workspaceModules(project)]
+        .sort((a, b) => b.module.length - a.module.length);
+      for (const replace of modules) {
+        const dir = packageDir(imported.source, replace.module, replace.dir);
+        if (dir !== null) return project.hasDir(dir) ? dir : null;
+      }
+      return null;
+    }
+
+    const segments = imported.source.split('/');
+    if (!segments[0].includes('.')) return null;
+
+    for (let i = 1; i < segments.length; i++) {
+      const dir = segments.slice(i).join('/');
+      if (project.hasDir(dir)) return dir;
+    }
+    return null;
+  }
+
+  /**
+   * Go types implement interfaces implicitly
+   */
+  hasStructuralInterfaces() {
+    return true;
+  }
+
+  /**
+   * Go packages are directories
+   */
+  getPackageId(filePath) {
+    return path.posix.dirname(filePath);
+  }
+
+  describeTreeSitterNode(node, scope) {
+    const name = node.childForFieldName('name')?.text;
+
+    switch (node.type) {
+      case 'package_clause': {
+        const identifier = node.namedChildren.find(c => c.type === 'package_identifier');
+        return { name: identifier?.text, kind: SymbolKind.MODULE, exported: true };
+      }
+      case 'function_declaration':
+        return { name, kind: SymbolKind.FUNCTION, exported: isExported(name) };
+      case 'method_declaration': {
+        const receiver = node.childForFieldName('receiver')?.text || '';
+        const receiverType = receiver.replace(/[()*]/g, ' ').replace(/\[.*$/, '').trim().split(/\s+/).pop();
+        return { name, kind: SymbolKind.METHOD, parent: receiverType, exported: isExported(name) };
+      }
+      case 'type_declaration':
+        return null;
+      case 'type_spec':
+      case 'type_alias': {
+        const type = node.childForFieldName('type');
+        const kind = type?.type === 'struct_type'
+          ? SymbolKind.STRUCT
+          : type?.type === 'interface_type' ? SymbolKind.INTERFACE : SymbolKind.TYPE;
+        const declaration = node.parent;
+        const standalone = declaration?.type === 'type_declaration' &&
+          declaration.namedChildren.filter(c => c.type !== 'comment').length === 1;
+        return {
+          name,
+          kind,
+          exported: isExported(name),
+          anchor: standalone ? declaration : node,
+          container: kind === SymbolKind.INTERFACE,
+          body: kind === SymbolKind.INTERFACE ? type : undefined,
+          implements: kind === SymbolKind.INTERFACE ? embeddedInterfaces(type.text.split('\n').slice(1)) : null
+        };
+      }
+      case 'method_spec':
+      case 'method_elem':
+        return { name, kind: SymbolKind.METHOD, exported: isExported(name) };
+      default:
+        return { symbol: false };
+    }
+  }
+}
+
+export default GoPlugin;
@@ -0,0 +1,197 @@
+/**
+ * JavaPlugin - Java symbol extraction
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Uses tree-sitter-java when installed, otherwise a brace-aware heuristic
+ * parser. Javadoc comments become symbol docs; `public` decides exports.
+ */
+
+import path from 'path';
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { extractWithRules } from '../symbols/HeuristicExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { lineNumberAt, declarationHeader, supertypeNames } from '../symbols/SourceScanner.js';
+
+const TYPE_KINDS = {
+  class: SymbolKind.CLASS,
+  record: SymbolKind.CLASS,
+  interface: SymbolKind.INTERFACE,
+  '@interface': SymbolKind.INTERFACE,
+  enum: SymbolKind.ENUM
+};
+const NOT_TYPES = new Set(['return', 'new', 'throw', 'else', 'case', 'yield']);
+const IMPORT_PATTERN = /^[ \t]*import\s+(static\s+)?([\w.]+?)(\.\*)?\s*;/gm;
+const PACKAGE_PATTERN = /^[ \t]*package\s+[\w.]+\s*;/m;
+
+const TYPE_RULES = [
+  {
+    pattern: /^((?:public|protected|private|static|final|abstract|sealed|non-sealed|strictfp)\s+)*(class|interface|enum|record|@interface)\s+(\w+)/,
+    build: (m, ctx) => ({
+      name: m[3],
+      kind: TYPE_KINDS[m[2]],
+      exported: isPublic(m[0], ctx),
+      container: true,
+      implements: supertypeNames(declarationHeader(ctx.lines, ctx.lineIndex), m[2] === 'interface' ? 'extends' : 'implements')
+    })
+  }
+];
+
+const MEMBER_RULES = [
+  {
+    pattern: /^((?:public|protected|private)\s+)?(<[^>]+>\s+)?(\w+)\s*\(/,
+    build: (m, ctx) => {
+      if (m[3] !== lastSegment(ctx.container.name)) return null;
+      return { name: m[3], kind: SymbolKind.METHOD, exported: isPublic(m[0], ctx) };
+    }
+  },
+  {
+    pattern: /^((?:public|protected|private|static|final|abstract|synchronized|native|default|strictfp)\s+)*(<[^>]+>\s+)?([\w.$]+(?:<.*>)?(?:\[\])*)\s+(\w+)\s*\(/,
+    build: (m, ctx) => {
+      if (NOT_TYPES.has(m[3])) return null;
+      return { name: m[4], kind: SymbolKind.METHOD, exported: isPublic(m[0], ctx) };
+    }
+  }
+];
+
+/**
+ * Members of interfaces are implicitly public; everything else needs `public`
+ */
+function isPublic(text, ctx) {
+  const container = ctx.container;
+  if (container && container.kind === SymbolKind.INTERFACE) {
+    return container.exported && !/\bprivate\b/.test(text);
+  }
+  const declared = /\bpublic\b/.test(text);
+  return container ? declared && container.exported : declared;
+}
+
+/**
+ * Supertypes named in a tree-sitter type header
+ */
+function heritage(node, keyword) {
+  const body = node.childForFieldName('body');
+  const header = body ? node.text.slice(0, body.startIndex - node.startIndex) : node.text;
+  return supertypeNames(header.replace(/\s+/g, ' '), keyword);
+}
+
+function lastSegment(name) {
+  const parts = name.split('.');
+  return parts[parts.length - 1];
+}
+
+export class JavaPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'java';
+    this.extensions = ['.java'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  getTreeSitterGrammar() {
+    return { package: 'tree-sitter-java' };
+  }
+
+  extractSymbols(content, filePath) {
+    return extractWithRules(content, filePath, {
+      language: 'java',
+      scanOptions: { quotes: ['"', "'"] },
+      topLevel: TYPE_RULES,
+      members: MEMBER_RULES,
+      nestedTypes: true,
+      attribute: /^@(?!interface\b)[\w.]+/,
+      doc: { linePrefixes: ['//'], blockDocs: true }
+    });
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  extractImports(content) {
+    return [...content.matchAll(IMPORT_PATTERN)].map(match => {
+      const [, isStatic, name, wildcard] = match;
+      const line = lineNumberAt(content, match.index);
+
+      if (wildcard) {
+        return { source: name, names: null, wildcard: true, line };
+      }
+      // `import static a.B.member` depends on class a.B
+      const source = isStatic ? name.split('.').slice(0, -1).join('.') : name;
+      return { source, names: isStatic ? null : [lastSegment(name)], line };
+    });
+  }
+
+  /**
+   * Package declaration plus import statements
+   */
+  extractPreamble(content, filePath) {
+    const ranges = super.extractPreamble(content, filePath);
+
+    const packageMatch = PACKAGE_PATTERN.exec(content);
+    if (packageMatch) {
+      const line = lineNumberAt(content, packageMatch.index);
+      ranges.unshift({ startLine: line, endLine: line, imports: [] });
+    }
+
+    return ranges;
+  }
+
+  /**
+   * Imports resolve by package path suffix, so any source root works
+   * (src/main/java, app/src/...). Nested class imports fall back to the
+   * outer class file.
+   */
+  resolveImport(imported, fromFile, project) {
+    const segments = imported.source.split('.');
+
+    if (imported.wildcard) {
+      return project.findBySuffix(segments.join('/'), 'dir');
+    }
+
+    for (let i = segments.length; i > 1; i--) {
+      const file = project.findBySuffix(`${segments.slice(0, i).join('/')}.java`);
+      if (file) return file;
+    }
+    return null;
+  }
+
+  /**
+   * Java packages are directories
+   */
+  getPackageId(filePath) {
+    return path.posix.dirname(filePath);
+  }
+
+  describeTreeSitterNode(node, scope) {
+    const name = node.childForFieldName('name')?.text;
+    const modifiers = node.namedChildren.find(c => c.type === 'modifiers')?.text || '';
+    const inInterface = scope.parentKind === SymbolKind.INTERFACE;
+    const exported = inInterface
+      ? scope.exported && !/\bprivate\b/.test(modifiers)
+      : /\bpublic\b/.test(modifiers) && (scope.parent === null || scope.exported);
+
+    switch (node.type) {
+      case 'class_declaration':
+      case 'record_declaration':
+        return { name, kind: SymbolKind.CLASS, exported, container: true, implements: heritage(node, 'implements') };
+      case 'interface_declaration':
+      case 'annotation_type_declaration':
+        return { name, kind: SymbolKind.INTERFACE, exported, container: true, implements: heritage(node, 'extends') };
+      case 'enum_declaration':
+        return { name, kind: SymbolKind.ENUM, exported, container: true, implements: heritage(node, 'implements') };
+      case 'method_declaration':
+      case 'constructor_declaration':
+        return { name, kind: SymbolKind.METHOD, exported };
+      case 'enum_body_declarations':
+        return null;
+      default:
+        return { symbol: false };
+    }
+  }
+}
+
+export default JavaPlugin;
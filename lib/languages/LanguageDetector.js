@@ -0,0 +1,183 @@
+/**
+ * LanguageDetector - Languages of files their extension does not name
+ * v3.4.0 - Language detection beyond file extensions
+ *
+ * Responsibilities:
+ * - Recognize build and tool files by name: Dockerfile, Makefile, BUILD,
+ *   WORKSPACE, Jenkinsfile, Rakefile, Gemfile, CMakeLists.txt, ...
+ * - Read the interpreter of extension-less scripts from their shebang,
+ *   through env and its options (#!/usr/bin/env -S python3 -u)
+ * - Fall back to vim and emacs modelines, and a leading <?php
+ * - Map each language to the extension its plugin, token model and
+ *   comment syntax are registered under, so extension-based routing
+ *   handles detected files too
+ *
+ * A real extension always wins over content; file names win over both,
+ * so Dockerfile.dev and BUILD.bazel are recognized by name.
+ */
+
+import fs from 'fs';
+import path from 'path';
+
+// Extension each detected language is handled as
+export const DETECTED_LANGUAGES = {
+  python: '.py',
+  starlark: '.py', // Bazel and Buck files: Python syntax
+  javascript: '.js',
+  typescript: '.ts',
+  ruby: '.rb',
+  shell: '.sh',
+  perl: '.pl',
+  php: '.php',
+  lua: '.lua',
+  r: '.r',
+  awk: '.awk',
+  tcl: '.tcl',
+  elixir: '.exs',
+  groovy: '.groovy',
+  dockerfile: '.dockerfile',
+  makefile: '.mk',
+  cmake: '.cmake',
+  just: '.just'
+};
+
+// Bytes read to find a shebang or modeline
+export const HEAD_BYTES = 512;
+
+const FILENAME_PATTERNS = [
+  [/^(dockerfile|containerfile)([.-]\w+)?$|\.(dockerfile|containerfile)$/i, 'dockerfile'],
+  [/^(gnu)?makefile(\.(am|in))?$|\.mk$/i, 'makefile'],
+  [/^(BUILD|WORKSPACE|BUCK|TARGETS)(\.bazel|\.bzl)?$|^MODULE\.bazel$|^Tiltfile$|\.(bzl|star)$/, 'starlark'],
+  [/^(SConstruct|SConscript)$/, 'python'],
+  [/^(Rakefile|Gemfile|Podfile|Vagrantfile|Brewfile|Guardfile|Fastfile|Appfile|Dangerfile|Berksfile|Capfile)$|\.(gemspec|rake)$/, 'ruby'],
+  [/^Jenkinsfile$|\.gradle$/, 'groovy'],
+  [/^CMakeLists\.txt$/, 'cmake'],
+  [/^[Jj]ustfile$|^\.justfile$/, 'just'],
+  [/^(\.bashrc|\.bash_profile|\.bash_aliases|\.zshrc|\.zprofile|\.zshenv|\.profile|\.envrc|PKGBUILD|APKBUILD)$/, 'shell']
+];
+
+// Interpreters of shebang lines, version suffixes removed (python3.12 -> python)
+const INTERPRETERS = {
+  python: 'python', pypy: 'python',
+  node: 'javascript', nodejs: 'javascript',
+  deno: 'typescript', bun: 'typescript', 'ts-node': 'typescript', tsx: 'typescript',
+  sh: 'shell', bash: 'shell', zsh: 'shell', ksh: 'shell', dash: 'shell', ash: 'shell', fish: 'shell',
+  ruby: 'ruby', jruby: 'ruby',
+  perl: 'perl',
+  php: 'php',
+  lua: 'lua', luajit: 'lua',
+  rscript: 'r',
+  awk: 'awk', gawk: 'awk', mawk: 'awk', nawk: 'awk',
+  tclsh: 'tcl', wish: 'tcl',
+  elixir: 'elixir',
+  groovy: 'groovy',
+  make: 'makefile'
+};
+
+// Modeline names that differ from language names
+const MODELINE_NAMES = { sh: 'shell', bash: 'shell', zsh: 'shell', js: 'javascript', ts: 'typescript', make: 'makefile', bzl: 'starlark' };
+
+const MODELINE_PATTERNS = [
+  /-\*-.*?\bmode:\s*([\w+-]+).*?-\*-/i,
+  /-\*-\s*([\w+-]+)\s*-\*-/,
+  /\bvim?:.*?\b(?:ft|filetype|syntax)=([\w+-]+)/
+];
+
+export class LanguageDetector {
+  /**
+   * Language of a file by name, then (without an extension) by content
+   * @param {string} filePath
+   * @param {string|null} [content] - Content or its first lines
+   * @returns {string|null} Key of DETECTED_LANGUAGES
+   */
+  static detect(filePath, content = null) {
+    const byName = LanguageDetector.fromName(filePath);
+    if (byName || content === null || path.extname(filePath || '') !== '') return byName;
+    return LanguageDetector.fromContent(content);
+  }
+
+  /**
+   * Language recognized from a file name alone
+   * @param {string} filePath
+   * @returns {string|null}
+   */
+  static fromName(filePath) {
+    const name = path.basename(filePath || '');
+    const match = FILENAME_PATTERNS.find(([pattern]) => pattern.test(name));
+    return match ? match[1] : null;
+  }
+
+  /**
+   * Language from a shebang, a modeline or a leading <?php
+   * @param {string} content
+   * @returns {string|null}
+   */
+  static fromContent(content) {
+    const head = content.slice(0, HEAD_BYTES);
+    if (head.startsWith('#!')) {
+      const language = shebangLanguage(head.slice(2, head.indexOf('\n') === -1 ? head.length : head.indexOf('\n')));
+      if (language) return language;
+    }
+    if (/^<\?php\b/.test(head)) return 'php';
+
+    for (const line of head.split('\n').slice(0, 5)) {
+      for (const pattern of MODELINE_PATTERNS) {
+        const match = pattern.exec(line);
+        const name = match?.[1].toLowerCase();
+        const language = name && (MODELINE_NAMES[name] || (Object.hasOwn(DETECTED_LANGUAGES, name) ? name : null));
+        if (language) return language;
+      }
+    }
+    return null;
+  }
+
+  /**
+   * Extension a file is handled as: its detected language's, else its own
+   * @param {string} filePath
+   * @param {string|null} [content]
+   * @returns {string} Lower-case extension, '' when neither is known
+   */
+  static extensionOf(filePath, content = null) {
+    const language = LanguageDetector.detect(filePath, content);
+    return language ? DETECTED_LANGUAGES[language] : path.extname(filePath || '').toLowerCase();
+  }
+
+  /**
+   * Language of an extension-less file from its first bytes on disk
+   * @param {string} filePath - Absolute path
+   * @returns {string|null} Null for unreadable files
+   */
+  static sniff(filePath) {
+    let fd = null;
+    try {
+      fd = fs.openSync(filePath, 'r');
+      const buffer = Buffer.alloc(HEAD_BYTES);
+      const read = fs.readSync(fd, buffer, 0, HEAD_BYTES, 0);
+      return LanguageDetector.fromContent(buffer.subarray(0, read).toString('utf8'));
+    } catch (error) {
+      return null;
+    } finally {
+      if (fd !== null) fs.closeSync(fd);
+    }
+  }
+}
+
+/**
+ * Language of the interpreter a shebang line names
+ * @private
+ */
+function shebangLanguage(line) {
+  const words = line.trim().split(/\s+/);
+  let command = path.posix.basename(words.shift() || '');
+
+  // env [-S] [-i] [NAME=value ...] interpreter
+  if (command === 'env') {
+    command = words.find(word => !word.startsWith('-') && !/^\w+=/.test(word)) || '';
+    command = path.posix.basename(command);
+  }
+
+  const name = command.toLowerCase().replace(/[\d.]+$/, '');
+  return Object.hasOwn(INTERPRETERS, name) ? INTERPRETERS[name] : null;
+}
+
+export default LanguageDetector;
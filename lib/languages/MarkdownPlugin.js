@@ -0,0 +1,78 @@
+/**
+ * MarkdownPlugin - Fenced code extraction from Markdown
+ * v3.4.0 - Notebook and Markdown code extraction
+ *
+ * Symbols of Markdown files are those of their fenced code blocks
+ * (```ts ... ```), parsed by the plugin of the fence's language at the
+ * block's lines. Blocks of one language are parsed together, so a class
+ * split over several blocks keeps its methods. Code in documentation is
+ * an example, not a dependency, so Markdown files import nothing.
+ */
+
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { embeddedLanguage, embeddedSymbols } from './EmbeddedCode.js';
+
+const FENCE_PATTERN = /^( {0,3})(`{3,}|~{3,})\s*([^`\s]*)/;
+
+export class MarkdownPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'markdown';
+    this.extensions = ['.md', '.markdown', '.mdx'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  validate(content) {
+    return super.validate(content) && /^ {0,3}(```|~~~)/m.test(content);
+  }
+
+  extractSymbols(content, filePath) {
+    const lines = content.split('\n');
+    const symbols = [];
+    for (const { embedded, ranges } of fencedBlocks(lines).values()) {
+      symbols.push(...embeddedSymbols(embedded, lines, ranges, filePath));
+    }
+    return symbols;
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+}
+
+/**
+ * Code lines of the fenced blocks, grouped by language
+ * @returns {Map<string, {embedded: Object, ranges: Array<{startLine: number, endLine: number}>}>}
+ * @private
+ */
+function fencedBlocks(lines) {
+  const blocks = new Map();
+  let open = null;
+
+  lines.forEach((line, index) => {
+    const fence = line.match(FENCE_PATTERN);
+    if (open) {
+      // A closing fence uses the opening character at least as often, with no info string
+      if (fence && fence[2][0] === open.fence[0] && fence[2].length >= open.fence.length && !fence[3]) {
+        if (open.embedded && index > open.start) {
+          const key = open.embedded.extension;
+          if (!blocks.has(key)) blocks.set(key, { embedded: open.embedded, ranges: [] });
+          blocks.get(key).ranges.push({ startLine: open.start + 1, endLine: index });
+        }
+        open = null;
+      }
+      return;
+    }
+    if (fence) {
+      open = { fence: fence[2], embedded: embeddedLanguage(fence[3]), start: index + 1 };
+    }
+  });
+
+  return blocks;
+}
+
+export default MarkdownPlugin;
@@ -0,0 +1,166 @@
+/**
+ * NotebookPlugin - Jupyter notebook extraction
+ * v3.4.0 - Notebook and Markdown code extraction
+ *
+ * Notebooks are read as percent-format scripts (`# %%` cell markers, as
+ * jupytext writes them) instead of their JSON: code cells stay code,
+ * markdown and raw cells become comments, and outputs are dropped unless
+ * asked for (--notebook-outputs), as commented text under `# %% [output]`.
+ * Symbols and imports of the code cells come from the plugin of the
+ * kernel's language (Python, JavaScript, TypeScript, ...), at the lines of
+ * the percent-format text.
+ */
+
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { embeddedLanguage, embeddedSymbols, embeddedImports } from './EmbeddedCode.js';
+
+export const NOTEBOOK_EXTENSION = '.ipynb';
+
+// Output lines kept per output before the rest is elided
+const MAX_OUTPUT_LINES = 40;
+
+const HEADER_PATTERN = /^(#|\/\/) Jupyter notebook \(([^)]*)\)$/;
+const CELL_PATTERN = /^(?:#|\/\/) %%(?: \[(\w+)\])?$/;
+
+/**
+ * Percent-format text of a notebook
+ * Text that is not notebook JSON is returned unchanged.
+ * @param {string} content - Notebook JSON
+ * @param {Object} [options] - { outputs: include text outputs of code cells }
+ * @returns {string}
+ */
+export function notebookToText(content, options = {}) {
+  let notebook;
+  try {
+    notebook = JSON.parse(content);
+  } catch {
+    return content;
+  }
+  if (!Array.isArray(notebook?.cells)) return content;
+
+  const language = notebook.metadata?.language_info?.name || notebook.metadata?.kernelspec?.language || 'python';
+  const comment = embeddedLanguage(language)?.plugin.name === 'typescript' ? '//' : '#';
+  const commented = text => text.split('\n').map(line => (line ? `${comment} ${line}` : comment)).join('\n');
+
+  const sections = [`${comment} Jupyter notebook (${language})`];
+  for (const cell of notebook.cells) {
+    const source = joinSource(cell.source).replace(/\n+$/, '');
+    if (cell.cell_type === 'code') {
+      sections.push(`${comment} %%${source ? `\n${source}` : ''}`);
+      const outputs = options.outputs ? (cell.outputs || []).map(outputText).filter(Boolean) : [];
+      if (outputs.length > 0) {
+        sections.push(`${comment} %% [output]\n${commented(outputs.join('\n'))}`);
+      }
+    } else {
+      const kind = cell.cell_type === 'markdown' ? 'markdown' : 'raw';
+      sections.push(`${comment} %% [${kind}]${source ? `\n${commented(source)}` : ''}`);
+    }
+  }
+  return `${sections.join('\n\n')}\n`;
+}
+
+/**
+ * Kernel language of percent-format notebook text, or null
+ * @param {string} text - Result of notebookToText()
+ * @returns {string|null}
+ */
+export function notebookLanguage(text) {
+  return (text || '').split('\n', 1)[0].match(HEADER_PATTERN)?.[2] || null;
+}
+
+export class NotebookPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'notebook';
+    this.extensions = [NOTEBOOK_EXTENSION];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  extractSymbols(content, filePath) {
+    const cells = codeCells(content);
+    return cells ? embeddedSymbols(cells.embedded, cells.lines, cells.ranges, filePath) : [];
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  extractImports(content, filePath) {
+    const cells = codeCells(content);
+    if (!cells) return [];
+    return embeddedImports(cells.embedded, cells.lines, cells.ranges, filePath)
+      .map(imported => ({ ...imported, language: cells.language }));
+  }
+
+  extractPreamble(content, filePath) {
+    return super.extractPreamble(notebookToText(content), filePath);
+  }
+
+  /**
+   * Local imports resolve like the kernel language's, from the notebook's directory
+   */
+  resolveImport(imported, fromFile, project) {
+    return embeddedLanguage(imported.language)?.plugin.resolveImport(imported, fromFile, project) ?? null;
+  }
+}
+
+/**
+ * Lines of the code cells, and the plugin parsing them
+ * @private
+ */
+function codeCells(content) {
+  const text = notebookToText(content);
+  const language = notebookLanguage(text);
+  const embedded = language && embeddedLanguage(language);
+  if (!embedded) return null;
+  const lines = text.split('\n');
+
+  const ranges = [];
+  let start = null;
+  lines.forEach((line, index) => {
+    const marker = line.match(CELL_PATTERN);
+    if (!marker) return;
+    if (start !== null && index > start) ranges.push({ startLine: start + 1, endLine: index });
+    start = marker[1] ? null : index + 1;
+  });
+  if (start !== null && start < lines.length) ranges.push({ startLine: start + 1, endLine: lines.length });
+
+  return { embedded, language, lines, ranges };
+}
+
+/**
+ * @private
+ */
+function joinSource(source) {
+  return Array.isArray(source) ? source.join('') : source || '';
+}
+
+/**
+ * Text of a cell output; rich outputs are named by their type
+ * @private
+ */
+function outputText(output) {
+  let text;
+  if (output.output_type === 'stream') {
+    text = joinSource(output.text);
+  } else if (output.output_type === 'error') {
+    text = `${output.ename}: ${output.evalue}`;
+  } else if (output.data?.['text/plain'] !== undefined) {
+    text = joinSource(output.data['text/plain']);
+  } else {
+    const types = Object.keys(output.data || {});
+    text = types.length > 0 ? `[${types.join(', ')}]` : '';
+  }
+
+  const lines = text.replace(/\n+$/, '').split('\n');
+  if (lines.length > MAX_OUTPUT_LINES) {
+    return [...lines.slice(0, MAX_OUTPUT_LINES), `[... ${lines.length - MAX_OUTPUT_LINES} more lines]`].join('\n');
+  }
+  return lines.join('\n');
+}
+
+export default NotebookPlugin;
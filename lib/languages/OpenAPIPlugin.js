@@ -0,0 +1,364 @@
+/**
+ * OpenAPIPlugin - OpenAPI/Swagger schema extraction
+ * v3.4.0 - API schema extraction
+ *
+ * Claims .yaml, .yml and .json files, but only extracts from documents with
+ * a top-level `openapi` or `swagger` key; other files yield no symbols.
+ * Each operation becomes a function symbol (named by operationId, else
+ * "GET /path") and each schema a type symbol, so API documents rank and pack
+ * like code. External `$ref`s are imports.
+ *
+ * Documents are outlined line by line rather than parsed, so symbols keep
+ * their line ranges and YAML block scalars or anchors need no full parser.
+ */
+
+import path from 'path';
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { createSymbol, SymbolKind } from '../symbols/SymbolModel.js';
+
+const HTTP_METHODS = ['get', 'put', 'post', 'delete', 'options', 'head', 'patch', 'trace'];
+const DOCUMENT_PATTERN = /^\s*["']?(?:openapi|swagger)["']?\s*:/m;
+const YAML_KEY = /^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s"'#][^#]*?)\s*:(?:[ \t]+(.*))?$/;
+const BLOCK_SCALAR = /^[|>][-+0-9]*$/;
+
+export class OpenAPIPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'openapi';
+    this.extensions = ['.yaml', '.yml', '.json'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  validate(content) {
+    return super.validate(content) && DOCUMENT_PATTERN.test(content);
+  }
+
+  extractSymbols(content, filePath) {
+    const root = outline(content, filePath);
+    const version = root && (child(root, 'openapi') || child(root, 'swagger'));
+    if (!version) return [];
+
+    const symbol = fields => createSymbol({ language: 'openapi', file: filePath, exported: true, ...fields });
+    const info = child(root, 'info');
+    const title = child(info, 'title')?.value;
+    const symbols = [symbol({
+      name: title || path.basename(filePath, path.extname(filePath)),
+      kind: SymbolKind.MODULE,
+      startLine: version.line,
+      endLine: version.line,
+      signature: [`${version.key} ${version.value}`, title, child(info, 'version')?.value].filter(Boolean).join(' - '),
+      doc: child(info, 'description')?.value || null
+    })];
+
+    for (const pathItem of child(root, 'paths')?.children || []) {
+      for (const operation of pathItem.children.filter(node => HTTP_METHODS.includes(node.key))) {
+        const operationId = child(operation, 'operationId')?.value;
+        const summary = child(operation, 'summary')?.value;
+        const endpoint = `${operation.key.toUpperCase()} ${pathItem.key}`;
+        symbols.push(symbol({
+          name: operationId || endpoint,
+          kind: SymbolKind.FUNCTION,
+          startLine: operation.line,
+          endLine: operation.endLine,
+          signature: [operationId ? `${endpoint} (${operationId})` : endpoint, summary].filter(Boolean).join(' - '),
+          doc: child(operation, 'description')?.value || summary || null
+        }));
+      }
+    }
+
+    const schemas = child(child(root, 'components'), 'schemas') || child(root, 'definitions');
+    for (const schema of schemas?.children || []) {
+      symbols.push(symbol({
+        name: schema.key,
+        kind: schemaKind(schema),
+        startLine: schema.line,
+        endLine: schema.endLine,
+        signature: schemaSignature(schema),
+        doc: child(schema, 'description')?.value || child(schema, 'title')?.value || null
+      }));
+    }
+
+    return symbols;
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  /**
+   * External `$ref`s ("./schemas.yaml#/Pet"), one import per referenced file
+   */
+  extractImports(content, filePath) {
+    const root = this.validate(content) ? outline(content, filePath) : null;
+    const imports = new Map();
+
+    const visit = node => {
+      if (node.key === '$ref' && typeof node.value === 'string' && !node.value.startsWith('#')) {
+        const [source, fragment] = node.value.split('#');
+        const imported = imports.get(source) || { source, names: [], line: node.line };
+        const name = fragment && fragment.split('/').pop();
+        if (name && !imported.names.includes(name)) imported.names.push(name);
+        imports.set(source, imported);
+      }
+      node.children.forEach(visit);
+    };
+    if (root) visit(root);
+
+    return [...imports.values()].map(imported => ({ ...imported, names: imported.names.length > 0 ? imported.names : null }));
+  }
+
+  /**
+   * References are relative to the referencing document; URLs are external
+   */
+  resolveImport(imported, fromFile, project) {
+    if (!imported.source || /^[a-z][\w+.-]*:/i.test(imported.source)) return null;
+
+    const target = path.posix.normalize(path.posix.join(path.posix.dirname(fromFile), imported.source));
+    return project.hasFile(target) ? target : null;
+  }
+}
+
+/**
+ * @private
+ */
+function child(node, key) {
+  return node ? node.children.find(candidate => candidate.key === key) || null : null;
+}
+
+/**
+ * @private
+ */
+function schemaKind(schema) {
+  if (child(schema, 'enum')) return SymbolKind.ENUM;
+  if (child(schema, 'properties') || ['allOf', 'oneOf', 'anyOf'].some(key => child(schema, key)) ||
+      child(schema, 'type')?.value === 'object') {
+    return SymbolKind.STRUCT;
+  }
+  return SymbolKind.TYPE;
+}
+
+/**
+ * e.g. "schema Pet { id: integer, owner: Owner, tags: array }"
+ * @private
+ */
+function schemaSignature(schema) {
+  const properties = child(schema, 'properties')?.children || [];
+  if (properties.length === 0) {
+    const type = typeOf(schema);
+    return type ? `schema ${schema.key}: ${type}` : `schema ${schema.key}`;
+  }
+
+  const fields = properties.map(property => {
+    const type = typeOf(property);
+    return type ? `${property.key}: ${type}` : property.key;
+  });
+  return `schema ${schema.key} { ${fields.join(', ')} }`;
+}
+
+/**
+ * @private
+ */
+function typeOf(node) {
+  const ref = child(node, '$ref')?.value;
+  if (typeof ref === 'string') return ref.split('/').pop();
+  const type = child(node, 'type')?.value;
+  return typeof type === 'string' ? type : null;
+}
+
+/**
+ * Key tree of a YAML or JSON document
+ * Nodes: { key, value, line, endLine, children }; sequence items have a null key.
+ * @param {string} content
+ * @param {string} filePath
+ * @returns {Object|null} Root node, or null when the document cannot be outlined
+ */
+export function outline(content, filePath) {
+  if (path.extname(filePath || '').toLowerCase() === '.json' || /^\s*[{[]/.test(content)) {
+    try {
+      return jsonOutline(content);
+    } catch {
+      return null;
+    }
+  }
+  return yamlOutline(content);
+}
+
+/**
+ * @private
+ */
+function node(key, line, value = null) {
+  return { key, value, line, endLine: line, children: [] };
+}
+
+/**
+ * Indentation-based outline of block mappings and sequences
+ * @private
+ */
+function yamlOutline(content) {
+  const lines = content.split('\n');
+  const root = node(null, 1);
+  const stack = [{ indent: -1, node: root }];
+  let block = null; // Open block scalar: { indent, node, text }
+
+  const extend = line => stack.forEach(entry => { entry.node.endLine = Math.max(entry.node.endLine, line); });
+
+  for (let i = 0; i < lines.length; i++) {
+    const raw = lines[i].replace(/\r$/, '');
+    const text = raw.trim();
+    const indent = raw.length - raw.trimStart().length;
+
+    if (block) {
+      if (!text || indent > block.indent) {
+        block.text.push(raw.slice(Math.min(indent, block.indent + 2)));
+        if (text) extend(i + 1);
+        continue;
+      }
+      block.node.value = block.text.join('\n').trim();
+      block = null;
+    }
+    if (!text || text.startsWith('#') || text === '---' || text === '...') continue;
+
+    // "- key: value" opens a mapping at the item's content column
+    let itemIndent = indent;
+    let rest = text;
+    const item = /^-(\s+|$)/.exec(text);
+    if (item) {
+      itemIndent = indent + item[0].length;
+      rest = text.slice(item[0].length);
+    }
+
+    while (stack.length > 1 && stack[stack.length - 1].indent >= (item ? indent + 1 : indent)) {
+      stack.pop();
+    }
+    const parent = stack[stack.length - 1].node;
+
+    const match = rest ? YAML_KEY.exec(rest) : null;
+    if (!match || /^[[{]/.test(rest)) {
+      if (item) parent.children.push(node(null, i + 1, rest ? scalar(rest) : null));
+      extend(i + 1);
+      continue;
+    }
+
+    const value = match[2] === undefined ? '' : stripComment(match[2]);
+    const entry = node(scalar(match[1]), i + 1, value === '' ? null : scalar(value));
+    parent.children.push(entry);
+    stack.push({ indent: itemIndent, node: entry });
+    extend(i + 1);
+
+    if (BLOCK_SCALAR.test(value)) {
+      entry.value = null;
+      block = { indent: itemIndent, node: entry, text: [] };
+    }
+  }
+  if (block) block.node.value = block.text.join('\n').trim();
+
+  return root;
+}
+
+/**
+ * @private
+ */
+function stripComment(value) {
+  if (/^["']/.test(value)) return value.trim();
+  return value.replace(/\s+#.*$/, '').trim();
+}
+
+/**
+ * @private
+ */
+function scalar(text) {
+  if (text.startsWith('"') && text.endsWith('"') && text.length > 1) {
+    try {
+      return JSON.parse(text);
+    } catch {
+      return text.slice(1, -1);
+    }
+  }
+  if (text.startsWith("'") && text.endsWith("'") && text.length > 1) {
+    return text.slice(1, -1).replace(/''/g, "'");
+  }
+  return text;
+}
+
+/**
+ * Recursive-descent outline that records the line of every key
+ * @private
+ * @throws {SyntaxError} On malformed JSON
+ */
+function jsonOutline(content) {
+  let i = 0;
+  let line = 1;
+
+  const skipWhitespace = () => {
+    while (i < content.length && /\s/.test(content[i])) {
+      if (content[i] === '\n') line++;
+      i++;
+    }
+  };
+  const expect = ch => {
+    skipWhitespace();
+    if (content[i] !== ch) throw new SyntaxError(`Expected ${ch} on line ${line}`);
+    i++;
+  };
+  const string = () => {
+    const start = i;
+    for (i++; i < content.length && content[i] !== '"'; i++) {
+      if (content[i] === '\\') i++;
+    }
+    i++;
+    return JSON.parse(content.slice(start, i));
+  };
+
+  const parseValue = target => {
+    skipWhitespace();
+    const ch = content[i];
+    if (ch === '{') {
+      i++;
+      skipWhitespace();
+      while (content[i] !== '}') {
+        skipWhitespace();
+        if (content[i] !== '"') throw new SyntaxError(`Expected a key on line ${line}`);
+        const entry = node(string(), line);
+        expect(':');
+        parseValue(entry);
+        target.children.push(entry);
+        skipWhitespace();
+        if (content[i] === ',') i++;
+        else if (content[i] !== '}') throw new SyntaxError(`Expected , or } on line ${line}`);
+        skipWhitespace();
+      }
+      i++;
+    } else if (ch === '[') {
+      i++;
+      skipWhitespace();
+      while (content[i] !== ']') {
+        const entry = node(null, line);
+        parseValue(entry);
+        target.children.push(entry);
+        skipWhitespace();
+        if (content[i] === ',') i++;
+        else if (content[i] !== ']') throw new SyntaxError(`Expected , or ] on line ${line}`);
+        skipWhitespace();
+      }
+      i++;
+    } else if (ch === '"') {
+      target.value = string();
+    } else {
+      const token = /^[^\s,\]}]+/.exec(content.slice(i, i + 64));
+      if (!token) throw new SyntaxError(`Unexpected end of input on line ${line}`);
+      target.value = token[0];
+      i += token[0].length;
+    }
+    target.endLine = line;
+  };
+
+  const root = node(null, 1);
+  parseValue(root);
+  return root;
+}
+
+export default OpenAPIPlugin;
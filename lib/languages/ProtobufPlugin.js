@@ -0,0 +1,98 @@
+/**
+ * ProtobufPlugin - Protocol Buffers schema extraction
+ * v3.4.0 - API schema extraction
+ *
+ * Messages, enums and services become symbols, with rpc methods as members
+ * of their service, so schemas rank and pack like code. Everything a .proto
+ * file declares is public; leading `//` comments become docs.
+ */
+
+import path from 'path';
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { extractWithRules } from '../symbols/HeuristicExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { lineNumberAt } from '../symbols/SourceScanner.js';
+
+const IMPORT_PATTERN = /^[ \t]*import\s+(?:(?:public|weak)\s+)?["']([^"']+)["']\s*;/gm;
+
+const TYPE_RULES = [
+  {
+    pattern: /^package\s+([\w.]+)\s*;/,
+    build: m => ({ name: m[1], kind: SymbolKind.MODULE, exported: true, singleLine: true })
+  },
+  {
+    pattern: /^message\s+(\w+)/,
+    build: m => ({ name: m[1], kind: SymbolKind.STRUCT, exported: true, container: true })
+  },
+  {
+    pattern: /^enum\s+(\w+)/,
+    build: m => ({ name: m[1], kind: SymbolKind.ENUM, exported: true })
+  },
+  {
+    pattern: /^service\s+(\w+)/,
+    build: m => ({ name: m[1], kind: SymbolKind.INTERFACE, exported: true, container: true })
+  }
+];
+
+const MEMBER_RULES = [
+  {
+    pattern: /^rpc\s+(\w+)\s*\(/,
+    build: (m, ctx) => ctx.container.kind === SymbolKind.INTERFACE
+      ? { name: m[1], kind: SymbolKind.METHOD, exported: true }
+      : null
+  }
+];
+
+export class ProtobufPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'protobuf';
+    this.extensions = ['.proto'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  extractSymbols(content, filePath) {
+    return extractWithRules(content, filePath, {
+      language: 'protobuf',
+      scanOptions: { quotes: ['"', "'"] },
+      topLevel: TYPE_RULES,
+      members: MEMBER_RULES,
+      nestedTypes: true,
+      attribute: null,
+      doc: { linePrefixes: ['//'], blockDocs: true }
+    });
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  extractImports(content) {
+    return [...content.matchAll(IMPORT_PATTERN)].map(match => ({
+      source: match[1],
+      names: null,
+      line: lineNumberAt(content, match.index)
+    }));
+  }
+
+  /**
+   * Import paths are relative to an include root (protoc -I), which is
+   * seldom the project root: try the path as given, next to the importing
+   * file, then as a path suffix anywhere in the project.
+   */
+  resolveImport(imported, fromFile, project) {
+    const source = path.posix.normalize(imported.source);
+    if (project.hasFile(source)) return source;
+
+    const sibling = path.posix.join(path.posix.dirname(fromFile), source);
+    if (project.hasFile(sibling)) return sibling;
+
+    return project.findBySuffix(source);
+  }
+}
+
+export default ProtobufPlugin;
@@ -0,0 +1,343 @@
+/**
+ * PythonPlugin - Python symbol extraction
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Uses tree-sitter-python when installed, otherwise an indentation-aware
+ * heuristic parser. Docstrings become symbol docs; `__all__` (when present)
+ * decides what is exported.
+ */
+
+import path from 'path';
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { createSymbol, SymbolKind } from '../symbols/SymbolModel.js';
+import {
+  findIndentBlockEnd,
+  findHeaderEnd,
+  tripleQuotedLines,
+  extractDocstring,
+  indentOf,
+  collapseWhitespace,
+  lineNumberAt
+} from '../symbols/SourceScanner.js';
+
+const DEF_PATTERN = /^(\s*)(async\s+)?def\s+(\w+)\s*[\[(]/;
+const CLASS_PATTERN = /^(\s*)class\s+(\w+)/;
+const CONSTANT_PATTERN = /^([A-Z][A-Z0-9_]*)\s*(?::[^=]+)?=(?!=)/;
+const IMPORT_PATTERN = /^[ \t]*import[ \t]+([\w.]+(?:[ \t]+as[ \t]+\w+)?(?:[ \t]*,[ \t]*[\w.]+(?:[ \t]+as[ \t]+\w+)?)*)/gm;
+const FROM_IMPORT_PATTERN = /^[ \t]*from[ \t]+(\.*[\w.]*)[ \t]+import[ \t]+(\([^)]*\)|[^\n#]+)/gm;
+const IMPORT_STATEMENT_PATTERN = /^([ \t]*)import[ \t]+([\w. \t,]+?)[ \t]*(?:#.*)?$/;
+const FROM_STATEMENT_PATTERN = /^([ \t]*)from[ \t]+(\.*[\w.]*)[ \t]+import[ \t]+(?:\(([^)]*)\)|([^\n#()]+?))[ \t]*(?:#.*)?$/;
+
+export class PythonPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'python';
+    this.extensions = ['.py', '.pyi'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  getTreeSitterGrammar() {
+    return { package: 'tree-sitter-python' };
+  }
+
+  extractSymbols(content, filePath) {
+    const lines = content.split('\n');
+    const inString = tripleQuotedLines(lines);
+    const publicNames = parseDunderAll(content);
+    const symbols = [];
+    const scopes = [];
+
+    const docLine = firstCodeLine(lines);
+    const moduleDoc = extractDocstring(lines, docLine);
+    if (moduleDoc) {
+      symbols.push(createSymbol({
+        name: path.basename(filePath || 'module', path.extname(filePath || '')),
+        kind: SymbolKind.MODULE,
+        language: 'python',
+        file: filePath,
+        startLine: docLine + 1,
+        endLine: docLine + 1,
+        doc: moduleDoc,
+        exported: true
+      }));
+    }
+
+    for (let i = 0; i < lines.length; i++) {
+      if (inString[i]) continue;
+      const line = lines[i];
+
+      while (scopes.length > 0 && scopes[scopes.length - 1].endLine < i) {
+        scopes.pop();
+      }
+      const scope = scopes[scopes.length - 1] || null;
+
+      const defMatch = line.match(DEF_PATTERN);
+      const classMatch = defMatch ? null : line.match(CLASS_PATTERN);
+
+      if (!defMatch && !classMatch) {
+        const constant = !scope && line.match(CONSTANT_PATTERN);
+        if (constant) {
+          symbols.push(createSymbol({
+            name: constant[1],
+            kind: SymbolKind.CONSTANT,
+            language: 'python',
+            file: filePath,
+            startLine: i + 1,
+            endLine: i + 1,
+            signature: collapseWhitespace(line),
+            exported: isPublic(constant[1], publicNames)
+          }));
+        }
+        continue;
+      }
+
+      // Functions nested inside functions are implementation details
+      if (scope && scope.kind !== SymbolKind.CLASS) continue;
+
+      const name = defMatch ? defMatch[3] : classMatch[2];
+      const kind = classMatch ? SymbolKind.CLASS : (scope ? SymbolKind.METHOD : SymbolKind.FUNCTION);
+      const endLine = findIndentBlockEnd(lines, i);
+      const headerEnd = findHeaderEnd(lines, i);
+
+      let startLine = i;
+      while (startLine > 0 && lines[startLine - 1].trim().startsWith('@')) {
+        startLine--;
+      }
+
+      const parent = scope ? scope.name : null;
+      const exported = scope
+        ? scope.exported && (isDunder(name) || !name.startsWith('_'))
+        : isPublic(name, publicNames);
+
+      symbols.push(createSymbol({
+        name,
+        kind,
+        language: 'python',
+        file: filePath,
+        startLine: startLine + 1,
+        endLine: endLine + 1,
+        signature: pythonSignature(lines.slice(i, headerEnd + 1).join(' ')),
+        doc: headerEnd < endLine ? extractDocstring(lines, headerEnd + 1) : null,
+        parent,
+        exported
+      }));
+
+      scopes.push({
+        name: parent ? `${parent}.${name}` : name,
+        kind,
+        indent: indentOf(line),
+        endLine,
+        exported
+      });
+    }
+
+    return symbols;
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  extractImports(content) {
+    const imports = [];
+
+    for (const match of content.matchAll(IMPORT_PATTERN)) {
+      const line = lineNumberAt(content, match.index);
+      for (const spec of match[1].split(',')) {
+        imports.push({ source: spec.trim().split(/\s+as\s+/)[0], names: null, line });
+      }
+    }
+
+    for (const match of content.matchAll(FROM_IMPORT_PATTERN)) {
+      const line = lineNumberAt(content, match.index);
+      const { names, aliases } = parseImportedNames(match[2]);
+
+      if (/^\.+$/.test(match[1])) {
+        // `from . import a, b` imports sibling modules
+        for (const name of names) {
+          imports.push({ source: match[1] + name, names: null, line });
+        }
+      } else {
+        imports.push({ source: match[1], names: names.includes('*') ? null : names, aliases, line });
+      }
+    }
+
+    return imports.sort((a, b) => a.line - b.line);
+  }
+
+  /**
+   * `import a, b as c` and `from x import a, b as c` keep the names used;
+   * star imports stay as they are
+   */
+  pruneImport(statement, isUsed) {
+    const from = FROM_STATEMENT_PATTERN.exec(statement);
+    const plain = !from && IMPORT_STATEMENT_PATTERN.exec(statement);
+    if (!from && !plain) return undefined;
+
+    const indent = (from || plain)[1];
+    const prefix = from ? `from ${from[2]} import ` : 'import ';
+    const list = from ? from[3] ?? from[4] : plain[2];
+    const specs = list.replace(/#.*$/gm, '').split(',').map(spec => spec.trim()).filter(Boolean);
+    if (specs.includes('*')) return undefined;
+
+    // `import a.b` binds a, `x as y` binds y
+    const bound = spec => (/\s+as\s+(\w+)$/.exec(spec) || [null, spec.split('.')[0]])[1];
+    const kept = specs.filter(spec => isUsed(bound(spec)));
+    if (kept.length === specs.length) return statement;
+    if (kept.length === 0) return null;
+    return `${indent}${prefix}${kept.join(', ')}`;
+  }
+
+  /**
+   * Relative imports resolve from the importing package; absolute ones from
+   * the project root or any ancestor directory (src/ layouts, monorepos).
+   */
+  resolveImport(imported, fromFile, project) {
+    const level = imported.source.match(/^\.*/)[0].length;
+    const modulePath = imported.source.slice(level).split('.').filter(Boolean).join('/');
+
+    let bases;
+    if (level > 0) {
+      let dir = path.posix.dirname(fromFile);
+      for (let i = 1; i < level; i++) {
+        dir = path.posix.dirname(dir);
+      }
+      bases = [dir];
+    } else {
+      bases = ['.'];
+      for (let dir = path.posix.dirname(fromFile); dir !== '.'; dir = path.posix.dirname(dir)) {
+        bases.push(dir);
+      }
+    }
+
+    for (const base of bases) {
+      const target = path.posix.join(base, modulePath);
+      const candidates = modulePath
+        ? [`${target}.py`, `${target}.pyi`, `${target}/__init__.py`]
+        : [`${target}/__init__.py`];
+      const found = candidates.find(candidate => project.hasFile(candidate));
+      if (found) return found;
+    }
+
+    return null;
+  }
+
+  describeTreeSitterNode(node, scope) {
+    const anchor = node.parent && node.parent.type === 'decorated_definition' ? node.parent : node;
+    const name = node.childForFieldName('name')?.text;
+
+    switch (node.type) {
+      case 'decorated_definition':
+        return null;
+      case 'class_definition':
+        return {
+          name,
+          kind: SymbolKind.CLASS,
+          exported: scope.parent ? scope.exported && !name.startsWith('_') : !name.startsWith('_'),
+          container: true,
+          anchor,
+          doc: treeSitterDocstring(node)
+        };
+      case 'function_definition':
+        return {
+          name,
+          kind: scope.parentKind === SymbolKind.CLASS ? SymbolKind.METHOD : SymbolKind.FUNCTION,
+          exported: scope.parent
+            ? scope.exported && (isDunder(name) || !name.startsWith('_'))
+            : !name.startsWith('_'),
+          anchor,
+          doc: treeSitterDocstring(node)
+        };
+      default:
+        return { symbol: false };
+    }
+  }
+}
+
+/**
+ * Docstring of a tree-sitter function/class node
+ * @param {object} node
+ * @returns {string|null}
+ */
+function treeSitterDocstring(node) {
+  const first = node.childForFieldName('body')?.namedChildren[0];
+  if (!first || first.type !== 'expression_statement') return null;
+
+  const string = first.namedChildren[0];
+  if (!string || string.type !== 'string') return null;
+
+  return extractDocstring(string.text.split('\n'), 0);
+}
+
+/**
+ * Names listed in a module-level __all__, or null if absent
+ * @param {string} content
+ * @returns {Set<string>|null}
+ */
+function parseDunderAll(content) {
+  const match = content.match(/^__all__\s*(?::[^=]+)?=\s*[\[(]([\s\S]*?)[\])]/m);
+  if (!match) return null;
+
+  const names = [...match[1].matchAll(/['"]([\w.]+)['"]/g)].map(m => m[1]);
+  return new Set(names);
+}
+
+/**
+ * Names of a `from x import ...` clause
+ * @param {string} clause - `a, b as c` or `(a,\n b)`
+ * @returns {{names: Array<string>, aliases: object}}
+ */
+function parseImportedNames(clause) {
+  const names = [];
+  const aliases = {};
+
+  for (const spec of clause.replace(/[()\\]/g, ' ').replace(/#.*$/gm, '').split(',')) {
+    const match = spec.trim().match(/^(\w+|\*)(?:\s+as\s+(\w+))?$/);
+    if (!match) continue;
+    names.push(match[1]);
+    if (match[2] && match[2] !== match[1]) {
+      aliases[match[2]] = match[1];
+    }
+  }
+
+  return { names, aliases };
+}
+
+/**
+ * Header text without the trailing ':' (and any inline body)
+ * @param {string} header
+ * @returns {string}
+ */
+function pythonSignature(header) {
+  const text = collapseWhitespace(header.replace(/#.*$/, ''));
+  let depth = 0;
+  for (let i = 0; i < text.length; i++) {
+    const ch = text[i];
+    if ('([{'.includes(ch)) depth++;
+    else if (')]}'.includes(ch)) depth--;
+    else if (ch === ':' && depth === 0) return text.slice(0, i).trim();
+  }
+  return text;
+}
+
+function isPublic(name, publicNames) {
+  return publicNames ? publicNames.has(name) : !name.startsWith('_');
+}
+
+function isDunder(name) {
+  return name.startsWith('__') && name.endsWith('__');
+}
+
+function firstCodeLine(lines) {
+  let i = 0;
+  while (i < lines.length && (lines[i].trim() === '' || lines[i].trim().startsWith('#'))) {
+    i++;
+  }
+  return i;
+}
+
+export default PythonPlugin;
@@ -0,0 +1,317 @@
+/**
+ * RustPlugin - Rust symbol extraction
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Uses tree-sitter-rust when installed, otherwise a brace-aware heuristic
+ * parser. Methods inside `impl` blocks are attached to the implementing
+ * type; trait impls record the trait in `implements`.
+ */
+
+import path from 'path';
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { extractWithRules } from '../symbols/HeuristicExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { lineNumberAt } from '../symbols/SourceScanner.js';
+
+const VIS = '(pub(?:\\s*\\([^)]*\\))?\\s+)?';
+const IMPL_KINDS = new Set([SymbolKind.TRAIT, 'impl']);
+const USE_PATTERN = /^[ \t]*(?:pub(?:\s*\([^)]*\))?\s+)?use\s+([^;]+);/gm;
+const MOD_PATTERN = /^[ \t]*(?:pub(?:\s*\([^)]*\))?\s+)?mod\s+(\w+)\s*;/gm;
+const USE_STATEMENT_PATTERN = /^([ \t]*)((?:pub(?:\s*\([^)]*\))?\s+)?)use\s+([^;]+);[ \t]*$/;
+const MODULE_FILES = new Set(['mod.rs', 'lib.rs', 'main.rs']);
+const EXTERNAL_CRATES = new Set(['std', 'core', 'alloc']);
+
+const RULES = [
+  {
+    pattern: new RegExp(`^${VIS}(?:default\\s+)?(?:const\\s+)?(?:async\\s+)?(?:unsafe\\s+)?(?:extern\\s+"[^"]*"\\s+)?fn\\s+(\\w+)`),
+    build: (m, ctx) => {
+      const inImpl = ctx.container && IMPL_KINDS.has(ctx.container.kind);
+      return {
+        name: m[2],
+        kind: inImpl ? SymbolKind.METHOD : SymbolKind.FUNCTION,
+        exported: inImpl && (ctx.container.kind === SymbolKind.TRAIT || ctx.container.implements)
+          ? ctx.container.exported
+          : Boolean(m[1])
+      };
+    }
+  },
+  {
+    pattern: new RegExp(`^${VIS}(struct|union)\\s+(\\w+)`),
+    build: m => ({ name: m[3], kind: SymbolKind.STRUCT, exported: Boolean(m[1]) })
+  },
+  {
+    pattern: new RegExp(`^${VIS}enum\\s+(\\w+)`),
+    build: m => ({ name: m[2], kind: SymbolKind.ENUM, exported: Boolean(m[1]) })
+  },
+  {
+    pattern: new RegExp(`^${VIS}(?:unsafe\\s+)?trait\\s+(\\w+)`),
+    build: m => ({ name: m[2], kind: SymbolKind.TRAIT, exported: Boolean(m[1]), container: true })
+  },
+  {
+    pattern: new RegExp(`^${VIS}type\\s+(\\w+)`),
+    build: m => ({ name: m[2], kind: SymbolKind.TYPE, exported: Boolean(m[1]) })
+  },
+  {
+    pattern: new RegExp(`^${VIS}(?:const|static)\\s+(?:mut\\s+)?([A-Z_][A-Z0-9_]*)\\s*:`),
+    build: m => ({ name: m[2], kind: SymbolKind.CONSTANT, exported: Boolean(m[1]) })
+  },
+  {
+    pattern: new RegExp(`^${VIS}mod\\s+(\\w+)\\s*\\{`),
+    build: m => ({ name: m[2], kind: SymbolKind.MODULE, exported: Boolean(m[1]), container: true })
+  },
+  {
+    pattern: /^(?:unsafe\s+)?impl\s*(<(?:[^<>]|<[^<>]*>)*>)?\s*(?:(!?[\w:]+(?:<.*?>)?)\s+for\s+)?([\w:]+)/,
+    build: m => ({
+      name: lastSegment(m[3]),
+      kind: 'impl',
+      symbol: false,
+      container: true,
+      containerName: lastSegment(m[3]),
+      implements: m[2] ? [lastSegment(m[2].replace(/<.*$/, '').replace(/^!/, ''))] : null,
+      exported: true
+    })
+  }
+];
+
+function lastSegment(name) {
+  const parts = name.split('::');
+  return parts[parts.length - 1];
+}
+
+/**
+ * Flatten a use tree: `a::{b, c::{d as e}}` → ['a::b', 'a::c::d as e']
+ * @param {string} tree
+ * @returns {Array<string>}
+ */
+function flattenUseTree(tree) {
+  const text = tree.replace(/\s*(::|\{|\}|,)\s*/g, '$1').trim();
+  const open = text.indexOf('{');
+  if (open === -1) return [text];
+
+  const prefix = text.slice(0, open);
+  const inner = text.slice(open + 1, text.lastIndexOf('}'));
+  const parts = [];
+  let depth = 0;
+  let start = 0;
+
+  for (let i = 0; i < inner.length; i++) {
+    if (inner[i] === '{') depth++;
+    else if (inner[i] === '}') depth--;
+    else if (inner[i] === ',' && depth === 0) {
+      parts.push(inner.slice(start, i));
+      start = i + 1;
+    }
+  }
+  parts.push(inner.slice(start));
+
+  return parts.filter(Boolean).flatMap(part => flattenUseTree(prefix + part));
+}
+
+/**
+ * Directory holding a module file's child modules
+ * (`src/a.rs` → `src/a`, `src/a/mod.rs` → `src/a`)
+ */
+function moduleDir(filePath) {
+  const base = path.posix.basename(filePath);
+  return MODULE_FILES.has(base)
+    ? path.posix.dirname(filePath)
+    : path.posix.join(path.posix.dirname(filePath), path.posix.basename(base, '.rs'));
+}
+
+/**
+ * Nearest ancestor directory with lib.rs or main.rs
+ */
+function crateRoot(fromFile, project) {
+  for (let dir = path.posix.dirname(fromFile); ; dir = path.posix.dirname(dir)) {
+    if (project.hasFile(path.posix.join(dir, 'lib.rs')) || project.hasFile(path.posix.join(dir, 'main.rs'))) {
+      return dir;
+    }
+    if (dir === '.') return project.hasDir('src') ? 'src' : '.';
+  }
+}
+
+/**
+ * File defining the module whose children live in `dir`
+ */
+function moduleFile(dir, project) {
+  return [`${dir}.rs`, `${dir}/mod.rs`, `${dir}/lib.rs`, `${dir}/main.rs`]
+    .map(candidate => path.posix.normalize(candidate))
+    .find(candidate => project.hasFile(candidate)) || null;
+}
+
+export class RustPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'rust';
+    this.extensions = ['.rs'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  getTreeSitterGrammar() {
+    return { package: 'tree-sitter-rust' };
+  }
+
+  extractSymbols(content, filePath) {
+    return extractWithRules(content, filePath, {
+      language: 'rust',
+      scanOptions: { quotes: ['"', "'"], rustLifetimes: true },
+      topLevel: RULES,
+      members: [],
+      nestedTypes: true,
+      attribute: /^#!?\[/,
+      doc: { linePrefixes: ['///'], blockDocs: true }
+    });
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  extractImports(content) {
+    const imports = [];
+
+    for (const match of content.matchAll(USE_PATTERN)) {
+      const line = lineNumberAt(content, match.index);
+
+      for (const item of flattenUseTree(match[1])) {
+        const [usePath, alias] = item.split(/\s+as\s+/);
+        const segments = usePath.split('::').filter(Boolean);
+        const last = segments[segments.length - 1];
+
+        if (last === 'self' || last === '*') {
+          imports.push({ source: segments.slice(0, -1).join('::'), names: null, line });
+        } else {
+          const aliases = alias && alias !== '_' && alias !== last ? { [alias]: last } : {};
+          imports.push({ source: segments.join('::'), names: [last], aliases, line });
+        }
+      }
+    }
+
+    for (const match of content.matchAll(MOD_PATTERN)) {
+      imports.push({ source: `self::${match[1]}`, names: null, line: lineNumberAt(content, match.index) });
+    }
+
+    return imports.sort((a, b) => a.line - b.line);
+  }
+
+  /**
+   * `use a::{b, c::{d, e}}` keeps the items used, one `use` per parent path;
+   * globs and `as _` trait imports are always kept
+   */
+  pruneImport(statement, isUsed) {
+    const match = USE_STATEMENT_PATTERN.exec(statement);
+    if (!match) return undefined;
+    const [, indent, visibility, tree] = match;
+
+    const items = flattenUseTree(tree);
+    const bound = item => {
+      const [usePath, alias] = item.split(/\s+as\s+/);
+      const segments = usePath.split('::').filter(Boolean);
+      const last = segments[segments.length - 1];
+      return alias || (last === 'self' ? segments[segments.length - 2] : last);
+    };
+    const kept = items.filter(item => item.endsWith('*') || bound(item) === '_' || isUsed(bound(item)));
+    if (kept.length === items.length) return statement;
+    if (kept.length === 0) return null;
+
+    const groups = new Map();
+    for (const item of kept) {
+      const split = item.split(/\s+as\s+/)[0].lastIndexOf('::');
+      const parent = split === -1 ? '' : item.slice(0, split);
+      const leaf = split === -1 ? item : item.slice(split + 2);
+      if (!groups.has(parent)) groups.set(parent, []);
+      groups.get(parent).push(leaf);
+    }
+    return [...groups].map(([parent, leaves]) => {
+      const list = leaves.length === 1 ? leaves[0] : `{${leaves.join(', ')}}`;
+      return `${indent}${visibility || ''}use ${parent ? `${parent}::` : ''}${list};`;
+    }).join('\n');
+  }
+
+  /**
+   * `crate::`, `self::` and `super::` paths resolve to the longest module
+   * file that exists; the remaining segments are items inside it. Bare
+   * paths are tried against the crate root (2018-edition local modules).
+   */
+  resolveImport(imported, fromFile, project) {
+    const segments = imported.source.split('::').filter(Boolean);
+    if (segments.length === 0 || EXTERNAL_CRATES.has(segments[0])) return null;
+
+    let dir;
+    const relative = ['crate', 'self', 'super'].includes(segments[0]);
+    if (segments[0] === 'crate') {
+      dir = crateRoot(fromFile, project);
+      segments.shift();
+    } else if (relative) {
+      dir = moduleDir(fromFile);
+      if (segments[0] === 'self') segments.shift();
+      while (segments[0] === 'super') {
+        dir = path.posix.dirname(dir);
+        segments.shift();
+      }
+    } else {
+      dir = crateRoot(fromFile, project);
+    }
+
+    for (let i = segments.length; i > 0; i--) {
+      const target = path.posix.join(dir, ...segments.slice(0, i));
+      const file = [`${target}.rs`, `${target}/mod.rs`].find(candidate => project.hasFile(candidate));
+      if (file) return file;
+    }
+
+    // Items defined directly in the base module
+    return relative ? moduleFile(dir, project) : null;
+  }
+
+  describeTreeSitterNode(node, scope) {
+    const name = node.childForFieldName('name')?.text;
+    const exported = node.namedChildren.some(c => c.type === 'visibility_modifier');
+
+    switch (node.type) {
+      case 'function_item':
+      case 'function_signature_item': {
+        const inImpl = scope.parentKind === 'impl' || scope.parentKind === SymbolKind.TRAIT;
+        return {
+          name,
+          kind: inImpl ? SymbolKind.METHOD : SymbolKind.FUNCTION,
+          exported: inImpl && (scope.parentKind === SymbolKind.TRAIT || scope.implements) ? scope.exported : exported
+        };
+      }
+      case 'struct_item':
+      case 'union_item':
+        return { name, kind: SymbolKind.STRUCT, exported };
+      case 'enum_item':
+        return { name, kind: SymbolKind.ENUM, exported };
+      case 'trait_item':
+        return { name, kind: SymbolKind.TRAIT, exported, container: true };
+      case 'type_item':
+        return { name, kind: SymbolKind.TYPE, exported };
+      case 'const_item':
+      case 'static_item':
+        return { name, kind: SymbolKind.CONSTANT, exported };
+      case 'mod_item':
+        return node.childForFieldName('body') ? { name, kind: SymbolKind.MODULE, exported, container: true } : { symbol: false };
+      case 'impl_item': {
+        const typeName = lastSegment(node.childForFieldName('type')?.text.replace(/<.*$/, '') || '');
+        const trait = node.childForFieldName('trait')?.text.replace(/<.*$/, '');
+        return {
+          name: typeName,
+          kind: 'impl',
+          symbol: false,
+          container: true,
+          containerName: typeName,
+          implements: trait ? [lastSegment(trait)] : null,
+          exported: true
+        };
+      }
+      default:
+        return { symbol: false };
+    }
+  }
+}
+
+export default RustPlugin;
@@ -0,0 +1,295 @@
+/**
+ * TypeScriptPlugin - TypeScript/JavaScript symbol extraction
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Uses tree-sitter-typescript / tree-sitter-javascript when installed,
+ * otherwise a brace-aware heuristic parser.
+ */
+
+import path from 'path';
+import { LanguagePlugin } from '../plugins/LanguagePlugin.js';
+import { extractWithRules } from '../symbols/HeuristicExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { lineNumberAt, declarationHeader, supertypeNames } from '../symbols/SourceScanner.js';
+
+const IDENT = '[A-Za-z_$][\\w$]*';
+const NOT_METHODS = new Set([
+  'if', 'for', 'while', 'switch', 'catch', 'return', 'function', 'super',
+  'new', 'await', 'typeof', 'delete', 'throw', 'with', 'do', 'else'
+]);
+const TYPESCRIPT_EXTENSIONS = new Set(['.ts', '.tsx', '.mts', '.cts']);
+const RESOLVE_EXTENSIONS = ['.ts', '.tsx', '.d.ts', '.mts', '.cts', '.js', '.jsx', '.mjs', '.cjs'];
+
+const IMPORT_FROM_PATTERN = /^[ \t]*(?:import|export)[ \t]+(?:type[ \t]+)?((?:[\w$*, \t]|\{[^}]*\})*?)[ \t]*from[ \t]*['"]([^'"]+)['"]/gm;
+const SIDE_EFFECT_IMPORT_PATTERN = /^[ \t]*import\s*['"]([^'"]+)['"]/gm;
+const REQUIRE_PATTERN = /\b(?:require|import)\s*\(\s*['"]([^'"]+)['"]\s*\)/g;
+const IMPORT_STATEMENT_PATTERN = /^([ \t]*)import[ \t]+(type[ \t]+)?([\w$*,\s]*(?:\{[^}]*\})?[\w$*,\s]*?)\s*from\s*(['"][^'"]+['"])([ \t]*;?)[ \t]*$/;
+const DEFAULT_BINDING_PATTERN = /^(?:\*\s+as\s+)?([\w$]+)$/;
+
+const TOP_LEVEL_RULES = [
+  {
+    pattern: new RegExp(`^(export\\s+)?(default\\s+)?(declare\\s+)?(abstract\\s+)?class\\s+(${IDENT})`),
+    build: (m, ctx) => ({
+      name: m[5],
+      kind: SymbolKind.CLASS,
+      exported: Boolean(m[1]),
+      container: true,
+      implements: supertypeNames(declarationHeader(ctx.lines, ctx.lineIndex), 'implements')
+    })
+  },
+  {
+    pattern: new RegExp(`^(export\\s+)?(declare\\s+)?interface\\s+(${IDENT})`),
+    build: (m, ctx) => ({
+      name: m[3],
+      kind: SymbolKind.INTERFACE,
+      exported: Boolean(m[1]),
+      container: true,
+      implements: supertypeNames(declarationHeader(ctx.lines, ctx.lineIndex), 'extends')
+    })
+  },
+  {
+    pattern: new RegExp(`^(export\\s+)?(declare\\s+)?(const\\s+)?enum\\s+(${IDENT})`),
+    build: m => ({ name: m[4], kind: SymbolKind.ENUM, exported: Boolean(m[1]) })
+  },
+  {
+    pattern: new RegExp(`^(export\\s+)?(declare\\s+)?type\\s+(${IDENT})\\s*(<.*>)?\\s*=`),
+    build: m => ({ name: m[3], kind: SymbolKind.TYPE, exported: Boolean(m[1]), newlineTerminated: true })
+  },
+  {
+    pattern: new RegExp(`^(export\\s+)?(default\\s+)?(declare\\s+)?(async\\s+)?function\\s*\\*?\\s*(${IDENT})`),
+    build: m => ({ name: m[5], kind: SymbolKind.FUNCTION, exported: Boolean(m[1]) })
+  },
+  {
+    pattern: new RegExp(`^(export\\s+)?(?:const|let|var)\\s+(${IDENT})\\s*(?::[^=]+)?=\\s*(?:async\\s+)?(?:function\\b|(?:\\([^)]*\\)|${IDENT})\\s*(?::\\s*[^=]+)?=>|\\($)`),
+    build: m => ({ name: m[2], kind: SymbolKind.FUNCTION, exported: Boolean(m[1]), newlineTerminated: true })
+  },
+  {
+    pattern: /^(export\s+)?const\s+([A-Z][A-Z0-9_]*)\s*(?::[^=]+)?=/,
+    build: m => ({ name: m[2], kind: SymbolKind.CONSTANT, exported: Boolean(m[1]), newlineTerminated: true })
+  }
+];
+
+const MEMBER_RULES = [
+  {
+    pattern: /^((?:public|private|protected)\s+)?constructor\s*\(/,
+    build: (m, ctx) => ({
+      name: 'constructor',
+      kind: SymbolKind.METHOD,
+      exported: ctx.container.exported && !/private/.test(m[1] || '')
+    })
+  },
+  {
+    pattern: new RegExp(`^((?:public|private|protected|static|readonly|async|abstract|override|declare|get|set)\\s+)*(\\*\\s*)?(#?${IDENT})\\s*\\??\\s*(<[^>]*>)?\\s*\\(`),
+    build: (m, ctx) => {
+      if (NOT_METHODS.has(m[3])) return null;
+      return {
+        name: m[3],
+        kind: SymbolKind.METHOD,
+        exported: isPublicMember(m[0], m[3], ctx),
+        newlineTerminated: ctx.container.kind === SymbolKind.INTERFACE
+      };
+    }
+  },
+  {
+    pattern: new RegExp(`^((?:public|private|protected|static|readonly)\\s+)*(#?${IDENT})\\s*(?::[^=]+)?=\\s*(?:async\\s+)?(?:\\([^)]*\\)|${IDENT})\\s*(?::\\s*[^=]+)?=>`),
+    build: (m, ctx) => ({
+      name: m[2],
+      kind: SymbolKind.METHOD,
+      exported: isPublicMember(m[0], m[2], ctx),
+      newlineTerminated: true
+    })
+  }
+];
+
+/**
+ * Supertypes named in a tree-sitter class or interface header
+ */
+function heritage(node, keyword) {
+  const body = node.childForFieldName('body');
+  const header = body ? node.text.slice(0, body.startIndex - node.startIndex) : node.text;
+  return supertypeNames(header.replace(/\s+/g, ' '), keyword);
+}
+
+function isPublicMember(text, name, ctx) {
+  return ctx.container.exported && !/\b(private|protected)\b/.test(text) && !name.startsWith('#');
+}
+
+/**
+ * Parse `Default, { a, b as c }` / `* as ns` import clauses
+ * Default and namespace imports expose the whole module (names: null).
+ * @param {string} clause
+ * @returns {{names: Array<string>|null, aliases: object}}
+ */
+function parseImportClause(clause) {
+  const braces = clause.match(/\{([^}]*)\}/);
+  const rest = clause.replace(/\{[^}]*\}/, '').split(',').map(part => part.trim()).filter(Boolean);
+  const names = [];
+  const aliases = {};
+
+  for (const spec of braces ? braces[1].split(',') : []) {
+    const match = spec.trim().match(/^(?:type\s+)?([\w$]+)(?:\s+as\s+([\w$]+))?$/);
+    if (!match) continue;
+    names.push(match[1]);
+    if (match[2] && match[2] !== match[1]) {
+      aliases[match[2]] = match[1];
+    }
+  }
+
+  return { names: braces && rest.length === 0 ? names : null, aliases };
+}
+
+export class TypeScriptPlugin extends LanguagePlugin {
+  constructor() {
+    super();
+    this.name = 'typescript';
+    this.extensions = ['.ts', '.tsx', '.mts', '.cts', '.js', '.jsx', '.mjs', '.cjs'];
+    this.version = '1.0.0';
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  /**
+   * @param {string} filePath
+   * @returns {string} 'typescript' or 'javascript'
+   */
+  getLanguageId(filePath) {
+    const ext = path.extname(filePath || '').toLowerCase();
+    return TYPESCRIPT_EXTENSIONS.has(ext) ? 'typescript' : 'javascript';
+  }
+
+  getTreeSitterGrammar(filePath) {
+    const ext = path.extname(filePath || '').toLowerCase();
+    if (ext === '.tsx') return { package: 'tree-sitter-typescript', export: 'tsx' };
+    if (TYPESCRIPT_EXTENSIONS.has(ext)) return { package: 'tree-sitter-typescript', export: 'typescript' };
+    return { package: 'tree-sitter-javascript' };
+  }
+
+  extractSymbols(content, filePath) {
+    return extractWithRules(content, filePath, {
+      language: this.getLanguageId(filePath),
+      topLevel: TOP_LEVEL_RULES,
+      members: MEMBER_RULES,
+      attribute: /^@[\w.]+/,
+      doc: { linePrefixes: ['//'], blockDocs: true }
+    });
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  extractImports(content) {
+    const imports = [];
+
+    for (const match of content.matchAll(IMPORT_FROM_PATTERN)) {
+      const { names, aliases } = parseImportClause(match[1]);
+      imports.push({ source: match[2], names, aliases, line: lineNumberAt(content, match.index) });
+    }
+    for (const pattern of [SIDE_EFFECT_IMPORT_PATTERN, REQUIRE_PATTERN]) {
+      for (const match of content.matchAll(pattern)) {
+        imports.push({ source: match[1], names: null, line: lineNumberAt(content, match.index) });
+      }
+    }
+
+    return imports.sort((a, b) => a.line - b.line);
+  }
+
+  /**
+   * `import D, * as ns, { a, b as c } from 'x'` keeps the bindings used;
+   * re-exports, side-effect imports and require() stay as they are
+   */
+  pruneImport(statement, isUsed) {
+    const match = IMPORT_STATEMENT_PATTERN.exec(statement);
+    if (!match) return undefined;
+    const [, indent, typeOnly = '', clause, source, end] = match;
+
+    const braces = clause.match(/\{([^}]*)\}/);
+    const outside = clause.replace(/\{[^}]*\}/, '').split(',').map(part => part.trim()).filter(Boolean);
+    const named = braces ? braces[1].split(',').map(spec => spec.trim()).filter(Boolean) : [];
+    if (!outside.every(part => DEFAULT_BINDING_PATTERN.test(part))) return undefined;
+
+    const bound = part => DEFAULT_BINDING_PATTERN.exec(part)[1];
+    const keptOutside = outside.filter(part => isUsed(bound(part)));
+    const keptNamed = named.filter(spec => isUsed(spec.split(/\s+as\s+/).pop().replace(/^type\s+/, '')));
+    if (keptOutside.length === outside.length && keptNamed.length === named.length) return statement;
+    if (keptOutside.length === 0 && keptNamed.length === 0) return null;
+
+    const parts = [...keptOutside, ...(keptNamed.length > 0 ? [`{ ${keptNamed.join(', ')} }`] : [])];
+    return `${indent}import ${typeOnly}${parts.join(', ')} from ${source}${end}`;
+  }
+
+  /**
+   * Resolve relative specifiers the way bundlers do: exact file, added
+   * extension (`./a.js` may point at `a.ts`), then directory index.
+   * Bare specifiers are packages and stay external.
+   */
+  resolveImport(imported, fromFile, project) {
+    if (!imported.source.startsWith('.')) return null;
+
+    const base = path.posix.normalize(path.posix.join(path.posix.dirname(fromFile), imported.source));
+    const stem = base.replace(/\.[mc]?jsx?$/, '');
+    const candidates = [
+      base,
+      ...RESOLVE_EXTENSIONS.map(ext => stem + ext),
+      ...RESOLVE_EXTENSIONS.map(ext => `${base}/index${ext}`)
+    ];
+
+    return candidates.find(candidate => project.hasFile(candidate)) || null;
+  }
+
+  describeTreeSitterNode(node, scope) {
+    const exportNode = node.parent && node.parent.type === 'export_statement' ? node.parent : null;
+    const exported = Boolean(exportNode);
+    const anchor = exportNode || node;
+    const name = node.childForFieldName('name')?.text;
+
+    switch (node.type) {
+      case 'export_statement':
+        return null;
+      case 'class_declaration':
+      case 'abstract_class_declaration':
+        return { name, kind: SymbolKind.CLASS, exported, container: true, anchor, implements: heritage(node, 'implements') };
+      case 'interface_declaration':
+        return { name, kind: SymbolKind.INTERFACE, exported, container: true, anchor, implements: heritage(node, 'extends') };
+      case 'enum_declaration':
+        return { name, kind: SymbolKind.ENUM, exported, anchor };
+      case 'type_alias_declaration':
+        return { name, kind: SymbolKind.TYPE, exported, anchor };
+      case 'function_declaration':
+      case 'generator_function_declaration':
+      case 'function_signature':
+        return { name, kind: SymbolKind.FUNCTION, exported, anchor };
+      case 'method_definition':
+      case 'method_signature':
+      case 'abstract_method_signature': {
+        const accessibility = node.namedChildren.find(c => c.type === 'accessibility_modifier')?.text;
+        return {
+          name,
+          kind: SymbolKind.METHOD,
+          exported: scope.exported && accessibility !== 'private' && accessibility !== 'protected' &&
+            !(name || '').startsWith('#')
+        };
+      }
+      case 'lexical_declaration':
+      case 'variable_declaration': {
+        const declarator = node.namedChildren.find(c => c.type === 'variable_declarator');
+        const value = declarator?.childForFieldName('value');
+        if (!value || !['arrow_function', 'function', 'function_expression'].includes(value.type)) {
+          return { symbol: false };
+        }
+        return {
+          name: declarator.childForFieldName('name')?.text,
+          kind: SymbolKind.FUNCTION,
+          exported,
+          anchor
+        };
+      }
+      default:
+        return { symbol: false };
+    }
+  }
+}
+
+export default TypeScriptPlugin;
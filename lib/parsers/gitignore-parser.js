@@ -1,24 +1,57 @@
 /**
  * GitIgnore Parser
  * Parses .gitignore, .contextignore, and .contextinclude files
+ *
+ * Patterns follow gitignore semantics: last match wins, `!` re-includes,
+ * a trailing `/` matches directories only, a slash anywhere else anchors the
+ * pattern to the directory of its file, and `**` spans directories. A path
+ * inside an excluded directory cannot be re-included. With a rootDir, nested
+ * .gitignore/.contextignore files and .git/info/exclude are loaded too.
+ * .contextignore rules add to .gitignore; they never re-include what git ignores.
+ * The ignore lists of nested .context.yaml files (options.directoryConfig)
+ * exclude in both modes, like profile excludes.
  */
 
 import fs from 'fs';
+import path from 'path';
+import { decodeText } from '../core/FileSystem.js';
 
 class GitIgnoreParser {
-    constructor(gitignorePath, contextIgnorePath, contextIncludePath) {
+    /**
+     * @param {string} gitignorePath - Root .gitignore
+     * @param {string} contextIgnorePath - .contextignore (EXCLUDE mode)
+     * @param {string} contextIncludePath - .contextinclude (INCLUDE mode, takes priority)
+     * @param {Object} options
+     * @param {string} options.rootDir - Project root; enables nested ignore files
+     * @param {DirectoryConfig} options.directoryConfig - Settings of .context.yaml files
+     */
+    constructor(gitignorePath, contextIgnorePath, contextIncludePath, options = {}) {
         this.patterns = [];
         this.contextPatterns = [];
+        this.profilePatterns = [];
         this.hasIncludeFile = false;
         this._lastIgnoreReason = null;
+        // Rule that decided the last ignored path: { pattern, source, line, path } (v3.4.0, ctxman explain)
+        this._lastIgnoreRule = null;
+        this.rootDir = options.rootDir || null;
+        this.directoryConfig = options.directoryConfig || null;
+        this.loadedDirs = new Set(['']);
 
         this.loadPatterns(gitignorePath, contextIgnorePath, contextIncludePath);
     }
 
     loadPatterns(gitignorePath, contextIgnorePath, contextIncludePath) {
+        // Repository-local excludes rank below .gitignore
+        if (this.rootDir) {
+            const excludePath = path.join(this.rootDir, '.git', 'info', 'exclude');
+            if (fs.existsSync(excludePath)) {
+                this.patterns = this.parsePatternFile(excludePath);
+            }
+        }
+
         // Load .gitignore
-        if (fs.existsSync(gitignorePath)) {
-            this.patterns = this.parsePatternFile(gitignorePath);
+        if (gitignorePath && fs.existsSync(gitignorePath)) {
+            this.patterns.push(...this.parsePatternFile(gitignorePath));
         }
 
         // Load context patterns (include takes priority)
@@ -32,7 +65,47 @@ class GitIgnoreParser {
         }
     }
 
-    parsePatternFile(filePath) {
+    /**
+     * Apply a context profile's globs on top of the ignore files
+     * Include globs replace the context rules (INCLUDE mode); exclude globs
+     * remove matches in either mode.
+     * @param {Array<string>} include
+     * @param {Array<string>} exclude
+     */
+    addProfileRules(include = [], exclude = []) {
+        if (include.length > 0) {
+            this.contextPatterns = include.map(pattern => ({ ...this.convertToRegex(pattern), source: 'profile' }));
+            this.hasIncludeFile = true;
+        }
+        this.profilePatterns.push(...exclude.map(pattern => ({ ...this.convertToRegex(pattern), source: 'profile' })));
+    }
+
+    /**
+     * Load ignore files from the directories above a path (shallowest first,
+     * so deeper files override their parents)
+     */
+    loadNestedPatterns(relativePath) {
+        if (!this.rootDir) return;
+
+        const parts = relativePath.split('/').slice(0, -1);
+        for (let i = 1; i <= parts.length; i++) {
+            const dir = parts.slice(0, i).join('/');
+            if (this.loadedDirs.has(dir)) continue;
+            this.loadedDirs.add(dir);
+
+            const gitignorePath = path.join(this.rootDir, dir, '.gitignore');
+            if (fs.existsSync(gitignorePath)) {
+                this.patterns.push(...this.parsePatternFile(gitignorePath, dir));
+            }
+
+            const contextIgnorePath = path.join(this.rootDir, dir, '.contextignore');
+            if (!this.hasIncludeFile && fs.existsSync(contextIgnorePath)) {
+                this.contextPatterns.push(...this.parsePatternFile(contextIgnorePath, dir));
+            }
+        }
+    }
+
+    parsePatternFile(filePath, base = '') {
         try {
             // Check if path is actually a file (not a directory)
             const stats = fs.statSync(filePath);
@@ -41,47 +114,62 @@ class GitIgnoreParser {
                 return [];
             }
 
-            return fs.readFileSync(filePath, 'utf8')
-                .split('\n')
-                .map(line => line.trim())
-                .filter(line => line && !line.startsWith('#'))
-                .map(pattern => this.convertToRegex(pattern));
+            const source = this.rootDir ? path.relative(this.rootDir, filePath).split(path.sep).join('/') : filePath;
+            return decodeText(fs.readFileSync(filePath))
+                .split(/\r?\n/)
+                // Trailing spaces are dropped unless escaped with a backslash
+                .map((line, index) => ({ pattern: line.replace(/(?<!\\)\s+$/, ''), line: index + 1 }))
+                .filter(({ pattern }) => pattern && !pattern.startsWith('#'))
+                .map(({ pattern, line }) => ({ ...this.convertToRegex(pattern, base), source, line }));
         } catch (error) {
             // File doesn't exist or can't be read
             return [];
         }
     }
 
-    convertToRegex(pattern) {
-        const isDirectory = pattern.endsWith('/');
-        const isNegation = pattern.startsWith('!');
+    /**
+     * @param {string} pattern - One gitignore line
+     * @param {string} base - Directory of the ignore file, relative to the root
+     * @returns {Object} { regex, exact, isNegation, original, isDirectory, base }
+     *   regex matches the path or anything beneath it; exact only the path itself
+     */
+    convertToRegex(pattern, base = '') {
         const original = pattern;
+        const isNegation = pattern.startsWith('!');
+        if (isNegation) pattern = pattern.slice(1);
 
-        pattern = pattern.replace(/^[!/]/, '').replace(/\/$/, '');
-
-        let regexPattern = pattern
-            .replace(/[.+^${}()|[\]\\]/g, '\\$&')
-            .replace(/\*\*/g, '.*')
-            .replace(/\*/g, '[^/]*')
-            .replace(/\?/g, '[^/]');
+        const isDirectory = /[^\\]\/$/.test(pattern) || pattern === '/';
+        pattern = pattern.replace(/\/+$/, '');
 
-        regexPattern = original.startsWith('/')
-            ? `^${regexPattern}`
-            : `(^|/)${regexPattern}`;
+        // A slash at the start or in the middle anchors to the ignore file's directory
+        const anchored = pattern.includes('/');
+        pattern = pattern.replace(/^\//, '');
 
-        regexPattern += isDirectory ? '(/.*)?$' : '$';
+        const prefix = (base ? escapeRegex(base) + '/' : '') + (anchored ? '' : '(?:.*/)?');
+        const body = prefix + globToRegex(pattern);
 
-        return { regex: new RegExp(regexPattern), isNegation, original, isDirectory };
+        return {
+            regex: new RegExp(`^${body}(/.*)?$`),
+            exact: new RegExp(`^${body}$`),
+            isNegation,
+            original,
+            isDirectory,
+            base
+        };
     }
 
     isIgnored(filePath, relativePath) {
-        let ignored = this.testPatterns(this.patterns, relativePath, 'gitignore');
+        relativePath = relativePath.split(path.sep).join('/');
+        const isDirectory = this.isDirectoryPath(filePath);
+        this.loadNestedPatterns(relativePath);
+
+        this._lastIgnoreRule = null;
+        let ignored = this.testPatterns(this.patterns, relativePath, 'gitignore', isDirectory);
 
         if (this.hasIncludeFile) {
             if (ignored) return true; // Keep gitignore exclusions
 
-            const stat = fs.statSync(filePath);
-            if (stat.isDirectory()) {
+            if (isDirectory) {
                 const shouldTraverse = this.contextPatterns.some(p =>
                     p.original.startsWith(relativePath) ||
                     p.original.includes('**') ||
@@ -95,21 +183,67 @@ class GitIgnoreParser {
 
             if (ignored) this._lastIgnoreReason = 'context-include';
         } else if (!ignored) {
-            ignored = this.testPatterns(this.contextPatterns, relativePath, 'context');
+            ignored = this.testPatterns(this.contextPatterns, relativePath, 'context', isDirectory);
+        }
+
+        if (!ignored && this.profilePatterns.length > 0) {
+            ignored = this.testPatterns(this.profilePatterns, relativePath, 'profile', isDirectory);
+        }
+
+        if (!ignored && this.directoryConfig) {
+            const patterns = this.directoryConfig.ignorePatterns(relativePath);
+            if (patterns.length > 0) ignored = this.testPatterns(patterns, relativePath, 'directory-config', isDirectory);
         }
 
         return ignored;
     }
 
-    testPatterns(patterns, relativePath, reason) {
-        let ignored = false;
+    isDirectoryPath(filePath) {
+        try {
+            return Boolean(filePath) && fs.statSync(filePath).isDirectory();
+        } catch (error) {
+            return false;
+        }
+    }
+
+    /**
+     * Whether patterns exclude a path; excluded parent directories win
+     * over any pattern that would re-include the path itself
+     */
+    testPatterns(patterns, relativePath, reason, isDirectory = false) {
+        const parts = relativePath.split('/');
+        for (let i = 1; i <= parts.length; i++) {
+            const candidate = parts.slice(0, i).join('/');
+            const pattern = this.lastMatch(patterns, candidate, i < parts.length || isDirectory);
+            if (pattern && !pattern.isNegation) {
+                this._lastIgnoreReason = reason;
+                this._lastIgnoreRule = { pattern: pattern.original, source: pattern.source || null, line: pattern.line || null, path: candidate };
+                return true;
+            }
+        }
+        return false;
+    }
+
+    /**
+     * Last matching pattern decides
+     */
+    matchLast(patterns, relativePath, isDirectory) {
+        const pattern = this.lastMatch(patterns, relativePath, isDirectory);
+        return Boolean(pattern) && !pattern.isNegation;
+    }
+
+    /**
+     * @returns {Object|null} The last pattern matching a path
+     */
+    lastMatch(patterns, relativePath, isDirectory) {
+        let matched = null;
         for (const pattern of patterns) {
-            if (pattern.regex.test(relativePath)) {
-                ignored = !pattern.isNegation;
-                if (ignored) this._lastIgnoreReason = reason;
+            if (pattern.isDirectory && !isDirectory) continue;
+            if ((pattern.exact || pattern.regex).test(relativePath)) {
+                matched = pattern;
             }
         }
-        return ignored;
+        return matched;
     }
 
     testPatternsWithNegation(patterns, relativePath) {
@@ -124,4 +258,55 @@ class GitIgnoreParser {
     }
 }
 
+function escapeRegex(text) {
+    return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+/**
+ * Translate a gitignore glob (without anchoring slash) to a regex body
+ */
+function globToRegex(glob) {
+    let out = '';
+
+    for (let i = 0; i < glob.length; i++) {
+        const char = glob[i];
+
+        if (char === '\\' && i + 1 < glob.length) {
+            out += escapeRegex(glob[++i]);
+        } else if (char === '*') {
+            let end = i;
+            while (glob[end] === '*') end++;
+            const isDoubleStar = end - i > 1 && (i === 0 || glob[i - 1] === '/') &&
+                (end === glob.length || glob[end] === '/');
+
+            if (isDoubleStar && end === glob.length) {
+                out += '.*'; // Trailing /** matches everything inside
+            } else if (isDoubleStar) {
+                out += '(?:.*/)?'; // **/ matches zero or more directories
+                end++;
+            } else {
+                out += '[^/]*'; // Other runs of * do not cross slashes
+            }
+            i = end - 1;
+        } else if (char === '?') {
+            out += '[^/]';
+        } else if (char === '[') {
+            const negate = glob[i + 1] === '!' || glob[i + 1] === '^';
+            const start = negate ? i + 2 : i + 1;
+            const close = glob.indexOf(']', start + 1); // A leading ] is a member
+            if (close === -1) {
+                out += '\\[';
+                continue;
+            }
+            const members = glob.slice(start, close).replace(/\\/g, '\\\\');
+            out += `(?!/)[${negate ? '^' : ''}${members}]`;
+            i = close;
+        } else {
+            out += escapeRegex(char);
+        }
+    }
+
+    return out;
+}
+
 export default GitIgnoreParser;
@@ -4,6 +4,7 @@
  */
 
 import fs from 'fs';
+import { decodeText } from '../core/FileSystem.js';
 
 class MethodFilterParser {
     constructor(methodIncludePath, methodIgnorePath) {
@@ -24,7 +25,7 @@ class MethodFilterParser {
     }
 
     parseMethodFile(filePath) {
-        return fs.readFileSync(filePath, 'utf8')
+        return decodeText(fs.readFileSync(filePath))
             .split('\n')
             .map(line => line.trim())
             .filter(line => line && !line.startsWith('#'))
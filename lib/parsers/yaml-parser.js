@@ -0,0 +1,234 @@
+/**
+ * YAML Parser
+ * Parses the YAML subset used by config files such as context.yaml
+ *
+ * Supported: block mappings and sequences (including `- key: value` items),
+ * flow sequences (`[a, b]`), empty flow collections, single/double-quoted and
+ * plain scalars, numbers, booleans, null and `#` comments. Anchors, tags,
+ * multi-document streams and block scalars (`|`, `>`) are rejected.
+ */
+
+class YAMLParser {
+    /**
+     * @param {string} text - YAML source
+     * @returns {*} Parsed value (null for an empty document)
+     * @throws {Error} "Line N: ..." on unsupported or malformed input
+     */
+    static parse(text) {
+        const lines = this.tokenize(text);
+        if (lines.length === 0) return null;
+
+        const state = { lines, index: 0 };
+        const value = this.parseBlock(state, lines[0].indent);
+        if (state.index < lines.length) {
+            throw lineError(lines[state.index], 'unexpected indentation');
+        }
+        return value;
+    }
+
+    /**
+     * Significant lines with their indentation, comments removed
+     * @private
+     */
+    static tokenize(text) {
+        const lines = [];
+
+        text.split(/\r?\n/).forEach((raw, i) => {
+            const content = stripComment(raw).trimEnd();
+            if (!content.trim() || content === '---') return;
+
+            const indent = content.length - content.trimStart().length;
+            if (raw.slice(0, indent).includes('\t')) {
+                throw new Error(`Line ${i + 1}: tabs are not allowed for indentation`);
+            }
+            lines.push({ indent, text: content.trim(), line: i + 1 });
+        });
+
+        return lines;
+    }
+
+    /**
+     * @private
+     */
+    static parseBlock(state, indent) {
+        return isSequenceItem(state.lines[state.index].text)
+            ? this.parseSequence(state, indent)
+            : this.parseMapping(state, indent);
+    }
+
+    /**
+     * @private
+     */
+    static parseSequence(state, indent) {
+        const result = [];
+
+        while (state.index < state.lines.length) {
+            const current = state.lines[state.index];
+            if (current.indent !== indent || !isSequenceItem(current.text)) break;
+
+            const rest = current.text.slice(1).trimStart();
+            if (rest === '') {
+                state.index++;
+                result.push(this.parseNested(state, indent));
+            } else if (splitKey(rest)) {
+                // "- key: value" opens a mapping aligned with its first key
+                state.lines[state.index] = { ...current, indent: indent + current.text.length - rest.length, text: rest };
+                result.push(this.parseMapping(state, state.lines[state.index].indent));
+            } else {
+                state.index++;
+                result.push(this.parseScalar(rest, current));
+            }
+        }
+
+        return result;
+    }
+
+    /**
+     * @private
+     */
+    static parseMapping(state, indent) {
+        const result = {};
+
+        while (state.index < state.lines.length) {
+            const current = state.lines[state.index];
+            if (current.indent < indent) break;
+            if (current.indent > indent) throw lineError(current, 'unexpected indentation');
+            if (isSequenceItem(current.text)) break;
+
+            const entry = splitKey(current.text);
+            if (!entry) throw lineError(current, 'expected "key: value"');
+            if (Object.prototype.hasOwnProperty.call(result, entry.key)) {
+                throw lineError(current, `duplicate key "${entry.key}"`);
+            }
+
+            state.index++;
+            if (entry.value !== '') {
+                result[entry.key] = this.parseScalar(entry.value, current);
+                continue;
+            }
+
+            // Sequences may sit at the key's own indentation
+            const next = state.lines[state.index];
+            result[entry.key] = next && next.indent === indent && isSequenceItem(next.text)
+                ? this.parseSequence(state, indent)
+                : this.parseNested(state, indent);
+        }
+
+        return result;
+    }
+
+    /**
+     * Block nested deeper than its parent, or null when there is none
+     * @private
+     */
+    static parseNested(state, indent) {
+        const next = state.lines[state.index];
+        return next && next.indent > indent ? this.parseBlock(state, next.indent) : null;
+    }
+
+    /**
+     * @private
+     */
+    static parseScalar(value, line) {
+        if (value === '[]') return [];
+        if (value === '{}') return {};
+        if (value.startsWith('[')) {
+            if (!value.endsWith(']')) throw lineError(line, 'unterminated flow sequence');
+            return splitFlow(value.slice(1, -1), line).map(item => this.parseScalar(item, line));
+        }
+        if (/^[&*!|>{]/.test(value)) {
+            throw lineError(line, `unsupported YAML syntax "${value}"`);
+        }
+
+        if (value.startsWith('"')) {
+            try {
+                return JSON.parse(value);
+            } catch (error) {
+                throw lineError(line, `invalid double-quoted string ${value}`);
+            }
+        }
+        if (value.startsWith("'")) {
+            if (!/^'(?:[^']|'')*'$/.test(value)) throw lineError(line, `invalid single-quoted string ${value}`);
+            return value.slice(1, -1).replace(/''/g, "'");
+        }
+
+        if (value === 'true') return true;
+        if (value === 'false') return false;
+        if (value === 'null' || value === '~') return null;
+        if (/^-?\d+(\.\d+)?$/.test(value)) return Number(value);
+        return value;
+    }
+}
+
+function isSequenceItem(text) {
+    return text === '-' || text.startsWith('- ');
+}
+
+/**
+ * Split "key: value" (keys may be quoted); null when the line is not a pair
+ */
+function splitKey(text) {
+    const match = text.match(/^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s"'#[\]{},][^:]*?)\s*:(?:\s+(.*)|$)/);
+    if (!match) return null;
+
+    let key = match[1];
+    if (key.startsWith('"')) key = JSON.parse(key);
+    else if (key.startsWith("'")) key = key.slice(1, -1).replace(/''/g, "'");
+
+    return { key, value: (match[2] || '').trim() };
+}
+
+/**
+ * Split flow sequence items at commas outside quotes
+ */
+function splitFlow(text, line) {
+    const items = [];
+    let current = '';
+    let quote = null;
+
+    for (const char of text) {
+        if (quote) {
+            if (char === quote) quote = null;
+        } else if (char === '"' || char === "'") {
+            quote = char;
+        } else if (char === '[' || char === '{') {
+            throw lineError(line, 'nested flow collections are not supported');
+        } else if (char === ',') {
+            items.push(current.trim());
+            current = '';
+            continue;
+        }
+        current += char;
+    }
+
+    if (current.trim()) items.push(current.trim());
+    return items;
+}
+
+/**
+ * Remove a trailing comment (# at line start or after whitespace, outside quotes)
+ */
+function stripComment(line) {
+    let quote = null;
+
+    for (let i = 0; i < line.length; i++) {
+        const char = line[i];
+        if (quote) {
+            if (char === '\\' && quote === '"') i++;
+            else if (char === "'" && quote === "'" && line[i + 1] === "'") i++; // '' escape
+            else if (char === quote) quote = null;
+        } else if ((char === '"' || char === "'") && (i === 0 || /[\s[,]/.test(line[i - 1]))) {
+            quote = char; // Apostrophes inside plain scalars do not open a string
+        } else if (char === '#' && (i === 0 || /\s/.test(line[i - 1]))) {
+            return line.slice(0, i);
+        }
+    }
+
+    return line;
+}
+
+function lineError(line, message) {
+    return new Error(`Line ${line.line}: ${message}`);
+}
+
+export default YAMLParser;
@@ -0,0 +1,167 @@
+/**
+ * ExtractorPlugin - Base Class for Custom Symbol Extractors
+ * v3.4.0 - Extractor plugins
+ *
+ * Responsibilities:
+ * - Let users add symbol extraction for languages without a built-in plugin
+ *   (DSLs, protobuf, SQL migrations, Terraform)
+ * - Normalize whatever an extractor returns into the unified symbol model
+ * - Run external extractors that speak JSON over stdin/stdout (ExecExtractorPlugin)
+ */
+
+import path from 'path';
+import { spawnSync } from 'child_process';
+import { LanguagePlugin } from './LanguagePlugin.js';
+import { createSymbol, SymbolKind } from '../symbols/SymbolModel.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ExtractorPlugin');
+
+const KINDS = new Set(Object.values(SymbolKind));
+
+export class ExtractorPlugin extends LanguagePlugin {
+  /**
+   * @param {Object} options - { name, extensions, language }
+   */
+  constructor(options = {}) {
+    super();
+    this.name = options.name || 'custom';
+    this.extensions = (options.extensions || []).map(ext => (ext.startsWith('.') ? ext : `.${ext}`).toLowerCase());
+    this.language = options.language || this.name;
+    this.backend = `plugin:${this.name}`; // Content cache keys of outlines from this extractor
+    this.last = null;
+  }
+
+  /**
+   * Extract raw symbols; implemented by subclasses
+   * @param {string} content - File content
+   * @param {string} filePath - Relative path
+   * @returns {Array<Object>|{symbols: Array<Object>, imports?: Array<Object>}}
+   *   Symbols as { name, kind, startLine, endLine, signature, doc, parent, exported };
+   *   imports as { source, names, line } with source a path relative to the file or the root
+   */
+  extract(content, filePath) {
+    throw new Error('extract() must be implemented by subclass');
+  }
+
+  supportsSymbolExtraction() {
+    return true;
+  }
+
+  getLanguageId(filePath) {
+    return this.language;
+  }
+
+  extractSymbols(content, filePath) {
+    return this.result(content, filePath).symbols;
+  }
+
+  extractImports(content, filePath) {
+    return this.result(content, filePath).imports;
+  }
+
+  extractMethods(content, filePath) {
+    return this.methodsFromSymbols(this.extractSymbols(content, filePath));
+  }
+
+  resolveImport(imported, fromFile, project) {
+    const candidates = [
+      path.posix.normalize(path.posix.join(path.posix.dirname(fromFile), imported.source)),
+      path.posix.normalize(imported.source)
+    ];
+    return candidates.find(candidate => !candidate.startsWith('..') && (project.hasFile(candidate) || project.hasDir(candidate))) || null;
+  }
+
+  /**
+   * Normalized output of extract(); the last file is kept, since symbols
+   * and imports of the same content are asked for separately
+   * @private
+   */
+  result(content, filePath) {
+    if (this.last && this.last.content === content && this.last.filePath === filePath) {
+      return this.last.result;
+    }
+
+    let raw;
+    try {
+      raw = this.extract(content, filePath) || [];
+    } catch (error) {
+      logger.warn(`Extractor ${this.name} failed for ${filePath}: ${error.message}`);
+      raw = [];
+    }
+
+    const result = {
+      symbols: (Array.isArray(raw) ? raw : raw.symbols || []).map(symbol => this.normalize(symbol, filePath)).filter(Boolean),
+      imports: (Array.isArray(raw) ? [] : raw.imports || [])
+        .filter(imported => imported && typeof imported.source === 'string')
+        .map(imported => ({ source: imported.source, names: imported.names || null, line: imported.line || 1 }))
+    };
+    this.last = { content, filePath, result };
+    return result;
+  }
+
+  /**
+   * One raw symbol in the unified model; unknown kinds become types
+   * @private
+   */
+  normalize(symbol, filePath) {
+    if (!symbol || typeof symbol.name !== 'string' || !symbol.name) {
+      return null;
+    }
+    return createSymbol({
+      ...symbol,
+      kind: KINDS.has(symbol.kind) ? symbol.kind : SymbolKind.TYPE,
+      language: this.language,
+      file: filePath,
+      startLine: Number(symbol.startLine) || 1,
+      endLine: Number(symbol.endLine) || Number(symbol.startLine) || 1,
+      // DSL declarations are visible to the whole project unless told otherwise
+      exported: symbol.exported ?? true
+    });
+  }
+}
+
+/**
+ * Extractor running an external command per file
+ * The command reads {"file", "language", "content"} as JSON on stdin and
+ * writes {"symbols": [...], "imports": [...]} (or a bare symbol array) to stdout.
+ */
+export class ExecExtractorPlugin extends ExtractorPlugin {
+  /**
+   * @param {Object} options - { name, extensions, language, command, cwd, timeout }
+   */
+  constructor(options = {}) {
+    super(options);
+    this.command = options.command;
+    this.cwd = options.cwd || process.cwd();
+    this.timeout = options.timeout || 10000;
+  }
+
+  extract(content, filePath) {
+    const shell = typeof this.command === 'string';
+    const [command, ...args] = shell ? [this.command] : this.command;
+    const run = spawnSync(command, args, {
+      cwd: this.cwd,
+      shell,
+      input: JSON.stringify({ file: filePath, language: this.language, content }),
+      encoding: 'utf8',
+      timeout: this.timeout,
+      maxBuffer: 64 * 1024 * 1024
+    });
+
+    if (run.error) {
+      throw run.error;
+    }
+    if (run.status !== 0) {
+      throw new Error(`exited with ${run.status ?? run.signal}${run.stderr ? `: ${run.stderr.trim()}` : ''}`);
+    }
+
+    try {
+      return JSON.parse(run.stdout);
+    } catch (error) {
+      throw new Error(`invalid JSON output (${error.message})`);
+    }
+  }
+}
+
+export default ExtractorPlugin;
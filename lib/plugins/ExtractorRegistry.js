@@ -0,0 +1,142 @@
+/**
+ * ExtractorRegistry - Custom extractors from .ctxman/extractors.json
+ * v3.4.0 - Extractor plugins
+ *
+ * Responsibilities:
+ * - Validate extractor entries: a JS module or a command, and the extensions they handle
+ * - Import module extractors and wrap command extractors (JSON over stdin/stdout)
+ * - Register them with every SymbolExtractor and with the file scanner
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { pathToFileURL } from 'url';
+import { ExtractorPlugin, ExecExtractorPlugin } from './ExtractorPlugin.js';
+import { LanguagePlugin } from './LanguagePlugin.js';
+import { registerExtractorPlugin, getRegisteredPlugins } from '../symbols/SymbolExtractor.js';
+import FileUtils from '../utils/file-utils.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('ExtractorRegistry');
+
+export const EXTRACTORS_FILE = path.join('.ctxman', 'extractors.json');
+
+const ENTRY_KEYS = ['name', 'extensions', 'language', 'module', 'command', 'timeout'];
+
+/**
+ * Load and register the custom extractors of a project
+ * Loading the same file twice registers its extractors once.
+ * @param {string} root - Project root
+ * @returns {Promise<Array<LanguagePlugin>>} Registered plugins (empty without extractors.json)
+ * @throws {Error} When the file is invalid or a module cannot be loaded
+ */
+export async function loadExtractors(root) {
+  const filePath = path.join(root, EXTRACTORS_FILE);
+  if (!fs.existsSync(filePath)) return [];
+
+  let data;
+  try {
+    data = JSON.parse(fs.readFileSync(filePath, 'utf8'));
+  } catch (error) {
+    throw new Error(`${EXTRACTORS_FILE}: ${error.message}`);
+  }
+  if (!Array.isArray(data?.extractors)) {
+    throw new Error(`${EXTRACTORS_FILE}: expected an "extractors" list`);
+  }
+
+  const registered = new Set(getRegisteredPlugins().map(plugin => plugin.name));
+  const plugins = [];
+  for (const [index, entry] of data.extractors.entries()) {
+    const config = validateEntry(entry, index);
+    if (registered.has(config.name)) continue;
+
+    const plugin = config.module
+      ? await loadModule(root, config)
+      : new ExecExtractorPlugin({ ...config, cwd: root });
+
+    registerExtractorPlugin(plugin);
+    FileUtils.addTextExtensions(plugin.extensions);
+    plugins.push(plugin);
+    logger.debug(`Registered extractor ${plugin.name} for ${plugin.extensions.join(', ')}`);
+  }
+  return plugins;
+}
+
+/**
+ * Normalize one extractors.json entry; unknown keys and bad values are errors
+ */
+function validateEntry(entry, index) {
+  const label = `${EXTRACTORS_FILE}: extractor ${typeof entry?.name === 'string' ? `"${entry.name}"` : index + 1}`;
+  const fail = message => {
+    throw new Error(`${label} ${message}`);
+  };
+
+  if (!entry || typeof entry !== 'object' || Array.isArray(entry)) fail('must be an object');
+
+  const unknown = Object.keys(entry).filter(key => !ENTRY_KEYS.includes(key));
+  if (unknown.length > 0) fail(`has unknown keys: ${unknown.join(', ')} (allowed: ${ENTRY_KEYS.join(', ')})`);
+
+  if (typeof entry.name !== 'string' || !entry.name.trim()) fail('needs a "name"');
+  if (Boolean(entry.module) === Boolean(entry.command)) fail('needs either "module" or "command"');
+
+  const command = entry.command;
+  if (command !== undefined && !(typeof command === 'string' && command.trim()) &&
+      !(Array.isArray(command) && command.length > 0 && command.every(arg => typeof arg === 'string'))) {
+    fail('has an invalid "command" (expected a string or a list of arguments)');
+  }
+
+  const extensions = entry.extensions;
+  if (extensions !== undefined && !(Array.isArray(extensions) && extensions.every(ext => typeof ext === 'string' && ext.trim()))) {
+    fail('has invalid "extensions" (expected a list such as [".tf"])');
+  }
+  if (command && !extensions?.length) fail('needs "extensions"');
+
+  if (entry.timeout !== undefined && !(Number.isInteger(entry.timeout) && entry.timeout > 0)) {
+    fail('has an invalid "timeout" (expected milliseconds)');
+  }
+
+  return { ...entry, name: entry.name.trim() };
+}
+
+/**
+ * Plugin exported by a JS module: an ExtractorPlugin (or LanguagePlugin) class or
+ * instance, or an object with extract(content, filePath)
+ */
+async function loadModule(root, config) {
+  const modulePath = path.resolve(root, config.module);
+  let module;
+  try {
+    module = await import(pathToFileURL(modulePath).href);
+  } catch (error) {
+    throw new Error(`${EXTRACTORS_FILE}: extractor "${config.name}" could not load ${config.module}: ${error.message}`);
+  }
+
+  const exported = module.default ?? Object.values(module)[0];
+  let plugin;
+  if (typeof exported === 'function') {
+    plugin = new exported(config);
+  } else if (exported instanceof LanguagePlugin) {
+    plugin = exported;
+  } else if (exported && typeof exported.extract === 'function') {
+    plugin = new ExtractorPlugin(config);
+    plugin.extract = exported.extract.bind(exported);
+  }
+
+  if (!(plugin instanceof LanguagePlugin)) {
+    throw new Error(`${EXTRACTORS_FILE}: ${config.module} must export an ExtractorPlugin or an object with extract()`);
+  }
+
+  // Configuration overrides what the module declares
+  if (config.extensions) {
+    plugin.extensions = config.extensions.map(ext => (ext.startsWith('.') ? ext : `.${ext}`).toLowerCase());
+  }
+  plugin.name = config.name;
+  if (plugin instanceof ExtractorPlugin) plugin.backend = `plugin:${config.name}`;
+
+  if (plugin.extensions.length === 0) {
+    throw new Error(`${EXTRACTORS_FILE}: extractor "${config.name}" handles no extensions`);
+  }
+  return plugin;
+}
+
+export default { EXTRACTORS_FILE, loadExtractors };
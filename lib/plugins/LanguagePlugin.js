@@ -5,6 +5,9 @@
  * All language analyzers should extend this class
  */
 
+import { createSymbol, toMethod, SymbolKind } from '../symbols/SymbolModel.js';
+import { findHeaderEnd } from '../symbols/SourceScanner.js';
+
 export class LanguagePlugin {
   constructor() {
     this.name = 'base';
@@ -23,6 +26,7 @@ export class LanguagePlugin {
       extensions: this.extensions,
       supportsAST: this.supportsAST(),
       supportsMethodExtraction: this.supportsMethodExtraction(),
+      supportsSymbolExtraction: this.supportsSymbolExtraction(),
       supportsFrameworkDetection: this.supportsFrameworkDetection()
     };
   }
@@ -43,6 +47,14 @@ export class LanguagePlugin {
     return true;
   }
 
+  /**
+   * Check if this plugin emits the structured symbol model
+   * @returns {boolean}
+   */
+  supportsSymbolExtraction() {
+    return false;
+  }
+
   /**
    * Check if this plugin supports framework detection
    * @returns {boolean}
@@ -61,6 +73,175 @@ export class LanguagePlugin {
     throw new Error('extractMethods() must be implemented by subclass');
   }
 
+  /**
+   * Extract symbols (functions, types, doc comments) from code
+   * Default: wraps extractMethods() results as function symbols
+   * @param {string} content - File content
+   * @param {string} filePath - File path
+   * @returns {Array<Symbol>}
+   */
+  extractSymbols(content, filePath) {
+    return this.extractMethods(content, filePath).map(method => createSymbol({
+      name: method.name,
+      kind: SymbolKind.FUNCTION,
+      language: this.getLanguageId(filePath),
+      file: method.file || filePath,
+      startLine: method.line
+    }));
+  }
+
+  /**
+   * Language id recorded on symbols
+   * @param {string} filePath - File path
+   * @returns {string}
+   */
+  getLanguageId(filePath) {
+    return this.name;
+  }
+
+  /**
+   * Tree-sitter grammar for a file (optional)
+   * @param {string} filePath - File path
+   * @returns {{package: string, export?: string}|null}
+   */
+  getTreeSitterGrammar(filePath) {
+    return null;
+  }
+
+  /**
+   * Describe a tree-sitter node as a symbol (optional)
+   * @param {object} node - Syntax node
+   * @param {object} scope - { parent, parentKind, exported, implements }
+   * @returns {object|null} Descriptor { name, kind, exported, container?, symbol?, doc?, parent? }
+   */
+  describeTreeSitterNode(node, scope) {
+    return null;
+  }
+
+  /**
+   * Extract import statements (used by the dependency graph)
+   * @param {string} content - File content
+   * @param {string} filePath - File path
+   * @returns {Array<{source: string, names: Array<string>|null, aliases?: object, line: number}>}
+   *   names lists imported symbols, or null when the whole package is visible
+   */
+  extractImports(content, filePath) {
+    return [];
+  }
+
+  /**
+   * Resolve an import to a project file or package directory
+   * @param {object} imported - Entry returned by extractImports()
+   * @param {string} fromFile - Importing file (relative, '/'-separated)
+   * @param {ProjectIndex} project - { hasFile, hasDir, findBySuffix, readFile }
+   * @returns {string|null} Relative path, or null for external imports
+   */
+  resolveImport(imported, fromFile, project) {
+    return null;
+  }
+
+  /**
+   * Declarations a standalone excerpt of the file needs (symbol slices)
+   * Default: import statements, extended over bracketed name lists
+   * @param {string} content - File content
+   * @param {string} filePath - File path
+   * @returns {Array<{startLine: number, endLine: number, imports: Array<object>}>}
+   *   imports holds the extractImports() entries of each range (empty for package clauses)
+   */
+  extractPreamble(content, filePath) {
+    const lines = content.split('\n');
+    const ranges = [];
+
+    for (const imported of this.extractImports(content, filePath)) {
+      const last = ranges[ranges.length - 1];
+      if (last && imported.line <= last.endLine) {
+        last.imports.push(imported);
+        continue;
+      }
+      ranges.push({
+        startLine: imported.line,
+        endLine: findHeaderEnd(lines, imported.line - 1) + 1,
+        imports: [imported]
+      });
+    }
+
+    return ranges;
+  }
+
+  /**
+   * Local names an import binds in the importing file
+   * @param {object} imported - Entry returned by extractImports()
+   * @returns {Array<string>|null} Names, or null when unknown (default and namespace imports)
+   */
+  getImportBindings(imported) {
+    if (imported.names) {
+      const localFor = Object.fromEntries(Object.entries(imported.aliases || {}).map(([local, name]) => [name, local]));
+      return imported.names.map(name => localFor[name] || name);
+    }
+    return null;
+  }
+
+  /**
+   * Rewrite an import statement to the names an excerpt uses (optional)
+   * @param {string} statement - Text of one extractPreamble() range
+   * @param {Function} isUsed - Local name => whether the excerpt references it
+   * @returns {string|null|undefined} Rewritten statement, null to drop it, or
+   *   undefined to keep or drop it whole by its extractImports() entries
+   */
+  pruneImport(statement, isUsed) {
+    return undefined;
+  }
+
+  /**
+   * Prefix code puts before an imported package's symbols (optional)
+   * @param {object} imported - Entry returned by extractImports(), with
+   *   packageName once the dependency graph resolved it
+   * @returns {string|null|false} Qualifier such as 'models' for `models.User`,
+   *   null when symbols are used unqualified, false when the import binds none
+   */
+  getImportQualifier(imported) {
+    return null;
+  }
+
+  /**
+   * Name a package declares for itself (optional; Go package clauses)
+   * @param {Array<Symbol>} symbols - Symbols of the package's files
+   * @returns {string|null}
+   */
+  getPackageName(symbols) {
+    return null;
+  }
+
+  /**
+   * Whether types satisfy interfaces by their method set alone (Go),
+   * rather than by declaring them
+   * @returns {boolean}
+   */
+  hasStructuralInterfaces() {
+    return false;
+  }
+
+  /**
+   * Package a file belongs to; dependency graph nodes are packages
+   * Default: one package per file
+   * @param {string} filePath - Relative, '/'-separated path
+   * @returns {string}
+   */
+  getPackageId(filePath) {
+    return filePath;
+  }
+
+  /**
+   * Convert symbols to the legacy method shape
+   * @param {Array<Symbol>} symbols
+   * @returns {Array<Method>}
+   */
+  methodsFromSymbols(symbols) {
+    return symbols
+      .filter(symbol => symbol.kind === SymbolKind.FUNCTION || symbol.kind === SymbolKind.METHOD)
+      .map(toMethod);
+  }
+
   /**
    * Detect framework (optional)
    * @param {string} content - File content
@@ -1,9 +1,15 @@
-import { pipeline } from '@xenova/transformers';
-
 /**
  * Abstract base class for embedding providers
  */
 export class EmbeddingProvider {
+  /**
+   * Identifies the provider and model; vectors from different ids are not comparable.
+   * @returns {string}
+   */
+  get id() {
+    return this.constructor.name;
+  }
+
   /**
    * Generates an embedding for the given text.
    * @param {string} text 
@@ -12,6 +18,19 @@ export class EmbeddingProvider {
   async embed(text) {
     throw new Error('Not implemented');
   }
+
+  /**
+   * Generates embeddings for several texts, in order.
+   * @param {string[]} texts
+   * @returns {Promise<number[][]>}
+   */
+  async embedBatch(texts) {
+    const vectors = [];
+    for (const text of texts) {
+      vectors.push(await this.embed(text));
+    }
+    return vectors;
+  }
 }
 
 /**
@@ -25,8 +44,14 @@ export class TransformersEmbeddingProvider extends EmbeddingProvider {
     this.pipe = null;
   }
 
+  get id() {
+    return `transformers:${this.modelName}`;
+  }
+
   async init() {
     if (!this.pipe) {
+      // Loaded lazily so the HTTP providers work without the ONNX runtime
+      const { pipeline } = await import('@xenova/transformers');
       // Load the pipeline. This will download the model if not present.
       this.pipe = await pipeline('feature-extraction', this.modelName);
     }
@@ -42,6 +67,139 @@ export class TransformersEmbeddingProvider extends EmbeddingProvider {
   }
 }
 
+/**
+ * OpenAI embeddings API (POST /embeddings).
+ * Default model: text-embedding-3-small; the key defaults to OPENAI_API_KEY.
+ */
+export class OpenAIEmbeddingProvider extends EmbeddingProvider {
+  /**
+   * @param {Object} config
+   * @param {string} [config.model]
+   * @param {string} [config.apiKey]
+   * @param {string} [config.baseUrl]
+   * @param {number} [config.batchSize] - Texts per request
+   * @param {Function} [config.fetch] - fetch implementation (tests)
+   */
+  constructor(config = {}) {
+    super();
+    this.model = config.model || 'text-embedding-3-small';
+    this.apiKey = config.apiKey ?? process.env.OPENAI_API_KEY;
+    this.baseUrl = (config.baseUrl || 'https://api.openai.com/v1').replace(/\/+$/, '');
+    this.batchSize = config.batchSize || 64;
+    this.fetch = config.fetch || globalThis.fetch;
+    this.label = 'OpenAI';
+    this.requiresKey = true;
+  }
+
+  get id() {
+    return `openai:${this.model}`;
+  }
+
+  async embed(text) {
+    const [vector] = await this.embedBatch([text]);
+    return vector;
+  }
+
+  async embedBatch(texts) {
+    if (this.requiresKey && !this.apiKey) {
+      throw new Error('OpenAI embeddings need an API key (set OPENAI_API_KEY)');
+    }
+
+    const vectors = [];
+    for (let i = 0; i < texts.length; i += this.batchSize) {
+      const data = await postJSON(this, `${this.baseUrl}/embeddings`, {
+        model: this.model,
+        input: texts.slice(i, i + this.batchSize)
+      });
+      // Entries carry their input index; do not rely on response order
+      vectors.push(...[...data.data].sort((a, b) => a.index - b.index).map(entry => entry.embedding));
+    }
+    return vectors;
+  }
+}
+
+/**
+ * Local OpenAI-compatible endpoint (LM Studio, llama.cpp server, vLLM, LocalAI).
+ * The API key is optional.
+ */
+export class LocalEmbeddingProvider extends OpenAIEmbeddingProvider {
+  constructor(config = {}) {
+    super({ apiKey: '', ...config, baseUrl: config.baseUrl || 'http://localhost:1234/v1' });
+    this.model = config.model || 'text-embedding';
+    this.label = 'Local embeddings';
+    this.requiresKey = false;
+  }
+
+  get id() {
+    return `local:${this.baseUrl}:${this.model}`;
+  }
+}
+
+/**
+ * Ollama embeddings (POST /api/embed).
+ * Default model: nomic-embed-text
+ */
+export class OllamaEmbeddingProvider extends EmbeddingProvider {
+  /**
+   * @param {Object} config
+   * @param {string} [config.model]
+   * @param {string} [config.baseUrl]
+   * @param {number} [config.batchSize]
+   * @param {Function} [config.fetch]
+   */
+  constructor(config = {}) {
+    super();
+    this.model = config.model || 'nomic-embed-text';
+    this.baseUrl = (config.baseUrl || process.env.OLLAMA_HOST || 'http://localhost:11434').replace(/\/+$/, '');
+    this.batchSize = config.batchSize || 32;
+    this.fetch = config.fetch || globalThis.fetch;
+    this.label = 'Ollama';
+  }
+
+  get id() {
+    return `ollama:${this.model}`;
+  }
+
+  async embed(text) {
+    const [vector] = await this.embedBatch([text]);
+    return vector;
+  }
+
+  async embedBatch(texts) {
+    const vectors = [];
+    for (let i = 0; i < texts.length; i += this.batchSize) {
+      const data = await postJSON(this, `${this.baseUrl}/api/embed`, {
+        model: this.model,
+        input: texts.slice(i, i + this.batchSize)
+      });
+      vectors.push(...data.embeddings);
+    }
+    return vectors;
+  }
+}
+
+/**
+ * POST a JSON body and parse the JSON response, with readable errors
+ * @param {Object} provider - { fetch, apiKey, label }
+ */
+async function postJSON(provider, url, body) {
+  const headers = { 'Content-Type': 'application/json' };
+  if (provider.apiKey) headers.Authorization = `Bearer ${provider.apiKey}`;
+
+  let response;
+  try {
+    response = await provider.fetch(url, { method: 'POST', headers, body: JSON.stringify(body) });
+  } catch (error) {
+    throw new Error(`${provider.label} request to ${url} failed: ${error.message}`);
+  }
+
+  if (!response.ok) {
+    const detail = (await response.text().catch(() => '')).slice(0, 200);
+    throw new Error(`${provider.label} returned ${response.status}${detail ? `: ${detail}` : ''}`);
+  }
+  return response.json();
+}
+
 /**
  * Mock provider for testing without loading heavy models.
  */
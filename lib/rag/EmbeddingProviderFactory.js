@@ -0,0 +1,30 @@
+import {
+  TransformersEmbeddingProvider,
+  OpenAIEmbeddingProvider,
+  OllamaEmbeddingProvider,
+  LocalEmbeddingProvider
+} from './EmbeddingProvider.js';
+
+export const EMBEDDING_PROVIDERS = ['transformers', 'openai', 'ollama', 'local'];
+
+export class EmbeddingProviderFactory {
+  /**
+   * @param {string} type - transformers | openai | ollama | local
+   * @param {Object} config - { model, baseUrl, apiKey }
+   * @returns {import('./EmbeddingProvider').EmbeddingProvider}
+   */
+  static create(type = 'transformers', config = {}) {
+    switch (type.toLowerCase()) {
+      case 'transformers':
+        return config.model ? new TransformersEmbeddingProvider(config.model) : new TransformersEmbeddingProvider();
+      case 'openai':
+        return new OpenAIEmbeddingProvider(config);
+      case 'ollama':
+        return new OllamaEmbeddingProvider(config);
+      case 'local':
+        return new LocalEmbeddingProvider(config);
+      default:
+        throw new Error(`Unknown embedding provider: ${type} (expected ${EMBEDDING_PROVIDERS.join(', ')})`);
+    }
+  }
+}
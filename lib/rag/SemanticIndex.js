@@ -0,0 +1,339 @@
+/**
+ * SemanticIndex - Embedding index over files and symbols
+ * v3.4.0 - Semantic query
+ *
+ * Responsibilities:
+ * - Split files into chunks: symbols, Markdown sections, or line windows
+ * - Embed a short summary of each chunk (path, kind, name, doc, code)
+ * - Reuse vectors by summary hash so only changed chunks are re-embedded
+ * - Rank chunks by cosine similarity to a natural-language query
+ */
+
+import fs from 'fs';
+import path from 'path';
+import ContentCache from '../cache/ContentCache.js';
+import { SymbolExtractor } from '../symbols/SymbolExtractor.js';
+import { SymbolKind } from '../symbols/SymbolModel.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('SemanticIndex');
+
+// Bump when chunking or summaries change shape
+export const SEMANTIC_INDEX_VERSION = 1;
+
+export const SECTION_KIND = 'section';
+
+export class SemanticIndex {
+  /**
+   * @param {import('./EmbeddingProvider').EmbeddingProvider} provider
+   * @param {Object} options
+   */
+  constructor(provider, options = {}) {
+    this.provider = provider;
+    this.options = {
+      root: process.cwd(),
+      path: path.join('.ctxman', 'cache', 'embeddings'), // Directory relative to root; null = memory only
+      extractor: null, // Initialized SymbolExtractor (default: heuristic)
+      cache: null, // ContentCache for symbol outlines
+      windowLines: 60, // Lines per chunk for files without symbols
+      summaryChars: 1500, // Code characters embedded per chunk
+      batchSize: 32,
+      ...options
+    };
+    this.extractor = this.options.extractor || new SymbolExtractor({ backend: 'heuristic' });
+
+    this.chunks = [];
+    this.vectors = new Map();
+    this.loaded = false;
+    this.stats = { files: 0, chunks: 0, embedded: 0, reused: 0 };
+  }
+
+  /**
+   * Vector file for the provider (null when memory only)
+   * @returns {string|null}
+   */
+  getFilePath() {
+    if (!this.options.path) return null;
+    const name = this.provider.id.replace(/[^\w.-]+/g, '_');
+    return path.resolve(this.options.root, this.options.path, `${name}.json`);
+  }
+
+  /**
+   * Split a file into chunks
+   * @param {string} relativePath
+   * @param {string} content
+   * @returns {Array<Object>} { id, file, name, kind, startLine, endLine, summary }
+   */
+  chunkFile(relativePath, content) {
+    const lines = content.split('\n');
+    let ranges = this.symbolRanges(relativePath, content);
+
+    if (ranges.length === 0 && /\.(md|mdx|markdown)$/i.test(relativePath)) {
+      ranges = markdownSections(lines);
+    }
+    if (ranges.length === 0) {
+      ranges = this.windows(relativePath, lines);
+    }
+
+    return ranges
+      .filter(range => lines.slice(range.startLine - 1, range.endLine).some(line => line.trim()))
+      .map(range => {
+        const code = lines.slice(range.startLine - 1, range.endLine).join('\n').slice(0, this.options.summaryChars);
+        return {
+          id: `${relativePath}#${range.name}@${range.startLine}`,
+          file: relativePath,
+          name: range.name,
+          kind: range.kind,
+          startLine: range.startLine,
+          endLine: range.endLine,
+          summary: [relativePath, `${range.kind} ${range.name}`, range.doc, code].filter(Boolean).join('\n')
+        };
+      });
+  }
+
+  /**
+   * Leaf symbols (containers with members are covered by their members)
+   * @private
+   */
+  symbolRanges(relativePath, content) {
+    if (!this.extractor.supports(relativePath)) return [];
+
+    const cache = this.options.cache;
+    const extracted = cache
+      ? cache.symbols(ContentCache.hash(content), ContentCache.key(this.extractor.getBackend(relativePath), relativePath),
+        relativePath, () => this.extractor.extract(content, relativePath))
+      : this.extractor.extract(content, relativePath);
+
+    const symbols = extracted.filter(symbol => symbol.kind !== SymbolKind.MODULE);
+    const parents = new Set(symbols.map(symbol => symbol.parent).filter(Boolean));
+
+    return symbols
+      .filter(symbol => !parents.has(symbol.qualifiedName))
+      .map(symbol => ({
+        name: symbol.qualifiedName,
+        kind: symbol.kind,
+        startLine: symbol.startLine,
+        endLine: symbol.endLine,
+        doc: symbol.doc
+      }));
+  }
+
+  /**
+   * Fixed-size line windows
+   * @private
+   */
+  windows(relativePath, lines) {
+    const size = this.options.windowLines;
+    const count = lines[lines.length - 1] === '' ? lines.length - 1 : lines.length;
+    const ranges = [];
+
+    for (let start = 1; start <= count; start += size) {
+      ranges.push({
+        name: path.posix.basename(relativePath),
+        kind: SECTION_KIND,
+        startLine: start,
+        endLine: Math.min(count, start + size - 1)
+      });
+    }
+    return ranges;
+  }
+
+  /**
+   * Chunk files and embed the chunks without a stored vector
+   * @param {Array<{relativePath: string, content: string}>} files
+   * @returns {Promise<Object>} Stats: files, chunks, embedded, reused
+   */
+  async build(files) {
+    this.load();
+
+    this.chunks = files.flatMap(({ relativePath, content }) =>
+      this.chunkFile(relativePath.split(path.sep).join('/'), content));
+    for (const chunk of this.chunks) {
+      chunk.hash = ContentCache.hash(chunk.summary);
+    }
+
+    const missing = [...new Set(this.chunks.map(chunk => chunk.hash).filter(hash => !this.vectors.has(hash)))];
+    const summaries = new Map(this.chunks.map(chunk => [chunk.hash, chunk.summary]));
+
+    for (let i = 0; i < missing.length; i += this.options.batchSize) {
+      const batch = missing.slice(i, i + this.options.batchSize);
+      const vectors = await this.provider.embedBatch(batch.map(hash => summaries.get(hash)));
+      batch.forEach((hash, j) => this.vectors.set(hash, vectors[j]));
+      logger.debug(`Embedded ${Math.min(i + batch.length, missing.length)}/${missing.length} chunks`);
+    }
+
+    // Vectors of chunks that no longer exist are dropped on save
+    const used = new Set(this.chunks.map(chunk => chunk.hash));
+    for (const hash of this.vectors.keys()) {
+      if (!used.has(hash)) this.vectors.delete(hash);
+    }
+
+    this.stats = {
+      files: files.length,
+      chunks: this.chunks.length,
+      embedded: missing.length,
+      reused: used.size - missing.length
+    };
+    this.save(missing.length > 0);
+    return this.stats;
+  }
+
+  /**
+   * Rank chunks by similarity to a query
+   * @param {string} query - Natural-language question
+   * @param {Object} options
+   * @param {number} [options.limit] - Maximum chunks returned
+   * @returns {Promise<Array<Object>>} Chunks with score, best first
+   */
+  async search(query, options = {}) {
+    if (this.chunks.length === 0) return [];
+
+    const queryVector = await this.provider.embed(query);
+    const ranked = this.chunks
+      .map(({ summary, hash, ...chunk }) => ({
+        ...chunk,
+        score: Math.round(SemanticIndex.cosine(queryVector, this.vectors.get(hash)) * 10000) / 10000
+      }))
+      .sort((a, b) => b.score - a.score || compareBytes(a.id, b.id));
+
+    return options.limit ? ranked.slice(0, options.limit) : ranked;
+  }
+
+  /**
+   * @param {Array<number>} a
+   * @param {Array<number>} b
+   * @returns {number} Cosine similarity (0 for mismatched or zero vectors)
+   */
+  static cosine(a, b) {
+    if (!a || !b || a.length !== b.length) return 0;
+
+    let dot = 0;
+    let normA = 0;
+    let normB = 0;
+    for (let i = 0; i < a.length; i++) {
+      dot += a[i] * b[i];
+      normA += a[i] * a[i];
+      normB += b[i] * b[i];
+    }
+    return normA && normB ? dot / Math.sqrt(normA * normB) : 0;
+  }
+
+  /**
+   * Load stored vectors (once); a corrupt or outdated file starts empty
+   * @returns {SemanticIndex}
+   */
+  load() {
+    if (this.loaded) return this;
+    this.loaded = true;
+
+    const filePath = this.getFilePath();
+    if (!filePath || !fs.existsSync(filePath)) return this;
+
+    try {
+      const data = JSON.parse(fs.readFileSync(filePath, 'utf-8'));
+      if (data.version !== SEMANTIC_INDEX_VERSION || data.provider !== this.provider.id) {
+        logger.info(`Embedding index for ${this.provider.id} is outdated, rebuilding`);
+        return this;
+      }
+
+      for (const [hash, encoded] of Object.entries(data.vectors || {})) {
+        const buffer = Buffer.from(encoded, 'base64');
+        this.vectors.set(hash, Array.from(new Float32Array(buffer.buffer, buffer.byteOffset, buffer.byteLength / 4)));
+      }
+      logger.debug(`Loaded ${this.vectors.size} vectors from ${filePath}`);
+    } catch (error) {
+      logger.warn(`Ignoring unreadable embedding index: ${error.message}`);
+    }
+
+    return this;
+  }
+
+  /**
+   * Write vectors to disk (atomic rename); vectors are stored as base64 float32
+   * @param {boolean} changed - Whether new vectors were added
+   * @returns {boolean} True if the file was written
+   */
+  save(changed = true) {
+    const filePath = this.getFilePath();
+    if (!filePath || (!changed && fs.existsSync(filePath))) return false;
+
+    try {
+      fs.mkdirSync(path.dirname(filePath), { recursive: true });
+      const vectors = {};
+      for (const [hash, vector] of this.vectors) {
+        vectors[hash] = Buffer.from(new Float32Array(vector).buffer).toString('base64');
+      }
+
+      const tmpPath = `${filePath}.${process.pid}.tmp`;
+      fs.writeFileSync(tmpPath, JSON.stringify({
+        version: SEMANTIC_INDEX_VERSION,
+        provider: this.provider.id,
+        savedAt: new Date().toISOString(),
+        vectors
+      }));
+      fs.renameSync(tmpPath, filePath);
+      logger.debug(`Saved ${this.vectors.size} vectors to ${filePath}`);
+      return true;
+    } catch (error) {
+      logger.error(`Failed to save embedding index: ${error.message}`);
+      return false;
+    }
+  }
+
+  /**
+   * Format a query result as a console report
+   * @param {Object} result - { query, provider, stats, chunks, skipped, budget }
+   * @returns {string}
+   */
+  static formatQuery(result) {
+    const lines = [];
+
+    lines.push('');
+    lines.push('🔎 SEMANTIC QUERY');
+    lines.push('='.repeat(80));
+    lines.push(`   Query:    ${result.query}`);
+    lines.push(`   Provider: ${result.provider}`);
+    if (result.stats) {
+      lines.push(`   Index:    ${result.stats.chunks} chunks in ${result.stats.files} files ` +
+        `(${result.stats.embedded} embedded, ${result.stats.reused} reused)`);
+    }
+    if (result.budget) {
+      lines.push(`   Budget:   ${result.budget.usedTokens.toLocaleString()}/${result.budget.limit.toLocaleString()} tokens` +
+        (result.skipped > 0 ? ` (${result.skipped} relevant chunks did not fit)` : ''));
+    }
+    lines.push('');
+
+    if (result.chunks.length === 0) {
+      lines.push('   No matching chunks');
+    }
+    for (const chunk of result.chunks) {
+      lines.push(`   ${chunk.score.toFixed(3)}  ${chunk.file}:${chunk.startLine}-${chunk.endLine}  ${chunk.kind} ${chunk.name}`);
+    }
+
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Markdown sections split at headings (outside code fences)
+ */
+function markdownSections(lines) {
+  const sections = [];
+  let inFence = false;
+
+  lines.forEach((line, index) => {
+    if (/^\s*(```|~~~)/.test(line)) inFence = !inFence;
+    const heading = !inFence && /^#{1,6}\s+(.+?)\s*#*\s*$/.exec(line);
+    if (heading || (index === 0 && !heading)) {
+      if (sections.length > 0) sections[sections.length - 1].endLine = index;
+      sections.push({ name: heading ? heading[1] : 'preamble', kind: SECTION_KIND, startLine: index + 1, endLine: lines.length });
+    }
+  });
+
+  const last = sections[sections.length - 1];
+  if (last && lines[lines.length - 1] === '' && last.endLine > last.startLine) last.endLine--;
+  return sections.filter(section => section.endLine >= section.startLine);
+}
+
+export default SemanticIndex;
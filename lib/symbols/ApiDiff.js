@@ -0,0 +1,196 @@
+/**
+ * ApiDiff - Exported API changes between two revisions
+ * v3.4.0 - API change context (ctxman api-diff)
+ *
+ * Responsibilities:
+ * - List the exported symbols of a dependency graph, leaving out test files
+ * - Compare the exported symbols of two revisions by file, kind and
+ *   qualified name: added, removed, changed signature, moved to another file
+ * - Report the changes on the console and in the header of a digest, with
+ *   old and new signatures, so a context can explain a migration
+ *
+ * Signatures are compared with whitespace collapsed; overloads of a name
+ * are compared as a set.
+ */
+
+import { SymbolKind } from './SymbolModel.js';
+import { TestPairing } from '../core/TestPairing.js';
+import { compareBytes } from '../utils/ordering.js';
+
+export const API_CHANGES = ['added', 'removed', 'changed', 'moved'];
+
+export class ApiDiff {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      tests: false, // Count exported symbols of test files as API
+      ...options
+    };
+  }
+
+  /**
+   * Exported symbols of a graph
+   * @param {DependencyGraph} graph
+   * @returns {Array<Object>} In file and line order
+   */
+  exportedSymbols(graph) {
+    const symbols = [];
+    for (const [file, node] of graph.files) {
+      if (!this.options.tests && TestPairing.isTestFile(file)) continue;
+      symbols.push(...node.symbols.filter(symbol => symbol.exported && symbol.kind !== SymbolKind.MODULE));
+    }
+    return symbols.sort((a, b) => compareBytes(a.file, b.file) || a.startLine - b.startLine);
+  }
+
+  /**
+   * Changes from the base symbols to the head symbols
+   * @param {Array<Object>} baseSymbols - Result of exportedSymbols() at the base revision
+   * @param {Array<Object>} headSymbols - Result of exportedSymbols() at the head
+   * @returns {Array<Object>} { change, kind, name, file, line, before, after, from }, in file and line order;
+   *   file and line are the head's, the base's for removed symbols; from is the base file of moved symbols
+   */
+  compare(baseSymbols, headSymbols) {
+    const base = groupSymbols(baseSymbols);
+    const head = groupSymbols(headSymbols);
+    const changes = [];
+    const removed = [];
+    const added = [];
+
+    for (const [key, entry] of head) {
+      const old = base.get(key);
+      if (!old) {
+        added.push(entry);
+      } else if (old.signature !== entry.signature) {
+        changes.push(change('changed', entry, old));
+      }
+    }
+    for (const [key, entry] of base) {
+      if (!head.has(key)) removed.push(entry);
+    }
+
+    // A symbol removed from one file and added to another with its name and kind moved
+    const movedFrom = new Map();
+    for (const entry of removed) {
+      const name = `${entry.symbol.kind}\0${entry.symbol.qualifiedName}`;
+      if (!movedFrom.has(name)) movedFrom.set(name, []);
+      movedFrom.get(name).push(entry);
+    }
+    for (const entry of added) {
+      const candidates = movedFrom.get(`${entry.symbol.kind}\0${entry.symbol.qualifiedName}`);
+      const old = candidates?.shift();
+      changes.push(old ? { ...change('moved', entry, old), from: old.symbol.file } : change('added', entry, null));
+    }
+    for (const entries of movedFrom.values()) {
+      changes.push(...entries.map(entry => change('removed', entry, entry)));
+    }
+
+    return changes.sort((a, b) => compareBytes(a.file, b.file) || a.line - b.line || compareBytes(a.name, b.name));
+  }
+
+  /**
+   * Format an API diff scope as a console report
+   * @param {Object} scope - { base, head, changes, callSites }
+   * @returns {string}
+   */
+  static format(scope) {
+    const lines = [];
+    const counts = API_CHANGES.map(kind => [kind, scope.changes.filter(entry => entry.change === kind).length])
+      .filter(([, count]) => count > 0)
+      .map(([kind, count]) => `${count} ${kind}`);
+
+    lines.push('');
+    lines.push('🧩 API CHANGES');
+    lines.push('='.repeat(80));
+    lines.push(`   Base:       ${scope.base}`);
+    lines.push(`   Head:       ${scope.head || 'working tree'}`);
+    lines.push(`   Changes:    ${counts.join(', ') || 'none'}`);
+    lines.push(`   Call sites: ${scope.callSites.length} in ${new Set(scope.callSites.map(site => site.file)).size} files`);
+
+    if (scope.changes.length > 0) {
+      lines.push('');
+      for (const entry of scope.changes) {
+        lines.push(`   ${entry.change.padEnd(8)} ${entry.kind} ${entry.name} (${entry.file}:${entry.line})` +
+          (entry.from ? ` ← ${entry.from}` : ''));
+        for (const detail of describe(entry)) {
+          lines.push(`            ${detail}`);
+        }
+      }
+    }
+
+    return lines.join('\n');
+  }
+
+  /**
+   * Digest header lines
+   * @param {Object} scope - As for format()
+   * @returns {string}
+   */
+  static formatHeader(scope) {
+    let header = `API changes: ${scope.base} → ${scope.head || 'working tree'} (${scope.changes.length} symbols, ` +
+      `${scope.callSites.length} call sites)\n`;
+    for (const entry of scope.changes) {
+      header += `  ${entry.change} ${entry.kind} ${entry.name} (${entry.file}:${entry.line})${entry.from ? ` ← ${entry.from}` : ''}\n`;
+      header += describe(entry).map(detail => `    ${detail}\n`).join('');
+    }
+    return header;
+  }
+}
+
+/**
+ * Exported symbols by file, kind and qualified name
+ * @private
+ */
+function groupSymbols(symbols) {
+  const groups = new Map();
+  for (const symbol of symbols) {
+    const key = `${symbol.file}\0${symbol.kind}\0${symbol.qualifiedName}`;
+    if (!groups.has(key)) groups.set(key, { symbol, signatures: [] });
+    groups.get(key).signatures.push(normalizeSignature(symbol));
+  }
+  for (const entry of groups.values()) {
+    entry.signature = [...new Set(entry.signatures)].sort().join('\n');
+  }
+  return groups;
+}
+
+/**
+ * @private
+ */
+function normalizeSignature(symbol) {
+  return (symbol.signature || symbol.name).replace(/\s+/g, ' ').replace(/\s*\{$/, '').trim();
+}
+
+/**
+ * @private
+ */
+function change(kind, entry, old) {
+  const { symbol } = entry;
+  return {
+    change: kind,
+    kind: symbol.kind,
+    name: symbol.qualifiedName,
+    file: symbol.file,
+    line: symbol.startLine,
+    before: kind === 'added' ? null : old.signature,
+    after: kind === 'removed' ? null : entry.signature,
+    from: null
+  };
+}
+
+/**
+ * Old and new signatures of a change
+ * @private
+ */
+function describe(entry) {
+  if (entry.change === 'added') return entry.after.split('\n').map(signature => `+ ${signature}`);
+  if (entry.change === 'removed') return entry.before.split('\n').map(signature => `- ${signature}`);
+  if (entry.before === entry.after) return [];
+  return [
+    ...entry.before.split('\n').map(signature => `- ${signature}`),
+    ...entry.after.split('\n').map(signature => `+ ${signature}`)
+  ];
+}
+
+export default ApiDiff;
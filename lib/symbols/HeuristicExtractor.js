@@ -0,0 +1,140 @@
+/**
+ * HeuristicExtractor - Rule-driven symbol extraction for brace languages
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Used when no tree-sitter grammar is available. Language plugins describe
+ * their declarations as line rules; this module handles scoping, block
+ * boundaries, attributes/decorators and doc comment attachment.
+ */
+
+import { SourceScanner, collectLeadingDoc } from './SourceScanner.js';
+import { createSymbol } from './SymbolModel.js';
+
+/**
+ * Extract symbols using a language spec
+ * @param {string} content - File content
+ * @param {string} filePath - File path recorded on each symbol
+ * @param {object} spec - Language spec
+ * @param {string} spec.language - Language id
+ * @param {object} spec.scanOptions - SourceScanner options
+ * @param {Array<object>} spec.topLevel - Rules applied at file scope
+ * @param {Array<object>} spec.members - Rules applied directly inside containers
+ * @param {boolean} spec.nestedTypes - Apply top-level rules inside containers too
+ * @param {RegExp} spec.attribute - Lines attached above a declaration (decorators, annotations)
+ * @param {object} spec.doc - Options for collectLeadingDoc
+ * @param {boolean} spec.newlineTerminated - Declarations may end at a newline
+ * @param {Function} spec.normalizeLine - Optional (text, lineIndex, scanner) => text
+ * @returns {Array<object>} Symbols
+ */
+export function extractWithRules(content, filePath, spec) {
+  const scanner = new SourceScanner(content, spec.scanOptions);
+  const { lines } = scanner;
+  const symbols = [];
+  const containers = [];
+
+  for (let i = 0; i < lines.length; i++) {
+    if (scanner.skipLine[i]) continue;
+
+    let text = lines[i].trim();
+    if (!text || isCommentLine(text)) continue;
+    if (spec.normalizeLine) {
+      text = spec.normalizeLine(text, i, scanner);
+    }
+
+    const depth = scanner.depthAtLine[i];
+    const container = innermostContainer(containers, i);
+    let rules;
+
+    if (!container) {
+      if (depth !== 0) continue;
+      rules = spec.topLevel;
+    } else {
+      if (depth !== container.bodyDepth) continue;
+      rules = spec.nestedTypes ? [...spec.members, ...spec.topLevel] : spec.members;
+    }
+
+    const descriptor = matchRules(rules, text, { container, lineIndex: i, lines });
+    if (!descriptor) continue;
+
+    const block = descriptor.singleLine
+      ? { endLine: i, hasBody: false, bodyLine: null }
+      : scanner.findBlockEnd(i, {
+        newlineTerminated: spec.newlineTerminated || descriptor.newlineTerminated
+      });
+
+    let startLine = i;
+    while (spec.attribute && startLine > 0 && spec.attribute.test(lines[startLine - 1].trim())) {
+      startLine--;
+    }
+
+    if (descriptor.container) {
+      containers.push({
+        name: descriptor.containerName || (container ? `${container.name}.${descriptor.name}` : descriptor.name),
+        kind: descriptor.kind,
+        startLine: i,
+        endLine: block.endLine,
+        bodyDepth: depth + 1,
+        exported: descriptor.exported,
+        implements: descriptor.implements || null
+      });
+    }
+
+    if (descriptor.symbol === false) continue;
+
+    symbols.push(createSymbol({
+      name: descriptor.name,
+      kind: descriptor.kind,
+      language: spec.language,
+      file: filePath,
+      startLine: startLine + 1,
+      endLine: block.endLine + 1,
+      signature: descriptor.signature || scanner.signatureText(i, block.bodyLine, block.endLine),
+      doc: collectLeadingDoc(lines, startLine, spec.doc),
+      parent: descriptor.parent !== undefined ? descriptor.parent : (container ? container.name : null),
+      exported: descriptor.exported,
+      implements: descriptor.implements || (container && !descriptor.container ? container.implements : null)
+    }));
+  }
+
+  return symbols;
+}
+
+/**
+ * Apply rules in order, returning the first descriptor produced
+ * @param {Array<object>} rules - [{pattern, build}]
+ * @param {string} text - Trimmed line
+ * @param {object} ctx - Scope context
+ * @returns {object|null}
+ */
+function matchRules(rules, text, ctx) {
+  for (const rule of rules) {
+    const match = text.match(rule.pattern);
+    if (!match) continue;
+
+    const descriptor = rule.build(match, ctx);
+    if (descriptor) return descriptor;
+  }
+  return null;
+}
+
+/**
+ * Innermost container whose range encloses the line
+ * @param {Array<object>} containers
+ * @param {number} lineIndex
+ * @returns {object|null}
+ */
+function innermostContainer(containers, lineIndex) {
+  for (let c = containers.length - 1; c >= 0; c--) {
+    const container = containers[c];
+    if (lineIndex > container.startLine && lineIndex <= container.endLine) {
+      return container;
+    }
+  }
+  return null;
+}
+
+function isCommentLine(text) {
+  return text.startsWith('//') || text.startsWith('/*') || text.startsWith('*');
+}
+
+export default { extractWithRules };
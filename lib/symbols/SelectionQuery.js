@@ -0,0 +1,351 @@
+/**
+ * SelectionQuery - Expression language for selecting symbols
+ * v3.4.0 - Selection expressions (ctxman select EXPR)
+ *
+ * Responsibilities:
+ * - Parse expressions such as
+ *   lang==go && (exported || path~"internal/auth") && tokens<2000
+ *   into a tree, reporting the column of syntax errors
+ * - Check fields, operators and values while parsing
+ * - Evaluate the tree against symbols of the symbol index, computing costly
+ *   fields (tokens) only for symbols that get that far
+ *
+ * Grammar, loosest binding first:
+ *   or         := and ('||' and)*
+ *   and        := unary ('&&' unary)*
+ *   unary      := '!' unary | '(' or ')' | field [op value]
+ *   op         := == != ~ !~ < <= > >=
+ * A field on its own tests it: booleans are true, strings non-empty, numbers
+ * non-zero. == and != compare strings with * and ? wildcards; ~ and !~ match
+ * a regular expression. Values are quoted strings or bare words; numbers
+ * take k and m suffixes (2k).
+ */
+
+import { parseTokenCount } from '../core/TokenBudget.js';
+
+// Field -> type, and how it reads a SymbolIndex row
+export const QUERY_FIELDS = {
+  lang: ['string', symbol => symbol.language],
+  kind: ['string', symbol => symbol.kind],
+  name: ['string', symbol => symbol.name],
+  qname: ['string', symbol => symbol.qualifiedName],
+  package: ['string', symbol => symbol.package],
+  path: ['string', symbol => symbol.file],
+  signature: ['string', symbol => symbol.signature || ''],
+  doc: ['string', symbol => symbol.doc || ''],
+  parent: ['string', symbol => symbol.parent || ''],
+  exported: ['boolean', symbol => Boolean(symbol.exported)],
+  line: ['number', symbol => symbol.startLine],
+  lines: ['number', symbol => symbol.endLine - symbol.startLine + 1],
+  tokens: ['number', null] // Counted by the caller (options.tokensOf)
+};
+
+const OPERATORS = ['==', '!=', '!~', '<=', '>=', '~', '<', '>'];
+const ORDER_OPERATORS = ['<', '<=', '>', '>='];
+
+export class SelectionQuery {
+  /**
+   * @param {string} source - Expression
+   * @throws {Error} For syntax errors; error.column is 1-based
+   */
+  constructor(source) {
+    this.source = source;
+    const parser = new Parser(tokenize(source));
+    this.tree = parser.parseOr();
+    parser.expectEnd();
+  }
+
+  /**
+   * @param {string} source
+   * @returns {SelectionQuery}
+   */
+  static parse(source) {
+    return new SelectionQuery(source);
+  }
+
+  /**
+   * Fields the expression reads
+   * @returns {Set<string>}
+   */
+  fields() {
+    const fields = new Set();
+    const visit = node => {
+      if (node.field) fields.add(node.field);
+      for (const child of node.operands || []) visit(child);
+    };
+    visit(this.tree);
+    return fields;
+  }
+
+  /**
+   * Whether a symbol matches
+   * @param {Object} symbol - SymbolIndex row
+   * @param {Object} [options] - { tokensOf: symbol => number }
+   * @returns {boolean}
+   */
+  matches(symbol, options = {}) {
+    const values = new Map();
+    const read = field => {
+      if (!values.has(field)) {
+        values.set(field, field === 'tokens' ? tokensOf(symbol, options) : QUERY_FIELDS[field][1](symbol));
+      }
+      return values.get(field);
+    };
+    return evaluate(this.tree, read);
+  }
+
+  /**
+   * Symbols that match, in their order
+   * @param {Array<Object>} symbols - SymbolIndex rows
+   * @param {Object} [options] - As for matches()
+   * @returns {Array<Object>}
+   */
+  select(symbols, options = {}) {
+    return symbols.filter(symbol => this.matches(symbol, options));
+  }
+}
+
+/**
+ * Tokens: { type, value, column }; column 1-based
+ * @private
+ */
+function tokenize(source) {
+  const tokens = [];
+  let index = 0;
+  while (index < source.length) {
+    const char = source[index];
+    const column = index + 1;
+    if (/\s/.test(char)) {
+      index++;
+    } else if (char === '"' || char === "'") {
+      let value = '';
+      let end = index + 1;
+      while (end < source.length && source[end] !== char) {
+        if (source[end] === '\\' && end + 1 < source.length) end++;
+        value += source[end];
+        end++;
+      }
+      if (end >= source.length) {
+        throw syntaxError('Unterminated string', column);
+      }
+      tokens.push({ type: 'string', value, column });
+      index = end + 1;
+    } else if (source.startsWith('&&', index) || source.startsWith('||', index)) {
+      tokens.push({ type: source.slice(index, index + 2), column });
+      index += 2;
+    } else if (char === '(' || char === ')') {
+      tokens.push({ type: char, column });
+      index++;
+    } else if (OPERATORS.some(operator => source.startsWith(operator, index))) {
+      const operator = OPERATORS.find(candidate => source.startsWith(candidate, index));
+      tokens.push({ type: 'operator', value: operator, column });
+      index += operator.length;
+    } else if (char === '!') {
+      tokens.push({ type: '!', column });
+      index++;
+    } else {
+      const word = source.slice(index).match(/^[^\s()!=~<>&|"']+/)?.[0];
+      if (!word) {
+        throw syntaxError(`Unexpected '${char}'`, column);
+      }
+      tokens.push({ type: 'word', value: word, column });
+      index += word.length;
+    }
+  }
+  tokens.push({ type: 'end', column: source.length + 1 });
+  return tokens;
+}
+
+/**
+ * Recursive-descent parser over tokenize() output
+ * @private
+ */
+class Parser {
+  constructor(tokens) {
+    this.tokens = tokens;
+    this.position = 0;
+  }
+
+  peek() {
+    return this.tokens[this.position];
+  }
+
+  next() {
+    return this.tokens[this.position++];
+  }
+
+  parseOr() {
+    const operands = [this.parseAnd()];
+    while (this.peek().type === '||') {
+      this.next();
+      operands.push(this.parseAnd());
+    }
+    return operands.length === 1 ? operands[0] : { type: 'or', operands };
+  }
+
+  parseAnd() {
+    const operands = [this.parseUnary()];
+    while (this.peek().type === '&&') {
+      this.next();
+      operands.push(this.parseUnary());
+    }
+    return operands.length === 1 ? operands[0] : { type: 'and', operands };
+  }
+
+  parseUnary() {
+    const token = this.next();
+    if (token.type === '!') {
+      return { type: 'not', operands: [this.parseUnary()] };
+    }
+    if (token.type === '(') {
+      const inner = this.parseOr();
+      const closing = this.next();
+      if (closing.type !== ')') {
+        throw syntaxError(`Expected ')' but found ${describe(closing)}`, closing.column);
+      }
+      return inner;
+    }
+    if (token.type !== 'word') {
+      throw syntaxError(`Expected a field but found ${describe(token)}`, token.column);
+    }
+
+    const field = token.value;
+    if (!QUERY_FIELDS[field]) {
+      throw syntaxError(`Unknown field: ${field} (expected ${Object.keys(QUERY_FIELDS).join(', ')})`, token.column);
+    }
+    const [type] = QUERY_FIELDS[field];
+    if (this.peek().type !== 'operator') {
+      return { type: 'test', field };
+    }
+
+    const operator = this.next();
+    const value = this.next();
+    if (value.type !== 'word' && value.type !== 'string') {
+      throw syntaxError(`Expected a value after ${operator.value} but found ${describe(value)}`, value.column);
+    }
+    return { type: 'compare', field, operator: operator.value, value: this.checkValue(type, field, operator, value) };
+  }
+
+  /**
+   * Value of a comparison, converted for the field's type
+   */
+  checkValue(type, field, operator, value) {
+    const invalid = message => syntaxError(message, operator.column);
+    if (operator.value === '~' || operator.value === '!~') {
+      if (type !== 'string') throw invalid(`${field} is a ${type}; ${operator.value} needs a string field`);
+      try {
+        return new RegExp(value.value);
+      } catch (error) {
+        throw syntaxError(error.message, value.column);
+      }
+    }
+    if (type === 'number') {
+      const number = Number(value.value);
+      const parsed = Number.isFinite(number) ? number : parseTokenCount(value.value);
+      if (parsed === null || parsed === undefined || Number.isNaN(parsed)) {
+        throw syntaxError(`${field} compares with a number, not ${value.value}`, value.column);
+      }
+      return parsed;
+    }
+    if (ORDER_OPERATORS.includes(operator.value)) {
+      throw invalid(`${field} is a ${type}; ${operator.value} needs a number field`);
+    }
+    if (type === 'boolean') {
+      if (value.value !== 'true' && value.value !== 'false') {
+        throw syntaxError(`${field} compares with true or false, not ${value.value}`, value.column);
+      }
+      return value.value === 'true';
+    }
+    return globMatcher(value.value);
+  }
+
+  expectEnd() {
+    const token = this.peek();
+    if (token.type !== 'end') {
+      throw syntaxError(`Unexpected ${describe(token)}`, token.column);
+    }
+  }
+}
+
+/**
+ * @private
+ */
+function evaluate(node, read) {
+  switch (node.type) {
+    case 'or':
+      return node.operands.some(operand => evaluate(operand, read));
+    case 'and':
+      return node.operands.every(operand => evaluate(operand, read));
+    case 'not':
+      return !evaluate(node.operands[0], read);
+    case 'test':
+      return Boolean(read(node.field));
+    default:
+      return compare(read(node.field), node.operator, node.value);
+  }
+}
+
+/**
+ * @private
+ */
+function compare(actual, operator, expected) {
+  switch (operator) {
+    case '~':
+      return expected.test(actual);
+    case '!~':
+      return !expected.test(actual);
+    case '<':
+      return actual < expected;
+    case '<=':
+      return actual <= expected;
+    case '>':
+      return actual > expected;
+    case '>=':
+      return actual >= expected;
+    case '!=':
+      return typeof expected === 'function' ? !expected(actual) : actual !== expected;
+    default:
+      return typeof expected === 'function' ? expected(actual) : actual === expected;
+  }
+}
+
+/**
+ * @private
+ */
+function tokensOf(symbol, options) {
+  if (!options.tokensOf) {
+    throw new Error('The tokens field needs options.tokensOf');
+  }
+  return options.tokensOf(symbol);
+}
+
+/**
+ * Case-sensitive glob with * and ? wildcards, as in SymbolIndex queries
+ * @private
+ */
+function globMatcher(glob) {
+  const source = glob.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.');
+  const regex = new RegExp(`^${source}$`, 's');
+  return value => regex.test(value);
+}
+
+/**
+ * @private
+ */
+function describe(token) {
+  if (token.type === 'end') return 'the end';
+  if (token.type === 'word' || token.type === 'operator') return `'${token.value}'`;
+  if (token.type === 'string') return `"${token.value}"`;
+  return `'${token.type}'`;
+}
+
+/**
+ * @private
+ */
+function syntaxError(message, column) {
+  const error = new Error(`${message} (column ${column})`);
+  error.column = column;
+  return error;
+}
+
+export default SelectionQuery;
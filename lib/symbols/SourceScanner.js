@@ -0,0 +1,501 @@
+/**
+ * SourceScanner - Lexical structure helpers for heuristic parsing
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Responsibilities:
+ * - Track brace/paren depth while skipping strings and comments
+ * - Locate the end of brace-delimited and indentation-delimited blocks
+ * - Collect doc comments attached to a declaration
+ */
+
+const DEFAULT_SCAN_OPTIONS = {
+  lineComment: '//',
+  blockComment: ['/*', '*/'],
+  quotes: ['"', "'", '`'],
+  rustLifetimes: false
+};
+
+export class SourceScanner {
+  /**
+   * @param {string} content - Source text
+   * @param {object} options - Lexical options
+   */
+  constructor(content, options = {}) {
+    this.options = { ...DEFAULT_SCAN_OPTIONS, ...options };
+    this.lines = content.split('\n');
+    this.events = [];
+    this.depthAtLine = new Array(this.lines.length).fill(0);
+    this.parenAtLine = new Array(this.lines.length).fill(0);
+    this.skipLine = new Array(this.lines.length).fill(false);
+    this.scan(content);
+  }
+
+  /**
+   * Single pass over the source recording structural characters
+   * @param {string} content
+   */
+  scan(content) {
+    const { lineComment, blockComment, quotes, rustLifetimes } = this.options;
+    let line = 0;
+    let braceDepth = 0;
+    let parenDepth = 0;
+    let i = 0;
+
+    this.depthAtLine[0] = 0;
+
+    while (i < content.length) {
+      const ch = content[i];
+
+      if (ch === '\n') {
+        line++;
+        this.depthAtLine[line] = braceDepth;
+        this.parenAtLine[line] = parenDepth;
+        i++;
+        continue;
+      }
+
+      if (lineComment && content.startsWith(lineComment, i)) {
+        const end = content.indexOf('\n', i);
+        i = end === -1 ? content.length : end;
+        continue;
+      }
+
+      if (blockComment && content.startsWith(blockComment[0], i)) {
+        const end = content.indexOf(blockComment[1], i + blockComment[0].length);
+        const stop = end === -1 ? content.length : end + blockComment[1].length;
+        line = this.advanceLines(content, i, stop, line, braceDepth, parenDepth);
+        i = stop;
+        continue;
+      }
+
+      if (quotes.includes(ch)) {
+        if (ch === "'" && rustLifetimes && !/^'(?:\\.|[^\\'\n])'/.test(content.slice(i, i + 12))) {
+          i++;
+          continue;
+        }
+        const stop = this.findStringEnd(content, i, ch);
+        line = this.advanceLines(content, i, stop, line, braceDepth, parenDepth);
+        i = stop;
+        continue;
+      }
+
+      if (ch === '{') {
+        this.events.push({ type: '{', line, braceDepth, parenDepth });
+        braceDepth++;
+      } else if (ch === '}') {
+        braceDepth = Math.max(0, braceDepth - 1);
+        this.events.push({ type: '}', line, braceDepth, parenDepth });
+      } else if (ch === '(' || ch === '[') {
+        parenDepth++;
+      } else if (ch === ')' || ch === ']') {
+        parenDepth = Math.max(0, parenDepth - 1);
+      } else if (ch === ';') {
+        this.events.push({ type: ';', line, braceDepth, parenDepth });
+      }
+
+      i++;
+    }
+  }
+
+  /**
+   * Find the index just past the closing quote of a string literal
+   * @param {string} content
+   * @param {number} start - Index of the opening quote
+   * @param {string} quote
+   * @returns {number}
+   */
+  findStringEnd(content, start, quote) {
+    let i = start + 1;
+    const multiline = quote === '`';
+
+    while (i < content.length) {
+      const ch = content[i];
+      if (ch === '\\') {
+        i += 2;
+        continue;
+      }
+      if (ch === quote) {
+        return i + 1;
+      }
+      if (ch === '\n' && !multiline) {
+        return i;
+      }
+      i++;
+    }
+
+    return content.length;
+  }
+
+  /**
+   * Record line starts inside a skipped region (comment or string)
+   * @returns {number} Line number at the end of the region
+   */
+  advanceLines(content, from, to, line, braceDepth, parenDepth) {
+    for (let j = from; j < to; j++) {
+      if (content[j] === '\n') {
+        line++;
+        this.depthAtLine[line] = braceDepth;
+        this.parenAtLine[line] = parenDepth;
+        this.skipLine[line] = true;
+      }
+    }
+    return line;
+  }
+
+  /**
+   * Find the last line of the declaration starting at a given line
+   * @param {number} startLine - 0-based line index
+   * @param {object} options
+   * @param {boolean} options.newlineTerminated - Body brace must appear before the signature's line ends (Go)
+   * @returns {{endLine: number, hasBody: boolean, bodyLine: number|null}}
+   */
+  findBlockEnd(startLine, options = {}) {
+    const baseDepth = this.depthAtLine[startLine] || 0;
+    const baseParen = this.parenAtLine[startLine] || 0;
+    let openIndex = -1;
+
+    for (let e = 0; e < this.events.length; e++) {
+      const event = this.events[e];
+      if (event.line < startLine) continue;
+
+      if (options.newlineTerminated && event.line > startLine &&
+          this.parenAtLine[event.line] === baseParen && this.depthAtLine[event.line] === baseDepth &&
+          !this.signatureContinues(event.line)) {
+        break;
+      }
+
+      if (event.braceDepth < baseDepth) break;
+      if (event.braceDepth !== baseDepth || event.parenDepth !== baseParen) continue;
+
+      if (event.type === ';') {
+        return { endLine: event.line, hasBody: false, bodyLine: null };
+      }
+      if (event.type === '{') {
+        openIndex = e;
+        break;
+      }
+    }
+
+    if (openIndex === -1) {
+      return { endLine: this.signatureEnd(startLine, baseParen), hasBody: false, bodyLine: null };
+    }
+
+    const open = this.events[openIndex];
+    for (let e = openIndex + 1; e < this.events.length; e++) {
+      const event = this.events[e];
+      if (event.type === '}' && event.braceDepth === baseDepth) {
+        return { endLine: event.line, hasBody: true, bodyLine: open.line };
+      }
+    }
+
+    return { endLine: this.lines.length - 1, hasBody: true, bodyLine: open.line };
+  }
+
+  /**
+   * Whether the signature started on an earlier line is still open at lineIndex
+   * (the previous line ended with a continuation character).
+   * @param {number} lineIndex
+   * @returns {boolean}
+   */
+  signatureContinues(lineIndex) {
+    const previous = (this.lines[lineIndex - 1] || '').trim();
+    const current = (this.lines[lineIndex] || '').trim();
+    return /[,(|&+\-=]$/.test(previous) || /^[|&.?:]/.test(current);
+  }
+
+  /**
+   * Last line of a body-less declaration (parens balanced again)
+   * @param {number} startLine
+   * @param {number} baseParen
+   * @returns {number}
+   */
+  signatureEnd(startLine, baseParen) {
+    let line = startLine;
+    while (line + 1 < this.lines.length &&
+           (this.parenAtLine[line + 1] > baseParen || this.signatureContinues(line + 1))) {
+      line++;
+    }
+    return line;
+  }
+
+  /**
+   * Text of a declaration up to (not including) its body
+   * @param {number} startLine - 0-based
+   * @param {number|null} bodyLine - 0-based line holding the opening brace
+   * @param {number} endLine - 0-based fallback end
+   * @returns {string}
+   */
+  signatureText(startLine, bodyLine, endLine) {
+    const last = bodyLine === null ? endLine : bodyLine;
+    const text = this.lines.slice(startLine, last + 1).join(' ');
+    const cut = bodyLine === null ? text : text.slice(0, this.firstBodyBrace(text));
+    return collapseWhitespace(cut.replace(/;\s*$/, ''));
+  }
+
+  /**
+   * Index of the brace opening the body: the first '{' at paren depth 0
+   * @param {string} text
+   * @returns {number}
+   */
+  firstBodyBrace(text) {
+    let paren = 0;
+    for (let i = 0; i < text.length; i++) {
+      const ch = text[i];
+      if (ch === '(' || ch === '[') paren++;
+      else if (ch === ')' || ch === ']') paren--;
+      else if (ch === '{' && paren === 0) return i;
+    }
+    return text.length;
+  }
+}
+
+/**
+ * Find the end of an indentation-delimited block (Python)
+ * @param {Array<string>} lines
+ * @param {number} startLine - 0-based line of the header
+ * @returns {number} 0-based last line of the block
+ */
+export function findIndentBlockEnd(lines, startLine) {
+  const baseIndent = indentOf(lines[startLine]);
+  const insideString = tripleQuotedLines(lines);
+  const headerEnd = findHeaderEnd(lines, startLine);
+
+  let last = headerEnd;
+  for (let i = headerEnd + 1; i < lines.length; i++) {
+    const line = lines[i];
+    if (insideString[i]) {
+      last = i;
+      continue;
+    }
+    if (line.trim() === '') continue;
+    if (indentOf(line) <= baseIndent) break;
+    last = i;
+  }
+
+  return last;
+}
+
+/**
+ * Last line of a header whose brackets may span several lines
+ * @param {Array<string>} lines
+ * @param {number} startLine - 0-based
+ * @returns {number} 0-based line where brackets are balanced again
+ */
+export function findHeaderEnd(lines, startLine) {
+  let depth = 0;
+
+  for (let i = startLine; i < lines.length; i++) {
+    const code = lines[i].replace(/(["'])(?:\\.|(?!\1).)*\1/g, '""').replace(/#.*$/, '');
+    for (const ch of code) {
+      if ('([{'.includes(ch)) depth++;
+      else if (')]}'.includes(ch)) depth--;
+    }
+    if (depth <= 0) return i;
+  }
+
+  return lines.length - 1;
+}
+
+/**
+ * Declaration text up to the opening brace of its body
+ * @param {Array<string>} lines
+ * @param {number} startLine - 0-based
+ * @returns {string} Header joined onto one line
+ */
+export function declarationHeader(lines, startLine) {
+  const parts = [];
+
+  for (let i = startLine; i < lines.length; i++) {
+    const brace = lines[i].indexOf('{');
+    parts.push((brace === -1 ? lines[i] : lines[i].slice(0, brace)).trim());
+    if (brace !== -1 || /;\s*$/.test(lines[i])) break;
+  }
+
+  return parts.join(' ');
+}
+
+/**
+ * Simple names listed after a keyword in a type header
+ * (`class A<T> extends B implements x.C<T>, D` with 'implements' -> C, D)
+ * @param {string} header - Declaration up to its body
+ * @param {string} keyword - 'implements' or 'extends'
+ * @returns {Array<string>|null} Names, or null without such a clause
+ */
+export function supertypeNames(header, keyword) {
+  let plain = header;
+  while (/<[^<>]*>/.test(plain)) {
+    plain = plain.replace(/<[^<>]*>/g, '');
+  }
+
+  const clause = plain.match(new RegExp(`\\b${keyword}\\s+(.+?)(?=\\s+(?:implements|extends|permits)\\b|$)`));
+  const names = (clause ? clause[1].split(',') : [])
+    .map(part => part.trim().split('.').pop())
+    .filter(name => /^[A-Za-z_$][\w$]*$/.test(name));
+
+  return names.length > 0 ? names : null;
+}
+
+/**
+ * Mark lines that begin inside a triple-quoted string
+ * @param {Array<string>} lines
+ * @returns {Array<boolean>}
+ */
+export function tripleQuotedLines(lines) {
+  const result = new Array(lines.length).fill(false);
+  let open = null;
+
+  for (let i = 0; i < lines.length; i++) {
+    result[i] = open !== null;
+    const matches = lines[i].match(/"""|'''/g) || [];
+    for (const quote of matches) {
+      if (open === null) open = quote;
+      else if (open === quote) open = null;
+    }
+  }
+
+  return result;
+}
+
+/**
+ * Collect the comment block immediately above a declaration
+ * @param {Array<string>} lines
+ * @param {number} declLine - 0-based line of the declaration (after attributes)
+ * @param {object} options
+ * @param {Array<string>} options.linePrefixes - Line comment markers considered doc
+ * @param {boolean} options.blockDocs - Accept /** ... *\/ blocks
+ * @param {RegExp} options.skip - Lines to skip between doc and declaration (attributes)
+ * @returns {string|null}
+ */
+export function collectLeadingDoc(lines, declLine, options = {}) {
+  const { linePrefixes = ['//'], blockDocs = true, skip = null } = options;
+  let i = declLine - 1;
+
+  while (i >= 0 && skip && skip.test(lines[i].trim())) {
+    i--;
+  }
+  if (i < 0) return null;
+
+  const trimmed = lines[i].trim();
+
+  if (blockDocs && trimmed.endsWith('*/')) {
+    const block = [];
+    while (i >= 0) {
+      block.unshift(lines[i].trim());
+      if (lines[i].includes('/*')) break;
+      i--;
+    }
+    if (!block[0] || !block[0].startsWith('/**')) return null;
+    return cleanBlockComment(block);
+  }
+
+  const doc = [];
+  while (i >= 0) {
+    const current = lines[i].trim();
+    const prefix = linePrefixes.find(p => current.startsWith(p));
+    if (!prefix) break;
+    doc.unshift(current.slice(prefix.length).replace(/^ /, ''));
+    i--;
+  }
+
+  return doc.length > 0 ? doc.join('\n').trim() || null : null;
+}
+
+/**
+ * Strip comment markers from a /** *\/ block
+ * @param {Array<string>} block - Trimmed lines
+ * @returns {string|null}
+ */
+export function cleanBlockComment(block) {
+  const text = block
+    .map(line => line
+      .replace(/^\/\*\*+/, '')
+      .replace(/\*+\/$/, '')
+      .replace(/^\*\s?/, '')
+      .trimEnd())
+    .join('\n')
+    .trim();
+  return text || null;
+}
+
+/**
+ * Extract a Python docstring starting at the given line
+ * @param {Array<string>} lines
+ * @param {number} lineIndex - 0-based line expected to open the docstring
+ * @returns {string|null}
+ */
+export function extractDocstring(lines, lineIndex) {
+  let i = lineIndex;
+  while (i < lines.length && lines[i].trim() === '') i++;
+  if (i >= lines.length) return null;
+
+  const first = lines[i].trim();
+  const open = first.match(/^[rRuUbB]?("""|''')/);
+  if (!open) {
+    const single = first.match(/^[rRuU]?(["'])(.*)\1$/);
+    return single ? single[2].trim() || null : null;
+  }
+
+  const quote = open[1];
+  const rest = first.slice(open[0].length);
+  if (rest.includes(quote)) {
+    return rest.slice(0, rest.indexOf(quote)).trim() || null;
+  }
+
+  const body = [rest];
+  for (let j = i + 1; j < lines.length; j++) {
+    const idx = lines[j].indexOf(quote);
+    if (idx !== -1) {
+      body.push(lines[j].slice(0, idx));
+      break;
+    }
+    body.push(lines[j]);
+  }
+
+  return dedent(body).trim() || null;
+}
+
+/**
+ * Remove common leading indentation
+ * @param {Array<string>} lines
+ * @returns {string}
+ */
+export function dedent(lines) {
+  const indents = lines.slice(1).filter(l => l.trim()).map(indentOf);
+  const common = indents.length > 0 ? Math.min(...indents) : 0;
+  return [lines[0].trim(), ...lines.slice(1).map(l => l.slice(common))].join('\n');
+}
+
+/**
+ * Width of leading whitespace (tabs count as 4)
+ * @param {string} line
+ * @returns {number}
+ */
+export function indentOf(line) {
+  const match = line.match(/^[ \t]*/)[0];
+  return match.replace(/\t/g, '    ').length;
+}
+
+/**
+ * Collapse runs of whitespace into single spaces
+ * @param {string} text
+ * @returns {string}
+ */
+export function collapseWhitespace(text) {
+  return text.replace(/\s+/g, ' ').replace(/\(\s+/g, '(').replace(/\s+\)/g, ')').trim();
+}
+
+/**
+ * 1-based line number of a character offset
+ * @param {string} content
+ * @param {number} index
+ * @returns {number}
+ */
+export function lineNumberAt(content, index) {
+  let line = 1;
+  for (let i = 0; i < index && i < content.length; i++) {
+    if (content.charCodeAt(i) === 10) line++;
+  }
+  return line;
+}
+
+export default SourceScanner;
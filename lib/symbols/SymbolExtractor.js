@@ -0,0 +1,216 @@
+/**
+ * SymbolExtractor - Multi-language symbol extraction entry point
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Responsibilities:
+ * - Route files to language plugins by extension, or by the language
+ *   LanguageDetector finds for build files and extension-less scripts
+ * - Prefer the tree-sitter backend when runtime and grammar are installed
+ * - Fall back to each plugin's heuristic parser otherwise
+ * - Include custom extractor plugins registered for the process (v3.4.0)
+ * - Emit one symbol model regardless of language or backend
+ */
+
+import path from 'path';
+import { TreeSitterBackend } from './TreeSitterBackend.js';
+import { sortSymbols } from './SymbolModel.js';
+import { PythonPlugin } from '../languages/PythonPlugin.js';
+import { TypeScriptPlugin } from '../languages/TypeScriptPlugin.js';
+import { RustPlugin } from '../languages/RustPlugin.js';
+import { JavaPlugin } from '../languages/JavaPlugin.js';
+import { GoPlugin } from '../languages/GoPlugin.js';
+import { ProtobufPlugin } from '../languages/ProtobufPlugin.js';
+import { OpenAPIPlugin } from '../languages/OpenAPIPlugin.js';
+import { NotebookPlugin } from '../languages/NotebookPlugin.js';
+import { MarkdownPlugin } from '../languages/MarkdownPlugin.js';
+import LanguageDetector, { DETECTED_LANGUAGES } from '../languages/LanguageDetector.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('SymbolExtractor');
+
+export const BUILTIN_LANGUAGE_PLUGINS = [
+  PythonPlugin,
+  TypeScriptPlugin,
+  RustPlugin,
+  JavaPlugin,
+  GoPlugin,
+  ProtobufPlugin,
+  OpenAPIPlugin,
+  NotebookPlugin,
+  MarkdownPlugin
+];
+
+// Custom extractors (ExtractorPlugin) added to every SymbolExtractor
+const registeredPlugins = [];
+
+/**
+ * Register a plugin with every SymbolExtractor created afterwards
+ * @param {LanguagePlugin} plugin
+ */
+export function registerExtractorPlugin(plugin) {
+  registeredPlugins.push(plugin);
+}
+
+/**
+ * @returns {Array<LanguagePlugin>} Plugins added with registerExtractorPlugin()
+ */
+export function getRegisteredPlugins() {
+  return [...registeredPlugins];
+}
+
+export class SymbolExtractor {
+  constructor(options = {}) {
+    this.options = {
+      backend: 'auto', // auto | tree-sitter | heuristic
+      builtins: true,
+      registered: true, // Include registerExtractorPlugin() plugins
+      ...options
+    };
+
+    this.plugins = [];
+    this.byExtension = new Map();
+    this.treeSitter = new TreeSitterBackend(this.options.treeSitter);
+    this.initialized = false;
+
+    if (this.options.builtins) {
+      for (const PluginClass of BUILTIN_LANGUAGE_PLUGINS) {
+        this.register(new PluginClass());
+      }
+    }
+    if (this.options.registered) {
+      for (const plugin of registeredPlugins) {
+        this.register(plugin);
+      }
+    }
+  }
+
+  /**
+   * Register a language plugin (later registrations win per extension)
+   * @param {LanguagePlugin} plugin
+   * @returns {SymbolExtractor}
+   */
+  register(plugin) {
+    this.plugins.push(plugin);
+    for (const ext of plugin.extensions) {
+      this.byExtension.set(ext.toLowerCase(), plugin);
+    }
+    return this;
+  }
+
+  /**
+   * Load the tree-sitter runtime and grammars for registered plugins
+   * @returns {Promise<SymbolExtractor>}
+   */
+  async initialize() {
+    if (this.initialized) return this;
+    this.initialized = true;
+
+    if (this.options.backend === 'heuristic') {
+      return this;
+    }
+
+    const available = await this.treeSitter.initialize();
+
+    if (!available) {
+      if (this.options.backend === 'tree-sitter') {
+        throw new Error('tree-sitter backend requested but the "tree-sitter" package is not installed');
+      }
+      logger.debug('tree-sitter not installed, using heuristic parsers');
+      return this;
+    }
+
+    for (const plugin of this.plugins) {
+      for (const ext of plugin.extensions) {
+        await this.treeSitter.loadGrammar(plugin.getTreeSitterGrammar(`file${ext}`));
+      }
+    }
+
+    return this;
+  }
+
+  /**
+   * Get the plugin handling a file
+   * @param {string} filePath
+   * @param {string|null} [content] - Routes extension-less scripts by their shebang
+   * @returns {LanguagePlugin|null}
+   */
+  getPlugin(filePath, content = null) {
+    return this.byExtension.get(path.extname(filePath || '').toLowerCase()) ||
+      this.byExtension.get(LanguageDetector.extensionOf(filePath, content)) || null;
+  }
+
+  /**
+   * Check if symbols can be extracted from a file
+   * @param {string} filePath
+   * @param {string|null} [content]
+   * @returns {boolean}
+   */
+  supports(filePath, content = null) {
+    return this.getPlugin(filePath, content) !== null;
+  }
+
+  /**
+   * Check if a language found by LanguageDetector has a plugin
+   * @param {string|null} language - Key of DETECTED_LANGUAGES
+   * @returns {boolean}
+   */
+  supportsLanguage(language) {
+    return Boolean(language) && this.byExtension.has(DETECTED_LANGUAGES[language]);
+  }
+
+  /**
+   * @returns {Array<string>} Extensions with a registered plugin
+   */
+  getSupportedExtensions() {
+    return [...this.byExtension.keys()].sort();
+  }
+
+  /**
+   * Backend that would be used for a file
+   * @param {string} filePath
+   * @param {string|null} [content]
+   * @returns {string|null} tree-sitter, heuristic, or plugin:NAME for custom extractors
+   */
+  getBackend(filePath, content = null) {
+    const plugin = this.getPlugin(filePath, content);
+    if (!plugin) return null;
+    if (plugin.backend) return plugin.backend;
+
+    if (this.options.backend !== 'heuristic' &&
+        this.treeSitter.hasGrammar(plugin.getTreeSitterGrammar(filePath))) {
+      return 'tree-sitter';
+    }
+    return 'heuristic';
+  }
+
+  /**
+   * Extract symbols from file content
+   * @param {string} content - File content
+   * @param {string} filePath - Path used for language routing and recorded on symbols
+   * @returns {Array<Symbol>}
+   */
+  extract(content, filePath) {
+    const plugin = this.getPlugin(filePath, content);
+    if (!plugin || !plugin.validate(content)) {
+      return [];
+    }
+
+    let symbols = null;
+
+    if (this.getBackend(filePath, content) === 'tree-sitter') {
+      try {
+        symbols = this.treeSitter.extract(content, filePath, plugin);
+      } catch (error) {
+        logger.warn(`tree-sitter failed for ${filePath}, falling back: ${error.message}`);
+      }
+    }
+
+    if (!symbols) {
+      symbols = plugin.extractSymbols(content, filePath);
+    }
+
+    return sortSymbols(symbols.filter(symbol => symbol.name));
+  }
+}
+
+export default SymbolExtractor;
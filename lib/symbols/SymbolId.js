@@ -0,0 +1,141 @@
+/**
+ * SymbolId - Stable symbol references
+ * v3.4.0 - Rename-safe symbol references
+ *
+ * Responsibilities:
+ * - Build symbol IDs from package path, kind, qualified name and a hash of
+ *   the signature with the symbol's own name left out:
+ *   "pkg/calc#method:Calculator.Add@3f2a91c0"
+ * - Resolve IDs against a dependency graph
+ * - Reconcile IDs of moved or renamed symbols by kind and signature hash,
+ *   remembering the remap in the content cache
+ */
+
+import crypto from 'crypto';
+import { SymbolKind } from './SymbolModel.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('SymbolId');
+
+const ID_PATTERN = /^(.+?)#([a-z]+):(.+)@([0-9a-f]{8})$/;
+
+/**
+ * Hash of a symbol's kind and signature without its name, so that
+ * `func Add(a, b int) int` and `func Sum(a, b int) int` hash alike
+ * @param {Object} symbol
+ * @returns {string} 8 hex characters
+ */
+export function signatureHash(symbol) {
+  const name = symbol.name.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+  const signature = (symbol.signature || '')
+    .replace(new RegExp(`(^|[^\\w$])${name}(?![\\w$])`, 'g'), '$1∅')
+    .replace(/\s+/g, ' ')
+    .trim();
+  return crypto.createHash('sha256').update(`${symbol.kind}\n${signature}`).digest('hex').slice(0, 8);
+}
+
+/**
+ * ID of a symbol
+ * @param {Object} symbol
+ * @param {string} packageId - Package the symbol's file belongs to
+ * @returns {string}
+ */
+export function symbolId(symbol, packageId) {
+  return `${packageId}#${symbol.kind}:${symbol.qualifiedName}@${signatureHash(symbol)}`;
+}
+
+/**
+ * @param {string} id
+ * @returns {{package: string, kind: string, qualifiedName: string, hash: string}|null}
+ */
+export function parseSymbolId(id) {
+  const match = ID_PATTERN.exec(String(id));
+  if (!match || !Object.values(SymbolKind).includes(match[2])) return null;
+  return { package: match[1], kind: match[2], qualifiedName: match[3], hash: match[4] };
+}
+
+/**
+ * @param {string} spec
+ * @returns {boolean}
+ */
+export function isSymbolId(spec) {
+  return parseSymbolId(spec) !== null;
+}
+
+export class SymbolIdIndex {
+  /**
+   * @param {DependencyGraph} graph - Built graph
+   * @param {Object} options
+   */
+  constructor(graph, options = {}) {
+    this.graph = graph;
+    this.options = {
+      cache: graph.options?.cache || null, // ContentCache remembering remapped IDs
+      ...options
+    };
+
+    this.byId = new Map();
+    for (const file of graph.files.values()) {
+      for (const symbol of file.symbols) {
+        if (symbol.kind === SymbolKind.MODULE) continue;
+        this.byId.set(symbolId(symbol, file.package), { symbol, package: file.package });
+      }
+    }
+    this.remapped = [];
+  }
+
+  /**
+   * Current ID of a symbol in the graph
+   * @param {Object} symbol
+   * @returns {string}
+   */
+  idOf(symbol) {
+    return symbolId(symbol, this.graph.getFile(symbol.file).package);
+  }
+
+  /**
+   * Resolve an ID, reconciling it when the symbol moved or was renamed
+   * Candidates share the kind and signature hash; a unique one with the same
+   * name (moved), else in the same package (renamed), else anywhere wins.
+   * @param {string} id
+   * @returns {{id: string, symbol: Object, remappedFrom: string|null}|null}
+   */
+  resolve(id) {
+    const parsed = parseSymbolId(id);
+    if (!parsed) return null;
+
+    if (this.byId.has(id)) {
+      return { id, symbol: this.byId.get(id).symbol, remappedFrom: null };
+    }
+
+    const cached = this.options.cache?.symbolId(id);
+    if (cached && this.byId.has(cached)) {
+      return this.remap(id, cached);
+    }
+
+    const candidates = [...this.byId].filter(([candidate, { symbol }]) =>
+      symbol.kind === parsed.kind && candidate.endsWith(`@${parsed.hash}`));
+    const unique = list => (list.length === 1 ? list[0][0] : null);
+    const match = unique(candidates.filter(([, { symbol }]) => symbol.qualifiedName === parsed.qualifiedName)) ||
+      unique(candidates.filter(([, entry]) => entry.package === parsed.package)) ||
+      unique(candidates);
+
+    if (!match) {
+      logger.debug(`Symbol ${id} not found (${candidates.length} candidates with its signature)`);
+      return null;
+    }
+    return this.remap(id, match);
+  }
+
+  /**
+   * @private
+   */
+  remap(id, currentId) {
+    this.options.cache?.setSymbolId(id, currentId);
+    this.remapped.push({ from: id, to: currentId });
+    logger.debug(`Remapped symbol ${id} -> ${currentId}`);
+    return { id: currentId, symbol: this.byId.get(currentId).symbol, remappedFrom: id };
+  }
+}
+
+export default SymbolIdIndex;
@@ -0,0 +1,415 @@
+/**
+ * SymbolIndex - Queryable project symbol index
+ * v3.4.0 - Symbol index and query CLI (ctxman symbols)
+ *
+ * Responsibilities:
+ * - Index the symbols of project files with their package and symbol ID
+ * - Keep the index in memory, or in a SQLite database other tools can read
+ * - Re-extract only files whose size, modification time or backend changed
+ * - Answer kind, package, name and export queries
+ *
+ * SQLite uses the built-in node:sqlite module (Node.js 22.5+) or, when that
+ * is missing, better-sqlite3 if installed.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { SymbolKind } from './SymbolModel.js';
+import { symbolId } from './SymbolId.js';
+import { nativeFileSystem } from '../core/FileSystem.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('SymbolIndex');
+
+export const SYMBOL_INDEX_FILE = path.join('.ctxman', 'cache', 'symbols.db');
+
+// Bump when the tables change; older databases are rebuilt
+const SCHEMA_VERSION = 1;
+
+const SCHEMA = `
+  CREATE TABLE IF NOT EXISTS files (
+    path TEXT PRIMARY KEY,
+    package TEXT NOT NULL,
+    language TEXT NOT NULL,
+    stamp TEXT NOT NULL
+  );
+  CREATE TABLE IF NOT EXISTS symbols (
+    id TEXT NOT NULL,
+    file TEXT NOT NULL,
+    package TEXT NOT NULL,
+    name TEXT NOT NULL,
+    qualified_name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    language TEXT NOT NULL,
+    start_line INTEGER NOT NULL,
+    end_line INTEGER NOT NULL,
+    signature TEXT NOT NULL,
+    doc TEXT,
+    parent TEXT,
+    exported INTEGER NOT NULL
+  );
+  CREATE INDEX IF NOT EXISTS symbols_file ON symbols (file);
+  CREATE INDEX IF NOT EXISTS symbols_kind ON symbols (kind);
+  CREATE INDEX IF NOT EXISTS symbols_package ON symbols (package);
+  CREATE INDEX IF NOT EXISTS symbols_name ON symbols (name);
+`;
+
+const COLUMNS = 'id, file, package, name, qualified_name AS qualifiedName, kind, language, ' +
+  'start_line AS startLine, end_line AS endLine, signature, doc, parent, exported';
+
+export class SymbolIndex {
+  /**
+   * @param {MemorySymbolStore|SqliteSymbolStore} store
+   */
+  constructor(store = new MemorySymbolStore()) {
+    this.store = store;
+  }
+
+  /**
+   * In-memory index, or one stored in a SQLite database
+   * @param {Object} options - { database: file path, or null for memory }
+   * @returns {Promise<SymbolIndex>}
+   */
+  static async open(options = {}) {
+    if (!options.database) return new SymbolIndex();
+    return new SymbolIndex(await SqliteSymbolStore.open(options.database));
+  }
+
+  /**
+   * Bring the index up to date with the project's files
+   * Files are re-extracted when their size, modification time or extraction
+   * backend changed; files no longer listed are dropped.
+   * @param {Array<{path: string, relativePath: string}>} files - Every file to index
+   * @param {SymbolExtractor} extractor - Initialized extractor
+   * @returns {{indexed: number, unchanged: number, removed: number}}
+   */
+  refresh(files, extractor) {
+    const stamps = this.store.stamps();
+    const seen = new Set();
+    const result = { indexed: 0, unchanged: 0, removed: 0 };
+
+    this.store.transaction(() => {
+      for (const file of files) {
+        const relativePath = file.relativePath.split(path.sep).join('/');
+        if (!extractor.supports(relativePath)) continue;
+
+        let stat;
+        try {
+          stat = fs.statSync(file.path);
+        } catch (error) {
+          logger.debug(`Skipping ${relativePath}: ${error.message}`);
+          continue;
+        }
+        seen.add(relativePath);
+
+        const stamp = `${stat.size}:${Math.trunc(stat.mtimeMs)}:${extractor.getBackend(relativePath)}`;
+        if (stamps.get(relativePath) === stamp) {
+          result.unchanged++;
+          continue;
+        }
+
+        const plugin = extractor.getPlugin(relativePath);
+        const packageId = plugin.getPackageId(relativePath);
+        const symbols = extractor.extract(nativeFileSystem.readText(file.path), relativePath)
+          .filter(symbol => symbol.kind !== SymbolKind.MODULE)
+          .map(symbol => toRow(symbol, relativePath, packageId));
+        this.store.put({ path: relativePath, package: packageId, language: plugin.getLanguageId(relativePath), stamp }, symbols);
+        result.indexed++;
+      }
+
+      for (const stale of stamps.keys()) {
+        if (!seen.has(stale)) {
+          this.store.remove(stale);
+          result.removed++;
+        }
+      }
+    });
+
+    return result;
+  }
+
+  /**
+   * Symbols matching every given filter, by file and line
+   * @param {Object} filters
+   * @param {Array<string>} [filters.kinds] - SymbolKind values
+   * @param {Array<string>} [filters.packages] - Package IDs; `dir/...` includes
+   *   everything under dir, `...` everything
+   * @param {string} [filters.name] - Name or qualified name; * and ? are wildcards
+   * @param {boolean} [filters.exported] - Only exported symbols
+   * @returns {Array<Object>} { id, file, package, name, qualifiedName, kind, language,
+   *   startLine, endLine, signature, doc, parent, exported }
+   */
+  query(filters = {}) {
+    return this.store.query({
+      kinds: filters.kinds || [],
+      packages: (filters.packages || []).map(parsePackagePattern),
+      name: filters.name || null,
+      exported: Boolean(filters.exported)
+    });
+  }
+
+  /**
+   * @returns {{files: number, symbols: number}}
+   */
+  count() {
+    return this.store.count();
+  }
+
+  close() {
+    this.store.close();
+  }
+
+  /**
+   * Symbol listing: kind, qualified name, location and symbol ID
+   * @param {Array<Object>} symbols - From query()
+   * @returns {string}
+   */
+  static formatSymbols(symbols) {
+    const lines = ['', `📇 SYMBOLS (${symbols.length})`, '='.repeat(80)];
+    for (const symbol of symbols) {
+      lines.push(`${symbol.kind.padEnd(10)} ${symbol.qualifiedName}  ${symbol.file}:${symbol.startLine}`);
+      lines.push(`${' '.repeat(11)}${symbol.id}`);
+    }
+    if (symbols.length === 0) {
+      lines.push('No matching symbols');
+    }
+    return lines.join('\n');
+  }
+}
+
+export class MemorySymbolStore {
+  constructor() {
+    this.files = new Map(); // path -> { file, symbols }
+  }
+
+  stamps() {
+    return new Map([...this.files].map(([filePath, entry]) => [filePath, entry.file.stamp]));
+  }
+
+  put(file, symbols) {
+    this.files.set(file.path, { file, symbols });
+  }
+
+  remove(filePath) {
+    this.files.delete(filePath);
+  }
+
+  transaction(fn) {
+    fn();
+  }
+
+  query(filters) {
+    const name = filters.name ? globMatcher(filters.name) : null;
+    return [...this.files.values()]
+      .flatMap(entry => entry.symbols)
+      .filter(symbol => (filters.kinds.length === 0 || filters.kinds.includes(symbol.kind)) &&
+        (filters.packages.length === 0 || filters.packages.some(pattern => matchesPackage(symbol.package, pattern))) &&
+        (!name || name(symbol.name) || name(symbol.qualifiedName)) &&
+        (!filters.exported || symbol.exported))
+      .sort(compareSymbols);
+  }
+
+  count() {
+    const entries = [...this.files.values()];
+    return { files: entries.length, symbols: entries.reduce((sum, entry) => sum + entry.symbols.length, 0) };
+  }
+
+  close() {}
+}
+
+export class SqliteSymbolStore {
+  /**
+   * @param {Object} db - node:sqlite DatabaseSync or better-sqlite3 Database
+   */
+  constructor(db) {
+    this.db = db;
+
+    if (this.db.prepare('PRAGMA user_version').get().user_version !== SCHEMA_VERSION) {
+      this.db.exec(`DROP TABLE IF EXISTS symbols; DROP TABLE IF EXISTS files; PRAGMA user_version = ${SCHEMA_VERSION};`);
+    }
+    this.db.exec(SCHEMA);
+
+    this.insertFile = this.db.prepare('INSERT OR REPLACE INTO files (path, package, language, stamp) VALUES (?, ?, ?, ?)');
+    this.insertSymbol = this.db.prepare('INSERT INTO symbols (id, file, package, name, qualified_name, kind, language, ' +
+      'start_line, end_line, signature, doc, parent, exported) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)');
+    this.deleteFile = this.db.prepare('DELETE FROM files WHERE path = ?');
+    this.deleteSymbols = this.db.prepare('DELETE FROM symbols WHERE file = ?');
+  }
+
+  /**
+   * Open or create a database file
+   * @param {string} filePath
+   * @returns {Promise<SqliteSymbolStore>}
+   */
+  static async open(filePath) {
+    const Database = await loadDriver();
+    fs.mkdirSync(path.dirname(filePath), { recursive: true });
+    return new SqliteSymbolStore(new Database(filePath));
+  }
+
+  stamps() {
+    return new Map(this.db.prepare('SELECT path, stamp FROM files').all().map(row => [row.path, row.stamp]));
+  }
+
+  put(file, symbols) {
+    this.deleteSymbols.run(file.path);
+    this.insertFile.run(file.path, file.package, file.language, file.stamp);
+    for (const symbol of symbols) {
+      this.insertSymbol.run(symbol.id, symbol.file, symbol.package, symbol.name, symbol.qualifiedName, symbol.kind,
+        symbol.language, symbol.startLine, symbol.endLine, symbol.signature, symbol.doc, symbol.parent, symbol.exported ? 1 : 0);
+    }
+  }
+
+  remove(filePath) {
+    this.deleteSymbols.run(filePath);
+    this.deleteFile.run(filePath);
+  }
+
+  transaction(fn) {
+    this.db.exec('BEGIN');
+    try {
+      fn();
+      this.db.exec('COMMIT');
+    } catch (error) {
+      this.db.exec('ROLLBACK');
+      throw error;
+    }
+  }
+
+  query(filters) {
+    const where = [];
+    const params = [];
+
+    if (filters.kinds.length > 0) {
+      where.push(`kind IN (${filters.kinds.map(() => '?').join(', ')})`);
+      params.push(...filters.kinds);
+    }
+    if (filters.packages.length > 0) {
+      const clauses = [];
+      for (const pattern of filters.packages) {
+        if (pattern.prefix === null) {
+          clauses.push('1');
+        } else if (pattern.recursive) {
+          clauses.push('package = ? OR substr(package, 1, ?) = ?');
+          params.push(pattern.prefix, pattern.prefix.length + 1, `${pattern.prefix}/`);
+        } else {
+          clauses.push('package = ?');
+          params.push(pattern.prefix);
+        }
+      }
+      where.push(`(${clauses.map(clause => `(${clause})`).join(' OR ')})`);
+    }
+    if (filters.name) {
+      // GLOB is case-sensitive with * and ? wildcards, as globMatcher()
+      where.push('(name GLOB ? OR qualified_name GLOB ?)');
+      params.push(filters.name, filters.name);
+    }
+    if (filters.exported) {
+      where.push('exported = 1');
+    }
+
+    const sql = `SELECT ${COLUMNS} FROM symbols${where.length > 0 ? ` WHERE ${where.join(' AND ')}` : ''} ` +
+      'ORDER BY file, start_line, qualified_name';
+    return this.db.prepare(sql).all(...params).map(row => ({ ...row, exported: row.exported === 1 }));
+  }
+
+  count() {
+    return {
+      files: this.db.prepare('SELECT COUNT(*) AS count FROM files').get().count,
+      symbols: this.db.prepare('SELECT COUNT(*) AS count FROM symbols').get().count
+    };
+  }
+
+  close() {
+    this.db.close();
+  }
+}
+
+/**
+ * Database class of the first available SQLite driver
+ * @private
+ */
+async function loadDriver() {
+  try {
+    const { DatabaseSync } = await import('node:sqlite');
+    return DatabaseSync;
+  } catch (error) {
+    logger.debug(`node:sqlite not available: ${error.message}`);
+  }
+  try {
+    const module = await import('better-sqlite3');
+    return module.default || module;
+  } catch (error) {
+    logger.debug(`better-sqlite3 not available: ${error.message}`);
+  }
+  throw new Error('The SQLite symbol index needs Node.js 22.5+ (node:sqlite) or better-sqlite3 (npm install better-sqlite3)');
+}
+
+/**
+ * Stored form of a symbol
+ * @private
+ */
+function toRow(symbol, file, packageId) {
+  return {
+    id: symbolId(symbol, packageId),
+    file,
+    package: packageId,
+    name: symbol.name,
+    qualifiedName: symbol.qualifiedName,
+    kind: symbol.kind,
+    language: symbol.language,
+    startLine: symbol.startLine,
+    endLine: symbol.endLine,
+    signature: symbol.signature || '',
+    doc: symbol.doc || null,
+    parent: symbol.parent || null,
+    exported: Boolean(symbol.exported)
+  };
+}
+
+/**
+ * ./internal/... -> { prefix: 'internal', recursive: true }; ... -> { prefix: null }
+ * @private
+ */
+function parsePackagePattern(pattern) {
+  let spec = pattern.trim().replace(/^\.\/+/, '').replace(/\/+$/, '');
+  const recursive = spec === '...' || spec.endsWith('/...');
+  if (recursive) spec = spec.replace(/\/?\.\.\.$/, '');
+  if (spec === '' || spec === '.') return { prefix: null, recursive: true };
+  return { prefix: spec, recursive };
+}
+
+/**
+ * @private
+ */
+function matchesPackage(packageId, pattern) {
+  if (pattern.prefix === null) return true;
+  return packageId === pattern.prefix || (pattern.recursive && packageId.startsWith(`${pattern.prefix}/`));
+}
+
+/**
+ * Case-sensitive glob with * and ? wildcards, like SQLite's GLOB
+ * @private
+ */
+function globMatcher(glob) {
+  const source = glob.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.');
+  const regex = new RegExp(`^${source}$`, 's');
+  return value => regex.test(value);
+}
+
+/**
+ * Same order as the SQLite query: binary comparison of file, then line, then name
+ * @private
+ */
+function compareSymbols(a, b) {
+  return compareText(a.file, b.file) || a.startLine - b.startLine || compareText(a.qualifiedName, b.qualifiedName);
+}
+
+/**
+ * @private
+ */
+function compareText(a, b) {
+  return a < b ? -1 : a > b ? 1 : 0;
+}
+
+export default SymbolIndex;
@@ -0,0 +1,85 @@
+/**
+ * SymbolModel - Language-independent symbol representation
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Every parser backend (tree-sitter or heuristic) emits symbols in this
+ * shape so downstream consumers never need to know which language or
+ * backend produced them.
+ */
+
+import { compareSymbols } from '../utils/ordering.js';
+
+export const SymbolKind = Object.freeze({
+  MODULE: 'module',
+  CLASS: 'class',
+  STRUCT: 'struct',
+  INTERFACE: 'interface',
+  TRAIT: 'trait',
+  ENUM: 'enum',
+  TYPE: 'type',
+  FUNCTION: 'function',
+  METHOD: 'method',
+  CONSTANT: 'constant',
+  VARIABLE: 'variable'
+});
+
+/**
+ * Kinds that can contain other symbols
+ */
+export const CONTAINER_KINDS = new Set([
+  SymbolKind.CLASS,
+  SymbolKind.STRUCT,
+  SymbolKind.INTERFACE,
+  SymbolKind.TRAIT,
+  SymbolKind.ENUM
+]);
+
+/**
+ * Create a normalized symbol
+ * @param {object} fields - Symbol fields
+ * @returns {object} Symbol
+ */
+export function createSymbol(fields) {
+  const parent = fields.parent || null;
+  const name = fields.name;
+
+  return {
+    name,
+    qualifiedName: fields.qualifiedName || (parent ? `${parent}.${name}` : name),
+    kind: fields.kind || SymbolKind.FUNCTION,
+    language: fields.language || 'unknown',
+    file: fields.file || null,
+    startLine: fields.startLine || 1,
+    endLine: fields.endLine || fields.startLine || 1,
+    signature: fields.signature || '',
+    doc: fields.doc || null,
+    parent,
+    exported: Boolean(fields.exported),
+    ...(fields.implements ? { implements: fields.implements } : {})
+  };
+}
+
+/**
+ * Sort symbols by position in file, then by kind and name, so backends
+ * listing symbols in different orders agree
+ * @param {Array<object>} symbols
+ * @returns {Array<object>}
+ */
+export function sortSymbols(symbols) {
+  return symbols.sort(compareSymbols);
+}
+
+/**
+ * Convert a symbol to the legacy method shape ({name, line, file})
+ * @param {object} symbol
+ * @returns {object}
+ */
+export function toMethod(symbol) {
+  return {
+    name: symbol.parent ? `${symbol.parent}.${symbol.name}` : symbol.name,
+    line: symbol.startLine,
+    file: symbol.file
+  };
+}
+
+export default { SymbolKind, CONTAINER_KINDS, createSymbol, sortSymbols, toMethod };
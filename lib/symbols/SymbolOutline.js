@@ -0,0 +1,161 @@
+/**
+ * SymbolOutline - Nested symbol outline of one file
+ * v3.4.0 - Single-file outline (ctxman outline)
+ *
+ * Responsibilities:
+ * - Extract the symbols of one file without scanning its project
+ * - Nest them by range: a symbol belongs to the innermost one enclosing it
+ * - Describe each like a language server's DocumentSymbol: kind (name and
+ *   LSP number), signature, doc comment, range, selection range and hover
+ *
+ * range and selectionRange are zero-based LSP positions; startLine and
+ * endLine are the one-based lines used everywhere else in ctxman.
+ */
+
+import { SymbolKind } from './SymbolModel.js';
+
+export const OUTLINE_SCHEMA = 'ctxman.outline/v1';
+
+// SymbolKind of the Language Server Protocol
+export const LSP_SYMBOL_KINDS = Object.freeze({
+  [SymbolKind.MODULE]: 2,
+  [SymbolKind.CLASS]: 5,
+  [SymbolKind.METHOD]: 6,
+  [SymbolKind.ENUM]: 10,
+  [SymbolKind.INTERFACE]: 11,
+  [SymbolKind.TRAIT]: 11,
+  [SymbolKind.FUNCTION]: 12,
+  [SymbolKind.VARIABLE]: 13,
+  [SymbolKind.CONSTANT]: 14,
+  [SymbolKind.STRUCT]: 23,
+  [SymbolKind.TYPE]: 26
+});
+
+export class SymbolOutline {
+  /**
+   * @param {Object} options
+   */
+  constructor(options = {}) {
+    this.options = {
+      extractor: null, // Initialized SymbolExtractor
+      ...options
+    };
+  }
+
+  /**
+   * Outline of a file
+   * @param {string} content - File content
+   * @param {string} file - Path shown in the outline; routes the file to its language
+   * @returns {Object} { schema, file, language, backend, lines, symbols }
+   */
+  outline(content, file) {
+    const extractor = this.options.extractor;
+    const lines = content.split('\n');
+    const symbols = extractor.extract(content, file).map(symbol => describe(symbol, lines));
+
+    return {
+      schema: OUTLINE_SCHEMA,
+      file,
+      language: symbols[0]?.language || extractor.getPlugin(file, content)?.name || null,
+      backend: extractor.getBackend(file, content),
+      lines: lines.length,
+      symbols: SymbolOutline.nest(symbols)
+    };
+  }
+
+  /**
+   * Nest symbols into the innermost symbol whose lines enclose them
+   * @param {Array<Object>} symbols - With startLine and endLine
+   * @returns {Array<Object>} Top-level symbols, each with children
+   */
+  static nest(symbols) {
+    const roots = [];
+    const open = [];
+    // Outer symbols first where two start on the same line
+    const ordered = [...symbols].sort((a, b) => a.startLine - b.startLine || b.endLine - a.endLine);
+
+    for (const symbol of ordered) {
+      const node = { ...symbol, children: [] };
+      while (open.length > 0 && !encloses(open[open.length - 1], node)) {
+        open.pop();
+      }
+      (open.length > 0 ? open[open.length - 1].children : roots).push(node);
+      open.push(node);
+    }
+    return roots;
+  }
+
+  /**
+   * Outline as indented text
+   * @param {Object} outline - From outline()
+   * @returns {string}
+   */
+  static formatText(outline) {
+    const lines = [`📑 ${outline.file} (${outline.language}, ${outline.backend}, ${outline.lines} lines)`];
+    const walk = (symbols, depth) => {
+      for (const symbol of symbols) {
+        const range = symbol.startLine === symbol.endLine ? `L${symbol.startLine}` : `L${symbol.startLine}-${symbol.endLine}`;
+        const doc = symbol.doc ? ` — ${symbol.doc.split('\n')[0]}` : '';
+        lines.push(`${'  '.repeat(depth + 1)}${symbol.signature || `${symbol.kind} ${symbol.name}`} (${range})${doc}`);
+        walk(symbol.children, depth + 1);
+      }
+    };
+    walk(outline.symbols, 0);
+    if (outline.symbols.length === 0) {
+      lines.push('   No symbols');
+    }
+    return lines.join('\n');
+  }
+}
+
+/**
+ * DocumentSymbol-like description of a symbol
+ * @private
+ */
+function describe(symbol, lines) {
+  const startText = lines[symbol.startLine - 1] || '';
+  const endText = lines[symbol.endLine - 1] || '';
+  const nameAt = startText.indexOf(symbol.name);
+  const start = { line: symbol.startLine - 1, character: startText.length - startText.trimStart().length };
+
+  return {
+    name: symbol.name,
+    qualifiedName: symbol.qualifiedName,
+    kind: symbol.kind,
+    lspKind: LSP_SYMBOL_KINDS[symbol.kind] || LSP_SYMBOL_KINDS[SymbolKind.VARIABLE],
+    language: symbol.language,
+    exported: symbol.exported,
+    signature: symbol.signature,
+    doc: symbol.doc,
+    startLine: symbol.startLine,
+    endLine: symbol.endLine,
+    range: { start, end: { line: symbol.endLine - 1, character: endText.length } },
+    // The name where the start line shows it, else the whole start line
+    selectionRange: nameAt === -1
+      ? { start, end: { line: symbol.startLine - 1, character: startText.length } }
+      : { start: { line: symbol.startLine - 1, character: nameAt }, end: { line: symbol.startLine - 1, character: nameAt + symbol.name.length } },
+    hover: hover(symbol)
+  };
+}
+
+/**
+ * Markdown a language server would show on hover
+ * @private
+ */
+function hover(symbol) {
+  const parts = [];
+  if (symbol.signature) parts.push(`\`\`\`${symbol.language}\n${symbol.signature}\n\`\`\``);
+  if (symbol.doc) parts.push(symbol.doc);
+  return parts.join('\n\n') || symbol.qualifiedName;
+}
+
+/**
+ * Whether the lines of a symbol enclose another's
+ * @private
+ */
+function encloses(outer, inner) {
+  return outer.startLine <= inner.startLine && inner.endLine <= outer.endLine &&
+    (outer.startLine !== inner.startLine || outer.endLine !== inner.endLine);
+}
+
+export default SymbolOutline;
@@ -0,0 +1,223 @@
+/**
+ * TreeSitterBackend - Optional tree-sitter parsing backend
+ * v3.4.0 - Multi-language symbol extraction
+ *
+ * Responsibilities:
+ * - Load the native `tree-sitter` binding and per-language grammars lazily
+ * - Walk syntax trees and turn declaration nodes into symbols
+ * - Stay silent when tree-sitter is not installed (heuristic fallback)
+ *
+ * The runtime and grammars are optionalDependencies; npm skips them when the
+ * native build fails. To add them to an existing install:
+ *   npm install tree-sitter tree-sitter-python tree-sitter-typescript \
+ *     tree-sitter-javascript tree-sitter-rust tree-sitter-java tree-sitter-go
+ */
+
+import { createSymbol } from './SymbolModel.js';
+import { cleanBlockComment } from './SourceScanner.js';
+import { getLogger } from '../utils/logger.js';
+
+const logger = getLogger('TreeSitterBackend');
+
+const ATTRIBUTE_NODES = new Set(['attribute_item', 'decorator']);
+
+export class TreeSitterBackend {
+  constructor(options = {}) {
+    this.options = {
+      runtime: 'tree-sitter',
+      ...options
+    };
+
+    this.Parser = null;
+    this.available = false;
+    this.grammars = new Map();
+    this.parsers = new Map();
+  }
+
+  /**
+   * Load the tree-sitter runtime
+   * @returns {Promise<boolean>} Whether the runtime is available
+   */
+  async initialize() {
+    if (this.Parser) return this.available;
+
+    try {
+      const module = await import(this.options.runtime);
+      this.Parser = module.default || module;
+      this.available = typeof this.Parser === 'function';
+    } catch (error) {
+      logger.debug(`tree-sitter runtime not available: ${error.message}`);
+      this.available = false;
+    }
+
+    return this.available;
+  }
+
+  /**
+   * Load a grammar described by a plugin
+   * @param {object} grammar - { package, export }
+   * @returns {Promise<boolean>} Whether the grammar loaded
+   */
+  async loadGrammar(grammar) {
+    if (!this.available || !grammar) return false;
+
+    const key = grammarKey(grammar);
+    if (this.grammars.has(key)) return this.grammars.get(key) !== null;
+
+    try {
+      const module = await import(grammar.package);
+      let language = module.default || module;
+      if (grammar.export) {
+        language = language[grammar.export];
+      }
+      this.grammars.set(key, language || null);
+      logger.debug(`Loaded grammar: ${key}`);
+    } catch (error) {
+      logger.debug(`Grammar not available (${key}): ${error.message}`);
+      this.grammars.set(key, null);
+    }
+
+    return this.grammars.get(key) !== null;
+  }
+
+  /**
+   * Check if a grammar is loaded
+   * @param {object} grammar
+   * @returns {boolean}
+   */
+  hasGrammar(grammar) {
+    return Boolean(grammar && this.grammars.get(grammarKey(grammar)));
+  }
+
+  /**
+   * Parse content into a syntax tree
+   * @param {string} content
+   * @param {object} grammar
+   * @returns {object} Tree
+   */
+  parse(content, grammar) {
+    const key = grammarKey(grammar);
+    let parser = this.parsers.get(key);
+
+    if (!parser) {
+      parser = new this.Parser();
+      parser.setLanguage(this.grammars.get(key));
+      this.parsers.set(key, parser);
+    }
+
+    return parser.parse(content);
+  }
+
+  /**
+   * Extract symbols from content using a plugin's node descriptions
+   * @param {string} content - File content
+   * @param {string} filePath - File path
+   * @param {LanguagePlugin} plugin - Language plugin
+   * @returns {Array<object>|null} Symbols, or null if no grammar is loaded
+   */
+  extract(content, filePath, plugin) {
+    const grammar = plugin.getTreeSitterGrammar(filePath);
+    if (!this.available || !this.hasGrammar(grammar)) {
+      return null;
+    }
+
+    const tree = this.parse(content, grammar);
+    const symbols = [];
+    const language = plugin.getLanguageId(filePath);
+
+    const visit = (node, scope) => {
+      for (const child of node.namedChildren) {
+        const descriptor = plugin.describeTreeSitterNode(child, scope);
+
+        if (!descriptor) {
+          visit(child, scope);
+          continue;
+        }
+
+        if (descriptor.symbol !== false) {
+          const anchor = descriptor.anchor || child;
+          symbols.push(createSymbol({
+            name: descriptor.name,
+            kind: descriptor.kind,
+            language,
+            file: filePath,
+            startLine: anchor.startPosition.row + 1,
+            endLine: child.endPosition.row + 1,
+            signature: descriptor.signature || nodeSignature(child),
+            doc: descriptor.doc !== undefined ? descriptor.doc : leadingComment(anchor),
+            parent: descriptor.parent !== undefined ? descriptor.parent : scope.parent,
+            exported: descriptor.exported,
+            implements: descriptor.implements || scope.implements || null
+          }));
+        }
+
+        if (descriptor.container) {
+          const body = descriptor.body || child.childForFieldName('body') || child;
+          visit(body, {
+            parent: descriptor.containerName || (scope.parent ? `${scope.parent}.${descriptor.name}` : descriptor.name),
+            parentKind: descriptor.kind,
+            exported: descriptor.exported,
+            implements: descriptor.implements || null
+          });
+        }
+      }
+    };
+
+    visit(tree.rootNode, { parent: null, parentKind: null, exported: true, implements: null });
+    return symbols;
+  }
+}
+
+/**
+ * Declaration text up to the body node
+ * @param {object} node - Syntax node
+ * @returns {string}
+ */
+export function nodeSignature(node) {
+  const body = node.childForFieldName('body');
+  const text = body
+    ? node.text.slice(0, body.startIndex - node.startIndex)
+    : node.text.split('\n')[0];
+  return text.replace(/\s+/g, ' ').replace(/[{:;]\s*$/, '').trim();
+}
+
+/**
+ * Contiguous comment nodes directly above a node (attributes in between are skipped)
+ * @param {object} node - Syntax node
+ * @returns {string|null}
+ */
+export function leadingComment(node) {
+  const comments = [];
+  let expectedRow = node.startPosition.row - 1;
+  let sibling = node.previousNamedSibling;
+
+  while (sibling && ATTRIBUTE_NODES.has(sibling.type) && sibling.endPosition.row === expectedRow) {
+    expectedRow = sibling.startPosition.row - 1;
+    sibling = sibling.previousNamedSibling;
+  }
+
+  while (sibling && sibling.type.includes('comment') && sibling.endPosition.row === expectedRow) {
+    comments.unshift(sibling.text);
+    expectedRow = sibling.startPosition.row - 1;
+    sibling = sibling.previousNamedSibling;
+  }
+
+  if (comments.length === 0) return null;
+
+  const text = comments.join('\n');
+  if (text.trim().startsWith('/**')) {
+    return cleanBlockComment(text.split('\n').map(line => line.trim()));
+  }
+
+  return text
+    .split('\n')
+    .map(line => line.trim().replace(/^(\/\/[\/!]?|#)\s?/, ''))
+    .join('\n')
+    .trim() || null;
+}
+
+function grammarKey(grammar) {
+  return grammar.export ? `${grammar.package}#${grammar.export}` : grammar.package;
+}
+
+export default TreeSitterBackend;
@@ -0,0 +1,106 @@
+import React, { useState } from 'react';
+import { Box, Text, useInput, useStdin } from 'ink';
+
+/**
+ * Interactive Context Selector
+ * v3.4.0 - Browse the file tree, toggle files and symbols, watch the budget
+ *
+ * Keys: ↑/↓ move, → expand (files show their symbols), ← collapse,
+ * space toggle, a all, n none, enter generate, q/esc quit
+ */
+
+const WIDTH = 76;
+const MARKS = { all: '[x]', some: '[~]', none: '[ ]' };
+
+function formatRow(tree, { node, depth }) {
+	const arrow = node.type === 'symbol' ? ' ' : node.expanded ? '▾' : '▸';
+	const state = tree.stateOf(node);
+	const label = `${'  '.repeat(depth)}${arrow} ${MARKS[state]} ${node.name}${node.type === 'dir' ? '/' : ''}`;
+
+	const total = tree.totalTokens(node);
+	const tokens = state === 'some' ? `${tree.selectedTokens(node).toLocaleString()}/${total.toLocaleString()}` : total.toLocaleString();
+	const room = WIDTH - tokens.length - 6;
+	return `${label.length > room ? `${label.slice(0, room - 1)}…` : label.padEnd(room)} ${tokens}`;
+}
+
+export default function ContextSelector({ tree, onSubmit, onCancel, height = 20 }) {
+	const [cursor, setCursor] = useState(0);
+	const [, setVersion] = useState(0);
+	const { isRawModeSupported } = useStdin();
+
+	const rows = tree.rows();
+	const current = rows[Math.min(cursor, rows.length - 1)];
+	const refresh = () => setVersion(version => version + 1);
+
+	useInput((input, key) => {
+		if (key.upArrow || input === 'k') {
+			setCursor(Math.max(0, cursor - 1));
+		} else if (key.downArrow || input === 'j') {
+			setCursor(Math.min(rows.length - 1, cursor + 1));
+		} else if ((key.rightArrow || input === 'l') && current) {
+			tree.expand(current.node);
+			refresh();
+		} else if ((key.leftArrow || input === 'h') && current) {
+			if (current.node.expanded) {
+				tree.collapse(current.node);
+			} else if (current.node.parent && current.node.parent !== tree.root) {
+				// Jump to the enclosing directory or file
+				setCursor(rows.findIndex(row => row.node === current.node.parent));
+			}
+			refresh();
+		} else if (input === ' ' && current) {
+			tree.toggle(current.node);
+			refresh();
+		} else if (input === 'a' || input === 'n') {
+			tree.setAll(input === 'a');
+			refresh();
+		} else if (key.return) {
+			onSubmit(tree.selection());
+		} else if (input === 'q' || key.escape) {
+			onCancel();
+		}
+	}, { isActive: isRawModeSupported });
+
+	// Keep the cursor inside the visible window
+	const start = Math.max(0, Math.min(cursor - Math.floor(height / 2), rows.length - height));
+	const visible = rows.slice(start, start + height);
+
+	const selected = tree.selectedTokens();
+	const budget = tree.budget
+		? ` / ${tree.budget.toLocaleString()} (${Math.round((selected / tree.budget) * 100)}%)`
+		: ` of ${tree.totalTokens().toLocaleString()}`;
+
+	return React.createElement(Box, {
+		flexDirection: 'column',
+		paddingX: 1,
+		borderStyle: 'round',
+		borderColor: 'cyan',
+		width: WIDTH + 4
+	},
+		React.createElement(Box, { marginBottom: 1 },
+			React.createElement(Text, { bold: true, color: 'cyan' }, '🗂️  Select Context'),
+			React.createElement(Text, null, '   Selected: '),
+			React.createElement(Text, { bold: true, color: tree.isOverBudget() ? 'red' : 'green' },
+				`${selected.toLocaleString()} tokens`),
+			React.createElement(Text, { color: 'gray' }, budget)
+		),
+
+		rows.length === 0
+			? React.createElement(Text, { color: 'gray' }, 'No files to select')
+			: React.createElement(Box, { flexDirection: 'column' },
+				...visible.map((row, index) => {
+					const isCurrent = start + index === cursor;
+					return React.createElement(Text, {
+						key: `${row.node.type}:${row.node.path}`,
+						color: isCurrent ? 'cyan' : tree.stateOf(row.node) === 'none' ? 'gray' : undefined,
+						inverse: isCurrent
+					}, formatRow(tree, row));
+				})
+			),
+
+		React.createElement(Box, { marginTop: 1 },
+			React.createElement(Text, { dimColor: true },
+				'[↑↓] Move  [→←] Expand  [Space] Toggle  [A]ll  [N]one  [Enter] Generate  [Q] Quit')
+		)
+	);
+}
@@ -6,10 +6,12 @@
 import { ProgressBar, SpinnerWithText } from './progress-bar.js';
 import Wizard from './wizard.js';
 import Dashboard from './dashboard.js';
+import ContextSelector from './context-selector.js';
 
 export {
     ProgressBar,
     SpinnerWithText,
     Wizard,
-    Dashboard
+    Dashboard,
+    ContextSelector
 };
@@ -0,0 +1,234 @@
+/**
+ * Selection Tree
+ * v3.4.0 - State behind the interactive context selector
+ *
+ * Holds the project tree with per-file token counts, which files and
+ * symbols are selected, and the running token total. Rendering lives in
+ * context-selector.js; this module has no UI dependencies.
+ */
+
+import { compareBytes } from '../utils/ordering.js';
+
+/**
+ * @typedef {Object} TreeNode
+ * @property {'dir'|'file'|'symbol'} type
+ * @property {string} name - Display name
+ * @property {string} path - Relative path ('' for the root); symbols use their ID
+ * @property {number} tokens - Tokens of the whole file or symbol
+ */
+
+export class SelectionTree {
+	/**
+	 * @param {Array<{relativePath: string, tokens: number}>} files - Analyzed files
+	 * @param {Object} options
+	 * @param {number|null} options.budget - Token budget to show against the selection
+	 * @param {Function|null} options.loadSymbols - relativePath => [{id, name, kind, tokens}]
+	 */
+	constructor(files, options = {}) {
+		this.budget = options.budget ?? null;
+		this.loadSymbols = options.loadSymbols || null;
+		this.root = { type: 'dir', name: '.', path: '', children: [], expanded: true, parent: null };
+
+		for (const fileInfo of files) {
+			if (fileInfo.error) continue;
+			this.addFile(fileInfo.relativePath.split('\\').join('/'), fileInfo.tokens);
+		}
+		this.sortChildren(this.root);
+	}
+
+	/**
+	 * @private
+	 */
+	addFile(relativePath, tokens) {
+		const parts = relativePath.split('/');
+		let dir = this.root;
+
+		for (const part of parts.slice(0, -1)) {
+			let child = dir.children.find(node => node.type === 'dir' && node.name === part);
+			if (!child) {
+				child = { type: 'dir', name: part, path: dir.path ? `${dir.path}/${part}` : part, children: [], expanded: false, parent: dir };
+				dir.children.push(child);
+			}
+			dir = child;
+		}
+
+		dir.children.push({
+			type: 'file',
+			name: parts[parts.length - 1],
+			path: relativePath,
+			tokens,
+			selected: true, // Whole file
+			symbols: null, // Loaded on expand
+			picked: new Set(), // Symbol IDs when only part of the file is selected
+			expanded: false,
+			parent: dir
+		});
+	}
+
+	/**
+	 * Directories first, then files, by name in code point order
+	 * @private
+	 */
+	sortChildren(dir) {
+		dir.children.sort((a, b) => (a.type === b.type ? compareBytes(a.name, b.name) : a.type === 'dir' ? -1 : 1));
+		dir.children.filter(node => node.type === 'dir').forEach(node => this.sortChildren(node));
+	}
+
+	/**
+	 * Visible rows, depth-first through expanded nodes
+	 * @returns {Array<{node: TreeNode, depth: number}>}
+	 */
+	rows() {
+		const rows = [];
+		const walk = (node, depth) => {
+			for (const child of node.children || node.symbols || []) {
+				rows.push({ node: child, depth });
+				if (child.expanded) walk(child, depth + 1);
+			}
+		};
+		walk(this.root, 0);
+		return rows;
+	}
+
+	/**
+	 * Show a directory's children or a file's symbols
+	 * @param {TreeNode} node
+	 */
+	expand(node) {
+		if (node.type === 'file' && node.symbols === null) {
+			node.symbols = (this.loadSymbols ? this.loadSymbols(node.path) : [])
+				.map(symbol => ({ type: 'symbol', name: `${symbol.kind} ${symbol.name}`, path: symbol.id, tokens: symbol.tokens, parent: node }));
+		}
+		if (node.type === 'dir' || node.symbols?.length > 0) {
+			node.expanded = true;
+		}
+	}
+
+	/**
+	 * @param {TreeNode} node
+	 */
+	collapse(node) {
+		node.expanded = false;
+	}
+
+	/**
+	 * Toggle a node in or out of the selection
+	 * A partly selected directory or file becomes fully selected.
+	 * @param {TreeNode} node
+	 */
+	toggle(node) {
+		if (node.type === 'symbol') {
+			const file = node.parent;
+			if (file.selected) {
+				// Deselecting one symbol of a whole file keeps the others
+				file.selected = false;
+				file.picked = new Set(file.symbols.map(symbol => symbol.path));
+			}
+			if (file.picked.has(node.path)) {
+				file.picked.delete(node.path);
+			} else {
+				file.picked.add(node.path);
+			}
+			if (file.picked.size === file.symbols.length) {
+				file.selected = true;
+				file.picked.clear();
+			}
+			return;
+		}
+
+		this.setSelected(node, this.stateOf(node) !== 'all');
+	}
+
+	/**
+	 * @private
+	 */
+	setSelected(node, selected) {
+		if (node.type === 'dir') {
+			node.children.forEach(child => this.setSelected(child, selected));
+		} else {
+			node.selected = selected;
+			node.picked.clear();
+		}
+	}
+
+	/**
+	 * Select or clear everything
+	 * @param {boolean} selected
+	 */
+	setAll(selected) {
+		this.setSelected(this.root, selected);
+	}
+
+	/**
+	 * @param {TreeNode} node
+	 * @returns {'all'|'some'|'none'}
+	 */
+	stateOf(node) {
+		if (node.type === 'symbol') {
+			return node.parent.selected || node.parent.picked.has(node.path) ? 'all' : 'none';
+		}
+		if (node.type === 'file') {
+			return node.selected ? 'all' : node.picked.size > 0 ? 'some' : 'none';
+		}
+
+		const states = new Set(node.children.map(child => this.stateOf(child)));
+		if (states.size === 1) return [...states][0];
+		return states.size === 0 ? 'none' : 'some';
+	}
+
+	/**
+	 * Selected tokens under a node (approximate for partial files: the sum
+	 * of the picked symbols)
+	 * @param {TreeNode} node
+	 * @returns {number}
+	 */
+	selectedTokens(node = this.root) {
+		if (node.type === 'symbol') {
+			return this.stateOf(node) === 'all' ? node.tokens : 0;
+		}
+		if (node.type === 'file') {
+			if (node.selected) return node.tokens;
+			return node.symbols ? node.symbols.reduce((sum, symbol) => sum + this.selectedTokens(symbol), 0) : 0;
+		}
+		return node.children.reduce((sum, child) => sum + this.selectedTokens(child), 0);
+	}
+
+	/**
+	 * Tokens of everything under a node
+	 * @param {TreeNode} node
+	 * @returns {number}
+	 */
+	totalTokens(node = this.root) {
+		return node.type === 'dir' ? node.children.reduce((sum, child) => sum + this.totalTokens(child), 0) : node.tokens;
+	}
+
+	/**
+	 * Whether the selection exceeds the budget
+	 * @returns {boolean}
+	 */
+	isOverBudget() {
+		return this.budget !== null && this.selectedTokens() > this.budget;
+	}
+
+	/**
+	 * What to export: whole files and symbol IDs of partly selected files
+	 * @returns {{files: Array<string>, symbols: Array<string>}}
+	 */
+	selection() {
+		const files = [];
+		const symbols = [];
+		const walk = node => {
+			if (node.type === 'dir') {
+				node.children.forEach(walk);
+			} else if (node.selected) {
+				files.push(node.path);
+			} else {
+				symbols.push(...node.symbols?.filter(symbol => node.picked.has(symbol.path)).map(symbol => symbol.path) || []);
+			}
+		};
+		walk(this.root);
+		return { files, symbols };
+	}
+}
+
+export default SelectionTree;
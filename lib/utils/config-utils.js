@@ -60,9 +60,10 @@ class ConfigUtils {
     /**
      * Initialize git ignore parser
      * @param {string} projectRoot - Project root directory
+     * @param {object} directoryConfig - DirectoryConfig of nested .context.yaml files
      * @returns {object} GitIgnoreParser instance
      */
-    static initGitIgnore(projectRoot) {
+    static initGitIgnore(projectRoot, directoryConfig = null) {
         const paths = {
             contextIgnore: this.findConfigFile(projectRoot, '.contextignore'),
             contextInclude: this.findConfigFile(projectRoot, '.contextinclude')
@@ -71,7 +72,8 @@ class ConfigUtils {
         return new GitIgnoreParser(
             path.join(projectRoot, '.gitignore'),
             paths.contextIgnore,
-            paths.contextInclude
+            paths.contextInclude,
+            { rootDir: projectRoot, directoryConfig }
         );
     }
 
@@ -4,6 +4,14 @@
  */
 
 import path from 'path';
+import LanguageDetector from '../languages/LanguageDetector.js';
+
+const TEXT_EXTENSIONS = new Set([
+    '.js', '.ts', '.jsx', '.tsx', '.json', '.md', '.txt', '.yml', '.yaml',
+    '.html', '.css', '.scss', '.sass', '.less', '.xml', '.svg',
+    '.sh', '.bash', '.zsh', '.py', '.rb', '.php', '.java', '.c', '.cpp', '.cc', '.h', '.hpp',
+    '.go', '.rs', '.swift', '.kt', '.kts', '.cs', '.scala', '.sql', '.toml', '.ini', '.conf', '.ipynb'
+]);
 
 class FileUtils {
     /**
@@ -15,17 +23,32 @@ class FileUtils {
         const ext = path.extname(filePath).toLowerCase();
         const basename = path.basename(filePath).toLowerCase();
 
-        const textExtensions = new Set([
-            '.js', '.ts', '.jsx', '.tsx', '.json', '.md', '.txt', '.yml', '.yaml',
-            '.html', '.css', '.scss', '.sass', '.less', '.xml', '.svg',
-            '.sh', '.bash', '.zsh', '.py', '.rb', '.php', '.java', '.c', '.cpp', '.cc', '.h', '.hpp',
-            '.go', '.rs', '.swift', '.kt', '.kts', '.cs', '.scala', '.sql', '.toml', '.ini', '.conf'
-        ]);
-
         const textFiles = ['dockerfile', 'makefile', 'license', 'readme', 'changelog'];
 
-        return textExtensions.has(ext) ||
-               textFiles.some(name => basename.includes(name));
+        return TEXT_EXTENSIONS.has(ext) ||
+               textFiles.some(name => basename.includes(name)) ||
+               LanguageDetector.fromName(filePath) !== null;
+    }
+
+    /**
+     * Check if an extension-less file is a script, by its shebang or modeline (v3.4.0)
+     * @param {string} filePath - File path
+     * @param {string} [content] - Content, or its first bytes; read from disk when omitted
+     * @returns {boolean}
+     */
+    static isScript(filePath, content) {
+        if (path.extname(filePath) !== '') return false;
+        return (content === undefined ? LanguageDetector.sniff(filePath) : LanguageDetector.fromContent(content)) !== null;
+    }
+
+    /**
+     * Treat more extensions as text (v3.4.0: files of custom extractors)
+     * @param {Array<string>} extensions - e.g. ['.tf', '.proto']
+     */
+    static addTextExtensions(extensions) {
+        for (const ext of extensions) {
+            TEXT_EXTENSIONS.add(ext.toLowerCase());
+        }
     }
 
     /**
@@ -0,0 +1,79 @@
+/**
+ * Deterministic Ordering (v3.4.0)
+ * Orderings that come out the same on every machine: strings compare by
+ * their UTF-8 bytes rather than the locale (localeCompare) or UTF-16 code
+ * units (the default sort), paths with '/' separators, and symbols by
+ * position with their kind and name breaking ties. Section IDs are derived
+ * from the path alone, so a file keeps its ID when others come and go.
+ */
+
+import crypto from 'crypto';
+import path from 'path';
+
+/**
+ * Compare strings by their UTF-8 bytes, which is code point order
+ * @param {string} a
+ * @param {string} b
+ * @returns {number}
+ */
+export function compareBytes(a, b) {
+    if (a === b) return 0;
+    const length = Math.min(a.length, b.length);
+    for (let i = 0; i < length; i++) {
+        const x = a.charCodeAt(i);
+        const y = b.charCodeAt(i);
+        if (x !== y) return codeUnitRank(x) - codeUnitRank(y);
+    }
+    return a.length - b.length;
+}
+
+/**
+ * Compare paths byte-wise, with native separators read as '/'
+ * @param {string} a
+ * @param {string} b
+ * @returns {number}
+ */
+export function comparePaths(a, b) {
+    return compareBytes(toPosix(a), toPosix(b));
+}
+
+/**
+ * Compare symbols by file, position, kind and qualified name
+ * Symbols starting on the same line keep the shorter (inner) one first.
+ * @param {Object} a
+ * @param {Object} b
+ * @returns {number}
+ */
+export function compareSymbols(a, b) {
+    return compareBytes(a.file || '', b.file || '') ||
+        a.startLine - b.startLine ||
+        a.endLine - b.endLine ||
+        compareBytes(a.kind || '', b.kind || '') ||
+        compareBytes(a.qualifiedName || a.name || '', b.qualifiedName || b.name || '');
+}
+
+/**
+ * Stable numeric ID of a context section: the first 32 bits of the SHA-256
+ * of its '/'-separated path
+ * @param {string} relativePath
+ * @returns {number}
+ */
+export function sectionId(relativePath) {
+    return parseInt(crypto.createHash('sha256').update(toPosix(relativePath)).digest('hex').slice(0, 8), 16);
+}
+
+/**
+ * Surrogates encode code points above U+FFFF, which UTF-8 puts after the
+ * rest of the Basic Multilingual Plane
+ * @private
+ */
+function codeUnitRank(unit) {
+    return unit >= 0xD800 && unit <= 0xDFFF ? unit + 0x10000 : unit;
+}
+
+/**
+ * @private
+ */
+function toPosix(file) {
+    return file.split(path.sep).join('/');
+}
@@ -5,6 +5,7 @@
 
 import path from 'path';
 import { getTokenizerManager } from './tokenizer-adapter.js';
+import TokenEstimator from '../core/TokenEstimator.js';
 
 // Load tiktoken for backward compatibility (dynamic import for optional dep)
 let tiktoken = null;
@@ -18,6 +19,9 @@ try {
 // Tokenizer manager singleton
 let tokenizerManager = null;
 
+// Per-language estimator, calibrated by useEstimator() (v3.4.0)
+let estimator = new TokenEstimator();
+
 export default class TokenUtils {
     /**
      * Calculate tokens using model-specific tokenizer
@@ -60,41 +64,28 @@ export default class TokenUtils {
     }
 
     /**
-     * Estimate tokens based on file type and content length
+     * Estimate tokens with the per-language model of the file type (v3.4.0)
      * @param {string} content - File content
      * @param {string} filePath - File path for extension detection
      * @returns {number} Estimated token count
      */
     static estimate(content, filePath) {
-        const ext = path.extname(filePath).toLowerCase();
-
-        const charsPerToken = {
-            '.json': 2.5,
-            '.js': 3.2,
-            '.ts': 3.2,
-            '.jsx': 3.2,
-            '.tsx': 3.2,
-            '.py': 3.0,
-            '.java': 3.5,
-            '.go': 3.3,
-            '.rs': 3.4,
-            '.php': 3.1,
-            '.rb': 3.0,
-            '.swift': 3.3,
-            '.kt': 3.5,
-            '.cs': 3.5,
-            '.cpp': 3.6,
-            '.c': 3.6,
-            '.h': 3.6,
-            '.hpp': 3.6,
-            '.scala': 3.5,
-            '.md': 3.5,
-            '.txt': 4.0,
-            'default': 3.5
-        };
-
-        const ratio = charsPerToken[ext] || charsPerToken['default'];
-        return Math.round(content.length / ratio);
+        return estimator.estimate(content, filePath);
+    }
+
+    /**
+     * Estimator used by estimate(), e.g. one with calibration factors
+     * @param {TokenEstimator|null} next - null restores the uncalibrated models
+     */
+    static useEstimator(next) {
+        estimator = next || new TokenEstimator();
+    }
+
+    /**
+     * @returns {TokenEstimator} Estimator used by estimate()
+     */
+    static getEstimator() {
+        return estimator;
     }
 
     /**
@@ -48,12 +48,15 @@ export class TokenizerAdapter {
 
 /**
  * OpenAI Tokenizer (tiktoken)
- * For GPT-4, GPT-5, o1 models
+ * cl100k_base for GPT-4/GPT-3.5, o200k_base for GPT-4o, GPT-4.1, GPT-5, o-series
  */
 export class TiktokenAdapter extends TokenizerAdapter {
-    constructor() {
+    constructor(encodingName = 'cl100k_base') {
         super();
-        this.name = 'Tiktoken (OpenAI)';
+        this.encodingName = encodingName;
+        this.name = encodingName === 'cl100k_base'
+            ? 'Tiktoken (OpenAI)'
+            : `Tiktoken (OpenAI ${encodingName})`;
         this.tiktoken = null;
         this.encoding = null;
     }
@@ -76,9 +79,7 @@ export class TiktokenAdapter extends TokenizerAdapter {
         }
 
         try {
-            // Use cl100k_base for GPT-4, GPT-4o, GPT-5
-            // Use o200k_base for newer models if needed
-            const encoding = this.tiktoken.get_encoding('cl100k_base');
+            const encoding = this.tiktoken.get_encoding(this.encodingName);
             const tokens = encoding.encode(content);
             encoding.free();
             return tokens.length;
@@ -241,6 +242,61 @@ export class EstimationAdapter extends TokenizerAdapter {
     }
 }
 
+/**
+ * Claude approximation (fallback when @anthropic-ai/tokenizer is missing)
+ * Claude's tokenizer produces ~10% more tokens than cl100k_base on typical
+ * source code; without tiktoken, falls back to ~3.2 chars per token.
+ */
+export class ClaudeApproximationAdapter extends TokenizerAdapter {
+    constructor() {
+        super();
+        this.name = 'Claude (approximation)';
+        this.ratio = 1.1;
+        this.cl100k = new TiktokenAdapter('cl100k_base');
+        this.available = true; // Always available
+    }
+
+    async initialize() {
+        await this.cl100k.initialize();
+        this.available = true;
+        return true;
+    }
+
+    count(content) {
+        if (this.cl100k.isAvailable()) {
+            try {
+                return Math.ceil(this.cl100k.count(content) * this.ratio);
+            } catch (error) {
+                // Fall through to character estimate
+            }
+        }
+        return Math.ceil(content.length / 3.2);
+    }
+}
+
+/**
+ * Tokenizer names accepted by TokenizerManager.resolveTokenizer()
+ */
+export const TOKENIZER_ALIASES = {
+    'cl100k_base': 'tiktoken',
+    'cl100k': 'tiktoken',
+    'o200k_base': 'tiktoken-o200k',
+    'o200k': 'tiktoken-o200k',
+    'claude': 'claude',
+    'anthropic': 'claude',
+    'estimate': 'estimation',
+    'estimation': 'estimation'
+};
+
+/**
+ * OpenAI models using the o200k_base encoding
+ * @param {string} lowerModel - Lowercased model name
+ * @returns {boolean}
+ */
+function usesO200k(lowerModel) {
+    return /gpt-4o|gpt-4\.1|gpt-5|chatgpt|(^|[^a-z0-9])o[134](-|$)/.test(lowerModel);
+}
+
 /**
  * Telemetry tracker for tokenizer usage
  */
@@ -319,7 +375,9 @@ export class TokenizerManager {
 
         const adapters = [
             { key: 'tiktoken', adapter: new TiktokenAdapter() },
+            { key: 'tiktoken-o200k', adapter: new TiktokenAdapter('o200k_base') },
             { key: 'anthropic', adapter: new AnthropicAdapter() },
+            { key: 'claude-approx', adapter: new ClaudeApproximationAdapter() },
             { key: 'gemini', adapter: new GeminiAdapter() },
             { key: 'deepseek', adapter: new DeepSeekAdapter() },
             { key: 'llama', adapter: new LlamaAdapter() },
@@ -348,6 +406,9 @@ export class TokenizerManager {
 
         // OpenAI models
         if (lowerModel.includes('gpt') || lowerModel.includes('o1') || lowerModel.includes('o3')) {
+            const o200k = this.adapters.get('tiktoken-o200k');
+            if (usesO200k(lowerModel) && o200k && o200k.isAvailable()) return o200k;
+
             const tiktoken = this.adapters.get('tiktoken');
             if (tiktoken.isAvailable()) return tiktoken;
         }
@@ -356,6 +417,9 @@ export class TokenizerManager {
         if (lowerModel.includes('claude')) {
             const anthropic = this.adapters.get('anthropic');
             if (anthropic.isAvailable()) return anthropic;
+
+            const approximation = this.adapters.get('claude-approx');
+            if (approximation) return approximation;
         }
 
         // Google models
@@ -379,6 +443,39 @@ export class TokenizerManager {
         return this.adapters.get('estimation');
     }
 
+    /**
+     * Resolve a tokenizer by name, falling back to model-based selection
+     * @param {string|null} tokenizerName - cl100k_base, o200k_base, claude, estimate or auto
+     * @param {string|null} modelName - Model name used when tokenizerName is auto/empty
+     * @returns {TokenizerAdapter}
+     */
+    resolveTokenizer(tokenizerName, modelName = null) {
+        if (!this.initialized) {
+            throw new Error('TokenizerManager not initialized. Call initialize() first.');
+        }
+
+        const name = (tokenizerName || 'auto').toLowerCase();
+
+        if (name === 'auto') {
+            if (modelName) return this.getTokenizerForModel(modelName);
+            const tiktoken = this.adapters.get('tiktoken');
+            return tiktoken.isAvailable() ? tiktoken : this.adapters.get('estimation');
+        }
+
+        const key = TOKENIZER_ALIASES[name];
+        if (!key) {
+            throw new Error(`Unknown tokenizer: ${tokenizerName} (expected one of: auto, ${Object.keys(TOKENIZER_ALIASES).join(', ')})`);
+        }
+
+        if (key === 'claude') {
+            const anthropic = this.adapters.get('anthropic');
+            return anthropic.isAvailable() ? anthropic : this.adapters.get('claude-approx');
+        }
+
+        const adapter = this.adapters.get(key);
+        return adapter.isAvailable() ? adapter : this.adapters.get('estimation');
+    }
+
     /**
      * Count tokens with telemetry tracking
      * @param {string} content - Content to tokenize
@@ -0,0 +1,310 @@
+/**
+ * ContextRegenerator - Live context regeneration
+ * v3.4.0 - Watch mode context regeneration
+ *
+ * Responsibilities:
+ * - Regenerate the GitIngest digest or LLM context after file changes
+ * - Batch bursts of changes into one regeneration (debounce)
+ * - Reuse token counts of unchanged files (in-memory content cache), and
+ *   their symbol outlines up to the ParseCache's limit
+ * - Report which sections of the output were added, updated or removed
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { EventEmitter } from 'events';
+import TokenCalculator from '../analyzers/token-calculator.js';
+import GitIngestFormatter from '../formatters/gitingest-formatter.js';
+import ContentCache from '../cache/ContentCache.js';
+import ParseCache from '../cache/ParseCache.js';
+import { getLogger } from '../utils/logger.js';
+import { compareBytes } from '../utils/ordering.js';
+
+const logger = getLogger('ContextRegenerator');
+
+const FILE_HEADER_PATTERN = /^={48}\nFILE: (.+)\n={48}\n/gm;
+const RULE_FILES = new Set(['.gitignore', '.contextignore', '.contextinclude', '.context.yaml']);
+
+export const DEFAULT_OUTPUTS = {
+  gitingest: 'digest.txt',
+  context: 'llm-context.json'
+};
+
+export class ContextRegenerator extends EventEmitter {
+  /**
+   * @param {string} projectRoot
+   * @param {Object} options
+   */
+  constructor(projectRoot, options = {}) {
+    super();
+
+    this.projectRoot = projectRoot;
+    this.options = {
+      format: 'gitingest', // gitingest | context
+      output: undefined, // Relative path; null writes to stream (default: DEFAULT_OUTPUTS[format])
+      stream: process.stdout,
+      debounce: 200, // Batch window for changes across files
+      analysis: {}, // TokenCalculator options (methodLevel, tokenBudget, focus, expandDeps, ...)
+      parseCache: null, // ParseCache of the in-memory cache; default size when not given
+      ...options
+    };
+
+    if (!DEFAULT_OUTPUTS[this.options.format]) {
+      throw new Error(`Unknown context format: ${this.options.format} (expected gitingest or context)`);
+    }
+    if (this.options.output === undefined) {
+      this.options.output = DEFAULT_OUTPUTS[this.options.format];
+    }
+
+    // Outlines of the in-memory cache are bounded; a persisted cache keeps its own
+    this.parseCache = this.options.analysis.cache ? null : this.options.parseCache || new ParseCache();
+    this.calculator = new TokenCalculator(projectRoot, {
+      ...this.options.analysis,
+      // Persisted cache if given, otherwise reuse counts between regenerations only
+      cache: this.options.analysis.cache || new ContentCache({ root: projectRoot, path: null, parseCache: this.parseCache }),
+      dashboard: true // Reports and exports are handled here
+    });
+
+    this.sections = new Map();
+    this.pending = new Set();
+    this.timer = null;
+    this.generation = 0;
+  }
+
+  /**
+   * @returns {string|null} Absolute output path, or null when streaming
+   */
+  getOutputPath() {
+    return this.options.output ? path.resolve(this.projectRoot, this.options.output) : null;
+  }
+
+  /**
+   * Queue a FileWatcher change; regenerates once the batch window closes
+   * @param {{relativePath: string}} changeEvent
+   */
+  queue(changeEvent) {
+    const outputPath = this.getOutputPath();
+    const changedPath = path.resolve(this.projectRoot, changeEvent.relativePath);
+    if (outputPath && (changedPath === outputPath || changedPath === `${outputPath}.tmp`)) {
+      return; // Our own write
+    }
+
+    this.pending.add(changeEvent.relativePath.split(path.sep).join('/'));
+
+    clearTimeout(this.timer);
+    this.timer = setTimeout(() => this.flush(), this.options.debounce);
+  }
+
+  /**
+   * Regenerate now for all queued changes
+   * @returns {Object|null} Summary, or null when nothing was queued
+   */
+  flush() {
+    clearTimeout(this.timer);
+    this.timer = null;
+
+    const changedFiles = [...this.pending].sort(compareBytes);
+    this.pending.clear();
+    if (changedFiles.length === 0) return null;
+
+    try {
+      return this.regenerate(changedFiles);
+    } catch (error) {
+      logger.error(`Context regeneration failed: ${error.message}`);
+      this.emit('context:error', { error, changedFiles });
+      return null;
+    }
+  }
+
+  /**
+   * Regenerate the output and write it if any section changed
+   * @param {Array<string>} changedFiles - Files that triggered the run
+   * @returns {Object} Summary { generation, changedFiles, added, updated, removed, sections, files, tokens, written, elapsed }
+   */
+  regenerate(changedFiles = []) {
+    const started = Date.now();
+
+    if (changedFiles.some(file => RULE_FILES.has(path.posix.basename(file)))) {
+      this.calculator.gitIgnore = this.calculator.initGitIgnore();
+    }
+
+    const { content, sections, files } = this.generate();
+    const diff = diffSections(this.sections, sections);
+    const first = this.generation === 0;
+
+    this.sections = sections;
+    this.generation++;
+
+    const written = first || diff.added.length + diff.updated.length + diff.removed.length > 0;
+    if (written) {
+      this.write(content);
+    }
+
+    const summary = {
+      generation: this.generation,
+      changedFiles,
+      ...diff,
+      sections: sections.size,
+      files,
+      tokens: this.calculator.stats.totalTokens,
+      output: this.options.output,
+      written,
+      elapsed: Date.now() - started,
+      parseCache: this.parseCache?.getStats() || null
+    };
+
+    this.emit('context:regenerated', summary);
+    return summary;
+  }
+
+  /**
+   * Build the output and its sections
+   * @returns {{content: string, sections: Map<string, string>, files: number}}
+   */
+  generate() {
+    const calculator = this.calculator;
+    calculator.stats = calculator.initStats();
+    calculator.methodStats = { totalMethods: 0, includedMethods: 0, methodTokens: {} };
+    calculator.directiveWarnings = [];
+    calculator.expansion = null;
+    calculator.selection = null;
+    calculator.budgetPlan = null;
+
+    const outputPath = this.getOutputPath();
+    const allFiles = calculator.scanDirectory(this.projectRoot).filter(file => file !== outputPath);
+    const exportResults = calculator.selectExportResults(calculator.analyzeFiles(allFiles)) || [];
+
+    const sections = new Map();
+    let content;
+
+    if (this.options.format === 'context') {
+      const context = calculator.generateLLMContext(exportResults);
+      content = JSON.stringify(context, null, 2);
+
+      for (const [key, value] of Object.entries(context)) {
+        // Per-directory (paths) and per-file (methods) entries are sections of their own
+        if ((key === 'paths' || key === 'methods') && value && typeof value === 'object') {
+          for (const [entry, entryValue] of Object.entries(value)) {
+            sections.set(`${key}:${entry}`, ContentCache.hash(JSON.stringify(entryValue)));
+          }
+        } else {
+          sections.set(key, ContentCache.hash(JSON.stringify(value)));
+        }
+      }
+    } else {
+      const formatter = new GitIngestFormatter(this.projectRoot, calculator.stats, exportResults);
+      const summary = formatter.generateSummary();
+      const tree = formatter.generateTree();
+      const fileContents = formatter.generateFileContents();
+      content = summary + '\n' + tree + '\n' + fileContents;
+
+      sections.set('summary', ContentCache.hash(summary));
+      sections.set('tree', ContentCache.hash(tree));
+
+      const headers = [...fileContents.matchAll(FILE_HEADER_PATTERN)];
+      headers.forEach((match, i) => {
+        const end = i + 1 < headers.length ? headers[i + 1].index : fileContents.length;
+        sections.set(match[1], ContentCache.hash(fileContents.slice(match.index, end)));
+      });
+    }
+
+    return { content, sections, files: exportResults.filter(fileInfo => !fileInfo.error).length };
+  }
+
+  /**
+   * Write the output file, or the full context to the stream
+   * @param {string} content
+   */
+  write(content) {
+    const outputPath = this.getOutputPath();
+
+    if (outputPath) {
+      // Write-then-rename so readers never see a half-written file
+      const tempPath = `${outputPath}.tmp`;
+      fs.writeFileSync(tempPath, content, 'utf8');
+      fs.renameSync(tempPath, outputPath);
+    } else {
+      this.options.stream.write(content.endsWith('\n') ? content : content + '\n');
+    }
+  }
+
+  /**
+   * Stop pending regenerations
+   */
+  stop() {
+    clearTimeout(this.timer);
+    this.timer = null;
+    this.pending.clear();
+  }
+
+  /**
+   * Format a regeneration summary for the console
+   * @param {Object} summary - Result of regenerate()
+   * @param {number} maxListed - Section names listed per change type
+   * @returns {string}
+   */
+  static formatSummary(summary, maxListed = 10) {
+    const lines = [];
+    const target = summary.output || 'stdout';
+
+    if (!summary.written) {
+      lines.push(`⏸️  Context unchanged (${summary.changedFiles.join(', ')})`);
+      return lines.join('\n');
+    }
+
+    lines.push(`🔄 Context #${summary.generation} → ${target} (${summary.files} files, ` +
+      `${summary.tokens.toLocaleString()} tokens, ${summary.elapsed}ms)`);
+
+    if (summary.changedFiles.length > 0) {
+      lines.push(`   Changed: ${summary.changedFiles.join(', ')}`);
+    }
+    if (summary.parseCache?.entries > 0) {
+      lines.push(`   ${ParseCache.describe(summary.parseCache)}`);
+    }
+
+    if (summary.generation > 1) {
+      lines.push(`   Sections: ${summary.updated.length} updated, ${summary.added.length} added, ` +
+        `${summary.removed.length} removed (of ${summary.sections})`);
+
+      for (const [marker, names] of [['~', summary.updated], ['+', summary.added], ['-', summary.removed]]) {
+        for (const name of names.slice(0, maxListed)) {
+          lines.push(`   ${marker} ${name}`);
+        }
+        if (names.length > maxListed) {
+          lines.push(`   ${marker} … ${names.length - maxListed} more`);
+        }
+      }
+    }
+
+    return lines.join('\n');
+  }
+}
+
+/**
+ * Compare section hashes of two generations
+ * @param {Map<string, string>} before
+ * @param {Map<string, string>} after
+ * @returns {{added: Array<string>, updated: Array<string>, removed: Array<string>}}
+ */
+function diffSections(before, after) {
+  const added = [];
+  const updated = [];
+  const removed = [];
+
+  for (const [name, hash] of after) {
+    if (!before.has(name)) added.push(name);
+    else if (before.get(name) !== hash) updated.push(name);
+  }
+  for (const name of before.keys()) {
+    if (!after.has(name)) removed.push(name);
+  }
+
+  // Nothing to compare on the first run
+  if (before.size === 0) {
+    return { added: [], updated: [], removed: [] };
+  }
+
+  return { added, updated, removed };
+}
+
+export default ContextRegenerator;
@@ -17,6 +17,9 @@ import { getLogger } from '../utils/logger.js';
 
 const logger = getLogger('FileWatcher');
 
+// Same directories TokenCalculator never scans
+const SKIPPED_DIRECTORIES = ['node_modules', '.git', '.svn', '.hg', 'coverage', 'dist', 'build'];
+
 export class FileWatcher extends EventEmitter {
   constructor(rootPath, options = {}) {
     super();
@@ -25,7 +28,7 @@ export class FileWatcher extends EventEmitter {
     this.options = {
       debounce: 1000, // 1 second debounce
       recursive: true,
-      ignorePatterns: [],
+      ignorePatterns: [], // Path prefixes ('.ctxman', 'digest.txt') or RegExps
       ...options
     };
 
@@ -34,7 +37,7 @@ export class FileWatcher extends EventEmitter {
     const contextIgnorePath = path.join(rootPath, '.contextignore');
     const contextIncludePath = path.join(rootPath, '.contextinclude');
 
-    this.gitIgnore = new GitIgnoreParser(gitignorePath, contextIgnorePath, contextIncludePath);
+    this.gitIgnore = new GitIgnoreParser(gitignorePath, contextIgnorePath, contextIncludePath, { rootDir: rootPath });
     this.watchers = [];
     this.debounceTimers = new Map();
     this.isWatching = false;
@@ -176,8 +179,26 @@ export class FileWatcher extends EventEmitter {
    * @returns {boolean}
    */
   shouldIgnore(relativePath) {
+    const normalized = relativePath.split(path.sep).join('/');
+
+    if (normalized.split('/').some(segment => SKIPPED_DIRECTORIES.includes(segment))) {
+      return true;
+    }
+
+    const matchesPattern = this.options.ignorePatterns.some(pattern => (pattern instanceof RegExp
+      ? pattern.test(normalized)
+      : normalized === pattern || normalized.startsWith(`${pattern.replace(/\/$/, '')}/`)));
+    if (matchesPattern) {
+      return true;
+    }
+
     // Use GitIgnoreParser for ignore rules
-    return !this.gitIgnore.shouldIncludeFile(relativePath);
+    try {
+      return this.gitIgnore.isIgnored(path.join(this.rootPath, relativePath), relativePath);
+    } catch (error) {
+      // Deleted files cannot be stat'ed for include rules; let consumers decide
+      return false;
+    }
   }
 
   /**
@@ -9,6 +9,7 @@
  * - Optimize for performance
  */
 
+import path from 'path';
 import { EventEmitter } from 'events';
 import { Analyzer } from '../core/Analyzer.js';
 import { getLogger } from '../utils/logger.js';
@@ -0,0 +1,68 @@
+import { describe, test, expect } from 'vitest';
+import ApiDiff from '../lib/symbols/ApiDiff.js';
+
+const symbol = (file, qualifiedName, signature, fields = {}) => ({
+    file, qualifiedName, name: qualifiedName.split('.').pop(), kind: 'function', signature,
+    exported: true, startLine: 1, endLine: 3, ...fields
+});
+
+describe('ApiDiff', () => {
+    test('classifies added, removed, changed and moved symbols', () => {
+        const base = [
+            symbol('src/util.js', 'parse', 'export function parse(text)'),
+            symbol('src/util.js', 'legacy', 'export function legacy()', { startLine: 5 }),
+            symbol('src/util.js', 'format', 'export function format(value)', { startLine: 9 }),
+            symbol('src/api.ts', 'Client.get', 'get(url: string): Promise<Response>', { kind: 'method' })
+        ];
+        const head = [
+            symbol('src/util.js', 'parse', 'export function parse(text, options = {})'),
+            symbol('src/format.js', 'format', 'export function   format(value)  {'),
+            symbol('src/api.ts', 'Client.get', 'get(url: string): Promise<Response>', { kind: 'method' }),
+            symbol('src/util.js', 'stringify', 'export function stringify(value)', { startLine: 7 })
+        ];
+
+        const changes = new ApiDiff().compare(base, head);
+        expect(changes.map(entry => [entry.change, entry.name, entry.file])).toEqual([
+            ['moved', 'format', 'src/format.js'],
+            ['changed', 'parse', 'src/util.js'],
+            ['removed', 'legacy', 'src/util.js'],
+            ['added', 'stringify', 'src/util.js']
+        ]);
+        expect(changes[0]).toMatchObject({ from: 'src/util.js', before: 'export function format(value)', after: 'export function format(value)' });
+        expect(changes[1]).toMatchObject({ before: 'export function parse(text)', after: 'export function parse(text, options = {})' });
+        expect(changes[2].after).toBeNull();
+    });
+
+    test('compares overloads as a set and skips test files', () => {
+        const overloads = signatures => signatures.map(signature => symbol('src/api.ts', 'get', signature));
+        const diff = new ApiDiff();
+        expect(diff.compare(overloads(['get(a: string)', 'get(a: number)']), overloads(['get(a: number)', 'get(a: string)']))).toEqual([]);
+        expect(diff.compare(overloads(['get(a: string)']), overloads(['get(a: string)', 'get(a: number)']))[0].after)
+            .toBe('get(a: number)\nget(a: string)');
+
+        const graph = {
+            files: new Map([
+                ['pkg/calc/calc.go', { symbols: [symbol('pkg/calc/calc.go', 'Add', 'func Add(a, b int) int'), symbol('pkg/calc/calc.go', 'sub', 'func sub()', { exported: false })] }],
+                ['pkg/calc/calc_test.go', { symbols: [symbol('pkg/calc/calc_test.go', 'TestAdd', 'func TestAdd(t *testing.T)')] }]
+            ])
+        };
+        expect(diff.exportedSymbols(graph).map(entry => entry.qualifiedName)).toEqual(['Add']);
+        expect(new ApiDiff({ tests: true }).exportedSymbols(graph)).toHaveLength(2);
+    });
+
+    test('reports old and new signatures', () => {
+        const changes = new ApiDiff().compare(
+            [symbol('src/util.js', 'parse', 'export function parse(text)')],
+            [symbol('src/util.js', 'parse', 'export function parse(text, options)')]);
+        const scope = { base: 'v1.2.0 (3f2a9c1b7d4e)', head: null, changes, callSites: [{ file: 'src/main.js', line: 4 }] };
+
+        expect(ApiDiff.formatHeader(scope)).toBe([
+            'API changes: v1.2.0 (3f2a9c1b7d4e) → working tree (1 symbols, 1 call sites)',
+            '  changed function parse (src/util.js:1)',
+            '    - export function parse(text)',
+            '    + export function parse(text, options)',
+            ''
+        ].join('\n'));
+        expect(ApiDiff.format(scope)).toContain('   Call sites: 1 in 1 files');
+    });
+});
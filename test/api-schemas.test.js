@@ -0,0 +1,175 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import SymbolExtractor from '../lib/symbols/SymbolExtractor.js';
+import { SymbolKind } from '../lib/symbols/SymbolModel.js';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import DependencyExpander from '../lib/graph/DependencyExpander.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+
+const PROTO = [
+    'syntax = "proto3";',
+    '',
+    'package shop.v1;',
+    '',
+    'import "shop/v1/common.proto";',
+    '',
+    '// A customer order.',
+    'message Order {',
+    '  string id = 1;',
+    '  Money total = 2;',
+    '}',
+    '',
+    'enum Status {',
+    '  STATUS_UNSPECIFIED = 0;',
+    '}',
+    '',
+    '// Orders API.',
+    'service OrderService {',
+    '  // Fetches an order.',
+    '  rpc GetOrder(GetOrderRequest) returns (Order);',
+    '  rpc Watch(WatchRequest) returns (stream Order) {',
+    '    option deprecated = true;',
+    '  }',
+    '}',
+    ''
+].join('\n');
+
+const OPENAPI = [
+    'openapi: 3.0.3',
+    'info:',
+    '  title: Pet Store',
+    '  version: "1.2"',
+    '  description: |',
+    '    Manage pets.',
+    'paths:',
+    '  /pets/{petId}:',
+    '    parameters:',
+    '      - name: petId',
+    '        in: path',
+    '    get:',
+    '      operationId: getPet',
+    '      summary: Fetch a pet # shown in listings',
+    '      responses:',
+    "        '200':",
+    "          $ref: './responses.yaml#/PetResponse'",
+    '    delete:',
+    '      summary: Remove a pet',
+    '      responses:',
+    "        '204': { description: gone }",
+    'components:',
+    '  schemas:',
+    '    Pet:',
+    '      type: object',
+    '      properties:',
+    '        id:',
+    '          type: integer',
+    '        owner:',
+    "          $ref: '#/components/schemas/Owner'",
+    '    Status:',
+    '      type: string',
+    '      enum: [available, sold]',
+    ''
+].join('\n');
+
+const SWAGGER = JSON.stringify({
+    swagger: '2.0',
+    info: { title: 'Legacy', version: '1' },
+    paths: { '/users': { post: { operationId: 'createUser', description: 'Creates a user.' } } },
+    definitions: { User: { type: 'object', properties: { name: { type: 'string' } } } }
+}, null, 2);
+
+function summary(symbols) {
+    return symbols.map(symbol => [symbol.kind, symbol.qualifiedName, symbol.startLine, symbol.endLine]);
+}
+
+describe('API schema extraction', () => {
+    const extractor = new SymbolExtractor({ backend: 'heuristic' });
+
+    test('extracts protobuf messages, enums and service methods', () => {
+        const symbols = extractor.extract(PROTO, 'proto/shop/v1/order.proto');
+        expect(summary(symbols)).toEqual([
+            [SymbolKind.MODULE, 'shop.v1', 3, 3],
+            [SymbolKind.STRUCT, 'Order', 8, 11],
+            [SymbolKind.ENUM, 'Status', 13, 15],
+            [SymbolKind.INTERFACE, 'OrderService', 18, 24],
+            [SymbolKind.METHOD, 'OrderService.GetOrder', 20, 20],
+            [SymbolKind.METHOD, 'OrderService.Watch', 21, 23]
+        ]);
+        expect(symbols.every(symbol => symbol.exported)).toBe(true);
+        expect(symbols[1].doc).toBe('A customer order.');
+        expect(symbols[4]).toMatchObject({ signature: 'rpc GetOrder(GetOrderRequest) returns (Order)', doc: 'Fetches an order.' });
+    });
+
+    test('extracts OpenAPI operations and schemas from YAML', () => {
+        const symbols = extractor.extract(OPENAPI, 'api/openapi.yaml');
+        expect(summary(symbols)).toEqual([
+            [SymbolKind.MODULE, 'Pet Store', 1, 1],
+            [SymbolKind.FUNCTION, 'getPet', 12, 17],
+            [SymbolKind.FUNCTION, 'DELETE /pets/{petId}', 18, 21],
+            [SymbolKind.STRUCT, 'Pet', 24, 30],
+            [SymbolKind.ENUM, 'Status', 31, 33]
+        ]);
+        expect(symbols[0]).toMatchObject({ signature: 'openapi 3.0.3 - Pet Store - 1.2', doc: 'Manage pets.' });
+        expect(symbols[1]).toMatchObject({ signature: 'GET /pets/{petId} (getPet) - Fetch a pet', doc: 'Fetch a pet' });
+        expect(symbols[3].signature).toBe('schema Pet { id: integer, owner: Owner }');
+    });
+
+    test('extracts Swagger 2 documents from JSON and ignores other data files', () => {
+        expect(summary(extractor.extract(SWAGGER, 'swagger.json'))).toEqual([
+            [SymbolKind.MODULE, 'Legacy', 2, 2],
+            [SymbolKind.FUNCTION, 'createUser', 9, 12],
+            [SymbolKind.STRUCT, 'User', 16, 23]
+        ]);
+        expect(extractor.extract('{\n  "name": "pkg",\n  "version": "1.0.0"\n}\n', 'package.json')).toEqual([]);
+        expect(extractor.extract('services:\n  web:\n    image: nginx\n', 'docker-compose.yml')).toEqual([]);
+        expect(extractor.extract('{ "openapi": ', 'broken.json')).toEqual([]);
+    });
+
+    test('summarizes schemas for the signatures tier', () => {
+        const budget = new TokenBudget({ maxTokens: 1000 });
+        expect(budget.summaryFor(OPENAPI, 'api/openapi.yaml', 'names'))
+            .toBe('// Exports: getPet, DELETE /pets/{petId}, Pet, Status');
+        expect(budget.summaryFor(PROTO, 'order.proto', 'signatures')).toContain('  rpc GetOrder(GetOrderRequest) returns (Order)');
+    });
+
+    describe('dependency graph', () => {
+        let root;
+        const FILES = {
+            'proto/shop/v1/order.proto': PROTO,
+            'proto/shop/v1/common.proto': 'syntax = "proto3";\n\nmessage Money {\n  int64 units = 1;\n}\n',
+            'api/openapi.yaml': OPENAPI,
+            'api/responses.yaml': 'openapi: 3.0.3\ncomponents:\n  schemas:\n    PetResponse:\n      type: object\n'
+        };
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-schemas-'));
+            for (const [file, content] of Object.entries(FILES)) {
+                fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+                fs.writeFileSync(path.join(root, file), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('resolves proto imports and external $refs', () => {
+            const graph = new DependencyGraph({ root }).build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+            expect(graph.getDependencies('proto/shop/v1/order.proto')).toEqual(['proto/shop/v1/common.proto']);
+            expect(graph.getDependencies('api/openapi.yaml')).toEqual(['api/responses.yaml']);
+            expect(graph.getFile('api/openapi.yaml').imports[0]).toMatchObject({ names: ['PetResponse'], line: 17 });
+        });
+
+        test('expands operations to the schemas they $ref', () => {
+            const graph = new DependencyGraph({ root }).build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+            const expander = new DependencyExpander(graph);
+            const expansion = expander.expand(expander.resolveFocus('api/openapi.yaml:getPet'), 1);
+            expect(expansion.files.map(({ file, symbols }) => [file, symbols.map(symbol => symbol.name)])).toEqual([
+                ['api/openapi.yaml', ['getPet']],
+                ['api/responses.yaml', ['PetResponse']]
+            ]);
+        });
+    });
+});
@@ -0,0 +1,206 @@
+import { describe, test, expect, afterAll } from 'vitest';
+import crypto from 'crypto';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import zlib from 'zlib';
+import ArchiveSource from '../lib/integrations/artifact/ArchiveSource.js';
+import ContainerImage from '../lib/integrations/artifact/ContainerImage.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+// ustar archive of { path, data?, type?: '0'|'2'|'5', linkname? } entries
+function tar(entries) {
+    const blocks = entries.map(({ path: name, data = '', type = '0', linkname = '' }) => {
+        const body = Buffer.from(type === '0' ? data : '');
+        const header = Buffer.alloc(512);
+        header.write(name, 0, 100);
+        header.write('0000644\0', 100);
+        header.write(`${body.length.toString(8).padStart(11, '0')}\0`, 124);
+        header.write(type, 156);
+        header.write(linkname, 157, 100);
+        header.write('ustar\u000000', 257);
+        header.write('        ', 148);
+        const sum = header.reduce((total, byte) => total + byte, 0);
+        header.write(`${sum.toString(8).padStart(6, '0')}\0 `, 148);
+        return Buffer.concat([header, body, Buffer.alloc((512 - (body.length % 512)) % 512)]);
+    });
+    return Buffer.concat([...blocks, Buffer.alloc(1024)]);
+}
+
+// Zip of stored entries
+function zip(entries) {
+    const locals = [];
+    const central = [];
+    let offset = 0;
+    for (const { path: name, data } of entries) {
+        const body = Buffer.from(data);
+        const local = Buffer.alloc(30);
+        local.writeUInt32LE(0x04034b50, 0);
+        local.writeUInt32LE(body.length, 18);
+        local.writeUInt32LE(body.length, 22);
+        local.writeUInt16LE(name.length, 26);
+        const record = Buffer.alloc(46);
+        record.writeUInt32LE(0x02014b50, 0);
+        record.writeUInt32LE(body.length, 20);
+        record.writeUInt32LE(body.length, 24);
+        record.writeUInt16LE(name.length, 28);
+        record.writeUInt32LE(offset, 42);
+        locals.push(local, Buffer.from(name), body);
+        central.push(record, Buffer.from(name));
+        offset += 30 + name.length + body.length;
+    }
+    const directory = Buffer.concat(central);
+    const end = Buffer.alloc(22);
+    end.writeUInt32LE(0x06054b50, 0);
+    end.writeUInt16LE(entries.length, 8);
+    end.writeUInt16LE(entries.length, 10);
+    end.writeUInt32LE(directory.length, 12);
+    end.writeUInt32LE(offset, 16);
+    return Buffer.concat([...locals, directory, end]);
+}
+
+const digestOf = data => `sha256:${crypto.createHash('sha256').update(data).digest('hex')}`;
+const listFiles = dir => fs.readdirSync(dir, { recursive: true })
+    .filter(name => fs.statSync(path.join(dir, name)).isFile())
+    .map(name => name.split(path.sep).join('/'))
+    .sort();
+
+describe('ArchiveSource', () => {
+    const dirs = [];
+    const scratch = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-archive-test-'));
+    const checkout = async (name, data, options) => {
+        fs.writeFileSync(path.join(scratch, name), data);
+        const result = await ArchiveSource.parse(name, scratch).checkout(options);
+        dirs.push(result.dir);
+        return result;
+    };
+
+    afterAll(() => {
+        for (const dir of [scratch, ...dirs]) fs.rmSync(dir, { recursive: true, force: true });
+    });
+
+    test('parses archive paths and URLs', () => {
+        expect(ArchiveSource.isArchive('dist/app-1.2.0.tar.gz')).toBe(true);
+        expect(ArchiveSource.isArchive('https://example.com/app.zip?download=1')).toBe(true);
+        expect(ArchiveSource.isArchive('acme/app@v1.2.0')).toBe(false);
+        expect(ArchiveSource.parse('dist/app-1.2.0.tar.gz', '/work')).toMatchObject({ location: '/work/dist/app-1.2.0.tar.gz', url: null, name: 'app-1.2.0' });
+        expect(ArchiveSource.parse('https://example.com/r/app%201.tgz').name).toBe('app 1');
+        expect(() => ArchiveSource.parse('app.rar')).toThrow('Not an archive');
+    });
+
+    test('extracts a gzipped tar, using its single top-level directory as the root', async () => {
+        const data = zlib.gzipSync(tar([
+            { path: 'app-1.0/', type: '5' },
+            { path: 'app-1.0/src/index.js', data: 'export default 1;\n' },
+            { path: 'app-1.0/current', type: '2', linkname: '/etc/passwd' },
+            { path: 'app-1.0/../../escape.txt', data: 'outside' }
+        ]));
+        const result = await checkout('app-1.0.tar.gz', data);
+        expect(result.method).toBe('tar');
+        expect(path.basename(result.root)).toBe('app-1.0');
+        expect(listFiles(result.root)).toEqual(['src/index.js']);
+        expect(result.stats).toMatchObject({ files: 1, skipped: 2 });
+        expect(fs.existsSync(path.join(result.dir, '..', 'escape.txt'))).toBe(false);
+    });
+
+    test('extracts zips and refuses archives beyond the size limit', async () => {
+        const data = zip([{ path: 'README.md', data: '# App\n' }, { path: 'lib/util.js', data: 'module.exports = {};\n' }]);
+        const result = await checkout('bundle.zip', data);
+        expect(result.method).toBe('zip');
+        expect(path.basename(result.root)).toBe('bundle');
+        expect(listFiles(result.root)).toEqual(['README.md', 'lib/util.js']);
+
+        await expect(checkout('big.zip', data, { maxBytes: 10 })).rejects.toThrow('Archive expands beyond');
+    });
+
+    describe('TokenCalculator integration', () => {
+        test('analyzes an extracted archive like a checkout', async () => {
+            const data = zlib.gzipSync(tar([
+                { path: 'lib-2.0/package.json', data: '{ "name": "lib" }\n' },
+                { path: 'lib-2.0/src/main.js', data: 'export function main() { return 42; }\n' }
+            ]));
+            const source = path.join(os.tmpdir(), `ctxman-lib-${process.pid}.tgz`);
+            fs.writeFileSync(source, data);
+            const result = await ArchiveSource.parse(source).checkout();
+            try {
+                const calculator = new TokenCalculator(result.root, {});
+                const analyzed = calculator.analyzeFiles(calculator.scanProject());
+                expect(analyzed.map(fileInfo => fileInfo.relativePath.split(path.sep).join('/')).sort()).toEqual(['package.json', 'src/main.js']);
+                expect(analyzed.every(fileInfo => fileInfo.tokens > 0)).toBe(true);
+            } finally {
+                fs.rmSync(result.dir, { recursive: true, force: true });
+                fs.rmSync(source, { force: true });
+            }
+        });
+    });
+});
+
+describe('ContainerImage', () => {
+    test('parses image references', () => {
+        expect(ContainerImage.parse('docker://nginx')).toMatchObject({ registry: 'registry-1.docker.io', repository: 'library/nginx', tag: 'latest' });
+        expect(ContainerImage.parse('docker://docker.io/bitnami/redis:7.2')).toMatchObject({ repository: 'bitnami/redis', tag: '7.2' });
+        const digest = `sha256:${'a'.repeat(64)}`;
+        const image = ContainerImage.parse(`oci://ghcr.io/acme/app@${digest}`);
+        expect(image).toMatchObject({ registry: 'ghcr.io', repository: 'acme/app', tag: null, digest, name: 'app' });
+        expect(image.describe()).toBe('ghcr.io/acme/app@sha256:aaaaaaaaaaaa…');
+        expect(ContainerImage.parse('docker://localhost:5000/tools:dev').registry).toBe('localhost:5000');
+        expect(() => ContainerImage.parse('docker://Bad_Ref')).toThrow('Not an image reference');
+    });
+
+    test('pulls the platform image of an index and applies its layers with whiteouts', async () => {
+        const base = zlib.gzipSync(tar([
+            { path: 'etc/os-release', data: 'ID=test\n' },
+            { path: 'app/old.js', data: 'old' },
+            { path: 'app/keep.js', data: 'keep' },
+            { path: 'cache/a.tmp', data: 'a' }
+        ]));
+        const top = zlib.gzipSync(tar([
+            { path: 'app/.wh.old.js' },
+            { path: 'cache/.wh..wh..opq' },
+            { path: 'cache/b.tmp', data: 'b' },
+            { path: 'app/server.js', data: 'listen();\n' }
+        ]));
+        const manifest = Buffer.from(JSON.stringify({
+            mediaType: 'application/vnd.oci.image.manifest.v1+json',
+            layers: [{ digest: digestOf(base) }, { digest: digestOf(top) }]
+        }));
+        const index = Buffer.from(JSON.stringify({
+            mediaType: 'application/vnd.oci.image.index.v1+json',
+            manifests: [
+                { digest: `sha256:${'0'.repeat(64)}`, platform: { os: 'linux', architecture: 'amd64' } },
+                { digest: digestOf(manifest), platform: { os: 'linux', architecture: 'arm64', variant: 'v8' } }
+            ]
+        }));
+        const objects = {
+            'manifests/1.0': index,
+            [`manifests/${digestOf(manifest)}`]: manifest,
+            [`blobs/${digestOf(base)}`]: base,
+            [`blobs/${digestOf(top)}`]: top
+        };
+        const requests = [];
+        const fetch = async (url, init) => {
+            requests.push(url);
+            if (url.startsWith('https://auth.example.com/')) return Response.json({ token: 'pull-token' });
+            if (init.headers.Authorization !== 'Bearer pull-token') {
+                return new Response('', { status: 401, headers: { 'WWW-Authenticate': 'Bearer realm="https://auth.example.com/token",service="registry.example.com"' } });
+            }
+            const object = objects[url.replace('https://registry.example.com/v2/acme/app/', '')];
+            return object ? new Response(object) : new Response('', { status: 404 });
+        };
+
+        const result = await ContainerImage.parse('docker://registry.example.com/acme/app:1.0').checkout({ platform: 'linux/arm64', fetch });
+        try {
+            expect(result).toMatchObject({ method: 'image', commit: digestOf(manifest), stats: { layers: 2 } });
+            expect(listFiles(result.root)).toEqual(['app/keep.js', 'app/server.js', 'cache/b.tmp', 'etc/os-release']);
+            expect(requests[1]).toBe('https://auth.example.com/token?service=registry.example.com&scope=repository%3Aacme%2Fapp%3Apull');
+
+            await expect(ContainerImage.parse('docker://registry.example.com/acme/app:1.0').checkout({ platform: 'linux/s390x', fetch }))
+                .rejects.toThrow('has no linux/s390x image (available: linux/amd64, linux/arm64/v8)');
+            objects[`blobs/${digestOf(top)}`] = base;
+            await expect(ContainerImage.parse('docker://registry.example.com/acme/app:1.0').checkout({ platform: 'linux/arm64', fetch }))
+                .rejects.toThrow('is corrupt (digest mismatch)');
+        } finally {
+            fs.rmSync(result.dir, { recursive: true, force: true });
+        }
+    });
+});
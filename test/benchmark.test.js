@@ -0,0 +1,112 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import zlib from 'zlib';
+import Benchmark, { contextPipeline, BENCH_PHASES } from '../lib/core/Benchmark.js';
+import { cpuProfileToPprof, heapProfileToPprof } from '../lib/core/Pprof.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const CPU_PROFILE = {
+    nodes: [
+        { id: 1, callFrame: { functionName: '(root)', url: '', lineNumber: -1 }, children: [2] },
+        { id: 2, callFrame: { functionName: 'scanProject', url: 'file:///app/lib/scan.js', lineNumber: 9 }, children: [3] },
+        { id: 3, callFrame: { functionName: 'readFileSync', url: 'node:fs', lineNumber: 41 } }
+    ],
+    samples: [2, 3, 3],
+    timeDeltas: [100, 250, 250],
+    startTime: 0,
+    endTime: 600
+};
+
+describe('Benchmark', () => {
+    test('times each phase and passes outputs along', async () => {
+        let runs = 0;
+        const report = await new Benchmark({ iterations: 3, warmup: 1 }).run(() => {
+            runs++;
+            return [
+                { name: 'scan', run: () => ['a.js', 'b.js'], describe: files => `${files.length} files` },
+                { name: 'format', run: files => { console.log('noise'); return files.join('\n'); }, describe: text => `${text.length} chars` }
+            ];
+        });
+
+        expect(runs).toBe(4);
+        expect(report.iterations).toBe(3);
+        expect(report.phases.map(phase => [phase.name, phase.detail])).toEqual([['scan', '2 files'], ['format', '9 chars']]);
+        expect(report.phases[0].min).toBeLessThanOrEqual(report.phases[0].median);
+        expect(report.total.median).toBeGreaterThanOrEqual(0);
+        expect(report.peakRss).toBeGreaterThan(0);
+
+        const text = Benchmark.formatReport(report);
+        expect(text).toContain('Benchmark (3 iterations after 1 warmup)');
+        expect(text).toMatch(/^scan\s+[\d.]+ ms/m);
+        expect(text).toContain('Peak RSS:');
+    });
+
+    test('samples the allocations and read buffers of each phase', async () => {
+        let buffers = 0;
+        const report = await new Benchmark({ allocations: true }).run(() => [
+            { name: 'scan', run: () => [], buffers: () => buffers },
+            { name: 'analyze', run: () => { buffers += 4096; return Array.from({ length: 20000 }, (_, i) => ({ i })); }, buffers: () => buffers }
+        ]);
+
+        expect(report.phases[1].allocated).toBeGreaterThan(report.phases[0].allocated);
+        expect(report.phases.map(phase => phase.buffers)).toEqual([0, 4096]);
+        expect(Benchmark.formatReport(report)).toMatch(/rss\s+alloc\s+buffers/);
+        await expect(new Benchmark({ allocations: true, heapProfile: 'heap.pb.gz' }).run(() => [])).rejects.toThrow('cannot be sampled together');
+    });
+
+    test('encodes CPU and heap profiles as gzipped pprof', () => {
+        const cpu = zlib.gunzipSync(cpuProfileToPprof(CPU_PROFILE));
+        // Field 1 (sample_type) comes first, as a length-delimited message
+        expect(cpu[0]).toBe(0x0a);
+        const strings = cpu.toString('latin1');
+        for (const text of ['samples', 'nanoseconds', 'scanProject', 'readFileSync', '/app/lib/scan.js']) {
+            expect(strings).toContain(text);
+        }
+        expect(strings).not.toContain('(root)');
+
+        const heap = zlib.gunzipSync(heapProfileToPprof({
+            head: { id: 1, callFrame: { functionName: '(root)' }, selfSize: 0, children: [
+                { id: 2, callFrame: { functionName: 'buildGraph', url: 'file:///app/graph.js', lineNumber: 3 }, selfSize: 4096, children: [] }
+            ] },
+            samples: [{ nodeId: 2, size: 4096 }]
+        })).toString('latin1');
+        expect(heap).toContain('space');
+        expect(heap).toContain('buildGraph');
+    });
+
+    describe('context pipeline', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-bench-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src', 'math.js'), 'export function add(a, b) {\n    return a + b;\n}\n');
+            fs.writeFileSync(path.join(root, 'src', 'main.js'), "import { add } from './math.js';\nexport function two() {\n    return add(1, 1);\n}\n");
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('runs every phase against a project and writes profiles', async () => {
+            const cpuProfile = path.join(root, 'profiles', 'cpu.pb.gz');
+            const heapProfile = path.join(root, 'profiles', 'heap.heapprofile');
+            const report = await new Benchmark({ cpuProfile, heapProfile })
+                .run(() => contextPipeline(new TokenCalculator(root, {})));
+
+            expect(report.phases.map(phase => phase.name)).toEqual(BENCH_PHASES);
+            const [scan, analyze, parse, pack, format] = report.phases.map(phase => phase.detail);
+            expect([scan, parse, pack]).toEqual(['2 files', '2 symbols, 1 imports resolved', '2 files selected']);
+            expect(analyze).toMatch(/^\d+ tokens$/);
+            expect(format).toMatch(/^[\d.]+ KB digest$/);
+            expect(report.profiles).toEqual([
+                { kind: 'cpu', file: cpuProfile, format: 'pprof' },
+                { kind: 'heap', file: heapProfile, format: 'v8' }
+            ]);
+            expect(zlib.gunzipSync(fs.readFileSync(cpuProfile)).length).toBeGreaterThan(0);
+            expect(JSON.parse(fs.readFileSync(heapProfile, 'utf8')).head).toBeDefined();
+        });
+    });
+});
@@ -0,0 +1,106 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import BodyElider, { parseElisionRules } from '../lib/core/BodyElider.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('BodyElider', () => {
+    const source = [
+        '/**',
+        ' * Adds two numbers',
+        ' */',
+        'export function add(a,',
+        '    b) {',
+        '    const sum = a + b;',
+        '    return sum;',
+        '}',
+        '',
+        'export class Store {',
+        '    get(key) {',
+        '        return this.map.get(key);',
+        '    }',
+        '',
+        '    load(file) {',
+        '        const parse = text => {',
+        '            return JSON.parse(text);',
+        '        };',
+        '        return parse(file);',
+        '    }',
+        '}',
+        ''
+    ].join('\n');
+
+    test('keeps signatures and docs and elides long bodies', () => {
+        const elided = new BodyElider({ lines: 1 }).elide(source, 'src/store.js');
+
+        expect(elided.content).toBe([
+            '/**',
+            ' * Adds two numbers',
+            ' */',
+            'export function add(a,',
+            '    b) {',
+            '    /* ... 2 lines elided ... */',
+            '}',
+            '',
+            'export class Store {',
+            '    get(key) {',
+            '        return this.map.get(key);',
+            '    }',
+            '',
+            '    load(file) {',
+            '        /* ... 4 lines elided ... */',
+            '    }',
+            '}',
+            ''
+        ].join('\n'));
+        expect(elided).toMatchObject({ bodies: 2, lines: 6 });
+        expect(new BodyElider({ lines: 4 }).elide(source, 'src/store.js')).toBeNull();
+    });
+
+    test('keeps Python docstrings and uses hash comments', () => {
+        const python = 'def total(items):\n    """Sum of items."""\n    result = 0\n    for item in items:\n        result += item\n    return result\n';
+        expect(new BodyElider({ lines: 2 }).elide(python, 'calc.py').content)
+            .toBe('def total(items):\n    """Sum of items."""\n    # ... 4 lines elided ...\n');
+    });
+
+    test('picks the limit of the longest matching directory', () => {
+        const rules = parseElisionRules(['20', 'src=10', './src/api/=off']);
+        expect(rules).toEqual({ lines: 20, directories: [{ directory: 'src', lines: 10 }, { directory: 'src/api', lines: 0 }] });
+
+        const elider = new BodyElider(rules);
+        expect(elider.limitFor('lib/a.js')).toBe(20);
+        expect(elider.limitFor('src/core/a.js')).toBe(10);
+        expect(elider.limitFor('src/api/a.js')).toBe(0);
+        expect(elider.limitFor('src/apis/a.js')).toBe(10);
+        expect(() => parseElisionRules(['src=many'])).toThrow('src=many (expected N or DIR=N');
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-elide-'));
+            fs.mkdirSync(path.join(root, 'src/api'), { recursive: true });
+            fs.writeFileSync(path.join(root, 'src/store.js'), source);
+            fs.writeFileSync(path.join(root, 'src/api/store.js'), source);
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('elides bodies outside kept directories', () => {
+            const calculator = new TokenCalculator(root, { bodyElider: new BodyElider(parseElisionRules(['1', 'src/api=0'])) });
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            const exported = calculator.applyBodyElision(results);
+            const byPath = new Map(exported.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+
+            expect(byPath.get('src/api/store.js').summary).toBeUndefined();
+            expect(byPath.get('src/store.js').summary).toContain('/* ... 4 lines elided ... */');
+            expect(byPath.get('src/store.js').selectionNote).toBe('2 bodies elided (6 lines)');
+            expect(byPath.get('src/store.js').tokens).toBeLessThan(byPath.get('src/api/store.js').tokens);
+            expect(calculator.elision.bodies).toBe(2);
+        });
+    });
+});
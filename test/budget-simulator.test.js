@@ -0,0 +1,79 @@
+import { describe, test, expect } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import BudgetSimulator from '../lib/core/BudgetSimulator.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const PY_SOURCE = [
+    'def small():',
+    '    return 1',
+    '',
+    'def huge():',
+    `    return "${'x'.repeat(400)}"`,
+    ''
+].join('\n');
+
+describe('BudgetSimulator', () => {
+    test('parses token counts and model names, smallest first', () => {
+        const budgets = BudgetSimulator.parseBudgets('128k, 32k,claude-sonnet');
+        expect(budgets.map(budget => budget.label)).toEqual(['32k', '128k', 'claude-sonnet (200k)']);
+        expect(budgets[2].maxTokens).toBe(200000);
+
+        expect(() => BudgetSimulator.parseBudgets('')).toThrow('Invalid --budgets');
+        expect(() => BudgetSimulator.parseBudgets('32k,huge')).toThrow('Invalid --budgets entry: huge');
+        expect(() => BudgetSimulator.parseBudgets('32k,32000')).toThrow('Duplicate budget');
+    });
+
+    test('packs the same items at every budget and reports where they differ', async () => {
+        const budget = await TokenBudget.create({ maxTokens: 1, tokenizer: 'estimate' });
+        const simulator = new BudgetSimulator({ budgets: BudgetSimulator.parseBudgets('50,100,200') });
+        const items = [
+            { id: 'lib/core.js', tokens: 40, priority: 10 },
+            { id: 'lib/big.js', tokens: 90, priority: 5 },
+            { id: 'docs/guide.md', tokens: 50, priority: 0 }
+        ];
+        let expanded = 0;
+        const simulation = simulator.simulate(budget, items, {
+            expand: item => {
+                expanded++;
+                return item.id === 'lib/big.js' ? [{ id: 'run', tokens: 10, startLine: 1, endLine: 2 }] : [];
+            }
+        });
+
+        expect(simulation.budgets.map(({ plan }) => plan.usedTokens)).toEqual([50, 100, 180]);
+        expect(simulation.rows.map(row => row.cells.map(cell => cell.tier))).toEqual([
+            ['full', 'full', 'full'],
+            ['symbols', 'symbols', 'full'],
+            [null, 'full', 'full']
+        ]);
+        expect(expanded).toBe(2);
+
+        const report = simulator.format(simulation);
+        expect(report).toContain('Every file fits whole from 200 on');
+        expect(report).toMatch(/lib\/big\.js \(90\)\s+symbols 10\s+symbols 10\s+full/);
+        expect(report).not.toMatch(/lib\/core\.js \(40\)/);
+        expect(report).toContain('Same at every budget: 1 whole, 0 trimmed, summarized or dropped');
+    });
+
+    test('exports nothing from a calculator run', async () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-simulate-'));
+        fs.mkdirSync(path.join(root, 'lib'));
+        fs.writeFileSync(path.join(root, 'lib', 'big.py'), PY_SOURCE);
+
+        try {
+            const tokenBudget = await TokenBudget.create({ maxTokens: 1000, tokenizer: 'estimate' });
+            const budgetSimulator = new BudgetSimulator({ budgets: BudgetSimulator.parseBudgets('20,1000') });
+            const calculator = new TokenCalculator(root, { tokenBudget, budgetSimulator, dashboard: true });
+            const results = [calculator.analyzeFile(path.join(root, 'lib', 'big.py'))];
+
+            expect(calculator.selectExportResults(results)).toBeNull();
+            const [small, large] = calculator.budgetSimulation.budgets;
+            expect(small.plan.partial[0].symbols.map(symbol => symbol.name)).toEqual(['small']);
+            expect(large.plan.included.map(item => item.id)).toEqual([path.join('lib', 'big.py')]);
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
@@ -0,0 +1,84 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import CodeOwners from '../lib/core/CodeOwners.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+const CODEOWNERS = [
+    '# Default owners',
+    '*                    @acme/core',
+    '/src/payments/       @acme/team-payments @alice',
+    'src/payments/vendor/',
+    'docs/*               @acme/Docs  # not docs/api/',
+    '*.sql                dba@acme.com',
+    '',
+    '[Frontend][2] @acme/frontend',
+    'src/web/',
+    '/src/web/legacy/     @bob'
+].join('\n');
+
+describe('CodeOwners', () => {
+    const owners = new CodeOwners(CODEOWNERS, '.github/CODEOWNERS');
+
+    test('names the owners of the last matching rule of each section', () => {
+        expect(owners.ownersOf('src/payments/charge.js')).toEqual(['@acme/team-payments', '@alice']);
+        expect(owners.ownersOf('src/payments/vendor/stripe.js')).toEqual([]);
+        expect(owners.ownersOf('lib/util.js')).toEqual(['@acme/core']);
+        expect(owners.ownersOf('docs/guide.md')).toEqual(['@acme/Docs']);
+        expect(owners.ownersOf('docs/api/index.md')).toEqual(['@acme/core']);
+        expect(owners.ownersOf('db/schema.sql')).toEqual(['dba@acme.com']);
+        // Sections add up; a rule without owners takes the section's defaults
+        expect(owners.ownersOf('src/web/app.js')).toEqual(['@acme/core', '@acme/frontend']);
+        expect(owners.ownersOf('src/web/legacy/old.js')).toEqual(['@acme/core', '@bob']);
+        expect(CodeOwners.formatNote(owners.ownersOf('src/payments/vendor/stripe.js'))).toBe('Owners: none');
+    });
+
+    test('matches owner selectors', () => {
+        expect(owners.isOwnedBy('src/payments/charge.js', ['@team-payments'])).toBe(true);
+        expect(owners.isOwnedBy('src/payments/charge.js', ['team-payments'])).toBe(true);
+        expect(owners.isOwnedBy('docs/guide.md', ['@ACME/docs'])).toBe(true);
+        expect(owners.isOwnedBy('src/payments/charge.js', ['@other/team-payments'])).toBe(false);
+        expect(owners.isOwnedBy('lib/util.js', ['@team-payments', 'core'])).toBe(true);
+        expect(owners.names('dba@acme.com')).toBe(true);
+        expect(owners.names('@payments')).toBe(false);
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-owners-'));
+            const files = {
+                '.github/CODEOWNERS': CODEOWNERS,
+                'src/payments/charge.js': 'export const charge = () => 1;\n',
+                'src/payments/refund.js': 'export const refund = () => 2;\n',
+                'lib/util.js': 'export const util = () => 3;\n'
+            };
+            for (const [file, content] of Object.entries(files)) {
+                fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+                fs.writeFileSync(path.join(root, file), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('selects the files of an owner and annotates their headers', () => {
+            expect(CodeOwners.find(root)).toBe('.github/CODEOWNERS');
+            const calculator = new TokenCalculator(root, { codeOwners: CodeOwners.load(root), owners: ['@team-payments'] });
+            const files = calculator.filterOwnedFiles(calculator.scanProject());
+            expect(files.map(file => path.relative(root, file).split(path.sep).join('/')).sort()).toEqual([
+                'src/payments/charge.js', 'src/payments/refund.js'
+            ]);
+            expect(calculator.ownedFiles).toMatchObject({ kept: 2, source: '.github/CODEOWNERS' });
+
+            const exported = calculator.applyOwnership(calculator.analyzeFiles(files));
+            expect(exported.map(fileInfo => fileInfo.owners)).toEqual([['@acme/team-payments', '@alice'], ['@acme/team-payments', '@alice']]);
+            const digest = new GitIngestFormatter(root, { totalFiles: 2, totalTokens: 10 }, exported).generateDigest();
+            expect(digest).toContain('FILE: src/payments/charge.js\nOwners: @acme/team-payments, @alice\n' + '='.repeat(48));
+        });
+    });
+});
@@ -0,0 +1,166 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import CommentTranslator from '../lib/core/CommentTranslator.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const TRANSLATIONS = {
+    'Berechnet die Summe der Werte und gibt sie zurück': 'Computes the sum of the values and returns it',
+    'Die Liste darf nicht leer sein': 'The list must not be empty',
+    '設定ファイルを読み込んで検証する': 'Reads and validates the configuration file',
+    'Проверяем, что пользователь существует': 'Check that the user exists'
+};
+
+// Answers chat completions or LibreTranslate requests from TRANSLATIONS
+const fakeFetch = (statuses = []) => {
+    const requests = [];
+    const fetch = async (url, options) => {
+        const body = JSON.parse(options.body);
+        requests.push({ url, headers: options.headers, body });
+        const status = statuses.shift() || 200;
+        if (status !== 200) {
+            return { ok: false, status, headers: new Map([['retry-after', '1']]), text: async () => 'Busy' };
+        }
+        if (url.endsWith('/translate')) {
+            return { ok: true, json: async () => ({ translatedText: body.q.map(text => TRANSLATIONS[text] || text) }) };
+        }
+        const lines = JSON.parse(body.messages[1].content);
+        return {
+            ok: true,
+            json: async () => ({
+                choices: [{ message: { content: '```json\n' + JSON.stringify(lines.map(text => TRANSLATIONS[text] || text)) + '\n```' } }],
+                usage: { prompt_tokens: 100, completion_tokens: 20 }
+            })
+        };
+    };
+    return { fetch, requests };
+};
+
+const SOURCE = [
+    '/**',
+    ' * Berechnet die Summe der Werte und gibt sie zurück',
+    ' */',
+    'export function sum(values) {',
+    '    // Die Liste darf nicht leer sein',
+    "    if (values.length === 0) throw new Error('leer');",
+    '    // Returns the total of all values',
+    '    return values.reduce((a, b) => a + b, 0);',
+    '}',
+    ''
+].join('\n');
+
+describe('CommentTranslator', () => {
+    test('detects non-English comments by script and common words', () => {
+        const translator = new CommentTranslator({ apiKey: 'key', cache: new ContentCache({ path: null }) });
+        expect(translator.detect('// Berechnet die Summe der Werte und gibt sie zurück')).toBe('de');
+        expect(translator.detect('# Vérifie que le fichier existe dans le dossier')).toBe('fr');
+        expect(translator.detect('// 設定ファイルを読み込んで検証する')).toBe('ja');
+        expect(translator.detect('// 读取配置文件并验证其中的内容')).toBe('zh');
+        expect(translator.detect('# Проверяем, что пользователь существует')).toBe('ru');
+        expect(translator.detect('// Returns the total of all values in the list')).toBeNull();
+        expect(translator.detect('// TODO: fix')).toBeNull();
+
+        const comments = translator.comments(SOURCE, 'src/sum.js');
+        expect(comments.map(comment => [comment.language, comment.lines.map(line => line.text)])).toEqual([
+            ['de', ['Berechnet die Summe der Werte und gibt sie zurück']],
+            ['de', ['Die Liste darf nicht leer sein']]
+        ]);
+        // String literals are not comments
+        expect(translator.comments("const message = 'Die Liste darf nicht leer sein';\n", 'a.js')).toEqual([]);
+    });
+
+    test('translates comment lines in place and caches them by hash', async () => {
+        const cache = new ContentCache({ path: null });
+        const { fetch, requests } = fakeFetch();
+        const translator = new CommentTranslator({ baseUrl: 'http://localhost:1234/v1/', apiKey: 'key', cache, fetch });
+        const python = 'def load(path):\n    """設定ファイルを読み込んで検証する"""\n    return open(path)\n';
+
+        const translated = await translator.translateAll([
+            { id: 'src/sum.js', content: SOURCE },
+            { id: 'app/config.py', content: python },
+            { id: 'src/english.js', content: '// Returns the total of all values\nexport const x = 1;\n' }
+        ]);
+        expect([...translated.keys()]).toEqual(['src/sum.js', 'app/config.py']);
+        expect(translated.get('src/sum.js').split('\n').slice(0, 5)).toEqual([
+            '/**',
+            ' * Computes the sum of the values and returns it',
+            ' */',
+            'export function sum(values) {',
+            '    // The list must not be empty'
+        ]);
+        expect(translated.get('app/config.py')).toBe('def load(path):\n    """Reads and validates the configuration file"""\n    return open(path)\n');
+        expect(requests[0].url).toBe('http://localhost:1234/v1/chat/completions');
+        expect(requests[0].headers.Authorization).toBe('Bearer key');
+        expect(translator.stats).toMatchObject({ translated: 2, comments: 3, lines: 3, cached: 0, requested: 2, languages: { de: 2, ja: 1 } });
+        expect(translator.apply(SOURCE, 'src/sum.js')).toBe(translated.get('src/sum.js'));
+        expect(translator.apply('changed', 'src/sum.js')).toBe('changed');
+
+        const again = new CommentTranslator({ baseUrl: 'http://localhost:1234/v1', cache, fetch });
+        await again.translateAll([{ id: 'src/sum.js', content: SOURCE }]);
+        expect(again.stats).toMatchObject({ translated: 1, lines: 2, cached: 2, requested: 0 });
+        expect(requests).toHaveLength(2);
+
+        const report = CommentTranslator.formatReport(translator);
+        expect(report).toContain('🌐 COMMENT TRANSLATION');
+        expect(report).toContain('3 in 2 files translated (de 2, ja 1)');
+        expect(report).toContain('3 (0 cached, 3 sent in 2 requests)');
+    });
+
+    test('talks to LibreTranslate, retries busy servers and keeps failed files', async () => {
+        const delays = [];
+        const { fetch, requests } = fakeFetch([503]);
+        const translator = new CommentTranslator({
+            api: 'libretranslate', cache: new ContentCache({ path: null }), fetch,
+            sleep: async ms => { delays.push(ms); }
+        });
+        expect(translator.missingKey()).toBe(false);
+
+        const translated = await translator.translateAll([{ id: 'users.rb', content: '# Проверяем, что пользователь существует\nUser.find(id)\n' }]);
+        expect(translated.get('users.rb')).toBe('# Check that the user exists\nUser.find(id)\n');
+        expect(requests[1]).toMatchObject({ url: 'http://localhost:5000/translate', body: { source: 'auto', target: 'en', format: 'text' } });
+        expect(delays).toEqual([1000]);
+
+        const broken = new CommentTranslator({
+            apiKey: 'key', cache: new ContentCache({ path: null }),
+            fetch: async () => ({ ok: true, json: async () => ({ choices: [{ message: { content: 'Sure! Here it is.' } }] }) })
+        });
+        expect((await broken.translateAll([{ id: 'src/sum.js', content: SOURCE }])).size).toBe(0);
+        expect(broken.stats.failed).toBe(1);
+        expect(() => new CommentTranslator({ api: 'deepl' })).toThrow('Invalid translation API: deepl');
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-translate-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src', 'sum.js'), SOURCE);
+            fs.writeFileSync(path.join(root, 'src', 'other.js'), 'export const other = 1;\n');
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('exports translated comments with their tokens recounted', async () => {
+            const { fetch } = fakeFetch();
+            const commentTranslator = new CommentTranslator({ apiKey: 'key', cache: new ContentCache({ path: null }), fetch });
+            const calculator = new TokenCalculator(root, { commentTranslator });
+            const analyzed = calculator.analyzeFiles(calculator.scanProject());
+            const before = analyzed.find(fileInfo => fileInfo.relativePath.endsWith('sum.js')).tokens;
+
+            const exported = await calculator.selectExportResults(analyzed);
+            const sum = exported.find(fileInfo => fileInfo.relativePath.endsWith('sum.js'));
+            expect(sum.tokens).not.toBe(before);
+            expect(calculator.commentTranslations).toMatchObject({ translated: 1, comments: 2 });
+
+            const digest = calculator.createGitIngestFormatter(exported).generateDigest();
+            expect(digest).toContain(' * Computes the sum of the values and returns it');
+            expect(digest).toContain("throw new Error('leer');");
+            expect(digest).not.toContain('Berechnet');
+        });
+    });
+});
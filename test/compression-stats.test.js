@@ -0,0 +1,95 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import CompressionStats, { COMPRESSION_SCHEMA } from '../lib/core/CompressionStats.js';
+import ContentStripper from '../lib/core/ContentStripper.js';
+import { BodyElider, parseElisionRules } from '../lib/core/BodyElider.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const file = (relativePath, tokens, extra = {}) => ({ relativePath, tokens, sizeBytes: tokens * 4, ...extra });
+
+describe('CompressionStats', () => {
+    test('totals the savings per transform, section and directory', () => {
+        const stats = new CompressionStats();
+        const api = file('src/api/server.js', 300, { strippedTokens: 50 });
+        const guide = file('docs/guide.md', 200);
+        const copy = file('src/api/copy.js', 100);
+
+        stats.record('elide', api, 120);
+        stats.record('dedupe', copy, 0);
+        stats.record('budget', guide, 40);
+        const result = stats.build([{ ...api, tokens: 120 }, { ...guide, tokens: 40 }, file('README.md', 30)]);
+
+        expect(result.schema).toBe(COMPRESSION_SCHEMA);
+        expect(result.transforms).toEqual(['strip', 'elide', 'dedupe', 'budget']);
+        expect(result.totals).toMatchObject({
+            files: 4,
+            rawTokens: 680,
+            finalTokens: 190,
+            saved: { strip: 50, elide: 180, dedupe: 100, budget: 160 },
+            changed: { strip: 1, elide: 1, dedupe: 1, budget: 1 }
+        });
+        expect(result.bySection.map(row => [row.name, row.rawTokens, row.finalTokens])).toEqual([
+            ['code', 450, 120],
+            ['docs', 230, 70]
+        ]);
+        expect(result.byDirectory.map(row => [row.name, row.saved.budget, row.finalTokens])).toEqual([
+            ['src/', 0, 120],
+            ['docs/', 160, 40],
+            ['.', 0, 30]
+        ]);
+
+        const report = CompressionStats.formatReport(result);
+        expect(report).toContain('Tokens: 680 raw → 190 final (72.1% saved) in 4 files');
+        expect(report).toMatch(/elide\s+180 tokens\s+26\.5% of raw, in 1 files/);
+    });
+
+    test('reports files no transform changed', () => {
+        const result = new CompressionStats().build([file('src/a.js', 10), { relativePath: 'src/b.js', error: 'EACCES' }]);
+        expect(result.transforms).toEqual([]);
+        expect(result.totals.files).toBe(1);
+        expect(CompressionStats.formatReport(result)).toContain('No transform removed anything');
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-compression-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src/store.js'), [
+                '// The store',
+                '',
+                'export function load(key) {',
+                '    // Read it',
+                '    const value = cache.get(key);',
+                '    if (!value) return null;',
+                '    return JSON.parse(value);',
+                '}',
+                ''
+            ].join('\n'));
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('attributes stripped and elided tokens and writes them as JSON', () => {
+            const calculator = new TokenCalculator(root, {
+                stripper: new ContentStripper({ mode: 'both' }),
+                bodyElider: new BodyElider(parseElisionRules(['1'])),
+                compressionStats: { file: 'compression.json' }
+            });
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            expect(results[0].strippedTokens).toBeGreaterThan(0);
+
+            calculator.reportCompression(calculator.applyBodyElision(results));
+            const written = JSON.parse(fs.readFileSync(path.join(root, 'compression.json'), 'utf8'));
+            expect(written.transforms).toEqual(['strip', 'elide']);
+            expect(written.totals.rawTokens - written.totals.finalTokens)
+                .toBe(written.totals.saved.strip + written.totals.saved.elide);
+            expect(written.byDirectory[0].name).toBe('src/');
+        });
+    });
+});
@@ -0,0 +1,149 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContentCache, { CONTENT_CACHE_VERSION } from '../lib/cache/ContentCache.js';
+import Analyzer from '../lib/core/Analyzer.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('ContentCache', () => {
+    let root;
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-cache-'));
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('hashes content with SHA-256', () => {
+        expect(ContentCache.hash('abc')).toBe('ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad');
+        expect(ContentCache.key('tiktoken', 'src/App.TS')).toBe('tiktoken:.ts');
+    });
+
+    test('computes on miss and reuses on hit', () => {
+        const cache = new ContentCache({ root });
+        const hash = ContentCache.hash('const a = 1;');
+        let calls = 0;
+        const compute = () => { calls++; return 7; };
+
+        expect(cache.tokens(hash, 'estimate:.js', compute)).toBe(7);
+        expect(cache.tokens(hash, 'estimate:.js', compute)).toBe(7);
+        expect(cache.tokens(hash, 'tiktoken:.js', compute)).toBe(7);
+        expect(calls).toBe(2);
+        expect(cache.getStats()).toMatchObject({ hits: 1, misses: 2, entries: 1 });
+    });
+
+    test('persists across instances and survives renames', () => {
+        const first = new ContentCache({ root });
+        const hash = ContentCache.hash('def f(): pass');
+        first.symbols(hash, 'heuristic:.py', 'a.py', () => [{ name: 'f', file: 'a.py', startLine: 1 }]);
+        expect(first.save()).toBe(true);
+        expect(fs.existsSync(path.join(root, '.ctxman', 'cache', 'content-cache.json'))).toBe(true);
+
+        const second = new ContentCache({ root });
+        const symbols = second.symbols(hash, 'heuristic:.py', 'renamed.py', () => {
+            throw new Error('should not recompute');
+        });
+        expect(symbols).toEqual([{ name: 'f', startLine: 1, file: 'renamed.py' }]);
+        expect(second.save()).toBe(false); // nothing changed
+    });
+
+    test('discards caches written by another version', () => {
+        const filePath = path.join(root, '.ctxman', 'cache', 'content-cache.json');
+        fs.mkdirSync(path.dirname(filePath), { recursive: true });
+        fs.writeFileSync(filePath, JSON.stringify({
+            version: CONTENT_CACHE_VERSION + 1,
+            entries: { abc: { tokens: { x: 1 }, symbols: {}, usedAt: Date.now() } }
+        }));
+
+        const cache = new ContentCache({ root }).load();
+        expect(cache.getStats().entries).toBe(0);
+    });
+
+    test('prunes stale and excess entries', () => {
+        const cache = new ContentCache({ root, maxEntries: 2 });
+        for (const text of ['a', 'b', 'c']) {
+            cache.tokens(ContentCache.hash(text), 'k', () => 1);
+        }
+        cache.entries.get(ContentCache.hash('a')).usedAt = 0;
+
+        expect(cache.prune()).toBe(1);
+        expect(cache.entries.has(ContentCache.hash('a'))).toBe(false);
+
+        cache.tokens(ContentCache.hash('d'), 'k', () => 1);
+        expect(cache.prune()).toBe(1);
+        expect(cache.getStats().entries).toBe(2);
+    });
+
+    test('memory-only caches never touch disk', () => {
+        const cache = new ContentCache({ root, path: null });
+        cache.tokens('h', 'k', () => 1);
+        expect(cache.save()).toBe(false);
+        expect(fs.existsSync(path.join(root, '.ctxman'))).toBe(false);
+    });
+
+    test('clear removes the cache file', () => {
+        const cache = new ContentCache({ root });
+        cache.tokens('h', 'k', () => 1);
+        cache.save();
+        cache.clear();
+        expect(cache.getStats().entries).toBe(0);
+        expect(fs.existsSync(cache.getFilePath())).toBe(false);
+    });
+});
+
+describe('ContentCache integration', () => {
+    let root;
+    let file;
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-cache-'));
+        file = path.join(root, 'app.py');
+        fs.writeFileSync(file, 'def main():\n    return 1\n');
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const fileInfo = () => ({
+        path: file,
+        relativePath: 'app.py',
+        name: 'app.py',
+        extension: '.py',
+        size: fs.statSync(file).size
+    });
+
+    test('Analyzer only re-parses files whose content changed', async () => {
+        const analyzer = () => new Analyzer({
+            symbols: true,
+            symbolBackend: 'heuristic',
+            cache: new ContentCache({ root })
+        });
+
+        const cold = await analyzer().analyze([fileInfo()]);
+        expect(cold.stats.cache.misses).toBe(2); // tokens + symbols
+        expect(cold.files[0].hash).toBe(ContentCache.hash(fs.readFileSync(file, 'utf8')));
+
+        const warm = await analyzer().analyze([fileInfo()]);
+        expect(warm.stats.cache).toMatchObject({ hits: 2, misses: 0 });
+        expect(warm.files[0].symbols).toEqual(cold.files[0].symbols);
+
+        fs.appendFileSync(file, '\ndef extra():\n    pass\n');
+        const changed = await analyzer().analyze([fileInfo()]);
+        expect(changed.stats.cache.misses).toBe(2);
+        expect(changed.files[0].symbols.map(s => s.name)).toEqual(['main', 'extra']);
+    });
+
+    test('TokenCalculator caches file token counts', () => {
+        const calculator = new TokenCalculator(root, { cache: new ContentCache({ root }) });
+        const first = calculator.analyzeFile(file);
+        calculator.contentCache.save();
+
+        const again = new TokenCalculator(root, { cache: new ContentCache({ root }) });
+        expect(again.analyzeFile(file).tokens).toBe(first.tokens);
+        expect(again.contentCache.getStats().hits).toBe(1);
+    });
+});
@@ -0,0 +1,36 @@
+import { describe, test, expect } from 'vitest';
+import ContentChunker from '../lib/cache/ContentChunker.js';
+
+// Varied lines, so boundaries fall where the rolling hash finds them
+const text = Array.from({ length: 3000 }, (_, i) => `line ${i}: ${(i * 2654435761 % 1000003).toString(36)}\n`).join('');
+
+describe('ContentChunker', () => {
+    const chunker = new ContentChunker({ minChars: 500, averageChars: 1500, maxChars: 4000 });
+
+    test('splits text into line-aligned chunks within the size bounds', () => {
+        const chunks = chunker.split(text);
+        expect(chunks.map(chunk => chunk.text).join('')).toBe(text);
+        expect(chunks.length).toBeGreaterThan(10);
+        for (const [index, chunk] of chunks.entries()) {
+            expect(chunk.text.length).toBeLessThanOrEqual(4000);
+            if (index < chunks.length - 1) {
+                expect(chunk.text.length).toBeGreaterThanOrEqual(500);
+                expect(chunk.text.endsWith('\n')).toBe(true);
+            }
+        }
+        expect(chunks[1].start).toBe(chunks[0].text.length);
+        expect(chunker.split('')).toEqual([]);
+    });
+
+    test('keeps the chunks an edit does not touch', () => {
+        const before = new Set(chunker.split(text).map(chunk => chunk.hash));
+        const middle = text.indexOf('line 1500:');
+        const edited = `${text.slice(0, middle)}an inserted line\n${text.slice(middle)}`;
+        const changed = chunker.split(edited).filter(chunk => !before.has(chunk.hash));
+        expect(changed.length).toBeLessThanOrEqual(2);
+        expect(changed.some(chunk => chunk.text.includes('an inserted line'))).toBe(true);
+
+        // A prefix shifts every offset, but only the first chunk changes
+        expect(chunker.split(`// header\n${text}`).filter(chunk => !before.has(chunk.hash))).toHaveLength(1);
+    });
+});
@@ -0,0 +1,54 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContentClassifier, { ContentKind } from '../lib/core/ContentClassifier.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const SOURCE = Array.from({ length: 80 }, (_, i) => `export function handler${i}(request) {\n    return request.body;\n}\n`).join('\n');
+const BUNDLE = `!function(e){${'var a=e.map(function(t){return t*2}),b=a.filter(Boolean);'.repeat(60)}}(window);\n`;
+
+describe('ContentClassifier', () => {
+    test('classifies binary, minified and lockfile content', () => {
+        expect(ContentClassifier.classify('GIF89a\0\0\x01', 'assets/logo.txt')).toBe(ContentKind.BINARY);
+        expect(ContentClassifier.classify('\uFFFD\uFFFDPK\uFFFD', 'data.json')).toBe(ContentKind.BINARY);
+        expect(ContentClassifier.classify(BUNDLE, 'public/app.js')).toBe(ContentKind.MINIFIED);
+        expect(ContentClassifier.classify('a{color:red}', 'vendor/reset.min.css')).toBe(ContentKind.MINIFIED);
+        expect(ContentClassifier.classify('{}', 'web/package-lock.json')).toBe(ContentKind.LOCKFILE);
+        expect(ContentClassifier.classify(SOURCE, 'src/app.js')).toBeNull();
+    });
+
+    test('keeps long lines in otherwise readable files', () => {
+        const longLiteral = `const PATTERN = '${'x'.repeat(1500)}';\n`;
+        expect(ContentClassifier.classify(longLiteral + SOURCE, 'src/patterns.js')).toBeNull();
+        expect(ContentClassifier.classify(longLiteral, 'src/short.js')).toBeNull();
+        expect(ContentClassifier.classify(BUNDLE, 'docs/unwrapped.md')).toBeNull();
+        expect(ContentClassifier.describe({ minified: 2, lockfile: 1 })).toBe('2 minified, 1 lockfile');
+    });
+
+    describe('in the analysis', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-classify-'));
+            fs.writeFileSync(path.join(root, 'app.js'), SOURCE);
+            fs.writeFileSync(path.join(root, 'bundle.js'), BUNDLE);
+            fs.writeFileSync(path.join(root, 'package-lock.json'), '{"lockfileVersion": 3}\n');
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('skips classified files unless includeGenerated is set', () => {
+            const calculator = new TokenCalculator(root, {});
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            expect(results.map(fileInfo => fileInfo.relativePath)).toEqual(['app.js']);
+            expect(calculator.stats.skippedContentFiles).toEqual({ minified: 1, lockfile: 1 });
+
+            const keeping = new TokenCalculator(root, { includeGenerated: true });
+            expect(keeping.analyzeFiles(keeping.scanProject()).map(fileInfo => fileInfo.relativePath).sort())
+                .toEqual(['app.js', 'bundle.js', 'package-lock.json']);
+        });
+    });
+});
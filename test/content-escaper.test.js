@@ -0,0 +1,149 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { fence, inlineCode, xmlContent, cdata, escapeXml, parseFenceChar, parseXmlContentMode } from '../lib/formatters/content-escaper.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+// Pieces that end blocks in Markdown and XML, and characters XML cannot hold
+const HOSTILE = ['```', '````````', '~~~', '~~~~~', '`', ']]>', ']]', '>', '<![CDATA[', '</document_content>', '</document >',
+    '</documents>', '</context>', '</text>', '</lines>', '<', '&', '&amp;', '\n', '\r\n', '    ', '\u0000', '\u001B', '\uD800', 'code'];
+
+/**
+ * Deterministic contents, so a failure reproduces
+ */
+function hostileContents(count, seed = 7) {
+    let state = seed;
+    const next = () => (state = (state * 1103515245 + 12345) % 2147483648) / 2147483648;
+    return Array.from({ length: count }, () => Array.from({ length: 1 + Math.floor(next() * 40) },
+        () => HOSTILE[Math.floor(next() * HOSTILE.length)]).join(''));
+}
+
+/**
+ * Text of a fenced block as a CommonMark reader sees it, or null when a line closes it early
+ */
+function readFence(block) {
+    const [opening, ...rest] = block.split('\n');
+    const delimiter = opening.match(/^(`+|~+)/)[1];
+    const closing = new RegExp(`^ {0,3}${delimiter[0] === '`' ? '`' : '~'}{${delimiter.length},}[ \\t]*$`);
+    const end = rest.findIndex(line => closing.test(line));
+    return end === rest.length - 1 ? rest.slice(0, end).join('\n') : null;
+}
+
+/**
+ * Text of an XML document when its elements balance, the way a parser reads it
+ */
+function readXml(xml) {
+    const stack = [];
+    let text = '';
+    let last = 0;
+    const markup = /<!\[CDATA\[([\s\S]*?)\]\]>|<\?[^>]*\?>|<(\/?)([A-Za-z_][\w.-]*)(?:\s[^<>]*?)?(\/?)>/g;
+    for (const match of xml.matchAll(markup)) {
+        text += readText(xml.slice(last, match.index));
+        last = match.index + match[0].length;
+        if (match[1] !== undefined) text += match[1];
+        else if (match[2]) expect(stack.pop()).toBe(match[3]);
+        else if (match[3] && !match[4]) stack.push(match[3]);
+    }
+    text += readText(xml.slice(last));
+    expect(stack).toEqual([]);
+    return text;
+}
+
+function readText(text) {
+    expect(text).not.toMatch(/[<>]|&(?!(amp|lt|gt|quot);)|[\u0000-\u0008\u000B\u000C\u000E-\u001F]/);
+    return text.replace(/&lt;/g, '<').replace(/&gt;/g, '>').replace(/&quot;/g, '"').replace(/&amp;/g, '&');
+}
+
+describe('ContentEscaper', () => {
+    test('fences outgrow every run of the fence character', () => {
+        expect(fence('const a = 1;', 'js')).toBe('```js\nconst a = 1;\n```');
+        expect(fence('see ```` here\n', 'md')).toBe('`````md\nsee ```` here\n`````');
+        expect(fence('~~~\nx', 'a b`~c', { char: 'tilde' })).toBe('~~~~abc\n~~~\nx\n~~~~');
+        expect(() => fence('x', '', { char: 'quote' })).toThrow('Invalid fence: quote (expected backtick or tilde)');
+
+        for (const char of ['backtick', 'tilde']) {
+            for (const content of hostileContents(300)) {
+                const block = fence(content, 'txt', { char });
+                expect(readFence(block)).toBe(content.replace(/\r?\n$/, ''));
+            }
+        }
+        // Runs longer than a spread argument list takes
+        expect(fence('`'.repeat(200000), '').split('\n')[0]).toHaveLength(200001);
+    });
+
+    test('inline code spans hold backticks and stay on one line', () => {
+        expect(inlineCode('src/app.js')).toBe('`src/app.js`');
+        expect(inlineCode('a`b')).toBe('``a`b``');
+        expect(inlineCode('`tick`')).toBe('`` `tick` ``');
+        expect(inlineCode('two\nlines')).toBe('`two lines`');
+
+        for (const content of hostileContents(200)) {
+            const span = inlineCode(content);
+            expect(span).not.toMatch(/[\r\n]/);
+            const delimiter = span.match(/^`+/)[0];
+            const inner = span.slice(delimiter.length, -delimiter.length);
+            expect(inner.match(new RegExp(`(?<!\`)${delimiter}(?!\`)`))).toBeNull();
+        }
+    });
+
+    test('XML contents cannot close their elements', () => {
+        const tags = ['document_content', 'document', 'documents', 'context'];
+        expect(xmlContent('a </document_content> b </document\t> </other>', { tags }))
+            .toBe('a &lt;/document_content&gt; b &lt;/document\t&gt; </other>');
+        expect(cdata('x ]]> y')).toBe('<![CDATA[x ]]]]><![CDATA[> y]]>');
+        expect(xmlContent('a < b && c', { mode: 'entities' })).toBe('a &lt; b &amp;&amp; c');
+        expect(escapeXml('"\u0007"')).toBe('&quot;�&quot;');
+        expect(() => parseXmlContentMode('base64')).toThrow('Invalid XML content mode: base64 (expected raw, cdata, entities)');
+        expect(parseFenceChar('tilde')).toBe('tilde');
+
+        const sanitized = text => text.replace(/[\u0000-\u0008\u000B\u000C\u000E-\u001F]|[\uD800-\uDFFF]/g, '�');
+        for (const content of hostileContents(300)) {
+            const wrap = mode => `<document_content>${xmlContent(content, { mode, tags })}</document_content>`;
+            expect(readXml(wrap('cdata'))).toBe(sanitized(content));
+            expect(readXml(wrap('entities'))).toBe(sanitized(content));
+            // Raw contents stay as written, apart from the escaped closing tags
+            const raw = xmlContent(content, { tags });
+            expect(raw).not.toMatch(/<\/(document_content|document|documents|context)\s*>/);
+            expect(raw.replace(/&lt;\/(\w+)(\s*)&gt;/g, '</$1$2>')).toBe(content.replace(/&lt;\/(\w+)(\s*)&gt;/g, '</$1$2>'));
+        }
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+        const hostile = 'const md = "```\\n~~~~\\n````";\n// ]]> </document_content> </document> </documents>\nconst bell = "\u0007";\n';
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-escaper-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src', 'hostile.js'), hostile);
+            fs.writeFileSync(path.join(root, 'src', 'a`b.js'), 'export const tick = "`";\n');
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        const render = (options, format) => {
+            const calculator = new TokenCalculator(root, options);
+            return calculator.createStructuredFormatter(calculator.analyzeFiles(calculator.scanDirectory(root))).encode(format);
+        };
+
+        test('Markdown fences and headings hold hostile files', () => {
+            const markdown = render({ fence: 'tilde' }, 'markdown');
+            expect(markdown).toContain(`~~~~~javascript\n${hostile.replace(/\n$/, '')}\n~~~~~`);
+            expect(markdown).toContain('### ``src/a`b.js``');
+            expect(render({}, 'markdown')).toContain('`````javascript\nconst md');
+        });
+
+        test('XML is well-formed in cdata and entities modes', () => {
+            for (const xmlContentMode of ['cdata', 'entities']) {
+                const text = readXml(render({ xmlContent: xmlContentMode }, 'xml'));
+                expect(text).toContain(hostile.replace(/\u0007/, '�').replace(/\n$/, ''));
+            }
+            const raw = render({}, 'xml');
+            expect(raw).toContain('// ]]> &lt;/document_content&gt; &lt;/document&gt; &lt;/documents&gt;');
+            expect(raw.match(/<\/document_content>/g)).toHaveLength(2);
+        });
+    });
+});
@@ -0,0 +1,82 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContentPolicy, { POLICY_FILE } from '../lib/core/ContentPolicy.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const POLICY = {
+    rules: [
+        { name: 'prod-configs', paths: ['config/prod/', '**/*.prod.yaml'], message: 'Production configuration never leaves the org' },
+        { name: 'internal-hosts', pattern: '[a-z0-9.-]+\\.corp\\.acme\\.com', flags: 'i', exclude: ['docs/'] },
+        { name: 'license-header', paths: ['src/**/*.js'], require: 'Copyright \\(c\\) \\d{4} Acme', within: 3 }
+    ]
+};
+
+const file = (filePath, content, original = content) => ({ path: filePath, read: () => content, readOriginal: () => original });
+
+describe('ContentPolicy', () => {
+    const policy = ContentPolicy.fromData(POLICY);
+
+    test('finds forbidden paths, forbidden content and missing headers', () => {
+        const violations = policy.evaluate([
+            file('src/api.js', '// Copyright (c) 2026 Acme\n\nconst host = "DB.Corp.Acme.com";\n'),
+            file('src/util.js', 'export const util = 1;\n'),
+            file('config/prod/db.json', '{}\n'),
+            file('deploy/app.prod.yaml', 'replicas: 3\n'),
+            file('docs/hosts.md', 'db.corp.acme.com\n'),
+            // Stripped comments leave the header of the file on disk
+            file('src/stripped.js', 'export const s = 1;\n', '// Copyright (c) 2025 Acme\nexport const s = 1;\n')
+        ]);
+
+        expect(violations).toEqual([
+            { rule: 'prod-configs', file: 'config/prod/db.json', line: null, message: 'Production configuration never leaves the org' },
+            { rule: 'prod-configs', file: 'deploy/app.prod.yaml', line: null, message: 'Production configuration never leaves the org' },
+            { rule: 'internal-hosts', file: 'src/api.js', line: 3, message: 'Forbidden content: DB.Corp.Acme.com' },
+            { rule: 'license-header', file: 'src/util.js', line: null, message: 'Missing required content in the first 3 lines: /Copyright \\(c\\) \\d{4} Acme/' }
+        ]);
+
+        const report = policy.formatReport(violations);
+        expect(report).toContain(`🛂 CONTENT POLICY (${POLICY_FILE}, 3 rules)`);
+        expect(report).toContain('4 violations in 4 files (not enforced; --enforce fails the run)');
+        expect(report).toContain('❌ src/api.js:3 [internal-hosts] Forbidden content: DB.Corp.Acme.com');
+    });
+
+    test('rejects invalid policies', () => {
+        expect(() => ContentPolicy.fromData({ rules: [{ name: 'empty' }] }, 'policy.json'))
+            .toThrow('policy.json: rule "empty" needs "paths", a forbidden "pattern" or a "require" pattern');
+        expect(() => ContentPolicy.fromData({ rules: [{ name: 'bad', pattern: '(' }] })).toThrow('rule "bad" has an invalid "pattern"');
+        expect(() => ContentPolicy.fromData({ rules: [{ name: 'both', pattern: 'a', require: 'b' }] })).toThrow('sets both "pattern" and "require"');
+        expect(() => ContentPolicy.fromData({ rules: [], block: [] })).toThrow('unknown keys: block');
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-policy-'));
+            const files = {
+                [POLICY_FILE]: JSON.stringify({ enforce: true, rules: POLICY.rules }),
+                'src/app.js': '// Copyright (c) 2026 Acme\nexport const app = 1;\n',
+                'config/prod/db.yaml': 'password: hunter2\n'
+            };
+            for (const [name, content] of Object.entries(files)) {
+                fs.mkdirSync(path.dirname(path.join(root, name)), { recursive: true });
+                fs.writeFileSync(path.join(root, name), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('fails an enforced run before anything is written', () => {
+            const calculator = new TokenCalculator(root, { policy: ContentPolicy.load(root), gitingest: true });
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            expect(() => calculator.finishRun(results)).toThrow('1 content policy violations; no context was written');
+            expect(calculator.policyViolations.map(violation => violation.file)).toEqual(['config/prod/db.yaml']);
+            expect(fs.existsSync(path.join(root, 'digest.txt'))).toBe(false);
+            expect(() => ContentPolicy.load(root, 'missing.json')).toThrow('Policy file not found: missing.json');
+        });
+    });
+});
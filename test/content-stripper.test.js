@@ -0,0 +1,135 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContentStripper from '../lib/core/ContentStripper.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const SOURCE = [
+    '#!/usr/bin/env node',
+    '// Helpers',
+    "import { join } from 'path'; // trailing",
+    '',
+    '/**',
+    ' * Joins parts.',
+    ' */',
+    'export function joinAll(parts) {',
+    '    const url = "http://example.com/*x*/";',
+    '    const text = `first',
+    '',
+    '// still text`;',
+    '    return join(...parts); /* inline */',
+    '}',
+    '',
+    '',
+    '// Not exported',
+    'function helper() {}',
+    ''
+].join('\n');
+
+describe('ContentStripper', () => {
+    test('removes comments and blank lines but not strings', () => {
+        expect(new ContentStripper({ mode: 'both' }).strip(SOURCE, 'src/a.js')).toBe([
+            '#!/usr/bin/env node',
+            "import { join } from 'path';",
+            'export function joinAll(parts) {',
+            '    const url = "http://example.com/*x*/";',
+            '    const text = `first',
+            '',
+            '// still text`;',
+            '    return join(...parts);',
+            '}',
+            'function helper() {}',
+            ''
+        ].join('\n'));
+    });
+
+    test('keeps blank lines in comments mode and comments in blank-lines mode', () => {
+        const comments = new ContentStripper({ mode: 'comments' }).strip(SOURCE, 'src/a.js');
+        expect(comments).toContain("import { join } from 'path';\n\nexport function");
+        expect(comments).not.toContain('Helpers');
+
+        const blankLines = new ContentStripper({ mode: 'blank-lines' }).strip(SOURCE, 'src/a.js');
+        expect(blankLines).toContain('}\n// Not exported\nfunction helper() {}');
+        expect(blankLines).toContain('`first\n\n// still text`');
+    });
+
+    test('keeps doc comments of exported symbols with keepDocs', () => {
+        const stripped = new ContentStripper({ mode: 'both', keepDocs: true }).strip(SOURCE, 'src/a.js');
+        expect(stripped).toContain('/**\n * Joins parts.\n */\nexport function joinAll');
+        expect(stripped).not.toContain('Not exported');
+        expect(stripped).not.toContain('Helpers');
+
+        const rust = '/// Parses input.\n/// Returns none on error.\n#[inline]\npub fn parse(s: &str) -> char { \'/\' } // x\n// private\nfn other() {}\n';
+        expect(new ContentStripper({ keepDocs: true }).strip(rust, 'lib.rs'))
+            .toBe('/// Parses input.\n/// Returns none on error.\n#[inline]\npub fn parse(s: &str) -> char { \'/\' }\nfn other() {}\n');
+    });
+
+    test('treats Python docstrings as comments', () => {
+        const python = [
+            '"""Module doc."""',
+            'import os  # comment',
+            '',
+            'class Public:',
+            '    """Public doc."""',
+            '    label = "#1"',
+            '',
+            'def _private():',
+            '    """Private doc."""',
+            '    value = """not a docstring"""',
+            '    return value',
+            ''
+        ].join('\n');
+
+        expect(new ContentStripper().strip(python, 'app.py')).toBe([
+            'import os',
+            'class Public:',
+            '    label = "#1"',
+            'def _private():',
+            '    value = """not a docstring"""',
+            '    return value',
+            ''
+        ].join('\n'));
+
+        const kept = new ContentStripper({ keepDocs: true }).strip(python, 'app.py');
+        expect(kept).toContain('"""Module doc."""');
+        expect(kept).toContain('"""Public doc."""');
+        expect(kept).not.toContain('Private doc');
+    });
+
+    test('only strips blank lines from files with unknown comment syntax', () => {
+        const stripper = new ContentStripper();
+        expect(stripper.strip('# Title\n\n// not code\n', 'README.md')).toBe('# Title\n// not code\n');
+        expect(stripper.strip('a: 1 # note\nurl: "http://x#y"\n', 'config.yml')).toBe('a: 1\nurl: "http://x#y"\n');
+        expect(() => new ContentStripper({ mode: 'all' })).toThrow('Invalid strip mode');
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-strip-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src', 'a.js'), SOURCE);
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('counts tokens and builds symbols from the stripped contents', () => {
+            const stripper = new ContentStripper({ mode: 'both' });
+            const calculator = new TokenCalculator(root, { stripper });
+            const [fileInfo] = calculator.analyzeFiles(calculator.scanProject());
+            const stripped = stripper.strip(SOURCE, 'src/a.js');
+
+            expect(fileInfo.tokens).toBe(calculator.calculateTokens(stripped, fileInfo.path));
+            expect(fileInfo.tokens).toBeLessThan(calculator.calculateTokens(SOURCE, fileInfo.path));
+            expect(fileInfo.lines).toBe(stripped.split('\n').length);
+
+            const { graph } = calculator.buildDependencyGraph([fileInfo]);
+            const joinAll = graph.getFile('src/a.js').symbols.find(symbol => symbol.name === 'joinAll');
+            expect(joinAll.startLine).toBe(3);
+        });
+    });
+});
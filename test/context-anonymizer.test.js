@@ -0,0 +1,94 @@
+import { describe, test, expect } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextAnonymizer from '../lib/core/ContextAnonymizer.js';
+import OutputTarget from '../lib/core/OutputTarget.js';
+
+const GO_SOURCE = [
+    'package billing',
+    '',
+    'import (',
+    '\t"errors"',
+    '\t"acme.com/shop/billing/tax"',
+    ')',
+    '',
+    '// Invoice is a bill sent to a customer',
+    'type Invoice struct {',
+    '\ttotal int',
+    '}',
+    '',
+    'func (i *Invoice) Settle() error {',
+    '\treturn errors.New("invoice already settled")',
+    '}',
+    ''
+].join('\n');
+
+describe('ContextAnonymizer', () => {
+    test('renames declared names, paths and string literals consistently', () => {
+        const anonymizer = new ContextAnonymizer();
+        anonymizer.learn(GO_SOURCE, 'billing/invoice.go');
+
+        const source = anonymizer.replaceStrings(GO_SOURCE, 'billing/invoice.go');
+        expect(source.split('\n')).toHaveLength(GO_SOURCE.split('\n').length);
+        expect(source).toContain('errors.New("str1")');
+        expect(source).toContain('"acme.com/shop/billing/tax"');
+
+        const context = anonymizer.anonymize(`FILE: billing/invoice.go\n${source}`);
+        expect(context).toContain('FILE: pkg1/type1.go');
+        expect(context).toContain('package pkg1');
+        expect(context).toContain('"acme.com/shop/pkg1/tax"');
+        expect(context).toContain('// Type1 is a bill sent to a customer');
+        expect(context).toContain('type Type1 struct');
+        expect(context).toContain('func (i *Type1) Func1() error');
+        expect(context).not.toMatch(/Invoice|Settle|settled/);
+
+        // Other files of the run share the placeholders
+        expect(anonymizer.anonymize('INVOICE invoice Invoice')).toBe('TYPE1 type1 Type1');
+        expect(anonymizer.describe()).toBe('3 names, 1 strings');
+    });
+
+    test('keeps keywords and format words, and reverses placeholders', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-anonymize-'));
+        const mapFile = path.join(root, '.ctxman', 'anonymize.json');
+
+        try {
+            const anonymizer = ContextAnonymizer.load(mapFile);
+            anonymizer.learn('export function path() {}\nexport class Ledger {}\n', 'src/index.js');
+            expect(anonymizer.anonymize('src/index.js: path Ledger')).toBe('src/index.js: path Type1');
+            anonymizer.save();
+
+            // A later run extends the mapping without renumbering
+            const reloaded = ContextAnonymizer.load(mapFile);
+            reloaded.learn('export function rates() {}\n', 'src/rates.js');
+            expect(reloaded.anonymize('Ledger rates')).toBe('Type1 func1');
+            expect(reloaded.restore('Move Type1 to func1.js (see "str9")')).toBe('Move Ledger to rates.js (see "str9")');
+
+            fs.writeFileSync(mapFile, '{"schema":"other"}');
+            expect(() => ContextAnonymizer.load(mapFile)).toThrow('is not a ctxman anonymization map');
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+
+    test('output targets write anonymized context', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-anonymize-'));
+        const anonymizer = new ContextAnonymizer();
+        anonymizer.learn('class Ledger:\n    pass\n', 'ledger.py');
+        const target = new OutputTarget({ root, anonymizer });
+
+        try {
+            const written = target.write('FILE: ledger.py\nclass Ledger:\n', 'digest.txt');
+            expect(fs.readFileSync(written, 'utf8')).toBe('FILE: type1.py\nclass Type1:\n');
+
+            const { path: streamed } = target.stream(['FILE: ledger.py\n', 'class Ledger:\n'], 'context.md');
+            expect(fs.readFileSync(streamed, 'utf8')).toBe('FILE: type1.py\nclass Type1:\n');
+
+            fs.writeFileSync(path.join(root, 'chunk-1.txt'), 'Ledger');
+            target.done(path.join(root, 'chunk-1.txt'));
+            expect(fs.readFileSync(path.join(root, 'chunk-1.txt'), 'utf8')).toBe('Type1');
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
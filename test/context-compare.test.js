@@ -0,0 +1,78 @@
+import { describe, test, expect } from 'vitest';
+import ContextComparer from '../lib/core/ContextComparer.js';
+
+const structured = files => ({
+    schema: 'ctxman.context/v1',
+    project: { name: 'app', files: files.length, tokens: files.reduce((sum, file) => sum + file.tokens, 0) },
+    files
+});
+
+const symbol = (name, tokens, signature = `function ${name}()`) => ({ id: null, name, kind: 'function', signature, tokens });
+
+describe('ContextComparer', () => {
+    const before = ContextComparer.normalize(structured([
+        { path: 'README.md', tokens: 50, symbols: [] },
+        { path: 'src/api.js', tokens: 300, symbols: [symbol('get', 100), symbol('post', 120), symbol('old', 80)] },
+        { path: 'src/util.js', tokens: 40, symbols: [symbol('pad', 40)] },
+        { path: 'lib/legacy.js', tokens: 200, symbols: [symbol('legacy', 200)] }
+    ]), 'old.json');
+    const after = ContextComparer.normalize(structured([
+        { path: 'README.md', tokens: 50, symbols: [] },
+        { path: 'src/api.js', tokens: 900, symbols: [symbol('get', 100), symbol('post', 500, 'function post(body)'), symbol('patch', 300)] },
+        { path: 'src/util.js', tokens: 40, symbols: [symbol('pad', 40)] },
+        { path: 'test/api.test.js', tokens: 150, symbols: [] }
+    ]), 'new.json');
+
+    test('reports added, removed and changed files and symbols', () => {
+        const comparison = ContextComparer.compare(before, after);
+
+        expect(comparison.tokens).toEqual({ before: 590, after: 1140, delta: 550 });
+        expect(comparison.files.added.map(change => change.path)).toEqual(['test/api.test.js']);
+        expect(comparison.files.removed).toMatchObject([{ path: 'lib/legacy.js', delta: -200, symbols: { removed: [{ name: 'legacy' }] } }]);
+        expect(comparison.files.unchanged).toBe(2);
+
+        const [api] = comparison.files.changed;
+        expect(api).toMatchObject({ path: 'src/api.js', before: 300, after: 900, delta: 600 });
+        expect(api.symbols.added.map(s => s.name)).toEqual(['patch']);
+        expect(api.symbols.removed.map(s => s.name)).toEqual(['old']);
+        expect(api.symbols.changed).toEqual([{
+            name: 'post',
+            kind: 'function',
+            signature: { before: 'function post()', after: 'function post(body)' },
+            before: 120,
+            after: 500,
+            delta: 380
+        }]);
+        expect(comparison.symbols).toEqual({ added: 1, removed: 2, changed: 1 });
+    });
+
+    test('sums token deltas per top-level directory', () => {
+        const { sections } = ContextComparer.compare(before, after);
+
+        expect(sections.map(({ name, delta }) => [name, delta])).toEqual([['src', 600], ['lib', -200], ['test', 150]]);
+        expect(ContextComparer.formatComparison(ContextComparer.compare(before, after)))
+            .toContain('Tokens: 590 → 1,140 (+550 tokens)');
+    });
+
+    test('compares llm-context.json paths and methods', () => {
+        const paths = project => ({ project: { root: 'app', totalFiles: 2, totalTokens: project }, paths: { '/': ['README.md'], 'src/': ['api.js'] } });
+        const grown = ContextComparer.compare(
+            ContextComparer.normalize(paths(100)),
+            ContextComparer.normalize({ ...paths(180), paths: { '/': ['README.md'], 'src/': ['api.js', 'db.js'] } })
+        );
+        expect(grown.fileTokens).toBe(false);
+        expect(grown.tokens.delta).toBe(80);
+        expect(grown.files.added).toMatchObject([{ path: 'src/db.js', delta: null }]);
+        expect(ContextComparer.formatComparison(grown)).not.toContain('Token delta by section');
+
+        const methods = tokens => ({ project: {}, methods: { 'src/api.js': [{ name: 'get', line: 1, tokens }] } });
+        const changed = ContextComparer.compare(ContextComparer.normalize(methods(10)), ContextComparer.normalize(methods(25)));
+        expect(changed.files.changed[0].symbols.changed).toMatchObject([{ name: 'get', kind: 'method', delta: 15, signature: null }]);
+    });
+
+    test('rejects files that are not generated contexts', () => {
+        expect(() => ContextComparer.normalize({ summary: {} }, 'report.json'))
+            .toThrow('report.json is not a generated context');
+        expect(() => ContextComparer.load('/nonexistent/context.json')).toThrow('Cannot read context /nonexistent/context.json');
+    });
+});
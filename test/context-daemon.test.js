@@ -0,0 +1,171 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import WarmIndex from '../lib/daemon/WarmIndex.js';
+import ContextDaemon from '../lib/daemon/ContextDaemon.js';
+import DaemonClient, { socketPathFor } from '../lib/daemon/DaemonClient.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('WarmIndex', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-warm-'));
+        for (let i = 0; i < 6; i++) {
+            const file = path.join(root, 'src', `file${i}.js`);
+            fs.mkdirSync(path.dirname(file), { recursive: true });
+            fs.writeFileSync(file, `export function f${i}() {\n    return ${i};\n}\n`);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const analyze = index => {
+        const calculator = new TokenCalculator(root, { index });
+        const results = calculator.analyzeFiles(calculator.scanProject());
+        return { calculator, results };
+    };
+
+    test('reuses scans and analyses of an unchanged tree', () => {
+        const index = new WarmIndex({ root });
+        const cold = analyze(index);
+        const warm = analyze(index);
+
+        expect(warm.results).toEqual(cold.results);
+        expect(warm.calculator.stats).toEqual(cold.calculator.stats);
+        expect(warm.results[0]).not.toBe(cold.results[0]);
+        expect(index.getStats()).toMatchObject({ files: 6, scans: 1, scanHits: 1, analyses: 6, analysisHits: 6 });
+    });
+
+    test('notices added, edited and deleted files', () => {
+        const index = new WarmIndex({ root });
+        analyze(index);
+
+        const added = path.join(root, 'src', 'added.js');
+        fs.writeFileSync(added, 'export const added = 1;\n');
+        const edited = path.join(root, 'src', 'file0.js');
+        fs.writeFileSync(edited, 'export function f0() {\n    return "a much longer body than before";\n}\n');
+        fs.utimesSync(edited, new Date(), new Date(Date.now() + 5000));
+
+        const { results } = analyze(index);
+        expect(results.map(fileInfo => fileInfo.relativePath)).toContain(path.join('src', 'added.js'));
+        expect(results.find(fileInfo => fileInfo.relativePath === path.join('src', 'file0.js')).tokens)
+            .toBe(new TokenCalculator(root).analyzeFile(edited).tokens);
+        expect(index.getStats()).toMatchObject({ scans: 2, analyses: 8, analysisHits: 5 });
+
+        fs.rmSync(added);
+        analyze(index);
+        expect(index.getStats()).toMatchObject({ files: 6, scans: 3 });
+    });
+
+    test('rebuilds the dependency graph only when files changed', () => {
+        const index = new WarmIndex({ root });
+        const { calculator, results } = analyze(index);
+        const first = calculator.buildDependencyGraph(results).graph;
+
+        const again = analyze(index);
+        expect(again.calculator.buildDependencyGraph(again.results).graph).toBe(first);
+
+        const edited = path.join(root, 'src', 'file1.js');
+        fs.appendFileSync(edited, 'export function g1() {\n    return f1();\n}\n');
+        const changed = analyze(index);
+        const rebuilt = changed.calculator.buildDependencyGraph(changed.results).graph;
+        expect(rebuilt).not.toBe(first);
+        expect(rebuilt.getFile('src/file1.js').symbols.map(symbol => symbol.name)).toContain('g1');
+        expect(index.getStats()).toMatchObject({ graphs: 2, graphHits: 1 });
+    });
+
+    test('computes memos once', async () => {
+        const index = new WarmIndex({ root });
+        let calls = 0;
+        const first = await index.memo('extractor:auto', async () => ({ calls: ++calls }));
+        expect(await index.memo('extractor:auto', async () => ({ calls: ++calls }))).toBe(first);
+        expect(calls).toBe(1);
+    });
+});
+
+describe('ContextDaemon', () => {
+    let dir;
+    let daemon;
+    let socketPath;
+
+    const collect = () => {
+        const output = { stdout: '', stderr: '' };
+        return {
+            output,
+            stdout: { write: data => { output.stdout += data; } },
+            stderr: { write: data => { output.stderr += data; } }
+        };
+    };
+
+    beforeAll(async () => {
+        dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-daemon-'));
+        socketPath = path.join(dir, 'daemon.sock');
+        daemon = await new ContextDaemon({
+            root: dir,
+            socketPath,
+            status: () => ({ files: 3 }),
+            handler: async args => {
+                if (args[0] === 'local') return false;
+                console.log(`args: ${args.join(' ')}`);
+                process.stdout.write('raw\n');
+                console.error('report');
+                if (args[0] === 'fail') process.exit(3);
+                return true;
+            }
+        }).start();
+    });
+
+    afterAll(async () => {
+        await daemon.stop();
+        fs.rmSync(dir, { recursive: true, force: true });
+    });
+
+    test('derives one socket per project root', () => {
+        expect(socketPathFor('/a/project')).toBe(socketPathFor('/a/project/'));
+        expect(socketPathFor('/a/project')).not.toBe(socketPathFor('/a/other'));
+    });
+
+    test('streams output and exit codes to the client', async () => {
+        const { output, stdout, stderr } = collect();
+        const client = new DaemonClient({ socketPath, stdout, stderr });
+
+        expect(await client.run(['--cli', '-g'])).toBe(0);
+        expect(output).toEqual({ stdout: 'args: --cli -g\nraw\n', stderr: 'report\n' });
+
+        expect(await client.run(['fail'])).toBe(3);
+        expect(await client.run(['local'])).toBeNull();
+    });
+
+    test('restores the console and process.exit after each request', async () => {
+        const { log } = console;
+        const { exit } = process;
+        await new DaemonClient({ socketPath, ...collect() }).run(['fail']);
+        expect(console.log).toBe(log);
+        expect(process.exit).toBe(exit);
+    });
+
+    test('answers status and reports missing daemons', async () => {
+        const status = await new DaemonClient({ socketPath }).status();
+        expect(status).toMatchObject({ pid: process.pid, root: dir, socket: socketPath, files: 3 });
+
+        const missing = new DaemonClient({ socketPath: path.join(dir, 'missing.sock') });
+        expect(await missing.run(['--cli'])).toBeNull();
+        expect(await missing.stop()).toBe(false);
+    });
+
+    test('refuses a second daemon and replaces stale sockets', async () => {
+        await expect(new ContextDaemon({ root: dir, socketPath, handler: async () => true }).start())
+            .rejects.toThrow('already serves');
+
+        const stalePath = path.join(dir, 'stale.sock');
+        fs.writeFileSync(stalePath, '');
+        const replacement = await new ContextDaemon({ root: dir, socketPath: stalePath, handler: async () => true }).start();
+        expect(await new DaemonClient({ socketPath: stalePath }).stop()).toBe(true);
+        await replacement.closed();
+        expect(fs.existsSync(stalePath)).toBe(false);
+    });
+});
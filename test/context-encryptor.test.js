@@ -0,0 +1,68 @@
+import { describe, test, expect } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { spawnSync } from 'child_process';
+import ContextEncryptor from '../lib/core/ContextEncryptor.js';
+import OutputTarget from '../lib/core/OutputTarget.js';
+
+const HAS_GPG = !spawnSync('gpg', ['--version']).error;
+
+describe('ContextEncryptor', () => {
+    test('parses recipients of one scheme', () => {
+        const encryptor = ContextEncryptor.parse(['age:age1alice', 'age:age1bob']);
+        expect(encryptor.options.recipients).toEqual(['age1alice', 'age1bob']);
+        expect(encryptor.describe()).toBe('age, 2 recipients');
+        expect(encryptor.extension).toBe('.age');
+        expect(ContextEncryptor.parse(['gpg:alice@example.com']).armored().extension).toBe('.asc');
+
+        expect(() => ContextEncryptor.parse(['age1alice'])).toThrow('Invalid --encrypt value: age1alice');
+        expect(() => ContextEncryptor.parse(['gpg:'])).toThrow('Invalid --encrypt value');
+        expect(() => ContextEncryptor.parse(['age:age1alice', 'gpg:bob'])).toThrow('must all use one scheme');
+    });
+
+    test('names decrypted files and tells schemes apart', () => {
+        expect(ContextEncryptor.plainPath('digest.txt.age')).toBe('digest.txt');
+        expect(ContextEncryptor.plainPath('app.ctxpack.gpg')).toBe('app.ctxpack');
+        expect(ContextEncryptor.plainPath('digest.txt')).toBeNull();
+
+        expect(ContextEncryptor.detect(Buffer.from('age-encryption.org/v1\n-> X25519 ...'))).toBe('age');
+        expect(ContextEncryptor.detect(Buffer.from('-----BEGIN AGE ENCRYPTED FILE-----\n'))).toBe('age');
+        expect(ContextEncryptor.detect(Buffer.from([0x85, 0x02, 0x0c]))).toBe('gpg');
+        expect(() => ContextEncryptor.decrypt(Buffer.from('age-encryption.org/v1\n'))).toThrow('needs --identity');
+
+        const missing = new ContextEncryptor({ recipients: ['age1alice'], command: 'ctxman-missing-age' });
+        expect(() => missing.encrypt('context')).toThrow('age encryption needs the ctxman-missing-age command');
+    });
+
+    (HAS_GPG ? test : test.skip)('writes only ciphertext and decrypts it with the keyring', () => {
+        const home = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-gpg-'));
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-encrypt-'));
+        const previous = process.env.GNUPGHOME;
+        process.env.GNUPGHOME = home;
+
+        try {
+            spawnSync('gpg', ['--batch', '--quiet', '--passphrase', '', '--quick-gen-key', 'Test <test@example.com>', 'default', 'default', 'never']);
+            const encryptor = ContextEncryptor.parse(['gpg:test@example.com']);
+            const target = new OutputTarget({ root, encryptor });
+
+            const outputPath = target.write('FILE: src/secret.js\nconst key = 1;\n', 'digest.txt');
+            expect(outputPath).toBe(path.join(root, 'digest.txt.gpg'));
+            expect(fs.readdirSync(root)).toEqual(['digest.txt.gpg']);
+            expect(fs.readFileSync(outputPath).includes('secret.js')).toBe(false);
+            expect(ContextEncryptor.decrypt(fs.readFileSync(outputPath)).toString('utf8')).toContain('const key = 1;');
+
+            // Files a formatter wrote itself are replaced by their encrypted form
+            fs.writeFileSync(path.join(root, 'chunk-1.txt'), 'chunk');
+            expect(target.done(path.join(root, 'chunk-1.txt'))).toBe(path.join(root, 'chunk-1.txt.gpg'));
+            expect(fs.existsSync(path.join(root, 'chunk-1.txt'))).toBe(false);
+            expect(target.written).toEqual([outputPath, path.join(root, 'chunk-1.txt.gpg')]);
+        } finally {
+            spawnSync('gpgconf', ['--kill', 'gpg-agent']);
+            if (previous === undefined) delete process.env.GNUPGHOME;
+            else process.env.GNUPGHOME = previous;
+            fs.rmSync(home, { recursive: true, force: true });
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
@@ -0,0 +1,84 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextEngine from '../lib/api/ContextEngine.js';
+import * as API from '../index.js';
+
+const FILES = {
+    'src/service.js': [
+        "import { parse } from './util.js';",
+        '',
+        'export class Service {',
+        '    fetch(input) {',
+        '        return parse(input);',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'src/util.js': 'export function parse(input) {\n    return JSON.parse(input);\n}\n',
+    'docs/guide.md': `# Guide\n\n${'Long prose about the service. '.repeat(200)}\n`
+};
+
+describe('ContextEngine', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-engine-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('scans, analyzes, packs and renders in stages', async () => {
+        const engine = new API.ContextEngine(root);
+        expect((await engine.scan()).sort()).toEqual(['docs/guide.md', 'src/service.js', 'src/util.js']);
+
+        const analysis = await engine.analyze();
+        expect(Object.isFrozen(analysis.files)).toBe(true);
+        expect(analysis.stats.totalFiles).toBe(3);
+
+        const focused = await engine.pack(analysis, { focus: 'src/service.js', depth: 1 });
+        expect(focused.files.map(file => file.relativePath).sort()).toEqual(['src/service.js', 'src/util.js']);
+        const everything = await engine.pack(analysis);
+        expect(everything.files).toHaveLength(3);
+
+        const digest = await engine.render(focused, { format: 'gitingest' });
+        expect(digest).toContain('FILE: src/util.js');
+        expect(digest).not.toContain('docs/guide.md');
+        expect(JSON.parse(await engine.render(focused)).files).toHaveLength(2);
+        expect(await engine.generate({ format: 'markdown', symbols: ['parse'] })).toContain('src/util.js');
+    });
+
+    test('runs overlapping calls independently', async () => {
+        const engine = new ContextEngine(root);
+        const [budgeted, full] = await Promise.all([
+            engine.generate({ format: 'json', maxTokens: 200 }),
+            engine.generate({ format: 'json' })
+        ]);
+        expect(JSON.parse(budgeted).files.map(file => file.path)).not.toContain('docs/guide.md');
+        expect(JSON.parse(full).files).toHaveLength(3);
+    });
+
+    test('rejects aborted calls and invalid options', async () => {
+        const engine = new ContextEngine(root, { batchSize: 1 });
+        const controller = new AbortController();
+        const analyzing = engine.analyze({ signal: controller.signal });
+        controller.abort();
+        await expect(analyzing).rejects.toThrow('This operation was aborted');
+        await expect(engine.scan({ signal: AbortSignal.abort(new Error('client gone')) })).rejects.toThrow('client gone');
+
+        const analysis = await engine.analyze();
+        await expect(engine.pack(analysis, { maxTokens: 'lots' })).rejects.toThrow('Invalid maxTokens: lots');
+        await expect(engine.pack(analysis, { symbols: ['missing'] })).rejects.toThrow('Symbol not found: missing');
+        await expect(engine.pack(analysis, { reservePrompt: '2k' })).rejects.toThrow('reservePrompt requires maxTokens');
+        await expect(engine.pack(analysis, { maxTokens: '4k', reserveOutput: '4k' })).rejects.toThrow('leave no room in maxTokens 4000');
+        await expect(engine.render({ files: [] })).rejects.toThrow('Not a selection of this engine');
+        await expect(engine.render(await engine.pack(analysis), { format: 'toon' })).rejects.toThrow('Invalid format: toon');
+    });
+});
@@ -0,0 +1,32 @@
+import { describe, test, expect } from 'vitest';
+import ContextFreshness from '../lib/core/ContextFreshness.js';
+
+const digest = files => [
+    'Directory: shop',
+    `Files analyzed: ${Object.keys(files).length}`,
+    '',
+    ...Object.entries(files).flatMap(([file, content]) => ['', '='.repeat(48), `FILE: ${file}`, '='.repeat(48), content])
+].join('\n') + '\n';
+
+describe('ContextFreshness', () => {
+    test('picks the format from the extension', () => {
+        expect(['docs/llm-context.md', 'context.json', 'ctx.yml', 'ctx.xml', 'digest.txt', 'CONTEXT']
+            .map(file => ContextFreshness.formatOf(file))).toEqual(['markdown', 'json', 'yaml', 'xml', 'gitingest', 'gitingest']);
+    });
+
+    test('names the sections that changed, appeared or went away', () => {
+        const committed = digest({ 'src/cart.js': 'export const cart = [];', 'src/old.js': 'old();' });
+        const regenerated = digest({ 'src/cart.js': 'export const cart = new Map();', 'src/new.js': 'fresh();', 'src/z.js': 'z();' });
+
+        expect(ContextFreshness.compare(committed, committed)).toMatchObject({ fresh: true, firstDifference: null });
+        expect(ContextFreshness.compare(null, committed)).toMatchObject({ fresh: false, missing: true });
+
+        const result = ContextFreshness.compare(committed, regenerated);
+        expect(result).toMatchObject({ fresh: false, changed: ['src/cart.js'], added: ['src/new.js', 'src/z.js'], removed: ['src/old.js'] });
+        expect(result.firstDifference).toEqual({ line: 2, committed: 'Files analyzed: 2', regenerated: 'Files analyzed: 3' });
+
+        const report = ContextFreshness.format(result, 'CONTEXT.txt');
+        expect(report).toContain('❌ CONTEXT.txt is stale\n   ~ src/cart.js\n   + src/new.js (new)\n   + src/z.js (new)\n   - src/old.js (gone)');
+        expect(ContextFreshness.format(ContextFreshness.compare(committed, committed), 'CONTEXT.txt')).toBe('✅ CONTEXT.txt is up to date');
+    });
+});
@@ -0,0 +1,64 @@
+import { describe, test, expect } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextLayout from '../lib/core/ContextLayout.js';
+import ContextProfiles from '../lib/core/ContextProfiles.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+describe('ContextLayout', () => {
+    test('parses section lists and mappings', () => {
+        const layout = ContextLayout.parse('overview, docs:8k,code,tests:20k');
+        expect(layout.options.order).toEqual(['overview', 'docs', 'code', 'tests']);
+        expect(layout.options.caps).toEqual({ docs: 8000, tests: 20000 });
+        expect(layout.describe()).toBe('overview → docs (8k) → code → tests (20k)');
+
+        const mapped = ContextLayout.parse({ order: ['code', 'tree'], caps: { tree: '2k' }, separator: '\n---\n' });
+        expect(mapped.options).toEqual({ order: ['code', 'tree'], caps: { tree: 2000 }, separator: '\n---\n' });
+
+        expect(() => ContextLayout.parse('code,intro')).toThrow('Unknown layout section: intro');
+        expect(() => ContextLayout.parse('code,code')).toThrow('listed twice');
+        expect(() => ContextLayout.parse('code:lots')).toThrow('Invalid cap for layout section code');
+        expect(() => ContextLayout.parse({ order: ['code'], caps: { tests: 100 } })).toThrow('not in the order');
+        expect(() => new ContextProfiles({ small: { layout: 'code,nope' } })).toThrow('profile "small" has an invalid layout');
+    });
+
+    test('sorts files into docs, schemas, tests and code', () => {
+        expect(['README.md', 'docs/guide.rst', 'api/service.proto', 'db/migrations/001.sql', 'schemas/user.json',
+            'src/user.test.js', 'tests/test_user.py', 'src/user.js', 'config/app.json'].map(file => ContextLayout.sectionOf(file)))
+            .toEqual(['docs', 'docs', 'schemas', 'schemas', 'schemas', 'tests', 'tests', 'code', 'code']);
+    });
+
+    test('emits digest sections in order with caps and separators', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-layout-'));
+        const files = {
+            'README.md': '# Shop\n',
+            'src/cart.js': 'export const cart = [];\n',
+            'src/big.js': `export const big = "${'x'.repeat(400)}";\n`,
+            'src/cart.test.js': "test('cart', () => {});\n"
+        };
+        const results = Object.entries(files).map(([relativePath, content]) => {
+            fs.mkdirSync(path.join(root, path.dirname(relativePath)), { recursive: true });
+            fs.writeFileSync(path.join(root, relativePath), content);
+            return { path: path.join(root, relativePath), relativePath, tokens: content.length };
+        });
+
+        try {
+            const layout = ContextLayout.parse({ order: 'tests,code:40,docs,tree:12', separator: '\n~~~\n' });
+            const digest = new GitIngestFormatter(root, { totalFiles: 4, totalTokens: 0 }, results, {
+                layout,
+                countTokens: text => Math.ceil(text.length / 4)
+            }).generateDigest();
+
+            expect(digest.indexOf('FILE: src/cart.test.js')).toBeLessThan(digest.indexOf('FILE: README.md'));
+            expect(digest).not.toContain('Directory: ');
+            expect(digest).toContain('FILE: src/cart.js');
+            expect(digest).not.toContain('FILE: src/big.js');
+            expect(digest).toMatch(/\[code: 1 more file \(\d+ tokens\) over the 40 cap: src\/big\.js\]/);
+            expect(digest.split('\n~~~\n')).toHaveLength(4);
+            expect(digest).toMatch(/Directory structure:\n└── ctxman-layout-\w+\/\n\[tree: \d+ more lines over the 12 cap\]\n$/);
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
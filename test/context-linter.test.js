@@ -0,0 +1,116 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextLinter from '../lib/core/ContextLinter.js';
+
+const section = (file, content) => `\n${'='.repeat(48)}\nFILE: ${file}\n${'='.repeat(48)}\n${content}\n`;
+
+const selected = (symbols) => '// Selected symbols: showing 1 symbols\n\n' +
+    symbols.map(([header, code]) => `// ${header}\n${code}\n`).join('\n');
+
+const handler = 'export function handle(request) {\n' +
+    '  const session = new Session(request);\n' +
+    '  if (!session.valid) {\n    throw new Error(`Invalid session for ${request.url}`);\n  }\n' +
+    '  const response = { status: 200, body: session.user, headers: { "content-type": "application/json" } };\n' +
+    '  return response;\n}\n';
+
+const digest = 'Directory structure:\n└── app/\n' +
+    section('src/store.ts', selected([
+        ['class: Store (lines 3-9)', 'export class Store {\n  add(user: User) {\n    this.users.push(user);'],
+        ['import: imports (lines 1-1, preamble)', "import { User } from './user';"]
+    ])) +
+    section('scripts/build.py', selected([['function: build (lines 1-1)', 'def build(target):']])) +
+    section('src/handler.js', handler) +
+    section('src/handler-copy.js', handler) +
+    section('docs/guide.md', '// Summarized by gpt-4o-mini\n\nHow to use the app.');
+
+describe('ContextLinter', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-lint-'));
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.writeFileSync(path.join(root, 'src/user.ts'), 'export interface User {\n  id: string;\n}\n');
+        fs.writeFileSync(path.join(root, 'src/session.js'), 'export class Session {\n  constructor(request) {}\n}\n');
+        fs.writeFileSync(path.join(root, 'src/store.ts'), '// see digest\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const lint = (options = {}) => new ContextLinter({
+        root,
+        listFiles: dir => ['src/user.ts', 'src/session.js', 'src/store.ts'].map(file => path.join(dir, file)),
+        ...options
+    }).lint(ContextLinter.parse(digest, 'digest.txt'));
+
+    test('parses digest sections and their symbol excerpts', () => {
+        const context = ContextLinter.parse(digest, 'digest.txt');
+
+        expect(context.files.map(file => [file.path, file.included])).toEqual([
+            ['src/store.ts', 'partial'],
+            ['scripts/build.py', 'partial'],
+            ['src/handler.js', 'full'],
+            ['src/handler-copy.js', 'full'],
+            ['docs/guide.md', 'summary']
+        ]);
+        // The import preamble is context, not a selected symbol
+        expect(context.files[0].excerpts.map(excerpt => excerpt.name)).toEqual(['Store']);
+        expect(() => ContextLinter.parse('just some notes', 'notes.txt')).toThrow('not a generated context');
+    });
+
+    test('reports truncated symbols, dangling types and duplicates', async () => {
+        const report = await lint();
+        const rules = report.findings.map(finding => [finding.rule, finding.severity, finding.path]);
+
+        expect(rules).toEqual([
+            ['truncated-symbol', 'error', 'scripts/build.py'],
+            ['truncated-symbol', 'error', 'src/store.ts'],
+            ['dangling-reference', 'warning', 'src/handler.js'],
+            ['dangling-reference', 'warning', 'src/store.ts'],
+            ['duplicate-content', 'warning', 'src/handler.js']
+        ]);
+        expect(report.findings[1].message).toBe("Store (lines 3-9) is cut off: 2 unclosed '{'");
+        expect(report.findings[2].hint).toContain('--symbol src/session.js:Session');
+        expect(report.findings[3].message).toBe('User is used by src/store.ts but its definition (src/user.ts:1) is not in the context');
+        expect(report).toMatchObject({ files: 5, errors: 2, warnings: 3 });
+    });
+
+    test('checks the token budget and model windows', async () => {
+        const report = await lint({ rules: ['budget-overrun'], maxTokens: 50, models: ['gpt-4o'], tokenizer: 'estimate' });
+
+        expect(report.findings).toHaveLength(1);
+        expect(report.findings[0].message).toMatch(/^\d+ tokens exceed the 50-token budget$/);
+        expect(Object.keys(report.tokens)).toHaveLength(1);
+
+        const output = ContextLinter.formatReport(report);
+        expect(output).toContain('🔍 CONTEXT LINT: digest.txt');
+        expect(output).toContain('❌ [budget-overrun]');
+        expect(output).toContain('1 error, 0 warnings');
+    });
+
+    test('lints structured contexts by their partial files', async () => {
+        const context = ContextLinter.parse(JSON.stringify({
+            schema: 'ctxman.context/v1',
+            files: [{
+                path: 'src/store.ts',
+                included: 'partial',
+                content: 'export class Store {\n  add() {}\n}\n',
+                ranges: [{ name: 'Store', startLine: 3, endLine: 5, role: 'selected' }],
+                symbols: [{ name: 'Cache', kind: 'class', startLine: 7, included: false }]
+            }, {
+                path: 'src/index.ts',
+                included: 'full',
+                content: 'const cache = new Cache();\n'
+            }]
+        }), 'context.json');
+
+        const report = await new ContextLinter({ rules: ['truncated-symbol', 'dangling-reference'] }).lint(context);
+        expect(report.findings).toMatchObject([{
+            rule: 'dangling-reference',
+            message: 'Cache is used by src/index.ts but its definition (src/store.ts:7) is not in the context'
+        }]);
+    });
+});
@@ -0,0 +1,71 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextManifest, { MANIFEST_SCHEMA } from '../lib/core/ContextManifest.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('ContextManifest', () => {
+    let root;
+
+    const createManifest = () => ContextManifest.create({
+        project: 'app',
+        command: ['--cli', '-g', '--symbol', 'Service.fetch'],
+        contexts: [{ name: 'digest.txt', content: 'FILE: src/a.js\nexport const a = 1;\n' }],
+        files: [{ path: path.join(root, 'src/a.js'), relativePath: 'src/a.js', tokens: 8, lines: 1, sizeBytes: 20, selectedSymbols: ['a'] }],
+        readOriginal: filePath => fs.readFileSync(filePath, 'utf8'),
+        countTokens: text => Math.ceil(text.length / 4),
+        tokenizer: 'estimate',
+        version: '3.0.0',
+        git: { commit: 'b'.repeat(40), branch: 'main', dirty: false },
+        now: new Date('2026-01-02T03:04:05Z')
+    });
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-manifest-'));
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.writeFileSync(path.join(root, 'src/a.js'), 'export const a = 1;\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('writes a sidecar that reads back from the context path', () => {
+        const manifest = createManifest();
+        const contextPath = path.join(root, 'digest.txt');
+        fs.writeFileSync(contextPath, 'FILE: src/a.js\nexport const a = 1;\n');
+        ContextManifest.write(manifest, ContextManifest.sidecarOf(contextPath));
+
+        expect(ContextManifest.sidecarOf(contextPath)).toBe(`${contextPath}.manifest.json`);
+        expect(ContextManifest.read(contextPath)).toEqual(manifest);
+        expect(manifest).toMatchObject({
+            schema: MANIFEST_SCHEMA,
+            createdAt: '2026-01-02T03:04:05.000Z',
+            project: { name: 'app', commit: 'b'.repeat(40), branch: 'main', dirty: false },
+            contexts: [{ file: 'digest.txt', format: 'gitingest', size: 35, tokens: 9 }],
+            files: [{ path: 'src/a.js', tokens: 8, included: 'partial' }]
+        });
+        expect(ContextManifest.command(manifest)).toBe('ctxman --cli -g --symbol Service.fetch');
+
+        fs.writeFileSync(path.join(root, 'pack.json'), JSON.stringify({ schema: 'ctxman.pack/v1' }));
+        expect(() => ContextManifest.read(path.join(root, 'pack.json'))).toThrow('is not a context manifest (expected ctxman.manifest/v1)');
+    });
+
+    test('compares sources and regenerated contexts with the recorded hashes', () => {
+        const manifest = createManifest();
+        expect(ContextManifest.drift(manifest, root)).toEqual({ changed: [], missing: [] });
+        expect(ContextManifest.drift({ ...manifest, files: [...manifest.files, { path: 'gone.js', sha256: '' }] }, root))
+            .toEqual({ changed: [], missing: ['gone.js'] });
+
+        expect(ContextManifest.compare(manifest, new Map([['digest.txt', Buffer.from('FILE: src/a.js\nexport const a = 1;\n')]])))
+            .toEqual([{ file: 'digest.txt', status: 'identical', tokens: 9 }]);
+        expect(ContextManifest.compare(manifest, new Map([['digest.txt', Buffer.from('FILE: src/a.js\n')]]))[0].status).toBe('differs');
+        expect(ContextManifest.compare(manifest, new Map())[0].status).toBe('missing');
+    });
+
+    test('the calculator skips excluded outputs of earlier runs', () => {
+        const calculator = new TokenCalculator(root, { excludePaths: new Set(['digest.txt', 'digest.txt.manifest.json', 'pack.json']) });
+        expect(calculator.scanProject().map(file => path.relative(root, file))).toEqual([path.join('src', 'a.js')]);
+    });
+});
@@ -0,0 +1,74 @@
+import { describe, test, expect } from 'vitest';
+import ContextMerger from '../lib/core/ContextMerger.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+import StructuredFormatter, { STRUCTURED_SCHEMA } from '../lib/formatters/structured-formatter.js';
+
+function context(name, files, source = `${name}.json`) {
+    return ContextMerger.fromDocument({
+        schema: STRUCTURED_SCHEMA,
+        project: { name },
+        files: Object.entries(files).map(([path, content]) => ({ path, content, tokens: Math.ceil(content.length / 4), included: 'full' }))
+    }, source);
+}
+
+const functions = (prefix, count) => Array.from({ length: count }, (_, i) =>
+    `export function ${prefix}${i}(value) {\n    return value + ${i};\n}\n`).join('\n');
+
+describe('ContextMerger', () => {
+    test('keeps shared files once and lets later contexts replace changed ones', () => {
+        const monday = context('api', { 'src/a.js': 'const a = 1;\n', 'src/b.js': 'const b = 1;\n' }, 'monday.json');
+        const today = context('api', { 'src/b.js': 'const b = 2;\n', 'src/c.js': 'const c = 1;\n', 'src/a.js': 'const a = 1;\n' }, 'today.json');
+        const result = new ContextMerger().merge([monday, today]);
+
+        expect(result.prefixed).toBe(false);
+        expect(result.files.map(file => [file.path, file.content])).toEqual([
+            ['src/a.js', 'const a = 1;\n'],
+            ['src/b.js', 'const b = 2;\n'],
+            ['src/c.js', 'const c = 1;\n']
+        ]);
+        expect(result.overlapping).toBe(1);
+        expect(result.replaced).toEqual([{ path: 'src/b.js', source: 'monday.json', by: 'today.json' }]);
+        expect(ContextMerger.formatReport(result, [monday, today])).toContain('src/b.js: today.json replaces the one of monday.json');
+    });
+
+    test('prefixes the files of different projects and dedupes identical contents', () => {
+        const shared = 'export const VERSION = 3;\n';
+        const contexts = [
+            context('api', { 'src/version.js': shared, 'src/server.js': 'serve();\n' }),
+            context('web', { 'src/version.js': shared, 'src/app.js': 'render();\n' })
+        ];
+
+        const merged = new ContextMerger().merge(contexts);
+        expect(merged.project).toBe('api+web');
+        expect(merged.files.map(file => file.path)).toEqual(['api/src/version.js', 'api/src/server.js', 'web/src/version.js', 'web/src/app.js']);
+
+        const deduped = new ContextMerger({ dedupe: true }).merge(contexts);
+        expect(deduped.files.map(file => file.path)).toEqual(['api/src/version.js', 'api/src/server.js', 'web/src/app.js']);
+        expect(deduped.duplicates).toEqual([{ path: 'web/src/version.js', of: 'api/src/version.js', tokens: 7 }]);
+
+        const { fileInfos, read } = ContextMerger.toFileInfos(deduped.files);
+        const document = new StructuredFormatter('/work/api+web', { totalFiles: 3, totalTokens: 0 }, fileInfos, { readFile: read }).build();
+        expect(document.files.find(file => file.path === 'api/src/version.js')).toMatchObject({ path: 'api/src/version.js', content: shared, note: 'Near-duplicates omitted: web/src/version.js (100%)' });
+    });
+
+    test('packs the merged files into a token budget', async () => {
+        const result = new ContextMerger().merge([
+            context('api', { 'src/small.js': functions('small', 2) }),
+            context('api', { 'src/large.js': functions('large', 40) })
+        ]);
+        const budget = await TokenBudget.create({ maxTokens: 400, tokenizer: 'estimate' });
+        const { fileInfos, plan } = ContextMerger.toFileInfos(result.files, { budget });
+
+        expect(plan.limit).toBe(400);
+        expect(fileInfos.reduce((sum, fileInfo) => sum + fileInfo.tokens, 0)).toBeLessThanOrEqual(400);
+        expect(fileInfos.find(fileInfo => fileInfo.path === 'src/small.js').selectionNote).toBeUndefined();
+        const large = fileInfos.find(fileInfo => fileInfo.path === 'src/large.js');
+        expect(large.selectionNote).toMatch(/to fit token budget/);
+    });
+
+    test('rejects contexts without file contents', () => {
+        expect(() => ContextMerger.fromDocument({ files: [] }, 'llm-context.json')).toThrow('llm-context.json is not a structured context');
+        expect(() => ContextMerger.fromDocument({ schema: STRUCTURED_SCHEMA, files: [{ path: 'a.js' }] }, 'paths.json'))
+            .toThrow('paths.json has no content for a.js');
+    });
+});
@@ -0,0 +1,133 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { once } from 'events';
+import ContextMetrics, { MetricsRegistry, METRICS_CONTENT_TYPE } from '../lib/core/ContextMetrics.js';
+import APIServer from '../lib/api/rest/server.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+// Value of one sample line, e.g. metric('ctxman_runs_total{mode="http"}')
+const sample = (text, series) => {
+    const line = text.split('\n').find(entry => entry.startsWith(`${series} `));
+    return line === undefined ? undefined : Number(line.slice(series.length + 1));
+};
+
+describe('ContextMetrics', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-metrics-'));
+        const files = {
+            '.gitignore': '*.log\n',
+            'src/app.js': 'export function start() {\n    return 1;\n}\n',
+            'src/util.js': 'export const twice = x => x * 2;\n',
+            'debug.log': 'noise\n'
+        };
+        for (const [name, content] of Object.entries(files)) {
+            fs.mkdirSync(path.dirname(path.join(root, name)), { recursive: true });
+            fs.writeFileSync(path.join(root, name), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('renders counters, gauges and histograms in the text format', () => {
+        const registry = new MetricsRegistry()
+            .define('jobs_total', 'counter', 'Jobs run')
+            .define('queue_depth', 'gauge', 'Jobs waiting')
+            .define('job_seconds', 'histogram', 'Job time', [0.1, 1]);
+        registry.inc('jobs_total', { queue: 'a "b"' });
+        registry.inc('jobs_total', { queue: 'a "b"' }, 2);
+        registry.set('queue_depth', {}, 4);
+        registry.observe('job_seconds', {}, 0.05);
+        registry.observe('job_seconds', {}, 0.5);
+        registry.observe('job_seconds', {}, 3);
+
+        expect(registry.render()).toBe([
+            '# HELP jobs_total Jobs run',
+            '# TYPE jobs_total counter',
+            'jobs_total{queue="a \\"b\\""} 3',
+            '# HELP queue_depth Jobs waiting',
+            '# TYPE queue_depth gauge',
+            'queue_depth 4',
+            '# HELP job_seconds Job time',
+            '# TYPE job_seconds histogram',
+            'job_seconds_bucket{le="0.1"} 1',
+            'job_seconds_bucket{le="1"} 2',
+            'job_seconds_bucket{le="+Inf"} 3',
+            'job_seconds_sum 3.55',
+            'job_seconds_count 3',
+            ''
+        ].join('\n'));
+        expect(() => registry.inc('missing_total')).toThrow('Unknown metric: missing_total');
+    });
+
+    test('serves GET /metrics only', async () => {
+        const metrics = new ContextMetrics({ mode: 'daemon', version: '9.9.9' });
+        metrics.recordRequest('context', 'ok');
+        const server = await metrics.listen({ port: 0 });
+        const baseUrl = `http://localhost:${server.address().port}`;
+        try {
+            const response = await fetch(`${baseUrl}/metrics`);
+            expect(response.headers.get('content-type')).toBe(METRICS_CONTENT_TYPE);
+            const text = await response.text();
+            expect(sample(text, 'ctxman_info{version="9.9.9",mode="daemon"}')).toBe(1);
+            expect(sample(text, 'ctxman_requests_total{mode="daemon",route="context",outcome="ok"}')).toBe(1);
+
+            expect((await fetch(`${baseUrl}/`)).status).toBe(404);
+            expect((await fetch(`${baseUrl}/metrics`, { method: 'POST' })).status).toBe(405);
+        } finally {
+            server.close();
+        }
+    });
+
+    describe('TokenCalculator integration', () => {
+        test('records scan time, files, tokens and cache lookups of a run', () => {
+            const metrics = new ContextMetrics({ mode: 'daemon' });
+            const output = path.join(root, 'digest.txt');
+            const calculator = new TokenCalculator(root, { metrics, gitingest: true, outputFile: output });
+            calculator.run();
+            fs.rmSync(output);
+
+            const text = metrics.render();
+            expect(sample(text, 'ctxman_runs_total{mode="daemon"}')).toBe(1);
+            expect(sample(text, 'ctxman_scan_duration_seconds_count{mode="daemon"}')).toBe(1);
+            expect(sample(text, 'ctxman_scan_duration_seconds_bucket{mode="daemon",le="+Inf"}')).toBe(1);
+            expect(sample(text, 'ctxman_files_scanned_total{mode="daemon"}')).toBe(calculator.stats.totalFiles);
+            expect(sample(text, 'ctxman_files_skipped_total{mode="daemon",reason="ignored"}')).toBe(calculator.stats.ignoredFiles);
+            expect(calculator.stats.ignoredFiles).toBeGreaterThan(0);
+            expect(sample(text, 'ctxman_files_exported_total{mode="daemon"}')).toBe(2);
+            expect(sample(text, 'ctxman_tokens_emitted_total{mode="daemon"}')).toBe(calculator.stats.totalTokens);
+        });
+
+        test('counts requests and generated contexts of the HTTP server', async () => {
+            const metrics = new ContextMetrics({ mode: 'http' });
+            const server = new APIServer({ port: 0, projectRoot: root, metrics, service: { symbolBackend: 'heuristic' } });
+            server.start();
+            await once(server.server, 'listening');
+            const baseUrl = `http://localhost:${server.server.address().port}`;
+            try {
+                const context = await (await fetch(`${baseUrl}/context`)).json();
+                await fetch(`${baseUrl}/context?format=csv`);
+                await fetch(`${baseUrl}/no/such/page`);
+                // Requests are counted once answered
+                await new Promise(resolve => setTimeout(resolve, 20));
+
+                const text = metrics.render();
+                expect(sample(text, 'ctxman_requests_total{mode="http",route="/context",outcome="200"}')).toBe(1);
+                expect(sample(text, 'ctxman_requests_total{mode="http",route="/context",outcome="400"}')).toBe(1);
+                expect(sample(text, 'ctxman_requests_total{mode="http",route="other",outcome="404"}')).toBe(1);
+                expect(sample(text, 'ctxman_runs_total{mode="http"}')).toBe(1);
+                expect(sample(text, 'ctxman_files_exported_total{mode="http"}')).toBe(context.files.length);
+                expect(sample(text, 'ctxman_tokens_emitted_total{mode="http"}'))
+                    .toBe(context.files.reduce((sum, file) => sum + file.tokens, 0));
+                expect(sample(text, 'ctxman_cache_misses_total')).toBeGreaterThan(0);
+            } finally {
+                server.server.close();
+            }
+        });
+    });
+});
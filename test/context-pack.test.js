@@ -0,0 +1,80 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import zlib from 'zlib';
+import ContextPack, { PACK_SCHEMA } from '../lib/core/ContextPack.js';
+import ContextComparer from '../lib/core/ContextComparer.js';
+
+describe('ContextPack', () => {
+    let root;
+
+    const createPack = () => ContextPack.create({
+        project: 'app',
+        command: ['--gitingest', '--max-tokens', '8k'],
+        contexts: [{ name: 'digest.txt', content: 'FILE: src/a.js\nexport const a = 1;\n' }],
+        files: [
+            { path: path.join(root, 'src/a.js'), relativePath: 'src/a.js', tokens: 8, lines: 1, sizeBytes: 20 },
+            { path: path.join(root, 'README.md'), relativePath: 'README.md', tokens: 5, lines: 2, sizeBytes: 12, summary: '# App' }
+        ],
+        readOriginal: filePath => fs.readFileSync(filePath, 'utf8'),
+        countTokens: text => Math.ceil(text.length / 4),
+        tokenizer: 'estimate',
+        version: '3.0.0',
+        git: { commit: 'a'.repeat(40), branch: 'main', dirty: false },
+        now: new Date('2026-01-02T03:04:05Z')
+    });
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-pack-'));
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.writeFileSync(path.join(root, 'src/a.js'), 'export const a = 1;\n');
+        fs.writeFileSync(path.join(root, 'README.md'), '# App\n\nHello\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('round-trips manifest, token map and contexts', () => {
+        const pack = createPack();
+        const read = ContextPack.fromBuffer(pack.toBuffer());
+
+        expect(read.manifest).toEqual(pack.manifest);
+        expect(read.manifest.schema).toBe(PACK_SCHEMA);
+        expect(read.manifest.files.map(file => [file.path, file.included])).toEqual([['README.md', 'summary'], ['src/a.js', 'full']]);
+        expect(read.manifest.contexts[0]).toMatchObject({ file: 'digest.txt', format: 'gitingest', size: 35 });
+        expect(read.tokenMap).toEqual({ tokenizer: 'estimate', total: 13, directories: { '.': 5, src: 8 }, files: { 'README.md': 5, 'src/a.js': 8 } });
+        expect(read.entries.get('digest.txt').toString()).toBe('FILE: src/a.js\nexport const a = 1;\n');
+        expect(pack.toBuffer().equals(createPack().toBuffer())).toBe(true);
+        expect(pack.regenerateCommand()).toBe('ctxman pack --gitingest --max-tokens 8k');
+    });
+
+    test('rejects corrupted contexts and other archives', () => {
+        const tar = zlib.gunzipSync(createPack().toBuffer());
+        const tampered = Buffer.from(tar.toString('latin1').replace('export const a = 1;', 'export const a = 2;'), 'latin1');
+
+        expect(() => ContextPack.fromBuffer(zlib.gzipSync(tampered), 'app.ctxpack'))
+            .toThrow('app.ctxpack: digest.txt does not match its sha256 in manifest.json');
+        expect(() => ContextPack.fromBuffer(Buffer.from('not a pack'), 'x.ctxpack')).toThrow('x.ctxpack is not a context pack');
+    });
+
+    test('compares packs by their files', () => {
+        const file = path.join(root, 'app.ctxpack');
+        createPack().write(file);
+
+        const context = ContextComparer.load(file);
+        expect(context.format).toBe('pack');
+        expect([...context.files.keys()]).toEqual(['README.md', 'src/a.js']);
+        expect(ContextComparer.compare(context, context).files.unchanged).toBe(2);
+    });
+
+    test('reports sources changed since packing', () => {
+        const pack = createPack();
+        expect(pack.drift(root)).toEqual({ changed: [], missing: [] });
+
+        fs.writeFileSync(path.join(root, 'src/a.js'), 'export const a = 2;\n');
+        fs.rmSync(path.join(root, 'README.md'));
+        expect(pack.drift(root)).toEqual({ changed: ['src/a.js'], missing: ['README.md'] });
+    });
+});
@@ -0,0 +1,172 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import YAMLParser from '../lib/parsers/yaml-parser.js';
+import ContextProfiles from '../lib/core/ContextProfiles.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const CONTEXT_YAML = `# Shared profiles
+profiles:
+  api-review:
+    description: Public API surface
+    include: ["src/api/**", "src/models/**"]
+    exclude:
+      - "**/*.test.js"
+    budget: 32k
+    format: json
+    output: api-review.json
+    priority:
+      "src/api/**": 100
+      src/models/**: 80
+  bugfix:
+    extends: api-review
+    include: [src/**]
+    format: gitingest
+    output: null
+`;
+
+describe('YAMLParser', () => {
+    test('parses mappings, sequences and scalars', () => {
+        expect(YAMLParser.parse([
+            'name: app # comment',
+            'count: 3',
+            'ratio: 0.5',
+            'enabled: true',
+            'empty: ~',
+            "quoted: 'it''s # not a comment'",
+            'plain: don\'t # comment',
+            'list:',
+            '- a',
+            '- "b c"',
+            'flow: [x, "y, z", 1]',
+            'items:',
+            '  - pattern: src/**',
+            '    priority: 10',
+            '  - {}'
+        ].join('\n'))).toEqual({
+            name: 'app',
+            count: 3,
+            ratio: 0.5,
+            enabled: true,
+            empty: null,
+            quoted: "it's # not a comment",
+            plain: "don't",
+            list: ['a', 'b c'],
+            flow: ['x', 'y, z', 1],
+            items: [{ pattern: 'src/**', priority: 10 }, {}]
+        });
+        expect(YAMLParser.parse('# nothing\n')).toBeNull();
+    });
+
+    test('reports unsupported and malformed input by line', () => {
+        expect(() => YAMLParser.parse('a: 1\na: 2')).toThrow('Line 2: duplicate key "a"');
+        expect(() => YAMLParser.parse('a:\n\t- b')).toThrow('Line 2: tabs are not allowed');
+        expect(() => YAMLParser.parse('a: |\n  text')).toThrow('Line 1: unsupported YAML syntax');
+        expect(() => YAMLParser.parse('a:\n    b: 1\n  c: 2')).toThrow('Line 3: unexpected indentation');
+    });
+});
+
+describe('ContextProfiles', () => {
+    test('resolves profiles and their extends chain', () => {
+        const profiles = ContextProfiles.parse(CONTEXT_YAML);
+
+        expect(profiles.names()).toEqual(['api-review', 'bugfix']);
+        expect(profiles.resolve('api-review')).toMatchObject({
+            name: 'api-review',
+            include: ['src/api/**', 'src/models/**'],
+            exclude: ['**/*.test.js'],
+            budget: 32000,
+            tokenizer: null,
+            format: 'json',
+            output: 'api-review.json',
+            priority: [{ pattern: 'src/api/**', priority: 100 }, { pattern: 'src/models/**', priority: 80 }]
+        });
+        expect(profiles.resolve('bugfix')).toMatchObject({
+            name: 'bugfix',
+            description: 'Public API surface',
+            include: ['src/**'],
+            exclude: ['**/*.test.js'],
+            budget: 32000,
+            format: 'gitingest',
+            output: null
+        });
+    });
+
+    test('rejects unknown names, keys, values and extends cycles', () => {
+        const profiles = ContextProfiles.parse(CONTEXT_YAML);
+        expect(() => profiles.resolve('docs')).toThrow('Unknown profile "docs" (available: api-review, bugfix)');
+
+        expect(() => ContextProfiles.parse('profiles:\n  a:\n    includes: [src]'))
+            .toThrow('context.yaml: profile "a" has unknown keys: includes');
+        expect(() => ContextProfiles.parse('profiles:\n  a:\n    budget: lots'))
+            .toThrow('profile "a" has an invalid budget: lots');
+        expect(() => ContextProfiles.parse('profiles:\n  a:\n    format: pdf'))
+            .toThrow('profile "a" has an invalid format: pdf');
+        expect(() => ContextProfiles.parse('profiles:\n  a:\n    priority:\n      src/**: high'))
+            .toThrow('profile "a" priority must map globs to numbers');
+        expect(() => ContextProfiles.parse('profiles:\n  a:\n    extends: b\n  b:\n    extends: a').resolve('a'))
+            .toThrow('profile "a" extends itself (a -> b -> a)');
+        expect(() => ContextProfiles.parse('profiles: [a]', 'context.yml'))
+            .toThrow('context.yml: expected a "profiles" mapping');
+    });
+
+    test('reads priority weights and pins', () => {
+        const profiles = ContextProfiles.parse('profiles:\n  a:\n    weights:\n      churn: 2\n      fan-in: 0\n    pins: [src/main.js]\n  b:\n    extends: a');
+
+        expect(profiles.resolve('b')).toMatchObject({ weights: { churn: 2, fanIn: 0 }, pins: ['src/main.js'] });
+        expect(ContextProfiles.parse('profiles:\n  a: {}').resolve('a')).toMatchObject({ weights: null, pins: [] });
+        expect(() => ContextProfiles.parse('profiles:\n  a:\n    weights:\n      age: 1'))
+            .toThrow('profile "a" has invalid weights: Unknown priority signal: age');
+    });
+
+    test('first matching priority rule wins', () => {
+        const profile = ContextProfiles.parse(CONTEXT_YAML).resolve('api-review');
+
+        expect(ContextProfiles.priorityOf(profile, 'src/api/routes.js')).toBe(100);
+        expect(ContextProfiles.priorityOf(profile, 'src/models/user.js')).toBe(80);
+        expect(ContextProfiles.priorityOf(profile, 'README.md')).toBeNull();
+    });
+});
+
+describe('TokenCalculator with a profile', () => {
+    let root;
+
+    const write = (file, content = '') => {
+        fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+        fs.writeFileSync(path.join(root, file), content);
+    };
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-profiles-'));
+        write('context.yaml', CONTEXT_YAML);
+        write('.gitignore', 'src/api/generated.js\n');
+        write('src/api/routes.js', 'export const routes = [];\n');
+        write('src/api/routes.test.js', 'test();\n');
+        write('src/api/generated.js', '');
+        write('src/models/user.js', 'export class User {}\n');
+        write('src/cli.js', '');
+        write('README.md', '# app\n');
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('profile globs narrow the scan and priority rules rank files', () => {
+        const profile = ContextProfiles.load(root).resolve('api-review');
+        const calculator = new TokenCalculator(root, { profile });
+        const files = calculator.scanDirectory(root).map(file => path.relative(root, file).split(path.sep).join('/')).sort();
+
+        expect(files).toEqual(['src/api/routes.js', 'src/models/user.js']);
+        expect(calculator.analyzeFile(path.join(root, 'src/api/routes.js')).priority).toBe(100);
+        expect(calculator.analyzeFile(path.join(root, 'src/cli.js'))).not.toHaveProperty('priority');
+        expect(calculator.profileOutput('json')).toBe('api-review.json');
+        expect(calculator.profileOutput('gitingest')).toBeNull();
+    });
+
+    test('projects without context.yaml have no profiles', () => {
+        fs.rmSync(path.join(root, 'context.yaml'));
+        expect(ContextProfiles.load(root)).toBeNull();
+    });
+});
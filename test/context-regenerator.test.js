@@ -0,0 +1,123 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextRegenerator, { DEFAULT_OUTPUTS } from '../lib/watch/ContextRegenerator.js';
+import FileWatcher from '../lib/watch/FileWatcher.js';
+
+describe('ContextRegenerator', () => {
+    let root;
+
+    const write = (file, content) => {
+        fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+        fs.writeFileSync(path.join(root, file), content);
+    };
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-regen-'));
+        write('src/a.js', 'export function a() {\n    return 1;\n}\n');
+        write('src/b.js', 'export const b = 2;\n');
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('writes the digest on the first run', () => {
+        const regenerator = new ContextRegenerator(root);
+        const summary = regenerator.regenerate();
+
+        expect(summary).toMatchObject({ generation: 1, written: true, files: 2, output: DEFAULT_OUTPUTS.gitingest });
+        const digest = fs.readFileSync(path.join(root, 'digest.txt'), 'utf8');
+        expect(digest).toContain('FILE: src/a.js');
+        expect(digest).toContain('FILE: src/b.js');
+        expect(fs.existsSync(path.join(root, 'digest.txt.tmp'))).toBe(false);
+    });
+
+    test('reports updated, added and removed sections', () => {
+        const regenerator = new ContextRegenerator(root);
+        regenerator.regenerate();
+
+        write('src/a.js', 'export function a() {\n    return 42;\n}\n');
+        write('src/c.js', 'export const c = 3;\n');
+        fs.unlinkSync(path.join(root, 'src/b.js'));
+
+        const summary = regenerator.regenerate(['src/a.js', 'src/b.js', 'src/c.js']);
+        expect(summary.updated).toContain('src/a.js');
+        expect(summary.updated).toContain('tree');
+        expect(summary.added).toEqual(['src/c.js']);
+        expect(summary.removed).toEqual(['src/b.js']);
+
+        const digest = fs.readFileSync(path.join(root, 'digest.txt'), 'utf8');
+        expect(digest).toContain('return 42;');
+        expect(digest).not.toContain('FILE: src/b.js');
+
+        const report = ContextRegenerator.formatSummary(summary);
+        expect(report).toContain('Context #2 → digest.txt');
+        expect(report).toContain('+ src/c.js');
+        expect(report).toContain('- src/b.js');
+    });
+
+    test('skips the write when nothing changed', () => {
+        const regenerator = new ContextRegenerator(root);
+        regenerator.regenerate();
+        fs.writeFileSync(path.join(root, 'digest.txt'), 'sentinel');
+
+        const summary = regenerator.regenerate(['src/a.js']);
+        expect(summary.written).toBe(false);
+        expect(fs.readFileSync(path.join(root, 'digest.txt'), 'utf8')).toBe('sentinel');
+        expect(ContextRegenerator.formatSummary(summary)).toContain('Context unchanged (src/a.js)');
+    });
+
+    test('streams LLM context sections', () => {
+        const chunks = [];
+        const regenerator = new ContextRegenerator(root, {
+            format: 'context',
+            output: null,
+            stream: { write: chunk => chunks.push(chunk) }
+        });
+
+        regenerator.regenerate();
+        write('src/b.js', 'export const b = 3;\nexport const d = 4;\n');
+        const summary = regenerator.regenerate(['src/b.js']);
+
+        expect(chunks).toHaveLength(2);
+        expect(JSON.parse(chunks[0]).project).toBeDefined();
+        expect(summary.updated).toContain('project');
+        expect(ContextRegenerator.formatSummary(summary)).toContain('→ stdout');
+    });
+
+    test('batches queued changes and ignores its own output', async () => {
+        const regenerator = new ContextRegenerator(root, { debounce: 20 });
+        regenerator.regenerate();
+
+        const done = new Promise(resolve => regenerator.once('context:regenerated', resolve));
+        regenerator.queue({ relativePath: 'digest.txt' });
+        regenerator.queue({ relativePath: 'digest.txt.tmp' });
+        write('src/a.js', 'export function a() {}\n');
+        regenerator.queue({ relativePath: 'src/a.js' });
+        regenerator.queue({ relativePath: 'src/a.js' });
+
+        const summary = await done;
+        expect(summary.changedFiles).toEqual(['src/a.js']);
+        expect(summary.updated).toContain('src/a.js');
+        regenerator.stop();
+    });
+
+    test('rejects unknown formats', () => {
+        expect(() => new ContextRegenerator(root, { format: 'xml' })).toThrow('Unknown context format');
+    });
+});
+
+describe('FileWatcher ignore rules', () => {
+    test('skips vendored directories and configured patterns', () => {
+        const watcher = new FileWatcher(process.cwd(), { ignorePatterns: ['.ctxman', 'digest.txt', /\.tmp$/] });
+
+        expect(watcher.shouldIgnore('node_modules/x/index.js')).toBe(true);
+        expect(watcher.shouldIgnore('.ctxman/cache/tokens.json')).toBe(true);
+        expect(watcher.shouldIgnore('digest.txt')).toBe(true);
+        expect(watcher.shouldIgnore('out/llm-context.json.tmp')).toBe(true);
+        expect(watcher.shouldIgnore('digest.txt.bak')).toBe(false);
+        expect(watcher.shouldIgnore('lib/watch/FileWatcher.js')).toBe(false);
+    });
+});
@@ -0,0 +1,111 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextSession, { SESSION_SCHEMA } from '../lib/core/ContextSession.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const constants = count => Array.from({ length: count }, (_, i) => `export const v${i + 1} = ${i + 1};`).join('\n') + '\n';
+
+describe('ContextSession', () => {
+    let root;
+
+    const write = (file, content) => {
+        fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+        fs.writeFileSync(path.join(root, file), content);
+    };
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-session-'));
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('omits files supplied unchanged and narrows changed files to their regions', () => {
+        const session = ContextSession.load(root, 'chat');
+        const file = { relativePath: 'src/a.js' };
+        const content = constants(40);
+        expect(session.compare(file, content)).toEqual({ status: 'new' });
+
+        session.beginTurn();
+        session.record(file, content);
+        expect(session.compare(file, content)).toEqual({ status: 'unchanged', turn: 1 });
+
+        const changed = content.replace('v20 = 20', 'v20 = 2000').replace('export const v31 = 31;\n', '');
+        expect(session.compare(file, changed)).toEqual({
+            status: 'changed',
+            turn: 1,
+            ranges: [
+                { kind: 'region', name: 'changed', startLine: 17, endLine: 23 },
+                { kind: 'region', name: 'changed', startLine: 28, endLine: 34 }
+            ]
+        });
+        // Mostly rewritten files are resent whole
+        expect(session.compare(file, constants(4)).ranges).toBeNull();
+    });
+
+    test('drops symbols already supplied from partial files', () => {
+        const session = ContextSession.load(root, 'chat');
+        const content = 'import x from "x";\nfunction a() {}\nfunction b() {}\n';
+        const imports = { kind: 'imports', name: 'imports', startLine: 1, endLine: 1, context: true };
+        const a = { kind: 'function', name: 'a', startLine: 2, endLine: 2 };
+        const b = { kind: 'function', name: 'b', startLine: 3, endLine: 3 };
+
+        session.beginTurn();
+        session.record({ relativePath: 'src/a.js', selectedSymbols: [imports, a] }, content);
+        expect(session.compare({ relativePath: 'src/a.js', selectedSymbols: [imports, a] }, content).status).toBe('unchanged');
+        expect(session.compare({ relativePath: 'src/a.js', selectedSymbols: [imports, a, b] }, content))
+            .toEqual({ status: 'new', ranges: [imports, b] });
+        // Symbols do not stand for the whole file
+        expect(session.compare({ relativePath: 'src/a.js' }, content)).toEqual({ status: 'new' });
+    });
+
+    test('persists turns and lists, removes and reports sessions', () => {
+        write('src/a.js', 'export const a = 1;\n');
+        const session = ContextSession.load(root, 'chat');
+        session.beginTurn();
+        session.record({ relativePath: 'src/a.js' }, 'export const a = 1;\n');
+        session.record({ relativePath: 'src/gone.js' }, 'export const gone = 1;\n');
+        session.save(root, new Date('2026-01-02T03:04:05Z'));
+
+        const loaded = ContextSession.load(root, 'chat');
+        expect(loaded.turn).toBe(1);
+        expect(loaded.removed(root)).toEqual(['src/gone.js']);
+        expect(JSON.parse(fs.readFileSync(ContextSession.filePath(root, 'chat'), 'utf8')).schema).toBe(SESSION_SCHEMA);
+        expect(ContextSession.list(root)).toEqual([{ name: 'chat', turn: 1, files: 2, updatedAt: '2026-01-02T03:04:05.000Z' }]);
+
+        expect(() => ContextSession.load(root, '../chat')).toThrow('Invalid session name: ../chat');
+        expect(ContextSession.remove(root, 'chat')).toBe(true);
+        expect(ContextSession.list(root)).toEqual([]);
+    });
+
+    test('sends only the delta of later turns in an export', () => {
+        write('src/a.js', constants(40));
+        write('src/b.js', 'export const b = 1;\n');
+        const turn = () => {
+            const calculator = new TokenCalculator(root, { dashboard: true, session: ContextSession.load(root, 'chat') });
+            const results = calculator.selectExportResults(['src/a.js', 'src/b.js']
+                .map(file => calculator.analyzeFile(path.join(root, file))));
+            calculator.recordSession(results);
+            return { calculator, results };
+        };
+
+        expect(turn().results.map(file => file.session)).toEqual(['new', 'new']);
+
+        write('src/a.js', constants(40).replace('v20 = 20', 'v20 = 2000'));
+        const { calculator, results } = turn();
+        expect(results).toHaveLength(1);
+        expect(results[0]).toMatchObject({ relativePath: 'src/a.js', session: 'changed', selectionNote: 'Changed since turn 1' });
+        expect(calculator.sessionDelta).toMatchObject({ turn: 2, added: 0, changed: 1, omitted: [{ file: 'src/b.js', turn: 1 }] });
+
+        const digest = [...calculator.createGitIngestFormatter(results).generatePieces()].join('');
+        expect(digest).toContain('Conversation turn: 2 (session chat)');
+        expect(digest).toContain('  src/b.js (turn 1)');
+        expect(digest).toContain('// region: changed (lines 17-23)');
+        expect(digest).not.toContain('v1 = 1;');
+
+        expect(turn().results).toEqual([]);
+    });
+});
@@ -0,0 +1,90 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextTemplate from '../lib/core/ContextTemplate.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+function render(text, data = {}) {
+    return ContextTemplate.parse(text, 'test.tmpl').render(data);
+}
+
+describe('ContextTemplate', () => {
+    test('substitutes fields, variables and pipelines', () => {
+        const data = { Project: 'demo', Vars: new Map([['task', 'fix the cache']]), Count: 3 };
+        expect(render('{{.Project}}: {{.Vars.task | upper}} ({{printf "%03d" .Count}})', data))
+            .toBe('demo: FIX THE CACHE (003)');
+        expect(render('{{$name := .Project}}{{with .Vars}}{{$name}}/{{.task}}{{end}}', data)).toBe('demo/fix the cache');
+        expect(render('{{.Missing | default "none"}} {{len .Project}} {{print 1 2 "a" 3}}', { Missing: '', Project: 'demo' }))
+            .toBe('none 4 1 2a3');
+    });
+
+    test('supports if, range, define and whitespace trimming', () => {
+        const files = [{ Path: 'a.js', Tokens: 10 }, { Path: 'b.go', Tokens: 200 }];
+        const text = [
+            '{{- /* per-file headers */ -}}',
+            '{{define "size"}}{{if gt .Tokens 100}}large{{else if gt .Tokens 5}}small{{else}}tiny{{end}}{{end -}}',
+            '{{range $i, $file := .Files -}}',
+            '{{$i}}. {{$file.Path}} ({{template "size" $file}})',
+            '{{end -}}',
+            '{{range .None}}x{{else}}no other files{{end}}'
+        ].join('\n');
+
+        expect(render(text, { Files: files, None: [] })).toBe('0. a.js (small)\n1. b.go (large)\nno other files');
+        expect(render('{{if and .A (not .B)}}yes{{end}}|{{(index .Files 1).Path}}|{{join ", " .Names}}',
+            { A: [1], B: 0, Files: files, Names: ['x', 'y'] })).toBe('yes|b.go|x, y');
+    });
+
+    test('reports errors with the template name and line', () => {
+        expect(() => render('ok\n{{.Vars.task}}', { Vars: new Map() }))
+            .toThrow('template: test.tmpl:2: at <.Vars.task>: map has no entry for key "task"');
+        expect(() => render('{{.Nope}}', {})).toThrow("can't evaluate field Nope");
+        expect(() => render('{{if .A}}\nx')).toThrow('template: test.tmpl:1: unexpected EOF: {{if}} has no {{end}}');
+        expect(() => render('\n\n{{end}}')).toThrow('template: test.tmpl:3: unexpected {{end}}');
+        expect(() => render('{{shout .A}}')).toThrow('function "shout" not defined');
+    });
+
+    test('names the output after the template', () => {
+        expect(ContextTemplate.defaultFile('prompts/review.tmpl')).toBe('review.txt');
+        expect(ContextTemplate.defaultFile('prompt.md.tmpl')).toBe('prompt.md');
+        expect(ContextTemplate.defaultFile('wrap.tpl')).toBe('wrap.txt');
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-template-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src', 'math.js'), 'export function add(a, b) {\n    return a + b;\n}\n');
+            fs.writeFileSync(path.join(root, 'src', 'util.py'), 'def one():\n    return 1\n');
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('renders files, tree and variables into the output file', () => {
+            const template = ContextTemplate.parse([
+                'Task: {{.Vars.task}} ({{.TotalFiles}} files)',
+                '{{range .Files}}## {{.Path}} [{{.Language}}]',
+                '{{.Content}}{{end}}'
+            ].join('\n'), 'review.tmpl');
+            const calculator = new TokenCalculator(root, { template, templateVars: [['task', 'review']] });
+            const files = calculator.analyzeFiles(calculator.scanProject());
+
+            const outputPath = calculator.saveTemplatedOutput(files);
+            expect(outputPath).toBe(path.join(root, 'review.txt'));
+            expect(fs.readFileSync(outputPath, 'utf8')).toBe([
+                'Task: review (2 files)',
+                '## src/math.js [javascript]',
+                'export function add(a, b) {\n    return a + b;\n}\n## src/util.py [python]',
+                'def one():\n    return 1\n'
+            ].join('\n'));
+
+            const data = calculator.createTemplateData(files);
+            expect(data.Tree).toContain('├── math.js');
+            expect(data.Context).toContain('FILE: src/util.py');
+        });
+    });
+});
@@ -0,0 +1,151 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ContextWriter, { writeFileAtomic, BACKUP_SUFFIX } from '../lib/core/ContextWriter.js';
+import OutputTarget from '../lib/core/OutputTarget.js';
+import ContextPack from '../lib/core/ContextPack.js';
+import ContextManifest from '../lib/core/ContextManifest.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+import StructuredFormatter from '../lib/formatters/structured-formatter.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+// Collects what a writer hands to a stream, one write per entry
+const fakeStream = () => {
+    const stream = { writes: [], write: text => { stream.writes.push(text); } };
+    return stream;
+};
+
+describe('ContextWriter', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-writer-'));
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.writeFileSync(path.join(root, 'src/app.js'), 'export const app = () => 42;\n');
+        fs.writeFileSync(path.join(root, 'src/util.js'), 'export function add(a, b) {\n    return a + b;\n}\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('coalesces small pieces into bounded writes', () => {
+        const stream = fakeStream();
+        const writer = ContextWriter.toStream(stream, { bufferSize: 10 });
+        writer.writeAll(['abc', 'def', 'ghij', 'k', 'lmnopqrstu', 'v']);
+
+        expect(stream.writes).toEqual(['abcdefghij', 'klmnopqrstu']);
+        expect(writer.end()).toBe(22);
+        expect(stream.writes).toEqual(['abcdefghij', 'klmnopqrstu', 'v']);
+    });
+
+    test('stops producing pieces once the reader has gone', () => {
+        const stream = fakeStream();
+        let produced = 0;
+        function* pieces() {
+            for (;;) {
+                produced++;
+                if (produced === 3) stream.errored = new Error('write EPIPE');
+                yield 'x'.repeat(100);
+            }
+        }
+
+        ContextWriter.toStream(stream, { bufferSize: 1 }).writeAll(pieces()).end();
+        expect(produced).toBe(3);
+    });
+
+    test('replaces a file only once it is complete', () => {
+        const outputPath = path.join(root, 'out.txt');
+        fs.writeFileSync(outputPath, 'previous');
+
+        function* failing() {
+            yield 'partial';
+            throw new Error('render failed');
+        }
+        expect(() => ContextWriter.toFile(outputPath).writeAll(failing())).toThrow('render failed');
+        expect(fs.readFileSync(outputPath, 'utf8')).toBe('previous');
+        expect(fs.readdirSync(root).filter(file => file.endsWith('.tmp'))).toEqual([]);
+
+        expect(ContextWriter.toFile(outputPath).writeAll(['new ', 'context\n']).end()).toBe(12);
+        expect(fs.readFileSync(outputPath, 'utf8')).toBe('new context\n');
+    });
+
+    test('writes whole files atomically', () => {
+        const outputPath = path.join(root, 'atomic.txt');
+        writeFileAtomic(outputPath, 'previous');
+        writeFileAtomic(outputPath, Buffer.from('next'), { fsync: true, backup: true });
+
+        expect(fs.readFileSync(outputPath, 'utf8')).toBe('next');
+        expect(fs.readFileSync(`${outputPath}${BACKUP_SUFFIX}`, 'utf8')).toBe('previous');
+        expect(() => writeFileAtomic(path.join(root, 'missing', 'out.txt'), 'x')).toThrow('ENOENT');
+        expect(fs.readdirSync(root).filter(file => file.endsWith('.tmp'))).toEqual([]);
+    });
+
+    test('packs and manifests keep the previous file when a write fails', () => {
+        const pack = ContextPack.create({
+            project: 'app',
+            command: ['--gitingest'],
+            contexts: [{ name: 'digest.txt', content: 'FILE: src/app.js\nexport const app = () => 42;\n' }],
+            files: [{ path: path.join(root, 'src/app.js'), relativePath: 'src/app.js', tokens: 9, lines: 1, sizeBytes: 29 }],
+            readOriginal: filePath => fs.readFileSync(filePath, 'utf8'),
+            countTokens: text => Math.ceil(text.length / 4),
+            tokenizer: 'estimate',
+            version: '3.0.0',
+            git: null,
+            now: new Date('2026-01-02T03:04:05Z')
+        });
+        const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-writer-pack-'));
+        try {
+            const packPath = path.join(dir, 'app.ctxpack');
+            const manifestPath = path.join(dir, 'digest.txt.manifest.json');
+            pack.write(packPath);
+            pack.write(packPath, { backup: true });
+            expect(fs.readFileSync(`${packPath}${BACKUP_SUFFIX}`)).toEqual(fs.readFileSync(packPath));
+            ContextManifest.write(pack.manifest, manifestPath);
+            const extractDir = path.join(dir, 'extracted');
+            expect(pack.extract(extractDir).map(file => path.basename(file))).toEqual(['manifest.json', 'tokens.json', 'digest.txt']);
+
+            // A crash after part of the file is written leaves the previous one whole
+            const packed = fs.readFileSync(packPath);
+            const manifest = fs.readFileSync(manifestPath, 'utf8');
+            const writeSync = fs.writeSync;
+            fs.writeSync = (fd, buffer, offset) => {
+                if (offset > 0) throw new Error('disk full');
+                return writeSync(fd, buffer, 0, 16);
+            };
+            try {
+                expect(() => pack.write(packPath)).toThrow('disk full');
+                expect(() => ContextManifest.write({ ...pack.manifest, command: ['changed'] }, manifestPath)).toThrow('disk full');
+                expect(() => pack.extract(extractDir)).toThrow('disk full');
+            } finally {
+                fs.writeSync = writeSync;
+            }
+            expect(fs.readFileSync(packPath)).toEqual(packed);
+            expect(fs.readFileSync(manifestPath, 'utf8')).toBe(manifest);
+            expect(ContextPack.read(packPath).manifest.project.name).toBe('app');
+            expect(JSON.parse(fs.readFileSync(path.join(extractDir, 'manifest.json'), 'utf8')).project.name).toBe('app');
+            expect([...fs.readdirSync(dir), ...fs.readdirSync(extractDir)].filter(file => file.endsWith('.tmp'))).toEqual([]);
+        } finally {
+            fs.rmSync(dir, { recursive: true, force: true });
+        }
+    });
+
+    test('streams the same digest and JSON as the in-memory encoders', () => {
+        const calculator = new TokenCalculator(root);
+        const results = calculator.analyzeFiles(calculator.scanProject());
+        const digester = new GitIngestFormatter(root, calculator.stats, results);
+        const structured = new StructuredFormatter(root, calculator.stats, results);
+
+        const stdout = fakeStream();
+        const target = new OutputTarget({ target: 'stdout', root, stdout });
+        expect(target.stream(digester.generatePieces(), 'digest.txt').path).toBeNull();
+        expect(stdout.writes.join('')).toBe(digester.generateDigest());
+
+        const jsonPath = path.join(root, 'context.json');
+        structured.saveToFile(jsonPath, 'json');
+        expect(fs.readFileSync(jsonPath, 'utf8')).toBe(structured.encode('json'));
+        expect([...new StructuredFormatter(root, calculator.stats, []).encodePieces('json')].join(''))
+            .toBe(new StructuredFormatter(root, calculator.stats, []).encode('json'));
+    });
+});
@@ -0,0 +1,132 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import GitIgnoreParser from '../lib/parsers/gitignore-parser.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('GitIgnoreParser gitignore semantics', () => {
+    const parser = new GitIgnoreParser(null, null, null);
+    const matches = (pattern, relativePath, isDirectory = false) =>
+        parser.testPatterns([parser.convertToRegex(pattern)], relativePath, 'test', isDirectory);
+
+    test('a slash in the middle anchors the pattern', () => {
+        expect(matches('doc/frotz', 'doc/frotz')).toBe(true);
+        expect(matches('doc/frotz', 'a/doc/frotz')).toBe(false);
+        expect(matches('frotz', 'a/doc/frotz')).toBe(true);
+    });
+
+    test('trailing slash matches directories and their contents only', () => {
+        expect(matches('build/', 'build', true)).toBe(true);
+        expect(matches('build/', 'build', false)).toBe(false);
+        expect(matches('build/', 'src/build/out.js')).toBe(true);
+    });
+
+    test('double-star forms', () => {
+        expect(matches('**/fixtures', 'fixtures', true)).toBe(true);
+        expect(matches('**/fixtures', 'test/unit/fixtures', true)).toBe(true);
+        expect(matches('a/**/b', 'a/b')).toBe(true);
+        expect(matches('a/**/b', 'a/x/y/b')).toBe(true);
+        expect(matches('vendor/**', 'vendor/lib/x.js')).toBe(true);
+        expect(matches('vendor/**', 'vendor', true)).toBe(false);
+        expect(matches('vendor/**', 'src/vendor/x.js')).toBe(false);
+        expect(matches('foo**bar', 'foo/bar')).toBe(false);
+    });
+
+    test('character classes and escapes', () => {
+        expect(matches('*.[oa]', 'lib.a')).toBe(true);
+        expect(matches('*.[!oa]', 'lib.a')).toBe(false);
+        expect(matches('file[0-9].txt', 'file7.txt')).toBe(true);
+        expect(matches('\\#notes', '#notes')).toBe(true);
+        expect(matches('\\!important', '!important')).toBe(true);
+    });
+
+    test('files inside an excluded directory cannot be re-included', () => {
+        const patterns = ['logs/', '!logs/keep.log', '*.tmp', '!keep.tmp'].map(p => parser.convertToRegex(p));
+
+        expect(parser.testPatterns(patterns, 'logs/keep.log', 'test')).toBe(true);
+        expect(parser.testPatterns(patterns, 'a/b.tmp', 'test')).toBe(true);
+        expect(parser.testPatterns(patterns, 'a/keep.tmp', 'test')).toBe(false);
+    });
+});
+
+describe('GitIgnoreParser ignore files', () => {
+    let root;
+
+    const write = (file, content) => {
+        fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+        fs.writeFileSync(path.join(root, file), content);
+    };
+
+    const createParser = () => new GitIgnoreParser(
+        path.join(root, '.gitignore'),
+        path.join(root, '.contextignore'),
+        path.join(root, '.contextinclude'),
+        { rootDir: root }
+    );
+
+    const ignored = (parser, relativePath) => parser.isIgnored(path.join(root, relativePath), relativePath);
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-ignore-'));
+        write('.gitignore', 'dist/\n*.log\n');
+        write('.contextignore', '# generated bundles\nvendor/\ntest/fixtures/\n*.snap\n!keep.snap\n');
+        write('src/index.js', '');
+        write('src/app.log', '');
+        write('vendor/lib.js', '');
+        write('test/fixtures/data.json', '');
+        write('test/unit.test.js', '');
+        write('test/__snapshots__/a.snap', '');
+        write('test/__snapshots__/keep.snap', '');
+        write('packages/ui/.contextignore', 'stories/\n/generated.js\n');
+        write('packages/ui/stories/button.js', '');
+        write('packages/ui/generated.js', '');
+        write('packages/ui/src/generated.js', '');
+        write('packages/api/.gitignore', '!debug.log\n');
+        write('packages/api/debug.log', '');
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('.contextignore adds to .gitignore', () => {
+        const parser = createParser();
+
+        expect(ignored(parser, 'src/index.js')).toBe(false);
+        expect(ignored(parser, 'src/app.log')).toBe(true);
+        expect(parser._lastIgnoreReason).toBe('gitignore');
+        expect(ignored(parser, 'vendor')).toBe(true);
+        expect(parser._lastIgnoreReason).toBe('context');
+        expect(ignored(parser, 'vendor/lib.js')).toBe(true);
+        expect(ignored(parser, 'test/fixtures')).toBe(true);
+        expect(ignored(parser, 'test/unit.test.js')).toBe(false);
+        expect(ignored(parser, 'test/__snapshots__/a.snap')).toBe(true);
+        expect(ignored(parser, 'test/__snapshots__/keep.snap')).toBe(false);
+    });
+
+    test('nested ignore files apply relative to their directory', () => {
+        const parser = createParser();
+
+        expect(ignored(parser, 'packages/ui/stories')).toBe(true);
+        expect(ignored(parser, 'packages/ui/generated.js')).toBe(true);
+        expect(ignored(parser, 'packages/ui/src/generated.js')).toBe(false);
+        expect(ignored(parser, 'packages/api/debug.log')).toBe(false);
+    });
+
+    test('.contextignore cannot re-include what git ignores', () => {
+        write('.contextignore', '!*.log\n');
+        expect(ignored(createParser(), 'src/app.log')).toBe(true);
+    });
+
+    test('token calculator skips excluded files and directories', () => {
+        const calculator = new TokenCalculator(root, {});
+        const files = calculator.scanDirectory(root).map(file => path.relative(root, file).split(path.sep).join('/')).sort();
+
+        expect(files).toEqual([
+            'packages/ui/src/generated.js',
+            'src/index.js',
+            'test/unit.test.js'
+        ]);
+    });
+});
@@ -0,0 +1,109 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import CoverageHistory, { COVERAGE_FILE } from '../lib/core/CoverageHistory.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+
+describe('CoverageHistory', () => {
+    let root;
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-coverage-'));
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('counts how often files are packed', () => {
+        const history = new CoverageHistory(root, { maxRuns: 3 });
+        const run = (status, at) => history.record({
+            files: [
+                { path: 'src/api/server.js', tokens: 900, status },
+                { path: 'src/legacy/old.js', tokens: 400, status: 'excluded' },
+                { path: 'main.js', tokens: 100, status: 'full' }
+            ],
+            ignored: [{ path: 'vendor', files: 12 }],
+            profile: 'review'
+        }, new Date(at));
+        run('full', '2026-10-01T10:00:00Z');
+        run('partial', '2026-10-02T10:00:00Z');
+        run('excluded', '2026-10-03T10:00:00Z');
+        history.record({ files: [{ path: 'src/api/server.js', tokens: 900, status: 'summary' }] }, new Date('2026-10-04T10:00:00Z'));
+
+        const report = history.report({ depth: 1, minRuns: 2 });
+        expect(report).toMatchObject({ runs: 3, since: '2026-10-02T10:00:00.000Z' });
+        expect(report.directories.map(group => [group.path, group.files, group.included, group.seen, group.never])).toEqual([
+            ['.', 1, 3, 3, 0],
+            ['src', 2, 3, 7, 1]
+        ]);
+        expect(report.neverIncluded).toEqual([{ path: 'src/legacy/old.js', seen: 3, tokens: 400 }]);
+        expect(report.ignored).toEqual([{ path: 'vendor', seen: 3, files: 12 }]);
+
+        history.save();
+        const loaded = CoverageHistory.load(root);
+        expect(loaded.report({ depth: 1, minRuns: 2 })).toEqual(report);
+        expect(loaded.runs.at(-1)).toEqual({ run: 4, at: '2026-10-04T10:00:00.000Z', profile: null, mode: 'all', files: 1, included: 1 });
+
+        // Paths not seen in maxRuns runs are forgotten
+        history.record({ files: [] });
+        history.record({ files: [] });
+        expect([...history.files.keys()]).toEqual(['src/api/server.js']);
+        expect(history.ignored.size).toBe(0);
+
+        expect(CoverageHistory.remove(root)).toBe(true);
+        expect(CoverageHistory.load(root).runs).toEqual([]);
+        fs.writeFileSync(path.join(root, COVERAGE_FILE), '{"schema":"other"}');
+        expect(() => CoverageHistory.load(root)).toThrow('is not a ctxman coverage history');
+    });
+
+    test('formats a heat map', () => {
+        expect(CoverageHistory.format(new CoverageHistory(root).report())).toBe(
+            `No runs recorded in ${COVERAGE_FILE} yet (runs with --cache record what they pack)`);
+
+        const history = new CoverageHistory(root);
+        for (const day of [1, 2, 3, 4]) {
+            history.record({
+                files: [
+                    { path: 'src/api/server.js', tokens: 1200, status: day % 2 === 0 ? 'partial' : 'full' },
+                    { path: 'src/legacy/old.js', tokens: 4000, status: 'excluded' }
+                ],
+                ignored: [{ path: 'vendor', files: 12 }]
+            }, new Date(`2026-10-0${day}T10:00:00Z`));
+        }
+        expect(CoverageHistory.format(history.report(), { minRuns: 3 })).toBe([
+            '',
+            '🗺️  CONTEXT COVERAGE (4 runs since 2026-10-01, share of runs each file was included in)',
+            '='.repeat(80),
+            'src/api/     ██████████  100%      1 file      1.2K  50% partial',
+            'src/legacy/  ░░░░░░░░░░    0%      1 file      4.0K  1 never included',
+            '',
+            '🕳️  Never included (analyzed in 3+ runs):',
+            '   src/legacy/old.js (4 runs, 4.0K tokens)',
+            '',
+            '🚫 Excluded by .contextignore or the profile before analysis:',
+            '   vendor (12 files, 4 runs)'
+        ].join('\n'));
+    });
+
+    test('runs with a persistent cache record what they pack', () => {
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.mkdirSync(path.join(root, 'fixtures'));
+        fs.writeFileSync(path.join(root, 'src', 'a.js'), 'export const a = 1;\n');
+        fs.writeFileSync(path.join(root, 'src', 'b.js'), 'export const b = 2;\n');
+        fs.writeFileSync(path.join(root, 'fixtures', 'big.json'), '{}\n');
+        fs.writeFileSync(path.join(root, '.contextignore'), 'fixtures/\n');
+
+        const calculator = new TokenCalculator(root, { cache: new ContentCache({ root }) });
+        const analysis = calculator.analyzeFiles(calculator.scanDirectory(root));
+        calculator.analyzedResults = analysis;
+        calculator.recordCoverage(analysis.filter(fileInfo => fileInfo.relativePath.endsWith('a.js')));
+
+        const history = CoverageHistory.load(root);
+        expect(Object.fromEntries([...history.files].map(([file, entry]) => [file, entry.full]))).toMatchObject({ 'src/a.js': 1, 'src/b.js': 0 });
+        expect([...history.ignored.keys()]).toEqual(['fixtures']);
+        expect(history.runs[0]).toMatchObject({ mode: 'all', included: 1 });
+    });
+});
@@ -0,0 +1,118 @@
+import { describe, test, expect } from 'vitest';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import CrossReferenceIndex, { XREF_TITLE } from '../lib/graph/CrossReferenceIndex.js';
+import ContextLinter from '../lib/core/ContextLinter.js';
+import FormatRegistry from '../lib/formatters/format-registry.js';
+
+const FILES = {
+    'src/service.js': [
+        "import { parse } from './util.js';",
+        '',
+        'export class Service {',
+        '    fetch(url) {',
+        '        return parse(url);',
+        '    }',
+        '}'
+    ].join('\n'),
+    'src/main.js': [
+        "import { Service } from './service.js';",
+        "import { parse } from './util.js';",
+        '',
+        'export function main() {',
+        '    // parse is not called here',
+        '    const service = new Service();',
+        "    return service.fetch(parse(' x '));",
+        '}',
+        '',
+        'function fetch() {}'
+    ].join('\n'),
+    'src/util.js': [
+        'export function parse(text) {',
+        '    return text.trim();',
+        '}',
+        '',
+        'function unused() {}'
+    ].join('\n')
+};
+
+function buildGraph() {
+    return new DependencyGraph({ root: '/project' }).build(
+        Object.entries(FILES).map(([relativePath, content]) => ({ relativePath, content }))
+    );
+}
+
+const where = entries => Object.fromEntries(entries.map(entry => [
+    entry.name,
+    entry.references.map(reference => `${reference.file}:${reference.line}`)
+]));
+
+describe('CrossReferenceIndex', () => {
+    test('lists definitions and the included lines referencing them', () => {
+        const entries = new CrossReferenceIndex().build(buildGraph(), Object.keys(FILES).map(path => ({ path, ranges: null })));
+
+        expect(entries.map(entry => `${entry.name} ${entry.file}:${entry.line}`)).toEqual([
+            'main src/main.js:4',
+            'fetch src/main.js:10',
+            'Service src/service.js:3',
+            'Service.fetch src/service.js:4',
+            'parse src/util.js:1',
+            'unused src/util.js:5'
+        ]);
+        expect(where(entries)).toEqual({
+            main: [],
+            fetch: [],
+            Service: ['src/main.js:1', 'src/main.js:6'],
+            'Service.fetch': ['src/main.js:7'],
+            parse: ['src/main.js:2', 'src/main.js:7', 'src/service.js:1', 'src/service.js:5'],
+            unused: []
+        });
+    });
+
+    test('follows partial and summarized files', () => {
+        const entries = new CrossReferenceIndex().build(buildGraph(), [
+            { path: 'src/main.js', ranges: [{ startLine: 4, endLine: 8 }] },
+            { path: 'src/service.js', ranges: null, summary: true },
+            { path: 'src/util.js', ranges: [{ startLine: 1, endLine: 3 }] }
+        ]);
+
+        expect(where(entries)).toEqual({
+            main: [],
+            Service: ['src/main.js:6'],
+            'Service.fetch': ['src/main.js:7'],
+            parse: ['src/main.js:7']
+        });
+    });
+
+    test('formats the digest appendix, which lint keeps out of the last file', () => {
+        const index = new CrossReferenceIndex({ maxReferences: 2 });
+        const appendix = index.format(index.build(buildGraph(), Object.keys(FILES).map(path => ({ path, ranges: null }))));
+
+        expect(appendix.split('\n').slice(1, 4)).toEqual(['='.repeat(48), XREF_TITLE, '='.repeat(48)]);
+        expect(appendix).toContain([
+            'parse (function) — src/util.js:1',
+            '    src/main.js:2, src/main.js:7 +2 more',
+            'unused (function) — src/util.js:5',
+            '    not referenced in this context'
+        ].join('\n'));
+
+        const digest = `${'='.repeat(48)}\nFILE: src/util.js\n${'='.repeat(48)}\n${FILES['src/util.js']}\n${appendix}`;
+        expect(ContextLinter.parse(digest).files[0].content).toBe(`${FILES['src/util.js']}\n`);
+    });
+
+    test('renders in structured formats', () => {
+        const doc = {
+            project: { name: 'demo', files: 0, tokens: 0 },
+            selection: { mode: 'all', target: null, budget: null },
+            files: [],
+            crossReferences: [{ name: 'parse', kind: 'function', file: 'src/util.js', line: 1, references: [{ file: 'src/main.js', line: 7 }] }]
+        };
+        const registry = new FormatRegistry();
+
+        expect(registry.encode('xml', doc)).toContain([
+            '<cross_references>',
+            '<symbol name="parse" kind="function" defined="src/util.js:1">src/main.js:7</symbol>',
+            '</cross_references>'
+        ].join('\n'));
+        expect(registry.encode('markdown', doc)).toContain('## Cross-reference index\n\n- `parse` (function) — `src/util.js:1`\n  - `src/main.js:7`\n');
+    });
+});
@@ -0,0 +1,122 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DecisionLog, { DECISION_FILE } from '../lib/core/DecisionLog.js';
+import GitIgnoreParser from '../lib/parsers/gitignore-parser.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+import LicenseDetector from '../lib/core/LicenseDetector.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('DecisionLog', () => {
+    test('explains paths under a decided directory and round-trips through its file', () => {
+        const log = new DecisionLog();
+        log.record('vendor', { outcome: 'excluded', stage: 'ignore', reason: 'context', directory: true, rule: { pattern: 'vendor/', source: '.contextignore', line: 2, path: 'vendor' } });
+        log.record('src/a.js', { outcome: 'included', stage: 'select', reason: 'packed', tokens: 12, priority: 10 });
+        log.run = { at: '2026-10-14T09:00:00.000Z', mode: 'all', target: null, profile: null, transforms: null, budget: null };
+
+        const explanation = log.explain('./vendor/lib/x.js');
+        expect(explanation).toMatchObject({ path: 'vendor/lib/x.js', via: 'vendor', reason: 'context' });
+        expect(DecisionLog.format(explanation, log.run)).toBe([
+            '🔍 vendor/lib/x.js: excluded: ignored by .contextignore rules',
+            '   Directory vendor/ was ignored, so the scan did not enter it',
+            '   Rule: vendor/ (.contextignore:2)',
+            '   Run: 2026-10-14T09:00:00.000Z, mode all'
+        ].join('\n'));
+        expect(log.explain('src/b.js')).toBeNull();
+
+        const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-decisions-'));
+        try {
+            const file = log.save(path.join(dir, DECISION_FILE));
+            expect(DecisionLog.load(file).explain('src/a.js')).toMatchObject({ outcome: 'included', tokens: 12 });
+            fs.writeFileSync(file, '{"schema":"other"}');
+            expect(() => DecisionLog.load(file)).toThrow('is not a ctxman decision log');
+            expect(DecisionLog.load(path.join(dir, 'missing.json'))).toBeNull();
+        } finally {
+            fs.rmSync(dir, { recursive: true, force: true });
+        }
+    });
+
+    test('ignore rules report the pattern, file and line that matched', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-decisions-rules-'));
+        try {
+            fs.writeFileSync(path.join(root, '.gitignore'), '# outputs\n*.log\n!keep.log\nbuild/\n');
+            const parser = new GitIgnoreParser(path.join(root, '.gitignore'), null, null, { rootDir: root });
+            expect(parser.isIgnored(null, 'logs/app.log')).toBe(true);
+            expect(parser._lastIgnoreRule).toEqual({ pattern: '*.log', source: '.gitignore', line: 2, path: 'logs/app.log' });
+            expect(parser.isIgnored(null, 'keep.log')).toBe(false);
+            expect(parser._lastIgnoreRule).toBeNull();
+            expect(parser.isIgnored(null, 'build/out/main.js')).toBe(true);
+            expect(parser._lastIgnoreRule).toMatchObject({ pattern: 'build/', line: 4, path: 'build' });
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-decisions-run-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.mkdirSync(path.join(root, 'docs'));
+            fs.writeFileSync(path.join(root, 'src', 'small.js'), 'export const small = 1;\n');
+            fs.writeFileSync(path.join(root, 'src', 'large.js'), `export const large = [\n${'  "padding padding padding",\n'.repeat(80)}];\n`);
+            fs.writeFileSync(path.join(root, 'docs', 'notes.md'), '# Notes\n');
+            fs.writeFileSync(path.join(root, 'LICENSE'), 'MIT License\n\nPermission is hereby granted, free of charge, to any person obtaining a copy\n');
+            fs.writeFileSync(path.join(root, '.contextignore'), 'docs/\n');
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('records the stage that left each file out', () => {
+            const calculator = new TokenCalculator(root, {
+                tokenBudget: new TokenBudget({ maxTokens: 60, tokenizer: 'estimate', symbolFallback: false }),
+                licenseDetector: new LicenseDetector(),
+                dashboard: true
+            });
+            const analysis = calculator.analyzeFiles(calculator.scanDirectory(root));
+            calculator.analyzedResults = analysis;
+            calculator.recordDecisions(calculator.selectExportResults(analysis));
+
+            const log = DecisionLog.load(path.join(root, DECISION_FILE));
+            expect(log.explain('src/small.js')).toMatchObject({ outcome: 'included', stage: 'budget' });
+            expect(log.explain('docs/notes.md')).toMatchObject({ stage: 'ignore', reason: 'context', via: 'docs', rule: { pattern: 'docs/', line: 1 } });
+            expect(log.explain('LICENSE')).toMatchObject({ stage: 'transform', reason: 'license-file' });
+
+            const large = log.explain('src/large.js');
+            expect(large).toMatchObject({ outcome: 'excluded', stage: 'budget', reason: 'budget' });
+            expect(large.available).toBeLessThan(large.tokens);
+            expect(log.run.budget).toMatchObject({ limit: 60, exhaustedAt: { id: 'src/large.js' } });
+            expect(DecisionLog.format(large, log.run)).toContain(`Visited with ${large.available} tokens left; it needed ${large.tokens.toLocaleString()}`);
+
+            // The log of an earlier run is not scanned as a project file
+            expect(calculator.scanDirectory(root).some(file => file.endsWith('decisions.json'))).toBe(false);
+        });
+
+        test('writes the log only when it is requested', () => {
+            const project = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-decisions-opt-in-'));
+            const logFile = path.join(project, DECISION_FILE);
+            const run = options => {
+                const calculator = new TokenCalculator(project, { prompt: false, ...options });
+                calculator.completeRun(calculator.analyzeFiles(calculator.scanDirectory(project)));
+            };
+            try {
+                fs.writeFileSync(path.join(project, 'app.js'), 'export const app = 1;\n');
+                expect(new TokenCalculator(project, { profile: { name: 'review', decisions: true } }).decisionLogRequested()).toBe(true);
+
+                run({ decisionLog: true });
+                expect(DecisionLog.load(logFile).explain('app.js')).toMatchObject({ outcome: 'included' });
+
+                // A log left by an earlier run does not turn the next one on
+                fs.rmSync(logFile);
+                run({});
+                expect(fs.existsSync(logFile)).toBe(false);
+            } finally {
+                fs.rmSync(project, { recursive: true, force: true });
+            }
+        });
+    });
+});
@@ -0,0 +1,370 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DependencyGraph, { ProjectIndex } from '../lib/graph/DependencyGraph.js';
+import DependencyExpander from '../lib/graph/DependencyExpander.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+import { PythonPlugin } from '../lib/languages/PythonPlugin.js';
+import { TypeScriptPlugin } from '../lib/languages/TypeScriptPlugin.js';
+import { GoPlugin } from '../lib/languages/GoPlugin.js';
+import { JavaPlugin } from '../lib/languages/JavaPlugin.js';
+import { RustPlugin } from '../lib/languages/RustPlugin.js';
+
+const FILES = {
+    'src/service.ts': [
+        "import { parse, Options as Opts } from './util/parse';",
+        "import * as http from './http';",
+        '',
+        'export class Service {',
+        '    constructor(private opts: Opts) {}',
+        '',
+        '    fetch(url: string) {',
+        '        // parse the response body',
+        '        const res = http.get(url);',
+        '        return this.decode(parse(res));',
+        '    }',
+        '',
+        '    decode(value: string) {',
+        '        return value.trim();',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'src/util/parse.ts': [
+        'export interface Options {',
+        '    strict: boolean;',
+        '}',
+        '',
+        'export function parse(text: string): string {',
+        '    return normalize(text);',
+        '}',
+        '',
+        'function normalize(text: string) {',
+        '    return text;',
+        '}',
+        '',
+        'export function unused() {}',
+        ''
+    ].join('\n'),
+    'src/http.ts': 'export function get(url: string) {\n    return url;\n}\n\nexport function post(url: string) {}\n',
+    'go.mod': 'module example.com/app\n',
+    'main.go': [
+        'package main',
+        '',
+        'import (',
+        '\t"fmt"',
+        '\t"example.com/app/pkg/store"',
+        ')',
+        '',
+        'func main() {',
+        '\ts := store.New()',
+        '\tfmt.Println(s.Get("a"))',
+        '}',
+        ''
+    ].join('\n'),
+    'pkg/store/store.go': [
+        'package store',
+        '',
+        'type Store struct {',
+        '\titems map[string]string',
+        '}',
+        '',
+        'func New() *Store {',
+        '\treturn &Store{items: defaultItems()}',
+        '}',
+        '',
+        'func (s *Store) Get(key string) string {',
+        '\treturn s.items[key]',
+        '}',
+        '',
+        'func defaultItems() map[string]string {',
+        '\treturn nil',
+        '}',
+        ''
+    ].join('\n')
+};
+
+describe('LanguagePlugin imports', () => {
+    test('TypeScript: named, aliased, namespace and require imports', () => {
+        const imports = new TypeScriptPlugin().extractImports([
+            "import fs from 'fs';",
+            'import {',
+            '  a,',
+            '  b as c',
+            "} from './util.js';",
+            "export { d } from './d';",
+            "const q = require('./q');"
+        ].join('\n'));
+
+        expect(imports).toEqual([
+            { source: 'fs', names: null, aliases: {}, line: 1 },
+            { source: './util.js', names: ['a', 'b'], aliases: { c: 'b' }, line: 2 },
+            { source: './d', names: ['d'], aliases: {}, line: 6 },
+            { source: './q', names: null, line: 7 }
+        ]);
+
+        const project = new ProjectIndex('/', ['src/util.ts', 'x/index.js']);
+        const plugin = new TypeScriptPlugin();
+        expect(plugin.resolveImport({ source: './util.js' }, 'src/a.ts', project)).toBe('src/util.ts');
+        expect(plugin.resolveImport({ source: '../x' }, 'src/a.ts', project)).toBe('x/index.js');
+        expect(plugin.resolveImport({ source: 'fs' }, 'src/a.ts', project)).toBeNull();
+    });
+
+    test('Python: relative and absolute modules', () => {
+        const plugin = new PythonPlugin();
+        const imports = plugin.extractImports([
+            'import os, app.models as m',
+            'from .utils import helper, other as o',
+            'from ..core import (',
+            '    Base,',
+            ')',
+            'from . import views'
+        ].join('\n'));
+
+        expect(imports.map(i => i.source)).toEqual(['os', 'app.models', '.utils', '..core', '.views']);
+        expect(imports[2]).toMatchObject({ names: ['helper', 'other'], aliases: { o: 'other' } });
+
+        const project = new ProjectIndex('/', ['app/models.py', 'app/utils.py', 'core/__init__.py', 'app/views.py']);
+        const resolve = source => plugin.resolveImport({ source }, 'app/x.py', project);
+        expect(resolve('app.models')).toBe('app/models.py');
+        expect(resolve('.utils')).toBe('app/utils.py');
+        expect(resolve('..core')).toBe('core/__init__.py');
+        expect(resolve('os')).toBeNull();
+    });
+
+    test('Go: packages are directories under the go.mod module', () => {
+        const plugin = new GoPlugin();
+        const project = new ProjectIndex('/', ['main.go', 'internal/store/s.go']);
+        project.contents.set('go.mod', 'module example.com/app\n');
+
+        expect(plugin.resolveImport({ source: 'example.com/app/internal/store' }, 'main.go', project)).toBe('internal/store');
+        expect(plugin.resolveImport({ source: 'fmt' }, 'main.go', project)).toBeNull();
+        expect(plugin.getPackageId('internal/store/s.go')).toBe('internal/store');
+    });
+
+    test('Go: local replace directives and the longest module path win', () => {
+        const plugin = new GoPlugin();
+        const project = new ProjectIndex('/', ['main.go', 'lib/text/t.go', 'tools/gen/g.go']);
+        project.contents.set('go.mod', [
+            'module example.com/app',
+            '',
+            'replace example.com/lib => ./lib',
+            'replace (',
+            '\texample.com/app/tools v0.1.0 => ./tools // local copy',
+            '\texample.com/remote => example.com/fork v1.0.0',
+            ')'
+        ].join('\n'));
+
+        expect(plugin.resolveImport({ source: 'example.com/lib/text' }, 'main.go', project)).toBe('lib/text');
+        expect(plugin.resolveImport({ source: 'example.com/app/tools/gen' }, 'main.go', project)).toBe('tools/gen');
+        expect(plugin.resolveImport({ source: 'example.com/remote' }, 'main.go', project)).toBeNull();
+    });
+
+    test('Java: class, static and wildcard imports', () => {
+        const plugin = new JavaPlugin();
+        const imports = plugin.extractImports([
+            'import com.acme.model.User;',
+            'import static com.acme.util.Strings.trim;',
+            'import com.acme.service.*;'
+        ].join('\n'));
+
+        expect(imports).toEqual([
+            { source: 'com.acme.model.User', names: ['User'], line: 1 },
+            { source: 'com.acme.util.Strings', names: null, line: 2 },
+            { source: 'com.acme.service', names: null, wildcard: true, line: 3 }
+        ]);
+
+        const project = new ProjectIndex('/', ['src/main/java/com/acme/model/User.java', 'src/main/java/com/acme/service/A.java']);
+        expect(plugin.resolveImport(imports[0], 'x.java', project)).toBe('src/main/java/com/acme/model/User.java');
+        expect(plugin.resolveImport(imports[2], 'x.java', project)).toBe('src/main/java/com/acme/service');
+    });
+
+    test('Rust: use trees and mod declarations', () => {
+        const plugin = new RustPlugin();
+        const imports = plugin.extractImports([
+            'use std::collections::HashMap;',
+            'use crate::models::{User, Role as R};',
+            'use super::Config;',
+            'pub mod handlers;'
+        ].join('\n'));
+
+        expect(imports.map(i => i.source)).toEqual([
+            'std::collections::HashMap', 'crate::models::User', 'crate::models::Role', 'super::Config', 'self::handlers'
+        ]);
+        expect(imports[2].aliases).toEqual({ R: 'Role' });
+
+        const project = new ProjectIndex('/', ['src/lib.rs', 'src/models.rs', 'src/api/mod.rs', 'src/api/handlers.rs']);
+        const resolve = source => plugin.resolveImport({ source }, 'src/api/mod.rs', project);
+        expect(resolve('crate::models::User')).toBe('src/models.rs');
+        expect(resolve('super::Config')).toBe('src/lib.rs');
+        expect(resolve('self::handlers')).toBe('src/api/handlers.rs');
+        expect(resolve('std::collections::HashMap')).toBeNull();
+    });
+});
+
+describe('DependencyGraph', () => {
+    let root;
+    let graph;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-graph-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        graph = new DependencyGraph({ root }).build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('builds package-level edges', () => {
+        expect(graph.getDependencies('src/service.ts')).toEqual(['src/http.ts', 'src/util/parse.ts']);
+        expect(graph.getDependents('src/util/parse.ts')).toEqual(['src/service.ts']);
+        expect(graph.getDependencies('.')).toEqual(['pkg/store']);
+        expect(graph.getStats()).toMatchObject({ files: 5, packages: 5, edges: 3 });
+    });
+
+    test('named imports narrow visible symbols and keep aliases', () => {
+        const visible = graph.visibleSymbols('src/service.ts').map(v => `${v.local}=${v.symbol.qualifiedName}`);
+        expect(visible).toContain('Opts=Options');
+        expect(visible).toContain('parse=parse');
+        expect(visible).not.toContain('unused=unused');
+        expect(visible).toContain('post=post'); // namespace import exposes the module
+    });
+
+    test('expands a method to the definitions it calls', () => {
+        const expander = new DependencyExpander(graph);
+        const result = expander.expand('Service.fetch', 2);
+
+        expect(result.focus.map(e => e.symbol.qualifiedName)).toEqual(['Service.fetch']);
+        expect(result.dependencies.map(e => [e.symbol.qualifiedName, e.depth, e.via])).toEqual([
+            ['get', 1, 'Service.fetch'],
+            ['Service.decode', 1, 'Service.fetch'],
+            ['parse', 1, 'Service.fetch'],
+            ['normalize', 2, 'parse']
+        ]);
+        expect(result.files.map(f => f.file)).toEqual(['src/service.ts', 'src/http.ts', 'src/util/parse.ts']);
+    });
+
+    test('depth limits the walk', () => {
+        const expander = new DependencyExpander(graph);
+        expect(expander.expand('Service.fetch', 0).dependencies).toHaveLength(0);
+        expect(expander.expand('Service.fetch', 1).dependencies.map(e => e.symbol.name)).not.toContain('normalize');
+    });
+
+    test('follows Go packages and methods of selected types', () => {
+        const result = new DependencyExpander(graph).expand('main.go:main', 2);
+        expect(result.dependencies.map(e => e.symbol.qualifiedName).sort()).toEqual(['New', 'Store', 'Store.Get', 'defaultItems']);
+    });
+
+    test('resolves file focus and reports unknown symbols', () => {
+        const expander = new DependencyExpander(graph);
+        expect(expander.resolveFocus('src/http.ts')).toMatchObject([{ kind: 'module', startLine: 1 }]);
+        expect(expander.resolveFocus('nope')).toEqual([]);
+        expect(DependencyExpander.formatExpansion(expander.expand('parse', 1))).toContain('↳ function normalize');
+    });
+});
+
+describe('Go cross-package resolution', () => {
+    const GO_FILES = {
+        'go.mod': 'module example.com/app\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ./lib\n',
+        'api/handler.go': [
+            'package api',
+            '',
+            'import (',
+            '\tm "example.com/app/models"',
+            '\t. "example.com/app/util"',
+            '\t_ "example.com/app/plugins"',
+            '\t"example.com/app/config"',
+            '\t"example.com/lib/text"',
+            ')',
+            '',
+            'func Handle() m.User {',
+            '\tcfg := config.Load()',
+            '\treturn m.User{Name: text.Title(Clean(cfg.Name))}',
+            '}',
+            ''
+        ].join('\n'),
+        'cmd/main.go': 'package main\n\nimport "example.com/app/models"\n\nfunc main() {\n\t_ = model.User{}\n}\n',
+        'models/user.go': 'package model\n\ntype User struct {\n\tName string\n}\n\nfunc Load() User {\n\treturn User{}\n}\n',
+        'util/clean.go': 'package util\n\nfunc Clean(s string) string {\n\treturn s\n}\n',
+        'plugins/plugins.go': 'package plugins\n\nfunc Clean(s string) string {\n\treturn s\n}\n',
+        'config/config.go': 'package config\n\ntype Config struct {\n\tName string\n}\n\nfunc Load() Config {\n\treturn Config{}\n}\n',
+        'lib/go.mod': 'module example.com/lib\n',
+        'lib/text/title.go': 'package text\n\nfunc Title(s string) string {\n\treturn s\n}\n'
+    };
+
+    let root;
+    let graph;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-go-'));
+        for (const [file, content] of Object.entries(GO_FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        graph = new DependencyGraph({ root }).build(Object.keys(GO_FILES).map(relativePath => ({ relativePath })));
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('links imports to packages of the module and its replaced modules', () => {
+        expect(graph.getDependencies('api')).toEqual(['config', 'lib/text', 'models', 'plugins', 'util']);
+        expect(graph.getFile('cmd/main.go').imports[0]).toMatchObject({ package: 'models', packageName: 'model' });
+    });
+
+    test('qualifies imported names by alias or declared package name', () => {
+        const visible = graph.visibleSymbols('api/handler.go').map(v => `${v.local}=${v.symbol.file}`);
+        expect(visible).toContain('m.User=models/user.go');
+        expect(visible).toContain('Clean=util/clean.go'); // dot import
+        expect(visible).toContain('config.Load=config/config.go');
+        expect(visible).not.toContain('Clean=plugins/plugins.go'); // blank import
+        expect(graph.visibleSymbols('cmd/main.go').map(v => v.local)).toContain('model.User');
+    });
+
+    test('expands to the definitions of the referenced packages only', () => {
+        const result = new DependencyExpander(graph).expand('api/handler.go:Handle', 1);
+        expect(result.dependencies.map(e => `${e.symbol.file}:${e.symbol.qualifiedName}`).sort()).toEqual([
+            'config/config.go:Load',
+            'lib/text/title.go:Title',
+            'models/user.go:User',
+            'util/clean.go:Clean'
+        ]);
+    });
+});
+
+describe('TokenCalculator dependency expansion', () => {
+    test('exports only the focus and its dependencies', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-focus-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+
+        try {
+            const calculator = new TokenCalculator(root, { focus: 'Service.fetch', expandDeps: 1, dashboard: true });
+            const results = Object.keys(FILES).map(f => calculator.analyzeFile(path.join(root, f)));
+
+            const selected = calculator.applyDependencyExpansion(results);
+            expect(selected.map(f => f.relativePath)).toEqual(['src/service.ts', 'src/http.ts', 'src/util/parse.ts']);
+            expect(selected[0].selectedSymbols.map(s => s.name)).toEqual(['Service.fetch', 'Service.decode']);
+            expect(calculator.generateLLMContext(selected).focus.symbols).toHaveLength(4);
+
+            const contents = new GitIngestFormatter(root, calculator.stats, selected).generateFileContents();
+            expect(contents).toContain('// Dependencies of Service.fetch: showing 1 symbols');
+            expect(contents).toContain('export function parse');
+            expect(contents).not.toContain('export function unused');
+
+            const missing = new TokenCalculator(root, { focus: 'nope', dashboard: true });
+            expect(missing.applyDependencyExpansion(results)).toBeNull();
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
@@ -0,0 +1,142 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DependencySummary from '../lib/core/DependencySummary.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import ContextLinter from '../lib/core/ContextLinter.js';
+import XmlFormatter from '../lib/formatters/xml-formatter.js';
+
+const GO_MOD = [
+    'module github.com/acme/app',
+    '',
+    'go 1.22',
+    '',
+    'require github.com/spf13/cobra v1.8.0',
+    '',
+    'require (',
+    '\tgolang.org/x/net v0.20.0',
+    '\tgithub.com/inconshreveable/mousetrap v1.1.0 // indirect',
+    ')',
+    '',
+    'replace golang.org/x/net => ../net',
+    ''
+].join('\n');
+
+const GO_SUM = [
+    'github.com/spf13/cobra v1.8.0 h1:a',
+    'github.com/spf13/cobra v1.8.0/go.mod h1:b',
+    'golang.org/x/net v0.20.0 h1:c',
+    'github.com/inconshreveable/mousetrap v1.1.0 h1:d',
+    'github.com/spf13/pflag v1.0.5 h1:e',
+    ''
+].join('\n');
+
+// Reads like the calculator does: missing files throw
+const reader = files => file => {
+    if (!(file in files)) throw new Error(`ENOENT: ${file}`);
+    return files[file];
+};
+
+describe('DependencySummary', () => {
+    test('reads direct dependencies, locked versions and pins', () => {
+        const files = {
+            'web/package.json': JSON.stringify({
+                name: 'web',
+                dependencies: { express: '^4.18.2' },
+                devDependencies: { vitest: '^1.0.0' },
+                overrides: { lodash: '4.17.21' }
+            }),
+            'web/package-lock.json': JSON.stringify({
+                lockfileVersion: 3,
+                packages: {
+                    '': {},
+                    'node_modules/express': { version: '4.18.2' },
+                    'node_modules/vitest': { version: '1.6.0' },
+                    'node_modules/debug': { version: '4.3.4' },
+                    'node_modules/express/node_modules/debug': { version: '2.6.9' }
+                }
+            }),
+            'rs/Cargo.toml': '[package]\nname = "tool"\nversion = "0.3.0"\n\n[dependencies]\nserde = { version = "1.0", features = ["derive"] }\n\n[dependencies.tokio]\nversion = "1.35"\n\n[dev-dependencies]\ninsta = "1.34"\n',
+            'rs/Cargo.lock': '[[package]]\nname = "tool"\nversion = "0.3.0"\n\n[[package]]\nname = "serde"\nversion = "1.0.195"\nsource = "registry+https://github.com/rust-lang/crates.io-index"\n\n' +
+                '[[package]]\nname = "syn"\nversion = "2.0.48"\nsource = "registry+https://github.com/rust-lang/crates.io-index"\n',
+            'py/requirements.txt': 'click==8.1.7\n    # via -r requirements.in\nitsdangerous==2.1.2\n    # via flask\n',
+            'bad/package.json': '{ nope'
+        };
+        const [web, rust, python, bad] = new DependencySummary().summarize(Object.keys(files).filter(DependencySummary.isManifest), reader(files));
+
+        expect(web).toMatchObject({ ecosystem: 'npm', name: 'web', lockfile: 'web/package-lock.json', transitive: 1 });
+        expect(web.dependencies).toEqual([
+            { name: 'express', version: '^4.18.2', locked: '4.18.2', scope: 'runtime' },
+            { name: 'vitest', version: '^1.0.0', locked: '1.6.0', scope: 'dev' }
+        ]);
+        expect(web.pins.map(pin => `${pin.kind} ${pin.name} ${pin.version}`)).toEqual(['override lodash 4.17.21', 'duplicate debug 2.6.9, 4.3.4']);
+        expect(rust.dependencies.map(dependency => [dependency.name, dependency.version, dependency.locked, dependency.scope])).toEqual([
+            ['serde', '1.0', '1.0.195', 'runtime'],
+            ['tokio', '1.35', null, 'runtime'],
+            ['insta', '1.34', null, 'dev']
+        ]);
+        expect(rust.transitive).toBe(1);
+        expect(python.dependencies.map(dependency => dependency.name)).toEqual(['click']);
+        expect(python.pins).toEqual([{ name: 'itsdangerous', version: '==2.1.2 (via flask)', kind: 'transitive' }]);
+        expect(bad.error).toBeTruthy();
+        expect(DependencySummary.isManifest('requirements/dev.txt')).toBe(true);
+        expect(DependencySummary.isManifest('src/go.sum')).toBe(false);
+    });
+
+    test('formats a digest section', () => {
+        const summary = new DependencySummary({ limit: 1 });
+        const entries = summary.summarize(['go.mod'], reader({ 'go.mod': GO_MOD, 'go.sum': GO_SUM }));
+
+        expect(entries[0]).toMatchObject({ ecosystem: 'go', name: 'github.com/acme/app', runtime: 'go 1.22', transitive: 2 });
+        expect(summary.format(entries)).toBe([
+            '',
+            '='.repeat(48),
+            'DEPENDENCIES',
+            '='.repeat(48),
+            'go.mod — github.com/acme/app, go 1.22, locked in go.sum',
+            '  github.com/spf13/cobra v1.8.0',
+            '  … 1 more',
+            '  + 2 transitive',
+            '  Pins:',
+            '  replace golang.org/x/net => ../net',
+            ''
+        ].join('\n'));
+        expect(summary.describe(entries)).toBe('📦 Dependencies: 1 manifest, 2 direct, 1 pin');
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeEach(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-deps-'));
+            fs.mkdirSync(path.join(root, 'cmd'));
+            fs.writeFileSync(path.join(root, 'go.mod'), GO_MOD);
+            fs.writeFileSync(path.join(root, 'go.sum'), GO_SUM);
+            fs.writeFileSync(path.join(root, 'cmd', 'main.go'), 'package main\n\nfunc main() {}\n');
+        });
+
+        afterEach(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('appends the summary after the files', () => {
+            const calculator = new TokenCalculator(root, { dependencySummary: new DependencySummary() });
+            const analysis = calculator.analyzeFiles(calculator.scanDirectory(root));
+            const main = analysis.filter(fileInfo => fileInfo.relativePath.endsWith('main.go'));
+
+            const entries = calculator.dependencyEntries(main);
+            expect(entries.map(entry => entry.manifest)).toEqual(['go.mod']);
+            expect(calculator.dependencyEntries(main)).toBe(entries);
+
+            const digest = [...calculator.createGitIngestFormatter(main).generatePieces()].join('');
+            expect(digest.indexOf('\nDEPENDENCIES\n')).toBeGreaterThan(digest.indexOf('main.go'));
+            expect(digest).toContain('  golang.org/x/net v0.20.0\n');
+            expect(ContextLinter.parse(digest, 'digest.txt').files.map(file => file.path)).toEqual(['cmd/main.go']);
+
+            const context = calculator.createStructuredFormatter(main).build();
+            expect(context.dependencies).toEqual(entries);
+            expect(new XmlFormatter(context).render()).toContain('<dependency name="github.com/spf13/cobra" version="v1.8.0" scope="runtime"/>');
+        });
+    });
+});
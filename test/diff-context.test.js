@@ -0,0 +1,172 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { execSync } from 'child_process';
+import DiffAnalyzer from '../lib/integrations/git/DiffAnalyzer.js';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+const FILES = {
+    'src/util.ts': [
+        'export function parse(text: string): string {',
+        '    return normalize(text);',
+        '}',
+        '',
+        'function normalize(text: string) {',
+        '    return text.trim();',
+        '}',
+        ''
+    ].join('\n'),
+    'src/service.ts': [
+        "import { parse } from './util';",
+        '',
+        'export class Service {',
+        '    fetch(url: string) {',
+        '        return parse(url);',
+        '    }',
+        '',
+        '    other() {',
+        '        return 1;',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'README.md': '# Demo\n'
+};
+
+const DIFF = [
+    'diff --git a/src/service.ts b/src/service.ts',
+    'index 1111111..2222222 100644',
+    '--- a/src/service.ts',
+    '+++ b/src/service.ts',
+    '@@ -5 +5 @@ export class Service {',
+    '-        return parse(url);',
+    '+        return parse(url) + "!";',
+    '@@ -12,2 +11,0 @@ export class Service {',
+    '-',
+    '-// trailing',
+    'diff --git a/old.ts b/old.ts',
+    'deleted file mode 100644',
+    '--- a/old.ts',
+    '+++ /dev/null',
+    '@@ -1,3 +0,0 @@',
+    'diff --git a/src/a.ts b/src/b.ts',
+    'similarity index 90%',
+    'rename from src/a.ts',
+    'rename to src/b.ts',
+    '@@ -2,0 +3,2 @@'
+].join('\n');
+
+function writeFiles(root) {
+    for (const [file, content] of Object.entries(FILES)) {
+        fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+        fs.writeFileSync(path.join(root, file), content);
+    }
+}
+
+describe('DiffAnalyzer changed lines', () => {
+    test('parses hunks, deletions and renames', () => {
+        expect(DiffAnalyzer.parseChangedLines(DIFF)).toEqual([
+            { path: 'src/service.ts', oldPath: null, status: 'modified', ranges: [[5, 5], [12, 12]], added: 1, deleted: 3 },
+            { path: 'old.ts', oldPath: null, status: 'deleted', ranges: [[1, 1]], added: 0, deleted: 3 },
+            { path: 'src/b.ts', oldPath: 'src/a.ts', status: 'renamed', ranges: [[3, 4]], added: 2, deleted: 0 }
+        ]);
+    });
+
+    test('maps changes to the innermost touched symbols', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-diffmap-'));
+        writeFiles(root);
+
+        try {
+            const graph = new DependencyGraph({ root }).build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+            const touched = DiffAnalyzer.mapChangesToSymbols([
+                { path: 'src/service.ts', status: 'modified', ranges: [[5, 5]] },
+                { path: 'src/util.ts', status: 'modified', ranges: [[8, 8]] },
+                { path: 'README.md', status: 'modified', ranges: [[1, 1]] },
+                { path: 'gone.ts', status: 'deleted', ranges: [] }
+            ], graph);
+
+            expect(touched.symbols.map(symbol => symbol.qualifiedName)).toEqual(['Service.fetch']);
+            expect(touched.wholeFiles).toEqual(['src/util.ts', 'README.md']);
+            expect(touched.deleted).toEqual(['gone.ts']);
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
+
+describe('DiffAnalyzer on a repository', () => {
+    let root;
+    const git = command => execSync(`git ${command}`, { cwd: root, stdio: 'pipe' });
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-diffrepo-'));
+        writeFiles(root);
+        git('init -q');
+        git('-c user.name=t -c user.email=t@t commit -q --allow-empty -m root');
+        git('add -A');
+        git('-c user.name=t -c user.email=t@t commit -q -m init');
+
+        fs.writeFileSync(path.join(root, 'src/service.ts'), FILES['src/service.ts'].replace('return 1;', 'return 2;'));
+        fs.writeFileSync(path.join(root, 'src/extra.ts'), 'export const x = 1;\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('reports working tree changes and untracked files', () => {
+        const changes = new DiffAnalyzer(root).getChangedLines();
+        expect(changes).toMatchObject([
+            { path: 'src/extra.ts', status: 'added', ranges: null, added: 1 },
+            { path: 'src/service.ts', status: 'modified', ranges: [[9, 9]] }
+        ]);
+    });
+
+    test('compares commit ranges without untracked files', () => {
+        const changes = new DiffAnalyzer(root).getChangedLines('HEAD~1..HEAD');
+        expect(changes.map(change => [change.path, change.status])).toEqual([
+            ['README.md', 'added'],
+            ['src/service.ts', 'added'],
+            ['src/util.ts', 'added']
+        ]);
+        expect(() => new DiffAnalyzer(root).getChangedLines('main;rm')).toThrow('Invalid git reference');
+    });
+
+    test('finds files related through imports', () => {
+        expect(new DiffAnalyzer(root).findRelatedFiles(['src/service.ts'])).toEqual(['src/util.ts']);
+    });
+});
+
+describe('TokenCalculator diff scope', () => {
+    test('exports touched symbols plus their direct dependencies', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-diffscope-'));
+        writeFiles(root);
+
+        try {
+            const changes = [{ path: 'src/service.ts', oldPath: null, status: 'modified', ranges: [[5, 5]], added: 1, deleted: 1 }];
+            const calculator = new TokenCalculator(root, { diff: { base: 'main', changes }, dashboard: true });
+            const results = Object.keys(FILES).map(f => calculator.analyzeFile(path.join(root, f)));
+
+            const selected = calculator.selectExportResults(results);
+            expect(selected.map(f => [f.relativePath, f.priority])).toEqual([['src/service.ts', 100], ['src/util.ts', 99]]);
+
+            const context = calculator.generateLLMContext(selected);
+            expect(context.diff).toMatchObject({ base: 'main', symbols: [{ name: 'Service.fetch' }], dependencies: [{ name: 'parse' }] });
+
+            const contents = new GitIngestFormatter(root, calculator.stats, selected).generateFileContents();
+            expect(contents).toContain('// Changed since main: showing 1 symbols');
+            expect(contents).toContain('// method: Service.fetch (lines 4-6, changed)');
+            expect(contents).toContain('// function: parse (lines 1-3, dependency)');
+            expect(contents).not.toContain('other()');
+            expect(contents).not.toContain('function normalize');
+
+            const report = DiffAnalyzer.formatDiffScope(calculator.diffScope);
+            expect(report).toContain('modified  src/service.ts (+1/-1) → Service.fetch');
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
@@ -0,0 +1,85 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DirectoryConfig from '../lib/core/DirectoryConfig.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    '.context.yaml': 'ignore:\n  - "*.pb.js"\npriority:\n  "legacy/**": 5\n  "**/main.js": 10\ntiers: [full, names]\n',
+    'services/billing/.context.yaml': 'ignore:\n  - fixtures/\n  - "!money.pb.js"\npriority:\n  "main.js": 90\n',
+    'services/search/.context.yaml': 'inherit: false\ntiers: full\n',
+    'services/billing/main.js': 'export function bill() {}\n',
+    'services/billing/money.pb.js': 'export const Money = {};\n',
+    'services/billing/tax.pb.js': 'export const Tax = {};\n',
+    'services/billing/fixtures/invoice.js': 'export default {};\n',
+    'services/search/main.js': 'export function search() {}\n',
+    'services/search/query.pb.js': 'export const Query = {};\n',
+    'legacy/main.js': 'module.exports = {};\n'
+};
+
+describe('DirectoryConfig', () => {
+    let root;
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-dirconfig-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.join(root, path.dirname(file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('nearest files override their parents', () => {
+        const config = new DirectoryConfig({ root });
+
+        expect(config.priorityOf('services/billing/main.js')).toBe(90);
+        expect(config.priorityOf('services/billing/tax.pb.js')).toBeNull();
+        expect(config.priorityOf('legacy/main.js')).toBe(5);
+        expect(config.priorityOf('services/search/main.js')).toBeNull();
+
+        expect(config.tiersOf('services/billing/main.js')).toEqual(['full', 'names']);
+        expect(config.tiersOf('services/search/main.js')).toEqual(['full']);
+        expect(config.ignorePatterns('services/search/query.pb.js')).toEqual([]);
+        expect(config.directories()).toEqual(['', 'services/billing', 'services/search']);
+    });
+
+    test('scans apply nested ignore lists and priorities', () => {
+        const calculator = new TokenCalculator(root);
+        const files = calculator.scanDirectory(root)
+            .map(file => path.relative(root, file).split(path.sep).join('/'))
+            .filter(file => !file.endsWith('.context.yaml'))
+            .sort();
+
+        expect(files).toEqual(['legacy/main.js', 'services/billing/main.js', 'services/billing/money.pb.js',
+            'services/search/main.js', 'services/search/query.pb.js']);
+        expect(calculator.analyzeFile(path.join(root, 'services/billing/main.js')).priority).toBe(90);
+        expect(calculator.directoryTiers({ path: path.join(root, 'services/search/main.js') })).toEqual({ tiers: ['full'] });
+    });
+
+    test('budget items keep their own tiers', () => {
+        const budget = new TokenBudget({ maxTokens: 10, tiers: 'full,names' });
+        const plan = budget.plan([
+            { id: 'a.js', tokens: 40, priority: 2 },
+            { id: 'b.js', tokens: 40, priority: 1, tiers: ['full'] }
+        ], { summarize: () => 'names' });
+
+        expect(plan.summarized.map(item => item.id)).toEqual(['a.js']);
+        expect(plan.dropped.map(item => item.id)).toEqual(['b.js']);
+    });
+
+    test('rejects invalid files with their path', () => {
+        expect(() => DirectoryConfig.parse('prio: 3\n', 'services/billing'))
+            .toThrow('services/billing/.context.yaml: unknown keys: prio');
+        expect(() => DirectoryConfig.parse('tiers: full,all\n')).toThrow('.context.yaml: Unknown tier: all');
+        expect(() => DirectoryConfig.parse('priority:\n  src: high\n')).toThrow('priority must map globs to numbers');
+        expect(() => DirectoryConfig.parse('inherit: no\n')).toThrow('inherit must be true or false');
+
+        fs.writeFileSync(path.join(root, 'legacy', '.context.yaml'), 'ignore: [1]\n');
+        expect(() => new TokenCalculator(root).scanDirectory(root)).toThrow('legacy/.context.yaml: ignore must be a list');
+    });
+});
@@ -0,0 +1,118 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DuplicateDetector from '../lib/core/DuplicateDetector.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+const UTIL = [
+    '// Utilities',
+    'export function debounce(fn, wait) {',
+    '    let timer = null;',
+    '    return function (...args) {',
+    '        clearTimeout(timer);',
+    '        timer = setTimeout(() => fn.apply(this, args), wait);',
+    '    };',
+    '}',
+    '',
+    'export function chunk(items, size) {',
+    '    const result = [];',
+    '    for (let i = 0; i < items.length; i += size) {',
+    '        result.push(items.slice(i, i + size));',
+    '    }',
+    '    return result;',
+    '}',
+    ''
+].join('\n');
+
+// Same code: other comments, indentation and one renamed parameter
+const VENDORED = UTIL
+    .replace('// Utilities', '/* utils v1.2.0 (MIT) */')
+    .replace(/wait/g, 'delay')
+    .replace(/ {4}/g, '  ');
+
+const OTHER = [
+    'export class Queue {',
+    '    constructor() { this.items = []; }',
+    '    push(item) { this.items.push(item); return this.items.length; }',
+    '    pop() { return this.items.shift(); }',
+    '    get size() { return this.items.length; }',
+    '}',
+    ''
+].join('\n');
+
+describe('DuplicateDetector', () => {
+    const detector = new DuplicateDetector();
+
+    test('groups near-duplicates and keeps the hand-written copy', () => {
+        const groups = detector.findDuplicates([
+            { path: 'vendor/utils/index.js', content: VENDORED },
+            { path: 'src/util.js', content: UTIL },
+            { path: 'src/__mocks__/util.js', content: UTIL },
+            { path: 'src/queue.js', content: OTHER }
+        ]);
+
+        expect(groups).toHaveLength(1);
+        expect(groups[0].representative).toBe('src/util.js');
+        expect(groups[0].duplicates.map(duplicate => duplicate.path).sort()).toEqual(['src/__mocks__/util.js', 'vendor/utils/index.js']);
+        expect(groups[0].duplicates.find(duplicate => duplicate.path === 'src/__mocks__/util.js').similarity).toBe(1);
+        expect(groups[0].duplicates.find(duplicate => duplicate.path === 'vendor/utils/index.js').similarity).toBeGreaterThanOrEqual(0.8);
+    });
+
+    test('ignores small files and respects the threshold', () => {
+        expect(detector.findDuplicates([
+            { path: 'a/index.js', content: 'export * from "./a";\n' },
+            { path: 'b/index.js', content: 'export * from "./a";\n' }
+        ])).toEqual([]);
+        expect(new DuplicateDetector({ threshold: 1 }).findDuplicates([
+            { path: 'src/util.js', content: UTIL },
+            { path: 'vendor/util.js', content: VENDORED }
+        ])).toEqual([]);
+        expect(() => new DuplicateDetector({ threshold: 1.5 })).toThrow('Invalid duplicate threshold');
+    });
+
+    test('prefers priority and then the shorter path among hand-written copies', () => {
+        const files = [
+            { path: 'packages/web/src/util.js', content: UTIL, priority: 90 },
+            { path: 'lib/util.js', content: UTIL, priority: 50 }
+        ];
+        expect(detector.findDuplicates(files)[0].representative).toBe('packages/web/src/util.js');
+        expect(detector.findDuplicates(files.map(({ path: file, content }) => ({ path: file, content })))[0].representative).toBe('lib/util.js');
+        expect(DuplicateDetector.isSecondary('api/user.pb.go')).toBe(true);
+        expect(DuplicateDetector.isSecondary('src/build.js')).toBe(false);
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-dedupe-'));
+            for (const [file, content] of [['src/util.js', UTIL], ['vendor/util.js', VENDORED], ['src/queue.js', OTHER]]) {
+                fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+                fs.writeFileSync(path.join(root, file), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('drops duplicates before export and notes them on the representative', () => {
+            const calculator = new TokenCalculator(root, { duplicateDetector: new DuplicateDetector() });
+            const files = calculator.analyzeFiles(calculator.scanProject());
+            const vendored = files.find(fileInfo => fileInfo.relativePath === 'vendor/util.js');
+
+            const exported = calculator.selectExportResults(files);
+            expect(exported.map(fileInfo => fileInfo.relativePath).sort()).toEqual(['src/queue.js', 'src/util.js']);
+            expect(calculator.deduplication.savedTokens).toBe(vendored.tokens);
+
+            const util = exported.find(fileInfo => fileInfo.relativePath === 'src/util.js');
+            expect(util.duplicates.map(duplicate => duplicate.path)).toEqual(['vendor/util.js']);
+
+            const digest = new GitIngestFormatter(root, calculator.stats, exported).generateDigest();
+            expect(digest).toMatch(/FILE: src\/util\.js\nNear-duplicates omitted: vendor\/util\.js \(\d+%\)\n=+\n/);
+            expect(digest).not.toContain('FILE: vendor/util.js');
+        });
+    });
+});
@@ -0,0 +1,103 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { PassThrough } from 'stream';
+import EditorServer, { RPC_ERRORS, encodeMessage } from '../lib/api/editor/EditorServer.js';
+
+const FILES = {
+    'src/service.js': [
+        "import { parse } from './util.js';",
+        '',
+        'export class Service {',
+        '    fetch(input) {',
+        '        return parse(input);',
+        '    }',
+        '',
+        '    close() {',
+        '        return null;',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'src/util.js': 'export function parse(input) {\n    return JSON.parse(input);\n}\n'
+};
+
+describe('EditorServer', () => {
+    let root;
+    let id = 0;
+    const request = (server, method, params = {}) =>
+        server.handle({ jsonrpc: '2.0', id: ++id, method, params });
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-editor-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('builds context for the innermost symbols of a selection, plus pins', async () => {
+        const server = new EditorServer(root);
+        const outline = await request(server, 'GetOutline', { file: path.join(root, 'src/service.js') });
+        expect(outline.result.symbols.map(symbol => symbol.name)).toEqual(['Service', 'Service.fetch', 'Service.close']);
+
+        expect((await request(server, 'PinSymbol', { file: 'src/util.js', name: 'parse' })).result).toEqual({ pins: ['src/util.js:parse'] });
+        expect((await request(server, 'PinSymbol', { symbol: 'missing' })).error).toEqual({
+            code: RPC_ERRORS.invalidParams, message: 'Symbol not found: missing'
+        });
+
+        const { result } = await request(server, 'BuildContextForSelection', { file: 'src/service.js', startLine: 5, format: 'gitingest' });
+        expect(result.symbols).toEqual(['src/service.js:Service.fetch']);
+        expect(result.files.map(file => file.path).sort()).toEqual(['src/service.js', 'src/util.js']);
+        expect(result.context).toContain('return parse(input);');
+        expect(result.context).not.toContain('close()');
+
+        // Imports only: the whole file
+        const whole = await request(server, 'BuildContextForSelection', { file: 'src/service.js', startLine: 1, endLine: 2 });
+        expect(whole.result.symbols).toEqual(['src/service.js']);
+
+        expect((await request(server, 'UnpinSymbol', { symbol: 'src/util.js:parse' })).result).toEqual({ pins: [] });
+        expect((await request(server, 'ListPins')).result).toEqual({ pins: [] });
+    });
+
+    test('answers JSON-RPC errors and cancels requests', async () => {
+        const server = new EditorServer(root);
+        expect((await request(server, 'Format')).error.code).toBe(RPC_ERRORS.methodNotFound);
+        expect((await request(server, 'GetOutline', { file: '../outside.js' })).error).toEqual({
+            code: RPC_ERRORS.invalidParams, message: 'File outside the project: ../outside.js'
+        });
+        expect((await server.handle({ id: 1, method: 'ListPins' })).error.code).toBe(RPC_ERRORS.invalidRequest);
+        expect(await server.handle({ jsonrpc: '2.0', method: 'ListPins' })).toBeNull();
+
+        const pending = server.handle({ jsonrpc: '2.0', id: 'slow', method: 'BuildContextForSelection', params: { file: 'src/util.js' } });
+        await server.handle({ jsonrpc: '2.0', method: '$/cancelRequest', params: { id: 'slow' } });
+        expect((await pending).error).toEqual({ code: RPC_ERRORS.requestCancelled, message: 'Request cancelled' });
+    });
+
+    test('reads and writes Content-Length framed messages', async () => {
+        const server = new EditorServer(root);
+        const input = new PassThrough();
+        const output = new PassThrough();
+        const done = server.connect(input, output);
+
+        const framed = encodeMessage({ jsonrpc: '2.0', id: 7, method: 'GetOutline', params: { file: 'src/util.js' } });
+        input.write(framed.slice(0, 10));
+        input.write(framed.slice(10) + 'Content-Length: 3\r\n\r\n{x}');
+
+        const bodies = [];
+        await new Promise(resolve => output.on('data', chunk => {
+            bodies.push(...chunk.toString().split(/Content-Length: \d+\r\n\r\n/).filter(Boolean).map(body => JSON.parse(body)));
+            if (bodies.length === 2) resolve();
+        }));
+        input.end();
+        await done;
+
+        expect(bodies.find(body => body.id === 7).result.symbols[0].name).toBe('parse');
+        expect(bodies.find(body => body.id === null).error.code).toBe(RPC_ERRORS.parseError);
+    });
+});
@@ -0,0 +1,63 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import EntryPointDetector from '../lib/graph/EntryPointDetector.js';
+import PriorityScorer from '../lib/core/PriorityScorer.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    'cmd/api/main.go': 'package main\n\nimport "net/http"\n\nfunc main() {\n\thttp.HandleFunc("/health", health)\n}\n',
+    'src/routes.js': "import { handle } from './core/handler.js';\n\nrouter.get('/users', handle);\n",
+    'src/core/handler.js': 'export function handle(request) {\n    return request;\n}\n',
+    'src/core/unused.js': 'export const unused = 1;\n',
+    'jobs/nightly.py': 'import schedule\n\n# schedule.every() in a comment\nschedule.every().day.do(print)\n',
+    'tools/cli.py': "import argparse\n\nif __name__ == '__main__':\n    argparse.ArgumentParser()\n",
+    'bin/tool.js': '#!/usr/bin/env node\nconsole.log(1);\n',
+    'test/routes.test.js': "app.get('/users', () => {});\n",
+    'package.json': '{ "main": "src/routes.js", "bin": { "tool": "./bin/tool.js" } }\n'
+};
+
+describe('EntryPointDetector', () => {
+    test('detects main files, routes, CLI commands and cron jobs', () => {
+        const entryPoints = new EntryPointDetector().detect(Object.entries(FILES).map(([file, content]) => ({ path: file, content })));
+
+        expect(entryPoints).toEqual([
+            { file: 'bin/tool.js', kinds: ['main'], line: 1 },
+            { file: 'cmd/api/main.go', kinds: ['main', 'http'], line: 5 },
+            { file: 'jobs/nightly.py', kinds: ['cron'], line: 4 },
+            { file: 'src/routes.js', kinds: ['main', 'http'], line: 1 },
+            { file: 'tools/cli.py', kinds: ['main', 'cli'], line: 3 }
+        ]);
+        expect(EntryPointDetector.format(entryPoints)).toContain('   main, http       cmd/api/main.go:5');
+    });
+
+    describe('in priority scoring', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-entry-'));
+            for (const [file, content] of Object.entries(FILES)) {
+                fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+                fs.writeFileSync(path.join(root, file), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('ranks entry points and their direct dependencies up', () => {
+            const scorer = new PriorityScorer({ weights: { path: 0, churn: 0, fanIn: 0, depth: 0, size: 0 } });
+            const calculator = new TokenCalculator(root, { dashboard: true, priorityScorer: scorer });
+            const results = ['src/routes.js', 'src/core/handler.js', 'src/core/unused.js']
+                .map(file => calculator.analyzeFile(path.join(root, file)));
+            calculator.selectExportResults(results);
+
+            const entry = file => calculator.priorityScores.get(path.join(...file.split('/'))).signals.entry;
+            expect(entry('src/routes.js')).toMatchObject({ raw: { kinds: ['http'] }, points: 10 });
+            expect(entry('src/core/handler.js')).toMatchObject({ raw: { importedBy: 'src/routes.js' }, points: 5 });
+            expect(entry('src/core/unused.js').points).toBe(0);
+        });
+    });
+});
@@ -0,0 +1,92 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ErrorReport, { FileError } from '../lib/core/ErrorReport.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import StructuredFormatter from '../lib/formatters/structured-formatter.js';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+
+describe('ErrorReport', () => {
+    let root;
+
+    beforeEach(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-errors-'));
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.writeFileSync(path.join(root, 'src', 'a.js'), 'export const a = 1;\n');
+        fs.writeFileSync(path.join(root, 'src', 'b.js'), 'export function b() { return 2; }\n');
+        fs.symlinkSync('missing.js', path.join(root, 'src', 'broken.js'));
+    });
+
+    afterEach(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('collects failures per file and keeps going', () => {
+        const calculator = new TokenCalculator(root);
+        const originalRead = calculator.readOriginal.bind(calculator);
+        calculator.readOriginal = filePath => {
+            if (filePath.endsWith('b.js')) throw Object.assign(new Error('EIO: i/o error'), { code: 'EIO' });
+            return originalRead(filePath);
+        };
+
+        const files = calculator.scanDirectory(root);
+        expect(files.map(file => path.basename(file)).sort()).toEqual(['a.js', 'b.js']);
+        const results = calculator.analyzeFiles(files);
+        expect(results.filter(fileInfo => !fileInfo.error).map(fileInfo => path.basename(fileInfo.path))).toEqual(['a.js']);
+
+        calculator.errors.add('analyze', 'src/b.js', 'reported again');
+        const report = calculator.errors.toJSON();
+        expect(report).toMatchObject({ schema: 'ctxman-errors/1', failed: 2, stages: { scan: 1, analyze: 1 } });
+        expect(report.errors[0]).toMatchObject({ file: 'src/broken.js', stage: 'scan', code: 'ENOENT' });
+        expect(report.errors[1]).toEqual({ file: 'src/b.js', stage: 'analyze', message: 'EIO: i/o error', code: 'EIO' });
+        expect(calculator.errors.format(1)).toBe([
+            '⚠️  2 files failed and were left out (--strict stops instead):',
+            `   src/broken.js (scan): ${calculator.errors.entries[0].message}`,
+            '   … 1 more'
+        ].join('\n'));
+
+        calculator.reportErrors();
+        const saved = JSON.parse(fs.readFileSync(path.join(root, '.ctxman', 'errors.json'), 'utf8'));
+        expect(saved.failed).toBe(2);
+    });
+
+    test('strict runs stop at the first failure', () => {
+        const calculator = new TokenCalculator(root, { strict: true });
+        expect(() => calculator.scanDirectory(root)).toThrow(FileError);
+        expect(() => new ErrorReport({ strict: true }).add('export', 'src/a.js', new Error('boom')))
+            .toThrow('src/a.js: export failed: boom (--strict stops at the first failure)');
+    });
+
+    test('symbols and exports skip the files that fail', () => {
+        const failed = [];
+        const extractor = new DependencyGraph().extractor;
+        const getPlugin = extractor.getPlugin.bind(extractor);
+        extractor.getPlugin = (file, content) => {
+            if (content?.includes('function b')) throw new Error('parser crashed');
+            return getPlugin(file, content);
+        };
+        const graph = new DependencyGraph({ root, extractor, onError: (file, error) => failed.push([file, error.message]) }).build([
+            { relativePath: 'src/a.js', content: 'export const a = 1;\n' },
+            { relativePath: 'src/b.js', content: 'export function b() {}\n' }
+        ]);
+        expect([...graph.files.keys()]).toEqual(['src/a.js']);
+        expect(failed).toEqual([['src/b.js', 'parser crashed']]);
+        expect(() => new DependencyGraph({ root, extractor }).build([{ relativePath: 'src/b.js', content: 'export function b() {}\n' }]))
+            .toThrow('parser crashed');
+
+        const analysis = ['a.js', 'b.js'].map(file => ({
+            path: path.join(root, 'src', file), relativePath: path.join('src', file), tokens: 5, lines: 1, sizeBytes: 20
+        }));
+        const formatter = new StructuredFormatter(root, {}, analysis, {
+            readFile: filePath => {
+                if (filePath.endsWith('a.js')) throw new Error('EACCES: permission denied');
+                return fs.readFileSync(filePath, 'utf8');
+            },
+            onError: (fileInfo, error) => failed.push([fileInfo.relativePath, error.message])
+        });
+        expect(formatter.build().files.map(file => file.path)).toEqual(['src/b.js']);
+        expect(JSON.parse([...formatter.encodePieces('json')].join('')).files.map(file => file.path)).toEqual(['src/b.js']);
+        expect(failed.slice(1)).toEqual([[path.join('src', 'a.js'), 'EACCES: permission denied'], [path.join('src', 'a.js'), 'EACCES: permission denied']]);
+    });
+});
@@ -0,0 +1,133 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { ExtractorPlugin, ExecExtractorPlugin } from '../lib/plugins/ExtractorPlugin.js';
+import { loadExtractors } from '../lib/plugins/ExtractorRegistry.js';
+import { SymbolExtractor } from '../lib/symbols/SymbolExtractor.js';
+import FileUtils from '../lib/utils/file-utils.js';
+
+// Reads the request from stdin and lists `message Name {` blocks
+const PROTO_EXTRACTOR = [
+    "let input = '';",
+    "process.stdin.on('data', chunk => { input += chunk; }).on('end', () => {",
+    '    const { file, content } = JSON.parse(input);',
+    "    if (file.includes('broken')) process.exit(3);",
+    "    const lines = content.split('\\n');",
+    '    const symbols = [];',
+    '    lines.forEach((line, i) => {',
+    '        const match = line.match(/^message (\\w+) \\{/);',
+    "        if (match) symbols.push({ name: match[1], kind: 'struct', startLine: i + 1, endLine: lines.indexOf('}', i) + 1, signature: line.slice(0, -2) });",
+    '    });',
+    "    const imports = [...content.matchAll(/^import \"(.+)\";/gm)].map(m => ({ source: m[1], line: 1 }));",
+    '    process.stdout.write(JSON.stringify({ symbols, imports }));',
+    '});',
+    ''
+].join('\n');
+
+const SQL_EXTRACTOR = [
+    'export default {',
+    '    extract(content) {',
+    "        return content.split('\\n').flatMap((line, i) => {",
+    '            const match = line.match(/^CREATE TABLE (\\w+)/);',
+    "            return match ? [{ name: match[1], kind: 'table', startLine: i + 1, endLine: i + 3 }] : [];",
+    '        });',
+    '    }',
+    '};',
+    ''
+].join('\n');
+
+describe('ExtractorPlugin', () => {
+    class ListPlugin extends ExtractorPlugin {
+        extract(content) {
+            return [
+                { name: 'users', kind: 'struct', startLine: 2, endLine: 4, signature: 'CREATE TABLE users' },
+                { name: 'orders', kind: 'table', startLine: 6 },
+                { kind: 'function' }
+            ];
+        }
+    }
+
+    test('normalizes extracted symbols into the unified model', () => {
+        const plugin = new ListPlugin({ name: 'sql', extensions: ['sql'] });
+        const symbols = plugin.extractSymbols('...', 'db/001.sql');
+
+        expect(plugin.extensions).toEqual(['.sql']);
+        expect(symbols).toMatchObject([
+            { name: 'users', kind: 'struct', language: 'sql', file: 'db/001.sql', startLine: 2, endLine: 4, exported: true },
+            { name: 'orders', kind: 'type', startLine: 6, endLine: 6 }
+        ]);
+    });
+
+    test('routes files to registered plugins ahead of built-ins', () => {
+        const extractor = new SymbolExtractor({ backend: 'heuristic' })
+            .register(new ListPlugin({ name: 'sql', extensions: ['.sql'] }));
+
+        expect(extractor.supports('db/001.sql')).toBe(true);
+        expect(extractor.getBackend('db/001.sql')).toBe('plugin:sql');
+        expect(extractor.extract('...', 'db/001.sql').map(symbol => symbol.name)).toEqual(['users', 'orders']);
+    });
+});
+
+describe('ExtractorRegistry', () => {
+    let root;
+
+    const writeConfig = extractors => {
+        fs.writeFileSync(path.join(root, '.ctxman', 'extractors.json'), JSON.stringify({ extractors }));
+    };
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-extractors-'));
+        fs.mkdirSync(path.join(root, '.ctxman'));
+        fs.mkdirSync(path.join(root, 'tools'));
+        fs.writeFileSync(path.join(root, 'tools', 'proto.mjs'), PROTO_EXTRACTOR);
+        fs.writeFileSync(path.join(root, 'tools', 'sql.mjs'), SQL_EXTRACTOR);
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('rejects invalid entries', async () => {
+        writeConfig([{ name: 'proto', extensions: ['.proto'] }]);
+        await expect(loadExtractors(root)).rejects.toThrow('extractor "proto" needs either "module" or "command"');
+
+        writeConfig([{ name: 'proto', command: 'x' }]);
+        await expect(loadExtractors(root)).rejects.toThrow('extractor "proto" needs "extensions"');
+
+        writeConfig([{ name: 'proto', command: 'x', extensions: ['.proto'], args: [] }]);
+        await expect(loadExtractors(root)).rejects.toThrow('has unknown keys: args');
+    });
+
+    test('registers command and module extractors with every SymbolExtractor', async () => {
+        writeConfig([
+            { name: 'protobuf', extensions: ['.proto'], command: [process.execPath, 'tools/proto.mjs'] },
+            { name: 'sql', extensions: ['.sql'], module: './tools/sql.mjs' }
+        ]);
+        const plugins = await loadExtractors(root);
+        expect(plugins.map(plugin => [plugin.name, plugin.constructor.name])).toEqual([
+            ['protobuf', 'ExecExtractorPlugin'],
+            ['sql', 'ExtractorPlugin']
+        ]);
+        expect(await loadExtractors(root)).toEqual([]);
+        expect(FileUtils.isText('api/user.proto')).toBe(true);
+
+        const extractor = new SymbolExtractor({ backend: 'heuristic' });
+        const proto = 'syntax = "proto3";\nimport "common.proto";\n\nmessage User {\n  string name = 1;\n}\n';
+        expect(extractor.extract(proto, 'api/user.proto')).toMatchObject([
+            { name: 'User', kind: 'struct', language: 'protobuf', startLine: 4, endLine: 6, signature: 'message User' }
+        ]);
+        expect(extractor.getPlugin('api/user.proto').extractImports(proto, 'api/user.proto')).toEqual([
+            { source: 'common.proto', names: null, line: 1 }
+        ]);
+        expect(extractor.extract('CREATE TABLE users (\n  id int\n);\n', 'db/001.sql')).toMatchObject([
+            { name: 'users', kind: 'type', language: 'sql', endLine: 3 }
+        ]);
+    });
+
+    test('skips files a command fails on', () => {
+        const plugin = new ExecExtractorPlugin({ name: 'protobuf', extensions: ['.proto'], command: [process.execPath, 'tools/proto.mjs'], cwd: root });
+        expect(plugin.extractSymbols('message A {\n}\n', 'api/broken.proto')).toEqual([]);
+        expect(() => plugin.extract('message A {\n}\n', 'api/broken.proto')).toThrow('exited with 3');
+    });
+});
@@ -0,0 +1,56 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import FileList from '../lib/core/FileList.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('FileList', () => {
+    test('parses newline and NUL separated lists', () => {
+        expect(FileList.parse('src/a.js\r\n\n  src/b.js \nsrc/a.js\n')).toEqual(['src/a.js', 'src/b.js']);
+        expect(FileList.parse('src/a b.js\0src/c.js\0')).toEqual(['src/a b.js', 'src/c.js']);
+        expect(FileList.parse('')).toEqual([]);
+    });
+
+    test('unquotes paths git quotes', () => {
+        expect(FileList.parse('"docs/caf\\303\\251.md"\n"src/tab\\there.js"\n')).toEqual(['docs/café.md', 'src/tab\there.js']);
+        // NUL separated output is never quoted
+        expect(FileList.parse('"quoted".md\0')).toEqual(['"quoted".md']);
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-files-'));
+            const files = {
+                'src/a.js': 'export const a = 1;\n',
+                'src/b.js': 'export const b = 2;\n',
+                'docs/guide.md': '# Guide\n',
+                'docs/draft.md': '# Draft\n',
+                'build/out.js': 'ignored();\n',
+                '.gitignore': 'build/\ndocs/draft.md\n'
+            };
+            for (const [file, content] of Object.entries(files)) {
+                fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+                fs.writeFileSync(path.join(root, file), content);
+            }
+            fs.writeFileSync(path.join(root, 'logo.png'), Buffer.from([0x89, 0x50, 0x4e, 0x47, 0, 0, 0, 0]));
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('scans only listed files and the contents of listed directories', () => {
+            const calculator = new TokenCalculator(root, {
+                fileList: ['src/a.js', 'build/out.js', 'docs', 'src/deleted.js', 'logo.png', '../outside.js']
+            });
+            const files = calculator.scanProject().map(file => path.relative(root, file)).sort();
+
+            // Listed files skip ignore rules; listed directories keep them
+            expect(files).toEqual(['build/out.js', 'docs/guide.md', 'src/a.js']);
+            expect(calculator.skippedListEntries).toEqual(['src/deleted.js', 'logo.png', '../outside.js']);
+        });
+    });
+});
@@ -0,0 +1,65 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import FileReader, { READ_MODES, countLines } from '../lib/core/FileReader.js';
+
+const FILES = {
+    'small.js': 'export const café = "naïve";\n',
+    'wide.txt': Buffer.concat([Buffer.from([0xff, 0xfe]), Buffer.from('UTF-16 text\n', 'utf16le')]),
+    'empty.txt': '',
+    'large.js': 'x'.repeat(3000) + '\n'
+};
+
+describe('FileReader', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-reader-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('decodes the same text in every mode', () => {
+        const modes = READ_MODES.filter(mode => mode !== 'mmap' || FileReader.canMap());
+        for (const mode of modes) {
+            const reader = new FileReader({ mode, poolLimit: 1024, mmapThreshold: 1024 });
+            expect(Object.keys(FILES).map(file => reader.readText(path.join(root, file)))).toEqual([
+                'export const café = "naïve";\n', 'UTF-16 text\n', '', 'x'.repeat(3000) + '\n'
+            ]);
+            expect(reader.stats.files).toBe(4);
+        }
+    });
+
+    test('reuses one buffer for files up to the pool limit', () => {
+        const pooled = new FileReader({ mode: 'pooled' });
+        const buffered = new FileReader({ mode: 'buffer' });
+        for (let i = 0; i < 5; i++) {
+            for (const reader of [pooled, buffered]) reader.readText(path.join(root, 'large.js'));
+        }
+
+        expect(buffered.stats.allocated).toBe(5 * 3001);
+        expect(pooled.stats.allocated).toBe(3001);
+        expect(pooled.stats.pooled).toBe(5);
+        expect(pooled.describe()).toBe('5 files, 14.7 KB, 2.9 KB buffers');
+
+        // Larger files get a buffer of their own
+        const limited = new FileReader({ mode: 'pooled', poolLimit: 1024 });
+        limited.readText(path.join(root, 'large.js'));
+        limited.readText(path.join(root, 'small.js'));
+        expect(limited.stats).toMatchObject({ pooled: 1, allocated: 3001 + Buffer.byteLength(FILES['small.js']) });
+    });
+
+    test('rejects unknown modes and mmap without mmap-io', () => {
+        expect(() => new FileReader({ mode: 'fast' })).toThrow('Unknown read mode: fast (auto, mmap, pooled, buffer)');
+        if (!FileReader.canMap()) {
+            expect(() => new FileReader({ mode: 'mmap' })).toThrow('npm install mmap-io');
+        }
+        expect([countLines(''), countLines('a\nb'), countLines('a\nb\n')]).toEqual([1, 2, 3]);
+    });
+});
@@ -0,0 +1,112 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import FileSplitter from '../lib/core/FileSplitter.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+// One token per non-empty line keeps the part boundaries predictable
+const countTokens = text => text.split('\n').filter(line => line.trim()).length;
+
+const method = (name, lines) => [
+    `    ${name}() {`,
+    ...Array.from({ length: lines }, (_, i) => `        this.${name}${i} = ${i};`),
+    '    }'
+];
+
+const SOURCE = [
+    "import fs from 'fs';",
+    '',
+    'export class Big {',
+    ...method('first', 8),
+    ...method('second', 8),
+    ...method('third', 8),
+    '}',
+    '',
+    'export function huge() {',
+    ...Array.from({ length: 30 }, (_, i) => `    const v${i} = ${i};`),
+    '}',
+    ''
+].join('\n');
+
+describe('FileSplitter', () => {
+    const split = options => new FileSplitter({ countTokens, ...options }).split(SOURCE, 'big.js');
+
+    test('splits classes between their members and repeats the declaration', () => {
+        const parts = split({ maxTokens: 12 });
+
+        expect(parts.map(part => [part.startLine, part.endLine])).toEqual([
+            [1, 13], [14, 23], [24, 34], [35, 47], [48, 59], [60, 67]
+        ]);
+        expect(parts.every(part => part.tokens <= 12)).toBe(true);
+        expect(parts[0]).toMatchObject({ index: 1, total: 6, symbols: ['Big', 'Big.first'], context: null });
+        expect(parts[1]).toMatchObject({ symbols: ['Big.second'], context: { name: 'Big', startLine: 3, endLine: 3 } });
+    });
+
+    test('windows oversized symbols and carries the overlap', () => {
+        const parts = split({ maxTokens: 14, overlap: 2 });
+        const windows = parts.filter(part => part.context?.name === 'huge');
+
+        expect(windows.length).toBeGreaterThan(1);
+        expect(windows[0].context).toEqual({ name: 'huge', startLine: 36, endLine: 36 });
+        for (const part of parts.slice(1)) {
+            expect(part.overlap.endLine).toBe(part.startLine - 1);
+            expect(countTokens(SOURCE.split('\n').slice(part.overlap.startLine - 1, part.overlap.endLine).join('\n'))).toBeLessThanOrEqual(2);
+        }
+    });
+
+    test('covers the whole file without gaps', () => {
+        const parts = split({ maxTokens: 10 });
+        expect(parts[0].startLine).toBe(1);
+        for (let i = 1; i < parts.length; i++) {
+            expect(parts[i].startLine).toBe(parts[i - 1].endLine + 1);
+        }
+        expect(parts[parts.length - 1].endLine).toBe(SOURCE.split('\n').length - 1);
+    });
+});
+
+describe('GitIngestFormatter oversized files', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-split-'));
+        fs.writeFileSync(path.join(root, 'big.js'), SOURCE);
+        fs.writeFileSync(path.join(root, 'small.js'), 'export const small = 1;\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const createFormatter = (chunking = {}) => new GitIngestFormatter(root, { totalFiles: 2, totalTokens: 67 }, [
+        { path: path.join(root, 'big.js'), relativePath: 'big.js', tokens: 66 },
+        { path: path.join(root, 'small.js'), relativePath: 'small.js', tokens: 1 }
+    ], { chunking: { enabled: true, strategy: 'size', maxTokensPerChunk: 20, overlap: 4, ...chunking }, countTokens });
+
+    test('renders parts with their line ranges, context and metadata', () => {
+        const chunks = createFormatter().generateDigest();
+        const parts = chunks.filter(chunk => chunk.content.includes('FILE: big.js (part'));
+
+        expect(parts.length).toBeGreaterThan(1);
+        expect(chunks.every(chunk => chunk.tokens <= 20)).toBe(true);
+        expect(parts[1].content).toContain('// Enclosing declaration: Big (lines 3-3)');
+        expect(parts[1].content).toMatch(/\/\/ Overlap with part 1 \(lines \d+-\d+\)/);
+        expect(parts[1].content).toContain('Split files:');
+        expect(parts[1].parts[0]).toMatchObject({ file: 'big.js', index: 2, total: parts.length });
+    });
+
+    test('keeps whole files when splitting is disabled', () => {
+        const chunks = createFormatter({ splitFiles: false }).generateDigest();
+        expect(chunks.some(chunk => chunk.content.includes('FILE: big.js\n'))).toBe(true);
+        expect(chunks.every(chunk => chunk.parts.length === 0)).toBe(true);
+    });
+
+    test('saves each chunk next to the digest', () => {
+        const formatter = createFormatter();
+        const size = formatter.saveToFile(path.join(root, 'digest.txt'));
+
+        expect(formatter.chunkFiles.length).toBeGreaterThan(1);
+        expect(fs.existsSync(path.join(root, 'chunk-1.txt'))).toBe(true);
+        expect(size).toBe(formatter.chunkFiles.reduce((sum, file) => sum + fs.readFileSync(file, 'utf8').length, 0));
+    });
+});
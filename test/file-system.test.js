@@ -0,0 +1,90 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import FileSystem, { detectEncoding, decodeText } from '../lib/core/FileSystem.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import FileList from '../lib/core/FileList.js';
+
+const source = 'export const café = "naïve";\n';
+
+describe('FileSystem', () => {
+    test('normalizes Windows paths', () => {
+        const windows = new FileSystem({ platform: 'win32' });
+
+        expect(windows.normalize('c:/proj\\src/./a.js')).toBe('C:\\proj\\src\\a.js');
+        expect(windows.normalize('\\\\?\\C:\\proj\\a.js')).toBe('C:\\proj\\a.js');
+        expect(windows.normalize('\\\\?\\UNC\\server\\share\\proj')).toBe('\\\\server\\share\\proj');
+        expect(windows.normalize('//server/share/proj/lib/b.js')).toBe('\\\\server\\share\\proj\\lib\\b.js');
+
+        expect(windows.relative('C:\\proj', '\\\\?\\c:\\proj\\src\\a.js')).toBe('src\\a.js');
+        expect(windows.relative('\\\\server\\share\\proj', '//server/share/proj/lib/b.js')).toBe('lib\\b.js');
+        expect(windows.toPosix('src\\a.js')).toBe('src/a.js');
+        expect(windows.contains('C:\\proj', 'c:/proj/src')).toBe(true);
+        expect(windows.contains('C:\\proj', 'D:\\proj\\src')).toBe(false);
+        expect(windows.contains('C:\\proj', 'C:\\proj\\..config\\a.json')).toBe(true);
+        expect(windows.contains('C:\\proj\\src', 'C:\\proj\\lib')).toBe(false);
+    });
+
+    test('keeps POSIX paths, where backslashes are file name characters', () => {
+        const posix = new FileSystem({ platform: 'linux' });
+
+        expect(posix.normalize('/proj//src/../a.js')).toBe('/proj/a.js');
+        expect(posix.relative('/proj', '/proj/src/a\\b.js')).toBe('src/a\\b.js');
+        expect(posix.toPosix('src/a\\b.js')).toBe('src/a\\b.js');
+        expect(posix.contains('/proj', '/project/a.js')).toBe(false);
+        // Names that start with two dots are still children
+        expect(posix.contains('/proj', '/proj/..config')).toBe(true);
+        expect(posix.contains('/proj', '/proj/...')).toBe(true);
+        expect(posix.contains('/proj/src', '/proj')).toBe(false);
+        expect(posix.contains('/proj/src', '/proj/lib/a.js')).toBe(false);
+    });
+
+    test('detects encodings and transcodes to UTF-8', () => {
+        const utf16be = Buffer.from(`\ufeff${source}`, 'utf16le').swap16();
+        const samples = [
+            ['utf8', Buffer.from(source)],
+            ['utf8-bom', Buffer.from(`\ufeff${source}`)],
+            ['utf16le', Buffer.from(`\ufeff${source}`, 'utf16le')],
+            ['utf16le', Buffer.from(source, 'utf16le')],
+            ['utf16be', utf16be]
+        ];
+        for (const [encoding, buffer] of samples) {
+            expect(detectEncoding(buffer)).toBe(encoding);
+            expect(decodeText(buffer)).toBe(source);
+        }
+
+        const cp1252 = Buffer.from([0x93, 0x63, 0x61, 0x66, 0xe9, 0x94]);
+        expect(detectEncoding(cp1252)).toBe('windows-1252');
+        expect(decodeText(cp1252)).toBe('\u201ccaf\u00e9\u201d');
+        expect(detectEncoding(Buffer.from('src/a.js\0src/b.js\0'))).toBe('utf8');
+    });
+
+    describe('reading projects', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-fs-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src/utf8.js'), source);
+            fs.writeFileSync(path.join(root, 'src/utf16.js'), Buffer.from(`\ufeff${source}`, 'utf16le'));
+            fs.writeFileSync(path.join(root, 'files.txt'), Buffer.from('\ufeffsrc/utf16.js\r\nsrc/utf8.js\r\n', 'utf16le'));
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('counts UTF-16 files like their UTF-8 contents', () => {
+            const calculator = new TokenCalculator(root);
+            const [utf8, utf16] = ['src/utf8.js', 'src/utf16.js'].map(file => calculator.analyzeFile(path.join(root, file)));
+
+            expect(calculator.readFile(utf16.path)).toBe(source);
+            expect(utf16).toMatchObject({ tokens: utf8.tokens, lines: utf8.lines, relativePath: path.join('src', 'utf16.js') });
+        });
+
+        test('reads file lists saved as UTF-16', () => {
+            expect(FileList.read(path.join(root, 'files.txt'))).toEqual(['src/utf16.js', 'src/utf8.js']);
+        });
+    });
+});
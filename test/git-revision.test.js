@@ -0,0 +1,99 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { execSync } from 'child_process';
+import GitRevision from '../lib/integrations/git/GitRevision.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+const OLD_FILES = {
+    'src/parse.ts': 'export function parse(text: string) {\n    return text.trim();\n}\n',
+    'src/removed.ts': 'export const removed = true;\n',
+    'src/run.ts': "import { parse } from './parse';\n\nexport function run(input: string) {\n    return parse(input);\n}\n",
+    'logs/app.log': 'ignored\n',
+    'tool.sh': '#!/bin/sh\necho ü\n'
+};
+
+describe('GitRevision', () => {
+    let root;
+    const git = command => execSync(`git ${command}`, { cwd: root, stdio: 'pipe' }).toString().trim();
+    const write = (file, content) => {
+        fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+        fs.writeFileSync(path.join(root, file), content);
+    };
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-rev-'));
+        Object.entries(OLD_FILES).forEach(([file, content]) => write(file, content));
+        fs.chmodSync(path.join(root, 'tool.sh'), 0o755);
+        fs.symlinkSync('src/run.ts', path.join(root, 'link.ts'));
+        git('init -q');
+        git('add -A');
+        git('-c user.name=t -c user.email=t@t commit -q -m old');
+        git('tag v1');
+
+        write('.gitignore', 'logs/\n');
+        write('src/parse.ts', 'export function parse(text: string) {\n    return text.trim().toLowerCase();\n}\n');
+        write('src/added.ts', 'export const added = 1;\n');
+        fs.rmSync(path.join(root, 'src/removed.ts'));
+        git('add -A');
+        git('-c user.name=t -c user.email=t@t commit -q -m new');
+        write('src/untracked.ts', 'export const untracked = 1;\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('lists and reads files of a commit without checking it out', () => {
+        const revision = GitRevision.open(root, 'v1');
+        expect(revision.commit).toBe(git('rev-parse v1'));
+        expect(revision.describe()).toBe(`v1 (${revision.commit.slice(0, 12)})`);
+        expect(revision.listFiles().map(file => file.relativePath)).toEqual(['logs/app.log', 'src/parse.ts', 'src/removed.ts', 'src/run.ts', 'tool.sh']);
+
+        expect(revision.read(path.join(root, 'src', 'parse.ts'))).toBe(OLD_FILES['src/parse.ts']);
+        expect(revision.read('tool.sh')).toBe(OLD_FILES['tool.sh']);
+        expect(revision.size('tool.sh')).toBe(Buffer.byteLength(OLD_FILES['tool.sh']));
+        expect(() => revision.read('src/added.ts')).toThrow('is not in v1');
+        expect(fs.readFileSync(path.join(root, 'src', 'parse.ts'), 'utf8')).toContain('toLowerCase');
+    });
+
+    test('rejects unknown revisions and directories outside a repository', () => {
+        expect(() => GitRevision.open(root, 'nope')).toThrow('Unknown revision: nope');
+        expect(() => GitRevision.open(root, '--all')).toThrow('Invalid revision');
+
+        const outside = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-norepo-'));
+        try {
+            expect(() => GitRevision.open(outside, 'HEAD')).toThrow('needs a git repository');
+        } finally {
+            fs.rmSync(outside, { recursive: true, force: true });
+        }
+    });
+
+    test('analyzes and exports the project as it was at the revision', () => {
+        const revision = GitRevision.open(root, 'HEAD~1');
+        const calculator = new TokenCalculator(root, { revision });
+        const results = calculator.analyzeFiles(calculator.scanProject());
+
+        // The current .gitignore applies to the old tree
+        expect(results.map(fileInfo => fileInfo.relativePath.split(path.sep).join('/'))).toEqual(['src/parse.ts', 'src/removed.ts', 'src/run.ts', 'tool.sh']);
+        expect(calculator.stats.ignoredFiles).toBe(1);
+        expect(results[0].tokens).toBe(calculator.calculateTokens(OLD_FILES['src/parse.ts'], results[0].path));
+
+        const digest = new GitIngestFormatter(root, calculator.stats, results, {
+            readFile: filePath => calculator.readFile(filePath)
+        }).generateDigest();
+        expect(digest).toContain('export const removed = true;');
+        expect(digest).not.toContain('toLowerCase');
+
+        const { graph } = calculator.buildDependencyGraph(results);
+        expect(graph.getFile('src/run.ts').imports[0].target).toBe('src/parse.ts');
+    });
+
+    test('paths stay relative to a project root below the repository root', () => {
+        const revision = GitRevision.open(path.join(root, 'src'), 'v1');
+        expect(revision.listFiles().map(file => file.relativePath)).toEqual(['parse.ts', 'removed.ts', 'run.ts']);
+        expect(revision.read('removed.ts')).toBe(OLD_FILES['src/removed.ts']);
+    });
+});
@@ -0,0 +1,34 @@
+/**
+ * Golden-file snapshots for renderer tests
+ *
+ * expectGolden(name, output) compares output with test/golden/<name>.
+ * With UPDATE_GOLDEN=1 (npm run test:golden:update) the current output
+ * is written as the new golden file instead; review the change with
+ * git diff before committing it.
+ */
+
+import fs from 'fs';
+import path from 'path';
+import { fileURLToPath } from 'url';
+import { expect } from 'vitest';
+
+export const GOLDEN_DIR = path.join(path.dirname(fileURLToPath(import.meta.url)), 'golden');
+
+export const UPDATE_GOLDEN = process.env.UPDATE_GOLDEN === '1';
+
+/**
+ * @param {string} name - Path under test/golden/, e.g. 'gitingest/default.txt'
+ * @param {string} output - Rendered output
+ */
+export function expectGolden(name, output) {
+    const file = path.join(GOLDEN_DIR, name);
+    if (UPDATE_GOLDEN) {
+        fs.mkdirSync(path.dirname(file), { recursive: true });
+        fs.writeFileSync(file, output);
+        return;
+    }
+    if (!fs.existsSync(file)) {
+        throw new Error(`No golden file test/golden/${name}; run with UPDATE_GOLDEN=1 to create it`);
+    }
+    expect(output, `test/golden/${name} (UPDATE_GOLDEN=1 rewrites it)`).toBe(fs.readFileSync(file, 'utf8'));
+}
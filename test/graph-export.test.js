@@ -0,0 +1,153 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import DependencyExpander from '../lib/graph/DependencyExpander.js';
+import SymbolSlicer from '../lib/graph/SymbolSlicer.js';
+import GraphExporter, { GraphKind, EdgeKind } from '../lib/graph/GraphExporter.js';
+
+const FILES = {
+    'go.mod': 'module example.com/app\n',
+    'pkg/calc/calc.go': [
+        'package calc',
+        '',
+        'type Logger interface {',
+        '\tLog(msg string)',
+        '}',
+        '',
+        'type Calculator struct {',
+        '\tlog   Logger',
+        '\ttotal float64',
+        '}',
+        '',
+        'func (c *Calculator) Add(v float64) {',
+        '\tc.total = clamp(c.total + v)',
+        '}',
+        '',
+        'func clamp(v float64) float64 {',
+        '\treturn v',
+        '}',
+        ''
+    ].join('\n'),
+    'cmd/main.go': [
+        'package main',
+        '',
+        'import "example.com/app/pkg/calc"',
+        '',
+        'type Console struct{}',
+        '',
+        'func (Console) Log(msg string) {}',
+        '',
+        'func main() {',
+        '\tc := &calc.Calculator{}',
+        '\tc.Add(1)',
+        '}',
+        ''
+    ].join('\n')
+};
+
+describe('GraphExporter', () => {
+    let root;
+    let graph;
+
+    const edgeList = model => {
+        const names = new Map(model.nodes.map(node => [node.id, node.symbol.qualifiedName]));
+        return model.edges.map(edge => [names.get(edge.from), edge.kind, names.get(edge.to), edge.followed]);
+    };
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-graph-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        graph = new DependencyGraph({ root, symbolBackend: 'heuristic' })
+            .build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('collects call, uses and implements edges', () => {
+        const model = new GraphExporter(graph).build();
+
+        expect(model.nodes.every(node => node.included && node.reason === null)).toBe(true);
+        expect(edgeList(model)).toEqual([
+            ['main', EdgeKind.CALLS, 'Calculator.Add', false],
+            ['Calculator.Add', EdgeKind.CALLS, 'clamp', false],
+            ['Console', EdgeKind.IMPLEMENTS, 'Logger', false],
+            ['Calculator', EdgeKind.USES, 'Logger', false]
+        ]);
+
+        const calls = new GraphExporter(graph, { kinds: [GraphKind.CALLS] }).build();
+        expect(calls.nodes.map(node => node.symbol.kind)).not.toContain('struct');
+    });
+
+    test('marks what a focus expansion included and why', () => {
+        const expansion = new DependencyExpander(graph).expand('calc.Calculator.Add', 1);
+        const model = new GraphExporter(graph, { selection: GraphExporter.selectionFromExpansion(expansion) }).build();
+
+        expect(model.nodes.map(node => [node.symbol.qualifiedName, node.included, node.reason])).toEqual([
+            ['main', false, null],
+            ['Logger', false, null],
+            ['Calculator', true, 'depth 1'],
+            ['Calculator.Add', true, 'focus'],
+            ['clamp', true, 'depth 1']
+        ]);
+        expect(edgeList(model)).toEqual([
+            ['main', EdgeKind.CALLS, 'Calculator.Add', false],
+            ['Calculator.Add', EdgeKind.CALLS, 'clamp', true],
+            ['Calculator', EdgeKind.USES, 'Logger', false],
+            ['Calculator.Add', EdgeKind.REFERENCES, 'Calculator', true]
+        ]);
+    });
+
+    test('labels slice roles', () => {
+        const slice = new SymbolSlicer(graph).slice(['pkg/calc.Calculator.Add']);
+        const model = new GraphExporter(graph, { selection: GraphExporter.selectionFromSlice(slice, graph) }).build();
+
+        expect(model.nodes.filter(node => node.included).map(node => [node.symbol.qualifiedName, node.reason])).toEqual([
+            ['Calculator', 'receiver type'],
+            ['Calculator.Add', 'selected']
+        ]);
+    });
+
+    test('renders DOT and Mermaid', () => {
+        const expansion = new DependencyExpander(graph).expand('clamp', 0);
+        const exporter = new GraphExporter(graph, { kinds: [GraphKind.CALLS], selection: GraphExporter.selectionFromExpansion(expansion) });
+
+        expect(exporter.export('dot')).toBe([
+            'digraph ctxman {',
+            '  rankdir=LR;',
+            '  node [shape=box, fontname="Helvetica", fontsize=10];',
+            '  edge [fontname="Helvetica", fontsize=9];',
+            '  subgraph cluster_0 {',
+            '    label="pkg/calc/calc.go";',
+            '    style=rounded; color="#999999";',
+            '    n1 [label="Calculator.Add\\nmethod", style=dashed, fontcolor="#888888", color="#888888"];',
+            '    n2 [label="clamp\\nfunction · focus", style="filled,bold", fillcolor="#d4edda"];',
+            '  }',
+            '  n1 -> n2 [label="calls"];',
+            '}',
+            ''
+        ].join('\n'));
+
+        expect(exporter.export('mermaid')).toBe([
+            'flowchart LR',
+            '  subgraph f0["pkg/calc/calc.go"]',
+            '    n1["Calculator.Add<br/>method"]',
+            '    n2["clamp<br/>function · focus"]',
+            '  end',
+            '  n1 -->|"calls"| n2',
+            '  classDef included fill:#d4edda,stroke:#28a745,stroke-width:2px;',
+            '  classDef excluded stroke-dasharray:5 5,color:#888888;',
+            '  class n2 included;',
+            '  class n1 excluded;',
+            ''
+        ].join('\n'));
+
+        expect(() => exporter.export('svg')).toThrow('Unsupported graph format: svg');
+    });
+});
@@ -0,0 +1,139 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import http2 from 'http2';
+import os from 'os';
+import path from 'path';
+import GrpcServer, { GRPC_STATUS, frame } from '../lib/api/grpc/GrpcServer.js';
+import { encode, decode } from '../lib/api/grpc/protobuf.js';
+
+const FILES = {
+    'src/service.js': "import { parse } from './util.js';\n\nexport class Service {\n    fetch(input) {\n        return parse(input);\n    }\n}\n",
+    'src/util.js': 'export function parse(input) {\n    return JSON.parse(input);\n}\n'
+};
+
+/**
+ * One call over a client session; onMessage may cancel the call
+ */
+function call(client, method, [requestType, responseType], message, { headers = {}, onMessage } = {}) {
+    return new Promise((resolve, reject) => {
+        const stream = client.request({
+            ':method': 'POST',
+            ':path': `/ctxman.v1.ContextService/${method}`,
+            'content-type': 'application/grpc',
+            te: 'trailers',
+            ...headers
+        });
+        const messages = [];
+        let data = Buffer.alloc(0);
+        stream.on('data', chunk => {
+            data = Buffer.concat([data, chunk]);
+            while (data.length >= 5 && data.length >= 5 + data.readUInt32BE(1)) {
+                const length = data.readUInt32BE(1);
+                messages.push(decode(responseType, data.subarray(5, 5 + length)));
+                onMessage?.(messages.at(-1), stream);
+                data = data.subarray(5 + length);
+            }
+        });
+        stream.on('trailers', trailers => resolve({
+            messages,
+            status: Number(trailers['grpc-status']),
+            message: decodeURIComponent(trailers['grpc-message'] || '')
+        }));
+        stream.on('close', () => resolve({ messages, status: null }));
+        stream.on('error', reject);
+        stream.end(frame(encode(requestType, message)));
+    });
+}
+
+describe('GrpcServer', () => {
+    let root;
+    let server;
+    let client;
+
+    beforeAll(async () => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-grpc-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        server = new GrpcServer(root, { authToken: 'secret', chunkSize: 16 });
+        const { port } = await server.listen(0);
+        client = http2.connect(`http://localhost:${port}`);
+    });
+
+    afterAll(async () => {
+        client.close();
+        await server.close();
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const auth = { authorization: 'Bearer secret' };
+
+    test('encodes and decodes proto3 messages', () => {
+        const request = { format: 'xml', maxTokens: '32k', tokenizer: '', tiers: ['full', 'signatures'], focus: '', depth: 0, symbols: [], profile: '', pairTests: '' };
+        expect(decode('GenerateContextRequest', encode('GenerateContextRequest', request))).toEqual(request);
+        expect(decode('GenerateContextRequest', Buffer.alloc(0))).toMatchObject({ format: '', depth: null, tiers: [] });
+
+        const chunk = { text: 'é', stats: { files: 2, tokens: 2 ** 40, format: 'json' } };
+        expect(decode('ContextChunk', encode('ContextChunk', chunk))).toEqual(chunk);
+        // Unknown fields are skipped
+        const extended = Buffer.concat([Buffer.from([0x78, 0x01]), encode('WatchChangesRequest', { debounceMs: 50 })]);
+        expect(decode('WatchChangesRequest', extended)).toEqual({ debounceMs: 50 });
+        expect(() => decode('ChangeEvent', Buffer.from([0x0a, 0x05, 0x61]))).toThrow('truncated field');
+    });
+
+    test('streams a packed context and answers symbol queries', async () => {
+        const generated = await call(client, 'GenerateContext', ['GenerateContextRequest', 'ContextChunk'], { format: 'gitingest' }, { headers: auth });
+        expect(generated.status).toBe(GRPC_STATUS.OK);
+        expect(generated.messages[0].stats).toMatchObject({ files: 2, format: 'gitingest' });
+        expect(generated.messages.length).toBeGreaterThan(2);
+        const text = generated.messages.slice(1).map(message => message.text).join('');
+        expect(text).toContain('return JSON.parse(input);');
+        expect(text).toContain('export class Service');
+
+        const found = await call(client, 'QuerySymbols', ['QuerySymbolsRequest', 'QuerySymbolsResponse'], { name: 'parse' }, { headers: auth });
+        expect(found.messages[0].symbols).toMatchObject([{ name: 'parse', kind: 'function', file: 'src/util.js', startLine: 1, endLine: 3, exported: true }]);
+        const outline = await call(client, 'QuerySymbols', ['QuerySymbolsRequest', 'QuerySymbolsResponse'], { file: 'src/service.js' }, { headers: auth });
+        expect(outline.messages[0].symbols.map(symbol => symbol.name)).toEqual(['Service', 'Service.fetch']);
+    });
+
+    test('answers errors with gRPC status codes', async () => {
+        const types = ['QuerySymbolsRequest', 'QuerySymbolsResponse'];
+        expect(await call(client, 'QuerySymbols', types, { name: 'parse' })).toMatchObject({
+            status: GRPC_STATUS.UNAUTHENTICATED, messages: []
+        });
+        expect(await call(client, 'QuerySymbols', types, { file: 'src/missing.js' }, { headers: auth })).toMatchObject({
+            status: GRPC_STATUS.NOT_FOUND, message: 'File not found: src/missing.js'
+        });
+        expect(await call(client, 'QuerySymbols', types, {}, { headers: auth })).toMatchObject({
+            status: GRPC_STATUS.INVALID_ARGUMENT, message: 'QuerySymbols needs name or file'
+        });
+        expect(await call(client, 'GenerateContext', ['GenerateContextRequest', 'ContextChunk'], { format: 'pdf' }, { headers: auth })).toMatchObject({
+            status: GRPC_STATUS.INVALID_ARGUMENT
+        });
+        expect(await call(client, 'DeleteProject', types, {}, { headers: auth })).toMatchObject({
+            status: GRPC_STATUS.UNIMPLEMENTED, message: 'Unknown method: /ctxman.v1.ContextService/DeleteProject'
+        });
+    });
+
+    test('streams file changes until cancelled', async () => {
+        const events = [];
+        const watched = call(client, 'WatchChanges', ['WatchChangesRequest', 'ChangeEvent'], { debounceMs: 20 }, {
+            headers: auth,
+            onMessage: (event, stream) => {
+                events.push(event);
+                if (event.type === 'deleted') stream.close(http2.constants.NGHTTP2_CANCEL);
+            }
+        });
+        const file = path.join(root, 'src', 'new.js');
+        const wait = ms => new Promise(resolve => setTimeout(resolve, ms));
+        await wait(200);
+        fs.writeFileSync(file, 'export const x = 1;\n');
+        await wait(200);
+        fs.rmSync(file);
+        await watched;
+
+        expect(events.map(event => [event.type, event.file])).toEqual([['added', 'src/new.js'], ['deleted', 'src/new.js']]);
+        expect(events[0].size).toBe(20);
+    });
+});
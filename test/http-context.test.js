@@ -0,0 +1,122 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { once } from 'events';
+import APIServer from '../lib/api/rest/server.js';
+import { EmbeddingProvider } from '../lib/rag/EmbeddingProvider.js';
+
+const VOCABULARY = ['retry', 'attempt', 'user', 'name'];
+
+class WordProvider extends EmbeddingProvider {
+    get id() {
+        return 'test:words';
+    }
+
+    async embed(text) {
+        const words = text.toLowerCase().match(/[a-z]+/g) || [];
+        return [0.1, ...VOCABULARY.map(word => words.filter(w => w.startsWith(word)).length)];
+    }
+}
+
+const FILES = {
+    'lib/http.js': [
+        'export function withRetry(fn, attempts = 3) {',
+        '    // retry the attempt until it succeeds',
+        '    return fn().catch(() => attempts > 0 && withRetry(fn, attempts - 1));',
+        '}',
+        ''
+    ].join('\n'),
+    'lib/user.js': [
+        'export class User {',
+        '    rename(name) {',
+        '        this.name = name;',
+        '    }',
+        '}',
+        ''
+    ].join('\n')
+};
+
+describe('HTTP context daemon', () => {
+    let root;
+    let server;
+    let baseUrl;
+
+    const get = async (pathname, options) => {
+        const response = await fetch(baseUrl + pathname, options);
+        const type = response.headers.get('content-type');
+        return { status: response.status, type, body: type.startsWith('application/json') ? await response.json() : await response.text() };
+    };
+
+    beforeAll(async () => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-http-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+
+        server = new APIServer({
+            port: 0,
+            projectRoot: root,
+            service: { symbolBackend: 'heuristic', provider: new WordProvider() }
+        });
+        server.start();
+        await once(server.server, 'listening');
+        baseUrl = `http://localhost:${server.server.address().port}`;
+    });
+
+    afterAll(() => {
+        server.server.close();
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('GET /context follows the working tree', async () => {
+        const first = await get('/context');
+        expect(first.status).toBe(200);
+        expect(first.body.files.map(file => file.path)).toEqual(['lib/http.js', 'lib/user.js']);
+
+        fs.writeFileSync(path.join(root, 'lib', 'extra.js'), 'export const extra = 1;\n');
+        try {
+            const digest = await get('/context?format=gitingest');
+            expect(digest.type).toContain('text/plain');
+            expect(digest.body).toContain('FILE: lib/extra.js');
+        } finally {
+            fs.rmSync(path.join(root, 'lib', 'extra.js'));
+        }
+    });
+
+    test('GET /context narrows to a focus symbol', async () => {
+        const focused = await get('/context?focus=User.rename');
+        expect(focused.body.selection).toMatchObject({ mode: 'focus', target: 'User.rename' });
+        expect(focused.body.files.map(file => [file.path, file.included])).toEqual([['lib/user.js', 'partial']]);
+
+        expect((await get('/context?focus=nothing')).body).toMatchObject({ statusCode: 404, error: 'Symbol not found: nothing' });
+        expect((await get('/context?format=csv')).status).toBe(400);
+        expect((await get('/context?maxTokens=lots')).status).toBe(400);
+    });
+
+    test('GET /symbols lists outlines and definitions', async () => {
+        const all = await get('/symbols');
+        expect(all.body.outlines.map(outline => outline.file)).toEqual(['lib/http.js', 'lib/user.js']);
+
+        const outline = await get('/symbols?file=lib/user.js&kind=method');
+        expect(outline.body.symbols.map(symbol => symbol.name)).toEqual(['User.rename']);
+
+        const definitions = await get('/symbols?name=withRetry');
+        expect(definitions.body[0]).toMatchObject({ file: 'lib/http.js', startLine: 1 });
+
+        expect((await get('/symbols?file=../secret.js')).status).toBe(403);
+        expect((await get('/symbols?file=lib/missing.js')).status).toBe(404);
+    });
+
+    test('POST /query returns the most relevant chunks', async () => {
+        const result = await get('/query', { method: 'POST', body: JSON.stringify({ query: 'retry attempts', top: 1 }) });
+        expect(result.status).toBe(200);
+        expect(result.body).toContain('FILE: lib/http.js');
+        expect(result.body).not.toContain('lib/user.js');
+
+        expect((await get('/query', { method: 'POST', body: '{}' })).status).toBe(400);
+        expect((await get('/query', { method: 'POST', body: 'nope' })).body.error).toBe('Invalid JSON');
+        expect((await get('/query')).status).toBe(405);
+    });
+});
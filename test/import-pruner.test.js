@@ -0,0 +1,82 @@
+import { describe, test, expect } from 'vitest';
+import ImportPruner from '../lib/graph/ImportPruner.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+import { TypeScriptPlugin } from '../lib/languages/TypeScriptPlugin.js';
+import { PythonPlugin } from '../lib/languages/PythonPlugin.js';
+import { RustPlugin } from '../lib/languages/RustPlugin.js';
+import { GoPlugin } from '../lib/languages/GoPlugin.js';
+
+// Preamble of the lines `startLine-endLine` of a file
+const preambleOf = (plugin, file, content, startLine, endLine) => ImportPruner.preamble({
+    content, path: file, plugin, language: plugin.getLanguageId(file)
+}, [{ startLine, endLine }]);
+
+describe('ImportPruner', () => {
+    test('rewrites TypeScript imports to the bindings used', () => {
+        const content = [
+            "import fs, { readFileSync, writeFileSync } from 'fs';",
+            "import { helper as h, other } from './util.js';",
+            "import * as path from 'path';",
+            "import './polyfill.js';",
+            '',
+            'export function read(file) {',
+            '    return readFileSync(h(file));',
+            '}',
+            ''
+        ].join('\n');
+
+        expect(preambleOf(new TypeScriptPlugin(), 'src/read.ts', content, 6, 8)).toEqual({
+            startLine: 1,
+            endLine: 4,
+            lines: ["import { readFileSync } from 'fs';", "import { helper as h } from './util.js';", "import './polyfill.js';"],
+            pruned: 3
+        });
+    });
+
+    test('rewrites Python and Rust imports', () => {
+        const python = new PythonPlugin();
+        const used = name => ['path', 'Read', 'io', 'HashMap'].includes(name);
+        expect(python.pruneImport('from os import path, sep', used)).toBe('from os import path');
+        expect(python.pruneImport('from . import (\n    path,\n    sep,\n)', used)).toBe('from . import path');
+        expect(python.pruneImport('import sys, json as j', used)).toBeNull();
+        expect(python.pruneImport('from os import *', used)).toBeUndefined();
+
+        const rust = new RustPlugin();
+        expect(rust.pruneImport('use std::collections::{HashMap, HashSet};', used)).toBe('use std::collections::HashMap;');
+        expect(rust.pruneImport('pub use std::{io::{self, Read, Write}, fmt};', used)).toBe('pub use std::io::{self, Read};');
+        expect(rust.pruneImport('use std::fmt::Write as _;', used)).toBe('use std::fmt::Write as _;');
+    });
+
+    test('drops unused entries of Go import blocks', () => {
+        const content = [
+            'package calc',
+            '',
+            'import (',
+            '\t"fmt"',
+            '\t"strings"',
+            ')',
+            '',
+            'func Upper(s string) string {',
+            '\treturn strings.ToUpper(s)',
+            '}',
+            ''
+        ].join('\n');
+
+        expect(preambleOf(new GoPlugin(), 'calc/upper.go', content, 8, 10).lines)
+            .toEqual(['package calc', '', 'import (', '\t"strings"', ')']);
+    });
+
+    test('gives trimmed files the imports of their symbols within the budget', () => {
+        const budget = new TokenBudget({ maxTokens: 100, tokenizer: 'estimate' });
+        const item = { id: 'a.ts', tokens: 500, priority: 1 };
+        const symbols = [{ id: 'a.ts#f', startLine: 3, endLine: 5, tokens: 60, priority: 1 }];
+        const plan = context => budget.plan([item], { expand: () => symbols, context: () => context });
+
+        const fits = plan({ name: 'imports', startLine: 1, endLine: 1, context: true, tokens: 20 });
+        expect(fits.partial[0].symbols.map(symbol => symbol.name || symbol.id)).toEqual(['imports', 'a.ts#f']);
+        expect(fits.partial[0]).toMatchObject({ tokens: 80, omittedSymbols: 0 });
+
+        const tooLarge = plan({ name: 'imports', startLine: 1, endLine: 1, context: true, tokens: 50 });
+        expect(tooLarge.partial[0].symbols.map(symbol => symbol.id)).toEqual(['a.ts#f']);
+    });
+});
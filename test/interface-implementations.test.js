@@ -0,0 +1,191 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import DependencyExpander from '../lib/graph/DependencyExpander.js';
+import ImplementationFinder, { ImplementationMatch } from '../lib/graph/ImplementationFinder.js';
+import SymbolSlicer, { SliceRole } from '../lib/graph/SymbolSlicer.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import { supertypeNames } from '../lib/symbols/SourceScanner.js';
+
+const FILES = {
+    'go.mod': 'module example.com/app\n',
+    'pkg/stream/stream.go': [
+        'package stream',
+        '',
+        'type Reader interface {',
+        '\tRead(p []byte) (int, error)',
+        '}',
+        '',
+        'type Writer interface {',
+        '\tWrite(p []byte) (int, error)',
+        '}',
+        '',
+        'type ReadWriter interface {',
+        '\tReader',
+        '\tWriter',
+        '}',
+        ''
+    ].join('\n'),
+    'pkg/file/file.go': [
+        'package file',
+        '',
+        'type File struct {',
+        '\tpath string',
+        '}',
+        '',
+        'func (f *File) Read(p []byte) (int, error) {',
+        '\treturn 0, nil',
+        '}',
+        '',
+        'func (f *File) Write(p []byte) (int, error) {',
+        '\treturn len(p), nil',
+        '}',
+        '',
+        'func (f *File) Close() error {',
+        '\treturn nil',
+        '}',
+        ''
+    ].join('\n'),
+    'pkg/file/source.go': [
+        'package file',
+        '',
+        'type Source struct{}',
+        '',
+        'func (s Source) Read(p []byte) (int, error) {',
+        '\treturn 0, nil',
+        '}',
+        ''
+    ].join('\n'),
+    'src/Shape.java': 'public interface Shape {\n    double area();\n}\n',
+    'src/Polygon.java': 'public interface Polygon extends Shape {\n    int sides();\n}\n',
+    'src/Square.java': [
+        'public class Square implements Polygon {',
+        '    public double area() {',
+        '        return 1;',
+        '    }',
+        '',
+        '    public int sides() {',
+        '        return 4;',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'src/lib.rs': [
+        'pub trait Speak {',
+        '    fn speak(&self) -> String;',
+        '}',
+        '',
+        'pub struct Dog;',
+        '',
+        'impl Speak for Dog {',
+        '    fn speak(&self) -> String {',
+        '        String::from("woof")',
+        '    }',
+        '}',
+        ''
+    ].join('\n')
+};
+
+describe('supertypeNames', () => {
+    test('reads implements and extends clauses', () => {
+        expect(supertypeNames('class A<T extends X> extends B implements C<T>, ns.D', 'implements')).toEqual(['C', 'D']);
+        expect(supertypeNames('interface I<T> extends J<T>, K', 'extends')).toEqual(['J', 'K']);
+        expect(supertypeNames('public sealed interface S extends T permits U', 'extends')).toEqual(['T']);
+        expect(supertypeNames('class Plain', 'implements')).toBeNull();
+    });
+});
+
+describe('ImplementationFinder', () => {
+    let root;
+    let graph;
+    let finder;
+
+    const symbol = (file, name) => graph.getFile(file).symbols.find(s => s.qualifiedName === name);
+    const found = iface => finder.find(iface).map(({ type, via, methods }) => [type.qualifiedName, via, methods.map(m => m.name)]);
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-impl-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        graph = new DependencyGraph({ root, symbolBackend: 'heuristic' })
+            .build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+        finder = new ImplementationFinder(graph);
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('Go types match by method set, including embedded interfaces', () => {
+        expect(found(symbol('pkg/stream/stream.go', 'Reader'))).toEqual([
+            ['File', ImplementationMatch.STRUCTURAL, ['Read']],
+            ['Source', ImplementationMatch.STRUCTURAL, ['Read']]
+        ]);
+        expect(found(symbol('pkg/stream/stream.go', 'ReadWriter'))).toEqual([
+            ['File', ImplementationMatch.STRUCTURAL, ['Read', 'Write']]
+        ]);
+    });
+
+    test('declared implementations follow sub-interfaces', () => {
+        expect(found(symbol('src/Shape.java', 'Shape'))).toEqual([['Square', ImplementationMatch.DECLARED, ['area']]]);
+        expect(found(symbol('src/Polygon.java', 'Polygon'))).toEqual([['Square', ImplementationMatch.DECLARED, ['area', 'sides']]]);
+        expect(found(symbol('src/lib.rs', 'Speak'))).toEqual([['Dog', ImplementationMatch.DECLARED, ['speak']]]);
+        expect(finder.find(symbol('pkg/file/file.go', 'File'))).toEqual([]);
+    });
+
+    test('the slicer lists implementations and adds them on request', () => {
+        const listed = new SymbolSlicer(graph).slice(['pkg/stream.Writer']);
+        expect(listed.files.map(f => f.file)).toEqual(['pkg/stream/stream.go']);
+        expect(SymbolSlicer.formatSlice(listed)).toContain('Implementations of Writer: File (pkg/file/file.go:3, structural)');
+        expect(SymbolSlicer.formatSlice(listed)).toContain('--include-implementations');
+
+        const { files } = new SymbolSlicer(graph, { implementations: true }).slice(['pkg/stream.Writer']);
+        const file = files.find(f => f.file === 'pkg/file/file.go');
+        expect(file.ranges.map(r => [r.name, r.role])).toEqual([
+            ['imports', SliceRole.PREAMBLE],
+            ['File', SliceRole.IMPLEMENTATION],
+            ['File.Write', SliceRole.IMPLEMENTATION]
+        ]);
+
+        const java = new SymbolSlicer(graph, { implementations: true }).slice(['Shape']).files.find(f => f.file === 'src/Square.java');
+        expect(java.ranges.map(r => [r.name, r.role, r.startLine, r.endLine])).toEqual([
+            ['Square', SliceRole.DECLARATION, 1, 1],
+            ['Square.area', SliceRole.IMPLEMENTATION, 2, 4]
+        ]);
+    });
+
+    test('focus expansion adds implementations one level down', () => {
+        const expansion = new DependencyExpander(graph, { implementations: true }).expand('pkg/stream.Reader', 0);
+        expect(expansion.dependencies.map(({ symbol, depth, via }) => [symbol.qualifiedName, depth, via])).toEqual([
+            ['File', 1, 'Reader'],
+            ['File.Read', 1, 'Reader'],
+            ['Source', 1, 'Reader'],
+            ['Source.Read', 1, 'Reader']
+        ]);
+    });
+
+    test('token calculator exports implementations with --include-implementations', () => {
+        const calculator = new TokenCalculator(root, {
+            symbols: ['pkg/stream.Reader'],
+            includeImplementations: true,
+            symbolBackend: 'heuristic',
+            dashboard: true
+        });
+        const results = Object.keys(FILES).map(f => calculator.analyzeFile(path.join(root, f)));
+        const selected = calculator.selectExportResults(results);
+
+        expect(selected.map(f => f.relativePath).sort()).toEqual(['pkg/file/file.go', 'pkg/file/source.go', 'pkg/stream/stream.go']);
+        expect(calculator.generateLLMContext(selected).selection.implementations).toEqual([{
+            interface: 'Reader',
+            included: true,
+            types: [
+                { name: 'File', file: 'pkg/file/file.go', line: 3, via: 'structural', methods: ['Read'] },
+                { name: 'Source', file: 'pkg/file/source.go', line: 3, via: 'structural', methods: ['Read'] }
+            ]
+        }]);
+    });
+});
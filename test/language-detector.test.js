@@ -0,0 +1,62 @@
+import { describe, test, expect } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import LanguageDetector from '../lib/languages/LanguageDetector.js';
+import SymbolExtractor from '../lib/symbols/SymbolExtractor.js';
+import ContentStripper from '../lib/core/ContentStripper.js';
+import FileUtils from '../lib/utils/file-utils.js';
+
+describe('LanguageDetector', () => {
+    test('recognizes build and tool files by name', () => {
+        expect(LanguageDetector.fromName('Dockerfile')).toBe('dockerfile');
+        expect(LanguageDetector.fromName('docker/Dockerfile.dev')).toBe('dockerfile');
+        expect(LanguageDetector.fromName('Makefile')).toBe('makefile');
+        expect(LanguageDetector.fromName('src/BUILD.bazel')).toBe('starlark');
+        expect(LanguageDetector.fromName('WORKSPACE')).toBe('starlark');
+        expect(LanguageDetector.fromName('Gemfile')).toBe('ruby');
+        expect(LanguageDetector.fromName('CMakeLists.txt')).toBe('cmake');
+        expect(LanguageDetector.fromName('build')).toBeNull();
+        expect(LanguageDetector.fromName('README')).toBeNull();
+    });
+
+    test('reads the interpreter of shebangs and modelines', () => {
+        expect(LanguageDetector.fromContent('#!/usr/bin/env -S python3.12 -u\nprint(1)\n')).toBe('python');
+        expect(LanguageDetector.fromContent('#!/usr/bin/env NODE_ENV=production node\n')).toBe('javascript');
+        expect(LanguageDetector.fromContent('#!/bin/bash -e\nset -x\n')).toBe('shell');
+        expect(LanguageDetector.fromContent('#!/usr/bin/perl -w\n')).toBe('perl');
+        expect(LanguageDetector.fromContent('<?php\necho 1;\n')).toBe('php');
+        expect(LanguageDetector.fromContent('# vim: set ft=ruby:\nputs 1\n')).toBe('ruby');
+        expect(LanguageDetector.fromContent('# -*- mode: python; coding: utf-8 -*-\n')).toBe('python');
+        expect(LanguageDetector.fromContent('#!/opt/tool/run\nplain text\n')).toBeNull();
+    });
+
+    test('lets extensions win over content and names win over both', () => {
+        const python = '#!/usr/bin/env python3\n';
+        expect(LanguageDetector.detect('bin/deploy', python)).toBe('python');
+        expect(LanguageDetector.detect('scripts/run.sh', python)).toBeNull();
+        expect(LanguageDetector.extensionOf('scripts/run.sh', python)).toBe('.sh');
+        expect(LanguageDetector.extensionOf('bin/deploy', python)).toBe('.py');
+        expect(LanguageDetector.extensionOf('BUILD', '#!/bin/sh\n')).toBe('.py');
+        expect(LanguageDetector.extensionOf('LICENSE', 'MIT License\n')).toBe('');
+    });
+
+    test('routes detected files to plugins, comment syntax and the scanner', () => {
+        const script = '#!/usr/bin/env python3\n# deploy\ndef main():\n    return 1\n';
+        const extractor = new SymbolExtractor({ backend: 'heuristic' });
+        expect(extractor.supports('bin/deploy')).toBe(false);
+        expect(extractor.extract(script, 'bin/deploy').map(symbol => symbol.name)).toContain('main');
+        expect(ContentStripper.syntaxOf('Dockerfile')).toBe(ContentStripper.syntaxOf('run.sh'));
+
+        const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'language-detector-'));
+        try {
+            fs.writeFileSync(path.join(dir, 'deploy'), script);
+            fs.writeFileSync(path.join(dir, 'notes'), 'plain text\n');
+            expect(FileUtils.isScript(path.join(dir, 'deploy'))).toBe(true);
+            expect(FileUtils.isScript(path.join(dir, 'notes'))).toBe(false);
+            expect(FileUtils.isText(path.join(dir, 'Makefile'))).toBe(true);
+        } finally {
+            fs.rmSync(dir, { recursive: true, force: true });
+        }
+    });
+});
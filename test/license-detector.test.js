@@ -0,0 +1,135 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import LicenseDetector from '../lib/core/LicenseDetector.js';
+import CompressionStats from '../lib/core/CompressionStats.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const APACHE_HEADER = [
+    '// Copyright 2020 Google LLC',
+    '//',
+    '// Licensed under the Apache License, Version 2.0 (the "License");',
+    '// you may not use this file except in compliance with the License.',
+    ''
+].join('\n');
+
+const MIT_LICENSE = [
+    'MIT License',
+    '',
+    'Copyright (c) 2024 Acme Corp',
+    '',
+    'Permission is hereby granted, free of charge, to any person obtaining a copy',
+    'of this software and associated documentation files (the "Software"), to deal',
+    'in the Software without restriction.',
+    ''
+].join('\n');
+
+describe('LicenseDetector', () => {
+    test('identifies licenses by SPDX identifier or wording, with copyright holders', () => {
+        expect(LicenseDetector.identify(MIT_LICENSE)).toEqual({ license: 'MIT', copyright: ['Copyright (c) 2024 Acme Corp'] });
+        expect(LicenseDetector.identify(APACHE_HEADER)).toEqual({ license: 'Apache-2.0', copyright: ['Copyright 2020 Google LLC'] });
+        expect(LicenseDetector.identify('# SPDX-License-Identifier: GPL-2.0-only OR MIT\n# (c) 2019-2021 Jane Doe. All rights reserved.\n'))
+            .toEqual({ license: 'GPL-2.0-only OR MIT', copyright: ['(c) 2019-2021 Jane Doe'] });
+        expect(LicenseDetector.identify('Redistribution and use in source and binary forms ... Neither the name of the copyright holder').license)
+            .toBe('BSD-3-Clause');
+        expect(LicenseDetector.identify('Proprietary. Copyright 2023 Initech.').license).toBeNull();
+
+        expect(LicenseDetector.isLicenseFile('LICENSE')).toBe(true);
+        expect(LicenseDetector.isLicenseFile('packages/ui/LICENSE-MIT.txt')).toBe(true);
+        expect(LicenseDetector.isLicenseFile('COPYING.LESSER')).toBe(true);
+        expect(LicenseDetector.isLicenseFile('src/license.js')).toBe(false);
+    });
+
+    test('removes leading license comments past shebangs and directives', () => {
+        const detector = new LicenseDetector();
+        const go = `//go:build linux\n\n${APACHE_HEADER}\npackage main\n\n// main starts the server\nfunc main() {}\n`;
+        expect(detector.strip(go, 'cmd/main.go')).toBe('//go:build linux\n\npackage main\n\n// main starts the server\nfunc main() {}\n');
+        expect(detector.headerOf('cmd/main.go')).toMatchObject({ license: 'Apache-2.0', copyright: ['Copyright 2020 Google LLC'] });
+
+        const python = '#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\n# SPDX-License-Identifier: MIT\n# Copyright (c) 2022 Foo\n\nimport os\n';
+        expect(detector.strip(python, 'tool.py')).toBe('#!/usr/bin/env python3\n# -*- coding: utf-8 -*-\nimport os\n');
+
+        const block = '/*\n * Copyright (c) 2015 Example Inc.\n * Licensed under the MIT License.\n */\n/** Adds two numbers */\nexport const add = (a, b) => a + b;\n';
+        expect(detector.strip(block, 'src/add.js')).toBe('/** Adds two numbers */\nexport const add = (a, b) => a + b;\n');
+
+        // Ordinary doc comments and later mentions stay
+        const doc = '/** Parses the license field of package.json */\nexport function parse() {}\n';
+        expect(detector.strip(doc, 'src/parse.js')).toBe(doc);
+        const late = 'const x = 1;\n// Copyright 2020 Google LLC\n';
+        expect(detector.strip(late, 'src/late.js')).toBe(late);
+        expect(detector.headerOf('src/late.js')).toBeNull();
+    });
+
+    test('aggregates files and headers into one note', () => {
+        const detector = new LicenseDetector({ limit: 2 });
+        const note = detector.aggregate(
+            [{ path: 'vendor/lib/LICENSE', content: 'Apache License, Version 2.0', tokens: 900 }, { path: 'LICENSE', content: MIT_LICENSE, tokens: 80 }],
+            [
+                { path: 'src/b.js', license: 'MIT', copyright: ['Copyright (c) 2024 Acme Corp'], tokens: 30 },
+                { path: 'src/a.js', license: 'MIT', copyright: ['Copyright (c) 2024 Acme Corp'], tokens: 30 },
+                { path: 'src/c.js', license: 'MIT', copyright: ['Copyright (c) 2023 Acme Corp'], tokens: 30 },
+                { path: 'vendor/lib/x.js', license: null, copyright: [], tokens: 10 }
+            ]
+        );
+        expect(note.files.map(file => [file.path, file.license])).toEqual([['LICENSE', 'MIT'], ['vendor/lib/LICENSE', 'Apache-2.0']]);
+        expect(note.headers).toEqual([
+            { license: 'MIT', copyright: ['Copyright (c) 2023 Acme Corp', 'Copyright (c) 2024 Acme Corp'], files: ['src/a.js', 'src/b.js', 'src/c.js'], tokens: 90 },
+            { license: null, copyright: [], files: ['vendor/lib/x.js'], tokens: 10 }
+        ]);
+
+        const section = detector.format(note);
+        expect(section).toContain('\nLICENSES\n');
+        expect(section).toContain('LICENSE — MIT\n  Copyright (c) 2024 Acme Corp\n');
+        expect(section).toContain('License headers removed from 4 files:');
+        expect(section).toContain('  MIT (3 files): src/a.js, src/b.js, … 1 more');
+        expect(section).toContain('  unknown (1 file): vendor/lib/x.js');
+        expect(detector.describe(note)).toBe('⚖️  Licenses: MIT (LICENSE), Apache-2.0 (vendor/lib/LICENSE); 4 headers removed, 100 tokens');
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-licenses-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'LICENSE'), MIT_LICENSE);
+            fs.writeFileSync(path.join(root, 'src', 'server.js'), `${APACHE_HEADER}\nexport function serve() {\n    return 1;\n}\n`);
+            fs.writeFileSync(path.join(root, 'src', 'util.js'), `${APACHE_HEADER}\nexport const twice = x => x * 2;\n`);
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('exports code without headers and LICENSE files, with one licensing note', () => {
+            const calculator = new TokenCalculator(root, { licenseDetector: new LicenseDetector(), compressionStats: {} });
+            const analyzed = calculator.analyzeFiles(calculator.scanProject());
+            const server = analyzed.find(fileInfo => fileInfo.relativePath.endsWith('server.js'));
+            expect(server.licenseHeader).toMatchObject({ license: 'Apache-2.0', copyright: ['Copyright 2020 Google LLC'] });
+            expect(server.licenseHeader.tokens).toBeGreaterThan(0);
+            expect(server.lines).toBe(4);
+
+            const exported = calculator.selectExportResults(analyzed);
+            expect(exported.map(fileInfo => fileInfo.relativePath).sort()).toEqual([path.join('src', 'server.js'), path.join('src', 'util.js')]);
+
+            const digest = calculator.createGitIngestFormatter(exported).generateDigest();
+            expect(digest).not.toContain('you may not use this file');
+            expect(digest).not.toContain('Permission is hereby granted');
+            expect(digest).toContain('LICENSE — MIT');
+            expect(digest).toContain('  Apache-2.0 (2 files): src/server.js, src/util.js');
+
+            const structured = calculator.createStructuredFormatter(exported).build();
+            expect(structured.licenses.files).toMatchObject([{ path: 'LICENSE', license: 'MIT' }]);
+            expect(structured.licenses.headers[0]).toMatchObject({ license: 'Apache-2.0', files: ['src/server.js', 'src/util.js'] });
+
+            // Header tokens of both files, and the LICENSE file left out
+            const license = analyzed.find(fileInfo => fileInfo.relativePath === 'LICENSE');
+            const stats = calculator.compression.build(exported);
+            expect(stats.transforms).toContain('license');
+            const headers = analyzed.reduce((sum, fileInfo) => sum + (fileInfo.licenseHeader?.tokens || 0), 0);
+            expect(stats.totals.saved.license).toBe(headers + license.tokens);
+            expect(CompressionStats.formatReport(stats)).toContain('license');
+        });
+    });
+});
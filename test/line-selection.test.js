@@ -0,0 +1,86 @@
+import { describe, test, expect, beforeEach, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import LineSelection from '../lib/core/LineSelection.js';
+import SourceDirectives from '../lib/core/SourceDirectives.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const PARSER = [
+    'package parse',
+    '',
+    '// ctx:region hot-loop',
+    'func scan() {',
+    '\tfor {}',
+    '}',
+    '// ctx:endregion',
+    '',
+    'func cold() {}',
+    ''
+].join('\n');
+
+describe('LineSelection', () => {
+    test('parses line ranges and regions', () => {
+        expect(LineSelection.isSelector('src/parse.go:120-240')).toBe(true);
+        expect(LineSelection.isSelector('src/parse.go#hot-loop')).toBe(true);
+        expect(LineSelection.isSelector('src/**/*.go')).toBe(false);
+
+        const selection = new LineSelection(['./src/parse.go:3', 'src/parse.go:8-', 'src/parse.go#hot-loop', 'src/parse.go:4-5']);
+        expect(selection.files()).toEqual(['src/parse.go']);
+        expect(selection.rangesOf('src/parse.go', 10, [{ name: 'hot-loop', startLine: 4, endLine: 6 }])).toEqual([
+            { name: '3', kind: 'lines', startLine: 3, endLine: 3 },
+            { name: 'hot-loop', kind: 'region', startLine: 4, endLine: 6 },
+            { name: '8-', kind: 'lines', startLine: 8, endLine: 10 }
+        ]);
+
+        expect(() => LineSelection.parse('src/parse.go:9-2')).toThrow('Empty line range: src/parse.go:9-2');
+        expect(() => new LineSelection(['a.go:20']).rangesOf('a.go', 10)).toThrow('Line range past the end of a.go: a.go:20 (10 lines)');
+        expect(() => new LineSelection(['a.go#hot']).rangesOf('a.go', 10)).toThrow('Region not found: a.go#hot (no //ctx:region in the file)');
+    });
+
+    test('reads regions between their markers', () => {
+        const nested = '/* ctx:region=outer */\n// ctx:region inner\nx\n// ctx:endregion\n// ctx:endregion outer\n// ctx:endregion\n';
+        const directives = SourceDirectives.parse(`${PARSER}${nested}`, 'src/parse.go');
+        expect(directives.regions).toEqual([
+            { name: 'hot-loop', startLine: 4, endLine: 6 },
+            { name: 'outer', startLine: 11, endLine: 13 },
+            { name: 'inner', startLine: 12, endLine: 12 }
+        ]);
+        expect(directives.warnings.map(warning => warning.message)).toEqual([
+            'ctx:endregion has no open ctx:region'
+        ]);
+        expect(SourceDirectives.parse('// ctx:region\n', 'a.js').warnings[0].message).toContain('Invalid ctx:region');
+        expect(SourceDirectives.parse('// ctx:region a\n', 'a.js').warnings[0].message).toBe('ctx:region a is not closed (expected ctx:endregion)');
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeEach(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-slices-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src', 'parse.go'), PARSER);
+            fs.writeFileSync(path.join(root, 'src', 'util.go'), 'package parse\n\nfunc util() {}\n');
+        });
+
+        afterEach(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('exports only the pinned lines of a file', () => {
+            const calculator = new TokenCalculator(root, { lineSelection: new LineSelection(['src/parse.go#hot-loop', 'src/parse.go:9']) });
+            const analysis = calculator.analyzeFiles(calculator.scanDirectory(root));
+            const util = analysis.find(fileInfo => fileInfo.relativePath.endsWith('util.go'));
+            const [parse, ...rest] = calculator.applyLineSelection([util], analysis).reverse();
+
+            expect(rest).toEqual([util]);
+            expect(parse).toMatchObject({ pinned: true, pinnedBy: 'src/parse.go#hot-loop, src/parse.go:9', selectionNote: 'Pinned lines' });
+            expect(parse.selectedSymbols.map(range => [range.name, range.startLine, range.endLine])).toEqual([['hot-loop', 4, 6], ['9', 9, 9]]);
+
+            const digest = calculator.createGitIngestFormatter([parse]).generateFileBody(parse);
+            expect(digest).toContain('// region: hot-loop (lines 4-6)\nfunc scan() {\n\tfor {}\n}\n');
+            expect(digest).not.toContain('ctx:region');
+            expect(new TokenCalculator(root, { lineSelection: new LineSelection(['src/none.go:1']) }).applyLineSelection([], analysis)).toBe(null);
+        });
+    });
+});
@@ -0,0 +1,140 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import DependencyExpander from '../lib/graph/DependencyExpander.js';
+import LspGateway, { parseLspSpec } from '../lib/integrations/lsp/LspGateway.js';
+import LspClient from '../lib/integrations/lsp/LspClient.js';
+
+// Minimal stdio language server: definitions and references come from the
+// JSON table in FAKE_LSP_TABLE, keyed by the word under the cursor
+const FAKE_SERVER = `
+import fs from 'fs';
+import { fileURLToPath, pathToFileURL } from 'url';
+import path from 'path';
+
+const root = process.cwd();
+const table = JSON.parse(process.env.FAKE_LSP_TABLE);
+let buffer = Buffer.alloc(0);
+
+const send = message => {
+    const body = Buffer.from(JSON.stringify(message));
+    process.stdout.write('Content-Length: ' + body.length + '\\r\\n\\r\\n');
+    process.stdout.write(body);
+};
+const location = ([file, line, character]) => ({
+    uri: pathToFileURL(path.resolve(root, file)).href,
+    range: { start: { line, character }, end: { line, character } }
+});
+const wordAt = ({ textDocument, position }) => {
+    const line = fs.readFileSync(fileURLToPath(textDocument.uri), 'utf8').split('\\n')[position.line];
+    return /^[\\w$]*/.exec(line.slice(position.character))[0];
+};
+
+process.stdin.on('data', chunk => {
+    buffer = Buffer.concat([buffer, chunk]);
+    for (;;) {
+        const end = buffer.indexOf('\\r\\n\\r\\n');
+        if (end === -1) return;
+        const length = Number(/Content-Length: (\\d+)/.exec(buffer.subarray(0, end).toString())[1]);
+        if (buffer.length < end + 4 + length) return;
+        const message = JSON.parse(buffer.subarray(end + 4, end + 4 + length).toString());
+        buffer = buffer.subarray(end + 4 + length);
+
+        if (message.method === 'initialize') send({ id: message.id, result: { capabilities: {} } });
+        if (message.method === 'textDocument/definition') {
+            const entry = table[wordAt(message.params)];
+            send({ id: message.id, result: entry ? location(entry.definition) : null });
+        }
+        if (message.method === 'textDocument/references') {
+            send({ id: message.id, result: (table[wordAt(message.params)]?.references || []).map(location) });
+        }
+        if (message.method === 'shutdown') send({ id: message.id, result: null });
+        if (message.method === 'exit') process.exit(0);
+    }
+});
+`;
+
+const FILES = {
+    'src/store.js': 'export class Store {\n    get(key) {\n        return key;\n    }\n}\n',
+    'src/cache.js': 'export class Cache {\n    get(key) {\n        return null;\n    }\n}\n',
+    'src/app.js': [
+        "import { Store } from './store.js';",
+        '',
+        'export function load(store) {',
+        "    // get is only resolvable by type",
+        "    return store.get('a');",
+        '}',
+        ''
+    ].join('\n')
+};
+
+const TABLE = {
+    get: { definition: ['src/store.js', 1, 4], references: [['src/app.js', 4, 17]] },
+    Store: { definition: ['src/store.js', 0, 13], references: [['src/app.js', 0, 9], ['/outside/project.js', 3, 0]] }
+};
+
+describe('LSP gateway', () => {
+    let root;
+    let gateway;
+    let graph;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-lsp-'));
+        fs.writeFileSync(path.join(root, 'fake-lsp.mjs'), FAKE_SERVER);
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        graph = new DependencyGraph({ root }).build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+
+        process.env.FAKE_LSP_TABLE = JSON.stringify(TABLE);
+        gateway = new LspGateway({
+            root,
+            spec: `${process.execPath} ${path.join(root, 'fake-lsp.mjs')}`,
+            languages: ['javascript'],
+            timeout: 5000
+        }).start();
+    });
+
+    afterAll(() => {
+        gateway?.close();
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('parses commands and addresses', () => {
+        expect(parseLspSpec('typescript-language-server --stdio')).toEqual({ command: ['typescript-language-server', '--stdio'], address: null });
+        expect(parseLspSpec('tcp://:37374')).toEqual({ command: null, address: { host: '127.0.0.1', port: 37374 } });
+        expect(() => parseLspSpec('tcp://localhost')).toThrow('expected tcp://host:port');
+        expect(new LspGateway({ spec: 'gopls' }).languages).toEqual(new Set(['go']));
+        expect(() => new LspGateway({ spec: 'tcp://127.0.0.1:1' })).toThrow('--lsp-languages');
+    });
+
+    test('answers definitions and references with project paths', () => {
+        expect(gateway.definition('src/app.js', 'javascript', 5, 17)).toEqual([{ file: 'src/store.js', line: 2, character: 4 }]);
+        expect(gateway.references('src/app.js', 'javascript', 1, 9)).toEqual([{ file: 'src/app.js', line: 1, character: 9 }]);
+        expect(gateway.definition('src/app.js', 'python', 5, 17)).toBeNull();
+    });
+
+    test('dependency expansion follows definitions the server resolves', () => {
+        const focus = 'src/app.js:load';
+        const withoutServer = new DependencyExpander(graph).expand(focus, 1);
+        const withServer = new DependencyExpander(graph, { lsp: gateway }).expand(focus, 1);
+
+        expect(withoutServer.dependencies.map(entry => entry.symbol.qualifiedName)).not.toContain('Store.get');
+        expect(withServer.dependencies.map(entry => `${entry.symbol.file}:${entry.symbol.qualifiedName}`)).toEqual(['src/store.js:Store.get']);
+    });
+
+    test('normalizes locations and links', () => {
+        const range = { start: { line: 2, character: 1 }, end: { line: 2, character: 5 } };
+        expect(LspClient.locations([{ targetUri: 'file:///a/b.go', targetRange: range, targetSelectionRange: range }, { uri: 'untitled:x', range }]))
+            .toEqual([{ path: path.resolve('/a/b.go'), line: 2, character: 1 }]);
+        expect(LspClient.locations(null)).toEqual([]);
+    });
+
+    test('reports servers that cannot start', () => {
+        expect(() => new LspGateway({ root, spec: 'ctxman-missing-language-server', languages: ['go'], timeout: 2000 }).start())
+            .toThrow('Cannot start language server ctxman-missing-language-server');
+    });
+});
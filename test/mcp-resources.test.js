@@ -100,11 +100,13 @@ describe("ResourceProvider", () => {
             const templates = provider.getResourceTemplates();
 
             expect(templates).toBeInstanceOf(Array);
-            expect(templates.length).toBe(3);
+            expect(templates.length).toBe(5);
 
             expect(templates[0].uriTemplate).toBe("file://codebase/{path}");
             expect(templates[1].uriTemplate).toBe("analysis://recent/{id}");
             expect(templates[2].uriTemplate).toBe("context://template/{name}");
+            expect(templates[3].uriTemplate).toBe("outline://codebase/{path}");
+            expect(templates[4].uriTemplate).toBe("symbol://codebase/{name}");
         });
 
         it("should have proper template structure", () => {
@@ -232,6 +234,13 @@ describe("ResourceProvider", () => {
             expect(result).toEqual({ type: "context", name: "bug-fix" });
         });
 
+        it("should parse outline and symbol URIs", () => {
+            expect(provider.parseUri("outline://codebase/src/app.py"))
+                .toEqual({ type: "outline", path: "src/app.py" });
+            expect(provider.parseUri("symbol://codebase/Service.fetch"))
+                .toEqual({ type: "symbol", name: "Service.fetch" });
+        });
+
         it("should throw for invalid URIs", () => {
             expect(() => provider.parseUri("https://example.com")).toThrow("Invalid resource URI");
         });
@@ -80,12 +80,14 @@ describe("MCPServer", () => {
 
         const result = await listToolsHandler({});
         expect(result.tools).toBeDefined();
-        expect(result.tools.length).toBe(5);
+        expect(result.tools.length).toBe(7);
         expect(result.tools[0].name).toBe("analyze_codebase");
         expect(result.tools[1].name).toBe("generate_context");
         expect(result.tools[2].name).toBe("git_diff");
         expect(result.tools[3].name).toBe("search_code");
         expect(result.tools[4].name).toBe("list_methods");
+        expect(result.tools[5].name).toBe("get_file_outline");
+        expect(result.tools[6].name).toBe("find_symbol");
     });
 
     it("should handle analyze_codebase tool call", async () => {
@@ -0,0 +1,88 @@
+import { describe, it, expect, beforeAll, afterAll } from "vitest";
+import fs from "fs";
+import os from "os";
+import path from "path";
+import { SymbolProvider } from "../lib/api/mcp/symbols.js";
+import { ResourceProvider } from "../lib/api/mcp/resources.js";
+
+const SERVICE_TS = [
+    "/** Fetches items. */",
+    "export class Service {",
+    "  async fetch(id: string): Promise<string> {",
+    "    return id;",
+    "  }",
+    "}",
+    "",
+    "export function fetchAll() {}",
+    ""
+].join("\n");
+
+describe("SymbolProvider", () => {
+    let root;
+    let provider;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), "ctxman-mcp-"));
+        fs.mkdirSync(path.join(root, "src"));
+        fs.writeFileSync(path.join(root, "src", "service.ts"), SERVICE_TS);
+        fs.writeFileSync(path.join(root, "src", "util.py"), "def fetch():\n    return 1\n");
+        fs.writeFileSync(path.join(root, "notes.txt"), "fetch me\n");
+        provider = new SymbolProvider({ symbolBackend: "heuristic" });
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    it("should return a file outline", async () => {
+        const outline = await provider.getOutline(root, "src/service.ts");
+
+        expect(outline.supported).toBe(true);
+        expect(outline.symbols.map(s => s.name)).toEqual(["Service", "Service.fetch", "fetchAll"]);
+        expect(outline.symbols[0].doc).toBe("Fetches items.");
+
+        const text = provider.formatOutline(outline);
+        expect(text).toContain("# src/service.ts");
+        expect(text).toContain("  - method async fetch(id: string): Promise<string> (L3-5)");
+    });
+
+    it("should report unsupported files", async () => {
+        const outline = await provider.getOutline(root, "notes.txt");
+        expect(outline.supported).toBe(false);
+        expect(provider.formatOutline(outline)).toContain("No symbol extractor");
+    });
+
+    it("should find definitions ranked by name match", async () => {
+        const definitions = await provider.findSymbol(root, "fetch");
+
+        expect(definitions.map(d => d.name)).toEqual(["fetch", "Service.fetch"]);
+        expect(definitions[0].file).toBe("src/util.py");
+        expect(definitions[1].source).toBe("  async fetch(id: string): Promise<string> {\n    return id;\n  }");
+        expect(definitions[1].truncated).toBe(false);
+    });
+
+    it("should filter definitions by kind and file", async () => {
+        const methods = await provider.findSymbol(root, "fetch", { kind: "method" });
+        expect(methods.map(d => d.name)).toEqual(["Service.fetch"]);
+
+        const inFile = await provider.findSymbol(root, "fetch", { file: "src/util.py" });
+        expect(inFile).toHaveLength(1);
+    });
+
+    it("should refuse paths outside the project", async () => {
+        await expect(provider.getOutline(root, "../etc/passwd")).rejects.toThrow("Access denied");
+    });
+
+    it("should serve outline and symbol resources", async () => {
+        const resources = new ResourceProvider(root, provider);
+
+        const outline = await resources.readResource("outline://codebase/src/service.ts");
+        expect(outline.contents[0].mimeType).toBe("text/markdown");
+        expect(outline.contents[0].text).toContain("class Service");
+
+        const symbol = await resources.readResource("symbol://codebase/Service.fetch");
+        expect(JSON.parse(symbol.contents[0].text)[0].startLine).toBe(3);
+
+        await expect(resources.readResource("symbol://codebase/missing")).rejects.toThrow("Symbol not found");
+    });
+});
@@ -0,0 +1,91 @@
+import { describe, test, expect } from 'vitest';
+import ModelPresets, { MODEL_PRESETS } from '../lib/core/ModelPresets.js';
+import FormatRegistry from '../lib/formatters/format-registry.js';
+import { parseXmlTags } from '../lib/formatters/xml-formatter.js';
+
+describe('ModelPresets', () => {
+    test('resolves presets from their LLM profiles', () => {
+        expect(ModelPresets.resolve('claude-sonnet')).toEqual({
+            name: 'claude-sonnet',
+            model: 'claude-sonnet-4.5',
+            label: 'Claude Sonnet 4.5',
+            tokenizer: 'claude',
+            contextWindow: 200000,
+            reserve: 8192,
+            format: 'xml'
+        });
+        expect(ModelPresets.resolve('gpt-4o')).toMatchObject({ tokenizer: 'o200k_base', contextWindow: 128000, reserve: 16384, format: 'markdown' });
+        expect(ModelPresets.resolve('gemini-1.5').model).toBe('gemini-1.5-pro');
+    });
+
+    test('accepts profile ids and rejects unknown models', () => {
+        expect(ModelPresets.resolve('claude-opus-4')).toMatchObject({ name: 'claude-opus-4', tokenizer: 'claude', format: 'xml' });
+        expect(() => ModelPresets.resolve('gpt-9')).toThrow('Unknown --model: gpt-9');
+        expect(ModelPresets.list().map(preset => preset.name)).toEqual(Object.keys(MODEL_PRESETS));
+        expect(ModelPresets.describe(ModelPresets.resolve('claude-sonnet')))
+            .toBe('Claude Sonnet 4.5 (200,000-token window, 8,192 reserved for output, claude tokenizer, xml format)');
+    });
+});
+
+describe('XML context', () => {
+    test('renders files as tagged documents', () => {
+        const doc = {
+            project: { name: 'demo', files: 2, tokens: 30 },
+            selection: { mode: 'focus', target: 'parse', budget: { used: 30, limit: 1000, tokenizer: 'claude' } },
+            files: [
+                {
+                    path: 'src/a&b.js', language: 'javascript', tokens: 20, lines: 2, included: 'partial', note: 'Focus parse',
+                    ranges: [{ name: 'parse' }], content: 'if (a < b) {}\nconst end = "</document_content>";\n'
+                },
+                { path: 'src/b.js', language: null, tokens: 10, lines: 1, included: 'summary', note: null, ranges: null, content: null }
+            ]
+        };
+
+        expect(new FormatRegistry().encode('xml', doc)).toBe([
+            '<context project="demo" files="2" tokens="30">',
+            '<selection mode="focus" target="parse" budget="30/1000" tokenizer="claude"/>',
+            '<documents>',
+            '<document index="1">',
+            '<source language="javascript" tokens="20" lines="2" included="partial: parse">src/a&amp;b.js</source>',
+            '<note>Focus parse</note>',
+            '<document_content>',
+            'if (a < b) {}',
+            'const end = "&lt;/document_content&gt;";',
+            '</document_content>',
+            '</document>',
+            '<document index="2">',
+            '<source tokens="10" lines="1" included="summary">src/b.js</source>',
+            '</document>',
+            '</documents>',
+            '</context>',
+            ''
+        ].join('\n'));
+    });
+
+    test('renames the tags of the document layout', () => {
+        const tags = parseXmlTags('documents=files, document=file,source=path,document_content=document_contents');
+        expect(tags).toEqual({ documents: 'files', document: 'file', source: 'path', document_content: 'document_contents' });
+        expect(() => parseXmlTags('body=text')).toThrow('Unknown XML tag: body (expected documents, document, source, document_content)');
+        expect(() => parseXmlTags('source=1path')).toThrow('Invalid XML tag name for source: 1path');
+        expect(() => parseXmlTags('')).toThrow('No XML tags given');
+
+        const doc = {
+            project: { name: 'demo', files: 1, tokens: 5 },
+            selection: { mode: 'all', target: null, budget: null },
+            files: [{ path: 'a.js', language: 'javascript', tokens: 5, lines: 1, included: 'full', note: null, ranges: null, content: 'x("</document_contents>");\n' }]
+        };
+        expect(new FormatRegistry().encode('xml', doc, { xmlTags: tags })).toBe([
+            '<context project="demo" files="1" tokens="5">',
+            '<files>',
+            '<file index="1">',
+            '<path language="javascript" tokens="5" lines="1" included="full">a.js</path>',
+            '<document_contents>',
+            'x("&lt;/document_contents&gt;");',
+            '</document_contents>',
+            '</file>',
+            '</files>',
+            '</context>',
+            ''
+        ].join('\n'));
+    });
+});
@@ -0,0 +1,133 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import NotebookPlugin, { notebookToText } from '../lib/languages/NotebookPlugin.js';
+import MarkdownPlugin from '../lib/languages/MarkdownPlugin.js';
+import { SymbolExtractor } from '../lib/symbols/SymbolExtractor.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const NOTEBOOK = JSON.stringify({
+    metadata: { kernelspec: { language: 'python' }, language_info: { name: 'python' } },
+    cells: [
+        { cell_type: 'markdown', source: ['# Training\n', 'Loads the data.'] },
+        { cell_type: 'code', source: ['import pandas as pd\n', 'from .utils import clean'], outputs: [] },
+        {
+            cell_type: 'code',
+            source: ['def load_dataset(path):\n', '    return clean(pd.read_csv(path))'],
+            outputs: [{ output_type: 'stream', name: 'stdout', text: ['loaded\n'] }]
+        },
+        {
+            cell_type: 'code',
+            source: 'class Model:\n    def fit(self, data):\n        return data',
+            outputs: [{ output_type: 'display_data', data: { 'image/png': 'iVBOR' } }]
+        }
+    ]
+});
+
+const MARKDOWN = [
+    '# Usage',
+    '',
+    '```python',
+    'class Client:',
+    '    def connect(self):',
+    '        pass',
+    '```',
+    '',
+    'Then query:',
+    '',
+    '~~~ts',
+    'export function query(sql: string) {',
+    '  return sql;',
+    '}',
+    '~~~',
+    '',
+    '```bash',
+    'pip install client',
+    '```'
+].join('\n');
+
+describe('NotebookPlugin', () => {
+    test('converts notebooks to percent-format text without outputs', () => {
+        expect(notebookToText(NOTEBOOK).split('\n')).toEqual([
+            '# Jupyter notebook (python)',
+            '',
+            '# %% [markdown]',
+            '# # Training',
+            '# Loads the data.',
+            '',
+            '# %%',
+            'import pandas as pd',
+            'from .utils import clean',
+            '',
+            '# %%',
+            'def load_dataset(path):',
+            '    return clean(pd.read_csv(path))',
+            '',
+            '# %%',
+            'class Model:',
+            '    def fit(self, data):',
+            '        return data',
+            ''
+        ]);
+        expect(notebookToText('not json')).toBe('not json');
+    });
+
+    test('keeps outputs as comments when asked', () => {
+        const text = notebookToText(NOTEBOOK, { outputs: true });
+
+        expect(text).toContain('    return clean(pd.read_csv(path))\n\n# %% [output]\n# loaded\n\n# %%\nclass Model:');
+        expect(text).toContain('        return data\n\n# %% [output]\n# [image/png]\n');
+    });
+
+    test('extracts symbols and imports of code cells at text lines', () => {
+        const plugin = new NotebookPlugin();
+        const symbols = plugin.extractSymbols(NOTEBOOK, 'train.ipynb');
+
+        expect(symbols.map(symbol => [symbol.name, symbol.kind, symbol.startLine, symbol.endLine])).toEqual([
+            ['load_dataset', 'function', 12, 13],
+            ['Model', 'class', 16, 18],
+            ['fit', 'method', 17, 18]
+        ]);
+        expect(symbols.every(symbol => symbol.file === 'train.ipynb' && symbol.language === 'python')).toBe(true);
+        expect(plugin.extractImports(NOTEBOOK, 'train.ipynb').map(imported => imported.source)).toEqual(['pandas', '.utils']);
+    });
+});
+
+describe('MarkdownPlugin', () => {
+    test('extracts symbols of fenced code blocks at document lines', () => {
+        const symbols = new SymbolExtractor({ backend: 'heuristic' }).extract(MARKDOWN, 'docs/usage.md');
+
+        expect(symbols.map(symbol => [symbol.name, symbol.kind, symbol.startLine, symbol.language])).toEqual([
+            ['Client', 'class', 4, 'python'],
+            ['connect', 'method', 5, 'python'],
+            ['query', 'function', 12, 'typescript']
+        ]);
+        expect(new MarkdownPlugin().extractImports(MARKDOWN, 'docs/usage.md')).toEqual([]);
+        expect(new MarkdownPlugin().extractSymbols('# No code\n', 'README.md')).toEqual([]);
+    });
+});
+
+describe('TokenCalculator notebooks', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-notebook-'));
+        fs.writeFileSync(path.join(root, 'train.ipynb'), NOTEBOOK);
+        fs.mkdirSync(path.join(root, '.ipynb_checkpoints'));
+        fs.writeFileSync(path.join(root, '.ipynb_checkpoints', 'train-checkpoint.ipynb'), NOTEBOOK);
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('reads notebooks as text and skips checkpoints', () => {
+        const calculator = new TokenCalculator(root, { dashboard: true });
+        const scanned = calculator.scanProject().map(file => path.relative(root, file));
+
+        expect(scanned).toEqual(['train.ipynb']);
+        expect(calculator.readFile(path.join(root, 'train.ipynb'))).toBe(notebookToText(NOTEBOOK));
+        expect(new TokenCalculator(root, { notebookOutputs: true }).readFile(path.join(root, 'train.ipynb'))).toContain('# loaded');
+    });
+});
@@ -0,0 +1,81 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { compareBytes, comparePaths, compareSymbols, sectionId } from '../lib/utils/ordering.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('Deterministic ordering', () => {
+    test('compares strings by their UTF-8 bytes', () => {
+        expect(['main_test.go', 'main.go', 'Zeta', 'alpha', 'é', 'z'].sort(compareBytes))
+            .toEqual(['Zeta', 'alpha', 'main.go', 'main_test.go', 'z', 'é']);
+        // U+FF5E sorts before U+1F600 in UTF-8, after its surrogates in UTF-16
+        expect(compareBytes('～', '\u{1F600}')).toBeLessThan(0);
+        expect(compareBytes('ab', 'abc')).toBeLessThan(0);
+        expect(compareBytes('same', 'same')).toBe(0);
+    });
+
+    test('compares paths with native separators as /', () => {
+        expect(comparePaths(['src', 'a.js'].join(path.sep), 'src/a.js')).toBe(0);
+        expect([`src${path.sep}b.js`, 'src-old/a.js', 'src/a.js'].sort(comparePaths))
+            .toEqual(['src-old/a.js', 'src/a.js', `src${path.sep}b.js`]);
+    });
+
+    test('orders symbols by file, position, kind and name', () => {
+        const symbols = [
+            { file: 'b.js', startLine: 1, endLine: 5, kind: 'function', qualifiedName: 'b' },
+            { file: 'a.js', startLine: 3, endLine: 9, kind: 'class', qualifiedName: 'Outer' },
+            { file: 'a.js', startLine: 3, endLine: 4, kind: 'method', qualifiedName: 'Outer.inner' },
+            { file: 'a.js', startLine: 1, endLine: 1, kind: 'variable', name: 'y' },
+            { file: 'a.js', startLine: 1, endLine: 1, kind: 'variable', name: 'x' }
+        ];
+        expect([...symbols].sort(compareSymbols).map(symbol => symbol.qualifiedName || symbol.name))
+            .toEqual(['x', 'y', 'Outer.inner', 'Outer', 'b']);
+    });
+
+    test('derives section IDs from the path alone', () => {
+        expect(sectionId('src/server.js')).toBe(2046085069);
+        expect(sectionId(['src', 'server.js'].join(path.sep))).toBe(2046085069);
+        expect(sectionId('src/client.js')).not.toBe(sectionId('src/server.js'));
+        expect(Number.isInteger(sectionId(''))).toBe(true);
+        expect(sectionId('x')).toBeLessThan(2 ** 32);
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-ordering-'));
+            const files = { 'lib/main_test.js': 'test();\n', 'lib/main.js': 'run();\n', 'lib/Zed.js': 'zed();\n', 'README.md': '# R\n' };
+            for (const [name, content] of Object.entries(files)) {
+                fs.mkdirSync(path.dirname(path.join(root, name)), { recursive: true });
+                fs.writeFileSync(path.join(root, name), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('files come out in byte order with section IDs', () => {
+            const calculator = new TokenCalculator(root, { sectionIds: true });
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            const { files } = calculator.createStructuredFormatter(results).build();
+
+            expect(files.map(file => file.path)).toEqual(['README.md', 'lib/Zed.js', 'lib/main.js', 'lib/main_test.js']);
+            expect(files.map(file => file.id)).toEqual(files.map(file => sectionId(file.path)));
+
+            const digest = calculator.createGitIngestFormatter(results).generateDigest();
+            expect(digest).toContain(`FILE: lib/main.js\nID: ${sectionId('lib/main.js')}\n`);
+        });
+
+        test('structured output has no ids without the option', () => {
+            const calculator = new TokenCalculator(root);
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            const { files } = calculator.createStructuredFormatter(results).build();
+
+            expect(files.every(file => !('id' in file))).toBe(true);
+            expect(calculator.createGitIngestFormatter(results).generateDigest()).not.toContain('\nID: ');
+        });
+    });
+});
@@ -0,0 +1,80 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import OutputTarget from '../lib/core/OutputTarget.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+// Collects what a target writes to stdout
+const fakeStdout = () => {
+    const stream = { data: '', write: text => { stream.data += text; } };
+    return stream;
+};
+
+describe('OutputTarget', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-out-'));
+        fs.writeFileSync(path.join(root, 'app.js'), 'export const app = () => 42;\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('rejects unknown targets', () => {
+        expect(() => new OutputTarget({ target: 'printer' })).toThrow('Unknown output target: printer');
+    });
+
+    test('writes project files and prints their paths', () => {
+        const stdout = fakeStdout();
+        const target = new OutputTarget({ root, printPath: true, stdout });
+
+        expect(target.write('context', 'digest.txt')).toBe(path.join(root, 'digest.txt'));
+        expect(fs.readFileSync(path.join(root, 'digest.txt'), 'utf8')).toBe('context');
+        expect(stdout.data).toBe(`${path.join(root, 'digest.txt')}\n`);
+    });
+
+    test('keeps the output a run replaces with backup', () => {
+        const target = new OutputTarget({ root, backup: true, fsync: true });
+        const digestPath = path.join(root, 'bundle.txt');
+
+        target.write('first', 'bundle.txt');
+        expect(fs.existsSync(`${digestPath}.bak`)).toBe(false);
+        target.stream(['second ', 'run'], 'bundle.txt');
+        expect(fs.readFileSync(digestPath, 'utf8')).toBe('second run');
+        expect(fs.readFileSync(`${digestPath}.bak`, 'utf8')).toBe('first');
+        expect(fs.readdirSync(root).filter(file => file.endsWith('.tmp'))).toEqual([]);
+    });
+
+    test('sends content to stdout without writing files', () => {
+        const stdout = fakeStdout();
+        const target = new OutputTarget({ target: 'stdout', root, stdout });
+
+        expect(target.isStream()).toBe(true);
+        expect(target.write('{"files": []}', 'context.json')).toBeNull();
+        expect(stdout.data).toBe('{"files": []}\n');
+        expect(fs.existsSync(path.join(root, 'context.json'))).toBe(false);
+    });
+
+    test('writes tmpfile outputs to one temporary directory', () => {
+        const stdout = fakeStdout();
+        const calculator = new TokenCalculator(root, {
+            outputTarget: new OutputTarget({ target: 'tmpfile', root, printPath: true, stdout })
+        });
+        const results = [calculator.analyzeFile(path.join(root, 'app.js'))];
+
+        calculator.saveGitIngestDigest(results);
+        calculator.saveContextToFile(calculator.generateLLMContext(results));
+
+        const [digestPath, contextPath] = stdout.data.trim().split('\n');
+        expect(path.dirname(digestPath)).toBe(path.dirname(contextPath));
+        expect(path.dirname(digestPath).startsWith(os.tmpdir())).toBe(true);
+        expect(path.basename(digestPath)).toBe('digest.txt');
+        expect(fs.readFileSync(digestPath, 'utf8')).toContain('export const app = () => 42;');
+        expect(JSON.parse(fs.readFileSync(contextPath, 'utf8'))).toHaveProperty('project');
+
+        fs.rmSync(path.dirname(digestPath), { recursive: true, force: true });
+    });
+});
@@ -0,0 +1,101 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import WorkerPool, { parseJobs } from '../lib/core/WorkerPool.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+
+describe('WorkerPool', () => {
+    let dir;
+    let script;
+
+    beforeAll(() => {
+        dir = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-pool-'));
+        script = path.join(dir, 'worker.mjs');
+        // Later tasks finish first, so ordering comes from the pool
+        fs.writeFileSync(script, [
+            "import { parentPort, threadId } from 'worker_threads';",
+            'parentPort.on(\'message\', ({ id, message }) => {',
+            "    if (message === 'boom') return parentPort.postMessage({ id, error: 'boom failed' });",
+            "    if (message === 'who') return parentPort.postMessage({ id, result: threadId });",
+            '    setTimeout(() => parentPort.postMessage({ id, result: message * 2 }), 40 - message * 4);',
+            '});',
+            ''
+        ].join('\n'));
+    });
+
+    afterAll(() => {
+        fs.rmSync(dir, { recursive: true, force: true });
+    });
+
+    test('returns results in input order and stays within its size', async () => {
+        const pool = new WorkerPool(script, { size: 3 });
+        try {
+            expect(await pool.map([1, 2, 3, 4, 5, 6, 7, 8, 9])).toEqual([2, 4, 6, 8, 10, 12, 14, 16, 18]);
+            expect(pool.size).toBe(3);
+            expect(new Set(await pool.broadcast('who')).size).toBe(3);
+        } finally {
+            await pool.close();
+        }
+    });
+
+    test('rejects failed tasks and closed pools', async () => {
+        const pool = new WorkerPool(script, { size: 1 });
+        await expect(pool.run('boom')).rejects.toThrow('boom failed');
+        expect(await pool.run(1)).toBe(2);
+        await pool.close();
+        await expect(pool.run(1)).rejects.toThrow('Worker pool is closed');
+    });
+
+    test('parses --jobs values', () => {
+        expect(parseJobs('4')).toBe(4);
+        expect(parseJobs('auto')).toBeGreaterThan(0);
+        expect(parseJobs('0')).toBeNull();
+        expect(parseJobs('x')).toBeNull();
+        expect(parseJobs(undefined)).toBeNull();
+    });
+});
+
+describe('TokenCalculator parallel analysis', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-parallel-'));
+        for (let i = 0; i < 40; i++) {
+            const file = path.join(root, `pkg${i % 4}`, `file${i}.js`);
+            fs.mkdirSync(path.dirname(file), { recursive: true });
+            fs.writeFileSync(file, `export function f${i}() {\n    return ${'x + '.repeat(i)}1;\n}\n`);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const analyze = async (jobs, options = {}) => {
+        const calculator = new TokenCalculator(root, { methodLevel: true, ...options });
+        const files = calculator.scanDirectory(root);
+        const results = jobs > 1 ? await calculator.analyzeFilesParallel(files, jobs) : calculator.analyzeFiles(files);
+        return { calculator, results };
+    };
+
+    test('matches sequential analysis, including order and stats', async () => {
+        const sequential = await analyze(1);
+        const parallel = await analyze(4);
+
+        expect(parallel.results).toEqual(sequential.results);
+        expect(parallel.calculator.stats).toEqual(sequential.calculator.stats);
+        expect(parallel.calculator.methodStats).toEqual(sequential.calculator.methodStats);
+        expect(parallel.calculator.methodStats.totalMethods).toBe(40);
+    });
+
+    test('reuses and fills the content cache', async () => {
+        const cache = new ContentCache({ root, path: null });
+        await analyze(3, { cache });
+        expect(cache.getStats()).toMatchObject({ hits: 0, misses: 40 });
+
+        await analyze(3, { cache });
+        expect(cache.getStats()).toMatchObject({ hits: 40, misses: 40 });
+    });
+});
@@ -0,0 +1,68 @@
+import { describe, test, expect } from 'vitest';
+import ParseCache from '../lib/cache/ParseCache.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+import WarmIndex from '../lib/daemon/WarmIndex.js';
+import { SymbolExtractor } from '../lib/symbols/SymbolExtractor.js';
+
+const outline = (name, file = 'src/a.js') => [{ name, qualifiedName: name, kind: 'function', file, startLine: 1, endLine: 3 }];
+
+describe('ParseCache', () => {
+    test('evicts the least recently used outlines beyond its limits', () => {
+        const cache = new ParseCache({ maxEntries: 2 });
+        cache.set('a', outline('a'));
+        cache.set('b', outline('b'));
+        expect(cache.get('a', 'src/a.js')).toEqual(outline('a'));
+        cache.set('c', outline('c'));
+
+        expect([...cache.entries.keys()]).toEqual(['a', 'c']);
+        expect(cache.get('b', 'src/b.js')).toBeNull();
+        expect(cache.getStats()).toMatchObject({ entries: 2, hits: 1, misses: 1, evictions: 1, hitRate: 50 });
+
+        const entryBytes = cache.entries.get('a').bytes;
+        const small = new ParseCache({ maxBytes: entryBytes * 2 });
+        for (const name of ['a', 'b', 'c']) small.set(name, outline(name));
+        expect([...small.entries.keys()]).toEqual(['b', 'c']);
+        expect(small.getStats().bytes).toBe(entryBytes * 2);
+
+        // Too large to keep, and a size of 0 keeps nothing
+        expect(new ParseCache({ maxBytes: entryBytes - 1 }).set('a', outline('a'))).toBe(false);
+        expect(new ParseCache({ maxBytes: 0 }).set('a', outline('a'))).toBe(false);
+        expect(ParseCache.describe(cache.getStats())).toBe('Parse cache: 2 outlines, 0.0 MB of 64.0 MB, 50% hits, 1 evicted');
+    });
+
+    test('hands out copies', () => {
+        const cache = new ParseCache();
+        const symbols = outline('parse');
+        cache.set('key', symbols);
+        symbols[0].name = 'changed';
+
+        const first = cache.get('key', 'lib/util.js');
+        first[0].endLine = 99;
+        expect(cache.get('key', 'lib/other.js')).toEqual(outline('parse', 'lib/other.js'));
+    });
+
+    test('holds the outlines of a memory ContentCache', () => {
+        const parseCache = new ParseCache({ maxEntries: 1 });
+        const cache = new ContentCache({ path: null, parseCache });
+        const extractor = new SymbolExtractor({ backend: 'heuristic', registered: false });
+        const files = { 'a.js': 'export function a() {}\n', 'b.js': 'export function b() {}\n' };
+        let parses = 0;
+        const symbols = file => cache.symbols(ContentCache.hash(files[file]), ContentCache.key('heuristic', file), file, () => {
+            parses++;
+            return extractor.extract(files[file], file);
+        });
+
+        expect(symbols('a.js').map(symbol => symbol.name)).toEqual(['a']);
+        expect(symbols('a.js')).toEqual(extractor.extract(files['a.js'], 'a.js'));
+        expect(parses).toBe(1);
+        symbols('b.js');
+        symbols('a.js');
+        expect(parses).toBe(3);
+        expect(cache.entries.size).toBe(0);
+        expect(cache.getStats()).toMatchObject({ hits: 1, misses: 3 });
+
+        const index = new WarmIndex({ root: process.cwd(), parseCache });
+        expect(index.cache.options.parseCache).toBe(parseCache);
+        expect(index.getStats().parseCache).toMatchObject({ entries: 1, evictions: 2 });
+    });
+});
@@ -0,0 +1,114 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import PinRebalancer, { parseRebalanceSteps, REBALANCE_STEPS } from '../lib/core/PinRebalancer.js';
+import PriorityScorer from '../lib/core/PriorityScorer.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const fn = i => [
+    `// Adds ${i} to the total`,
+    '// and logs it',
+    `export function add${i}(total) {`,
+    `    const next = total + ${i};`,
+    `    console.log('added', ${i});`,
+    '    if (next > 100) {',
+    '        return 100;',
+    '    }',
+    '    return next;',
+    '}',
+    ''
+].join('\n');
+const source = Array.from({ length: 8 }, (_, i) => fn(i)).join('\n');
+
+describe('PinRebalancer', () => {
+    test('parses steps into their fixed order', () => {
+        expect(parseRebalanceSteps('chunk,strip')).toEqual(['strip', 'chunk']);
+        expect(parseRebalanceSteps(['ELIDE'])).toEqual(['elide']);
+        expect(() => parseRebalanceSteps('')).toThrow(/expected a list/);
+        expect(() => parseRebalanceSteps('strip,squash')).toThrow(/unknown step squash/);
+    });
+
+    test('stops at the first step that fits', () => {
+        const rebalancer = new PinRebalancer();
+        const count = text => rebalancer.options.countTokens(text, 'src/big.js');
+        const full = count(source);
+        const stripped = rebalancer.rebalance(source, 'src/big.js', full - 10);
+
+        expect(stripped.fits).toBe(true);
+        expect(stripped.fullTokens).toBe(full);
+        expect(stripped.steps.map(step => step.step)).toEqual(['strip']);
+        expect(stripped.content).not.toContain('// Adds');
+        expect(stripped.content).toContain("console.log('added', 0);");
+
+        const elided = rebalancer.rebalance(source, 'src/big.js', stripped.tokens - 10);
+        expect(elided.steps.map(step => step.step)).toEqual(['strip', 'elide']);
+        expect(elided.content).toContain('/* ... 6 lines elided ... */');
+        expect(PinRebalancer.describeSteps(elided.steps)).toBe('comments stripped, 8 bodies elided');
+    });
+
+    test('cuts the first chunk when nothing else fits', () => {
+        const rebalancer = new PinRebalancer();
+        const result = rebalancer.rebalance(source, 'src/big.js', 120);
+
+        expect(result.fits).toBe(true);
+        expect(result.tokens).toBeLessThanOrEqual(120);
+        expect(result.steps.map(step => step.step)).toEqual(['strip', 'elide', 'chunk']);
+        expect(result.steps[2].detail).toMatch(/^lines 1-\d+ \(part 1 of \d+\)$/);
+        expect(result.content.startsWith('export function add0(total) {')).toBe(true);
+        expect(PinRebalancer.formatReport([{ file: 'src/big.js', limit: 120, ...result }]))
+            .toMatch(/src\/big\.js: [\d,]+ tokens → strip [\d,]+ → elide [\d,]+ → chunk [\d,]+/);
+    });
+
+    test('reports files no step fits', () => {
+        const result = new PinRebalancer({ steps: ['strip'] }).rebalance(source, 'src/big.js', 10);
+        expect(result.fits).toBe(false);
+        expect(result.steps.map(step => step.step)).toEqual(['strip']);
+        expect(PinRebalancer.formatReport([{ file: 'src/big.js', limit: 10, ...result }])).toContain('over a 10-token budget; dropped');
+        expect(new PinRebalancer().rebalance('x', 'a.txt', 10).steps).toEqual([]);
+        expect(REBALANCE_STEPS).toEqual(['strip', 'elide', 'chunk']);
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-rebalance-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src/big.js'), source);
+            fs.writeFileSync(path.join(root, 'src/small.js'), 'export const one = 1;\n');
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        async function exportWith(options) {
+            const tokenBudget = await TokenBudget.create({ maxTokens: 250, tokenizer: 'estimate', tiers: ['full'] });
+            const calculator = new TokenCalculator(root, {
+                tokenBudget,
+                priorityScorer: new PriorityScorer({ pins: ['src/big.js'] }),
+                ...options
+            });
+            const exported = calculator.selectExportResults(calculator.analyzeFiles(calculator.scanProject()));
+            return { calculator, byPath: new Map(exported.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo])) };
+        }
+
+        test('a pinned file larger than the budget is dropped without rebalancing', async () => {
+            const { byPath } = await exportWith({});
+            expect([...byPath.keys()]).toEqual(['src/small.js']);
+        });
+
+        test('rebalanced pins are packed degraded, with a note', async () => {
+            const { calculator, byPath } = await exportWith({ pinRebalancer: new PinRebalancer() });
+            const big = byPath.get('src/big.js');
+
+            expect(big.selectionNote).toBe('Pinned; comments stripped, 8 bodies elided to fit token budget');
+            expect(big.summary).toContain('/* ... 6 lines elided ... */');
+            expect(big.tokens).toBeLessThanOrEqual(250);
+            expect(calculator.pinRebalancing.map(entry => [entry.file, entry.fits])).toEqual([['src/big.js', true]]);
+            expect(byPath.has('src/small.js')).toBe(true);
+        });
+    });
+});
@@ -0,0 +1,114 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { execSync } from 'child_process';
+import PriorityScorer, { DEFAULT_WEIGHTS } from '../lib/core/PriorityScorer.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    'src/core/parser.js': "export function parse(text) {\n    return text.split('\\n');\n}\n",
+    'src/cli.js': "import { parse } from './core/parser.js';\n\nexport function run(text) {\n    return parse(text);\n}\n",
+    'src/server.js': "import { parse } from './core/parser.js';\n\nexport const serve = text => parse(text);\n",
+    'docs/examples/usage.js': "import { run } from '../../src/cli.js';\n\nrun('a\\nb');\n"
+};
+
+describe('PriorityScorer', () => {
+    const files = [
+        { relativePath: 'src/core/parser.js', tokens: 400 },
+        { relativePath: 'src/cli.js', tokens: 100 },
+        { relativePath: 'docs/examples/usage.js', tokens: 50 }
+    ];
+
+    test('parses weights', () => {
+        expect(PriorityScorer.parseWeights('churn=2, fan-in=1.5,size=0')).toEqual({ churn: 2, fanIn: 1.5, size: 0 });
+        expect(PriorityScorer.parseWeights({ FANIN: 3 })).toEqual({ fanIn: 3 });
+        expect(() => PriorityScorer.parseWeights('age=1')).toThrow('Unknown priority signal: age');
+        expect(() => PriorityScorer.parseWeights('churn=lots')).toThrow('Invalid weight for churn: lots');
+    });
+
+    test('combines normalized signals with their weights', () => {
+        const scorer = new PriorityScorer({ weights: { depth: 0, size: 0 } });
+        const scores = scorer.score(files, {
+            churn: new Map([['src/cli.js', 4], ['src/core/parser.js', 2]]),
+            fanIn: new Map([['src/core/parser.js', 2], ['src/cli.js', 1]])
+        });
+
+        expect(scores.get('src/core/parser.js')).toMatchObject({
+            score: 25,
+            signals: {
+                path: { raw: 18, value: 1, weight: 1, points: 10 },
+                churn: { raw: 2, value: 0.5, points: 5 },
+                fanIn: { raw: 2, value: 1, points: 10 },
+                depth: { weight: 0, points: 0 }
+            }
+        });
+        expect(scores.get('src/cli.js').score).toBe(21.2);
+        expect(scores.get('docs/examples/usage.js').score).toBe(0);
+        expect(scorer.uses('depth')).toBe(false);
+    });
+
+    test('ranks pinned files first', () => {
+        const scores = new PriorityScorer({ pins: ['docs/**'] }).score(files);
+        const ranked = [...scores].sort((a, b) => b[1].score - a[1].score).map(([file]) => file);
+
+        expect(ranked[0]).toBe('docs/examples/usage.js');
+        expect(scores.get('docs/examples/usage.js').signals.pins).toMatchObject({ raw: 'docs/**', points: DEFAULT_WEIGHTS.pins * 10 });
+    });
+
+    test('explains scores', () => {
+        const scores = new PriorityScorer({ weights: { churn: 0, fanIn: 0 } }).score(files);
+        const prioritized = files.map(fileInfo => ({ ...fileInfo, priority: scores.get(fileInfo.relativePath).score }));
+        const report = PriorityScorer.formatExplanation(prioritized, scores);
+
+        expect(report).toContain('🧮 PRIORITY SCORES');
+        expect(report).toContain('src/cli.js\n            path 6.20 (heuristic 10, ×1) · depth 5.00 (1 dir deep, ×0.5)');
+        expect(report).not.toContain('churn');
+    });
+});
+
+describe('TokenCalculator priority scoring', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-scores-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        const git = command => execSync(`git -c user.name=test -c user.email=test@example.com ${command}`, { cwd: root, stdio: 'pipe' });
+        git('init -q');
+        git('add .');
+        git('commit -q -m initial');
+        fs.appendFileSync(path.join(root, 'src/server.js'), '\nexport const port = 8080;\n');
+        git('commit -q -am "Add port"');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const select = (scorer, options = {}) => {
+        const calculator = new TokenCalculator(root, { dashboard: true, priorityScorer: scorer, ...options });
+        const results = Object.keys(FILES).map(file => calculator.analyzeFile(path.join(root, file)));
+        return { calculator, selected: calculator.selectExportResults(results) };
+    };
+
+    test('collects churn and fan-in from the project', () => {
+        const { calculator, selected } = select(new PriorityScorer());
+        const signals = file => calculator.priorityScores.get(file).signals;
+
+        expect(signals('src/core/parser.js').fanIn.raw).toBe(2);
+        expect(signals('src/cli.js').fanIn.raw).toBe(1);
+        expect(signals('src/server.js').churn.raw).toBe(2);
+        expect(signals('src/cli.js').churn.raw).toBe(1);
+        expect(selected.every(fileInfo => fileInfo.priority === calculator.priorityScores.get(fileInfo.relativePath).score)).toBe(true);
+    });
+
+    test('keeps priorities set by a profile rule', () => {
+        const profile = { include: [], exclude: [], priority: [{ pattern: 'docs/**', priority: 500 }] };
+        const { selected } = select(new PriorityScorer({ weights: { churn: 0, fanIn: 0 } }), { profile });
+
+        expect(selected.find(fileInfo => fileInfo.relativePath === path.join('docs', 'examples', 'usage.js')).priority).toBe(500);
+    });
+});
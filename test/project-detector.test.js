@@ -0,0 +1,171 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ProjectDetector from '../lib/core/ProjectDetector.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+// read() over an in-memory tree, throwing like fs for missing files
+const reader = files => file => {
+    if (!(file in files)) throw new Error(`ENOENT: ${file}`);
+    return files[file];
+};
+
+const detect = (files, options) => new ProjectDetector(options).detect(Object.keys(files), reader(files));
+
+describe('ProjectDetector', () => {
+    test('finds Go modules and the modules they require', () => {
+        const projects = detect({
+            'go.work': 'go 1.22\nuse ./services/api\nuse ./pkg/util\n',
+            'services/api/go.mod': 'module example.com/api\n\ngo 1.22\n\nrequire example.com/util v0.1.0\n\nreplace example.com/util => ../../pkg/util\n',
+            'services/api/main.go': 'package main\n',
+            'services/api/handlers/users.go': 'package handlers\n',
+            'pkg/util/go.mod': 'module example.com/util\n\ngo 1.22\n',
+            'pkg/util/strings.go': 'package util\n'
+        });
+
+        expect(projects.map(project => [project.name, project.kind, project.dir])).toEqual([
+            ['(root)', 'files', ''],
+            ['example.com/util', 'go module', 'pkg/util'],
+            ['example.com/api', 'go module', 'services/api']
+        ]);
+        const api = projects.find(project => project.dir === 'services/api');
+        expect(api.files).toEqual(['services/api/go.mod', 'services/api/main.go', 'services/api/handlers/users.go']);
+        expect(api.dependsOn).toEqual(['example.com/util']);
+        expect(projects[0].files).toEqual(['go.work']);
+        expect(ProjectDetector.slug(api)).toBe('services-api');
+        expect(ProjectDetector.slug(projects[0])).toBe('root');
+    });
+
+    test('limits npm packages to the root workspaces', () => {
+        const projects = detect({
+            'package.json': JSON.stringify({ name: 'monorepo', private: true, workspaces: ['packages/*', '!packages/legacy'] }),
+            'packages/web/package.json': JSON.stringify({ name: '@acme/web', dependencies: { '@acme/ui': '*', react: '^18.0.0' } }),
+            'packages/web/index.js': 'export {};\n',
+            'packages/ui/package.json': JSON.stringify({ name: '@acme/ui' }),
+            'packages/ui/button.js': 'export {};\n',
+            'packages/legacy/package.json': JSON.stringify({ name: '@acme/legacy' }),
+            'packages/legacy/old.js': 'module.exports = {};\n',
+            'tools/fixtures/package.json': JSON.stringify({ name: 'fixture' })
+        });
+
+        expect(projects.map(project => project.name)).toEqual(['monorepo', '@acme/ui', '@acme/web']);
+        expect(projects.find(project => project.name === '@acme/web').dependsOn).toEqual(['@acme/ui']);
+        expect(projects[0].files).toEqual(['package.json', 'packages/legacy/package.json', 'packages/legacy/old.js', 'tools/fixtures/package.json']);
+    });
+
+    test('finds Bazel packages in a Bazel workspace only', () => {
+        const files = {
+            'WORKSPACE': 'workspace(name = "acme")\n',
+            'server/BUILD.bazel': 'go_binary(\n    name = "server",\n    deps = ["//lib/auth:auth", "@com_github_x//:x"],\n)\n',
+            'server/main.go': 'package main\n',
+            'lib/auth/BUILD': 'go_library(name = "auth")\n',
+            'lib/auth/auth.go': 'package auth\n'
+        };
+        const projects = detect(files);
+
+        expect(projects.map(project => [project.name, project.kind])).toEqual([
+            ['(root)', 'files'],
+            ['//lib/auth', 'bazel package'],
+            ['//server', 'bazel package']
+        ]);
+        expect(projects[2].dependsOn).toEqual(['//lib/auth']);
+        expect(projects[2].manifest).toBe('server/BUILD.bazel');
+
+        const { WORKSPACE, ...withoutWorkspace } = files;
+        expect(detect(withoutWorkspace).map(project => project.name)).toEqual(['(root)']);
+        expect(detect(withoutWorkspace, { bazel: true }).map(project => project.name)).toEqual(['(root)', '//lib/auth', '//server']);
+    });
+
+    test('puts every file in the root project without markers', () => {
+        const projects = detect({ 'a.js': '', 'src/b.js': '' });
+        expect(projects).toEqual([{ name: '(root)', kind: 'files', dir: '', manifest: null, files: ['a.js', 'src/b.js'], dependsOn: [] }]);
+    });
+
+    test('formats the overview with the current project marked', () => {
+        const projects = detect({
+            'services/api/go.mod': 'module example.com/api\n\nrequire example.com/util v0.1.0\n',
+            'services/api/main.go': 'package main\n',
+            'pkg/util/go.mod': 'module example.com/util\n'
+        });
+        projects.forEach((project, index) => { project.tokens = 1000 * (index + 1); });
+        const [, util, api] = projects;
+        const overview = ProjectDetector.formatOverview(projects, api);
+
+        expect(overview).toBe([
+            '='.repeat(48),
+            'PROJECTS',
+            '='.repeat(48),
+            'This bundle: example.com/api (services/api)',
+            '',
+            '- example.com/util (go module, pkg/util): 1 file, 2.0K tokens',
+            '* example.com/api (go module, services/api): 2 files, 3.0K tokens; uses example.com/util',
+            ''
+        ].join('\n'));
+        expect(ProjectDetector.formatProjects(projects)).toContain('🏗️  PROJECTS');
+        expect(ProjectDetector.formatProjects([])).toContain('No files to bundle');
+        expect(ProjectDetector.projectOf(projects, 'pkg/utility/x.go')).toBe(projects[0]);
+        expect(ProjectDetector.projectOf(projects, 'pkg/util/x.go')).toBe(util);
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-projects-'));
+            const files = {
+                'README.md': '# Monorepo\n',
+                'services/api/go.mod': 'module example.com/api\n\ngo 1.22\n\nrequire example.com/util v0.1.0\n',
+                'services/api/main.go': 'package main\n\nfunc main() {}\n',
+                'pkg/util/go.mod': 'module example.com/util\n\ngo 1.22\n',
+                'pkg/util/strings.go': 'package util\n\nfunc Upper(s string) string { return s }\n'
+            };
+            for (const [name, content] of Object.entries(files)) {
+                fs.mkdirSync(path.dirname(path.join(root, name)), { recursive: true });
+                fs.writeFileSync(path.join(root, name), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('writes one digest per project with the shared overview', () => {
+            const calculator = new TokenCalculator(root, { gitingest: true, projectDetector: new ProjectDetector() });
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            calculator.saveProjectBundles(results);
+
+            const api = fs.readFileSync(path.join(root, 'digest-services-api.txt'), 'utf8');
+            expect(api).toContain('FILE: services/api/main.go');
+            expect(api).not.toContain('FILE: pkg/util/strings.go');
+            expect(api).toContain('This bundle: example.com/api (services/api)');
+            expect(api).toContain('* example.com/api (go module, services/api): 1 file');
+            expect(api).toContain('- example.com/util (go module, pkg/util): 1 file');
+            expect(api).toContain('Files analyzed: 1');
+            expect(api).toContain('uses example.com/util\n\nDirectory structure:');
+
+            const rootDigest = fs.readFileSync(path.join(root, 'digest-root.txt'), 'utf8');
+            expect(rootDigest).toContain('FILE: README.md');
+            expect(fs.existsSync(path.join(root, 'digest-pkg-util.txt'))).toBe(true);
+            expect(fs.existsSync(path.join(root, 'digest.txt'))).toBe(false);
+            for (const file of fs.readdirSync(root).filter(name => name.startsWith('digest-'))) {
+                fs.rmSync(path.join(root, file));
+            }
+        });
+
+        test('structured bundles list the projects', () => {
+            const calculator = new TokenCalculator(root, { structuredFormat: 'json', projectDetector: new ProjectDetector() });
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            calculator.saveProjectBundles(results);
+
+            const context = JSON.parse(fs.readFileSync(path.join(root, 'context-pkg-util.json'), 'utf8'));
+            expect(context.files.map(file => file.path)).toEqual(['pkg/util/strings.go']);
+            expect(context.projects.map(project => [project.name, project.dir, project.current])).toEqual([
+                ['(root)', '.', false],
+                ['example.com/util', 'pkg/util', true],
+                ['example.com/api', 'services/api', false]
+            ]);
+            expect(context.projects[2].dependsOn).toEqual(['example.com/util']);
+        });
+    });
+});
@@ -0,0 +1,83 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ProjectDocs, { DocKind } from '../lib/core/ProjectDocs.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('ProjectDocs', () => {
+    const files = {
+        'README.md': '# App\n\n[![CI](badge.svg)](ci)\n\nThe app serves things.\n\n## Usage\n\nRun it.\n\n## License\n\nMIT\n',
+        'ARCHITECTURE.md': '# Architecture\n\nThree layers.\n',
+        'src/auth/README.md': '# Auth\n\nLogin flows.\n',
+        'src/auth/login.js': 'export const login = () => 1;\n',
+        'pkg/store/doc.go': '// Package store caches values.\n// It never blocks.\n//\n// Details follow.\npackage store\n',
+        'pkg/store/store.go': 'package store\n\nfunc Get() {}\n',
+        'docs/adr/0001-cache.md': '# ADR 1: Cache\n\n## Status\n\nAccepted\n\n## Decision\n\nKeep the cache in `pkg/store/`.\n'
+    };
+
+    test('recognizes docs and orders the overview first', () => {
+        expect(ProjectDocs.kindOf('README.md')).toBe(DocKind.README);
+        expect(ProjectDocs.kindOf('docs/ARCHITECTURE.md')).toBe(DocKind.ARCHITECTURE);
+        expect(ProjectDocs.kindOf('pkg/store/doc.go')).toBe(DocKind.PACKAGE);
+        expect(ProjectDocs.kindOf('docs/decisions/use-go.md')).toBe(DocKind.ADR);
+        expect(ProjectDocs.kindOf('docs/adr-012-queues.md')).toBe(DocKind.ADR);
+        expect(ProjectDocs.kindOf('docs/guide.md')).toBe(null);
+
+        const docs = new ProjectDocs(Object.keys(files));
+        expect(docs.docs.map(doc => doc.path)).toEqual([
+            'README.md', 'ARCHITECTURE.md', 'src/auth/README.md', 'pkg/store/doc.go', 'docs/adr/0001-cache.md'
+        ]);
+        expect(docs.overview()).toEqual(['README.md', 'ARCHITECTURE.md']);
+        expect(docs.rankOf('src/auth/login.js')).toBe(-1);
+    });
+
+    test('links code to its package doc, README and ADRs', () => {
+        const docs = new ProjectDocs(Object.keys(files), { read: file => files[file] });
+        expect(docs.linksOf('pkg/store/store.go')).toEqual(['pkg/store/doc.go', 'docs/adr/0001-cache.md']);
+        expect(docs.linksOf('src/auth/login.js')).toEqual(['src/auth/README.md']);
+        expect(docs.linksOf('README.md')).toEqual([]);
+    });
+
+    test('summarizes docs', () => {
+        expect(ProjectDocs.summarize(files['README.md'], 'README.md'))
+            .toBe('# App\n\nThe app serves things.\n\nSections: Usage · License\n');
+        expect(ProjectDocs.summarize(files['docs/adr/0001-cache.md'], 'docs/adr/0001-cache.md'))
+            .toContain('Status: Accepted\n\nDecision: Keep the cache in `pkg/store/`.');
+        expect(ProjectDocs.summarize(files['pkg/store/doc.go'], 'pkg/store/doc.go'))
+            .toBe('// Package store caches values. It never blocks.\npackage store\n');
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-docs-'));
+            for (const [file, content] of Object.entries(files)) {
+                fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+                fs.writeFileSync(path.join(root, file), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('adds the docs of a selection ahead of its code', () => {
+            const calculator = new TokenCalculator(root, { docs: 'summary' });
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            const selection = results.filter(fileInfo => fileInfo.relativePath.endsWith('store.go'));
+            const exported = calculator.applyDocumentation(selection, results);
+            const byPath = new Map(exported.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+
+            expect([...byPath.keys()].sort()).toEqual([
+                'ARCHITECTURE.md', 'README.md', 'docs/adr/0001-cache.md', 'pkg/store/doc.go', 'pkg/store/store.go'
+            ]);
+            expect(byPath.get('pkg/store/store.go').docLinks).toEqual(['pkg/store/doc.go', 'docs/adr/0001-cache.md']);
+            expect(byPath.get('pkg/store/store.go').documentation).toBeUndefined();
+            expect(byPath.get('README.md').priority).toBeGreaterThan(byPath.get('ARCHITECTURE.md').priority);
+            expect(byPath.get('README.md').summary).toBe('# App\n\nThe app serves things.\n\nSections: Usage · License\n');
+            expect(byPath.get('README.md').selectionNote).toBe('Documentation summary');
+        });
+    });
+});
@@ -0,0 +1,102 @@
+import { describe, test, expect } from 'vitest';
+import PullRequest, { parsePullRequestRef } from '../lib/integrations/git/PullRequest.js';
+
+// Answers API requests from a table of URL => JSON, 404 otherwise
+const fakeFetch = responses => {
+    const requests = [];
+    const fetch = async (url, options) => {
+        requests.push({ url, headers: options.headers });
+        return url in responses
+            ? { ok: true, json: async () => responses[url] }
+            : { ok: false, status: 404, text: async () => 'Not Found' };
+    };
+    return { fetch, requests };
+};
+
+const patch = [
+    '@@ -10,5 +10,6 @@ export class Store {',
+    '     get(key) {',
+    '-        return this.map[key];',
+    '+        const value = this.map.get(key);',
+    '+        return value ?? null;',
+    '     }',
+    ' ',
+    '@@ -30,3 +31,2 @@',
+    '     load() {',
+    '-        this.reset();',
+    '     }'
+].join('\n');
+
+describe('PullRequest', () => {
+    test('resolves URLs and numbers of the origin remote', () => {
+        expect(parsePullRequestRef('https://github.com/acme/app/pull/12/files')).toEqual({
+            provider: 'github', host: 'github.com', project: 'acme/app', number: 12, apiBase: 'https://api.github.com'
+        });
+        expect(parsePullRequestRef('https://gitlab.example.com/acme/platform/app/-/merge_requests/7')).toMatchObject({
+            provider: 'gitlab', project: 'acme/platform/app', number: 7, apiBase: 'https://gitlab.example.com/api/v4'
+        });
+        expect(parsePullRequestRef('#12', { remoteUrl: 'git@github.example.com:acme/app.git' })).toMatchObject({
+            provider: 'github', project: 'acme/app', apiBase: 'https://github.example.com/api/v3'
+        });
+        expect(parsePullRequestRef('!7', { remoteUrl: 'https://git.example.com/acme/app.git', provider: 'gitlab' }))
+            .toMatchObject({ provider: 'gitlab', host: 'git.example.com', project: 'acme/app' });
+        expect(() => parsePullRequestRef('12')).toThrow('no origin remote');
+        expect(() => parsePullRequestRef('main')).toThrow('Not a pull request URL or number: main');
+    });
+
+    test('fetches a GitHub pull request with its files and comments', async () => {
+        const base = 'https://api.github.com/repos/acme/app';
+        const { fetch, requests } = fakeFetch({
+            [`${base}/pulls/12`]: {
+                html_url: 'https://github.com/acme/app/pull/12', title: 'Use a Map', body: 'Faster lookups.',
+                user: { login: 'dev' }, base: { ref: 'main' }, head: { ref: 'map', sha: 'abc123' }
+            },
+            [`${base}/pulls/12/files?per_page=100&page=1`]: [
+                { filename: 'src/store.js', status: 'modified', additions: 2, deletions: 2, patch },
+                { filename: 'src/old.js', status: 'removed', additions: 0, deletions: 4 }
+            ],
+            [`${base}/pulls/12/comments?per_page=100&page=1`]: [{ path: 'src/store.js', line: 12, user: { login: 'rev' }, body: 'Why null?' }],
+            [`${base}/issues/12/comments?per_page=100&page=1`]: [{ user: { login: 'bot' }, body: 'CI passed' }]
+        });
+
+        const pr = await PullRequest.fetch(parsePullRequestRef('https://github.com/acme/app/pull/12'), { fetch, token: 'secret' });
+        expect(requests[0].headers.Authorization).toBe('Bearer secret');
+        expect(pr.changedPaths()).toEqual(['src/store.js']);
+        expect(pr.lineChanges()).toEqual([
+            { path: 'src/store.js', oldPath: null, status: 'modified', ranges: [[11, 12], [32, 32]], added: 2, deleted: 2 },
+            { path: 'src/old.js', oldPath: null, status: 'deleted', ranges: null, added: 0, deleted: 4 }
+        ]);
+
+        const prompt = pr.formatPrompt('FILE: src/store.js\n...\n');
+        expect(prompt).toContain('# Review #12: Use a Map\n');
+        expect(prompt).toContain('Author: @dev · map → main · 2 files changed (+2 −6)');
+        expect(prompt).toContain('### `src/store.js` line 12\n\n**@rev**: Why null?');
+        expect(prompt).toContain('## Discussion\n\n**@bot**: CI passed');
+        expect(prompt).toContain('### `src/old.js`\n\n_No textual diff (binary or too large)._');
+        expect(prompt).toContain('## Code context');
+    });
+
+    test('fetches a GitLab merge request with its discussions', async () => {
+        const base = 'https://gitlab.com/api/v4/projects/acme%2Fapp/merge_requests/7';
+        const { fetch, requests } = fakeFetch({
+            [base]: {
+                web_url: 'https://gitlab.com/acme/app/-/merge_requests/7', title: 'Rename store',
+                description: '', author: { username: 'dev' }, source_branch: 'rename', target_branch: 'main'
+            },
+            [`${base}/diffs?per_page=100&page=1`]: [{ old_path: 'store.js', new_path: 'cache.js', renamed_file: true, diff: '@@ -1 +1 @@\n-a\n+b\n' }],
+            [`${base}/discussions?per_page=100&page=1`]: [
+                { notes: [{ system: true, body: 'added 1 commit', author: { username: 'dev' } }] },
+                { notes: [{ body: 'Keep the old name?', author: { username: 'rev' }, position: { new_path: 'cache.js', new_line: 1 } }] }
+            ]
+        });
+
+        const pr = await PullRequest.fetch(parsePullRequestRef('https://gitlab.com/acme/app/-/merge_requests/7'), { fetch, token: 'secret' });
+        expect(requests[0].headers['PRIVATE-TOKEN']).toBe('secret');
+        expect(pr.files).toEqual([{ path: 'cache.js', previousPath: 'store.js', status: 'renamed', additions: 1, deletions: 1, patch: '@@ -1 +1 @@\n-a\n+b\n' }]);
+        expect(pr.comments).toEqual([{ path: 'cache.js', line: 1, author: 'rev', body: 'Keep the old name?' }]);
+        expect(pr.formatPrompt()).toContain('# Review !7: Rename store\n');
+
+        await expect(PullRequest.fetch(parsePullRequestRef('https://gitlab.com/acme/app/-/merge_requests/8'), { fetch, token: '' }))
+            .rejects.toThrow('GitLab returned 404 for projects/acme%2Fapp/merge_requests/8: Not Found (private projects need GITLAB_TOKEN)');
+    });
+});
@@ -0,0 +1,91 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { execSync } from 'child_process';
+import RelatedFiles from '../lib/graph/RelatedFiles.js';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    'src/session.js': 'export class Session {\n    constructor(token) {\n        this.token = token;\n    }\n}\n',
+    'src/auth.js': "import { Session } from './session.js';\n\nexport function login(token) {\n    return new Session(token);\n}\n",
+    'src/routes.js': "import { login } from './auth.js';\n\nexport const routes = { login };\n",
+    'src/server.js': "import { routes } from './routes.js';\n\nexport const app = routes;\n",
+    'src/unrelated.js': 'export const answer = 42;\n',
+    'CHANGELOG.md': '# Changelog\n'
+};
+
+describe('RelatedFiles', () => {
+    const graph = new DependencyGraph({ root: '/project' }).build(Object.entries(FILES)
+        .filter(([file]) => file.endsWith('.js'))
+        .map(([relativePath, content]) => ({ relativePath, content })));
+    const files = Object.keys(FILES);
+
+    test('ranks files by shared names and import distance', () => {
+        const suggestions = new RelatedFiles().suggest(['src/auth.js'], { files, graph });
+
+        expect(suggestions.map(suggestion => [suggestion.path, suggestion.score])).toEqual([
+            ['src/routes.js', 20],
+            ['src/session.js', 20],
+            ['src/server.js', 5]
+        ]);
+        expect(suggestions[0].reasons).toEqual(['uses login of the seeds', 'imports src/auth.js']);
+        expect(suggestions[1].reasons).toEqual(['defines Session used by the seeds', 'imported by src/auth.js']);
+        expect(suggestions[2].reasons).toEqual(['2 imports from src/auth.js']);
+    });
+
+    test('weighs commits shared with the seeds', () => {
+        const coChanges = new Map([
+            ['CHANGELOG.md', { commits: 4, files: new Set(['src/auth.js']) }],
+            ['src/server.js', { commits: 1, files: new Set(['src/auth.js']) }]
+        ]);
+        const related = new RelatedFiles({ weights: { identifiers: 0, imports: 0 } });
+        const suggestions = related.suggest(['src/auth.js'], { files, coChanges });
+
+        expect(suggestions.map(suggestion => [suggestion.path, suggestion.score])).toEqual([['CHANGELOG.md', 10], ['src/server.js', 2.5]]);
+        expect(suggestions[0].signals.coChange).toEqual({ raw: 4, value: 1, weight: 1, points: 10 });
+        expect(suggestions[0].reasons).toEqual(['changed with src/auth.js in 4 commits']);
+
+        const report = RelatedFiles.formatSuggestions(suggestions, { seeds: 1, limit: 10, accepted: 1 });
+        expect(report).toContain('🔗 RELATED FILES (1 seed)');
+        expect(report).toContain('  +  1.  10.00  CHANGELOG.md');
+        expect(report).toContain('✅ Added the top 1 (+) to the context');
+    });
+});
+
+describe('TokenCalculator related files', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-related-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        const git = command => execSync(`git -c user.name=test -c user.email=test@example.com ${command}`, { cwd: root, stdio: 'pipe' });
+        git('init -q');
+        git('add .');
+        git('commit -q -m initial');
+        fs.appendFileSync(path.join(root, 'src/auth.js'), '\nexport const logout = () => null;\n');
+        fs.appendFileSync(path.join(root, 'CHANGELOG.md'), '- Add logout\n');
+        git('commit -q -am "Add logout"');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('adds accepted suggestions to the file list', () => {
+        const calculator = new TokenCalculator(root, {
+            dashboard: true,
+            fileList: ['src/auth.js'],
+            relatedFiles: new RelatedFiles({ accept: 2 })
+        });
+        const scanned = calculator.scanProject().map(file => path.relative(root, file).split(path.sep).join('/'));
+
+        expect(scanned).toEqual(['src/auth.js', 'src/routes.js', 'src/session.js']);
+        expect(calculator.relatedSuggestions.find(suggestion => suggestion.path === 'CHANGELOG.md').signals.coChange.raw).toBe(2);
+        expect(calculator.relatedSuggestions.some(suggestion => suggestion.path === 'src/unrelated.js')).toBe(true);
+    });
+});
@@ -0,0 +1,107 @@
+import { describe, test, expect } from 'vitest';
+import RemoteSummarizer from '../lib/core/RemoteSummarizer.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+
+// Answers chat completions with the queued statuses first, then summaries
+const fakeFetch = (statuses = []) => {
+    const requests = [];
+    const fetch = async (url, options) => {
+        const body = JSON.parse(options.body);
+        requests.push({ url, headers: options.headers, body });
+        const status = statuses.shift() || 200;
+        if (status !== 200) {
+            return { ok: false, status, headers: new Map([['retry-after', '2']]), text: async () => 'Too Many Requests' };
+        }
+        const file = body.messages[1].content.split('\n')[0];
+        return {
+            ok: true,
+            json: async () => ({
+                choices: [{ message: { content: ` Summary of ${file}. ` } }],
+                usage: { prompt_tokens: 1000, completion_tokens: 50 }
+            })
+        };
+    };
+    return { fetch, requests };
+};
+
+const files = [
+    { id: 'src/a.js', content: 'export const a = 1;\n' },
+    { id: 'src/b.js', content: 'export const b = 2;\n' }
+];
+
+describe('RemoteSummarizer', () => {
+    test('summarizes files and caches them by content hash', async () => {
+        const cache = new ContentCache({ path: null });
+        const { fetch, requests } = fakeFetch();
+        const summarizer = new RemoteSummarizer({ baseUrl: 'http://localhost:1234/v1/', apiKey: 'key', cache, fetch });
+
+        const summaries = await summarizer.summarizeAll(files);
+        expect(summaries).toEqual(new Map([['src/a.js', 'Summary of File: src/a.js.'], ['src/b.js', 'Summary of File: src/b.js.']]));
+        expect(requests[0].url).toBe('http://localhost:1234/v1/chat/completions');
+        expect(requests[0].headers.Authorization).toBe('Bearer key');
+        expect(requests[0].body.model).toBe('gpt-4o-mini');
+
+        const again = new RemoteSummarizer({ baseUrl: 'http://localhost:1234/v1', cache, fetch });
+        expect((await again.summarizeAll(files)).get('src/b.js')).toBe('Summary of File: src/b.js.');
+        expect(again.stats).toMatchObject({ files: 2, cached: 2, requested: 0 });
+        expect(requests).toHaveLength(2);
+    });
+
+    test('retries rate limits and reports usage and cost', async () => {
+        const delays = [];
+        const { fetch } = fakeFetch([429, 503]);
+        const summarizer = new RemoteSummarizer({
+            apiKey: 'key', concurrency: 1, cache: new ContentCache({ path: null }), fetch,
+            sleep: async ms => { delays.push(ms); }
+        });
+
+        const summaries = await summarizer.summarizeAll(files);
+        expect(summaries.size).toBe(2);
+        expect(delays).toEqual([2000, 2000]);
+        expect(summarizer.stats).toMatchObject({ requested: 2, retries: 2, failed: 0, inputTokens: 2000, outputTokens: 100 });
+        expect(summarizer.cost()).toBeCloseTo(0.00036);
+
+        const report = RemoteSummarizer.formatReport(summarizer, 500);
+        expect(report).toContain('2 summarized (0 cached, 2 requested, 2 retries)');
+        expect(report).toContain('2,000 prompt + 100 completion tokens, $0.0004');
+    });
+
+    test('summarizes large files by chunk and resends only changed chunks', async () => {
+        const cache = new ContentCache({ path: null });
+        const { fetch, requests } = fakeFetch();
+        const lines = Array.from({ length: 2000 }, (_, i) => `export const value${i} = ${i * 7} + offset;\n`);
+        const large = { id: 'src/large.js', content: lines.join('') };
+
+        const summarizer = new RemoteSummarizer({ apiKey: 'key', cache, fetch });
+        const summary = (await summarizer.summarizeAll([large])).get('src/large.js');
+        const { chunks } = summarizer.stats;
+        expect(chunks).toBeGreaterThan(3);
+        expect(summary).toBe('Summary of File: src/large.js.');
+        expect(requests).toHaveLength(chunks + 1);
+        expect(requests[0].body.messages[1].content).toMatch(/^File: src\/large\.js \(part 1 of \d+\)\n\nexport const value0/);
+        expect(requests[chunks].body.messages[1].content).toMatch(/^File: src\/large\.js\n\n1\. Summary of File: src\/large\.js \(part 1 of/);
+
+        lines[1000] = 'export const changed = true;\n';
+        const edited = new RemoteSummarizer({ apiKey: 'key', cache, fetch });
+        await edited.summarizeAll([{ ...large, content: lines.join('') }]);
+        expect(edited.stats.chunks - edited.stats.chunksCached).toBeLessThanOrEqual(2);
+        expect(requests).toHaveLength(chunks + 1 + edited.stats.chunks - edited.stats.chunksCached + 1);
+        expect(RemoteSummarizer.formatReport(edited)).toContain(`Chunks:    ${edited.stats.chunks} of large files (${edited.stats.chunksCached} cached`);
+    });
+
+    test('leaves out files whose requests fail', async () => {
+        const { fetch, requests } = fakeFetch([400]);
+        const summarizer = new RemoteSummarizer({
+            model: 'local-model', baseUrl: 'http://localhost:8080/v1', concurrency: 1,
+            cache: new ContentCache({ path: null }), fetch
+        });
+
+        const summaries = await summarizer.summarizeAll(files);
+        expect([...summaries.keys()]).toEqual(['src/b.js']);
+        expect(requests).toHaveLength(2);
+        expect(summarizer.stats.failed).toBe(1);
+        expect(summarizer.cost()).toBeNull();
+        expect(summarizer.missingKey()).toBe(false);
+        expect(new RemoteSummarizer({ apiKey: '', baseUrl: 'https://api.openai.com/v1' }).missingKey()).toBe(true);
+    });
+});
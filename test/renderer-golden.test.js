@@ -0,0 +1,110 @@
+import { describe, test, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { fileURLToPath } from 'url';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import TokenUtils from '../lib/utils/token-utils.js';
+import FormatRegistry from '../lib/formatters/format-registry.js';
+import ContextLayout from '../lib/core/ContextLayout.js';
+import { parseXmlTags } from '../lib/formatters/xml-formatter.js';
+import { expectGolden } from './golden.js';
+
+const TEST_DIR = path.dirname(fileURLToPath(import.meta.url));
+
+// Fixture repository: the sample sources of test/ under project paths
+const FIXTURE = {
+    'README.md': '# Calculator\n\nSample project for the renderer golden files.\n',
+    'cmd/calc/main.go': fs.readFileSync(path.join(TEST_DIR, 'sample.go'), 'utf8'),
+    'cmd/calc/main_test.go': 'package main\n\nimport "testing"\n\nfunc TestAdd(t *testing.T) {\n\tc := NewCalculator()\n\tc.Add(2)\n\tif c.GetValue() != 2 {\n\t\tt.Fatal(c.GetValue())\n\t}\n}\n',
+    'scripts/sample.py': fs.readFileSync(path.join(TEST_DIR, 'fixtures', 'sample.py'), 'utf8'),
+    'src/lib.rs': fs.readFileSync(path.join(TEST_DIR, 'fixtures', 'sample.rs'), 'utf8')
+};
+
+describe('Renderer golden files', () => {
+    let tmp;
+    let root;
+
+    beforeAll(() => {
+        tmp = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-golden-'));
+        // A fixed directory name keeps the project name out of the diff
+        root = path.join(tmp, 'calculator');
+        for (const [file, content] of Object.entries(FIXTURE)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(tmp, { recursive: true, force: true });
+    });
+
+    /**
+     * Calculator with the fixture analyzed; tokens are estimated so the
+     * golden files do not depend on whether tiktoken is installed
+     */
+    function analyze(options = {}) {
+        const calculator = new TokenCalculator(root, { dashboard: true, ...options });
+        calculator.calculateTokens = (content, filePath) => TokenUtils.estimate(content, filePath);
+        const results = calculator.analyzeFiles(calculator.scanProject())
+            .sort((a, b) => a.relativePath.localeCompare(b.relativePath));
+        return { calculator, results };
+    }
+
+    describe('GitIngest', () => {
+        test('default digest', () => {
+            const { calculator, results } = analyze();
+            expectGolden('gitingest/default.txt', calculator.createGitIngestFormatter(results).generateDigest());
+        });
+
+        test('sectioned layout', () => {
+            const { calculator, results } = analyze({ layout: ContextLayout.parse('overview,tree,docs,code,tests:200') });
+            expectGolden('gitingest/layout.txt', calculator.createGitIngestFormatter(results).generateDigest());
+        });
+
+        test('chunks by directory', () => {
+            const { calculator, results } = analyze({
+                chunking: { enabled: true, strategy: 'directory', maxTokensPerChunk: 600 }
+            });
+            const digest = [...calculator.createGitIngestFormatter(results).generatePieces()].join('');
+            expectGolden('gitingest/chunked.txt', digest);
+        });
+    });
+
+    describe('Structured', () => {
+        for (const [format, file] of [['json', 'context.json'], ['yaml', 'context.yaml'], ['markdown', 'context.md'], ['xml', 'context.xml']]) {
+            test(format, () => {
+                const { calculator, results } = analyze({ structuredFormat: format });
+                expectGolden(`structured/${file}`, calculator.createStructuredFormatter(results).encode(format));
+            });
+        }
+
+        test('collapsible markdown', () => {
+            const { calculator, results } = analyze({ structuredFormat: 'markdown', collapsible: true });
+            expectGolden('structured/collapsible.md', calculator.createStructuredFormatter(results).encode('markdown'));
+        });
+
+        test('xml with renamed tags', () => {
+            const xmlTags = parseXmlTags('documents=files,document=file,document_content=content');
+            const { calculator, results } = analyze({ structuredFormat: 'xml', xmlTags });
+            expectGolden('structured/renamed-tags.xml', calculator.createStructuredFormatter(results).encode('xml'));
+        });
+    });
+
+    describe('LLM context', () => {
+        test('paths', () => {
+            const { calculator, results } = analyze();
+            expectGolden('llm-context/paths.json', JSON.stringify(calculator.generateLLMContext(results), null, 2));
+        });
+
+        // Method-level context in each format of the registry; gitingest renders files instead
+        const registry = new FormatRegistry();
+        for (const format of registry.listFormats().filter(format => format !== 'gitingest')) {
+            test(`${format} encoding`, () => {
+                const { calculator, results } = analyze({ methodLevel: true });
+                const { extension } = registry.getInfo(format);
+                expectGolden(`llm-context/${format}${extension}`, registry.encode(format, calculator.generateLLMContext(results)));
+            });
+        }
+    });
+});
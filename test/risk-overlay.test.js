@@ -0,0 +1,85 @@
+import { describe, test, expect } from 'vitest';
+import RiskOverlay from '../lib/core/RiskOverlay.js';
+import PriorityScorer from '../lib/core/PriorityScorer.js';
+
+const ROOT = '/work/app';
+const FILES = ['pkg/calc/calc.go', 'pkg/calc/parse.go', 'src/api.py', 'src/util.js', 'web/app.js'];
+
+const SARIF = JSON.stringify({
+    version: '2.1.0',
+    runs: [{
+        tool: { driver: { name: 'semgrep', rules: [{ id: 'eval', defaultConfiguration: { level: 'error' } }] } },
+        originalUriBaseIds: { SRCROOT: { uri: 'file:///work/app/' } },
+        artifacts: [{ location: { uri: 'web/app.js' } }],
+        results: [
+            { ruleId: 'eval', message: { text: 'eval of input' }, locations: [{ physicalLocation: { artifactLocation: { uri: 'src/util.js', uriBaseId: 'SRCROOT' }, region: { startLine: 3 } } }] },
+            { ruleId: 'style', level: 'note', message: { text: 'naming' }, locations: [{ physicalLocation: { artifactLocation: { index: 0 } } }] },
+            { ruleId: 'eval', suppressions: [{ kind: 'inSource' }], message: { text: 'suppressed' }, locations: [{ physicalLocation: { artifactLocation: { uri: 'src/api.py' } } }] },
+            { ruleId: 'eval', baselineState: 'absent', message: { text: 'fixed' }, locations: [{ physicalLocation: { artifactLocation: { uri: 'src/api.py' } } }] },
+            { ruleId: 'check', kind: 'pass', message: { text: 'ok' }, locations: [{ physicalLocation: { artifactLocation: { uri: 'src/api.py' } } }] }
+        ]
+    }]
+});
+
+describe('RiskOverlay', () => {
+    test('reads LCOV, Go cover profiles and Cobertura reports', () => {
+        const overlay = new RiskOverlay(ROOT);
+        overlay.addCoverage('TN:\nSF:/work/app/src/util.js\nDA:1,1\nDA:2,0\nDA:3,0\nDA:4,5\nend_of_record\n', 'lcov.info');
+        overlay.addCoverage([
+            'mode: set',
+            'example.com/app/pkg/calc/calc.go:3.20,5.2 2 1',
+            'example.com/app/pkg/calc/calc.go:7.20,9.2 3 0',
+            'example.com/app/pkg/calc/calc.go:7.20,9.2 3 1',
+            'example.com/app/pkg/calc/parse.go:3.20,5.2 4 0',
+            ''
+        ].join('\n'), 'cover.out');
+        overlay.addCoverage([
+            '<?xml version="1.0" ?>',
+            '<coverage><sources><source>/work/app/src</source></sources><packages><package><classes>',
+            '<class name="api" filename="api.py"><lines><line number="1" hits="1"/><line number="2" hits="0"/><line number="3" hits="0"/><line number="4" hits="0"/></lines></class>',
+            '</classes></package></packages></coverage>'
+        ].join('\n'), 'coverage.xml');
+
+        const risk = overlay.forFiles(FILES);
+        expect([...risk.keys()]).toEqual(['pkg/calc/calc.go', 'pkg/calc/parse.go', 'src/api.py', 'src/util.js']);
+        expect(risk.get('src/util.js').coverage).toBe(0.5);
+        expect(risk.get('pkg/calc/calc.go').coverage).toBe(1);
+        expect(risk.get('pkg/calc/parse.go').coverage).toBe(0);
+        expect(risk.get('src/api.py').coverage).toBe(0.25);
+        expect(() => overlay.addCoverage('<html></html>', 'index.html')).toThrow('Unknown coverage format: index.html');
+    });
+
+    test('keeps open SARIF findings only', () => {
+        const overlay = new RiskOverlay(ROOT);
+        overlay.addSarif(SARIF, 'semgrep.sarif');
+
+        const risk = overlay.forFiles(FILES);
+        expect([...risk.keys()]).toEqual(['src/util.js', 'web/app.js']);
+        expect(risk.get('src/util.js')).toEqual({
+            coverage: null,
+            findings: [{ level: 'error', ruleId: 'eval', line: 3, message: 'eval of input' }],
+            severity: 1
+        });
+        expect(risk.get('web/app.js').severity).toBe(0.2);
+        expect(RiskOverlay.format(overlay, risk)).toContain('   Findings: 2 open in semgrep.sarif');
+        expect(() => overlay.addSarif('{}', 'empty.sarif')).toThrow('Invalid SARIF report empty.sarif: no runs');
+    });
+
+    test('ranks uncovered files and files with findings up', () => {
+        const overlay = new RiskOverlay(ROOT);
+        overlay.addCoverage('SF:src/util.js\nLF:10\nLH:9\nend_of_record\nSF:web/app.js\nLF:10\nLH:2\nend_of_record\n');
+        overlay.addSarif(SARIF);
+
+        const scorer = new PriorityScorer({ weights: { path: 0, churn: 0, fanIn: 0, depth: 0, size: 0, entry: 0 } });
+        const scores = scorer.score(['src/util.js', 'web/app.js', 'src/api.py'].map(relativePath => ({ relativePath, tokens: 10 })), {
+            risk: overlay.forFiles(FILES)
+        });
+
+        // util.js: the only error finding; app.js: 80% uncovered
+        expect(scores.get('src/util.js').signals.risk).toMatchObject({ value: 1, points: 20 });
+        expect(scores.get('web/app.js').signals.risk).toMatchObject({ value: 0.8, points: 16 });
+        expect(scores.get('src/api.py').signals.risk.points).toBe(0);
+        expect(PriorityScorer.formatExplanation(
+            [{ relativePath: 'web/app.js', priority: 16 }], scores)).toContain('risk 16.00 (20% covered, 1 finding, ×2)');
+    });
+});
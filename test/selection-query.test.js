@@ -0,0 +1,65 @@
+import { describe, test, expect } from 'vitest';
+import SelectionQuery from '../lib/symbols/SelectionQuery.js';
+
+const symbol = fields => ({
+    package: 'internal/auth', language: 'go', kind: 'function', doc: null, parent: null, exported: true,
+    signature: '', startLine: 1, endLine: 10, ...fields
+});
+
+const SYMBOLS = [
+    symbol({ id: 'login', file: 'internal/auth/login.go', name: 'Login', qualifiedName: 'Login' }),
+    symbol({ id: 'hash', file: 'internal/auth/hash.go', name: 'hash', qualifiedName: 'hash', exported: false }),
+    symbol({ id: 'serve', file: 'cmd/server/main.go', package: 'cmd/server', name: 'Serve', qualifiedName: 'Serve', endLine: 400 }),
+    symbol({ id: 'parse', file: 'web/parse.ts', package: 'web', language: 'typescript', name: 'parse', qualifiedName: 'parse', doc: 'Parses input' }),
+    symbol({ id: 'Token.String', file: 'internal/auth/token.go', name: 'String', qualifiedName: 'Token.String', kind: 'method', parent: 'Token' })
+];
+
+// Tokens: ten per line
+const tokensOf = row => (row.endLine - row.startLine + 1) * 10;
+const ids = (source, rows = SYMBOLS) => SelectionQuery.parse(source).select(rows, { tokensOf }).map(row => row.id);
+
+describe('SelectionQuery', () => {
+    test('combines comparisons with &&, || and parentheses', () => {
+        expect(ids('lang==go && (exported || path~"internal/auth") && tokens<2000')).toEqual(['login', 'hash', 'Token.String']);
+        expect(ids('lang==go && exported || lang==typescript')).toEqual(['login', 'serve', 'parse', 'Token.String']);
+        expect(ids('!exported || kind==method')).toEqual(['hash', 'Token.String']);
+        expect(ids("name==S* && qname!='Token.*'")).toEqual(['serve']);
+        expect(ids('doc && lines<=10 && path!~^internal/')).toEqual(['parse']);
+        expect(ids('parent==Token && exported==true')).toEqual(['Token.String']);
+        expect(ids('tokens>=4k')).toEqual(['serve']);
+    });
+
+    test('counts tokens only for symbols that get that far', () => {
+        const counted = [];
+        SelectionQuery.parse('lang==typescript && tokens<100').select(SYMBOLS, {
+            tokensOf: row => {
+                counted.push(row.id);
+                return 10;
+            }
+        });
+        expect(counted).toEqual(['parse']);
+        expect(() => SelectionQuery.parse('tokens<100').select(SYMBOLS)).toThrow('The tokens field needs options.tokensOf');
+    });
+
+    test('reports errors with their column', () => {
+        const error = source => {
+            try {
+                SelectionQuery.parse(source);
+            } catch (thrown) {
+                return [thrown.message, thrown.column];
+            }
+            return null;
+        };
+
+        expect(error('lang==go && (exported')).toEqual(["Expected ')' but found the end (column 22)", 22]);
+        expect(error('lang = go')).toEqual(["Unexpected '=' (column 6)", 6]);
+        expect(error('size<10')[0]).toMatch(/^Unknown field: size \(expected lang, kind, /);
+        expect(error('name<10')).toEqual(['name is a string; < needs a number field (column 5)', 5]);
+        expect(error('tokens<lots')).toEqual(['tokens compares with a number, not lots (column 8)', 8]);
+        expect(error('exported==yes')).toEqual(['exported compares with true or false, not yes (column 11)', 11]);
+        expect(error('path~"("')[1]).toBe(6);
+        expect(error('name=="Login')).toEqual(['Unterminated string (column 7)', 7]);
+        expect(error('lang==go lang==ts')).toEqual(["Unexpected 'lang' (column 10)", 10]);
+        expect([...SelectionQuery.parse('!(kind==method) && tokens<1k').fields()]).toEqual(['kind', 'tokens']);
+    });
+});
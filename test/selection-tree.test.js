@@ -0,0 +1,117 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import SelectionTree from '../lib/ui/selection-tree.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import { SymbolExtractor } from '../lib/symbols/SymbolExtractor.js';
+import { symbolId } from '../lib/symbols/SymbolId.js';
+
+const FILES = [
+    { relativePath: 'src/api/routes.js', tokens: 300 },
+    { relativePath: 'src/util.js', tokens: 100 },
+    { relativePath: 'README.md', tokens: 50 }
+];
+
+const SYMBOLS = {
+    'src/util.js': [
+        { id: 'src/util.js#function:pad@00000001', name: 'pad', kind: 'function', tokens: 40 },
+        { id: 'src/util.js#function:trim@00000002', name: 'trim', kind: 'function', tokens: 50 }
+    ]
+};
+
+const treeOf = (options = {}) => new SelectionTree(FILES, { loadSymbols: file => SYMBOLS[file] || [], ...options });
+const find = (tree, name) => tree.rows().find(row => row.node.name === name).node;
+
+describe('SelectionTree', () => {
+    test('lists directories before files and starts fully selected', () => {
+        const tree = treeOf();
+
+        expect(tree.rows().map(({ node, depth }) => `${depth}:${node.name}`)).toEqual(['0:src', '0:README.md']);
+        tree.expand(find(tree, 'src'));
+        expect(tree.rows().map(({ node }) => node.name)).toEqual(['src', 'api', 'util.js', 'README.md']);
+        expect(tree.selectedTokens()).toBe(450);
+        expect(tree.totalTokens(find(tree, 'src'))).toBe(400);
+
+        // Names sort by code point, the same on every locale
+        const mixed = new SelectionTree(['b.js', 'Z.js', 'a.js', 'é.js'].map(relativePath => ({ relativePath, tokens: 1 })));
+        expect(mixed.rows().map(({ node }) => node.name)).toEqual(['Z.js', 'a.js', 'b.js', 'é.js']);
+    });
+
+    test('toggles files and directories', () => {
+        const tree = treeOf({ budget: 200 });
+        const src = find(tree, 'src');
+        tree.expand(src);
+
+        expect(tree.isOverBudget()).toBe(true);
+        tree.toggle(find(tree, 'util.js'));
+        expect(tree.stateOf(src)).toBe('some');
+        expect(tree.selectedTokens()).toBe(350);
+
+        // A partly selected directory becomes fully selected, then empty
+        tree.toggle(src);
+        expect(tree.stateOf(src)).toBe('all');
+        tree.toggle(src);
+        expect(tree.selection()).toEqual({ files: ['README.md'], symbols: [] });
+        expect(tree.isOverBudget()).toBe(false);
+    });
+
+    test('narrows a file to its symbols', () => {
+        const tree = treeOf();
+        tree.expand(find(tree, 'src'));
+        const util = find(tree, 'util.js');
+        tree.expand(util);
+
+        expect(tree.rows().filter(row => row.depth === 2).map(row => row.node.name)).toEqual(['function pad', 'function trim']);
+        tree.toggle(find(tree, 'function trim'));
+        expect(tree.stateOf(util)).toBe('some');
+        expect(tree.selectedTokens(util)).toBe(40);
+        expect(tree.selection().symbols).toEqual(['src/util.js#function:pad@00000001']);
+
+        // Picking every symbol again selects the whole file
+        tree.toggle(find(tree, 'function trim'));
+        expect(tree.stateOf(util)).toBe('all');
+        expect(tree.selection().files).toContain('src/util.js');
+    });
+
+    test('files without symbols do not expand', () => {
+        const tree = treeOf();
+        const readme = find(tree, 'README.md');
+        tree.expand(readme);
+        expect(readme.expanded).toBe(false);
+        tree.setAll(false);
+        expect(tree.selectedTokens()).toBe(0);
+    });
+});
+
+describe('TokenCalculator picked selection', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-select-'));
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.writeFileSync(path.join(root, 'src/util.js'), 'export function pad(s) {\n    return ` ${s}`;\n}\n\nexport function trim(s) {\n    return s.trim();\n}\n');
+        fs.writeFileSync(path.join(root, 'src/app.js'), 'export const app = 1;\n');
+        fs.writeFileSync(path.join(root, 'README.md'), '# app\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('exports picked files whole and picked symbols alone', () => {
+        const extractor = new SymbolExtractor({ backend: 'heuristic' });
+        const util = fs.readFileSync(path.join(root, 'src/util.js'), 'utf8');
+        const trim = extractor.extract(util, 'src/util.js').find(symbol => symbol.name === 'trim');
+
+        const calculator = new TokenCalculator(root, {
+            symbolExtractor: extractor,
+            pick: { files: ['README.md'], symbols: [symbolId(trim, 'src/util.js')] }
+        });
+        const results = calculator.scanDirectory(root).map(file => calculator.analyzeFile(file));
+        const exported = calculator.selectExportResults(results);
+
+        expect(exported.map(fileInfo => fileInfo.relativePath).sort()).toEqual(['README.md', 'src/util.js']);
+        expect(exported.find(fileInfo => fileInfo.relativePath === 'src/util.js').selectedSymbols.map(range => range.name)).toContain('trim');
+    });
+});
@@ -0,0 +1,207 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import SemanticIndex from '../lib/rag/SemanticIndex.js';
+import { EmbeddingProvider, OpenAIEmbeddingProvider, OllamaEmbeddingProvider } from '../lib/rag/EmbeddingProvider.js';
+import { EmbeddingProviderFactory } from '../lib/rag/EmbeddingProviderFactory.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import TokenBudget from '../lib/core/TokenBudget.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+const VOCABULARY = ['retry', 'attempt', 'backoff', 'user', 'name', 'email', 'install', 'config'];
+
+/**
+ * Counts vocabulary words so related text gets similar vectors
+ */
+class WordProvider extends EmbeddingProvider {
+    constructor() {
+        super();
+        this.calls = 0;
+    }
+
+    get id() {
+        return 'test:words';
+    }
+
+    async embed(text) {
+        this.calls++;
+        const words = text.toLowerCase().match(/[a-z]+/g) || [];
+        return [0.1, ...VOCABULARY.map(word => words.filter(w => w.startsWith(word)).length)];
+    }
+}
+
+const FILES = {
+    'src/http.ts': [
+        'export function withRetry(fn: () => Promise<void>, attempts = 3) {',
+        '    // retry with exponential backoff',
+        '    return fn().catch(() => attempts > 0 && withRetry(fn, attempts - 1));',
+        '}',
+        '',
+        'export function get(url: string) {',
+        '    return fetch(url);',
+        '}',
+        ''
+    ].join('\n'),
+    'src/user.ts': [
+        'export class User {',
+        '    rename(name: string) {',
+        '        this.name = name;',
+        '    }',
+        '',
+        '    setEmail(email: string) {',
+        '        this.email = email;',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'README.md': '# Demo\n\nIntro text.\n\n## Install\n\nRun the install script and edit the config.\n'
+};
+
+function mockFetch(calls, body) {
+    return async (url, init) => {
+        calls.push({ url, init, body: JSON.parse(init.body) });
+        return { ok: true, json: async () => body(JSON.parse(init.body)) };
+    };
+}
+
+describe('SemanticIndex', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-semantic-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const files = () => Object.entries(FILES).map(([relativePath, content]) => ({ relativePath, content }));
+
+    test('chunks files by symbol and Markdown section', () => {
+        const index = new SemanticIndex(new WordProvider(), { root, path: null });
+
+        expect(index.chunkFile('src/user.ts', FILES['src/user.ts']).map(c => [c.name, c.startLine, c.endLine])).toEqual([
+            ['User.rename', 2, 4],
+            ['User.setEmail', 6, 8]
+        ]);
+        expect(index.chunkFile('README.md', FILES['README.md']).map(c => [c.name, c.startLine, c.endLine])).toEqual([
+            ['Demo', 1, 4],
+            ['Install', 5, 7]
+        ]);
+        expect(index.chunkFile('notes.txt', 'a\nb\nc\n').map(c => [c.kind, c.startLine, c.endLine])).toEqual([['section', 1, 3]]);
+    });
+
+    test('ranks chunks by similarity and reuses stored vectors', async () => {
+        const provider = new WordProvider();
+        const index = new SemanticIndex(provider, { root });
+
+        expect(await index.build(files())).toEqual({ files: 3, chunks: 6, embedded: 6, reused: 0 });
+        const ranked = await index.search('where is retry logic?', { limit: 2 });
+        expect(ranked[0]).toMatchObject({ file: 'src/http.ts', name: 'withRetry', startLine: 1, endLine: 4 });
+        expect(ranked[0].score).toBeGreaterThan(ranked[1].score);
+
+        const reloaded = new SemanticIndex(new WordProvider(), { root });
+        const changed = files().map(file => file.relativePath === 'src/user.ts'
+            ? { ...file, content: file.content.replace('this.email = email;', 'this.email = email.trim();') }
+            : file);
+        expect(await reloaded.build(changed)).toMatchObject({ embedded: 1, reused: 5 });
+        expect(fs.existsSync(path.join(root, '.ctxman/cache/embeddings/test_words.json'))).toBe(true);
+    });
+});
+
+describe('Embedding providers', () => {
+    test('OpenAI batches inputs and orders results by index', async () => {
+        const calls = [];
+        const provider = new OpenAIEmbeddingProvider({
+            apiKey: 'sk-test',
+            batchSize: 2,
+            fetch: mockFetch(calls, body => ({ data: body.input.map((text, index) => ({ index, embedding: [text.length] })).reverse() }))
+        });
+
+        expect(await provider.embedBatch(['a', 'bb', 'ccc'])).toEqual([[1], [2], [3]]);
+        expect(calls).toHaveLength(2);
+        expect(calls[0].url).toBe('https://api.openai.com/v1/embeddings');
+        expect(calls[0].init.headers.Authorization).toBe('Bearer sk-test');
+        expect(calls[0].body).toEqual({ model: 'text-embedding-3-small', input: ['a', 'bb'] });
+        await expect(new OpenAIEmbeddingProvider({ apiKey: '' }).embed('x')).rejects.toThrow('OPENAI_API_KEY');
+    });
+
+    test('Ollama and local endpoints', async () => {
+        const calls = [];
+        const ollama = new OllamaEmbeddingProvider({
+            baseUrl: 'http://gpu:11434/',
+            fetch: mockFetch(calls, body => ({ embeddings: body.input.map(() => [1, 0]) }))
+        });
+        expect(await ollama.embed('hello')).toEqual([1, 0]);
+        expect(calls[0].url).toBe('http://gpu:11434/api/embed');
+        expect(ollama.id).toBe('ollama:nomic-embed-text');
+
+        const local = EmbeddingProviderFactory.create('local', {
+            baseUrl: 'http://localhost:8080/v1',
+            fetch: mockFetch(calls, body => ({ data: body.input.map((text, index) => ({ index, embedding: [0, 1] })) }))
+        });
+        expect(await local.embed('hello')).toEqual([0, 1]);
+        expect(calls[1].url).toBe('http://localhost:8080/v1/embeddings');
+        expect(calls[1].init.headers.Authorization).toBeUndefined();
+
+        expect(() => EmbeddingProviderFactory.create('cohere')).toThrow('Unknown embedding provider');
+    });
+
+    test('reports HTTP errors', async () => {
+        const provider = new OllamaEmbeddingProvider({
+            fetch: async () => ({ ok: false, status: 404, text: async () => 'model "nomic-embed-text" not found' })
+        });
+        await expect(provider.embed('x')).rejects.toThrow('Ollama returned 404: model "nomic-embed-text" not found');
+    });
+});
+
+describe('TokenCalculator query scope', () => {
+    test('exports the best chunks that fit the budget', async () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-queryscope-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+
+        try {
+            const index = new SemanticIndex(new WordProvider(), { root, path: null });
+            const stats = await index.build(Object.entries(FILES).map(([relativePath, content]) => ({ relativePath, content })));
+            const chunks = await index.search('retry backoff email');
+            const query = { text: 'retry backoff email', provider: 'test:words', stats, chunks, limit: 2 };
+
+            const calculator = new TokenCalculator(root, { query, dashboard: true });
+            const results = Object.keys(FILES).map(f => calculator.analyzeFile(path.join(root, f)));
+            const selected = calculator.selectExportResults(results);
+
+            expect(selected.map(f => [f.relativePath, f.priority])).toEqual([['src/user.ts', 100], ['src/http.ts', 99]]);
+            expect(selected[0].selectedSymbols.map(s => s.name)).toEqual(['User.setEmail']);
+            expect(calculator.generateLLMContext(selected).query.chunks.map(c => c.name)).toEqual(['User.setEmail', 'withRetry']);
+
+            const contents = new GitIngestFormatter(root, calculator.stats, selected).generateFileContents();
+            expect(contents).toContain('// Relevant to "retry backoff email": showing 1 symbols');
+            expect(contents).not.toContain('export function get');
+
+            const report = SemanticIndex.formatQuery(calculator.queryScope);
+            expect(report).toContain('src/http.ts:1-4  function withRetry');
+            expect(report).toContain('6 chunks in 3 files');
+
+            const budgeted = new TokenCalculator(root, {
+                query: { ...query, limit: null },
+                tokenBudget: new TokenBudget({ maxTokens: 30 }),
+                dashboard: true
+            });
+            budgeted.selectExportResults(results);
+            expect(budgeted.queryScope.chunks[0].name).toBe('User.setEmail');
+            expect(budgeted.queryScope.chunks.map(c => c.name)).not.toContain('withRetry');
+            expect(budgeted.queryScope.skipped).toBeGreaterThan(0);
+            expect(budgeted.queryScope.budget.usedTokens).toBeLessThanOrEqual(30);
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
@@ -0,0 +1,67 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import ShellCompletion from '../lib/core/ShellCompletion.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+import { createSymbol } from '../lib/symbols/SymbolModel.js';
+
+const HELP = [
+    'Analysis Options:',
+    '  -g, --gitingest          Generate GitIngest-style digest',
+    '  --max-tokens N           Pack highest-priority files into N tokens (e.g. 32k)',
+    '                           --not-a-flag in a continuation line',
+    '  symbols [options]        List project symbols with their symbol IDs',
+    '    --package PKG          Package ID; PKG/... includes everything below (repeatable)'
+].join('\n');
+
+describe('ShellCompletion', () => {
+    test('reads flags from help text', () => {
+        expect(ShellCompletion.flagsOf(HELP)).toEqual(['--gitingest', '--max-tokens', '--package', '-g']);
+    });
+
+    test('generates scripts that complete dynamic values through ctxman', () => {
+        const completion = new ShellCompletion({ commands: ['lint', 'symbols'], flags: ['--profile', '--symbol', '--tree', '-g'] });
+
+        const bash = completion.script('bash');
+        expect(bash).toContain('    --symbol)\n        COMPREPLY=($(compgen -W "$(ctxman completion --complete symbols "$cur" 2>/dev/null)" -- "$cur"))');
+        expect(bash).toContain('compgen -W "lint symbols"');
+        expect(bash).toMatch(/complete -o default -F _ctxman ctxman\n$/);
+
+        expect(completion.script('zsh')).toContain('compadd -- ${(f)"$(ctxman completion --complete profiles "${words[CURRENT]}" 2>/dev/null)"}');
+
+        const fish = completion.script('fish').split('\n');
+        expect(fish).toContain('complete -c ctxman -l tree');
+        expect(fish).toContain('complete -c ctxman -s g');
+        expect(fish).toContain("complete -c ctxman -l symbol -x -a '(ctxman completion --complete symbols (commandline -ct) 2>/dev/null)'");
+        expect(() => completion.script('tcsh')).toThrow('Unsupported shell: tcsh (expected bash, zsh, fish)');
+    });
+
+    describe('values', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-completion-'));
+            fs.writeFileSync(path.join(root, 'context.yaml'), 'profiles:\n  backend:\n    include: ["src/**"]\n  docs:\n    include: ["docs/**"]\n');
+
+            const cache = new ContentCache({ root });
+            cache.symbols('a'.repeat(64), 'heuristic:.js', 'src/service.js', () => [
+                createSymbol({ name: 'Service', kind: 'class', file: 'src/service.js' }),
+                createSymbol({ name: 'fetch', kind: 'method', parent: 'Service', file: 'src/service.js' }),
+                createSymbol({ name: 'parse', kind: 'function', file: 'src/service.js' })
+            ]);
+            cache.save();
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('completes profiles and cached symbol names by prefix', async () => {
+            expect(await ShellCompletion.values('profiles', { root })).toEqual(['backend', 'docs']);
+            expect(await ShellCompletion.values('symbols', { root, prefix: 'Serv' })).toEqual(['Service', 'Service.fetch']);
+            expect(await ShellCompletion.values('packages', { root })).toEqual([]);
+            await expect(ShellCompletion.values('files', { root })).rejects.toThrow('Unknown completion: files');
+        });
+    });
+});
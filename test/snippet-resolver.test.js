@@ -0,0 +1,135 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import SnippetResolver from '../lib/graph/SnippetResolver.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    'src/config.js': [
+        'export function parseConfig(text) {',
+        '    return JSON.parse(text);',
+        '}',
+        ''
+    ].join('\n'),
+    'src/server.js': [
+        "import { parseConfig } from './config.js';",
+        '',
+        'export class Server {',
+        '    start(text) {',
+        '        const config = parseConfig(text);',
+        '        return config.port;',
+        '    }',
+        '}',
+        '',
+        'const server = new Server();',
+        'server.start(process.argv[2]);',
+        ''
+    ].join('\n'),
+    'lib/a/util.js': 'export function helper() {\n    return 1;\n}\n',
+    'lib/b/util.js': 'export function helper() {\n    return 2;\n}\n',
+    'app/main.py': 'import sys\n\n\ndef run(args):\n    return args[0]\n'
+};
+
+const TRACE = [
+    'SyntaxError: Unexpected token } in JSON at position 12',
+    '    at JSON.parse (<anonymous>)',
+    '    at parseConfig (/home/ci/work/app/src/config.js:2:17)',
+    '    at Server.start (/home/ci/work/app/src/server.js:5:24)',
+    '    at Object.<anonymous> (/home/ci/work/app/src/server.js:11:8)',
+    '    at Module._compile (node:internal/modules/cjs/loader:1256:14)',
+    '    at <anonymous> (util.js:2:5)'
+].join('\n');
+
+describe('SnippetResolver', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-snippet-'));
+        for (const [name, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, name)), { recursive: true });
+            fs.writeFileSync(path.join(root, name), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    function graphOf() {
+        const calculator = new TokenCalculator(root);
+        return calculator.buildDependencyGraph(calculator.analyzeFiles(calculator.scanProject())).graph;
+    }
+
+    test('maps stack trace frames to project files and functions', () => {
+        const { frames } = new SnippetResolver().resolve(graphOf(), TRACE);
+
+        expect(frames.map(frame => [frame.location, frame.file, frame.symbol?.qualifiedName ?? null])).toEqual([
+            ['/home/ci/work/app/src/config.js:2', 'src/config.js', 'parseConfig'],
+            ['/home/ci/work/app/src/server.js:5', 'src/server.js', 'Server.start'],
+            ['/home/ci/work/app/src/server.js:11', 'src/server.js', null],
+            ['util.js:2', null, null]
+        ]);
+        // Outside every function: the lines around the frame
+        expect(frames[2]).toMatchObject({ line: 11, startLine: 8, endLine: 12 });
+    });
+
+    test('resolves Python frames and the names a snippet uses', () => {
+        const graph = graphOf();
+        const python = new SnippetResolver().resolve(graph, 'Traceback (most recent call last):\n  File "/srv/app/main.py", line 5, in run\nIndexError: list index out of range\n');
+        expect(python.frames.map(frame => [frame.file, frame.symbol?.name])).toEqual([['app/main.py', 'run']]);
+
+        const snippet = new SnippetResolver().resolve(graph, 'const server = new Server();\nserver.start(parseConfig(raw));\nhelper();\n');
+        expect(snippet.frames).toEqual([]);
+        expect(snippet.mentions.map(mention => [mention.name, mention.symbols.map(symbol => symbol.file)])).toEqual([
+            ['Server', ['src/server.js']],
+            ['start', ['src/server.js']],
+            ['parseConfig', ['src/config.js']],
+            ['helper', ['lib/a/util.js', 'lib/b/util.js']]
+        ]);
+
+        const strict = new SnippetResolver({ maxMatches: 1 }).resolve(graph, 'helper();\nparseConfig(raw);\n');
+        expect(strict.ambiguous).toEqual(['helper']);
+        expect(strict.symbols.map(symbol => symbol.name)).toEqual(['parseConfig']);
+    });
+
+    test('formats the resolution and the digest section', () => {
+        const resolution = new SnippetResolver().resolve(graphOf(), TRACE);
+        const report = SnippetResolver.formatResolution(resolution);
+        expect(report).toContain('🧷 SNIPPET REFERENCES');
+        expect(report).toContain('util.js:2 → not in the project');
+        expect(report).toContain('/home/ci/work/app/src/config.js:2 → function parseConfig (src/config.js:1-3)');
+
+        const section = SnippetResolver.formatSnippet(TRACE, resolution);
+        expect(section.startsWith(`${'='.repeat(48)}\nSNIPPET\n${'='.repeat(48)}\nSyntaxError:`)).toBe(true);
+        expect(section).toContain('- /home/ci/work/app/src/server.js:11 → src/server.js:8-12');
+        expect(SnippetResolver.formatResolution(new SnippetResolver().resolve(graphOf(), 'nothing here')))
+            .toContain('Nothing in the snippet names a project file or symbol');
+    });
+
+    describe('TokenCalculator integration', () => {
+        test('exports the snippet with the definitions it mentions', () => {
+            const calculator = new TokenCalculator(root, { snippet: { text: TRACE, source: 'stdin' } });
+            const exported = calculator.selectExportResults(calculator.analyzeFiles(calculator.scanProject()));
+            const byPath = new Map(exported.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+
+            expect([...byPath.keys()].sort()).toEqual(['src/config.js', 'src/server.js']);
+            expect(byPath.get('src/server.js').selectionNote).toBe('In the stack trace');
+            expect(byPath.get('src/server.js').selectedSymbols.map(range => range.name)).toContain('line 11');
+
+            const digest = calculator.createGitIngestFormatter(exported).generateDigest();
+            expect(digest).toContain('SNIPPET\n================================================\nSyntaxError: Unexpected token');
+            expect(digest).toContain('FILE: src/config.js');
+
+            const context = calculator.createStructuredFormatter(exported).build();
+            expect(context.selection).toMatchObject({ mode: 'snippet', target: 'stdin' });
+            expect(context.snippet.frames[0]).toEqual({ location: '/home/ci/work/app/src/config.js:2', file: 'src/config.js', line: 2, symbol: 'parseConfig' });
+            expect(context.snippet.symbols.map(symbol => symbol.name)).toContain('Server.start');
+        });
+
+        test('exports nothing when the snippet names nothing in the project', () => {
+            const calculator = new TokenCalculator(root, { snippet: { text: 'segmentation fault (core dumped)', source: 'stdin' } });
+            expect(calculator.selectExportResults(calculator.analyzeFiles(calculator.scanProject()))).toBeNull();
+        });
+    });
+});
@@ -0,0 +1,69 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import SourceDirectives from '../lib/core/SourceDirectives.js';
+import PriorityScorer from '../lib/core/PriorityScorer.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+describe('SourceDirectives', () => {
+    test('reads directives at the start of comments', () => {
+        const directives = SourceDirectives.parse('//ctx:pin\n/* ctx:priority=high */\nexport const a = 1;\n', 'src/a.js');
+        expect(directives).toEqual({ pin: true, ignore: false, priority: 100, warnings: [] });
+        expect(SourceDirectives.parse('# ctx:ignore ctx:priority=-5 legacy helpers\n', 'tools/old.py'))
+            .toMatchObject({ ignore: true, priority: -5 });
+        expect(SourceDirectives.parse('<!-- ctx:priority=low -->\n# Notes\n', 'docs/notes.md').priority).toBe(-100);
+    });
+
+    test('ignores directives in strings, prose and code samples', () => {
+        expect(SourceDirectives.parse('const help = "//ctx:ignore";\n// see ctx:pin in the docs\n', 'src/a.js')).toBe(null);
+        expect(SourceDirectives.parse('def f():\n    """ctx:ignore"""\n', 'a.py')).toBe(null);
+        expect(SourceDirectives.parse('```html\n<!-- ctx:ignore -->\n```\n', 'README.md')).toBe(null);
+        expect(SourceDirectives.parse('ctx:ignore\n', 'notes.txt')).toBe(null);
+    });
+
+    test('warns about unknown directives and invalid values', () => {
+        const directives = SourceDirectives.parse('// ctx:ingore\nlet a;\n// ctx:priority=urgent\n', 'src/a.ts');
+        expect(directives.ignore).toBe(false);
+        expect(directives.priority).toBe(null);
+        expect(directives.warnings.map(warning => warning.line)).toEqual([1, 3]);
+        expect(directives.warnings[0].message).toContain('Unknown directive ctx:ingore');
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-directives-'));
+            const files = {
+                'src/core.js': '// ctx:pin\nexport const core = 1;\n',
+                'src/dead.js': '// ctx:ignore\nexport const dead = 2;\n',
+                'test/app.test.js': '/* ctx:priority=high */\ntest();\n',
+                'src/app.js': 'export const app = 3;\n'
+            };
+            for (const [file, content] of Object.entries(files)) {
+                fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+                fs.writeFileSync(path.join(root, file), content);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('drops ignored files and keeps pins and priorities', () => {
+            const calculator = new TokenCalculator(root);
+            const results = calculator.analyzeFiles(calculator.scanProject());
+            const byPath = new Map(results.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+
+            expect([...byPath.keys()].sort()).toEqual(['src/app.js', 'src/core.js', 'test/app.test.js']);
+            expect(calculator.stats.directiveIgnoredFiles).toBe(1);
+            expect(calculator.stats.totalFiles).toBe(3);
+            expect(byPath.get('src/core.js').pinned).toBe(true);
+            expect(byPath.get('test/app.test.js').priority).toBe(100);
+
+            const scores = new PriorityScorer().score(results);
+            expect(scores.get(byPath.get('src/core.js').relativePath).signals.pins.raw).toBe('ctx:pin');
+        });
+    });
+});
@@ -0,0 +1,88 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import { execFileSync } from 'child_process';
+import SparseCheckout from '../lib/integrations/git/SparseCheckout.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const CONE = ['/*', '!/*/', '/services/', '!/services/*/', '/services/api/', '/libs/'];
+
+describe('SparseCheckout', () => {
+    test('keeps cone directories and the files of their parents', () => {
+        const sparse = new SparseCheckout(CONE);
+        expect(sparse.cone).toBe(true);
+        expect(sparse.describe()).toBe('cone: 2 directories');
+
+        expect(sparse.includes('README.md')).toBe(true);
+        expect(sparse.includes('services/Makefile')).toBe(true);
+        expect(sparse.includes('services/api/src/main.js')).toBe(true);
+        expect(sparse.includes('libs/a/b/c.js')).toBe(true);
+        expect(sparse.includes('services/web/index.js')).toBe(false);
+        expect(sparse.includes('tools/build.js')).toBe(false);
+
+        expect(sparse.includesDirectory('services')).toBe(true);
+        expect(sparse.includesDirectory('services/api/src')).toBe(true);
+        expect(sparse.includesDirectory('services/web')).toBe(false);
+        expect(sparse.includesDirectory('tools')).toBe(false);
+
+        // A project in a subdirectory of the repository
+        const nested = new SparseCheckout(CONE, { prefix: 'services/' });
+        expect(nested.includes('api/main.js')).toBe(true);
+        expect(nested.includes('web/main.js')).toBe(false);
+    });
+
+    test('applies non-cone patterns with the last match winning', () => {
+        const sparse = new SparseCheckout(['/*', '!/docs/', '/docs/api.md']);
+        expect(sparse.cone).toBe(false);
+        expect(sparse.includes('src/app.js')).toBe(true);
+        expect(sparse.includes('docs/guide.md')).toBe(false);
+        expect(sparse.includes('docs/api.md')).toBe(true);
+        expect(sparse.includesDirectory('docs')).toBe(true);
+    });
+
+    describe('TokenCalculator', () => {
+        let root;
+        const git = (...args) => execFileSync('git', args, { cwd: root, stdio: 'pipe' });
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-sparse-'));
+            for (const name of ['README.md', 'services/api/main.js', 'services/web/main.js', 'libs/util.js', 'tools/build.js']) {
+                fs.mkdirSync(path.dirname(path.join(root, name)), { recursive: true });
+                fs.writeFileSync(path.join(root, name), 'export const value = 1;\n');
+            }
+            git('init', '-q');
+            git('add', '-A');
+            git('-c', 'user.name=Test', '-c', 'user.email=test@example.com', 'commit', '-qm', 'init');
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        const scan = sparse => new TokenCalculator(root, { sparse }).scanProject()
+            .map(file => path.relative(root, file).split(path.sep).join('/'))
+            .sort();
+
+        test('applies only when sparse checkout is on, unless forced', () => {
+            expect(SparseCheckout.load(root)).toBe(null);
+            expect(() => SparseCheckout.load(root, { force: true })).toThrow('this repository has no sparse-checkout definition');
+
+            const definition = path.join(root, 'sparse.txt');
+            fs.writeFileSync(definition, `# api only\n${CONE.slice(0, 5).join('\n')}\n`);
+            const calculator = new TokenCalculator(root, { sparse: SparseCheckout.load(root, { file: 'sparse.txt' }) });
+            expect(calculator.scanProject().length).toBe(3);
+            // libs, services/web and tools are skipped without being read
+            expect(calculator.sparseSkipped).toBe(3);
+            expect(() => SparseCheckout.load(root, { file: 'missing.txt' })).toThrow('Sparse-checkout file not found: missing.txt');
+
+            git('sparse-checkout', 'set', '--cone', 'services/api', 'libs');
+            const sparse = SparseCheckout.load(root);
+            expect(sparse.cone).toBe(true);
+            // Untracked files outside the cone stay in the working tree, and out of the context
+            fs.mkdirSync(path.join(root, 'tools'), { recursive: true });
+            fs.writeFileSync(path.join(root, 'tools', 'local.js'), 'export const local = 1;\n');
+            expect(scan(sparse)).toEqual(['README.md', 'libs/util.js', 'services/api/main.js', 'sparse.txt']);
+        });
+    });
+});
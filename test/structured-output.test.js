@@ -0,0 +1,191 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import StructuredFormatter, { STRUCTURED_SCHEMA } from '../lib/formatters/structured-formatter.js';
+import FormatRegistry from '../lib/formatters/format-registry.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    'src/util.ts': [
+        'export function parse(text: string): string {',
+        '    return text.trim();',
+        '}',
+        ''
+    ].join('\n'),
+    'src/service.ts': [
+        "import { parse } from './util';",
+        '',
+        'export class Service {',
+        '    fetch(url: string) {',
+        '        return parse(url);',
+        '    }',
+        '',
+        '    other() {',
+        '        return 1;',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'README.md': '# Demo\n'
+};
+
+describe('StructuredFormatter', () => {
+    let root;
+    let calculator;
+    let results;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-structured-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        calculator = new TokenCalculator(root, { structuredFormat: 'json', dashboard: true });
+        results = Object.keys(FILES).map(f => calculator.analyzeFile(path.join(root, f)));
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('builds the stable schema with files sorted by path', () => {
+        const doc = calculator.createStructuredFormatter(results).build();
+
+        expect(Object.keys(doc)).toEqual(['schema', 'project', 'selection', 'files']);
+        expect(doc.schema).toBe(STRUCTURED_SCHEMA);
+        expect(doc.project).toMatchObject({ name: path.basename(root), files: 3 });
+        expect(doc.selection).toEqual({ mode: 'all', target: null, budget: null });
+        expect(doc.files.map(file => file.path)).toEqual(['README.md', 'src/service.ts', 'src/util.ts']);
+        expect(Object.keys(doc.files[0])).toEqual([
+            'path', 'language', 'priority', 'tokens', 'lines', 'size', 'included', 'note', 'ranges', 'symbols', 'content'
+        ]);
+        expect(doc.files[0]).toMatchObject({ included: 'full', ranges: null, symbols: [], content: '# Demo\n' });
+    });
+
+    test('lists symbols with signatures and token counts', () => {
+        const service = calculator.createStructuredFormatter(results).build().files[1];
+
+        expect(service.language).toBe('typescript');
+        expect(service.symbols.map(symbol => [symbol.name, symbol.kind, symbol.startLine])).toEqual([
+            ['Service', 'class', 3],
+            ['Service.fetch', 'method', 4],
+            ['Service.other', 'method', 8]
+        ]);
+        expect(service.symbols[1]).toMatchObject({ parent: 'Service', included: true });
+        expect(service.symbols[1].signature).toContain('fetch(url: string)');
+        expect(service.symbols[1].tokens).toBeGreaterThan(0);
+    });
+
+    test('marks partial files and the symbols left out', () => {
+        const partial = results.map(fileInfo => fileInfo.relativePath !== 'src/service.ts' ? fileInfo : {
+            ...fileInfo,
+            selectionNote: 'Selected symbols',
+            selectedSymbols: [{ name: 'Service.fetch', kind: 'method', startLine: 4, endLine: 6 }]
+        });
+        const service = new StructuredFormatter(root, calculator.stats, partial).build().files[1];
+
+        expect(service).toMatchObject({ included: 'partial', note: 'Selected symbols' });
+        expect(service.ranges).toEqual([{ name: 'Service.fetch', kind: 'method', role: 'selected', startLine: 4, endLine: 6 }]);
+        expect(service.symbols.filter(symbol => symbol.included).map(symbol => symbol.name)).toEqual(['Service.fetch']);
+        expect(service.content).toBe('    fetch(url: string) {\n        return parse(url);\n    }');
+    });
+
+    test('encodes YAML with block literals and writes files', () => {
+        const formatter = calculator.createStructuredFormatter(results);
+        const yaml = formatter.encode('yaml');
+
+        expect(yaml.startsWith(`schema: ${STRUCTURED_SCHEMA}\n`)).toBe(true);
+        expect(yaml).toContain('content: |\n');
+        expect(yaml).toContain('\n      export function parse(text: string): string {\n');
+        expect(yaml).toContain('target: null');
+
+        calculator.saveStructuredOutput(results);
+        const saved = JSON.parse(fs.readFileSync(path.join(root, 'context.json'), 'utf8'));
+        expect(saved.files).toHaveLength(3);
+        expect(() => formatter.encode('csv')).toThrow('Unsupported structured format');
+    });
+});
+
+describe('Markdown context', () => {
+    let root;
+    let calculator;
+    let results;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-markdown-'));
+        const files = { ...FILES, 'docs/guide.md': 'Run ```npm test``` first.\n' };
+        for (const [file, content] of Object.entries(files)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        calculator = new TokenCalculator(root, { structuredFormat: 'markdown', dashboard: true });
+        results = Object.keys(files).map(f => calculator.analyzeFile(path.join(root, f)));
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('lists sections and files with token counts in a table of contents', () => {
+        const markdown = calculator.createStructuredFormatter(results).encode('markdown');
+        const tokensOf = file => results.find(fileInfo => fileInfo.relativePath === path.join(...file.split('/'))).tokens;
+        const srcTokens = tokensOf('src/service.ts') + tokensOf('src/util.ts');
+
+        expect(markdown.startsWith(`# ${path.basename(root)} context\n`)).toBe(true);
+        expect(markdown).toContain('## Contents\n\n- [`(root)`](#root) — 1 file,');
+        expect(markdown).toContain(`- [\`src/\`](#src) — 2 files, ${srcTokens} tokens\n`);
+        expect(markdown).toContain(`  - [\`src/service.ts\`](#srcservicets) — ${tokensOf('src/service.ts')} tokens\n`);
+        expect(markdown.indexOf('## `(root)`')).toBeLessThan(markdown.indexOf('## `docs/`'));
+        expect(markdown.endsWith('```\n')).toBe(true);
+    });
+
+    test('fences contents with language tags and longer fences when needed', () => {
+        const markdown = calculator.createStructuredFormatter(results).encode('markdown');
+
+        expect(markdown).toContain('### `src/util.ts`\n\n_');
+        expect(markdown).toContain('```typescript\nexport function parse(text: string): string {\n    return text.trim();\n}\n```');
+        expect(markdown).toContain('````markdown\nRun ```npm test``` first.\n````');
+        expect(markdown).not.toContain('<details>');
+    });
+
+    test('wraps contents in details blocks and marks partial files', () => {
+        const partial = results.map(fileInfo => fileInfo.relativePath !== path.join('src', 'service.ts') ? fileInfo : {
+            ...fileInfo,
+            selectionNote: 'Selected symbols',
+            selectedSymbols: [{ name: 'Service.fetch', kind: 'method', startLine: 4, endLine: 6 }]
+        });
+        const markdown = new StructuredFormatter(root, calculator.stats, partial, { collapsible: true }).encode('markdown');
+
+        expect(markdown).toMatch(/### `src\/service\.ts`\n\n> Selected symbols\n\n<details>\n<summary>\d+ tokens · 12 lines · typescript · partial: Service\.fetch<\/summary>\n\n```typescript\n    fetch/);
+        expect(markdown).toContain('```\n\n</details>\n');
+    });
+
+    test('numbers repeated heading slugs and writes context.md', () => {
+        const doc = {
+            project: { name: 'demo', files: 2, tokens: 3 },
+            selection: { mode: 'all', target: null, budget: null },
+            files: [
+                { path: 'a/b.js', language: 'javascript', tokens: 1, lines: 1, included: 'full', note: null, ranges: null, content: 'x' },
+                { path: 'ab.js', language: 'javascript', tokens: 2, lines: 1, included: 'full', note: null, ranges: null, content: 'y' }
+            ]
+        };
+        const markdown = new FormatRegistry().encode('markdown', doc);
+        expect(markdown).toContain('[`ab.js`](#abjs)');
+        expect(markdown).toContain('[`a/b.js`](#abjs-1)');
+
+        calculator.saveStructuredOutput(results);
+        expect(fs.readFileSync(path.join(root, 'context.md'), 'utf8')).toContain('## Contents');
+    });
+});
+
+describe('FormatRegistry YAML scalars', () => {
+    test('quotes ambiguous scalars and keys', () => {
+        const yaml = new FormatRegistry().encode('yaml', { 'a b': 'yes', n: '42', s: 'a: b', ok: 'plain' });
+
+        expect(yaml).toContain('"a b": "yes"');
+        expect(yaml).toContain('n: "42"');
+        expect(yaml).toContain('s: "a: b"');
+        expect(yaml).toContain('ok: plain');
+    });
+});
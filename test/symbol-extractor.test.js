@@ -0,0 +1,312 @@
+import { describe, test, expect } from 'vitest';
+import fs from 'fs';
+import path from 'path';
+import { fileURLToPath } from 'url';
+import SymbolExtractor from '../lib/symbols/SymbolExtractor.js';
+import { SymbolKind } from '../lib/symbols/SymbolModel.js';
+import { SourceScanner, findIndentBlockEnd, extractDocstring } from '../lib/symbols/SourceScanner.js';
+import Analyzer from '../lib/core/Analyzer.js';
+
+const __dirname = path.dirname(fileURLToPath(import.meta.url));
+const FIXTURES = path.join(__dirname, 'fixtures');
+const SYMBOL_FIELDS = [
+    'name', 'qualifiedName', 'kind', 'language', 'file',
+    'startLine', 'endLine', 'signature', 'doc', 'parent', 'exported'
+];
+
+function byName(symbols, qualifiedName) {
+    return symbols.find(s => s.qualifiedName === qualifiedName);
+}
+
+describe('SymbolExtractor', () => {
+    const extractor = new SymbolExtractor({ backend: 'heuristic' });
+
+    test('routes files to language plugins by extension', () => {
+        expect(extractor.supports('a.py')).toBe(true);
+        expect(extractor.supports('a.ts')).toBe(true);
+        expect(extractor.supports('a.rs')).toBe(true);
+        expect(extractor.supports('A.java')).toBe(true);
+        expect(extractor.supports('a.go')).toBe(true);
+        expect(extractor.supports('a.txt')).toBe(false);
+        expect(extractor.getBackend('a.py')).toBe('heuristic');
+        expect(extractor.extract('hello', 'notes.txt')).toEqual([]);
+    });
+
+    test('emits the same symbol shape for every language', () => {
+        const files = ['sample.py', 'SampleClass.java', 'sample.rs'].map(f => path.join(FIXTURES, f));
+        files.push(path.join(__dirname, 'sample.go'));
+
+        for (const file of files) {
+            const symbols = extractor.extract(fs.readFileSync(file, 'utf8'), file);
+            expect(symbols.length).toBeGreaterThan(0);
+            for (const symbol of symbols) {
+                for (const field of SYMBOL_FIELDS) {
+                    expect(symbol).toHaveProperty(field);
+                }
+                expect(symbol.endLine).toBeGreaterThanOrEqual(symbol.startLine);
+            }
+        }
+    });
+
+    describe('Python', () => {
+        const source = [
+            '"""Module docs."""',
+            '__all__ = ["Greeter"]',
+            '',
+            'class Greeter:',
+            '    """Says hello."""',
+            '',
+            '    @staticmethod',
+            '    def greet(name: str,',
+            '              loud: bool = False) -> str:',
+            '        """Return a greeting."""',
+            '        def helper():',
+            '            return name',
+            '        return helper()',
+            '',
+            '    def _private(self): return 1',
+            '',
+            'def util():',
+            '    pass',
+            ''
+        ].join('\n');
+
+        test('extracts classes, methods and docstrings', () => {
+            const symbols = extractor.extract(source, 'greeter.py');
+
+            const module = symbols.find(s => s.kind === SymbolKind.MODULE);
+            expect(module.doc).toBe('Module docs.');
+
+            const cls = byName(symbols, 'Greeter');
+            expect(cls.kind).toBe(SymbolKind.CLASS);
+            expect(cls.doc).toBe('Says hello.');
+            expect(cls.endLine).toBe(15);
+
+            const greet = byName(symbols, 'Greeter.greet');
+            expect(greet.kind).toBe(SymbolKind.METHOD);
+            expect(greet.startLine).toBe(7);
+            expect(greet.endLine).toBe(13);
+            expect(greet.signature).toBe('def greet(name: str, loud: bool = False) -> str');
+            expect(greet.doc).toBe('Return a greeting.');
+
+            // nested functions are not symbols
+            expect(symbols.some(s => s.name === 'helper')).toBe(false);
+        });
+
+        test('uses __all__ for exports', () => {
+            const symbols = extractor.extract(source, 'greeter.py');
+            expect(byName(symbols, 'Greeter').exported).toBe(true);
+            expect(byName(symbols, 'Greeter._private').exported).toBe(false);
+            expect(byName(symbols, 'util').exported).toBe(false);
+        });
+    });
+
+    describe('TypeScript', () => {
+        const source = [
+            '/** Service options. */',
+            'export interface Options {',
+            '  retry(count: number): void',
+            '}',
+            '',
+            '@Injectable()',
+            'export class Service {',
+            '  constructor(private opts: Options) {}',
+            '',
+            '  /** Fetch one item. */',
+            '  async fetch(id: string): Promise<string> {',
+            '    const s = `}`;',
+            '    return s;',
+            '  }',
+            '',
+            '  private reset(): void {}',
+            '}',
+            '',
+            'export const add = (a: number, b: number): number => a + b;',
+            'function internal() {}',
+            ''
+        ].join('\n');
+
+        test('extracts interfaces, classes and members', () => {
+            const symbols = extractor.extract(source, 'service.ts');
+
+            expect(byName(symbols, 'Options').kind).toBe(SymbolKind.INTERFACE);
+            expect(byName(symbols, 'Options').doc).toBe('Service options.');
+            expect(byName(symbols, 'Options.retry').kind).toBe(SymbolKind.METHOD);
+
+            const service = byName(symbols, 'Service');
+            expect(service.startLine).toBe(6);
+            expect(service.endLine).toBe(17);
+
+            const fetch = byName(symbols, 'Service.fetch');
+            expect(fetch.doc).toBe('Fetch one item.');
+            expect(fetch.endLine).toBe(14);
+            expect(fetch.signature).toBe('async fetch(id: string): Promise<string>');
+            expect(byName(symbols, 'Service.reset').exported).toBe(false);
+        });
+
+        test('tracks exports and arrow functions', () => {
+            const symbols = extractor.extract(source, 'service.ts');
+            expect(byName(symbols, 'add').kind).toBe(SymbolKind.FUNCTION);
+            expect(byName(symbols, 'add').exported).toBe(true);
+            expect(byName(symbols, 'internal').exported).toBe(false);
+            expect(symbols[0].language).toBe('typescript');
+            expect(extractor.extract(source, 'service.js')[0].language).toBe('javascript');
+        });
+    });
+
+    describe('Rust', () => {
+        test('attaches impl methods to their type and records traits', () => {
+            const source = [
+                '/// A point.',
+                '#[derive(Debug)]',
+                'pub struct Point { x: i32 }',
+                '',
+                "impl<'a> Display for Point {",
+                "    fn fmt(&self, f: &mut Formatter<'_>) -> Result {",
+                "        write!(f, \"{}\", '}')",
+                '    }',
+                '}',
+                '',
+                'pub trait Shape {',
+                '    fn area(&self) -> f64;',
+                '}',
+                ''
+            ].join('\n');
+
+            const symbols = extractor.extract(source, 'point.rs');
+            const point = byName(symbols, 'Point');
+            expect(point.kind).toBe(SymbolKind.STRUCT);
+            expect(point.startLine).toBe(2);
+            expect(point.doc).toBe('A point.');
+
+            const fmt = byName(symbols, 'Point.fmt');
+            expect(fmt.kind).toBe(SymbolKind.METHOD);
+            expect(fmt.endLine).toBe(8);
+            expect(fmt.implements).toEqual(['Display']);
+
+            const area = byName(symbols, 'Shape.area');
+            expect(area.startLine).toBe(12);
+            expect(area.endLine).toBe(12);
+            expect(area.exported).toBe(true);
+        });
+    });
+
+    describe('Java', () => {
+        test('extracts nested types, constructors and javadoc', () => {
+            const symbols = extractor.extract(
+                fs.readFileSync(path.join(FIXTURES, 'SampleClass.java'), 'utf8'),
+                'SampleClass.java'
+            );
+
+            expect(byName(symbols, 'SampleClass').kind).toBe(SymbolKind.CLASS);
+            expect(byName(symbols, 'SampleClass.SampleClass').doc).toBe('Constructor');
+            expect(byName(symbols, 'SampleClass.validateAge').exported).toBe(false);
+            expect(byName(symbols, 'SampleClass.createList').signature)
+                .toBe('public <T> List<T> createList(T... items)');
+
+            const nested = extractor.extract([
+                'public class Outer {',
+                '    interface Inner {',
+                '        void run();',
+                '    }',
+                '}'
+            ].join('\n'), 'Outer.java');
+            expect(byName(nested, 'Outer.Inner.run').kind).toBe(SymbolKind.METHOD);
+        });
+    });
+
+    describe('Go', () => {
+        test('extracts receivers, interfaces and grouped types', () => {
+            const source = [
+                'package shapes',
+                '',
+                'type (',
+                '\t// Circle is round.',
+                '\tCircle struct {',
+                '\t\tR float64',
+                '\t}',
+                '\tID int',
+                ')',
+                '',
+                '// Area computes the area.',
+                'func (c *Circle) Area() float64 {',
+                '\treturn c.R * c.R',
+                '}',
+                ''
+            ].join('\n');
+
+            const symbols = extractor.extract(source, 'shapes.go');
+            expect(byName(symbols, 'shapes').kind).toBe(SymbolKind.MODULE);
+            expect(byName(symbols, 'Circle').doc).toBe('Circle is round.');
+            expect(byName(symbols, 'Circle').endLine).toBe(7);
+            expect(byName(symbols, 'ID').kind).toBe(SymbolKind.TYPE);
+            expect(byName(symbols, 'Circle.Area').doc).toBe('Area computes the area.');
+
+            const sample = extractor.extract(fs.readFileSync(path.join(__dirname, 'sample.go'), 'utf8'), 'sample.go');
+            expect(byName(sample, 'Reader.Read').endLine).toBe(byName(sample, 'Reader.Read').startLine);
+            expect(sample.find(s => s.kind === SymbolKind.FUNCTION && s.name === 'main').exported).toBe(false);
+        });
+    });
+
+    test('rejects an explicit tree-sitter backend when the runtime is missing', async () => {
+        const strict = new SymbolExtractor({
+            backend: 'tree-sitter',
+            treeSitter: { runtime: 'ctxman-missing-tree-sitter-runtime' }
+        });
+        await expect(strict.initialize()).rejects.toThrow('tree-sitter');
+
+        const auto = new SymbolExtractor({ treeSitter: { runtime: 'ctxman-missing-tree-sitter-runtime' } });
+        await auto.initialize();
+        expect(auto.getBackend('a.rs')).toBe('heuristic');
+    });
+
+    test('legacy method shape is derived from symbols', () => {
+        const plugin = extractor.getPlugin('a.go');
+        const methods = plugin.extractMethods('func (c *Calc) Add() {}\nfunc Sub() {}\n', 'calc.go');
+        expect(methods).toEqual([
+            { name: 'Calc.Add', line: 1, file: 'calc.go' },
+            { name: 'Sub', line: 2, file: 'calc.go' }
+        ]);
+    });
+});
+
+describe('SourceScanner', () => {
+    test('ignores braces inside strings and comments', () => {
+        const scanner = new SourceScanner([
+            'function a() {',
+            '  const s = "{";',
+            '  // }',
+            '  /* } */',
+            '}',
+            'function b() {}'
+        ].join('\n'));
+
+        expect(scanner.findBlockEnd(0)).toMatchObject({ endLine: 4, hasBody: true });
+        expect(scanner.findBlockEnd(5)).toMatchObject({ endLine: 5, hasBody: true });
+    });
+
+    test('finds indentation block ends and docstrings', () => {
+        const lines = ['def f():', '    """Doc', '', '    more."""', '    return 1', 'x = 1'];
+        expect(findIndentBlockEnd(lines, 0)).toBe(4);
+        expect(extractDocstring(lines, 1)).toBe('Doc\n\nmore.');
+    });
+});
+
+describe('Analyzer symbols option', () => {
+    test('attaches symbols to supported files', async () => {
+        const analyzer = new Analyzer({ symbols: true, symbolBackend: 'heuristic' });
+        const file = path.join(FIXTURES, 'sample.py');
+        const result = await analyzer.analyze([{
+            path: file,
+            relativePath: 'test/fixtures/sample.py',
+            name: 'sample.py',
+            extension: '.py',
+            size: fs.statSync(file).size
+        }]);
+
+        const symbols = result.files[0].symbols;
+        expect(symbols.length).toBeGreaterThan(0);
+        expect(symbols[0].file).toBe('test/fixtures/sample.py');
+        expect(result.stats.totalSymbols).toBe(symbols.length);
+    });
+});
@@ -0,0 +1,99 @@
+import { describe, test, expect } from 'vitest';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import SymbolSlicer from '../lib/graph/SymbolSlicer.js';
+import ContentCache from '../lib/cache/ContentCache.js';
+import { SymbolIdIndex, symbolId, signatureHash, parseSymbolId } from '../lib/symbols/SymbolId.js';
+
+const CALC = [
+    'package calc',
+    '',
+    'type Calculator struct {',
+    '\ttotal float64',
+    '}',
+    '',
+    'func (c *Calculator) Add(v float64) {',
+    '\tc.total += v',
+    '}',
+    '',
+    'func Double(v float64) float64 {',
+    '\treturn v * 2',
+    '}',
+    ''
+].join('\n');
+
+const graphOf = (files, options = {}) => new DependencyGraph({ root: '/nonexistent', symbolBackend: 'heuristic', ...options })
+    .build(Object.entries(files).map(([relativePath, content]) => ({ relativePath, content })));
+
+const idOf = (graph, qualifiedName) => {
+    const index = new SymbolIdIndex(graph);
+    return [...index.byId.keys()].find(id => parseSymbolId(id).qualifiedName === qualifiedName);
+};
+
+describe('SymbolId', () => {
+    test('builds IDs from package, kind, name and signature hash', () => {
+        const symbol = { name: 'Add', qualifiedName: 'Calculator.Add', kind: 'method', signature: 'func (c *Calculator) Add(v float64)' };
+        const id = symbolId(symbol, 'pkg/calc');
+
+        expect(id).toMatch(/^pkg\/calc#method:Calculator\.Add@[0-9a-f]{8}$/);
+        expect(parseSymbolId(id)).toEqual({ package: 'pkg/calc', kind: 'method', qualifiedName: 'Calculator.Add', hash: signatureHash(symbol) });
+        expect(parseSymbolId('Calculator.Add')).toBeNull();
+        expect(parseSymbolId('pkg#widget:A@0123abcd')).toBeNull();
+    });
+
+    test('hashes signatures without the symbol name', () => {
+        const add = { name: 'Add', kind: 'function', signature: 'func Add(a, b int) int' };
+        expect(signatureHash({ ...add, name: 'Sum', signature: 'func Sum(a,  b int) int' })).toBe(signatureHash(add));
+        expect(signatureHash({ ...add, signature: 'func Add(a, b int64) int64' })).not.toBe(signatureHash(add));
+        expect(signatureHash({ ...add, kind: 'method' })).not.toBe(signatureHash(add));
+    });
+
+    test('resolves unchanged IDs directly', () => {
+        const graph = graphOf({ 'pkg/calc/calc.go': CALC });
+        const id = idOf(graph, 'Calculator.Add');
+
+        expect(new SymbolIdIndex(graph).resolve(id)).toMatchObject({ id, remappedFrom: null, symbol: { file: 'pkg/calc/calc.go' } });
+    });
+
+    test('remaps IDs of moved and renamed symbols', () => {
+        const before = graphOf({ 'pkg/calc/calc.go': CALC });
+        const addId = idOf(before, 'Calculator.Add');
+        const doubleId = idOf(before, 'Double');
+
+        // The package moved to pkg/math and Double became Twice
+        const after = graphOf({ 'pkg/math/calc.go': CALC.replace('func Double', 'func Twice') });
+        const index = new SymbolIdIndex(after);
+
+        expect(index.resolve(addId)).toMatchObject({ id: addId.replace('pkg/calc', 'pkg/math'), remappedFrom: addId });
+        expect(index.resolve(doubleId).symbol).toMatchObject({ qualifiedName: 'Twice', file: 'pkg/math/calc.go' });
+        expect(index.remapped.map(({ from }) => from)).toEqual([addId, doubleId]);
+    });
+
+    test('leaves ambiguous IDs unresolved', () => {
+        const before = graphOf({ 'pkg/calc/calc.go': CALC });
+        const doubleId = idOf(before, 'Double');
+
+        const after = graphOf({
+            'pkg/a/calc.go': CALC.replace('func Double', 'func Twice'),
+            'pkg/b/calc.go': CALC.replace('func Double', 'func Dup')
+        });
+        expect(new SymbolIdIndex(after).resolve(doubleId)).toBeNull();
+    });
+
+    test('remembers remaps in the content cache', () => {
+        const cache = new ContentCache({ path: null });
+        const before = graphOf({ 'pkg/calc/calc.go': CALC });
+        const doubleId = idOf(before, 'Double');
+
+        const moved = graphOf({ 'pkg/math/calc.go': CALC.replace('func Double', 'func Twice') }, { cache });
+        const movedId = new SymbolIdIndex(moved).resolve(doubleId).id;
+        expect(cache.symbolId(doubleId)).toBe(movedId);
+
+        // A second move updates the remembered ID
+        const again = graphOf({ 'pkg/num/calc.go': CALC.replace('func Double', 'func Twice') }, { cache });
+        const slice = new SymbolSlicer(again).slice([doubleId]);
+        expect(slice.symbols.map(symbol => symbol.file)).toEqual(['pkg/num/calc.go']);
+        expect(slice.remapped).toEqual([{ from: doubleId, to: movedId.replace('pkg/math', 'pkg/num') }]);
+        expect(cache.symbolId(doubleId)).toBe(slice.remapped[0].to);
+        expect(SymbolSlicer.formatSlice(slice)).toContain(`Remapped: ${doubleId} → `);
+    });
+});
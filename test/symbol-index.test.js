@@ -0,0 +1,58 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import SymbolIndex from '../lib/symbols/SymbolIndex.js';
+import { SymbolExtractor } from '../lib/symbols/SymbolExtractor.js';
+
+describe('SymbolIndex', () => {
+    const extractor = new SymbolExtractor({ backend: 'heuristic' });
+    let root;
+
+    const write = (file, content) => {
+        fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+        fs.writeFileSync(path.join(root, file), content);
+    };
+    const projectFiles = () => ['go.mod', 'internal/auth/auth.go', 'internal/store/store.go', 'internal2/cache.go', 'src/view.ts']
+        .filter(file => fs.existsSync(path.join(root, file)))
+        .map(file => ({ path: path.join(root, file), relativePath: file }));
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-symbols-'));
+        write('go.mod', 'module example.com/app\n\ngo 1.21\n');
+        write('internal/auth/auth.go', 'package auth\n\ntype Authenticator interface {\n\tLogin(user string) error\n}\n\nfunc NewSession() {}\n');
+        write('internal/store/store.go', 'package store\n\ntype Store interface {\n\tGet(key string) string\n}\n');
+        write('internal2/cache.go', 'package internal2\n\ntype Cache interface {\n\tPut(key string)\n}\n');
+        write('src/view.ts', 'export interface Props { a: number }\nexport function render(p: Props) { return p; }\nfunction helper() {}\n');
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('queries by kind, package, name and export', () => {
+        const index = new SymbolIndex();
+        expect(index.refresh(projectFiles(), extractor)).toEqual({ indexed: 4, unchanged: 0, removed: 0 });
+
+        const interfaces = index.query({ kinds: ['interface'], packages: ['./internal/...'] });
+        expect(interfaces.map(symbol => symbol.name)).toEqual(['Authenticator', 'Store']);
+        expect(interfaces[0].id).toMatch(/^internal\/auth#interface:Authenticator@[0-9a-f]{8}$/);
+
+        expect(index.query({ packages: ['internal/store'] }).map(symbol => symbol.qualifiedName)).toEqual(['Store', 'Store.Get']);
+        expect(index.query({ name: 'render*' }).map(symbol => symbol.file)).toEqual(['src/view.ts']);
+        expect(index.query({ packages: ['src/...'], exported: true }).map(symbol => symbol.name)).toEqual(['Props', 'render']);
+    });
+
+    test('re-extracts only changed files and drops removed ones', () => {
+        const index = new SymbolIndex();
+        index.refresh(projectFiles(), extractor);
+
+        write('internal/auth/auth.go', fs.readFileSync(path.join(root, 'internal/auth/auth.go'), 'utf8') + '\nfunc Logout() {}\n');
+        fs.rmSync(path.join(root, 'internal2'), { recursive: true });
+
+        expect(index.refresh(projectFiles(), extractor)).toEqual({ indexed: 1, unchanged: 2, removed: 1 });
+        expect(index.query({ name: 'Logout' })).toHaveLength(1);
+        expect(index.query({ name: 'Cache' })).toEqual([]);
+        expect(index.count().files).toBe(3);
+    });
+});
@@ -0,0 +1,52 @@
+import { describe, test, expect, beforeAll } from 'vitest';
+import fs from 'fs';
+import path from 'path';
+import { fileURLToPath } from 'url';
+import SymbolOutline, { OUTLINE_SCHEMA } from '../lib/symbols/SymbolOutline.js';
+import { SymbolExtractor } from '../lib/symbols/SymbolExtractor.js';
+
+const TEST_DIR = path.dirname(fileURLToPath(import.meta.url));
+
+describe('SymbolOutline', () => {
+    let outliner;
+
+    beforeAll(async () => {
+        outliner = new SymbolOutline({ extractor: await new SymbolExtractor({ backend: 'heuristic' }).initialize() });
+    });
+
+    test('nests methods under their class with LSP ranges and hovers', () => {
+        const content = fs.readFileSync(path.join(TEST_DIR, 'fixtures', 'sample.py'), 'utf8');
+        const outline = outliner.outline(content, 'scripts/sample.py');
+
+        expect(outline).toMatchObject({ schema: OUTLINE_SCHEMA, file: 'scripts/sample.py', language: 'python', backend: 'heuristic' });
+        const calculator = outline.symbols.find(symbol => symbol.name === 'Calculator');
+        expect(calculator).toMatchObject({ kind: 'class', lspKind: 5, startLine: 5, endLine: 30 });
+        expect(calculator.range.start).toEqual({ line: 4, character: 0 });
+        expect(calculator.selectionRange).toEqual({ start: { line: 4, character: 6 }, end: { line: 4, character: 16 } });
+
+        const add = calculator.children.find(symbol => symbol.name === 'add');
+        expect(add).toMatchObject({ qualifiedName: 'Calculator.add', kind: 'method', lspKind: 6, doc: 'Add two numbers', children: [] });
+        expect(add.range.start).toEqual({ line: 8, character: 4 });
+        expect(add.hover).toBe('```python\ndef add(self, x, y)\n```\n\nAdd two numbers');
+        expect(outline.symbols.map(symbol => symbol.name)).not.toContain('add');
+    });
+
+    test('nests by enclosing lines', () => {
+        const roots = SymbolOutline.nest([
+            { name: 'inner', startLine: 3, endLine: 4 },
+            { name: 'outer', startLine: 2, endLine: 8 },
+            { name: 'Type', startLine: 2, endLine: 10 },
+            { name: 'after', startLine: 12, endLine: 14 }
+        ]);
+        expect(roots.map(node => node.name)).toEqual(['Type', 'after']);
+        expect(roots[0].children[0].name).toBe('outer');
+        expect(roots[0].children[0].children.map(node => node.name)).toEqual(['inner']);
+    });
+
+    test('formats an indented outline', () => {
+        const content = fs.readFileSync(path.join(TEST_DIR, 'sample.go'), 'utf8');
+        const text = SymbolOutline.formatText(outliner.outline(content, 'sample.go'));
+        expect(text).toContain('📑 sample.go (go, heuristic, ');
+        expect(text).toContain('\n  type Reader interface (L47-50) — Reader interface for reading operations\n    Read(p []byte) (n int, err error) (L48)\n');
+    });
+});
@@ -0,0 +1,183 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import DependencyGraph from '../lib/graph/DependencyGraph.js';
+import SymbolSlicer, { SliceRole } from '../lib/graph/SymbolSlicer.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+import { GoPlugin } from '../lib/languages/GoPlugin.js';
+import { JavaPlugin } from '../lib/languages/JavaPlugin.js';
+import { TypeScriptPlugin } from '../lib/languages/TypeScriptPlugin.js';
+
+const FILES = {
+    'go.mod': 'module example.com/app\n',
+    'pkg/calc/calc.go': [
+        'package calc',
+        '',
+        'import (',
+        '\t"fmt"',
+        '\t"math"',
+        ')',
+        '',
+        '// Calculator keeps a running total',
+        'type Calculator struct {',
+        '\ttotal float64',
+        '}',
+        '',
+        'func (c *Calculator) Add(v float64) {',
+        '\tc.total += v',
+        '}',
+        '',
+        'func (c *Calculator) Sqrt() float64 {',
+        '\treturn math.Sqrt(c.total)',
+        '}',
+        '',
+        'func Describe(c *Calculator) string {',
+        '\treturn fmt.Sprint(c.total)',
+        '}',
+        ''
+    ].join('\n'),
+    'pkg/calc/extra.go': [
+        'package calc',
+        '',
+        'func (c *Calculator) Reset() {',
+        '\tc.total = 0',
+        '}',
+        ''
+    ].join('\n'),
+    'src/shapes.ts': [
+        "import { Point } from './point';",
+        "import { unused } from './unused';",
+        '',
+        'export class Circle {',
+        '    constructor(private center: Point, private r: number) {}',
+        '',
+        '    area(): number {',
+        '        return Math.PI * this.r * this.r;',
+        '    }',
+        '',
+        '    move(to: Point) {',
+        '        this.center = to;',
+        '    }',
+        '}',
+        ''
+    ].join('\n'),
+    'src/point.ts': 'export interface Point {\n    x: number;\n    y: number;\n}\n',
+    'src/unused.ts': 'export const unused = 1;\n'
+};
+
+describe('LanguagePlugin preambles', () => {
+    test('Go keeps the package clause and whole import blocks', () => {
+        expect(new GoPlugin().extractPreamble(FILES['pkg/calc/calc.go'])).toMatchObject([
+            { startLine: 1, endLine: 1, imports: [] },
+            { startLine: 3, endLine: 6 }
+        ]);
+    });
+
+    test('TypeScript extends imports over multi-line name lists', () => {
+        const preamble = new TypeScriptPlugin().extractPreamble("import {\n  a,\n  b\n} from './x';\nimport y from 'y';\n");
+        expect(preamble.map(range => [range.startLine, range.endLine])).toEqual([[1, 4], [5, 5]]);
+    });
+
+    test('Java adds the package declaration', () => {
+        const preamble = new JavaPlugin().extractPreamble('package com.acme;\n\nimport java.util.List;\n\nclass A {}\n');
+        expect(preamble.map(range => range.startLine)).toEqual([1, 3]);
+    });
+});
+
+describe('SymbolSlicer', () => {
+    let root;
+    let slicer;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-slice-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+        const graph = new DependencyGraph({ root }).build(Object.keys(FILES).map(relativePath => ({ relativePath })));
+        slicer = new SymbolSlicer(graph);
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('resolves package-qualified names', () => {
+        expect(slicer.resolve(['pkg/calc.Calculator.Add']).symbols.map(s => s.qualifiedName)).toEqual(['Calculator.Add']);
+        expect(slicer.resolve(['example.com/app/pkg/calc.Describe']).symbols).toHaveLength(1);
+        expect(slicer.resolve(['src/shapes.Circle.area']).symbols.map(s => s.file)).toEqual(['src/shapes.ts']);
+        expect(slicer.resolve(['pkg/calc.Nope']).missing).toEqual(['pkg/calc.Nope']);
+    });
+
+    test('a Go method brings its receiver type and the package clause', () => {
+        const { files } = slicer.slice(['pkg/calc.Calculator.Add']);
+        expect(files).toHaveLength(1);
+        expect(files[0].ranges.map(r => [r.name, r.role, r.startLine, r.endLine])).toEqual([
+            ['imports', SliceRole.PREAMBLE, 1, 1],
+            ['Calculator', SliceRole.RECEIVER, 9, 11],
+            ['Calculator.Add', SliceRole.SELECTED, 13, 15]
+        ]);
+    });
+
+    test('a Go type brings its methods from every file of the package', () => {
+        const { files } = slicer.slice(['pkg/calc.Calculator']);
+        const calc = files.find(f => f.file === 'pkg/calc/calc.go');
+        const extra = files.find(f => f.file === 'pkg/calc/extra.go');
+
+        expect(calc.ranges.map(r => r.name)).toEqual(['imports', 'Calculator', 'Calculator.Add', 'Calculator.Sqrt']);
+        expect(calc.ranges[0].lines).toEqual(['package calc', '', 'import (', '\t"math"', ')']);
+        expect(extra.ranges.map(r => r.name)).toEqual(['imports', 'Calculator.Reset']);
+    });
+
+    test('a nested method keeps the class declaration and only used imports', () => {
+        const { files } = slicer.slice(['Circle.move']);
+        const excerpt = SymbolSlicer.excerpt(FILES['src/shapes.ts'], files[0].ranges);
+
+        expect(files[0].ranges.map(r => r.role)).toEqual([SliceRole.PREAMBLE, SliceRole.DECLARATION, SliceRole.SELECTED]);
+        expect(excerpt).toContain("import { Point } from './point';");
+        expect(excerpt).not.toContain('unused');
+        expect(excerpt).toContain('export class Circle {');
+        expect(excerpt).not.toContain('area()');
+    });
+
+    test('nested selections are not duplicated', () => {
+        const { files } = slicer.slice(['Circle.area', 'Circle']);
+        expect(files[0].ranges.filter(r => r.role !== SliceRole.PREAMBLE).map(r => r.name)).toEqual(['Circle']);
+        expect(SymbolSlicer.formatSlice(slicer.slice(['Describe']))).toContain('function Describe (lines 21-23)');
+    });
+});
+
+describe('TokenCalculator symbol selection', () => {
+    test('exports only the selected symbols', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-symbol-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+
+        try {
+            const calculator = new TokenCalculator(root, { symbols: ['pkg/calc.Calculator.Sqrt'], dashboard: true });
+            const results = Object.keys(FILES).map(f => calculator.analyzeFile(path.join(root, f)));
+
+            const selected = calculator.selectExportResults(results);
+            expect(selected.map(f => f.relativePath)).toEqual(['pkg/calc/calc.go']);
+            expect(calculator.generateLLMContext(selected).selection.symbols).toMatchObject([{ name: 'Calculator.Sqrt', line: 17 }]);
+
+            const contents = new GitIngestFormatter(root, calculator.stats, selected).generateFileContents();
+            expect(contents).toContain('// Selected symbols: showing 2 symbols');
+            expect(contents).toContain('// preamble: imports (lines 1-6)');
+            expect(contents).toContain('import (\n\t"math"\n)');
+            expect(contents).not.toContain('"fmt"');
+            expect(contents).toContain('// struct: Calculator (lines 9-11, receiver type)');
+            expect(contents).toContain('return math.Sqrt(c.total)');
+            expect(contents).not.toContain('func Describe');
+
+            const missing = new TokenCalculator(root, { symbols: ['Nope'], dashboard: true });
+            expect(missing.selectExportResults(results)).toBeNull();
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
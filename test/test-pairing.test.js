@@ -0,0 +1,111 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import TestPairing from '../lib/core/TestPairing.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+
+const FILES = {
+    'pkg/calc/calc.go': 'package calc\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n',
+    'pkg/calc/calc_test.go': [
+        'package calc',
+        '',
+        'import "testing"',
+        '',
+        'func TestAdd(t *testing.T) {',
+        '\tif Add(1, 2) != 3 {',
+        '\t\tt.Fatal("wrong sum")',
+        '\t}',
+        '}',
+        '',
+        'func BenchmarkAdd(b *testing.B) {}',
+        ''
+    ].join('\n'),
+    'pkg/other/calc.go': 'package other\n\nfunc Sub(a, b int) int {\n\treturn a - b\n}\n',
+    'lib/core/TokenBudget.js': 'export function plan() {\n    return [];\n}\n',
+    'lib/util/index.js': 'export const util = 1;\n',
+    'test/token-budget.test.js': "import { test } from 'vitest';\n\ntest('plans files', () => {});\nit.skip('drops files', () => {});\n"
+};
+
+describe('TestPairing', () => {
+    const pairing = new TestPairing(Object.keys(FILES));
+
+    test('recognizes test files by convention', () => {
+        expect(TestPairing.isTestFile('pkg/calc/calc_test.go')).toBe(true);
+        expect(TestPairing.isTestFile('pkg/calc/calc.go')).toBe(false);
+        expect(TestPairing.isTestFile('src/__tests__/parser.js')).toBe(true);
+        expect(TestPairing.isTestFile('src/parser.spec.ts')).toBe(true);
+        expect(TestPairing.isTestFile('tests/test_parser.py')).toBe(true);
+        expect(TestPairing.isTestFile('src/test/java/ParserTest.java')).toBe(true);
+        expect(TestPairing.isTestFile('README.md')).toBe(false);
+    });
+
+    test('pairs sources and tests in both directions', () => {
+        expect(pairing.pairsOf('pkg/calc/calc.go')).toEqual(['pkg/calc/calc_test.go']);
+        expect(pairing.pairsOf('pkg/calc/calc_test.go')).toEqual(['pkg/calc/calc.go']);
+        expect(pairing.pairsOf('pkg/other/calc.go')).toEqual([]);
+        expect(pairing.pairsOf('lib/core/TokenBudget.js')).toEqual(['test/token-budget.test.js']);
+        expect(pairing.pairsOf('test/token-budget.test.js')).toEqual(['lib/core/TokenBudget.js']);
+        expect(pairing.pairsOf('lib/util/index.js')).toEqual([]);
+    });
+
+    test('lists test names', () => {
+        expect(TestPairing.testNames(FILES['pkg/calc/calc_test.go'], 'calc_test.go')).toEqual(['TestAdd', 'BenchmarkAdd']);
+        expect(TestPairing.summary(FILES['test/token-budget.test.js'], 'token-budget.test.js')).toBe('// Tests: plans files, drops files');
+        expect(TestPairing.testNames('def test_parse():\n    pass\n\ndef helper():\n    pass\n', 'test_x.py')).toEqual(['test_parse']);
+        expect(TestPairing.testNames('    @Test\n    public void parsesInput() {}\n', 'ParserTest.java')).toEqual(['parsesInput']);
+    });
+});
+
+describe('TokenCalculator test pairing', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-pairs-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const select = (options, files = Object.keys(FILES)) => {
+        const calculator = new TokenCalculator(root, { dashboard: true, ...options });
+        const results = files.map(file => calculator.analyzeFile(path.join(root, file)));
+        return { calculator, selected: calculator.selectExportResults(results) };
+    };
+
+    test('adds the tests of focused files after them', () => {
+        const { calculator, selected } = select({ focus: 'pkg/calc/calc.go', pairTests: 'full' });
+
+        expect(selected.map(f => [f.relativePath, f.priority])).toEqual([['pkg/calc/calc.go', 100], ['pkg/calc/calc_test.go', 99]]);
+        expect(selected[1].selectionNote).toBe('Tests of pkg/calc/calc.go');
+        expect(calculator.generateLLMContext(selected).tests).toEqual({
+            mode: 'full',
+            pairs: [['pkg/calc/calc.go', 'pkg/calc/calc_test.go']],
+            untested: []
+        });
+    });
+
+    test('finds tests the scan skipped and reduces them to names', () => {
+        // The test directory is not among the analyzed files, as with an ignore rule
+        const scanned = Object.keys(FILES).filter(file => !file.startsWith('test/'));
+        const { selected } = select({ focus: 'lib/core/TokenBudget.js', pairTests: 'names' }, scanned);
+
+        expect(selected.map(f => f.relativePath)).toEqual(['lib/core/TokenBudget.js', path.join('test', 'token-budget.test.js')]);
+        const contents = new GitIngestFormatter(root, {}, selected).generateFileContents();
+        expect(contents).toContain('// Test names of lib/core/TokenBudget.js\n\n// Tests: plans files, drops files');
+    });
+
+    test('adds the source of a selected test', () => {
+        const { selected } = select({ focus: 'pkg/calc/calc_test.go', pairTests: 'full' });
+        expect(selected.map(f => [f.relativePath, f.selectionNote])).toEqual([
+            ['pkg/calc/calc_test.go', undefined],
+            ['pkg/calc/calc.go', 'Source of pkg/calc/calc_test.go']
+        ]);
+    });
+});
@@ -0,0 +1,70 @@
+import { describe, test, expect } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import TodoHarvester, { TODO_TITLE } from '../lib/core/TodoHarvester.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import StructuredFormatter from '../lib/formatters/structured-formatter.js';
+
+const JS_SOURCE = [
+    '// TODO(alice): retry on timeouts',
+    'export function fetch(url) {',
+    '  const label = "TODO: not a comment";',
+    '  /* FIXME: leaks sockets',
+    '   * under load */',
+    '  return get(url); // HACK skip the proxy',
+    '}',
+    'const TODO_LIST = [];',
+    ''
+].join('\n');
+
+describe('TodoHarvester', () => {
+    test('finds markers in comments only, with authors and surrounding lines', () => {
+        const entries = new TodoHarvester({ context: 1 }).harvest([{ path: 'src/net.js', content: JS_SOURCE }]);
+
+        expect(entries.map(entry => [entry.marker, entry.line, entry.text, entry.author])).toEqual([
+            ['TODO', 1, 'retry on timeouts', 'alice'],
+            ['FIXME', 4, 'leaks sockets', null],
+            ['HACK', 6, 'skip the proxy', null]
+        ]);
+        expect(entries[1].context).toEqual({ startLine: 3, lines: JS_SOURCE.split('\n').slice(2, 5) });
+        expect(new TodoHarvester().harvest([{ path: 'notes.txt', content: 'TODO: write notes\n' }])).toEqual([]);
+    });
+
+    test('keeps to included lines and lists other files after the context', () => {
+        const harvester = new TodoHarvester({ scope: 'repo', context: 0 });
+        const entries = harvester.harvest([
+            { path: 'src/net.js', content: JS_SOURCE, ranges: [{ startLine: 2, endLine: 7 }] },
+            { path: 'lib/a.py', content: 'def a():\n    # XXX: wrong on windows\n    return 1\n', included: false }
+        ]);
+
+        expect(entries.map(entry => `${entry.file}:${entry.line}`)).toEqual(['src/net.js:4', 'src/net.js:6', 'lib/a.py:2']);
+        expect(harvester.describe(entries)).toBe('📌 Markers: 3 (1 FIXME, 1 HACK, 1 XXX), 1 outside the context');
+
+        const section = harvester.format(entries);
+        expect(section).toContain(`${TODO_TITLE}\n${'='.repeat(48)}\nFIXME src/net.js:4 — leaks sockets\n> 4 |   /* FIXME: leaks sockets\n`);
+        expect(section).toContain('--- Outside this context ---\n\nXXX lib/a.py:2 — wrong on windows');
+        expect(() => new TodoHarvester({ scope: 'all' })).toThrow('Unknown --todos scope: all');
+    });
+
+    test('appends markers to digests and structured contexts', () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-todos-'));
+        fs.mkdirSync(path.join(root, 'src'));
+        fs.writeFileSync(path.join(root, 'src', 'net.js'), JS_SOURCE);
+
+        try {
+            const calculator = new TokenCalculator(root, { todoHarvester: new TodoHarvester(), dashboard: true });
+            const results = [calculator.analyzeFile(path.join(root, 'src', 'net.js'))];
+
+            const digest = calculator.createGitIngestFormatter(results).generateDigest();
+            expect(digest.indexOf(TODO_TITLE)).toBeGreaterThan(digest.indexOf('FILE: src/net.js'));
+            expect(digest).toContain('TODO src/net.js:1 (alice) — retry on timeouts');
+
+            const context = JSON.parse([...calculator.createStructuredFormatter(results).encodePieces('json')].join(''));
+            expect(context.todos.map(entry => entry.marker)).toEqual(['TODO', 'FIXME', 'HACK']);
+            expect(new StructuredFormatter(root, calculator.stats, results).build().todos).toBeUndefined();
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
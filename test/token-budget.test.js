@@ -0,0 +1,289 @@
+import { describe, test, expect, beforeAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import TokenBudget, { parseTokenCount, parseTiers } from '../lib/core/TokenBudget.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import GitIngestFormatter from '../lib/formatters/gitingest-formatter.js';
+import { TokenizerManager } from '../lib/utils/tokenizer-adapter.js';
+
+const PY_SOURCE = [
+    'def small():',
+    '    return 1',
+    '',
+    'class Big:',
+    '    def huge(self):',
+    `        return "${'x'.repeat(400)}"`,
+    '',
+    '    def tiny(self):',
+    '        return 2',
+    ''
+].join('\n');
+
+const GO_SOURCE = [
+    '// Package calc does arithmetic.',
+    'package calc',
+    '',
+    'type Calculator struct {',
+    '\ttotal float64',
+    '}',
+    '',
+    'func (c *Calculator) Add(v float64) float64 {',
+    `\tc.total += v // ${'x'.repeat(400)}`,
+    '\treturn c.total',
+    '}',
+    '',
+    'func clamp(v float64) float64 {',
+    '\treturn v',
+    '}',
+    ''
+].join('\n');
+
+describe('parseTokenCount', () => {
+    test('accepts plain numbers and k/m suffixes', () => {
+        expect(parseTokenCount('8000')).toBe(8000);
+        expect(parseTokenCount('32k')).toBe(32000);
+        expect(parseTokenCount('1.5M')).toBe(1500000);
+        expect(parseTokenCount(500)).toBe(500);
+    });
+
+    test('rejects invalid values', () => {
+        expect(parseTokenCount('abc')).toBeNull();
+        expect(parseTokenCount('0')).toBeNull();
+        expect(parseTokenCount(undefined)).toBeNull();
+        expect(() => new TokenBudget({ maxTokens: 'lots' })).toThrow('Invalid token budget');
+    });
+});
+
+describe('TokenBudget', () => {
+    let budget;
+
+    beforeAll(async () => {
+        budget = await TokenBudget.create({ maxTokens: 100, tokenizer: 'estimate' });
+    });
+
+    test('counts with per-extension estimates for the estimate tokenizer', () => {
+        expect(budget.getTokenizerName()).toContain('Estimation');
+        expect(budget.count('x'.repeat(300), 'a.py')).toBe(100);
+    });
+
+    test('packs by priority and reports dropped items', () => {
+        const plan = budget.plan([
+            { id: 'test/a.test.js', tokens: 30, priority: -5 },
+            { id: 'lib/core/a.js', tokens: 60, priority: 18 },
+            { id: 'lib/b.js', tokens: 50, priority: 10 },
+            { id: 'README.md', tokens: 40, priority: 0 }
+        ]);
+
+        expect(plan.included.map(i => i.id)).toEqual(['lib/core/a.js', 'README.md']);
+        expect(plan.dropped.map(i => i.id)).toEqual(['lib/b.js', 'test/a.test.js']);
+        expect(plan.usedTokens).toBe(100);
+        expect(plan.remainingTokens).toBe(0);
+    });
+
+    test('reserve lowers the limit', () => {
+        const reserved = new TokenBudget({ maxTokens: '1k', reserve: 200 });
+        expect(reserved.limit).toBe(800);
+        expect(TokenBudget.formatReserve({ maxTokens: 1000, reserve: 200 })).toBe(' (1,000 - 200 reserved)');
+
+        // The task prompt's reserve comes off the window too
+        const prompted = new TokenBudget({ maxTokens: '1k', reserve: 200, reservePrompt: 50 });
+        expect(prompted.limit).toBe(750);
+        const plan = prompted.plan([{ id: 'a.py', tokens: 700, priority: 1 }]);
+        expect(plan).toMatchObject({ limit: 750, reserve: 250, reservePrompt: 50 });
+        expect(TokenBudget.formatPlan(plan)).toContain('Budget:    750 tokens (1,000 - 50 prompt - 200 answer reserved)');
+        expect(TokenBudget.formatReserve({ maxTokens: 1000, reserve: 50, reservePrompt: 50 })).toBe(' (1,000 - 50 prompt reserved)');
+    });
+
+    test('trims files that do not fit to their symbols', () => {
+        const symbols = budget.symbolsFor(PY_SOURCE, 'lib/big.py');
+        expect(symbols.map(s => s.name)).toEqual(['small', 'Big.huge', 'Big.tiny']);
+
+        const plan = budget.plan([{ id: 'lib/big.py', tokens: 200, priority: 10 }], {
+            expand: () => symbols
+        });
+
+        expect(plan.included).toEqual([]);
+        expect(plan.partial).toHaveLength(1);
+        expect(plan.partial[0].symbols.map(s => s.name)).toEqual(['small', 'Big.tiny']);
+        expect(plan.partial[0].omittedSymbols).toBe(1);
+        expect(plan.partial[0].fullTokens).toBe(200);
+        expect(TokenBudget.formatPlan(plan)).toContain('Trimmed to symbols');
+    });
+
+    test('symbol fallback can be disabled', () => {
+        const strict = new TokenBudget({ maxTokens: 100, symbolFallback: false });
+        const plan = strict.plan([{ id: 'lib/big.py', tokens: 200 }], {
+            expand: () => budget.symbolsFor(PY_SOURCE, 'lib/big.py')
+        });
+        expect(plan.partial).toEqual([]);
+        expect(TokenBudget.formatPlan(plan)).toContain('lib/big.py (200 tokens)');
+    });
+
+    test('packs a Go type with its methods as one unit', () => {
+        const source = [
+            'package calc',
+            '',
+            'type Calculator struct {',
+            '\tvalue int',
+            '}',
+            '',
+            'func (c *Calculator) Add(n int) { c.value += n }',
+            '',
+            'func (c *Calculator) reset() { c.value = 0 }',
+            '',
+            'func Double(n int) int { return n * 2 }',
+            ''
+        ].join('\n');
+
+        const symbols = budget.symbolsFor(source, 'calc/calc.go');
+        expect(symbols.map(s => s.name)).toEqual(['Calculator', 'Double']);
+        expect(symbols[0].members.map(s => s.name)).toEqual(['Calculator', 'Calculator.Add', 'Calculator.reset']);
+
+        const plan = budget.plan([{ id: 'calc/calc.go', tokens: 200, priority: 10 }], { expand: () => symbols });
+        expect(plan.partial[0].symbols.map(s => s.name)).toEqual(['Calculator', 'Calculator.Add', 'Calculator.reset', 'Double']);
+
+        const exported = new TokenBudget({ maxTokens: 100, tokenizer: 'estimate', exportedMethods: true });
+        expect(exported.symbolsFor(source, 'calc/calc.go')[0].members.map(s => s.name)).toEqual(['Calculator', 'Calculator.Add']);
+    });
+
+    test('parses tier lists', () => {
+        expect(parseTiers('full, Signatures,names')).toEqual(['full', 'signatures', 'names']);
+        expect(() => parseTiers('full,bodies')).toThrow('Unknown tier: bodies');
+        expect(() => parseTiers('full,full')).toThrow('Duplicate tier');
+        expect(() => parseTiers('')).toThrow('Invalid tiers');
+        expect(budget.tiers).toEqual(['full', 'symbols']);
+    });
+
+    test('summarizes files to package docs, exported signatures and type declarations', () => {
+        expect(budget.summaryFor(GO_SOURCE, 'pkg/calc.go', 'signatures')).toBe([
+            '// Package calc does arithmetic.',
+            'package calc',
+            '',
+            'type Calculator struct {',
+            '\ttotal float64',
+            '}',
+            'func (c *Calculator) Add(v float64) float64'
+        ].join('\n'));
+        expect(budget.summaryFor(GO_SOURCE, 'pkg/calc.go', 'names')).toBe('// Exports: Calculator, Calculator.Add');
+        expect(budget.summaryFor(PY_SOURCE, 'lib/big.py', 'signatures')).toBe([
+            'def small()',
+            'class Big',
+            '    def huge(self)',
+            '    def tiny(self)'
+        ].join('\n'));
+    });
+
+    test('degrades through tiers before dropping', () => {
+        const tiered = new TokenBudget({ maxTokens: 100, tokenizer: 'estimate', tiers: 'full,signatures,names' });
+        const summaries = {
+            signatures: tiered.summaryFor(GO_SOURCE, 'pkg/calc.go', 'signatures'),
+            names: 'n'.repeat(30)
+        };
+        const plan = tiered.plan([
+            { id: 'lib/core/a.js', tokens: 50, priority: 10 },
+            { id: 'pkg/calc.go', tokens: 200, priority: 5 },
+            { id: 'docs/b.md', tokens: 50, priority: -3 }
+        ], {
+            expand: () => { throw new Error('symbols tier not enabled'); },
+            summarize: (item, tier) => item.id === 'pkg/calc.go' ? summaries[tier] : null
+        });
+
+        expect(plan.included.map(i => i.id)).toEqual(['lib/core/a.js']);
+        expect(plan.partial).toEqual([]);
+        expect(plan.summarized.map(i => [i.id, i.tier, i.fullTokens])).toEqual([['pkg/calc.go', 'signatures', 200]]);
+        expect(plan.summarized[0].summary).toBe(summaries.signatures);
+        expect(plan.usedTokens).toBe(50 + plan.summarized[0].tokens);
+        expect(plan.dropped.map(i => i.id)).toEqual(['docs/b.md']);
+        expect(TokenBudget.formatPlan(plan)).toContain('pkg/calc.go — signatures');
+
+        // Too little room for signatures falls through to names
+        const tight = new TokenBudget({ maxTokens: 85, tokenizer: 'estimate', tiers: ['full', 'signatures', 'names'] });
+        const names = tight.plan([{ id: 'lib/core/a.js', tokens: 70, priority: 10 }, { id: 'pkg/calc.go', tokens: 200 }], {
+            summarize: (item, tier) => tight.summaryFor(GO_SOURCE, item.id, tier)
+        });
+        expect(names.summarized.map(i => i.tier)).toEqual(['names']);
+    });
+
+    test('default priority prefers core source over tests and docs', () => {
+        expect(TokenBudget.filePriority('lib/core/Analyzer.js'))
+            .toBeGreaterThan(TokenBudget.filePriority('lib/utils/x.js'));
+        expect(TokenBudget.filePriority('lib/utils/x.js'))
+            .toBeGreaterThan(TokenBudget.filePriority('docs/guide.md'));
+        expect(TokenBudget.filePriority('docs/guide.md'))
+            .toBeGreaterThan(TokenBudget.filePriority('test/a.test.js'));
+    });
+});
+
+describe('TokenizerManager.resolveTokenizer', () => {
+    let manager;
+
+    beforeAll(async () => {
+        manager = new TokenizerManager();
+        await manager.initialize();
+    });
+
+    test('resolves aliases to an available tokenizer', () => {
+        expect(manager.resolveTokenizer('estimate').getName()).toContain('Estimation');
+        expect(manager.resolveTokenizer('claude').isAvailable()).toBe(true);
+        expect(manager.resolveTokenizer('o200k_base').isAvailable()).toBe(true);
+    });
+
+    test('auto uses the model when given', () => {
+        expect(manager.resolveTokenizer('auto', 'claude-sonnet-4.5').getName()).toMatch(/Anthropic|Claude/);
+    });
+
+    test('rejects unknown tokenizers', () => {
+        expect(() => manager.resolveTokenizer('p50k')).toThrow('Unknown tokenizer: p50k');
+    });
+});
+
+describe('TokenCalculator token budget', () => {
+    test('exports only what fits and trims the rest to symbols', async () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-budget-'));
+        fs.mkdirSync(path.join(root, 'lib'));
+        fs.writeFileSync(path.join(root, 'lib', 'big.py'), PY_SOURCE);
+        fs.writeFileSync(path.join(root, 'notes.txt'), 'n'.repeat(2000));
+
+        try {
+            const tokenBudget = await TokenBudget.create({ maxTokens: 60, tokenizer: 'estimate' });
+            const calculator = new TokenCalculator(root, { tokenBudget, dashboard: true });
+            const results = ['lib/big.py', 'notes.txt'].map(f => calculator.analyzeFile(path.join(root, f)));
+
+            const selected = calculator.applyTokenBudget(results);
+            expect(selected.map(f => f.relativePath)).toEqual(['lib/big.py']);
+            expect(selected[0].selectedSymbols.map(s => s.name)).toEqual(['small', 'Big.tiny']);
+            expect(calculator.generateLLMContext(selected).budget.dropped).toEqual(['notes.txt']);
+
+            const formatter = new GitIngestFormatter(root, calculator.stats, selected);
+            const contents = formatter.generateFileContents();
+            expect(contents).toContain('// Trimmed to fit token budget: showing 2 symbols');
+            expect(contents).toContain('def tiny(self):');
+            expect(contents).not.toContain('def huge(self):');
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+
+    test('summarizes files that do not fit with --tiers', async () => {
+        const root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-tiers-'));
+        fs.mkdirSync(path.join(root, 'lib'));
+        fs.writeFileSync(path.join(root, 'lib', 'big.py'), PY_SOURCE);
+
+        try {
+            const tokenBudget = await TokenBudget.create({ maxTokens: 60, tokenizer: 'estimate', tiers: 'full,signatures' });
+            const calculator = new TokenCalculator(root, { tokenBudget, dashboard: true });
+            const selected = calculator.applyTokenBudget([calculator.analyzeFile(path.join(root, 'lib', 'big.py'))]);
+
+            expect(selected[0].summaryTier).toBe('signatures');
+            expect(calculator.generateLLMContext(selected).budget.summarized).toEqual(['lib/big.py']);
+
+            const contents = new GitIngestFormatter(root, calculator.stats, selected).generateFileContents();
+            expect(contents).toContain('// Summarized to fit token budget (signatures)');
+            expect(contents).toContain('    def huge(self)\n');
+            expect(contents).not.toContain('return 2');
+        } finally {
+            fs.rmSync(root, { recursive: true, force: true });
+        }
+    });
+});
@@ -0,0 +1,109 @@
+import { describe, test, expect, beforeAll, afterAll, afterEach } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import TokenEstimator, { CALIBRATION_FILE } from '../lib/core/TokenEstimator.js';
+import TokenCalibrator from '../lib/core/TokenCalibrator.js';
+import TokenUtils from '../lib/utils/token-utils.js';
+
+// Stand-in tokenizer: one token per 3 characters
+const REFERENCE = { target: 'cl100k_base', name: 'Test tokenizer', count: content => Math.ceil(content.length / 3) };
+
+describe('TokenEstimator', () => {
+    const estimator = new TokenEstimator();
+
+    test('picks a model per language', () => {
+        expect(TokenEstimator.languageOf('src/app.tsx')).toBe('typescript');
+        expect(TokenEstimator.languageOf('Cargo.lock')).toBe('other');
+        const data = 'x'.repeat(100);
+        expect(estimator.estimate(data, 'data.json')).toBeGreaterThan(estimator.estimate(data, 'app.js'));
+    });
+
+    test('counts identifier pieces, digits and punctuation', () => {
+        // get, User, Name and ();
+        expect(estimator.estimate('getUserName();', 'a.js')).toBe(5);
+        expect(estimator.estimate('1234567', 'a.js')).toBe(3);
+        expect(estimator.estimate('a\n    b', 'a.js')).toBe(4);
+    });
+
+    test('charges non-ASCII identifiers more than ASCII ones of the same length', () => {
+        const ascii = estimator.estimate('const kullaniciAdi = 1;', 'a.js');
+        expect(estimator.estimate('const kullanıcıAdı = 1;', 'a.js')).toBeGreaterThan(ascii);
+        expect(estimator.estimate('这是中文文档', 'a.md')).toBe(7);
+    });
+
+    test('splits acronyms, line breaks and surrogate pairs', () => {
+        // parse, XML, Http, Request; HTTP, Server
+        expect(TokenEstimator.rawEstimate('parseXMLHttpRequest HTTPServer', 'javascript')).toBe(9.125);
+        expect(TokenEstimator.rawEstimate('if (a) {\r\n\t\treturn 12345;\n}', 'javascript')).toBe(13.875);
+        expect(TokenEstimator.rawEstimate('é 中文 😀', 'javascript')).toBe(5.5);
+        // Lone \r, \v and \f are skipped
+        expect(TokenEstimator.rawEstimate('x\ry\vz  !==', 'javascript')).toBe(5.5);
+    });
+
+    test('applies the correction factor of the language', () => {
+        const calibrated = new TokenEstimator({ target: 'cl100k_base', factors: { javascript: 2 } });
+        expect(calibrated.estimate('getUserName();', 'a.js')).toBe(10);
+        expect(calibrated.estimate('get_user_name()', 'a.py')).toBe(estimator.estimate('get_user_name()', 'a.py'));
+        expect(calibrated.getName()).toBe('estimate (calibrated to cl100k_base)');
+        expect(calibrated.getKey()).not.toBe(estimator.getKey());
+    });
+});
+
+describe('TokenCalibrator', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-calibrate-'));
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    afterEach(() => {
+        TokenUtils.useEstimator(null);
+    });
+
+    test('samples every language before taking more of one', () => {
+        const files = ['a.js', 'b.js', 'c.js', 'd.js', 'main.go', 'README.md'];
+        const sample = new TokenCalibrator({ sampleSize: 3 }).sample(files);
+        expect(sample.map(file => TokenEstimator.languageOf(file)).sort()).toEqual(['go', 'javascript', 'markdown']);
+        expect(new TokenCalibrator({ sampleSize: 3 }).sample([...files].reverse())).toEqual(sample);
+    });
+
+    test('derives per-language factors that remove the estimation error', () => {
+        const source = 'export function parseConfig(input) {\n    return JSON.parse(input);\n}\n';
+        const samples = [1, 2, 3].map(i => ({ path: `src/m${i}.js`, content: source.repeat(i) }));
+        samples.push({ path: 'main.go', content: 'package main\n' });
+
+        const calibration = new TokenCalibrator().calibrate(samples, REFERENCE);
+        expect(calibration.files).toBe(4);
+        expect(Object.keys(calibration.factors)).toEqual(['javascript']);
+
+        const javascript = calibration.languages.find(entry => entry.language === 'javascript');
+        expect(javascript.actual).toBe(samples.slice(0, 3).reduce((sum, sample) => sum + REFERENCE.count(sample.content), 0));
+        expect(javascript.errorAfter).toBeLessThan(javascript.errorBefore);
+        // Too few Go files to correct
+        expect(calibration.languages.find(entry => entry.language === 'go').factor).toBe(null);
+        expect(TokenCalibrator.formatReport(calibration)).toContain('Tokenizer: Test tokenizer (cl100k_base)');
+    });
+
+    test('stores calibrations per tokenizer and estimates with them', () => {
+        const calibration = { target: 'cl100k_base', tokenizer: 'Test tokenizer', factors: { javascript: 1.5 } };
+        TokenEstimator.saveCalibration(root, calibration);
+        TokenEstimator.saveCalibration(root, { ...calibration, target: 'o200k_base', factors: { javascript: 0.5 } });
+        expect(fs.existsSync(path.join(root, CALIBRATION_FILE))).toBe(true);
+
+        expect(TokenEstimator.load(root, 'cl100k').factors).toEqual({ javascript: 1.5 });
+        // auto follows the latest calibration
+        expect(TokenEstimator.load(root, 'auto').calibration.target).toBe('o200k_base');
+        expect(TokenEstimator.load(root, 'claude').calibration).toBe(null);
+
+        const content = 'export const answer = computeAnswer(42);\n';
+        const uncalibrated = TokenUtils.estimate(content, 'a.js');
+        TokenUtils.useEstimator(TokenEstimator.load(root, 'cl100k_base'));
+        expect(TokenUtils.estimate(content, 'a.js')).toBe(Math.round(TokenEstimator.rawEstimate(content, 'javascript') * 1.5));
+        expect(TokenUtils.estimate(content, 'a.js')).toBeGreaterThan(uncalibrated);
+    });
+});
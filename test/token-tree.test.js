@@ -0,0 +1,57 @@
+import { describe, test, expect } from 'vitest';
+import TokenTree from '../lib/core/TokenTree.js';
+
+const FILES = [
+    { relativePath: 'src/core/engine.js', tokens: 600 },
+    { relativePath: 'src/core/cache.js', tokens: 200 },
+    { relativePath: 'src/index.js', tokens: 100 },
+    { relativePath: 'docs/guide.md', tokens: 50 },
+    { relativePath: 'README.md', tokens: 50 }
+];
+
+describe('TokenTree', () => {
+    test('sums tokens and files per directory', () => {
+        const root = new TokenTree().build(FILES, 'demo');
+        const src = root.children.get('src');
+
+        expect([root.tokens, root.files]).toEqual([1000, 5]);
+        expect([src.tokens, src.files, src.path]).toEqual([900, 3, 'src']);
+        expect(src.children.get('core').children.get('engine.js')).toEqual({
+            name: 'engine.js', path: 'src/core/engine.js', type: 'file', tokens: 600, files: 1
+        });
+    });
+
+    test('sorts by tokens with shares of all tokens', () => {
+        const lines = new TokenTree({ sort: 'tokens' }).format(FILES, 'demo').split('\n');
+
+        expect(lines[1]).toBe('🌳 TOKEN TREE (by tokens, % of all tokens)');
+        expect(lines.slice(3).map(line => line.replace(/\s+\S+\s+\S+%.*$/, ''))).toEqual([
+            'demo/',
+            '├── src/',
+            '│   ├── core/',
+            '│   │   ├── engine.js',
+            '│   │   └── cache.js',
+            '│   └── index.js',
+            '├── README.md',
+            '└── docs/',
+            '    └── guide.md'
+        ]);
+        expect(lines[4]).toMatch(/^├── src\/\s+900\s+90\.0%\s+█{9}░$/);
+    });
+
+    test('collapses deep directories and flags those over the budget', () => {
+        const report = new TokenTree({ depth: 1, budget: 800 }).format(FILES, 'demo');
+        const lines = report.split('\n');
+
+        expect(lines[1]).toBe('🌳 TOKEN TREE (by name, % of 800-token budget)');
+        expect(lines.slice(3, 7).map(line => line.split(/\s{2,}/)[0])).toEqual([
+            'demo/',
+            '├── README.md',
+            '├── docs/ (1 file)',
+            '└── src/ (3 files)'
+        ]);
+        expect(lines[6]).toMatch(/900\s+112\.5%\s+█{10}  ⚠️ over budget$/);
+        expect(report).toContain('⚠️  1,000 tokens analyzed, 200 over the budget');
+        expect(() => new TokenTree({ sort: 'size' })).toThrow('Invalid tree sort: size (expected name, tokens)');
+    });
+});
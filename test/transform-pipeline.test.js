@@ -0,0 +1,110 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import TransformPipeline, { registerTransform, normalizeWhitespace, DEFAULT_TRANSFORMS } from '../lib/core/TransformPipeline.js';
+import ContextProfiles from '../lib/core/ContextProfiles.js';
+import ContentStripper from '../lib/core/ContentStripper.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+import ContextEngine from '../lib/api/ContextEngine.js';
+
+const names = pipeline => pipeline.steps.map(step => step.name);
+
+describe('TransformPipeline', () => {
+    test('parses step lists and keeps emission steps last', () => {
+        expect(TransformPipeline.parse('licenses, strip,normalize-whitespace')).toEqual([
+            { name: 'licenses', options: {} },
+            { name: 'strip', options: {} },
+            { name: 'normalize-whitespace', options: {} }
+        ]);
+        expect(TransformPipeline.parse(['redact', { 'elide-bodies': { lines: 10 } }])[1]).toEqual({ name: 'elide-bodies', options: { lines: 10 } });
+        expect(() => TransformPipeline.parse('strip,strip')).toThrow('transform "strip" is listed twice');
+        expect(() => new TransformPipeline(['strip', 'minify'])).toThrow('Unknown transform "minify"');
+        expect(() => new TransformPipeline(['redact', 'strip'])).toThrow('Transform "strip" must come before redact');
+        expect(names(new TransformPipeline())).toEqual(DEFAULT_TRANSFORMS);
+
+        // Flags add their steps at the default position
+        const pipeline = new TransformPipeline(['normalize-whitespace', 'elide-bodies']);
+        pipeline.add('redact').add('licenses').add('redact');
+        expect(pipeline.describe()).toBe('normalize-whitespace → licenses → redact → elide-bodies');
+    });
+
+    test('normalizes whitespace outside of the content', () => {
+        expect(normalizeWhitespace('\n\na = 1;  \r\n\r\n\r\n\r\nb = 2;\t\n\n\n')).toBe('a = 1;\n\nb = 2;\n');
+        expect(normalizeWhitespace('a\n\n\n\nb', { blankLines: 0 })).toBe('a\nb\n');
+        expect(normalizeWhitespace('  \n\n')).toBe('');
+    });
+
+    test('runs built-in and custom steps in the listed order', () => {
+        const source = '// note\nconst token = "abc";\n\n\n\nrun(token);\n';
+        const upper = (content, { file, options }) => `${options.prefix || ''}${file}\n${content.toUpperCase()}`;
+        const first = new TransformPipeline(['normalize-whitespace', { header: { prefix: '# ' } }], { transforms: { header: upper } });
+        expect(first.apply(source, { relativePath: path.join('src', 'a.js'), options: {} }))
+            .toBe('# src/a.js\n// NOTE\nCONST TOKEN = "ABC";\n\nRUN(TOKEN);\n');
+
+        const stripper = new ContentStripper({ mode: 'comments' });
+        const second = new TransformPipeline([{ header: {} }, 'strip'], { transforms: { header: upper } });
+        expect(second.apply(source, { relativePath: 'a.js', options: { stripper } })).not.toContain('NOTE');
+        expect(second.hasCustom()).toBe(true);
+
+        expect(() => new TransformPipeline(['broken'], { transforms: { broken: () => null } }).apply('x', { relativePath: 'a.js', options: {} }))
+            .toThrow('Transform "broken" returned null for a.js, not a string');
+        expect(() => registerTransform('strip', content => content)).toThrow('it is built in');
+    });
+
+    test('profiles list transforms', () => {
+        const profiles = ContextProfiles.parse([
+            'profiles:',
+            '  tidy:',
+            '    transforms:',
+            '      - strip:',
+            '          mode: comments',
+            '      - normalize-whitespace',
+            '  review:',
+            '    extends: tidy'
+        ].join('\n'));
+        expect(profiles.resolve('review').transforms).toEqual([{ strip: { mode: 'comments' } }, 'normalize-whitespace']);
+        expect(() => ContextProfiles.parse('profiles:\n  bad:\n    transforms: strip,strip'))
+            .toThrow('profile "bad" has invalid transforms: transform "strip" is listed twice');
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-transforms-'));
+            fs.mkdirSync(path.join(root, 'src'));
+            fs.writeFileSync(path.join(root, 'src', 'app.js'), 'export function app() {  \n\n\n\n\n  return 1;\n}\n');
+            fs.writeFileSync(path.join(root, 'context.yaml'), 'profiles:\n  tidy:\n    transforms:\n      - normalize-whitespace\n      - banner\n');
+            registerTransform('banner', {
+                apply: (content, { file }) => `/* ${file} */\n${content}`,
+                analyze: false
+            });
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('reads and counts files through the pipeline', () => {
+            const file = path.join(root, 'src', 'app.js');
+            const [plain] = new TokenCalculator(root, {}).analyzeFiles([file]);
+            const options = new TransformPipeline(['normalize-whitespace', 'banner']).configure({}, root);
+            const calculator = new TokenCalculator(root, options);
+
+            expect(calculator.readFile(file)).toBe('/* src/app.js */\nexport function app() {\n\n  return 1;\n}\n');
+            // banner does not count toward analysis tokens; normalize-whitespace does
+            const [analyzed] = calculator.analyzeFiles([file]);
+            expect(plain.lines - analyzed.lines).toBe(3);
+            expect(analyzed.tokens).toBeLessThan(plain.tokens);
+        });
+
+        test('ContextEngine applies the profile transforms', async () => {
+            const engine = new ContextEngine(root);
+            const analysis = await engine.analyze({ profile: 'tidy' });
+            const selection = await engine.pack(analysis);
+            const output = await engine.render(selection, { format: 'gitingest' });
+            expect(output).toContain('/* src/app.js */\nexport function app() {\n\n  return 1;\n}');
+        });
+    });
+});
@@ -0,0 +1,108 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import TreeCollapser from '../lib/core/TreeCollapser.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+// Six files in each of the directories
+const DIRS = ['src/core', 'src/util', 'legacy/a', 'legacy/b', 'docs'];
+const FILES = DIRS.flatMap(dir => [1, 2, 3, 4, 5, 6].map(i => ({ relativePath: `${dir}/f${i}.js`, tokens: 100 })));
+
+// Priorities like TokenBudget.filePriority: src/core highest, docs lowest
+const PRIORITIES = { 'src/core': 18, 'src/util': 15, 'legacy/a': 0, 'legacy/b': 0, docs: -3 };
+const priorityOf = fileInfo => PRIORITIES[path.posix.dirname(fileInfo.relativePath)];
+const lines = text => text.split('\n').filter(Boolean);
+
+describe('TreeCollapser', () => {
+    test('expands only directories of included files', () => {
+        const included = new Set(['src/core/f1.js', 'src/core/f2.js', 'docs/f1.js']);
+        const result = new TreeCollapser({ maxTokens: 10000 }).layout(FILES, { included, rootName: 'app', priorityOf });
+        expect(lines(result.text)).toEqual([
+            '└── app/',
+            '    ├── docs/',
+            '    │   ├── f1.js',
+            '    │   └── … 5 other files (500 tokens)',
+            '    ├── legacy/ (12 files, 1.2K tokens)',
+            '    └── src/',
+            '        ├── core/',
+            '        │   ├── f1.js',
+            '        │   ├── f2.js',
+            '        │   └── … 4 other files (400 tokens)',
+            '        └── util/ (6 files, 600 tokens)'
+        ]);
+        expect(result).toMatchObject({ threshold: null, files: 30, shown: 3, collapsed: 0 });
+    });
+
+    test('collapses directories below a rising priority threshold until the tree fits', () => {
+        const included = new Set(FILES.map(file => file.relativePath));
+        const collapser = new TreeCollapser();
+        const full = collapser.layout(FILES, { included, rootName: 'app', priorityOf });
+        expect(full.shown).toBe(30);
+
+        collapser.options.maxTokens = full.tokens - 1;
+        const fitted = collapser.layout(FILES, { included, rootName: 'app', priorityOf });
+        expect(fitted.tokens).toBeLessThanOrEqual(collapser.options.maxTokens);
+        expect(fitted.threshold).toBe(0);
+        expect(lines(fitted.text)).toContain('    ├── docs/ (6 files, 600 tokens, 6 included)');
+        expect(lines(fitted.text)).toContain('    ├── legacy/');
+        expect(collapser.describe(fitted)).toBe(`🌳 Tree: 24 of 30 files listed, 1 directory collapsed below priority 0 (${fitted.tokens.toLocaleString()} of ${collapser.options.maxTokens.toLocaleString()} tokens)`);
+
+        // Parents rank by their best file, so src/ outlasts its lower-priority util/
+        collapser.options.maxTokens = 90;
+        const small = collapser.layout(FILES, { included, rootName: 'app', priorityOf });
+        expect(small.threshold).toBe(18);
+        expect(lines(small.text)).toEqual([
+            '└── app/',
+            '    ├── docs/ (6 files, 600 tokens, 6 included)',
+            '    ├── legacy/ (12 files, 1.2K tokens, 12 included)',
+            '    └── src/',
+            '        ├── core/',
+            '        │   ├── f1.js',
+            '        │   ├── f2.js',
+            '        │   ├── f3.js',
+            '        │   ├── f4.js',
+            '        │   ├── f5.js',
+            '        │   └── f6.js',
+            '        └── util/ (6 files, 600 tokens, 6 included)'
+        ]);
+
+        // Merged as a last resort
+        collapser.options.maxTokens = 10;
+        const tiny = collapser.layout(FILES, { included, rootName: 'app', priorityOf });
+        expect(lines(tiny.text)).toEqual(['└── app/', '    └── … 3 directories (30 files, 3.0K tokens, 30 included)']);
+        expect(tiny.merged).toBe(true);
+    });
+
+    describe('TokenCalculator integration', () => {
+        let root;
+
+        beforeAll(() => {
+            root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-tree-'));
+            for (const file of FILES) {
+                fs.mkdirSync(path.join(root, path.dirname(file.relativePath)), { recursive: true });
+                fs.writeFileSync(path.join(root, file.relativePath), `export const value = '${file.relativePath}';\n`);
+            }
+        });
+
+        afterAll(() => {
+            fs.rmSync(root, { recursive: true, force: true });
+        });
+
+        test('lays out the digest tree of every analyzed file within the budget', () => {
+            const calculator = new TokenCalculator(root, { treeCollapser: new TreeCollapser({ maxTokens: 200 }) });
+            const analyzed = calculator.analyzeFiles(calculator.scanProject());
+            calculator.analyzedResults = analyzed;
+            const exported = analyzed.filter(fileInfo => fileInfo.relativePath.startsWith(path.join('src', 'core')));
+
+            const digest = calculator.createGitIngestFormatter(exported).generateDigest();
+            const tree = digest.slice(digest.indexOf('Directory structure:'), digest.indexOf('\n\n', digest.indexOf('Directory structure:')));
+            expect(tree).toContain(`└── ${path.basename(root)}/`);
+            expect(tree).toContain('    ├── legacy/ (12 files, ');
+            expect(tree).toContain('        ├── core/');
+            expect(tree).toContain('        │   ├── f1.js');
+            expect(tree).toContain('        └── util/ (6 files, ');
+            expect(calculator.calculateTokens(tree)).toBeLessThanOrEqual(200);
+        });
+    });
+});
@@ -0,0 +1,363 @@
+import { describe, test, expect } from 'vitest';
+import TreeSitterBackend from '../lib/symbols/TreeSitterBackend.js';
+import GoPlugin from '../lib/languages/GoPlugin.js';
+import JavaPlugin from '../lib/languages/JavaPlugin.js';
+import PythonPlugin from '../lib/languages/PythonPlugin.js';
+import RustPlugin from '../lib/languages/RustPlugin.js';
+import TypeScriptPlugin from '../lib/languages/TypeScriptPlugin.js';
+
+// Tree-sitter is an optional dependency, so the syntax trees below are written
+// by hand in the shape its grammars produce and fed through the real backend.
+// Fields that differ by design (signatures) are left out of the comparison.
+const COMPARED_FIELDS = ['name', 'kind', 'parent', 'startLine', 'endLine', 'exported', 'doc', 'implements'];
+
+/**
+ * A node spec: `range` is a string found after the previous sibling, or
+ * [firstLine, lastLine, startText?] running to the end of lastLine
+ */
+const n = (type, range, children = []) => ({ type, range, children });
+const field = (name, type, range, children = []) => ({ ...n(type, range, children), field: name });
+
+/**
+ * Build a tree with the node API the backend and plugins use
+ */
+function buildTree(source, spec) {
+    const lines = source.split('\n');
+    const offsets = [];
+    lines.reduce((offset, line) => (offsets.push(offset), offset + line.length + 1), 0);
+    const position = index => {
+        const row = offsets.findLastIndex(offset => offset <= index);
+        return { row, column: index - offsets[row] };
+    };
+    const locate = (range, from) => {
+        if (typeof range === 'string') {
+            const start = source.indexOf(range, from);
+            if (start === -1) throw new Error(`fixture has no "${range}" after ${from}`);
+            return [start, start + range.length];
+        }
+        const [first, last, startText] = range;
+        const line = lines[first - 1];
+        const start = offsets[first - 1] + (startText ? line.indexOf(startText) : line.search(/\S/));
+        return [start, offsets[last - 1] + lines[last - 1].trimEnd().length];
+    };
+    const build = (spec, from, parent) => {
+        const [startIndex, endIndex] = spec.range ? locate(spec.range, from) : [0, source.length];
+        const fields = {};
+        const node = {
+            type: spec.type,
+            parent,
+            startIndex,
+            endIndex,
+            startPosition: position(startIndex),
+            endPosition: position(endIndex),
+            text: source.slice(startIndex, endIndex),
+            childForFieldName: name => fields[name] || null
+        };
+        let cursor = startIndex;
+        node.namedChildren = spec.children.map(childSpec => {
+            const child = build(childSpec, cursor, node);
+            cursor = child.endIndex;
+            if (childSpec.field) fields[childSpec.field] = child;
+            return child;
+        });
+        node.namedChildren.forEach((child, i) => { child.previousNamedSibling = node.namedChildren[i - 1] || null; });
+        return node;
+    };
+    return build(spec, 0, null);
+}
+
+/**
+ * Symbols from both backends, projected onto the compared fields
+ */
+function extractBoth(plugin, filePath, source, spec) {
+    const grammar = plugin.getTreeSitterGrammar(filePath);
+    const backend = new TreeSitterBackend();
+    backend.Parser = class {
+        setLanguage(language) { this.language = language; }
+        parse(content) { return { rootNode: this.language(content) }; }
+    };
+    backend.available = true;
+    backend.grammars.set(grammar.export ? `${grammar.package}#${grammar.export}` : grammar.package, content => buildTree(content, spec));
+
+    const project = symbols => symbols
+        .map(symbol => Object.fromEntries(COMPARED_FIELDS.map(key => [key, symbol[key] ?? null])))
+        .sort((a, b) => a.startLine - b.startLine || a.name.localeCompare(b.name));
+    return { treeSitter: project(backend.extract(source, filePath, plugin)), heuristic: project(plugin.extractSymbols(source, filePath)) };
+}
+
+describe('tree-sitter backend parity', () => {
+    test('Go', () => {
+        const source = [
+            'package shapes',
+            '',
+            '// Shape has an area.',
+            'type Shape interface {',
+            '\tArea() float64',
+            '}',
+            '',
+            '// Circle is round.',
+            'type Circle struct {',
+            '\tR float64',
+            '}',
+            '',
+            '// Area computes the area.',
+            'func (c *Circle) Area() float64 {',
+            '\treturn 3.14 * c.R * c.R',
+            '}',
+            '',
+            'func helper() {}'
+        ].join('\n');
+        const tree = n('source_file', null, [
+            n('package_clause', [1, 1], [n('package_identifier', 'shapes')]),
+            n('comment', [3, 3]),
+            n('type_declaration', [4, 6], [
+                n('type_spec', [4, 6, 'Shape'], [
+                    field('name', 'type_identifier', 'Shape'),
+                    field('type', 'interface_type', [4, 6, 'interface'], [
+                        n('method_elem', [5, 5], [field('name', 'field_identifier', 'Area'), field('result', 'type_identifier', 'float64')])
+                    ])
+                ])
+            ]),
+            n('comment', [8, 8]),
+            n('type_declaration', [9, 11], [
+                n('type_spec', [9, 11, 'Circle'], [field('name', 'type_identifier', 'Circle'), field('type', 'struct_type', [9, 11, 'struct'])])
+            ]),
+            n('comment', [13, 13]),
+            n('method_declaration', [14, 16], [
+                field('receiver', 'parameter_list', '(c *Circle)'),
+                field('name', 'field_identifier', 'Area'),
+                field('body', 'block', [14, 16, '{'])
+            ]),
+            n('function_declaration', [18, 18], [field('name', 'identifier', 'helper'), field('body', 'block', '{}')])
+        ]);
+
+        const { treeSitter, heuristic } = extractBoth(new GoPlugin(), 'shapes/a.go', source, tree);
+        expect(treeSitter).toEqual(heuristic);
+        expect(treeSitter.map(s => `${s.parent ? `${s.parent}.` : ''}${s.name}`)).toEqual(['shapes', 'Shape', 'Shape.Area', 'Circle', 'Circle.Area', 'helper']);
+    });
+
+    test('Java', () => {
+        const source = [
+            'package app;',
+            '',
+            '/** Greets people. */',
+            'public class Greeter implements Runnable {',
+            '    private String name;',
+            '',
+            '    public Greeter(String name) {',
+            '        this.name = name;',
+            '    }',
+            '',
+            '    /** Runs it. */',
+            '    public void run() {',
+            '    }',
+            '',
+            '    private void hidden() {}',
+            '}'
+        ].join('\n');
+        const tree = n('program', null, [
+            n('package_declaration', [1, 1], [n('identifier', 'app')]),
+            n('block_comment', [3, 3]),
+            n('class_declaration', [4, 16], [
+                n('modifiers', 'public'),
+                field('name', 'identifier', 'Greeter'),
+                field('interfaces', 'super_interfaces', 'implements Runnable', [n('type_list', 'Runnable', [n('type_identifier', 'Runnable')])]),
+                field('body', 'class_body', [4, 16, '{'], [
+                    n('field_declaration', [5, 5], [n('modifiers', 'private')]),
+                    n('constructor_declaration', [7, 9], [
+                        n('modifiers', 'public'),
+                        field('name', 'identifier', 'Greeter'),
+                        field('body', 'constructor_body', [7, 9, '{'])
+                    ]),
+                    n('block_comment', [11, 11]),
+                    n('method_declaration', [12, 13], [
+                        n('modifiers', 'public'),
+                        field('name', 'identifier', 'run'),
+                        field('body', 'block', [12, 13, '{'])
+                    ]),
+                    n('method_declaration', [15, 15], [
+                        n('modifiers', 'private'),
+                        field('name', 'identifier', 'hidden'),
+                        field('body', 'block', '{}')
+                    ])
+                ])
+            ])
+        ]);
+
+        const { treeSitter, heuristic } = extractBoth(new JavaPlugin(), 'app/Greeter.java', source, tree);
+        expect(treeSitter).toEqual(heuristic);
+        expect(treeSitter.find(s => s.name === 'run')).toMatchObject({ parent: 'Greeter', doc: 'Runs it.', exported: true });
+    });
+
+    test('Python', () => {
+        const source = [
+            'class Greeter:',
+            '    """Greets people."""',
+            '',
+            '    def greet(self, name):',
+            '        return name',
+            '',
+            '    def _hidden(self):',
+            '        pass',
+            '',
+            '',
+            '@cache',
+            'def helper():',
+            '    """Helps."""',
+            '    return 1'
+        ].join('\n');
+        const tree = n('module', null, [
+            n('class_definition', [1, 8], [
+                field('name', 'identifier', 'Greeter'),
+                field('body', 'block', [2, 8], [
+                    n('expression_statement', [2, 2], [n('string', [2, 2])]),
+                    n('function_definition', [4, 5], [
+                        field('name', 'identifier', 'greet'),
+                        field('parameters', 'parameters', '(self, name)'),
+                        field('body', 'block', [5, 5], [n('return_statement', [5, 5])])
+                    ]),
+                    n('function_definition', [7, 8], [
+                        field('name', 'identifier', '_hidden'),
+                        field('parameters', 'parameters', '(self)'),
+                        field('body', 'block', [8, 8], [n('pass_statement', [8, 8])])
+                    ])
+                ])
+            ]),
+            n('decorated_definition', [11, 14], [
+                n('decorator', [11, 11], [n('identifier', 'cache')]),
+                field('definition', 'function_definition', [12, 14], [
+                    field('name', 'identifier', 'helper'),
+                    field('parameters', 'parameters', '()'),
+                    field('body', 'block', [13, 14], [
+                        n('expression_statement', [13, 13], [n('string', [13, 13])]),
+                        n('return_statement', [14, 14])
+                    ])
+                ])
+            ])
+        ]);
+
+        const { treeSitter, heuristic } = extractBoth(new PythonPlugin(), 'app/greeter.py', source, tree);
+        expect(treeSitter).toEqual(heuristic);
+        expect(treeSitter.find(s => s.name === 'helper')).toMatchObject({ startLine: 11, doc: 'Helps.' });
+    });
+
+    test('Rust', () => {
+        const source = [
+            '/// A point.',
+            'pub struct Point {',
+            '    x: i32,',
+            '}',
+            '',
+            'pub trait Shape {',
+            '    fn area(&self) -> f64;',
+            '}',
+            '',
+            'impl Shape for Point {',
+            '    fn area(&self) -> f64 {',
+            '        0.0',
+            '    }',
+            '}',
+            '',
+            'fn helper() {}'
+        ].join('\n');
+        const tree = n('source_file', null, [
+            n('line_comment', [1, 1]),
+            n('struct_item', [2, 4], [
+                n('visibility_modifier', 'pub'),
+                field('name', 'type_identifier', 'Point'),
+                field('body', 'field_declaration_list', [2, 4, '{'], [n('field_declaration', [3, 3, 'x'])])
+            ]),
+            n('trait_item', [6, 8], [
+                n('visibility_modifier', 'pub'),
+                field('name', 'type_identifier', 'Shape'),
+                field('body', 'declaration_list', [6, 8, '{'], [
+                    n('function_signature_item', [7, 7], [field('name', 'identifier', 'area'), field('parameters', 'parameters', '(&self)')])
+                ])
+            ]),
+            n('impl_item', [10, 14], [
+                field('trait', 'type_identifier', 'Shape'),
+                field('type', 'type_identifier', 'Point'),
+                field('body', 'declaration_list', [10, 14, '{'], [
+                    n('function_item', [11, 13], [
+                        field('name', 'identifier', 'area'),
+                        field('parameters', 'parameters', '(&self)'),
+                        field('body', 'block', [11, 13, '{'])
+                    ])
+                ])
+            ]),
+            n('function_item', [16, 16], [field('name', 'identifier', 'helper'), field('body', 'block', '{}')])
+        ]);
+
+        const { treeSitter, heuristic } = extractBoth(new RustPlugin(), 'src/shapes.rs', source, tree);
+        expect(treeSitter).toEqual(heuristic);
+        expect(treeSitter.find(s => s.parent === 'Point')).toMatchObject({ name: 'area', implements: ['Shape'], exported: true });
+    });
+
+    test('TypeScript', () => {
+        const source = [
+            '/** Greets. */',
+            'export class Greeter implements Named {',
+            '    greet(name: string): string {',
+            '        return name;',
+            '    }',
+            '',
+            '    private hidden() {}',
+            '}',
+            '',
+            'export interface Named {',
+            '    name(): string;',
+            '}',
+            '',
+            'export const add = (a: number, b: number) => a + b;',
+            '',
+            'function helper() {}'
+        ].join('\n');
+        const tree = n('program', null, [
+            n('comment', [1, 1]),
+            n('export_statement', [2, 8], [
+                n('class_declaration', [2, 8, 'class'], [
+                    field('name', 'type_identifier', 'Greeter'),
+                    n('class_heritage', 'implements Named', [n('implements_clause', 'implements Named', [n('type_identifier', 'Named')])]),
+                    field('body', 'class_body', [2, 8, '{'], [
+                        n('method_definition', [3, 5], [
+                            field('name', 'property_identifier', 'greet'),
+                            field('parameters', 'formal_parameters', '(name: string)'),
+                            field('body', 'statement_block', [3, 5, '{'])
+                        ]),
+                        n('method_definition', [7, 7], [
+                            n('accessibility_modifier', 'private'),
+                            field('name', 'property_identifier', 'hidden'),
+                            field('parameters', 'formal_parameters', '()'),
+                            field('body', 'statement_block', '{}')
+                        ])
+                    ])
+                ])
+            ]),
+            n('export_statement', [10, 12], [
+                n('interface_declaration', [10, 12, 'interface'], [
+                    field('name', 'type_identifier', 'Named'),
+                    field('body', 'interface_body', [10, 12, '{'], [
+                        n('method_signature', [11, 11], [field('name', 'property_identifier', 'name'), field('parameters', 'formal_parameters', '()')])
+                    ])
+                ])
+            ]),
+            n('export_statement', [14, 14], [
+                n('lexical_declaration', [14, 14, 'const'], [
+                    n('variable_declarator', 'add = (a: number, b: number) => a + b', [
+                        field('name', 'identifier', 'add'),
+                        field('value', 'arrow_function', '(a: number, b: number) => a + b')
+                    ])
+                ])
+            ]),
+            n('function_declaration', [16, 16], [
+                field('name', 'identifier', 'helper'),
+                field('parameters', 'formal_parameters', '()'),
+                field('body', 'statement_block', '{}')
+            ])
+        ]);
+
+        const { treeSitter, heuristic } = extractBoth(new TypeScriptPlugin(), 'src/greeter.ts', source, tree);
+        expect(treeSitter).toEqual(heuristic);
+        expect(treeSitter.find(s => s.name === 'Greeter')).toMatchObject({ doc: 'Greets.', implements: ['Named'], exported: true });
+    });
+});
@@ -0,0 +1,71 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import UsageExamples from '../lib/graph/UsageExamples.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    'src/calc.js': 'export function add(a, b) {\n    return a + b;\n}\n',
+    'src/app.js': [
+        "import { add } from './calc.js';",
+        '',
+        'export function total(items) {',
+        '    return items.reduce((sum, item) => add(sum, item.price), 0);',
+        '}',
+        '',
+        'export function twice(x) {',
+        '    const once = add(x, x);',
+        '    return add(once, 0);',
+        '}',
+        ''
+    ].join('\n'),
+    'src/report.js': "import { add } from './calc.js';\n\nexport const label = add('total: ', 42);\n",
+    'test/calc.test.js': "import { add } from '../src/calc.js';\n\ntest('adds', () => {\n    expect(add(1, 2)).toBe(3);\n});\n"
+};
+
+describe('UsageExamples', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-usage-'));
+        for (const [name, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, name)), { recursive: true });
+            fs.writeFileSync(path.join(root, name), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    test('picks uses with new argument shapes from code and tests', () => {
+        const calculator = new TokenCalculator(root);
+        const results = calculator.analyzeFiles(calculator.scanProject());
+        const { graph, byPath } = calculator.buildDependencyGraph(results);
+        const add = graph.getFile('src/calc.js').symbols.find(symbol => symbol.name === 'add');
+
+        const [found] = new UsageExamples().find(graph, [...byPath.keys()], [add]);
+        expect(found.uses).toBe(5);
+        expect(found.examples.map(example => [example.file, example.startLine, example.endLine, example.test, example.shape])).toEqual([
+            ['src/app.js', 3, 5, false, '(identifier, identifier)'],
+            ['src/report.js', 1, 3, false, '(string, number)'],
+            ['test/calc.test.js', 3, 5, true, '(number, number)']
+        ]);
+
+        const [one] = new UsageExamples({ limit: 1 }).find(graph, [...byPath.keys()], [add]);
+        expect(one.examples.map(example => example.file)).toEqual(['src/app.js']);
+        expect(UsageExamples.formatExamples([one])).toContain('add (function, src/calc.js:1): 1 of 5 uses');
+    });
+
+    test('adds the snippets next to the selected symbol, from code and tests', () => {
+        const calculator = new TokenCalculator(root, { symbols: ['add'], usageExamples: new UsageExamples({ limit: 2 }) });
+        const exported = calculator.selectExportResults(calculator.analyzeFiles(calculator.scanProject()));
+        const byPath = new Map(exported.map(fileInfo => [fileInfo.relativePath.split(path.sep).join('/'), fileInfo]));
+
+        expect([...byPath.keys()]).toEqual(['src/calc.js', 'src/app.js', 'test/calc.test.js']);
+        expect(byPath.get('src/app.js').selectionNote).toBe('Usage examples of add');
+        expect(byPath.get('src/app.js').selectedSymbols).toEqual([{ name: 'add', kind: 'usage', startLine: 3, endLine: 5 }]);
+        expect(calculator.generateLLMContext(exported).usageExamples[0]).toMatchObject({ name: 'add', uses: 5 });
+    });
+});
@@ -0,0 +1,91 @@
+import { describe, test, expect, beforeAll, afterAll } from 'vitest';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import Workspace from '../lib/core/Workspace.js';
+import { ProjectIndex } from '../lib/graph/DependencyGraph.js';
+import { GoPlugin } from '../lib/languages/GoPlugin.js';
+import { goWorkUses } from '../lib/languages/GoPackages.js';
+import TokenCalculator from '../lib/analyzers/token-calculator.js';
+
+const FILES = {
+    'service/go.mod': 'module example.com/service\n\nrequire example.com/shared v0.0.0\n',
+    'service/cmd/main.go': 'package main\n\nimport "example.com/shared/text"\n\nfunc main() {\n\ttext.Upper("x")\n}\n',
+    'service/tools/go.mod': 'module example.com/service/tools\n',
+    'service/tools/gen.go': 'package tools\n',
+    'shared-lib/go.mod': 'module example.com/shared\n',
+    'shared-lib/.gitignore': 'generated/\n',
+    'shared-lib/text/text.go': 'package text\n\nfunc Upper(s string) string {\n\treturn s\n}\n\nfunc Unused() {}\n',
+    'shared-lib/generated/big.go': 'package generated\n'
+};
+
+describe('Workspace', () => {
+    let root;
+
+    beforeAll(() => {
+        root = fs.mkdtempSync(path.join(os.tmpdir(), 'ctxman-workspace-'));
+        for (const [file, content] of Object.entries(FILES)) {
+            fs.mkdirSync(path.dirname(path.join(root, file)), { recursive: true });
+            fs.writeFileSync(path.join(root, file), content);
+        }
+    });
+
+    afterAll(() => {
+        fs.rmSync(root, { recursive: true, force: true });
+    });
+
+    const manifest = repos => Workspace.parse(JSON.stringify({ repos }), path.join(root, 'service', 'ctxman.workspace.json'));
+
+    test('maps repository files to prefixed paths and back', () => {
+        const workspace = manifest([{ path: '.', prefix: 'service' }, { path: '../shared-lib', prefix: 'libs/shared' }]);
+
+        const file = path.join(root, 'shared-lib', 'text', 'text.go');
+        expect(workspace.relativePath(file)).toBe(path.join('libs', 'shared', 'text', 'text.go'));
+        expect(workspace.absolutePath('libs/shared/text/text.go')).toBe(file);
+        expect(workspace.absolutePath('service')).toBe(path.join(root, 'service'));
+        expect(workspace.relativePath(path.join(root, 'elsewhere.go'))).toBeNull();
+        expect(workspace.absolutePath('go.mod')).toBeNull();
+    });
+
+    test('defaults prefixes to directory names and rejects overlapping ones', () => {
+        expect(manifest(['.', '../shared-lib']).repos.map(repo => repo.prefix)).toEqual(['service', 'shared-lib']);
+        expect(() => manifest([{ path: '.', prefix: 'lib' }, { path: '../shared-lib', prefix: 'lib/shared' }])).toThrow('overlaps');
+        expect(() => manifest([{ path: '.', prefix: '.' }, '../shared-lib'])).toThrow('overlaps');
+        expect(() => manifest(['../missing'])).toThrow('not a directory');
+        expect(() => Workspace.parse('{}')).toThrow('expected a "repos" list');
+    });
+
+    test('reads go.work use directives', () => {
+        expect(goWorkUses('go 1.22\n\nuse ./a\nuse (\n\t./b // shared\n\t"./c d"\n)\n')).toEqual(['./b', './c d', './a']);
+
+        const workspace = Workspace.parseGoWork('use (\n\t./service\n\t./service/tools\n\t./shared-lib\n)\n', path.join(root, 'go.work'));
+        expect(workspace.repos).toEqual([
+            { root: path.join(root, 'service'), prefix: 'service' },
+            { root: path.join(root, 'shared-lib'), prefix: 'shared-lib' }
+        ]);
+    });
+
+    test('scans every repository with its own ignore files', () => {
+        const workspace = manifest([{ path: '.', prefix: 'service' }, { path: '../shared-lib', prefix: 'shared' }]);
+        const calculator = new TokenCalculator(path.join(root, 'service'), { workspace });
+        const results = calculator.analyzeFiles(calculator.scanProject());
+
+        expect(results.map(fileInfo => fileInfo.relativePath.split(path.sep).join('/')).sort())
+            .toEqual(['service/cmd/main.go', 'service/tools/gen.go', 'shared/text/text.go']);
+        expect(calculator.stats.ignoredFiles).toBe(1);
+        expect(Object.keys(calculator.stats.byDirectory).sort()).toEqual(['service', 'shared']);
+
+        const { graph } = calculator.buildDependencyGraph(results);
+        expect(graph.getDependencies('service/cmd')).toEqual(['shared/text']);
+    });
+
+    test('resolves imports of other workspace modules without the go command', () => {
+        const workspace = manifest([{ path: '.', prefix: 'service' }, { path: '../shared-lib', prefix: 'shared' }]);
+        const project = new ProjectIndex(path.join(root, 'service'), ['service/cmd/main.go', 'shared/text/text.go'], { workspace });
+        project.memos.set('go-packages', null);
+
+        const plugin = new GoPlugin();
+        expect(plugin.resolveImport({ source: 'example.com/shared/text' }, 'service/cmd/main.go', project)).toBe('shared/text');
+        expect(plugin.resolveImport({ source: 'example.com/other/text' }, 'service/cmd/main.go', project)).toBeNull();
+    });
+});